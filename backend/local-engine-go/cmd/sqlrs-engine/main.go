@@ -11,24 +11,32 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"math/big"
 	"net"
 	"net/http"
 	"os"
 	"os/exec"
 	"os/signal"
 	"path/filepath"
+	goruntime "runtime"
 	"runtime/debug"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"syscall"
 	"time"
 
+	"google.golang.org/grpc"
+
+	"github.com/sqlrs/engine-local/internal/auth"
 	"github.com/sqlrs/engine-local/internal/config"
 	"github.com/sqlrs/engine-local/internal/conntrack"
 	"github.com/sqlrs/engine-local/internal/dbms"
 	"github.com/sqlrs/engine-local/internal/deletion"
+	"github.com/sqlrs/engine-local/internal/grpcapi"
 	"github.com/sqlrs/engine-local/internal/httpapi"
+	"github.com/sqlrs/engine-local/internal/loglevel"
 	"github.com/sqlrs/engine-local/internal/prepare"
 	"github.com/sqlrs/engine-local/internal/prepare/queue"
 	"github.com/sqlrs/engine-local/internal/registry"
@@ -36,15 +44,21 @@ import (
 	engineRuntime "github.com/sqlrs/engine-local/internal/runtime"
 	"github.com/sqlrs/engine-local/internal/statefs"
 	"github.com/sqlrs/engine-local/internal/store/sqlite"
+	"github.com/sqlrs/engine-local/internal/tracing"
 )
 
 type EngineState struct {
-	Endpoint   string `json:"endpoint"`
-	PID        int    `json:"pid"`
-	StartedAt  string `json:"startedAt"`
-	AuthToken  string `json:"authToken"`
-	Version    string `json:"version"`
-	InstanceID string `json:"instanceId"`
+	Endpoint  string `json:"endpoint"`
+	PID       int    `json:"pid"`
+	StartedAt string `json:"startedAt"`
+	AuthToken string `json:"authToken"`
+	// AuthTokenFile points at the file holding the token when the engine runs
+	// with --auth-token-file; AuthToken is empty then.
+	AuthTokenFile string `json:"authTokenFile,omitempty"`
+	Version       string `json:"version"`
+	InstanceID    string `json:"instanceId"`
+	// GRPCEndpoint is set when the engine also serves gRPC (--grpc-listen).
+	GRPCEndpoint string `json:"grpcEndpoint,omitempty"`
 }
 
 type activityTracker struct {
@@ -81,7 +95,11 @@ func (a *activityTracker) FinishRequest() {
 }
 
 func (a *activityTracker) HasInflightRequests() bool {
-	return atomic.LoadInt64(&a.inflight) > 0
+	return a.InflightRequests() > 0
+}
+
+func (a *activityTracker) InflightRequests() int64 {
+	return atomic.LoadInt64(&a.inflight)
 }
 
 func (a *activityTracker) IdleFor() time.Duration {
@@ -120,6 +138,19 @@ func (r *statusRecorderFlusher) Flush() {
 	}
 }
 
+// logHTTPRequest writes the access log line for a request. Successful
+// requests are logged at info; 4xx and 5xx responses are logged at warn.
+func logHTTPRequest(level string, r *http.Request, status int, bytes int, dur time.Duration) {
+	severity := loglevel.Info
+	if status >= http.StatusBadRequest {
+		severity = loglevel.Warn
+	}
+	if !loglevel.Allows(level, severity) {
+		return
+	}
+	log.Printf("http request method=%s path=%s status=%d bytes=%d dur=%s remote=%s", r.Method, r.URL.RequestURI(), status, bytes, dur.Truncate(time.Millisecond), r.RemoteAddr)
+}
+
 // containerRuntimeFromConfig resolves container.runtime from engine config.
 // Allowed values: auto, docker, podman. Invalid or missing values fall back to auto.
 func containerRuntimeFromConfig(cfg config.Store) string {
@@ -137,34 +168,156 @@ func containerRuntimeFromConfig(cfg config.Store) string {
 	return normalizeContainerRuntimeMode(mode)
 }
 
+// registryOptionsFromConfig reads container.registry.mirror and
+// container.registry.authFile. Missing or non-string values are treated as unset.
+func registryOptionsFromConfig(cfg config.Store) (string, string) {
+	return configStringFromConfig(cfg, "container.registry.mirror"), configStringFromConfig(cfg, "container.registry.authFile")
+}
+
+func configStringFromConfig(cfg config.Store, path string) string {
+	if cfg == nil {
+		return ""
+	}
+	value, err := cfg.Get(path, true)
+	if err != nil {
+		return ""
+	}
+	str, ok := value.(string)
+	if !ok {
+		return ""
+	}
+	return strings.TrimSpace(str)
+}
+
+// ensureWorkDir creates workdir.root and checks that it is writable, so a bad
+// scratch location fails at startup rather than in the first job. An empty
+// root means job scratch stays in the state store.
+func ensureWorkDir(root string) error {
+	if root == "" {
+		return nil
+	}
+	if err := os.MkdirAll(root, 0o700); err != nil {
+		return err
+	}
+	probe, err := os.CreateTemp(root, ".write-check-*")
+	if err != nil {
+		return fmt.Errorf("%s is not writable: %v", root, err)
+	}
+	name := probe.Name()
+	_ = probe.Close()
+	return os.Remove(name)
+}
+
+var engineUID = os.Geteuid
+
+// checkStateStoreAccess writes a probe file into the state store and reads it
+// back, so a store the engine cannot use fails at startup rather than in the
+// first clone. The returned warning is non-empty when container.runAsUser is a
+// uid other than the engine's and snapshots are copies: containers then write
+// data files the copy clone of a later job may not be able to read.
+func checkStateStoreAccess(root string, containerUser string, snapshotKind string) (string, error) {
+	const content = "sqlrs state store check\n"
+	probe, err := os.CreateTemp(root, ".access-check-*")
+	if err != nil {
+		return "", fmt.Errorf("%s is not writable: %v", root, err)
+	}
+	name := probe.Name()
+	defer os.Remove(name)
+	_, err = probe.WriteString(content)
+	if closeErr := probe.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s is not writable: %v", root, err)
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return "", fmt.Errorf("%s is not readable: %v", root, err)
+	}
+	if string(data) != content {
+		return "", fmt.Errorf("%s returned different content for a probe file", root)
+	}
+	if snapshotKind != "copy" {
+		return "", nil
+	}
+	uid, _, _ := strings.Cut(containerUser, ":")
+	containerUID, err := strconv.Atoi(uid)
+	if err != nil || engineUID() < 0 || containerUID == engineUID() {
+		return "", nil
+	}
+	return fmt.Sprintf("container.runAsUser uid %d differs from the engine uid %d; copy clones may fail to read state files", containerUID, engineUID()), nil
+}
+
+// tracerFromConfig builds the span exporter for otel.endpoint. It returns nil
+// (tracing disabled) when the endpoint is unset.
+func tracerFromConfig(cfg config.Store) *tracing.Tracer {
+	return tracing.New(tracing.Options{
+		Endpoint:    configStringFromConfig(cfg, "otel.endpoint"),
+		ServiceName: "sqlrs-engine",
+	})
+}
+
+const defaultDrainTimeout = 30 * time.Second
+
+func drainTimeoutFromConfig(cfg config.Store) time.Duration {
+	if cfg == nil {
+		return defaultDrainTimeout
+	}
+	value, err := cfg.Get("shutdown.drainTimeout", true)
+	if err != nil {
+		return defaultDrainTimeout
+	}
+	raw, ok := value.(string)
+	if !ok {
+		return defaultDrainTimeout
+	}
+	timeout, err := time.ParseDuration(strings.TrimSpace(raw))
+	if err != nil || timeout < 0 {
+		return defaultDrainTimeout
+	}
+	return timeout
+}
+
 func normalizeContainerRuntimeMode(mode string) string {
 	switch strings.ToLower(strings.TrimSpace(mode)) {
 	case "docker":
 		return "docker"
 	case "podman":
 		return "podman"
+	case "nerdctl", "containerd":
+		return "nerdctl"
 	default:
 		return "auto"
 	}
 }
 
 // resolveContainerRuntimeBinary returns the executable name or path for the container runtime
-// (docker/podman). Prefers config-based mode selection, with SQLRS_CONTAINER_RUNTIME as an
-// operational override. In auto mode, tries docker then podman.
+// (docker/podman/nerdctl). Prefers config-based mode selection, with SQLRS_CONTAINER_RUNTIME as an
+// operational override. In auto mode, tries docker, then podman, then nerdctl.
 // When using podman on macOS, ensures CONTAINER_HOST is set from the default connection so
-// the runtime can reach the podman machine.
+// the runtime can reach the podman machine. On Linux without a default connection, the
+// rootless podman socket is used when present.
 func resolveContainerRuntimeBinary(mode string) string {
 	if name := strings.TrimSpace(os.Getenv("SQLRS_CONTAINER_RUNTIME")); name != "" {
 		return resolveConfiguredRuntimeBinary(name)
 	}
+	return resolveContainerRuntimeMode(mode)
+}
+
+// resolveContainerRuntimeMode is resolveContainerRuntimeBinary without the
+// SQLRS_CONTAINER_RUNTIME override. An explicit --container-runtime uses it,
+// so a stray environment variable cannot defeat the flag.
+func resolveContainerRuntimeMode(mode string) string {
 	switch normalizeContainerRuntimeMode(mode) {
 	case "docker":
 		return resolveConfiguredRuntimeBinary("docker")
 	case "podman":
 		return resolveConfiguredRuntimeBinary("podman")
+	case "nerdctl":
+		return resolveConfiguredRuntimeBinary("nerdctl")
 	}
 
-	for _, name := range []string{"docker", "podman"} {
+	for _, name := range []string{"docker", "podman", "nerdctl"} {
 		if path, err := execLookPathFn(name); err == nil {
 			return resolveConfiguredRuntimeBinary(path)
 		}
@@ -178,6 +331,15 @@ func resolveConfiguredRuntimeBinary(name string) string {
 	return binary
 }
 
+// ensureNerdctlDockerConfig points DOCKER_CONFIG at the registry credentials
+// for nerdctl, which has no flag for them, unless it is already set.
+func ensureNerdctlDockerConfig(binary string, authFile string) {
+	if authFile == "" || os.Getenv("DOCKER_CONFIG") != "" || !engineRuntime.IsNerdctlBinary(binary) {
+		return
+	}
+	_ = osSetenvFn("DOCKER_CONFIG", engineRuntime.DockerConfigDir(authFile))
+}
+
 func resolveRuntimeBinary(name string) string {
 	if filepath.IsAbs(name) {
 		return name
@@ -194,6 +356,11 @@ func ensurePodmanContainerHost(binary string) {
 	}
 	if strings.Contains(strings.ToLower(filepath.Base(binary)), "podman") {
 		conn := podmanDefaultConnection(binary)
+		if conn.URI == "" {
+			if socket := podmanRootlessSocket(); socket != "" {
+				_ = osSetenvFn("CONTAINER_HOST", "unix://"+socket)
+			}
+		}
 		if conn.URI != "" {
 			_ = osSetenvFn("CONTAINER_HOST", conn.URI)
 			if strings.HasPrefix(strings.ToLower(conn.URI), "ssh://") && os.Getenv("CONTAINER_SSHKEY") == "" {
@@ -206,6 +373,27 @@ func ensurePodmanContainerHost(binary string) {
 	}
 }
 
+// podmanRootlessSocket returns the rootless podman API socket on Linux when no
+// default connection is configured. It probes $XDG_RUNTIME_DIR/podman/podman.sock
+// first and falls back to /run/user/<uid>/podman/podman.sock.
+func podmanRootlessSocket() string {
+	if hostGOOS != "linux" {
+		return ""
+	}
+	var candidates []string
+	if dir := strings.TrimSpace(os.Getenv("XDG_RUNTIME_DIR")); dir != "" {
+		candidates = append(candidates, filepath.Join(dir, "podman", "podman.sock"))
+	}
+	candidates = append(candidates, fmt.Sprintf("/run/user/%d/podman/podman.sock", osGetuidFn()))
+	for _, path := range candidates {
+		info, err := osStatFn(path)
+		if err == nil && info.Mode()&os.ModeSocket != 0 {
+			return path
+		}
+	}
+	return ""
+}
+
 type podmanConnection struct {
 	URI      string
 	Identity string
@@ -284,6 +472,67 @@ func snapshotBackendFromConfig(cfg config.Store) string {
 	}
 }
 
+// parseContainerRuntimeFlag validates --container-runtime. Unlike the config
+// value, a typo on the command line is an error rather than a fallback to auto.
+func parseContainerRuntimeFlag(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", nil
+	}
+	switch strings.ToLower(value) {
+	case "auto", "docker", "podman", "nerdctl", "containerd":
+		return normalizeContainerRuntimeMode(value), nil
+	}
+	return "", fmt.Errorf("invalid --container-runtime %q (expected auto, docker, podman or nerdctl)", value)
+}
+
+// parseSnapshotBackendFlag validates --snapshot-backend.
+func parseSnapshotBackendFlag(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	switch value {
+	case "", "auto", "overlay", "btrfs", "copy":
+		return value, nil
+	}
+	return "", fmt.Errorf("invalid --snapshot-backend %q (expected auto, overlay, btrfs or copy)", value)
+}
+
+func stateCompressionFromConfig(cfg config.Store) string {
+	value, err := cfg.Get("statefs.compression", true)
+	if err != nil {
+		return statefs.CompressionNone
+	}
+	compression, ok := value.(string)
+	if !ok || compression != statefs.CompressionZstd {
+		return statefs.CompressionNone
+	}
+	return compression
+}
+
+func stateQuotaFromConfig(cfg config.Store) int64 {
+	value, err := cfg.Get("statefs.btrfs.quotaBytes", true)
+	if err != nil {
+		return 0
+	}
+	var quota int64
+	switch v := value.(type) {
+	case int:
+		quota = int64(v)
+	case int64:
+		quota = v
+	case float64:
+		if v != float64(int64(v)) {
+			return 0
+		}
+		quota = int64(v)
+	default:
+		return 0
+	}
+	if quota < 0 {
+		return 0
+	}
+	return quota
+}
+
 func logLevelFromConfig(cfg config.Store) string {
 	if cfg == nil {
 		return ""
@@ -300,31 +549,37 @@ func logLevelFromConfig(cfg config.Store) string {
 }
 
 func buildSummary() string {
+	info, ok := readBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	if info.Revision == "" && info.Time == "" && !info.Modified {
+		if info.GoVersion != "" {
+			return "go=" + info.GoVersion
+		}
+		return "unknown"
+	}
+	return fmt.Sprintf("rev=%s time=%s modified=%t", info.Revision, info.Time, info.Modified)
+}
+
+// readBuildInfo extracts the VCS stamp and Go version embedded by go build.
+func readBuildInfo() (httpapi.BuildInfo, bool) {
 	info, ok := debug.ReadBuildInfo()
 	if !ok || info == nil {
-		return "unknown"
+		return httpapi.BuildInfo{}, false
 	}
-	var revision, buildTime, modified string
+	build := httpapi.BuildInfo{GoVersion: info.GoVersion}
 	for _, setting := range info.Settings {
 		switch setting.Key {
 		case "vcs.revision":
-			revision = setting.Value
+			build.Revision = setting.Value
 		case "vcs.time":
-			buildTime = setting.Value
+			build.Time = setting.Value
 		case "vcs.modified":
-			modified = setting.Value
+			build.Modified = setting.Value == "true"
 		}
 	}
-	if revision == "" && buildTime == "" && modified == "" {
-		if info.GoVersion != "" {
-			return "go=" + info.GoVersion
-		}
-		return "unknown"
-	}
-	if modified == "" {
-		modified = "false"
-	}
-	return fmt.Sprintf("rev=%s time=%s modified=%s", revision, buildTime, modified)
+	return build, true
 }
 
 var serveHTTP = func(server *http.Server, listener net.Listener) error {
@@ -336,9 +591,16 @@ var randReader = rand.Reader
 var execLookPathFn = exec.LookPath
 var execCommandContextFn = exec.CommandContext
 var osSetenvFn = os.Setenv
+var osStatFn = os.Stat
+var osGetuidFn = os.Getuid
+var hostGOOS = goruntime.GOOS
 var writeFileFn = os.WriteFile
 var renameFn = os.Rename
 var idleTickerEvery = time.Second
+var sdNotifyFn = sdNotify
+var notifyReloadFn = func(c chan<- os.Signal) {
+	signal.Notify(c, syscall.SIGHUP)
+}
 var runMountCommandFn = runMountCommand
 var openDBFn = func(path string) (*sql.DB, error) {
 	if strings.TrimSpace(path) == "" {
@@ -355,12 +617,18 @@ var newPrepareServiceFn = prepare.NewPrepareService
 var prepareRecoverFn = func(mgr *prepare.PrepareService) error {
 	return mgr.Recover(context.Background())
 }
+var prepareDrainFn = func(mgr *prepare.PrepareService, ctx context.Context) error {
+	return mgr.Drain(ctx)
+}
 var newDeletionManagerFn = deletion.NewManager
 var newRunManagerFn = runpkg.NewManager
 var newHandlerFn = httpapi.NewHandler
 var serverShutdownFn = func(server *http.Server, ctx context.Context) error {
 	return server.Shutdown(ctx)
 }
+var serveGRPC = func(server *grpc.Server, listener net.Listener) error {
+	return server.Serve(listener)
+}
 var isCharDeviceFn = isCharDevice
 var jsonMarshalIndent = json.MarshalIndent
 
@@ -369,10 +637,17 @@ func run(args []string) (int, error) {
 	fs.SetOutput(io.Discard)
 
 	listenAddr := fs.String("listen", "", "listen address (host:port)")
+	grpcListenAddr := fs.String("grpc-listen", "", "also serve the gRPC API on this address (host:port)")
 	runDir := fs.String("run-dir", "", "runtime directory (unused in MVP)")
 	statePath := fs.String("write-engine-json", "", "path to engine.json")
 	idleTimeout := fs.Duration("idle-timeout", 30*time.Second, "shutdown after this idle duration")
+	idleJitterMax := fs.Duration("idle-jitter", 0, "add a random delay up to this duration to the idle timeout")
+	minUptime := fs.Duration("min-uptime", 0, "never shut down for idleness before this uptime")
 	version := fs.String("version", "dev", "engine version")
+	authTokenFileFlag := fs.String("auth-token-file", "", "keep the auth token in this file instead of engine.json (generated when missing)")
+	daemon := fs.Bool("daemon", false, "run as a long-lived service: no idle shutdown, SIGHUP reloads config, sd_notify readiness")
+	containerRuntimeFlag := fs.String("container-runtime", "", "container runtime for this process (auto, docker, podman, nerdctl); overrides container.runtime")
+	snapshotBackendFlag := fs.String("snapshot-backend", "", "snapshot backend for this process (auto, overlay, btrfs, copy); overrides snapshot.backend")
 	if err := fs.Parse(args); err != nil {
 		return 2, err
 	}
@@ -385,6 +660,14 @@ func run(args []string) (int, error) {
 	if *statePath == "" {
 		return 2, errors.New("missing --write-engine-json")
 	}
+	containerRuntimeOverride, err := parseContainerRuntimeFlag(*containerRuntimeFlag)
+	if err != nil {
+		return 2, err
+	}
+	snapshotBackendOverride, err := parseSnapshotBackendFlag(*snapshotBackendFlag)
+	if err != nil {
+		return 2, err
+	}
 	if *runDir != "" {
 		if err := os.MkdirAll(*runDir, 0o700); err != nil {
 			return 1, fmt.Errorf("create run dir: %v", err)
@@ -397,6 +680,10 @@ func run(args []string) (int, error) {
 		defer closeLog()
 	}
 	log.Printf("sqlrs-engine version=%s build=%s", *version, buildSummary())
+	if *daemon {
+		log.Printf("daemon mode: idle shutdown disabled")
+		*idleTimeout = 0
+	}
 
 	listener, err := net.Listen("tcp", *listenAddr)
 	if err != nil {
@@ -405,12 +692,26 @@ func run(args []string) (int, error) {
 	defer func() {
 		_ = listener.Close()
 	}()
+	var grpcListener net.Listener
+	if *grpcListenAddr != "" {
+		grpcListener, err = net.Listen("tcp", *grpcListenAddr)
+		if err != nil {
+			return 1, fmt.Errorf("grpc listen: %v", err)
+		}
+		defer func() {
+			_ = grpcListener.Close()
+		}()
+	}
 
 	instanceID, err := randomHex(16)
 	if err != nil {
 		return 1, fmt.Errorf("instance id: %v", err)
 	}
-	authToken, err := randomHex(32)
+	authTokenFile, err := resolveAuthTokenFile(*authTokenFileFlag)
+	if err != nil {
+		return 1, fmt.Errorf("auth token file: %v", err)
+	}
+	authToken, err := loadAuthToken(authTokenFile)
 	if err != nil {
 		return 1, fmt.Errorf("auth token: %v", err)
 	}
@@ -426,30 +727,33 @@ func run(args []string) (int, error) {
 	if err := os.MkdirAll(stateStoreRoot, 0o700); err != nil {
 		return 1, fmt.Errorf("create state store root: %v", err)
 	}
-	db, err := openDBFn(filepath.Join(stateStoreRoot, "state.db"))
+	db, store, err := openStateStore(stateStoreRoot)
 	if err != nil {
 		return 1, fmt.Errorf("open state db: %v", err)
 	}
 	defer db.Close()
 
-	store, err := newStoreFn(db)
-	if err != nil {
-		return 1, fmt.Errorf("open state db: %v", err)
-	}
-
 	queueStore, err := newQueueFn(db)
 	if err != nil {
 		return 1, fmt.Errorf("open queue db: %v", err)
 	}
 
+	startedAt := time.Now().UTC()
 	state := EngineState{
 		Endpoint:   listener.Addr().String(),
 		PID:        os.Getpid(),
-		StartedAt:  time.Now().UTC().Format(time.RFC3339Nano),
+		StartedAt:  startedAt.Format(time.RFC3339Nano),
 		AuthToken:  authToken,
 		Version:    *version,
 		InstanceID: instanceID,
 	}
+	if grpcListener != nil {
+		state.GRPCEndpoint = grpcListener.Addr().String()
+	}
+	if authTokenFile != "" {
+		state.AuthToken = ""
+		state.AuthTokenFile = authTokenFile
+	}
 	if err := writeEngineState(*statePath, state); err != nil {
 		return 1, fmt.Errorf("write engine.json: %v", err)
 	}
@@ -461,27 +765,67 @@ func run(args []string) (int, error) {
 	if err != nil {
 		return 1, fmt.Errorf("config manager: %v", err)
 	}
+	workDirRoot := configStringFromConfig(configMgr, "workdir.root")
+	if err := ensureWorkDir(workDirRoot); err != nil {
+		return 1, fmt.Errorf("work dir: %v", err)
+	}
 	reg := registry.New(store)
-	containerMode := containerRuntimeFromConfig(configMgr)
-	containerBinary := resolveContainerRuntimeBinary(containerMode)
-	rt := engineRuntime.NewDocker(engineRuntime.Options{Binary: containerBinary})
+	var containerBinary string
+	if containerRuntimeOverride != "" {
+		containerBinary = resolveContainerRuntimeMode(containerRuntimeOverride)
+	} else {
+		containerBinary = resolveContainerRuntimeBinary(containerRuntimeFromConfig(configMgr))
+	}
+	registryMirror, registryAuthFile := registryOptionsFromConfig(configMgr)
+	ensureNerdctlDockerConfig(containerBinary, registryAuthFile)
+	superuser := configStringFromConfig(configMgr, "container.postgres.superuser")
+	rt := engineRuntime.NewDocker(engineRuntime.Options{
+		Binary:           containerBinary,
+		RegistryMirror:   registryMirror,
+		RegistryAuthFile: registryAuthFile,
+		Namespace:        configStringFromConfig(configMgr, "container.namespace"),
+		Superuser:        superuser,
+		EngineID:         engineRuntime.StoreIdentity(stateStoreRoot),
+	})
+	snapshotBackend := snapshotBackendFromConfig(configMgr)
+	if snapshotBackendOverride != "" {
+		snapshotBackend = snapshotBackendOverride
+	}
 	stateFS := statefs.NewManager(statefs.Options{
-		Backend:        snapshotBackendFromConfig(configMgr),
+		Backend:        snapshotBackend,
 		StateStoreRoot: stateStoreRoot,
+		Compression:    stateCompressionFromConfig(configMgr),
+		QuotaBytes:     stateQuotaFromConfig(configMgr),
 	})
-	connector := dbms.NewPostgres(rt, dbms.WithLogLevel(func() string {
+	warning, err := checkStateStoreAccess(stateStoreRoot, configStringFromConfig(configMgr, "container.runAsUser"), stateFS.Kind())
+	if err != nil {
+		return 1, fmt.Errorf("state store: %v", err)
+	}
+	if warning != "" {
+		log.Printf("state store: %s", warning)
+	}
+	connector := dbms.NewPostgres(rt, dbms.WithUser(superuser), dbms.WithLogLevel(func() string {
 		return logLevelFromConfig(configMgr)
 	}))
+	tracer := tracerFromConfig(configMgr)
+	defer func() {
+		flushCtx, cancelFlush := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancelFlush()
+		_ = tracer.Shutdown(flushCtx)
+	}()
 	prepareSvc, err := newPrepareServiceFn(prepare.Options{
-		Store:          store,
-		Queue:          queueStore,
-		Runtime:        rt,
-		StateFS:        stateFS,
-		DBMS:           connector,
-		StateStoreRoot: stateStoreRoot,
-		Config:         configMgr,
-		Version:        *version,
-		Async:          true,
+		Store:             store,
+		Queue:             queueStore,
+		Runtime:           rt,
+		StateFS:           stateFS,
+		DBMS:              connector,
+		StateStoreRoot:    stateStoreRoot,
+		WorkDirRoot:       workDirRoot,
+		Config:            configMgr,
+		Version:           *version,
+		Async:             true,
+		Tracer:            tracer,
+		PostgresSuperuser: superuser,
 	})
 	if err != nil {
 		return 1, fmt.Errorf("prepare service: %v", err)
@@ -496,28 +840,65 @@ func run(args []string) (int, error) {
 		Runtime:        rt,
 		StateFS:        stateFS,
 		StateStoreRoot: stateStoreRoot,
+		Config:         configMgr,
 	})
 	if err != nil {
 		return 1, fmt.Errorf("delete manager: %v", err)
 	}
 
 	runMgr, err := newRunManagerFn(runpkg.Options{
-		Registry: reg,
-		Runtime:  rt,
+		Registry:      reg,
+		Runtime:       rt,
+		Superuser:     superuser,
+		ContainerUser: configStringFromConfig(configMgr, "container.runAsUser"),
 	})
 	if err != nil {
 		return 1, fmt.Errorf("run manager: %v", err)
 	}
 
+	token := auth.NewToken(authToken)
+	var rotateMu sync.Mutex
+	rotateAuth := func() (string, error) {
+		rotateMu.Lock()
+		defer rotateMu.Unlock()
+		next, err := randomHex(32)
+		if err != nil {
+			return "", err
+		}
+		if authTokenFile != "" {
+			err = writeFileAtomic(authTokenFile, []byte(next+"\n"))
+		} else {
+			state.AuthToken = next
+			err = writeEngineState(*statePath, state)
+		}
+		if err != nil {
+			return "", err
+		}
+		token.Set(next)
+		log.Printf("auth token rotated")
+		return next, nil
+	}
+
+	build, _ := readBuildInfo()
 	mux := newHandlerFn(httpapi.Options{
 		Version:    *version,
+		Build:      build,
 		InstanceID: instanceID,
 		AuthToken:  authToken,
+		Auth:       token,
+		RotateAuth: rotateAuth,
 		Registry:   reg,
 		Prepare:    prepareSvc,
 		Deletion:   deleteMgr,
 		Run:        runMgr,
 		Config:     configMgr,
+		Runtime:    rt,
+
+		StartedAt:       startedAt,
+		Activity:        activity,
+		IdleTimeout:     *idleTimeout,
+		SnapshotBackend: stateFS.Kind(),
+		StateStoreRoot:  stateStoreRoot,
 	})
 
 	server := &http.Server{
@@ -535,26 +916,49 @@ func run(args []string) (int, error) {
 			if status == 0 {
 				status = http.StatusOK
 			}
-			log.Printf("http request method=%s path=%s status=%d bytes=%d dur=%s remote=%s", r.Method, r.URL.RequestURI(), status, rec.bytes, time.Since(start).Truncate(time.Millisecond), r.RemoteAddr)
+			logHTTPRequest(logLevelFromConfig(configMgr), r, status, rec.bytes, time.Since(start))
 		}),
 	}
 
+	var grpcServer *grpc.Server
+	if grpcListener != nil {
+		grpcServer = grpcapi.NewServer(grpcapi.Options{
+			AuthToken: authToken,
+			Auth:      token,
+			Prepare:   prepareSvc,
+			Activity:  activity,
+		})
+	}
+
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
+	prepareSvc.StartSweeper(ctx)
 
 	var shutdownOnce sync.Once
 	shutdown := func(reason string) {
 		shutdownOnce.Do(func() {
 			log.Printf("shutting down: %s", reason)
+			if *daemon {
+				_ = sdNotifyFn("STOPPING=1")
+			}
+			drainCtx, cancelDrain := context.WithTimeout(context.Background(), drainTimeoutFromConfig(configMgr))
+			if err := prepareDrainFn(prepareSvc, drainCtx); err != nil {
+				log.Printf("shutdown drain: %v", err)
+			}
+			cancelDrain()
 			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 			defer cancel()
 			if err := serverShutdownFn(server, shutdownCtx); err != nil {
 				log.Printf("shutdown error: %v", err)
 			}
+			if grpcServer != nil {
+				stopGRPCServer(grpcServer, shutdownCtx)
+			}
 		})
 	}
 
 	if *idleTimeout > 0 {
+		idleThreshold := *idleTimeout + idleJitter(*idleJitterMax)
 		go func() {
 			ticker := time.NewTicker(idleTickerEvery)
 			defer ticker.Stop()
@@ -563,7 +967,7 @@ func run(args []string) (int, error) {
 				case <-ctx.Done():
 					return
 				case <-ticker.C:
-					if !activity.HasInflightRequests() && activity.IdleFor() >= *idleTimeout {
+					if idleShutdownDue(activity, prepareSvc.EventSubscribers(), time.Since(startedAt), *minUptime, idleThreshold) {
 						shutdown("idle timeout")
 						return
 					}
@@ -577,7 +981,36 @@ func run(args []string) (int, error) {
 		shutdown("signal")
 	}()
 
+	if *daemon {
+		reload := make(chan os.Signal, 1)
+		notifyReloadFn(reload)
+		defer signal.Stop(reload)
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-reload:
+					reloadConfig(configMgr)
+				}
+			}
+		}()
+	}
+
 	log.Printf("sqlrs-engine listening on %s", state.Endpoint)
+	if grpcServer != nil {
+		log.Printf("sqlrs-engine gRPC listening on %s", state.GRPCEndpoint)
+		go func() {
+			if err := serveGRPC(grpcServer, grpcListener); err != nil {
+				log.Printf("grpc server error: %v", err)
+			}
+		}()
+	}
+	if *daemon {
+		if err := sdNotifyFn("READY=1"); err != nil {
+			log.Printf("sd_notify: %v", err)
+		}
+	}
 	if err := serveHTTP(server, listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		log.Printf("server error: %v", err)
 		return 1, nil
@@ -596,6 +1029,59 @@ func main() {
 	}
 }
 
+// stopGRPCServer lets in-flight calls finish until ctx is done, then closes
+// the remaining ones, such as event streams of jobs still running.
+func stopGRPCServer(server *grpc.Server, ctx context.Context) {
+	stopped := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		server.Stop()
+	}
+}
+
+// idleShutdownDue reports whether the idle ticker may stop the engine. Open
+// event streams count as activity, and the engine stays up for at least
+// minUptime so a client connecting right after start does not race a shutdown.
+func idleShutdownDue(activity *activityTracker, subscribers int, uptime, minUptime, threshold time.Duration) bool {
+	if uptime < minUptime {
+		return false
+	}
+	if activity.HasInflightRequests() || subscribers > 0 {
+		return false
+	}
+	return activity.IdleFor() >= threshold
+}
+
+// idleJitter returns a random duration in [0, max) so several engines started
+// together do not all stop on the same tick. It returns 0 when max is not
+// positive or randomness is unavailable.
+func idleJitter(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	n, err := rand.Int(randReader, big.NewInt(int64(max)))
+	if err != nil {
+		return 0
+	}
+	return time.Duration(n.Int64())
+}
+
+// reloadConfig re-reads config.json on SIGHUP. Settings read per request or
+// per job (log level, limits, timeouts) apply right away; the container
+// runtime and snapshot backend are chosen at startup and need a restart.
+func reloadConfig(cfg interface{ Reload() error }) {
+	if err := cfg.Reload(); err != nil {
+		log.Printf("config reload failed, keeping current config: %v", err)
+		return
+	}
+	log.Printf("config reloaded")
+}
+
 func randomHex(bytes int) (string, error) {
 	buf := make([]byte, bytes)
 	if _, err := randReader.Read(buf); err != nil {
@@ -604,14 +1090,58 @@ func randomHex(bytes int) (string, error) {
 	return hex.EncodeToString(buf), nil
 }
 
-func writeEngineState(path string, state EngineState) error {
-	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
-		return err
+// resolveAuthTokenFile returns the absolute token file path from
+// --auth-token-file or SQLRS_AUTH_TOKEN_FILE; empty keeps the token inline.
+func resolveAuthTokenFile(flagValue string) (string, error) {
+	path := strings.TrimSpace(flagValue)
+	if path == "" {
+		path = strings.TrimSpace(os.Getenv("SQLRS_AUTH_TOKEN_FILE"))
+	}
+	if path == "" {
+		return "", nil
+	}
+	return filepath.Abs(path)
+}
+
+// loadAuthToken returns the pre-provisioned token in path. When the file is
+// missing or empty, or no path is set, a new token is generated and written
+// to path if there is one.
+func loadAuthToken(path string) (string, error) {
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+		if token := strings.TrimSpace(string(data)); token != "" {
+			return token, nil
+		}
 	}
+	token, err := randomHex(32)
+	if err != nil {
+		return "", err
+	}
+	if path != "" {
+		if err := writeFileAtomic(path, []byte(token+"\n")); err != nil {
+			return "", err
+		}
+	}
+	return token, nil
+}
+
+func writeEngineState(path string, state EngineState) error {
 	data, err := jsonMarshalIndent(state, "", "  ")
 	if err != nil {
 		return err
 	}
+	return writeFileAtomic(path, data)
+}
+
+// writeFileAtomic replaces path with data (mode 0600) via a temp file and
+// rename, so readers never see a partial file.
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
 	tmp := path + ".tmp"
 	if err := writeFileFn(tmp, data, 0o600); err != nil {
 		return err
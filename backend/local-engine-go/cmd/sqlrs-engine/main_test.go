@@ -16,9 +16,15 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
 	"time"
 
+	"google.golang.org/grpc"
+
+	"github.com/sqlrs/engine-local/internal/dbms"
 	"github.com/sqlrs/engine-local/internal/deletion"
 	"github.com/sqlrs/engine-local/internal/httpapi"
 	"github.com/sqlrs/engine-local/internal/prepare"
@@ -45,10 +51,12 @@ func TestActivityTrackerInflightRequests(t *testing.T) {
 		t.Fatalf("expected no in-flight requests")
 	}
 	tracker.StartRequest()
-	if !tracker.HasInflightRequests() {
-		t.Fatalf("expected in-flight request")
+	tracker.StartRequest()
+	if !tracker.HasInflightRequests() || tracker.InflightRequests() != 2 {
+		t.Fatalf("expected 2 in-flight requests, got %d", tracker.InflightRequests())
 	}
 	tracker.FinishRequest()
+	tracker.FinishRequest()
 	if tracker.HasInflightRequests() {
 		t.Fatalf("expected in-flight requests to be drained")
 	}
@@ -69,6 +77,44 @@ func TestActivityTrackerIdleStartsFromLastRequestCompletion(t *testing.T) {
 	}
 }
 
+func TestIdleShutdownDue(t *testing.T) {
+	tracker := newActivityTracker()
+	tracker.last = time.Now().Add(-2 * time.Second).UnixNano()
+	if !idleShutdownDue(tracker, 0, time.Minute, 0, time.Second) {
+		t.Fatalf("expected idle engine to shut down")
+	}
+	if idleShutdownDue(tracker, 0, time.Minute, 0, 5*time.Second) {
+		t.Fatalf("expected threshold to be respected")
+	}
+	if idleShutdownDue(tracker, 0, time.Minute, 2*time.Minute, time.Second) {
+		t.Fatalf("expected min uptime to prevent shutdown")
+	}
+	if idleShutdownDue(tracker, 1, time.Minute, 0, time.Second) {
+		t.Fatalf("expected open event streams to count as activity")
+	}
+	tracker.StartRequest()
+	if idleShutdownDue(tracker, 0, time.Minute, 0, time.Second) {
+		t.Fatalf("expected in-flight requests to prevent shutdown")
+	}
+}
+
+func TestIdleJitter(t *testing.T) {
+	if got := idleJitter(0); got != 0 {
+		t.Fatalf("expected no jitter, got %v", got)
+	}
+	for i := 0; i < 10; i++ {
+		if got := idleJitter(time.Second); got < 0 || got >= time.Second {
+			t.Fatalf("expected jitter in [0, 1s), got %v", got)
+		}
+	}
+	prevReader := randReader
+	randReader = errorReader{}
+	t.Cleanup(func() { randReader = prevReader })
+	if got := idleJitter(time.Second); got != 0 {
+		t.Fatalf("expected no jitter when randomness fails, got %v", got)
+	}
+}
+
 func TestRandomHex(t *testing.T) {
 	value, err := randomHex(8)
 	if err != nil {
@@ -135,6 +181,61 @@ func TestWriteEngineStateInvalidDir(t *testing.T) {
 	}
 }
 
+func TestLoadAuthTokenFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "auth", "token")
+	generated, err := loadAuthToken(path)
+	if err != nil || len(generated) != 64 {
+		t.Fatalf("expected generated token, got %q err=%v", generated, err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || strings.TrimSpace(string(data)) != generated {
+		t.Fatalf("expected token file to hold the generated token, got %q err=%v", data, err)
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("expected 0600 token file, got %v err=%v", info, err)
+	}
+
+	if err := os.WriteFile(path, []byte("provisioned\n"), 0o600); err != nil {
+		t.Fatalf("write token: %v", err)
+	}
+	token, err := loadAuthToken(path)
+	if err != nil || token != "provisioned" {
+		t.Fatalf("expected provisioned token, got %q err=%v", token, err)
+	}
+
+	inline, err := loadAuthToken("")
+	if err != nil || len(inline) != 64 {
+		t.Fatalf("expected inline token, got %q err=%v", inline, err)
+	}
+}
+
+func TestResolveAuthTokenFile(t *testing.T) {
+	t.Setenv("SQLRS_AUTH_TOKEN_FILE", "")
+	if path, err := resolveAuthTokenFile(""); err != nil || path != "" {
+		t.Fatalf("expected inline token by default, got %q err=%v", path, err)
+	}
+	t.Setenv("SQLRS_AUTH_TOKEN_FILE", "from-env")
+	path, err := resolveAuthTokenFile("")
+	if err != nil || !filepath.IsAbs(path) || filepath.Base(path) != "from-env" {
+		t.Fatalf("expected absolute env path, got %q err=%v", path, err)
+	}
+	path, err = resolveAuthTokenFile("from-flag")
+	if err != nil || filepath.Base(path) != "from-flag" {
+		t.Fatalf("expected flag to win, got %q err=%v", path, err)
+	}
+}
+
+func TestEngineStateOmitsTokenFileByDefault(t *testing.T) {
+	data, err := json.Marshal(EngineState{AuthToken: "secret"})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if strings.Contains(string(data), "authTokenFile") {
+		t.Fatalf("expected inline state without authTokenFile, got %s", data)
+	}
+}
+
 func TestWriteEngineStateRemoveError(t *testing.T) {
 	dir := t.TempDir()
 	path := filepath.Join(dir, "engine.json")
@@ -272,6 +373,48 @@ func TestSnapshotBackendFromConfigValidValues(t *testing.T) {
 	}
 }
 
+func TestStateCompressionFromConfig(t *testing.T) {
+	if got := stateCompressionFromConfig(fakeConfigStore{value: "zstd"}); got != "zstd" {
+		t.Fatalf("expected zstd, got %s", got)
+	}
+	for _, store := range []fakeConfigStore{{value: "none"}, {value: "gzip"}, {value: 1}, {err: errors.New("boom")}} {
+		if got := stateCompressionFromConfig(store); got != "none" {
+			t.Fatalf("expected none fallback for %+v, got %s", store, got)
+		}
+	}
+}
+
+func TestStateQuotaFromConfig(t *testing.T) {
+	for _, value := range []any{int64(1 << 30), float64(1 << 30), 1 << 30} {
+		if got := stateQuotaFromConfig(fakeConfigStore{value: value}); got != 1<<30 {
+			t.Fatalf("expected quota for %v, got %d", value, got)
+		}
+	}
+	for _, store := range []fakeConfigStore{{value: nil}, {value: -1}, {value: 1.5}, {value: "1G"}, {err: errors.New("boom")}} {
+		if got := stateQuotaFromConfig(store); got != 0 {
+			t.Fatalf("expected disabled quota for %+v, got %d", store, got)
+		}
+	}
+}
+
+func TestTracerFromConfig(t *testing.T) {
+	if tracer := tracerFromConfig(nil); tracer != nil {
+		t.Fatalf("expected tracing disabled without config")
+	}
+	for _, store := range []fakeConfigStore{{value: nil}, {value: " "}, {value: 4318}, {err: errors.New("boom")}} {
+		if tracer := tracerFromConfig(store); tracer != nil {
+			t.Fatalf("expected tracing disabled for %+v", store)
+		}
+	}
+	tracer := tracerFromConfig(fakeConfigStore{value: "http://127.0.0.1:4318"})
+	if tracer == nil {
+		t.Fatalf("expected tracer for configured endpoint")
+	}
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+}
+
 func TestSnapshotBackendFromConfigRejectsInvalidValues(t *testing.T) {
 	backend := snapshotBackendFromConfig(fakeConfigStore{value: "bad"})
 	if backend != "auto" {
@@ -298,6 +441,33 @@ func TestLogLevelFromConfig(t *testing.T) {
 	}
 }
 
+func TestLogHTTPRequestLevels(t *testing.T) {
+	cases := []struct {
+		level  string
+		status int
+		want   bool
+	}{
+		{"debug", http.StatusOK, true},
+		{"info", http.StatusOK, true},
+		{"warn", http.StatusOK, false},
+		{"warn", http.StatusNotFound, true},
+		{"warn", http.StatusInternalServerError, true},
+		{"error", http.StatusInternalServerError, false},
+		{"", http.StatusOK, true},
+	}
+	prev := log.Writer()
+	t.Cleanup(func() { log.SetOutput(prev) })
+	for _, tc := range cases {
+		var buf strings.Builder
+		log.SetOutput(&buf)
+		req := httptest.NewRequest(http.MethodGet, "/v1/health", nil)
+		logHTTPRequest(tc.level, req, tc.status, 2, time.Millisecond)
+		if got := strings.Contains(buf.String(), "http request method=GET path=/v1/health"); got != tc.want {
+			t.Fatalf("level %q status %d: expected logged=%t, got %q", tc.level, tc.status, tc.want, buf.String())
+		}
+	}
+}
+
 func TestContainerRuntimeFromConfig(t *testing.T) {
 	if mode := containerRuntimeFromConfig(nil); mode != "auto" {
 		t.Fatalf("expected auto for nil config, got %q", mode)
@@ -314,11 +484,108 @@ func TestContainerRuntimeFromConfig(t *testing.T) {
 	if mode := containerRuntimeFromConfig(fakeConfigStore{value: "podman"}); mode != "podman" {
 		t.Fatalf("expected podman mode, got %q", mode)
 	}
+	if mode := containerRuntimeFromConfig(fakeConfigStore{value: "nerdctl"}); mode != "nerdctl" {
+		t.Fatalf("expected nerdctl mode, got %q", mode)
+	}
+	if mode := containerRuntimeFromConfig(fakeConfigStore{value: "containerd"}); mode != "nerdctl" {
+		t.Fatalf("expected nerdctl mode for containerd, got %q", mode)
+	}
 	if mode := containerRuntimeFromConfig(fakeConfigStore{value: "bad"}); mode != "auto" {
 		t.Fatalf("expected auto for invalid value, got %q", mode)
 	}
 }
 
+func TestRegistryOptionsFromConfig(t *testing.T) {
+	if mirror, authFile := registryOptionsFromConfig(nil); mirror != "" || authFile != "" {
+		t.Fatalf("expected empty registry options for nil config, got %q %q", mirror, authFile)
+	}
+	if mirror, _ := registryOptionsFromConfig(fakeConfigStore{err: errors.New("boom")}); mirror != "" {
+		t.Fatalf("expected empty mirror on config error, got %q", mirror)
+	}
+	if mirror, _ := registryOptionsFromConfig(fakeConfigStore{value: nil}); mirror != "" {
+		t.Fatalf("expected empty mirror for unset value, got %q", mirror)
+	}
+	mirror, authFile := registryOptionsFromConfig(fakeConfigStore{value: " mirror.local "})
+	if mirror != "mirror.local" || authFile != "mirror.local" {
+		t.Fatalf("expected trimmed configured values, got %q %q", mirror, authFile)
+	}
+}
+
+func TestEnsureWorkDir(t *testing.T) {
+	if err := ensureWorkDir(""); err != nil {
+		t.Fatalf("expected empty work dir to be skipped, got %v", err)
+	}
+	root := filepath.Join(t.TempDir(), "work")
+	if err := ensureWorkDir(root); err != nil {
+		t.Fatalf("ensureWorkDir: %v", err)
+	}
+	entries, err := os.ReadDir(root)
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("expected an empty work dir, got %v %v", entries, err)
+	}
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, []byte("x"), 0o600); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	if err := ensureWorkDir(filepath.Join(file, "work")); err == nil {
+		t.Fatalf("expected work dir under a file to fail")
+	}
+}
+
+func TestCheckStateStoreAccess(t *testing.T) {
+	prevUID := engineUID
+	engineUID = func() int { return 1000 }
+	t.Cleanup(func() { engineUID = prevUID })
+	root := t.TempDir()
+	for _, tc := range []struct {
+		user        string
+		kind        string
+		wantWarning bool
+	}{
+		{user: "", kind: "copy"},
+		{user: "1000:1000", kind: "copy"},
+		{user: "postgres", kind: "copy"},
+		{user: "999:999", kind: "overlay"},
+		{user: "999:999", kind: "copy", wantWarning: true},
+	} {
+		warning, err := checkStateStoreAccess(root, tc.user, tc.kind)
+		if err != nil {
+			t.Fatalf("checkStateStoreAccess(%q, %q): %v", tc.user, tc.kind, err)
+		}
+		if (warning != "") != tc.wantWarning {
+			t.Fatalf("checkStateStoreAccess(%q, %q): unexpected warning %q", tc.user, tc.kind, warning)
+		}
+	}
+	entries, err := os.ReadDir(root)
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("expected the probe file to be removed, got %v %v", entries, err)
+	}
+	if _, err := checkStateStoreAccess(filepath.Join(root, "missing"), "", "copy"); err == nil || !strings.Contains(err.Error(), "is not writable") {
+		t.Fatalf("expected a missing store to fail, got %v", err)
+	}
+}
+
+func TestDrainTimeoutFromConfig(t *testing.T) {
+	if timeout := drainTimeoutFromConfig(nil); timeout != defaultDrainTimeout {
+		t.Fatalf("expected default for nil config, got %s", timeout)
+	}
+	if timeout := drainTimeoutFromConfig(fakeConfigStore{err: errors.New("boom")}); timeout != defaultDrainTimeout {
+		t.Fatalf("expected default on config error, got %s", timeout)
+	}
+	if timeout := drainTimeoutFromConfig(fakeConfigStore{value: 5}); timeout != defaultDrainTimeout {
+		t.Fatalf("expected default for non-string value, got %s", timeout)
+	}
+	if timeout := drainTimeoutFromConfig(fakeConfigStore{value: "soon"}); timeout != defaultDrainTimeout {
+		t.Fatalf("expected default for invalid value, got %s", timeout)
+	}
+	if timeout := drainTimeoutFromConfig(fakeConfigStore{value: "2m"}); timeout != 2*time.Minute {
+		t.Fatalf("expected configured timeout, got %s", timeout)
+	}
+	if timeout := drainTimeoutFromConfig(fakeConfigStore{value: "0"}); timeout != 0 {
+		t.Fatalf("expected zero timeout, got %s", timeout)
+	}
+}
+
 func TestResolveContainerRuntimeBinaryDefaultsToDocker(t *testing.T) {
 	prevLook := execLookPathFn
 	prevCmd := execCommandContextFn
@@ -428,6 +695,122 @@ func TestResolveContainerRuntimeBinaryFallsBackToPodmanAndSetsContainerHost(t *t
 	}
 }
 
+func TestResolveContainerRuntimeBinaryFallsBackToNerdctl(t *testing.T) {
+	prevLook := execLookPathFn
+	prevCmd := execCommandContextFn
+	execLookPathFn = func(name string) (string, error) {
+		if name == "nerdctl" {
+			return "/usr/local/bin/nerdctl", nil
+		}
+		return "", errors.New("missing")
+	}
+	execCommandContextFn = func(ctx context.Context, name string, args ...string) *exec.Cmd {
+		t.Fatalf("unexpected command call: %s %v", name, args)
+		return testCommandExit(ctx, 1)
+	}
+	t.Cleanup(func() {
+		execLookPathFn = prevLook
+		execCommandContextFn = prevCmd
+	})
+
+	t.Setenv("SQLRS_CONTAINER_RUNTIME", "")
+	t.Setenv("CONTAINER_HOST", "")
+	if got := resolveContainerRuntimeBinary("auto"); got != "/usr/local/bin/nerdctl" {
+		t.Fatalf("expected nerdctl path, got %q", got)
+	}
+	if host := os.Getenv("CONTAINER_HOST"); host != "" {
+		t.Fatalf("expected empty CONTAINER_HOST, got %q", host)
+	}
+}
+
+func TestResolveContainerRuntimeBinaryAutoPrefersDockerOverNerdctl(t *testing.T) {
+	prevLook := execLookPathFn
+	execLookPathFn = func(name string) (string, error) {
+		switch name {
+		case "docker":
+			return "/usr/bin/docker", nil
+		case "nerdctl":
+			return "/usr/local/bin/nerdctl", nil
+		}
+		return "", errors.New("missing")
+	}
+	t.Cleanup(func() { execLookPathFn = prevLook })
+
+	t.Setenv("SQLRS_CONTAINER_RUNTIME", "")
+	if got := resolveContainerRuntimeBinary("auto"); got != "/usr/bin/docker" {
+		t.Fatalf("expected docker path, got %q", got)
+	}
+}
+
+func TestResolveContainerRuntimeBinaryNerdctlMode(t *testing.T) {
+	prevLook := execLookPathFn
+	execLookPathFn = func(name string) (string, error) {
+		switch name {
+		case "docker":
+			return "/usr/bin/docker", nil
+		case "nerdctl":
+			return "/usr/local/bin/nerdctl", nil
+		}
+		return "", errors.New("missing")
+	}
+	t.Cleanup(func() { execLookPathFn = prevLook })
+
+	t.Setenv("SQLRS_CONTAINER_RUNTIME", "")
+	if got := resolveContainerRuntimeBinary("nerdctl"); got != "/usr/local/bin/nerdctl" {
+		t.Fatalf("expected nerdctl path, got %q", got)
+	}
+	t.Setenv("SQLRS_CONTAINER_RUNTIME", "nerdctl")
+	if got := resolveContainerRuntimeBinary("docker"); got != "/usr/local/bin/nerdctl" {
+		t.Fatalf("expected nerdctl path from env override, got %q", got)
+	}
+}
+
+func TestResolveContainerRuntimeModeIgnoresEnvOverride(t *testing.T) {
+	prevLook := execLookPathFn
+	execLookPathFn = func(name string) (string, error) {
+		switch name {
+		case "docker":
+			return "/usr/bin/docker", nil
+		case "podman":
+			return "/usr/bin/podman", nil
+		}
+		return "", errors.New("missing")
+	}
+	t.Cleanup(func() { execLookPathFn = prevLook })
+
+	t.Setenv("SQLRS_CONTAINER_RUNTIME", "docker")
+	if got := resolveContainerRuntimeMode("podman"); got != "/usr/bin/podman" {
+		t.Fatalf("expected the explicit runtime to beat the env override, got %q", got)
+	}
+	if got := resolveContainerRuntimeBinary("podman"); got != "/usr/bin/docker" {
+		t.Fatalf("expected the env override to beat config, got %q", got)
+	}
+}
+
+func TestEnsureNerdctlDockerConfig(t *testing.T) {
+	authFile := filepath.Join(t.TempDir(), "config.json")
+
+	t.Setenv("DOCKER_CONFIG", "")
+	ensureNerdctlDockerConfig("/usr/bin/docker", authFile)
+	if got := os.Getenv("DOCKER_CONFIG"); got != "" {
+		t.Fatalf("expected DOCKER_CONFIG untouched for docker, got %q", got)
+	}
+	ensureNerdctlDockerConfig("/usr/local/bin/nerdctl", "")
+	if got := os.Getenv("DOCKER_CONFIG"); got != "" {
+		t.Fatalf("expected DOCKER_CONFIG untouched without auth file, got %q", got)
+	}
+	ensureNerdctlDockerConfig("/usr/local/bin/nerdctl", authFile)
+	if got := os.Getenv("DOCKER_CONFIG"); got != filepath.Dir(authFile) {
+		t.Fatalf("expected DOCKER_CONFIG %q, got %q", filepath.Dir(authFile), got)
+	}
+
+	t.Setenv("DOCKER_CONFIG", "/custom")
+	ensureNerdctlDockerConfig("/usr/local/bin/nerdctl", authFile)
+	if got := os.Getenv("DOCKER_CONFIG"); got != "/custom" {
+		t.Fatalf("expected existing DOCKER_CONFIG preserved, got %q", got)
+	}
+}
+
 func TestResolveContainerRuntimeBinaryEnvPodmanSetsContainerHost(t *testing.T) {
 	prevLook := execLookPathFn
 	prevCmd := execCommandContextFn
@@ -527,6 +910,111 @@ func TestResolveContainerRuntimeBinaryPodmanModeUsesPodman(t *testing.T) {
 	}
 }
 
+func stubPodmanRootless(t *testing.T, goos string, uid int) {
+	t.Helper()
+	prevCmd := execCommandContextFn
+	prevGOOS := hostGOOS
+	prevUID := osGetuidFn
+	execCommandContextFn = func(ctx context.Context, name string, args ...string) *exec.Cmd {
+		return testCommandExit(ctx, 1)
+	}
+	hostGOOS = goos
+	osGetuidFn = func() int { return uid }
+	t.Cleanup(func() {
+		execCommandContextFn = prevCmd
+		hostGOOS = prevGOOS
+		osGetuidFn = prevUID
+	})
+	t.Setenv("CONTAINER_HOST", "")
+	t.Setenv("CONTAINER_SSHKEY", "")
+}
+
+func listenTestSocket(t *testing.T, path string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("unix sockets are not used for podman on windows")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		t.Fatalf("mkdir socket dir: %v", err)
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skipf("unix socket unavailable: %v", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+}
+
+func TestEnsurePodmanContainerHostUsesXDGRootlessSocket(t *testing.T) {
+	stubPodmanRootless(t, "linux", 4242)
+	runtimeDir := t.TempDir()
+	socket := filepath.Join(runtimeDir, "podman", "podman.sock")
+	listenTestSocket(t, socket)
+	t.Setenv("XDG_RUNTIME_DIR", runtimeDir)
+
+	ensurePodmanContainerHost("/usr/bin/podman")
+	if host := os.Getenv("CONTAINER_HOST"); host != "unix://"+socket {
+		t.Fatalf("expected rootless CONTAINER_HOST, got %q", host)
+	}
+}
+
+func TestEnsurePodmanContainerHostFallsBackToRunUserSocket(t *testing.T) {
+	stubPodmanRootless(t, "linux", 4242)
+	socket := filepath.Join(t.TempDir(), "podman.sock")
+	listenTestSocket(t, socket)
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	prevStat := osStatFn
+	osStatFn = func(path string) (os.FileInfo, error) {
+		if path == "/run/user/4242/podman/podman.sock" {
+			return os.Stat(socket)
+		}
+		return prevStat(path)
+	}
+	t.Cleanup(func() { osStatFn = prevStat })
+
+	ensurePodmanContainerHost("/usr/bin/podman")
+	if host := os.Getenv("CONTAINER_HOST"); host != "unix:///run/user/4242/podman/podman.sock" {
+		t.Fatalf("expected /run/user fallback CONTAINER_HOST, got %q", host)
+	}
+}
+
+func TestEnsurePodmanContainerHostSkipsRootlessProbeOffLinux(t *testing.T) {
+	stubPodmanRootless(t, "darwin", 4242)
+	runtimeDir := t.TempDir()
+	listenTestSocket(t, filepath.Join(runtimeDir, "podman", "podman.sock"))
+	t.Setenv("XDG_RUNTIME_DIR", runtimeDir)
+
+	ensurePodmanContainerHost("/usr/bin/podman")
+	if host := os.Getenv("CONTAINER_HOST"); host != "" {
+		t.Fatalf("expected empty CONTAINER_HOST off linux, got %q", host)
+	}
+}
+
+func TestEnsurePodmanContainerHostIgnoresNonSocketPath(t *testing.T) {
+	stubPodmanRootless(t, "linux", 4242)
+	runtimeDir := t.TempDir()
+	path := filepath.Join(runtimeDir, "podman", "podman.sock")
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte("not a socket"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	t.Setenv("XDG_RUNTIME_DIR", runtimeDir)
+	prevStat := osStatFn
+	osStatFn = func(path string) (os.FileInfo, error) {
+		if strings.HasPrefix(path, "/run/user/") {
+			return nil, os.ErrNotExist
+		}
+		return prevStat(path)
+	}
+	t.Cleanup(func() { osStatFn = prevStat })
+
+	ensurePodmanContainerHost("/usr/bin/podman")
+	if host := os.Getenv("CONTAINER_HOST"); host != "" {
+		t.Fatalf("expected empty CONTAINER_HOST for non-socket path, got %q", host)
+	}
+}
+
 func TestResolveContainerRuntimeBinaryEnvAbsolutePodmanPath(t *testing.T) {
 	absPodman := "/opt/podman/bin/podman"
 	if runtime.GOOS == "windows" {
@@ -808,6 +1296,52 @@ func TestRunInvalidListen(t *testing.T) {
 	}
 }
 
+func TestRunInvalidGRPCListen(t *testing.T) {
+	dir := t.TempDir()
+	statePath := filepath.Join(dir, "engine.json")
+	code, err := run([]string{"--listen=127.0.0.1:0", "--grpc-listen=bad", "--write-engine-json=" + statePath})
+	if code != 1 || err == nil || !strings.Contains(err.Error(), "grpc listen:") {
+		t.Fatalf("expected grpc listen error, got code=%d err=%v", code, err)
+	}
+}
+
+func TestRunServesGRPC(t *testing.T) {
+	dir := t.TempDir()
+	statePath := filepath.Join(dir, "engine.json")
+	var state EngineState
+	previousServe := serveHTTP
+	serveHTTP = func(server *http.Server, listener net.Listener) error {
+		data, err := os.ReadFile(statePath)
+		if err == nil {
+			err = json.Unmarshal(data, &state)
+		}
+		if err != nil {
+			t.Errorf("read engine.json: %v", err)
+		}
+		_ = listener.Close()
+		return http.ErrServerClosed
+	}
+	served := make(chan net.Listener, 1)
+	previousServeGRPC := serveGRPC
+	serveGRPC = func(server *grpc.Server, listener net.Listener) error {
+		served <- listener
+		return nil
+	}
+	t.Cleanup(func() {
+		serveHTTP = previousServe
+		serveGRPC = previousServeGRPC
+	})
+
+	code, err := run([]string{"--listen=127.0.0.1:0", "--grpc-listen=127.0.0.1:0", "--write-engine-json=" + statePath, "--idle-timeout=0"})
+	if code != 0 || err != nil {
+		t.Fatalf("expected success, got code=%d err=%v", code, err)
+	}
+	listener := <-served
+	if state.GRPCEndpoint == "" || state.GRPCEndpoint != listener.Addr().String() || state.GRPCEndpoint == state.Endpoint {
+		t.Fatalf("expected engine.json to advertise the gRPC listener, got %+v", state)
+	}
+}
+
 func TestRunUnknownFlag(t *testing.T) {
 	code, err := run([]string{"--nope"})
 	if code != 2 || err == nil {
@@ -983,6 +1517,93 @@ func TestRunRunManagerError(t *testing.T) {
 	}
 }
 
+func TestRunPassesPostgresSuperuser(t *testing.T) {
+	var prepareOpts prepare.Options
+	var runOpts runpkg.Options
+	prevPrepare := newPrepareServiceFn
+	newPrepareServiceFn = func(opts prepare.Options) (*prepare.PrepareService, error) {
+		prepareOpts = opts
+		return prevPrepare(opts)
+	}
+	prevRun := newRunManagerFn
+	newRunManagerFn = func(opts runpkg.Options) (*runpkg.Manager, error) {
+		runOpts = opts
+		return nil, errors.New("boom")
+	}
+	t.Cleanup(func() {
+		newPrepareServiceFn = prevPrepare
+		newRunManagerFn = prevRun
+	})
+
+	dir := t.TempDir()
+	stateStoreRoot := filepath.Join(dir, "state-store")
+	if err := os.MkdirAll(stateStoreRoot, 0o700); err != nil {
+		t.Fatalf("mkdir state-store: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(stateStoreRoot, "config.json"), []byte(`{"container":{"postgres":{"superuser":"postgres"}}}`), 0o600); err != nil {
+		t.Fatalf("write config.json: %v", err)
+	}
+	statePath := filepath.Join(dir, "engine.json")
+	if code, err := run([]string{"--listen=127.0.0.1:0", "--write-engine-json=" + statePath}); code != 1 || err == nil {
+		t.Fatalf("expected run manager error, got code=%d err=%v", code, err)
+	}
+	if prepareOpts.PostgresSuperuser != "postgres" || runOpts.Superuser != "postgres" {
+		t.Fatalf("expected superuser postgres, got prepare=%q run=%q", prepareOpts.PostgresSuperuser, runOpts.Superuser)
+	}
+	if connector, ok := prepareOpts.DBMS.(*dbms.PostgresConnector); !ok || connector.User() != "postgres" {
+		t.Fatalf("expected connector for postgres, got %+v", prepareOpts.DBMS)
+	}
+}
+
+func TestRunSnapshotBackendFlagOverridesConfig(t *testing.T) {
+	var prepareOpts prepare.Options
+	prevPrepare := newPrepareServiceFn
+	newPrepareServiceFn = func(opts prepare.Options) (*prepare.PrepareService, error) {
+		prepareOpts = opts
+		return nil, errors.New("boom")
+	}
+	t.Cleanup(func() { newPrepareServiceFn = prevPrepare })
+
+	dir := t.TempDir()
+	stateStoreRoot := filepath.Join(dir, "state-store")
+	if err := os.MkdirAll(stateStoreRoot, 0o700); err != nil {
+		t.Fatalf("mkdir state-store: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(stateStoreRoot, "config.json"), []byte(`{"snapshot":{"backend":"btrfs"}}`), 0o600); err != nil {
+		t.Fatalf("write config.json: %v", err)
+	}
+	statePath := filepath.Join(dir, "engine.json")
+	if code, err := run([]string{"--listen=127.0.0.1:0", "--write-engine-json=" + statePath, "--snapshot-backend=copy"}); code != 1 || err == nil {
+		t.Fatalf("expected prepare manager error, got code=%d err=%v", code, err)
+	}
+	if prepareOpts.StateFS == nil || prepareOpts.StateFS.Kind() != "copy" {
+		t.Fatalf("expected copy statefs, got %+v", prepareOpts.StateFS)
+	}
+}
+
+func TestRunRejectsInvalidOverrideFlags(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "engine.json")
+	for _, flagArg := range []string{"--container-runtime=rkt", "--snapshot-backend=zfs"} {
+		code, err := run([]string{"--listen=127.0.0.1:0", "--write-engine-json=" + statePath, flagArg})
+		if code != 2 || err == nil {
+			t.Fatalf("expected usage error for %s, got code=%d err=%v", flagArg, code, err)
+		}
+	}
+}
+
+func TestParseContainerRuntimeFlag(t *testing.T) {
+	cases := map[string]string{"": "", "auto": "auto", "Docker": "docker", " podman ": "podman", "containerd": "nerdctl"}
+	for input, want := range cases {
+		got, err := parseContainerRuntimeFlag(input)
+		if err != nil || got != want {
+			t.Fatalf("parseContainerRuntimeFlag(%q) = %q, %v; want %q", input, got, err, want)
+		}
+	}
+	if _, err := parseContainerRuntimeFlag("rkt"); err == nil {
+		t.Fatalf("expected error for unknown runtime")
+	}
+}
+
 func TestRunSetupLoggingError(t *testing.T) {
 	previousServe := serveHTTP
 	serveHTTP = func(server *http.Server, listener net.Listener) error {
@@ -1430,6 +2051,40 @@ func TestRunShutdownError(t *testing.T) {
 	}
 }
 
+func TestRunIdleShutdownDrainsPrepareJobs(t *testing.T) {
+	previousServe := serveHTTP
+	serveHTTP = func(server *http.Server, listener net.Listener) error {
+		time.Sleep(50 * time.Millisecond)
+		_ = listener.Close()
+		return http.ErrServerClosed
+	}
+	t.Cleanup(func() { serveHTTP = previousServe })
+	prevTicker := idleTickerEvery
+	idleTickerEvery = 10 * time.Millisecond
+	t.Cleanup(func() { idleTickerEvery = prevTicker })
+
+	var drained atomic.Int32
+	prevDrain := prepareDrainFn
+	prepareDrainFn = func(mgr *prepare.PrepareService, ctx context.Context) error {
+		drained.Add(1)
+		if _, ok := ctx.Deadline(); !ok {
+			t.Errorf("expected drain deadline")
+		}
+		return errors.New("drain interrupted jobs: job-1")
+	}
+	t.Cleanup(func() { prepareDrainFn = prevDrain })
+
+	dir := t.TempDir()
+	statePath := filepath.Join(dir, "engine.json")
+	code, err := run([]string{"--listen=127.0.0.1:0", "--write-engine-json=" + statePath, "--idle-timeout=10ms"})
+	if err != nil || code != 0 {
+		t.Fatalf("expected success, got code=%d err=%v", code, err)
+	}
+	if drained.Load() != 1 {
+		t.Fatalf("expected one drain on idle shutdown, got %d", drained.Load())
+	}
+}
+
 func TestSetupLoggingSuccess(t *testing.T) {
 	dir := t.TempDir()
 	statePath := filepath.Join(dir, "engine.json")
@@ -1722,3 +2377,99 @@ func testCommandExit(ctx context.Context, code int) *exec.Cmd {
 	}
 	return exec.CommandContext(ctx, "sh", "-c", "exit "+strconv.Itoa(code))
 }
+
+func TestRunDaemonReloadsConfigAndNotifies(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test stops the engine with SIGTERM")
+	}
+	storeRoot := t.TempDir()
+	t.Setenv("SQLRS_STATE_STORE", storeRoot)
+
+	var statesMu sync.Mutex
+	var states []string
+	prevNotify := sdNotifyFn
+	sdNotifyFn = func(state string) error {
+		statesMu.Lock()
+		defer statesMu.Unlock()
+		states = append(states, state)
+		return nil
+	}
+	t.Cleanup(func() { sdNotifyFn = prevNotify })
+
+	var reload chan<- os.Signal
+	prevReload := notifyReloadFn
+	notifyReloadFn = func(c chan<- os.Signal) { reload = c }
+	t.Cleanup(func() { notifyReloadFn = prevReload })
+
+	var opts httpapi.Options
+	prevHandler := newHandlerFn
+	newHandlerFn = func(o httpapi.Options) http.Handler {
+		opts = o
+		return http.NotFoundHandler()
+	}
+	t.Cleanup(func() { newHandlerFn = prevHandler })
+
+	prevServe := serveHTTP
+	serveHTTP = func(server *http.Server, listener net.Listener) error {
+		statesMu.Lock()
+		if len(states) != 1 || states[0] != "READY=1" {
+			t.Errorf("expected READY=1 before serving, got %v", states)
+		}
+		statesMu.Unlock()
+		if opts.IdleTimeout != 0 {
+			t.Errorf("expected idle timeout to be disabled, got %v", opts.IdleTimeout)
+		}
+		data := []byte(`{"log":{"level":"debug"}}`)
+		if err := os.WriteFile(filepath.Join(storeRoot, "config.json"), data, 0o600); err != nil {
+			t.Errorf("write config: %v", err)
+			return http.ErrServerClosed
+		}
+		reload <- syscall.SIGHUP
+		deadline := time.Now().Add(2 * time.Second)
+		for logLevelFromConfig(opts.Config) != "debug" {
+			if time.Now().After(deadline) {
+				t.Errorf("expected log.level to be reloaded, got %q", logLevelFromConfig(opts.Config))
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		self, _ := os.FindProcess(os.Getpid())
+		if err := self.Signal(syscall.SIGTERM); err != nil {
+			t.Errorf("signal: %v", err)
+		}
+		return server.Serve(listener)
+	}
+	t.Cleanup(func() { serveHTTP = prevServe })
+
+	statePath := filepath.Join(t.TempDir(), "engine.json")
+	code, err := run([]string{"--listen=127.0.0.1:0", "--write-engine-json=" + statePath, "--daemon", "--idle-timeout=1ms"})
+	if err != nil || code != 0 {
+		t.Fatalf("expected success, got code=%d err=%v", code, err)
+	}
+	statesMu.Lock()
+	defer statesMu.Unlock()
+	if len(states) != 2 || states[1] != "STOPPING=1" {
+		t.Fatalf("expected STOPPING=1 on shutdown, got %v", states)
+	}
+}
+
+func TestReloadConfigKeepsRunningOnError(t *testing.T) {
+	var buf strings.Builder
+	prevOut := log.Writer()
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(prevOut) })
+
+	reloadConfig(reloaderFunc(func() error { return errors.New("boom") }))
+	if !strings.Contains(buf.String(), "config reload failed, keeping current config: boom") {
+		t.Fatalf("unexpected log: %q", buf.String())
+	}
+	buf.Reset()
+	reloadConfig(reloaderFunc(func() error { return nil }))
+	if !strings.Contains(buf.String(), "config reloaded") {
+		t.Fatalf("unexpected log: %q", buf.String())
+	}
+}
+
+type reloaderFunc func() error
+
+func (f reloaderFunc) Reload() error { return f() }
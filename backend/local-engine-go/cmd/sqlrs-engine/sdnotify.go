@@ -0,0 +1,28 @@
+package main
+
+import (
+	"net"
+	"os"
+	"strings"
+)
+
+// sdNotify sends a state line such as "READY=1" to the service manager over
+// $NOTIFY_SOCKET, the sd_notify protocol systemd uses for Type=notify units.
+// It is a no-op when the variable is unset.
+func sdNotify(state string) error {
+	socket := strings.TrimSpace(os.Getenv("NOTIFY_SOCKET"))
+	if socket == "" {
+		return nil
+	}
+	// A leading '@' names a socket in the abstract namespace.
+	if strings.HasPrefix(socket, "@") {
+		socket = "\x00" + socket[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return err
+}
@@ -0,0 +1,49 @@
+//go:build linux
+
+package main
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+)
+
+func TestSDNotifyWithoutSocket(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	if err := sdNotify("READY=1"); err != nil {
+		t.Fatalf("expected no-op, got %v", err)
+	}
+}
+
+func TestSDNotifySendsState(t *testing.T) {
+	for name, addr := range map[string]string{
+		"path":     filepath.Join(t.TempDir(), "notify.sock"),
+		"abstract": "@sqlrs-notify-test-" + filepath.Base(t.TempDir()),
+	} {
+		listenName := addr
+		if listenName[0] == '@' {
+			listenName = "\x00" + listenName[1:]
+		}
+		conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: listenName, Net: "unixgram"})
+		if err != nil {
+			t.Fatalf("%s: listen: %v", name, err)
+		}
+		t.Setenv("NOTIFY_SOCKET", addr)
+		if err := sdNotify("READY=1"); err != nil {
+			t.Fatalf("%s: sdNotify: %v", name, err)
+		}
+		buf := make([]byte, 64)
+		n, err := conn.Read(buf)
+		_ = conn.Close()
+		if err != nil || string(buf[:n]) != "READY=1" {
+			t.Fatalf("%s: expected READY=1, got %q err=%v", name, buf[:n], err)
+		}
+	}
+}
+
+func TestSDNotifyDialError(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", filepath.Join(t.TempDir(), "missing.sock"))
+	if err := sdNotify("READY=1"); err == nil {
+		t.Fatalf("expected dial error")
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sqlrs/engine-local/internal/store"
+	"github.com/sqlrs/engine-local/internal/store/sqlite"
+)
+
+// errStateDBCorrupt marks integrity failures that openStateStore recovers
+// from. Other errors (for example a locked database) are returned as-is so a
+// healthy database is never moved aside.
+var errStateDBCorrupt = errors.New("state db is corrupt")
+
+var checkStateDBFn = checkStateDB
+
+// openStateStore opens state.db under stateStoreRoot. A database that fails
+// PRAGMA integrity_check is moved aside with a timestamped suffix and replaced
+// by a fresh one; states still readable from the old file whose data dirs are
+// still on disk are registered again. Jobs, instances and names are not
+// carried over.
+func openStateStore(stateStoreRoot string) (*sql.DB, *sqlite.Store, error) {
+	path := filepath.Join(stateStoreRoot, "state.db")
+	db, err := openDBFn(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	checkErr := checkStateDBFn(db)
+	if checkErr != nil && !errors.Is(checkErr, errStateDBCorrupt) {
+		_ = db.Close()
+		return nil, nil, checkErr
+	}
+	if checkErr == nil {
+		st, err := newStoreFn(db)
+		if err != nil {
+			_ = db.Close()
+			return nil, nil, err
+		}
+		return db, st, nil
+	}
+
+	_ = db.Close()
+	backupPath, err := quarantineStateDB(path, time.Now())
+	if err != nil {
+		return nil, nil, fmt.Errorf("%v; cannot move it aside: %v", checkErr, err)
+	}
+	log.Printf("state db failed integrity check: %v; moved to %s, starting with a fresh state db", checkErr, backupPath)
+	db, err = openDBFn(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	st, err := newStoreFn(db)
+	if err != nil {
+		_ = db.Close()
+		return nil, nil, err
+	}
+	restored, err := salvageStates(context.Background(), backupPath, st, stateStoreRoot)
+	if err != nil {
+		log.Printf("state db salvage stopped after %d states: %v", restored, err)
+	} else {
+		log.Printf("state db salvage restored %d states from %s", restored, backupPath)
+	}
+	return db, st, nil
+}
+
+// checkStateDB runs PRAGMA integrity_check. Results other than "ok", and
+// errors reporting a malformed file, wrap errStateDBCorrupt.
+func checkStateDB(db *sql.DB) error {
+	rows, err := db.Query("PRAGMA integrity_check")
+	if err != nil {
+		return stateDBError(err)
+	}
+	defer rows.Close()
+	var problems []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return stateDBError(err)
+		}
+		if line != "ok" && len(problems) < 5 {
+			problems = append(problems, line)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return stateDBError(err)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", errStateDBCorrupt, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func stateDBError(err error) error {
+	if isStateDBCorruption(err) {
+		return fmt.Errorf("%w: %v", errStateDBCorrupt, err)
+	}
+	return err
+}
+
+// isStateDBCorruption matches SQLITE_CORRUPT and SQLITE_NOTADB messages.
+func isStateDBCorruption(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "malformed") ||
+		strings.Contains(msg, "not a database") ||
+		strings.Contains(msg, "corrupt")
+}
+
+// quarantineStateDB renames path (and its WAL/SHM sidecars) to
+// path.corrupt-<UTC timestamp> and returns the new name.
+func quarantineStateDB(path string, now time.Time) (string, error) {
+	backupPath := path + ".corrupt-" + now.UTC().Format("20060102T150405Z")
+	if err := renameFn(path, backupPath); err != nil {
+		return "", err
+	}
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := renameFn(path+suffix, backupPath+suffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("state db: cannot move %s aside: %v", path+suffix, err)
+		}
+	}
+	return backupPath, nil
+}
+
+// salvageStates copies the states rows that can still be read from the
+// quarantined database into st, skipping states whose data dir is gone. It
+// stops at the first read error and reports how many states it restored.
+func salvageStates(ctx context.Context, backupPath string, st *sqlite.Store, stateStoreRoot string) (int, error) {
+	present := stateDirsOnDisk(stateStoreRoot)
+	if len(present) == 0 {
+		return 0, nil
+	}
+	old, err := openDBFn(backupPath)
+	if err != nil {
+		return 0, err
+	}
+	defer old.Close()
+	rows, err := old.QueryContext(ctx, `
+SELECT state_id, parent_state_id, state_fingerprint, image_id, prepare_kind, prepare_args_normalized,
+	created_at, size_bytes, status, namespace, task_hash
+FROM states`)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	restored := 0
+	for rows.Next() {
+		var (
+			stateID, imageID, kind, args, createdAt            string
+			parentID, fingerprint, status, namespace, taskHash sql.NullString
+			sizeBytes                                          sql.NullInt64
+		)
+		if err := rows.Scan(&stateID, &parentID, &fingerprint, &imageID, &kind, &args, &createdAt, &sizeBytes, &status, &namespace, &taskHash); err != nil {
+			return restored, err
+		}
+		if !present[stateID] {
+			continue
+		}
+		entry := store.StateCreate{
+			StateID:               stateID,
+			StateFingerprint:      stateID,
+			ImageID:               imageID,
+			PrepareKind:           kind,
+			PrepareArgsNormalized: args,
+			CreatedAt:             createdAt,
+			Namespace:             namespace.String,
+			TaskHash:              taskHash.String,
+		}
+		if parentID.Valid {
+			entry.ParentStateID = &parentID.String
+		}
+		if fingerprint.Valid && fingerprint.String != "" {
+			entry.StateFingerprint = fingerprint.String
+		}
+		if sizeBytes.Valid {
+			entry.SizeBytes = &sizeBytes.Int64
+		}
+		if status.Valid {
+			entry.Status = &status.String
+		}
+		if err := st.CreateState(ctx, entry); err != nil {
+			return restored, err
+		}
+		restored++
+	}
+	return restored, rows.Err()
+}
+
+// stateDirsOnDisk lists the state IDs that have a data dir in the default
+// namespace or any named one. Dot-named entries (build locks, in-flight
+// rebuilds) are skipped.
+func stateDirsOnDisk(stateStoreRoot string) map[string]bool {
+	present := map[string]bool{}
+	for _, pattern := range []string{
+		filepath.Join(stateStoreRoot, "engines", "*", "*", "states", "*"),
+		filepath.Join(stateStoreRoot, "ns", "*", "engines", "*", "*", "states", "*"),
+	} {
+		matches, _ := filepath.Glob(pattern)
+		for _, match := range matches {
+			name := filepath.Base(match)
+			if strings.Contains(name, ".") {
+				continue
+			}
+			if info, err := os.Stat(match); err == nil && info.IsDir() {
+				present[name] = true
+			}
+		}
+	}
+	return present
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sqlrs/engine-local/internal/store"
+	"github.com/sqlrs/engine-local/internal/store/sqlite"
+)
+
+func seedStateDB(t *testing.T, root string, stateIDs ...string) {
+	t.Helper()
+	st, err := sqlite.Open(filepath.Join(root, "state.db"))
+	if err != nil {
+		t.Fatalf("sqlite.Open: %v", err)
+	}
+	defer st.Close()
+	for _, stateID := range stateIDs {
+		if err := st.CreateState(context.Background(), store.StateCreate{
+			StateID:               stateID,
+			StateFingerprint:      stateID,
+			ImageID:               "postgres:17",
+			PrepareKind:           "psql",
+			PrepareArgsNormalized: "-c select 1",
+			CreatedAt:             "2026-01-01T00:00:00Z",
+			TaskHash:              "hash-" + stateID,
+		}); err != nil {
+			t.Fatalf("CreateState: %v", err)
+		}
+	}
+}
+
+func corruptBackups(t *testing.T, root string) []string {
+	t.Helper()
+	matches, err := filepath.Glob(filepath.Join(root, "state.db.corrupt-*"))
+	if err != nil {
+		t.Fatalf("Glob: %v", err)
+	}
+	var backups []string
+	for _, match := range matches {
+		if !strings.HasSuffix(match, "-wal") && !strings.HasSuffix(match, "-shm") {
+			backups = append(backups, match)
+		}
+	}
+	return backups
+}
+
+func TestOpenStateStoreRecoversTruncatedDB(t *testing.T) {
+	root := t.TempDir()
+	seedStateDB(t, root, "state-a")
+	if err := os.Truncate(filepath.Join(root, "state.db"), 100); err != nil {
+		t.Fatalf("Truncate: %v", err)
+	}
+
+	db, st, err := openStateStore(root)
+	if err != nil {
+		t.Fatalf("openStateStore: %v", err)
+	}
+	defer db.Close()
+	if _, err := st.ListStates(context.Background(), store.StateFilters{}); err != nil {
+		t.Fatalf("ListStates on recovered db: %v", err)
+	}
+	if err := st.CreateState(context.Background(), store.StateCreate{
+		StateID:          "state-new",
+		StateFingerprint: "state-new",
+		ImageID:          "postgres:17",
+		PrepareKind:      "psql",
+		CreatedAt:        "2026-01-01T00:00:00Z",
+	}); err != nil {
+		t.Fatalf("CreateState on recovered db: %v", err)
+	}
+	if backups := corruptBackups(t, root); len(backups) != 1 {
+		t.Fatalf("expected one corrupt backup, got %v", backups)
+	}
+}
+
+func TestOpenStateStoreSalvagesStatesWithDataDirs(t *testing.T) {
+	root := t.TempDir()
+	seedStateDB(t, root, "state-a", "state-b")
+	if err := os.MkdirAll(filepath.Join(root, "engines", "postgres", "17", "states", "state-a"), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	prev := checkStateDBFn
+	calls := 0
+	checkStateDBFn = func(db *sql.DB) error {
+		calls++
+		if calls == 1 {
+			return fmt.Errorf("%w: page 3 is never used", errStateDBCorrupt)
+		}
+		return nil
+	}
+	t.Cleanup(func() { checkStateDBFn = prev })
+
+	db, st, err := openStateStore(root)
+	if err != nil {
+		t.Fatalf("openStateStore: %v", err)
+	}
+	defer db.Close()
+	states, err := st.ListStates(context.Background(), store.StateFilters{})
+	if err != nil {
+		t.Fatalf("ListStates: %v", err)
+	}
+	if len(states) != 1 || states[0].StateID != "state-a" || states[0].ImageID != "postgres:17" || states[0].TaskHash != "hash-state-a" {
+		t.Fatalf("expected only state-a to be restored, got %+v", states)
+	}
+	if backups := corruptBackups(t, root); len(backups) != 1 {
+		t.Fatalf("expected one corrupt backup, got %v", backups)
+	}
+}
+
+func TestOpenStateStoreKeepsDBOnOtherCheckErrors(t *testing.T) {
+	root := t.TempDir()
+	seedStateDB(t, root, "state-a")
+	prev := checkStateDBFn
+	checkStateDBFn = func(db *sql.DB) error {
+		return errors.New("database is locked")
+	}
+	t.Cleanup(func() { checkStateDBFn = prev })
+
+	if _, _, err := openStateStore(root); err == nil || !strings.Contains(err.Error(), "locked") {
+		t.Fatalf("expected locked error, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "state.db")); err != nil {
+		t.Fatalf("expected state.db to stay in place: %v", err)
+	}
+	if backups := corruptBackups(t, root); len(backups) != 0 {
+		t.Fatalf("expected no backups, got %v", backups)
+	}
+}
+
+func TestOpenStateStoreRenameError(t *testing.T) {
+	root := t.TempDir()
+	seedStateDB(t, root)
+	prevCheck := checkStateDBFn
+	prevRename := renameFn
+	checkStateDBFn = func(db *sql.DB) error {
+		return fmt.Errorf("%w: bad page", errStateDBCorrupt)
+	}
+	renameFn = func(string, string) error {
+		return errors.New("read-only file system")
+	}
+	t.Cleanup(func() {
+		checkStateDBFn = prevCheck
+		renameFn = prevRename
+	})
+
+	_, _, err := openStateStore(root)
+	if err == nil || !strings.Contains(err.Error(), "cannot move it aside") {
+		t.Fatalf("expected rename error, got %v", err)
+	}
+}
+
+func TestIsStateDBCorruption(t *testing.T) {
+	cases := map[string]bool{
+		"database disk image is malformed (11)": true,
+		"file is not a database (26)":           true,
+		"database corruption at line 1":         true,
+		"database is locked (5)":                false,
+	}
+	for msg, want := range cases {
+		if got := isStateDBCorruption(errors.New(msg)); got != want {
+			t.Fatalf("isStateDBCorruption(%q)=%v, want %v", msg, got, want)
+		}
+	}
+	if isStateDBCorruption(nil) {
+		t.Fatalf("expected nil error to be healthy")
+	}
+}
@@ -43,3 +43,18 @@ func TestRequireBearerRejectsInvalidToken(t *testing.T) {
 		t.Fatalf("expected 401, got %d", rec.Code)
 	}
 }
+
+func TestTokenGetSet(t *testing.T) {
+	var missing *Token
+	if missing.Get() != "" {
+		t.Fatalf("expected nil token to be empty")
+	}
+	token := NewToken("first")
+	if token.Get() != "first" {
+		t.Fatalf("expected initial token, got %q", token.Get())
+	}
+	token.Set("second")
+	if token.Get() != "second" {
+		t.Fatalf("expected rotated token, got %q", token.Get())
+	}
+}
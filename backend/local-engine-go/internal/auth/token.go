@@ -0,0 +1,27 @@
+package auth
+
+import "sync/atomic"
+
+// Token holds the engine bearer token. It is shared by all handlers so a
+// rotation takes effect for the next request.
+type Token struct {
+	value atomic.Value
+}
+
+func NewToken(value string) *Token {
+	token := &Token{}
+	token.Set(value)
+	return token
+}
+
+func (t *Token) Get() string {
+	if t == nil {
+		return ""
+	}
+	value, _ := t.value.Load().(string)
+	return value
+}
+
+func (t *Token) Set(value string) {
+	t.value.Store(value)
+}
@@ -5,14 +5,31 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 )
 
+// containerdNamespacePattern follows containerd's namespace naming rules.
+var containerdNamespacePattern = regexp.MustCompile(`^[A-Za-z0-9]+(?:[._-][A-Za-z0-9]+)*$`)
+
+// postgresRolePattern accepts unquoted Postgres identifiers up to the default
+// NAMEDATALEN limit, so the name can be passed to initdb, psql and DSNs as is.
+var postgresRolePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,62}$`)
+
+// platformPattern accepts an OCI platform: os/arch with an optional variant,
+// for example "linux/amd64" or "linux/arm64/v8".
+var platformPattern = regexp.MustCompile(`^[a-z0-9_]+/[a-z0-9_]+(?:/[a-z0-9_]+)?$`)
+
+// containerUserPattern accepts a --user value: a user name or uid with an
+// optional group name or gid, for example "postgres" or "1000:1000".
+var containerUserPattern = regexp.MustCompile(`^(?:[0-9]+|[a-z_][a-z0-9_-]*)(?::(?:[0-9]+|[a-z_][a-z0-9_-]*))?$`)
+
 var (
 	ErrInvalidPath  = errors.New("config path is invalid")
 	ErrPathNotFound = errors.New("config path not found")
@@ -103,15 +120,98 @@ func DefaultConfig() map[string]any {
 			},
     },
 		"container": map[string]any{
-			"runtime": "auto",
+			"runtime":   "auto",
+			"namespace": nil,
+			"retry": map[string]any{
+				"maxAttempts": 3,
+				"baseDelay":   "500ms",
+			},
+			"registry": map[string]any{
+				"mirror":   nil,
+				"authFile": nil,
+			},
+			"limits": map[string]any{
+				"cpus":   nil,
+				"memory": nil,
+			},
+			"network": map[string]any{
+				"allowed": []any{},
+			},
+			"postgres": map[string]any{
+				"superuser": "sqlrs",
+			},
+			"runAsUser": nil,
+			"platform":  nil,
+			"warmPool": map[string]any{
+				"size":        0,
+				"idleTimeout": "10m",
+			},
+			"build": map[string]any{
+				"enabled": false,
+			},
+			"offline": false,
+		},
+		"images": map[string]any{
+			"allowed": []any{},
+			"denied":  []any{},
 		},
 		"snapshot": map[string]any{
 			"backend": "auto",
 		},
+		"statefs": map[string]any{
+			"verifyChecksums": false,
+			"compression":     "none",
+			"btrfs": map[string]any{
+				"quotaBytes": 0,
+			},
+		},
 		"orchestrator": map[string]any{
 			"jobs": map[string]any{
-				"maxIdentical": 2,
+				"maxIdentical":       2,
+				"maxQueued":          1000,
+				"submitRate":         20,
+				"heartbeatMax":       "1m",
+				"ttl":                nil,
+				"sweepInterval":      "10m",
+				"cancelOnDisconnect": false,
+				"disconnectGrace":    "15s",
+			},
+			"tasks": map[string]any{
+				"timeout": "10m",
 			},
+			"events": map[string]any{
+				"maxPerJob": nil,
+			},
+		},
+		"shutdown": map[string]any{
+			"drainTimeout": "30s",
+		},
+		"prepare": map[string]any{
+			"psql": map[string]any{
+				"maxScriptBytes":   256 << 20,
+				"maxStdinBytes":    64 << 20,
+				"maxFiles":         1000,
+				"runner":           "container",
+				"statementTimeout": nil,
+				"normalizeHash":    false,
+			},
+			"lb": map[string]any{
+				"autoReleaseLocks": false,
+			},
+			"cache": map[string]any{
+				"versionSalt": nil,
+			},
+		},
+		"otel": map[string]any{
+			"endpoint": nil,
+		},
+		"http": map[string]any{
+			"cors": map[string]any{
+				"allowedOrigins": []any{},
+			},
+		},
+		"workdir": map[string]any{
+			"root": nil,
 		},
 	}
 }
@@ -168,7 +268,111 @@ func DefaultSchema() map[string]any {
 				"properties": map[string]any{
 					"runtime": map[string]any{
 						"type": []any{"string", "null"},
-						"enum": []any{"auto", "docker", "podman", nil},
+						"enum": []any{"auto", "docker", "podman", "nerdctl", "containerd", nil},
+					},
+					"namespace": map[string]any{
+						"type": []any{"string", "null"},
+					},
+					"retry": map[string]any{
+						"type": "object",
+						"properties": map[string]any{
+							"maxAttempts": map[string]any{
+								"type":    []any{"integer", "null"},
+								"minimum": 1,
+							},
+							"baseDelay": map[string]any{
+								"type": []any{"string", "null"},
+							},
+						},
+						"additionalProperties": true,
+					},
+					"registry": map[string]any{
+						"type": "object",
+						"properties": map[string]any{
+							"mirror": map[string]any{
+								"type": []any{"string", "null"},
+							},
+							"authFile": map[string]any{
+								"type": []any{"string", "null"},
+							},
+						},
+						"additionalProperties": true,
+					},
+					"limits": map[string]any{
+						"type": "object",
+						"properties": map[string]any{
+							"cpus": map[string]any{
+								"type": []any{"number", "string", "null"},
+							},
+							"memory": map[string]any{
+								"type": []any{"string", "null"},
+							},
+						},
+						"additionalProperties": true,
+					},
+					"network": map[string]any{
+						"type": "object",
+						"properties": map[string]any{
+							"allowed": map[string]any{
+								"type":  []any{"array", "null"},
+								"items": map[string]any{"type": "string"},
+							},
+						},
+						"additionalProperties": true,
+					},
+					"postgres": map[string]any{
+						"type": "object",
+						"properties": map[string]any{
+							"superuser": map[string]any{
+								"type": []any{"string", "null"},
+							},
+						},
+						"additionalProperties": true,
+					},
+					"runAsUser": map[string]any{
+						"type": []any{"string", "null"},
+					},
+					"platform": map[string]any{
+						"type": []any{"string", "null"},
+					},
+					"warmPool": map[string]any{
+						"type": "object",
+						"properties": map[string]any{
+							"size": map[string]any{
+								"type":    []any{"integer", "null"},
+								"minimum": 0,
+							},
+							"idleTimeout": map[string]any{
+								"type": []any{"string", "null"},
+							},
+						},
+						"additionalProperties": true,
+					},
+					"build": map[string]any{
+						"type": "object",
+						"properties": map[string]any{
+							"enabled": map[string]any{
+								"type": []any{"boolean", "null"},
+							},
+						},
+						"additionalProperties": true,
+					},
+					"offline": map[string]any{
+						"type": []any{"boolean", "null"},
+					},
+				},
+				"additionalProperties": true,
+			},
+			"images": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"allowed": map[string]any{
+						"type":  []any{"array", "null"},
+						"items": map[string]any{"type": "string"},
+					},
+					"denied": map[string]any{
+						"type":  []any{"array", "null"},
+						"items": map[string]any{"type": "string"},
 					},
 				},
 				"additionalProperties": true,
@@ -183,6 +387,29 @@ func DefaultSchema() map[string]any {
 				},
 				"additionalProperties": true,
 			},
+			"statefs": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"verifyChecksums": map[string]any{
+						"type": []any{"boolean", "null"},
+					},
+					"compression": map[string]any{
+						"type": []any{"string", "null"},
+						"enum": []any{"none", "zstd", nil},
+					},
+					"btrfs": map[string]any{
+						"type": "object",
+						"properties": map[string]any{
+							"quotaBytes": map[string]any{
+								"type":    []any{"integer", "null"},
+								"minimum": 0,
+							},
+						},
+						"additionalProperties": true,
+					},
+				},
+				"additionalProperties": true,
+			},
 			"orchestrator": map[string]any{
 				"type": "object",
 				"properties": map[string]any{
@@ -193,9 +420,146 @@ func DefaultSchema() map[string]any {
 								"type":    []any{"integer", "null"},
 								"minimum": 0,
 							},
+							"maxQueued": map[string]any{
+								"type":    []any{"integer", "null"},
+								"minimum": 0,
+							},
+							"submitRate": map[string]any{
+								"type":    []any{"number", "null"},
+								"minimum": 0,
+							},
+							"heartbeatMax": map[string]any{
+								"type": []any{"string", "null"},
+							},
+							"ttl": map[string]any{
+								"type": []any{"string", "null"},
+							},
+							"sweepInterval": map[string]any{
+								"type": []any{"string", "null"},
+							},
+							"cancelOnDisconnect": map[string]any{
+								"type": []any{"boolean", "null"},
+							},
+							"disconnectGrace": map[string]any{
+								"type": []any{"string", "null"},
+							},
+						},
+						"additionalProperties": true,
+					},
+					"tasks": map[string]any{
+						"type": "object",
+						"properties": map[string]any{
+							"timeout": map[string]any{
+								"type": []any{"string", "null"},
+							},
+						},
+						"additionalProperties": true,
+					},
+					"events": map[string]any{
+						"type": "object",
+						"properties": map[string]any{
+							"maxPerJob": map[string]any{
+								"type":    []any{"integer", "null"},
+								"minimum": 0,
+							},
+						},
+						"additionalProperties": true,
+					},
+				},
+				"additionalProperties": true,
+			},
+			"shutdown": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"drainTimeout": map[string]any{
+						"type": []any{"string", "null"},
+					},
+				},
+				"additionalProperties": true,
+			},
+			"prepare": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"psql": map[string]any{
+						"type": "object",
+						"properties": map[string]any{
+							"maxScriptBytes": map[string]any{
+								"type":    []any{"integer", "null"},
+								"minimum": 0,
+							},
+							"maxFiles": map[string]any{
+								"type":    []any{"integer", "null"},
+								"minimum": 0,
+							},
+							"maxStdinBytes": map[string]any{
+								"type":    []any{"integer", "null"},
+								"minimum": 0,
+							},
+							"runner": map[string]any{
+								"type": []any{"string", "null"},
+								"enum": []any{"container", "native", nil},
+							},
+							"statementTimeout": map[string]any{
+								"type": []any{"string", "null"},
+							},
+							"normalizeHash": map[string]any{
+								"type": []any{"boolean", "null"},
+							},
+						},
+						"additionalProperties": true,
+					},
+					"lb": map[string]any{
+						"type": "object",
+						"properties": map[string]any{
+							"autoReleaseLocks": map[string]any{
+								"type": []any{"boolean", "null"},
+							},
 						},
 						"additionalProperties": true,
 					},
+					"cache": map[string]any{
+						"type": "object",
+						"properties": map[string]any{
+							"versionSalt": map[string]any{
+								"type": []any{"string", "null"},
+							},
+						},
+						"additionalProperties": true,
+					},
+				},
+				"additionalProperties": true,
+			},
+			"otel": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"endpoint": map[string]any{
+						"type": []any{"string", "null"},
+					},
+				},
+				"additionalProperties": true,
+			},
+			"http": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"cors": map[string]any{
+						"type": "object",
+						"properties": map[string]any{
+							"allowedOrigins": map[string]any{
+								"type":  []any{"array", "null"},
+								"items": map[string]any{"type": "string"},
+							},
+						},
+						"additionalProperties": true,
+					},
+				},
+				"additionalProperties": true,
+			},
+			"workdir": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"root": map[string]any{
+						"type": []any{"string", "null"},
+					},
 				},
 				"additionalProperties": true,
 			},
@@ -287,6 +651,46 @@ func (m *Manager) Remove(path string) (any, error) {
 	return value, nil
 }
 
+// Reload re-reads config.json, so edits made to the file directly take
+// effect without a restart. Every value is validated as Set would; on error
+// the current overrides are kept.
+func (m *Manager) Reload() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	overrides, err := loadOverrides(m.path)
+	if err != nil {
+		return err
+	}
+	if err := validateOverrides("", overrides); err != nil {
+		return err
+	}
+	if err := validateCacheConstraintsForPath("cache.capacity.lowWatermark", m.defaults, overrides); err != nil {
+		return fmt.Errorf("cache.capacity: %w", err)
+	}
+	m.overrides = overrides
+	return nil
+}
+
+// validateOverrides runs validateValue on every leaf of a config object.
+func validateOverrides(prefix string, values map[string]any) error {
+	for key, value := range values {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		if nested, ok := value.(map[string]any); ok {
+			if err := validateOverrides(path, nested); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := validateValue(path, value); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return nil
+}
+
 func (m *Manager) Schema() any {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -313,7 +717,7 @@ func loadOverrides(path string) (map[string]any, error) {
 }
 
 func validateValue(path string, value any) error {
-	if path == "orchestrator.jobs.maxIdentical" {
+	if path == "orchestrator.jobs.maxIdentical" || path == "orchestrator.jobs.maxQueued" || path == "prepare.psql.maxScriptBytes" || path == "prepare.psql.maxFiles" || path == "prepare.psql.maxStdinBytes" || path == "container.warmPool.size" || path == "orchestrator.events.maxPerJob" {
 		if value == nil {
 			return nil
 		}
@@ -325,7 +729,211 @@ func validateValue(path string, value any) error {
 		}
 		return ErrInvalidValue
 	}
-	if path == "cache.capacity.maxBytes" || path == "cache.capacity.reserveBytes" {
+	if path == "orchestrator.jobs.submitRate" {
+		if value == nil {
+			return nil
+		}
+		rate, ok := asFloat(value)
+		if !ok || rate < 0 {
+			return ErrInvalidValue
+		}
+		return nil
+	}
+	if path == "orchestrator.jobs.heartbeatMax" || path == "orchestrator.jobs.sweepInterval" || path == "container.warmPool.idleTimeout" || path == "orchestrator.jobs.disconnectGrace" {
+		if value == nil {
+			return nil
+		}
+		str, ok := value.(string)
+		if !ok {
+			return ErrInvalidValue
+		}
+		ceiling, err := time.ParseDuration(strings.TrimSpace(str))
+		if err != nil || ceiling <= 0 {
+			return ErrInvalidValue
+		}
+		return nil
+	}
+	if path == "orchestrator.tasks.timeout" || path == "prepare.psql.statementTimeout" || path == "orchestrator.jobs.ttl" {
+		if value == nil {
+			return nil
+		}
+		str, ok := value.(string)
+		if !ok {
+			return ErrInvalidValue
+		}
+		timeout, err := time.ParseDuration(strings.TrimSpace(str))
+		if err != nil || timeout < 0 {
+			return ErrInvalidValue
+		}
+		return nil
+	}
+	if path == "shutdown.drainTimeout" {
+		if value == nil {
+			return nil
+		}
+		str, ok := value.(string)
+		if !ok {
+			return ErrInvalidValue
+		}
+		timeout, err := time.ParseDuration(strings.TrimSpace(str))
+		if err != nil || timeout < 0 {
+			return ErrInvalidValue
+		}
+		return nil
+	}
+	if path == "container.retry.maxAttempts" {
+		if value == nil {
+			return nil
+		}
+		if num, ok := asInt(value); ok {
+			if num < 1 {
+				return ErrInvalidValue
+			}
+			return nil
+		}
+		return ErrInvalidValue
+	}
+	if path == "container.retry.baseDelay" {
+		if value == nil {
+			return nil
+		}
+		str, ok := value.(string)
+		if !ok {
+			return ErrInvalidValue
+		}
+		delay, err := time.ParseDuration(strings.TrimSpace(str))
+		if err != nil || delay < 0 {
+			return ErrInvalidValue
+		}
+		return nil
+	}
+	if path == "container.registry.mirror" {
+		if value == nil {
+			return nil
+		}
+		str, ok := value.(string)
+		if !ok {
+			return ErrInvalidValue
+		}
+		mirror := strings.TrimSpace(str)
+		mirror = strings.TrimPrefix(mirror, "https://")
+		mirror = strings.TrimPrefix(mirror, "http://")
+		if strings.ContainsAny(mirror, " \t@") || strings.Contains(mirror, "://") || strings.HasPrefix(mirror, "/") {
+			return ErrInvalidValue
+		}
+		return nil
+	}
+	if path == "otel.endpoint" {
+		if value == nil {
+			return nil
+		}
+		str, ok := value.(string)
+		if !ok {
+			return ErrInvalidValue
+		}
+		endpoint := strings.TrimSpace(str)
+		if endpoint == "" {
+			return nil
+		}
+		parsed, err := url.Parse(endpoint)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return ErrInvalidValue
+		}
+		return nil
+	}
+	if path == "http.cors.allowedOrigins" {
+		if value == nil {
+			return nil
+		}
+		items, ok := value.([]any)
+		if !ok {
+			return ErrInvalidValue
+		}
+		for _, item := range items {
+			origin, ok := item.(string)
+			if !ok || (origin != "*" && !validCORSOrigin(origin)) {
+				return ErrInvalidValue
+			}
+		}
+		return nil
+	}
+	if path == "workdir.root" {
+		if value == nil {
+			return nil
+		}
+		str, ok := value.(string)
+		if !ok {
+			return ErrInvalidValue
+		}
+		root := strings.TrimSpace(str)
+		if root != "" && !filepath.IsAbs(root) {
+			return ErrInvalidValue
+		}
+		return nil
+	}
+	if path == "container.registry.authFile" {
+		if value == nil {
+			return nil
+		}
+		if _, ok := value.(string); !ok {
+			return ErrInvalidValue
+		}
+		return nil
+	}
+	if path == "container.network.allowed" || path == "images.allowed" || path == "images.denied" {
+		if value == nil {
+			return nil
+		}
+		items, ok := value.([]any)
+		if !ok {
+			return ErrInvalidValue
+		}
+		for _, item := range items {
+			name, ok := item.(string)
+			if !ok || strings.TrimSpace(name) == "" {
+				return ErrInvalidValue
+			}
+		}
+		return nil
+	}
+	if path == "container.limits.cpus" {
+		if value == nil {
+			return nil
+		}
+		var cpus float64
+		switch v := value.(type) {
+		case string:
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				return ErrInvalidValue
+			}
+			cpus = parsed
+		default:
+			num, ok := asFloat(value)
+			if !ok {
+				return ErrInvalidValue
+			}
+			cpus = num
+		}
+		if cpus <= 0 {
+			return ErrInvalidValue
+		}
+		return nil
+	}
+	if path == "container.limits.memory" {
+		if value == nil {
+			return nil
+		}
+		str, ok := value.(string)
+		if !ok {
+			return ErrInvalidValue
+		}
+		if _, ok := ParseMemoryLimit(str); !ok {
+			return ErrInvalidValue
+		}
+		return nil
+	}
+	if path == "cache.capacity.maxBytes" || path == "cache.capacity.reserveBytes" || path == "statefs.btrfs.quotaBytes" {
 		if value == nil {
 			return nil
 		}
@@ -363,6 +971,45 @@ func validateValue(path string, value any) error {
 		}
 		return nil
 	}
+	if path == "statefs.verifyChecksums" || path == "prepare.psql.normalizeHash" || path == "prepare.lb.autoReleaseLocks" || path == "orchestrator.jobs.cancelOnDisconnect" || path == "container.build.enabled" || path == "container.offline" {
+		if value == nil {
+			return nil
+		}
+		if _, ok := value.(bool); !ok {
+			return ErrInvalidValue
+		}
+		return nil
+	}
+	if path == "statefs.compression" {
+		if value == nil {
+			return nil
+		}
+		str, ok := value.(string)
+		if !ok {
+			return ErrInvalidValue
+		}
+		switch str {
+		case "none", "zstd":
+			return nil
+		default:
+			return ErrInvalidValue
+		}
+	}
+	if path == "prepare.psql.runner" {
+		if value == nil {
+			return nil
+		}
+		str, ok := value.(string)
+		if !ok {
+			return ErrInvalidValue
+		}
+		switch str {
+		case "container", "native":
+			return nil
+		default:
+			return ErrInvalidValue
+		}
+	}
 	if path == "snapshot.backend" {
 		if value == nil {
 			return nil
@@ -387,12 +1034,69 @@ func validateValue(path string, value any) error {
 			return ErrInvalidValue
 		}
 		switch str {
-		case "auto", "docker", "podman":
+		case "auto", "docker", "podman", "nerdctl", "containerd":
 			return nil
 		default:
 			return ErrInvalidValue
 		}
 	}
+	if path == "container.namespace" {
+		if value == nil {
+			return nil
+		}
+		str, ok := value.(string)
+		if !ok {
+			return ErrInvalidValue
+		}
+		namespace := strings.TrimSpace(str)
+		if namespace != "" && !containerdNamespacePattern.MatchString(namespace) {
+			return ErrInvalidValue
+		}
+		return nil
+	}
+	if path == "container.runAsUser" {
+		if value == nil {
+			return nil
+		}
+		str, ok := value.(string)
+		if !ok {
+			return ErrInvalidValue
+		}
+		user := strings.TrimSpace(str)
+		if user != "" && !containerUserPattern.MatchString(user) {
+			return ErrInvalidValue
+		}
+		return nil
+	}
+	if path == "container.platform" {
+		if value == nil {
+			return nil
+		}
+		str, ok := value.(string)
+		if !ok || !ValidPlatform(str) {
+			return ErrInvalidValue
+		}
+		return nil
+	}
+	if path == "prepare.cache.versionSalt" {
+		if value == nil {
+			return nil
+		}
+		if _, ok := value.(string); !ok {
+			return ErrInvalidValue
+		}
+		return nil
+	}
+	if path == "container.postgres.superuser" {
+		if value == nil {
+			return nil
+		}
+		str, ok := value.(string)
+		if !ok || !postgresRolePattern.MatchString(strings.TrimSpace(str)) {
+			return ErrInvalidValue
+		}
+		return nil
+	}
 	if path == "log.level" {
 		if value == nil {
 			return nil
@@ -466,6 +1170,46 @@ func asFloat(value any) (float64, bool) {
 	}
 }
 
+// validCORSOrigin accepts a browser origin: an http(s) scheme and host with
+// an optional port, and nothing else.
+func validCORSOrigin(value string) bool {
+	parsed, err := url.Parse(value)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return false
+	}
+	return parsed.User == nil && parsed.Path == "" && parsed.RawQuery == "" && parsed.Fragment == "" && !strings.HasSuffix(value, "?")
+}
+
+// ValidPlatform reports whether value, once trimmed, is an OCI platform such
+// as "linux/amd64". container.platform and the prepare --platform option share
+// it.
+func ValidPlatform(value string) bool {
+	return platformPattern.MatchString(strings.TrimSpace(value))
+}
+
+// ParseMemoryLimit parses a container --memory value: a positive integer
+// followed by b, k, m or g, with an optional trailing "b" after k, m or g (for
+// example "512m" or "4gb"). It returns the trimmed, lowercased value. A bare
+// number is rejected because docker would read it as bytes, which is never
+// what was meant.
+func ParseMemoryLimit(value string) (string, bool) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	var number string
+	switch {
+	case len(value) >= 2 && strings.HasSuffix(value, "b") && strings.ContainsAny(value[len(value)-2:len(value)-1], "kmg"):
+		number = value[:len(value)-2]
+	case value != "" && strings.ContainsAny(value[len(value)-1:], "bkmg"):
+		number = value[:len(value)-1]
+	default:
+		return "", false
+	}
+	size, err := strconv.ParseUint(number, 10, 64)
+	if err != nil || size == 0 {
+		return "", false
+	}
+	return value, true
+}
+
 func validateCacheConstraintsForPath(path string, defaults map[string]any, overrides map[string]any) error {
 	if path != "cache.capacity.lowWatermark" && path != "cache.capacity.highWatermark" {
 		return nil
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -558,6 +559,24 @@ func TestValidateValueVariants(t *testing.T) {
 	if err := validateValue("snapshot.backend", 1); err == nil {
 		t.Fatalf("expected non-string snapshot backend to be rejected")
 	}
+	if err := validateValue("statefs.compression", "zstd"); err != nil {
+		t.Fatalf("expected statefs compression zstd to be valid")
+	}
+	if err := validateValue("statefs.compression", "gzip"); err == nil {
+		t.Fatalf("expected unknown statefs compression to be rejected")
+	}
+	if err := validateValue("statefs.btrfs.quotaBytes", int64(1<<30)); err != nil {
+		t.Fatalf("expected btrfs quota to be valid")
+	}
+	if err := validateValue("statefs.btrfs.quotaBytes", int64(-1)); err == nil {
+		t.Fatalf("expected negative btrfs quota to be rejected")
+	}
+	if err := validateValue("prepare.psql.runner", "native"); err != nil {
+		t.Fatalf("expected psql runner native to be valid")
+	}
+	if err := validateValue("prepare.psql.runner", "exec"); err == nil {
+		t.Fatalf("expected unknown psql runner to be rejected")
+	}
 	if err := validateValue("cache.capacity.maxBytes", int64(0)); err != nil {
 		t.Fatalf("expected maxBytes=0 to be valid")
 	}
@@ -585,6 +604,212 @@ func TestValidateValueVariants(t *testing.T) {
 	if err := validateValue("cache.capacity.minStateAge", "bad"); err == nil {
 		t.Fatalf("expected invalid minStateAge to be rejected")
 	}
+	if err := validateValue("container.retry.maxAttempts", 3); err != nil {
+		t.Fatalf("expected retry maxAttempts=3 to be valid")
+	}
+	if err := validateValue("container.retry.maxAttempts", nil); err != nil {
+		t.Fatalf("expected nil retry maxAttempts to be allowed")
+	}
+	if err := validateValue("container.retry.maxAttempts", 0); err == nil {
+		t.Fatalf("expected retry maxAttempts=0 to be rejected")
+	}
+	if err := validateValue("container.retry.maxAttempts", "3"); err == nil {
+		t.Fatalf("expected non-integer retry maxAttempts to be rejected")
+	}
+	if err := validateValue("container.retry.baseDelay", "250ms"); err != nil {
+		t.Fatalf("expected retry baseDelay=250ms to be valid")
+	}
+	if err := validateValue("container.retry.baseDelay", nil); err != nil {
+		t.Fatalf("expected nil retry baseDelay to be allowed")
+	}
+	if err := validateValue("container.retry.baseDelay", "-1s"); err == nil {
+		t.Fatalf("expected negative retry baseDelay to be rejected")
+	}
+	if err := validateValue("container.retry.baseDelay", 5); err == nil {
+		t.Fatalf("expected non-string retry baseDelay to be rejected")
+	}
+	if err := validateValue("orchestrator.tasks.timeout", "30m"); err != nil {
+		t.Fatalf("expected task timeout=30m to be valid")
+	}
+	if err := validateValue("orchestrator.tasks.timeout", "0"); err != nil {
+		t.Fatalf("expected task timeout=0 to be allowed")
+	}
+	if err := validateValue("orchestrator.tasks.timeout", "-1m"); err == nil {
+		t.Fatalf("expected negative task timeout to be rejected")
+	}
+	if err := validateValue("orchestrator.tasks.timeout", 600); err == nil {
+		t.Fatalf("expected non-string task timeout to be rejected")
+	}
+	if err := validateValue("prepare.psql.statementTimeout", "30s"); err != nil {
+		t.Fatalf("expected statement timeout=30s to be valid")
+	}
+	if err := validateValue("prepare.psql.statementTimeout", "soon"); err == nil {
+		t.Fatalf("expected invalid statement timeout to be rejected")
+	}
+	if err := validateValue("prepare.psql.normalizeHash", true); err != nil {
+		t.Fatalf("expected normalizeHash=true to be valid")
+	}
+	if err := validateValue("prepare.psql.normalizeHash", "yes"); err == nil {
+		t.Fatalf("expected non-boolean normalizeHash to be rejected")
+	}
+	if err := validateValue("prepare.lb.autoReleaseLocks", true); err != nil {
+		t.Fatalf("expected autoReleaseLocks=true to be valid")
+	}
+	if err := validateValue("prepare.lb.autoReleaseLocks", "yes"); err == nil {
+		t.Fatalf("expected non-boolean autoReleaseLocks to be rejected")
+	}
+	if err := validateValue("container.build.enabled", "yes"); err == nil {
+		t.Fatalf("expected non-boolean build.enabled to be rejected")
+	}
+	if err := validateValue("container.offline", "yes"); err == nil {
+		t.Fatalf("expected non-boolean container.offline to be rejected")
+	}
+	if err := validateValue("prepare.cache.versionSalt", "2026-01"); err != nil {
+		t.Fatalf("expected string versionSalt to be valid")
+	}
+	if err := validateValue("prepare.cache.versionSalt", 1); err == nil {
+		t.Fatalf("expected non-string versionSalt to be rejected")
+	}
+	if err := validateValue("shutdown.drainTimeout", "1m"); err != nil {
+		t.Fatalf("expected drain timeout=1m to be valid")
+	}
+	if err := validateValue("shutdown.drainTimeout", "bad"); err == nil {
+		t.Fatalf("expected invalid drain timeout to be rejected")
+	}
+	if err := validateValue("shutdown.drainTimeout", 30); err == nil {
+		t.Fatalf("expected non-string drain timeout to be rejected")
+	}
+	if err := validateValue("container.registry.mirror", "mirror.local:5000"); err != nil {
+		t.Fatalf("expected registry mirror host to be valid")
+	}
+	if err := validateValue("container.registry.mirror", "https://mirror.local/proxy"); err != nil {
+		t.Fatalf("expected registry mirror with scheme and path to be valid")
+	}
+	if err := validateValue("container.registry.mirror", "user@mirror.local"); err == nil {
+		t.Fatalf("expected registry mirror with credentials to be rejected")
+	}
+	if err := validateValue("container.registry.mirror", 5000); err == nil {
+		t.Fatalf("expected non-string registry mirror to be rejected")
+	}
+	if err := validateValue("otel.endpoint", "http://127.0.0.1:4318"); err != nil {
+		t.Fatalf("expected otel endpoint URL to be valid")
+	}
+	if err := validateValue("otel.endpoint", "collector:4318"); err == nil {
+		t.Fatalf("expected otel endpoint without scheme to be rejected")
+	}
+	if err := validateValue("otel.endpoint", 4318); err == nil {
+		t.Fatalf("expected non-string otel endpoint to be rejected")
+	}
+	if err := validateValue("http.cors.allowedOrigins", []any{"https://dash.example.com", "http://localhost:3000", "*"}); err != nil {
+		t.Fatalf("expected CORS origins to be valid")
+	}
+	for _, origin := range []string{"dash.example.com", "https://dash.example.com/app", "ftp://dash.example.com", ""} {
+		if err := validateValue("http.cors.allowedOrigins", []any{origin}); err == nil {
+			t.Fatalf("expected CORS origin %q to be rejected", origin)
+		}
+	}
+	if err := validateValue("workdir.root", "/var/tmp/sqlrs"); err != nil {
+		t.Fatalf("expected absolute workdir root to be valid")
+	}
+	if err := validateValue("workdir.root", ""); err != nil {
+		t.Fatalf("expected empty workdir root to be valid")
+	}
+	if err := validateValue("workdir.root", "scratch"); err == nil {
+		t.Fatalf("expected relative workdir root to be rejected")
+	}
+	if err := validateValue("workdir.root", 1); err == nil {
+		t.Fatalf("expected non-string workdir root to be rejected")
+	}
+	if err := validateValue("container.registry.authFile", "/etc/sqlrs/auth.json"); err != nil {
+		t.Fatalf("expected registry authFile path to be valid")
+	}
+	if err := validateValue("container.registry.authFile", true); err == nil {
+		t.Fatalf("expected non-string registry authFile to be rejected")
+	}
+	if err := validateValue("container.network.allowed", []any{"bridge", "corp-net"}); err != nil {
+		t.Fatalf("expected network allowlist to be valid")
+	}
+	if err := validateValue("container.network.allowed", []any{"bridge", ""}); err == nil {
+		t.Fatalf("expected empty network name to be rejected")
+	}
+	if err := validateValue("container.network.allowed", "bridge"); err == nil {
+		t.Fatalf("expected non-array network allowlist to be rejected")
+	}
+	if err := validateValue("images.allowed", []any{"registry.internal/*"}); err != nil {
+		t.Fatalf("expected image patterns to be valid")
+	}
+	if err := validateValue("images.denied", []any{" "}); err == nil {
+		t.Fatalf("expected blank image pattern to be rejected")
+	}
+	if err := validateValue("container.limits.cpus", 1.5); err != nil {
+		t.Fatalf("expected numeric cpu limit to be valid")
+	}
+	if err := validateValue("container.limits.cpus", "2"); err != nil {
+		t.Fatalf("expected string cpu limit to be valid")
+	}
+	if err := validateValue("container.limits.cpus", 0); err == nil {
+		t.Fatalf("expected zero cpu limit to be rejected")
+	}
+	if err := validateValue("container.limits.memory", "4g"); err != nil {
+		t.Fatalf("expected memory limit with unit to be valid")
+	}
+	if err := validateValue("container.limits.memory", "512mb"); err != nil {
+		t.Fatalf("expected memory limit with mb unit to be valid")
+	}
+	if err := validateValue("container.limits.memory", "512"); err == nil {
+		t.Fatalf("expected memory limit without unit to be rejected")
+	}
+	if err := validateValue("container.limits.memory", 512); err == nil {
+		t.Fatalf("expected non-string memory limit to be rejected")
+	}
+	if err := validateValue("orchestrator.jobs.maxQueued", 0); err != nil {
+		t.Fatalf("expected zero maxQueued to be valid")
+	}
+	if err := validateValue("orchestrator.jobs.maxQueued", -1); err == nil {
+		t.Fatalf("expected negative maxQueued to be rejected")
+	}
+	if err := validateValue("orchestrator.events.maxPerJob", 10000); err != nil {
+		t.Fatalf("expected maxPerJob count to be valid")
+	}
+	if err := validateValue("orchestrator.events.maxPerJob", -1); err == nil {
+		t.Fatalf("expected negative maxPerJob to be rejected")
+	}
+	if err := validateValue("orchestrator.jobs.submitRate", 0.5); err != nil {
+		t.Fatalf("expected fractional submitRate to be valid")
+	}
+	if err := validateValue("orchestrator.jobs.submitRate", "fast"); err == nil {
+		t.Fatalf("expected non-numeric submitRate to be rejected")
+	}
+	if err := validateValue("orchestrator.jobs.heartbeatMax", "30s"); err != nil {
+		t.Fatalf("expected heartbeatMax duration to be valid")
+	}
+	if err := validateValue("orchestrator.jobs.heartbeatMax", "0s"); err == nil {
+		t.Fatalf("expected zero heartbeatMax to be rejected")
+	}
+	if err := validateValue("orchestrator.jobs.disconnectGrace", "0s"); err == nil {
+		t.Fatalf("expected zero disconnectGrace to be rejected")
+	}
+	if err := validateValue("orchestrator.jobs.cancelOnDisconnect", "yes"); err == nil {
+		t.Fatalf("expected non-boolean cancelOnDisconnect to be rejected")
+	}
+	if err := validateValue("orchestrator.jobs.ttl", "168h"); err != nil {
+		t.Fatalf("expected ttl duration to be valid")
+	}
+	if err := validateValue("orchestrator.jobs.ttl", "-1h"); err == nil {
+		t.Fatalf("expected negative ttl to be rejected")
+	}
+	if err := validateValue("orchestrator.jobs.sweepInterval", "0s"); err == nil {
+		t.Fatalf("expected zero sweepInterval to be rejected")
+	}
+	if err := validateValue("container.warmPool.size", 2); err != nil {
+		t.Fatalf("expected warmPool.size to be valid")
+	}
+	if err := validateValue("container.warmPool.size", -1); err == nil {
+		t.Fatalf("expected negative warmPool.size to be rejected")
+	}
+	if err := validateValue("container.warmPool.idleTimeout", "0s"); err == nil {
+		t.Fatalf("expected zero warmPool.idleTimeout to be rejected")
+	}
 	if err := validateValue("container.runtime", nil); err != nil {
 		t.Fatalf("expected nil container runtime to be allowed")
 	}
@@ -597,6 +822,59 @@ func TestValidateValueVariants(t *testing.T) {
 	if err := validateValue("container.runtime", "podman"); err != nil {
 		t.Fatalf("expected container runtime podman to be valid")
 	}
+	if err := validateValue("container.runtime", "nerdctl"); err != nil {
+		t.Fatalf("expected container runtime nerdctl to be valid")
+	}
+	if err := validateValue("container.runtime", "containerd"); err != nil {
+		t.Fatalf("expected container runtime containerd to be valid")
+	}
+	if err := validateValue("container.namespace", "ci.builds-1"); err != nil {
+		t.Fatalf("expected containerd namespace to be valid")
+	}
+	if err := validateValue("container.namespace", nil); err != nil {
+		t.Fatalf("expected nil containerd namespace to be allowed")
+	}
+	if err := validateValue("container.namespace", "bad namespace"); err == nil {
+		t.Fatalf("expected invalid containerd namespace to be rejected")
+	}
+	if err := validateValue("container.namespace", 1); err == nil {
+		t.Fatalf("expected non-string containerd namespace to be rejected")
+	}
+	if err := validateValue("container.postgres.superuser", "postgres"); err != nil {
+		t.Fatalf("expected postgres superuser to be valid")
+	}
+	if err := validateValue("container.postgres.superuser", nil); err != nil {
+		t.Fatalf("expected nil postgres superuser to be allowed")
+	}
+	if err := validateValue("container.postgres.superuser", "bad-role"); err == nil {
+		t.Fatalf("expected quoted-only role name to be rejected")
+	}
+	if err := validateValue("container.postgres.superuser", ""); err == nil {
+		t.Fatalf("expected empty postgres superuser to be rejected")
+	}
+	if err := validateValue("container.postgres.superuser", strings.Repeat("a", 64)); err == nil {
+		t.Fatalf("expected postgres superuser over 63 bytes to be rejected")
+	}
+	for _, user := range []any{"1000", "1000:1000", "postgres", "app:staff", "", nil} {
+		if err := validateValue("container.runAsUser", user); err != nil {
+			t.Fatalf("expected container user %v to be valid", user)
+		}
+	}
+	for _, user := range []any{"1000:", ":1000", "root --privileged", "1000:1000:1", 1000} {
+		if err := validateValue("container.runAsUser", user); err == nil {
+			t.Fatalf("expected container user %v to be rejected", user)
+		}
+	}
+	for _, platform := range []string{"linux/amd64", "linux/arm64/v8"} {
+		if err := validateValue("container.platform", platform); err != nil {
+			t.Fatalf("expected platform %q to be valid", platform)
+		}
+	}
+	for _, platform := range []any{"amd64", "linux/amd64/v8/x", "Linux/AMD64", "linux/amd64 --privileged", 1} {
+		if err := validateValue("container.platform", platform); err == nil {
+			t.Fatalf("expected platform %v to be rejected", platform)
+		}
+	}
 	if err := validateValue("container.runtime", "bad"); err == nil {
 		t.Fatalf("expected invalid container runtime to be rejected")
 	}
@@ -1230,3 +1508,111 @@ func testDefaults() map[string]any {
 		},
 	}
 }
+
+func TestCheckValueAgainstSchema(t *testing.T) {
+	schema := DefaultSchema()
+	valid := map[string]any{
+		"log.level":                      "info",
+		"container.runtime":              nil,
+		"orchestrator.jobs.maxIdentical": json.Number("3"),
+		"cache.capacity.highWatermark":   0.95,
+		"container.registry.mirror":      "mirror.local",
+	}
+	for path, value := range valid {
+		if err := CheckValue(schema, path, value); err != nil {
+			t.Fatalf("CheckValue(%s, %v): %v", path, value, err)
+		}
+	}
+	if err := CheckValue(schema, "features.flag", true); !errors.Is(err, ErrInvalidPath) {
+		t.Fatalf("expected unknown path to be rejected, got %v", err)
+	}
+	if err := CheckValue(schema, "log", "debug"); err == nil {
+		t.Fatalf("expected object path to reject string value")
+	}
+	invalid := map[string]any{
+		"log.level":                      "verbose",
+		"orchestrator.jobs.maxIdentical": "2",
+		"cache.capacity.highWatermark":   1.5,
+		"container.retry.maxAttempts":    0,
+		"shutdown.drainTimeout":          30,
+	}
+	for path, value := range invalid {
+		if err := CheckValue(schema, path, value); !errors.Is(err, ErrInvalidValue) {
+			t.Fatalf("CheckValue(%s, %v): expected invalid value, got %v", path, value, err)
+		}
+	}
+}
+
+func TestConfigReloadPicksUpFileChanges(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	mgr, err := NewManager(Options{
+		StateStoreRoot: dir,
+		Defaults:       testDefaults(),
+	})
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+
+	writeConfigFile(t, path, map[string]any{
+		"orchestrator": map[string]any{"jobs": map[string]any{"maxIdentical": 7}},
+	})
+	if err := mgr.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	value, err := mgr.Get("orchestrator.jobs.maxIdentical", true)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got, ok := asInt(value); !ok || got != 7 {
+		t.Fatalf("expected reloaded maxIdentical=7, got %#v", value)
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("remove config: %v", err)
+	}
+	if err := mgr.Reload(); err != nil {
+		t.Fatalf("Reload without file: %v", err)
+	}
+	if value, _ := mgr.Get("orchestrator.jobs.maxIdentical", true); value != 2 {
+		t.Fatalf("expected default after file removal, got %#v", value)
+	}
+}
+
+func TestConfigReloadKeepsOverridesOnError(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	writeConfigFile(t, path, map[string]any{
+		"orchestrator": map[string]any{"jobs": map[string]any{"maxIdentical": 5}},
+	})
+	mgr, err := NewManager(Options{
+		StateStoreRoot: dir,
+		Defaults:       testDefaults(),
+	})
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+
+	cases := map[string]map[string]any{
+		"invalid value": {"orchestrator": map[string]any{"jobs": map[string]any{"maxIdentical": -1}}},
+		"watermarks":    {"cache": map[string]any{"capacity": map[string]any{"lowWatermark": 0.95, "highWatermark": 0.9}}},
+	}
+	for name, overrides := range cases {
+		writeConfigFile(t, path, overrides)
+		err := mgr.Reload()
+		if !errors.Is(err, ErrInvalidValue) {
+			t.Fatalf("%s: expected ErrInvalidValue, got %v", name, err)
+		}
+		value, _ := mgr.Get("orchestrator.jobs.maxIdentical", true)
+		if got, ok := asInt(value); !ok || got != 5 {
+			t.Fatalf("%s: expected previous override to be kept, got %#v", name, value)
+		}
+	}
+
+	if err := os.WriteFile(path, []byte("{"), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := mgr.Reload(); err == nil {
+		t.Fatalf("expected invalid JSON to fail reload")
+	}
+}
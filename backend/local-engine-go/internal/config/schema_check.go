@@ -0,0 +1,121 @@
+package config
+
+import (
+	"fmt"
+	"math"
+)
+
+// CheckValue validates a value for path against a JSON schema such as
+// DefaultSchema. Unlike Set, which accepts any well-formed path, it only
+// accepts paths declared in the schema: it returns ErrInvalidPath for paths
+// the schema does not describe and ErrInvalidValue for type, enum or range
+// mismatches.
+func CheckValue(schema any, path string, value any) error {
+	segments, err := parsePath(path)
+	if err != nil || len(segments) == 0 {
+		return ErrInvalidPath
+	}
+	node, ok := schema.(map[string]any)
+	if !ok {
+		return ErrInvalidPath
+	}
+	for _, segment := range segments {
+		var next any
+		if segment.isIndex {
+			next = node["items"]
+		} else if properties, ok := node["properties"].(map[string]any); ok {
+			next = properties[segment.key]
+		}
+		child, ok := next.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%w: %s is not described by the config schema", ErrInvalidPath, path)
+		}
+		node = child
+	}
+	if err := checkSchemaNode(node, value); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidValue, path, err)
+	}
+	return nil
+}
+
+func checkSchemaNode(node map[string]any, value any) error {
+	if types := schemaTypes(node["type"]); len(types) > 0 {
+		matched := false
+		for _, typ := range types {
+			if schemaTypeMatches(typ, value) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("expected %v", types)
+		}
+	}
+	if enum, ok := node["enum"].([]any); ok {
+		matched := false
+		for _, allowed := range enum {
+			if allowed == value {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("expected one of %v", enum)
+		}
+	}
+	if number, ok := asFloat(value); ok {
+		if minimum, ok := asFloat(node["minimum"]); ok && number < minimum {
+			return fmt.Errorf("must be >= %v", node["minimum"])
+		}
+		if maximum, ok := asFloat(node["maximum"]); ok && number > maximum {
+			return fmt.Errorf("must be <= %v", node["maximum"])
+		}
+	}
+	return nil
+}
+
+func schemaTypes(value any) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		types := make([]string, 0, len(v))
+		for _, item := range v {
+			if typ, ok := item.(string); ok {
+				types = append(types, typ)
+			}
+		}
+		return types
+	case []string:
+		return v
+	default:
+		return nil
+	}
+}
+
+func schemaTypeMatches(typ string, value any) bool {
+	switch typ {
+	case "null":
+		return value == nil
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "integer":
+		_, ok := asInt(value)
+		return ok
+	case "number":
+		number, ok := asFloat(value)
+		return ok && !math.IsNaN(number) && !math.IsInf(number, 0)
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	default:
+		return false
+	}
+}
@@ -6,12 +6,14 @@ import (
 	"log"
 	"strings"
 
+	"github.com/sqlrs/engine-local/internal/loglevel"
 	"github.com/sqlrs/engine-local/internal/runtime"
 )
 
 type PostgresConnector struct {
 	Runtime  runtime.Runtime
 	logLevel func() string
+	user     string
 }
 
 type PostgresOption func(*PostgresConnector)
@@ -22,6 +24,14 @@ func WithLogLevel(fn func() string) PostgresOption {
 	}
 }
 
+// WithUser sets the superuser the connector connects as; empty keeps
+// runtime.DefaultPostgresSuperuser.
+func WithUser(name string) PostgresOption {
+	return func(connector *PostgresConnector) {
+		connector.user = strings.TrimSpace(name)
+	}
+}
+
 func NewPostgres(runtime runtime.Runtime, opts ...PostgresOption) *PostgresConnector {
 	connector := &PostgresConnector{Runtime: runtime}
 	for _, opt := range opts {
@@ -36,19 +46,19 @@ func (c *PostgresConnector) PrepareSnapshot(ctx context.Context, instance runtim
 	if c == nil || c.Runtime == nil {
 		return fmt.Errorf("runtime is required")
 	}
-	if c.logInfoEnabled() {
+	if c.logEnabled(loglevel.Debug) {
 		log.Printf("pg_ctl stop start instance=%s", instance.ID)
 	}
 	output, err := c.Runtime.Exec(ctx, instance.ID, runtime.ExecRequest{
 		User: "postgres",
 		Args: []string{"pg_ctl", "-D", runtime.PostgresDataDir, "-m", "fast", "-w", "stop"},
 	})
-	if c.logInfoEnabled() {
+	if c.logEnabled(loglevel.Debug) {
 		log.Printf("pg_ctl stop result instance=%s err=%v output=%q", instance.ID, err, strings.TrimSpace(output))
 	}
 	if err == nil {
 		if verifyErr := c.verifyStopped(ctx, instance); verifyErr != nil {
-			if c.logInfoEnabled() {
+			if c.logEnabled(loglevel.Warn) {
 				log.Printf("pg_ctl stop verify failed instance=%s err=%v", instance.ID, verifyErr)
 			}
 			return verifyErr
@@ -64,7 +74,7 @@ func (c *PostgresConnector) ResumeSnapshot(ctx context.Context, instance runtime
 	if c == nil || c.Runtime == nil {
 		return fmt.Errorf("runtime is required")
 	}
-	if c.logInfoEnabled() {
+	if c.logEnabled(loglevel.Debug) {
 		log.Printf("pg_ctl start start instance=%s", instance.ID)
 	}
 	if err := c.hardenDataDirPermissions(ctx, instance); err != nil {
@@ -78,10 +88,43 @@ func (c *PostgresConnector) ResumeSnapshot(ctx context.Context, instance runtime
 			"-w", "start",
 		},
 	})
-	if c.logInfoEnabled() {
+	if c.logEnabled(loglevel.Debug) {
 		log.Printf("pg_ctl start result instance=%s err=%v output=%q", instance.ID, err, strings.TrimSpace(output))
 	}
-	return err
+	if err != nil {
+		return err
+	}
+	return c.verifyConnectable(ctx, instance)
+}
+
+// User returns the superuser the connector connects as.
+func (c *PostgresConnector) User() string {
+	if c == nil {
+		return runtime.DefaultPostgresSuperuser
+	}
+	return runtime.PostgresSuperuser(c.user)
+}
+
+// verifyConnectable logs in as the superuser after a resume. pg_ctl start
+// succeeds for any data dir, so a state bootstrapped for another role would
+// otherwise only fail at the first task.
+func (c *PostgresConnector) verifyConnectable(ctx context.Context, instance runtime.Instance) error {
+	output, err := c.Runtime.Exec(ctx, instance.ID, runtime.ExecRequest{
+		User: "postgres",
+		Args: []string{
+			"psql", "-h", "127.0.0.1", "-p", "5432",
+			"-U", c.User(), "-d", "postgres",
+			"-w", "-Atc", "SELECT 1",
+		},
+	})
+	if err != nil {
+		msg := strings.TrimSpace(output)
+		if msg == "" {
+			msg = err.Error()
+		}
+		return fmt.Errorf("cannot connect as postgres superuser %q: %s", c.User(), msg)
+	}
+	return nil
 }
 
 func (c *PostgresConnector) relaxHostSnapshotAccess(ctx context.Context, instance runtime.Instance) error {
@@ -133,18 +176,12 @@ func (c *PostgresConnector) verifyStopped(ctx context.Context, instance runtime.
 	return fmt.Errorf("cannot verify postgres stopped: %s", msg)
 }
 
-func (c *PostgresConnector) logInfoEnabled() bool {
-	level := "debug"
+// logEnabled reports whether log.level shows messages of severity min.
+// pg_ctl exec lines are debug; a failed stop verification is a warning.
+func (c *PostgresConnector) logEnabled(min string) bool {
+	level := ""
 	if c != nil && c.logLevel != nil {
-		value := strings.TrimSpace(strings.ToLower(c.logLevel()))
-		if value != "" {
-			level = value
-		}
-	}
-	switch level {
-	case "debug", "info":
-		return true
-	default:
-		return false
+		level = c.logLevel()
 	}
+	return loglevel.Allows(level, min)
 }
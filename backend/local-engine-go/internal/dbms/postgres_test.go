@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/sqlrs/engine-local/internal/loglevel"
 	"github.com/sqlrs/engine-local/internal/runtime"
 )
 
@@ -20,10 +21,18 @@ func (f *fakeRuntime) InitBase(ctx context.Context, imageID string, dataDir stri
 	return nil
 }
 
-func (f *fakeRuntime) ResolveImage(ctx context.Context, imageID string) (string, error) {
+func (f *fakeRuntime) ResolveImage(ctx context.Context, imageID string, platform string) (string, error) {
 	return imageID, nil
 }
 
+func (*fakeRuntime) Build(ctx context.Context, req runtime.BuildRequest) (string, error) {
+	return "sha256:built", nil
+}
+
+func (*fakeRuntime) HasImageLocally(ctx context.Context, ref string) (string, bool, error) {
+	return ref, true, nil
+}
+
 func (f *fakeRuntime) Start(ctx context.Context, req runtime.StartRequest) (runtime.Instance, error) {
 	return runtime.Instance{}, nil
 }
@@ -44,6 +53,14 @@ func (f *fakeRuntime) WaitForReady(ctx context.Context, id string, timeout time.
 	return nil
 }
 
+func (f *fakeRuntime) Ping(ctx context.Context) (string, error) {
+	return "test", nil
+}
+
+func (f *fakeRuntime) ListManaged(ctx context.Context) ([]runtime.ManagedContainer, error) {
+	return nil, nil
+}
+
 func TestPostgresConnectorPrepareSnapshot(t *testing.T) {
 	rt := &fakeRuntime{}
 	rt.execFunc = func(ctx context.Context, id string, req runtime.ExecRequest) (string, error) {
@@ -92,8 +109,8 @@ func TestPostgresConnectorResumeSnapshot(t *testing.T) {
 	if err := connector.ResumeSnapshot(context.Background(), runtime.Instance{ID: "c1"}); err != nil {
 		t.Fatalf("ResumeSnapshot: %v", err)
 	}
-	if len(rt.execCalls) != 2 {
-		t.Fatalf("expected 2 exec calls, got %d", len(rt.execCalls))
+	if len(rt.execCalls) != 3 {
+		t.Fatalf("expected 3 exec calls, got %d", len(rt.execCalls))
 	}
 	chmodArgs := rt.execCalls[0].Args
 	if len(chmodArgs) == 0 || chmodArgs[0] != "chmod" {
@@ -109,6 +126,42 @@ func TestPostgresConnectorResumeSnapshot(t *testing.T) {
 	if !hasArgs(args, "-D", runtime.PostgresDataDir) {
 		t.Fatalf("expected pgdata path %q in args: %v", runtime.PostgresDataDir, args)
 	}
+	connArgs := rt.execCalls[2].Args
+	if len(connArgs) == 0 || connArgs[0] != "psql" || !hasArgs(connArgs, "-U", runtime.DefaultPostgresSuperuser) {
+		t.Fatalf("expected psql connection check as default superuser: %v", connArgs)
+	}
+}
+
+func TestPostgresConnectorResumeSnapshotWithUser(t *testing.T) {
+	rt := &fakeRuntime{}
+	connector := NewPostgres(rt, WithUser("postgres"))
+	if connector.User() != "postgres" {
+		t.Fatalf("expected user postgres, got %q", connector.User())
+	}
+	if err := connector.ResumeSnapshot(context.Background(), runtime.Instance{ID: "c1"}); err != nil {
+		t.Fatalf("ResumeSnapshot: %v", err)
+	}
+	if len(rt.execCalls) != 3 || !hasArgs(rt.execCalls[2].Args, "-U", "postgres") {
+		t.Fatalf("expected connection check as postgres, got %+v", rt.execCalls)
+	}
+	if NewPostgres(rt, WithUser("  ")).User() != runtime.DefaultPostgresSuperuser {
+		t.Fatalf("expected blank user to fall back to the default")
+	}
+}
+
+func TestPostgresConnectorResumeSnapshotMissingRole(t *testing.T) {
+	rt := &fakeRuntime{}
+	rt.execFunc = func(ctx context.Context, id string, req runtime.ExecRequest) (string, error) {
+		if len(req.Args) > 0 && req.Args[0] == "psql" {
+			return "psql: error: FATAL:  role \"postgres\" does not exist", errors.New("exit status 2")
+		}
+		return "", nil
+	}
+	connector := NewPostgres(rt, WithUser("postgres"))
+	err := connector.ResumeSnapshot(context.Background(), runtime.Instance{ID: "c1"})
+	if err == nil || !strings.Contains(err.Error(), `superuser "postgres"`) || !strings.Contains(err.Error(), "does not exist") {
+		t.Fatalf("expected missing role error, got %v", err)
+	}
 }
 
 func TestPostgresConnectorRequiresRuntime(t *testing.T) {
@@ -145,18 +198,21 @@ func TestPostgresConnectorPrepareSnapshotVerifyFails(t *testing.T) {
 	}
 }
 
-func TestPostgresConnectorLogInfoEnabled(t *testing.T) {
+func TestPostgresConnectorLogEnabled(t *testing.T) {
 	connector := &PostgresConnector{}
-	if !connector.logInfoEnabled() {
-		t.Fatalf("expected default log level to be enabled")
+	if !connector.logEnabled(loglevel.Debug) {
+		t.Fatalf("expected default log level to enable debug logging")
 	}
 	connector = NewPostgres(&fakeRuntime{}, WithLogLevel(func() string { return "warn" }))
-	if connector.logInfoEnabled() {
+	if connector.logEnabled(loglevel.Info) {
 		t.Fatalf("expected warn to disable info logging")
 	}
+	if !connector.logEnabled(loglevel.Warn) {
+		t.Fatalf("expected warn to keep warnings")
+	}
 	connector = NewPostgres(&fakeRuntime{}, WithLogLevel(func() string { return "INFO" }))
-	if !connector.logInfoEnabled() {
-		t.Fatalf("expected info to enable logging")
+	if !connector.logEnabled(loglevel.Info) || connector.logEnabled(loglevel.Debug) {
+		t.Fatalf("expected info to enable info but not debug logging")
 	}
 }
 
@@ -8,6 +8,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/sqlrs/engine-local/internal/config"
 	"github.com/sqlrs/engine-local/internal/conntrack"
 	"github.com/sqlrs/engine-local/internal/runtime"
 	"github.com/sqlrs/engine-local/internal/statefs"
@@ -31,6 +32,7 @@ type Options struct {
 	Runtime        runtime.Runtime
 	StateFS        statefs.StateFS
 	StateStoreRoot string
+	Config         config.Store
 }
 
 type Manager struct {
@@ -39,6 +41,7 @@ type Manager struct {
 	runtime        runtime.Runtime
 	statefs        statefs.StateFS
 	stateStoreRoot string
+	config         config.Store
 }
 
 var (
@@ -67,6 +70,7 @@ type DeleteNode struct {
 	RuntimeID   *string      `json:"runtime_id,omitempty"`
 	RuntimeDir  *string      `json:"-"`
 	ImageID     *string      `json:"-"`
+	Namespace   string       `json:"-"`
 	Children    []DeleteNode `json:"children,omitempty"`
 }
 
@@ -84,6 +88,7 @@ func NewManager(opts Options) (*Manager, error) {
 		runtime:        opts.Runtime,
 		statefs:        opts.StateFS,
 		stateStoreRoot: strings.TrimSpace(opts.StateStoreRoot),
+		config:         opts.Config,
 	}, nil
 }
 
@@ -148,9 +153,10 @@ func (m *Manager) DeleteState(ctx context.Context, stateID string, opts DeleteOp
 			return DeleteResult{}, true, err
 		}
 		node := DeleteNode{
-			Kind:    "state",
-			ID:      stateID,
-			ImageID: strPtr(entry.ImageID),
+			Kind:      "state",
+			ID:        stateID,
+			ImageID:   strPtr(entry.ImageID),
+			Namespace: entry.Namespace,
 		}
 		if hasDescendants {
 			node.Blocked = BlockHasDescendants
@@ -169,7 +175,7 @@ func (m *Manager) DeleteState(ctx context.Context, stateID string, opts DeleteOp
 		if opts.DryRun {
 			return result, true, nil
 		}
-		if err := m.removeStateDir(node.ImageID, node.ID); err != nil {
+		if err := m.removeStateDir(node.Namespace, node.ImageID, node.ID); err != nil {
 			return DeleteResult{}, true, err
 		}
 		if err := m.store.DeleteState(ctx, stateID); err != nil {
@@ -220,9 +226,10 @@ func (m *Manager) buildStateNode(ctx context.Context, stateID string, opts Delet
 		return DeleteNode{}, false, storeError("state not found")
 	}
 	node := DeleteNode{
-		Kind:    "state",
-		ID:      stateID,
-		ImageID: strPtr(entry.ImageID),
+		Kind:      "state",
+		ID:        stateID,
+		ImageID:   strPtr(entry.ImageID),
+		Namespace: entry.Namespace,
 	}
 
 	blocked := false
@@ -286,7 +293,7 @@ func (m *Manager) deleteTree(ctx context.Context, node DeleteNode) error {
 		}
 		return m.store.DeleteInstance(ctx, node.ID)
 	case "state":
-		if err := m.removeStateDir(node.ImageID, node.ID); err != nil {
+		if err := m.removeStateDir(node.Namespace, node.ImageID, node.ID); err != nil {
 			return err
 		}
 		return m.store.DeleteState(ctx, node.ID)
@@ -355,7 +362,7 @@ func (m *Manager) removeRuntimeDir(runtimeDir *string) error {
 	return removeErr
 }
 
-func (m *Manager) removeStateDir(imageID *string, stateID string) error {
+func (m *Manager) removeStateDir(namespace string, imageID *string, stateID string) error {
 	if strings.TrimSpace(m.stateStoreRoot) == "" {
 		return nil
 	}
@@ -369,7 +376,7 @@ func (m *Manager) removeStateDir(imageID *string, stateID string) error {
 	if imageID != nil {
 		img = *imageID
 	}
-	path, err := m.statefs.StateDir(m.stateStoreRoot, img, stateID)
+	path, err := m.statefs.StateDir(statefs.NamespaceRoot(m.stateStoreRoot, namespace), img, stateID)
 	if err != nil {
 		return err
 	}
@@ -166,7 +166,7 @@ func TestDeleteTreeStateReturnsRemoveStateDirError(t *testing.T) {
 
 func TestRemoveStateDirHandlesNilStateFSWithRoot(t *testing.T) {
 	mgr := &Manager{stateStoreRoot: t.TempDir()}
-	if err := mgr.removeStateDir(strPtr("img"), "state-1"); err != nil {
+	if err := mgr.removeStateDir("", strPtr("img"), "state-1"); err != nil {
 		t.Fatalf("removeStateDir: %v", err)
 	}
 }
@@ -176,7 +176,7 @@ func TestRemoveStateDirReturnsStateDirError(t *testing.T) {
 		stateStoreRoot: t.TempDir(),
 		statefs:        &fakeStateFS{stateDirErr: errors.New("boom")},
 	}
-	if err := mgr.removeStateDir(strPtr("img"), "state-1"); err == nil {
+	if err := mgr.removeStateDir("", strPtr("img"), "state-1"); err == nil {
 		t.Fatalf("expected state dir error")
 	}
 }
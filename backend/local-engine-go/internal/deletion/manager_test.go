@@ -55,13 +55,21 @@ func (f *fakeRuntime) InitBase(ctx context.Context, imageID string, dataDir stri
 	return nil
 }
 
-func (f *fakeRuntime) ResolveImage(ctx context.Context, imageID string) (string, error) {
+func (f *fakeRuntime) ResolveImage(ctx context.Context, imageID string, platform string) (string, error) {
 	if f.stopErr != nil {
 		return "", f.stopErr
 	}
 	return imageID, nil
 }
 
+func (*fakeRuntime) Build(ctx context.Context, req runtime.BuildRequest) (string, error) {
+	return "sha256:built", nil
+}
+
+func (*fakeRuntime) HasImageLocally(ctx context.Context, ref string) (string, bool, error) {
+	return ref, true, nil
+}
+
 func (f *fakeRuntime) Start(ctx context.Context, req runtime.StartRequest) (runtime.Instance, error) {
 	if f.stopErr != nil {
 		return runtime.Instance{}, f.stopErr
@@ -91,6 +99,14 @@ func (f *fakeRuntime) WaitForReady(ctx context.Context, id string, timeout time.
 	return nil
 }
 
+func (f *fakeRuntime) Ping(ctx context.Context) (string, error) {
+	return "test", nil
+}
+
+func (f *fakeRuntime) ListManaged(ctx context.Context) ([]runtime.ManagedContainer, error) {
+	return nil, nil
+}
+
 type fakeStateFS struct {
 	kind         string
 	removeCalls  []string
@@ -859,7 +875,7 @@ func TestRemoveStateDir(t *testing.T) {
 		t.Fatalf("mkdir state dir: %v", err)
 	}
 	manager := &Manager{stateStoreRoot: root, statefs: fs}
-	if err := manager.removeStateDir(strPtr(imageID), stateID); err != nil {
+	if err := manager.removeStateDir("", strPtr(imageID), stateID); err != nil {
 		t.Fatalf("removeStateDir: %v", err)
 	}
 	if _, err := os.Stat(dir); !os.IsNotExist(err) {
@@ -869,11 +885,11 @@ func TestRemoveStateDir(t *testing.T) {
 
 func TestRemoveStateDirNoop(t *testing.T) {
 	manager := &Manager{}
-	if err := manager.removeStateDir(nil, "state"); err != nil {
+	if err := manager.removeStateDir("", nil, "state"); err != nil {
 		t.Fatalf("expected noop remove, got %v", err)
 	}
 	manager = &Manager{stateStoreRoot: t.TempDir(), statefs: &fakeStateFS{}}
-	if err := manager.removeStateDir(nil, ""); err != nil {
+	if err := manager.removeStateDir("", nil, ""); err != nil {
 		t.Fatalf("expected noop for empty state id, got %v", err)
 	}
 }
@@ -0,0 +1,205 @@
+package deletion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sqlrs/engine-local/internal/statefs"
+	"github.com/sqlrs/engine-local/internal/store"
+)
+
+const defaultPruneMinStateAge = 10 * time.Minute
+
+var nowUTC = func() time.Time { return time.Now().UTC() }
+
+type PruneOptions struct {
+	DryRun    bool
+	OlderThan *time.Duration
+}
+
+type PruneResult struct {
+	DryRun         bool     `json:"dry_run"`
+	Outcome        string   `json:"outcome"`
+	OlderThan      string   `json:"older_than"`
+	StateIDs       []string `json:"state_ids"`
+	ReclaimedBytes int64    `json:"reclaimed_bytes"`
+}
+
+type pruneCandidate struct {
+	entry     store.StateEntry
+	createdAt time.Time
+	sizeBytes int64
+}
+
+// PruneStates removes unreferenced states older than the requested age.
+// States with instances, descendants that survive the prune, or an active
+// minimum retention are kept. Leaves are removed first so a chain of unused
+// states collapses in a single call. When OlderThan is nil the age threshold
+// comes from cache.capacity.minStateAge (see docs/user-guides/sqlrs-cache-capacity.md).
+// Dry runs report the would-delete set without touching disk or the store.
+func (m *Manager) PruneStates(ctx context.Context, opts PruneOptions) (PruneResult, error) {
+	minAge, err := m.pruneMinAge(opts.OlderThan)
+	if err != nil {
+		return PruneResult{}, err
+	}
+	entries, err := m.store.ListStates(ctx, store.StateFilters{})
+	if err != nil {
+		return PruneResult{}, err
+	}
+
+	now := nowUTC()
+	children := map[string]int{}
+	byID := make(map[string]store.StateEntry, len(entries))
+	for _, entry := range entries {
+		byID[entry.StateID] = entry
+		if entry.ParentStateID != nil && strings.TrimSpace(*entry.ParentStateID) != "" {
+			children[*entry.ParentStateID]++
+		}
+	}
+
+	eligible := map[string]pruneCandidate{}
+	for _, entry := range entries {
+		if entry.RefCount > 0 {
+			continue
+		}
+		createdAt, ok := parsePruneTime(entry.CreatedAt)
+		if !ok || now.Sub(createdAt) < minAge {
+			continue
+		}
+		if entry.MinRetentionUntil != nil {
+			if until, ok := parsePruneTime(*entry.MinRetentionUntil); ok && until.After(now) {
+				continue
+			}
+		}
+		eligible[entry.StateID] = pruneCandidate{entry: entry, createdAt: createdAt}
+	}
+
+	var pruned []pruneCandidate
+	for {
+		var leaves []pruneCandidate
+		for id, candidate := range eligible {
+			if children[id] > 0 {
+				continue
+			}
+			leaves = append(leaves, candidate)
+		}
+		if len(leaves) == 0 {
+			break
+		}
+		sort.Slice(leaves, func(i, j int) bool {
+			if !leaves[i].createdAt.Equal(leaves[j].createdAt) {
+				return leaves[i].createdAt.Before(leaves[j].createdAt)
+			}
+			return leaves[i].entry.StateID < leaves[j].entry.StateID
+		})
+		for _, leaf := range leaves {
+			delete(eligible, leaf.entry.StateID)
+			if parent := leaf.entry.ParentStateID; parent != nil {
+				if _, ok := byID[*parent]; ok {
+					children[*parent]--
+				}
+			}
+			pruned = append(pruned, leaf)
+		}
+	}
+
+	result := PruneResult{
+		DryRun:    opts.DryRun,
+		Outcome:   outcomeFor(false, opts.DryRun),
+		OlderThan: minAge.String(),
+		StateIDs:  []string{},
+	}
+	for _, candidate := range pruned {
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+		candidate.sizeBytes = m.stateSizeBytes(candidate.entry)
+		if !opts.DryRun {
+			if err := m.removeStateDir(candidate.entry.Namespace, strPtr(candidate.entry.ImageID), candidate.entry.StateID); err != nil {
+				return result, err
+			}
+			if err := m.store.DeleteState(ctx, candidate.entry.StateID); err != nil {
+				return result, err
+			}
+		}
+		result.StateIDs = append(result.StateIDs, candidate.entry.StateID)
+		result.ReclaimedBytes += candidate.sizeBytes
+	}
+	return result, nil
+}
+
+func (m *Manager) pruneMinAge(override *time.Duration) (time.Duration, error) {
+	if override != nil {
+		if *override < 0 {
+			return 0, fmt.Errorf("older_than must not be negative")
+		}
+		return *override, nil
+	}
+	if m.config == nil {
+		return defaultPruneMinStateAge, nil
+	}
+	value, err := m.config.Get("cache.capacity.minStateAge", true)
+	if err != nil || value == nil {
+		return defaultPruneMinStateAge, nil
+	}
+	raw, ok := value.(string)
+	if !ok {
+		return 0, fmt.Errorf("cache.capacity.minStateAge is invalid")
+	}
+	parsed, err := time.ParseDuration(strings.TrimSpace(raw))
+	if err != nil || parsed < 0 {
+		return 0, fmt.Errorf("cache.capacity.minStateAge is invalid")
+	}
+	return parsed, nil
+}
+
+func (m *Manager) stateSizeBytes(entry store.StateEntry) int64 {
+	if entry.SizeBytes != nil && *entry.SizeBytes > 0 {
+		return *entry.SizeBytes
+	}
+	if m.statefs == nil || strings.TrimSpace(m.stateStoreRoot) == "" {
+		return 0
+	}
+	dir, err := m.statefs.StateDir(statefs.NamespaceRoot(m.stateStoreRoot, entry.Namespace), entry.ImageID, entry.StateID)
+	if err != nil {
+		return 0
+	}
+	var total int64
+	_ = filepath.WalkDir(dir, func(_ string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			if errors.Is(walkErr, os.ErrNotExist) {
+				return nil
+			}
+			return walkErr
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			total += info.Size()
+		}
+		return nil
+	})
+	return total
+}
+
+func parsePruneTime(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, false
+	}
+	if parsed, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return parsed, true
+	}
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		return parsed, true
+	}
+	return time.Time{}, false
+}
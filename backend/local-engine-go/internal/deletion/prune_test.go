@@ -0,0 +1,216 @@
+package deletion
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/sqlrs/engine-local/internal/store"
+)
+
+type fakePruneConfig struct {
+	values map[string]any
+}
+
+func (f fakePruneConfig) Get(path string, effective bool) (any, error) {
+	value, ok := f.values[path]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return value, nil
+}
+
+func (f fakePruneConfig) Set(path string, value any) (any, error) { return value, nil }
+func (f fakePruneConfig) Remove(path string) (any, error)         { return nil, nil }
+func (f fakePruneConfig) Schema() any                             { return nil }
+
+func withPruneNow(t *testing.T, now time.Time) {
+	t.Helper()
+	prev := nowUTC
+	nowUTC = func() time.Time { return now }
+	t.Cleanup(func() { nowUTC = prev })
+}
+
+func pruneState(id string, parent string, createdAt time.Time, size int64, refCount int) store.StateEntry {
+	entry := store.StateEntry{
+		StateID:   id,
+		ImageID:   "postgres:17",
+		CreatedAt: createdAt.Format(time.RFC3339Nano),
+		RefCount:  refCount,
+	}
+	if parent != "" {
+		entry.ParentStateID = strPtr(parent)
+	}
+	if size > 0 {
+		entry.SizeBytes = &size
+	}
+	return entry
+}
+
+func TestPruneStatesDeletesUnreferencedChainLeafFirst(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	withPruneNow(t, now)
+	old := now.Add(-2 * time.Hour)
+
+	st := newFakeStore()
+	st.states["root"] = pruneState("root", "", old, 100, 0)
+	st.states["child"] = pruneState("child", "root", old, 50, 0)
+	root := t.TempDir()
+	fs := &fakeStateFS{}
+	for _, id := range []string{"root", "child"} {
+		dir, err := fs.StateDir(root, "postgres:17", id)
+		if err != nil {
+			t.Fatalf("StateDir: %v", err)
+		}
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+	}
+
+	mgr, err := NewManager(Options{Store: st, StateFS: fs, StateStoreRoot: root})
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	result, err := mgr.PruneStates(context.Background(), PruneOptions{})
+	if err != nil {
+		t.Fatalf("PruneStates: %v", err)
+	}
+	if result.Outcome != OutcomeDeleted || result.DryRun {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if len(result.StateIDs) != 2 || result.StateIDs[0] != "child" || result.StateIDs[1] != "root" {
+		t.Fatalf("expected leaf-first order, got %+v", result.StateIDs)
+	}
+	if result.ReclaimedBytes != 150 {
+		t.Fatalf("expected 150 reclaimed bytes, got %d", result.ReclaimedBytes)
+	}
+	if len(st.states) != 0 {
+		t.Fatalf("expected states to be deleted, got %+v", st.states)
+	}
+	if len(fs.removeCalls) != 2 {
+		t.Fatalf("expected state dirs to be removed, got %+v", fs.removeCalls)
+	}
+}
+
+func TestPruneStatesDryRunDoesNotTouchDisk(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	withPruneNow(t, now)
+
+	st := newFakeStore()
+	st.states["old"] = pruneState("old", "", now.Add(-time.Hour), 10, 0)
+	fs := &fakeStateFS{}
+	mgr, err := NewManager(Options{Store: st, StateFS: fs, StateStoreRoot: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	result, err := mgr.PruneStates(context.Background(), PruneOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("PruneStates: %v", err)
+	}
+	if !result.DryRun || result.Outcome != OutcomeWouldDelete {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if len(result.StateIDs) != 1 || result.StateIDs[0] != "old" || result.ReclaimedBytes != 10 {
+		t.Fatalf("unexpected would-delete set: %+v", result)
+	}
+	if len(fs.removeCalls) != 0 || len(st.deletedStates) != 0 {
+		t.Fatalf("dry run must not delete, removes=%v deletes=%v", fs.removeCalls, st.deletedStates)
+	}
+}
+
+func TestPruneStatesSkipsReferencedYoungAndRetainedStates(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	withPruneNow(t, now)
+	old := now.Add(-time.Hour)
+
+	st := newFakeStore()
+	st.states["parent"] = pruneState("parent", "", old, 1, 0)
+	st.states["used"] = pruneState("used", "parent", old, 1, 1)
+	st.states["young"] = pruneState("young", "", now.Add(-time.Minute), 1, 0)
+	retained := pruneState("retained", "", old, 1, 0)
+	retained.MinRetentionUntil = strPtr(now.Add(time.Hour).Format(time.RFC3339Nano))
+	st.states["retained"] = retained
+
+	mgr, err := NewManager(Options{Store: st})
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	result, err := mgr.PruneStates(context.Background(), PruneOptions{})
+	if err != nil {
+		t.Fatalf("PruneStates: %v", err)
+	}
+	if len(result.StateIDs) != 0 {
+		t.Fatalf("expected nothing pruned, got %+v", result.StateIDs)
+	}
+	if len(st.states) != 4 {
+		t.Fatalf("expected all states kept, got %+v", st.states)
+	}
+}
+
+func TestPruneStatesUsesConfigMinStateAge(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	withPruneNow(t, now)
+
+	st := newFakeStore()
+	st.states["s1"] = pruneState("s1", "", now.Add(-30*time.Minute), 1, 0)
+	cfg := fakePruneConfig{values: map[string]any{"cache.capacity.minStateAge": "1h"}}
+	mgr, err := NewManager(Options{Store: st, Config: cfg})
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	result, err := mgr.PruneStates(context.Background(), PruneOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("PruneStates: %v", err)
+	}
+	if result.OlderThan != "1h0m0s" || len(result.StateIDs) != 0 {
+		t.Fatalf("expected config age to keep state, got %+v", result)
+	}
+
+	override := 10 * time.Minute
+	result, err = mgr.PruneStates(context.Background(), PruneOptions{DryRun: true, OlderThan: &override})
+	if err != nil {
+		t.Fatalf("PruneStates: %v", err)
+	}
+	if len(result.StateIDs) != 1 {
+		t.Fatalf("expected override to select state, got %+v", result)
+	}
+}
+
+func TestPruneStatesRejectsInvalidAge(t *testing.T) {
+	st := newFakeStore()
+	mgr, err := NewManager(Options{Store: st, Config: fakePruneConfig{values: map[string]any{"cache.capacity.minStateAge": "soon"}}})
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	if _, err := mgr.PruneStates(context.Background(), PruneOptions{}); err == nil {
+		t.Fatalf("expected invalid config error")
+	}
+	negative := -time.Second
+	if _, err := mgr.PruneStates(context.Background(), PruneOptions{OlderThan: &negative}); err == nil {
+		t.Fatalf("expected negative override error")
+	}
+}
+
+func TestPruneStatesReturnsStoreErrors(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	withPruneNow(t, now)
+
+	st := newFakeStore()
+	st.listStatesErr = errors.New("boom")
+	mgr, err := NewManager(Options{Store: st})
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	if _, err := mgr.PruneStates(context.Background(), PruneOptions{}); err == nil {
+		t.Fatalf("expected list error")
+	}
+
+	st.listStatesErr = nil
+	st.states["s1"] = pruneState("s1", "", now.Add(-time.Hour), 1, 0)
+	st.deleteStateErr = errors.New("delete failed")
+	if _, err := mgr.PruneStates(context.Background(), PruneOptions{}); err == nil {
+		t.Fatalf("expected delete error")
+	}
+}
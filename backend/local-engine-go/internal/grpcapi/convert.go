@@ -0,0 +1,197 @@
+package grpcapi
+
+import (
+	"github.com/sqlrs/engine-local/internal/deletion"
+	"github.com/sqlrs/engine-local/internal/grpcapi/enginev1"
+	"github.com/sqlrs/engine-local/internal/prepare"
+)
+
+func requestFromProto(req *enginev1.PrepareJobRequest) prepare.Request {
+	out := prepare.Request{
+		PrepareKind:           req.GetPrepareKind(),
+		ImageID:               req.GetImageId(),
+		PsqlArgs:              req.GetPsqlArgs(),
+		LiquibaseArgs:         req.GetLiquibaseArgs(),
+		LiquibaseExec:         req.GetLiquibaseExec(),
+		LiquibaseExecMode:     req.GetLiquibaseExecMode(),
+		LiquibaseEnv:          req.GetLiquibaseEnv(),
+		LiquibaseStdin:        req.LiquibaseStdin,
+		LiquibaseStdinFormat:  req.GetLiquibaseStdinFormat(),
+		FlywayArgs:            req.GetFlywayArgs(),
+		FlywayExec:            req.GetFlywayExec(),
+		FlywayExecMode:        req.GetFlywayExecMode(),
+		FlywayEnv:             req.GetFlywayEnv(),
+		WorkDir:               req.GetWorkDir(),
+		Stdin:                 req.Stdin,
+		PlanOnly:              req.GetPlanOnly(),
+		IdempotencyKey:        req.GetIdempotencyKey(),
+		InstanceMode:          req.GetInstanceMode(),
+		Namespace:             req.GetNamespace(),
+		TaskTimeout:           req.GetTaskTimeout(),
+		HeartbeatEvery:        req.GetHeartbeatEvery(),
+		PsqlSplit:             req.GetPsqlSplit(),
+		PsqlSingleTransaction: req.GetPsqlSingleTransaction(),
+		SearchPath:            req.GetSearchPath(),
+		PsqlPreamble:          req.GetPsqlPreamble(),
+		StatementTimeout:      req.GetStatementTimeout(),
+		Labels:                req.GetLabels(),
+		BaseStateID:           req.GetBaseStateId(),
+		Coalesce:              req.GetCoalesce(),
+		NoCache:               req.GetNoCache(),
+		CancelOnDisconnect:    req.GetCancelOnDisconnect(),
+		CPULimit:              req.GetCpuLimit(),
+		MemoryLimit:           req.GetMemoryLimit(),
+		Network:               req.GetNetwork(),
+		DNS:                   req.GetDns(),
+		Platform:              req.GetPlatform(),
+	}
+	for _, mount := range req.GetMounts() {
+		out.Mounts = append(out.Mounts, prepare.MountSpec{
+			Source:   mount.GetSource(),
+			Target:   mount.GetTarget(),
+			ReadOnly: mount.GetReadOnly(),
+		})
+	}
+	return out
+}
+
+func acceptedToProto(accepted prepare.Accepted) *enginev1.PrepareJobAccepted {
+	return &enginev1.PrepareJobAccepted{
+		JobId:     accepted.JobID,
+		StatusUrl: accepted.StatusURL,
+		EventsUrl: accepted.EventsURL,
+		Status:    accepted.Status,
+	}
+}
+
+func statusToProto(status prepare.Status) *enginev1.PrepareJobStatus {
+	out := &enginev1.PrepareJobStatus{
+		JobId:                 status.JobID,
+		Status:                status.Status,
+		PrepareKind:           status.PrepareKind,
+		ImageId:               status.ImageID,
+		PlanOnly:              status.PlanOnly,
+		PrepareArgsNormalized: status.PrepareArgsNormalized,
+		CreatedAt:             status.CreatedAt,
+		StartedAt:             status.StartedAt,
+		FinishedAt:            status.FinishedAt,
+		Labels:                status.Labels,
+		Result:                resultToProto(status.Result),
+		Error:                 errorToProto(status.Error),
+	}
+	for _, task := range status.Tasks {
+		out.Tasks = append(out.Tasks, planTaskToProto(task))
+	}
+	return out
+}
+
+func planTaskToProto(task prepare.PlanTask) *enginev1.PlanTask {
+	out := &enginev1.PlanTask{
+		TaskId:          task.TaskID,
+		Type:            task.Type,
+		PlannerKind:     task.PlannerKind,
+		ImageId:         task.ImageID,
+		ResolvedImageId: task.ResolvedImageID,
+		TaskHash:        task.TaskHash,
+		OutputStateId:   task.OutputStateID,
+		Cached:          task.Cached,
+		InstanceMode:    task.InstanceMode,
+		ChangesetId:     task.ChangesetID,
+		ChangesetAuthor: task.ChangesetAuthor,
+		ChangesetPath:   task.ChangesetPath,
+		StartedAt:       task.StartedAt,
+		FinishedAt:      task.FinishedAt,
+		DurationMs:      task.DurationMs,
+	}
+	if task.Input != nil {
+		out.Input = &enginev1.TaskInput{Kind: task.Input.Kind, Id: task.Input.ID}
+	}
+	return out
+}
+
+func jobEntryToProto(job prepare.JobEntry) *enginev1.PrepareJobEntry {
+	return &enginev1.PrepareJobEntry{
+		JobId:                 job.JobID,
+		Status:                job.Status,
+		PrepareKind:           job.PrepareKind,
+		ImageId:               job.ImageID,
+		ResolvedImageId:       job.ResolvedImageID,
+		Namespace:             job.Namespace,
+		PrepareArgsNormalized: job.PrepareArgsNormalized,
+		Signature:             job.Signature,
+		PlanOnly:              job.PlanOnly,
+		CreatedAt:             job.CreatedAt,
+		StartedAt:             job.StartedAt,
+		FinishedAt:            job.FinishedAt,
+		Labels:                job.Labels,
+	}
+}
+
+func eventToProto(event prepare.Event) *enginev1.PrepareJobEvent {
+	out := &enginev1.PrepareJobEvent{
+		Seq:     event.Seq,
+		Type:    event.Type,
+		Ts:      event.Ts,
+		Status:  event.Status,
+		TaskId:  event.TaskID,
+		Message: event.Message,
+		Result:  resultToProto(event.Result),
+		Error:   errorToProto(event.Error),
+	}
+	if event.Progress != nil {
+		out.Progress = &enginev1.TaskProgress{
+			Completed: int32(event.Progress.Completed),
+			Total:     int32(event.Progress.Total),
+		}
+	}
+	for _, task := range event.Plan {
+		out.Plan = append(out.Plan, planTaskToProto(task))
+	}
+	return out
+}
+
+func resultToProto(result *prepare.Result) *enginev1.PrepareJobResult {
+	if result == nil {
+		return nil
+	}
+	return &enginev1.PrepareJobResult{
+		Dsn:                   result.DSN,
+		InstanceId:            result.InstanceID,
+		StateId:               result.StateID,
+		ImageId:               result.ImageID,
+		PrepareKind:           result.PrepareKind,
+		PrepareArgsNormalized: result.PrepareArgsNormalized,
+	}
+}
+
+func errorToProto(resp *prepare.ErrorResponse) *enginev1.ErrorResponse {
+	if resp == nil {
+		return nil
+	}
+	return &enginev1.ErrorResponse{Code: resp.Code, Message: resp.Message, Details: resp.Details}
+}
+
+func deleteResultToProto(result deletion.DeleteResult) *enginev1.DeleteResult {
+	return &enginev1.DeleteResult{
+		DryRun:  result.DryRun,
+		Outcome: result.Outcome,
+		Root:    deleteNodeToProto(result.Root),
+	}
+}
+
+func deleteNodeToProto(node deletion.DeleteNode) *enginev1.DeleteNode {
+	out := &enginev1.DeleteNode{
+		Kind:      node.Kind,
+		Id:        node.ID,
+		Blocked:   node.Blocked,
+		RuntimeId: node.RuntimeID,
+	}
+	if node.Connections != nil {
+		connections := int32(*node.Connections)
+		out.Connections = &connections
+	}
+	for _, child := range node.Children {
+		out.Children = append(out.Children, deleteNodeToProto(child))
+	}
+	return out
+}
@@ -0,0 +1,2028 @@
+// gRPC interface of the local sqlrs engine.
+//
+// The engine serves it next to the HTTP API when started with
+// --grpc-listen. Messages mirror the JSON shapes of the OpenAPI spec
+// (sqlrs-engine.openapi.yaml); field names are the JSON property names.
+// Calls carry the engine auth token as "authorization: Bearer <token>"
+// metadata, like the HTTP Authorization header.
+//
+// Failed calls return a gRPC status whose details hold an ErrorResponse with
+// the same stable error code the HTTP API returns.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.10
+// 	protoc        (unknown)
+// source: sqlrs-engine.proto
+
+package enginev1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type PrepareJobRequest struct {
+	state                 protoimpl.MessageState `protogen:"open.v1"`
+	PrepareKind           string                 `protobuf:"bytes,1,opt,name=prepare_kind,json=prepareKind,proto3" json:"prepare_kind,omitempty"`
+	ImageId               string                 `protobuf:"bytes,2,opt,name=image_id,json=imageId,proto3" json:"image_id,omitempty"`
+	PsqlArgs              []string               `protobuf:"bytes,3,rep,name=psql_args,json=psqlArgs,proto3" json:"psql_args,omitempty"`
+	LiquibaseArgs         []string               `protobuf:"bytes,4,rep,name=liquibase_args,json=liquibaseArgs,proto3" json:"liquibase_args,omitempty"`
+	LiquibaseExec         string                 `protobuf:"bytes,5,opt,name=liquibase_exec,json=liquibaseExec,proto3" json:"liquibase_exec,omitempty"`
+	LiquibaseExecMode     string                 `protobuf:"bytes,6,opt,name=liquibase_exec_mode,json=liquibaseExecMode,proto3" json:"liquibase_exec_mode,omitempty"`
+	LiquibaseEnv          map[string]string      `protobuf:"bytes,7,rep,name=liquibase_env,json=liquibaseEnv,proto3" json:"liquibase_env,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	FlywayArgs            []string               `protobuf:"bytes,8,rep,name=flyway_args,json=flywayArgs,proto3" json:"flyway_args,omitempty"`
+	FlywayExec            string                 `protobuf:"bytes,9,opt,name=flyway_exec,json=flywayExec,proto3" json:"flyway_exec,omitempty"`
+	FlywayExecMode        string                 `protobuf:"bytes,10,opt,name=flyway_exec_mode,json=flywayExecMode,proto3" json:"flyway_exec_mode,omitempty"`
+	FlywayEnv             map[string]string      `protobuf:"bytes,11,rep,name=flyway_env,json=flywayEnv,proto3" json:"flyway_env,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	WorkDir               string                 `protobuf:"bytes,12,opt,name=work_dir,json=workDir,proto3" json:"work_dir,omitempty"`
+	Stdin                 *string                `protobuf:"bytes,13,opt,name=stdin,proto3,oneof" json:"stdin,omitempty"`
+	PlanOnly              bool                   `protobuf:"varint,14,opt,name=plan_only,json=planOnly,proto3" json:"plan_only,omitempty"`
+	IdempotencyKey        string                 `protobuf:"bytes,15,opt,name=idempotency_key,json=idempotencyKey,proto3" json:"idempotency_key,omitempty"`
+	InstanceMode          string                 `protobuf:"bytes,16,opt,name=instance_mode,json=instanceMode,proto3" json:"instance_mode,omitempty"`
+	Namespace             string                 `protobuf:"bytes,17,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	TaskTimeout           string                 `protobuf:"bytes,18,opt,name=task_timeout,json=taskTimeout,proto3" json:"task_timeout,omitempty"`
+	HeartbeatEvery        string                 `protobuf:"bytes,19,opt,name=heartbeat_every,json=heartbeatEvery,proto3" json:"heartbeat_every,omitempty"`
+	PsqlSplit             bool                   `protobuf:"varint,20,opt,name=psql_split,json=psqlSplit,proto3" json:"psql_split,omitempty"`
+	PsqlSingleTransaction bool                   `protobuf:"varint,21,opt,name=psql_single_transaction,json=psqlSingleTransaction,proto3" json:"psql_single_transaction,omitempty"`
+	SearchPath            string                 `protobuf:"bytes,22,opt,name=search_path,json=searchPath,proto3" json:"search_path,omitempty"`
+	PsqlPreamble          []string               `protobuf:"bytes,23,rep,name=psql_preamble,json=psqlPreamble,proto3" json:"psql_preamble,omitempty"`
+	StatementTimeout      string                 `protobuf:"bytes,24,opt,name=statement_timeout,json=statementTimeout,proto3" json:"statement_timeout,omitempty"`
+	Mounts                []*MountSpec           `protobuf:"bytes,25,rep,name=mounts,proto3" json:"mounts,omitempty"`
+	Labels                map[string]string      `protobuf:"bytes,26,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	BaseStateId           string                 `protobuf:"bytes,27,opt,name=base_state_id,json=baseStateId,proto3" json:"base_state_id,omitempty"`
+	Coalesce              bool                   `protobuf:"varint,28,opt,name=coalesce,proto3" json:"coalesce,omitempty"`
+	NoCache               bool                   `protobuf:"varint,29,opt,name=no_cache,json=noCache,proto3" json:"no_cache,omitempty"`
+	CpuLimit              string                 `protobuf:"bytes,30,opt,name=cpu_limit,json=cpuLimit,proto3" json:"cpu_limit,omitempty"`
+	MemoryLimit           string                 `protobuf:"bytes,31,opt,name=memory_limit,json=memoryLimit,proto3" json:"memory_limit,omitempty"`
+	Network               string                 `protobuf:"bytes,32,opt,name=network,proto3" json:"network,omitempty"`
+	Dns                   []string               `protobuf:"bytes,33,rep,name=dns,proto3" json:"dns,omitempty"`
+	Platform              string                 `protobuf:"bytes,34,opt,name=platform,proto3" json:"platform,omitempty"`
+	CancelOnDisconnect    bool                   `protobuf:"varint,35,opt,name=cancel_on_disconnect,json=cancelOnDisconnect,proto3" json:"cancel_on_disconnect,omitempty"`
+	LiquibaseStdin        *string                `protobuf:"bytes,36,opt,name=liquibase_stdin,json=liquibaseStdin,proto3,oneof" json:"liquibase_stdin,omitempty"`
+	LiquibaseStdinFormat  string                 `protobuf:"bytes,37,opt,name=liquibase_stdin_format,json=liquibaseStdinFormat,proto3" json:"liquibase_stdin_format,omitempty"`
+	unknownFields         protoimpl.UnknownFields
+	sizeCache             protoimpl.SizeCache
+}
+
+func (x *PrepareJobRequest) Reset() {
+	*x = PrepareJobRequest{}
+	mi := &file_sqlrs_engine_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PrepareJobRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PrepareJobRequest) ProtoMessage() {}
+
+func (x *PrepareJobRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sqlrs_engine_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PrepareJobRequest.ProtoReflect.Descriptor instead.
+func (*PrepareJobRequest) Descriptor() ([]byte, []int) {
+	return file_sqlrs_engine_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *PrepareJobRequest) GetPrepareKind() string {
+	if x != nil {
+		return x.PrepareKind
+	}
+	return ""
+}
+
+func (x *PrepareJobRequest) GetImageId() string {
+	if x != nil {
+		return x.ImageId
+	}
+	return ""
+}
+
+func (x *PrepareJobRequest) GetPsqlArgs() []string {
+	if x != nil {
+		return x.PsqlArgs
+	}
+	return nil
+}
+
+func (x *PrepareJobRequest) GetLiquibaseArgs() []string {
+	if x != nil {
+		return x.LiquibaseArgs
+	}
+	return nil
+}
+
+func (x *PrepareJobRequest) GetLiquibaseExec() string {
+	if x != nil {
+		return x.LiquibaseExec
+	}
+	return ""
+}
+
+func (x *PrepareJobRequest) GetLiquibaseExecMode() string {
+	if x != nil {
+		return x.LiquibaseExecMode
+	}
+	return ""
+}
+
+func (x *PrepareJobRequest) GetLiquibaseEnv() map[string]string {
+	if x != nil {
+		return x.LiquibaseEnv
+	}
+	return nil
+}
+
+func (x *PrepareJobRequest) GetFlywayArgs() []string {
+	if x != nil {
+		return x.FlywayArgs
+	}
+	return nil
+}
+
+func (x *PrepareJobRequest) GetFlywayExec() string {
+	if x != nil {
+		return x.FlywayExec
+	}
+	return ""
+}
+
+func (x *PrepareJobRequest) GetFlywayExecMode() string {
+	if x != nil {
+		return x.FlywayExecMode
+	}
+	return ""
+}
+
+func (x *PrepareJobRequest) GetFlywayEnv() map[string]string {
+	if x != nil {
+		return x.FlywayEnv
+	}
+	return nil
+}
+
+func (x *PrepareJobRequest) GetWorkDir() string {
+	if x != nil {
+		return x.WorkDir
+	}
+	return ""
+}
+
+func (x *PrepareJobRequest) GetStdin() string {
+	if x != nil && x.Stdin != nil {
+		return *x.Stdin
+	}
+	return ""
+}
+
+func (x *PrepareJobRequest) GetPlanOnly() bool {
+	if x != nil {
+		return x.PlanOnly
+	}
+	return false
+}
+
+func (x *PrepareJobRequest) GetIdempotencyKey() string {
+	if x != nil {
+		return x.IdempotencyKey
+	}
+	return ""
+}
+
+func (x *PrepareJobRequest) GetInstanceMode() string {
+	if x != nil {
+		return x.InstanceMode
+	}
+	return ""
+}
+
+func (x *PrepareJobRequest) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+func (x *PrepareJobRequest) GetTaskTimeout() string {
+	if x != nil {
+		return x.TaskTimeout
+	}
+	return ""
+}
+
+func (x *PrepareJobRequest) GetHeartbeatEvery() string {
+	if x != nil {
+		return x.HeartbeatEvery
+	}
+	return ""
+}
+
+func (x *PrepareJobRequest) GetPsqlSplit() bool {
+	if x != nil {
+		return x.PsqlSplit
+	}
+	return false
+}
+
+func (x *PrepareJobRequest) GetPsqlSingleTransaction() bool {
+	if x != nil {
+		return x.PsqlSingleTransaction
+	}
+	return false
+}
+
+func (x *PrepareJobRequest) GetSearchPath() string {
+	if x != nil {
+		return x.SearchPath
+	}
+	return ""
+}
+
+func (x *PrepareJobRequest) GetPsqlPreamble() []string {
+	if x != nil {
+		return x.PsqlPreamble
+	}
+	return nil
+}
+
+func (x *PrepareJobRequest) GetStatementTimeout() string {
+	if x != nil {
+		return x.StatementTimeout
+	}
+	return ""
+}
+
+func (x *PrepareJobRequest) GetMounts() []*MountSpec {
+	if x != nil {
+		return x.Mounts
+	}
+	return nil
+}
+
+func (x *PrepareJobRequest) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+func (x *PrepareJobRequest) GetBaseStateId() string {
+	if x != nil {
+		return x.BaseStateId
+	}
+	return ""
+}
+
+func (x *PrepareJobRequest) GetCoalesce() bool {
+	if x != nil {
+		return x.Coalesce
+	}
+	return false
+}
+
+func (x *PrepareJobRequest) GetNoCache() bool {
+	if x != nil {
+		return x.NoCache
+	}
+	return false
+}
+
+func (x *PrepareJobRequest) GetCpuLimit() string {
+	if x != nil {
+		return x.CpuLimit
+	}
+	return ""
+}
+
+func (x *PrepareJobRequest) GetMemoryLimit() string {
+	if x != nil {
+		return x.MemoryLimit
+	}
+	return ""
+}
+
+func (x *PrepareJobRequest) GetNetwork() string {
+	if x != nil {
+		return x.Network
+	}
+	return ""
+}
+
+func (x *PrepareJobRequest) GetDns() []string {
+	if x != nil {
+		return x.Dns
+	}
+	return nil
+}
+
+func (x *PrepareJobRequest) GetPlatform() string {
+	if x != nil {
+		return x.Platform
+	}
+	return ""
+}
+
+func (x *PrepareJobRequest) GetCancelOnDisconnect() bool {
+	if x != nil {
+		return x.CancelOnDisconnect
+	}
+	return false
+}
+
+func (x *PrepareJobRequest) GetLiquibaseStdin() string {
+	if x != nil && x.LiquibaseStdin != nil {
+		return *x.LiquibaseStdin
+	}
+	return ""
+}
+
+func (x *PrepareJobRequest) GetLiquibaseStdinFormat() string {
+	if x != nil {
+		return x.LiquibaseStdinFormat
+	}
+	return ""
+}
+
+type MountSpec struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Source        string                 `protobuf:"bytes,1,opt,name=source,proto3" json:"source,omitempty"`
+	Target        string                 `protobuf:"bytes,2,opt,name=target,proto3" json:"target,omitempty"`
+	ReadOnly      bool                   `protobuf:"varint,3,opt,name=read_only,json=readOnly,proto3" json:"read_only,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MountSpec) Reset() {
+	*x = MountSpec{}
+	mi := &file_sqlrs_engine_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MountSpec) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MountSpec) ProtoMessage() {}
+
+func (x *MountSpec) ProtoReflect() protoreflect.Message {
+	mi := &file_sqlrs_engine_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MountSpec.ProtoReflect.Descriptor instead.
+func (*MountSpec) Descriptor() ([]byte, []int) {
+	return file_sqlrs_engine_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *MountSpec) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *MountSpec) GetTarget() string {
+	if x != nil {
+		return x.Target
+	}
+	return ""
+}
+
+func (x *MountSpec) GetReadOnly() bool {
+	if x != nil {
+		return x.ReadOnly
+	}
+	return false
+}
+
+type PrepareJobAccepted struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	JobId         string                 `protobuf:"bytes,1,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"`
+	StatusUrl     string                 `protobuf:"bytes,2,opt,name=status_url,json=statusUrl,proto3" json:"status_url,omitempty"`
+	EventsUrl     string                 `protobuf:"bytes,3,opt,name=events_url,json=eventsUrl,proto3" json:"events_url,omitempty"`
+	Status        string                 `protobuf:"bytes,4,opt,name=status,proto3" json:"status,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PrepareJobAccepted) Reset() {
+	*x = PrepareJobAccepted{}
+	mi := &file_sqlrs_engine_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PrepareJobAccepted) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PrepareJobAccepted) ProtoMessage() {}
+
+func (x *PrepareJobAccepted) ProtoReflect() protoreflect.Message {
+	mi := &file_sqlrs_engine_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PrepareJobAccepted.ProtoReflect.Descriptor instead.
+func (*PrepareJobAccepted) Descriptor() ([]byte, []int) {
+	return file_sqlrs_engine_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *PrepareJobAccepted) GetJobId() string {
+	if x != nil {
+		return x.JobId
+	}
+	return ""
+}
+
+func (x *PrepareJobAccepted) GetStatusUrl() string {
+	if x != nil {
+		return x.StatusUrl
+	}
+	return ""
+}
+
+func (x *PrepareJobAccepted) GetEventsUrl() string {
+	if x != nil {
+		return x.EventsUrl
+	}
+	return ""
+}
+
+func (x *PrepareJobAccepted) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+type GetPrepareJobRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	JobId         string                 `protobuf:"bytes,1,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetPrepareJobRequest) Reset() {
+	*x = GetPrepareJobRequest{}
+	mi := &file_sqlrs_engine_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetPrepareJobRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetPrepareJobRequest) ProtoMessage() {}
+
+func (x *GetPrepareJobRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sqlrs_engine_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetPrepareJobRequest.ProtoReflect.Descriptor instead.
+func (*GetPrepareJobRequest) Descriptor() ([]byte, []int) {
+	return file_sqlrs_engine_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *GetPrepareJobRequest) GetJobId() string {
+	if x != nil {
+		return x.JobId
+	}
+	return ""
+}
+
+type PrepareJobStatus struct {
+	state                 protoimpl.MessageState `protogen:"open.v1"`
+	JobId                 string                 `protobuf:"bytes,1,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"`
+	Status                string                 `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`
+	PrepareKind           string                 `protobuf:"bytes,3,opt,name=prepare_kind,json=prepareKind,proto3" json:"prepare_kind,omitempty"`
+	ImageId               string                 `protobuf:"bytes,4,opt,name=image_id,json=imageId,proto3" json:"image_id,omitempty"`
+	PlanOnly              bool                   `protobuf:"varint,5,opt,name=plan_only,json=planOnly,proto3" json:"plan_only,omitempty"`
+	PrepareArgsNormalized string                 `protobuf:"bytes,6,opt,name=prepare_args_normalized,json=prepareArgsNormalized,proto3" json:"prepare_args_normalized,omitempty"`
+	CreatedAt             *string                `protobuf:"bytes,7,opt,name=created_at,json=createdAt,proto3,oneof" json:"created_at,omitempty"`
+	StartedAt             *string                `protobuf:"bytes,8,opt,name=started_at,json=startedAt,proto3,oneof" json:"started_at,omitempty"`
+	FinishedAt            *string                `protobuf:"bytes,9,opt,name=finished_at,json=finishedAt,proto3,oneof" json:"finished_at,omitempty"`
+	Labels                map[string]string      `protobuf:"bytes,10,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Tasks                 []*PlanTask            `protobuf:"bytes,11,rep,name=tasks,proto3" json:"tasks,omitempty"`
+	Result                *PrepareJobResult      `protobuf:"bytes,12,opt,name=result,proto3" json:"result,omitempty"`
+	Error                 *ErrorResponse         `protobuf:"bytes,13,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields         protoimpl.UnknownFields
+	sizeCache             protoimpl.SizeCache
+}
+
+func (x *PrepareJobStatus) Reset() {
+	*x = PrepareJobStatus{}
+	mi := &file_sqlrs_engine_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PrepareJobStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PrepareJobStatus) ProtoMessage() {}
+
+func (x *PrepareJobStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_sqlrs_engine_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PrepareJobStatus.ProtoReflect.Descriptor instead.
+func (*PrepareJobStatus) Descriptor() ([]byte, []int) {
+	return file_sqlrs_engine_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *PrepareJobStatus) GetJobId() string {
+	if x != nil {
+		return x.JobId
+	}
+	return ""
+}
+
+func (x *PrepareJobStatus) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *PrepareJobStatus) GetPrepareKind() string {
+	if x != nil {
+		return x.PrepareKind
+	}
+	return ""
+}
+
+func (x *PrepareJobStatus) GetImageId() string {
+	if x != nil {
+		return x.ImageId
+	}
+	return ""
+}
+
+func (x *PrepareJobStatus) GetPlanOnly() bool {
+	if x != nil {
+		return x.PlanOnly
+	}
+	return false
+}
+
+func (x *PrepareJobStatus) GetPrepareArgsNormalized() string {
+	if x != nil {
+		return x.PrepareArgsNormalized
+	}
+	return ""
+}
+
+func (x *PrepareJobStatus) GetCreatedAt() string {
+	if x != nil && x.CreatedAt != nil {
+		return *x.CreatedAt
+	}
+	return ""
+}
+
+func (x *PrepareJobStatus) GetStartedAt() string {
+	if x != nil && x.StartedAt != nil {
+		return *x.StartedAt
+	}
+	return ""
+}
+
+func (x *PrepareJobStatus) GetFinishedAt() string {
+	if x != nil && x.FinishedAt != nil {
+		return *x.FinishedAt
+	}
+	return ""
+}
+
+func (x *PrepareJobStatus) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+func (x *PrepareJobStatus) GetTasks() []*PlanTask {
+	if x != nil {
+		return x.Tasks
+	}
+	return nil
+}
+
+func (x *PrepareJobStatus) GetResult() *PrepareJobResult {
+	if x != nil {
+		return x.Result
+	}
+	return nil
+}
+
+func (x *PrepareJobStatus) GetError() *ErrorResponse {
+	if x != nil {
+		return x.Error
+	}
+	return nil
+}
+
+type PlanTask struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	TaskId          string                 `protobuf:"bytes,1,opt,name=task_id,json=taskId,proto3" json:"task_id,omitempty"`
+	Type            string                 `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	PlannerKind     string                 `protobuf:"bytes,3,opt,name=planner_kind,json=plannerKind,proto3" json:"planner_kind,omitempty"`
+	Input           *TaskInput             `protobuf:"bytes,4,opt,name=input,proto3" json:"input,omitempty"`
+	ImageId         string                 `protobuf:"bytes,5,opt,name=image_id,json=imageId,proto3" json:"image_id,omitempty"`
+	ResolvedImageId string                 `protobuf:"bytes,6,opt,name=resolved_image_id,json=resolvedImageId,proto3" json:"resolved_image_id,omitempty"`
+	TaskHash        string                 `protobuf:"bytes,7,opt,name=task_hash,json=taskHash,proto3" json:"task_hash,omitempty"`
+	OutputStateId   string                 `protobuf:"bytes,8,opt,name=output_state_id,json=outputStateId,proto3" json:"output_state_id,omitempty"`
+	Cached          *bool                  `protobuf:"varint,9,opt,name=cached,proto3,oneof" json:"cached,omitempty"`
+	InstanceMode    string                 `protobuf:"bytes,10,opt,name=instance_mode,json=instanceMode,proto3" json:"instance_mode,omitempty"`
+	ChangesetId     string                 `protobuf:"bytes,11,opt,name=changeset_id,json=changesetId,proto3" json:"changeset_id,omitempty"`
+	ChangesetAuthor string                 `protobuf:"bytes,12,opt,name=changeset_author,json=changesetAuthor,proto3" json:"changeset_author,omitempty"`
+	ChangesetPath   string                 `protobuf:"bytes,13,opt,name=changeset_path,json=changesetPath,proto3" json:"changeset_path,omitempty"`
+	StartedAt       *string                `protobuf:"bytes,14,opt,name=started_at,json=startedAt,proto3,oneof" json:"started_at,omitempty"`
+	FinishedAt      *string                `protobuf:"bytes,15,opt,name=finished_at,json=finishedAt,proto3,oneof" json:"finished_at,omitempty"`
+	DurationMs      *int64                 `protobuf:"varint,16,opt,name=duration_ms,json=durationMs,proto3,oneof" json:"duration_ms,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *PlanTask) Reset() {
+	*x = PlanTask{}
+	mi := &file_sqlrs_engine_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PlanTask) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PlanTask) ProtoMessage() {}
+
+func (x *PlanTask) ProtoReflect() protoreflect.Message {
+	mi := &file_sqlrs_engine_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PlanTask.ProtoReflect.Descriptor instead.
+func (*PlanTask) Descriptor() ([]byte, []int) {
+	return file_sqlrs_engine_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *PlanTask) GetTaskId() string {
+	if x != nil {
+		return x.TaskId
+	}
+	return ""
+}
+
+func (x *PlanTask) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *PlanTask) GetPlannerKind() string {
+	if x != nil {
+		return x.PlannerKind
+	}
+	return ""
+}
+
+func (x *PlanTask) GetInput() *TaskInput {
+	if x != nil {
+		return x.Input
+	}
+	return nil
+}
+
+func (x *PlanTask) GetImageId() string {
+	if x != nil {
+		return x.ImageId
+	}
+	return ""
+}
+
+func (x *PlanTask) GetResolvedImageId() string {
+	if x != nil {
+		return x.ResolvedImageId
+	}
+	return ""
+}
+
+func (x *PlanTask) GetTaskHash() string {
+	if x != nil {
+		return x.TaskHash
+	}
+	return ""
+}
+
+func (x *PlanTask) GetOutputStateId() string {
+	if x != nil {
+		return x.OutputStateId
+	}
+	return ""
+}
+
+func (x *PlanTask) GetCached() bool {
+	if x != nil && x.Cached != nil {
+		return *x.Cached
+	}
+	return false
+}
+
+func (x *PlanTask) GetInstanceMode() string {
+	if x != nil {
+		return x.InstanceMode
+	}
+	return ""
+}
+
+func (x *PlanTask) GetChangesetId() string {
+	if x != nil {
+		return x.ChangesetId
+	}
+	return ""
+}
+
+func (x *PlanTask) GetChangesetAuthor() string {
+	if x != nil {
+		return x.ChangesetAuthor
+	}
+	return ""
+}
+
+func (x *PlanTask) GetChangesetPath() string {
+	if x != nil {
+		return x.ChangesetPath
+	}
+	return ""
+}
+
+func (x *PlanTask) GetStartedAt() string {
+	if x != nil && x.StartedAt != nil {
+		return *x.StartedAt
+	}
+	return ""
+}
+
+func (x *PlanTask) GetFinishedAt() string {
+	if x != nil && x.FinishedAt != nil {
+		return *x.FinishedAt
+	}
+	return ""
+}
+
+func (x *PlanTask) GetDurationMs() int64 {
+	if x != nil && x.DurationMs != nil {
+		return *x.DurationMs
+	}
+	return 0
+}
+
+type TaskInput struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Kind          string                 `protobuf:"bytes,1,opt,name=kind,proto3" json:"kind,omitempty"`
+	Id            string                 `protobuf:"bytes,2,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TaskInput) Reset() {
+	*x = TaskInput{}
+	mi := &file_sqlrs_engine_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TaskInput) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TaskInput) ProtoMessage() {}
+
+func (x *TaskInput) ProtoReflect() protoreflect.Message {
+	mi := &file_sqlrs_engine_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TaskInput.ProtoReflect.Descriptor instead.
+func (*TaskInput) Descriptor() ([]byte, []int) {
+	return file_sqlrs_engine_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *TaskInput) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
+}
+
+func (x *TaskInput) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type PrepareJobResult struct {
+	state                 protoimpl.MessageState `protogen:"open.v1"`
+	Dsn                   string                 `protobuf:"bytes,1,opt,name=dsn,proto3" json:"dsn,omitempty"`
+	InstanceId            string                 `protobuf:"bytes,2,opt,name=instance_id,json=instanceId,proto3" json:"instance_id,omitempty"`
+	StateId               string                 `protobuf:"bytes,3,opt,name=state_id,json=stateId,proto3" json:"state_id,omitempty"`
+	ImageId               string                 `protobuf:"bytes,4,opt,name=image_id,json=imageId,proto3" json:"image_id,omitempty"`
+	PrepareKind           string                 `protobuf:"bytes,5,opt,name=prepare_kind,json=prepareKind,proto3" json:"prepare_kind,omitempty"`
+	PrepareArgsNormalized string                 `protobuf:"bytes,6,opt,name=prepare_args_normalized,json=prepareArgsNormalized,proto3" json:"prepare_args_normalized,omitempty"`
+	unknownFields         protoimpl.UnknownFields
+	sizeCache             protoimpl.SizeCache
+}
+
+func (x *PrepareJobResult) Reset() {
+	*x = PrepareJobResult{}
+	mi := &file_sqlrs_engine_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PrepareJobResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PrepareJobResult) ProtoMessage() {}
+
+func (x *PrepareJobResult) ProtoReflect() protoreflect.Message {
+	mi := &file_sqlrs_engine_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PrepareJobResult.ProtoReflect.Descriptor instead.
+func (*PrepareJobResult) Descriptor() ([]byte, []int) {
+	return file_sqlrs_engine_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *PrepareJobResult) GetDsn() string {
+	if x != nil {
+		return x.Dsn
+	}
+	return ""
+}
+
+func (x *PrepareJobResult) GetInstanceId() string {
+	if x != nil {
+		return x.InstanceId
+	}
+	return ""
+}
+
+func (x *PrepareJobResult) GetStateId() string {
+	if x != nil {
+		return x.StateId
+	}
+	return ""
+}
+
+func (x *PrepareJobResult) GetImageId() string {
+	if x != nil {
+		return x.ImageId
+	}
+	return ""
+}
+
+func (x *PrepareJobResult) GetPrepareKind() string {
+	if x != nil {
+		return x.PrepareKind
+	}
+	return ""
+}
+
+func (x *PrepareJobResult) GetPrepareArgsNormalized() string {
+	if x != nil {
+		return x.PrepareArgsNormalized
+	}
+	return ""
+}
+
+type ErrorResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Code          string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`
+	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	Details       string                 `protobuf:"bytes,3,opt,name=details,proto3" json:"details,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ErrorResponse) Reset() {
+	*x = ErrorResponse{}
+	mi := &file_sqlrs_engine_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ErrorResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ErrorResponse) ProtoMessage() {}
+
+func (x *ErrorResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_sqlrs_engine_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ErrorResponse.ProtoReflect.Descriptor instead.
+func (*ErrorResponse) Descriptor() ([]byte, []int) {
+	return file_sqlrs_engine_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *ErrorResponse) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *ErrorResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *ErrorResponse) GetDetails() string {
+	if x != nil {
+		return x.Details
+	}
+	return ""
+}
+
+type ListPrepareJobsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// job keeps jobs whose id starts with it.
+	Job       string `protobuf:"bytes,1,opt,name=job,proto3" json:"job,omitempty"`
+	Namespace string `protobuf:"bytes,2,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	// labels keeps jobs carrying every given label.
+	Labels        map[string]string `protobuf:"bytes,3,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListPrepareJobsRequest) Reset() {
+	*x = ListPrepareJobsRequest{}
+	mi := &file_sqlrs_engine_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListPrepareJobsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListPrepareJobsRequest) ProtoMessage() {}
+
+func (x *ListPrepareJobsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sqlrs_engine_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListPrepareJobsRequest.ProtoReflect.Descriptor instead.
+func (*ListPrepareJobsRequest) Descriptor() ([]byte, []int) {
+	return file_sqlrs_engine_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ListPrepareJobsRequest) GetJob() string {
+	if x != nil {
+		return x.Job
+	}
+	return ""
+}
+
+func (x *ListPrepareJobsRequest) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+func (x *ListPrepareJobsRequest) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+type ListPrepareJobsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Jobs          []*PrepareJobEntry     `protobuf:"bytes,1,rep,name=jobs,proto3" json:"jobs,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListPrepareJobsResponse) Reset() {
+	*x = ListPrepareJobsResponse{}
+	mi := &file_sqlrs_engine_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListPrepareJobsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListPrepareJobsResponse) ProtoMessage() {}
+
+func (x *ListPrepareJobsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_sqlrs_engine_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListPrepareJobsResponse.ProtoReflect.Descriptor instead.
+func (*ListPrepareJobsResponse) Descriptor() ([]byte, []int) {
+	return file_sqlrs_engine_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *ListPrepareJobsResponse) GetJobs() []*PrepareJobEntry {
+	if x != nil {
+		return x.Jobs
+	}
+	return nil
+}
+
+type PrepareJobEntry struct {
+	state                 protoimpl.MessageState `protogen:"open.v1"`
+	JobId                 string                 `protobuf:"bytes,1,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"`
+	Status                string                 `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`
+	PrepareKind           string                 `protobuf:"bytes,3,opt,name=prepare_kind,json=prepareKind,proto3" json:"prepare_kind,omitempty"`
+	ImageId               string                 `protobuf:"bytes,4,opt,name=image_id,json=imageId,proto3" json:"image_id,omitempty"`
+	ResolvedImageId       string                 `protobuf:"bytes,5,opt,name=resolved_image_id,json=resolvedImageId,proto3" json:"resolved_image_id,omitempty"`
+	Namespace             string                 `protobuf:"bytes,6,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	PrepareArgsNormalized string                 `protobuf:"bytes,7,opt,name=prepare_args_normalized,json=prepareArgsNormalized,proto3" json:"prepare_args_normalized,omitempty"`
+	Signature             string                 `protobuf:"bytes,8,opt,name=signature,proto3" json:"signature,omitempty"`
+	PlanOnly              bool                   `protobuf:"varint,9,opt,name=plan_only,json=planOnly,proto3" json:"plan_only,omitempty"`
+	CreatedAt             *string                `protobuf:"bytes,10,opt,name=created_at,json=createdAt,proto3,oneof" json:"created_at,omitempty"`
+	StartedAt             *string                `protobuf:"bytes,11,opt,name=started_at,json=startedAt,proto3,oneof" json:"started_at,omitempty"`
+	FinishedAt            *string                `protobuf:"bytes,12,opt,name=finished_at,json=finishedAt,proto3,oneof" json:"finished_at,omitempty"`
+	Labels                map[string]string      `protobuf:"bytes,13,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields         protoimpl.UnknownFields
+	sizeCache             protoimpl.SizeCache
+}
+
+func (x *PrepareJobEntry) Reset() {
+	*x = PrepareJobEntry{}
+	mi := &file_sqlrs_engine_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PrepareJobEntry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PrepareJobEntry) ProtoMessage() {}
+
+func (x *PrepareJobEntry) ProtoReflect() protoreflect.Message {
+	mi := &file_sqlrs_engine_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PrepareJobEntry.ProtoReflect.Descriptor instead.
+func (*PrepareJobEntry) Descriptor() ([]byte, []int) {
+	return file_sqlrs_engine_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *PrepareJobEntry) GetJobId() string {
+	if x != nil {
+		return x.JobId
+	}
+	return ""
+}
+
+func (x *PrepareJobEntry) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *PrepareJobEntry) GetPrepareKind() string {
+	if x != nil {
+		return x.PrepareKind
+	}
+	return ""
+}
+
+func (x *PrepareJobEntry) GetImageId() string {
+	if x != nil {
+		return x.ImageId
+	}
+	return ""
+}
+
+func (x *PrepareJobEntry) GetResolvedImageId() string {
+	if x != nil {
+		return x.ResolvedImageId
+	}
+	return ""
+}
+
+func (x *PrepareJobEntry) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+func (x *PrepareJobEntry) GetPrepareArgsNormalized() string {
+	if x != nil {
+		return x.PrepareArgsNormalized
+	}
+	return ""
+}
+
+func (x *PrepareJobEntry) GetSignature() string {
+	if x != nil {
+		return x.Signature
+	}
+	return ""
+}
+
+func (x *PrepareJobEntry) GetPlanOnly() bool {
+	if x != nil {
+		return x.PlanOnly
+	}
+	return false
+}
+
+func (x *PrepareJobEntry) GetCreatedAt() string {
+	if x != nil && x.CreatedAt != nil {
+		return *x.CreatedAt
+	}
+	return ""
+}
+
+func (x *PrepareJobEntry) GetStartedAt() string {
+	if x != nil && x.StartedAt != nil {
+		return *x.StartedAt
+	}
+	return ""
+}
+
+func (x *PrepareJobEntry) GetFinishedAt() string {
+	if x != nil && x.FinishedAt != nil {
+		return *x.FinishedAt
+	}
+	return ""
+}
+
+func (x *PrepareJobEntry) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+type DeletePrepareJobRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	JobId         string                 `protobuf:"bytes,1,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"`
+	Force         bool                   `protobuf:"varint,2,opt,name=force,proto3" json:"force,omitempty"`
+	DryRun        bool                   `protobuf:"varint,3,opt,name=dry_run,json=dryRun,proto3" json:"dry_run,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeletePrepareJobRequest) Reset() {
+	*x = DeletePrepareJobRequest{}
+	mi := &file_sqlrs_engine_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeletePrepareJobRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeletePrepareJobRequest) ProtoMessage() {}
+
+func (x *DeletePrepareJobRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sqlrs_engine_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeletePrepareJobRequest.ProtoReflect.Descriptor instead.
+func (*DeletePrepareJobRequest) Descriptor() ([]byte, []int) {
+	return file_sqlrs_engine_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *DeletePrepareJobRequest) GetJobId() string {
+	if x != nil {
+		return x.JobId
+	}
+	return ""
+}
+
+func (x *DeletePrepareJobRequest) GetForce() bool {
+	if x != nil {
+		return x.Force
+	}
+	return false
+}
+
+func (x *DeletePrepareJobRequest) GetDryRun() bool {
+	if x != nil {
+		return x.DryRun
+	}
+	return false
+}
+
+type DeleteResult struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	DryRun        bool                   `protobuf:"varint,1,opt,name=dry_run,json=dryRun,proto3" json:"dry_run,omitempty"`
+	Outcome       string                 `protobuf:"bytes,2,opt,name=outcome,proto3" json:"outcome,omitempty"`
+	Root          *DeleteNode            `protobuf:"bytes,3,opt,name=root,proto3" json:"root,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteResult) Reset() {
+	*x = DeleteResult{}
+	mi := &file_sqlrs_engine_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteResult) ProtoMessage() {}
+
+func (x *DeleteResult) ProtoReflect() protoreflect.Message {
+	mi := &file_sqlrs_engine_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteResult.ProtoReflect.Descriptor instead.
+func (*DeleteResult) Descriptor() ([]byte, []int) {
+	return file_sqlrs_engine_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *DeleteResult) GetDryRun() bool {
+	if x != nil {
+		return x.DryRun
+	}
+	return false
+}
+
+func (x *DeleteResult) GetOutcome() string {
+	if x != nil {
+		return x.Outcome
+	}
+	return ""
+}
+
+func (x *DeleteResult) GetRoot() *DeleteNode {
+	if x != nil {
+		return x.Root
+	}
+	return nil
+}
+
+type DeleteNode struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Kind          string                 `protobuf:"bytes,1,opt,name=kind,proto3" json:"kind,omitempty"`
+	Id            string                 `protobuf:"bytes,2,opt,name=id,proto3" json:"id,omitempty"`
+	Connections   *int32                 `protobuf:"varint,3,opt,name=connections,proto3,oneof" json:"connections,omitempty"`
+	Blocked       string                 `protobuf:"bytes,4,opt,name=blocked,proto3" json:"blocked,omitempty"`
+	RuntimeId     *string                `protobuf:"bytes,5,opt,name=runtime_id,json=runtimeId,proto3,oneof" json:"runtime_id,omitempty"`
+	Children      []*DeleteNode          `protobuf:"bytes,6,rep,name=children,proto3" json:"children,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteNode) Reset() {
+	*x = DeleteNode{}
+	mi := &file_sqlrs_engine_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteNode) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteNode) ProtoMessage() {}
+
+func (x *DeleteNode) ProtoReflect() protoreflect.Message {
+	mi := &file_sqlrs_engine_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteNode.ProtoReflect.Descriptor instead.
+func (*DeleteNode) Descriptor() ([]byte, []int) {
+	return file_sqlrs_engine_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *DeleteNode) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
+}
+
+func (x *DeleteNode) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *DeleteNode) GetConnections() int32 {
+	if x != nil && x.Connections != nil {
+		return *x.Connections
+	}
+	return 0
+}
+
+func (x *DeleteNode) GetBlocked() string {
+	if x != nil {
+		return x.Blocked
+	}
+	return ""
+}
+
+func (x *DeleteNode) GetRuntimeId() string {
+	if x != nil && x.RuntimeId != nil {
+		return *x.RuntimeId
+	}
+	return ""
+}
+
+func (x *DeleteNode) GetChildren() []*DeleteNode {
+	if x != nil {
+		return x.Children
+	}
+	return nil
+}
+
+type PrepareJobEventsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	JobId string                 `protobuf:"bytes,1,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"`
+	// after_seq starts the stream right after the event with this seq; zero
+	// streams from the first event.
+	AfterSeq      int64 `protobuf:"varint,2,opt,name=after_seq,json=afterSeq,proto3" json:"after_seq,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PrepareJobEventsRequest) Reset() {
+	*x = PrepareJobEventsRequest{}
+	mi := &file_sqlrs_engine_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PrepareJobEventsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PrepareJobEventsRequest) ProtoMessage() {}
+
+func (x *PrepareJobEventsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sqlrs_engine_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PrepareJobEventsRequest.ProtoReflect.Descriptor instead.
+func (*PrepareJobEventsRequest) Descriptor() ([]byte, []int) {
+	return file_sqlrs_engine_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *PrepareJobEventsRequest) GetJobId() string {
+	if x != nil {
+		return x.JobId
+	}
+	return ""
+}
+
+func (x *PrepareJobEventsRequest) GetAfterSeq() int64 {
+	if x != nil {
+		return x.AfterSeq
+	}
+	return 0
+}
+
+type PrepareJobEvent struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Seq      int64                  `protobuf:"varint,1,opt,name=seq,proto3" json:"seq,omitempty"`
+	Type     string                 `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	Ts       string                 `protobuf:"bytes,3,opt,name=ts,proto3" json:"ts,omitempty"`
+	Status   string                 `protobuf:"bytes,4,opt,name=status,proto3" json:"status,omitempty"`
+	TaskId   string                 `protobuf:"bytes,5,opt,name=task_id,json=taskId,proto3" json:"task_id,omitempty"`
+	Message  string                 `protobuf:"bytes,6,opt,name=message,proto3" json:"message,omitempty"`
+	Result   *PrepareJobResult      `protobuf:"bytes,7,opt,name=result,proto3" json:"result,omitempty"`
+	Error    *ErrorResponse         `protobuf:"bytes,8,opt,name=error,proto3" json:"error,omitempty"`
+	Progress *TaskProgress          `protobuf:"bytes,9,opt,name=progress,proto3" json:"progress,omitempty"`
+	// plan is set on "plan" events: the job's tasks once planning is done.
+	Plan          []*PlanTask `protobuf:"bytes,10,rep,name=plan,proto3" json:"plan,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PrepareJobEvent) Reset() {
+	*x = PrepareJobEvent{}
+	mi := &file_sqlrs_engine_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PrepareJobEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PrepareJobEvent) ProtoMessage() {}
+
+func (x *PrepareJobEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_sqlrs_engine_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PrepareJobEvent.ProtoReflect.Descriptor instead.
+func (*PrepareJobEvent) Descriptor() ([]byte, []int) {
+	return file_sqlrs_engine_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *PrepareJobEvent) GetSeq() int64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *PrepareJobEvent) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *PrepareJobEvent) GetTs() string {
+	if x != nil {
+		return x.Ts
+	}
+	return ""
+}
+
+func (x *PrepareJobEvent) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *PrepareJobEvent) GetTaskId() string {
+	if x != nil {
+		return x.TaskId
+	}
+	return ""
+}
+
+func (x *PrepareJobEvent) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *PrepareJobEvent) GetResult() *PrepareJobResult {
+	if x != nil {
+		return x.Result
+	}
+	return nil
+}
+
+func (x *PrepareJobEvent) GetError() *ErrorResponse {
+	if x != nil {
+		return x.Error
+	}
+	return nil
+}
+
+func (x *PrepareJobEvent) GetProgress() *TaskProgress {
+	if x != nil {
+		return x.Progress
+	}
+	return nil
+}
+
+func (x *PrepareJobEvent) GetPlan() []*PlanTask {
+	if x != nil {
+		return x.Plan
+	}
+	return nil
+}
+
+type TaskProgress struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Completed     int32                  `protobuf:"varint,1,opt,name=completed,proto3" json:"completed,omitempty"`
+	Total         int32                  `protobuf:"varint,2,opt,name=total,proto3" json:"total,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TaskProgress) Reset() {
+	*x = TaskProgress{}
+	mi := &file_sqlrs_engine_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TaskProgress) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TaskProgress) ProtoMessage() {}
+
+func (x *TaskProgress) ProtoReflect() protoreflect.Message {
+	mi := &file_sqlrs_engine_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TaskProgress.ProtoReflect.Descriptor instead.
+func (*TaskProgress) Descriptor() ([]byte, []int) {
+	return file_sqlrs_engine_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *TaskProgress) GetCompleted() int32 {
+	if x != nil {
+		return x.Completed
+	}
+	return 0
+}
+
+func (x *TaskProgress) GetTotal() int32 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+var File_sqlrs_engine_proto protoreflect.FileDescriptor
+
+const file_sqlrs_engine_proto_rawDesc = "" +
+	"\n" +
+	"\x12sqlrs-engine.proto\x12\x0fsqlrs.engine.v1\"\xa7\r\n" +
+	"\x11PrepareJobRequest\x12!\n" +
+	"\fprepare_kind\x18\x01 \x01(\tR\vprepareKind\x12\x19\n" +
+	"\bimage_id\x18\x02 \x01(\tR\aimageId\x12\x1b\n" +
+	"\tpsql_args\x18\x03 \x03(\tR\bpsqlArgs\x12%\n" +
+	"\x0eliquibase_args\x18\x04 \x03(\tR\rliquibaseArgs\x12%\n" +
+	"\x0eliquibase_exec\x18\x05 \x01(\tR\rliquibaseExec\x12.\n" +
+	"\x13liquibase_exec_mode\x18\x06 \x01(\tR\x11liquibaseExecMode\x12Y\n" +
+	"\rliquibase_env\x18\a \x03(\v24.sqlrs.engine.v1.PrepareJobRequest.LiquibaseEnvEntryR\fliquibaseEnv\x12\x1f\n" +
+	"\vflyway_args\x18\b \x03(\tR\n" +
+	"flywayArgs\x12\x1f\n" +
+	"\vflyway_exec\x18\t \x01(\tR\n" +
+	"flywayExec\x12(\n" +
+	"\x10flyway_exec_mode\x18\n" +
+	" \x01(\tR\x0eflywayExecMode\x12P\n" +
+	"\n" +
+	"flyway_env\x18\v \x03(\v21.sqlrs.engine.v1.PrepareJobRequest.FlywayEnvEntryR\tflywayEnv\x12\x19\n" +
+	"\bwork_dir\x18\f \x01(\tR\aworkDir\x12\x19\n" +
+	"\x05stdin\x18\r \x01(\tH\x00R\x05stdin\x88\x01\x01\x12\x1b\n" +
+	"\tplan_only\x18\x0e \x01(\bR\bplanOnly\x12'\n" +
+	"\x0fidempotency_key\x18\x0f \x01(\tR\x0eidempotencyKey\x12#\n" +
+	"\rinstance_mode\x18\x10 \x01(\tR\finstanceMode\x12\x1c\n" +
+	"\tnamespace\x18\x11 \x01(\tR\tnamespace\x12!\n" +
+	"\ftask_timeout\x18\x12 \x01(\tR\vtaskTimeout\x12'\n" +
+	"\x0fheartbeat_every\x18\x13 \x01(\tR\x0eheartbeatEvery\x12\x1d\n" +
+	"\n" +
+	"psql_split\x18\x14 \x01(\bR\tpsqlSplit\x126\n" +
+	"\x17psql_single_transaction\x18\x15 \x01(\bR\x15psqlSingleTransaction\x12\x1f\n" +
+	"\vsearch_path\x18\x16 \x01(\tR\n" +
+	"searchPath\x12#\n" +
+	"\rpsql_preamble\x18\x17 \x03(\tR\fpsqlPreamble\x12+\n" +
+	"\x11statement_timeout\x18\x18 \x01(\tR\x10statementTimeout\x122\n" +
+	"\x06mounts\x18\x19 \x03(\v2\x1a.sqlrs.engine.v1.MountSpecR\x06mounts\x12F\n" +
+	"\x06labels\x18\x1a \x03(\v2..sqlrs.engine.v1.PrepareJobRequest.LabelsEntryR\x06labels\x12\"\n" +
+	"\rbase_state_id\x18\x1b \x01(\tR\vbaseStateId\x12\x1a\n" +
+	"\bcoalesce\x18\x1c \x01(\bR\bcoalesce\x12\x19\n" +
+	"\bno_cache\x18\x1d \x01(\bR\anoCache\x12\x1b\n" +
+	"\tcpu_limit\x18\x1e \x01(\tR\bcpuLimit\x12!\n" +
+	"\fmemory_limit\x18\x1f \x01(\tR\vmemoryLimit\x12\x18\n" +
+	"\anetwork\x18  \x01(\tR\anetwork\x12\x10\n" +
+	"\x03dns\x18! \x03(\tR\x03dns\x12\x1a\n" +
+	"\bplatform\x18\" \x01(\tR\bplatform\x120\n" +
+	"\x14cancel_on_disconnect\x18# \x01(\bR\x12cancelOnDisconnect\x12,\n" +
+	"\x0fliquibase_stdin\x18$ \x01(\tH\x01R\x0eliquibaseStdin\x88\x01\x01\x124\n" +
+	"\x16liquibase_stdin_format\x18% \x01(\tR\x14liquibaseStdinFormat\x1a?\n" +
+	"\x11LiquibaseEnvEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\x1a<\n" +
+	"\x0eFlywayEnvEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01B\b\n" +
+	"\x06_stdinB\x12\n" +
+	"\x10_liquibase_stdin\"X\n" +
+	"\tMountSpec\x12\x16\n" +
+	"\x06source\x18\x01 \x01(\tR\x06source\x12\x16\n" +
+	"\x06target\x18\x02 \x01(\tR\x06target\x12\x1b\n" +
+	"\tread_only\x18\x03 \x01(\bR\breadOnly\"\x81\x01\n" +
+	"\x12PrepareJobAccepted\x12\x15\n" +
+	"\x06job_id\x18\x01 \x01(\tR\x05jobId\x12\x1d\n" +
+	"\n" +
+	"status_url\x18\x02 \x01(\tR\tstatusUrl\x12\x1d\n" +
+	"\n" +
+	"events_url\x18\x03 \x01(\tR\teventsUrl\x12\x16\n" +
+	"\x06status\x18\x04 \x01(\tR\x06status\"-\n" +
+	"\x14GetPrepareJobRequest\x12\x15\n" +
+	"\x06job_id\x18\x01 \x01(\tR\x05jobId\"\x94\x05\n" +
+	"\x10PrepareJobStatus\x12\x15\n" +
+	"\x06job_id\x18\x01 \x01(\tR\x05jobId\x12\x16\n" +
+	"\x06status\x18\x02 \x01(\tR\x06status\x12!\n" +
+	"\fprepare_kind\x18\x03 \x01(\tR\vprepareKind\x12\x19\n" +
+	"\bimage_id\x18\x04 \x01(\tR\aimageId\x12\x1b\n" +
+	"\tplan_only\x18\x05 \x01(\bR\bplanOnly\x126\n" +
+	"\x17prepare_args_normalized\x18\x06 \x01(\tR\x15prepareArgsNormalized\x12\"\n" +
+	"\n" +
+	"created_at\x18\a \x01(\tH\x00R\tcreatedAt\x88\x01\x01\x12\"\n" +
+	"\n" +
+	"started_at\x18\b \x01(\tH\x01R\tstartedAt\x88\x01\x01\x12$\n" +
+	"\vfinished_at\x18\t \x01(\tH\x02R\n" +
+	"finishedAt\x88\x01\x01\x12E\n" +
+	"\x06labels\x18\n" +
+	" \x03(\v2-.sqlrs.engine.v1.PrepareJobStatus.LabelsEntryR\x06labels\x12/\n" +
+	"\x05tasks\x18\v \x03(\v2\x19.sqlrs.engine.v1.PlanTaskR\x05tasks\x129\n" +
+	"\x06result\x18\f \x01(\v2!.sqlrs.engine.v1.PrepareJobResultR\x06result\x124\n" +
+	"\x05error\x18\r \x01(\v2\x1e.sqlrs.engine.v1.ErrorResponseR\x05error\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01B\r\n" +
+	"\v_created_atB\r\n" +
+	"\v_started_atB\x0e\n" +
+	"\f_finished_at\"\xf9\x04\n" +
+	"\bPlanTask\x12\x17\n" +
+	"\atask_id\x18\x01 \x01(\tR\x06taskId\x12\x12\n" +
+	"\x04type\x18\x02 \x01(\tR\x04type\x12!\n" +
+	"\fplanner_kind\x18\x03 \x01(\tR\vplannerKind\x120\n" +
+	"\x05input\x18\x04 \x01(\v2\x1a.sqlrs.engine.v1.TaskInputR\x05input\x12\x19\n" +
+	"\bimage_id\x18\x05 \x01(\tR\aimageId\x12*\n" +
+	"\x11resolved_image_id\x18\x06 \x01(\tR\x0fresolvedImageId\x12\x1b\n" +
+	"\ttask_hash\x18\a \x01(\tR\btaskHash\x12&\n" +
+	"\x0foutput_state_id\x18\b \x01(\tR\routputStateId\x12\x1b\n" +
+	"\x06cached\x18\t \x01(\bH\x00R\x06cached\x88\x01\x01\x12#\n" +
+	"\rinstance_mode\x18\n" +
+	" \x01(\tR\finstanceMode\x12!\n" +
+	"\fchangeset_id\x18\v \x01(\tR\vchangesetId\x12)\n" +
+	"\x10changeset_author\x18\f \x01(\tR\x0fchangesetAuthor\x12%\n" +
+	"\x0echangeset_path\x18\r \x01(\tR\rchangesetPath\x12\"\n" +
+	"\n" +
+	"started_at\x18\x0e \x01(\tH\x01R\tstartedAt\x88\x01\x01\x12$\n" +
+	"\vfinished_at\x18\x0f \x01(\tH\x02R\n" +
+	"finishedAt\x88\x01\x01\x12$\n" +
+	"\vduration_ms\x18\x10 \x01(\x03H\x03R\n" +
+	"durationMs\x88\x01\x01B\t\n" +
+	"\a_cachedB\r\n" +
+	"\v_started_atB\x0e\n" +
+	"\f_finished_atB\x0e\n" +
+	"\f_duration_ms\"/\n" +
+	"\tTaskInput\x12\x12\n" +
+	"\x04kind\x18\x01 \x01(\tR\x04kind\x12\x0e\n" +
+	"\x02id\x18\x02 \x01(\tR\x02id\"\xd6\x01\n" +
+	"\x10PrepareJobResult\x12\x10\n" +
+	"\x03dsn\x18\x01 \x01(\tR\x03dsn\x12\x1f\n" +
+	"\vinstance_id\x18\x02 \x01(\tR\n" +
+	"instanceId\x12\x19\n" +
+	"\bstate_id\x18\x03 \x01(\tR\astateId\x12\x19\n" +
+	"\bimage_id\x18\x04 \x01(\tR\aimageId\x12!\n" +
+	"\fprepare_kind\x18\x05 \x01(\tR\vprepareKind\x126\n" +
+	"\x17prepare_args_normalized\x18\x06 \x01(\tR\x15prepareArgsNormalized\"W\n" +
+	"\rErrorResponse\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x12\x18\n" +
+	"\adetails\x18\x03 \x01(\tR\adetails\"\xd0\x01\n" +
+	"\x16ListPrepareJobsRequest\x12\x10\n" +
+	"\x03job\x18\x01 \x01(\tR\x03job\x12\x1c\n" +
+	"\tnamespace\x18\x02 \x01(\tR\tnamespace\x12K\n" +
+	"\x06labels\x18\x03 \x03(\v23.sqlrs.engine.v1.ListPrepareJobsRequest.LabelsEntryR\x06labels\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"O\n" +
+	"\x17ListPrepareJobsResponse\x124\n" +
+	"\x04jobs\x18\x01 \x03(\v2 .sqlrs.engine.v1.PrepareJobEntryR\x04jobs\"\xd8\x04\n" +
+	"\x0fPrepareJobEntry\x12\x15\n" +
+	"\x06job_id\x18\x01 \x01(\tR\x05jobId\x12\x16\n" +
+	"\x06status\x18\x02 \x01(\tR\x06status\x12!\n" +
+	"\fprepare_kind\x18\x03 \x01(\tR\vprepareKind\x12\x19\n" +
+	"\bimage_id\x18\x04 \x01(\tR\aimageId\x12*\n" +
+	"\x11resolved_image_id\x18\x05 \x01(\tR\x0fresolvedImageId\x12\x1c\n" +
+	"\tnamespace\x18\x06 \x01(\tR\tnamespace\x126\n" +
+	"\x17prepare_args_normalized\x18\a \x01(\tR\x15prepareArgsNormalized\x12\x1c\n" +
+	"\tsignature\x18\b \x01(\tR\tsignature\x12\x1b\n" +
+	"\tplan_only\x18\t \x01(\bR\bplanOnly\x12\"\n" +
+	"\n" +
+	"created_at\x18\n" +
+	" \x01(\tH\x00R\tcreatedAt\x88\x01\x01\x12\"\n" +
+	"\n" +
+	"started_at\x18\v \x01(\tH\x01R\tstartedAt\x88\x01\x01\x12$\n" +
+	"\vfinished_at\x18\f \x01(\tH\x02R\n" +
+	"finishedAt\x88\x01\x01\x12D\n" +
+	"\x06labels\x18\r \x03(\v2,.sqlrs.engine.v1.PrepareJobEntry.LabelsEntryR\x06labels\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01B\r\n" +
+	"\v_created_atB\r\n" +
+	"\v_started_atB\x0e\n" +
+	"\f_finished_at\"_\n" +
+	"\x17DeletePrepareJobRequest\x12\x15\n" +
+	"\x06job_id\x18\x01 \x01(\tR\x05jobId\x12\x14\n" +
+	"\x05force\x18\x02 \x01(\bR\x05force\x12\x17\n" +
+	"\adry_run\x18\x03 \x01(\bR\x06dryRun\"r\n" +
+	"\fDeleteResult\x12\x17\n" +
+	"\adry_run\x18\x01 \x01(\bR\x06dryRun\x12\x18\n" +
+	"\aoutcome\x18\x02 \x01(\tR\aoutcome\x12/\n" +
+	"\x04root\x18\x03 \x01(\v2\x1b.sqlrs.engine.v1.DeleteNodeR\x04root\"\xed\x01\n" +
+	"\n" +
+	"DeleteNode\x12\x12\n" +
+	"\x04kind\x18\x01 \x01(\tR\x04kind\x12\x0e\n" +
+	"\x02id\x18\x02 \x01(\tR\x02id\x12%\n" +
+	"\vconnections\x18\x03 \x01(\x05H\x00R\vconnections\x88\x01\x01\x12\x18\n" +
+	"\ablocked\x18\x04 \x01(\tR\ablocked\x12\"\n" +
+	"\n" +
+	"runtime_id\x18\x05 \x01(\tH\x01R\truntimeId\x88\x01\x01\x127\n" +
+	"\bchildren\x18\x06 \x03(\v2\x1b.sqlrs.engine.v1.DeleteNodeR\bchildrenB\x0e\n" +
+	"\f_connectionsB\r\n" +
+	"\v_runtime_id\"M\n" +
+	"\x17PrepareJobEventsRequest\x12\x15\n" +
+	"\x06job_id\x18\x01 \x01(\tR\x05jobId\x12\x1b\n" +
+	"\tafter_seq\x18\x02 \x01(\x03R\bafterSeq\"\xed\x02\n" +
+	"\x0fPrepareJobEvent\x12\x10\n" +
+	"\x03seq\x18\x01 \x01(\x03R\x03seq\x12\x12\n" +
+	"\x04type\x18\x02 \x01(\tR\x04type\x12\x0e\n" +
+	"\x02ts\x18\x03 \x01(\tR\x02ts\x12\x16\n" +
+	"\x06status\x18\x04 \x01(\tR\x06status\x12\x17\n" +
+	"\atask_id\x18\x05 \x01(\tR\x06taskId\x12\x18\n" +
+	"\amessage\x18\x06 \x01(\tR\amessage\x129\n" +
+	"\x06result\x18\a \x01(\v2!.sqlrs.engine.v1.PrepareJobResultR\x06result\x124\n" +
+	"\x05error\x18\b \x01(\v2\x1e.sqlrs.engine.v1.ErrorResponseR\x05error\x129\n" +
+	"\bprogress\x18\t \x01(\v2\x1d.sqlrs.engine.v1.TaskProgressR\bprogress\x12-\n" +
+	"\x04plan\x18\n" +
+	" \x03(\v2\x19.sqlrs.engine.v1.PlanTaskR\x04plan\"B\n" +
+	"\fTaskProgress\x12\x1c\n" +
+	"\tcompleted\x18\x01 \x01(\x05R\tcompleted\x12\x14\n" +
+	"\x05total\x18\x02 \x01(\x05R\x05total2\xbb\x03\n" +
+	"\vPrepareJobs\x12Q\n" +
+	"\x06Submit\x12\".sqlrs.engine.v1.PrepareJobRequest\x1a#.sqlrs.engine.v1.PrepareJobAccepted\x12O\n" +
+	"\x03Get\x12%.sqlrs.engine.v1.GetPrepareJobRequest\x1a!.sqlrs.engine.v1.PrepareJobStatus\x12]\n" +
+	"\bListJobs\x12'.sqlrs.engine.v1.ListPrepareJobsRequest\x1a(.sqlrs.engine.v1.ListPrepareJobsResponse\x12Q\n" +
+	"\x06Delete\x12(.sqlrs.engine.v1.DeletePrepareJobRequest\x1a\x1d.sqlrs.engine.v1.DeleteResult\x12V\n" +
+	"\x06Events\x12(.sqlrs.engine.v1.PrepareJobEventsRequest\x1a .sqlrs.engine.v1.PrepareJobEvent0\x01B9Z7github.com/sqlrs/engine-local/internal/grpcapi/enginev1b\x06proto3"
+
+var (
+	file_sqlrs_engine_proto_rawDescOnce sync.Once
+	file_sqlrs_engine_proto_rawDescData []byte
+)
+
+func file_sqlrs_engine_proto_rawDescGZIP() []byte {
+	file_sqlrs_engine_proto_rawDescOnce.Do(func() {
+		file_sqlrs_engine_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_sqlrs_engine_proto_rawDesc), len(file_sqlrs_engine_proto_rawDesc)))
+	})
+	return file_sqlrs_engine_proto_rawDescData
+}
+
+var file_sqlrs_engine_proto_msgTypes = make([]protoimpl.MessageInfo, 24)
+var file_sqlrs_engine_proto_goTypes = []any{
+	(*PrepareJobRequest)(nil),       // 0: sqlrs.engine.v1.PrepareJobRequest
+	(*MountSpec)(nil),               // 1: sqlrs.engine.v1.MountSpec
+	(*PrepareJobAccepted)(nil),      // 2: sqlrs.engine.v1.PrepareJobAccepted
+	(*GetPrepareJobRequest)(nil),    // 3: sqlrs.engine.v1.GetPrepareJobRequest
+	(*PrepareJobStatus)(nil),        // 4: sqlrs.engine.v1.PrepareJobStatus
+	(*PlanTask)(nil),                // 5: sqlrs.engine.v1.PlanTask
+	(*TaskInput)(nil),               // 6: sqlrs.engine.v1.TaskInput
+	(*PrepareJobResult)(nil),        // 7: sqlrs.engine.v1.PrepareJobResult
+	(*ErrorResponse)(nil),           // 8: sqlrs.engine.v1.ErrorResponse
+	(*ListPrepareJobsRequest)(nil),  // 9: sqlrs.engine.v1.ListPrepareJobsRequest
+	(*ListPrepareJobsResponse)(nil), // 10: sqlrs.engine.v1.ListPrepareJobsResponse
+	(*PrepareJobEntry)(nil),         // 11: sqlrs.engine.v1.PrepareJobEntry
+	(*DeletePrepareJobRequest)(nil), // 12: sqlrs.engine.v1.DeletePrepareJobRequest
+	(*DeleteResult)(nil),            // 13: sqlrs.engine.v1.DeleteResult
+	(*DeleteNode)(nil),              // 14: sqlrs.engine.v1.DeleteNode
+	(*PrepareJobEventsRequest)(nil), // 15: sqlrs.engine.v1.PrepareJobEventsRequest
+	(*PrepareJobEvent)(nil),         // 16: sqlrs.engine.v1.PrepareJobEvent
+	(*TaskProgress)(nil),            // 17: sqlrs.engine.v1.TaskProgress
+	nil,                             // 18: sqlrs.engine.v1.PrepareJobRequest.LiquibaseEnvEntry
+	nil,                             // 19: sqlrs.engine.v1.PrepareJobRequest.FlywayEnvEntry
+	nil,                             // 20: sqlrs.engine.v1.PrepareJobRequest.LabelsEntry
+	nil,                             // 21: sqlrs.engine.v1.PrepareJobStatus.LabelsEntry
+	nil,                             // 22: sqlrs.engine.v1.ListPrepareJobsRequest.LabelsEntry
+	nil,                             // 23: sqlrs.engine.v1.PrepareJobEntry.LabelsEntry
+}
+var file_sqlrs_engine_proto_depIdxs = []int32{
+	18, // 0: sqlrs.engine.v1.PrepareJobRequest.liquibase_env:type_name -> sqlrs.engine.v1.PrepareJobRequest.LiquibaseEnvEntry
+	19, // 1: sqlrs.engine.v1.PrepareJobRequest.flyway_env:type_name -> sqlrs.engine.v1.PrepareJobRequest.FlywayEnvEntry
+	1,  // 2: sqlrs.engine.v1.PrepareJobRequest.mounts:type_name -> sqlrs.engine.v1.MountSpec
+	20, // 3: sqlrs.engine.v1.PrepareJobRequest.labels:type_name -> sqlrs.engine.v1.PrepareJobRequest.LabelsEntry
+	21, // 4: sqlrs.engine.v1.PrepareJobStatus.labels:type_name -> sqlrs.engine.v1.PrepareJobStatus.LabelsEntry
+	5,  // 5: sqlrs.engine.v1.PrepareJobStatus.tasks:type_name -> sqlrs.engine.v1.PlanTask
+	7,  // 6: sqlrs.engine.v1.PrepareJobStatus.result:type_name -> sqlrs.engine.v1.PrepareJobResult
+	8,  // 7: sqlrs.engine.v1.PrepareJobStatus.error:type_name -> sqlrs.engine.v1.ErrorResponse
+	6,  // 8: sqlrs.engine.v1.PlanTask.input:type_name -> sqlrs.engine.v1.TaskInput
+	22, // 9: sqlrs.engine.v1.ListPrepareJobsRequest.labels:type_name -> sqlrs.engine.v1.ListPrepareJobsRequest.LabelsEntry
+	11, // 10: sqlrs.engine.v1.ListPrepareJobsResponse.jobs:type_name -> sqlrs.engine.v1.PrepareJobEntry
+	23, // 11: sqlrs.engine.v1.PrepareJobEntry.labels:type_name -> sqlrs.engine.v1.PrepareJobEntry.LabelsEntry
+	14, // 12: sqlrs.engine.v1.DeleteResult.root:type_name -> sqlrs.engine.v1.DeleteNode
+	14, // 13: sqlrs.engine.v1.DeleteNode.children:type_name -> sqlrs.engine.v1.DeleteNode
+	7,  // 14: sqlrs.engine.v1.PrepareJobEvent.result:type_name -> sqlrs.engine.v1.PrepareJobResult
+	8,  // 15: sqlrs.engine.v1.PrepareJobEvent.error:type_name -> sqlrs.engine.v1.ErrorResponse
+	17, // 16: sqlrs.engine.v1.PrepareJobEvent.progress:type_name -> sqlrs.engine.v1.TaskProgress
+	5,  // 17: sqlrs.engine.v1.PrepareJobEvent.plan:type_name -> sqlrs.engine.v1.PlanTask
+	0,  // 18: sqlrs.engine.v1.PrepareJobs.Submit:input_type -> sqlrs.engine.v1.PrepareJobRequest
+	3,  // 19: sqlrs.engine.v1.PrepareJobs.Get:input_type -> sqlrs.engine.v1.GetPrepareJobRequest
+	9,  // 20: sqlrs.engine.v1.PrepareJobs.ListJobs:input_type -> sqlrs.engine.v1.ListPrepareJobsRequest
+	12, // 21: sqlrs.engine.v1.PrepareJobs.Delete:input_type -> sqlrs.engine.v1.DeletePrepareJobRequest
+	15, // 22: sqlrs.engine.v1.PrepareJobs.Events:input_type -> sqlrs.engine.v1.PrepareJobEventsRequest
+	2,  // 23: sqlrs.engine.v1.PrepareJobs.Submit:output_type -> sqlrs.engine.v1.PrepareJobAccepted
+	4,  // 24: sqlrs.engine.v1.PrepareJobs.Get:output_type -> sqlrs.engine.v1.PrepareJobStatus
+	10, // 25: sqlrs.engine.v1.PrepareJobs.ListJobs:output_type -> sqlrs.engine.v1.ListPrepareJobsResponse
+	13, // 26: sqlrs.engine.v1.PrepareJobs.Delete:output_type -> sqlrs.engine.v1.DeleteResult
+	16, // 27: sqlrs.engine.v1.PrepareJobs.Events:output_type -> sqlrs.engine.v1.PrepareJobEvent
+	23, // [23:28] is the sub-list for method output_type
+	18, // [18:23] is the sub-list for method input_type
+	18, // [18:18] is the sub-list for extension type_name
+	18, // [18:18] is the sub-list for extension extendee
+	0,  // [0:18] is the sub-list for field type_name
+}
+
+func init() { file_sqlrs_engine_proto_init() }
+func file_sqlrs_engine_proto_init() {
+	if File_sqlrs_engine_proto != nil {
+		return
+	}
+	file_sqlrs_engine_proto_msgTypes[0].OneofWrappers = []any{}
+	file_sqlrs_engine_proto_msgTypes[4].OneofWrappers = []any{}
+	file_sqlrs_engine_proto_msgTypes[5].OneofWrappers = []any{}
+	file_sqlrs_engine_proto_msgTypes[11].OneofWrappers = []any{}
+	file_sqlrs_engine_proto_msgTypes[14].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_sqlrs_engine_proto_rawDesc), len(file_sqlrs_engine_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   24,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_sqlrs_engine_proto_goTypes,
+		DependencyIndexes: file_sqlrs_engine_proto_depIdxs,
+		MessageInfos:      file_sqlrs_engine_proto_msgTypes,
+	}.Build()
+	File_sqlrs_engine_proto = out.File
+	file_sqlrs_engine_proto_goTypes = nil
+	file_sqlrs_engine_proto_depIdxs = nil
+}
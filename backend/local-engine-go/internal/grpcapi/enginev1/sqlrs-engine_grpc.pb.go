@@ -0,0 +1,308 @@
+// gRPC interface of the local sqlrs engine.
+//
+// The engine serves it next to the HTTP API when started with
+// --grpc-listen. Messages mirror the JSON shapes of the OpenAPI spec
+// (sqlrs-engine.openapi.yaml); field names are the JSON property names.
+// Calls carry the engine auth token as "authorization: Bearer <token>"
+// metadata, like the HTTP Authorization header.
+//
+// Failed calls return a gRPC status whose details hold an ErrorResponse with
+// the same stable error code the HTTP API returns.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: sqlrs-engine.proto
+
+package enginev1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	PrepareJobs_Submit_FullMethodName   = "/sqlrs.engine.v1.PrepareJobs/Submit"
+	PrepareJobs_Get_FullMethodName      = "/sqlrs.engine.v1.PrepareJobs/Get"
+	PrepareJobs_ListJobs_FullMethodName = "/sqlrs.engine.v1.PrepareJobs/ListJobs"
+	PrepareJobs_Delete_FullMethodName   = "/sqlrs.engine.v1.PrepareJobs/Delete"
+	PrepareJobs_Events_FullMethodName   = "/sqlrs.engine.v1.PrepareJobs/Events"
+)
+
+// PrepareJobsClient is the client API for PrepareJobs service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type PrepareJobsClient interface {
+	// Submit queues a prepare job (POST /v1/prepare-jobs). A "traceparent"
+	// metadata entry makes the job span a child of the caller's trace.
+	Submit(ctx context.Context, in *PrepareJobRequest, opts ...grpc.CallOption) (*PrepareJobAccepted, error)
+	// Get returns the status of a prepare job (GET /v1/prepare-jobs/{jobId}).
+	Get(ctx context.Context, in *GetPrepareJobRequest, opts ...grpc.CallOption) (*PrepareJobStatus, error)
+	// ListJobs lists prepare jobs (GET /v1/prepare-jobs).
+	ListJobs(ctx context.Context, in *ListPrepareJobsRequest, opts ...grpc.CallOption) (*ListPrepareJobsResponse, error)
+	// Delete removes a prepare job (DELETE /v1/prepare-jobs/{jobId}). A job
+	// that is still running comes back with outcome "blocked" unless force is
+	// set.
+	Delete(ctx context.Context, in *DeletePrepareJobRequest, opts ...grpc.CallOption) (*DeleteResult, error)
+	// Events streams the events of a prepare job until it finishes
+	// (GET /v1/prepare-jobs/{jobId}/events). To resume after a dropped
+	// stream, pass the seq of the last event received as after_seq.
+	Events(ctx context.Context, in *PrepareJobEventsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[PrepareJobEvent], error)
+}
+
+type prepareJobsClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPrepareJobsClient(cc grpc.ClientConnInterface) PrepareJobsClient {
+	return &prepareJobsClient{cc}
+}
+
+func (c *prepareJobsClient) Submit(ctx context.Context, in *PrepareJobRequest, opts ...grpc.CallOption) (*PrepareJobAccepted, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PrepareJobAccepted)
+	err := c.cc.Invoke(ctx, PrepareJobs_Submit_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *prepareJobsClient) Get(ctx context.Context, in *GetPrepareJobRequest, opts ...grpc.CallOption) (*PrepareJobStatus, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PrepareJobStatus)
+	err := c.cc.Invoke(ctx, PrepareJobs_Get_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *prepareJobsClient) ListJobs(ctx context.Context, in *ListPrepareJobsRequest, opts ...grpc.CallOption) (*ListPrepareJobsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListPrepareJobsResponse)
+	err := c.cc.Invoke(ctx, PrepareJobs_ListJobs_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *prepareJobsClient) Delete(ctx context.Context, in *DeletePrepareJobRequest, opts ...grpc.CallOption) (*DeleteResult, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteResult)
+	err := c.cc.Invoke(ctx, PrepareJobs_Delete_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *prepareJobsClient) Events(ctx context.Context, in *PrepareJobEventsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[PrepareJobEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &PrepareJobs_ServiceDesc.Streams[0], PrepareJobs_Events_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[PrepareJobEventsRequest, PrepareJobEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PrepareJobs_EventsClient = grpc.ServerStreamingClient[PrepareJobEvent]
+
+// PrepareJobsServer is the server API for PrepareJobs service.
+// All implementations must embed UnimplementedPrepareJobsServer
+// for forward compatibility.
+type PrepareJobsServer interface {
+	// Submit queues a prepare job (POST /v1/prepare-jobs). A "traceparent"
+	// metadata entry makes the job span a child of the caller's trace.
+	Submit(context.Context, *PrepareJobRequest) (*PrepareJobAccepted, error)
+	// Get returns the status of a prepare job (GET /v1/prepare-jobs/{jobId}).
+	Get(context.Context, *GetPrepareJobRequest) (*PrepareJobStatus, error)
+	// ListJobs lists prepare jobs (GET /v1/prepare-jobs).
+	ListJobs(context.Context, *ListPrepareJobsRequest) (*ListPrepareJobsResponse, error)
+	// Delete removes a prepare job (DELETE /v1/prepare-jobs/{jobId}). A job
+	// that is still running comes back with outcome "blocked" unless force is
+	// set.
+	Delete(context.Context, *DeletePrepareJobRequest) (*DeleteResult, error)
+	// Events streams the events of a prepare job until it finishes
+	// (GET /v1/prepare-jobs/{jobId}/events). To resume after a dropped
+	// stream, pass the seq of the last event received as after_seq.
+	Events(*PrepareJobEventsRequest, grpc.ServerStreamingServer[PrepareJobEvent]) error
+	mustEmbedUnimplementedPrepareJobsServer()
+}
+
+// UnimplementedPrepareJobsServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedPrepareJobsServer struct{}
+
+func (UnimplementedPrepareJobsServer) Submit(context.Context, *PrepareJobRequest) (*PrepareJobAccepted, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Submit not implemented")
+}
+func (UnimplementedPrepareJobsServer) Get(context.Context, *GetPrepareJobRequest) (*PrepareJobStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
+}
+func (UnimplementedPrepareJobsServer) ListJobs(context.Context, *ListPrepareJobsRequest) (*ListPrepareJobsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListJobs not implemented")
+}
+func (UnimplementedPrepareJobsServer) Delete(context.Context, *DeletePrepareJobRequest) (*DeleteResult, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Delete not implemented")
+}
+func (UnimplementedPrepareJobsServer) Events(*PrepareJobEventsRequest, grpc.ServerStreamingServer[PrepareJobEvent]) error {
+	return status.Errorf(codes.Unimplemented, "method Events not implemented")
+}
+func (UnimplementedPrepareJobsServer) mustEmbedUnimplementedPrepareJobsServer() {}
+func (UnimplementedPrepareJobsServer) testEmbeddedByValue()                     {}
+
+// UnsafePrepareJobsServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to PrepareJobsServer will
+// result in compilation errors.
+type UnsafePrepareJobsServer interface {
+	mustEmbedUnimplementedPrepareJobsServer()
+}
+
+func RegisterPrepareJobsServer(s grpc.ServiceRegistrar, srv PrepareJobsServer) {
+	// If the following call pancis, it indicates UnimplementedPrepareJobsServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&PrepareJobs_ServiceDesc, srv)
+}
+
+func _PrepareJobs_Submit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PrepareJobRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PrepareJobsServer).Submit(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PrepareJobs_Submit_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PrepareJobsServer).Submit(ctx, req.(*PrepareJobRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PrepareJobs_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetPrepareJobRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PrepareJobsServer).Get(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PrepareJobs_Get_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PrepareJobsServer).Get(ctx, req.(*GetPrepareJobRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PrepareJobs_ListJobs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListPrepareJobsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PrepareJobsServer).ListJobs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PrepareJobs_ListJobs_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PrepareJobsServer).ListJobs(ctx, req.(*ListPrepareJobsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PrepareJobs_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeletePrepareJobRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PrepareJobsServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PrepareJobs_Delete_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PrepareJobsServer).Delete(ctx, req.(*DeletePrepareJobRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PrepareJobs_Events_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(PrepareJobEventsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PrepareJobsServer).Events(m, &grpc.GenericServerStream[PrepareJobEventsRequest, PrepareJobEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PrepareJobs_EventsServer = grpc.ServerStreamingServer[PrepareJobEvent]
+
+// PrepareJobs_ServiceDesc is the grpc.ServiceDesc for PrepareJobs service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var PrepareJobs_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "sqlrs.engine.v1.PrepareJobs",
+	HandlerType: (*PrepareJobsServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Submit",
+			Handler:    _PrepareJobs_Submit_Handler,
+		},
+		{
+			MethodName: "Get",
+			Handler:    _PrepareJobs_Get_Handler,
+		},
+		{
+			MethodName: "ListJobs",
+			Handler:    _PrepareJobs_ListJobs_Handler,
+		},
+		{
+			MethodName: "Delete",
+			Handler:    _PrepareJobs_Delete_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Events",
+			Handler:       _PrepareJobs_Events_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "sqlrs-engine.proto",
+}
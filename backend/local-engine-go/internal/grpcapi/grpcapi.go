@@ -0,0 +1,236 @@
+// Package grpcapi serves the prepare job operations of the HTTP API over
+// gRPC. The service is defined in docs/api-guides/sqlrs-engine.proto; the
+// engine starts it only when --grpc-listen is set.
+package grpcapi
+
+//go:generate protoc -I ../../../../docs/api-guides --go_out=enginev1 --go_opt=paths=source_relative --go-grpc_out=enginev1 --go-grpc_opt=paths=source_relative sqlrs-engine.proto
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/sqlrs/engine-local/internal/auth"
+	"github.com/sqlrs/engine-local/internal/deletion"
+	"github.com/sqlrs/engine-local/internal/grpcapi/enginev1"
+	"github.com/sqlrs/engine-local/internal/prepare"
+	"github.com/sqlrs/engine-local/internal/tracing"
+)
+
+type Options struct {
+	AuthToken string
+	// Auth, when set, replaces AuthToken so rotations done through the HTTP
+	// API apply to gRPC calls as well.
+	Auth    *auth.Token
+	Prepare *prepare.PrepareService
+	// Activity, when set, sees every call so the idle shutdown counts gRPC
+	// clients like HTTP ones.
+	Activity Activity
+}
+
+// Activity tracks in-flight calls.
+type Activity interface {
+	StartRequest()
+	FinishRequest()
+}
+
+func (opts Options) authToken() string {
+	if opts.Auth != nil {
+		return opts.Auth.Get()
+	}
+	return opts.AuthToken
+}
+
+// NewServer returns a gRPC server with the PrepareJobs service registered.
+func NewServer(opts Options) *grpc.Server {
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(opts.unaryInterceptor),
+		grpc.ChainStreamInterceptor(opts.streamInterceptor),
+	)
+	enginev1.RegisterPrepareJobsServer(server, &prepareJobsServer{opts: opts})
+	return server
+}
+
+func (opts Options) unaryInterceptor(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if opts.Activity != nil {
+		opts.Activity.StartRequest()
+		defer opts.Activity.FinishRequest()
+	}
+	if err := opts.requireBearer(ctx); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (opts Options) streamInterceptor(srv any, stream grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if opts.Activity != nil {
+		opts.Activity.StartRequest()
+		defer opts.Activity.FinishRequest()
+	}
+	if err := opts.requireBearer(stream.Context()); err != nil {
+		return err
+	}
+	return handler(srv, stream)
+}
+
+// requireBearer is auth.RequireBearer for the "authorization" metadata.
+func (opts Options) requireBearer(ctx context.Context) error {
+	token := opts.authToken()
+	if token == "" {
+		return nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get("authorization") {
+		if strings.TrimSpace(value) == "Bearer "+token {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "missing or invalid bearer token")
+}
+
+type prepareJobsServer struct {
+	enginev1.UnimplementedPrepareJobsServer
+	opts Options
+}
+
+func (s *prepareJobsServer) Submit(ctx context.Context, req *enginev1.PrepareJobRequest) (*enginev1.PrepareJobAccepted, error) {
+	if s.opts.Prepare == nil {
+		return nil, status.Error(codes.Internal, "prepare service is not configured")
+	}
+	// A caller's traceparent makes the job span a child of the caller's trace.
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(strings.ToLower(tracing.TraceparentHeader)); len(values) > 0 {
+			ctx = tracing.Extract(ctx, values[0])
+		}
+	}
+	accepted, err := s.opts.Prepare.Submit(ctx, requestFromProto(req))
+	if err != nil {
+		return nil, errorStatus(err)
+	}
+	return acceptedToProto(accepted), nil
+}
+
+func (s *prepareJobsServer) Get(_ context.Context, req *enginev1.GetPrepareJobRequest) (*enginev1.PrepareJobStatus, error) {
+	if s.opts.Prepare == nil {
+		return nil, status.Error(codes.Internal, "prepare service is not configured")
+	}
+	jobStatus, ok := s.opts.Prepare.Get(req.GetJobId())
+	if !ok {
+		return nil, notFound()
+	}
+	return statusToProto(jobStatus), nil
+}
+
+func (s *prepareJobsServer) ListJobs(_ context.Context, req *enginev1.ListPrepareJobsRequest) (*enginev1.ListPrepareJobsResponse, error) {
+	if s.opts.Prepare == nil {
+		return nil, status.Error(codes.Internal, "prepare service is not configured")
+	}
+	var jobs []prepare.JobEntry
+	if len(req.GetLabels()) > 0 {
+		for _, job := range s.opts.Prepare.ListJobsByLabels(req.GetLabels()) {
+			if strings.HasPrefix(job.JobID, req.GetJob()) {
+				jobs = append(jobs, job)
+			}
+		}
+	} else {
+		jobs = s.opts.Prepare.ListJobs(req.GetJob())
+	}
+	resp := &enginev1.ListPrepareJobsResponse{}
+	for _, job := range jobs {
+		if req.GetNamespace() != "" && job.Namespace != req.GetNamespace() {
+			continue
+		}
+		resp.Jobs = append(resp.Jobs, jobEntryToProto(job))
+	}
+	return resp, nil
+}
+
+func (s *prepareJobsServer) Delete(_ context.Context, req *enginev1.DeletePrepareJobRequest) (*enginev1.DeleteResult, error) {
+	if s.opts.Prepare == nil {
+		return nil, status.Error(codes.Internal, "prepare service is not configured")
+	}
+	result, ok := s.opts.Prepare.Delete(req.GetJobId(), deletion.DeleteOptions{Force: req.GetForce(), DryRun: req.GetDryRun()})
+	if !ok {
+		return nil, notFound()
+	}
+	return deleteResultToProto(result), nil
+}
+
+// Events sends the job's events as they are recorded and returns once the
+// job has finished and every event has been sent.
+func (s *prepareJobsServer) Events(req *enginev1.PrepareJobEventsRequest, stream enginev1.PrepareJobs_EventsServer) error {
+	mgr := s.opts.Prepare
+	if mgr == nil {
+		return status.Error(codes.Internal, "prepare service is not configured")
+	}
+	jobID := req.GetJobId()
+	if req.GetAfterSeq() < 0 {
+		return status.Error(codes.InvalidArgument, "after_seq must not be negative")
+	}
+	defer mgr.WatchEvents(jobID)()
+	ctx := stream.Context()
+	lastSeq := req.GetAfterSeq()
+	for {
+		events, ok, done, err := mgr.EventsAfterSeq(jobID, lastSeq)
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+		if !ok {
+			return notFound()
+		}
+		for _, event := range events {
+			if err := stream.Send(eventToProto(event)); err != nil {
+				return err
+			}
+			lastSeq = event.Seq
+		}
+		if done {
+			return nil
+		}
+		if len(events) == 0 {
+			if err := mgr.WaitForEvent(ctx, jobID, lastSeq); err != nil {
+				if ctx.Err() != nil {
+					return status.FromContextError(ctx.Err()).Err()
+				}
+				return status.Error(codes.Internal, err.Error())
+			}
+		}
+	}
+}
+
+func notFound() error {
+	return errorResponseStatus(codes.NotFound, prepare.ErrorResponse{Code: "not_found", Message: "job not found"})
+}
+
+// errorStatus maps a prepare error onto the gRPC code matching the HTTP
+// status the REST API answers with.
+func errorStatus(err error) error {
+	code := codes.Internal
+	switch err.(type) {
+	case prepare.ValidationError, *prepare.ValidationError:
+		code = codes.InvalidArgument
+	case prepare.ConflictError:
+		code = codes.AlreadyExists
+	case prepare.UnavailableError:
+		code = codes.Unavailable
+	case prepare.ResourceExhaustedError:
+		code = codes.ResourceExhausted
+	case prepare.PermissionDeniedError:
+		code = codes.PermissionDenied
+	}
+	return errorResponseStatus(code, *prepare.ToErrorResponse(err))
+}
+
+// errorResponseStatus carries resp as a status detail so clients get the
+// stable error code.
+func errorResponseStatus(code codes.Code, resp prepare.ErrorResponse) error {
+	st := status.New(code, resp.Message)
+	if detailed, err := st.WithDetails(errorToProto(&resp)); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
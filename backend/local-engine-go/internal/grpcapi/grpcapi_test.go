@@ -0,0 +1,227 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/sqlrs/engine-local/internal/grpcapi/enginev1"
+	"github.com/sqlrs/engine-local/internal/prepare"
+	"github.com/sqlrs/engine-local/internal/prepare/queue"
+	engineRuntime "github.com/sqlrs/engine-local/internal/runtime"
+	"github.com/sqlrs/engine-local/internal/statefs"
+	"github.com/sqlrs/engine-local/internal/store/sqlite"
+)
+
+type fakeRuntime struct {
+	engineRuntime.Runtime
+}
+
+func (fakeRuntime) ResolveImage(ctx context.Context, imageID string, platform string) (string, error) {
+	return imageID + "@sha256:resolved", nil
+}
+
+type fakeDBMS struct{}
+
+func (fakeDBMS) PrepareSnapshot(ctx context.Context, instance engineRuntime.Instance) error {
+	return nil
+}
+
+func (fakeDBMS) ResumeSnapshot(ctx context.Context, instance engineRuntime.Instance) error {
+	return nil
+}
+
+func newTestClient(t *testing.T) enginev1.PrepareJobsClient {
+	t.Helper()
+	dir := t.TempDir()
+	st, err := sqlite.Open(filepath.Join(dir, "state.db"))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(func() { _ = st.Close() })
+	stateRoot := filepath.Join(dir, "state-store")
+	mgr, err := prepare.NewPrepareService(prepare.Options{
+		Store:          st,
+		Queue:          queue.NewMemory(),
+		Runtime:        fakeRuntime{},
+		StateFS:        statefs.NewManager(statefs.Options{Backend: "copy", StateStoreRoot: stateRoot}),
+		DBMS:           fakeDBMS{},
+		StateStoreRoot: stateRoot,
+		Version:        "test",
+	})
+	if err != nil {
+		t.Fatalf("prepare service: %v", err)
+	}
+
+	listener := bufconn.Listen(1 << 20)
+	server := NewServer(Options{AuthToken: "secret", Prepare: mgr})
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return enginev1.NewPrepareJobsClient(conn)
+}
+
+func authContext(t *testing.T) context.Context {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer secret")
+}
+
+func planRequest() *enginev1.PrepareJobRequest {
+	return &enginev1.PrepareJobRequest{
+		PrepareKind: "psql",
+		ImageId:     "postgres:17",
+		PsqlArgs:    []string{"-c", "select 1"},
+		PlanOnly:    true,
+		Labels:      map[string]string{"pr": "42"},
+	}
+}
+
+func TestRequiresBearerToken(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+	if _, err := client.Get(ctx, &enginev1.GetPrepareJobRequest{JobId: "job-1"}); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("Get without token: expected Unauthenticated, got %v", err)
+	}
+	wrong := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer wrong")
+	stream, err := client.Events(wrong, &enginev1.PrepareJobEventsRequest{JobId: "job-1"})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("Events with wrong token: expected Unauthenticated, got %v", err)
+	}
+}
+
+func TestSubmitStreamsEventsUntilJobEnds(t *testing.T) {
+	client := newTestClient(t)
+	ctx := authContext(t)
+	accepted, err := client.Submit(ctx, planRequest())
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	if accepted.GetJobId() == "" || accepted.GetStatusUrl() != "/v1/prepare-jobs/"+accepted.GetJobId() {
+		t.Fatalf("unexpected accepted: %+v", accepted)
+	}
+
+	events := receiveEvents(t, client, ctx, accepted.GetJobId(), 0)
+	if len(events) < 2 {
+		t.Fatalf("expected several events, got %+v", events)
+	}
+	last := events[len(events)-1]
+	if last.GetType() != "status" || last.GetStatus() != prepare.StatusSucceeded {
+		t.Fatalf("expected the stream to end with the terminal status, got %+v", last)
+	}
+	resumed := receiveEvents(t, client, ctx, accepted.GetJobId(), events[0].GetSeq())
+	if len(resumed) != len(events)-1 || resumed[0].GetSeq() != events[1].GetSeq() {
+		t.Fatalf("expected after_seq to resume after the first event, got %+v", resumed)
+	}
+
+	jobStatus, err := client.Get(ctx, &enginev1.GetPrepareJobRequest{JobId: accepted.GetJobId()})
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if jobStatus.GetStatus() != prepare.StatusSucceeded || !jobStatus.GetPlanOnly() || len(jobStatus.GetTasks()) == 0 {
+		t.Fatalf("unexpected status: %+v", jobStatus)
+	}
+	if jobStatus.GetTasks()[0].GetTaskId() != "plan" {
+		t.Fatalf("expected the plan task first, got %+v", jobStatus.GetTasks())
+	}
+
+	listed, err := client.ListJobs(ctx, &enginev1.ListPrepareJobsRequest{Labels: map[string]string{"pr": "42"}})
+	if err != nil {
+		t.Fatalf("ListJobs: %v", err)
+	}
+	if len(listed.GetJobs()) != 1 || listed.GetJobs()[0].GetJobId() != accepted.GetJobId() {
+		t.Fatalf("unexpected jobs: %+v", listed.GetJobs())
+	}
+	other, err := client.ListJobs(ctx, &enginev1.ListPrepareJobsRequest{Namespace: "other"})
+	if err != nil || len(other.GetJobs()) != 0 {
+		t.Fatalf("expected no jobs in another namespace, got %+v, %v", other.GetJobs(), err)
+	}
+
+	deleted, err := client.Delete(ctx, &enginev1.DeletePrepareJobRequest{JobId: accepted.GetJobId()})
+	if err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if deleted.GetOutcome() != "deleted" || deleted.GetRoot().GetId() != accepted.GetJobId() {
+		t.Fatalf("unexpected delete result: %+v", deleted)
+	}
+	if _, err := client.Get(ctx, &enginev1.GetPrepareJobRequest{JobId: accepted.GetJobId()}); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected deleted job to be gone, got %v", err)
+	}
+}
+
+func TestErrorsCarryStableCodes(t *testing.T) {
+	client := newTestClient(t)
+	ctx := authContext(t)
+
+	req := planRequest()
+	req.PrepareKind = "liquibase"
+	_, err := client.Submit(ctx, req)
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument, got %v", err)
+	}
+	if resp := errorDetail(t, err); resp.GetCode() != "invalid_argument" {
+		t.Fatalf("unexpected error detail: %+v", resp)
+	}
+
+	stream, err := client.Events(ctx, &enginev1.PrepareJobEventsRequest{JobId: "missing"})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.NotFound || errorDetail(t, err).GetCode() != "not_found" {
+		t.Fatalf("expected not_found for a missing job, got %v", err)
+	}
+}
+
+func receiveEvents(t *testing.T, client enginev1.PrepareJobsClient, ctx context.Context, jobID string, afterSeq int64) []*enginev1.PrepareJobEvent {
+	t.Helper()
+	stream, err := client.Events(ctx, &enginev1.PrepareJobEventsRequest{JobId: jobID, AfterSeq: afterSeq})
+	if err != nil {
+		t.Fatalf("Events: %v", err)
+	}
+	var events []*enginev1.PrepareJobEvent
+	for {
+		event, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return events
+		}
+		if err != nil {
+			t.Fatalf("Recv: %v", err)
+		}
+		events = append(events, event)
+	}
+}
+
+func errorDetail(t *testing.T, err error) *enginev1.ErrorResponse {
+	t.Helper()
+	for _, detail := range status.Convert(err).Details() {
+		if resp, ok := detail.(*enginev1.ErrorResponse); ok {
+			return resp
+		}
+	}
+	t.Fatalf("no ErrorResponse detail in %v", err)
+	return nil
+}
@@ -0,0 +1,72 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sqlrs/engine-local/internal/auth"
+)
+
+func TestAuthRotateReplacesToken(t *testing.T) {
+	token := auth.NewToken("secret")
+	opts := Options{
+		AuthToken: "secret",
+		Auth:      token,
+		RotateAuth: func() (string, error) {
+			token.Set("rotated")
+			return "rotated", nil
+		},
+	}
+	mux := http.NewServeMux()
+	authRoutes{opts: opts}.register(mux)
+
+	rotate := func(method, bearer string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/v1/auth/rotate", nil)
+		req.Header.Set("Authorization", "Bearer "+bearer)
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, req)
+		return resp
+	}
+
+	if resp := rotate(http.MethodGet, "secret"); resp.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("status = %d, want %d", resp.Code, http.StatusMethodNotAllowed)
+	}
+	resp := rotate(http.MethodPost, "secret")
+	if resp.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.Code, http.StatusOK)
+	}
+	var body authRotateResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil || body.AuthToken != "rotated" {
+		t.Fatalf("unexpected body %+v err=%v", body, err)
+	}
+	if resp := rotate(http.MethodPost, "secret"); resp.Code != http.StatusUnauthorized {
+		t.Fatalf("expected old token to be rejected, got %d", resp.Code)
+	}
+	if resp := rotate(http.MethodPost, "rotated"); resp.Code != http.StatusOK {
+		t.Fatalf("expected new token to be accepted, got %d", resp.Code)
+	}
+}
+
+func TestAuthRotateErrors(t *testing.T) {
+	cases := []struct {
+		rotate func() (string, error)
+		want   int
+	}{
+		{rotate: nil, want: http.StatusNotImplemented},
+		{rotate: func() (string, error) { return "", errors.New("disk full") }, want: http.StatusInternalServerError},
+	}
+	for _, tc := range cases {
+		mux := http.NewServeMux()
+		authRoutes{opts: Options{AuthToken: "secret", RotateAuth: tc.rotate}}.register(mux)
+		req := httptest.NewRequest(http.MethodPost, "/v1/auth/rotate", nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, req)
+		if resp.Code != tc.want {
+			t.Fatalf("status = %d, want %d", resp.Code, tc.want)
+		}
+	}
+}
@@ -47,6 +47,39 @@ func TestCacheStatusReturnsPayload(t *testing.T) {
 	}
 }
 
+func TestUsageReturnsSummary(t *testing.T) {
+	opts, cleanup := newRouteTestOptions(t)
+	defer cleanup()
+
+	handler := NewHandler(opts)
+	req := httptest.NewRequest(http.MethodGet, "/v1/usage", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	resp := httptest.NewRecorder()
+
+	handler.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.Code, http.StatusOK)
+	}
+	var payload map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		t.Fatalf("decode payload: %v", err)
+	}
+	for _, key := range []string{"generated_at", "state_count", "state_size_bytes", "jobs", "cache", "prepare_duration"} {
+		if _, ok := payload[key]; !ok {
+			t.Fatalf("expected %q in payload, got %+v", key, payload)
+		}
+	}
+
+	post := httptest.NewRequest(http.MethodPost, "/v1/usage", nil)
+	post.Header.Set("Authorization", "Bearer secret")
+	resp = httptest.NewRecorder()
+	handler.ServeHTTP(resp, post)
+	if resp.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("status = %d, want %d", resp.Code, http.StatusMethodNotAllowed)
+	}
+}
+
 func TestStatesListIncludesCacheMetadataFields(t *testing.T) {
 	server, cleanup := newTestServer(t)
 	defer cleanup()
@@ -0,0 +1,102 @@
+package httpapi
+
+import (
+	"net/http"
+	"strings"
+)
+
+const corsOriginsPath = "http.cors.allowedOrigins"
+
+const (
+	corsAllowMethods  = "GET, POST, PUT, PATCH, DELETE"
+	corsAllowHeaders  = "Authorization, Content-Type, Range, Last-Event-ID, If-None-Match"
+	corsExposeHeaders = "ETag, Location, Retry-After"
+	corsMaxAge        = "600"
+)
+
+// withCORS adds CORS headers for origins listed in http.cors.allowedOrigins
+// and answers preflight requests before auth, since browsers send them
+// without the Authorization header. The list is read per request, so config
+// changes apply right away; an empty list keeps the API same-origin only.
+// "*" is honored only when the engine runs without an auth token, so a
+// leaked token cannot be used from an arbitrary page.
+func withCORS(next http.Handler, opts Options) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		allowed, ok := opts.corsAllowOrigin(origin)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		header := w.Header()
+		header.Add("Vary", "Origin")
+		header.Set("Access-Control-Allow-Origin", allowed)
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			header.Add("Vary", "Access-Control-Request-Method")
+			header.Add("Vary", "Access-Control-Request-Headers")
+			header.Set("Access-Control-Allow-Methods", corsAllowMethods)
+			header.Set("Access-Control-Allow-Headers", corsAllowHeaders)
+			header.Set("Access-Control-Max-Age", corsMaxAge)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		header.Set("Access-Control-Expose-Headers", corsExposeHeaders)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// corsAllowOrigin returns the Access-Control-Allow-Origin value for origin,
+// or false when the origin is not allowed.
+func (opts Options) corsAllowOrigin(origin string) (string, bool) {
+	for _, allowed := range opts.corsOrigins() {
+		if allowed == "*" {
+			if opts.authToken() == "" {
+				return "*", true
+			}
+			continue
+		}
+		if strings.EqualFold(allowed, origin) {
+			return origin, true
+		}
+	}
+	return "", false
+}
+
+func (opts Options) corsOrigins() []string {
+	if opts.Config == nil {
+		return nil
+	}
+	value, err := opts.Config.Get(corsOriginsPath, true)
+	if err != nil {
+		return nil
+	}
+	items, ok := value.([]any)
+	if !ok {
+		return nil
+	}
+	origins := make([]string, 0, len(items))
+	for _, item := range items {
+		if origin, ok := item.(string); ok {
+			origins = append(origins, strings.TrimSpace(origin))
+		}
+	}
+	return origins
+}
+
+// checkCORSOrigins rejects a wildcard origin while the API requires a token.
+func (opts Options) checkCORSOrigins(path string, value any) bool {
+	if path != corsOriginsPath || opts.authToken() == "" {
+		return true
+	}
+	items, _ := value.([]any)
+	for _, item := range items {
+		if origin, ok := item.(string); ok && strings.TrimSpace(origin) == "*" {
+			return false
+		}
+	}
+	return true
+}
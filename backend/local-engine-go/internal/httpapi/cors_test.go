@@ -0,0 +1,135 @@
+package httpapi
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sqlrs/engine-local/internal/config"
+)
+
+func TestCORSPreflight(t *testing.T) {
+	handler := NewHandler(Options{
+		AuthToken: "secret",
+		Config:    &fakeConfig{getValue: []any{"https://dash.example.com"}},
+	})
+
+	for _, path := range []string{"/v1/prepare-jobs", "/v1/prepare-jobs/job-1/events"} {
+		req := httptest.NewRequest(http.MethodOptions, path, nil)
+		req.Header.Set("Origin", "https://dash.example.com")
+		req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+		req.Header.Set("Access-Control-Request-Headers", "authorization, last-event-id")
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+
+		if resp.Code != http.StatusNoContent {
+			t.Fatalf("%s: expected 204 without auth, got %d", path, resp.Code)
+		}
+		if got := resp.Header().Get("Access-Control-Allow-Origin"); got != "https://dash.example.com" {
+			t.Fatalf("%s: unexpected allow origin %q", path, got)
+		}
+		allowHeaders := resp.Header().Get("Access-Control-Allow-Headers")
+		for _, name := range []string{"Authorization", "Range", "Last-Event-ID"} {
+			if !strings.Contains(allowHeaders, name) {
+				t.Fatalf("%s: expected %s in allowed headers, got %q", path, name, allowHeaders)
+			}
+		}
+		if !strings.Contains(resp.Header().Get("Access-Control-Allow-Methods"), http.MethodPost) {
+			t.Fatalf("%s: unexpected allow methods %q", path, resp.Header().Get("Access-Control-Allow-Methods"))
+		}
+	}
+}
+
+func TestCORSActualRequest(t *testing.T) {
+	handler := NewHandler(Options{
+		AuthToken: "secret",
+		Config:    &fakeConfig{getValue: []any{"https://dash.example.com"}},
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/health", nil)
+	req.Header.Set("Origin", "https://dash.example.com")
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.Code)
+	}
+	if got := resp.Header().Get("Access-Control-Allow-Origin"); got != "https://dash.example.com" {
+		t.Fatalf("unexpected allow origin %q", got)
+	}
+	if resp.Header().Get("Vary") != "Origin" || !strings.Contains(resp.Header().Get("Access-Control-Expose-Headers"), "ETag") {
+		t.Fatalf("unexpected CORS headers: %v", resp.Header())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/health", nil)
+	req.Header.Set("Origin", "https://evil.example.com")
+	resp = httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+	if resp.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("expected no CORS headers for an unlisted origin, got %v", resp.Header())
+	}
+
+	req = httptest.NewRequest(http.MethodOptions, "/v1/prepare-jobs", nil)
+	req.Header.Set("Origin", "https://evil.example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	resp = httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+	if resp.Code == http.StatusNoContent || resp.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("expected preflight from an unlisted origin to be refused, got %d %v", resp.Code, resp.Header())
+	}
+}
+
+func TestCORSDisabledByDefault(t *testing.T) {
+	handler := NewHandler(Options{AuthToken: "secret", Config: &fakeConfig{getValue: []any{}}})
+	req := httptest.NewRequest(http.MethodGet, "/v1/health", nil)
+	req.Header.Set("Origin", "https://dash.example.com")
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+	if len(resp.Header().Values("Access-Control-Allow-Origin")) != 0 || len(resp.Header().Values("Vary")) != 0 {
+		t.Fatalf("expected no CORS headers, got %v", resp.Header())
+	}
+}
+
+func TestCORSWildcard(t *testing.T) {
+	cfg := &fakeConfig{getValue: []any{"*"}}
+	for token, want := range map[string]string{"": "*", "secret": ""} {
+		handler := NewHandler(Options{AuthToken: token, Config: cfg})
+		req := httptest.NewRequest(http.MethodGet, "/v1/health", nil)
+		req.Header.Set("Origin", "https://dash.example.com")
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		if got := resp.Header().Get("Access-Control-Allow-Origin"); got != want {
+			t.Fatalf("token %q: expected allow origin %q, got %q", token, want, got)
+		}
+	}
+}
+
+func TestConfigRejectsCORSWildcardWithAuthToken(t *testing.T) {
+	cfg := &fakeConfig{schema: config.DefaultSchema()}
+	handler := NewHandler(Options{AuthToken: "secret", Config: cfg})
+
+	req := httptest.NewRequest(http.MethodPatch, "/v1/config", bytes.NewBufferString(`{"path":"http.cors.allowedOrigins","value":["*"]}`))
+	req.Header.Set("Authorization", "Bearer secret")
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+	if resp.Code != http.StatusBadRequest || cfg.lastValue != nil || !strings.Contains(resp.Body.String(), "wildcard") {
+		t.Fatalf("expected wildcard to be rejected, got %d %s", resp.Code, resp.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodPut, "/v1/config/http.cors.allowedOrigins", bytes.NewBufferString(`["https://dash.example.com", "*"]`))
+	req.Header.Set("Authorization", "Bearer secret")
+	resp = httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+	if resp.Code != http.StatusBadRequest || cfg.lastValue != nil || !strings.Contains(resp.Body.String(), "wildcard") {
+		t.Fatalf("expected wildcard to be rejected, got %d %s", resp.Code, resp.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodPatch, "/v1/config", bytes.NewBufferString(`{"path":"http.cors.allowedOrigins","value":["https://dash.example.com"]}`))
+	req.Header.Set("Authorization", "Bearer secret")
+	resp = httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected explicit origin to be accepted, got %d %s", resp.Code, resp.Body.String())
+	}
+}
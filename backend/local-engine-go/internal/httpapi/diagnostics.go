@@ -0,0 +1,191 @@
+package httpapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/sqlrs/engine-local/internal/auth"
+	engineRuntime "github.com/sqlrs/engine-local/internal/runtime"
+)
+
+const (
+	diagnosticPass = "pass"
+	diagnosticWarn = "warn"
+	diagnosticFail = "fail"
+)
+
+var stateStoreMountStatusFn = engineRuntime.StateStoreMountStatus
+
+type diagnosticCheck struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	Hint    string `json:"hint,omitempty"`
+}
+
+type diagnosticsResponse struct {
+	// Ok is false when any check failed.
+	Ok     bool              `json:"ok"`
+	Checks []diagnosticCheck `json:"checks"`
+}
+
+// handleDiagnostics serves /v1/diagnostics: engine-side checks for
+// `sqlrs doctor`. Every check runs on each request; nothing is cached and
+// nothing is repaired.
+func (routes engineRoutes) handleDiagnostics(w http.ResponseWriter, r *http.Request) {
+	if !auth.RequireBearer(w, r, routes.opts.authToken()) {
+		return
+	}
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	checks := []diagnosticCheck{
+		routes.checkContainerRuntime(r.Context()),
+		routes.checkSnapshotBackend(),
+		routes.checkStateStore(),
+	}
+	if check, ok := routes.checkDiskSpace(r.Context()); ok {
+		checks = append(checks, check)
+	}
+	if check, ok := routes.checkWSLMount(); ok {
+		checks = append(checks, check)
+	}
+	resp := diagnosticsResponse{Ok: true, Checks: checks}
+	for _, check := range checks {
+		if check.Status == diagnosticFail {
+			resp.Ok = false
+		}
+	}
+	_ = writeJSON(w, resp)
+}
+
+func (routes engineRoutes) checkContainerRuntime(ctx context.Context) diagnosticCheck {
+	check := diagnosticCheck{Name: "containerRuntime"}
+	if routes.opts.Runtime == nil {
+		check.Status = diagnosticFail
+		check.Message = "container runtime is not configured"
+		check.Hint = "install Docker or Podman and restart the engine"
+		return check
+	}
+	binary := "container runtime"
+	if named, ok := routes.opts.Runtime.(interface{ Binary() string }); ok && named.Binary() != "" {
+		binary = named.Binary()
+	}
+	pingCtx, cancel := context.WithTimeout(ctx, runtimeHealthTimeout)
+	defer cancel()
+	version, err := routes.opts.Runtime.Ping(pingCtx)
+	if err != nil {
+		check.Status = diagnosticFail
+		check.Message = fmt.Sprintf("%s is not reachable: %v", binary, err)
+		check.Hint = fmt.Sprintf("start %s and make sure the engine user can access it; container.runtime selects the runtime", binary)
+		return check
+	}
+	check.Status = diagnosticPass
+	check.Message = strings.TrimSpace(binary + " " + version)
+	return check
+}
+
+func (routes engineRoutes) checkSnapshotBackend() diagnosticCheck {
+	check := diagnosticCheck{Name: "snapshotBackend"}
+	switch backend := routes.opts.SnapshotBackend; backend {
+	case "":
+		check.Status = diagnosticWarn
+		check.Message = "snapshot backend is unknown"
+		check.Hint = "check the engine log for the snapshot backend chosen at startup"
+	case "copy":
+		check.Status = diagnosticWarn
+		check.Message = "using copy snapshots"
+		check.Hint = "copy snapshots duplicate every data directory; put the state store on btrfs or enable overlay for faster, smaller snapshots (snapshot.backend)"
+	default:
+		check.Status = diagnosticPass
+		check.Message = "using " + backend + " snapshots"
+	}
+	return check
+}
+
+func (routes engineRoutes) checkStateStore() diagnosticCheck {
+	check := diagnosticCheck{Name: "stateStore"}
+	root := strings.TrimSpace(routes.opts.StateStoreRoot)
+	if root == "" {
+		check.Status = diagnosticWarn
+		check.Message = "state store root is unknown"
+		return check
+	}
+	probe, err := os.CreateTemp(root, ".doctor-*")
+	if err != nil {
+		check.Status = diagnosticFail
+		check.Message = fmt.Sprintf("state store %s is not writable: %v", root, err)
+		check.Hint = "make sure the state store directory exists and is writable by the engine user (SQLRS_STATE_STORE)"
+		return check
+	}
+	_ = probe.Close()
+	_ = os.Remove(probe.Name())
+	check.Status = diagnosticPass
+	check.Message = "state store " + root + " is writable"
+	return check
+}
+
+func (routes engineRoutes) checkDiskSpace(ctx context.Context) (diagnosticCheck, bool) {
+	if routes.opts.Prepare == nil {
+		return diagnosticCheck{}, false
+	}
+	check := diagnosticCheck{Name: "diskSpace"}
+	status, err := routes.opts.Prepare.CacheStatus(ctx)
+	if err != nil {
+		check.Status = diagnosticFail
+		check.Message = fmt.Sprintf("cannot measure state store disk space: %v", err)
+		return check, true
+	}
+	check.Message = fmt.Sprintf("%d bytes free, %d bytes reserved (cache.capacity.reserveBytes)", status.StoreFreeBytes, status.ReserveBytes)
+	switch {
+	case status.StoreFreeBytes < status.ReserveBytes:
+		check.Status = diagnosticFail
+		check.Hint = "free disk space on the state store, remove unused states with `sqlrs states prune`, or lower cache.capacity.reserveBytes"
+	case len(status.PressureReasons) > 0:
+		check.Status = diagnosticWarn
+		check.Message += "; cache under pressure: " + strings.Join(status.PressureReasons, ", ")
+		check.Hint = "remove unused states with `sqlrs states prune` or raise cache.capacity.maxBytes"
+	default:
+		check.Status = diagnosticPass
+	}
+	return check, true
+}
+
+// checkWSLMount reports the WSL state store mount; it is skipped when the
+// engine runs without one.
+func (routes engineRoutes) checkWSLMount() (diagnosticCheck, bool) {
+	status, ok, err := stateStoreMountStatusFn(routes.opts.StateStoreRoot)
+	if !ok {
+		return diagnosticCheck{}, false
+	}
+	check := diagnosticCheck{Name: "wslMount"}
+	hint := "run `sqlrs init local --update` to recreate the WSL mount unit"
+	if status.Unit != "" {
+		hint = fmt.Sprintf("start it with `systemctl start %s` inside WSL, or check `journalctl -u %s`", status.Unit, status.Unit)
+	}
+	switch {
+	case err != nil:
+		check.Status = diagnosticFail
+		check.Message = err.Error()
+		check.Hint = hint
+	case status.Unit == "":
+		check.Status = diagnosticFail
+		check.Message = "SQLRS_WSL_MOUNT_UNIT is not set"
+		check.Hint = hint
+	case !status.UnitActive:
+		check.Status = diagnosticFail
+		check.Message = fmt.Sprintf("mount unit %s is not active", status.Unit)
+		check.Hint = hint
+	case !status.Mounted || status.FSType != status.ExpectedFSType:
+		check.Status = diagnosticFail
+		check.Message = fmt.Sprintf("state store is on %q, expected %s", status.FSType, status.ExpectedFSType)
+		check.Hint = hint
+	default:
+		check.Status = diagnosticPass
+		check.Message = fmt.Sprintf("mount unit %s is active (%s)", status.Unit, status.FSType)
+	}
+	return check, true
+}
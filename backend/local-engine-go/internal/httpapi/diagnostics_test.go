@@ -0,0 +1,136 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	engineRuntime "github.com/sqlrs/engine-local/internal/runtime"
+)
+
+func getDiagnostics(t *testing.T, handler http.Handler) diagnosticsResponse {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/v1/diagnostics", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected 200 for diagnostics, got %d: %s", resp.Code, resp.Body.String())
+	}
+	var out diagnosticsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatalf("decode diagnostics: %v", err)
+	}
+	return out
+}
+
+func diagnosticByName(checks []diagnosticCheck, name string) (diagnosticCheck, bool) {
+	for _, check := range checks {
+		if check.Name == name {
+			return check, true
+		}
+	}
+	return diagnosticCheck{}, false
+}
+
+func stubMountStatus(t *testing.T, status engineRuntime.MountStatus, ok bool, err error) {
+	t.Helper()
+	prev := stateStoreMountStatusFn
+	stateStoreMountStatusFn = func(string) (engineRuntime.MountStatus, bool, error) {
+		return status, ok, err
+	}
+	t.Cleanup(func() { stateStoreMountStatusFn = prev })
+}
+
+func TestDiagnosticsAllPass(t *testing.T) {
+	stubMountStatus(t, engineRuntime.MountStatus{}, false, nil)
+	root := t.TempDir()
+	handler := NewHandler(Options{
+		AuthToken:       "secret",
+		Runtime:         &pingRuntime{version: "27.0.1"},
+		SnapshotBackend: "btrfs",
+		StateStoreRoot:  root,
+	})
+
+	out := getDiagnostics(t, handler)
+	if !out.Ok || len(out.Checks) != 3 {
+		t.Fatalf("expected three passing checks, got %+v", out)
+	}
+	for _, check := range out.Checks {
+		if check.Status != diagnosticPass {
+			t.Fatalf("expected %s to pass, got %+v", check.Name, check)
+		}
+	}
+	if entries, err := os.ReadDir(root); err != nil || len(entries) != 0 {
+		t.Fatalf("expected the writability probe to be removed, got %v %v", entries, err)
+	}
+	if _, ok := diagnosticByName(out.Checks, "wslMount"); ok {
+		t.Fatalf("expected wslMount to be skipped without a mount unit")
+	}
+}
+
+func TestDiagnosticsReportsFailures(t *testing.T) {
+	stubMountStatus(t, engineRuntime.MountStatus{Unit: "sqlrs-state.mount", ExpectedFSType: "btrfs"}, true, nil)
+	handler := NewHandler(Options{
+		AuthToken:       "secret",
+		Runtime:         &pingRuntime{err: errors.New("cannot connect to the docker daemon")},
+		SnapshotBackend: "copy",
+		StateStoreRoot:  filepath.Join(t.TempDir(), "missing"),
+	})
+
+	out := getDiagnostics(t, handler)
+	if out.Ok {
+		t.Fatalf("expected diagnostics to fail, got %+v", out)
+	}
+	want := map[string]string{
+		"containerRuntime": diagnosticFail,
+		"snapshotBackend":  diagnosticWarn,
+		"stateStore":       diagnosticFail,
+		"wslMount":         diagnosticFail,
+	}
+	for name, status := range want {
+		check, ok := diagnosticByName(out.Checks, name)
+		if !ok || check.Status != status {
+			t.Fatalf("expected %s to be %s, got %+v", name, status, check)
+		}
+		if check.Hint == "" {
+			t.Fatalf("expected a hint for %s", name)
+		}
+	}
+}
+
+func TestDiagnosticsWSLMountActive(t *testing.T) {
+	stubMountStatus(t, engineRuntime.MountStatus{Unit: "sqlrs-state.mount", ExpectedFSType: "btrfs", UnitActive: true, Mounted: true, FSType: "btrfs"}, true, nil)
+	check, ok := engineRoutes{}.checkWSLMount()
+	if !ok || check.Status != diagnosticPass {
+		t.Fatalf("expected wslMount to pass, got %+v", check)
+	}
+
+	stubMountStatus(t, engineRuntime.MountStatus{Unit: "sqlrs-state.mount", ExpectedFSType: "btrfs", UnitActive: true, Mounted: true, FSType: "ext4"}, true, nil)
+	check, _ = engineRoutes{}.checkWSLMount()
+	if check.Status != diagnosticFail {
+		t.Fatalf("expected wrong filesystem to fail, got %+v", check)
+	}
+}
+
+func TestDiagnosticsRequiresAuth(t *testing.T) {
+	handler := NewHandler(Options{AuthToken: "secret", StateStoreRoot: t.TempDir()})
+	req := httptest.NewRequest(http.MethodGet, "/v1/diagnostics", nil)
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+	if resp.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", resp.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/v1/diagnostics", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	resp = httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+	if resp.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, got %d", resp.Code)
+	}
+}
@@ -0,0 +1,112 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type fakeActivity struct {
+	inflight int64
+	idle     time.Duration
+}
+
+func (a fakeActivity) InflightRequests() int64 { return a.inflight }
+
+func (a fakeActivity) IdleFor() time.Duration { return a.idle }
+
+func TestEngineStatsReportsActivityAndBackends(t *testing.T) {
+	opts, cleanup := newRouteTestOptions(t)
+	defer cleanup()
+	opts.StartedAt = time.Now().Add(-90 * time.Second)
+	opts.Activity = fakeActivity{inflight: 3, idle: 12 * time.Second}
+	opts.IdleTimeout = 30 * time.Second
+	opts.SnapshotBackend = "copy"
+	opts.Runtime = &pingRuntime{}
+
+	mux := http.NewServeMux()
+	engineRoutes{opts: opts}.register(mux)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/engine/stats", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	resp := httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.Code, http.StatusOK)
+	}
+	var stats engineStatsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		t.Fatalf("decode stats: %v", err)
+	}
+	if stats.Version != "test" || stats.InstanceID != "instance" || stats.PID == 0 {
+		t.Fatalf("unexpected identity: %+v", stats)
+	}
+	if stats.UptimeSeconds < 90 || stats.StartedAt == "" {
+		t.Fatalf("unexpected uptime: %+v", stats)
+	}
+	if stats.InflightRequests != 2 || stats.IdleSeconds != 12 || stats.IdleTimeoutSeconds != 30 {
+		t.Fatalf("unexpected activity: %+v", stats)
+	}
+	if stats.RunningJobs != 0 || stats.ContainerRuntime != "/usr/bin/docker" || stats.SnapshotBackend != "copy" {
+		t.Fatalf("unexpected engine details: %+v", stats)
+	}
+}
+
+func TestEngineStatsRequiresAuthAndGet(t *testing.T) {
+	opts, cleanup := newRouteTestOptions(t)
+	defer cleanup()
+	mux := http.NewServeMux()
+	engineRoutes{opts: opts}.register(mux)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/engine/stats", nil)
+	resp := httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+	if resp.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want %d", resp.Code, http.StatusUnauthorized)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/v1/engine/stats", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	resp = httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+	if resp.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("status = %d, want %d", resp.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestVersionReportsBuildWithoutAuth(t *testing.T) {
+	opts, cleanup := newRouteTestOptions(t)
+	defer cleanup()
+	opts.Build = BuildInfo{Revision: "abc123", Time: "2026-01-01T00:00:00Z", GoVersion: "go1.22.0"}
+	opts.SnapshotBackend = "btrfs"
+	opts.Runtime = &pingRuntime{}
+
+	mux := http.NewServeMux()
+	engineRoutes{opts: opts}.register(mux)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/version", nil)
+	resp := httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.Code, http.StatusOK)
+	}
+	var version versionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&version); err != nil {
+		t.Fatalf("decode version: %v", err)
+	}
+	if version.Version != "test" || version.APIVersion != APIVersion || version.Revision != "abc123" || version.GoVersion != "go1.22.0" {
+		t.Fatalf("unexpected version: %+v", version)
+	}
+	if version.ContainerRuntime != "/usr/bin/docker" || version.SnapshotBackend != "btrfs" {
+		t.Fatalf("unexpected engine details: %+v", version)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/v1/version", nil)
+	resp = httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+	if resp.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("status = %d, want %d", resp.Code, http.StatusMethodNotAllowed)
+	}
+}
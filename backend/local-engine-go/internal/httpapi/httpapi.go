@@ -2,32 +2,65 @@ package httpapi
 
 import (
 	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/sqlrs/engine-local/internal/auth"
 	"github.com/sqlrs/engine-local/internal/config"
 	"github.com/sqlrs/engine-local/internal/deletion"
 	"github.com/sqlrs/engine-local/internal/prepare"
 	"github.com/sqlrs/engine-local/internal/registry"
 	"github.com/sqlrs/engine-local/internal/run"
+	engineRuntime "github.com/sqlrs/engine-local/internal/runtime"
 )
 
 type Options struct {
 	Version    string
 	InstanceID string
 	AuthToken  string
+	// Build feeds /v1/version.
+	Build BuildInfo
+	// Auth, when set, replaces AuthToken so the token can be rotated while
+	// the engine runs. RotateAuth generates a new token, persists it where
+	// clients discover it, and returns it.
+	Auth       *auth.Token
+	RotateAuth func() (string, error)
 	Registry   *registry.Registry
 	Prepare    *prepare.PrepareService
 	Deletion   *deletion.Manager
 	Run        *run.Manager
 	Config     config.Store
+	// Runtime backs the health runtime probe and instance liveness checks.
+	Runtime engineRuntime.Runtime
+	// StartedAt, Activity, IdleTimeout and SnapshotBackend feed
+	// /v1/engine/stats.
+	StartedAt       time.Time
+	Activity        EngineActivity
+	IdleTimeout     time.Duration
+	SnapshotBackend string
+	// StateStoreRoot is checked by /v1/diagnostics.
+	StateStoreRoot string
 }
 
 type healthResponse struct {
-	Ok         bool   `json:"ok"`
-	Version    string `json:"version"`
-	InstanceID string `json:"instanceId"`
-	PID        int    `json:"pid"`
+	Ok               bool                    `json:"ok"`
+	Reason           string                  `json:"reason,omitempty"`
+	Version          string                  `json:"version"`
+	InstanceID       string                  `json:"instanceId"`
+	PID              int                     `json:"pid"`
+	ContainerRuntime *containerRuntimeHealth `json:"containerRuntime,omitempty"`
+}
+
+func (opts Options) authToken() string {
+	if opts.Auth != nil {
+		return opts.Auth.Get()
+	}
+	return opts.AuthToken
 }
 
 func NewHandler(opts Options) http.Handler {
@@ -38,54 +71,177 @@ func NewHandler(opts Options) http.Handler {
 	prepareRoutes{opts: opts}.register(mux)
 	runRoutes{opts: opts}.register(mux)
 	registryRoutes{opts: opts}.register(mux)
-	return mux
+	engineRoutes{opts: opts}.register(mux)
+	authRoutes{opts: opts}.register(mux)
+	return withCORS(mux, opts)
 }
 
+// registerHealthRoutes serves /v1/health. The engine answers 200 even when the
+// container runtime is unreachable; ok is false and reason explains why.
 func registerHealthRoutes(mux *http.ServeMux, opts Options) {
+	probe := newRuntimeHealthProbe(opts.Runtime)
 	mux.HandleFunc("/v1/health", func(w http.ResponseWriter, r *http.Request) {
 		if !requireMethod(w, r, http.MethodGet) {
 			return
 		}
-		_ = writeJSON(w, healthResponse{
+		resp := healthResponse{
 			Ok:         true,
 			Version:    opts.Version,
 			InstanceID: opts.InstanceID,
 			PID:        os.Getpid(),
-		})
+		}
+		if probe != nil {
+			runtimeHealth := probe.check(r.Context())
+			resp.ContainerRuntime = &runtimeHealth
+			if !runtimeHealth.Available {
+				resp.Ok = false
+				resp.Reason = "container runtime unavailable"
+			}
+		}
+		_ = writeJSON(w, resp)
 	})
 }
 
+// streamPrepareEvents streams job events as NDJSON, or as Server-Sent Events
+// when the client asks for text/event-stream. ?after_seq=N starts right after
+// the event with seq N. SSE ids are event seqs, so a reconnect with
+// Last-Event-ID resumes right after the last delivered event, even when
+// older log events were pruned in between.
 func streamPrepareEvents(w http.ResponseWriter, r *http.Request, mgr *prepare.PrepareService, jobID string) {
 	flusher, ok := w.(http.Flusher)
 	if !ok {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
-	w.Header().Set("Content-Type", "application/x-ndjson")
+	sse := acceptsEventStream(r.Header.Get("Accept"))
+	lastSeq, ok := eventAfterSeq(w, r)
+	if !ok {
+		return
+	}
+	if sse && strings.TrimSpace(r.Header.Get("Last-Event-ID")) != "" {
+		resume, err := parseLastEventID(r.Header.Get("Last-Event-ID"))
+		if err != nil {
+			_ = writeErrorResponse(w, "invalid_argument", "invalid Last-Event-ID", err.Error(), http.StatusBadRequest)
+			return
+		}
+		lastSeq = resume
+	}
+	defer mgr.WatchEvents(jobID)()
 	enc := json.NewEncoder(w)
-	index := 0
+	headerWritten := false
 	for {
-		events, ok, done, err := mgr.EventsSince(jobID, index)
+		events, ok, done, err := mgr.EventsAfterSeq(jobID, lastSeq)
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
+			if !headerWritten {
+				w.WriteHeader(http.StatusInternalServerError)
+			}
 			return
 		}
 		if !ok {
 			http.NotFound(w, r)
 			return
 		}
+		if !headerWritten {
+			if sse {
+				w.Header().Set("Content-Type", "text/event-stream")
+				w.Header().Set("Cache-Control", "no-cache")
+			} else {
+				w.Header().Set("Content-Type", "application/x-ndjson")
+			}
+			headerWritten = true
+		}
 		for _, event := range events {
-			_ = enc.Encode(event)
+			if sse {
+				_ = writeSSEEvent(w, strconv.FormatInt(event.Seq, 10), event.Type, event)
+			} else {
+				_ = enc.Encode(event)
+			}
 			flusher.Flush()
-			index++
+			lastSeq = event.Seq
 		}
 		if done {
+			if sse {
+				status, _ := mgr.Get(jobID)
+				_ = writeSSEEvent(w, "", "end", map[string]string{"job_id": jobID, "status": status.Status})
+				flusher.Flush()
+			}
 			return
 		}
 		if len(events) == 0 {
-			if err := mgr.WaitForEvent(r.Context(), jobID, index); err != nil {
+			if err := mgr.WaitForEvent(r.Context(), jobID, lastSeq); err != nil {
 				return
 			}
 		}
 	}
 }
+
+// acceptsEventStream reports whether the Accept header selects SSE. The first
+// supported media type wins; NDJSON stays the default.
+func acceptsEventStream(accept string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType := strings.ToLower(strings.TrimSpace(strings.SplitN(part, ";", 2)[0]))
+		switch mediaType {
+		case "text/event-stream":
+			return true
+		case "application/x-ndjson":
+			return false
+		}
+	}
+	return false
+}
+
+// eventAfterSeq reads the optional after_seq query parameter. Seqs survive
+// removal of older events, where offsets shift. It writes the error response
+// and returns false when the stream must not start.
+func eventAfterSeq(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	raw := strings.TrimSpace(r.URL.Query().Get("after_seq"))
+	if raw == "" {
+		return 0, true
+	}
+	seq, err := parseEventSeq(raw)
+	if err != nil {
+		_ = writeErrorResponse(w, "invalid_argument", "invalid after_seq", err.Error(), http.StatusBadRequest)
+		return 0, false
+	}
+	return seq, true
+}
+
+// parseLastEventID reads an SSE Last-Event-ID, which is the seq of the last
+// event the client received.
+func parseLastEventID(value string) (int64, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+	return parseEventSeq(value)
+}
+
+func parseEventSeq(value string) (int64, error) {
+	seq, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if seq < 0 {
+		return 0, fmt.Errorf("must not be negative")
+	}
+	return seq, nil
+}
+
+func writeSSEEvent(w http.ResponseWriter, id string, eventType string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	var b strings.Builder
+	if id != "" {
+		b.WriteString("id: " + id + "\n")
+	}
+	if eventType != "" {
+		b.WriteString("event: " + eventType + "\n")
+	}
+	b.WriteString("data: ")
+	b.Write(data)
+	b.WriteString("\n\n")
+	_, err = io.WriteString(w, b.String())
+	return err
+}
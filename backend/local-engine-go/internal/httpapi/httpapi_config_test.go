@@ -214,3 +214,84 @@ func (f *fakeConfig) Remove(path string) (any, error) {
 func (f *fakeConfig) Schema() any {
 	return f.schema
 }
+
+func TestConfigPathPutGetDelete(t *testing.T) {
+	cfg, err := config.NewManager(config.Options{StateStoreRoot: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	handler := NewHandler(Options{AuthToken: "secret", Config: cfg})
+	do := func(method string, path string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		return resp
+	}
+	decode := func(resp *httptest.ResponseRecorder) config.Value {
+		var payload config.Value
+		if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		return payload
+	}
+
+	resp := do(http.MethodPut, "/v1/config/orchestrator.jobs.maxIdentical", `5`)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", resp.Code, resp.Body.String())
+	}
+	if payload := decode(resp); payload.Path != "orchestrator.jobs.maxIdentical" || payload.Value != float64(5) {
+		t.Fatalf("unexpected payload: %+v", payload)
+	}
+
+	resp = do(http.MethodGet, "/v1/config/orchestrator.jobs.maxIdentical", "")
+	if payload := decode(resp); resp.Code != http.StatusOK || payload.Value != float64(5) {
+		t.Fatalf("unexpected get: %d %+v", resp.Code, payload)
+	}
+
+	resp = do(http.MethodDelete, "/v1/config/orchestrator.jobs.maxIdentical", "")
+	if payload := decode(resp); resp.Code != http.StatusOK || payload.Value != float64(2) {
+		t.Fatalf("expected default after delete: %d %+v", resp.Code, payload)
+	}
+
+	resp = do(http.MethodGet, "/v1/config/orchestrator.jobs.maxIdentical?effective=false", "")
+	if resp.Code != http.StatusNotFound {
+		t.Fatalf("expected override to be gone, got %d", resp.Code)
+	}
+}
+
+func TestConfigPathPutRejectsUnknownPathAndTypeMismatch(t *testing.T) {
+	cfg := &fakeConfig{schema: config.DefaultSchema()}
+	handler := NewHandler(Options{AuthToken: "secret", Config: cfg})
+	cases := map[string]string{
+		"/v1/config/features.flag":                  `true`,
+		"/v1/config/log.level":                      `"verbose"`,
+		"/v1/config/orchestrator.jobs.maxIdentical": `"two"`,
+		"/v1/config/cache.capacity.highWatermark":   `2`,
+		"/v1/config/container.runtime":              `{bad`,
+	}
+	for path, body := range cases {
+		req := httptest.NewRequest(http.MethodPut, path, bytes.NewBufferString(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		if resp.Code != http.StatusBadRequest {
+			t.Fatalf("%s %s: expected 400, got %d", path, body, resp.Code)
+		}
+	}
+	if cfg.lastValue != nil {
+		t.Fatalf("expected no Set call, got %#v", cfg.lastValue)
+	}
+}
+
+func TestConfigPathSchemaRouteWins(t *testing.T) {
+	cfg := &fakeConfig{schema: map[string]any{"type": "object"}}
+	handler := NewHandler(Options{AuthToken: "secret", Config: cfg})
+	req := httptest.NewRequest(http.MethodGet, "/v1/config/schema", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+	if resp.Code != http.StatusOK || cfg.lastPath != "" {
+		t.Fatalf("expected schema response, got %d path=%q", resp.Code, cfg.lastPath)
+	}
+}
@@ -462,6 +462,63 @@ func TestListStatesIncludesParentID(t *testing.T) {
 	}
 }
 
+func TestGetStateIncludesAncestors(t *testing.T) {
+	server, cleanup := newDeleteTestServer(t, seedStateTree, fakeConnTracker{})
+	defer cleanup()
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/v1/states/state-child", nil)
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("get request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var detail stateDetail
+	if err := json.NewDecoder(resp.Body).Decode(&detail); err != nil {
+		t.Fatalf("decode state: %v", err)
+	}
+	if detail.StateID != "state-child" || detail.PrepareArgs != "args" {
+		t.Fatalf("unexpected state: %+v", detail.StateEntry)
+	}
+	if len(detail.Ancestors) != 1 || detail.Ancestors[0].StateID != "state-root" {
+		t.Fatalf("unexpected ancestors: %+v", detail.Ancestors)
+	}
+}
+
+func TestGetStateNotFound(t *testing.T) {
+	server, cleanup := newDeleteTestServer(t, seedEmptyData, fakeConnTracker{})
+	defer cleanup()
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/v1/states/missing", nil)
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("get request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", resp.StatusCode)
+	}
+	var body prepare.ErrorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	if body.Code != "not_found" || body.Details != "missing" {
+		t.Fatalf("unexpected error response: %+v", body)
+	}
+}
+
 func newDeleteTestServer(t *testing.T, seed func(*sql.DB) error, tracker conntrack.Tracker) (*httptest.Server, func()) {
 	t.Helper()
 	dir := t.TempDir()
@@ -540,3 +597,68 @@ func seedStateTree(db *sql.DB) error {
 func seedEmptyData(db *sql.DB) error {
 	return nil
 }
+
+func TestStatesGCDryRunReportsUnreferencedStates(t *testing.T) {
+	server, cleanup := newDeleteTestServer(t, seedStateTree, fakeConnTracker{})
+	defer cleanup()
+
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/v1/states/gc?dry_run=true&older_than=0s", nil)
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("gc request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var result deletion.PruneResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("decode result: %v", err)
+	}
+	if !result.DryRun || result.Outcome != deletion.OutcomeWouldDelete {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if len(result.StateIDs) != 0 {
+		t.Fatalf("expected referenced chain to be kept, got %+v", result.StateIDs)
+	}
+}
+
+func TestStatesGCRejectsInvalidParams(t *testing.T) {
+	server, cleanup := newDeleteTestServer(t, seedEmptyData, fakeConnTracker{})
+	defer cleanup()
+
+	for _, query := range []string{"dry_run=maybe", "older_than=soon", "older_than=-1h"} {
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/v1/states/gc?"+query, nil)
+		if err != nil {
+			t.Fatalf("new request: %v", err)
+		}
+		req.Header.Set("Authorization", "Bearer secret")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("gc request: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("query %s: expected 400, got %d", query, resp.StatusCode)
+		}
+	}
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/v1/states/gc", nil)
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("gc request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,157 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sqlrs/engine-local/internal/run"
+	"github.com/sqlrs/engine-local/internal/store"
+)
+
+const execInstanceID = "dddddddddddddddddddddddddddddddd"
+
+type execInstanceStore struct {
+	store.Store
+	entry store.InstanceEntry
+}
+
+func (s execInstanceStore) GetInstance(ctx context.Context, instanceID string) (store.InstanceEntry, bool, error) {
+	if instanceID != s.entry.InstanceID {
+		return store.InstanceEntry{}, false, nil
+	}
+	return s.entry, true, nil
+}
+
+func (s execInstanceStore) GetName(ctx context.Context, name string) (store.NameEntry, bool, error) {
+	return store.NameEntry{}, false, nil
+}
+
+func newInstanceExecServer(t *testing.T, mode string, runtime *fakeRunRuntime) *httptest.Server {
+	t.Helper()
+	return newRunServer(t, execInstanceStore{entry: store.InstanceEntry{
+		InstanceID: execInstanceID,
+		ImageID:    "image-1",
+		StateID:    "state-1",
+		RuntimeID:  strPtr("container-1"),
+		Mode:       mode,
+	}}, runtime)
+}
+
+func postInstanceExec(t *testing.T, server *httptest.Server, instanceID string, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/v1/instances/"+instanceID+"/exec", bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	return resp
+}
+
+func TestInstanceExecStreamsOutputAsLogEvents(t *testing.T) {
+	server := newInstanceExecServer(t, "persistent", &fakeRunRuntime{output: "line-1\nline-2\n"})
+	defer server.Close()
+
+	resp := postInstanceExec(t, server, execInstanceID, `{"sql":"select 1"}`)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Fatalf("unexpected content type: %q", ct)
+	}
+	var types, data []string
+	dec := json.NewDecoder(resp.Body)
+	for dec.More() {
+		var evt run.Event
+		if err := dec.Decode(&evt); err != nil {
+			t.Fatalf("decode event: %v", err)
+		}
+		types = append(types, evt.Type)
+		if evt.Type == "log" {
+			data = append(data, evt.Data)
+		}
+	}
+	if len(types) != 4 || types[0] != "start" || types[3] != "exit" {
+		t.Fatalf("unexpected events: %v", types)
+	}
+	if len(data) != 2 || data[0] != "line-1" || data[1] != "line-2" {
+		t.Fatalf("unexpected log data: %v", data)
+	}
+}
+
+func TestInstanceExecReportsFailureAfterStart(t *testing.T) {
+	server := newInstanceExecServer(t, "persistent", &fakeRunRuntime{output: "ERROR:  boom\n", err: errors.New("exit status 1")})
+	defer server.Close()
+
+	resp := postInstanceExec(t, server, execInstanceID, `{"sql":"select boom"}`)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	var events []run.Event
+	dec := json.NewDecoder(resp.Body)
+	for dec.More() {
+		var evt run.Event
+		if err := dec.Decode(&evt); err != nil {
+			t.Fatalf("decode event: %v", err)
+		}
+		events = append(events, evt)
+	}
+	if len(events) != 3 || events[0].Type != "start" || events[1].Data != "ERROR:  boom" || events[2].Type != "error" || events[2].Error == nil {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+}
+
+func TestInstanceExecErrors(t *testing.T) {
+	cases := []struct {
+		name   string
+		mode   string
+		id     string
+		body   string
+		status int
+	}{
+		{name: "ephemeral", mode: "ephemeral", id: execInstanceID, body: `{"sql":"select 1"}`, status: http.StatusConflict},
+		{name: "missing", mode: "persistent", id: "eeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee", body: `{"sql":"select 1"}`, status: http.StatusNotFound},
+		{name: "empty", mode: "persistent", id: execInstanceID, body: `{}`, status: http.StatusBadRequest},
+		{name: "invalid json", mode: "persistent", id: execInstanceID, body: `{`, status: http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := newInstanceExecServer(t, tc.mode, &fakeRunRuntime{})
+			defer server.Close()
+			resp := postInstanceExec(t, server, tc.id, tc.body)
+			resp.Body.Close()
+			if resp.StatusCode != tc.status {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tc.status)
+			}
+		})
+	}
+}
+
+func TestInstanceExecMethodNotAllowed(t *testing.T) {
+	server := newInstanceExecServer(t, "persistent", &fakeRunRuntime{})
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/v1/instances/"+execInstanceID+"/exec", nil)
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,217 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sqlrs/engine-local/internal/registry"
+	"github.com/sqlrs/engine-local/internal/store"
+	"github.com/sqlrs/engine-local/internal/store/sqlite"
+)
+
+type fakeLivenessRuntime struct {
+	fakeRunRuntime
+	running map[string]bool
+	err     error
+}
+
+func (f *fakeLivenessRuntime) ContainerRunning(ctx context.Context, id string) (bool, error) {
+	if f.err != nil {
+		return false, f.err
+	}
+	return f.running[id], nil
+}
+
+func newInstancesTestServer(t *testing.T, rt *fakeLivenessRuntime) *httptest.Server {
+	t.Helper()
+	st, err := sqlite.Open(filepath.Join(t.TempDir(), "state.db"))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(func() { _ = st.Close() })
+	createState(t, st, "state-1")
+	createState(t, st, "state-2")
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	for _, inst := range []struct{ id, stateID, runtimeID string }{
+		{"aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", "state-1", "container-live"},
+		{"bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb", "state-2", "container-gone"},
+	} {
+		runtimeID := inst.runtimeID
+		if err := st.CreateInstance(context.Background(), store.InstanceCreate{
+			InstanceID: inst.id,
+			StateID:    inst.stateID,
+			ImageID:    "image-1",
+			CreatedAt:  now,
+			RuntimeID:  &runtimeID,
+			RuntimeDir: strPtr("/tmp/runtime-" + inst.id[:4]),
+		}); err != nil {
+			t.Fatalf("CreateInstance: %v", err)
+		}
+	}
+	server := httptest.NewServer(NewHandler(Options{
+		Version:    "test",
+		InstanceID: "instance",
+		AuthToken:  "secret",
+		Registry:   registry.New(st),
+		Runtime:    rt,
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func getInstances(t *testing.T, url string) []store.InstanceEntry {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("Accept", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("instances request: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var entries []store.InstanceEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		t.Fatalf("decode instances: %v", err)
+	}
+	return entries
+}
+
+func TestInstancesMarksStaleContainers(t *testing.T) {
+	server := newInstancesTestServer(t, &fakeLivenessRuntime{running: map[string]bool{"container-live": true}})
+
+	entries := getInstances(t, server.URL+"/v1/instances")
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 instances, got %+v", entries)
+	}
+	statuses := map[string]string{}
+	for _, entry := range entries {
+		statuses[entry.InstanceID] = entry.Status
+		if entry.RuntimeDir == nil || *entry.RuntimeDir == "" || entry.CreatedAt == "" {
+			t.Fatalf("expected runtime_dir and created_at, got %+v", entry)
+		}
+	}
+	if statuses["bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"] != store.InstanceStatusStale {
+		t.Fatalf("expected stale instance, got %+v", statuses)
+	}
+	if statuses["aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"] == store.InstanceStatusStale {
+		t.Fatalf("expected live instance not to be stale, got %+v", statuses)
+	}
+}
+
+func TestInstancesFilterByStateIDParam(t *testing.T) {
+	server := newInstancesTestServer(t, &fakeLivenessRuntime{})
+
+	entries := getInstances(t, server.URL+"/v1/instances?state_id=state-2")
+	if len(entries) != 1 || entries[0].InstanceID != "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb" {
+		t.Fatalf("unexpected filtered instances: %+v", entries)
+	}
+}
+
+func TestInstanceDetailMarksStaleContainer(t *testing.T) {
+	server := newInstancesTestServer(t, &fakeLivenessRuntime{})
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/v1/instances/bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb", nil)
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("instance request: %v", err)
+	}
+	defer resp.Body.Close()
+	var entry store.InstanceEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entry); err != nil {
+		t.Fatalf("decode instance: %v", err)
+	}
+	if entry.Status != store.InstanceStatusStale {
+		t.Fatalf("expected stale status, got %+v", entry)
+	}
+}
+
+func TestInstancesKeepStatusWhenLivenessFails(t *testing.T) {
+	server := newInstancesTestServer(t, &fakeLivenessRuntime{err: errors.New("docker down")})
+
+	for _, entry := range getInstances(t, server.URL+"/v1/instances") {
+		if entry.Status == store.InstanceStatusStale {
+			t.Fatalf("expected stored status on liveness error, got %+v", entry)
+		}
+	}
+}
+
+func TestInstancesAndStatesFilterByNamespace(t *testing.T) {
+	st, err := sqlite.Open(filepath.Join(t.TempDir(), "state.db"))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(func() { _ = st.Close() })
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	for _, state := range []struct{ id, namespace string }{{"state-default", ""}, {"state-team", "team-a"}} {
+		if err := st.CreateState(context.Background(), store.StateCreate{
+			StateID:               state.id,
+			ImageID:               "image-1",
+			PrepareKind:           "psql",
+			PrepareArgsNormalized: "-c select 1",
+			CreatedAt:             now,
+			StateFingerprint:      "fp-" + state.id,
+			Namespace:             state.namespace,
+		}); err != nil {
+			t.Fatalf("CreateState: %v", err)
+		}
+	}
+	for _, inst := range []struct{ id, stateID string }{
+		{"aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", "state-default"},
+		{"bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb", "state-team"},
+	} {
+		if err := st.CreateInstance(context.Background(), store.InstanceCreate{
+			InstanceID: inst.id,
+			StateID:    inst.stateID,
+			ImageID:    "image-1",
+			CreatedAt:  now,
+		}); err != nil {
+			t.Fatalf("CreateInstance: %v", err)
+		}
+	}
+	server := httptest.NewServer(NewHandler(Options{
+		Version:    "test",
+		InstanceID: "instance",
+		AuthToken:  "secret",
+		Registry:   registry.New(st),
+	}))
+	t.Cleanup(server.Close)
+
+	entries := getInstances(t, server.URL+"/v1/instances?namespace=team-a")
+	if len(entries) != 1 || entries[0].StateID != "state-team" {
+		t.Fatalf("unexpected namespaced instances: %+v", entries)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/v1/states?namespace=team-a", nil)
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("states request: %v", err)
+	}
+	defer resp.Body.Close()
+	var states []store.StateEntry
+	if err := json.NewDecoder(resp.Body).Decode(&states); err != nil {
+		t.Fatalf("decode states: %v", err)
+	}
+	if len(states) != 1 || states[0].StateID != "state-team" || states[0].Namespace != "team-a" {
+		t.Fatalf("unexpected namespaced states: %+v", states)
+	}
+}
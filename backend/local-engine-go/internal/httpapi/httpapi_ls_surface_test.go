@@ -3,6 +3,7 @@ package httpapi
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"path/filepath"
@@ -291,3 +292,52 @@ func mustMarshalPrepareRequest(t *testing.T, req prepare.Request) string {
 	}
 	return string(data)
 }
+
+func TestPrepareJobsListFiltersByLabel(t *testing.T) {
+	server, cleanup, queueStore := newListSurfaceServer(t)
+	defer cleanup()
+
+	for i, labels := range []string{`{"pr":"1234"}`, `{"pr":"99"}`} {
+		if err := queueStore.CreateJob(context.Background(), queue.JobRecord{
+			JobID:       fmt.Sprintf("job-%d", i+1),
+			Status:      prepare.StatusSucceeded,
+			PrepareKind: "psql",
+			ImageID:     "postgres:17",
+			LabelsJSON:  strPtr(labels),
+			CreatedAt:   "2026-03-10T00:00:00Z",
+		}); err != nil {
+			t.Fatalf("CreateJob: %v", err)
+		}
+	}
+
+	get := func(query string) (*http.Response, []prepare.JobEntry) {
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/v1/prepare-jobs"+query, nil)
+		if err != nil {
+			t.Fatalf("NewRequest: %v", err)
+		}
+		req.Header.Set("Authorization", "Bearer secret")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Do: %v", err)
+		}
+		defer resp.Body.Close()
+		var entries []prepare.JobEntry
+		if resp.StatusCode == http.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+		}
+		return resp, entries
+	}
+
+	resp, entries := get("?label=pr%3D1234")
+	if resp.StatusCode != http.StatusOK || len(entries) != 1 || entries[0].JobID != "job-1" || entries[0].Labels["pr"] != "1234" {
+		t.Fatalf("unexpected labeled jobs: %d %+v", resp.StatusCode, entries)
+	}
+	if _, entries := get("?label=pr%3D1234&job=job-2"); len(entries) != 0 {
+		t.Fatalf("expected job prefix to narrow label results, got %+v", entries)
+	}
+	if resp, _ := get("?label=pr"); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for malformed label, got %d", resp.StatusCode)
+	}
+}
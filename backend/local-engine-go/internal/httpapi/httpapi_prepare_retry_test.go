@@ -0,0 +1,65 @@
+package httpapi
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/sqlrs/engine-local/internal/prepare"
+)
+
+func TestPrepareJobRetryErrors(t *testing.T) {
+	server, cleanup := newTestServer(t)
+	defer cleanup()
+
+	jobID := submitPlanOnlyJob(t, server.URL, "secret")
+	if err := waitForPrepareCompletion(server.URL, "/v1/prepare-jobs/"+jobID, "secret"); err != nil {
+		t.Fatalf("wait for completion: %v", err)
+	}
+
+	cases := []struct {
+		method string
+		path   string
+		want   int
+	}{
+		{method: http.MethodGet, path: "/v1/prepare-jobs/" + jobID + "/retry", want: http.StatusMethodNotAllowed},
+		{method: http.MethodPost, path: "/v1/prepare-jobs/job/extra/retry", want: http.StatusNotFound},
+		{method: http.MethodPost, path: "/v1/prepare-jobs/missing/retry", want: http.StatusNotFound},
+		{method: http.MethodPost, path: "/v1/prepare-jobs/" + jobID + "/retry", want: http.StatusConflict},
+	}
+	for _, tc := range cases {
+		req, err := http.NewRequest(tc.method, server.URL+tc.path, nil)
+		if err != nil {
+			t.Fatalf("new request: %v", err)
+		}
+		req.Header.Set("Authorization", "Bearer secret")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.want {
+			t.Fatalf("%s %s: expected %d, got %d", tc.method, tc.path, tc.want, resp.StatusCode)
+		}
+	}
+}
+
+func TestPrepareErrorStatus(t *testing.T) {
+	cases := []struct {
+		err  error
+		want int
+	}{
+		{err: prepare.ValidationError{Code: "invalid_argument"}, want: http.StatusBadRequest},
+		{err: &prepare.ValidationError{Code: "invalid_argument"}, want: http.StatusBadRequest},
+		{err: prepare.ConflictError{Code: "conflict"}, want: http.StatusConflict},
+		{err: prepare.UnavailableError{Code: "unavailable"}, want: http.StatusServiceUnavailable},
+		{err: prepare.ResourceExhaustedError{Code: "resource_exhausted"}, want: http.StatusTooManyRequests},
+		{err: prepare.PermissionDeniedError{Code: "permission_denied"}, want: http.StatusForbidden},
+		{err: errors.New("boom"), want: http.StatusInternalServerError},
+	}
+	for _, tc := range cases {
+		if got := prepareErrorStatus(tc.err); got != tc.want {
+			t.Fatalf("prepareErrorStatus(%T) = %d, want %d", tc.err, got, tc.want)
+		}
+	}
+}
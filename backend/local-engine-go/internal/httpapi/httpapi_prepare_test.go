@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -93,6 +94,44 @@ func TestPrepareJobsRejectsInvalidKind(t *testing.T) {
 	}
 }
 
+func TestPrepareJobsIdempotencyKeyConflict(t *testing.T) {
+	server, cleanup := newTestServer(t)
+	defer cleanup()
+
+	post := func(payload string) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/v1/prepare-jobs", strings.NewReader(payload))
+		if err != nil {
+			t.Fatalf("new request: %v", err)
+		}
+		req.Header.Set("Authorization", "Bearer secret")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request: %v", err)
+		}
+		return resp
+	}
+
+	first := post(`{"prepare_kind":"psql","image_id":"image-1","psql_args":["-c","select 1"],"plan_only":true,"idempotency_key":"ci-42"}`)
+	first.Body.Close()
+	if first.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", first.StatusCode)
+	}
+
+	resp := post(`{"prepare_kind":"psql","image_id":"image-1","psql_args":["-c","select 2"],"plan_only":true,"idempotency_key":"ci-42"}`)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Fatalf("expected 409, got %d", resp.StatusCode)
+	}
+	var body prepare.ErrorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	if body.Code != "invalid_argument" {
+		t.Fatalf("unexpected error body: %+v", body)
+	}
+}
+
 func TestPrepareJobsInternalError(t *testing.T) {
 	dir := t.TempDir()
 	st, err := sqlite.Open(filepath.Join(dir, "state.db"))
@@ -140,6 +179,50 @@ func TestPrepareJobsInternalError(t *testing.T) {
 	}
 }
 
+func TestPrepareJobsUnavailableWhileDraining(t *testing.T) {
+	dir := t.TempDir()
+	st, err := sqlite.Open(filepath.Join(dir, "state.db"))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer st.Close()
+
+	prep := newPrepareManager(t, st, mustOpenQueue(t, filepath.Join(dir, "state.db")))
+	if err := prep.Drain(context.Background()); err != nil {
+		t.Fatalf("Drain: %v", err)
+	}
+	handler := NewHandler(Options{
+		Version:    "test",
+		InstanceID: "instance",
+		AuthToken:  "secret",
+		Registry:   registry.New(st),
+		Prepare:    prep,
+	})
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/v1/prepare-jobs", strings.NewReader(`{"prepare_kind":"psql","image_id":"image-1","psql_args":["-c","select 1"]}`))
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", resp.StatusCode)
+	}
+	var body prepare.ErrorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	if body.Code != "unavailable" {
+		t.Fatalf("unexpected error body: %+v", body)
+	}
+}
+
 func TestPrepareJobStatusMethodNotAllowed(t *testing.T) {
 	server, cleanup := newTestServer(t)
 	defer cleanup()
@@ -454,6 +537,20 @@ func TestPrepareJobsListAndFilter(t *testing.T) {
 	}
 }
 
+func TestFilterJobsByNamespace(t *testing.T) {
+	jobs := []prepare.JobEntry{
+		{JobID: "job-1"},
+		{JobID: "job-2", Namespace: "team-a"},
+	}
+	if got := filterJobsByNamespace(jobs, ""); len(got) != 2 {
+		t.Fatalf("expected all jobs without filter, got %+v", got)
+	}
+	got := filterJobsByNamespace(jobs, "team-a")
+	if len(got) != 1 || got[0].JobID != "job-2" {
+		t.Fatalf("unexpected namespace filter result: %+v", got)
+	}
+}
+
 func TestPrepareJobsDelete(t *testing.T) {
 	server, cleanup := newTestServer(t)
 	defer cleanup()
@@ -895,3 +992,267 @@ type httpStatusError struct {
 func (e *httpStatusError) Error() string {
 	return "unexpected status"
 }
+
+func newSSETestJob(t *testing.T) *prepare.PrepareService {
+	t.Helper()
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "state.db")
+	st, err := sqlite.Open(dbPath)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(func() { _ = st.Close() })
+	queueStore := mustOpenQueue(t, dbPath)
+	t.Cleanup(func() { _ = queueStore.Close() })
+	prep := newPrepareManager(t, st, queueStore)
+
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	if err := queueStore.CreateJob(context.Background(), queue.JobRecord{
+		JobID:       "job-sse",
+		Status:      prepare.StatusSucceeded,
+		PrepareKind: "psql",
+		ImageID:     "image-1",
+		CreatedAt:   now,
+	}); err != nil {
+		t.Fatalf("create job: %v", err)
+	}
+	for _, status := range []string{prepare.StatusQueued, prepare.StatusRunning, prepare.StatusSucceeded} {
+		status := status
+		if _, err := queueStore.AppendEvent(context.Background(), queue.EventRecord{
+			JobID:  "job-sse",
+			Type:   "status",
+			Ts:     now,
+			Status: &status,
+		}); err != nil {
+			t.Fatalf("append event: %v", err)
+		}
+	}
+	return prep
+}
+
+func TestPrepareEventsServerSentEvents(t *testing.T) {
+	prep := newSSETestJob(t)
+
+	req := httptest.NewRequest(http.MethodGet, "http://example/v1/prepare-jobs/job-sse/events", nil)
+	req.Header.Set("Accept", "text/event-stream")
+	resp := httptest.NewRecorder()
+	streamPrepareEvents(resp, req, prep, "job-sse")
+
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.Code)
+	}
+	if got := resp.Header().Get("Content-Type"); got != "text/event-stream" {
+		t.Fatalf("unexpected content type: %q", got)
+	}
+	frames := strings.Split(strings.TrimSuffix(resp.Body.String(), "\n\n"), "\n\n")
+	if len(frames) != 4 {
+		t.Fatalf("expected 3 events and an end frame, got %q", resp.Body.String())
+	}
+	if !strings.HasPrefix(frames[0], "id: 1\nevent: status\ndata: {") || !strings.Contains(frames[0], `"status":"queued"`) {
+		t.Fatalf("unexpected first frame: %q", frames[0])
+	}
+	if !strings.HasPrefix(frames[2], "id: 3\nevent: status\n") {
+		t.Fatalf("unexpected third frame: %q", frames[2])
+	}
+	if frames[3] != `event: end`+"\n"+`data: {"job_id":"job-sse","status":"succeeded"}` {
+		t.Fatalf("unexpected end frame: %q", frames[3])
+	}
+}
+
+func TestPrepareEventsServerSentEventsResumeFromLastEventID(t *testing.T) {
+	prep := newSSETestJob(t)
+
+	req := httptest.NewRequest(http.MethodGet, "http://example/v1/prepare-jobs/job-sse/events", nil)
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Last-Event-ID", "1")
+	resp := httptest.NewRecorder()
+	streamPrepareEvents(resp, req, prep, "job-sse")
+
+	body := resp.Body.String()
+	if strings.Contains(body, "id: 1\n") {
+		t.Fatalf("expected delivered events to be skipped, got %q", body)
+	}
+	if !strings.HasPrefix(body, "id: 2\n") || !strings.Contains(body, "id: 3\n") || !strings.Contains(body, "event: end\n") {
+		t.Fatalf("unexpected resumed stream: %q", body)
+	}
+}
+
+func TestPrepareEventsServerSentEventsRejectsInvalidLastEventID(t *testing.T) {
+	prep := newSSETestJob(t)
+
+	for _, value := range []string{"abc", "-1"} {
+		req := httptest.NewRequest(http.MethodGet, "http://example/v1/prepare-jobs/job-sse/events", nil)
+		req.Header.Set("Accept", "text/event-stream")
+		req.Header.Set("Last-Event-ID", value)
+		resp := httptest.NewRecorder()
+		streamPrepareEvents(resp, req, prep, "job-sse")
+		if resp.Code != http.StatusBadRequest {
+			t.Fatalf("Last-Event-ID %q: expected 400, got %d", value, resp.Code)
+		}
+	}
+}
+
+func TestPrepareEventsResumeAfterSeq(t *testing.T) {
+	prep := newSSETestJob(t)
+
+	req := httptest.NewRequest(http.MethodGet, "http://example/v1/prepare-jobs/job-sse/events", nil)
+	resp := httptest.NewRecorder()
+	streamPrepareEvents(resp, req, prep, "job-sse")
+	var first prepare.Event
+	if err := json.Unmarshal([]byte(strings.SplitN(resp.Body.String(), "\n", 2)[0]), &first); err != nil {
+		t.Fatalf("decode first event: %v", err)
+	}
+	if first.Seq <= 0 {
+		t.Fatalf("expected events to carry seq, got %+v", first)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, fmt.Sprintf("http://example/v1/prepare-jobs/job-sse/events?after_seq=%d", first.Seq), nil)
+	resp = httptest.NewRecorder()
+	streamPrepareEvents(resp, req, prep, "job-sse")
+	lines := strings.Split(strings.TrimSpace(resp.Body.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected the 2 events after seq %d, got %q", first.Seq, resp.Body.String())
+	}
+	for _, line := range lines {
+		var event prepare.Event
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("decode event: %v", err)
+		}
+		if event.Seq <= first.Seq {
+			t.Fatalf("expected only events after seq %d, got %+v", first.Seq, event)
+		}
+	}
+}
+
+// tailingQueueStore emits one more event on every event read, as a job
+// logging steadily while a client tails it, and counts the rows each read
+// visits: an offset read steps over every earlier row, a seq read does not.
+type tailingQueueStore struct {
+	queue.Store
+	jobID   string
+	total   int
+	emitted int
+	visited int
+}
+
+func (s *tailingQueueStore) ListEventsSince(ctx context.Context, jobID string, offset int) ([]queue.EventRecord, error) {
+	s.emit(ctx)
+	events, err := s.Store.ListEventsSince(ctx, jobID, offset)
+	s.visited += offset + len(events)
+	return events, err
+}
+
+func (s *tailingQueueStore) ListEventsAfterSeq(ctx context.Context, jobID string, seq int64) ([]queue.EventRecord, error) {
+	s.emit(ctx)
+	events, err := s.Store.ListEventsAfterSeq(ctx, jobID, seq)
+	s.visited += len(events)
+	return events, err
+}
+
+func (s *tailingQueueStore) emit(ctx context.Context) {
+	if s.emitted >= s.total {
+		return
+	}
+	s.emitted++
+	message := fmt.Sprintf("log line %d", s.emitted)
+	_, _ = s.Store.AppendEvent(ctx, queue.EventRecord{JobID: s.jobID, Type: "log", Ts: time.Now().UTC().Format(time.RFC3339Nano), Message: &message})
+	if s.emitted == s.total {
+		status := prepare.StatusSucceeded
+		_ = s.Store.UpdateJob(ctx, s.jobID, queue.JobUpdate{Status: &status})
+	}
+}
+
+func tailPrepareEvents(t *testing.T, total int) (int, int) {
+	t.Helper()
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "state.db")
+	st, err := sqlite.Open(dbPath)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(func() { _ = st.Close() })
+	queueStore := &tailingQueueStore{Store: mustOpenQueue(t, dbPath), jobID: "job-tail", total: total}
+	prep := newPrepareManager(t, st, queueStore)
+	if err := queueStore.CreateJob(context.Background(), queue.JobRecord{
+		JobID:       "job-tail",
+		Status:      prepare.StatusRunning,
+		PrepareKind: "psql",
+		ImageID:     "image-1",
+		CreatedAt:   time.Now().UTC().Format(time.RFC3339Nano),
+	}); err != nil {
+		t.Fatalf("create job: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "http://example/v1/prepare-jobs/job-tail/events", nil)
+	resp := httptest.NewRecorder()
+	streamPrepareEvents(resp, req, prep, "job-tail")
+	if lines := strings.Count(resp.Body.String(), "\n"); lines != total {
+		t.Fatalf("expected %d events, got %d", total, lines)
+	}
+	return resp.Body.Len(), queueStore.visited
+}
+
+func TestPrepareEventsTailReadsOnlyNewEvents(t *testing.T) {
+	smallBytes, smallVisited := tailPrepareEvents(t, 200)
+	largeBytes, largeVisited := tailPrepareEvents(t, 400)
+
+	if smallVisited != 200 || largeVisited != 400 {
+		t.Fatalf("expected each event to be read once, visited %d and %d rows", smallVisited, largeVisited)
+	}
+	if largeBytes > smallBytes*21/10 {
+		t.Fatalf("expected serialized bytes to grow linearly, got %d for 200 events and %d for 400", smallBytes, largeBytes)
+	}
+}
+
+func TestPrepareEventsRejectsInvalidAfterSeq(t *testing.T) {
+	prep := newSSETestJob(t)
+
+	cases := map[string]int{
+		"job-sse?after_seq=abc": http.StatusBadRequest,
+		"job-sse?after_seq=-1":  http.StatusBadRequest,
+		"missing?after_seq=1":   http.StatusNotFound,
+	}
+	for target, want := range cases {
+		jobID := strings.SplitN(target, "?", 2)[0]
+		req := httptest.NewRequest(http.MethodGet, "http://example/v1/prepare-jobs/"+target, nil)
+		resp := httptest.NewRecorder()
+		streamPrepareEvents(resp, req, prep, jobID)
+		if resp.Code != want {
+			t.Fatalf("%s: expected %d, got %d", target, want, resp.Code)
+		}
+	}
+}
+
+func TestPrepareEventsDefaultsToNDJSON(t *testing.T) {
+	prep := newSSETestJob(t)
+
+	req := httptest.NewRequest(http.MethodGet, "http://example/v1/prepare-jobs/job-sse/events", nil)
+	req.Header.Set("Last-Event-ID", "1")
+	resp := httptest.NewRecorder()
+	streamPrepareEvents(resp, req, prep, "job-sse")
+
+	if got := resp.Header().Get("Content-Type"); got != "application/x-ndjson" {
+		t.Fatalf("unexpected content type: %q", got)
+	}
+	lines := strings.Split(strings.TrimSpace(resp.Body.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected all 3 NDJSON events, got %q", resp.Body.String())
+	}
+}
+
+func TestAcceptsEventStream(t *testing.T) {
+	cases := map[string]bool{
+		"":                                 false,
+		"*/*":                              false,
+		"application/x-ndjson":             false,
+		"text/event-stream":                true,
+		"Text/Event-Stream; charset=utf-8": true,
+		"application/x-ndjson, text/event-stream": false,
+		"text/html, text/event-stream;q=0.9":      true,
+	}
+	for accept, want := range cases {
+		if got := acceptsEventStream(accept); got != want {
+			t.Fatalf("acceptsEventStream(%q) = %v, want %v", accept, got, want)
+		}
+	}
+}
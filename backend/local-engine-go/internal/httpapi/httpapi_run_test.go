@@ -34,10 +34,18 @@ func (f *fakeRunRuntime) InitBase(ctx context.Context, imageID string, dataDir s
 	return nil
 }
 
-func (f *fakeRunRuntime) ResolveImage(ctx context.Context, imageID string) (string, error) {
+func (f *fakeRunRuntime) ResolveImage(ctx context.Context, imageID string, platform string) (string, error) {
 	return imageID, nil
 }
 
+func (*fakeRunRuntime) Build(ctx context.Context, req engineRuntime.BuildRequest) (string, error) {
+	return "sha256:built", nil
+}
+
+func (*fakeRunRuntime) HasImageLocally(ctx context.Context, ref string) (string, bool, error) {
+	return ref, true, nil
+}
+
 func (f *fakeRunRuntime) Start(ctx context.Context, req engineRuntime.StartRequest) (engineRuntime.Instance, error) {
 	f.startCalls = append(f.startCalls, req)
 	if f.startErr != nil {
@@ -69,6 +77,14 @@ func (f *fakeRunRuntime) WaitForReady(ctx context.Context, id string, timeout ti
 	return nil
 }
 
+func (f *fakeRunRuntime) Ping(ctx context.Context) (string, error) {
+	return "test", nil
+}
+
+func (f *fakeRunRuntime) ListManaged(ctx context.Context) ([]engineRuntime.ManagedContainer, error) {
+	return nil, nil
+}
+
 func newRunServer(t *testing.T, st store.Store, runtime engineRuntime.Runtime) *httptest.Server {
 	t.Helper()
 	reg := registry.New(st)
@@ -6,6 +6,7 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"path/filepath"
 	"strings"
 	"testing"
@@ -252,6 +253,124 @@ func TestNameDetailNotFound(t *testing.T) {
 	}
 }
 
+func TestStateHeadAndLookup(t *testing.T) {
+	server, cleanup := newTestServer(t)
+	defer cleanup()
+
+	do := func(method, path, body string) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("new request: %v", err)
+		}
+		req.Header.Set("Authorization", "Bearer secret")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		return resp
+	}
+	for path, status := range map[string]int{"/v1/states/state-1": http.StatusOK, "/v1/states/missing": http.StatusNotFound} {
+		resp := do(http.MethodHead, path, "")
+		resp.Body.Close()
+		if resp.StatusCode != status {
+			t.Fatalf("HEAD %s: expected %d, got %d", path, status, resp.StatusCode)
+		}
+	}
+
+	scriptPath := filepath.Join(t.TempDir(), "prepare.sql")
+	if err := os.WriteFile(scriptPath, []byte("select 1;\n"), 0o600); err != nil {
+		t.Fatalf("write script: %v", err)
+	}
+	resp := do(http.MethodPost, "/v1/states/lookup", `{"prepare_kind":"psql","image_id":"image-1","psql_args":["-f","`+filepath.ToSlash(scriptPath)+`"]}`)
+	var payload map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		t.Fatalf("decode lookup: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || payload["cached"] != false || payload["state_id"] == "" || payload["reason_code"] != "no_matching_state" {
+		t.Fatalf("unexpected lookup response %d %+v", resp.StatusCode, payload)
+	}
+
+	for _, tc := range []struct {
+		path   string
+		body   string
+		status int
+	}{
+		{path: "/v1/states/lookup", body: `{`, status: http.StatusBadRequest},
+		{path: "/v1/states/lookup?cheap=maybe", body: `{}`, status: http.StatusBadRequest},
+		{path: "/v1/states/lookup", body: `{"prepare_kind":"bad"}`, status: http.StatusBadRequest},
+	} {
+		resp := do(http.MethodPost, tc.path, tc.body)
+		resp.Body.Close()
+		if resp.StatusCode != tc.status {
+			t.Fatalf("lookup %s %s: expected %d, got %d", tc.path, tc.body, tc.status, resp.StatusCode)
+		}
+	}
+}
+
+func TestStateTagResolvesByName(t *testing.T) {
+	server, cleanup := newTestServer(t)
+	defer cleanup()
+
+	tag := func(stateID, body string) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/v1/states/"+stateID+"/tags", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("new request: %v", err)
+		}
+		req.Header.Set("Authorization", "Bearer secret")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("tag request: %v", err)
+		}
+		return resp
+	}
+	for _, stateID := range []string{"state-1", "state-2"} {
+		resp := tag(stateID, `{"name":"my-seed"}`)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("tag %s: expected 200, got %d", stateID, resp.StatusCode)
+		}
+	}
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/v1/names/my-seed", nil)
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("names request: %v", err)
+	}
+	var entry store.NameEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entry); err != nil {
+		t.Fatalf("decode name: %v", err)
+	}
+	resp.Body.Close()
+	if entry.StateID != "state-2" || entry.InstanceID != nil || entry.Status != store.NameStatusActive {
+		t.Fatalf("expected the re-pointed tag, got %+v", entry)
+	}
+
+	cases := []struct {
+		stateID string
+		body    string
+		status  int
+	}{
+		{stateID: "state-1", body: `{"name":"Bad Name"}`, status: http.StatusBadRequest},
+		{stateID: "state-1", body: `{`, status: http.StatusBadRequest},
+		{stateID: "state-1", body: `{"name":"dev"}`, status: http.StatusConflict},
+		{stateID: "missing", body: `{"name":"other"}`, status: http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		resp := tag(tc.stateID, tc.body)
+		resp.Body.Close()
+		if resp.StatusCode != tc.status {
+			t.Fatalf("tag %s %s: expected %d, got %d", tc.stateID, tc.body, tc.status, resp.StatusCode)
+		}
+	}
+}
+
 func TestNameDetailMethodNotAllowed(t *testing.T) {
 	server, cleanup := newTestServer(t)
 	defer cleanup()
@@ -452,6 +571,41 @@ func TestStateDetailMethodNotAllowed(t *testing.T) {
 	}
 }
 
+func TestStateExportAndImportErrors(t *testing.T) {
+	server, cleanup := newTestServer(t)
+	defer cleanup()
+
+	cases := []struct {
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{method: http.MethodGet, path: "/v1/states/missing/export", want: http.StatusNotFound},
+		{method: http.MethodGet, path: "/v1/states/state-1/export", want: http.StatusConflict},
+		{method: http.MethodPost, path: "/v1/states/state-1/export", want: http.StatusMethodNotAllowed},
+		{method: http.MethodGet, path: "/v1/states/missing/schema", want: http.StatusNotFound},
+		{method: http.MethodPost, path: "/v1/states/state-1/schema", want: http.StatusMethodNotAllowed},
+		{method: http.MethodPost, path: "/v1/states/import", body: "not a tarball", want: http.StatusBadRequest},
+		{method: http.MethodGet, path: "/v1/states/import", want: http.StatusMethodNotAllowed},
+	}
+	for _, tc := range cases {
+		req, err := http.NewRequest(tc.method, server.URL+tc.path, strings.NewReader(tc.body))
+		if err != nil {
+			t.Fatalf("new request: %v", err)
+		}
+		req.Header.Set("Authorization", "Bearer secret")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("states request: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.want {
+			t.Fatalf("%s %s: expected %d, got %d", tc.method, tc.path, tc.want, resp.StatusCode)
+		}
+	}
+}
+
 func TestNamesFilterByInstance(t *testing.T) {
 	server, cleanup := newTestServer(t)
 	defer cleanup()
@@ -0,0 +1,125 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sqlrs/engine-local/internal/prepare"
+	"github.com/sqlrs/engine-local/internal/prepare/queue"
+	"github.com/sqlrs/engine-local/internal/registry"
+	"github.com/sqlrs/engine-local/internal/store"
+	"github.com/sqlrs/engine-local/internal/store/sqlite"
+)
+
+func TestPrepareJobsListETag(t *testing.T) {
+	server, _, queueStore := newETagTestServer(t)
+	createJob := func(jobID string) {
+		t.Helper()
+		if err := queueStore.CreateJob(context.Background(), queue.JobRecord{
+			JobID:       jobID,
+			Status:      prepare.StatusQueued,
+			PrepareKind: "psql",
+			ImageID:     "postgres:17",
+			CreatedAt:   "2026-03-10T00:00:00Z",
+		}); err != nil {
+			t.Fatalf("CreateJob: %v", err)
+		}
+	}
+	createJob("job-1")
+
+	etag := expectListETag(t, server.URL+"/v1/prepare-jobs", "", http.StatusOK)
+	expectListETag(t, server.URL+"/v1/prepare-jobs", etag, http.StatusNotModified)
+	if other := expectListETag(t, server.URL+"/v1/prepare-jobs?job=job-1", "", http.StatusOK); other == etag {
+		t.Fatalf("expected filters to change the etag")
+	}
+
+	createJob("job-2")
+	if next := expectListETag(t, server.URL+"/v1/prepare-jobs", etag, http.StatusOK); next == etag {
+		t.Fatalf("expected a new etag after a job write")
+	}
+}
+
+func TestStatesListETag(t *testing.T) {
+	server, st, _ := newETagTestServer(t)
+	createState := func(stateID string) {
+		t.Helper()
+		if err := st.CreateState(context.Background(), store.StateCreate{
+			StateID:               stateID,
+			StateFingerprint:      stateID,
+			ImageID:               "image-1",
+			PrepareKind:           "psql",
+			PrepareArgsNormalized: "-c select 1",
+			CreatedAt:             time.Now().UTC().Format(time.RFC3339Nano),
+		}); err != nil {
+			t.Fatalf("CreateState: %v", err)
+		}
+	}
+	createState("state-1")
+
+	etag := expectListETag(t, server.URL+"/v1/states", "", http.StatusOK)
+	expectListETag(t, server.URL+"/v1/states", etag, http.StatusNotModified)
+	expectListETag(t, server.URL+"/v1/states", "W/\"other\", "+etag, http.StatusNotModified)
+	expectListETag(t, server.URL+"/v1/states", "*", http.StatusNotModified)
+	expectListETag(t, server.URL+"/v1/states", "W/\"other\"", http.StatusOK)
+
+	createState("state-2")
+	if next := expectListETag(t, server.URL+"/v1/states", etag, http.StatusOK); next == etag {
+		t.Fatalf("expected a new etag after a state write")
+	}
+}
+
+func expectListETag(t *testing.T, url string, ifNoneMatch string, wantStatus int) string {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer secret")
+	if ifNoneMatch != "" {
+		req.Header.Set("If-None-Match", ifNoneMatch)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != wantStatus {
+		t.Fatalf("expected %d for %s, got %d", wantStatus, url, resp.StatusCode)
+	}
+	etag := resp.Header.Get("ETag")
+	if len(etag) < 4 || etag[:3] != `W/"` {
+		t.Fatalf("expected weak etag, got %q", etag)
+	}
+	return etag
+}
+
+func newETagTestServer(t *testing.T) (*httptest.Server, *sqlite.Store, *queue.SQLiteStore) {
+	t.Helper()
+	dbPath := filepath.Join(t.TempDir(), "state.db")
+	st, err := sqlite.Open(dbPath)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	queueStore, err := queue.Open(dbPath)
+	if err != nil {
+		st.Close()
+		t.Fatalf("open queue: %v", err)
+	}
+	server := httptest.NewServer(NewHandler(Options{
+		Version:    "test",
+		InstanceID: "instance",
+		AuthToken:  "secret",
+		Registry:   registry.New(st),
+		Prepare:    newPrepareManager(t, st, queueStore),
+	}))
+	t.Cleanup(func() {
+		server.Close()
+		_ = queueStore.Close()
+		_ = st.Close()
+	})
+	return server, st, queueStore
+}
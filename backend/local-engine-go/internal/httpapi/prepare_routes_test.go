@@ -1,6 +1,7 @@
 package httpapi
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -27,7 +28,14 @@ func TestPrepareRoutesRegisterExpectedHandlers(t *testing.T) {
 		{name: "missing job status", method: http.MethodGet, path: "/v1/prepare-jobs/missing", want: http.StatusNotFound},
 		{name: "missing job delete", method: http.MethodDelete, path: "/v1/prepare-jobs/missing", want: http.StatusNotFound},
 		{name: "missing job cancel", method: http.MethodPost, path: "/v1/prepare-jobs/missing/cancel", want: http.StatusNotFound},
+		{name: "missing job retry", method: http.MethodPost, path: "/v1/prepare-jobs/missing/retry", want: http.StatusNotFound},
 		{name: "missing job events", method: http.MethodGet, path: "/v1/prepare-jobs/missing/events", want: http.StatusNotFound},
+		{name: "validate invalid payload", method: http.MethodPost, path: "/v1/prepare-jobs/validate", body: "{", want: http.StatusBadRequest},
+		{name: "validate invalid request", method: http.MethodPost, path: "/v1/prepare-jobs/validate", body: `{"prepare_kind":"bad"}`, want: http.StatusOK},
+		{name: "validate wrong method", method: http.MethodGet, path: "/v1/prepare-jobs/validate", want: http.StatusMethodNotAllowed},
+		{name: "reap orphans", method: http.MethodPost, path: "/v1/orphans/reap?dry_run=true", want: http.StatusOK},
+		{name: "reap orphans invalid dry_run", method: http.MethodPost, path: "/v1/orphans/reap?dry_run=maybe", want: http.StatusBadRequest},
+		{name: "reap orphans wrong method", method: http.MethodGet, path: "/v1/orphans/reap", want: http.StatusMethodNotAllowed},
 	}
 
 	for _, tt := range tests {
@@ -44,3 +52,31 @@ func TestPrepareRoutesRegisterExpectedHandlers(t *testing.T) {
 		})
 	}
 }
+
+func TestPrepareValidateResponseShape(t *testing.T) {
+	opts, cleanup := newRouteTestOptions(t)
+	defer cleanup()
+
+	handler := NewHandler(opts)
+	body := strings.NewReader(`{"prepare_kind":"psql","image_id":"postgres:17","psql_args":["-c","select 1"]}`)
+	req := httptest.NewRequest(http.MethodPost, "/v1/prepare-jobs/validate", body)
+	req.Header.Set("Authorization", "Bearer secret")
+	resp := httptest.NewRecorder()
+
+	handler.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d body=%q", resp.Code, http.StatusOK, resp.Body.String())
+	}
+	var payload map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		t.Fatalf("decode payload: %v", err)
+	}
+	if payload["valid"] != true || payload["normalized_image_ref"] != "docker.io/library/postgres:17" || payload["planning_requires_container"] != false {
+		t.Fatalf("unexpected payload: %+v", payload)
+	}
+	cache, ok := payload["cache"].(map[string]any)
+	if !ok || cache["decision"] != "unknown" || cache["reason_code"] != "image_not_resolved" {
+		t.Fatalf("unexpected cache payload: %+v", payload["cache"])
+	}
+}
@@ -23,10 +23,18 @@ func (f *fakeRuntime) InitBase(ctx context.Context, imageID string, dataDir stri
 	return os.WriteFile(filepath.Join(dataDir, "PG_VERSION"), []byte("17"), 0o600)
 }
 
-func (f *fakeRuntime) ResolveImage(ctx context.Context, imageID string) (string, error) {
+func (f *fakeRuntime) ResolveImage(ctx context.Context, imageID string, platform string) (string, error) {
 	return imageID + "@sha256:resolved", nil
 }
 
+func (*fakeRuntime) Build(ctx context.Context, req engineRuntime.BuildRequest) (string, error) {
+	return "sha256:built", nil
+}
+
+func (*fakeRuntime) HasImageLocally(ctx context.Context, ref string) (string, bool, error) {
+	return ref, true, nil
+}
+
 func (f *fakeRuntime) Start(ctx context.Context, req engineRuntime.StartRequest) (engineRuntime.Instance, error) {
 	return engineRuntime.Instance{ID: "container-1", Host: "127.0.0.1", Port: 5432}, nil
 }
@@ -43,6 +51,14 @@ func (f *fakeRuntime) WaitForReady(ctx context.Context, id string, timeout time.
 	return nil
 }
 
+func (f *fakeRuntime) Ping(ctx context.Context) (string, error) {
+	return "test", nil
+}
+
+func (f *fakeRuntime) ListManaged(ctx context.Context) ([]engineRuntime.ManagedContainer, error) {
+	return nil, nil
+}
+
 type fakeStateFS struct{}
 
 var httpapiTestLayoutFS = statefs.NewManager(statefs.Options{Backend: "copy"})
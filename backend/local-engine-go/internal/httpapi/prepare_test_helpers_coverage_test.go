@@ -20,7 +20,7 @@ func TestPrepareTestHelpersCoverage(t *testing.T) {
 	if _, err := os.Stat(filepath.Join(dir, "PG_VERSION")); err != nil {
 		t.Fatalf("expected PG_VERSION: %v", err)
 	}
-	if out, err := rt.ResolveImage(context.Background(), "image-1"); err != nil || out == "" {
+	if out, err := rt.ResolveImage(context.Background(), "image-1", ""); err != nil || out == "" {
 		t.Fatalf("ResolveImage: out=%q err=%v", out, err)
 	}
 	inst, err := rt.Start(context.Background(), engineRuntime.StartRequest{ImageID: "image-1"})
@@ -0,0 +1,208 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/sqlrs/engine-local/internal/prepare"
+)
+
+// wsControl is a client frame on the job WebSocket: {"action":"cancel"} or
+// {"from": N} to continue the stream at event offset N.
+type wsControl struct {
+	Action string `json:"action,omitempty"`
+	From   *int   `json:"from,omitempty"`
+
+	decodeErr error
+}
+
+// wsControlError reports a rejected control frame. It is not a job event and
+// does not move the stream.
+type wsControlError struct {
+	Type  string                `json:"type"`
+	Error prepare.ErrorResponse `json:"error"`
+}
+
+// streamPrepareEventsWebSocket pushes job events as JSON text frames and
+// closes normally once the job is done. ?after_seq=N takes precedence over
+// ?from. A from offset is resolved to a seq once, and the stream follows seqs
+// from there, so pruning older log events cannot shift it.
+func streamPrepareEventsWebSocket(w http.ResponseWriter, r *http.Request, mgr *prepare.PrepareService, jobID string) {
+	from, err := parseEventOffset(r.URL.Query().Get("from"))
+	if err != nil {
+		_ = writeErrorResponse(w, "invalid_argument", "invalid from", err.Error(), http.StatusBadRequest)
+		return
+	}
+	afterSeq, ok := eventAfterSeq(w, r)
+	if !ok {
+		return
+	}
+	lastSeq, found, err := mgr.EventSeqBeforeOffset(jobID, from)
+	if err != nil {
+		_ = writeErrorResponse(w, "internal_error", "cannot read job events", err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		_ = writeErrorResponse(w, "not_found", "job not found", "", http.StatusNotFound)
+		return
+	}
+	if strings.TrimSpace(r.URL.Query().Get("after_seq")) != "" {
+		lastSeq = afterSeq
+	}
+	conn, ok := upgradeWebSocket(w, r)
+	if !ok {
+		return
+	}
+	defer conn.Close(wsCloseNormal, "")
+	defer mgr.WatchEvents(jobID)()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	controls := make(chan wsControl)
+	go readWebSocketControls(ctx, conn, controls)
+
+	for {
+		select {
+		case control, ok := <-controls:
+			if !ok {
+				return
+			}
+			lastSeq = applyWebSocketControl(conn, mgr, jobID, control, lastSeq)
+			continue
+		default:
+		}
+
+		events, ok, done, err := mgr.EventsAfterSeq(jobID, lastSeq)
+		if err != nil {
+			_ = conn.Close(wsCloseInternalError, "cannot read job events")
+			return
+		}
+		if !ok {
+			_ = conn.Close(wsCloseNormal, "job not found")
+			return
+		}
+		for _, event := range events {
+			data, err := json.Marshal(event)
+			if err != nil {
+				_ = conn.Close(wsCloseInternalError, "cannot encode job event")
+				return
+			}
+			if err := conn.WriteText(data); err != nil {
+				return
+			}
+			lastSeq = event.Seq
+		}
+		if done {
+			_ = conn.Close(wsCloseNormal, "job finished")
+			return
+		}
+		if len(events) > 0 {
+			continue
+		}
+
+		waitCtx, stopWait := context.WithCancel(ctx)
+		woke := make(chan error, 1)
+		go func(afterSeq int64) {
+			woke <- mgr.WaitForEvent(waitCtx, jobID, afterSeq)
+		}(lastSeq)
+		select {
+		case err := <-woke:
+			stopWait()
+			if err != nil {
+				return
+			}
+		case control, ok := <-controls:
+			stopWait()
+			<-woke
+			if !ok {
+				return
+			}
+			lastSeq = applyWebSocketControl(conn, mgr, jobID, control, lastSeq)
+		}
+	}
+}
+
+// readWebSocketControls decodes client frames until the socket closes; the
+// channel is closed when the client goes away.
+func readWebSocketControls(ctx context.Context, conn *wsConn, controls chan<- wsControl) {
+	defer close(controls)
+	for {
+		message, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var control wsControl
+		if err := json.Unmarshal(message, &control); err != nil {
+			control = wsControl{decodeErr: err}
+		}
+		select {
+		case controls <- control:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// applyWebSocketControl handles one control frame and returns the seq the
+// stream continues after.
+func applyWebSocketControl(conn *wsConn, mgr *prepare.PrepareService, jobID string, control wsControl, lastSeq int64) int64 {
+	if control.decodeErr != nil {
+		writeWebSocketControlError(conn, "invalid_argument", "invalid control frame", control.decodeErr.Error())
+		return lastSeq
+	}
+	if control.From != nil {
+		if *control.From < 0 {
+			writeWebSocketControlError(conn, "invalid_argument", "invalid from", "must not be negative")
+			return lastSeq
+		}
+		seq, _, err := mgr.EventSeqBeforeOffset(jobID, *control.From)
+		if err != nil {
+			writeWebSocketControlError(conn, "internal_error", "cannot read job events", err.Error())
+			return lastSeq
+		}
+		lastSeq = seq
+	}
+	switch action := strings.TrimSpace(control.Action); {
+	case action == "":
+		if control.From == nil {
+			writeWebSocketControlError(conn, "invalid_argument", "empty control frame", "")
+		}
+	case action == "cancel":
+		if _, _, _, err := mgr.Cancel(jobID); err != nil {
+			writeWebSocketControlError(conn, "internal_error", "cancel failed", err.Error())
+		}
+	default:
+		writeWebSocketControlError(conn, "invalid_argument", "unknown action", action)
+	}
+	return lastSeq
+}
+
+func writeWebSocketControlError(conn *wsConn, code string, message string, details string) {
+	data, err := json.Marshal(wsControlError{
+		Type:  "control_error",
+		Error: prepare.ErrorResponse{Code: code, Message: message, Details: details},
+	})
+	if err != nil {
+		return
+	}
+	_ = conn.WriteText(data)
+}
+
+func parseEventOffset(value string) (int, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+	offset, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, err
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("must not be negative")
+	}
+	return offset, nil
+}
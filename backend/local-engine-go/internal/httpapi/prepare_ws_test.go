@@ -0,0 +1,216 @@
+package httpapi
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sqlrs/engine-local/internal/prepare"
+	"github.com/sqlrs/engine-local/internal/prepare/queue"
+	"github.com/sqlrs/engine-local/internal/store/sqlite"
+)
+
+func TestPrepareJobWebSocketStreamsEventsAndCloses(t *testing.T) {
+	prep := newSSETestJob(t)
+	server := httptest.NewServer(NewHandler(Options{Prepare: prep}))
+	defer server.Close()
+
+	client := dialTestWebSocket(t, server, "/v1/prepare-jobs/job-sse/ws")
+	statuses := []string{}
+	for {
+		opcode, payload := client.readFrame(t)
+		if opcode == wsOpClose {
+			if code := binary.BigEndian.Uint16(payload); code != wsCloseNormal {
+				t.Fatalf("expected normal close, got %d", code)
+			}
+			break
+		}
+		var event prepare.Event
+		if err := json.Unmarshal(payload, &event); err != nil {
+			t.Fatalf("decode event %q: %v", payload, err)
+		}
+		statuses = append(statuses, event.Status)
+	}
+	if strings.Join(statuses, ",") != "queued,running,succeeded" {
+		t.Fatalf("unexpected events: %v", statuses)
+	}
+}
+
+func TestPrepareJobWebSocketResumesFromOffset(t *testing.T) {
+	prep := newSSETestJob(t)
+	server := httptest.NewServer(NewHandler(Options{Prepare: prep}))
+	defer server.Close()
+
+	client := dialTestWebSocket(t, server, "/v1/prepare-jobs/job-sse/ws?from=2")
+	opcode, payload := client.readFrame(t)
+	if opcode != wsOpText || !strings.Contains(string(payload), `"status":"succeeded"`) {
+		t.Fatalf("expected last event only, got %d %q", opcode, payload)
+	}
+	if opcode, _ := client.readFrame(t); opcode != wsOpClose {
+		t.Fatalf("expected close frame, got %d", opcode)
+	}
+}
+
+func TestPrepareJobWebSocketCancelControlFrame(t *testing.T) {
+	prep, queueStore := newRunningWebSocketJob(t)
+	server := httptest.NewServer(NewHandler(Options{Prepare: prep}))
+	defer server.Close()
+
+	client := dialTestWebSocket(t, server, "/v1/prepare-jobs/job-ws/ws")
+	client.writeText(t, `{"action":"bogus"}`)
+	opcode, payload := client.readFrame(t)
+	if opcode != wsOpText || !strings.Contains(string(payload), `"control_error"`) {
+		t.Fatalf("expected control error, got %d %q", opcode, payload)
+	}
+
+	client.writeText(t, `{"action":"cancel"}`)
+	sawFailed := false
+	for {
+		opcode, payload := client.readFrame(t)
+		if opcode == wsOpClose {
+			break
+		}
+		if strings.Contains(string(payload), `"status":"failed"`) {
+			sawFailed = true
+		}
+	}
+	if !sawFailed {
+		t.Fatalf("expected failed status event after cancel")
+	}
+	job, ok, err := queueStore.GetJob(context.Background(), "job-ws")
+	if err != nil || !ok || job.Status != prepare.StatusFailed {
+		t.Fatalf("expected cancelled job, got %+v ok=%v err=%v", job, ok, err)
+	}
+}
+
+func TestPrepareJobWebSocketRequiresUpgrade(t *testing.T) {
+	prep := newSSETestJob(t)
+	req := httptest.NewRequest(http.MethodGet, "http://example/v1/prepare-jobs/job-sse/ws", nil)
+	resp := httptest.NewRecorder()
+	NewHandler(Options{Prepare: prep}).ServeHTTP(resp, req)
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without upgrade, got %d", resp.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "http://example/v1/prepare-jobs/missing/ws", nil)
+	resp = httptest.NewRecorder()
+	NewHandler(Options{Prepare: prep}).ServeHTTP(resp, req)
+	if resp.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown job, got %d", resp.Code)
+	}
+}
+
+func TestWebSocketAccept(t *testing.T) {
+	if got := websocketAccept("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("unexpected accept key: %q", got)
+	}
+}
+
+func newRunningWebSocketJob(t *testing.T) (*prepare.PrepareService, queue.Store) {
+	t.Helper()
+	dbPath := filepath.Join(t.TempDir(), "state.db")
+	st, err := sqlite.Open(dbPath)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(func() { _ = st.Close() })
+	queueStore := mustOpenQueue(t, dbPath)
+	t.Cleanup(func() { _ = queueStore.Close() })
+	prep := newPrepareManager(t, st, queueStore)
+	if err := queueStore.CreateJob(context.Background(), queue.JobRecord{
+		JobID:       "job-ws",
+		Status:      prepare.StatusRunning,
+		PrepareKind: "psql",
+		ImageID:     "image-1",
+		CreatedAt:   time.Now().UTC().Format(time.RFC3339Nano),
+	}); err != nil {
+		t.Fatalf("create job: %v", err)
+	}
+	return prep, queueStore
+}
+
+type testWebSocketClient struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func dialTestWebSocket(t *testing.T, server *httptest.Server, path string) *testWebSocketClient {
+	t.Helper()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	request := "GET " + path + " HTTP/1.1\r\n" +
+		"Host: example\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n" +
+		"Sec-WebSocket-Version: 13\r\n\r\n"
+	if _, err := io.WriteString(conn, request); err != nil {
+		t.Fatalf("write handshake: %v", err)
+	}
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatalf("read handshake: %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("expected 101, got %d", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Sec-WebSocket-Accept"); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("unexpected accept header: %q", got)
+	}
+	return &testWebSocketClient{conn: conn, reader: reader}
+}
+
+func (c *testWebSocketClient) readFrame(t *testing.T) (byte, []byte) {
+	t.Helper()
+	var head [2]byte
+	if _, err := io.ReadFull(c.reader, head[:]); err != nil {
+		t.Fatalf("read frame: %v", err)
+	}
+	length := uint64(head[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.reader, ext[:]); err != nil {
+			t.Fatalf("read frame length: %v", err)
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.reader, ext[:]); err != nil {
+			t.Fatalf("read frame length: %v", err)
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		t.Fatalf("read frame payload: %v", err)
+	}
+	return head[0] & 0x0F, payload
+}
+
+func (c *testWebSocketClient) writeText(t *testing.T, text string) {
+	t.Helper()
+	mask := [4]byte{1, 2, 3, 4}
+	frame := []byte{0x80 | wsOpText, 0x80 | byte(len(text))}
+	frame = append(frame, mask[:]...)
+	for i := 0; i < len(text); i++ {
+		frame = append(frame, text[i]^mask[i%4])
+	}
+	if _, err := c.conn.Write(frame); err != nil {
+		t.Fatalf("write frame: %v", err)
+	}
+}
@@ -19,15 +19,20 @@ func TestNewHandlerRegistersResourceRoutes(t *testing.T) {
 		want   int
 	}{
 		{name: "health", method: http.MethodGet, path: "/v1/health", want: http.StatusOK},
+		{name: "diagnostics", method: http.MethodGet, path: "/v1/diagnostics", auth: true, want: http.StatusOK},
 		{name: "config schema", method: http.MethodGet, path: "/v1/config/schema", auth: true, want: http.StatusOK},
 		{name: "prepare jobs", method: http.MethodGet, path: "/v1/prepare-jobs", auth: true, want: http.StatusOK},
+		{name: "prepare validate", method: http.MethodGet, path: "/v1/prepare-jobs/validate", auth: true, want: http.StatusMethodNotAllowed},
 		{name: "tasks", method: http.MethodGet, path: "/v1/tasks", auth: true, want: http.StatusOK},
 		{name: "names", method: http.MethodGet, path: "/v1/names", auth: true, want: http.StatusOK},
 		{name: "instances", method: http.MethodGet, path: "/v1/instances", auth: true, want: http.StatusOK},
 		{name: "states", method: http.MethodGet, path: "/v1/states", auth: true, want: http.StatusOK},
+		{name: "states lookup", method: http.MethodGet, path: "/v1/states/lookup", auth: true, want: http.StatusMethodNotAllowed},
 		{name: "cache status", method: http.MethodGet, path: "/v1/cache/status", auth: true, want: http.StatusOK},
+		{name: "usage", method: http.MethodGet, path: "/v1/usage", auth: true, want: http.StatusOK},
 		{name: "cache explain", method: http.MethodGet, path: "/v1/cache/explain/prepare", auth: true, want: http.StatusMethodNotAllowed},
 		{name: "runs", method: http.MethodGet, path: "/v1/runs", auth: true, want: http.StatusMethodNotAllowed},
+		{name: "engine stats", method: http.MethodGet, path: "/v1/engine/stats", auth: true, want: http.StatusOK},
 	}
 
 	for _, tt := range tests {
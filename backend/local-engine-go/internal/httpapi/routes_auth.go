@@ -0,0 +1,40 @@
+package httpapi
+
+import (
+	"net/http"
+
+	"github.com/sqlrs/engine-local/internal/auth"
+)
+
+type authRotateResponse struct {
+	AuthToken string `json:"authToken"`
+}
+
+type authRoutes struct {
+	opts Options
+}
+
+func (routes authRoutes) register(mux *http.ServeMux) {
+	mux.HandleFunc("/v1/auth/rotate", routes.handleRotate)
+}
+
+// handleRotate replaces the bearer token. The old token stops working as soon
+// as the new one has been persisted for discovery.
+func (routes authRoutes) handleRotate(w http.ResponseWriter, r *http.Request) {
+	if !auth.RequireBearer(w, r, routes.opts.authToken()) {
+		return
+	}
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	if routes.opts.RotateAuth == nil {
+		_ = writeErrorResponse(w, "not_supported", "token rotation is not supported", "", http.StatusNotImplemented)
+		return
+	}
+	token, err := routes.opts.RotateAuth()
+	if err != nil {
+		_ = writeErrorResponse(w, "internal_error", "token rotation failed", err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_ = writeJSON(w, authRotateResponse{AuthToken: token})
+}
@@ -15,10 +15,11 @@ type cacheRoutes struct {
 func (routes cacheRoutes) register(mux *http.ServeMux) {
 	mux.HandleFunc("/v1/cache/status", routes.handleStatus)
 	mux.HandleFunc("/v1/cache/explain/prepare", routes.handleExplainPrepare)
+	mux.HandleFunc("/v1/usage", routes.handleUsage)
 }
 
 func (routes cacheRoutes) handleStatus(w http.ResponseWriter, r *http.Request) {
-	if !auth.RequireBearer(w, r, routes.opts.AuthToken) {
+	if !auth.RequireBearer(w, r, routes.opts.authToken()) {
 		return
 	}
 	if !requireMethod(w, r, http.MethodGet) {
@@ -36,8 +37,27 @@ func (routes cacheRoutes) handleStatus(w http.ResponseWriter, r *http.Request) {
 	_ = writeJSON(w, status)
 }
 
+func (routes cacheRoutes) handleUsage(w http.ResponseWriter, r *http.Request) {
+	if !auth.RequireBearer(w, r, routes.opts.authToken()) {
+		return
+	}
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	if routes.opts.Prepare == nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	usage, err := routes.opts.Prepare.Usage(r.Context())
+	if err != nil {
+		_ = writeErrorResponse(w, "internal_error", "cannot compute usage", err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_ = writeJSON(w, usage)
+}
+
 func (routes cacheRoutes) handleExplainPrepare(w http.ResponseWriter, r *http.Request) {
-	if !auth.RequireBearer(w, r, routes.opts.AuthToken) {
+	if !auth.RequireBearer(w, r, routes.opts.authToken()) {
 		return
 	}
 	if !requireMethod(w, r, http.MethodPost) {
@@ -64,6 +84,9 @@ func (routes cacheRoutes) handleExplainPrepare(w http.ResponseWriter, r *http.Re
 		if _, ok := err.(*prepare.ValidationError); ok {
 			status = http.StatusBadRequest
 		}
+		if _, ok := err.(prepare.PermissionDeniedError); ok {
+			status = http.StatusForbidden
+		}
 		_ = writeError(w, *resp, status)
 		return
 	}
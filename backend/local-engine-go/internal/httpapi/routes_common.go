@@ -1,6 +1,8 @@
 package httpapi
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -48,6 +50,16 @@ func readQueryValue(r *http.Request, key string) string {
 	return strings.TrimSpace(r.URL.Query().Get(key))
 }
 
+// firstQueryValue returns the first non-empty value among keys.
+func firstQueryValue(r *http.Request, keys ...string) string {
+	for _, key := range keys {
+		if value := readQueryValue(r, key); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
 func parseBoolQuery(r *http.Request, key string) (bool, error) {
 	raw := strings.TrimSpace(r.URL.Query().Get(key))
 	if raw == "" {
@@ -77,3 +89,35 @@ func normalizeIDPrefix(value string) (string, error) {
 func writeListResponse[T any](w http.ResponseWriter, r *http.Request, items []T) error {
 	return stream.WriteList(w, r, items)
 }
+
+// listNotModified sets a weak ETag for a list response and reports whether
+// the request's If-None-Match already matches it, in which case 304 has been
+// written. The tag covers the store generation, the engine instance, the
+// query and the Accept header, so it must be taken before the list is read.
+func listNotModified(w http.ResponseWriter, r *http.Request, instanceID string, generation uint64, ok bool) bool {
+	if !ok {
+		return false
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\n%d\n%s\n%s", instanceID, generation, r.URL.RawQuery, r.Header.Get("Accept"))))
+	etag := `W/"` + hex.EncodeToString(sum[:12]) + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Add("Vary", "Accept")
+	if !etagMatches(r.Header.Get("If-None-Match"), etag) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// etagMatches applies the weak comparison of RFC 9110 to an If-None-Match
+// header value.
+func etagMatches(header string, etag string) bool {
+	want := strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == want {
+			return true
+		}
+	}
+	return false
+}
@@ -19,10 +19,11 @@ type configRoutes struct {
 func (routes configRoutes) register(mux *http.ServeMux) {
 	mux.HandleFunc("/v1/config/schema", routes.handleSchema)
 	mux.HandleFunc("/v1/config", routes.handleConfig)
+	mux.HandleFunc("/v1/config/", routes.handleConfigPath)
 }
 
 func (routes configRoutes) handleSchema(w http.ResponseWriter, r *http.Request) {
-	if !auth.RequireBearer(w, r, routes.opts.AuthToken) {
+	if !auth.RequireBearer(w, r, routes.opts.authToken()) {
 		return
 	}
 	if !requireMethod(w, r, http.MethodGet) {
@@ -36,7 +37,7 @@ func (routes configRoutes) handleSchema(w http.ResponseWriter, r *http.Request)
 }
 
 func (routes configRoutes) handleConfig(w http.ResponseWriter, r *http.Request) {
-	if !auth.RequireBearer(w, r, routes.opts.AuthToken) {
+	if !auth.RequireBearer(w, r, routes.opts.authToken()) {
 		return
 	}
 	if routes.opts.Config == nil {
@@ -81,6 +82,10 @@ func (routes configRoutes) handleConfig(w http.ResponseWriter, r *http.Request)
 			_ = writeErrorResponse(w, "invalid_argument", "path is required", "", http.StatusBadRequest)
 			return
 		}
+		if !routes.opts.checkCORSOrigins(req.Path, req.Value) {
+			writeCORSWildcardError(w)
+			return
+		}
 		value, err := routes.opts.Config.Set(req.Path, req.Value)
 		if err != nil {
 			if errors.Is(err, config.ErrInvalidPath) || errors.Is(err, config.ErrInvalidValue) {
@@ -115,3 +120,96 @@ func (routes configRoutes) handleConfig(w http.ResponseWriter, r *http.Request)
 		w.WriteHeader(http.StatusMethodNotAllowed)
 	}
 }
+
+// handleConfigPath serves /v1/config/{path}. Unlike the query-based
+// /v1/config surface, writes are checked against the config schema, so only
+// declared paths with values of the declared type are accepted.
+func (routes configRoutes) handleConfigPath(w http.ResponseWriter, r *http.Request) {
+	if !auth.RequireBearer(w, r, routes.opts.authToken()) {
+		return
+	}
+	if routes.opts.Config == nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	path := strings.TrimSpace(strings.TrimPrefix(r.URL.Path, "/v1/config/"))
+	if path == "" || strings.Contains(path, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	schema := routes.opts.Config.Schema()
+	switch r.Method {
+	case http.MethodGet:
+		effective := true
+		if readQueryValue(r, "effective") != "" {
+			parsed, err := parseBoolQuery(r, "effective")
+			if err != nil {
+				_ = writeErrorResponse(w, "invalid_argument", "invalid effective", err.Error(), http.StatusBadRequest)
+				return
+			}
+			effective = parsed
+		}
+		value, err := routes.opts.Config.Get(path, effective)
+		if err != nil {
+			writeConfigPathError(w, err, "cannot read config")
+			return
+		}
+		_ = writeJSON(w, config.Value{Path: path, Value: value})
+	case http.MethodPut:
+		var value any
+		decoder := json.NewDecoder(r.Body)
+		decoder.UseNumber()
+		if err := decoder.Decode(&value); err != nil {
+			_ = writeErrorResponse(w, "invalid_argument", "invalid json payload", err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := config.CheckValue(schema, path, value); err != nil {
+			writeConfigPathError(w, err, "cannot update config")
+			return
+		}
+		if !routes.opts.checkCORSOrigins(path, value) {
+			writeCORSWildcardError(w)
+			return
+		}
+		if _, err := routes.opts.Config.Set(path, value); err != nil {
+			writeConfigPathError(w, err, "cannot update config")
+			return
+		}
+		effective, err := routes.opts.Config.Get(path, true)
+		if err != nil {
+			writeConfigPathError(w, err, "cannot read config")
+			return
+		}
+		_ = writeJSON(w, config.Value{Path: path, Value: effective})
+	case http.MethodDelete:
+		if err := config.CheckValue(schema, path, nil); err != nil && errors.Is(err, config.ErrInvalidPath) {
+			writeConfigPathError(w, err, "cannot update config")
+			return
+		}
+		value, err := routes.opts.Config.Remove(path)
+		if err != nil {
+			writeConfigPathError(w, err, "cannot update config")
+			return
+		}
+		_ = writeJSON(w, config.Value{Path: path, Value: value})
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func writeCORSWildcardError(w http.ResponseWriter) {
+	_ = writeErrorResponse(w, "invalid_argument", "invalid config value", "wildcard CORS origin is not allowed while an auth token is required", http.StatusBadRequest)
+}
+
+func writeConfigPathError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, config.ErrInvalidPath):
+		_ = writeErrorResponse(w, "invalid_argument", "invalid path", err.Error(), http.StatusBadRequest)
+	case errors.Is(err, config.ErrInvalidValue):
+		_ = writeErrorResponse(w, "invalid_argument", "invalid config value", err.Error(), http.StatusBadRequest)
+	case errors.Is(err, config.ErrPathNotFound):
+		_ = writeErrorResponse(w, "not_found", "path not found", err.Error(), http.StatusNotFound)
+	default:
+		_ = writeErrorResponse(w, "internal_error", message, err.Error(), http.StatusInternalServerError)
+	}
+}
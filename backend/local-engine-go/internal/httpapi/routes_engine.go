@@ -0,0 +1,118 @@
+package httpapi
+
+import (
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/sqlrs/engine-local/internal/auth"
+)
+
+// EngineActivity reports HTTP activity; the engine uses it for the idle
+// shutdown timer.
+type EngineActivity interface {
+	InflightRequests() int64
+	IdleFor() time.Duration
+}
+
+// APIVersion is the HTTP API generation served under /v1.
+const APIVersion = "v1"
+
+// BuildInfo describes the engine binary for /v1/version.
+type BuildInfo struct {
+	Revision  string
+	Time      string
+	Modified  bool
+	GoVersion string
+}
+
+type versionResponse struct {
+	Version          string `json:"version"`
+	APIVersion       string `json:"apiVersion"`
+	Revision         string `json:"revision,omitempty"`
+	BuildTime        string `json:"buildTime,omitempty"`
+	Modified         bool   `json:"modified,omitempty"`
+	GoVersion        string `json:"goVersion,omitempty"`
+	ContainerRuntime string `json:"containerRuntime,omitempty"`
+	SnapshotBackend  string `json:"snapshotBackend,omitempty"`
+}
+
+type engineStatsResponse struct {
+	Version            string  `json:"version"`
+	InstanceID         string  `json:"instanceId"`
+	PID                int     `json:"pid"`
+	StartedAt          string  `json:"startedAt,omitempty"`
+	UptimeSeconds      float64 `json:"uptimeSeconds"`
+	InflightRequests   int64   `json:"inflightRequests"`
+	IdleSeconds        float64 `json:"idleSeconds"`
+	IdleTimeoutSeconds float64 `json:"idleTimeoutSeconds"`
+	RunningJobs        int     `json:"runningJobs"`
+	ContainerRuntime   string  `json:"containerRuntime,omitempty"`
+	SnapshotBackend    string  `json:"snapshotBackend,omitempty"`
+}
+
+type engineRoutes struct {
+	opts Options
+}
+
+func (routes engineRoutes) register(mux *http.ServeMux) {
+	mux.HandleFunc("/v1/engine/stats", routes.handleStats)
+	mux.HandleFunc("/v1/version", routes.handleVersion)
+	mux.HandleFunc("/v1/diagnostics", routes.handleDiagnostics)
+}
+
+// handleVersion serves /v1/version without auth, like /v1/health, so clients
+// can check for version skew before they have a token.
+func (routes engineRoutes) handleVersion(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	build := routes.opts.Build
+	resp := versionResponse{
+		Version:         routes.opts.Version,
+		APIVersion:      APIVersion,
+		Revision:        build.Revision,
+		BuildTime:       build.Time,
+		Modified:        build.Modified,
+		GoVersion:       build.GoVersion,
+		SnapshotBackend: routes.opts.SnapshotBackend,
+	}
+	if named, ok := routes.opts.Runtime.(interface{ Binary() string }); ok {
+		resp.ContainerRuntime = named.Binary()
+	}
+	_ = writeJSON(w, resp)
+}
+
+func (routes engineRoutes) handleStats(w http.ResponseWriter, r *http.Request) {
+	if !auth.RequireBearer(w, r, routes.opts.authToken()) {
+		return
+	}
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	resp := engineStatsResponse{
+		Version:            routes.opts.Version,
+		InstanceID:         routes.opts.InstanceID,
+		PID:                os.Getpid(),
+		IdleTimeoutSeconds: routes.opts.IdleTimeout.Seconds(),
+		SnapshotBackend:    routes.opts.SnapshotBackend,
+	}
+	if !routes.opts.StartedAt.IsZero() {
+		resp.StartedAt = routes.opts.StartedAt.UTC().Format(time.RFC3339Nano)
+		resp.UptimeSeconds = time.Since(routes.opts.StartedAt).Seconds()
+	}
+	if routes.opts.Activity != nil {
+		// The stats request itself is in flight; report the others.
+		if inflight := routes.opts.Activity.InflightRequests() - 1; inflight > 0 {
+			resp.InflightRequests = inflight
+		}
+		resp.IdleSeconds = routes.opts.Activity.IdleFor().Seconds()
+	}
+	if routes.opts.Prepare != nil {
+		resp.RunningJobs = routes.opts.Prepare.RunningJobs()
+	}
+	if named, ok := routes.opts.Runtime.(interface{ Binary() string }); ok {
+		resp.ContainerRuntime = named.Binary()
+	}
+	_ = writeJSON(w, resp)
+}
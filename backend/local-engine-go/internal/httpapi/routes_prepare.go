@@ -2,26 +2,87 @@ package httpapi
 
 import (
 	"encoding/json"
+	"fmt"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/sqlrs/engine-local/internal/auth"
 	"github.com/sqlrs/engine-local/internal/deletion"
 	"github.com/sqlrs/engine-local/internal/prepare"
+	"github.com/sqlrs/engine-local/internal/tracing"
 )
 
 type prepareRoutes struct {
-	opts Options
+	opts    Options
+	submits *submitLimiter
 }
 
 func (routes prepareRoutes) register(mux *http.ServeMux) {
+	routes.submits = newSubmitLimiter(routes.opts.Config)
 	mux.HandleFunc("/v1/prepare-jobs", routes.handleJobs)
 	mux.HandleFunc("/v1/prepare-jobs/", routes.handleJob)
+	mux.HandleFunc("/v1/prepare-jobs/validate", routes.handleValidate)
 	mux.HandleFunc("/v1/tasks", routes.handleTasks)
+	mux.HandleFunc("/v1/orphans/reap", routes.handleOrphansReap)
+}
+
+func (routes prepareRoutes) handleOrphansReap(w http.ResponseWriter, r *http.Request) {
+	if !auth.RequireBearer(w, r, routes.opts.authToken()) {
+		return
+	}
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	if routes.opts.Prepare == nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	dryRun, err := parseBoolQuery(r, "dry_run")
+	if err != nil {
+		_ = writeErrorResponse(w, "invalid_argument", "invalid dry_run", err.Error(), http.StatusBadRequest)
+		return
+	}
+	result, err := routes.opts.Prepare.ReapOrphans(r.Context(), prepare.OrphanReapOptions{DryRun: dryRun})
+	if err != nil {
+		log.Printf("reap orphans failed error=%v", err)
+		_ = writeErrorResponse(w, "internal_error", "reap orphans failed", err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_ = writeJSON(w, result)
+}
+
+// handleValidate checks a prepare request without creating a job. Invalid
+// requests are a 200 report with valid=false, so editors can show the error
+// inline; only engine failures are error responses.
+func (routes prepareRoutes) handleValidate(w http.ResponseWriter, r *http.Request) {
+	if !auth.RequireBearer(w, r, routes.opts.authToken()) {
+		return
+	}
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	if routes.opts.Prepare == nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	var req prepare.Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		_ = writeErrorResponse(w, "invalid_argument", "invalid json payload", err.Error(), http.StatusBadRequest)
+		return
+	}
+	report, err := routes.opts.Prepare.Validate(r.Context(), req)
+	if err != nil {
+		_ = writeError(w, *prepare.ToErrorResponse(err), http.StatusInternalServerError)
+		return
+	}
+	_ = writeJSON(w, report)
 }
 
 func (routes prepareRoutes) handleJobs(w http.ResponseWriter, r *http.Request) {
-	if !auth.RequireBearer(w, r, routes.opts.AuthToken) {
+	if !auth.RequireBearer(w, r, routes.opts.authToken()) {
 		return
 	}
 	if routes.opts.Prepare == nil {
@@ -30,8 +91,28 @@ func (routes prepareRoutes) handleJobs(w http.ResponseWriter, r *http.Request) {
 	}
 	switch r.Method {
 	case http.MethodGet:
-		_ = writeListResponse(w, r, routes.opts.Prepare.ListJobs(readQueryValue(r, "job")))
+		labels, err := parseLabelQuery(r)
+		if err != nil {
+			_ = writeErrorResponse(w, "invalid_argument", "invalid label", err.Error(), http.StatusBadRequest)
+			return
+		}
+		generation, ok := routes.opts.Prepare.JobsGeneration()
+		if listNotModified(w, r, routes.opts.InstanceID, generation, ok) {
+			return
+		}
+		var jobs []prepare.JobEntry
+		if len(labels) > 0 {
+			jobs = filterJobsByIDPrefix(routes.opts.Prepare.ListJobsByLabels(labels), readQueryValue(r, "job"))
+		} else {
+			jobs = routes.opts.Prepare.ListJobs(readQueryValue(r, "job"))
+		}
+		_ = writeListResponse(w, r, filterJobsByNamespace(jobs, readQueryValue(r, "namespace")))
 	case http.MethodPost:
+		if ok, wait := routes.submits.allow(); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			_ = writeErrorResponse(w, "resource_exhausted", "too many job submissions", "submit rate limit reached", http.StatusTooManyRequests)
+			return
+		}
 		var req prepare.Request
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			_ = writeError(w, prepare.ErrorResponse{
@@ -41,17 +122,11 @@ func (routes prepareRoutes) handleJobs(w http.ResponseWriter, r *http.Request) {
 			}, http.StatusBadRequest)
 			return
 		}
-		accepted, err := routes.opts.Prepare.Submit(r.Context(), req)
+		// A caller's traceparent makes the job span a child of the caller's trace.
+		ctx := tracing.Extract(r.Context(), r.Header.Get(tracing.TraceparentHeader))
+		accepted, err := routes.opts.Prepare.Submit(ctx, req)
 		if err != nil {
-			resp := prepare.ToErrorResponse(err)
-			status := http.StatusInternalServerError
-			if _, ok := err.(prepare.ValidationError); ok {
-				status = http.StatusBadRequest
-			}
-			if _, ok := err.(*prepare.ValidationError); ok {
-				status = http.StatusBadRequest
-			}
-			_ = writeError(w, *resp, status)
+			writePrepareError(w, err)
 			return
 		}
 		w.Header().Set("Location", accepted.StatusURL)
@@ -62,7 +137,7 @@ func (routes prepareRoutes) handleJobs(w http.ResponseWriter, r *http.Request) {
 }
 
 func (routes prepareRoutes) handleJob(w http.ResponseWriter, r *http.Request) {
-	if !auth.RequireBearer(w, r, routes.opts.AuthToken) {
+	if !auth.RequireBearer(w, r, routes.opts.authToken()) {
 		return
 	}
 	if routes.opts.Prepare == nil {
@@ -78,10 +153,18 @@ func (routes prepareRoutes) handleJob(w http.ResponseWriter, r *http.Request) {
 		routes.handleCancel(w, r, strings.TrimSuffix(path, "/cancel"))
 		return
 	}
+	if strings.HasSuffix(path, "/retry") {
+		routes.handleRetry(w, r, strings.TrimSuffix(path, "/retry"))
+		return
+	}
 	if strings.HasSuffix(path, "/events") {
 		routes.handleEvents(w, r, strings.TrimSuffix(path, "/events"))
 		return
 	}
+	if strings.HasSuffix(path, "/ws") {
+		routes.handleWebSocket(w, r, strings.TrimSuffix(path, "/ws"))
+		return
+	}
 	if strings.Contains(path, "/") {
 		http.NotFound(w, r)
 		return
@@ -144,6 +227,50 @@ func (routes prepareRoutes) handleCancel(w http.ResponseWriter, r *http.Request,
 	_ = writeJSONStatus(w, status, http.StatusOK)
 }
 
+func (routes prepareRoutes) handleRetry(w http.ResponseWriter, r *http.Request, jobID string) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	if jobID == "" || strings.Contains(jobID, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	accepted, ok, err := routes.opts.Prepare.Retry(r.Context(), jobID)
+	if err != nil {
+		writePrepareError(w, err)
+		return
+	}
+	if !ok {
+		_ = writeErrorResponse(w, "not_found", "job not found", "", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Location", accepted.StatusURL)
+	_ = writeJSONStatus(w, accepted, http.StatusAccepted)
+}
+
+// writePrepareError answers a failed job submission or retry with the error
+// response and the HTTP status of its type.
+func writePrepareError(w http.ResponseWriter, err error) {
+	_ = writeError(w, *prepare.ToErrorResponse(err), prepareErrorStatus(err))
+}
+
+func prepareErrorStatus(err error) int {
+	switch err.(type) {
+	case prepare.ValidationError, *prepare.ValidationError:
+		return http.StatusBadRequest
+	case prepare.ConflictError:
+		return http.StatusConflict
+	case prepare.UnavailableError:
+		return http.StatusServiceUnavailable
+	case prepare.ResourceExhaustedError:
+		return http.StatusTooManyRequests
+	case prepare.PermissionDeniedError:
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
 func (routes prepareRoutes) handleEvents(w http.ResponseWriter, r *http.Request, jobID string) {
 	if !requireMethod(w, r, http.MethodGet) {
 		return
@@ -155,8 +282,19 @@ func (routes prepareRoutes) handleEvents(w http.ResponseWriter, r *http.Request,
 	streamPrepareEvents(w, r, routes.opts.Prepare, jobID)
 }
 
+func (routes prepareRoutes) handleWebSocket(w http.ResponseWriter, r *http.Request, jobID string) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	if jobID == "" || strings.Contains(jobID, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	streamPrepareEventsWebSocket(w, r, routes.opts.Prepare, jobID)
+}
+
 func (routes prepareRoutes) handleTasks(w http.ResponseWriter, r *http.Request) {
-	if !auth.RequireBearer(w, r, routes.opts.AuthToken) {
+	if !auth.RequireBearer(w, r, routes.opts.authToken()) {
 		return
 	}
 	if !requireMethod(w, r, http.MethodGet) {
@@ -168,3 +306,50 @@ func (routes prepareRoutes) handleTasks(w http.ResponseWriter, r *http.Request)
 	}
 	_ = writeListResponse(w, r, routes.opts.Prepare.ListTasks(readQueryValue(r, "job")))
 }
+
+// parseLabelQuery reads repeated label=key=value filters; every label must
+// match.
+func parseLabelQuery(r *http.Request) (map[string]string, error) {
+	values := r.URL.Query()["label"]
+	if len(values) == 0 {
+		return nil, nil
+	}
+	labels := make(map[string]string, len(values))
+	for _, raw := range values {
+		key, value, ok := strings.Cut(raw, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("expected key=value, got %q", raw)
+		}
+		labels[key] = strings.TrimSpace(value)
+	}
+	return labels, nil
+}
+
+func filterJobsByIDPrefix(jobs []prepare.JobEntry, prefix string) []prepare.JobEntry {
+	if prefix == "" {
+		return jobs
+	}
+	filtered := make([]prepare.JobEntry, 0, len(jobs))
+	for _, job := range jobs {
+		if strings.HasPrefix(job.JobID, prefix) {
+			filtered = append(filtered, job)
+		}
+	}
+	return filtered
+}
+
+// filterJobsByNamespace keeps jobs submitted in namespace; an empty filter
+// keeps every job.
+func filterJobsByNamespace(jobs []prepare.JobEntry, namespace string) []prepare.JobEntry {
+	if namespace == "" {
+		return jobs
+	}
+	filtered := make([]prepare.JobEntry, 0, len(jobs))
+	for _, job := range jobs {
+		if job.Namespace == namespace {
+			filtered = append(filtered, job)
+		}
+	}
+	return filtered
+}
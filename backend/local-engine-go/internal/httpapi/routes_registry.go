@@ -1,12 +1,18 @@
 package httpapi
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/sqlrs/engine-local/internal/auth"
 	"github.com/sqlrs/engine-local/internal/deletion"
+	"github.com/sqlrs/engine-local/internal/prepare"
+	"github.com/sqlrs/engine-local/internal/registry"
 	"github.com/sqlrs/engine-local/internal/store"
 )
 
@@ -21,10 +27,13 @@ func (routes registryRoutes) register(mux *http.ServeMux) {
 	mux.HandleFunc("/v1/instances/", routes.handleInstance)
 	mux.HandleFunc("/v1/states", routes.handleStates)
 	mux.HandleFunc("/v1/states/", routes.handleState)
+	mux.HandleFunc("/v1/states/gc", routes.handleStatesGC)
+	mux.HandleFunc("/v1/states/import", routes.handleStatesImport)
+	mux.HandleFunc("/v1/states/lookup", routes.handleStatesLookup)
 }
 
 func (routes registryRoutes) handleNames(w http.ResponseWriter, r *http.Request) {
-	if !auth.RequireBearer(w, r, routes.opts.AuthToken) {
+	if !auth.RequireBearer(w, r, routes.opts.authToken()) {
 		return
 	}
 	if !requireMethod(w, r, http.MethodGet) {
@@ -44,7 +53,7 @@ func (routes registryRoutes) handleNames(w http.ResponseWriter, r *http.Request)
 }
 
 func (routes registryRoutes) handleName(w http.ResponseWriter, r *http.Request) {
-	if !auth.RequireBearer(w, r, routes.opts.AuthToken) {
+	if !auth.RequireBearer(w, r, routes.opts.authToken()) {
 		return
 	}
 	if !requireMethod(w, r, http.MethodGet) {
@@ -68,7 +77,7 @@ func (routes registryRoutes) handleName(w http.ResponseWriter, r *http.Request)
 }
 
 func (routes registryRoutes) handleInstances(w http.ResponseWriter, r *http.Request) {
-	if !auth.RequireBearer(w, r, routes.opts.AuthToken) {
+	if !auth.RequireBearer(w, r, routes.opts.authToken()) {
 		return
 	}
 	if !requireMethod(w, r, http.MethodGet) {
@@ -80,27 +89,35 @@ func (routes registryRoutes) handleInstances(w http.ResponseWriter, r *http.Requ
 		return
 	}
 	filters := store.InstanceFilters{
-		StateID:  readQueryValue(r, "state"),
-		ImageID:  readQueryValue(r, "image"),
-		IDPrefix: idPrefix,
+		StateID:   firstQueryValue(r, "state_id", "state"),
+		ImageID:   firstQueryValue(r, "image_id", "image"),
+		Namespace: readQueryValue(r, "namespace"),
+		IDPrefix:  idPrefix,
 	}
 	entries, err := routes.opts.Registry.ListInstances(r.Context(), filters)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+	for i := range entries {
+		routes.markStaleInstance(r.Context(), &entries[i])
+	}
 	_ = writeListResponse(w, r, entries)
 }
 
 func (routes registryRoutes) handleInstance(w http.ResponseWriter, r *http.Request) {
-	if !auth.RequireBearer(w, r, routes.opts.AuthToken) {
+	if !auth.RequireBearer(w, r, routes.opts.authToken()) {
+		return
+	}
+	idOrName := strings.TrimPrefix(r.URL.Path, "/v1/instances/")
+	if instanceID, ok := strings.CutSuffix(idOrName, "/exec"); ok && instanceID != "" && !strings.Contains(instanceID, "/") {
+		runRoutes{opts: routes.opts}.handleInstanceExec(w, r, instanceID)
 		return
 	}
 	if r.Method != http.MethodGet && r.Method != http.MethodDelete {
 		w.WriteHeader(http.StatusMethodNotAllowed)
 		return
 	}
-	idOrName := strings.TrimPrefix(r.URL.Path, "/v1/instances/")
 	if idOrName == "" {
 		http.NotFound(w, r)
 		return
@@ -123,11 +140,33 @@ func (routes registryRoutes) handleInstance(w http.ResponseWriter, r *http.Reque
 		w.WriteHeader(http.StatusTemporaryRedirect)
 		return
 	}
+	routes.markStaleInstance(r.Context(), &entry)
 	_ = writeJSON(w, entry)
 }
 
+type containerLiveness interface {
+	ContainerRunning(ctx context.Context, id string) (bool, error)
+}
+
+// markStaleInstance reports an instance as stale when its recorded container
+// is no longer running. Liveness errors leave the stored status untouched.
+func (routes registryRoutes) markStaleInstance(ctx context.Context, entry *store.InstanceEntry) {
+	liveness, ok := routes.opts.Runtime.(containerLiveness)
+	if !ok || entry == nil || entry.RuntimeID == nil || strings.TrimSpace(*entry.RuntimeID) == "" {
+		return
+	}
+	running, err := liveness.ContainerRunning(ctx, *entry.RuntimeID)
+	if err != nil {
+		log.Printf("instance liveness check failed instance=%s runtime=%s err=%v", entry.InstanceID, *entry.RuntimeID, err)
+		return
+	}
+	if !running {
+		entry.Status = store.InstanceStatusStale
+	}
+}
+
 func (routes registryRoutes) handleStates(w http.ResponseWriter, r *http.Request) {
-	if !auth.RequireBearer(w, r, routes.opts.AuthToken) {
+	if !auth.RequireBearer(w, r, routes.opts.authToken()) {
 		return
 	}
 	if !requireMethod(w, r, http.MethodGet) {
@@ -138,10 +177,15 @@ func (routes registryRoutes) handleStates(w http.ResponseWriter, r *http.Request
 		_ = writeErrorResponse(w, "invalid_argument", "invalid id_prefix", err.Error(), http.StatusBadRequest)
 		return
 	}
+	generation, ok := routes.opts.Registry.Generation()
+	if listNotModified(w, r, routes.opts.InstanceID, generation, ok) {
+		return
+	}
 	filters := store.StateFilters{
-		Kind:     readQueryValue(r, "kind"),
-		ImageID:  readQueryValue(r, "image"),
-		IDPrefix: idPrefix,
+		Kind:      readQueryValue(r, "kind"),
+		ImageID:   readQueryValue(r, "image"),
+		Namespace: readQueryValue(r, "namespace"),
+		IDPrefix:  idPrefix,
 	}
 	entries, err := routes.opts.Registry.ListStates(r.Context(), filters)
 	if err != nil {
@@ -152,14 +196,26 @@ func (routes registryRoutes) handleStates(w http.ResponseWriter, r *http.Request
 }
 
 func (routes registryRoutes) handleState(w http.ResponseWriter, r *http.Request) {
-	if !auth.RequireBearer(w, r, routes.opts.AuthToken) {
+	if !auth.RequireBearer(w, r, routes.opts.authToken()) {
 		return
 	}
-	if r.Method != http.MethodGet && r.Method != http.MethodDelete {
+	stateID := strings.TrimPrefix(r.URL.Path, "/v1/states/")
+	if exportID, ok := strings.CutSuffix(stateID, "/export"); ok && exportID != "" {
+		routes.exportState(w, r, exportID)
+		return
+	}
+	if schemaID, ok := strings.CutSuffix(stateID, "/schema"); ok && schemaID != "" {
+		routes.stateSchema(w, r, schemaID)
+		return
+	}
+	if tagID, ok := strings.CutSuffix(stateID, "/tags"); ok && tagID != "" {
+		routes.tagState(w, r, tagID)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead && r.Method != http.MethodDelete {
 		w.WriteHeader(http.StatusMethodNotAllowed)
 		return
 	}
-	stateID := strings.TrimPrefix(r.URL.Path, "/v1/states/")
 	if stateID == "" {
 		http.NotFound(w, r)
 		return
@@ -173,13 +229,233 @@ func (routes registryRoutes) handleState(w http.ResponseWriter, r *http.Request)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+	if r.Method == http.MethodHead {
+		// An existence check: the status alone answers it.
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		return
+	}
 	if !ok {
-		http.NotFound(w, r)
+		_ = writeErrorResponse(w, "not_found", "state not found", stateID, http.StatusNotFound)
+		return
+	}
+	ancestors, err := routes.opts.Registry.StateAncestors(r.Context(), entry)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	_ = writeJSON(w, stateDetail{StateEntry: entry, Ancestors: ancestors})
+}
+
+// handleStatesLookup answers whether a prepare request would be a cache hit
+// without submitting it; ?cheap=true skips container planning.
+func (routes registryRoutes) handleStatesLookup(w http.ResponseWriter, r *http.Request) {
+	if !auth.RequireBearer(w, r, routes.opts.authToken()) {
+		return
+	}
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	if routes.opts.Prepare == nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	cheap, err := parseBoolQuery(r, "cheap")
+	if err != nil {
+		_ = writeErrorResponse(w, "invalid_argument", "invalid cheap", err.Error(), http.StatusBadRequest)
+		return
+	}
+	var req prepare.Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		_ = writeErrorResponse(w, "invalid_argument", "invalid json payload", err.Error(), http.StatusBadRequest)
+		return
+	}
+	result, err := routes.opts.Prepare.LookupState(r.Context(), req, cheap)
+	if err != nil {
+		resp := prepare.ToErrorResponse(err)
+		status := http.StatusInternalServerError
+		if _, ok := err.(prepare.ValidationError); ok {
+			status = http.StatusBadRequest
+		}
+		if _, ok := err.(prepare.PermissionDeniedError); ok {
+			status = http.StatusForbidden
+		}
+		_ = writeError(w, *resp, status)
+		return
+	}
+	_ = writeJSON(w, result)
+}
+
+// stateDetail is the GET /v1/states/{id} payload: the state itself plus its
+// ancestor chain, nearest parent first.
+type stateDetail struct {
+	store.StateEntry
+	Ancestors []store.StateEntry `json:"ancestors,omitempty"`
+}
+
+func (routes registryRoutes) handleStatesGC(w http.ResponseWriter, r *http.Request) {
+	if !auth.RequireBearer(w, r, routes.opts.authToken()) {
+		return
+	}
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	if routes.opts.Deletion == nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	dryRun, err := parseBoolQuery(r, "dry_run")
+	if err != nil {
+		_ = writeErrorResponse(w, "invalid_argument", "invalid dry_run", err.Error(), http.StatusBadRequest)
+		return
+	}
+	opts := deletion.PruneOptions{DryRun: dryRun}
+	if raw := readQueryValue(r, "older_than"); raw != "" {
+		olderThan, err := time.ParseDuration(raw)
+		if err != nil || olderThan < 0 {
+			details := "must be a non-negative duration"
+			if err != nil {
+				details = err.Error()
+			}
+			_ = writeErrorResponse(w, "invalid_argument", "invalid older_than", details, http.StatusBadRequest)
+			return
+		}
+		opts.OlderThan = &olderThan
+	}
+	result, err := routes.opts.Deletion.PruneStates(r.Context(), opts)
+	if err != nil {
+		log.Printf("prune states failed error=%v", err)
+		_ = writeErrorResponse(w, "internal_error", "prune states failed", err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_ = writeJSON(w, result)
+}
+
+// exportState streams a state archive. Errors found before the first byte is
+// written are reported as JSON; later ones can only abort the stream.
+func (routes registryRoutes) exportState(w http.ResponseWriter, r *http.Request, stateID string) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	if routes.opts.Prepare == nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	meta, ok, err := routes.opts.Prepare.StateExport(r.Context(), stateID)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if _, conflict := err.(prepare.ConflictError); conflict {
+			status = http.StatusConflict
+		}
+		_ = writeError(w, *prepare.ToErrorResponse(err), status)
+		return
+	}
+	if !ok {
+		_ = writeErrorResponse(w, "not_found", "state not found", "", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-tar")
+	w.Header().Set("Content-Disposition", `attachment; filename="sqlrs-state-`+meta.StateID+`.tar"`)
+	if err := routes.opts.Prepare.WriteStateArchive(r.Context(), meta, w); err != nil {
+		log.Printf("export state failed id=%s error=%v", meta.StateID, err)
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// stateSchema returns the schema-only dump of a state. Dumping starts a
+// throwaway container, so a cold request takes as long as a state start.
+func (routes registryRoutes) stateSchema(w http.ResponseWriter, r *http.Request, stateID string) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	if routes.opts.Prepare == nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	schema, ok, err := routes.opts.Prepare.StateSchema(r.Context(), stateID)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if _, unavailable := err.(prepare.UnavailableError); unavailable {
+			status = http.StatusServiceUnavailable
+		} else {
+			log.Printf("state schema failed id=%s error=%v", stateID, err)
+		}
+		_ = writeError(w, *prepare.ToErrorResponse(err), status)
+		return
+	}
+	if !ok {
+		_ = writeErrorResponse(w, "not_found", "state not found", stateID, http.StatusNotFound)
+		return
+	}
+	_ = writeJSON(w, schema)
+}
+
+// stateTagRequest is the POST /v1/states/{id}/tags payload.
+type stateTagRequest struct {
+	Name string `json:"name"`
+}
+
+// tagState attaches a name to a state, or re-points an existing state tag.
+func (routes registryRoutes) tagState(w http.ResponseWriter, r *http.Request, stateID string) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	var req stateTagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		_ = writeErrorResponse(w, "invalid_argument", "invalid json payload", err.Error(), http.StatusBadRequest)
+		return
+	}
+	entry, ok, err := routes.opts.Registry.TagState(r.Context(), stateID, req.Name)
+	switch {
+	case errors.Is(err, registry.ErrInvalidName):
+		_ = writeErrorResponse(w, "invalid_argument", "invalid name", err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, store.ErrNameInUse):
+		_ = writeErrorResponse(w, "conflict", "name is in use by an instance", strings.TrimSpace(req.Name), http.StatusConflict)
+		return
+	case err != nil:
+		log.Printf("tag state failed id=%s error=%v", stateID, err)
+		_ = writeErrorResponse(w, "internal_error", "tag state failed", err.Error(), http.StatusInternalServerError)
+		return
+	case !ok:
+		_ = writeErrorResponse(w, "not_found", "state not found", stateID, http.StatusNotFound)
 		return
 	}
 	_ = writeJSON(w, entry)
 }
 
+func (routes registryRoutes) handleStatesImport(w http.ResponseWriter, r *http.Request) {
+	if !auth.RequireBearer(w, r, routes.opts.authToken()) {
+		return
+	}
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	if routes.opts.Prepare == nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	result, err := routes.opts.Prepare.ImportState(r.Context(), r.Body)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if _, ok := err.(prepare.ValidationError); ok {
+			status = http.StatusBadRequest
+		} else {
+			log.Printf("import state failed error=%v", err)
+		}
+		_ = writeError(w, *prepare.ToErrorResponse(err), status)
+		return
+	}
+	status := http.StatusOK
+	if result.Created {
+		status = http.StatusCreated
+	}
+	_ = writeJSONStatus(w, result, status)
+}
+
 func (routes registryRoutes) deleteInstance(w http.ResponseWriter, r *http.Request, idOrName string) {
 	if routes.opts.Deletion == nil {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/sqlrs/engine-local/internal/auth"
@@ -19,7 +20,7 @@ func (routes runRoutes) register(mux *http.ServeMux) {
 }
 
 func (routes runRoutes) handleRuns(w http.ResponseWriter, r *http.Request) {
-	if !auth.RequireBearer(w, r, routes.opts.AuthToken) {
+	if !auth.RequireBearer(w, r, routes.opts.authToken()) {
 		return
 	}
 	if !requireMethod(w, r, http.MethodPost) {
@@ -36,20 +37,8 @@ func (routes runRoutes) handleRuns(w http.ResponseWriter, r *http.Request) {
 	}
 	result, err := routes.opts.Run.Run(r.Context(), req)
 	if err != nil {
-		switch err.(type) {
-		case run.ValidationError, *run.ValidationError:
-			_ = writeErrorResponse(w, "invalid_argument", err.Error(), "", http.StatusBadRequest)
-			return
-		case run.NotFoundError, *run.NotFoundError:
-			_ = writeErrorResponse(w, "not_found", err.Error(), "", http.StatusNotFound)
-			return
-		case run.ConflictError, *run.ConflictError:
-			_ = writeErrorResponse(w, "conflict", err.Error(), "", http.StatusConflict)
-			return
-		default:
-			_ = writeErrorResponse(w, "internal_error", "run failed", err.Error(), http.StatusInternalServerError)
-			return
-		}
+		writeRunError(w, "run failed", err)
+		return
 	}
 	w.Header().Set("Content-Type", "application/x-ndjson")
 	enc := json.NewEncoder(w)
@@ -82,3 +71,86 @@ func (routes runRoutes) handleRuns(w http.ResponseWriter, r *http.Request) {
 		ExitCode: &exitCode,
 	})
 }
+
+// handleInstanceExec serves POST /v1/instances/{id}/exec: ad-hoc psql against
+// a running persistent instance, with its output streamed as log events while
+// psql runs. Errors found before psql starts are plain error responses; later
+// ones end the stream with an error event.
+func (routes runRoutes) handleInstanceExec(w http.ResponseWriter, r *http.Request, instanceID string) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	if routes.opts.Run == nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	var req run.ExecRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		_ = writeErrorResponse(w, "invalid_argument", "invalid json payload", err.Error(), http.StatusBadRequest)
+		return
+	}
+	enc := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+	var mu sync.Mutex
+	started := false
+	emit := func(evt run.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		if !started {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			started = true
+		}
+		_ = enc.Encode(evt)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	result, err := routes.opts.Run.ExecStreaming(r.Context(), instanceID, req, emit)
+	if err != nil {
+		mu.Lock()
+		streaming := started
+		mu.Unlock()
+		if !streaming {
+			writeRunError(w, "exec failed", err)
+			return
+		}
+		emit(run.Event{
+			Type:  "error",
+			Ts:    time.Now().UTC().Format(time.RFC3339Nano),
+			Error: &run.ErrorPayload{Code: runErrorCode(err), Message: "exec failed", Details: err.Error()},
+		})
+		return
+	}
+	exitCode := result.ExitCode
+	emit(run.Event{
+		Type:     "exit",
+		Ts:       time.Now().UTC().Format(time.RFC3339Nano),
+		ExitCode: &exitCode,
+	})
+}
+
+func writeRunError(w http.ResponseWriter, message string, err error) {
+	switch code := runErrorCode(err); code {
+	case "invalid_argument":
+		_ = writeErrorResponse(w, code, err.Error(), "", http.StatusBadRequest)
+	case "not_found":
+		_ = writeErrorResponse(w, code, err.Error(), "", http.StatusNotFound)
+	case "conflict":
+		_ = writeErrorResponse(w, code, err.Error(), "", http.StatusConflict)
+	default:
+		_ = writeErrorResponse(w, code, message, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func runErrorCode(err error) string {
+	switch err.(type) {
+	case run.ValidationError, *run.ValidationError:
+		return "invalid_argument"
+	case run.NotFoundError, *run.NotFoundError:
+		return "not_found"
+	case run.ConflictError, *run.ConflictError:
+		return "conflict"
+	default:
+		return "internal_error"
+	}
+}
@@ -0,0 +1,64 @@
+package httpapi
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	engineRuntime "github.com/sqlrs/engine-local/internal/runtime"
+)
+
+const (
+	runtimeHealthTTL     = 5 * time.Second
+	runtimeHealthTimeout = 2 * time.Second
+)
+
+type containerRuntimeHealth struct {
+	Binary    string `json:"binary,omitempty"`
+	Available bool   `json:"available"`
+	Version   string `json:"version,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// runtimeHealthProbe pings the container runtime and caches the outcome so
+// frequent health checks do not spawn a process per request.
+type runtimeHealthProbe struct {
+	runtime engineRuntime.Runtime
+	now     func() time.Time
+
+	mu        sync.Mutex
+	checkedAt time.Time
+	last      containerRuntimeHealth
+}
+
+func newRuntimeHealthProbe(rt engineRuntime.Runtime) *runtimeHealthProbe {
+	if rt == nil {
+		return nil
+	}
+	return &runtimeHealthProbe{runtime: rt, now: time.Now}
+}
+
+func (p *runtimeHealthProbe) check(ctx context.Context) containerRuntimeHealth {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+	if !p.checkedAt.IsZero() && now.Sub(p.checkedAt) < runtimeHealthTTL {
+		return p.last
+	}
+	pingCtx, cancel := context.WithTimeout(ctx, runtimeHealthTimeout)
+	defer cancel()
+	health := containerRuntimeHealth{}
+	if named, ok := p.runtime.(interface{ Binary() string }); ok {
+		health.Binary = named.Binary()
+	}
+	version, err := p.runtime.Ping(pingCtx)
+	if err != nil {
+		health.Reason = err.Error()
+	} else {
+		health.Available = true
+		health.Version = version
+	}
+	p.checkedAt = now
+	p.last = health
+	return health
+}
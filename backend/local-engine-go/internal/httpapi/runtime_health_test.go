@@ -0,0 +1,101 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type pingRuntime struct {
+	fakeRunRuntime
+	version string
+	err     error
+	calls   int
+}
+
+func (p *pingRuntime) Ping(ctx context.Context) (string, error) {
+	p.calls++
+	return p.version, p.err
+}
+
+func (p *pingRuntime) Binary() string {
+	return "/usr/bin/docker"
+}
+
+func getHealth(t *testing.T, url string) healthResponse {
+	t.Helper()
+	resp, err := http.Get(url + "/v1/health")
+	if err != nil {
+		t.Fatalf("health request: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 for health, got %d", resp.StatusCode)
+	}
+	var health healthResponse
+	if err := json.NewDecoder(resp.Body).Decode(&health); err != nil {
+		t.Fatalf("decode health: %v", err)
+	}
+	return health
+}
+
+func TestHealthReportsContainerRuntime(t *testing.T) {
+	rt := &pingRuntime{version: "27.0.1"}
+	server := httptest.NewServer(NewHandler(Options{Version: "test", InstanceID: "instance", Runtime: rt}))
+	defer server.Close()
+
+	health := getHealth(t, server.URL)
+	if !health.Ok || health.Reason != "" {
+		t.Fatalf("expected ok health, got %+v", health)
+	}
+	if health.ContainerRuntime == nil || !health.ContainerRuntime.Available || health.ContainerRuntime.Version != "27.0.1" || health.ContainerRuntime.Binary != "/usr/bin/docker" {
+		t.Fatalf("unexpected container runtime health: %+v", health.ContainerRuntime)
+	}
+}
+
+func TestHealthReportsUnavailableRuntimeWith200(t *testing.T) {
+	rt := &pingRuntime{err: errors.New("cannot connect to the docker daemon")}
+	server := httptest.NewServer(NewHandler(Options{Version: "test", InstanceID: "instance", Runtime: rt}))
+	defer server.Close()
+
+	health := getHealth(t, server.URL)
+	if health.Ok || health.Reason != "container runtime unavailable" {
+		t.Fatalf("expected not-ok health with reason, got %+v", health)
+	}
+	if health.ContainerRuntime == nil || health.ContainerRuntime.Available || health.ContainerRuntime.Reason != "cannot connect to the docker daemon" {
+		t.Fatalf("unexpected container runtime health: %+v", health.ContainerRuntime)
+	}
+}
+
+func TestHealthWithoutRuntimeOmitsContainerRuntime(t *testing.T) {
+	server := httptest.NewServer(NewHandler(Options{Version: "test", InstanceID: "instance"}))
+	defer server.Close()
+
+	health := getHealth(t, server.URL)
+	if !health.Ok || health.ContainerRuntime != nil {
+		t.Fatalf("expected plain ok health, got %+v", health)
+	}
+}
+
+func TestRuntimeHealthProbeCachesResult(t *testing.T) {
+	rt := &pingRuntime{version: "1.0"}
+	probe := newRuntimeHealthProbe(rt)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	probe.now = func() time.Time { return now }
+
+	probe.check(context.Background())
+	now = now.Add(runtimeHealthTTL - time.Second)
+	probe.check(context.Background())
+	if rt.calls != 1 {
+		t.Fatalf("expected cached ping, got %d calls", rt.calls)
+	}
+	now = now.Add(2 * time.Second)
+	probe.check(context.Background())
+	if rt.calls != 2 {
+		t.Fatalf("expected ping after ttl, got %d calls", rt.calls)
+	}
+}
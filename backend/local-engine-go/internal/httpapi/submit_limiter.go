@@ -0,0 +1,81 @@
+package httpapi
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/sqlrs/engine-local/internal/config"
+)
+
+const defaultSubmitRate = 20
+
+// submitLimiter is a token bucket shared by every POST /v1/prepare-jobs. The
+// engine is single-tenant, so one global bucket is enough. The bucket refills
+// at orchestrator.jobs.submitRate tokens per second and holds up to one
+// second's worth of tokens; a rate of 0 disables the limit.
+type submitLimiter struct {
+	config config.Store
+	now    func() time.Time
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newSubmitLimiter(cfg config.Store) *submitLimiter {
+	if cfg == nil {
+		return nil
+	}
+	return &submitLimiter{config: cfg, now: time.Now, tokens: -1}
+}
+
+// allow takes a token when one is available. Otherwise it returns false and
+// how long the caller should wait before the next token.
+func (l *submitLimiter) allow() (bool, time.Duration) {
+	if l == nil {
+		return true, 0
+	}
+	rate := l.rate()
+	if rate <= 0 {
+		return true, 0
+	}
+	burst := math.Max(1, rate)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	if l.tokens < 0 {
+		l.tokens = burst
+	} else {
+		l.tokens = math.Min(burst, l.tokens+now.Sub(l.last).Seconds()*rate)
+	}
+	l.last = now
+	if l.tokens >= 1 {
+		l.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - l.tokens) / rate * float64(time.Second))
+	return false, wait
+}
+
+func (l *submitLimiter) rate() float64 {
+	value, err := l.config.Get("orchestrator.jobs.submitRate", true)
+	if err != nil || value == nil {
+		return defaultSubmitRate
+	}
+	switch v := value.(type) {
+	case float64:
+		if v >= 0 {
+			return v
+		}
+	case int:
+		if v >= 0 {
+			return float64(v)
+		}
+	case int64:
+		if v >= 0 {
+			return float64(v)
+		}
+	}
+	return defaultSubmitRate
+}
@@ -0,0 +1,71 @@
+package httpapi
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSubmitLimiterTokenBucket(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := newSubmitLimiter(&fakeConfig{getValue: float64(2)})
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := limiter.allow(); !ok {
+			t.Fatalf("expected burst submit %d to be allowed", i)
+		}
+	}
+	ok, wait := limiter.allow()
+	if ok || wait != 500*time.Millisecond {
+		t.Fatalf("expected submit to be limited for 500ms, got ok=%v wait=%v", ok, wait)
+	}
+	now = now.Add(500 * time.Millisecond)
+	if ok, _ := limiter.allow(); !ok {
+		t.Fatalf("expected refilled token to be allowed")
+	}
+}
+
+func TestSubmitLimiterDisabled(t *testing.T) {
+	var nilLimiter *submitLimiter
+	if ok, _ := nilLimiter.allow(); !ok {
+		t.Fatalf("expected nil limiter to allow")
+	}
+	limiter := newSubmitLimiter(&fakeConfig{getValue: float64(0)})
+	for i := 0; i < 100; i++ {
+		if ok, _ := limiter.allow(); !ok {
+			t.Fatalf("expected zero rate to disable the limit")
+		}
+	}
+	limiter = newSubmitLimiter(&fakeConfig{getErr: errors.New("boom")})
+	if rate := limiter.rate(); rate != defaultSubmitRate {
+		t.Fatalf("expected default rate, got %v", rate)
+	}
+}
+
+func TestPrepareSubmitRateLimited(t *testing.T) {
+	opts, cleanup := newRouteTestOptions(t)
+	defer cleanup()
+	opts.Config = &fakeConfig{getValue: float64(0.5)}
+
+	mux := http.NewServeMux()
+	prepareRoutes{opts: opts}.register(mux)
+
+	codes := make([]int, 0, 2)
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/v1/prepare-jobs", strings.NewReader("{"))
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		codes = append(codes, rec.Code)
+		if rec.Code == http.StatusTooManyRequests && rec.Header().Get("Retry-After") != "2" {
+			t.Fatalf("expected Retry-After 2, got %q", rec.Header().Get("Retry-After"))
+		}
+	}
+	if codes[0] != http.StatusBadRequest || codes[1] != http.StatusTooManyRequests {
+		t.Fatalf("expected only the second submit to be limited, got %v", codes)
+	}
+}
@@ -0,0 +1,264 @@
+package httpapi
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// websocketGUID is the fixed key suffix from RFC 6455 section 1.3.
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+const (
+	wsOpContinuation = 0x0
+	wsOpText         = 0x1
+	wsOpBinary       = 0x2
+	wsOpClose        = 0x8
+	wsOpPing         = 0x9
+	wsOpPong         = 0xA
+)
+
+const (
+	wsCloseNormal          = 1000
+	wsCloseProtocolError   = 1002
+	wsCloseInternalError   = 1011
+	wsMaxControlPayload    = 125
+	wsMaxClientMessageSize = 64 << 10
+)
+
+var errWebSocketClosed = errors.New("websocket closed")
+
+// wsConn is the small server-side subset of RFC 6455 the engine needs: text
+// frames out, text and control frames in. Writes are serialized so the
+// reader can answer pings while the handler streams events.
+type wsConn struct {
+	conn    net.Conn
+	rw      *bufio.ReadWriter
+	writeMu sync.Mutex
+	closed  bool
+}
+
+func isWebSocketUpgrade(r *http.Request) bool {
+	return headerContainsToken(r.Header, "Connection", "upgrade") && headerContainsToken(r.Header, "Upgrade", "websocket")
+}
+
+// upgradeWebSocket validates the handshake and hijacks the connection. On
+// failure it has already written an error response.
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (*wsConn, bool) {
+	if !isWebSocketUpgrade(r) {
+		_ = writeErrorResponse(w, "invalid_argument", "websocket upgrade required", "", http.StatusBadRequest)
+		return nil, false
+	}
+	if strings.TrimSpace(r.Header.Get("Sec-WebSocket-Version")) != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		_ = writeErrorResponse(w, "invalid_argument", "unsupported websocket version", "", http.StatusUpgradeRequired)
+		return nil, false
+	}
+	key := strings.TrimSpace(r.Header.Get("Sec-WebSocket-Key"))
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		_ = writeErrorResponse(w, "invalid_argument", "invalid Sec-WebSocket-Key", "", http.StatusBadRequest)
+		return nil, false
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil, false
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil, false
+	}
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + websocketAccept(key) + "\r\n\r\n"
+	if _, err := rw.WriteString(response); err != nil {
+		_ = conn.Close()
+		return nil, false
+	}
+	if err := rw.Flush(); err != nil {
+		_ = conn.Close()
+		return nil, false
+	}
+	return &wsConn{conn: conn, rw: rw}, true
+}
+
+func websocketAccept(key string) string {
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func headerContainsToken(header http.Header, name string, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c *wsConn) WriteText(payload []byte) error {
+	return c.writeFrame(wsOpText, payload)
+}
+
+// Close sends a close frame with the given code and closes the connection.
+// It is safe to call more than once.
+func (c *wsConn) Close(code int, reason string) error {
+	c.writeMu.Lock()
+	if c.closed {
+		c.writeMu.Unlock()
+		return nil
+	}
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	payload = append(payload, reason...)
+	if len(payload) > wsMaxControlPayload {
+		payload = payload[:wsMaxControlPayload]
+	}
+	err := c.writeFrameLocked(wsOpClose, payload)
+	c.closed = true
+	c.writeMu.Unlock()
+	if closeErr := c.conn.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closed {
+		return errWebSocketClosed
+	}
+	return c.writeFrameLocked(opcode, payload)
+}
+
+func (c *wsConn) writeFrameLocked(opcode byte, payload []byte) error {
+	header := []byte{0x80 | opcode}
+	switch n := len(payload); {
+	case n <= 125:
+		header = append(header, byte(n))
+	case n <= 0xFFFF:
+		header = append(header, 126, 0, 0)
+		binary.BigEndian.PutUint16(header[2:], uint16(n))
+	default:
+		header = append(header, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(header[2:], uint64(n))
+	}
+	if _, err := c.rw.Write(header); err != nil {
+		return err
+	}
+	if _, err := c.rw.Write(payload); err != nil {
+		return err
+	}
+	return c.rw.Flush()
+}
+
+// ReadMessage returns the next complete text or binary message. Pings are
+// answered, pongs ignored, and a close frame ends the stream with
+// errWebSocketClosed after echoing the close.
+func (c *wsConn) ReadMessage() ([]byte, error) {
+	var message []byte
+	fragmented := false
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+		switch opcode {
+		case wsOpPing:
+			if err := c.writeFrame(wsOpPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case wsOpPong:
+			continue
+		case wsOpClose:
+			_ = c.Close(wsCloseNormal, "")
+			return nil, errWebSocketClosed
+		case wsOpText, wsOpBinary:
+			if fragmented {
+				return nil, c.protocolError("unexpected data frame inside fragmented message")
+			}
+			message = payload
+		case wsOpContinuation:
+			if !fragmented {
+				return nil, c.protocolError("unexpected continuation frame")
+			}
+			message = append(message, payload...)
+		default:
+			return nil, c.protocolError(fmt.Sprintf("unknown opcode %d", opcode))
+		}
+		if len(message) > wsMaxClientMessageSize {
+			return nil, c.protocolError("message too large")
+		}
+		if fin {
+			return message, nil
+		}
+		fragmented = true
+	}
+}
+
+func (c *wsConn) readFrame() (bool, byte, []byte, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(c.rw, head[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin := head[0]&0x80 != 0
+	opcode := head[0] & 0x0F
+	if head[0]&0x70 != 0 {
+		return false, 0, nil, c.protocolError("reserved bits set")
+	}
+	if head[1]&0x80 == 0 {
+		return false, 0, nil, c.protocolError("client frames must be masked")
+	}
+	length := uint64(head[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.rw, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.rw, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if opcode >= wsOpClose && (length > wsMaxControlPayload || !fin) {
+		return false, 0, nil, c.protocolError("invalid control frame")
+	}
+	if length > wsMaxClientMessageSize {
+		return false, 0, nil, c.protocolError("frame too large")
+	}
+	var mask [4]byte
+	if _, err := io.ReadFull(c.rw, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.rw, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, opcode, payload, nil
+}
+
+func (c *wsConn) protocolError(reason string) error {
+	_ = c.Close(wsCloseProtocolError, reason)
+	return fmt.Errorf("websocket protocol error: %s", reason)
+}
@@ -0,0 +1,43 @@
+// Package loglevel implements the engine's log.level verbosity matrix:
+// error < warn < info < debug, where each level also shows everything the
+// levels before it show.
+package loglevel
+
+import "strings"
+
+const (
+	Debug = "debug"
+	Info  = "info"
+	Warn  = "warn"
+	Error = "error"
+)
+
+// Normalize lowercases and trims level. Empty and unknown values map to
+// Debug, the engine default.
+func Normalize(level string) string {
+	switch value := strings.ToLower(strings.TrimSpace(level)); value {
+	case Info, Warn, Error:
+		return value
+	default:
+		return Debug
+	}
+}
+
+// Allows reports whether a message of severity min is logged when the engine
+// runs at level.
+func Allows(level string, min string) bool {
+	return rank(Normalize(min)) >= rank(Normalize(level))
+}
+
+func rank(level string) int {
+	switch level {
+	case Error:
+		return 3
+	case Warn:
+		return 2
+	case Info:
+		return 1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,42 @@
+package loglevel
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	cases := map[string]string{
+		"":        Debug,
+		" INFO ":  Info,
+		"warn":    Warn,
+		"Error":   Error,
+		"verbose": Debug,
+	}
+	for input, want := range cases {
+		if got := Normalize(input); got != want {
+			t.Fatalf("Normalize(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestAllowsMatrix(t *testing.T) {
+	levels := []string{Debug, Info, Warn, Error}
+	want := map[string][]string{
+		Debug: {Debug, Info, Warn, Error},
+		Info:  {Info, Warn, Error},
+		Warn:  {Warn, Error},
+		Error: {Error},
+	}
+	for _, level := range levels {
+		allowed := map[string]bool{}
+		for _, min := range want[level] {
+			allowed[min] = true
+		}
+		for _, min := range levels {
+			if got := Allows(level, min); got != allowed[min] {
+				t.Fatalf("Allows(%q, %q) = %t, want %t", level, min, got, allowed[min])
+			}
+		}
+	}
+	if !Allows("", Debug) {
+		t.Fatalf("expected empty level to default to debug")
+	}
+}
@@ -0,0 +1,54 @@
+package prepare
+
+import (
+	"context"
+	"sync"
+)
+
+// imageLocks serializes work per image within the engine. The zero value is
+// ready to use; entries are dropped once no caller holds or waits on them.
+type imageLocks struct {
+	mu    sync.Mutex
+	locks map[string]*imageLock
+}
+
+type imageLock struct {
+	ch   chan struct{}
+	refs int
+}
+
+// acquire blocks until the lock for key is free or ctx is done. The returned
+// release must be called exactly once.
+func (l *imageLocks) acquire(ctx context.Context, key string) (func(), error) {
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = map[string]*imageLock{}
+	}
+	lock := l.locks[key]
+	if lock == nil {
+		lock = &imageLock{ch: make(chan struct{}, 1)}
+		l.locks[key] = lock
+	}
+	lock.refs++
+	l.mu.Unlock()
+
+	select {
+	case lock.ch <- struct{}{}:
+	case <-ctx.Done():
+		l.unref(key, lock)
+		return nil, ctx.Err()
+	}
+	return func() {
+		<-lock.ch
+		l.unref(key, lock)
+	}, nil
+}
+
+func (l *imageLocks) unref(key string, lock *imageLock) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	lock.refs--
+	if lock.refs == 0 && l.locks[key] == lock {
+		delete(l.locks, key)
+	}
+}
@@ -0,0 +1,170 @@
+package prepare
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// parallelInitRuntime counts InitBase calls per image. Calls for an image in
+// waitFor block until InitBase has started for the image it names.
+type parallelInitRuntime struct {
+	noPgRuntime
+	mu      sync.Mutex
+	calls   map[string]int
+	started map[string]chan struct{}
+	waitFor map[string]string
+	failN   int
+}
+
+func newParallelInitRuntime(images ...string) *parallelInitRuntime {
+	rt := &parallelInitRuntime{calls: map[string]int{}, started: map[string]chan struct{}{}, waitFor: map[string]string{}}
+	for _, image := range images {
+		rt.started[image] = make(chan struct{})
+	}
+	return rt
+}
+
+func (r *parallelInitRuntime) InitBase(ctx context.Context, imageID string, dataDir string) error {
+	r.mu.Lock()
+	r.calls[imageID]++
+	first := r.calls[imageID] == 1
+	fail := r.failN > 0
+	if fail {
+		r.failN--
+	}
+	r.mu.Unlock()
+	if first {
+		close(r.started[imageID])
+	}
+	if other, ok := r.waitFor[imageID]; ok {
+		select {
+		case <-r.started[other]:
+		case <-time.After(2 * time.Second):
+			return errors.New("init for " + other + " never started")
+		}
+	}
+	// Widen the window in which an unserialized caller would also init.
+	time.Sleep(20 * time.Millisecond)
+	if fail {
+		return errors.New("init failed")
+	}
+	if err := os.MkdirAll(dataDir, 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dataDir, "PG_VERSION"), []byte("17"), 0o600)
+}
+
+func (r *parallelInitRuntime) initCalls(imageID string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.calls[imageID]
+}
+
+func runConcurrently(fns ...func() error) []error {
+	errs := make([]error, len(fns))
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i, fn := range fns {
+		wg.Add(1)
+		go func(i int, fn func() error) {
+			defer wg.Done()
+			<-start
+			errs[i] = fn()
+		}(i, fn)
+	}
+	close(start)
+	wg.Wait()
+	return errs
+}
+
+func TestEnsureBaseStateSameImageInitsOnce(t *testing.T) {
+	runtime := newParallelInitRuntime("image-1")
+	mgr := newManagerWithRuntime(t, runtime)
+	baseDir := filepath.Join(t.TempDir(), "base")
+
+	errs := runConcurrently(
+		func() error { return mgr.ensureBaseState(context.Background(), "image-1", baseDir) },
+		func() error { return mgr.ensureBaseState(context.Background(), "image-1", baseDir) },
+	)
+	for _, err := range errs {
+		if err != nil {
+			t.Fatalf("ensureBaseState: %v", err)
+		}
+	}
+	if calls := runtime.initCalls("image-1"); calls != 1 {
+		t.Fatalf("expected a single InitBase call, got %d", calls)
+	}
+	if len(mgr.baseInit.locks) != 0 {
+		t.Fatalf("expected image locks to be released, got %v", mgr.baseInit.locks)
+	}
+}
+
+func TestEnsureBaseStateDifferentImagesRunInParallel(t *testing.T) {
+	runtime := newParallelInitRuntime("image-a", "image-b")
+	runtime.waitFor["image-a"] = "image-b"
+	runtime.waitFor["image-b"] = "image-a"
+	mgr := newManagerWithRuntime(t, runtime)
+	root := t.TempDir()
+
+	errs := runConcurrently(
+		func() error {
+			return mgr.ensureBaseState(context.Background(), "image-a", filepath.Join(root, "a", "base"))
+		},
+		func() error {
+			return mgr.ensureBaseState(context.Background(), "image-b", filepath.Join(root, "b", "base"))
+		},
+	)
+	for _, err := range errs {
+		if err != nil {
+			t.Fatalf("expected both images to init concurrently, got %v", err)
+		}
+	}
+}
+
+func TestEnsureBaseStateReleasesLockOnInitFailure(t *testing.T) {
+	runtime := newParallelInitRuntime("image-1")
+	runtime.failN = 1
+	mgr := newManagerWithRuntime(t, runtime)
+	baseDir := filepath.Join(t.TempDir(), "base")
+
+	if err := mgr.ensureBaseState(context.Background(), "image-1", baseDir); err == nil {
+		t.Fatalf("expected init failure")
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- mgr.ensureBaseState(context.Background(), "image-1", baseDir)
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("ensureBaseState after failure: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("lock was not released after init failure")
+	}
+	if calls := runtime.initCalls("image-1"); calls != 2 {
+		t.Fatalf("expected retry to init again, got %d calls", calls)
+	}
+}
+
+func TestImageLocksAcquireHonorsContext(t *testing.T) {
+	var locks imageLocks
+	release, err := locks.acquire(context.Background(), "image-1")
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := locks.acquire(ctx, "image-1"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	release()
+	if len(locks.locks) != 0 {
+		t.Fatalf("expected lock entry to be dropped, got %v", locks.locks)
+	}
+}
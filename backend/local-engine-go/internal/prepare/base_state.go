@@ -0,0 +1,90 @@
+package prepare
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sqlrs/engine-local/internal/store"
+)
+
+// resolveBaseState loads the state a job builds on. stateID may also be a
+// state tag (see POST /v1/states/{id}/tags); the caller records the resolved
+// ID, so re-pointing the tag later does not change the job. The job is pinned
+// to the image the base state was built from, so imageID may be empty;
+// otherwise it must name that image (or, without a digest, the same
+// repository).
+func (m *PrepareService) resolveBaseState(stateID string, imageID string, namespace string) (store.StateEntry, error) {
+	ctx := context.Background()
+	entry, ok, err := m.store.GetState(ctx, stateID)
+	if err != nil {
+		return store.StateEntry{}, err
+	}
+	if !ok {
+		name, named, err := m.store.GetName(ctx, stateID)
+		if err != nil {
+			return store.StateEntry{}, err
+		}
+		if named && name.InstanceID == nil && name.StateID != "" {
+			entry, ok, err = m.store.GetState(ctx, name.StateID)
+			if err != nil {
+				return store.StateEntry{}, err
+			}
+		}
+	}
+	if !ok {
+		return store.StateEntry{}, ValidationError{Code: ErrorCodeInvalidArgument, Message: "base state not found", Details: stateID}
+	}
+	if entry.Namespace != namespace {
+		return store.StateEntry{}, ValidationError{
+			Code:    ErrorCodeInvalidArgument,
+			Message: "base state belongs to a different namespace",
+			Details: fmt.Sprintf("base_state_id=%s namespace=%q", stateID, entry.Namespace),
+		}
+	}
+	if strings.TrimSpace(entry.ImageID) == "" {
+		return store.StateEntry{}, ValidationError{Code: ErrorCodeInvalidArgument, Message: "base state has no image", Details: stateID}
+	}
+	if imageID != "" && !baseStateImageMatches(imageID, entry.ImageID) {
+		return store.StateEntry{}, ValidationError{
+			Code:    ErrorCodeInvalidArgument,
+			Message: "image_id does not match base state image",
+			Details: fmt.Sprintf("image_id=%s base_state_image_id=%s", imageID, entry.ImageID),
+		}
+	}
+	return entry, nil
+}
+
+// baseStateImageMatches compares a requested image with the image of the base
+// state. A tag cannot be checked against a digest without a registry lookup,
+// so a reference without a digest only has to name the same repository.
+func baseStateImageMatches(imageID string, baseImageID string) bool {
+	if imageID == baseImageID {
+		return true
+	}
+	if hasImageDigest(imageID) {
+		return digestKeyedImageID(imageID) == digestKeyedImageID(baseImageID)
+	}
+	return imageRepository(imageID) == imageRepository(baseImageID)
+}
+
+// imageRepository strips the digest and tag from an image reference.
+func imageRepository(imageID string) string {
+	repo := strings.TrimSpace(imageID)
+	if at := strings.LastIndex(repo, "@"); at != -1 {
+		repo = repo[:at]
+	}
+	if colon := strings.LastIndex(repo, ":"); colon > strings.LastIndex(repo, "/") {
+		repo = repo[:colon]
+	}
+	return repo
+}
+
+// baseInput is the input of the job's first state_execute task: the base
+// state when one is set, otherwise the image.
+func (p preparedRequest) baseInput() (string, string) {
+	if p.request.BaseStateID != "" {
+		return "state", p.request.BaseStateID
+	}
+	return "image", p.effectiveImageID()
+}
@@ -0,0 +1,151 @@
+package prepare
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/sqlrs/engine-local/internal/statefs"
+	"github.com/sqlrs/engine-local/internal/store"
+)
+
+func TestPrepareRequestBaseStatePinsImage(t *testing.T) {
+	st := &fakeStore{statesByID: map[string]store.StateEntry{
+		"base-1": {StateID: "base-1", ImageID: "postgres@sha256:abc"},
+		"ns-1":   {StateID: "ns-1", ImageID: "postgres@sha256:abc", Namespace: "team-a"},
+	}}
+	mgr := newManagerWithStateFS(t, st, &fakeStateFS{})
+
+	for _, imageID := range []string{"", "postgres:17", "postgres@sha256:abc", "postgres:17@sha256:abc"} {
+		prepared, err := mgr.prepareRequest(Request{PrepareKind: "psql", ImageID: imageID, BaseStateID: " base-1 ", PsqlArgs: []string{"-c", "select 1"}})
+		if err != nil {
+			t.Fatalf("image %q: prepareRequest: %v", imageID, err)
+		}
+		if prepared.request.BaseStateID != "base-1" || prepared.request.ImageID != "postgres@sha256:abc" || prepared.effectiveImageID() != "postgres@sha256:abc" {
+			t.Fatalf("image %q: expected job pinned to base image, got %+v resolved=%q", imageID, prepared.request, prepared.resolvedImageID)
+		}
+		if kind, id := prepared.baseInput(); kind != "state" || id != "base-1" {
+			t.Fatalf("image %q: unexpected base input %s/%s", imageID, kind, id)
+		}
+	}
+
+	cases := []Request{
+		{BaseStateID: "missing"},
+		{BaseStateID: "base-1", ImageID: "mysql:8"},
+		{BaseStateID: "base-1", ImageID: "postgres@sha256:def"},
+		{BaseStateID: "ns-1"},
+	}
+	for _, req := range cases {
+		req.PrepareKind = "psql"
+		req.PsqlArgs = []string{"-c", "select 1"}
+		_, err := mgr.prepareRequest(req)
+		var validation ValidationError
+		if !errors.As(err, &validation) || validation.Code != "invalid_argument" {
+			t.Fatalf("request %+v: expected validation error, got %v", req, err)
+		}
+	}
+
+	st.getStateErr = errors.New("db down")
+	if _, err := mgr.prepareRequest(Request{PrepareKind: "psql", BaseStateID: "base-1", PsqlArgs: []string{"-c", "select 1"}}); err == nil {
+		t.Fatalf("expected store error")
+	}
+}
+
+func TestPrepareRequestBaseStateAcceptsTag(t *testing.T) {
+	instanceID := "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	st := &fakeStore{
+		statesByID: map[string]store.StateEntry{
+			"base-1": {StateID: "base-1", ImageID: "postgres@sha256:abc"},
+		},
+		names: map[string]store.NameEntry{
+			"my-seed": {Name: "my-seed", StateID: "base-1", Status: store.NameStatusActive},
+			"dev":     {Name: "dev", InstanceID: &instanceID, StateID: "base-1", Status: store.NameStatusActive},
+			"gone":    {Name: "gone", StateID: "base-0", Status: store.NameStatusMissing},
+		},
+	}
+	mgr := newManagerWithStateFS(t, st, &fakeStateFS{})
+
+	prepared, err := mgr.prepareRequest(Request{PrepareKind: "psql", BaseStateID: "my-seed", PsqlArgs: []string{"-c", "select 1"}})
+	if err != nil {
+		t.Fatalf("prepareRequest: %v", err)
+	}
+	if prepared.request.BaseStateID != "base-1" || prepared.request.ImageID != "postgres@sha256:abc" {
+		t.Fatalf("expected the tag to be replaced by its state, got %+v", prepared.request)
+	}
+
+	for _, name := range []string{"dev", "gone"} {
+		_, err := mgr.prepareRequest(Request{PrepareKind: "psql", BaseStateID: name, PsqlArgs: []string{"-c", "select 1"}})
+		var validation ValidationError
+		if !errors.As(err, &validation) || validation.Message != "base state not found" {
+			t.Fatalf("name %q: expected base state not found, got %v", name, err)
+		}
+	}
+}
+
+func TestBaseStateJobBuildsOnBaseState(t *testing.T) {
+	st := &fakeStore{}
+	mgr := newManagerWithStateFS(t, st, statefs.NewManager(statefs.Options{Backend: "copy"}))
+
+	base, err := mgr.prepareRequest(Request{PrepareKind: "psql", ImageID: "image-1", PsqlArgs: []string{"-c", "create table t(id int)"}})
+	if err != nil {
+		t.Fatalf("prepareRequest base: %v", err)
+	}
+	baseTasks, baseStateID, errResp := mgr.buildPlanPsql(base)
+	if errResp != nil {
+		t.Fatalf("buildPlanPsql base: %+v", errResp)
+	}
+	if _, errResp := mgr.executeStateTask(context.Background(), "job-1", base, taskState{PlanTask: baseTasks[len(baseTasks)-2]}); errResp != nil {
+		t.Fatalf("executeStateTask base: %+v", errResp)
+	}
+
+	req := Request{PrepareKind: "psql", BaseStateID: baseStateID, PsqlArgs: []string{"-c", "insert into t values (1)"}}
+	layered, err := mgr.prepareRequest(req)
+	if err != nil {
+		t.Fatalf("prepareRequest layered: %v", err)
+	}
+	if layered.request.ImageID != "image-1" {
+		t.Fatalf("expected base state image, got %q", layered.request.ImageID)
+	}
+	tasks, stateID, errResp := mgr.buildPlanPsql(layered)
+	if errResp != nil {
+		t.Fatalf("buildPlanPsql layered: %+v", errResp)
+	}
+	execute := tasks[len(tasks)-2]
+	if execute.Type != "state_execute" || execute.Input == nil || execute.Input.Kind != "state" || execute.Input.ID != baseStateID {
+		t.Fatalf("expected first input to be the base state, got %+v", execute)
+	}
+
+	// The same script on the image yields a different state and signature.
+	plain, err := mgr.prepareRequest(Request{PrepareKind: "psql", ImageID: "image-1", PsqlArgs: req.PsqlArgs})
+	if err != nil {
+		t.Fatalf("prepareRequest plain: %v", err)
+	}
+	_, plainStateID, errResp := mgr.buildPlanPsql(plain)
+	if errResp != nil {
+		t.Fatalf("buildPlanPsql plain: %+v", errResp)
+	}
+	if plainStateID == stateID {
+		t.Fatalf("expected base state to change the output state id")
+	}
+	layeredSig, errResp := mgr.computeJobSignature(layered)
+	if errResp != nil {
+		t.Fatalf("computeJobSignature layered: %+v", errResp)
+	}
+	plainSig, errResp := mgr.computeJobSignature(plain)
+	if errResp != nil {
+		t.Fatalf("computeJobSignature plain: %+v", errResp)
+	}
+	if layeredSig == plainSig {
+		t.Fatalf("expected base state to change the job signature")
+	}
+
+	if _, errResp := mgr.executeStateTask(context.Background(), "job-2", layered, taskState{PlanTask: execute}); errResp != nil {
+		t.Fatalf("executeStateTask layered: %+v", errResp)
+	}
+	if len(st.states) != 2 || st.states[1].StateID != stateID {
+		t.Fatalf("expected layered state to be stored, got %+v", st.states)
+	}
+	if parent := st.states[1].ParentStateID; parent == nil || *parent != baseStateID {
+		t.Fatalf("expected parent state %s, got %v", baseStateID, parent)
+	}
+}
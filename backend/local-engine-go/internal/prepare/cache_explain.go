@@ -26,29 +26,24 @@ func (m *PrepareService) CacheExplain(ctx context.Context, req Request) (result
 	if errResp := m.ensureResolvedImageID(ctx, "", &prepared, nil); errResp != nil {
 		return CacheExplainPrepareResult{}, errorFromExplainResponse(errResp)
 	}
-
-	planner := m
-	jobID := ""
-	if prepared.request.PrepareKind == "lb" {
-		var cleanup func() error
-		planner, jobID, cleanup, err = m.newCacheExplainPlanner()
-		if err != nil {
-			return CacheExplainPrepareResult{}, err
-		}
-		defer func() {
-			if cleanupErr := cleanup(); err == nil && cleanupErr != nil {
-				err = cleanupErr
-			}
-		}()
+	// Explaining never builds: without the built image a runtime-planned
+	// request cannot be planned, so the cache cannot be looked up.
+	if m.buildImagePending(ctx, prepared) {
+		return CacheExplainPrepareResult{
+			Decision:        "miss",
+			ReasonCode:      "cache_lookup_unavailable",
+			ResolvedImageID: prepared.effectiveImageID(),
+		}, nil
 	}
 
-	tasks, stateID, errResp := planner.buildPlan(ctx, jobID, prepared)
-	if errResp != nil {
-		return CacheExplainPrepareResult{}, errorFromExplainResponse(errResp)
+	tasks, stateID, err := m.planWithoutJob(ctx, prepared)
+	if err != nil {
+		return CacheExplainPrepareResult{}, err
 	}
 
+	var errResp *ErrorResponse
 	signature := ""
-	if prepared.request.PrepareKind == "lb" {
+	if plansFromRuntime(prepared.request.PrepareKind) {
 		signature, errResp = m.computeJobSignatureFromPlan(prepared, tasks)
 	} else {
 		signature, errResp = m.computeJobSignature(prepared)
@@ -57,7 +52,7 @@ func (m *PrepareService) CacheExplain(ctx context.Context, req Request) (result
 		return CacheExplainPrepareResult{}, errorFromExplainResponse(errResp)
 	}
 
-	cached, err := m.isStateCached(stateID)
+	cached, err := m.isStateCachedForPlan(prepared, stateID)
 	if err != nil {
 		return CacheExplainPrepareResult{}, err
 	}
@@ -68,6 +63,9 @@ func (m *PrepareService) CacheExplain(ctx context.Context, req Request) (result
 		Signature:       signature,
 		ResolvedImageID: prepared.effectiveImageID(),
 	}
+	if prepared.request.NoCache {
+		result.ReasonCode = "no_cache"
+	}
 	if cached {
 		result.Decision = "hit"
 		result.ReasonCode = "exact_state_match"
@@ -76,30 +74,61 @@ func (m *PrepareService) CacheExplain(ctx context.Context, req Request) (result
 	return result, nil
 }
 
+// planWithoutJob builds the plan of a prepared request outside any job and
+// returns its tasks and final state ID. Kinds planned from the runtime run
+// their planner against a scratch state store that is removed afterwards.
+func (m *PrepareService) planWithoutJob(ctx context.Context, prepared preparedRequest) (tasks []PlanTask, stateID string, err error) {
+	planner := m
+	jobID := ""
+	if plansFromRuntime(prepared.request.PrepareKind) {
+		if err := writeLiquibaseStdin(prepared); err != nil {
+			return nil, "", err
+		}
+		var cleanup func() error
+		planner, jobID, cleanup, err = m.newCacheExplainPlanner()
+		if err != nil {
+			return nil, "", err
+		}
+		defer func() {
+			if cleanupErr := cleanup(); err == nil && cleanupErr != nil {
+				err = cleanupErr
+			}
+		}()
+	}
+	tasks, stateID, errResp := planner.buildPlan(ctx, jobID, prepared)
+	if errResp != nil {
+		return nil, "", errorFromExplainResponse(errResp)
+	}
+	return tasks, stateID, nil
+}
+
 func (m *PrepareService) newCacheExplainPlanner() (*PrepareService, string, func() error, error) {
-	if err := os.MkdirAll(m.stateStoreRoot, 0o700); err != nil {
+	scratchRoot := m.workRoot("")
+	if err := os.MkdirAll(scratchRoot, 0o700); err != nil {
 		return nil, "", nil, err
 	}
-	tempRoot, err := os.MkdirTemp(m.stateStoreRoot, "cache-explain-*")
+	tempRoot, err := os.MkdirTemp(scratchRoot, "cache-explain-*")
 	if err != nil {
 		return nil, "", nil, err
 	}
 	planner, err := NewPrepareService(Options{
-		Store:          m.store,
-		Queue:          cacheExplainQueueStore{Store: m.queue},
-		Runtime:        m.runtime,
-		StateFS:        m.statefs,
-		DBMS:           m.dbms,
-		StateStoreRoot: tempRoot,
-		Config:         m.config,
-		Psql:           m.psql,
-		Liquibase:      m.liquibase,
-		Version:        m.version,
-		ValidateStore:  m.validateStore,
-		Now:            m.now,
-		IDGen:          m.idGen,
-		Async:          false,
-		HeartbeatEvery: m.heartbeatEvery,
+		Store:             m.store,
+		Queue:             cacheExplainQueueStore{Store: m.queue},
+		Runtime:           m.runtime,
+		StateFS:           m.statefs,
+		DBMS:              m.dbms,
+		StateStoreRoot:    tempRoot,
+		Config:            m.config,
+		Psql:              m.psql,
+		Liquibase:         m.liquibase,
+		Flyway:            m.flyway,
+		Version:           m.version,
+		ValidateStore:     m.validateStore,
+		Now:               m.now,
+		IDGen:             m.idGen,
+		Async:             false,
+		HeartbeatEvery:    m.heartbeatEvery,
+		PostgresSuperuser: m.superuser,
 	})
 	if err != nil {
 		_ = os.RemoveAll(tempRoot)
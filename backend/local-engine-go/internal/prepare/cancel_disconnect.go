@@ -0,0 +1,91 @@
+package prepare
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultDisconnectGrace = 15 * time.Second
+
+// WatchEvents records an open events stream for jobID and returns the func
+// that ends it; stream handlers defer it. When the last stream of a job that
+// cancels on disconnect (Request.CancelOnDisconnect or
+// orchestrator.jobs.cancelOnDisconnect) ends, the job is cancelled unless a
+// stream reopens within orchestrator.jobs.disconnectGrace, which covers
+// client reconnects. Jobs nobody streams are never cancelled this way.
+func (m *PrepareService) WatchEvents(jobID string) func() {
+	m.events.watch(jobID)
+	var once sync.Once
+	return func() {
+		once.Do(func() { m.unwatchEvents(jobID) })
+	}
+}
+
+func (m *PrepareService) unwatchEvents(jobID string) {
+	if !m.events.unwatch(jobID) || !m.cancelsOnDisconnect(jobID) {
+		return
+	}
+	grace := m.disconnectGrace()
+	m.events.afterIdle(jobID, grace, func() {
+		// Draining stops the servers, which closes every stream; that is not
+		// the client going away.
+		if m.isDraining() || !m.cancelsOnDisconnect(jobID) {
+			return
+		}
+		m.logInfoJob(jobID, "cancel on disconnect: no event streams for %s", grace)
+		if _, _, _, err := m.Cancel(jobID); err != nil {
+			m.logErrorJob(jobID, "cancel on disconnect failed: %v", err)
+		}
+	})
+}
+
+// cancelsOnDisconnect reports whether jobID is still active and asked to be
+// cancelled when its clients go away.
+func (m *PrepareService) cancelsOnDisconnect(jobID string) bool {
+	job, ok, err := m.queue.GetJob(context.Background(), jobID)
+	if err != nil || !ok {
+		return false
+	}
+	if job.Status != StatusQueued && job.Status != StatusRunning {
+		return false
+	}
+	if req := decodeJobRequest(job); req != nil && req.CancelOnDisconnect {
+		return true
+	}
+	return m.cancelOnDisconnectDefault()
+}
+
+// cancelOnDisconnectDefault reads orchestrator.jobs.cancelOnDisconnect.
+func (m *PrepareService) cancelOnDisconnectDefault() bool {
+	if m.config == nil {
+		return false
+	}
+	value, err := m.config.Get("orchestrator.jobs.cancelOnDisconnect", true)
+	if err != nil {
+		return false
+	}
+	enabled, ok := value.(bool)
+	return ok && enabled
+}
+
+// disconnectGrace reads orchestrator.jobs.disconnectGrace.
+func (m *PrepareService) disconnectGrace() time.Duration {
+	if m.config == nil {
+		return defaultDisconnectGrace
+	}
+	value, err := m.config.Get("orchestrator.jobs.disconnectGrace", true)
+	if err != nil || value == nil {
+		return defaultDisconnectGrace
+	}
+	raw, ok := value.(string)
+	if !ok {
+		return defaultDisconnectGrace
+	}
+	grace, err := time.ParseDuration(strings.TrimSpace(raw))
+	if err != nil || grace <= 0 {
+		return defaultDisconnectGrace
+	}
+	return grace
+}
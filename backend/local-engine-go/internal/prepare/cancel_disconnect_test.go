@@ -0,0 +1,119 @@
+package prepare
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sqlrs/engine-local/internal/prepare/queue"
+)
+
+func TestWatchEventsCancelsJobAfterLastStreamLeaves(t *testing.T) {
+	mgr := newDisconnectTestManager(t, map[string]any{"orchestrator.jobs.disconnectGrace": "20ms"})
+	createDisconnectJob(t, mgr, "job-1", true)
+
+	first := mgr.WatchEvents("job-1")
+	second := mgr.WatchEvents("job-1")
+	first()
+	first()
+	time.Sleep(60 * time.Millisecond)
+	if status, _ := mgr.Get("job-1"); status.Status != StatusQueued {
+		t.Fatalf("expected job kept while a stream is open, got %s", status.Status)
+	}
+
+	second()
+	waitForJobStatus(t, mgr, "job-1", StatusFailed)
+	if status, _ := mgr.Get("job-1"); status.Error == nil || status.Error.Code != ErrorCodeCancelled {
+		t.Fatalf("expected cancelled job, got %+v", status.Error)
+	}
+}
+
+func TestWatchEventsReconnectWithinGraceKeepsJob(t *testing.T) {
+	mgr := newDisconnectTestManager(t, map[string]any{"orchestrator.jobs.disconnectGrace": "50ms"})
+	createDisconnectJob(t, mgr, "job-1", true)
+
+	mgr.WatchEvents("job-1")()
+	release := mgr.WatchEvents("job-1")
+	time.Sleep(120 * time.Millisecond)
+	if status, _ := mgr.Get("job-1"); status.Status != StatusQueued {
+		t.Fatalf("expected reconnect to keep the job, got %s", status.Status)
+	}
+	release()
+	waitForJobStatus(t, mgr, "job-1", StatusFailed)
+}
+
+func TestWatchEventsKeepsJobsWithoutCancelOnDisconnect(t *testing.T) {
+	mgr := newDisconnectTestManager(t, map[string]any{"orchestrator.jobs.disconnectGrace": "10ms"})
+	createDisconnectJob(t, mgr, "job-1", false)
+
+	mgr.WatchEvents("job-1")()
+	time.Sleep(50 * time.Millisecond)
+	if status, _ := mgr.Get("job-1"); status.Status != StatusQueued {
+		t.Fatalf("expected fire-and-forget job kept, got %s", status.Status)
+	}
+}
+
+func TestWatchEventsUsesConfigDefault(t *testing.T) {
+	mgr := newDisconnectTestManager(t, map[string]any{
+		"orchestrator.jobs.disconnectGrace":    "10ms",
+		"orchestrator.jobs.cancelOnDisconnect": true,
+	})
+	createDisconnectJob(t, mgr, "job-1", false)
+
+	mgr.WatchEvents("job-1")()
+	waitForJobStatus(t, mgr, "job-1", StatusFailed)
+}
+
+func TestDisconnectGraceDefaults(t *testing.T) {
+	mgr := newDisconnectTestManager(t, map[string]any{"orchestrator.jobs.disconnectGrace": "soon"})
+	if got := mgr.disconnectGrace(); got != defaultDisconnectGrace {
+		t.Fatalf("expected default grace for an invalid value, got %s", got)
+	}
+	mgr.config = nil
+	if got := mgr.disconnectGrace(); got != defaultDisconnectGrace || mgr.cancelOnDisconnectDefault() {
+		t.Fatalf("expected defaults without config, got %s", got)
+	}
+}
+
+func newDisconnectTestManager(t *testing.T, values map[string]any) *PrepareService {
+	t.Helper()
+	return newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{
+		config: &fakeConfigStore{values: values},
+	})
+}
+
+func createDisconnectJob(t *testing.T, mgr *PrepareService, jobID string, cancelOnDisconnect bool) {
+	t.Helper()
+	reqJSON, err := jsonMarshal(Request{
+		PrepareKind:        "psql",
+		ImageID:            "image-1",
+		PsqlArgs:           []string{"-c", "select 1"},
+		CancelOnDisconnect: cancelOnDisconnect,
+	})
+	if err != nil {
+		t.Fatalf("jsonMarshal: %v", err)
+	}
+	if err := mgr.queue.CreateJob(context.Background(), queue.JobRecord{
+		JobID:       jobID,
+		Status:      StatusQueued,
+		PrepareKind: "psql",
+		ImageID:     "image-1",
+		RequestJSON: &reqJSON,
+		CreatedAt:   time.Now().UTC().Format(time.RFC3339Nano),
+	}); err != nil {
+		t.Fatalf("CreateJob: %v", err)
+	}
+}
+
+func waitForJobStatus(t *testing.T, mgr *PrepareService, jobID string, want string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if status, ok := mgr.Get(jobID); ok && status.Status == want {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	status, _ := mgr.Get(jobID)
+	t.Fatalf("expected job %s to become %s, got %s", jobID, want, status.Status)
+}
@@ -13,6 +13,7 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/sqlrs/engine-local/internal/statefs"
 	"github.com/sqlrs/engine-local/internal/store"
 )
 
@@ -33,11 +34,14 @@ type capacitySettings struct {
 	MinStateAge   time.Duration
 	EffectiveMax  int64
 	Enabled       bool
+	// ReserveSet reports an explicit cache.capacity.reserveBytes.
+	ReserveSet bool
 }
 
 type evictCandidate struct {
 	StateID    string
 	ImageID    string
+	Namespace  string
 	CreatedAt  time.Time
 	LastUsedAt time.Time
 	SizeBytes  int64
@@ -58,14 +62,14 @@ func (m *PrepareService) ensureCacheCapacity(ctx context.Context, jobID string,
 	}
 	totalBytes, freeBytes, err := filesystemStatsFn(m.stateStoreRoot)
 	if err != nil {
-		return capacityError("cache_enforcement_unavailable", "cannot measure state store filesystem", map[string]any{
+		return capacityError(ErrorCodeCacheEnforcementUnavailable, "cannot measure state store filesystem", map[string]any{
 			"phase": phase,
 			"error": err.Error(),
 		})
 	}
 	settings, err := m.loadCapacitySettings(totalBytes)
 	if err != nil {
-		return capacityError("cache_enforcement_unavailable", "cannot load cache capacity settings", map[string]any{
+		return capacityError(ErrorCodeCacheEnforcementUnavailable, "cannot load cache capacity settings", map[string]any{
 			"phase": phase,
 			"error": err.Error(),
 		})
@@ -74,7 +78,7 @@ func (m *PrepareService) ensureCacheCapacity(ctx context.Context, jobID string,
 		return nil
 	}
 	if settings.EffectiveMax <= 0 {
-		return capacityError("cache_limit_too_small", "effective cache limit is too small", map[string]any{
+		return capacityError(ErrorCodeCacheLimitTooSmall, "effective cache limit is too small", map[string]any{
 			"phase":               phase,
 			"effective_max_bytes": settings.EffectiveMax,
 			"store_total_bytes":   totalBytes,
@@ -84,7 +88,7 @@ func (m *PrepareService) ensureCacheCapacity(ctx context.Context, jobID string,
 
 	usageBytes, err := cacheUsageFn(m.stateStoreRoot)
 	if err != nil {
-		return capacityError("cache_enforcement_unavailable", "cannot measure cache usage", map[string]any{
+		return capacityError(ErrorCodeCacheEnforcementUnavailable, "cannot measure cache usage", map[string]any{
 			"phase": phase,
 			"error": err.Error(),
 		})
@@ -95,12 +99,12 @@ func (m *PrepareService) ensureCacheCapacity(ctx context.Context, jobID string,
 
 	eviction := evictionSummary{}
 	lockErr := withEvictLock(ctx, m.stateStoreRoot, func() error {
-		summary, runErr := m.runEviction(ctx, settings, usageBytes, freeBytes, protectedStateIDs...)
+		summary, runErr := m.runEviction(ctx, jobID, settings, usageBytes, freeBytes, protectedStateIDs...)
 		eviction = summary
 		return runErr
 	})
 	if lockErr != nil {
-		return capacityError("cache_enforcement_unavailable", "cannot acquire cache eviction lock", map[string]any{
+		return capacityError(ErrorCodeCacheEnforcementUnavailable, "cannot acquire cache eviction lock", map[string]any{
 			"phase": phase,
 			"error": lockErr.Error(),
 		})
@@ -108,18 +112,21 @@ func (m *PrepareService) ensureCacheCapacity(ctx context.Context, jobID string,
 
 	usageAfter, usageErr := cacheUsageFn(m.stateStoreRoot)
 	if usageErr != nil {
-		return capacityError("cache_enforcement_unavailable", "cannot measure cache usage after eviction", map[string]any{
+		return capacityError(ErrorCodeCacheEnforcementUnavailable, "cannot measure cache usage after eviction", map[string]any{
 			"phase": phase,
 			"error": usageErr.Error(),
 		})
 	}
 	_, freeAfter, freeErr := filesystemStatsFn(m.stateStoreRoot)
 	if freeErr != nil {
-		return capacityError("cache_enforcement_unavailable", "cannot measure free space after eviction", map[string]any{
+		return capacityError(ErrorCodeCacheEnforcementUnavailable, "cannot measure free space after eviction", map[string]any{
 			"phase": phase,
 			"error": freeErr.Error(),
 		})
 	}
+	if eviction.EvictedCount > 0 {
+		m.appendLog(jobID, fmt.Sprintf("cache: evicted %d state(s), reclaimed %d bytes", eviction.EvictedCount, eviction.FreedBytes))
+	}
 	m.recordCacheEviction(CacheEvictionSummary{
 		CompletedAt:      nowUTCFn().Format(time.RFC3339Nano),
 		Trigger:          phase,
@@ -140,7 +147,7 @@ func (m *PrepareService) ensureCacheCapacity(ctx context.Context, jobID string,
 	reasons := pressureReasons(usageAfter, freeAfter, settings)
 	observedRequired := m.observedMinStateBytes(ctx)
 	if observedRequired > 0 && settings.EffectiveMax < observedRequired {
-		return capacityError("cache_limit_too_small", "effective cache limit is below observed minimum state size", map[string]any{
+		return capacityError(ErrorCodeCacheLimitTooSmall, "effective cache limit is below observed minimum state size", map[string]any{
 			"phase":                   phase,
 			"effective_max_bytes":     settings.EffectiveMax,
 			"observed_required_bytes": observedRequired,
@@ -149,7 +156,7 @@ func (m *PrepareService) ensureCacheCapacity(ctx context.Context, jobID string,
 		})
 	}
 	if eviction.ReclaimableBytes == 0 && eviction.BlockedCount == 0 {
-		return capacityError("cache_limit_too_small", "effective cache limit is too small to materialize a state", map[string]any{
+		return capacityError(ErrorCodeCacheLimitTooSmall, "effective cache limit is too small to materialize a state", map[string]any{
 			"phase":                   phase,
 			"effective_max_bytes":     settings.EffectiveMax,
 			"observed_required_bytes": observedRequired,
@@ -158,7 +165,7 @@ func (m *PrepareService) ensureCacheCapacity(ctx context.Context, jobID string,
 		})
 	}
 
-	return capacityError("cache_full_unreclaimable", "cache cannot be reclaimed to required thresholds", map[string]any{
+	return capacityError(ErrorCodeCacheFull, "cache cannot be reclaimed to required thresholds", map[string]any{
 		"phase":               phase,
 		"reasons":             reasons,
 		"usage_bytes":         usageAfter,
@@ -174,6 +181,96 @@ func (m *PrepareService) ensureCacheCapacity(ctx context.Context, jobID string,
 	})
 }
 
+// ensureSnapshotHeadroom checks, right before a snapshot, that the state store
+// keeps cache.capacity.reserveBytes free once the snapshot is written. The
+// snapshot size is estimated from the runtime data dir, except on btrfs where
+// snapshots share extents with it. When short it evicts states; if that is not
+// enough the job fails with resource_exhausted naming the shortfall. The check
+// only runs when reserveBytes is set explicitly.
+func (m *PrepareService) ensureSnapshotHeadroom(ctx context.Context, jobID string, dataDir string) *ErrorResponse {
+	if m == nil || m.config == nil || strings.TrimSpace(m.stateStoreRoot) == "" {
+		return nil
+	}
+	totalBytes, freeBytes, err := filesystemStatsFn(m.stateStoreRoot)
+	if err != nil {
+		return capacityError(ErrorCodeCacheEnforcementUnavailable, "cannot measure state store filesystem", map[string]any{
+			"phase": "snapshot",
+			"error": err.Error(),
+		})
+	}
+	settings, err := m.loadCapacitySettings(totalBytes)
+	if err != nil {
+		return capacityError(ErrorCodeCacheEnforcementUnavailable, "cannot load cache capacity settings", map[string]any{
+			"phase": "snapshot",
+			"error": err.Error(),
+		})
+	}
+	if !settings.ReserveSet {
+		return nil
+	}
+	estimate := int64(0)
+	if m.statefs == nil || m.statefs.Kind() != "btrfs" {
+		estimate, err = storeUsageFn(dataDir)
+		if err != nil {
+			return capacityError(ErrorCodeCacheEnforcementUnavailable, "cannot measure runtime data size", map[string]any{
+				"phase": "snapshot",
+				"error": err.Error(),
+			})
+		}
+	}
+	required := settings.ReserveBytes + estimate
+	if freeBytes >= required {
+		return nil
+	}
+
+	usageBytes, err := cacheUsageFn(m.stateStoreRoot)
+	if err != nil {
+		return capacityError(ErrorCodeCacheEnforcementUnavailable, "cannot measure cache usage", map[string]any{
+			"phase": "snapshot",
+			"error": err.Error(),
+		})
+	}
+	// Evicting against a reserve raised by the estimate frees room for the
+	// snapshot itself as well.
+	evictSettings := settings
+	evictSettings.ReserveBytes = required
+	eviction := evictionSummary{}
+	lockErr := withEvictLock(ctx, m.stateStoreRoot, func() error {
+		summary, runErr := m.runEviction(ctx, jobID, evictSettings, usageBytes, freeBytes)
+		eviction = summary
+		return runErr
+	})
+	if lockErr != nil {
+		return capacityError(ErrorCodeCacheEnforcementUnavailable, "cannot acquire cache eviction lock", map[string]any{
+			"phase": "snapshot",
+			"error": lockErr.Error(),
+		})
+	}
+	_, freeAfter, err := filesystemStatsFn(m.stateStoreRoot)
+	if err != nil {
+		return capacityError(ErrorCodeCacheEnforcementUnavailable, "cannot measure free space after eviction", map[string]any{
+			"phase": "snapshot",
+			"error": err.Error(),
+		})
+	}
+	if eviction.EvictedCount > 0 {
+		m.appendLog(jobID, fmt.Sprintf("cache: evicted %d state(s), reclaimed %d bytes", eviction.EvictedCount, eviction.FreedBytes))
+	}
+	if freeAfter >= required {
+		return nil
+	}
+	shortfall := required - freeAfter
+	return capacityError(ErrorCodeResourceExhausted, fmt.Sprintf("not enough free space to snapshot state: short by %d bytes", shortfall), map[string]any{
+		"phase":                    "snapshot",
+		"free_bytes":               freeAfter,
+		"estimated_snapshot_bytes": estimate,
+		"reserve_bytes":            settings.ReserveBytes,
+		"shortfall_bytes":          shortfall,
+		"evicted_count":            eviction.EvictedCount,
+		"freed_bytes":              eviction.FreedBytes,
+	})
+}
+
 func (m *PrepareService) loadCapacitySettings(storeTotalBytes int64) (capacitySettings, error) {
 	high := defaultCapacityHighWatermark
 	low := defaultCapacityLowWatermark
@@ -277,6 +374,7 @@ func (m *PrepareService) loadCapacitySettings(storeTotalBytes int64) (capacitySe
 		MinStateAge:   minAge,
 		EffectiveMax:  effectiveMax,
 		Enabled:       true,
+		ReserveSet:    reserveOverride != nil,
 	}, nil
 }
 
@@ -318,7 +416,10 @@ type evictionSummary struct {
 	ReclaimableBytes int64
 }
 
-func (m *PrepareService) runEviction(ctx context.Context, settings capacitySettings, usageBytes int64, freeBytes int64, protectedStateIDs ...string) (evictionSummary, error) {
+// runEviction deletes unreferenced states in LRU order until usage drops to the
+// low watermark and the reserve is restored. Each evicted state is reported in
+// the job log (when jobID is set) together with the bytes it reclaimed.
+func (m *PrepareService) runEviction(ctx context.Context, jobID string, settings capacitySettings, usageBytes int64, freeBytes int64, protectedStateIDs ...string) (evictionSummary, error) {
 	summary := evictionSummary{}
 	candidates, blocked, reclaimable, err := m.listEvictionCandidates(ctx, settings, protectedStateIDs...)
 	if err != nil {
@@ -340,6 +441,8 @@ func (m *PrepareService) runEviction(ctx context.Context, settings capacitySetti
 		}
 		summary.EvictedCount++
 		summary.FreedBytes += candidate.SizeBytes
+		m.logInfoJob(jobID, "cache evicted state=%s bytes=%d", candidate.StateID, candidate.SizeBytes)
+		m.appendLog(jobID, fmt.Sprintf("cache: evicted state %s (%d bytes)", candidate.StateID, candidate.SizeBytes))
 		updatedUsage, usageErr := cacheUsageFn(m.stateStoreRoot)
 		if usageErr != nil {
 			return summary, usageErr
@@ -412,7 +515,7 @@ func (m *PrepareService) listEvictionCandidates(ctx context.Context, settings ca
 		if entry.SizeBytes != nil && *entry.SizeBytes > 0 {
 			size = *entry.SizeBytes
 		} else {
-			paths, resolveErr := resolveStatePaths(m.stateStoreRoot, entry.ImageID, entry.StateID, m.statefs)
+			paths, resolveErr := resolveStatePaths(m.namespaceRoot(entry.Namespace), entry.ImageID, entry.StateID, m.statefs)
 			if resolveErr == nil {
 				if measured, measureErr := storeUsageFn(paths.stateDir); measureErr == nil {
 					size = measured
@@ -426,6 +529,7 @@ func (m *PrepareService) listEvictionCandidates(ctx context.Context, settings ca
 		out = append(out, evictCandidate{
 			StateID:    entry.StateID,
 			ImageID:    entry.ImageID,
+			Namespace:  entry.Namespace,
 			CreatedAt:  createdAt,
 			LastUsedAt: lastUsedAt,
 			SizeBytes:  size,
@@ -446,7 +550,7 @@ func (m *PrepareService) listEvictionCandidates(ctx context.Context, settings ca
 }
 
 func (m *PrepareService) deleteEvictionCandidate(ctx context.Context, candidate evictCandidate) error {
-	paths, err := resolveStatePaths(m.stateStoreRoot, candidate.ImageID, candidate.StateID, m.statefs)
+	paths, err := resolveStatePaths(m.namespaceRoot(candidate.Namespace), candidate.ImageID, candidate.StateID, m.statefs)
 	if err != nil {
 		return err
 	}
@@ -627,11 +731,38 @@ func measureStoreUsage(path string) (int64, error) {
 	return total, err
 }
 
+// measureCacheUsage sums state usage for the default namespace and every
+// namespace under state-store/ns.
 func measureCacheUsage(stateStoreRoot string) (int64, error) {
 	stateStoreRoot = strings.TrimSpace(stateStoreRoot)
 	if stateStoreRoot == "" {
 		return 0, nil
 	}
+	total, err := measureEnginesUsage(stateStoreRoot)
+	if err != nil {
+		return 0, err
+	}
+	namespaces, err := os.ReadDir(filepath.Join(stateStoreRoot, "ns"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return total, nil
+		}
+		return 0, err
+	}
+	for _, namespace := range namespaces {
+		if !namespace.IsDir() {
+			continue
+		}
+		usage, usageErr := measureEnginesUsage(statefs.NamespaceRoot(stateStoreRoot, namespace.Name()))
+		if usageErr != nil {
+			return 0, usageErr
+		}
+		total += usage
+	}
+	return total, nil
+}
+
+func measureEnginesUsage(stateStoreRoot string) (int64, error) {
 	enginesDir := filepath.Join(stateStoreRoot, "engines")
 	info, err := os.Stat(enginesDir)
 	if err != nil {
@@ -701,7 +832,19 @@ func noSpaceErrorResponse(message string, phase string, err error) *ErrorRespons
 	if !isNoSpaceError(err) {
 		return nil
 	}
-	return capacityError("cache_limit_too_small", message, map[string]any{
+	return capacityError(ErrorCodeCacheLimitTooSmall, message, map[string]any{
+		"phase": phase,
+		"error": err.Error(),
+	})
+}
+
+// quotaErrorResponse maps a refused btrfs snapshot to resource_exhausted so
+// clients can tell a full namespace quota from a full disk.
+func quotaErrorResponse(phase string, err error) *ErrorResponse {
+	if !errors.Is(err, statefs.ErrQuotaExceeded) {
+		return nil
+	}
+	return capacityError(ErrorCodeResourceExhausted, "state store quota exceeded", map[string]any{
 		"phase": phase,
 		"error": err.Error(),
 	})
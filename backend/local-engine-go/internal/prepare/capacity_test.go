@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -12,6 +13,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/sqlrs/engine-local/internal/prepare/queue"
+	"github.com/sqlrs/engine-local/internal/statefs"
 	"github.com/sqlrs/engine-local/internal/store"
 )
 
@@ -408,7 +411,7 @@ func TestRunEvictionEvictsCandidates(t *testing.T) {
 		},
 	)
 
-	summary, err := mgr.runEviction(context.Background(), capacitySettings{
+	summary, err := mgr.runEviction(context.Background(), "", capacitySettings{
 		EffectiveMax:  1000,
 		ReserveBytes:  300,
 		LowWatermark:  0.80,
@@ -429,6 +432,58 @@ func TestRunEvictionEvictsCandidates(t *testing.T) {
 	}
 }
 
+func TestRunEvictionLogsEvictedStates(t *testing.T) {
+	st := &fakeStore{
+		listStates: []store.StateEntry{
+			{
+				StateID:     "state-1",
+				ImageID:     "image-1",
+				PrepareKind: "psql",
+				CreatedAt:   "2026-02-22T10:00:00Z",
+				SizeBytes:   int64Ptr(250),
+			},
+		},
+	}
+	queueStore := newQueueStore(t)
+	mgr := newManagerWithDeps(t, st, queueStore, &testDeps{statefs: &fakeStateFS{}})
+	if err := queueStore.CreateJob(context.Background(), queue.JobRecord{
+		JobID:       "job-1",
+		Status:      StatusRunning,
+		PrepareKind: "psql",
+		ImageID:     "image-1",
+		CreatedAt:   "2026-02-22T10:00:00Z",
+	}); err != nil {
+		t.Fatalf("create job: %v", err)
+	}
+	overrideCapacitySignals(t,
+		func(string) (int64, int64, error) { return 1000, 450, nil },
+		func(string) (int64, error) { return 700, nil },
+	)
+
+	if _, err := mgr.runEviction(context.Background(), "job-1", capacitySettings{
+		EffectiveMax:  1000,
+		ReserveBytes:  300,
+		LowWatermark:  0.80,
+		HighWatermark: 0.90,
+	}, 900, 100); err != nil {
+		t.Fatalf("runEviction: %v", err)
+	}
+
+	events, err := queueStore.ListEventsSince(context.Background(), "job-1", 0)
+	if err != nil {
+		t.Fatalf("ListEventsSince: %v", err)
+	}
+	found := false
+	for _, event := range events {
+		if event.Message != nil && *event.Message == "cache: evicted state state-1 (250 bytes)" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected eviction log event, got %+v", events)
+	}
+}
+
 func TestDeleteEvictionCandidateReturnsDeleteError(t *testing.T) {
 	st := &deleteStateErrStore{
 		fakeStore: fakeStore{},
@@ -515,6 +570,19 @@ func TestCapacityParsersAndNoSpaceHelpers(t *testing.T) {
 	}
 }
 
+func TestQuotaErrorResponseMapsToResourceExhausted(t *testing.T) {
+	if quotaErrorResponse("snapshot", errors.New("no space left on device")) != nil {
+		t.Fatalf("expected nil for non-quota error")
+	}
+	resp := quotaErrorResponse("snapshot", fmt.Errorf("%w: qgroup 1/7 uses 10 of 10 bytes", statefs.ErrQuotaExceeded))
+	if resp == nil || resp.Code != "resource_exhausted" {
+		t.Fatalf("expected resource_exhausted, got %+v", resp)
+	}
+	if !strings.Contains(resp.Details, `"phase":"snapshot"`) {
+		t.Fatalf("expected phase in details, got %q", resp.Details)
+	}
+}
+
 func TestCapacityNumberParsingAndUsageMeasurement(t *testing.T) {
 	if _, ok := asInt64(json.Number("1.2")); ok {
 		t.Fatalf("expected fractional json number to fail")
@@ -873,7 +941,7 @@ func TestLoadCapacitySettingsNegativeStoreTotalAndDefaults(t *testing.T) {
 func TestRunEvictionErrorBranches(t *testing.T) {
 	t.Run("list candidates error", func(t *testing.T) {
 		mgr := newManagerWithDeps(t, &fakeStore{listStatesErr: errors.New("boom")}, newQueueStore(t), nil)
-		if _, err := mgr.runEviction(context.Background(), capacitySettings{}, 10, 10); err == nil {
+		if _, err := mgr.runEviction(context.Background(), "", capacitySettings{}, 10, 10); err == nil {
 			t.Fatalf("expected error")
 		}
 	})
@@ -887,7 +955,7 @@ func TestRunEvictionErrorBranches(t *testing.T) {
 		mgr := newManagerWithDeps(t, st, newQueueStore(t), nil)
 		ctx, cancel := context.WithCancel(context.Background())
 		cancel()
-		if _, err := mgr.runEviction(ctx, capacitySettings{EffectiveMax: 1000, LowWatermark: 0.8}, 900, 100); !errors.Is(err, context.Canceled) {
+		if _, err := mgr.runEviction(ctx, "", capacitySettings{EffectiveMax: 1000, LowWatermark: 0.8}, 900, 100); !errors.Is(err, context.Canceled) {
 			t.Fatalf("expected context canceled, got %v", err)
 		}
 	})
@@ -899,7 +967,7 @@ func TestRunEvictionErrorBranches(t *testing.T) {
 			},
 		}
 		mgr := newManagerWithDeps(t, st, newQueueStore(t), &testDeps{statefs: &fakeStateFS{removeErr: errors.New("boom")}})
-		summary, err := mgr.runEviction(context.Background(), capacitySettings{EffectiveMax: 1000, LowWatermark: 0.8}, 900, 100)
+		summary, err := mgr.runEviction(context.Background(), "", capacitySettings{EffectiveMax: 1000, LowWatermark: 0.8}, 900, 100)
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -1216,7 +1284,7 @@ func TestRunEvictionAdditionalBranches(t *testing.T) {
 			},
 		}
 		mgr := newManagerWithDeps(t, st, newQueueStore(t), nil)
-		summary, err := mgr.runEviction(context.Background(), capacitySettings{
+		summary, err := mgr.runEviction(context.Background(), "", capacitySettings{
 			EffectiveMax: 1000,
 			LowWatermark: 0.80,
 			ReserveBytes: 100,
@@ -1245,7 +1313,7 @@ func TestRunEvictionAdditionalBranches(t *testing.T) {
 				return 0, errors.New("usage boom")
 			},
 		)
-		_, err := mgr.runEviction(context.Background(), capacitySettings{
+		_, err := mgr.runEviction(context.Background(), "", capacitySettings{
 			EffectiveMax: 1000,
 			LowWatermark: 0.80,
 			ReserveBytes: 200,
@@ -1271,7 +1339,7 @@ func TestRunEvictionAdditionalBranches(t *testing.T) {
 				return 700, nil
 			},
 		)
-		_, err := mgr.runEviction(context.Background(), capacitySettings{
+		_, err := mgr.runEviction(context.Background(), "", capacitySettings{
 			EffectiveMax: 1000,
 			LowWatermark: 0.80,
 			ReserveBytes: 200,
@@ -1536,3 +1604,81 @@ func (s *deleteStateErrStore) DeleteState(ctx context.Context, stateID string) e
 func int64Ptr(value int64) *int64 {
 	return &value
 }
+
+func TestEnsureSnapshotHeadroomSkipsWithoutExplicitReserve(t *testing.T) {
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{
+		config: &fakeConfigStore{values: map[string]any{}},
+	})
+	overrideCapacitySignals(t,
+		func(string) (int64, int64, error) { return 1000, 1, nil },
+		func(string) (int64, error) { return 900, nil },
+	)
+	if errResp := mgr.ensureSnapshotHeadroom(context.Background(), "job-1", "/runtime/data"); errResp != nil {
+		t.Fatalf("expected the default reserve to skip the check, got %+v", errResp)
+	}
+}
+
+func TestEnsureSnapshotHeadroomUsesRuntimeSizeExceptOnBtrfs(t *testing.T) {
+	config := &fakeConfigStore{values: map[string]any{"cache.capacity.reserveBytes": int64(100)}}
+	overrideCapacitySignals(t,
+		func(string) (int64, int64, error) { return 1 << 30, 500, nil },
+		func(path string) (int64, error) {
+			if path == "/runtime/data" {
+				return 450, nil
+			}
+			return 0, nil
+		},
+	)
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{config: config})
+	errResp := mgr.ensureSnapshotHeadroom(context.Background(), "job-1", "/runtime/data")
+	if errResp == nil || errResp.Code != "resource_exhausted" {
+		t.Fatalf("expected resource_exhausted, got %+v", errResp)
+	}
+	if !strings.Contains(errResp.Message, "short by 50 bytes") || !strings.Contains(errResp.Details, `"estimated_snapshot_bytes":450`) {
+		t.Fatalf("expected shortfall and estimate, got %+v", errResp)
+	}
+
+	mgr = newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{config: config, statefs: &fakeStateFS{kind: "btrfs"}})
+	if errResp := mgr.ensureSnapshotHeadroom(context.Background(), "job-1", "/runtime/data"); errResp != nil {
+		t.Fatalf("expected btrfs snapshots to only need the reserve, got %+v", errResp)
+	}
+}
+
+func TestEnsureSnapshotHeadroomEvictsToMakeRoom(t *testing.T) {
+	st := &fakeStore{
+		listStates: []store.StateEntry{
+			{StateID: "state-1", ImageID: "image-1", CreatedAt: "2026-02-22T10:00:00Z", SizeBytes: int64Ptr(300)},
+		},
+	}
+	mgr := newManagerWithDeps(t, st, newQueueStore(t), &testDeps{
+		config: &fakeConfigStore{values: map[string]any{
+			"cache.capacity.reserveBytes": int64(100),
+			"cache.capacity.minStateAge":  "0s",
+		}},
+	})
+	statCalls := 0
+	overrideCapacitySignals(t,
+		func(string) (int64, int64, error) {
+			statCalls++
+			if statCalls == 1 {
+				return 1 << 30, 500, nil
+			}
+			return 1 << 30, 800, nil
+		},
+		func(path string) (int64, error) {
+			switch {
+			case path == "/runtime/data":
+				return 450, nil
+			case strings.Contains(path, "state-1"):
+				return 300, nil
+			}
+			return 0, nil
+		},
+	)
+	if errResp := mgr.ensureSnapshotHeadroom(context.Background(), "job-1", "/runtime/data"); errResp != nil {
+		t.Fatalf("expected eviction to make room, got %+v", errResp)
+	}
+	if len(st.deletedStates) != 1 || st.deletedStates[0] != "state-1" {
+		t.Fatalf("expected state-1 to be evicted, got %+v", st.deletedStates)
+	}
+}
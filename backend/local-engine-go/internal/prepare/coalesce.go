@@ -0,0 +1,82 @@
+package prepare
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"strings"
+
+	"github.com/sqlrs/engine-local/internal/prepare/queue"
+)
+
+// coalesceSignature returns the signature a coalescing submit looks up
+// in-flight jobs by. The image is resolved first because the signature covers
+// the resolved image id; Submit calls this before taking coalesceMu so a slow
+// image resolve does not hold up other submits.
+//
+// Kinds that plan from the runtime (Liquibase, Flyway) only know their
+// signature after planning and are never coalesced; neither are requests whose
+// image or signature cannot be computed up front, which fail in the job, nor
+// image builds, whose context hash is left to the job so a large context does
+// not hold up the submit. For those the signature is empty.
+func (m *PrepareService) coalesceSignature(ctx context.Context, prepared *preparedRequest) string {
+	if plansFromRuntime(prepared.request.PrepareKind) || prepared.request.Build != nil {
+		return ""
+	}
+	if errResp := m.ensureResolvedImageID(ctx, "", prepared, nil); errResp != nil {
+		m.logDebugJob("", "coalesce skipped: %s", errResp.Message)
+		return ""
+	}
+	signature, errResp := m.computeJobSignature(*prepared)
+	if errResp != nil {
+		m.logDebugJob("", "coalesce skipped: %s", errResp.Message)
+		return ""
+	}
+	return signature
+}
+
+// acceptedForInFlightSignature looks for a queued or running job with the
+// given signature whose request also matches prepared in what the signature
+// leaves out (instance mode, labels, task timeout, pgpass), and returns its
+// Accepted payload. Callers hold coalesceMu.
+func (m *PrepareService) acceptedForInFlightSignature(ctx context.Context, prepared preparedRequest, signature string) (Accepted, bool, error) {
+	if signature == "" {
+		return Accepted{}, false, nil
+	}
+	jobs, err := m.queue.ListJobsBySignature(ctx, signature, []string{StatusQueued, StatusRunning})
+	if err != nil {
+		return Accepted{}, false, err
+	}
+	for _, job := range jobs {
+		if !coalescibleJob(prepared.request, job) {
+			continue
+		}
+		m.logInfoJob(job.JobID, "coalesced identical request status=%s", job.Status)
+		return acceptedFor(job.JobID, job.Status), true, nil
+	}
+	return Accepted{}, false, nil
+}
+
+// coalescibleJob reports whether an in-flight job can serve req: the job
+// signature covers the state being built, but the instance handed out, the
+// job labels, the task timeout and the pgpass used to run it must match too.
+func coalescibleJob(req Request, job queue.JobRecord) bool {
+	if job.RequestJSON == nil {
+		return false
+	}
+	var other Request
+	if err := json.Unmarshal([]byte(*job.RequestJSON), &other); err != nil {
+		return false
+	}
+	return coalesceInstanceMode(req.InstanceMode) == coalesceInstanceMode(other.InstanceMode) &&
+		maps.Equal(req.Labels, other.Labels) &&
+		strings.TrimSpace(req.TaskTimeout) == strings.TrimSpace(other.TaskTimeout) &&
+		req.Pgpass == other.Pgpass
+}
+
+func coalesceInstanceMode(mode string) string {
+	if normalized, err := normalizeInstanceMode(mode); err == nil {
+		return normalized
+	}
+	return mode
+}
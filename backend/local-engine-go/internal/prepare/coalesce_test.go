@@ -0,0 +1,176 @@
+package prepare
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/sqlrs/engine-local/internal/prepare/queue"
+)
+
+func coalesceRequest() Request {
+	return Request{
+		PrepareKind: "psql",
+		ImageID:     "image-1",
+		PsqlArgs:    []string{"-c", "select 1"},
+		PlanOnly:    true,
+		Coalesce:    true,
+	}
+}
+
+// createInFlightJob stores a job with the signature req resolves to.
+func createInFlightJob(t *testing.T, mgr *PrepareService, queueStore queue.Store, jobID string, status string, req Request) {
+	t.Helper()
+	prepared, err := mgr.prepareRequest(req)
+	if err != nil {
+		t.Fatalf("prepareRequest: %v", err)
+	}
+	if errResp := mgr.ensureResolvedImageID(context.Background(), "", &prepared, nil); errResp != nil {
+		t.Fatalf("ensureResolvedImageID: %+v", errResp)
+	}
+	signature, errResp := mgr.computeJobSignature(prepared)
+	if errResp != nil {
+		t.Fatalf("computeJobSignature: %+v", errResp)
+	}
+	reqJSON, err := json.Marshal(prepared.request)
+	if err != nil {
+		t.Fatalf("marshal request: %v", err)
+	}
+	if err := queueStore.CreateJob(context.Background(), queue.JobRecord{
+		JobID:       jobID,
+		Status:      status,
+		PrepareKind: "psql",
+		ImageID:     req.ImageID,
+		PlanOnly:    req.PlanOnly,
+		Signature:   &signature,
+		RequestJSON: strPtr(string(reqJSON)),
+		CreatedAt:   "2026-03-10T00:00:00Z",
+	}); err != nil {
+		t.Fatalf("CreateJob: %v", err)
+	}
+}
+
+func TestSubmitCoalescesInFlightJob(t *testing.T) {
+	queueStore := newQueueStore(t)
+	runtime := &fakeRuntime{}
+	mgr := newManagerWithDeps(t, &fakeStore{}, queueStore, &testDeps{runtime: runtime})
+	createInFlightJob(t, mgr, queueStore, "job-running", StatusRunning, coalesceRequest())
+
+	accepted, err := mgr.Submit(context.Background(), coalesceRequest())
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	if accepted.JobID != "job-running" || accepted.Status != StatusRunning {
+		t.Fatalf("expected in-flight job, got %+v", accepted)
+	}
+	if accepted.EventsURL != "/v1/prepare-jobs/job-running/events" {
+		t.Fatalf("expected events url of in-flight job, got %+v", accepted)
+	}
+	jobs, err := queueStore.ListJobs(context.Background(), "")
+	if err != nil {
+		t.Fatalf("ListJobs: %v", err)
+	}
+	if len(jobs) != 1 {
+		t.Fatalf("expected no new job, got %d", len(jobs))
+	}
+}
+
+func TestSubmitWithoutCoalesceStartsNewJob(t *testing.T) {
+	queueStore := newQueueStore(t)
+	mgr := newManagerWithDeps(t, &fakeStore{}, queueStore, &testDeps{runtime: &fakeRuntime{}})
+	createInFlightJob(t, mgr, queueStore, "job-running", StatusRunning, coalesceRequest())
+
+	req := coalesceRequest()
+	req.Coalesce = false
+	accepted, err := mgr.Submit(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	if accepted.JobID == "job-running" {
+		t.Fatalf("expected a new job without coalesce")
+	}
+}
+
+func TestSubmitCoalesceIgnoresFinishedAndDifferentJobs(t *testing.T) {
+	queueStore := newQueueStore(t)
+	mgr := newManagerWithDeps(t, &fakeStore{}, queueStore, &testDeps{runtime: &fakeRuntime{}})
+	createInFlightJob(t, mgr, queueStore, "job-done", StatusSucceeded, coalesceRequest())
+	other := coalesceRequest()
+	other.PsqlArgs = []string{"-c", "select 2"}
+	createInFlightJob(t, mgr, queueStore, "job-other", StatusQueued, other)
+
+	accepted, err := mgr.Submit(context.Background(), coalesceRequest())
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	if accepted.JobID == "job-done" || accepted.JobID == "job-other" {
+		t.Fatalf("expected a new job, got %+v", accepted)
+	}
+}
+
+func TestSubmitCoalesceStoresSignatureOnNewJob(t *testing.T) {
+	queueStore := newQueueStore(t)
+	runtime := &fakeRuntime{}
+	mgr := newManagerWithDeps(t, &fakeStore{}, queueStore, &testDeps{runtime: runtime})
+
+	accepted, err := mgr.Submit(context.Background(), coalesceRequest())
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	job, ok, err := queueStore.GetJob(context.Background(), accepted.JobID)
+	if err != nil || !ok {
+		t.Fatalf("GetJob: ok=%t err=%v", ok, err)
+	}
+	if job.Signature == nil || *job.Signature == "" {
+		t.Fatalf("expected signature stored on coalescing job, got %+v", job)
+	}
+	if len(runtime.resolveCalls) != 1 {
+		t.Fatalf("expected image resolved once, got %v", runtime.resolveCalls)
+	}
+}
+
+func TestSubmitCoalesceSkipsRuntimePlannedKinds(t *testing.T) {
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{runtime: &fakeRuntime{}})
+	prepared := preparedRequest{request: Request{PrepareKind: "lb", ImageID: "image-1"}}
+	if signature := mgr.coalesceSignature(context.Background(), &prepared); signature != "" {
+		t.Fatalf("expected liquibase to skip coalescing, got signature=%q", signature)
+	}
+	if _, ok, err := mgr.acceptedForInFlightSignature(context.Background(), prepared, ""); ok || err != nil {
+		t.Fatalf("expected no in-flight job without a signature, got ok=%t err=%v", ok, err)
+	}
+}
+
+func TestSubmitCoalesceRequiresMatchingJobSettings(t *testing.T) {
+	variants := map[string]func(*Request){
+		"instance mode": func(req *Request) { req.InstanceMode = "persistent" },
+		"labels":        func(req *Request) { req.Labels = map[string]string{"pr": "1"} },
+		"task timeout":  func(req *Request) { req.TaskTimeout = "5m" },
+		"pgpass":        func(req *Request) { req.Pgpass = "*:*:*:app:${secret:app}\n" },
+	}
+	for name, change := range variants {
+		queueStore := newQueueStore(t)
+		mgr := newManagerWithDeps(t, &fakeStore{}, queueStore, &testDeps{runtime: &fakeRuntime{}})
+		createInFlightJob(t, mgr, queueStore, "job-running", StatusRunning, coalesceRequest())
+
+		req := coalesceRequest()
+		change(&req)
+		accepted, err := mgr.Submit(context.Background(), req)
+		if err != nil {
+			t.Fatalf("%s: Submit: %v", name, err)
+		}
+		if accepted.JobID == "job-running" {
+			t.Fatalf("%s: expected a new job for a different setting", name)
+		}
+	}
+
+	// An explicit default instance mode still matches.
+	queueStore := newQueueStore(t)
+	mgr := newManagerWithDeps(t, &fakeStore{}, queueStore, &testDeps{runtime: &fakeRuntime{}})
+	createInFlightJob(t, mgr, queueStore, "job-running", StatusRunning, coalesceRequest())
+	req := coalesceRequest()
+	req.InstanceMode = "ephemeral"
+	accepted, err := mgr.Submit(context.Background(), req)
+	if err != nil || accepted.JobID != "job-running" {
+		t.Fatalf("expected ephemeral request to coalesce, got %+v err=%v", accepted, err)
+	}
+}
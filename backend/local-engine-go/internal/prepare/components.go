@@ -9,6 +9,7 @@ type jobCoordinatorAPI interface {
 	buildPlanPsql(prepared preparedRequest) ([]PlanTask, string, *ErrorResponse)
 	buildPlanLiquibase(ctx context.Context, jobID string, prepared preparedRequest) ([]PlanTask, string, *ErrorResponse)
 	planLiquibaseChangesets(ctx context.Context, jobID string, prepared preparedRequest) ([]LiquibaseChangeset, *ErrorResponse)
+	buildPlanFlyway(ctx context.Context, jobID string, prepared preparedRequest) ([]PlanTask, string, *ErrorResponse)
 }
 
 type taskExecutorAPI interface {
@@ -17,6 +18,8 @@ type taskExecutorAPI interface {
 	executePsqlStep(ctx context.Context, jobID string, prepared preparedRequest, rt *jobRuntime, task taskState) *ErrorResponse
 	executeLiquibaseStep(ctx context.Context, jobID string, prepared preparedRequest, rt *jobRuntime, task taskState) *ErrorResponse
 	runLiquibaseUpdateSQL(ctx context.Context, jobID string, prepared preparedRequest, rt *jobRuntime) ([]LiquibaseChangeset, *ErrorResponse)
+	executeFlywayStep(ctx context.Context, jobID string, prepared preparedRequest, rt *jobRuntime, task taskState) *ErrorResponse
+	runFlywayInfo(ctx context.Context, jobID string, prepared preparedRequest, rt *jobRuntime) ([]FlywayMigration, *ErrorResponse)
 	createInstance(ctx context.Context, jobID string, prepared preparedRequest, stateID string) (*Result, *ErrorResponse)
 	ensureRuntime(ctx context.Context, jobID string, prepared preparedRequest, input *TaskInput, runner *jobRunner) (*jobRuntime, *ErrorResponse)
 	startRuntime(ctx context.Context, jobID string, prepared preparedRequest, input *TaskInput) (*jobRuntime, *ErrorResponse)
@@ -65,6 +68,10 @@ func (m *PrepareService) planLiquibaseChangesets(ctx context.Context, jobID stri
 	return m.coordinator.planLiquibaseChangesets(ctx, jobID, prepared)
 }
 
+func (m *PrepareService) buildPlanFlyway(ctx context.Context, jobID string, prepared preparedRequest) ([]PlanTask, string, *ErrorResponse) {
+	return m.coordinator.buildPlanFlyway(ctx, jobID, prepared)
+}
+
 func (m *PrepareService) executeStateTask(ctx context.Context, jobID string, prepared preparedRequest, task taskState) (string, *ErrorResponse) {
 	return m.executor.executeStateTask(ctx, jobID, prepared, task)
 }
@@ -85,6 +92,14 @@ func (m *PrepareService) runLiquibaseUpdateSQL(ctx context.Context, jobID string
 	return m.executor.runLiquibaseUpdateSQL(ctx, jobID, prepared, rt)
 }
 
+func (m *PrepareService) executeFlywayStep(ctx context.Context, jobID string, prepared preparedRequest, rt *jobRuntime, task taskState) *ErrorResponse {
+	return m.executor.executeFlywayStep(ctx, jobID, prepared, rt, task)
+}
+
+func (m *PrepareService) runFlywayInfo(ctx context.Context, jobID string, prepared preparedRequest, rt *jobRuntime) ([]FlywayMigration, *ErrorResponse) {
+	return m.executor.runFlywayInfo(ctx, jobID, prepared, rt)
+}
+
 func (m *PrepareService) createInstance(ctx context.Context, jobID string, prepared preparedRequest, stateID string) (*Result, *ErrorResponse) {
 	return m.executor.createInstance(ctx, jobID, prepared, stateID)
 }
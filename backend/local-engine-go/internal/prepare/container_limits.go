@@ -0,0 +1,93 @@
+package prepare
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/sqlrs/engine-local/internal/config"
+)
+
+// parseCPULimit validates a --cpus value: a positive decimal number of CPUs.
+func parseCPULimit(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", nil
+	}
+	cpus, err := strconv.ParseFloat(value, 64)
+	if err != nil || cpus <= 0 {
+		return "", ValidationError{Code: ErrorCodeInvalidArgument, Message: "cpu_limit must be a positive number of CPUs", Details: value}
+	}
+	return value, nil
+}
+
+// parseMemoryLimit validates a --memory value with config.ParseMemoryLimit,
+// the same parser that checks container.limits.memory.
+func parseMemoryLimit(value string) (string, error) {
+	if strings.TrimSpace(value) == "" {
+		return "", nil
+	}
+	memory, ok := config.ParseMemoryLimit(value)
+	if !ok {
+		return "", ValidationError{Code: ErrorCodeInvalidArgument, Message: "memory_limit must be a positive size with a unit (b, k, m or g)", Details: strings.ToLower(strings.TrimSpace(value))}
+	}
+	return memory, nil
+}
+
+// containerLimits resolves the --cpus/--memory values for prepare containers:
+// the request override wins, then container.limits.*. Invalid config values
+// are ignored so a bad config never blocks prepare jobs. Limits do not change
+// state content and are kept out of task hashes and job signatures.
+func (m *PrepareService) containerLimits(req Request) (string, string) {
+	cpus, _ := parseCPULimit(req.CPULimit)
+	memory, _ := parseMemoryLimit(req.MemoryLimit)
+	if m.config == nil {
+		return cpus, memory
+	}
+	if cpus == "" {
+		if value, err := m.config.Get("container.limits.cpus", true); err == nil && value != nil {
+			if parsed, err := parseCPULimit(configLimitString(value)); err == nil {
+				cpus = parsed
+			}
+		}
+	}
+	if memory == "" {
+		if value, err := m.config.Get("container.limits.memory", true); err == nil && value != nil {
+			if raw, ok := value.(string); ok {
+				if parsed, err := parseMemoryLimit(raw); err == nil {
+					memory = parsed
+				}
+			}
+		}
+	}
+	return cpus, memory
+}
+
+// containerUser is container.runAsUser, the --user prepare containers run as
+// and own their data directory with; empty keeps the image's postgres user.
+func (m *PrepareService) containerUser() string {
+	if m.config == nil {
+		return ""
+	}
+	value, err := m.config.Get("container.runAsUser", true)
+	if err != nil || value == nil {
+		return ""
+	}
+	user, _ := value.(string)
+	return strings.TrimSpace(user)
+}
+
+// configLimitString accepts container.limits.cpus as a JSON number or string.
+func configLimitString(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,141 @@
+package prepare
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestParseContainerLimits(t *testing.T) {
+	for _, value := range []string{"", "2", "0.5"} {
+		if _, err := parseCPULimit(value); err != nil {
+			t.Fatalf("expected cpu limit %q to be valid: %v", value, err)
+		}
+	}
+	for _, value := range []string{"0", "-1", "two"} {
+		if _, err := parseCPULimit(value); err == nil {
+			t.Fatalf("expected cpu limit %q to be rejected", value)
+		}
+	}
+	for _, value := range []string{"", "512m", "4G", "1gb", "1048576b"} {
+		if _, err := parseMemoryLimit(value); err != nil {
+			t.Fatalf("expected memory limit %q to be valid: %v", value, err)
+		}
+	}
+	for _, value := range []string{"512", "0m", "m", "4t", "1.5g", "b"} {
+		if _, err := parseMemoryLimit(value); err == nil {
+			t.Fatalf("expected memory limit %q to be rejected", value)
+		}
+	}
+}
+
+func TestContainerLimitsResolution(t *testing.T) {
+	m := &PrepareService{}
+	if cpus, memory := m.containerLimits(Request{}); cpus != "" || memory != "" {
+		t.Fatalf("expected no limits, got %q %q", cpus, memory)
+	}
+	m.config = &fakeConfigStore{values: map[string]any{
+		"container.limits.cpus":   float64(2),
+		"container.limits.memory": "4g",
+	}}
+	if cpus, memory := m.containerLimits(Request{}); cpus != "2" || memory != "4g" {
+		t.Fatalf("expected configured limits, got %q %q", cpus, memory)
+	}
+	if cpus, memory := m.containerLimits(Request{CPULimit: "1", MemoryLimit: "512m"}); cpus != "1" || memory != "512m" {
+		t.Fatalf("expected request overrides, got %q %q", cpus, memory)
+	}
+	m.config = &fakeConfigStore{values: map[string]any{"container.limits.memory": "512"}}
+	if _, memory := m.containerLimits(Request{}); memory != "" {
+		t.Fatalf("expected invalid config to be ignored, got %q", memory)
+	}
+}
+
+func TestPrepareRequestRejectsInvalidContainerLimits(t *testing.T) {
+	m := &PrepareService{}
+	for _, req := range []Request{{CPULimit: "0"}, {MemoryLimit: "512"}} {
+		req.PrepareKind = "psql"
+		req.ImageID = "image-1"
+		req.PsqlArgs = []string{"-c", "select 1"}
+		_, err := m.prepareRequest(req)
+		var validation ValidationError
+		if !errors.As(err, &validation) {
+			t.Fatalf("expected validation error for %+v, got %v", req, err)
+		}
+	}
+}
+
+func TestContainerLimitsDoNotAffectSignature(t *testing.T) {
+	m := &PrepareService{}
+	base := Request{PrepareKind: "psql", ImageID: "image-1@sha256:abc", PsqlArgs: []string{"-c", "select 1"}}
+	limited := base
+	limited.CPULimit = "2"
+	limited.MemoryLimit = "4g"
+	var signatures []string
+	for _, req := range []Request{base, limited} {
+		prepared, err := m.prepareRequest(req)
+		if err != nil {
+			t.Fatalf("prepareRequest: %v", err)
+		}
+		signature, errResp := m.computeJobSignature(prepared)
+		if errResp != nil {
+			t.Fatalf("computeJobSignature: %+v", errResp)
+		}
+		signatures = append(signatures, signature)
+	}
+	if signatures[0] != signatures[1] {
+		t.Fatalf("expected limits to keep the signature, got %q and %q", signatures[0], signatures[1])
+	}
+}
+
+func TestStartRuntimePassesContainerLimits(t *testing.T) {
+	runtime := &fakeRuntime{}
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{
+		runtime: runtime,
+		statefs: &fakeStateFS{},
+		config:  &fakeConfigStore{values: map[string]any{"container.limits.cpus": "2"}},
+	})
+	prepared, err := mgr.prepareRequest(Request{PrepareKind: "psql", ImageID: "image-1", PsqlArgs: []string{"-c", "select 1"}, MemoryLimit: "1g"})
+	if err != nil {
+		t.Fatalf("prepareRequest: %v", err)
+	}
+	rt, errResp := mgr.startRuntime(context.Background(), "job-1", prepared, &TaskInput{Kind: "image", ID: "image-1"})
+	if errResp != nil {
+		t.Fatalf("startRuntime: %+v", errResp)
+	}
+	defer rt.cleanup()
+	if len(runtime.startCalls) != 1 || runtime.startCalls[0].CPUs != "2" || runtime.startCalls[0].Memory != "1g" {
+		t.Fatalf("unexpected start calls: %+v", runtime.startCalls)
+	}
+}
+
+func TestStartRuntimePassesContainerUser(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		config map[string]any
+		want   string
+	}{
+		{name: "unset", config: map[string]any{}, want: ""},
+		{name: "configured", config: map[string]any{"container.runAsUser": " 1000:1000 "}, want: "1000:1000"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			runtime := &fakeRuntime{}
+			mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{
+				runtime: runtime,
+				statefs: &fakeStateFS{},
+				config:  &fakeConfigStore{values: tc.config},
+			})
+			prepared, err := mgr.prepareRequest(Request{PrepareKind: "psql", ImageID: "image-1", PsqlArgs: []string{"-c", "select 1"}})
+			if err != nil {
+				t.Fatalf("prepareRequest: %v", err)
+			}
+			rt, errResp := mgr.startRuntime(context.Background(), "job-1", prepared, &TaskInput{Kind: "image", ID: "image-1"})
+			if errResp != nil {
+				t.Fatalf("startRuntime: %+v", errResp)
+			}
+			defer rt.cleanup()
+			if len(runtime.startCalls) != 1 || runtime.startCalls[0].User != tc.want {
+				t.Fatalf("expected user %q, got start calls %+v", tc.want, runtime.startCalls)
+			}
+		})
+	}
+}
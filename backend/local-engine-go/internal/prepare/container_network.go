@@ -0,0 +1,65 @@
+package prepare
+
+import (
+	"net"
+	"strings"
+)
+
+// parseContainerNetwork validates Request.Network against
+// container.network.allowed. Any non-empty network must be listed there, so
+// a shared engine never attaches prepare containers to arbitrary networks
+// (for example "host").
+func (m *PrepareService) parseContainerNetwork(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", nil
+	}
+	allowed := m.allowedContainerNetworks()
+	for _, name := range allowed {
+		if name == value {
+			return value, nil
+		}
+	}
+	details := "container.network.allowed is empty"
+	if len(allowed) > 0 {
+		details = "allowed: " + strings.Join(allowed, ", ")
+	}
+	return "", ValidationError{Code: ErrorCodeInvalidArgument, Message: "network is not allowed: " + value, Details: details}
+}
+
+func (m *PrepareService) allowedContainerNetworks() []string {
+	if m.config == nil {
+		return nil
+	}
+	value, err := m.config.Get("container.network.allowed", true)
+	if err != nil {
+		return nil
+	}
+	items, ok := value.([]any)
+	if !ok {
+		return nil
+	}
+	var allowed []string
+	for _, item := range items {
+		if name, ok := item.(string); ok && strings.TrimSpace(name) != "" {
+			allowed = append(allowed, strings.TrimSpace(name))
+		}
+	}
+	return allowed
+}
+
+// parseContainerDNS validates Request.DNS: every entry must be an IP address.
+func parseContainerDNS(values []string) ([]string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	servers := make([]string, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if net.ParseIP(value) == nil {
+			return nil, ValidationError{Code: ErrorCodeInvalidArgument, Message: "dns entries must be IP addresses", Details: value}
+		}
+		servers = append(servers, value)
+	}
+	return servers, nil
+}
@@ -0,0 +1,121 @@
+package prepare
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	engineRuntime "github.com/sqlrs/engine-local/internal/runtime"
+)
+
+func TestParseContainerNetworkAllowlist(t *testing.T) {
+	m := &PrepareService{}
+	if network, err := m.parseContainerNetwork("  "); err != nil || network != "" {
+		t.Fatalf("expected empty network to pass, got %q %v", network, err)
+	}
+	var validation ValidationError
+	if _, err := m.parseContainerNetwork("corp-net"); !errors.As(err, &validation) {
+		t.Fatalf("expected network to be rejected without allowlist, got %v", err)
+	}
+	m.config = &fakeConfigStore{values: map[string]any{
+		"container.network.allowed": []any{"corp-net", " seed-net "},
+	}}
+	for _, value := range []string{"corp-net", " seed-net"} {
+		if _, err := m.parseContainerNetwork(value); err != nil {
+			t.Fatalf("expected %q to be allowed, got %v", value, err)
+		}
+	}
+	_, err := m.parseContainerNetwork("host")
+	if !errors.As(err, &validation) || validation.Details != "allowed: corp-net, seed-net" {
+		t.Fatalf("expected host network to be rejected, got %v", err)
+	}
+}
+
+func TestParseContainerDNS(t *testing.T) {
+	servers, err := parseContainerDNS([]string{" 10.0.0.53 ", "fd00::53"})
+	if err != nil || len(servers) != 2 || servers[0] != "10.0.0.53" {
+		t.Fatalf("unexpected dns servers %v err=%v", servers, err)
+	}
+	for _, value := range []string{"", "dns.corp"} {
+		var validation ValidationError
+		if _, err := parseContainerDNS([]string{value}); !errors.As(err, &validation) {
+			t.Fatalf("expected %q to be rejected, got %v", value, err)
+		}
+	}
+}
+
+func TestContainerNetworkDoesNotAffectSignature(t *testing.T) {
+	m := &PrepareService{config: &fakeConfigStore{values: map[string]any{
+		"container.network.allowed": []any{"corp-net"},
+	}}}
+	base := Request{PrepareKind: "psql", ImageID: "image-1@sha256:abc", PsqlArgs: []string{"-c", "select 1"}}
+	networked := base
+	networked.Network = "corp-net"
+	networked.DNS = []string{"10.0.0.53"}
+	var signatures []string
+	for _, req := range []Request{base, networked} {
+		prepared, err := m.prepareRequest(req)
+		if err != nil {
+			t.Fatalf("prepareRequest: %v", err)
+		}
+		signature, errResp := m.computeJobSignature(prepared)
+		if errResp != nil {
+			t.Fatalf("computeJobSignature: %+v", errResp)
+		}
+		signatures = append(signatures, signature)
+	}
+	if signatures[0] != signatures[1] {
+		t.Fatalf("expected network to keep the signature, got %q and %q", signatures[0], signatures[1])
+	}
+}
+
+func TestStartRuntimePassesNetworkAndDNS(t *testing.T) {
+	runtime := &fakeRuntime{}
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{
+		runtime: runtime,
+		statefs: &fakeStateFS{},
+		config:  &fakeConfigStore{values: map[string]any{"container.network.allowed": []any{"corp-net"}}},
+	})
+	prepared, err := mgr.prepareRequest(Request{
+		PrepareKind: "psql",
+		ImageID:     "image-1",
+		PsqlArgs:    []string{"-c", "select 1"},
+		Network:     "corp-net",
+		DNS:         []string{"10.0.0.53"},
+	})
+	if err != nil {
+		t.Fatalf("prepareRequest: %v", err)
+	}
+	rt, errResp := mgr.startRuntime(context.Background(), "job-1", prepared, &TaskInput{Kind: "image", ID: "image-1"})
+	if errResp != nil {
+		t.Fatalf("startRuntime: %+v", errResp)
+	}
+	defer rt.cleanup()
+	if len(runtime.startCalls) != 1 || runtime.startCalls[0].Network != "corp-net" || len(runtime.startCalls[0].DNS) != 1 || runtime.startCalls[0].DNS[0] != "10.0.0.53" {
+		t.Fatalf("unexpected start calls: %+v", runtime.startCalls)
+	}
+}
+
+func TestLiquibaseRunPassesNetworkAndDNS(t *testing.T) {
+	liquibase := &fakeLiquibaseRunner{output: "ok"}
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{liquibase: liquibase})
+	prepared := preparedRequest{
+		request:        Request{PrepareKind: "lb", Network: "corp-net", DNS: []string{"10.0.0.53"}},
+		normalizedArgs: []string{"update", "--changelog-file", "/sqlrs/mnt/path1"},
+	}
+	rt := &jobRuntime{instance: engineRuntime.Instance{ID: "container-1", Host: "127.0.0.1", Port: 5432}}
+	if errResp := mgr.executeLiquibaseStep(context.Background(), "job-1", prepared, rt, taskState{}); errResp != nil {
+		t.Fatalf("executeLiquibaseStep: %+v", errResp)
+	}
+	if len(liquibase.runs) != 1 || liquibase.runs[0].Network != "corp-net" || len(liquibase.runs[0].DNS) != 1 {
+		t.Fatalf("unexpected liquibase runs: %+v", liquibase.runs)
+	}
+
+	cont := &fakeContainerRuntime{runOut: "ok"}
+	if _, err := (containerLiquibaseRunner{runtime: cont}).Run(context.Background(), liquibase.runs[0]); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(cont.runCalls) != 1 || cont.runCalls[0].Network != "corp-net" || cont.runCalls[0].DNS[0] != "10.0.0.53" {
+		t.Fatalf("unexpected container runs: %+v", cont.runCalls)
+	}
+}
@@ -83,6 +83,19 @@ func (c *contentLock) Close() error {
 	return outErr
 }
 
+// openFile returns a reader over path, reusing the locked handle when there is
+// one. Closing the reader leaves a locked handle open.
+func (c *contentLock) openFile(path string) (io.ReadCloser, error) {
+	if c == nil || c.files[path] == nil {
+		return os.Open(path)
+	}
+	f := c.files[path]
+	if _, err := f.Seek(0, 0); err != nil {
+		return nil, err
+	}
+	return io.NopCloser(f), nil
+}
+
 func (c *contentLock) readFile(path string) ([]byte, error) {
 	if c == nil {
 		return os.ReadFile(path)
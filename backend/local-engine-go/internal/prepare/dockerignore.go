@@ -0,0 +1,110 @@
+package prepare
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// dockerignore holds the rules of a build context's .dockerignore, read the
+// way docker reads them: one pattern per line, "#" comments, "**" for any
+// number of directories and "!" to re-include. The last matching rule wins,
+// and a pattern matching a directory covers everything below it.
+type dockerignore struct {
+	rules []dockerignoreRule
+}
+
+type dockerignoreRule struct {
+	segments  []string
+	exception bool
+}
+
+func loadDockerignore(contextDir string) (dockerignore, error) {
+	data, err := os.ReadFile(filepath.Join(contextDir, ".dockerignore"))
+	if os.IsNotExist(err) {
+		return dockerignore{}, nil
+	}
+	if err != nil {
+		return dockerignore{}, err
+	}
+	var ignore dockerignore
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule := dockerignoreRule{}
+		if strings.HasPrefix(line, "!") {
+			rule.exception = true
+			line = strings.TrimSpace(line[1:])
+		}
+		pattern := strings.TrimPrefix(path.Clean(filepath.ToSlash(line)), "/")
+		if pattern == "" || pattern == "." {
+			continue
+		}
+		rule.segments = strings.Split(pattern, "/")
+		for _, segment := range rule.segments {
+			if _, err := path.Match(segment, ""); err != nil {
+				return dockerignore{}, fmt.Errorf("invalid .dockerignore pattern %q: %w", line, err)
+			}
+		}
+		ignore.rules = append(ignore.rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return dockerignore{}, err
+	}
+	return ignore, nil
+}
+
+// excludes reports whether the slash-separated context path rel is left out
+// of the build context.
+func (d dockerignore) excludes(rel string) bool {
+	parts := strings.Split(rel, "/")
+	excluded := false
+	for _, rule := range d.rules {
+		for i := 1; i <= len(parts); i++ {
+			if matchIgnoreSegments(rule.segments, parts[:i]) {
+				excluded = !rule.exception
+				break
+			}
+		}
+	}
+	return excluded
+}
+
+// hasExceptions reports whether a "!" rule may re-include a path below an
+// excluded directory, in which case the directory must still be walked.
+func (d dockerignore) hasExceptions() bool {
+	for _, rule := range d.rules {
+		if rule.exception {
+			return true
+		}
+	}
+	return false
+}
+
+func matchIgnoreSegments(pattern []string, parts []string) bool {
+	if len(pattern) == 0 {
+		return len(parts) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(parts); i++ {
+			if matchIgnoreSegments(pattern[1:], parts[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(parts) == 0 {
+		return false
+	}
+	if ok, err := path.Match(pattern[0], parts[0]); err != nil || !ok {
+		return false
+	}
+	return matchIgnoreSegments(pattern[1:], parts[1:])
+}
@@ -0,0 +1,83 @@
+package prepare
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// drainCancelGrace bounds how long Drain waits for a cancelled job to unwind
+// before it stops the job's runtime itself.
+var drainCancelGrace = 20 * time.Second
+
+// Drain stops accepting new jobs and waits for running jobs to finish. If ctx
+// expires first, the remaining jobs are cancelled, their runtimes are cleaned
+// up, and the returned error lists the interrupted job ids. Idle warm pool
+// containers are stopped either way.
+func (m *PrepareService) Drain(ctx context.Context) error {
+	defer m.stopWarmPool()
+	m.mu.Lock()
+	m.draining = true
+	runners := make(map[string]*jobRunner, len(m.running))
+	for jobID, runner := range m.running {
+		runners[jobID] = runner
+	}
+	m.mu.Unlock()
+	if len(runners) == 0 {
+		return nil
+	}
+	m.logInfoJob("", "drain waiting for %d running jobs", len(runners))
+
+	interrupted := []string{}
+	for jobID, runner := range runners {
+		select {
+		case <-runner.done:
+		case <-ctx.Done():
+			if !runnerDone(runner) {
+				interrupted = append(interrupted, jobID)
+			}
+		}
+	}
+	if len(interrupted) == 0 {
+		return nil
+	}
+	sort.Strings(interrupted)
+	for _, jobID := range interrupted {
+		m.logWarnJob(jobID, "drain timed out; cancelling job")
+		runners[jobID].cancel()
+	}
+	for _, jobID := range interrupted {
+		runner := runners[jobID]
+		select {
+		case <-runner.done:
+		case <-time.After(drainCancelGrace):
+			m.logWarnJob(jobID, "job did not stop after cancel; cleaning up runtime")
+			m.cleanupRuntime(context.Background(), runner)
+		}
+	}
+	return fmt.Errorf("drain interrupted jobs: %s", strings.Join(interrupted, ", "))
+}
+
+func (m *PrepareService) isDraining() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.draining
+}
+
+// RunningJobs returns the number of jobs currently executing.
+func (m *PrepareService) RunningJobs() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.running)
+}
+
+func runnerDone(runner *jobRunner) bool {
+	select {
+	case <-runner.done:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,107 @@
+package prepare
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	engineRuntime "github.com/sqlrs/engine-local/internal/runtime"
+)
+
+func TestDrainWaitsForRunningJobs(t *testing.T) {
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{})
+	runner := mgr.registerRunner("job-1", func() {})
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		close(runner.done)
+		mgr.unregisterRunner("job-1")
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := mgr.Drain(ctx); err != nil {
+		t.Fatalf("Drain: %v", err)
+	}
+	if !runnerDone(runner) {
+		t.Fatalf("expected drain to wait for the running job")
+	}
+
+	_, err := mgr.Submit(context.Background(), Request{
+		PrepareKind: "psql",
+		ImageID:     "image-1",
+		PsqlArgs:    []string{"-c", "select 1"},
+	})
+	var unavailable UnavailableError
+	if !errors.As(err, &unavailable) || unavailable.Code != "unavailable" {
+		t.Fatalf("expected submit to be rejected while draining, got %v", err)
+	}
+}
+
+func TestDrainCancelsJobsOnTimeout(t *testing.T) {
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{})
+	var runner *jobRunner
+	runner = mgr.registerRunner("job-1", func() {
+		close(runner.done)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := mgr.Drain(ctx)
+	if err == nil || !strings.Contains(err.Error(), "job-1") {
+		t.Fatalf("expected interrupted job in error, got %v", err)
+	}
+	if !runnerDone(runner) {
+		t.Fatalf("expected runner to be cancelled")
+	}
+}
+
+func TestDrainCleansUpRuntimeOfStuckJob(t *testing.T) {
+	prev := drainCancelGrace
+	drainCancelGrace = 10 * time.Millisecond
+	t.Cleanup(func() { drainCancelGrace = prev })
+
+	runtime := &fakeRuntime{}
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{runtime: runtime})
+	runner := mgr.registerRunner("job-1", func() {})
+	runner.setRuntime(&jobRuntime{instance: engineRuntime.Instance{ID: "container-1"}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := mgr.Drain(ctx); err == nil {
+		t.Fatalf("expected drain timeout error")
+	}
+	if len(runtime.stopCalls) != 1 || runtime.stopCalls[0] != "container-1" {
+		t.Fatalf("expected stuck job runtime to be stopped, got %+v", runtime.stopCalls)
+	}
+	if runner.getRuntime() != nil {
+		t.Fatalf("expected runtime to be detached")
+	}
+}
+
+func TestDrainWithoutRunningJobs(t *testing.T) {
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{})
+	if err := mgr.Drain(context.Background()); err != nil {
+		t.Fatalf("Drain: %v", err)
+	}
+	if !mgr.isDraining() {
+		t.Fatalf("expected service to stop accepting jobs")
+	}
+}
+
+func TestRunningJobsCountsRegisteredRunners(t *testing.T) {
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{})
+	if got := mgr.RunningJobs(); got != 0 {
+		t.Fatalf("expected no running jobs, got %d", got)
+	}
+	mgr.registerRunner("job-1", func() {})
+	mgr.registerRunner("job-2", func() {})
+	if got := mgr.RunningJobs(); got != 2 {
+		t.Fatalf("expected 2 running jobs, got %d", got)
+	}
+	mgr.unregisterRunner("job-1")
+	if got := mgr.RunningJobs(); got != 1 {
+		t.Fatalf("expected 1 running job, got %d", got)
+	}
+}
@@ -31,10 +31,18 @@ func (b *blockingRuntime) InitBase(ctx context.Context, imageID string, dataDir
 	return os.WriteFile(filepath.Join(dataDir, "PG_VERSION"), []byte("17"), 0o600)
 }
 
-func (b *blockingRuntime) ResolveImage(ctx context.Context, imageID string) (string, error) {
+func (b *blockingRuntime) ResolveImage(ctx context.Context, imageID string, platform string) (string, error) {
 	return imageID, nil
 }
 
+func (*blockingRuntime) Build(ctx context.Context, req engineRuntime.BuildRequest) (string, error) {
+	return "sha256:built", nil
+}
+
+func (*blockingRuntime) HasImageLocally(ctx context.Context, ref string) (string, bool, error) {
+	return ref, true, nil
+}
+
 func (b *blockingRuntime) Start(ctx context.Context, req engineRuntime.StartRequest) (engineRuntime.Instance, error) {
 	return engineRuntime.Instance{}, nil
 }
@@ -51,6 +59,14 @@ func (b *blockingRuntime) WaitForReady(ctx context.Context, id string, timeout t
 	return nil
 }
 
+func (b *blockingRuntime) Ping(ctx context.Context) (string, error) {
+	return "test", nil
+}
+
+func (b *blockingRuntime) ListManaged(ctx context.Context) ([]engineRuntime.ManagedContainer, error) {
+	return nil, nil
+}
+
 type noPgRuntime struct{}
 
 func (n noPgRuntime) InitBase(ctx context.Context, imageID string, dataDir string) error {
@@ -60,10 +76,18 @@ func (n noPgRuntime) InitBase(ctx context.Context, imageID string, dataDir strin
 	return nil
 }
 
-func (n noPgRuntime) ResolveImage(ctx context.Context, imageID string) (string, error) {
+func (n noPgRuntime) ResolveImage(ctx context.Context, imageID string, platform string) (string, error) {
 	return imageID, nil
 }
 
+func (noPgRuntime) Build(ctx context.Context, req engineRuntime.BuildRequest) (string, error) {
+	return "sha256:built", nil
+}
+
+func (noPgRuntime) HasImageLocally(ctx context.Context, ref string) (string, bool, error) {
+	return ref, true, nil
+}
+
 func (n noPgRuntime) Start(ctx context.Context, req engineRuntime.StartRequest) (engineRuntime.Instance, error) {
 	return engineRuntime.Instance{}, nil
 }
@@ -80,6 +104,14 @@ func (n noPgRuntime) WaitForReady(ctx context.Context, id string, timeout time.D
 	return nil
 }
 
+func (n noPgRuntime) Ping(ctx context.Context) (string, error) {
+	return "test", nil
+}
+
+func (n noPgRuntime) ListManaged(ctx context.Context) ([]engineRuntime.ManagedContainer, error) {
+	return nil, nil
+}
+
 type ensureEmptyRuntime struct{}
 
 func (e ensureEmptyRuntime) InitBase(ctx context.Context, imageID string, dataDir string) error {
@@ -99,10 +131,18 @@ func (e ensureEmptyRuntime) InitBase(ctx context.Context, imageID string, dataDi
 	return os.WriteFile(filepath.Join(dataDir, "PG_VERSION"), []byte("17"), 0o600)
 }
 
-func (e ensureEmptyRuntime) ResolveImage(ctx context.Context, imageID string) (string, error) {
+func (e ensureEmptyRuntime) ResolveImage(ctx context.Context, imageID string, platform string) (string, error) {
 	return imageID, nil
 }
 
+func (ensureEmptyRuntime) Build(ctx context.Context, req engineRuntime.BuildRequest) (string, error) {
+	return "sha256:built", nil
+}
+
+func (ensureEmptyRuntime) HasImageLocally(ctx context.Context, ref string) (string, bool, error) {
+	return ref, true, nil
+}
+
 func (e ensureEmptyRuntime) Start(ctx context.Context, req engineRuntime.StartRequest) (engineRuntime.Instance, error) {
 	return engineRuntime.Instance{}, nil
 }
@@ -119,6 +159,14 @@ func (e ensureEmptyRuntime) WaitForReady(ctx context.Context, id string, timeout
 	return nil
 }
 
+func (e ensureEmptyRuntime) Ping(ctx context.Context) (string, error) {
+	return "test", nil
+}
+
+func (e ensureEmptyRuntime) ListManaged(ctx context.Context) ([]engineRuntime.ManagedContainer, error) {
+	return nil, nil
+}
+
 func TestEnsureBaseStateUsesInitMarker(t *testing.T) {
 	runtime := &fakeRuntime{}
 	mgr := newManagerWithRuntime(t, runtime)
@@ -152,6 +200,34 @@ func TestEnsureBaseStateCreatesInitMarker(t *testing.T) {
 	}
 }
 
+func TestEnsureBaseStateReinitializesForOtherSuperuser(t *testing.T) {
+	runtime := &fakeRuntime{}
+	mgr := newManagerWithRuntime(t, runtime)
+	mgr.superuser = "postgres"
+	baseDir := filepath.Join(t.TempDir(), "base")
+	if err := os.MkdirAll(baseDir, 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(baseDir, baseInitMarkerName), []byte("ok"), 0o600); err != nil {
+		t.Fatalf("write marker: %v", err)
+	}
+	if err := mgr.ensureBaseState(context.Background(), "image-1", baseDir); err != nil {
+		t.Fatalf("ensureBaseState: %v", err)
+	}
+	if len(runtime.initCalls) != 1 {
+		t.Fatalf("expected base bootstrapped for sqlrs to be initialized again, got %d init calls", len(runtime.initCalls))
+	}
+	if !initMarkerMatches(baseDir, "postgres") || initMarkerMatches(baseDir, "") {
+		t.Fatalf("expected marker to record the postgres superuser")
+	}
+	if err := mgr.ensureBaseState(context.Background(), "image-1", baseDir); err != nil {
+		t.Fatalf("ensureBaseState: %v", err)
+	}
+	if len(runtime.initCalls) != 1 {
+		t.Fatalf("expected no further init calls, got %d", len(runtime.initCalls))
+	}
+}
+
 func TestEnsureBaseStateUsesPGVersionInPgdata(t *testing.T) {
 	runtime := &fakeRuntime{}
 	mgr := newManagerWithRuntime(t, runtime)
@@ -271,3 +347,79 @@ func newManagerWithRuntime(t *testing.T, rt engineRuntime.Runtime) *PrepareServi
 	}
 	return mgr
 }
+
+func TestSubmitSharesBaseStateAcrossTagsPinnedToOneDigest(t *testing.T) {
+	runtime := &fakeRuntime{}
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{runtime: runtime})
+	jobs := 0
+	mgr.idGen = func() (string, error) {
+		jobs++
+		return fmt.Sprintf("job-%d", jobs), nil
+	}
+	for _, imageID := range []string{"postgres:16@sha256:abc", "postgres:latest@sha256:abc"} {
+		if _, err := mgr.Submit(context.Background(), Request{
+			PrepareKind: "psql",
+			ImageID:     imageID,
+			PsqlArgs:    []string{"-c", "select 1"},
+		}); err != nil {
+			t.Fatalf("Submit %s: %v", imageID, err)
+		}
+	}
+	if len(runtime.startCalls) != 2 {
+		t.Fatalf("expected both jobs to start a runtime, got %d", len(runtime.startCalls))
+	}
+	if len(runtime.initCalls) != 1 {
+		t.Fatalf("expected a single base init for one digest, got %+v", runtime.initCalls)
+	}
+}
+
+func TestResolveStatePathsKeepsLegacyTaggedBaseDir(t *testing.T) {
+	root := t.TempDir()
+	mgr := newManagerWithRuntime(t, &fakeRuntime{})
+	imageID := "postgres:16@sha256:abc"
+
+	paths, err := resolveStatePaths(root, imageID, "", mgr.statefs)
+	if err != nil {
+		t.Fatalf("resolveStatePaths: %v", err)
+	}
+	digestPaths, err := resolveStatePaths(root, "postgres@sha256:abc", "", mgr.statefs)
+	if err != nil {
+		t.Fatalf("resolveStatePaths: %v", err)
+	}
+	if paths.baseDir != digestPaths.baseDir {
+		t.Fatalf("expected digest-keyed base dir, got %s vs %s", paths.baseDir, digestPaths.baseDir)
+	}
+
+	legacy, err := mgr.statefs.BaseDir(root, imageID)
+	if err != nil {
+		t.Fatalf("BaseDir: %v", err)
+	}
+	if err := os.MkdirAll(legacy, 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := writeInitMarker(legacy, ""); err != nil {
+		t.Fatalf("writeInitMarker: %v", err)
+	}
+	paths, err = resolveStatePaths(root, imageID, "", mgr.statefs)
+	if err != nil {
+		t.Fatalf("resolveStatePaths: %v", err)
+	}
+	if paths.baseDir != legacy {
+		t.Fatalf("expected existing tagged base dir %s, got %s", legacy, paths.baseDir)
+	}
+}
+
+func TestDigestKeyedImageID(t *testing.T) {
+	cases := map[string]string{
+		"postgres:16@sha256:abc":         "postgres@sha256:abc",
+		"localhost:5000/pg:1@sha256:abc": "localhost:5000/pg@sha256:abc",
+		"postgres@sha256:abc":            "postgres@sha256:abc",
+		"postgres:16":                    "postgres:16",
+		"localhost:5000/pg@sha256:abc":   "localhost:5000/pg@sha256:abc",
+	}
+	for input, want := range cases {
+		if got := digestKeyedImageID(input); got != want {
+			t.Fatalf("digestKeyedImageID(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
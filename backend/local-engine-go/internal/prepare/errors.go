@@ -1,6 +1,47 @@
 package prepare
 
-import "fmt"
+import (
+	"fmt"
+
+	engineRuntime "github.com/sqlrs/engine-local/internal/runtime"
+)
+
+// Error codes reported in ErrorResponse.Code. Codes are part of the API:
+// clients branch on them instead of on messages, so a code is never renamed
+// or reused for a different failure. New failure classes get a new code.
+const (
+	// Request and lifecycle errors.
+	ErrorCodeInvalidArgument   = "invalid_argument"
+	ErrorCodeConflict          = "conflict"
+	ErrorCodePermissionDenied  = "permission_denied"
+	ErrorCodeUnavailable       = "unavailable"
+	ErrorCodeResourceExhausted = "resource_exhausted"
+	ErrorCodeCancelled         = "cancelled"
+	ErrorCodeDeadlineExceeded  = "deadline_exceeded"
+	ErrorCodeInternal          = "internal_error"
+
+	// Container runtime errors.
+	ErrorCodeRuntimeUnavailable   = "runtime_unavailable"
+	ErrorCodeImageNotFound        = "image_not_found"
+	ErrorCodeImageResolveFailed   = "image_resolve_failed"
+	ErrorCodeImageBuildFailed     = "image_build_failed"
+	ErrorCodeBaseInitFailed       = "base_init_failed"
+	ErrorCodeContainerStartFailed = "container_start_failed"
+
+	// Errors from the prepare step itself: the psql script, Liquibase
+	// changelog or Flyway migration failed.
+	ErrorCodeMigrationFailed = "migration_failed"
+
+	// State store errors.
+	ErrorCodeStoreNotReady  = "store_not_ready"
+	ErrorCodeSnapshotFailed = "snapshot_failed"
+	ErrorCodeStateCorrupt   = "state_corrupt"
+
+	// Cache capacity errors.
+	ErrorCodeCacheFull                   = "cache_full_unreclaimable"
+	ErrorCodeCacheLimitTooSmall          = "cache_limit_too_small"
+	ErrorCodeCacheEnforcementUnavailable = "cache_enforcement_unavailable"
+)
 
 type ValidationError struct {
 	Code    string
@@ -19,6 +60,82 @@ func (e ValidationError) Response() *ErrorResponse {
 	return errorResponse(e.Code, e.Message, e.Details)
 }
 
+// ConflictError reports a request that contradicts existing job state, such as
+// an idempotency key reused with a different request body.
+type ConflictError struct {
+	Code    string
+	Message string
+	Details string
+}
+
+func (e ConflictError) Error() string {
+	if e.Details == "" {
+		return e.Message
+	}
+	return fmt.Sprintf("%s: %s", e.Message, e.Details)
+}
+
+func (e ConflictError) Response() *ErrorResponse {
+	return errorResponse(e.Code, e.Message, e.Details)
+}
+
+// UnavailableError reports that the service cannot accept new work right now,
+// for example while it drains running jobs before shutdown.
+type UnavailableError struct {
+	Code    string
+	Message string
+	Details string
+}
+
+func (e UnavailableError) Error() string {
+	if e.Details == "" {
+		return e.Message
+	}
+	return fmt.Sprintf("%s: %s", e.Message, e.Details)
+}
+
+func (e UnavailableError) Response() *ErrorResponse {
+	return errorResponse(e.Code, e.Message, e.Details)
+}
+
+// ResourceExhaustedError reports that a limit on outstanding work was reached;
+// the client may retry once some of it has finished.
+type ResourceExhaustedError struct {
+	Code    string
+	Message string
+	Details string
+}
+
+func (e ResourceExhaustedError) Error() string {
+	if e.Details == "" {
+		return e.Message
+	}
+	return fmt.Sprintf("%s: %s", e.Message, e.Details)
+}
+
+func (e ResourceExhaustedError) Response() *ErrorResponse {
+	return errorResponse(e.Code, e.Message, e.Details)
+}
+
+// PermissionDeniedError reports a request that engine policy forbids, such as
+// a base image outside images.allowed.
+type PermissionDeniedError struct {
+	Code    string
+	Message string
+	Details string
+}
+
+func (e PermissionDeniedError) Error() string {
+	if e.Details == "" {
+		return e.Message
+	}
+	return fmt.Sprintf("%s: %s", e.Message, e.Details)
+}
+
+func (e PermissionDeniedError) Response() *ErrorResponse {
+	return errorResponse(e.Code, e.Message, e.Details)
+}
+
 func ToErrorResponse(err error) *ErrorResponse {
 	if err == nil {
 		return nil
@@ -28,8 +145,31 @@ func ToErrorResponse(err error) *ErrorResponse {
 		return v.Response()
 	case *ValidationError:
 		return v.Response()
+	case ConflictError:
+		return v.Response()
+	case *ConflictError:
+		return v.Response()
+	case UnavailableError:
+		return v.Response()
+	case ResourceExhaustedError:
+		return v.Response()
+	case PermissionDeniedError:
+		return v.Response()
+	default:
+		return errorResponse(ErrorCodeInternal, "internal error", err.Error())
+	}
+}
+
+// runtimeErrorCode classifies a container runtime error: an unreachable
+// runtime and a missing image get their own codes, anything else gets code.
+func runtimeErrorCode(err error, code string) string {
+	switch {
+	case engineRuntime.IsUnavailableError(err):
+		return ErrorCodeRuntimeUnavailable
+	case engineRuntime.IsImageNotFoundError(err):
+		return ErrorCodeImageNotFound
 	default:
-		return errorResponse("internal_error", "internal error", err.Error())
+		return code
 	}
 }
 
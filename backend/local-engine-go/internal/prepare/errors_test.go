@@ -1,8 +1,12 @@
 package prepare
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"testing"
+
+	engineRuntime "github.com/sqlrs/engine-local/internal/runtime"
 )
 
 func TestValidationErrorMessage(t *testing.T) {
@@ -40,3 +44,78 @@ func TestToErrorResponse(t *testing.T) {
 		t.Fatalf("expected nil response, got %+v", resp)
 	}
 }
+
+func TestRuntimeErrorCode(t *testing.T) {
+	cases := []struct {
+		err  error
+		want string
+	}{
+		{err: engineRuntime.DockerUnavailableError{}, want: "runtime_unavailable"},
+		{err: errors.New("docker pull failed: manifest for postgres:99 not found: manifest unknown"), want: "image_not_found"},
+		{err: errors.New("docker pull failed: toomanyrequests"), want: "container_start_failed"},
+	}
+	for _, tc := range cases {
+		if got := runtimeErrorCode(tc.err, ErrorCodeContainerStartFailed); got != tc.want {
+			t.Fatalf("runtimeErrorCode(%v) = %q, want %q", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestEnsureResolvedImageIDErrorCodes(t *testing.T) {
+	overrideRuntimeRetrySleep(t)
+	cases := []struct {
+		err  error
+		want string
+	}{
+		{err: errors.New("docker pull failed: pull access denied for acme/pg, repository does not exist"), want: "image_not_found"},
+		{err: fmt.Errorf("docker is not running: %w", engineRuntime.DockerUnavailableError{}), want: "runtime_unavailable"},
+		{err: errors.New("docker inspect failed: exit status 1"), want: "image_resolve_failed"},
+	}
+	for _, tc := range cases {
+		rt := &fakeRuntime{resolveErr: tc.err}
+		mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{runtime: rt})
+		prepared := &preparedRequest{request: Request{PrepareKind: "psql", ImageID: "acme/pg:17"}}
+		errResp := mgr.ensureResolvedImageID(context.Background(), "job-1", prepared, nil)
+		if errResp == nil || errResp.Code != tc.want {
+			t.Fatalf("%v: expected %s, got %+v", tc.err, tc.want, errResp)
+		}
+	}
+}
+
+func TestStartRuntimeErrorCodes(t *testing.T) {
+	overrideRuntimeRetrySleep(t)
+	cases := []struct {
+		name string
+		rt   *fakeRuntime
+		want string
+	}{
+		{name: "init", rt: &fakeRuntime{initErr: errors.New("initdb: could not create directory")}, want: "base_init_failed"},
+		{name: "start", rt: &fakeRuntime{startErr: errors.New("port is already allocated")}, want: "container_start_failed"},
+		{name: "unavailable", rt: &fakeRuntime{startErr: engineRuntime.DockerUnavailableError{}}, want: "runtime_unavailable"},
+	}
+	for _, tc := range cases {
+		mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{runtime: tc.rt})
+		prepared := preparedRequest{request: Request{PrepareKind: "psql", ImageID: "image-1@sha256:abc"}}
+		_, errResp := mgr.startRuntime(context.Background(), "job-1", prepared, &TaskInput{Kind: "image", ID: "image-1@sha256:abc"})
+		if errResp == nil || errResp.Code != tc.want {
+			t.Fatalf("%s: expected %s, got %+v", tc.name, tc.want, errResp)
+		}
+	}
+}
+
+func TestSubmitReportsMigrationFailed(t *testing.T) {
+	psql := &fakePsqlRunner{output: "ERROR: syntax error", err: errors.New("exit status 3")}
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{psql: psql})
+	accepted, err := mgr.Submit(context.Background(), Request{
+		PrepareKind: "psql",
+		ImageID:     "image-1",
+		PsqlArgs:    []string{"-c", "selec 1"},
+	})
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	status, ok := mgr.Get(accepted.JobID)
+	if !ok || status.Status != StatusFailed || status.Error == nil || status.Error.Code != "migration_failed" {
+		t.Fatalf("expected migration_failed, got %+v", status)
+	}
+}
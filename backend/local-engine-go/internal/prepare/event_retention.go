@@ -0,0 +1,110 @@
+package prepare
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sqlrs/engine-local/internal/prepare/queue"
+)
+
+// trackEventRetention counts the job's appended log events and prunes its
+// oldest log events once orchestrator.events.maxPerJob is exceeded. The count
+// is checked every tenth of the limit rather than on every append, and a
+// prune goes down to three quarters of the limit, so a chatty job pays for a
+// count query now and then and a prune only every quarter limit.
+func (m *PrepareService) trackEventRetention(jobID string, event Event) {
+	if event.Type == "status" && (event.Status == StatusSucceeded || event.Status == StatusFailed) {
+		m.mu.Lock()
+		delete(m.logAppends, jobID)
+		m.mu.Unlock()
+		return
+	}
+	if event.Type != "log" {
+		return
+	}
+	limit := m.maxEventsPerJob()
+	if limit <= 0 {
+		return
+	}
+	checkEvery := limit / 10
+	if checkEvery < 1 {
+		checkEvery = 1
+	}
+	m.mu.Lock()
+	if m.logAppends == nil {
+		m.logAppends = map[string]int{}
+	}
+	m.logAppends[jobID]++
+	due := m.logAppends[jobID] >= checkEvery
+	if due {
+		m.logAppends[jobID] = 0
+	}
+	m.mu.Unlock()
+	if due {
+		m.pruneJobEvents(context.Background(), jobID, limit)
+	}
+}
+
+// pruneJobEvents drops the job's oldest log events when it has more than
+// limit events and appends one "events truncated" log event saying how many
+// went. Status, task, plan, result and error events are never pruned, so the
+// stream keeps its milestones and terminal status. Streams follow seq, so
+// attached and resuming clients simply see a gap in seq.
+func (m *PrepareService) pruneJobEvents(ctx context.Context, jobID string, limit int) {
+	count, err := m.queue.CountEvents(ctx, jobID)
+	if err != nil {
+		m.logErrorJob(jobID, "event retention count failed: %v", err)
+		return
+	}
+	if count <= limit {
+		return
+	}
+	keep := limit - limit/4
+	tail, err := m.queue.ListEventsSince(ctx, jobID, count-keep)
+	if err != nil {
+		m.logErrorJob(jobID, "event retention lookup failed: %v", err)
+		return
+	}
+	if len(tail) == 0 {
+		return
+	}
+	deleted, err := m.queue.PruneEvents(ctx, jobID, tail[0].Seq)
+	if err != nil {
+		m.logErrorJob(jobID, "event retention prune failed: %v", err)
+		return
+	}
+	if deleted == 0 {
+		return
+	}
+	m.logInfoJob(jobID, "pruned %d log events (limit %d)", deleted, limit)
+	// The marker goes straight to the queue: appending it through appendEvent
+	// would count towards the next check.
+	message := fmt.Sprintf("events truncated: %d earlier log events dropped (orchestrator.events.maxPerJob=%d)", deleted, limit)
+	if _, err := m.queue.AppendEvent(ctx, queue.EventRecord{
+		JobID:   jobID,
+		Type:    "log",
+		Ts:      m.now().UTC().Format(time.RFC3339Nano),
+		Message: &message,
+	}); err != nil {
+		m.logErrorJob(jobID, "event retention marker failed: %v", err)
+		return
+	}
+	m.events.notify(jobID)
+}
+
+// maxEventsPerJob reads orchestrator.events.maxPerJob; zero or unset keeps
+// every event.
+func (m *PrepareService) maxEventsPerJob() int {
+	if m.config == nil {
+		return 0
+	}
+	value, err := m.config.Get("orchestrator.events.maxPerJob", true)
+	if err != nil || value == nil {
+		return 0
+	}
+	if num, ok := configValueToInt(value); ok && num > 0 {
+		return num
+	}
+	return 0
+}
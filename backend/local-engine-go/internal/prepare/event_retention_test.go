@@ -0,0 +1,156 @@
+package prepare
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sqlrs/engine-local/internal/prepare/queue"
+)
+
+func TestEventRetentionKeepsMilestonesAndTerminalStatus(t *testing.T) {
+	q := newQueueStore(t)
+	mgr := newManagerWithDeps(t, &fakeStore{}, q, &testDeps{config: &fakeConfigStore{values: map[string]any{
+		"orchestrator.events.maxPerJob": 20,
+	}}})
+	if err := q.CreateJob(context.Background(), queue.JobRecord{
+		JobID:       "job-1",
+		Status:      StatusRunning,
+		PrepareKind: "lb",
+		ImageID:     "image-1",
+		CreatedAt:   time.Now().UTC().Format(time.RFC3339Nano),
+	}); err != nil {
+		t.Fatalf("CreateJob: %v", err)
+	}
+	ts := time.Now().UTC().Format(time.RFC3339Nano)
+	if err := mgr.appendEvent("job-1", Event{Type: "status", Ts: ts, Status: StatusRunning}); err != nil {
+		t.Fatalf("appendEvent: %v", err)
+	}
+	if err := mgr.appendEvent("job-1", Event{Type: "task", Ts: ts, Status: StatusRunning, TaskID: "execute-0"}); err != nil {
+		t.Fatalf("appendEvent: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		mgr.appendLog("job-1", fmt.Sprintf("liquibase: line %d", i))
+	}
+	if err := mgr.appendEvent("job-1", Event{Type: "task", Ts: ts, Status: StatusSucceeded, TaskID: "execute-0"}); err != nil {
+		t.Fatalf("appendEvent: %v", err)
+	}
+	if err := mgr.appendEvent("job-1", Event{Type: "status", Ts: ts, Status: StatusSucceeded}); err != nil {
+		t.Fatalf("appendEvent: %v", err)
+	}
+
+	events, err := q.ListEventsAfterSeq(context.Background(), "job-1", 0)
+	if err != nil {
+		t.Fatalf("ListEventsAfterSeq: %v", err)
+	}
+	if len(events) > 20+4 {
+		t.Fatalf("expected events to stay near the limit, got %d", len(events))
+	}
+	var milestones []string
+	var markers int
+	var newest string
+	var lastSeq int64
+	for _, event := range events {
+		if event.Seq <= lastSeq {
+			t.Fatalf("expected increasing seqs, got %d after %d", event.Seq, lastSeq)
+		}
+		lastSeq = event.Seq
+		if event.Type != "log" {
+			milestones = append(milestones, event.Type+"/"+valueOrEmpty(event.Status))
+			continue
+		}
+		if strings.HasPrefix(valueOrEmpty(event.Message), "events truncated:") {
+			markers++
+			continue
+		}
+		newest = valueOrEmpty(event.Message)
+	}
+	want := []string{"status/running", "task/running", "task/succeeded", "status/succeeded"}
+	if strings.Join(milestones, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected milestones %v, got %v", want, milestones)
+	}
+	if markers == 0 {
+		t.Fatalf("expected a truncation marker, got %+v", events)
+	}
+	if newest != "liquibase: line 99" {
+		t.Fatalf("expected the newest log to be kept, got %q", newest)
+	}
+	if _, ok := mgr.logAppends["job-1"]; ok {
+		t.Fatalf("expected the retention counter to be dropped with the terminal status")
+	}
+}
+
+func TestEventRetentionUnlimitedByDefault(t *testing.T) {
+	q := newQueueStore(t)
+	mgr := newManagerWithDeps(t, &fakeStore{}, q, &testDeps{})
+	if err := q.CreateJob(context.Background(), queue.JobRecord{
+		JobID:       "job-1",
+		Status:      StatusRunning,
+		PrepareKind: "psql",
+		ImageID:     "image-1",
+		CreatedAt:   time.Now().UTC().Format(time.RFC3339Nano),
+	}); err != nil {
+		t.Fatalf("CreateJob: %v", err)
+	}
+	for i := 0; i < 50; i++ {
+		mgr.appendLog("job-1", fmt.Sprintf("psql: line %d", i))
+	}
+	if count, err := q.CountEvents(context.Background(), "job-1"); err != nil || count != 50 {
+		t.Fatalf("expected every event to be kept, count=%d err=%v", count, err)
+	}
+}
+
+func TestEventRetentionPruneWakesAttachedStream(t *testing.T) {
+	q := newQueueStore(t)
+	mgr := newManagerWithDeps(t, &fakeStore{}, q, &testDeps{})
+	if err := q.CreateJob(context.Background(), queue.JobRecord{
+		JobID:       "job-1",
+		Status:      StatusRunning,
+		PrepareKind: "lb",
+		ImageID:     "image-1",
+		CreatedAt:   time.Now().UTC().Format(time.RFC3339Nano),
+	}); err != nil {
+		t.Fatalf("CreateJob: %v", err)
+	}
+	for i := 0; i < 40; i++ {
+		mgr.appendLog("job-1", fmt.Sprintf("liquibase: line %d", i))
+	}
+	events, ok, _, err := mgr.EventsAfterSeq("job-1", 0)
+	if err != nil || !ok || len(events) != 40 {
+		t.Fatalf("EventsAfterSeq: %d events ok=%v err=%v", len(events), ok, err)
+	}
+	lastSeq := events[len(events)-1].Seq
+
+	// The stream has sent everything and waits; pruning shrinks the event
+	// count below what it has already seen.
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	waitErr := make(chan error, 1)
+	go func() {
+		waitErr <- mgr.WaitForEvent(ctx, "job-1", lastSeq)
+	}()
+	deadline := time.Now().Add(2 * time.Second)
+	for mgr.EventSubscribers() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for subscription")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	mgr.pruneJobEvents(context.Background(), "job-1", 20)
+	if err := <-waitErr; err != nil {
+		t.Fatalf("WaitForEvent: %v", err)
+	}
+
+	events, ok, _, err = mgr.EventsAfterSeq("job-1", lastSeq)
+	if err != nil || !ok {
+		t.Fatalf("EventsAfterSeq: ok=%v err=%v", ok, err)
+	}
+	if len(events) != 1 || !strings.HasPrefix(events[0].Message, "events truncated:") {
+		t.Fatalf("expected the stream to continue with the truncation marker, got %+v", events)
+	}
+	if seq, ok, err := mgr.EventSeqBeforeOffset("job-1", 1000); err != nil || !ok || seq != events[0].Seq {
+		t.Fatalf("expected an offset past the end to resolve to the latest seq, got %d ok=%v err=%v", seq, ok, err)
+	}
+}
@@ -24,6 +24,15 @@ type PsqlRunRequest struct {
 	Env     map[string]string
 	Stdin   *string
 	WorkDir string
+	// Script is the expanded SQL of the step for runners that execute it
+	// without psql; nil when the step needs psql.
+	Script *string
+	// StatementTimeout, when positive, is set as the session statement_timeout
+	// before the script runs.
+	StatementTimeout time.Duration
+	// User is the role runners that connect themselves log in as; empty
+	// means the default superuser.
+	User string
 }
 
 type LiquibaseRunRequest struct {
@@ -35,6 +44,15 @@ type LiquibaseRunRequest struct {
 	WorkDir  string
 	Mounts   []engineRuntime.Mount
 	Network  string
+	DNS      []string
+}
+
+type FlywayRunRequest struct {
+	ExecPath string
+	ExecMode string
+	Args     []string
+	Env      map[string]string
+	WorkDir  string
 }
 
 type psqlRunner interface {
@@ -45,6 +63,10 @@ type liquibaseRunner interface {
 	Run(ctx context.Context, req LiquibaseRunRequest) (string, error)
 }
 
+type flywayRunner interface {
+	Run(ctx context.Context, req FlywayRunRequest) (string, error)
+}
+
 var listNetInterfaces = net.Interfaces
 var ifaceAddrs = func(iface net.Interface) ([]net.Addr, error) {
 	return iface.Addrs()
@@ -66,10 +88,20 @@ func (r containerPsqlRunner) Run(ctx context.Context, instance engineRuntime.Ins
 	if r.runtime == nil {
 		return "", fmt.Errorf("runtime is required")
 	}
+	env := req.Env
+	if req.StatementTimeout > 0 {
+		// PGOPTIONS applies the setting at connect time, before the first
+		// statement and outside any --single-transaction block.
+		env = make(map[string]string, len(req.Env)+1)
+		for key, value := range req.Env {
+			env[key] = value
+		}
+		env["PGOPTIONS"] = "-c statement_timeout=" + statementTimeoutSetting(req.StatementTimeout)
+	}
 	return r.runtime.Exec(ctx, instance.ID, engineRuntime.ExecRequest{
 		User:  "postgres",
 		Args:  req.Args,
-		Env:   req.Env,
+		Env:   env,
 		Dir:   req.WorkDir,
 		Stdin: req.Stdin,
 	})
@@ -90,6 +122,7 @@ func (r containerLiquibaseRunner) Run(ctx context.Context, req LiquibaseRunReque
 		Dir:     req.WorkDir,
 		Mounts:  req.Mounts,
 		Network: req.Network,
+		DNS:     req.DNS,
 	})
 }
 
@@ -114,7 +147,7 @@ func resolveStatePaths(root string, imageID string, stateID string, fs statefs.S
 	if fs == nil {
 		return statePaths{}, fmt.Errorf("statefs is required")
 	}
-	baseDir, err := fs.BaseDir(root, imageID)
+	baseDir, err := resolveBaseDir(root, imageID, fs)
 	if err != nil {
 		return statePaths{}, err
 	}
@@ -139,6 +172,37 @@ func resolveStatePaths(root string, imageID string, stateID string, fs statefs.S
 	}, nil
 }
 
+// resolveBaseDir keys the base state on the image digest, so tags pinned to
+// one digest ("postgres:16@sha256:x", "postgres:latest@sha256:x") share a
+// single base init. A base already initialized under the tagged layout keeps
+// being used.
+func resolveBaseDir(root string, imageID string, fs statefs.StateFS) (string, error) {
+	baseDir, err := fs.BaseDir(root, imageID)
+	if err != nil {
+		return "", err
+	}
+	digestImageID := digestKeyedImageID(imageID)
+	if digestImageID == strings.TrimSpace(imageID) || initMarkerExists(baseDir) {
+		return baseDir, nil
+	}
+	return fs.BaseDir(root, digestImageID)
+}
+
+// digestKeyedImageID drops the tag from a digest reference; references
+// without a digest are returned unchanged.
+func digestKeyedImageID(imageID string) string {
+	imageID = strings.TrimSpace(imageID)
+	if !hasImageDigest(imageID) {
+		return imageID
+	}
+	at := strings.LastIndex(imageID, "@")
+	repo := imageID[:at]
+	if colon := strings.LastIndex(repo, ":"); colon > strings.LastIndex(repo, "/") {
+		repo = repo[:colon]
+	}
+	return repo + imageID[at:]
+}
+
 func mergeEnv(base []string, overrides map[string]string) []string {
 	if len(overrides) == 0 {
 		return base
@@ -173,17 +237,17 @@ func normalizeEnvKey(key string) string {
 func (e *taskExecutor) executeStateTask(ctx context.Context, jobID string, prepared preparedRequest, task taskState) (string, *ErrorResponse) {
 	m := e.m
 	if ctx.Err() != nil {
-		return "", errorResponse("cancelled", "task cancelled", "")
+		return "", errorResponse(ErrorCodeCancelled, "task cancelled", "")
 	}
 	if task.Input == nil {
-		return "", errorResponse("internal_error", "task input is required", "")
+		return "", errorResponse(ErrorCodeInternal, "task input is required", "")
 	}
 	outputStateID := task.OutputStateID
 	taskHash := task.TaskHash
 
 	runner, ephemeral := m.runnerForJob(jobID)
 	if runner == nil {
-		return "", errorResponse("internal_error", "job runner missing", "")
+		return "", errorResponse(ErrorCodeInternal, "job runner missing", "")
 	}
 	if ephemeral {
 		defer m.cleanupRuntime(context.Background(), runner)
@@ -195,15 +259,15 @@ func (e *taskExecutor) executeStateTask(ctx context.Context, jobID string, prepa
 	if prepared.request.PrepareKind == "psql" {
 		step, err := psqlStepForPreparedTask(prepared, task.TaskID)
 		if err != nil {
-			return "", errorResponse("internal_error", "cannot resolve psql step", err.Error())
+			return "", errorResponse(ErrorCodeInternal, "cannot resolve psql step", err.Error())
 		}
 		lock := &contentLock{files: map[string]*os.File{}}
-		digest, err := computePsqlContentDigestWithLock(step.inputs, prepared.psqlWorkDir, lock)
+		digest, err := computePsqlContentDigestWithLock(step.inputs, prepared.psqlWorkDir, lock, prepared.psqlLimits, prepared.psqlNormalizeHash)
 		if err != nil {
 			_ = lock.Close()
-			return "", errorResponse("invalid_argument", "cannot compute psql content hash", err.Error())
+			return "", errorResponse(ErrorCodeInvalidArgument, "cannot compute psql content hash", err.Error())
 		}
-		taskHash = psqlTaskHash(prepared.request.PrepareKind, digest.hash, m.version)
+		taskHash = psqlPreparedTaskHash(prepared, digest.hash, m.cacheVersion())
 		contentLocker = lock
 	}
 
@@ -229,29 +293,65 @@ func (e *taskExecutor) executeStateTask(ctx context.Context, jobID string, prepa
 			}
 			if len(changesets) == 0 {
 				_ = lock.Close()
-				return "", errorResponse("internal_error", "liquibase returned no pending changesets", "")
+				return "", errorResponse(ErrorCodeInternal, "liquibase returned no pending changesets", "")
 			}
 			parentFingerprintID := ""
 			if task.Input != nil {
 				parentFingerprintID = task.Input.ID
 			}
-			taskHash = liquibaseFingerprint(strings.TrimSpace(parentFingerprintID), []LiquibaseChangeset{changesets[0]})
+			taskHash = prepared.liquibaseTaskHash(strings.TrimSpace(parentFingerprintID), []LiquibaseChangeset{changesets[0]})
 		}
 	}
 
+	if prepared.request.PrepareKind == "flyway" && strings.TrimSpace(taskHash) == "" {
+		// Like Liquibase, flyway task hashes come from planning; recompute from
+		// the next pending migration only for tasks stored without one.
+		planned, errResp := e.ensureRuntime(ctx, jobID, prepared, task.Input, runner)
+		if errResp != nil {
+			return "", errResp
+		}
+		rt = planned
+		migrations, errResp := e.runFlywayInfo(ctx, jobID, prepared, rt)
+		if errResp != nil {
+			return "", errResp
+		}
+		if len(migrations) == 0 {
+			return "", errorResponse(ErrorCodeInternal, "flyway returned no pending migrations", "")
+		}
+		parentFingerprintID := ""
+		if task.Input != nil {
+			parentFingerprintID = task.Input.ID
+		}
+		taskHash = flywayFingerprint(strings.TrimSpace(parentFingerprintID), prepared.argsNormalized, migrations[:1])
+	}
+
 	if contentLocker != nil {
 		defer contentLocker.Close()
 	}
 
 	if taskHash != "" {
-		if output, errResp := m.computeOutputStateID(task.Input.Kind, task.Input.ID, taskHash); errResp == nil {
+		if output, errResp := m.computeOutputStateID(prepared.request.Namespace, task.Input.Kind, task.Input.ID, taskHash); errResp == nil {
 			outputStateID = output
 		}
 	}
 
 	cached, err := m.isStateCached(outputStateID)
 	if err != nil {
-		return "", errorResponse("internal_error", "cannot check state cache", err.Error())
+		return "", errorResponse(ErrorCodeStoreNotReady, "cannot check state cache", err.Error())
+	}
+	// A no_cache job rebuilds a cached state next to it and swaps it in once
+	// the snapshot is complete, so jobs already using the state keep working.
+	replaceCached := cached && prepared.request.NoCache
+	if replaceCached {
+		m.appendLog(jobID, fmt.Sprintf("no_cache: rebuilding cached state %s", outputStateID))
+		cached = false
+	}
+	if cached {
+		invalidated, errResp := m.invalidateCorruptCachedState(ctx, jobID, prepared, outputStateID)
+		if errResp != nil {
+			return "", errResp
+		}
+		cached = !invalidated
 	}
 	forceRebuild := false
 	m.logInfoJob(jobID, "state cache decision task=%s input_kind=%s input_id=%s task_hash=%s output_state=%s cached=%t",
@@ -292,33 +392,33 @@ func (e *taskExecutor) executeStateTask(ctx context.Context, jobID string, prepa
 
 	imageID := prepared.effectiveImageID()
 	if strings.TrimSpace(imageID) == "" {
-		return "", errorResponse("internal_error", "resolved image id is required", "")
+		return "", errorResponse(ErrorCodeInternal, "resolved image id is required", "")
 	}
-	paths, err := resolveStatePaths(m.stateStoreRoot, imageID, outputStateID, m.statefs)
+	paths, err := resolveStatePaths(m.namespaceRoot(prepared.request.Namespace), imageID, outputStateID, m.statefs)
 	if err != nil {
-		return "", errorResponse("internal_error", "cannot resolve state paths", err.Error())
+		return "", errorResponse(ErrorCodeStoreNotReady, "cannot resolve state paths", err.Error())
 	}
 	if err := os.MkdirAll(paths.statesDir, 0o700); err != nil {
 		if noSpaceResp := noSpaceErrorResponse("insufficient storage before state execution", "prepare_step", err); noSpaceResp != nil {
 			return "", noSpaceResp
 		}
-		return "", errorResponse("internal_error", "cannot create state dir", err.Error())
+		return "", errorResponse(ErrorCodeStoreNotReady, "cannot create state dir", err.Error())
 	}
 	if err := m.statefs.EnsureStateDir(ctx, paths.stateDir); err != nil {
 		if noSpaceResp := noSpaceErrorResponse("insufficient storage before state execution", "prepare_step", err); noSpaceResp != nil {
 			return "", noSpaceResp
 		}
-		return "", errorResponse("internal_error", "cannot create state dir", err.Error())
+		return "", errorResponse(ErrorCodeStoreNotReady, "cannot create state dir", err.Error())
 	}
 
 	var errResp *ErrorResponse
 	kind := snapshotKind(m.statefs)
 	lockPath := stateBuildLockPath(paths.stateDir, kind)
 	lockErr := withStateBuildLock(ctx, paths.stateDir, lockPath, kind, func() error {
-		if !forceRebuild {
+		if !forceRebuild && !replaceCached {
 			cached, err := m.isStateCached(outputStateID)
 			if err != nil {
-				errResp = errorResponse("internal_error", "cannot check state cache", err.Error())
+				errResp = errorResponse(ErrorCodeStoreNotReady, "cannot check state cache", err.Error())
 				return errStateBuildFailed
 			}
 			if cached {
@@ -327,9 +427,21 @@ func (e *taskExecutor) executeStateTask(ctx context.Context, jobID string, prepa
 				return nil
 			}
 		}
-		if forceRebuild || kind == "btrfs" || stateBuildMarkerExists(paths.stateDir, kind) {
+		buildDir := paths.stateDir
+		if replaceCached {
+			buildDir = rebuildStateDir(paths.stateDir, jobID)
+			if err := resetStateDir(ctx, m.statefs, buildDir); err != nil {
+				errResp = errorResponse(ErrorCodeStoreNotReady, "cannot reset state dir", err.Error())
+				return errStateBuildFailed
+			}
+			defer func() {
+				if errResp != nil {
+					_ = m.statefs.RemovePath(context.Background(), buildDir)
+				}
+			}()
+		} else if forceRebuild || kind == "btrfs" || stateBuildMarkerExists(paths.stateDir, kind) {
 			if err := resetStateDir(ctx, m.statefs, paths.stateDir); err != nil {
-				errResp = errorResponse("internal_error", "cannot reset state dir", err.Error())
+				errResp = errorResponse(ErrorCodeStoreNotReady, "cannot reset state dir", err.Error())
 				return errStateBuildFailed
 			}
 		}
@@ -342,7 +454,9 @@ func (e *taskExecutor) executeStateTask(ctx context.Context, jobID string, prepa
 			}
 			rt = planned
 		}
-		if execErr := e.executePrepareStep(ctx, jobID, prepared, rt, task); execErr != nil {
+		if execErr := e.timePhase(ctx, jobID, "execute", func() *ErrorResponse {
+			return e.executePrepareStep(ctx, jobID, prepared, rt, task)
+		}); execErr != nil {
 			if noSpaceResp := noSpaceFromErrorResponse("prepare step failed due to insufficient storage", "prepare_step", execErr); noSpaceResp != nil {
 				errResp = noSpaceResp
 			} else {
@@ -354,16 +468,22 @@ func (e *taskExecutor) executeStateTask(ctx context.Context, jobID string, prepa
 			errResp = capErr
 			return errStateBuildFailed
 		}
+		if capErr := m.ensureSnapshotHeadroom(ctx, jobID, rt.dataDir); capErr != nil {
+			errResp = capErr
+			return errStateBuildFailed
+		}
 
 		m.appendLog(jobID, "pg_ctl: stop for snapshot")
 		pgCtx := engineRuntime.WithLogSink(ctx, func(line string) {
 			m.appendLog(jobID, "pg_ctl: "+line)
 		})
-		if err := m.dbms.PrepareSnapshot(pgCtx, rt.instance); err != nil {
+		if err := e.timePhaseErr(ctx, jobID, "prepare_snapshot", func() error {
+			return m.dbms.PrepareSnapshot(pgCtx, rt.instance)
+		}); err != nil {
 			if noSpaceResp := noSpaceErrorResponse("insufficient storage during snapshot", "snapshot", err); noSpaceResp != nil {
 				errResp = noSpaceResp
 			} else {
-				errResp = errorResponse("internal_error", "snapshot prepare failed", err.Error())
+				errResp = errorResponse(ErrorCodeSnapshotFailed, "snapshot prepare failed", err.Error())
 			}
 			return errStateBuildFailed
 		}
@@ -376,36 +496,52 @@ func (e *taskExecutor) executeStateTask(ctx context.Context, jobID string, prepa
 		}()
 
 		m.appendLog(jobID, "snapshot: start")
-		m.logInfoJob(jobID, "snapshot start dir=%s", paths.stateDir)
-		if err := m.statefs.Snapshot(ctx, rt.dataDir, paths.stateDir); err != nil {
-			if noSpaceResp := noSpaceErrorResponse("insufficient storage during snapshot", "snapshot", err); noSpaceResp != nil {
-				errResp = noSpaceResp
-			} else {
-				errResp = errorResponse("internal_error", "snapshot failed", err.Error())
-			}
+		m.logInfoJob(jobID, "snapshot start dir=%s", buildDir)
+		if snapResp := m.snapshotStateDir(ctx, jobID, rt.dataDir, buildDir, func() error {
+			return e.timePhaseErr(ctx, jobID, "snapshot", func() error {
+				return m.statefs.Snapshot(ctx, rt.dataDir, buildDir)
+			})
+		}); snapResp != nil {
+			errResp = snapResp
 			return errStateBuildFailed
 		}
 		m.appendLog(jobID, "snapshot: complete")
-		m.logInfoJob(jobID, "snapshot complete dir=%s", paths.stateDir)
+		m.logInfoJob(jobID, "snapshot complete dir=%s", buildDir)
+		if !replaceCached {
+			if checksumErr := m.writeStateChecksums(ctx, jobID, outputStateID, paths.stateDir); checksumErr != nil {
+				errResp = checksumErr
+				return errStateBuildFailed
+			}
+		}
 		m.appendLog(jobID, "pg_ctl: start after snapshot")
 		pgResumeCtx := engineRuntime.WithLogSink(ctx, func(line string) {
 			m.appendLog(jobID, "pg_ctl: "+line)
 		})
-		if err := m.dbms.ResumeSnapshot(pgResumeCtx, rt.instance); err != nil {
+		if err := e.timePhaseErr(ctx, jobID, "resume_snapshot", func() error {
+			return m.dbms.ResumeSnapshot(pgResumeCtx, rt.instance)
+		}); err != nil {
 			if noSpaceResp := noSpaceErrorResponse("insufficient storage during snapshot", "snapshot", err); noSpaceResp != nil {
 				errResp = noSpaceResp
 			} else {
-				errResp = errorResponse("internal_error", "snapshot resume failed", err.Error())
+				errResp = errorResponse(ErrorCodeSnapshotFailed, "snapshot resume failed", err.Error())
 			}
 			return errStateBuildFailed
 		}
 		resumed = true
 
+		if replaceCached {
+			errResp = m.commitReplacedState(ctx, jobID, outputStateID, paths.stateDir, buildDir, kind)
+			if errResp != nil {
+				return errStateBuildFailed
+			}
+			return nil
+		}
+
 		parentID := parentStateID(task.Input)
 		createdAt := m.now().UTC().Format(time.RFC3339Nano)
 		stateSize, measureErr := storeUsageFn(paths.stateDir)
 		if measureErr != nil {
-			errResp = capacityError("internal_error", "cannot measure state size", map[string]any{
+			errResp = capacityError(ErrorCodeInternal, "cannot measure state size", map[string]any{
 				"phase": "metadata_commit",
 				"error": measureErr.Error(),
 			})
@@ -423,17 +559,19 @@ func (e *taskExecutor) executeStateTask(ctx context.Context, jobID string, prepa
 			PrepareArgsNormalized: prepared.argsNormalized,
 			CreatedAt:             createdAt,
 			SizeBytes:             &stateSize,
+			Namespace:             prepared.request.Namespace,
+			TaskHash:              taskHash,
 		}
 		if err := m.store.CreateState(ctx, entry); err != nil {
 			if ctx.Err() != nil {
-				errResp = errorResponse("cancelled", "task cancelled", "")
+				errResp = errorResponse(ErrorCodeCancelled, "task cancelled", "")
 				return errStateBuildFailed
 			}
 			_ = m.statefs.RemovePath(context.Background(), paths.stateDir)
 			if noSpaceResp := noSpaceErrorResponse("insufficient storage during metadata commit", "metadata_commit", err); noSpaceResp != nil {
 				errResp = noSpaceResp
 			} else {
-				errResp = errorResponse("internal_error", "cannot store state", err.Error())
+				errResp = errorResponse(ErrorCodeInternal, "cannot store state", err.Error())
 			}
 			return errStateBuildFailed
 		}
@@ -441,7 +579,7 @@ func (e *taskExecutor) executeStateTask(ctx context.Context, jobID string, prepa
 			if noSpaceResp := noSpaceErrorResponse("insufficient storage during metadata commit", "metadata_commit", err); noSpaceResp != nil {
 				errResp = noSpaceResp
 			} else {
-				errResp = errorResponse("internal_error", "cannot write state marker", err.Error())
+				errResp = errorResponse(ErrorCodeInternal, "cannot write state marker", err.Error())
 			}
 			return errStateBuildFailed
 		}
@@ -456,13 +594,38 @@ func (e *taskExecutor) executeStateTask(ctx context.Context, jobID string, prepa
 	}
 	if lockErr != nil {
 		if ctx.Err() != nil {
-			return "", errorResponse("cancelled", "task cancelled", "")
+			return "", errorResponse(ErrorCodeCancelled, "task cancelled", "")
 		}
-		return "", errorResponse("internal_error", "cannot acquire state build lock", lockErr.Error())
+		return "", errorResponse(ErrorCodeInternal, "cannot acquire state build lock", lockErr.Error())
 	}
 	return outputStateID, nil
 }
 
+// timePhase runs one sub-phase of a state_execute task and appends
+// "phase=<name> dur=<duration>" to the job log, so a slow step shows whether
+// the script or the snapshot took the time. The line is written on failure
+// too. With tracing enabled each phase is also a child span of the task.
+func (e *taskExecutor) timePhase(ctx context.Context, jobID string, phase string, fn func() *ErrorResponse) *ErrorResponse {
+	span := e.m.startPhaseSpan(ctx, phase)
+	start := time.Now()
+	errResp := fn()
+	e.m.appendLog(jobID, fmt.Sprintf("phase=%s dur=%s", phase, time.Since(start).Round(time.Millisecond)))
+	endSpan(span, errResp)
+	return errResp
+}
+
+func (e *taskExecutor) timePhaseErr(ctx context.Context, jobID string, phase string, fn func() error) error {
+	var err error
+	e.timePhase(ctx, jobID, phase, func() *ErrorResponse {
+		err = fn()
+		if err != nil {
+			return errorResponse(ErrorCodeInternal, phase+" failed", err.Error())
+		}
+		return nil
+	})
+	return err
+}
+
 func (e *taskExecutor) backfillCachedStateSizeIfMissing(ctx context.Context, jobID string, stateID string) {
 	m := e.m
 	entry, ok, err := m.store.GetState(ctx, stateID)
@@ -476,7 +639,7 @@ func (e *taskExecutor) backfillCachedStateSizeIfMissing(ctx context.Context, job
 	if entry.SizeBytes != nil && *entry.SizeBytes > 0 {
 		return
 	}
-	paths, err := resolveStatePaths(m.stateStoreRoot, entry.ImageID, stateID, m.statefs)
+	paths, err := resolveStatePaths(m.namespaceRoot(entry.Namespace), entry.ImageID, stateID, m.statefs)
 	if err != nil {
 		m.logInfoJob(jobID, "cached state size backfill skipped state=%s reason=resolve_paths_failed err=%v", stateID, err)
 		return
@@ -501,8 +664,10 @@ func (e *taskExecutor) executePrepareStep(ctx context.Context, jobID string, pre
 		return e.executePsqlStep(ctx, jobID, prepared, rt, task)
 	case "lb":
 		return e.executeLiquibaseStep(ctx, jobID, prepared, rt, task)
+	case "flyway":
+		return e.executeFlywayStep(ctx, jobID, prepared, rt, task)
 	default:
-		return errorResponse("internal_error", "unsupported prepare kind", prepared.request.PrepareKind)
+		return errorResponse(ErrorCodeInternal, "unsupported prepare kind", prepared.request.PrepareKind)
 	}
 }
 
@@ -523,37 +688,56 @@ func (e *taskExecutor) executePsqlStep(ctx context.Context, jobID string, prepar
 	m := e.m
 	step, err := psqlStepForPreparedTask(prepared, task.TaskID)
 	if err != nil {
-		return errorResponse("internal_error", "cannot resolve psql step", err.Error())
+		return errorResponse(ErrorCodeInternal, "cannot resolve psql step", err.Error())
 	}
-	psqlArgs, workdir, err := buildPsqlExecArgs(step.args, rt.scriptMount)
+	psqlArgs, workdir, err := buildPsqlExecArgs(step.args, rt.scriptMount, m.postgresSuperuser())
 	if err != nil {
-		return errorResponse("internal_error", "cannot prepare psql arguments", err.Error())
+		return errorResponse(ErrorCodeInternal, "cannot prepare psql arguments", err.Error())
 	}
 	if m.psql == nil {
-		return errorResponse("internal_error", "psql runner is required", "")
+		return errorResponse(ErrorCodeInternal, "psql runner is required", "")
+	}
+	runner := m.psql
+	req := PsqlRunRequest{
+		Args:             psqlArgs,
+		Env:              map[string]string{},
+		Stdin:            step.stdin,
+		WorkDir:          workdir,
+		StatementTimeout: m.psqlStatementTimeout(prepared.request),
+		User:             m.postgresSuperuser(),
+	}
+	if rt.pgpassFile != "" {
+		req.Env["PGPASSFILE"] = rt.pgpassFile
+	}
+	if m.psqlRunnerMode() == psqlRunnerNative {
+		runner = pgxPsqlRunner{fallback: m.psql}
+		req.Script = nativePsqlScript(step, prepared.psqlWorkDir, prepared.psqlLimits)
+		if req.Script == nil {
+			m.appendLog(jobID, "psql: script needs psql, using container runner")
+		}
+	}
+	if req.StatementTimeout > 0 {
+		m.appendLog(jobID, fmt.Sprintf("psql: start statement_timeout=%s", req.StatementTimeout))
+	} else {
+		m.appendLog(jobID, "psql: start")
 	}
-	m.appendLog(jobID, "psql: start")
 	var sinkCalled atomic.Bool
 	psqlCtx := engineRuntime.WithLogSink(ctx, func(line string) {
 		sinkCalled.Store(true)
-		m.appendLog(jobID, "psql: "+line)
-	})
-	output, err := m.psql.Run(psqlCtx, rt.instance, PsqlRunRequest{
-		Args:    psqlArgs,
-		Env:     map[string]string{},
-		Stdin:   step.stdin,
-		WorkDir: workdir,
+		m.appendLog(jobID, "psql: "+rt.secrets.redact(line))
 	})
+	output, err := runner.Run(psqlCtx, rt.instance, req)
+	output = rt.secrets.redact(output)
 	if !sinkCalled.Load() && strings.TrimSpace(output) != "" {
 		m.appendLogLines(jobID, "psql", output)
 	}
 	if err != nil {
 		if ctx.Err() != nil {
-			return errorResponse("cancelled", "task cancelled", "")
+			return errorResponse(ErrorCodeCancelled, "task cancelled", "")
 		}
 		details := strings.TrimSpace(output)
 		if details == "" {
-			details = err.Error()
+			details = rt.secrets.redact(err.Error())
 		}
 		if noSpaceResp := noSpaceErrorResponse("prepare step failed due to insufficient storage", "prepare_step", errors.New(details)); noSpaceResp != nil {
 			return noSpaceResp
@@ -561,86 +745,124 @@ func (e *taskExecutor) executePsqlStep(ctx context.Context, jobID string, prepar
 		if noSpaceResp := noSpaceErrorResponse("prepare step failed due to insufficient storage", "prepare_step", err); noSpaceResp != nil {
 			return noSpaceResp
 		}
-		return errorResponse("internal_error", "psql execution failed", details)
+		if req.StatementTimeout > 0 && isStatementTimeoutError(details) {
+			return errorResponse(ErrorCodeDeadlineExceeded, "psql statement timed out", fmt.Sprintf("statement_timeout %s exceeded: %s", req.StatementTimeout, details))
+		}
+		return errorResponse(ErrorCodeMigrationFailed, "psql execution failed", details)
 	}
 	if ctx.Err() != nil {
-		return errorResponse("cancelled", "task cancelled", "")
+		return errorResponse(ErrorCodeCancelled, "task cancelled", "")
 	}
 	return nil
 }
 
-func (e *taskExecutor) executeLiquibaseStep(ctx context.Context, jobID string, prepared preparedRequest, rt *jobRuntime, task taskState) *ErrorResponse {
+// liquibaseInvocation is the part of a liquibase command line shared by every
+// run against the job instance: the mapped user arguments, before the command
+// is rewritten and the engine-owned connection options are prepended.
+type liquibaseInvocation struct {
+	execPath    string
+	execMode    string
+	windowsMode bool
+	args        []string
+	workDir     string
+	env         map[string]string
+	secrets     secretValues
+}
+
+func (e *taskExecutor) liquibaseInvocation(ctx context.Context, prepared preparedRequest) (liquibaseInvocation, *ErrorResponse) {
 	m := e.m
-	if m.liquibase == nil {
-		return errorResponse("internal_error", "liquibase runner is required", "")
-	}
-	if strings.TrimSpace(rt.instance.Host) == "" || rt.instance.Port == 0 {
-		return errorResponse("internal_error", "runtime instance is missing connection info", "")
-	}
-	execMode := normalizeExecMode(prepared.request.LiquibaseExecMode)
+	inv := liquibaseInvocation{execMode: normalizeExecMode(prepared.request.LiquibaseExecMode)}
 	rawExecPath := strings.TrimSpace(prepared.request.LiquibaseExec)
-	windowsMode := shouldUseWindowsBat(rawExecPath, execMode)
-	execPath, err := normalizeLiquibaseExecPath(rawExecPath, windowsMode)
+	inv.windowsMode = shouldUseWindowsBat(rawExecPath, inv.execMode)
+	execPath, err := normalizeLiquibaseExecPath(rawExecPath, inv.windowsMode)
 	if err != nil {
-		return errorResponse("internal_error", "cannot resolve liquibase executable", err.Error())
+		return liquibaseInvocation{}, errorResponse(ErrorCodeInternal, "cannot resolve liquibase executable", err.Error())
 	}
+	inv.execPath = execPath
 	var mapper PathMapper
-	if windowsMode && isWSL() {
+	if inv.windowsMode && isWSL() {
 		mapper = wslPathMapper{}
 	}
 	args, err := mapLiquibaseArgs(prepared.normalizedArgs, mapper)
 	if err != nil {
-		return errorResponse("internal_error", "cannot map liquibase arguments", err.Error())
+		return liquibaseInvocation{}, errorResponse(ErrorCodeInternal, "cannot map liquibase arguments", err.Error())
 	}
 	workDir := strings.TrimSpace(prepared.request.WorkDir)
-	if windowsMode && workDir == "" {
+	if inv.windowsMode && workDir == "" {
 		workDir = deriveLiquibaseWorkDir(args)
 	}
 	if workDir != "" && mapper != nil {
 		mappedDir, mapErr := mapper.MapPath(workDir)
 		if mapErr != nil {
-			return errorResponse("internal_error", "cannot map liquibase workdir", mapErr.Error())
+			return liquibaseInvocation{}, errorResponse(ErrorCodeInternal, "cannot map liquibase workdir", mapErr.Error())
 		}
 		workDir = mappedDir
 	}
-	if !windowsMode {
+	if !inv.windowsMode {
 		args = relativizeLiquibaseHostFileArgs(args, workDir)
 	}
-	args = applyLiquibaseTaskArgs(args, task)
-	args = prependLiquibaseConnectionArgs(args, rt.instance, windowsMode)
-	env, err := mapLiquibaseEnv(prepared.request.LiquibaseEnv, windowsMode)
+	inv.args = args
+	inv.workDir = workDir
+	env, secrets, errResp := m.resolveEnvSecrets(ctx, prepared.request.LiquibaseEnv)
+	if errResp != nil {
+		return liquibaseInvocation{}, errResp
+	}
+	env, err = mapLiquibaseEnv(env, inv.windowsMode)
 	if err != nil {
-		return errorResponse("internal_error", "cannot map liquibase env", err.Error())
+		return liquibaseInvocation{}, errorResponse(ErrorCodeInternal, "cannot map liquibase env", err.Error())
 	}
+	inv.env = env
+	inv.secrets = secrets
+	return inv, nil
+}
 
-	execLine := formatExecLine(execPath, args)
+func (e *taskExecutor) executeLiquibaseStep(ctx context.Context, jobID string, prepared preparedRequest, rt *jobRuntime, task taskState) *ErrorResponse {
+	m := e.m
+	if m.liquibase == nil {
+		return errorResponse(ErrorCodeInternal, "liquibase runner is required", "")
+	}
+	if strings.TrimSpace(rt.instance.Host) == "" || rt.instance.Port == 0 {
+		return errorResponse(ErrorCodeInternal, "runtime instance is missing connection info", "")
+	}
+	inv, errResp := e.liquibaseInvocation(ctx, prepared)
+	if errResp != nil {
+		return errResp
+	}
+	if errResp := e.ensureLiquibaseLockReleased(ctx, jobID, prepared, rt, inv); errResp != nil {
+		return errResp
+	}
+	args := applyLiquibaseTaskArgs(inv.args, task)
+	args = prependLiquibaseConnectionArgs(args, rt.instance, inv.windowsMode, m.postgresSuperuser())
+
+	execLine := formatExecLine(inv.execPath, args)
 	m.appendLog(jobID, fmt.Sprintf("liquibase: exec %s", execLine))
-	m.logJob(jobID, "liquibase exec %s", execLine)
+	m.logDebugJob(jobID, "liquibase exec %s", execLine)
 	m.appendLog(jobID, "liquibase: start")
 	var sinkCalled atomic.Bool
 	lbCtx := engineRuntime.WithLogSink(ctx, func(line string) {
 		sinkCalled.Store(true)
-		m.appendLog(jobID, "liquibase: "+line)
+		m.appendLog(jobID, "liquibase: "+inv.secrets.redact(line))
 	})
 	output, err := m.liquibase.Run(lbCtx, LiquibaseRunRequest{
-		ExecPath: execPath,
-		ExecMode: execMode,
+		ExecPath: inv.execPath,
+		ExecMode: inv.execMode,
 		Args:     args,
-		Env:      env,
-		WorkDir:  workDir,
+		Env:      inv.env,
+		WorkDir:  inv.workDir,
 		Mounts:   prepared.liquibaseMounts,
-		Network:  "",
+		Network:  prepared.request.Network,
+		DNS:      prepared.request.DNS,
 	})
 	if !sinkCalled.Load() && strings.TrimSpace(output) != "" {
-		m.appendLogLines(jobID, "liquibase", output)
+		m.appendLogLines(jobID, "liquibase", inv.secrets.redact(output))
 	}
 	if err != nil {
 		if ctx.Err() != nil {
-			return errorResponse("cancelled", "task cancelled", "")
+			return errorResponse(ErrorCodeCancelled, "task cancelled", "")
 		}
-		details := strings.TrimSpace(output)
+		details := strings.TrimSpace(inv.secrets.redact(output))
 		if details == "" {
-			details = err.Error()
+			details = inv.secrets.redact(err.Error())
 		}
 		if noSpaceResp := noSpaceErrorResponse("prepare step failed due to insufficient storage", "prepare_step", errors.New(details)); noSpaceResp != nil {
 			return noSpaceResp
@@ -648,31 +870,103 @@ func (e *taskExecutor) executeLiquibaseStep(ctx context.Context, jobID string, p
 		if noSpaceResp := noSpaceErrorResponse("prepare step failed due to insufficient storage", "prepare_step", err); noSpaceResp != nil {
 			return noSpaceResp
 		}
-		return errorResponse("internal_error", "liquibase execution failed", details)
+		return errorResponse(ErrorCodeMigrationFailed, "liquibase execution failed", details)
 	}
 	if ctx.Err() != nil {
-		return errorResponse("cancelled", "task cancelled", "")
+		return errorResponse(ErrorCodeCancelled, "task cancelled", "")
 	}
 	return nil
 }
 
-func prependLiquibaseConnectionArgs(args []string, instance engineRuntime.Instance, windowsMode bool) []string {
-	host := instance.Host
-	if strings.TrimSpace(host) == "" {
-		host = "localhost"
+func (e *taskExecutor) executeFlywayStep(ctx context.Context, jobID string, prepared preparedRequest, rt *jobRuntime, task taskState) *ErrorResponse {
+	args := applyFlywayTaskArgs(prepared.normalizedArgs, task)
+	_, errResp := e.runFlyway(ctx, jobID, prepared, rt, args)
+	return errResp
+}
+
+// runFlyway runs flyway against the job instance with the engine-owned
+// connection options and returns its combined output.
+func (e *taskExecutor) runFlyway(ctx context.Context, jobID string, prepared preparedRequest, rt *jobRuntime, args []string) (string, *ErrorResponse) {
+	m := e.m
+	if m.flyway == nil {
+		return "", errorResponse(ErrorCodeInternal, "flyway runner is required", "")
 	}
-	if windowsMode && isWSL() && (host == "127.0.0.1" || strings.EqualFold(host, "localhost")) {
-		if resolved, err := resolveWSLPrimaryIPv4(); err == nil && strings.TrimSpace(resolved) != "" {
-			host = resolved
+	if rt == nil || strings.TrimSpace(rt.instance.Host) == "" || rt.instance.Port == 0 {
+		return "", errorResponse(ErrorCodeInternal, "runtime instance is missing connection info", "")
+	}
+	execMode := normalizeExecMode(prepared.request.FlywayExecMode)
+	rawExecPath := strings.TrimSpace(prepared.request.FlywayExec)
+	windowsMode := shouldUseWindowsBat(rawExecPath, execMode)
+	execPath, err := normalizeLiquibaseExecPath(rawExecPath, windowsMode)
+	if err != nil {
+		return "", errorResponse(ErrorCodeInternal, "cannot resolve flyway executable", err.Error())
+	}
+	workDir := strings.TrimSpace(prepared.request.WorkDir)
+	if workDir == "" {
+		workDir = prepared.flywayWorkDir
+	}
+	if workDir != "" && windowsMode && isWSL() {
+		mappedDir, mapErr := (wslPathMapper{}).MapPath(workDir)
+		if mapErr != nil {
+			return "", errorResponse(ErrorCodeInternal, "cannot map flyway workdir", mapErr.Error())
 		}
+		workDir = mappedDir
 	}
-	port := instance.Port
-	if port == 0 {
-		port = 5432
+	args = prependFlywayConnectionArgs(args, instanceJDBCURL(rt.instance, windowsMode), m.postgresSuperuser())
+	env, secrets, errResp := m.resolveEnvSecrets(ctx, prepared.request.FlywayEnv)
+	if errResp != nil {
+		return "", errResp
+	}
+	env, err = mapLiquibaseEnv(env, windowsMode)
+	if err != nil {
+		return "", errorResponse(ErrorCodeInternal, "cannot map flyway env", err.Error())
+	}
+
+	if execPath == "" {
+		execPath = "flyway"
 	}
+	execLine := formatExecLine(execPath, args)
+	m.appendLog(jobID, fmt.Sprintf("flyway: exec %s", execLine))
+	m.logDebugJob(jobID, "flyway exec %s", execLine)
+	m.appendLog(jobID, "flyway: start")
+	var sinkCalled atomic.Bool
+	flywayCtx := engineRuntime.WithLogSink(ctx, func(line string) {
+		sinkCalled.Store(true)
+		m.appendLog(jobID, "flyway: "+secrets.redact(line))
+	})
+	output, err := m.flyway.Run(flywayCtx, FlywayRunRequest{
+		ExecPath: execPath,
+		ExecMode: execMode,
+		Args:     args,
+		Env:      env,
+		WorkDir:  workDir,
+	})
+	if !sinkCalled.Load() && strings.TrimSpace(output) != "" {
+		m.appendLogLines(jobID, "flyway", secrets.redact(output))
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", errorResponse(ErrorCodeCancelled, "task cancelled", "")
+		}
+		details := strings.TrimSpace(secrets.redact(output))
+		if details == "" {
+			details = secrets.redact(err.Error())
+		}
+		if noSpaceResp := noSpaceErrorResponse("prepare step failed due to insufficient storage", "prepare_step", errors.New(details)); noSpaceResp != nil {
+			return "", noSpaceResp
+		}
+		return "", errorResponse(ErrorCodeMigrationFailed, "flyway execution failed", details)
+	}
+	if ctx.Err() != nil {
+		return "", errorResponse(ErrorCodeCancelled, "task cancelled", "")
+	}
+	return output, nil
+}
+
+func prependLiquibaseConnectionArgs(args []string, instance engineRuntime.Instance, windowsMode bool, user string) []string {
 	conn := []string{
-		fmt.Sprintf("--url=jdbc:postgresql://%s:%d/postgres", host, port),
-		"--username=sqlrs",
+		"--url=" + instanceJDBCURL(instance, windowsMode),
+		"--username=" + engineRuntime.PostgresSuperuser(user),
 	}
 	if len(args) == 0 {
 		return conn
@@ -683,6 +977,26 @@ func prependLiquibaseConnectionArgs(args []string, instance engineRuntime.Instan
 	return out
 }
 
+// instanceJDBCURL points a host-side JVM tool at the instance. Windows tools
+// launched from WSL cannot reach the WSL loopback, so it is replaced with the
+// primary WSL address.
+func instanceJDBCURL(instance engineRuntime.Instance, windowsMode bool) string {
+	host := instance.Host
+	if strings.TrimSpace(host) == "" {
+		host = "localhost"
+	}
+	if windowsMode && isWSL() && (host == "127.0.0.1" || strings.EqualFold(host, "localhost")) {
+		if resolved, err := resolveWSLPrimaryIPv4(); err == nil && strings.TrimSpace(resolved) != "" {
+			host = resolved
+		}
+	}
+	port := instance.Port
+	if port == 0 {
+		port = 5432
+	}
+	return fmt.Sprintf("jdbc:postgresql://%s:%d/postgres", host, port)
+}
+
 func resolveWSLPrimaryIPv4() (string, error) {
 	ifaces, err := listNetInterfaces()
 	if err != nil {
@@ -786,18 +1100,26 @@ func windowsPathDir(path string) string {
 func (e *taskExecutor) createInstance(ctx context.Context, jobID string, prepared preparedRequest, stateID string) (*Result, *ErrorResponse) {
 	m := e.m
 	if ctx.Err() != nil {
-		return nil, errorResponse("cancelled", "job cancelled", "")
+		return nil, errorResponse(ErrorCodeCancelled, "job cancelled", "")
 	}
 	if strings.TrimSpace(stateID) == "" {
-		return nil, errorResponse("internal_error", "state id is required", "")
+		return nil, errorResponse(ErrorCodeInternal, "state id is required", "")
 	}
 
 	runner, ephemeral := m.runnerForJob(jobID)
 	if runner == nil {
-		return nil, errorResponse("internal_error", "job runner missing", "")
+		return nil, errorResponse(ErrorCodeInternal, "job runner missing", "")
 	}
+	// Persistent instances keep their container running once registered,
+	// even when no job runner owns the runtime.
+	persistent := prepared.instanceMode() == instanceModePersistent
+	keepRuntime := false
 	if ephemeral {
-		defer m.cleanupRuntime(context.Background(), runner)
+		defer func() {
+			if !keepRuntime {
+				m.cleanupRuntime(context.Background(), runner)
+			}
+		}()
 	}
 
 	rt := runner.getRuntime()
@@ -810,19 +1132,19 @@ func (e *taskExecutor) createInstance(ctx context.Context, jobID string, prepare
 		runner.setRuntime(rt)
 	}
 	if rt.instance.Host == "" || rt.instance.Port == 0 {
-		return nil, errorResponse("internal_error", "runtime instance is missing connection info", "")
+		return nil, errorResponse(ErrorCodeInternal, "runtime instance is missing connection info", "")
 	}
 
 	instanceID, err := randomHex(16)
 	if err != nil {
-		return nil, errorResponse("internal_error", "cannot generate instance id", err.Error())
+		return nil, errorResponse(ErrorCodeInternal, "cannot generate instance id", err.Error())
 	}
 	createdAt := m.now().UTC().Format(time.RFC3339Nano)
 	status := store.InstanceStatusActive
 	var runtimeID *string
 	imageID := prepared.effectiveImageID()
 	if strings.TrimSpace(imageID) == "" {
-		return nil, errorResponse("internal_error", "resolved image id is required", "")
+		return nil, errorResponse(ErrorCodeInternal, "resolved image id is required", "")
 	}
 	if strings.TrimSpace(rt.instance.ID) != "" {
 		runtimeID = strPtr(rt.instance.ID)
@@ -838,16 +1160,21 @@ func (e *taskExecutor) createInstance(ctx context.Context, jobID string, prepare
 		CreatedAt:  createdAt,
 		RuntimeID:  runtimeID,
 		RuntimeDir: runtimeDir,
+		Mode:       strPtr(prepared.instanceMode()),
 		Status:     &status,
 	}); err != nil {
 		if ctx.Err() != nil {
-			return nil, errorResponse("cancelled", "job cancelled", "")
+			return nil, errorResponse(ErrorCodeCancelled, "job cancelled", "")
 		}
-		return nil, errorResponse("internal_error", "cannot store instance", err.Error())
+		return nil, errorResponse(ErrorCodeInternal, "cannot store instance", err.Error())
 	}
 	m.appendLog(jobID, fmt.Sprintf("instance created %s", instanceID))
+	if persistent {
+		keepRuntime = true
+		m.appendLog(jobID, fmt.Sprintf("instance %s is persistent; container left running", instanceID))
+	}
 	result := Result{
-		DSN:                   buildDSN(rt.instance.Host, rt.instance.Port),
+		DSN:                   buildDSN(rt.instance.Host, rt.instance.Port, m.postgresSuperuser()),
 		InstanceID:            instanceID,
 		StateID:               stateID,
 		ImageID:               imageID,
@@ -859,7 +1186,7 @@ func (e *taskExecutor) createInstance(ctx context.Context, jobID string, prepare
 
 func (e *taskExecutor) ensureRuntime(ctx context.Context, jobID string, prepared preparedRequest, input *TaskInput, runner *jobRunner) (*jobRuntime, *ErrorResponse) {
 	if runner == nil {
-		return nil, errorResponse("internal_error", "job runner missing", "")
+		return nil, errorResponse(ErrorCodeInternal, "job runner missing", "")
 	}
 	if rt := runner.getRuntime(); rt != nil {
 		return rt, nil
@@ -875,15 +1202,15 @@ func (e *taskExecutor) ensureRuntime(ctx context.Context, jobID string, prepared
 func (e *taskExecutor) startRuntime(ctx context.Context, jobID string, prepared preparedRequest, input *TaskInput) (*jobRuntime, *ErrorResponse) {
 	m := e.m
 	if ctx.Err() != nil {
-		return nil, errorResponse("cancelled", "job cancelled", "")
+		return nil, errorResponse(ErrorCodeCancelled, "job cancelled", "")
 	}
 	if input == nil {
-		return nil, errorResponse("internal_error", "task input is required", "")
+		return nil, errorResponse(ErrorCodeInternal, "task input is required", "")
 	}
 
 	imageID := prepared.effectiveImageID()
 	if strings.TrimSpace(imageID) == "" {
-		return nil, errorResponse("internal_error", "resolved image id is required", "")
+		return nil, errorResponse(ErrorCodeInternal, "resolved image id is required", "")
 	}
 	m.logInfoJob(jobID, "runtime start input_kind=%s input_id=%s image=%s", input.Kind, input.ID, imageID)
 	m.logInfoJob(jobID, "runtime start state_store_root=%s", m.stateStoreRoot)
@@ -892,68 +1219,78 @@ func (e *taskExecutor) startRuntime(ctx context.Context, jobID string, prepared
 	})
 	var srcDir string
 	var stateDir string
+	var warmSpec *warmPoolSpec
 	switch input.Kind {
 	case "image":
 		m.appendLog(jobID, fmt.Sprintf("docker: init base %s", imageID))
-		paths, err := resolveStatePaths(m.stateStoreRoot, imageID, "", m.statefs)
+		paths, err := resolveStatePaths(m.namespaceRoot(prepared.request.Namespace), imageID, "", m.statefs)
 		if err != nil {
-			return nil, errorResponse("internal_error", "cannot resolve state paths", err.Error())
+			return nil, errorResponse(ErrorCodeStoreNotReady, "cannot resolve state paths", err.Error())
 		}
 		if err := e.snapshot.ensureBaseState(ctx, imageID, paths.baseDir); err != nil {
 			if ctx.Err() != nil {
-				return nil, errorResponse("cancelled", "job cancelled", "")
+				return nil, errorResponse(ErrorCodeCancelled, "job cancelled", "")
+			}
+			return nil, errorResponse(runtimeErrorCode(err, ErrorCodeBaseInitFailed), "cannot initialize base state", err.Error())
+		}
+		if spec, ok := m.warmPoolSpecFor(prepared, imageID, paths.baseDir); ok {
+			if rt := m.takeWarmRuntime(ctx, jobID, spec.key); rt != nil {
+				m.refillWarmPool(spec)
+				return rt, nil
 			}
-			return nil, errorResponse("internal_error", "cannot initialize base state", err.Error())
+			// The pool is filled after this cold start, so it never
+			// competes with the job for the runtime.
+			warmSpec = &spec
 		}
 		srcDir = paths.baseDir
 	case "state":
 		if strings.TrimSpace(input.ID) == "" {
-			return nil, errorResponse("internal_error", "input state id is required", "")
+			return nil, errorResponse(ErrorCodeInternal, "input state id is required", "")
 		}
 		entry, ok, err := m.store.GetState(ctx, input.ID)
 		if err != nil {
-			return nil, errorResponse("internal_error", "cannot load input state", err.Error())
+			return nil, errorResponse(ErrorCodeStoreNotReady, "cannot load input state", err.Error())
 		}
 		if !ok {
-			return nil, errorResponse("internal_error", "input state not found", input.ID)
+			return nil, errorResponse(ErrorCodeInternal, "input state not found", input.ID)
 		}
 		if strings.TrimSpace(entry.ImageID) != "" {
 			imageID = entry.ImageID
 		}
-		paths, err := resolveStatePaths(m.stateStoreRoot, imageID, input.ID, m.statefs)
+		paths, err := resolveStatePaths(m.namespaceRoot(prepared.request.Namespace), imageID, input.ID, m.statefs)
 		if err != nil {
-			return nil, errorResponse("internal_error", "cannot resolve state paths", err.Error())
+			return nil, errorResponse(ErrorCodeStoreNotReady, "cannot resolve state paths", err.Error())
 		}
 		stateDir = paths.stateDir
 		if dirtyPath := postmasterPIDPath(paths.stateDir); dirtyPath != "" {
 			m.logInfoJob(jobID, "postmaster.pid present in state dir path=%s", dirtyPath)
-			return nil, errorResponse("internal_error", "state snapshot is dirty (postmaster.pid present)", dirtyPath)
+			return nil, errorResponse(ErrorCodeStateCorrupt, "state snapshot is dirty (postmaster.pid present)", dirtyPath)
 		}
 		ok, err = hasPGVersion(paths.stateDir)
 		if err != nil {
-			return nil, errorResponse("internal_error", "cannot inspect state PG_VERSION", err.Error())
+			return nil, errorResponse(ErrorCodeInternal, "cannot inspect state PG_VERSION", err.Error())
 		}
 		if !ok {
-			return nil, errorResponse("internal_error", "state snapshot missing PG_VERSION", paths.stateDir)
+			return nil, errorResponse(ErrorCodeStateCorrupt, "state snapshot missing PG_VERSION", paths.stateDir)
 		}
 		m.logInfoJob(jobID, "postmaster.pid not found in state dir=%s", paths.stateDir)
 		srcDir = paths.stateDir
 	default:
-		return nil, errorResponse("internal_error", "unsupported task input", input.Kind)
+		return nil, errorResponse(ErrorCodeInternal, "unsupported task input", input.Kind)
 	}
 
-	runtimeDir := filepath.Join(m.stateStoreRoot, "jobs", jobID, "runtime")
+	runtimeDir := filepath.Join(m.runtimeRoot(prepared.request.Namespace), "jobs", jobID, "runtime")
 	m.logInfoJob(jobID, "runtime start runtime_dir=%s", runtimeDir)
 	if stateDir != "" {
 		if rel, err := filepath.Rel(stateDir, runtimeDir); err == nil && rel != "." && !strings.HasPrefix(rel, "..") {
-			return nil, errorResponse("internal_error", "runtime dir is nested inside state dir", fmt.Sprintf("runtime=%s state=%s", runtimeDir, stateDir))
+			return nil, errorResponse(ErrorCodeInternal, "runtime dir is nested inside state dir", fmt.Sprintf("runtime=%s state=%s", runtimeDir, stateDir))
 		}
 	}
 	if err := removeAllFn(runtimeDir); err != nil && !errors.Is(err, os.ErrNotExist) {
 		staleRuntimeDir := fmt.Sprintf("%s.stale-%d", runtimeDir, time.Now().UnixNano())
 		if renameErr := os.Rename(runtimeDir, staleRuntimeDir); renameErr != nil {
 			if !errors.Is(renameErr, os.ErrNotExist) && !errors.Is(renameErr, syscall.ENOTDIR) {
-				return nil, errorResponse("internal_error", "cannot reset runtime dir", fmt.Sprintf("remove=%v rename=%v", err, renameErr))
+				return nil, errorResponse(ErrorCodeStoreNotReady, "cannot reset runtime dir", fmt.Sprintf("remove=%v rename=%v", err, renameErr))
 			}
 		} else {
 			m.logInfoJob(jobID, "runtime dir reset via rename old=%s new=%s remove_err=%v", runtimeDir, staleRuntimeDir, err)
@@ -963,26 +1300,30 @@ func (e *taskExecutor) startRuntime(ctx context.Context, jobID string, prepared
 		if noSpaceResp := noSpaceErrorResponse("insufficient storage before state execution", "prepare_step", err); noSpaceResp != nil {
 			return nil, noSpaceResp
 		}
-		return nil, errorResponse("internal_error", "cannot create runtime dir", err.Error())
+		return nil, errorResponse(ErrorCodeStoreNotReady, "cannot create runtime dir", err.Error())
 	}
 	clone, err := m.statefs.Clone(ctx, srcDir, runtimeDir)
 	if err != nil {
 		if noSpaceResp := noSpaceErrorResponse("insufficient storage before state execution", "prepare_step", err); noSpaceResp != nil {
 			return nil, noSpaceResp
 		}
-		return nil, errorResponse("internal_error", "cannot clone state", err.Error())
+		return nil, errorResponse(ErrorCodeStoreNotReady, "cannot clone state", err.Error())
 	}
 	if dirtyPath := postmasterPIDPath(clone.MountDir); dirtyPath != "" {
 		m.logInfoJob(jobID, "postmaster.pid present in runtime dir path=%s", dirtyPath)
-		return nil, errorResponse("internal_error", "runtime data dir is dirty (postmaster.pid present)", dirtyPath)
+		return nil, errorResponse(ErrorCodeStateCorrupt, "runtime data dir is dirty (postmaster.pid present)", dirtyPath)
 	}
 	if input.Kind == "state" {
+		if errResp := m.verifyCloneChecksums(ctx, jobID, input.ID, stateDir, clone.MountDir); errResp != nil {
+			_ = clone.Cleanup()
+			return nil, errResp
+		}
 		ok, err := hasPGVersion(clone.MountDir)
 		if err != nil {
-			return nil, errorResponse("internal_error", "cannot inspect runtime PG_VERSION", err.Error())
+			return nil, errorResponse(ErrorCodeInternal, "cannot inspect runtime PG_VERSION", err.Error())
 		}
 		if !ok {
-			return nil, errorResponse("internal_error", "runtime data dir missing PG_VERSION", clone.MountDir)
+			return nil, errorResponse(ErrorCodeStateCorrupt, "runtime data dir missing PG_VERSION", clone.MountDir)
 		}
 	}
 	m.logInfoJob(jobID, "postmaster.pid not found in runtime dir=%s", clone.MountDir)
@@ -990,7 +1331,12 @@ func (e *taskExecutor) startRuntime(ctx context.Context, jobID string, prepared
 	rtScriptMount, err := scriptMountForFiles(prepared.filePaths)
 	if err != nil {
 		_ = clone.Cleanup()
-		return nil, errorResponse("internal_error", "cannot prepare scripts", err.Error())
+		return nil, errorResponse(ErrorCodeInternal, "cannot prepare scripts", err.Error())
+	}
+	pgpassMounts, pgpassSecrets, errResp := m.writePgpass(ctx, jobID, prepared, runtimeDir)
+	if errResp != nil {
+		_ = clone.Cleanup()
+		return nil, errResp
 	}
 
 	m.appendLog(jobID, "docker: start container")
@@ -1002,32 +1348,66 @@ func (e *taskExecutor) startRuntime(ctx context.Context, jobID string, prepared
 	if suffix, err := randomHex(4); err == nil {
 		containerName = containerName + "-" + suffix
 	}
-	instance, err := m.runtime.Start(ctx, engineRuntime.StartRequest{
+	cpus, memory := m.containerLimits(prepared.request)
+	startReq := engineRuntime.StartRequest{
 		ImageID:     imageID,
 		DataDir:     clone.MountDir,
 		Name:        containerName,
-		Mounts:      runtimeMountsFrom(rtScriptMount),
+		Mounts:      append(append(runtimeMountsFrom(rtScriptMount), prepared.psqlMounts...), pgpassMounts...),
 		AllowInitdb: allowInitdb,
+		Labels:      runtimeLabels(jobID, input),
+		CPUs:        cpus,
+		Memory:      memory,
+		Network:     prepared.request.Network,
+		DNS:         prepared.request.DNS,
+		Platform:    prepared.request.Platform,
+		User:        m.containerUser(),
+	}
+	// Start includes the readiness wait, so retries also cover WaitForReady.
+	var instance engineRuntime.Instance
+	err = m.withRuntimeRetry(ctx, jobID, "container start", func() error {
+		var startErr error
+		instance, startErr = m.runtime.Start(ctx, startReq)
+		return startErr
 	})
 	if err != nil {
 		_ = clone.Cleanup()
-		m.logInfoJob(jobID, "runtime start failed image=%s input=%s err=%v", imageID, input.Kind, err)
+		m.logWarnJob(jobID, "runtime start failed image=%s input=%s err=%v", imageID, input.Kind, err)
 		if ctx.Err() != nil {
-			return nil, errorResponse("cancelled", "job cancelled", "")
+			return nil, errorResponse(ErrorCodeCancelled, "job cancelled", "")
 		}
-		return nil, errorResponse("internal_error", "cannot start runtime", err.Error())
+		return nil, errorResponse(runtimeErrorCode(err, ErrorCodeContainerStartFailed), "cannot start runtime", err.Error())
 	}
 	m.appendLog(jobID, fmt.Sprintf("docker: container started %s", instance.ID))
-	m.logJob(jobID, "runtime started container=%s host=%s port=%d snapshot=%s", instance.ID, instance.Host, instance.Port, m.statefs.Kind())
+	m.logInfoJob(jobID, "runtime started container=%s host=%s port=%d snapshot=%s", instance.ID, instance.Host, instance.Port, m.statefs.Kind())
 	m.appendLog(jobID, "docker: postgres ready")
+	if warmSpec != nil {
+		m.refillWarmPool(*warmSpec)
+	}
 
-	return &jobRuntime{
+	rt := &jobRuntime{
 		instance:    instance,
 		dataDir:     clone.MountDir,
 		runtimeDir:  runtimeDir,
 		cleanup:     clone.Cleanup,
 		scriptMount: rtScriptMount,
-	}, nil
+		secrets:     pgpassSecrets,
+	}
+	if len(pgpassMounts) > 0 {
+		rt.pgpassFile = pgpassContainerPath
+	}
+	return rt, nil
+}
+
+// runtimeLabels names the job and input state a prepare container belongs to.
+// The instance id does not exist yet when the container starts; instances
+// reference the container through their runtime id instead.
+func runtimeLabels(jobID string, input *TaskInput) map[string]string {
+	labels := map[string]string{engineRuntime.LabelJob: jobID}
+	if input != nil && input.Kind == "state" && strings.TrimSpace(input.ID) != "" {
+		labels[engineRuntime.LabelState] = input.ID
+	}
+	return labels
 }
 
 var removeAllFn = os.RemoveAll
@@ -1037,20 +1417,30 @@ func (s *snapshotOrchestrator) ensureBaseState(ctx context.Context, imageID stri
 	if strings.TrimSpace(baseDir) == "" {
 		return fmt.Errorf("base dir is required")
 	}
-	if initMarkerExists(baseDir) {
+	superuser := m.postgresSuperuser()
+	if initMarkerMatches(baseDir, superuser) {
 		return nil
 	}
 	if err := ensureBaseDir(ctx, m.statefs, baseDir); err != nil {
 		return err
 	}
+	release, err := m.baseInit.acquire(ctx, imageID)
+	if err != nil {
+		return err
+	}
+	defer release()
 	if err := withInitLock(ctx, baseDir, func() error {
-		if initMarkerExists(baseDir) {
+		if initMarkerMatches(baseDir, superuser) {
 			return nil
 		}
-		if ok, err := hasPGVersion(baseDir); ok {
-			return writeInitMarker(baseDir)
-		} else if err != nil {
-			return writeInitMarker(baseDir)
+		// A base bootstrapped for another superuser is initialized again;
+		// an unmarked data dir is adopted as before.
+		if !initMarkerExists(baseDir) {
+			if ok, err := hasPGVersion(baseDir); ok {
+				return writeInitMarker(baseDir, superuser)
+			} else if err != nil {
+				return writeInitMarker(baseDir, superuser)
+			}
 		}
 		if err := resetBaseDirContents(baseDir); err != nil {
 			return err
@@ -1058,7 +1448,7 @@ func (s *snapshotOrchestrator) ensureBaseState(ctx context.Context, imageID stri
 		if err := m.runtime.InitBase(ctx, imageID, baseDir); err != nil {
 			return err
 		}
-		return writeInitMarker(baseDir)
+		return writeInitMarker(baseDir, superuser)
 	}); err != nil {
 		return err
 	}
@@ -1075,9 +1465,30 @@ func initMarkerExists(baseDir string) bool {
 	return err == nil
 }
 
-func writeInitMarker(baseDir string) error {
+// A base bootstrapped with the default superuser keeps the plain "ok"
+// marker; other superusers are recorded as "superuser=<name>".
+const baseInitMarkerSuperuserPrefix = "superuser="
+
+func writeInitMarker(baseDir string, superuser string) error {
+	content := "ok"
+	if superuser = engineRuntime.PostgresSuperuser(superuser); superuser != engineRuntime.DefaultPostgresSuperuser {
+		content = baseInitMarkerSuperuserPrefix + superuser
+	}
 	path := filepath.Join(baseDir, baseInitMarkerName)
-	return os.WriteFile(path, []byte("ok"), 0o600)
+	return os.WriteFile(path, []byte(content), 0o600)
+}
+
+// initMarkerMatches reports whether baseDir is initialized for superuser.
+func initMarkerMatches(baseDir string, superuser string) bool {
+	data, err := os.ReadFile(filepath.Join(baseDir, baseInitMarkerName))
+	if err != nil {
+		return false
+	}
+	recorded := engineRuntime.DefaultPostgresSuperuser
+	if name, ok := strings.CutPrefix(strings.TrimSpace(string(data)), baseInitMarkerSuperuserPrefix); ok {
+		recorded = name
+	}
+	return recorded == engineRuntime.PostgresSuperuser(superuser)
 }
 
 func hasPGVersion(baseDir string) (bool, error) {
@@ -1142,40 +1553,40 @@ func (s *snapshotOrchestrator) invalidateDirtyCachedState(ctx context.Context, j
 	}
 	entry, ok, err := m.store.GetState(ctx, stateID)
 	if err != nil {
-		return false, errorResponse("internal_error", "cannot load cached state", err.Error())
+		return false, errorResponse(ErrorCodeInternal, "cannot load cached state", err.Error())
 	}
 	imageID := prepared.effectiveImageID()
 	if ok && strings.TrimSpace(entry.ImageID) != "" {
 		imageID = entry.ImageID
 	}
 	if strings.TrimSpace(imageID) == "" {
-		return false, errorResponse("internal_error", "resolved image id is required", "")
+		return false, errorResponse(ErrorCodeInternal, "resolved image id is required", "")
 	}
-	paths, err := resolveStatePaths(m.stateStoreRoot, imageID, stateID, m.statefs)
+	paths, err := resolveStatePaths(m.namespaceRoot(prepared.request.Namespace), imageID, stateID, m.statefs)
 	if err != nil {
-		return false, errorResponse("internal_error", "cannot resolve cached state paths", err.Error())
+		return false, errorResponse(ErrorCodeInternal, "cannot resolve cached state paths", err.Error())
 	}
 	if dirtyPath := postmasterPIDPath(paths.stateDir); dirtyPath != "" {
 		m.logInfoJob(jobID, "cached state dirty state=%s path=%s", stateID, dirtyPath)
 		if err := m.statefs.RemovePath(context.Background(), paths.stateDir); err != nil {
-			return false, errorResponse("internal_error", "cannot remove dirty cached state dir", err.Error())
+			return false, errorResponse(ErrorCodeInternal, "cannot remove dirty cached state dir", err.Error())
 		}
 		if err := m.store.DeleteState(ctx, stateID); err != nil {
-			return false, errorResponse("internal_error", "cannot delete dirty cached state", err.Error())
+			return false, errorResponse(ErrorCodeInternal, "cannot delete dirty cached state", err.Error())
 		}
 		return true, nil
 	}
 	ok, err = hasPGVersion(paths.stateDir)
 	if err != nil {
-		return false, errorResponse("internal_error", "cannot inspect cached state PG_VERSION", err.Error())
+		return false, errorResponse(ErrorCodeInternal, "cannot inspect cached state PG_VERSION", err.Error())
 	}
 	if !ok {
 		m.logInfoJob(jobID, "cached state missing PG_VERSION state=%s dir=%s", stateID, paths.stateDir)
 		if err := m.statefs.RemovePath(context.Background(), paths.stateDir); err != nil {
-			return false, errorResponse("internal_error", "cannot remove cached state dir missing PG_VERSION", err.Error())
+			return false, errorResponse(ErrorCodeInternal, "cannot remove cached state dir missing PG_VERSION", err.Error())
 		}
 		if err := m.store.DeleteState(ctx, stateID); err != nil {
-			return false, errorResponse("internal_error", "cannot delete cached state missing PG_VERSION", err.Error())
+			return false, errorResponse(ErrorCodeInternal, "cannot delete cached state missing PG_VERSION", err.Error())
 		}
 		return true, nil
 	}
@@ -1395,11 +1806,10 @@ func (m *PrepareService) cleanupRuntime(ctx context.Context, runner *jobRunner)
 	if runner == nil {
 		return
 	}
-	rt := runner.getRuntime()
+	rt := runner.takeRuntime()
 	if rt == nil {
 		return
 	}
-	runner.setRuntime(nil)
 	stopCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
 	defer cancel()
 	if err := m.runtime.Stop(stopCtx, rt.instance.ID); err != nil {
@@ -3,9 +3,11 @@ package prepare
 import (
 	"context"
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"syscall"
 	"testing"
 
 	"github.com/sqlrs/engine-local/internal/store"
@@ -42,10 +44,24 @@ func TestExecuteStateTaskReturnsCapacityErrorOnPreflight(t *testing.T) {
 	}
 }
 
-func TestExecuteStateTaskMapsSnapshotNoSpaceToCapacityError(t *testing.T) {
-	store := &fakeStore{}
-	snap := &fakeStateFS{snapshotErr: errors.New("no space left on device")}
-	mgr := newManagerWithStateFS(t, store, snap)
+func TestExecuteStateTaskReportsSnapshotNoSpaceAfterRetry(t *testing.T) {
+	snap := &fakeStateFS{snapshotErr: fmt.Errorf("copy data dir: %w", &os.PathError{Op: "write", Path: "base/1", Err: syscall.ENOSPC})}
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{
+		statefs: snap,
+		config: &fakeConfigStore{values: map[string]any{
+			"cache.capacity.maxBytes":     int64(900),
+			"cache.capacity.reserveBytes": int64(0),
+		}},
+	})
+	overrideCapacitySignals(t,
+		func(string) (int64, int64, error) { return 1000, 40, nil },
+		func(path string) (int64, error) {
+			if strings.Contains(path, "runtime") {
+				return 30, nil
+			}
+			return 700, nil
+		},
+	)
 	prepared, err := mgr.prepareRequest(Request{
 		PrepareKind: "psql",
 		ImageID:     "image-1",
@@ -64,11 +80,111 @@ func TestExecuteStateTaskMapsSnapshotNoSpaceToCapacityError(t *testing.T) {
 	}
 
 	_, errResp := mgr.executeStateTask(context.Background(), "job-1", prepared, task)
-	if errResp == nil || errResp.Code != "cache_limit_too_small" {
-		t.Fatalf("expected cache_limit_too_small, got %+v", errResp)
+	if errResp == nil || errResp.Code != "resource_exhausted" {
+		t.Fatalf("expected resource_exhausted, got %+v", errResp)
 	}
-	if !strings.Contains(errResp.Details, `"phase":"snapshot"`) {
-		t.Fatalf("expected snapshot phase in details, got %+v", errResp)
+	if len(snap.snapshotCalls) != 2 {
+		t.Fatalf("expected one retry, got %d snapshot calls", len(snap.snapshotCalls))
+	}
+	if !strings.Contains(errResp.Message, "40 bytes free") || !strings.Contains(errResp.Message, "cache uses 700 bytes") {
+		t.Fatalf("expected free space and usage in the message, got %q", errResp.Message)
+	}
+	details := decodeCapacityDetails(t, errResp)
+	for _, key := range []string{"phase", "free_bytes", "usage_bytes", "max_bytes", "estimated_snapshot_bytes", "hint"} {
+		if _, ok := details[key]; !ok {
+			t.Fatalf("expected %s in details, got %+v", key, details)
+		}
+	}
+	if details["phase"] != "snapshot" || details["estimated_snapshot_bytes"] != float64(30) || details["max_bytes"] != float64(900) {
+		t.Fatalf("unexpected details: %+v", details)
+	}
+}
+
+func TestExecuteStateTaskEvictsAndRetriesSnapshotOnNoSpace(t *testing.T) {
+	st := &fakeStore{
+		listStates: []store.StateEntry{{
+			StateID:   "old-state",
+			ImageID:   "image-1",
+			CreatedAt: "2026-02-22T10:00:00Z",
+			SizeBytes: int64Ptr(500),
+		}},
+	}
+	snap := &fakeStateFS{snapshotErr: errors.New("write failed: no space left on device"), snapshotFailures: 1}
+	mgr := newManagerWithDeps(t, st, newQueueStore(t), &testDeps{
+		statefs: snap,
+		config: &fakeConfigStore{values: map[string]any{
+			"cache.capacity.reserveBytes": int64(0),
+			"cache.capacity.minStateAge":  "0s",
+		}},
+	})
+	overrideCapacitySignals(t,
+		func(string) (int64, int64, error) {
+			// The disk fills up during the first snapshot and recovers once
+			// the old state is evicted.
+			if len(snap.snapshotCalls) == 0 || len(st.deletedStates) > 0 {
+				return 1 << 30, 1 << 29, nil
+			}
+			return 1 << 30, 10, nil
+		},
+		func(path string) (int64, error) {
+			if strings.Contains(path, "runtime") {
+				return 100, nil
+			}
+			return 0, nil
+		},
+	)
+	prepared, err := mgr.prepareRequest(Request{
+		PrepareKind: "psql",
+		ImageID:     "image-1",
+		PsqlArgs:    []string{"-c", "select 1"},
+	})
+	if err != nil {
+		t.Fatalf("prepareRequest: %v", err)
+	}
+	outputID := psqlOutputStateID(t, mgr, prepared, TaskInput{Kind: "image", ID: "image-1"})
+	task := taskState{
+		PlanTask: PlanTask{
+			TaskID:        "execute-0",
+			OutputStateID: outputID,
+			Input:         &TaskInput{Kind: "image", ID: "image-1"},
+		},
+	}
+
+	got, errResp := mgr.executeStateTask(context.Background(), "job-1", prepared, task)
+	if errResp != nil || got != outputID {
+		t.Fatalf("expected the retried snapshot to succeed, got %q %+v", got, errResp)
+	}
+	if len(snap.snapshotCalls) != 2 {
+		t.Fatalf("expected one retry, got %d snapshot calls", len(snap.snapshotCalls))
+	}
+	if !containsString(st.deletedStates, "old-state") {
+		t.Fatalf("expected the old state to be evicted, deleted=%v", st.deletedStates)
+	}
+}
+
+func TestExecuteStateTaskDoesNotRetryOtherSnapshotErrors(t *testing.T) {
+	snap := &fakeStateFS{snapshotErr: errors.New("boom")}
+	mgr := newManagerWithStateFS(t, &fakeStore{}, snap)
+	prepared, err := mgr.prepareRequest(Request{
+		PrepareKind: "psql",
+		ImageID:     "image-1",
+		PsqlArgs:    []string{"-c", "select 1"},
+	})
+	if err != nil {
+		t.Fatalf("prepareRequest: %v", err)
+	}
+	outputID := psqlOutputStateID(t, mgr, prepared, TaskInput{Kind: "image", ID: "image-1"})
+	task := taskState{
+		PlanTask: PlanTask{
+			TaskID:        "execute-0",
+			OutputStateID: outputID,
+			Input:         &TaskInput{Kind: "image", ID: "image-1"},
+		},
+	}
+
+	_, errResp := mgr.executeStateTask(context.Background(), "job-1", prepared, task)
+	if errResp == nil || errResp.Code != ErrorCodeSnapshotFailed || len(snap.snapshotCalls) != 1 {
+		t.Fatalf("expected snapshot_failed without retry, got %+v calls=%d", errResp, len(snap.snapshotCalls))
 	}
 }
 
@@ -385,7 +501,7 @@ func TestExecuteStateTaskPathAndCapacityBranches(t *testing.T) {
 		}
 		prepared := preparedRequest{request: Request{PrepareKind: "custom", ImageID: "image-1"}}
 		_, errResp := mgr.executeStateTask(context.Background(), "job-1", prepared, task)
-		if errResp == nil || errResp.Code != "internal_error" || !strings.Contains(errResp.Message, "cannot resolve state paths") {
+		if errResp == nil || errResp.Code != "store_not_ready" || !strings.Contains(errResp.Message, "cannot resolve state paths") {
 			t.Fatalf("expected resolve state paths error, got %+v", errResp)
 		}
 	})
@@ -410,7 +526,7 @@ func TestExecuteStateTaskPathAndCapacityBranches(t *testing.T) {
 		}
 		prepared := preparedRequest{request: Request{PrepareKind: "custom", ImageID: "image-1"}}
 		_, errResp := mgr.executeStateTask(context.Background(), "job-1", prepared, task)
-		if errResp == nil || errResp.Code != "internal_error" || !strings.Contains(errResp.Message, "cannot create state dir") {
+		if errResp == nil || errResp.Code != "store_not_ready" || !strings.Contains(errResp.Message, "cannot create state dir") {
 			t.Fatalf("expected create state dir error, got %+v", errResp)
 		}
 	})
@@ -462,7 +578,7 @@ func TestExecuteStateTaskLockBranches(t *testing.T) {
 		}
 		prepared := preparedRequest{request: Request{PrepareKind: "custom", ImageID: "image-1"}}
 		_, errResp := mgr.executeStateTask(context.Background(), "job-1", prepared, task)
-		if errResp == nil || errResp.Code != "internal_error" || !strings.Contains(errResp.Message, "cannot check state cache") {
+		if errResp == nil || errResp.Code != "store_not_ready" || !strings.Contains(errResp.Message, "cannot check state cache") {
 			t.Fatalf("expected state cache lookup error, got %+v", errResp)
 		}
 	})
@@ -484,7 +600,7 @@ func TestExecuteStateTaskLockBranches(t *testing.T) {
 		}
 		prepared := preparedRequest{request: Request{PrepareKind: "custom", ImageID: "image-1"}}
 		_, errResp := mgr.executeStateTask(context.Background(), "job-1", prepared, task)
-		if errResp == nil || errResp.Code != "internal_error" || !strings.Contains(errResp.Message, "cannot reset state dir") {
+		if errResp == nil || errResp.Code != "store_not_ready" || !strings.Contains(errResp.Message, "cannot reset state dir") {
 			t.Fatalf("expected reset state dir error, got %+v", errResp)
 		}
 	})
@@ -623,3 +739,49 @@ func (s *nthGetStateErrStore) GetState(ctx context.Context, stateID string) (sto
 	}
 	return s.fakeStore.GetState(ctx, stateID)
 }
+
+func TestExecuteStateTaskFailsWhenSnapshotWouldEatReserve(t *testing.T) {
+	snap := &fakeStateFS{}
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{
+		statefs: snap,
+		config: &fakeConfigStore{values: map[string]any{
+			"cache.capacity.reserveBytes": int64(10),
+		}},
+	})
+	overrideCapacitySignals(t,
+		func(string) (int64, int64, error) { return 1 << 40, 500, nil },
+		func(path string) (int64, error) {
+			if strings.Contains(path, "runtime") {
+				return 495, nil
+			}
+			return 0, nil
+		},
+	)
+	prepared, err := mgr.prepareRequest(Request{
+		PrepareKind: "psql",
+		ImageID:     "image-1",
+		PsqlArgs:    []string{"-c", "select 1"},
+	})
+	if err != nil {
+		t.Fatalf("prepareRequest: %v", err)
+	}
+	outputID := psqlOutputStateID(t, mgr, prepared, TaskInput{Kind: "image", ID: "image-1"})
+	task := taskState{
+		PlanTask: PlanTask{
+			TaskID:        "execute-0",
+			OutputStateID: outputID,
+			Input:         &TaskInput{Kind: "image", ID: "image-1"},
+		},
+	}
+
+	_, errResp := mgr.executeStateTask(context.Background(), "job-1", prepared, task)
+	if errResp == nil || errResp.Code != "resource_exhausted" {
+		t.Fatalf("expected resource_exhausted, got %+v", errResp)
+	}
+	if !strings.Contains(errResp.Details, `"shortfall_bytes":5`) {
+		t.Fatalf("expected shortfall in details, got %+v", errResp)
+	}
+	if len(snap.snapshotCalls) != 0 {
+		t.Fatalf("expected the snapshot to be skipped, got %+v", snap.snapshotCalls)
+	}
+}
@@ -579,7 +579,7 @@ func TestExecuteStateTaskLiquibaseCachedStateSkipsIntermediateRuntimeStart(t *te
 		t.Fatalf("expected changesets")
 	}
 	taskHash := liquibaseFingerprint("image-1", []LiquibaseChangeset{changesets[0]})
-	outputID, errResp := mgr.computeOutputStateID("image", "image-1", taskHash)
+	outputID, errResp := mgr.computeOutputStateID("", "image", "image-1", taskHash)
 	if errResp != nil {
 		t.Fatalf("computeOutputStateID: %+v", errResp)
 	}
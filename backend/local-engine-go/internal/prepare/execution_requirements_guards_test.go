@@ -175,7 +175,7 @@ func TestStartRuntimeRejectsInputStateLoadError(t *testing.T) {
 	}
 
 	_, errResp := mgr.startRuntime(context.Background(), "job-1", prepared, &TaskInput{Kind: "state", ID: "state-1"})
-	if errResp == nil || errResp.Code != "internal_error" || !strings.Contains(errResp.Message, "cannot load input state") {
+	if errResp == nil || errResp.Code != "store_not_ready" || !strings.Contains(errResp.Message, "cannot load input state") {
 		t.Fatalf("expected input state load error, got %+v", errResp)
 	}
 }
@@ -224,7 +224,7 @@ func TestStartRuntimeRejectsStateSnapshotWithoutPGVersion(t *testing.T) {
 	}
 
 	_, errResp := mgr.startRuntime(context.Background(), "job-1", prepared, &TaskInput{Kind: "state", ID: "state-1"})
-	if errResp == nil || errResp.Code != "internal_error" || !strings.Contains(errResp.Message, "state snapshot missing PG_VERSION") {
+	if errResp == nil || errResp.Code != "state_corrupt" || !strings.Contains(errResp.Message, "state snapshot missing PG_VERSION") {
 		t.Fatalf("expected missing PG_VERSION error, got %+v", errResp)
 	}
 }
@@ -259,7 +259,7 @@ func TestStartRuntimeRejectsCloneError(t *testing.T) {
 	}
 
 	_, errResp := mgr.startRuntime(context.Background(), "job-1", prepared, &TaskInput{Kind: "state", ID: "state-1"})
-	if errResp == nil || errResp.Code != "internal_error" || !strings.Contains(errResp.Message, "cannot clone state") {
+	if errResp == nil || errResp.Code != "store_not_ready" || !strings.Contains(errResp.Message, "cannot clone state") {
 		t.Fatalf("expected clone error, got %+v", errResp)
 	}
 }
@@ -280,7 +280,7 @@ func TestStartRuntimeRejectsRuntimeStartError(t *testing.T) {
 	}
 
 	_, errResp := mgr.startRuntime(context.Background(), "job-1", prepared, &TaskInput{Kind: "image", ID: "image-1"})
-	if errResp == nil || errResp.Code != "internal_error" || !strings.Contains(errResp.Message, "cannot start runtime") {
+	if errResp == nil || errResp.Code != "container_start_failed" || !strings.Contains(errResp.Message, "cannot start runtime") {
 		t.Fatalf("expected runtime start error, got %+v", errResp)
 	}
 }
@@ -1,10 +1,14 @@
 package prepare
 
 import (
+	"context"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/sqlrs/engine-local/internal/prepare/queue"
 	"github.com/sqlrs/engine-local/internal/statefs"
 )
 
@@ -80,3 +84,49 @@ func TestMergeEnvCaseInsensitiveOnWindows(t *testing.T) {
 		t.Fatalf("expected PATH override, got %+v", found)
 	}
 }
+
+func TestExecuteStateTaskLogsPhaseDurations(t *testing.T) {
+	mgr := newManagerWithStateFS(t, &fakeStore{}, &fakeStateFS{})
+	prepared, err := mgr.prepareRequest(Request{
+		PrepareKind: "psql",
+		ImageID:     "image-1",
+		PsqlArgs:    []string{"-c", "select 1"},
+	})
+	if err != nil {
+		t.Fatalf("prepareRequest: %v", err)
+	}
+	if err := mgr.queue.CreateJob(context.Background(), queue.JobRecord{
+		JobID:       "job-1",
+		Status:      StatusRunning,
+		PrepareKind: "psql",
+		ImageID:     "image-1",
+		CreatedAt:   time.Now().UTC().Format(time.RFC3339Nano),
+	}); err != nil {
+		t.Fatalf("create job: %v", err)
+	}
+	task := taskState{
+		PlanTask: PlanTask{
+			TaskID:        "execute-0",
+			Type:          "state_execute",
+			OutputStateID: psqlOutputStateID(t, mgr, prepared, TaskInput{Kind: "image", ID: "image-1"}),
+			Input:         &TaskInput{Kind: "image", ID: "image-1"},
+		},
+	}
+	if _, errResp := mgr.executeStateTask(context.Background(), "job-1", prepared, task); errResp != nil {
+		t.Fatalf("executeStateTask: %+v", errResp)
+	}
+	events, err := mgr.queue.ListEventsSince(context.Background(), "job-1", 0)
+	if err != nil {
+		t.Fatalf("ListEventsSince: %v", err)
+	}
+	var phases []string
+	for _, event := range events {
+		if message := valueOrEmpty(event.Message); strings.HasPrefix(message, "phase=") {
+			phases = append(phases, strings.Fields(message)[0])
+		}
+	}
+	want := []string{"phase=execute", "phase=prepare_snapshot", "phase=snapshot", "phase=resume_snapshot"}
+	if strings.Join(phases, ",") != strings.Join(want, ",") {
+		t.Fatalf("unexpected phase log lines: %v", phases)
+	}
+}
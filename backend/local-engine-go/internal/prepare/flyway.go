@@ -0,0 +1,234 @@
+package prepare
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	engineRuntime "github.com/sqlrs/engine-local/internal/runtime"
+)
+
+// FlywayMigration is a pending versioned migration reported by `flyway info`.
+type FlywayMigration struct {
+	Version     string
+	Description string
+	Script      string
+	Checksum    string
+}
+
+type flywayPrepared struct {
+	normalizedArgs []string
+	argsNormalized string
+	workDir        string
+}
+
+type hostFlywayRunner struct{}
+
+func (r hostFlywayRunner) Run(ctx context.Context, req FlywayRunRequest) (string, error) {
+	execPath := strings.TrimSpace(req.ExecPath)
+	if execPath == "" {
+		execPath = "flyway"
+	}
+	return runHostTool(ctx, execPath, req.ExecMode, req.Args, req.Env, req.WorkDir)
+}
+
+// prepareFlywayArgs validates flyway CLI arguments. Flyway options always use
+// the -key=value form, so every token that does not start with '-' is the
+// command; only migrate is supported. Connection and target options are owned
+// by the engine.
+func prepareFlywayArgs(args []string, cwd string) (flywayPrepared, error) {
+	flags := make([]string, 0, len(args))
+	command := ""
+	for _, raw := range args {
+		arg := strings.TrimSpace(raw)
+		if arg == "" || arg == "--" {
+			continue
+		}
+		if strings.HasPrefix(arg, "-") {
+			if isFlywayConnectionFlag(arg) {
+				return flywayPrepared{}, ValidationError{Code: ErrorCodeInvalidArgument, Message: "connection flags are not allowed", Details: arg}
+			}
+			if isFlywayEngineFlag(arg) {
+				return flywayPrepared{}, ValidationError{Code: ErrorCodeInvalidArgument, Message: "flag is managed by the engine", Details: arg}
+			}
+			flags = append(flags, arg)
+			continue
+		}
+		if command != "" {
+			return flywayPrepared{}, ValidationError{Code: ErrorCodeInvalidArgument, Message: "only one flyway command is allowed", Details: arg}
+		}
+		command = arg
+	}
+	if command == "" {
+		return flywayPrepared{}, ValidationError{Code: ErrorCodeInvalidArgument, Message: "flyway command is required"}
+	}
+	if !strings.EqualFold(command, "migrate") {
+		return flywayPrepared{}, ValidationError{Code: ErrorCodeInvalidArgument, Message: "unsupported flyway command", Details: command}
+	}
+	if strings.TrimSpace(cwd) == "" {
+		if wd, err := os.Getwd(); err == nil {
+			cwd = wd
+		}
+	}
+	normalized := append(flags, "migrate")
+	return flywayPrepared{
+		normalizedArgs: normalized,
+		argsNormalized: strings.Join(normalized, " "),
+		workDir:        cwd,
+	}, nil
+}
+
+func isFlywayConnectionFlag(arg string) bool {
+	switch flywayFlagName(arg) {
+	case "-url", "-user", "-password", "-driver":
+		return true
+	}
+	return false
+}
+
+func isFlywayEngineFlag(arg string) bool {
+	switch flywayFlagName(arg) {
+	case "-target", "-outputType", "-dryRunOutput":
+		return true
+	}
+	return false
+}
+
+func flywayFlagName(arg string) string {
+	if idx := strings.Index(arg, "="); idx >= 0 {
+		return arg[:idx]
+	}
+	return arg
+}
+
+// replaceFlywayCommand swaps the command token, appending it when missing.
+func replaceFlywayCommand(args []string, command string) []string {
+	out := make([]string, 0, len(args)+1)
+	replaced := false
+	for _, arg := range args {
+		if !replaced && strings.TrimSpace(arg) != "" && !strings.HasPrefix(arg, "-") {
+			out = append(out, command)
+			replaced = true
+			continue
+		}
+		out = append(out, arg)
+	}
+	if !replaced {
+		out = append(out, command)
+	}
+	return out
+}
+
+func flywayInfoArgs(args []string) []string {
+	return append(replaceFlywayCommand(args, "info"), "-outputType=json")
+}
+
+// applyFlywayTaskArgs migrates up to the version planned for the task; the
+// version is carried in the task's changeset id.
+func applyFlywayTaskArgs(args []string, task taskState) []string {
+	args = replaceFlywayCommand(args, "migrate")
+	if version := strings.TrimSpace(task.ChangesetID); version != "" {
+		args = append(args, "-target="+version)
+	}
+	return args
+}
+
+func prependFlywayConnectionArgs(args []string, url string, user string) []string {
+	out := make([]string, 0, len(args)+2)
+	out = append(out, "-url="+url, "-user="+engineRuntime.PostgresSuperuser(user))
+	return append(out, args...)
+}
+
+type flywayInfoOutput struct {
+	Migrations []flywayInfoMigration `json:"migrations"`
+	Error      *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+type flywayInfoMigration struct {
+	Category    string `json:"category"`
+	Version     string `json:"version"`
+	Description string `json:"description"`
+	State       string `json:"state"`
+	Filepath    string `json:"filepath"`
+}
+
+// parseFlywayInfo extracts pending versioned migrations from the JSON printed
+// by `flyway info -outputType=json`, skipping any banner before the document.
+func parseFlywayInfo(output string) ([]FlywayMigration, error) {
+	start := strings.Index(output, "{")
+	if start < 0 {
+		return nil, fmt.Errorf("flyway info output is not json")
+	}
+	var info flywayInfoOutput
+	if err := json.NewDecoder(strings.NewReader(output[start:])).Decode(&info); err != nil {
+		return nil, fmt.Errorf("cannot decode flyway info output: %w", err)
+	}
+	if info.Error != nil {
+		return nil, fmt.Errorf("flyway info failed: %s", strings.TrimSpace(info.Error.Message))
+	}
+	migrations := []FlywayMigration{}
+	for _, item := range info.Migrations {
+		if !strings.EqualFold(item.Category, "Versioned") || !strings.EqualFold(item.State, "Pending") {
+			continue
+		}
+		version := strings.TrimSpace(item.Version)
+		if version == "" {
+			return nil, fmt.Errorf("pending migration without version: %s", item.Description)
+		}
+		migrations = append(migrations, FlywayMigration{
+			Version:     version,
+			Description: strings.TrimSpace(item.Description),
+			Script:      strings.TrimSpace(item.Filepath),
+		})
+	}
+	return migrations, nil
+}
+
+// hashFlywayScripts fills migration checksums from script contents. A pending
+// script that cannot be read on this host (a wrong work dir, a Windows path
+// reported under WSL, a missing file) fails planning: hashing only its name
+// would let an edited migration reuse a stale state. Migrations without a
+// script path, such as Java migrations, keep an empty checksum.
+func hashFlywayScripts(migrations []FlywayMigration, workDir string) error {
+	for i := range migrations {
+		path := migrations[i].Script
+		if path == "" {
+			continue
+		}
+		if !filepath.IsAbs(path) && strings.TrimSpace(workDir) != "" {
+			path = filepath.Join(workDir, path)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("migration %s: %w", migrations[i].Version, err)
+		}
+		migrations[i].Checksum = sha256Hex(string(data))
+	}
+	return nil
+}
+
+// flywayFingerprint keys a migration step on its input state, the normalized
+// flyway arguments (placeholders, locations and schemas change the SQL that
+// runs) and the migration contents.
+func flywayFingerprint(prevStateID string, argsNormalized string, migrations []FlywayMigration) string {
+	hasher := newStateHasher()
+	hasher.write("prepare_kind", "flyway")
+	hasher.write("prev_state_id", prevStateID)
+	hasher.write("args", argsNormalized)
+	for _, migration := range migrations {
+		hasher.write("migration_hash", flywayMigrationHash(migration))
+	}
+	return hasher.sum()
+}
+
+func flywayMigrationHash(migration FlywayMigration) string {
+	if strings.TrimSpace(migration.Checksum) != "" {
+		return strings.TrimSpace(migration.Checksum)
+	}
+	return sha256Hex(migration.Version + "\n" + migration.Description + "\n" + migration.Script)
+}
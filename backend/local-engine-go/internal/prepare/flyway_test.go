@@ -0,0 +1,310 @@
+package prepare
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	engineRuntime "github.com/sqlrs/engine-local/internal/runtime"
+)
+
+type fakeFlywayRunner struct {
+	runs   []FlywayRunRequest
+	output string
+	err    error
+}
+
+func (f *fakeFlywayRunner) Run(ctx context.Context, req FlywayRunRequest) (string, error) {
+	f.runs = append(f.runs, req)
+	return f.output, f.err
+}
+
+const flywayInfoJSON = `Flyway Community Edition by Redgate
+{
+  "schemaVersion": "1",
+  "migrations": [
+    {"category": "Versioned", "version": "1", "description": "init", "state": "Success", "filepath": "sql/V1__init.sql"},
+    {"category": "Versioned", "version": "2", "description": "add users", "state": "Pending", "filepath": "sql/V2__add_users.sql"},
+    {"category": "Repeatable", "version": "", "description": "views", "state": "Pending", "filepath": "sql/R__views.sql"},
+    {"category": "Versioned", "version": "3", "description": "add orders", "state": "Pending", "filepath": "sql/V3__add_orders.sql"}
+  ]
+}`
+
+func TestPrepareFlywayArgs(t *testing.T) {
+	prepared, err := prepareFlywayArgs([]string{"-locations=filesystem:sql", "migrate", "-schemas=public"}, "/work")
+	if err != nil {
+		t.Fatalf("prepareFlywayArgs: %v", err)
+	}
+	if prepared.argsNormalized != "-locations=filesystem:sql -schemas=public migrate" {
+		t.Fatalf("unexpected normalized args: %q", prepared.argsNormalized)
+	}
+	if prepared.workDir != "/work" {
+		t.Fatalf("unexpected work dir: %q", prepared.workDir)
+	}
+}
+
+func TestPrepareFlywayArgsRejects(t *testing.T) {
+	cases := map[string][]string{
+		"empty":      nil,
+		"no command": {"-locations=filesystem:sql"},
+		"info":       {"info"},
+		"two":        {"migrate", "repair"},
+		"url":        {"-url=jdbc:postgresql://db/app", "migrate"},
+		"user":       {"-user=app", "migrate"},
+		"password":   {"-password", "migrate"},
+		"target":     {"-target=5", "migrate"},
+	}
+	for name, args := range cases {
+		_, err := prepareFlywayArgs(args, "/work")
+		var validation ValidationError
+		if !errors.As(err, &validation) || validation.Code != "invalid_argument" {
+			t.Fatalf("%s: expected validation error, got %v", name, err)
+		}
+	}
+}
+
+func TestPrepareRequestRejectsFlywayWithoutArgs(t *testing.T) {
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{})
+	_, err := mgr.prepareRequest(Request{PrepareKind: "flyway", ImageID: "image-1"})
+	var validation ValidationError
+	if !errors.As(err, &validation) || validation.Message != "flyway command is required" {
+		t.Fatalf("expected flyway args validation error, got %v", err)
+	}
+}
+
+func TestParseFlywayInfo(t *testing.T) {
+	migrations, err := parseFlywayInfo(flywayInfoJSON)
+	if err != nil {
+		t.Fatalf("parseFlywayInfo: %v", err)
+	}
+	if len(migrations) != 2 || migrations[0].Version != "2" || migrations[1].Version != "3" {
+		t.Fatalf("expected pending versioned migrations 2 and 3, got %+v", migrations)
+	}
+	if migrations[0].Script != "sql/V2__add_users.sql" || migrations[0].Description != "add users" {
+		t.Fatalf("unexpected migration metadata: %+v", migrations[0])
+	}
+
+	if _, err := parseFlywayInfo("ERROR: no json here"); err == nil {
+		t.Fatalf("expected error for non-json output")
+	}
+	if _, err := parseFlywayInfo(`{"error": {"message": "Unable to connect"}}`); err == nil || !strings.Contains(err.Error(), "Unable to connect") {
+		t.Fatalf("expected flyway error message, got %v", err)
+	}
+}
+
+func TestFlywayFingerprintUsesScriptChecksum(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "sql"), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	writeTempFile(t, filepath.Join(dir, "sql", "V2__add_users.sql"), "create table users(id int);")
+	migrations := []FlywayMigration{{Version: "2", Description: "add users", Script: "sql/V2__add_users.sql"}}
+	if err := hashFlywayScripts(migrations, dir); err != nil {
+		t.Fatalf("hashFlywayScripts: %v", err)
+	}
+	if migrations[0].Checksum == "" {
+		t.Fatalf("expected checksum from script content")
+	}
+	before := flywayFingerprint("state-1", "migrate", migrations)
+	if before == flywayFingerprint("state-2", "migrate", migrations) {
+		t.Fatalf("expected previous state to change fingerprint")
+	}
+	if before == flywayFingerprint("state-1", "-placeholders.env=prod migrate", migrations) {
+		t.Fatalf("expected flyway args to change fingerprint")
+	}
+
+	writeTempFile(t, filepath.Join(dir, "sql", "V2__add_users.sql"), "create table users(id bigint);")
+	if err := hashFlywayScripts(migrations, dir); err != nil {
+		t.Fatalf("hashFlywayScripts: %v", err)
+	}
+	if before == flywayFingerprint("state-1", "migrate", migrations) {
+		t.Fatalf("expected script change to change fingerprint")
+	}
+}
+
+func TestHashFlywayScriptsRejectsUnreadableScript(t *testing.T) {
+	migrations := []FlywayMigration{{Version: "2", Description: "add users", Script: `C:\work\sql\V2__add_users.sql`}}
+	if err := hashFlywayScripts(migrations, t.TempDir()); err == nil || !strings.Contains(err.Error(), "migration 2") {
+		t.Fatalf("expected an error for an unreadable script, got %v", err)
+	}
+	java := []FlywayMigration{{Version: "3", Description: "java migration"}}
+	if err := hashFlywayScripts(java, t.TempDir()); err != nil || java[0].Checksum != "" {
+		t.Fatalf("expected migrations without a script to be skipped, got %+v err=%v", java, err)
+	}
+}
+
+func TestBuildPlanFlywayRejectsUnreadableScript(t *testing.T) {
+	flyway := &fakeFlywayRunner{output: flywayInfoJSON}
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{flyway: flyway})
+	prepared, err := mgr.prepareRequest(Request{
+		PrepareKind: "flyway",
+		ImageID:     "image-1@sha256:resolved",
+		FlywayArgs:  []string{"-locations=filesystem:sql", "migrate"},
+		WorkDir:     t.TempDir(),
+		PlanOnly:    true,
+	})
+	if err != nil {
+		t.Fatalf("prepareRequest: %v", err)
+	}
+	_, _, errResp := mgr.buildPlan(context.Background(), "job-1", prepared)
+	if errResp == nil || errResp.Code != ErrorCodeInvalidArgument || errResp.Message != "cannot read flyway migration script" {
+		t.Fatalf("expected invalid_argument for a missing script, got %+v", errResp)
+	}
+}
+
+func TestBuildPlanFlywayUsesMigrations(t *testing.T) {
+	flyway := &fakeFlywayRunner{output: flywayInfoJSON}
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{flyway: flyway})
+	prepared, err := mgr.prepareRequest(Request{
+		PrepareKind: "flyway",
+		ImageID:     "image-1@sha256:resolved",
+		FlywayArgs:  []string{"-locations=filesystem:sql", "migrate"},
+		WorkDir:     writeFlywayScripts(t),
+		PlanOnly:    true,
+	})
+	if err != nil {
+		t.Fatalf("prepareRequest: %v", err)
+	}
+
+	tasks, stateID, errResp := mgr.buildPlan(context.Background(), "job-1", prepared)
+	if errResp != nil {
+		t.Fatalf("buildPlan: %+v", errResp)
+	}
+	if len(flyway.runs) != 1 {
+		t.Fatalf("expected one flyway info run, got %+v", flyway.runs)
+	}
+	args := flyway.runs[0].Args
+	if !containsArg(args, "info") || !containsArg(args, "-outputType=json") || !strings.HasPrefix(args[0], "-url=jdbc:postgresql://") {
+		t.Fatalf("unexpected info args: %+v", args)
+	}
+	if len(tasks) != 4 {
+		t.Fatalf("expected plan, two migrations and prepare-instance, got %+v", tasks)
+	}
+	first, second := tasks[1], tasks[2]
+	if first.ChangesetID != "2" || second.ChangesetID != "3" {
+		t.Fatalf("expected migration versions on tasks, got %+v", tasks)
+	}
+	if second.Input == nil || second.Input.Kind != "state" || second.Input.ID != first.OutputStateID {
+		t.Fatalf("expected second migration to build on the first, got %+v", second.Input)
+	}
+	if stateID != second.OutputStateID {
+		t.Fatalf("expected final state %s, got %s", second.OutputStateID, stateID)
+	}
+	if first.TaskHash == "" || first.TaskHash == second.TaskHash {
+		t.Fatalf("expected distinct task hashes, got %+v", tasks)
+	}
+}
+
+// writeFlywayScripts lays out the pending scripts listed in flywayInfoJSON.
+func writeFlywayScripts(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "sql"), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	writeTempFile(t, filepath.Join(dir, "sql", "V2__add_users.sql"), "create table users(id int);")
+	writeTempFile(t, filepath.Join(dir, "sql", "V3__add_orders.sql"), "create table orders(id int);")
+	return dir
+}
+
+func TestBuildPlanFlywayWithoutPendingMigrations(t *testing.T) {
+	flyway := &fakeFlywayRunner{output: `{"migrations": []}`}
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{flyway: flyway})
+	prepared, err := mgr.prepareRequest(Request{
+		PrepareKind: "flyway",
+		ImageID:     "image-1@sha256:resolved",
+		FlywayArgs:  []string{"migrate"},
+		PlanOnly:    true,
+	})
+	if err != nil {
+		t.Fatalf("prepareRequest: %v", err)
+	}
+	tasks, stateID, errResp := mgr.buildPlan(context.Background(), "job-1", prepared)
+	if errResp != nil {
+		t.Fatalf("buildPlan: %+v", errResp)
+	}
+	if len(tasks) != 3 || tasks[1].Type != "state_execute" || tasks[1].ChangesetID != "" || stateID == "" {
+		t.Fatalf("expected a single migrate task, got %+v", tasks)
+	}
+}
+
+func TestBuildPlanFlywayReportsRunnerFailure(t *testing.T) {
+	flyway := &fakeFlywayRunner{output: "ERROR: connection refused", err: errors.New("exit 1")}
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{flyway: flyway})
+	prepared, err := mgr.prepareRequest(Request{
+		PrepareKind: "flyway",
+		ImageID:     "image-1@sha256:resolved",
+		FlywayArgs:  []string{"migrate"},
+		PlanOnly:    true,
+	})
+	if err != nil {
+		t.Fatalf("prepareRequest: %v", err)
+	}
+	_, _, errResp := mgr.buildPlan(context.Background(), "job-1", prepared)
+	if errResp == nil || errResp.Code != "migration_failed" || errResp.Message != "flyway execution failed" || !strings.Contains(errResp.Details, "connection refused") {
+		t.Fatalf("expected flyway execution failure, got %+v", errResp)
+	}
+}
+
+func TestExecuteFlywayStepMigratesToTarget(t *testing.T) {
+	flyway := &fakeFlywayRunner{output: "ok"}
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{flyway: flyway})
+	prepared := preparedRequest{
+		request:        Request{PrepareKind: "flyway", FlywayEnv: map[string]string{"FLYWAY_SCHEMAS": "app"}},
+		normalizedArgs: []string{"-locations=filesystem:sql", "migrate"},
+		flywayWorkDir:  "/work",
+	}
+	rt := &jobRuntime{instance: engineRuntime.Instance{ID: "container-1", Host: "127.0.0.1", Port: 5432}}
+	task := taskState{PlanTask: PlanTask{ChangesetID: "2"}}
+
+	if errResp := mgr.executeFlywayStep(context.Background(), "job-1", prepared, rt, task); errResp != nil {
+		t.Fatalf("executeFlywayStep: %+v", errResp)
+	}
+	if len(flyway.runs) != 1 {
+		t.Fatalf("expected flyway run, got %+v", flyway.runs)
+	}
+	req := flyway.runs[0]
+	want := []string{
+		"-url=jdbc:postgresql://127.0.0.1:5432/postgres",
+		"-user=sqlrs",
+		"-locations=filesystem:sql",
+		"migrate",
+		"-target=2",
+	}
+	if strings.Join(req.Args, " ") != strings.Join(want, " ") {
+		t.Fatalf("unexpected args: %+v", req.Args)
+	}
+	if req.ExecPath != "flyway" || req.WorkDir != "/work" || req.Env["FLYWAY_SCHEMAS"] != "app" {
+		t.Fatalf("unexpected run request: %+v", req)
+	}
+}
+
+func TestExecuteFlywayStepRequiresInstance(t *testing.T) {
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{})
+	prepared := preparedRequest{request: Request{PrepareKind: "flyway"}, normalizedArgs: []string{"migrate"}}
+	errResp := mgr.executeFlywayStep(context.Background(), "job-1", prepared, &jobRuntime{}, taskState{})
+	if errResp == nil || errResp.Code != "internal_error" {
+		t.Fatalf("expected missing connection info error, got %+v", errResp)
+	}
+}
+
+func TestHostFlywayRunnerDefaultsExecPath(t *testing.T) {
+	var gotPath string
+	prev := execCommand
+	execCommand = func(ctx context.Context, name string, args ...string) *exec.Cmd {
+		gotPath = name
+		return prev(ctx, os.Args[0], "-test.run=^$")
+	}
+	t.Cleanup(func() { execCommand = prev })
+
+	if _, err := (hostFlywayRunner{}).Run(context.Background(), FlywayRunRequest{ExecMode: "native", Args: []string{"info"}}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if gotPath != "flyway" {
+		t.Fatalf("expected default flyway exec path, got %q", gotPath)
+	}
+}
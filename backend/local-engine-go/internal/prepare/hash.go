@@ -5,6 +5,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"hash"
+	"strings"
 )
 
 type stateHasher struct {
@@ -22,3 +23,21 @@ func (s *stateHasher) write(key, value string) {
 func (s *stateHasher) sum() string {
 	return hex.EncodeToString(s.hash.Sum(nil))
 }
+
+// cacheVersion is the engine version folded into task hashes and job
+// signatures. prepare.cache.versionSalt replaces the build version when set,
+// so operators decide when an upgrade invalidates cached states.
+func (m *PrepareService) cacheVersion() string {
+	if m.config == nil {
+		return m.version
+	}
+	value, err := m.config.Get("prepare.cache.versionSalt", true)
+	if err != nil || value == nil {
+		return m.version
+	}
+	salt, ok := value.(string)
+	if !ok || strings.TrimSpace(salt) == "" {
+		return m.version
+	}
+	return salt
+}
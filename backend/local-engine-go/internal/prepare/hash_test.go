@@ -29,3 +29,47 @@ func TestStateHasherDifferentInput(t *testing.T) {
 		t.Fatalf("expected different hashes")
 	}
 }
+
+func TestCacheVersionSaltKeepsStateIDsAcrossVersions(t *testing.T) {
+	req := Request{PrepareKind: "psql", ImageID: "image-1", PsqlArgs: []string{"-c", "select 1;"}}
+	stateID := func(version string, salt any) string {
+		t.Helper()
+		values := map[string]any{}
+		if salt != nil {
+			values["prepare.cache.versionSalt"] = salt
+		}
+		mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{
+			config: &fakeConfigStore{values: values},
+		})
+		mgr.version = version
+		prepared, err := mgr.prepareRequest(req)
+		if err != nil {
+			t.Fatalf("prepareRequest: %v", err)
+		}
+		taskHash, errResp := mgr.computeTaskHash(prepared)
+		if errResp != nil {
+			t.Fatalf("computeTaskHash: %+v", errResp)
+		}
+		id, errResp := mgr.computeOutputStateID("", "image", "image-1", taskHash)
+		if errResp != nil {
+			t.Fatalf("computeOutputStateID: %+v", errResp)
+		}
+		return id
+	}
+
+	if stateID("v1", nil) == stateID("v2", nil) {
+		t.Fatalf("expected engine versions to change state ids without a salt")
+	}
+	if stateID("v1", "salt-1") != stateID("v2", "salt-1") {
+		t.Fatalf("expected the same salt to keep state ids across engine versions")
+	}
+	if stateID("v1", "salt-1") == stateID("v1", "salt-2") {
+		t.Fatalf("expected a new salt to change state ids")
+	}
+	if stateID("v1", "v1") != stateID("v1", nil) {
+		t.Fatalf("expected a salt equal to the engine version to keep existing state ids")
+	}
+	if stateID("v1", "  ") != stateID("v1", nil) {
+		t.Fatalf("expected a blank salt to fall back to the engine version")
+	}
+}
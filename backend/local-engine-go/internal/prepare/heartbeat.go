@@ -0,0 +1,57 @@
+package prepare
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	minHeartbeat        = 200 * time.Millisecond
+	defaultHeartbeat    = 500 * time.Millisecond
+	defaultHeartbeatMax = time.Minute
+)
+
+// parseHeartbeatEvery validates Request.HeartbeatEvery; zero means "use the
+// engine default".
+func parseHeartbeatEvery(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+	every, err := time.ParseDuration(value)
+	if err != nil || every <= 0 {
+		return 0, ValidationError{Code: ErrorCodeInvalidArgument, Message: "heartbeat_every must be a positive duration", Details: value}
+	}
+	return every, nil
+}
+
+// heartbeatMax reads orchestrator.jobs.heartbeatMax, the ceiling for
+// per-job heartbeat intervals.
+func (m *PrepareService) heartbeatMax() time.Duration {
+	if m.config == nil {
+		return defaultHeartbeatMax
+	}
+	value, err := m.config.Get("orchestrator.jobs.heartbeatMax", true)
+	if err != nil || value == nil {
+		return defaultHeartbeatMax
+	}
+	raw, ok := value.(string)
+	if !ok {
+		return defaultHeartbeatMax
+	}
+	ceiling, err := time.ParseDuration(strings.TrimSpace(raw))
+	if err != nil || ceiling <= 0 {
+		return defaultHeartbeatMax
+	}
+	return ceiling
+}
+
+// jobHeartbeat resolves the heartbeat interval requested by a job, or zero
+// when the job keeps the service default.
+func (m *PrepareService) jobHeartbeat(req Request) time.Duration {
+	every, err := parseHeartbeatEvery(req.HeartbeatEvery)
+	if err != nil || every <= 0 {
+		return 0
+	}
+	return normalizeHeartbeat(every, m.heartbeatMax())
+}
@@ -0,0 +1,60 @@
+package prepare
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestJobHeartbeatResolution(t *testing.T) {
+	m := &PrepareService{}
+	if got := m.jobHeartbeat(Request{}); got != 0 {
+		t.Fatalf("expected service default, got %s", got)
+	}
+	if got := m.jobHeartbeat(Request{HeartbeatEvery: "20s"}); got != 20*time.Second {
+		t.Fatalf("expected request interval, got %s", got)
+	}
+	if got := m.jobHeartbeat(Request{HeartbeatEvery: "5m"}); got != defaultHeartbeatMax {
+		t.Fatalf("expected default ceiling, got %s", got)
+	}
+	if got := m.jobHeartbeat(Request{HeartbeatEvery: "10ms"}); got != minHeartbeat {
+		t.Fatalf("expected minimum interval, got %s", got)
+	}
+
+	m.config = &fakeConfigStore{values: map[string]any{"orchestrator.jobs.heartbeatMax": "10s"}}
+	if got := m.jobHeartbeat(Request{HeartbeatEvery: "20s"}); got != 10*time.Second {
+		t.Fatalf("expected configured ceiling, got %s", got)
+	}
+	m.config = &fakeConfigStore{values: map[string]any{"orchestrator.jobs.heartbeatMax": "never"}}
+	if got := m.heartbeatMax(); got != defaultHeartbeatMax {
+		t.Fatalf("expected invalid config to fall back to default, got %s", got)
+	}
+}
+
+func TestPrepareRequestRejectsInvalidHeartbeatEvery(t *testing.T) {
+	m := &PrepareService{}
+	for _, value := range []string{"often", "-1s", "0s"} {
+		_, err := m.prepareRequest(Request{
+			PrepareKind:    "psql",
+			ImageID:        "image-1",
+			PsqlArgs:       []string{"-c", "select 1"},
+			HeartbeatEvery: value,
+		})
+		var validation ValidationError
+		if !errors.As(err, &validation) {
+			t.Fatalf("expected validation error for %q, got %v", value, err)
+		}
+	}
+}
+
+func TestUpdateHeartbeatIgnoresPing(t *testing.T) {
+	m := &PrepareService{beats: map[string]*heartbeatState{}}
+	m.now = time.Now
+	lastEventAt := time.Now().Add(-time.Minute).UTC()
+	m.beats["job-1"] = &heartbeatState{lastEventAt: lastEventAt, runningTask: "execute-0"}
+
+	m.updateHeartbeat("job-1", Event{Type: "ping", TaskID: "execute-0"})
+	if got := m.beats["job-1"].lastEventAt; !got.Equal(lastEventAt) {
+		t.Fatalf("expected ping to leave the activity time alone, got %s", got)
+	}
+}
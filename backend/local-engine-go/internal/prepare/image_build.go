@@ -0,0 +1,196 @@
+package prepare
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sqlrs/engine-local/internal/runtime"
+)
+
+// buildImageRepo names images built from Request.Build. The tag is derived
+// from the build context hash, so an unchanged context keeps its tag and the
+// states built on it, even when docker's layer cache was pruned in between.
+const buildImageRepo = "sqlrs/build"
+
+// prepareBuild validates Request.Build and normalizes it. The context is
+// hashed later, by the job, so a large context does not hold up the submit.
+func (m *PrepareService) prepareBuild(spec BuildSpec) (BuildSpec, error) {
+	if !m.imageBuildEnabled() {
+		return BuildSpec{}, ValidationError{Code: ErrorCodeInvalidArgument, Message: "image builds are disabled", Details: "set container.build.enabled to true"}
+	}
+	contextDir := strings.TrimSpace(spec.ContextDir)
+	if contextDir == "" {
+		return BuildSpec{}, ValidationError{Code: ErrorCodeInvalidArgument, Message: "build.context_dir is required"}
+	}
+	if !filepath.IsAbs(contextDir) {
+		return BuildSpec{}, ValidationError{Code: ErrorCodeInvalidArgument, Message: "build.context_dir must be absolute", Details: contextDir}
+	}
+	contextDir = filepath.Clean(contextDir)
+	if info, err := os.Stat(contextDir); err != nil || !info.IsDir() {
+		return BuildSpec{}, ValidationError{Code: ErrorCodeInvalidArgument, Message: "build.context_dir is not a directory", Details: contextDir}
+	}
+	dockerfile := strings.TrimSpace(spec.Dockerfile)
+	if dockerfile == "" {
+		dockerfile = "Dockerfile"
+	}
+	dockerfile = filepath.Clean(filepath.FromSlash(dockerfile))
+	if filepath.IsAbs(dockerfile) || dockerfile == ".." || strings.HasPrefix(dockerfile, ".."+string(filepath.Separator)) {
+		return BuildSpec{}, ValidationError{Code: ErrorCodeInvalidArgument, Message: "build.dockerfile must be a path inside build.context_dir", Details: spec.Dockerfile}
+	}
+	if info, err := os.Stat(filepath.Join(contextDir, dockerfile)); err != nil || !info.Mode().IsRegular() {
+		return BuildSpec{}, ValidationError{Code: ErrorCodeInvalidArgument, Message: "build.dockerfile does not exist", Details: filepath.Join(contextDir, dockerfile)}
+	}
+	return BuildSpec{ContextDir: contextDir, Dockerfile: filepath.ToSlash(dockerfile)}, nil
+}
+
+// resolveBuildImage hashes the build context and uses the derived tag as the
+// job's resolved image, so task hashes and the job signature follow the
+// context rather than the image ID docker happens to assign. It builds
+// nothing; the build_image task does.
+func (m *PrepareService) resolveBuildImage(prepared *preparedRequest) *ErrorResponse {
+	hash, err := buildContextHash(*prepared.request.Build, prepared.request.Platform)
+	if err != nil {
+		return errorResponse(ErrorCodeImageBuildFailed, "cannot hash build context", err.Error())
+	}
+	tag := buildImageRepo + ":" + hash[:16]
+	prepared.request.ImageID = tag
+	prepared.resolvedImageID = tag
+	return nil
+}
+
+// buildContextHash hashes the Dockerfile path, the platform and every regular
+// file docker would send as the build context: .dockerignore is honored and
+// .git directories are skipped. The Dockerfile is always hashed, as docker
+// always sends it.
+func buildContextHash(spec BuildSpec, platform string) (string, error) {
+	ignore, err := loadDockerignore(spec.ContextDir)
+	if err != nil {
+		return "", err
+	}
+	hasher := newStateHasher()
+	hasher.write("build_dockerfile", spec.Dockerfile)
+	hasher.write("build_platform", platform)
+	err = filepath.WalkDir(spec.ContextDir, func(current string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(spec.ContextDir, current)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == "." {
+			return nil
+		}
+		if entry.IsDir() {
+			if entry.Name() == ".git" || (ignore.excludes(rel) && !ignore.hasExceptions()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() || (rel != spec.Dockerfile && ignore.excludes(rel)) {
+			return nil
+		}
+		digest, err := fileDigest(current)
+		if err != nil {
+			return err
+		}
+		hasher.write("build_file", rel)
+		hasher.write("build_file_hash", digest)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return hasher.sum(), nil
+}
+
+// isBuildImageRef reports whether imageID is the image reference prepareBuild
+// assigned; stored build requests carry it when they are prepared again.
+func isBuildImageRef(imageID string) bool {
+	return imageID == buildImageRepo || strings.HasPrefix(imageID, buildImageRepo+":")
+}
+
+// buildImage runs docker build for Request.Build under the tag
+// resolveBuildImage derived. The tag is content-addressed, so an image that
+// already carries it was built from the same context and is used as is.
+func (m *PrepareService) buildImage(ctx context.Context, jobID string, prepared preparedRequest) *ErrorResponse {
+	build := prepared.request.Build
+	tag := strings.TrimSpace(prepared.resolvedImageID)
+	if tag == "" {
+		return errorResponse(ErrorCodeInternal, "resolved image id is required", "")
+	}
+	if _, ok, err := m.runtime.HasImageLocally(ctx, tag); err == nil && ok {
+		m.appendLog(jobID, fmt.Sprintf("image %s is already built", tag))
+		return nil
+	}
+	m.appendLog(jobID, fmt.Sprintf("build image %s from %s", tag, build.ContextDir))
+	ctx = runtime.WithLogSink(ctx, func(line string) {
+		m.appendLog(jobID, "docker: "+line)
+	})
+	imageID, err := m.runtime.Build(ctx, runtime.BuildRequest{
+		ContextDir: build.ContextDir,
+		Dockerfile: build.Dockerfile,
+		Tag:        tag,
+		Platform:   prepared.request.Platform,
+	})
+	if err != nil {
+		return errorResponse(runtimeErrorCode(err, ErrorCodeImageBuildFailed), "cannot build image", err.Error())
+	}
+	m.appendLog(jobID, fmt.Sprintf("built image %s as %s", strings.TrimSpace(imageID), tag))
+	return nil
+}
+
+// buildImagePending reports whether planning prepared needs a container of
+// a build image that is not built yet. Liquibase and Flyway plan in a
+// container; state lookup and cache explain never build, so they cannot plan
+// those requests until a job has built the image.
+func (m *PrepareService) buildImagePending(ctx context.Context, prepared preparedRequest) bool {
+	if prepared.request.Build == nil || !plansFromRuntime(prepared.request.PrepareKind) {
+		return false
+	}
+	_, ok, err := m.runtime.HasImageLocally(ctx, prepared.resolvedImageID)
+	return err != nil || !ok
+}
+
+// imagePlanTasks returns the task that produced the job's image: build_image
+// for Request.Build, resolve_image for a tag or platform to resolve, and none
+// for a pinned digest.
+func imagePlanTasks(prepared preparedRequest, resolvedImageID string) []PlanTask {
+	switch {
+	case prepared.request.Build != nil:
+		return []PlanTask{{
+			TaskID:          "build-image",
+			Type:            "build_image",
+			ImageID:         prepared.request.ImageID,
+			ResolvedImageID: resolvedImageID,
+		}}
+	case needsImageResolve(prepared.request.ImageID, prepared.request.Platform):
+		return []PlanTask{{
+			TaskID:          "resolve-image",
+			Type:            "resolve_image",
+			ImageID:         prepared.request.ImageID,
+			ResolvedImageID: resolvedImageID,
+		}}
+	default:
+		return nil
+	}
+}
+
+// imageBuildEnabled reads container.build.enabled. Builds are off by default
+// because they run arbitrary Dockerfiles on the engine host.
+func (m *PrepareService) imageBuildEnabled() bool {
+	if m.config == nil {
+		return false
+	}
+	value, err := m.config.Get("container.build.enabled", true)
+	if err != nil {
+		return false
+	}
+	enabled, ok := value.(bool)
+	return ok && enabled
+}
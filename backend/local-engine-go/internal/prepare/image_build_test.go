@@ -0,0 +1,232 @@
+package prepare
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeBuildContext(t *testing.T, content string) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "Dockerfile"), []byte(content), 0o600); err != nil {
+		t.Fatalf("write Dockerfile: %v", err)
+	}
+	return dir
+}
+
+func newBuildManager(t *testing.T, rt *fakeRuntime) *PrepareService {
+	t.Helper()
+	return newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{
+		runtime: rt,
+		config:  &fakeConfigStore{values: map[string]any{"container.build.enabled": true}},
+	})
+}
+
+func TestBuildImageRunsAsTask(t *testing.T) {
+	rt := &fakeRuntime{builtImage: "sha256:seeded"}
+	mgr := newBuildManager(t, rt)
+	contextDir := writeBuildContext(t, "FROM postgres:17\n")
+
+	accepted, err := mgr.Submit(context.Background(), Request{
+		PrepareKind: "psql",
+		PsqlArgs:    []string{"-c", "select 1"},
+		Build:       &BuildSpec{ContextDir: contextDir},
+	})
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	status, ok := mgr.Get(accepted.JobID)
+	if !ok || status.Status != StatusSucceeded || status.Result == nil {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+	if len(rt.buildCalls) != 1 || len(rt.resolveCalls) != 0 {
+		t.Fatalf("expected one build and no resolve, got build=%+v resolve=%v", rt.buildCalls, rt.resolveCalls)
+	}
+	build := rt.buildCalls[0]
+	if build.ContextDir != contextDir || build.Dockerfile != "Dockerfile" || !strings.HasPrefix(build.Tag, "sqlrs/build:") {
+		t.Fatalf("unexpected build request %+v", build)
+	}
+	if len(rt.startCalls) == 0 || rt.startCalls[0].ImageID != build.Tag {
+		t.Fatalf("expected the built tag to be started, got %+v", rt.startCalls)
+	}
+	if status.Result.ImageID != build.Tag {
+		t.Fatalf("expected the built tag in the result, got %q", status.Result.ImageID)
+	}
+	tasks := mgr.ListTasks(accepted.JobID)
+	if len(tasks) < 2 || tasks[1].Type != "build_image" || tasks[1].ImageID != build.Tag || tasks[1].ResolvedImageID != build.Tag {
+		t.Fatalf("expected a build_image task after plan, got %+v", tasks)
+	}
+	for _, task := range tasks {
+		if task.Type == "resolve_image" {
+			t.Fatalf("expected no resolve_image task for a build, got %+v", tasks)
+		}
+	}
+}
+
+func TestBuildCacheKeyFollowsContextNotImageID(t *testing.T) {
+	rt := &fakeRuntime{builtImage: "sha256:first"}
+	mgr := newBuildManager(t, rt)
+	jobs := 0
+	mgr.idGen = func() (string, error) {
+		jobs++
+		return fmt.Sprintf("job-%d", jobs), nil
+	}
+	contextDir := writeBuildContext(t, "FROM postgres:17\n")
+	submit := func() []TaskEntry {
+		t.Helper()
+		accepted, err := mgr.Submit(context.Background(), Request{
+			PrepareKind: "psql",
+			PsqlArgs:    []string{"-c", "select 1"},
+			Build:       &BuildSpec{ContextDir: contextDir},
+		})
+		if err != nil {
+			t.Fatalf("Submit: %v", err)
+		}
+		return mgr.ListTasks(accepted.JobID)
+	}
+	first := submit()
+	// The layer cache was pruned: the same context builds to a new image ID.
+	rt.builtImage = "sha256:second"
+	second := submit()
+	if len(first) != len(second) {
+		t.Fatalf("expected the same plan, got %+v and %+v", first, second)
+	}
+	for i := range first {
+		if first[i].TaskHash != second[i].TaskHash || first[i].OutputStateID != second[i].OutputStateID {
+			t.Fatalf("expected task %s to keep its cache key, got %+v and %+v", first[i].TaskID, first[i], second[i])
+		}
+	}
+
+	// A tag already present locally was built from the same context.
+	rt.localImages = map[string]string{rt.buildCalls[0].Tag: "sha256:second"}
+	submit()
+	if len(rt.buildCalls) != 2 {
+		t.Fatalf("expected the existing tag to be reused, got %d builds", len(rt.buildCalls))
+	}
+}
+
+func TestSubmitDefersBuildContextHash(t *testing.T) {
+	mgr := newBuildManager(t, &fakeRuntime{})
+	prepared, err := mgr.prepareRequest(Request{
+		PrepareKind: "psql",
+		PsqlArgs:    []string{"-c", "select 1"},
+		Build:       &BuildSpec{ContextDir: writeBuildContext(t, "FROM postgres:17\n")},
+	})
+	if err != nil {
+		t.Fatalf("prepareRequest: %v", err)
+	}
+	if prepared.request.ImageID != buildImageRepo || prepared.resolvedImageID != "" {
+		t.Fatalf("expected the context to be hashed by the job, got image %q resolved %q", prepared.request.ImageID, prepared.resolvedImageID)
+	}
+	if errResp := mgr.ensureResolvedImageID(context.Background(), "", &prepared, nil); errResp != nil {
+		t.Fatalf("ensureResolvedImageID: %+v", errResp)
+	}
+	if !strings.HasPrefix(prepared.resolvedImageID, buildImageRepo+":") || prepared.request.ImageID != prepared.resolvedImageID {
+		t.Fatalf("expected the context tag, got image %q resolved %q", prepared.request.ImageID, prepared.resolvedImageID)
+	}
+
+	// A stored build request carries the engine-assigned reference.
+	stored := prepared.request
+	if _, err := mgr.prepareRequest(stored); err != nil {
+		t.Fatalf("expected a stored build request to prepare again, got %v", err)
+	}
+}
+
+func TestBuildContextHash(t *testing.T) {
+	contextDir := writeBuildContext(t, "FROM postgres:17\n")
+	write := func(rel string, content string) {
+		t.Helper()
+		target := filepath.Join(contextDir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(target), 0o700); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(target, []byte(content), 0o600); err != nil {
+			t.Fatalf("write %s: %v", rel, err)
+		}
+	}
+	hash := func() string {
+		t.Helper()
+		sum, err := buildContextHash(BuildSpec{ContextDir: contextDir, Dockerfile: "Dockerfile"}, "")
+		if err != nil {
+			t.Fatalf("buildContextHash: %v", err)
+		}
+		return sum
+	}
+	write(".dockerignore", "# local files\n**/*.log\nscratch\n!scratch/keep.sql\nDockerfile\n")
+	base := hash()
+	if hash() != base {
+		t.Fatalf("expected an unchanged context to keep its hash")
+	}
+
+	write(".git/HEAD", "ref: refs/heads/main\n")
+	write("logs/build.log", "noise")
+	write("scratch/tmp.sql", "select 1;")
+	if got := hash(); got != base {
+		t.Fatalf("expected .git and ignored files to leave the hash alone")
+	}
+
+	write("scratch/keep.sql", "create table t();")
+	reincluded := hash()
+	if reincluded == base {
+		t.Fatalf("expected a re-included file to change the hash")
+	}
+	write("Dockerfile", "FROM postgres:16\n")
+	if hash() == reincluded {
+		t.Fatalf("expected the Dockerfile to be hashed even when ignored")
+	}
+}
+
+func TestBuildValidation(t *testing.T) {
+	contextDir := writeBuildContext(t, "FROM postgres:17\n")
+	enabled := newBuildManager(t, &fakeRuntime{})
+	disabled := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{})
+	cases := []struct {
+		name    string
+		mgr     *PrepareService
+		req     Request
+		message string
+	}{
+		{name: "disabled", mgr: disabled, req: Request{Build: &BuildSpec{ContextDir: contextDir}}, message: "image builds are disabled"},
+		{name: "with image", mgr: enabled, req: Request{ImageID: "postgres:17", Build: &BuildSpec{ContextDir: contextDir}}, message: "cannot be combined"},
+		{name: "relative context", mgr: enabled, req: Request{Build: &BuildSpec{ContextDir: "ctx"}}, message: "must be absolute"},
+		{name: "missing context", mgr: enabled, req: Request{Build: &BuildSpec{ContextDir: filepath.Join(contextDir, "missing")}}, message: "not a directory"},
+		{name: "escaping dockerfile", mgr: enabled, req: Request{Build: &BuildSpec{ContextDir: contextDir, Dockerfile: "../Dockerfile"}}, message: "inside build.context_dir"},
+		{name: "missing dockerfile", mgr: enabled, req: Request{Build: &BuildSpec{ContextDir: contextDir, Dockerfile: "db.Dockerfile"}}, message: "does not exist"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.req.PrepareKind = "psql"
+			tc.req.PsqlArgs = []string{"-c", "select 1"}
+			_, err := tc.mgr.prepareRequest(tc.req)
+			var validation ValidationError
+			if !errors.As(err, &validation) || !strings.Contains(validation.Message, tc.message) {
+				t.Fatalf("expected %q validation error, got %v", tc.message, err)
+			}
+		})
+	}
+}
+
+func TestBuildFailureFailsJob(t *testing.T) {
+	rt := &fakeRuntime{buildErr: errors.New("docker build failed: exit status 1")}
+	mgr := newBuildManager(t, rt)
+	accepted, err := mgr.Submit(context.Background(), Request{
+		PrepareKind: "psql",
+		PsqlArgs:    []string{"-c", "select 1"},
+		Build:       &BuildSpec{ContextDir: writeBuildContext(t, "FROM postgres:17\n")},
+	})
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	status, ok := mgr.Get(accepted.JobID)
+	if !ok || status.Status != StatusFailed || status.Error == nil || status.Error.Code != ErrorCodeImageBuildFailed {
+		t.Fatalf("expected image_build_failed, got %+v", status)
+	}
+	if len(rt.startCalls) != 0 {
+		t.Fatalf("expected no container after a failed build")
+	}
+}
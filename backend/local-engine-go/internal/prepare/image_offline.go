@@ -0,0 +1,45 @@
+package prepare
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// resolveLocalImage resolves the job's image from the local image store
+// without contacting a registry. It is used instead of ResolveImage when
+// container.offline is set; the requested platform is not checked, since
+// the local store keeps a single platform per tag.
+func (m *PrepareService) resolveLocalImage(ctx context.Context, jobID string, prepared *preparedRequest) *ErrorResponse {
+	imageID := prepared.request.ImageID
+	m.appendLog(jobID, fmt.Sprintf("resolve image %s locally (offline)", imageID))
+	resolved, ok, err := m.runtime.HasImageLocally(ctx, imageID)
+	if err != nil {
+		return errorResponse(runtimeErrorCode(err, ErrorCodeImageResolveFailed), "cannot inspect local image", err.Error())
+	}
+	if !ok {
+		return errorResponse(ErrorCodeImageNotFound, "image is not available locally", fmt.Sprintf("container.offline is set; load or pull %s before preparing", imageID))
+	}
+	resolved = strings.TrimSpace(resolved)
+	if resolved == "" {
+		return errorResponse(ErrorCodeInternal, "resolved image id is required", "")
+	}
+	m.appendLog(jobID, fmt.Sprintf("resolved image %s", resolved))
+	prepared.resolvedImageID = resolved
+	return nil
+}
+
+// containerOffline reads container.offline. When set, tags are resolved from
+// the local image store only and a missing image fails the job instead of
+// being pulled.
+func (m *PrepareService) containerOffline() bool {
+	if m.config == nil {
+		return false
+	}
+	value, err := m.config.Get("container.offline", true)
+	if err != nil {
+		return false
+	}
+	offline, ok := value.(bool)
+	return ok && offline
+}
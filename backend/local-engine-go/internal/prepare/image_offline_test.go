@@ -0,0 +1,78 @@
+package prepare
+
+import (
+	"context"
+	"testing"
+)
+
+func newOfflineManager(t *testing.T, rt *fakeRuntime) *PrepareService {
+	t.Helper()
+	return newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{
+		runtime: rt,
+		config:  &fakeConfigStore{values: map[string]any{"container.offline": true}},
+	})
+}
+
+func TestOfflineUsesLocalImageWithoutResolve(t *testing.T) {
+	rt := &fakeRuntime{localImages: map[string]string{"postgres:17": "postgres@sha256:local"}}
+	mgr := newOfflineManager(t, rt)
+
+	accepted, err := mgr.Submit(context.Background(), Request{
+		PrepareKind: "psql",
+		ImageID:     "postgres:17",
+		PsqlArgs:    []string{"-c", "select 1"},
+	})
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	status, ok := mgr.Get(accepted.JobID)
+	if !ok || status.Status != StatusSucceeded || status.Result == nil {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+	if len(rt.resolveCalls) != 0 {
+		t.Fatalf("expected no remote resolve in offline mode, got %v", rt.resolveCalls)
+	}
+	if len(rt.localCalls) != 1 || rt.localCalls[0] != "postgres:17" {
+		t.Fatalf("expected one local lookup, got %v", rt.localCalls)
+	}
+	if status.Result.ImageID != "postgres@sha256:local" {
+		t.Fatalf("expected the local digest in the result, got %q", status.Result.ImageID)
+	}
+}
+
+func TestOfflineMissingImageFailsJob(t *testing.T) {
+	rt := &fakeRuntime{}
+	mgr := newOfflineManager(t, rt)
+
+	accepted, err := mgr.Submit(context.Background(), Request{
+		PrepareKind: "psql",
+		ImageID:     "postgres:17",
+		PsqlArgs:    []string{"-c", "select 1"},
+	})
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	status, ok := mgr.Get(accepted.JobID)
+	if !ok || status.Status != StatusFailed || status.Error == nil || status.Error.Code != ErrorCodeImageNotFound {
+		t.Fatalf("expected image_not_found, got %+v", status)
+	}
+	if len(rt.resolveCalls) != 0 || len(rt.startCalls) != 0 {
+		t.Fatalf("expected no resolve or container, got resolve=%v start=%+v", rt.resolveCalls, rt.startCalls)
+	}
+}
+
+func TestOnlineResolvesWithoutLocalLookup(t *testing.T) {
+	rt := &fakeRuntime{localImages: map[string]string{"postgres:17": "postgres@sha256:local"}}
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{runtime: rt})
+
+	if _, err := mgr.Submit(context.Background(), Request{
+		PrepareKind: "psql",
+		ImageID:     "postgres:17",
+		PsqlArgs:    []string{"-c", "select 1"},
+	}); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	if len(rt.localCalls) != 0 || len(rt.resolveCalls) != 1 {
+		t.Fatalf("expected a remote resolve only, got local=%v resolve=%v", rt.localCalls, rt.resolveCalls)
+	}
+}
@@ -0,0 +1,109 @@
+package prepare
+
+import (
+	"regexp"
+	"strings"
+
+	engineRuntime "github.com/sqlrs/engine-local/internal/runtime"
+)
+
+// checkImagePolicy gates a base image against images.denied and
+// images.allowed before anything is pulled. Patterns are matched against the
+// normalized reference without its digest (for example
+// "docker.io/library/postgres:17"), so pinning a digest cannot bypass the
+// policy, and an untagged reference is matched as ":latest". A denied match wins; an empty allowlist allows every image that is
+// not denied.
+func (m *PrepareService) checkImagePolicy(imageID string) error {
+	ref := imagePolicyRef(imageID)
+	if ref == "" {
+		return nil
+	}
+	if pattern, ok := matchImagePattern(m.imagePatterns("images.denied"), ref); ok {
+		return PermissionDeniedError{Code: ErrorCodePermissionDenied, Message: "image is denied: " + imageID, Details: "matches images.denied pattern " + pattern}
+	}
+	allowed := m.imagePatterns("images.allowed")
+	if len(allowed) == 0 {
+		return nil
+	}
+	if _, ok := matchImagePattern(allowed, ref); ok {
+		return nil
+	}
+	return PermissionDeniedError{Code: ErrorCodePermissionDenied, Message: "image is not allowed: " + imageID, Details: "allowed: " + strings.Join(allowed, ", ")}
+}
+
+func (m *PrepareService) imagePatterns(path string) []string {
+	if m.config == nil {
+		return nil
+	}
+	value, err := m.config.Get(path, true)
+	if err != nil {
+		return nil
+	}
+	items, ok := value.([]any)
+	if !ok {
+		return nil
+	}
+	var patterns []string
+	for _, item := range items {
+		if pattern, ok := item.(string); ok && strings.TrimSpace(pattern) != "" {
+			patterns = append(patterns, strings.TrimSpace(pattern))
+		}
+	}
+	return patterns
+}
+
+// imagePolicyRef normalizes an image reference and drops its digest. A
+// reference with neither tag nor digest gets the implied ":latest", so a bare
+// "postgres" is matched exactly like "postgres:latest".
+func imagePolicyRef(imageID string) string {
+	ref := strings.TrimSpace(imageID)
+	digested := false
+	if at := strings.Index(ref, "@"); at >= 0 {
+		ref = ref[:at]
+		digested = true
+	}
+	ref = engineRuntime.NormalizeImageRef(ref)
+	if ref != "" && !digested && strings.LastIndex(ref, ":") <= strings.LastIndex(ref, "/") {
+		ref += ":latest"
+	}
+	return ref
+}
+
+// matchImagePattern reports the first pattern matching ref. "*" matches any
+// run of characters, including "/", and "?" matches one character; a pattern
+// without either must equal ref.
+func matchImagePattern(patterns []string, ref string) (string, bool) {
+	for _, pattern := range patterns {
+		if imagePatternRegexp(normalizeImagePattern(pattern)).MatchString(ref) {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+// normalizeImagePattern normalizes a pattern like a reference, so
+// "postgres:*" means "docker.io/library/postgres:*". A wildcard right after
+// the registry ("registry.internal/*", "docker.io/*") spans the whole
+// registry, and a pattern starting with a wildcard is used as-is.
+func normalizeImagePattern(pattern string) string {
+	if strings.ContainsAny(pattern[:1], "*?") {
+		return pattern
+	}
+	prefix, rest, found := strings.Cut(pattern, "/")
+	if !found || rest == "" || !strings.ContainsAny(rest[:1], "*?") {
+		return engineRuntime.NormalizeImageRef(pattern)
+	}
+	if prefix == "docker.io" || prefix == "index.docker.io" {
+		return "docker.io/" + rest
+	}
+	// A placeholder repository normalizes the prefix without adding
+	// "library/" in front of the wildcard.
+	return strings.TrimSuffix(engineRuntime.NormalizeImageRef(prefix+"/_"), "_") + rest
+}
+
+func imagePatternRegexp(pattern string) *regexp.Regexp {
+	quoted := regexp.QuoteMeta(pattern)
+	quoted = strings.ReplaceAll(quoted, `\*`, ".*")
+	quoted = strings.ReplaceAll(quoted, `\?`, ".")
+	return regexp.MustCompile("^" + quoted + "$")
+}
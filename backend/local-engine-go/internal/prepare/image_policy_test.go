@@ -0,0 +1,121 @@
+package prepare
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMatchImagePattern(t *testing.T) {
+	cases := []struct {
+		pattern string
+		ref     string
+		want    bool
+	}{
+		{"registry.internal/*", "registry.internal/team/pg:17", true},
+		{"registry.internal/*", "registry.internal.evil/pg:17", false},
+		{"postgres:*", "docker.io/library/postgres:17", true},
+		{"postgres:*", "docker.io/library/postgres-fork:17", false},
+		{"postgres:1?", "docker.io/library/postgres:16", true},
+		{"docker.io/library/postgres:17", "docker.io/library/postgres:17", true},
+		{"postgres", "docker.io/library/postgres:17", false},
+		{"docker.io/*", "docker.io/bitnami/postgresql:16", true},
+		{"bitnami/*", "docker.io/bitnami/postgresql:16", true},
+		{"*", "ghcr.io/org/pg:1", true},
+		{"*:latest", "ghcr.io/org/pg:latest", true},
+		{"ghcr.io/org/pg.?", "ghcr.io/org/pgx1", false},
+	}
+	for _, tc := range cases {
+		if _, got := matchImagePattern([]string{tc.pattern}, tc.ref); got != tc.want {
+			t.Fatalf("matchImagePattern(%q, %q) = %v, want %v", tc.pattern, tc.ref, got, tc.want)
+		}
+	}
+}
+
+func TestImagePolicyRef(t *testing.T) {
+	cases := map[string]string{
+		"postgres":                          "docker.io/library/postgres:latest",
+		"postgres:17":                       "docker.io/library/postgres:17",
+		"postgres@sha256:abc":               "docker.io/library/postgres",
+		"postgres:17@sha256:abc":            "docker.io/library/postgres:17",
+		"registry.internal:5000/team/pg":    "registry.internal:5000/team/pg:latest",
+		"registry.internal:5000/team/pg:16": "registry.internal:5000/team/pg:16",
+	}
+	for image, want := range cases {
+		if got := imagePolicyRef(image); got != want {
+			t.Fatalf("imagePolicyRef(%q) = %q, want %q", image, got, want)
+		}
+	}
+}
+
+func TestCheckImagePolicy(t *testing.T) {
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{})
+	if err := mgr.checkImagePolicy("anything:1"); err != nil {
+		t.Fatalf("expected empty policy to allow all images, got %v", err)
+	}
+
+	mgr = newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{
+		config: &fakeConfigStore{values: map[string]any{
+			"images.allowed": []any{"registry.internal/*", "postgres:*"},
+			"images.denied":  []any{"registry.internal/legacy/*"},
+		}},
+	})
+	for _, image := range []string{"registry.internal/team/pg:17", "postgres:17", "postgres:17@sha256:abc"} {
+		if err := mgr.checkImagePolicy(image); err != nil {
+			t.Fatalf("expected %s to be allowed, got %v", image, err)
+		}
+	}
+	for _, image := range []string{"ghcr.io/org/pg:17", "mysql:8", "registry.internal/legacy/pg:9"} {
+		var denied PermissionDeniedError
+		if err := mgr.checkImagePolicy(image); !errors.As(err, &denied) || denied.Code != "permission_denied" {
+			t.Fatalf("expected %s to be denied, got %v", image, err)
+		}
+	}
+}
+
+func TestCheckImagePolicyDenyWinsOverAllow(t *testing.T) {
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{
+		config: &fakeConfigStore{values: map[string]any{
+			"images.allowed": []any{"postgres:*"},
+			"images.denied":  []any{"postgres:9*"},
+		}},
+	})
+	err := mgr.checkImagePolicy("postgres:9.6")
+	var denied PermissionDeniedError
+	if !errors.As(err, &denied) || !strings.Contains(denied.Details, "images.denied") {
+		t.Fatalf("expected denylist to take precedence, got %v", err)
+	}
+	if err := mgr.checkImagePolicy("postgres:17"); err != nil {
+		t.Fatalf("expected postgres:17 to be allowed, got %v", err)
+	}
+
+	// A denylist alone keeps every other image allowed.
+	mgr = newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{
+		config: &fakeConfigStore{values: map[string]any{"images.denied": []any{"*:latest"}}},
+	})
+	for _, image := range []string{"postgres:latest", "postgres", "registry.internal:5000/team/pg"} {
+		if err := mgr.checkImagePolicy(image); err == nil {
+			t.Fatalf("expected %s to be denied as :latest", image)
+		}
+	}
+	if err := mgr.checkImagePolicy("postgres:17"); err != nil {
+		t.Fatalf("expected postgres:17 to be allowed, got %v", err)
+	}
+}
+
+func TestPrepareRequestRejectsDisallowedImage(t *testing.T) {
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{
+		config: &fakeConfigStore{values: map[string]any{"images.allowed": []any{"registry.internal/*"}}},
+	})
+	_, err := mgr.prepareRequest(Request{PrepareKind: "psql", ImageID: "postgres:17", PsqlArgs: []string{"-c", "select 1"}})
+	var denied PermissionDeniedError
+	if !errors.As(err, &denied) {
+		t.Fatalf("expected permission_denied, got %v", err)
+	}
+	if resp := ToErrorResponse(err); resp.Code != "permission_denied" {
+		t.Fatalf("expected permission_denied response, got %+v", resp)
+	}
+	if _, err := mgr.prepareRequest(Request{PrepareKind: "psql", ImageID: "registry.internal/pg:17", PsqlArgs: []string{"-c", "select 1"}}); err != nil {
+		t.Fatalf("expected allowed image to pass, got %v", err)
+	}
+}
@@ -0,0 +1,160 @@
+package prepare
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sqlrs/engine-local/internal/store"
+)
+
+func TestPrepareRequestNormalizesInstanceMode(t *testing.T) {
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{})
+	cases := map[string]string{
+		"":             instanceModeEphemeral,
+		"ephemeral":    instanceModeEphemeral,
+		" Persistent ": instanceModePersistent,
+		"persistent":   instanceModePersistent,
+	}
+	for input, want := range cases {
+		prepared, err := mgr.prepareRequest(Request{
+			PrepareKind:  "psql",
+			ImageID:      "image-1",
+			PsqlArgs:     []string{"-c", "select 1"},
+			InstanceMode: input,
+		})
+		if err != nil {
+			t.Fatalf("prepareRequest(%q): %v", input, err)
+		}
+		if got := prepared.instanceMode(); got != want {
+			t.Fatalf("instance mode for %q = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestPrepareRequestRejectsUnknownInstanceMode(t *testing.T) {
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{})
+	_, err := mgr.prepareRequest(Request{
+		PrepareKind:  "psql",
+		ImageID:      "image-1",
+		PsqlArgs:     []string{"-c", "select 1"},
+		InstanceMode: "forever",
+	})
+	var validation ValidationError
+	if !errors.As(err, &validation) || validation.Message != "instance_mode must be ephemeral or persistent" {
+		t.Fatalf("expected instance_mode validation error, got %v", err)
+	}
+}
+
+func TestPlanUsesRequestedInstanceMode(t *testing.T) {
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{runtime: &fakeRuntime{}})
+
+	if _, err := mgr.Submit(context.Background(), Request{
+		PrepareKind:  "psql",
+		ImageID:      "image-1@sha256:abc",
+		PsqlArgs:     []string{"-c", "select 1"},
+		PlanOnly:     true,
+		InstanceMode: "persistent",
+	}); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	tasks := mgr.ListTasks("job-1")
+	if len(tasks) == 0 {
+		t.Fatalf("expected tasks")
+	}
+	last := tasks[len(tasks)-1]
+	if last.Type != "prepare_instance" || last.InstanceMode != instanceModePersistent {
+		t.Fatalf("unexpected prepare_instance task: %+v", last)
+	}
+}
+
+func TestCreateInstanceKeepsPersistentRuntime(t *testing.T) {
+	for _, mode := range []string{instanceModeEphemeral, instanceModePersistent} {
+		t.Run(mode, func(t *testing.T) {
+			stateRoot := filepath.Join(t.TempDir(), "state-store")
+			st := &fakeStore{
+				statesByID: map[string]store.StateEntry{
+					"state-1": {StateID: "state-1", ImageID: "image-1"},
+				},
+			}
+			runtime := &fakeRuntime{}
+			mgr := newManagerWithDeps(t, st, newQueueStore(t), &testDeps{runtime: runtime, stateRoot: stateRoot})
+			prepared, err := mgr.prepareRequest(Request{
+				PrepareKind:  "psql",
+				ImageID:      "image-1",
+				PsqlArgs:     []string{"-c", "select 1"},
+				InstanceMode: mode,
+			})
+			if err != nil {
+				t.Fatalf("prepareRequest: %v", err)
+			}
+			paths, err := resolveStatePaths(stateRoot, "image-1", "state-1", mgr.statefs)
+			if err != nil {
+				t.Fatalf("resolveStatePaths: %v", err)
+			}
+			if err := os.MkdirAll(paths.stateDir, 0o700); err != nil {
+				t.Fatalf("mkdir state dir: %v", err)
+			}
+			if err := os.WriteFile(filepath.Join(paths.stateDir, "PG_VERSION"), []byte("17"), 0o600); err != nil {
+				t.Fatalf("write PG_VERSION: %v", err)
+			}
+
+			if _, errResp := mgr.createInstance(context.Background(), "job-1", prepared, "state-1"); errResp != nil {
+				t.Fatalf("createInstance: %+v", errResp)
+			}
+			if len(st.instances) != 1 {
+				t.Fatalf("expected instance create, got %+v", st.instances)
+			}
+			if got := st.instances[0].Mode; got == nil || *got != mode {
+				t.Fatalf("expected stored instance mode %q, got %v", mode, got)
+			}
+			stopped := len(runtime.stopCalls) > 0
+			if mode == instanceModePersistent && stopped {
+				t.Fatalf("expected persistent container to keep running, got stops %+v", runtime.stopCalls)
+			}
+			if mode == instanceModeEphemeral && !stopped {
+				t.Fatalf("expected ephemeral container to be stopped")
+			}
+		})
+	}
+}
+
+func TestCreateInstancePersistentRespectsDirtyState(t *testing.T) {
+	stateRoot := filepath.Join(t.TempDir(), "state-store")
+	st := &fakeStore{
+		statesByID: map[string]store.StateEntry{
+			"state-1": {StateID: "state-1", ImageID: "image-1"},
+		},
+	}
+	runtime := &fakeRuntime{}
+	mgr := newManagerWithDeps(t, st, newQueueStore(t), &testDeps{runtime: runtime, stateRoot: stateRoot})
+	prepared, err := mgr.prepareRequest(Request{
+		PrepareKind:  "psql",
+		ImageID:      "image-1",
+		PsqlArgs:     []string{"-c", "select 1"},
+		InstanceMode: instanceModePersistent,
+	})
+	if err != nil {
+		t.Fatalf("prepareRequest: %v", err)
+	}
+	paths, err := resolveStatePaths(stateRoot, "image-1", "state-1", mgr.statefs)
+	if err != nil {
+		t.Fatalf("resolveStatePaths: %v", err)
+	}
+	if err := os.MkdirAll(paths.stateDir, 0o700); err != nil {
+		t.Fatalf("mkdir state dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(paths.stateDir, "postmaster.pid"), []byte("1"), 0o600); err != nil {
+		t.Fatalf("write postmaster.pid: %v", err)
+	}
+
+	_, errResp := mgr.createInstance(context.Background(), "job-1", prepared, "state-1")
+	if errResp == nil || errResp.Message != "state snapshot is dirty (postmaster.pid present)" {
+		t.Fatalf("expected dirty state error, got %+v", errResp)
+	}
+	if len(st.instances) != 0 || len(runtime.startCalls) != 0 {
+		t.Fatalf("expected no instance or container, got instances=%+v starts=%d", st.instances, len(runtime.startCalls))
+	}
+}
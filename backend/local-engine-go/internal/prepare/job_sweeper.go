@@ -0,0 +1,167 @@
+package prepare
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/sqlrs/engine-local/internal/prepare/queue"
+)
+
+const defaultJobSweepInterval = 10 * time.Minute
+
+// StartSweeper runs SweepExpiredJobs on a timer until ctx is done. The
+// interval and TTL are read from config on every pass, so changes apply
+// without a restart.
+func (m *PrepareService) StartSweeper(ctx context.Context) {
+	go func() {
+		timer := time.NewTimer(m.jobSweepInterval())
+		defer timer.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-timer.C:
+			}
+			m.SweepExpiredJobs(ctx)
+			timer.Reset(m.jobSweepInterval())
+		}
+	}()
+}
+
+// SweepExpiredJobs deletes terminal jobs that finished more than
+// orchestrator.jobs.ttl ago, together with their job dirs, and stdin
+// changelogs unused for as long. Jobs whose output states are still used by
+// instances are kept. It returns how many jobs were deleted; a zero or unset
+// TTL disables the sweep.
+func (m *PrepareService) SweepExpiredJobs(ctx context.Context) int {
+	ttl := m.jobTTL()
+	if ttl <= 0 || m.isDraining() {
+		return 0
+	}
+	// Retry requeues failed jobs; holding retryMu keeps a job from being
+	// retried and swept at the same time.
+	m.retryMu.Lock()
+	defer m.retryMu.Unlock()
+
+	jobs, err := m.queue.ListJobsByStatus(ctx, []string{StatusSucceeded, StatusFailed})
+	if err != nil {
+		m.logErrorJob("", "job sweep failed: %v", err)
+		return 0
+	}
+	cutoff := m.now().UTC().Add(-ttl)
+	deleted := 0
+	for _, job := range jobs {
+		if ctx.Err() != nil {
+			break
+		}
+		if !jobFinishedBefore(job, cutoff) || m.getRunner(job.JobID) != nil {
+			continue
+		}
+		referenced, err := m.jobOutputsReferenced(ctx, job)
+		if err != nil {
+			m.logErrorJob(job.JobID, "job sweep lookup failed: %v", err)
+			continue
+		}
+		if referenced {
+			continue
+		}
+		if err := m.queue.DeleteJob(ctx, job.JobID); err != nil {
+			m.logErrorJob(job.JobID, "job sweep delete failed: %v", err)
+			continue
+		}
+		if err := m.removeJobDir(jobNamespace(job), job.JobID); err != nil {
+			m.logErrorJob(job.JobID, "job sweep cleanup failed: %v", err)
+		}
+		m.logInfoJob(job.JobID, "ttl expired, deleted")
+		deleted++
+	}
+	m.sweepLiquibaseStdin(ctx, cutoff)
+	return deleted
+}
+
+// jobFinishedBefore reports whether job finished before cutoff. A job
+// without a parseable finished_at is never expired.
+func jobFinishedBefore(job queue.JobRecord, cutoff time.Time) bool {
+	finished := strings.TrimSpace(valueOrEmpty(job.FinishedAt))
+	if finished == "" {
+		return false
+	}
+	finishedAt, err := time.Parse(time.RFC3339Nano, finished)
+	if err != nil {
+		return false
+	}
+	return finishedAt.Before(cutoff)
+}
+
+// jobOutputsReferenced reports whether an instance still uses a state the
+// job produced: the result state or any task output state.
+func (m *PrepareService) jobOutputsReferenced(ctx context.Context, job queue.JobRecord) (bool, error) {
+	stateIDs := map[string]struct{}{}
+	if job.ResultJSON != nil {
+		var result Result
+		if err := json.Unmarshal([]byte(*job.ResultJSON), &result); err == nil && strings.TrimSpace(result.StateID) != "" {
+			stateIDs[strings.TrimSpace(result.StateID)] = struct{}{}
+		}
+	}
+	tasks, err := m.queue.ListTasks(ctx, job.JobID)
+	if err != nil {
+		return false, err
+	}
+	for _, task := range tasks {
+		if id := strings.TrimSpace(valueOrEmpty(task.OutputStateID)); id != "" {
+			stateIDs[id] = struct{}{}
+		}
+	}
+	for stateID := range stateIDs {
+		entry, ok, err := m.store.GetState(ctx, stateID)
+		if err != nil {
+			return false, err
+		}
+		if ok && entry.RefCount > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// jobTTL reads orchestrator.jobs.ttl; zero disables the sweep.
+func (m *PrepareService) jobTTL() time.Duration {
+	if m.config == nil {
+		return 0
+	}
+	value, err := m.config.Get("orchestrator.jobs.ttl", true)
+	if err != nil || value == nil {
+		return 0
+	}
+	raw, ok := value.(string)
+	if !ok {
+		return 0
+	}
+	ttl, err := time.ParseDuration(strings.TrimSpace(raw))
+	if err != nil || ttl < 0 {
+		return 0
+	}
+	return ttl
+}
+
+// jobSweepInterval reads orchestrator.jobs.sweepInterval.
+func (m *PrepareService) jobSweepInterval() time.Duration {
+	if m.config == nil {
+		return defaultJobSweepInterval
+	}
+	value, err := m.config.Get("orchestrator.jobs.sweepInterval", true)
+	if err != nil || value == nil {
+		return defaultJobSweepInterval
+	}
+	raw, ok := value.(string)
+	if !ok {
+		return defaultJobSweepInterval
+	}
+	interval, err := time.ParseDuration(strings.TrimSpace(raw))
+	if err != nil || interval <= 0 {
+		return defaultJobSweepInterval
+	}
+	return interval
+}
@@ -0,0 +1,122 @@
+package prepare
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sqlrs/engine-local/internal/prepare/queue"
+	"github.com/sqlrs/engine-local/internal/store"
+)
+
+func createSweepJob(t *testing.T, q queue.Store, jobID string, status string, finishedAt time.Time, outputStateID string) {
+	t.Helper()
+	finished := finishedAt.UTC().Format(time.RFC3339Nano)
+	if err := q.CreateJob(context.Background(), queue.JobRecord{
+		JobID:       jobID,
+		Status:      status,
+		PrepareKind: "psql",
+		ImageID:     "image-1",
+		CreatedAt:   finished,
+		FinishedAt:  &finished,
+	}); err != nil {
+		t.Fatalf("CreateJob: %v", err)
+	}
+	if outputStateID == "" {
+		return
+	}
+	if err := q.ReplaceTasks(context.Background(), jobID, []queue.TaskRecord{{
+		JobID:         jobID,
+		TaskID:        "execute-0",
+		Position:      0,
+		Type:          "state_execute",
+		Status:        StatusSucceeded,
+		OutputStateID: &outputStateID,
+	}}); err != nil {
+		t.Fatalf("ReplaceTasks: %v", err)
+	}
+}
+
+func TestSweepExpiredJobsHonorsTTLAndReferences(t *testing.T) {
+	q := newQueueStore(t)
+	st := &fakeStore{statesByID: map[string]store.StateEntry{
+		"state-used": {StateID: "state-used", RefCount: 1},
+		"state-free": {StateID: "state-free", RefCount: 0},
+	}}
+	mgr := newManagerWithDeps(t, st, q, &testDeps{config: &fakeConfigStore{values: map[string]any{
+		"orchestrator.jobs.ttl": "24h",
+	}}})
+	clock := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	mgr.now = func() time.Time { return clock }
+
+	createSweepJob(t, q, "job-old", StatusSucceeded, clock, "state-free")
+	createSweepJob(t, q, "job-failed", StatusFailed, clock, "")
+	createSweepJob(t, q, "job-referenced", StatusSucceeded, clock, "state-used")
+	createSweepJob(t, q, "job-recent", StatusSucceeded, clock.Add(12*time.Hour), "state-free")
+	jobDir := filepath.Join(mgr.stateStoreRoot, "jobs", "job-old")
+	if err := os.MkdirAll(jobDir, 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+
+	if deleted := mgr.SweepExpiredJobs(context.Background()); deleted != 0 {
+		t.Fatalf("expected nothing to expire yet, deleted %d", deleted)
+	}
+
+	clock = clock.Add(25 * time.Hour)
+	if deleted := mgr.SweepExpiredJobs(context.Background()); deleted != 2 {
+		t.Fatalf("expected two expired jobs, deleted %d", deleted)
+	}
+	for jobID, want := range map[string]bool{"job-old": false, "job-failed": false, "job-referenced": true, "job-recent": true} {
+		if _, ok, err := q.GetJob(context.Background(), jobID); err != nil || ok != want {
+			t.Fatalf("%s: expected present=%v, got %v err=%v", jobID, want, ok, err)
+		}
+	}
+	if _, err := os.Stat(jobDir); !os.IsNotExist(err) {
+		t.Fatalf("expected job dir to be removed, got %v", err)
+	}
+
+	clock = clock.Add(24 * time.Hour)
+	if deleted := mgr.SweepExpiredJobs(context.Background()); deleted != 1 {
+		t.Fatalf("expected the recent job to expire, deleted %d", deleted)
+	}
+	if _, ok, _ := q.GetJob(context.Background(), "job-referenced"); !ok {
+		t.Fatalf("expected job with a referenced state to survive")
+	}
+}
+
+func TestSweepExpiredJobsDisabledWithoutTTL(t *testing.T) {
+	q := newQueueStore(t)
+	mgr := newManagerWithDeps(t, &fakeStore{}, q, nil)
+	createSweepJob(t, q, "job-old", StatusSucceeded, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), "")
+
+	if deleted := mgr.SweepExpiredJobs(context.Background()); deleted != 0 {
+		t.Fatalf("expected no sweep without a ttl, deleted %d", deleted)
+	}
+	if mgr.jobSweepInterval() != defaultJobSweepInterval {
+		t.Fatalf("expected default sweep interval, got %s", mgr.jobSweepInterval())
+	}
+}
+
+func TestStartSweeperRunsOnInterval(t *testing.T) {
+	q := newQueueStore(t)
+	mgr := newManagerWithDeps(t, &fakeStore{}, q, &testDeps{config: &fakeConfigStore{values: map[string]any{
+		"orchestrator.jobs.ttl":           "1h",
+		"orchestrator.jobs.sweepInterval": "10ms",
+	}}})
+	createSweepJob(t, q, "job-old", StatusSucceeded, mgr.now().Add(-2*time.Hour), "")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mgr.StartSweeper(ctx)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, ok, _ := q.GetJob(context.Background(), "job-old"); !ok {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("expected the sweeper to delete the expired job")
+}
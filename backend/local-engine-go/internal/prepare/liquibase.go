@@ -55,10 +55,10 @@ func prepareLiquibaseArgs(args []string, cwd string, windowsMode bool, rewritePa
 		}
 
 		if isLiquibaseConnectionFlag(arg) {
-			return liquibasePrepared{}, ValidationError{Code: "invalid_argument", Message: "connection flags are not allowed", Details: arg}
+			return liquibasePrepared{}, ValidationError{Code: ErrorCodeInvalidArgument, Message: "connection flags are not allowed", Details: arg}
 		}
 		if isLiquibaseRuntimeFlag(arg) {
-			return liquibasePrepared{}, ValidationError{Code: "invalid_argument", Message: "runtime flags are not allowed", Details: arg}
+			return liquibasePrepared{}, ValidationError{Code: ErrorCodeInvalidArgument, Message: "runtime flags are not allowed", Details: arg}
 		}
 
 		if handled, err := handleLiquibasePathFlag(args, &i, cwd, windowsMode, rewritePaths, &preArgs, &mounts, &mountIndex, mounted); err != nil {
@@ -84,10 +84,10 @@ func prepareLiquibaseArgs(args []string, cwd string, windowsMode bool, rewritePa
 	}
 
 	if strings.TrimSpace(command) == "" {
-		return liquibasePrepared{}, ValidationError{Code: "invalid_argument", Message: "lb command is required"}
+		return liquibasePrepared{}, ValidationError{Code: ErrorCodeInvalidArgument, Message: "lb command is required"}
 	}
 	if !strings.HasPrefix(strings.ToLower(command), "update") {
-		return liquibasePrepared{}, ValidationError{Code: "invalid_argument", Message: "unsupported lb command", Details: command}
+		return liquibasePrepared{}, ValidationError{Code: ErrorCodeInvalidArgument, Message: "unsupported lb command", Details: command}
 	}
 
 	if rewritePaths && !windowsMode && len(mounts) == 0 && strings.TrimSpace(cwd) != "" {
@@ -138,10 +138,13 @@ func handleLiquibasePathFlag(args []string, index *int, cwd string, windowsMode
 	switch {
 	case arg == "--changelog-file" || arg == "--defaults-file" || arg == "--searchPath" || arg == "--search-path":
 		if *index+1 >= len(args) {
-			return true, ValidationError{Code: "invalid_argument", Message: "missing value for flag", Details: arg}
+			return true, ValidationError{Code: ErrorCodeInvalidArgument, Message: "missing value for flag", Details: arg}
 		}
 		value := args[*index+1]
 		if windowsMode {
+			if err := checkLiquibaseWindowsPathValue(arg, value); err != nil {
+				return true, err
+			}
 			flag := arg
 			if flag == "--searchPath" {
 				flag = "--searchPath"
@@ -170,6 +173,9 @@ func handleLiquibasePathFlag(args []string, index *int, cwd string, windowsMode
 	case strings.HasPrefix(arg, "--changelog-file="):
 		value := strings.TrimPrefix(arg, "--changelog-file=")
 		if windowsMode {
+			if err := checkLiquibaseWindowsPathValue("--changelog-file", value); err != nil {
+				return true, err
+			}
 			*normalized = append(*normalized, arg)
 			return true, nil
 		}
@@ -182,6 +188,9 @@ func handleLiquibasePathFlag(args []string, index *int, cwd string, windowsMode
 	case strings.HasPrefix(arg, "--defaults-file="):
 		value := strings.TrimPrefix(arg, "--defaults-file=")
 		if windowsMode {
+			if err := checkLiquibaseWindowsPathValue("--defaults-file", value); err != nil {
+				return true, err
+			}
 			*normalized = append(*normalized, arg)
 			return true, nil
 		}
@@ -194,6 +203,9 @@ func handleLiquibasePathFlag(args []string, index *int, cwd string, windowsMode
 	case strings.HasPrefix(arg, "--searchPath="):
 		value := strings.TrimPrefix(arg, "--searchPath=")
 		if windowsMode {
+			if err := checkLiquibaseWindowsPathValue("--searchPath", value); err != nil {
+				return true, err
+			}
 			*normalized = append(*normalized, "--searchPath="+value)
 			return true, nil
 		}
@@ -206,6 +218,9 @@ func handleLiquibasePathFlag(args []string, index *int, cwd string, windowsMode
 	case strings.HasPrefix(arg, "--search-path="):
 		value := strings.TrimPrefix(arg, "--search-path=")
 		if windowsMode {
+			if err := checkLiquibaseWindowsPathValue("--searchPath", value); err != nil {
+				return true, err
+			}
 			*normalized = append(*normalized, "--searchPath="+value)
 			return true, nil
 		}
@@ -230,14 +245,14 @@ func normalizeLiquibasePathValue(flag string, value string, cwd string, rewriteP
 func normalizeLiquibaseHostPathValue(flag string, value string, cwd string) (string, error) {
 	if flag == "--searchPath" || flag == "--search-path" {
 		if strings.TrimSpace(value) == "" {
-			return "", ValidationError{Code: "invalid_argument", Message: "searchPath is empty"}
+			return "", ValidationError{Code: ErrorCodeInvalidArgument, Message: "searchPath is empty"}
 		}
 		parts := strings.Split(value, ",")
 		out := make([]string, 0, len(parts))
 		for _, part := range parts {
 			item := strings.TrimSpace(part)
 			if item == "" {
-				return "", ValidationError{Code: "invalid_argument", Message: "searchPath is empty"}
+				return "", ValidationError{Code: ErrorCodeInvalidArgument, Message: "searchPath is empty"}
 			}
 			rewritten, err := normalizeHostPath(item, cwd, "searchPath path does not exist")
 			if err != nil {
@@ -252,7 +267,7 @@ func normalizeLiquibaseHostPathValue(flag string, value string, cwd string) (str
 
 func normalizeHostPath(value string, cwd string, notFoundMessage string) (string, error) {
 	if strings.TrimSpace(value) == "" {
-		return "", ValidationError{Code: "invalid_argument", Message: "path is empty"}
+		return "", ValidationError{Code: ErrorCodeInvalidArgument, Message: "path is empty"}
 	}
 	if looksLikeRemoteRef(value) {
 		return value, nil
@@ -260,12 +275,12 @@ func normalizeHostPath(value string, cwd string, notFoundMessage string) (string
 	path := value
 	if !filepath.IsAbs(path) {
 		if strings.TrimSpace(cwd) == "" {
-			return "", ValidationError{Code: "invalid_argument", Message: "relative path requires working directory"}
+			return "", ValidationError{Code: ErrorCodeInvalidArgument, Message: "relative path requires working directory"}
 		}
 		path = filepath.Join(cwd, path)
 	}
 	if _, err := os.Stat(path); err != nil {
-		return "", ValidationError{Code: "invalid_argument", Message: notFoundMessage, Details: path}
+		return "", ValidationError{Code: ErrorCodeInvalidArgument, Message: notFoundMessage, Details: path}
 	}
 	return filepath.Clean(path), nil
 }
@@ -273,14 +288,14 @@ func normalizeHostPath(value string, cwd string, notFoundMessage string) (string
 func rewriteLiquibasePathValue(flag string, value string, cwd string, mounts *[]runtime.Mount, mountIndex *int, mounted map[string]string) (string, error) {
 	if flag == "--searchPath" || flag == "--search-path" {
 		if strings.TrimSpace(value) == "" {
-			return "", ValidationError{Code: "invalid_argument", Message: "searchPath is empty"}
+			return "", ValidationError{Code: ErrorCodeInvalidArgument, Message: "searchPath is empty"}
 		}
 		parts := strings.Split(value, ",")
 		out := make([]string, 0, len(parts))
 		for _, part := range parts {
 			item := strings.TrimSpace(part)
 			if item == "" {
-				return "", ValidationError{Code: "invalid_argument", Message: "searchPath is empty"}
+				return "", ValidationError{Code: ErrorCodeInvalidArgument, Message: "searchPath is empty"}
 			}
 			rewritten, err := rewriteSinglePath(item, cwd, mounts, mountIndex, mounted, "searchPath path does not exist")
 			if err != nil {
@@ -295,7 +310,7 @@ func rewriteLiquibasePathValue(flag string, value string, cwd string, mounts *[]
 
 func rewriteSinglePath(value string, cwd string, mounts *[]runtime.Mount, mountIndex *int, mounted map[string]string, notFoundMessage string) (string, error) {
 	if strings.TrimSpace(value) == "" {
-		return "", ValidationError{Code: "invalid_argument", Message: "path is empty"}
+		return "", ValidationError{Code: ErrorCodeInvalidArgument, Message: "path is empty"}
 	}
 	if looksLikeRemoteRef(value) {
 		return value, nil
@@ -303,12 +318,12 @@ func rewriteSinglePath(value string, cwd string, mounts *[]runtime.Mount, mountI
 	path := value
 	if !filepath.IsAbs(path) {
 		if strings.TrimSpace(cwd) == "" {
-			return "", ValidationError{Code: "invalid_argument", Message: "relative path requires working directory"}
+			return "", ValidationError{Code: ErrorCodeInvalidArgument, Message: "relative path requires working directory"}
 		}
 		path = filepath.Join(cwd, path)
 	}
 	if _, err := os.Stat(path); err != nil {
-		return "", ValidationError{Code: "invalid_argument", Message: notFoundMessage, Details: path}
+		return "", ValidationError{Code: ErrorCodeInvalidArgument, Message: notFoundMessage, Details: path}
 	}
 	if mapped, ok := mounted[path]; ok {
 		return mapped, nil
@@ -335,7 +350,7 @@ func collectLiquibasePaths(args []string, cwd string) ([]string, []string, error
 		switch {
 		case arg == "--changelog-file" || arg == "--defaults-file" || arg == "--searchPath" || arg == "--search-path":
 			if i+1 >= len(args) {
-				return nil, nil, ValidationError{Code: "invalid_argument", Message: "missing value for flag", Details: arg}
+				return nil, nil, ValidationError{Code: ErrorCodeInvalidArgument, Message: "missing value for flag", Details: arg}
 			}
 			value := strings.TrimSpace(args[i+1])
 			i++
@@ -383,7 +398,7 @@ func collectLiquibasePaths(args []string, cwd string) ([]string, []string, error
 
 func normalizeLiquibasePathValues(flag string, value string, cwd string) ([]string, error) {
 	if strings.TrimSpace(value) == "" {
-		return nil, ValidationError{Code: "invalid_argument", Message: "path is empty", Details: flag}
+		return nil, ValidationError{Code: ErrorCodeInvalidArgument, Message: "path is empty", Details: flag}
 	}
 	if isSearchPathFlag(flag) {
 		parts := strings.Split(value, ",")
@@ -391,7 +406,7 @@ func normalizeLiquibasePathValues(flag string, value string, cwd string) ([]stri
 		for _, part := range parts {
 			item := strings.TrimSpace(part)
 			if item == "" {
-				return nil, ValidationError{Code: "invalid_argument", Message: "searchPath is empty"}
+				return nil, ValidationError{Code: ErrorCodeInvalidArgument, Message: "searchPath is empty"}
 			}
 			if looksLikeRemoteRef(item) {
 				continue
@@ -432,11 +447,20 @@ func looksLikeRemoteRef(value string) bool {
 	return strings.Contains(lower, "://") || strings.HasPrefix(lower, "classpath:")
 }
 
+// parseLiquibaseUpdateSQL splits updateSQL output into changesets at the
+// "-- Changeset path::id::author" markers Liquibase writes. Output before the
+// first marker (the script header, lock statements) is ignored, CRLF line
+// endings are accepted, and Liquibase log lines mixed into the output are
+// dropped. A marker only counts outside string literals, quoted identifiers,
+// dollar-quoted bodies and block comments, so SQL that merely contains the
+// marker text cannot split a changeset; output that ends inside one of those
+// is rejected rather than guessed at.
 func parseLiquibaseUpdateSQL(output string) ([]LiquibaseChangeset, error) {
-	lines := strings.Split(output, "\n")
+	lines := strings.Split(strings.ReplaceAll(output, "\r\n", "\n"), "\n")
 	var changesets []LiquibaseChangeset
 	var current *LiquibaseChangeset
 	var sqlLines []string
+	var scanner sqlScanner
 
 	flush := func() error {
 		if current == nil {
@@ -452,28 +476,38 @@ func parseLiquibaseUpdateSQL(output string) ([]LiquibaseChangeset, error) {
 	}
 
 	for _, line := range lines {
-		if meta, ok := parseChangesetHeader(line); ok {
-			if err := flush(); err != nil {
-				return nil, err
+		line = strings.TrimSuffix(line, "\r")
+		if scanner.topLevel() {
+			if meta, ok := parseChangesetHeader(line); ok {
+				if err := flush(); err != nil {
+					return nil, err
+				}
+				current = &LiquibaseChangeset{Path: meta.path, ID: meta.id, Author: meta.author}
+				continue
 			}
-			current = &LiquibaseChangeset{Path: meta.path, ID: meta.id, Author: meta.author}
-			continue
-		}
-		if current != nil {
-			if checksum, ok := parseChangesetChecksum(line); ok && checksum != "" {
-				current.Checksum = checksum
+			if isLiquibaseLogLine(line) {
+				continue
+			}
+			if current != nil {
+				if checksum, ok := parseChangesetChecksum(line); ok && checksum != "" {
+					current.Checksum = checksum
+				}
 			}
 		}
 		if current != nil {
 			sqlLines = append(sqlLines, line)
+			scanner.scanLine(line)
 		}
 	}
 
+	if !scanner.topLevel() {
+		return nil, ValidationError{Code: ErrorCodeInvalidArgument, Message: "unterminated literal in changeset", Details: current.Path + "::" + current.ID + "::" + current.Author}
+	}
 	if err := flush(); err != nil {
 		return nil, err
 	}
 	if len(changesets) == 0 && strings.TrimSpace(output) != "" {
-		return nil, ValidationError{Code: "invalid_argument", Message: "missing changeset"}
+		return nil, ValidationError{Code: ErrorCodeInvalidArgument, Message: "missing changeset"}
 	}
 	return changesets, nil
 }
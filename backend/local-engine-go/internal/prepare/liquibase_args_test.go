@@ -1,8 +1,11 @@
 package prepare
 
 import (
+	"context"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
 )
@@ -211,6 +214,49 @@ func TestPrepareLiquibaseArgsWindowsModeCollectsSearchPathValueErrors(t *testing
 	expectValidationError(t, err, "path is empty")
 }
 
+func TestPrepareLiquibaseArgsWindowsModeRejectsMissingChangelog(t *testing.T) {
+	root := t.TempDir()
+	fakeWslpathUnix(t, root)
+
+	_, err := prepareLiquibaseArgsContainer([]string{"update", "--changelog-file", "C:\\work\\missing.xml"}, "", true)
+	expectValidationError(t, err, "path does not exist")
+	if verr := err.(ValidationError); verr.Details != "C:\\work\\missing.xml" {
+		t.Fatalf("expected missing changelog in details, got %q", verr.Details)
+	}
+
+	if err := os.WriteFile(filepath.Join(root, "changelog.xml"), []byte("<databaseChangeLog/>"), 0o600); err != nil {
+		t.Fatalf("write changelog: %v", err)
+	}
+	if _, err := prepareLiquibaseArgsContainer([]string{"update", "--changelog-file=C:\\work\\changelog.xml"}, "", true); err != nil {
+		t.Fatalf("prepareLiquibaseArgs: %v", err)
+	}
+}
+
+func TestPrepareLiquibaseArgsWindowsModeRejectsMissingSearchPath(t *testing.T) {
+	root := t.TempDir()
+	fakeWslpathUnix(t, root)
+	if err := os.MkdirAll(filepath.Join(root, "db"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+
+	_, err := prepareLiquibaseArgsContainer([]string{"update", "--search-path=C:\\work\\db,C:\\work\\missing"}, "", true)
+	expectValidationError(t, err, "searchPath path does not exist")
+	if verr := err.(ValidationError); verr.Details != "C:\\work\\missing" {
+		t.Fatalf("expected missing search path in details, got %q", verr.Details)
+	}
+
+	missing := filepath.Join(root, "absent")
+	_, err = prepareLiquibaseArgsContainer([]string{"update", "--searchPath", missing}, "", true)
+	expectValidationError(t, err, "searchPath path does not exist")
+}
+
+func TestPrepareLiquibaseArgsWindowsModeSkipsUnmappedPaths(t *testing.T) {
+	setWSLForTest(t, false)
+	if _, err := prepareLiquibaseArgsContainer([]string{"update", "--changelog-file", "C:\\work\\missing.xml", "--searchPath", "db"}, "", true); err != nil {
+		t.Fatalf("prepareLiquibaseArgs: %v", err)
+	}
+}
+
 func TestPrepareLiquibaseArgsKeepsPreAndPostCommandFlags(t *testing.T) {
 	out, err := prepareLiquibaseArgsContainer([]string{"--log-level=info", "update", "--label-filter=dev"}, t.TempDir(), false)
 	if err != nil {
@@ -270,3 +316,20 @@ func TestPrepareLiquibaseArgsHostModeKeepsPaths(t *testing.T) {
 func prepareLiquibaseArgsContainer(args []string, cwd string, windowsMode bool) (liquibasePrepared, error) {
 	return prepareLiquibaseArgs(args, cwd, windowsMode, true)
 }
+
+// fakeWslpathUnix maps Windows paths under C:\work to root, as wslpath -u
+// would for a /mnt/c view.
+func fakeWslpathUnix(t *testing.T, root string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("wslpath fake requires a POSIX shell")
+	}
+	setWSLForTest(t, true)
+	prev := execCommand
+	t.Cleanup(func() { execCommand = prev })
+	execCommand = func(ctx context.Context, name string, args ...string) *exec.Cmd {
+		path := args[len(args)-1]
+		rel := strings.ReplaceAll(strings.TrimPrefix(path, "C:\\work"), "\\", "/")
+		return exec.CommandContext(ctx, "echo", filepath.Join(root, rel))
+	}
+}
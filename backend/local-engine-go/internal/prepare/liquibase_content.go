@@ -94,5 +94,5 @@ func ensureLiquibaseContentLock(prepared preparedRequest, changesetPath string)
 	if err == nil {
 		return lock, nil
 	}
-	return nil, errorResponse("invalid_argument", "cannot lock liquibase inputs", fmt.Sprintf("%v", err))
+	return nil, errorResponse(ErrorCodeInvalidArgument, "cannot lock liquibase inputs", fmt.Sprintf("%v", err))
 }
@@ -189,7 +189,7 @@ func TestExecuteLiquibaseStepRunError(t *testing.T) {
 	rt := &jobRuntime{instance: engineRuntime.Instance{ID: "container-1", Host: "127.0.0.1", Port: 5432}}
 
 	errResp := mgr.executeLiquibaseStep(context.Background(), "job-1", prepared, rt, taskState{})
-	if errResp == nil || !strings.Contains(errResp.Message, "liquibase execution failed") {
+	if errResp == nil || errResp.Code != "migration_failed" || !strings.Contains(errResp.Message, "liquibase execution failed") {
 		t.Fatalf("expected execution error, got %+v", errResp)
 	}
 	if len(liquibase.runs) != 1 || liquibase.runs[0].Env["JAVA_HOME"] != "C:\\Java" {
@@ -198,7 +198,7 @@ func TestExecuteLiquibaseStepRunError(t *testing.T) {
 }
 
 func TestPrependLiquibaseConnectionArgs(t *testing.T) {
-	out := prependLiquibaseConnectionArgs([]string{"update"}, engineRuntime.Instance{Host: "host", Port: 5432}, false)
+	out := prependLiquibaseConnectionArgs([]string{"update"}, engineRuntime.Instance{Host: "host", Port: 5432}, false, "")
 	if len(out) < 3 {
 		t.Fatalf("expected args, got %+v", out)
 	}
@@ -208,10 +208,14 @@ func TestPrependLiquibaseConnectionArgs(t *testing.T) {
 	if out[2] != "update" {
 		t.Fatalf("expected update arg, got %+v", out)
 	}
-	empty := prependLiquibaseConnectionArgs(nil, engineRuntime.Instance{}, false)
+	empty := prependLiquibaseConnectionArgs(nil, engineRuntime.Instance{}, false, "")
 	if len(empty) != 2 || !strings.HasPrefix(empty[0], "--url=") {
 		t.Fatalf("expected connection args only, got %+v", empty)
 	}
+	custom := prependLiquibaseConnectionArgs(nil, engineRuntime.Instance{}, false, "postgres")
+	if custom[1] != "--username=postgres" {
+		t.Fatalf("expected configured superuser, got %+v", custom)
+	}
 }
 
 func TestPrependLiquibaseConnectionArgsWSLWindowsModeUsesWSLIPv4(t *testing.T) {
@@ -237,7 +241,7 @@ func TestPrependLiquibaseConnectionArgsWSLWindowsModeUsesWSLIPv4(t *testing.T) {
 	}
 	t.Cleanup(func() { ifaceAddrs = prevAddrs })
 
-	out := prependLiquibaseConnectionArgs([]string{"update"}, engineRuntime.Instance{Host: "127.0.0.1", Port: 5432}, true)
+	out := prependLiquibaseConnectionArgs([]string{"update"}, engineRuntime.Instance{Host: "127.0.0.1", Port: 5432}, true, "")
 	if len(out) < 1 || !strings.Contains(out[0], "jdbc:postgresql://172.28.221.15:5432/postgres") {
 		t.Fatalf("expected WSL ipv4 in URL, got %+v", out)
 	}
@@ -21,8 +21,13 @@ func (r hostLiquibaseRunner) Run(ctx context.Context, req LiquibaseRunRequest) (
 	if execPath == "" {
 		execPath = "liquibase"
 	}
-	args := req.Args
-	mode := normalizeExecMode(req.ExecMode)
+	return runHostTool(ctx, execPath, req.ExecMode, req.Args, req.Env, req.WorkDir)
+}
+
+// runHostTool runs a migration tool on the host, wrapping .bat/.cmd launchers
+// in cmd.exe when the exec mode asks for it.
+func runHostTool(ctx context.Context, execPath string, execMode string, args []string, env map[string]string, workDir string) (string, error) {
+	mode := normalizeExecMode(execMode)
 	useWindows := shouldUseWindowsBat(execPath, mode)
 	sink := engineRuntime.LogSinkFromContext(ctx)
 	if sink != nil {
@@ -35,7 +40,7 @@ func (r hostLiquibaseRunner) Run(ctx context.Context, req LiquibaseRunRequest) (
 		sink(fmt.Sprintf("exec: mode=%s", mode))
 	}
 	if useWindows {
-		workDir := strings.TrimSpace(req.WorkDir)
+		workDir := strings.TrimSpace(workDir)
 		if sink != nil {
 			if workDir == "" {
 				sink(fmt.Sprintf("exec: cmd.exe /c call %q ...", execPath))
@@ -51,11 +56,11 @@ func (r hostLiquibaseRunner) Run(ctx context.Context, req LiquibaseRunRequest) (
 		execPath = "cmd.exe"
 	}
 	cmd := execCommand(ctx, execPath, args...)
-	if len(req.Env) > 0 {
-		cmd.Env = append(os.Environ(), formatEnv(req.Env)...)
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), formatEnv(env)...)
 	}
-	if !useWindows && strings.TrimSpace(req.WorkDir) != "" {
-		cmd.Dir = req.WorkDir
+	if !useWindows && strings.TrimSpace(workDir) != "" {
+		cmd.Dir = workDir
 	}
 	return runCommandWithSink(ctx, cmd)
 }
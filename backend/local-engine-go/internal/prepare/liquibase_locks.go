@@ -0,0 +1,144 @@
+package prepare
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	engineRuntime "github.com/sqlrs/engine-local/internal/runtime"
+)
+
+// liquibaseLockMarker prefixes each held-lock row of the lock query, so the
+// check never mistakes other psql output for a lock.
+const liquibaseLockMarker = "sqlrs-lb-lock|"
+
+// liquibaseLockQuery lists the held rows of liquibase's default lock table.
+// It fails when the table does not exist, which means no lock is held.
+const liquibaseLockQuery = "select '" + liquibaseLockMarker + "' || coalesce(lockedby, '') || '|' || coalesce(lockgranted::text, '') from databasechangeloglock where locked"
+
+// ensureLiquibaseLockReleased checks, once per instance, whether the state it
+// was started from still holds DATABASECHANGELOGLOCK, typically left behind by
+// a liquibase run that was killed. Liquibase would wait for such a lock and
+// then fail, and nothing else can connect to a job instance, so a held lock
+// is always stale. With prepare.lb.autoReleaseLocks it is released with
+// liquibase releaseLocks; otherwise the job fails at once with a hint.
+func (e *taskExecutor) ensureLiquibaseLockReleased(ctx context.Context, jobID string, prepared preparedRequest, rt *jobRuntime, inv liquibaseInvocation) *ErrorResponse {
+	m := e.m
+	if rt == nil || rt.liquibaseLockChecked || m.runtime == nil {
+		return nil
+	}
+	holder, held := e.liquibaseLockHolder(ctx, jobID, rt)
+	if !held {
+		rt.liquibaseLockChecked = true
+		return nil
+	}
+	if !m.liquibaseAutoReleaseLocks() {
+		return errorResponse(ErrorCodeMigrationFailed, "liquibase changelog lock is held",
+			fmt.Sprintf("DATABASECHANGELOGLOCK is held by %s; set prepare.lb.autoReleaseLocks to true to release it before liquibase runs", holder))
+	}
+	m.appendLog(jobID, fmt.Sprintf("liquibase: changelog lock held by %s, releasing", holder))
+	args := prependLiquibaseConnectionArgs(liquibaseReleaseLocksArgs(inv.args), rt.instance, inv.windowsMode, m.postgresSuperuser())
+	execLine := formatExecLine(inv.execPath, args)
+	m.appendLog(jobID, fmt.Sprintf("liquibase: exec %s", execLine))
+	m.logInfoJob(jobID, "liquibase releasing changelog lock held by %s", holder)
+	lbCtx := engineRuntime.WithLogSink(ctx, func(line string) {
+		m.appendLog(jobID, "liquibase: "+inv.secrets.redact(line))
+	})
+	output, err := m.liquibase.Run(lbCtx, LiquibaseRunRequest{
+		ExecPath: inv.execPath,
+		ExecMode: inv.execMode,
+		Args:     args,
+		Env:      inv.env,
+		WorkDir:  inv.workDir,
+		Mounts:   prepared.liquibaseMounts,
+		Network:  prepared.request.Network,
+		DNS:      prepared.request.DNS,
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return errorResponse(ErrorCodeCancelled, "task cancelled", "")
+		}
+		details := strings.TrimSpace(inv.secrets.redact(output))
+		if details == "" {
+			details = inv.secrets.redact(err.Error())
+		}
+		return errorResponse(ErrorCodeMigrationFailed, "liquibase releaseLocks failed", details)
+	}
+	rt.liquibaseLockChecked = true
+	return nil
+}
+
+// liquibaseLockHolder reports who holds the instance's liquibase lock. A
+// failed query (for example a database liquibase never ran against) counts
+// as no lock.
+func (e *taskExecutor) liquibaseLockHolder(ctx context.Context, jobID string, rt *jobRuntime) (string, bool) {
+	m := e.m
+	output, err := m.runtime.Exec(ctx, rt.instance.ID, engineRuntime.ExecRequest{
+		User: "postgres",
+		Args: []string{
+			"psql", "-X", "-q", "-t", "-A",
+			"-v", "ON_ERROR_STOP=1",
+			"-h", "127.0.0.1", "-p", "5432",
+			"-U", m.postgresSuperuser(), "-d", "postgres",
+			"-c", liquibaseLockQuery,
+		},
+	})
+	if err != nil {
+		m.logDebugJob(jobID, "liquibase lock check skipped: %v", err)
+		return "", false
+	}
+	for _, line := range strings.Split(output, "\n") {
+		row, ok := strings.CutPrefix(strings.TrimSpace(line), liquibaseLockMarker)
+		if !ok {
+			continue
+		}
+		lockedBy, granted, _ := strings.Cut(row, "|")
+		if lockedBy == "" {
+			lockedBy = "an unknown client"
+		}
+		if granted != "" {
+			return lockedBy + " since " + granted, true
+		}
+		return lockedBy, true
+	}
+	return "", false
+}
+
+// liquibaseReleaseLocksArgs keeps the global options in front of the user's
+// command and replaces the command and its arguments with releaseLocks.
+func liquibaseReleaseLocksArgs(args []string) []string {
+	out := make([]string, 0, len(args)+1)
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" || !strings.HasPrefix(arg, "-") {
+			break
+		}
+		takesValue := isLiquibaseFlagWithValue(arg) && !strings.Contains(arg, "=")
+		if arg == "--changelog-file" || strings.HasPrefix(arg, "--changelog-file=") {
+			// releaseLocks takes no changelog.
+			if takesValue {
+				i++
+			}
+			continue
+		}
+		out = append(out, arg)
+		if takesValue && i+1 < len(args) {
+			i++
+			out = append(out, args[i])
+		}
+	}
+	return append(out, "releaseLocks")
+}
+
+// liquibaseAutoReleaseLocks reads prepare.lb.autoReleaseLocks.
+func (m *PrepareService) liquibaseAutoReleaseLocks() bool {
+	if m.config == nil {
+		return false
+	}
+	value, err := m.config.Get("prepare.lb.autoReleaseLocks", true)
+	if err != nil {
+		return false
+	}
+	enabled, ok := value.(bool)
+	return ok && enabled
+}
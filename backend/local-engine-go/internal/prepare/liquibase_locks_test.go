@@ -0,0 +1,126 @@
+package prepare
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	engineRuntime "github.com/sqlrs/engine-local/internal/runtime"
+)
+
+func TestExecuteLiquibaseStepReleasesHeldLock(t *testing.T) {
+	rt := &fakeRuntime{execOutput: "sqlrs-lb-lock|ci-runner (10.0.0.5)|2026-10-01 12:00:00\n"}
+	liquibase := &fakeLiquibaseRunner{output: "ok"}
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{
+		runtime:   rt,
+		liquibase: liquibase,
+		config:    &fakeConfigStore{values: map[string]any{"prepare.lb.autoReleaseLocks": true}},
+	})
+	prepared := preparedRequest{
+		request:        Request{PrepareKind: "lb"},
+		normalizedArgs: []string{"--classpath", "/sqlrs/mnt/lib", "update", "--changelog-file", "/sqlrs/mnt/path1"},
+	}
+	jobRT := &jobRuntime{instance: engineRuntime.Instance{ID: "container-1", Host: "127.0.0.1", Port: 5432}}
+
+	if errResp := mgr.executeLiquibaseStep(context.Background(), "job-1", prepared, jobRT, taskState{}); errResp != nil {
+		t.Fatalf("executeLiquibaseStep: %+v", errResp)
+	}
+	if len(rt.execCalls) != 1 || !containsArg(rt.execCalls[0].Args, "psql") {
+		t.Fatalf("expected one lock check, got %+v", rt.execCalls)
+	}
+	if len(liquibase.runs) != 2 {
+		t.Fatalf("expected releaseLocks and update runs, got %+v", liquibase.runs)
+	}
+	release := liquibase.runs[0].Args
+	if !reflect.DeepEqual(release[2:], []string{"--classpath", "/sqlrs/mnt/lib", "releaseLocks"}) || !strings.HasPrefix(release[0], "--url=") {
+		t.Fatalf("unexpected releaseLocks args: %+v", release)
+	}
+	if !containsArg(liquibase.runs[1].Args, "update-count") {
+		t.Fatalf("expected the step to run after the release, got %+v", liquibase.runs[1].Args)
+	}
+
+	if errResp := mgr.executeLiquibaseStep(context.Background(), "job-1", prepared, jobRT, taskState{}); errResp != nil {
+		t.Fatalf("executeLiquibaseStep: %+v", errResp)
+	}
+	if len(rt.execCalls) != 1 || len(liquibase.runs) != 3 {
+		t.Fatalf("expected the lock to be checked once per instance, got exec=%d runs=%d", len(rt.execCalls), len(liquibase.runs))
+	}
+}
+
+func TestExecuteLiquibaseStepFailsOnHeldLockWithoutAutoRelease(t *testing.T) {
+	rt := &fakeRuntime{execOutput: "sqlrs-lb-lock|ci-runner (10.0.0.5)|\n"}
+	liquibase := &fakeLiquibaseRunner{output: "ok"}
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{runtime: rt, liquibase: liquibase})
+	prepared := preparedRequest{
+		request:        Request{PrepareKind: "lb"},
+		normalizedArgs: []string{"update", "--changelog-file", "/sqlrs/mnt/path1"},
+	}
+	jobRT := &jobRuntime{instance: engineRuntime.Instance{ID: "container-1", Host: "127.0.0.1", Port: 5432}}
+
+	errResp := mgr.executeLiquibaseStep(context.Background(), "job-1", prepared, jobRT, taskState{})
+	if errResp == nil || errResp.Code != ErrorCodeMigrationFailed || !strings.Contains(errResp.Details, "ci-runner (10.0.0.5)") || !strings.Contains(errResp.Details, "prepare.lb.autoReleaseLocks") {
+		t.Fatalf("expected a held lock error, got %+v", errResp)
+	}
+	if len(liquibase.runs) != 0 {
+		t.Fatalf("expected liquibase not to run, got %+v", liquibase.runs)
+	}
+}
+
+func TestRunLiquibaseUpdateSQLReleasesHeldLock(t *testing.T) {
+	rt := &fakeRuntime{execOutput: "sqlrs-lb-lock||\n"}
+	liquibase := &fakeLiquibaseRunner{output: "-- Changeset changelog.xml::1::dev\nCREATE TABLE t(id int);\n"}
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{
+		runtime:   rt,
+		liquibase: liquibase,
+		config:    &fakeConfigStore{values: map[string]any{"prepare.lb.autoReleaseLocks": true}},
+	})
+	prepared := preparedRequest{
+		request:        Request{PrepareKind: "lb"},
+		normalizedArgs: []string{"update", "--changelog-file", "/sqlrs/mnt/path1"},
+	}
+	jobRT := &jobRuntime{instance: engineRuntime.Instance{ID: "container-1", Host: "127.0.0.1", Port: 5432}}
+
+	if _, errResp := mgr.executor.runLiquibaseUpdateSQL(context.Background(), "job-1", prepared, jobRT); errResp != nil {
+		t.Fatalf("runLiquibaseUpdateSQL: %+v", errResp)
+	}
+	if len(liquibase.runs) != 2 || liquibase.runs[0].Args[len(liquibase.runs[0].Args)-1] != "releaseLocks" || !containsArg(liquibase.runs[1].Args, "updateSQL") {
+		t.Fatalf("expected releaseLocks before updateSQL, got %+v", liquibase.runs)
+	}
+}
+
+func TestLiquibaseLockCheckIgnoresMissingTable(t *testing.T) {
+	rt := &fakeRuntime{execErr: errors.New(`relation "databasechangeloglock" does not exist`)}
+	liquibase := &fakeLiquibaseRunner{output: "ok"}
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{runtime: rt, liquibase: liquibase})
+	prepared := preparedRequest{
+		request:        Request{PrepareKind: "lb"},
+		normalizedArgs: []string{"update", "--changelog-file", "/sqlrs/mnt/path1"},
+	}
+	jobRT := &jobRuntime{instance: engineRuntime.Instance{ID: "container-1", Host: "127.0.0.1", Port: 5432}}
+
+	if errResp := mgr.executeLiquibaseStep(context.Background(), "job-1", prepared, jobRT, taskState{}); errResp != nil {
+		t.Fatalf("executeLiquibaseStep: %+v", errResp)
+	}
+	if len(liquibase.runs) != 1 || !jobRT.liquibaseLockChecked {
+		t.Fatalf("expected a single update run, got %+v", liquibase.runs)
+	}
+}
+
+func TestLiquibaseReleaseLocksArgs(t *testing.T) {
+	cases := []struct {
+		args []string
+		want []string
+	}{
+		{args: nil, want: []string{"releaseLocks"}},
+		{args: []string{"update", "--changelog-file", "c.xml"}, want: []string{"releaseLocks"}},
+		{args: []string{"--changelog-file=c.xml", "--defaults-file", "lb.properties", "--log-level=info", "update"}, want: []string{"--defaults-file", "lb.properties", "--log-level=info", "releaseLocks"}},
+		{args: []string{"--changelog-file", "c.xml", "update"}, want: []string{"releaseLocks"}},
+	}
+	for _, tc := range cases {
+		if got := liquibaseReleaseLocksArgs(tc.args); !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("liquibaseReleaseLocksArgs(%v) = %v, want %v", tc.args, got, tc.want)
+		}
+	}
+}
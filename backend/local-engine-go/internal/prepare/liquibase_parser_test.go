@@ -1,6 +1,9 @@
 package prepare
 
 import (
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -70,3 +73,155 @@ func TestParseLiquibaseUpdateSQLEmptyOutput(t *testing.T) {
 		t.Fatalf("expected no changesets, got %d", len(sets))
 	}
 }
+
+// The files in testdata/liquibase-updatesql follow the updateSQL output of
+// the Liquibase versions they are named after, run against PostgreSQL.
+func readUpdateSQLFixture(t testing.TB, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "liquibase-updatesql", name))
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	return string(data)
+}
+
+func TestParseLiquibaseUpdateSQLCorpus(t *testing.T) {
+	cases := []struct {
+		file string
+		want []string
+	}{
+		{file: "4.9.1-two-changesets.sql", want: []string{
+			"db/changelog/001-users.xml::1::alice 8:7d1b8c3e5d4f0a9c2b6e1f3a4d5c6b7a",
+			"db/changelog/002-orders.xml::2::bob 8:2c4e6a8b0d1f3e5a7c9b1d3f5e7a9c0b",
+		}},
+		{file: "4.17.2-crlf.sql", want: []string{
+			"changelog.sql::create-accounts::carol 8:a1b2c3d4e5f60718293a4b5c6d7e8f90",
+			"changelog.sql::seed-accounts::carol 8:0f9e8d7c6b5a49382716a5b4c3d2e1f0",
+		}},
+		{file: "4.23.2-literals.sql", want: []string{
+			"db/functions.sql::audit-fn::dave 8:11112222333344445555666677778888",
+			"db/seed.sql::notes::dave 8:99990000aaaabbbbccccddddeeeeffff",
+		}},
+		{file: "4.29.2-console-noise.sql", want: []string{
+			"changelog.xml::1::erin 9:4f3e2d1c0b9a88776655443322110000",
+			"changelog.xml::2::erin 9:00112233445566778899aabbccddeeff",
+		}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.file, func(t *testing.T) {
+			sets, err := parseLiquibaseUpdateSQL(readUpdateSQLFixture(t, tc.file))
+			if err != nil {
+				t.Fatalf("parseLiquibaseUpdateSQL: %v", err)
+			}
+			var got []string
+			for _, cs := range sets {
+				got = append(got, cs.Path+"::"+cs.ID+"::"+cs.Author+" "+cs.Checksum)
+				if strings.Contains(cs.SQL, "\r") {
+					t.Fatalf("expected CRLF to be normalized in %s", cs.ID)
+				}
+				for _, noise := range []string{"Liquibase command", "INFO [", "UPDATE SUMMARY", "Starting Liquibase"} {
+					if strings.Contains(cs.SQL, noise) {
+						t.Fatalf("expected %q to be dropped from %s: %q", noise, cs.ID, cs.SQL)
+					}
+				}
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("expected changesets %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestParseLiquibaseUpdateSQLIgnoresMarkersInLiterals(t *testing.T) {
+	sets, err := parseLiquibaseUpdateSQL(readUpdateSQLFixture(t, "4.23.2-literals.sql"))
+	if err != nil {
+		t.Fatalf("parseLiquibaseUpdateSQL: %v", err)
+	}
+	if len(sets) != 2 {
+		t.Fatalf("expected 2 changesets, got %+v", sets)
+	}
+	for _, marker := range []string{"not-a-changeset", "inside-string", "inside-comment", "inside-escape-string"} {
+		if !strings.Contains(sets[0].SQL+sets[1].SQL, "fake.sql::"+marker+"::mallory") {
+			t.Fatalf("expected the %s marker to stay in the changeset SQL", marker)
+		}
+	}
+}
+
+func TestParseLiquibaseUpdateSQLKeepsFingerprints(t *testing.T) {
+	// Output that parsed correctly before CRLF and literal handling must keep
+	// its changeset SQL, and so its cache keys.
+	sets, err := parseLiquibaseUpdateSQL(readUpdateSQLFixture(t, "4.9.1-two-changesets.sql"))
+	if err != nil {
+		t.Fatalf("parseLiquibaseUpdateSQL: %v", err)
+	}
+	want := "CREATE TABLE public.users (id INTEGER GENERATED BY DEFAULT AS IDENTITY NOT NULL, name VARCHAR(255), CONSTRAINT users_pkey PRIMARY KEY (id));\n\n" +
+		"INSERT INTO public.databasechangelog (ID, AUTHOR, FILENAME, DATEEXECUTED, ORDEREXECUTED, MD5SUM, DESCRIPTION, COMMENTS, EXECTYPE, CONTEXTS, LABELS, LIQUIBASE, DEPLOYMENT_ID) VALUES ('1', 'alice', 'db/changelog/001-users.xml', NOW(), 1, '8:7d1b8c3e5d4f0a9c2b6e1f3a4d5c6b7a', 'createTable tableName=users', '', 'EXECUTED', NULL, NULL, '4.9.1', '5311520123');"
+	if sets[0].SQL != want || sets[0].SQLHash != sha256Hex(want) {
+		t.Fatalf("unexpected changeset SQL: %q", sets[0].SQL)
+	}
+	if !strings.HasSuffix(sets[1].SQL, "UPDATE public.databasechangeloglock SET LOCKED = FALSE, LOCKEDBY = NULL, LOCKGRANTED = NULL WHERE ID = 1;") {
+		t.Fatalf("expected the release lock to stay in the last changeset: %q", sets[1].SQL)
+	}
+}
+
+func TestParseLiquibaseUpdateSQLRejectsUnterminatedLiteral(t *testing.T) {
+	out := strings.Join([]string{
+		"-- Changeset a.sql::1::alice",
+		"INSERT INTO notes VALUES ('open",
+		"-- Changeset a.sql::2::alice",
+		"SELECT 1;",
+	}, "\n")
+	_, err := parseLiquibaseUpdateSQL(out)
+	expectValidationError(t, err, "unterminated literal in changeset")
+}
+
+func TestSQLScannerDollarQuotes(t *testing.T) {
+	cases := []struct {
+		line     string
+		topLevel bool
+	}{
+		{line: "SELECT $1, $2;", topLevel: true},
+		{line: "SELECT a$b$ FROM t;", topLevel: true},
+		{line: "DO $$ BEGIN", topLevel: false},
+		{line: "DO $fn$ SELECT '$$' $fn$;", topLevel: true},
+		{line: "SELECT 'a' -- it's a comment", topLevel: true},
+		{line: "SELECT \"quoted \"\" ident\"", topLevel: true},
+		{line: "/* outer /* inner */", topLevel: false},
+	}
+	for _, tc := range cases {
+		var scanner sqlScanner
+		scanner.scanLine(tc.line)
+		if scanner.topLevel() != tc.topLevel {
+			t.Fatalf("%q: expected topLevel=%v, got %+v", tc.line, tc.topLevel, scanner)
+		}
+	}
+}
+
+func FuzzParseLiquibaseUpdateSQL(f *testing.F) {
+	for _, name := range []string{"4.9.1-two-changesets.sql", "4.17.2-crlf.sql", "4.23.2-literals.sql", "4.29.2-console-noise.sql"} {
+		f.Add(readUpdateSQLFixture(f, name))
+	}
+	f.Add("-- Changeset a::b::c\nSELECT $x$ -- Changeset d::e::f $x$;")
+	f.Add("-- Changeset a::b::c\nSELECT E'\\\\';\n-- Changeset d::e::f\n")
+	f.Fuzz(func(t *testing.T, output string) {
+		sets, err := parseLiquibaseUpdateSQL(output)
+		if err != nil {
+			return
+		}
+		for _, cs := range sets {
+			if cs.SQLHash != sha256Hex(cs.SQL) {
+				t.Fatalf("SQLHash does not match SQL for %+v", cs)
+			}
+		}
+		if strings.Contains(output, "\r") {
+			return
+		}
+		crlf, err := parseLiquibaseUpdateSQL(strings.ReplaceAll(output, "\n", "\r\n"))
+		if err != nil {
+			t.Fatalf("CRLF output failed to parse: %v", err)
+		}
+		if !reflect.DeepEqual(sets, crlf) {
+			t.Fatalf("CRLF output parsed differently:\n%+v\n%+v", sets, crlf)
+		}
+	})
+}
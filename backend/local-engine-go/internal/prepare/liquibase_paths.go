@@ -190,6 +190,48 @@ func mapLiquibasePathValue(flag string, value string, mapper PathMapper) (string
 	return mapper.MapPath(value)
 }
 
+// checkLiquibaseWindowsPathValue fails fast when a path passed through to a
+// Windows Liquibase is missing, so the job is rejected before any container
+// work. Only absolute paths are checked: relative ones resolve against the
+// Liquibase work dir at run time. Windows paths are statted through their WSL
+// view and skipped when that cannot be resolved. Empty values are left to
+// collectLiquibasePaths.
+func checkLiquibaseWindowsPathValue(flag string, value string) error {
+	if !isSearchPathFlag(flag) {
+		return checkLiquibaseWindowsPath(value, "path does not exist")
+	}
+	for _, part := range strings.Split(value, ",") {
+		if err := checkLiquibaseWindowsPath(part, "searchPath path does not exist"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkLiquibaseWindowsPath(value string, notFoundMessage string) error {
+	value = strings.TrimSpace(value)
+	if value == "" || looksLikeRemoteRef(value) {
+		return nil
+	}
+	hostPath := value
+	if looksLikeWindowsPath(value) {
+		if !isWSL() {
+			return nil
+		}
+		mapped, err := wslPathConvert("-u", value)
+		if err != nil {
+			return nil
+		}
+		hostPath = mapped
+	} else if !strings.HasPrefix(value, "/") {
+		return nil
+	}
+	if _, err := os.Stat(hostPath); err != nil {
+		return ValidationError{Code: ErrorCodeInvalidArgument, Message: notFoundMessage, Details: value}
+	}
+	return nil
+}
+
 func looksLikeWindowsPath(value string) bool {
 	if len(value) < 2 {
 		return false
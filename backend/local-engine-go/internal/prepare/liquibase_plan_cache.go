@@ -0,0 +1,219 @@
+package prepare
+
+import (
+	"context"
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sqlrs/engine-local/internal/prepare/queue"
+)
+
+// liquibasePlanCache is the parsed updateSQL output persisted in the job
+// record, so that recovery can rebuild the plan without a planning container.
+type liquibasePlanCache struct {
+	ChangelogHash string                     `json:"changelog_hash"`
+	Changesets    []liquibaseCachedChangeset `json:"changesets"`
+}
+
+type liquibaseCachedChangeset struct {
+	ID       string `json:"id"`
+	Author   string `json:"author"`
+	Path     string `json:"path"`
+	SQL      string `json:"sql"`
+	SQLHash  string `json:"sql_hash"`
+	Checksum string `json:"checksum,omitempty"`
+}
+
+// searchPathDigestLimit caps the remembered file digests; the cache is reset
+// when it grows past it.
+const searchPathDigestLimit = 200000
+
+// searchPathDigestRacyWindow is how recent a modification time must be for a
+// digest not to be remembered: a file written again within the same mtime
+// tick, with the same size, would otherwise keep its old digest.
+const searchPathDigestRacyWindow = 2 * time.Second
+
+type searchPathDigest struct {
+	size    int64
+	modTime time.Time
+	digest  string
+}
+
+// searchPathDigests remembers the digests of search path files by size and
+// modification time, so that replanning over a large search path (such as a
+// repository root) only stats unchanged files instead of reading them.
+var searchPathDigests = struct {
+	mu      sync.Mutex
+	entries map[string]searchPathDigest
+}{entries: map[string]searchPathDigest{}}
+
+func cachedFileDigest(path string, info fs.FileInfo) (string, error) {
+	searchPathDigests.mu.Lock()
+	entry, ok := searchPathDigests.entries[path]
+	searchPathDigests.mu.Unlock()
+	if ok && entry.size == info.Size() && entry.modTime.Equal(info.ModTime()) {
+		return entry.digest, nil
+	}
+	digest, err := fileDigest(path)
+	if err != nil {
+		return "", err
+	}
+	if time.Since(info.ModTime()) < searchPathDigestRacyWindow {
+		return digest, nil
+	}
+	searchPathDigests.mu.Lock()
+	if len(searchPathDigests.entries) >= searchPathDigestLimit {
+		searchPathDigests.entries = map[string]searchPathDigest{}
+	}
+	searchPathDigests.entries[path] = searchPathDigest{size: info.Size(), modTime: info.ModTime(), digest: digest}
+	searchPathDigests.mu.Unlock()
+	return digest, nil
+}
+
+// liquibaseChangelogHash fingerprints everything updateSQL reads from the
+// host: the normalized arguments, the changelog and defaults files, and the
+// files under the search paths and changelog directories. File contents are
+// only read when their size or modification time changed since the last plan.
+func liquibaseChangelogHash(prepared preparedRequest) (string, error) {
+	hasher := newStateHasher()
+	hasher.write("image_id", prepared.effectiveImageID())
+	for _, arg := range prepared.normalizedArgs {
+		hasher.write("arg", arg)
+	}
+	roots := make([]string, 0, len(prepared.liquibaseSearchPaths)+len(prepared.liquibaseLockPaths))
+	for _, path := range prepared.liquibaseSearchPaths {
+		roots = append(roots, normalizeLockPath(path))
+	}
+	for _, path := range prepared.liquibaseLockPaths {
+		path = normalizeLockPath(path)
+		if path == "" || looksLikeRemoteRef(path) {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			hasher.write("missing", path)
+			continue
+		}
+		if info.IsDir() {
+			roots = append(roots, path)
+			continue
+		}
+		digest, err := fileDigest(path)
+		if err != nil {
+			return "", err
+		}
+		hasher.write("file", path)
+		hasher.write("file_hash", digest)
+		roots = append(roots, filepath.Dir(path))
+	}
+	sort.Strings(roots)
+	seen := map[string]bool{}
+	for _, root := range roots {
+		if root == "" || looksLikeRemoteRef(root) || seen[root] {
+			continue
+		}
+		seen[root] = true
+		if info, err := os.Stat(root); err != nil || !info.IsDir() {
+			continue
+		}
+		hasher.write("root", root)
+		err := filepath.WalkDir(root, func(current string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if entry.IsDir() && entry.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			if !entry.Type().IsRegular() {
+				return nil
+			}
+			rel, err := filepath.Rel(root, current)
+			if err != nil {
+				return err
+			}
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			digest, err := cachedFileDigest(current, info)
+			if err != nil {
+				return err
+			}
+			hasher.write("root_file", filepath.ToSlash(rel))
+			hasher.write("root_file_hash", digest)
+			return nil
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+	return hasher.sum(), nil
+}
+
+// loadLiquibasePlanCache returns the changesets stored for the job when they
+// were planned from the same changelog content.
+func (m *PrepareService) loadLiquibasePlanCache(ctx context.Context, jobID string, changelogHash string) ([]LiquibaseChangeset, bool) {
+	if m.queue == nil || changelogHash == "" {
+		return nil, false
+	}
+	job, ok, err := m.queue.GetJob(ctx, jobID)
+	if err != nil || !ok || job.PlanJSON == nil || strings.TrimSpace(*job.PlanJSON) == "" {
+		return nil, false
+	}
+	var cache liquibasePlanCache
+	if err := json.Unmarshal([]byte(*job.PlanJSON), &cache); err != nil {
+		m.logWarnJob(jobID, "liquibase plan cache unreadable: %v", err)
+		return nil, false
+	}
+	if cache.ChangelogHash != changelogHash {
+		m.logDebugJob(jobID, "liquibase plan cache is stale, replanning")
+		return nil, false
+	}
+	changesets := make([]LiquibaseChangeset, 0, len(cache.Changesets))
+	for _, item := range cache.Changesets {
+		changesets = append(changesets, LiquibaseChangeset{
+			ID:       item.ID,
+			Author:   item.Author,
+			Path:     item.Path,
+			SQL:      item.SQL,
+			SQLHash:  item.SQLHash,
+			Checksum: item.Checksum,
+		})
+	}
+	return changesets, true
+}
+
+func (m *PrepareService) storeLiquibasePlanCache(ctx context.Context, jobID string, changelogHash string, changesets []LiquibaseChangeset) {
+	if m.queue == nil || changelogHash == "" {
+		return
+	}
+	cache := liquibasePlanCache{
+		ChangelogHash: changelogHash,
+		Changesets:    make([]liquibaseCachedChangeset, 0, len(changesets)),
+	}
+	for _, changeset := range changesets {
+		cache.Changesets = append(cache.Changesets, liquibaseCachedChangeset{
+			ID:       changeset.ID,
+			Author:   changeset.Author,
+			Path:     changeset.Path,
+			SQL:      changeset.SQL,
+			SQLHash:  changeset.SQLHash,
+			Checksum: changeset.Checksum,
+		})
+	}
+	data, err := json.Marshal(cache)
+	if err != nil {
+		m.logWarnJob(jobID, "cannot encode liquibase plan cache: %v", err)
+		return
+	}
+	planJSON := string(data)
+	if err := m.queue.UpdateJob(ctx, jobID, queue.JobUpdate{PlanJSON: &planJSON}); err != nil {
+		m.logWarnJob(jobID, "cannot store liquibase plan cache: %v", err)
+	}
+}
@@ -0,0 +1,138 @@
+package prepare
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildPlanLiquibaseReusesPersistedChangesets(t *testing.T) {
+	temp := t.TempDir()
+	changelog := filepath.Join(temp, "changelog.xml")
+	writeTempFile(t, changelog, "<databaseChangeLog></databaseChangeLog>")
+
+	liquibase := &fakeLiquibaseRunner{output: strings.Join([]string{
+		"-- Changeset changelog.xml::1::dev",
+		"CREATE TABLE test(id INT);",
+		"-- Changeset changelog.xml::2::dev",
+		"ALTER TABLE test ADD COLUMN name TEXT;",
+	}, "\n")}
+	runtime := &fakeRuntime{}
+	queueStore := newQueueStore(t)
+	mgr := newManagerWithDeps(t, &fakeStore{}, queueStore, &testDeps{
+		runtime:   runtime,
+		liquibase: liquibase,
+	})
+	req := Request{
+		PrepareKind:   "lb",
+		ImageID:       "image-1@sha256:resolved",
+		LiquibaseArgs: []string{"update", "--changelog-file", changelog},
+		PlanOnly:      true,
+	}
+	createJobRecord(t, queueStore, "job-1", req, StatusRunning)
+	prepared, err := mgr.prepareRequest(req)
+	if err != nil {
+		t.Fatalf("prepareRequest: %v", err)
+	}
+
+	first, firstState, errResp := mgr.buildPlan(context.Background(), "job-1", prepared)
+	if errResp != nil {
+		t.Fatalf("buildPlan: %+v", errResp)
+	}
+	if len(liquibase.runs) != 1 || len(runtime.startCalls) != 1 {
+		t.Fatalf("expected one planning run, got liquibase=%d runtime=%d", len(liquibase.runs), len(runtime.startCalls))
+	}
+	job, ok, err := queueStore.GetJob(context.Background(), "job-1")
+	if err != nil || !ok || job.PlanJSON == nil {
+		t.Fatalf("expected persisted plan, got %+v ok=%v err=%v", job.PlanJSON, ok, err)
+	}
+
+	second, secondState, errResp := mgr.buildPlan(context.Background(), "job-1", prepared)
+	if errResp != nil {
+		t.Fatalf("buildPlan: %+v", errResp)
+	}
+	if len(liquibase.runs) != 1 || len(runtime.startCalls) != 1 {
+		t.Fatalf("expected cached plan without runtime, got liquibase=%d runtime=%d", len(liquibase.runs), len(runtime.startCalls))
+	}
+	if secondState != firstState || !reflect.DeepEqual(first, second) {
+		t.Fatalf("expected identical plan, got %+v vs %+v", first, second)
+	}
+
+	if err := os.WriteFile(changelog, []byte("<databaseChangeLog><!-- edit --></databaseChangeLog>"), 0o600); err != nil {
+		t.Fatalf("rewrite changelog: %v", err)
+	}
+	if _, _, errResp := mgr.buildPlan(context.Background(), "job-1", prepared); errResp != nil {
+		t.Fatalf("buildPlan: %+v", errResp)
+	}
+	if len(liquibase.runs) != 2 {
+		t.Fatalf("expected changelog change to invalidate cached plan, got %d runs", len(liquibase.runs))
+	}
+}
+
+func TestLiquibaseChangelogHashTracksIncludedFiles(t *testing.T) {
+	temp := t.TempDir()
+	changelog := filepath.Join(temp, "changelog.xml")
+	included := filepath.Join(temp, "sql", "001.sql")
+	if err := os.MkdirAll(filepath.Dir(included), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	writeTempFile(t, changelog, "<databaseChangeLog></databaseChangeLog>")
+	writeTempFile(t, included, "create table a(id int);")
+	prepared := preparedRequest{
+		request:            Request{ImageID: "image-1"},
+		normalizedArgs:     []string{"update", "--changelog-file", changelog},
+		liquibaseLockPaths: []string{changelog},
+	}
+	before, err := liquibaseChangelogHash(prepared)
+	if err != nil {
+		t.Fatalf("liquibaseChangelogHash: %v", err)
+	}
+	writeTempFile(t, included, "create table b(id int);")
+	after, err := liquibaseChangelogHash(prepared)
+	if err != nil {
+		t.Fatalf("liquibaseChangelogHash: %v", err)
+	}
+	if before == after {
+		t.Fatalf("expected included file change to change hash")
+	}
+}
+
+func TestCachedFileDigestReusesUnchangedFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "001.sql")
+	writeTempFile(t, path, "create table a(id int);")
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	first, err := cachedFileDigest(path, info)
+	if err != nil {
+		t.Fatalf("cachedFileDigest: %v", err)
+	}
+	// Same size and mtime: the remembered digest is used without reading.
+	writeTempFile(t, path, "create table b(id int);")
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+	if cached, err := cachedFileDigest(path, info); err != nil || cached != first {
+		t.Fatalf("expected the cached digest, got %q err=%v", cached, err)
+	}
+	newer := old.Add(time.Minute)
+	if err := os.Chtimes(path, newer, newer); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+	info, err = os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if fresh, err := cachedFileDigest(path, info); err != nil || fresh == first {
+		t.Fatalf("expected a new digest after the mtime changed, got %q err=%v", fresh, err)
+	}
+}
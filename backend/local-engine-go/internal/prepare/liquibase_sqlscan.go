@@ -0,0 +1,139 @@
+package prepare
+
+import "strings"
+
+// sqlScanner follows PostgreSQL lexical state across lines: whether the text
+// after the last scanned line continues a string, quoted identifier,
+// dollar-quoted body or block comment. parseLiquibaseUpdateSQL only looks for
+// changeset markers where the scanner is at top level.
+type sqlScanner struct {
+	// quote is ' or " while inside a string or quoted identifier.
+	quote byte
+	// escapes is set inside E'...' strings, where a backslash escapes the
+	// next character.
+	escapes bool
+	// dollarTag is the closing $tag$ while inside a dollar-quoted body.
+	dollarTag string
+	// blockDepth counts open /* */ comments, which nest in PostgreSQL.
+	blockDepth int
+}
+
+func (s *sqlScanner) topLevel() bool {
+	return s.quote == 0 && s.dollarTag == "" && s.blockDepth == 0
+}
+
+func (s *sqlScanner) scanLine(line string) {
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case s.dollarTag != "":
+			if strings.HasPrefix(line[i:], s.dollarTag) {
+				i += len(s.dollarTag) - 1
+				s.dollarTag = ""
+			}
+		case s.blockDepth > 0:
+			if strings.HasPrefix(line[i:], "*/") {
+				s.blockDepth--
+				i++
+			} else if strings.HasPrefix(line[i:], "/*") {
+				s.blockDepth++
+				i++
+			}
+		case s.quote != 0:
+			if s.escapes && c == '\\' {
+				i++
+				continue
+			}
+			if c != s.quote {
+				continue
+			}
+			if i+1 < len(line) && line[i+1] == s.quote {
+				i++
+				continue
+			}
+			s.quote = 0
+			s.escapes = false
+		case strings.HasPrefix(line[i:], "--"):
+			return
+		case strings.HasPrefix(line[i:], "/*"):
+			s.blockDepth = 1
+			i++
+		case c == '\'':
+			s.quote = c
+			s.escapes = i > 0 && (line[i-1] == 'E' || line[i-1] == 'e') && (i == 1 || !isSQLIdentChar(line[i-2]))
+		case c == '"':
+			s.quote = c
+		case c == '$' && (i == 0 || !isSQLIdentChar(line[i-1])):
+			if tag, ok := dollarQuoteTag(line[i:]); ok {
+				s.dollarTag = tag
+				i += len(tag) - 1
+			}
+		}
+	}
+}
+
+// dollarQuoteTag returns the $tag$ that text starts with. Positional
+// parameters such as $1 are not dollar quotes.
+func dollarQuoteTag(text string) (string, bool) {
+	for j := 1; j < len(text); j++ {
+		c := text[j]
+		switch {
+		case c == '$':
+			return text[:j+1], true
+		case c >= '0' && c <= '9':
+			if j == 1 {
+				return "", false
+			}
+		case c == '_' || c >= 0x80 || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z'):
+		default:
+			return "", false
+		}
+	}
+	return "", false
+}
+
+func isSQLIdentChar(c byte) bool {
+	return c == '_' || c == '$' || c >= 0x80 ||
+		(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// liquibaseLogPrefixes start the lines Liquibase prints around the SQL it
+// generates: the banner, start and finish messages and the update summary.
+// None of them starts valid SQL, and some carry timestamps, so they are
+// dropped instead of being hashed into the changeset they follow.
+var liquibaseLogPrefixes = []string{
+	"##",
+	"Starting Liquibase at ",
+	"Liquibase Version: ",
+	"Liquibase Community ",
+	"Liquibase Open Source ",
+	"Liquibase Pro ",
+	"Liquibase: ",
+	"Liquibase command '",
+	"Running Changeset: ",
+	"UPDATE SUMMARY",
+	"Run:",
+	"Previously run:",
+	"Filtered out:",
+	"Total change sets:",
+}
+
+// isLiquibaseLogLine reports Liquibase console and java.util.logging lines
+// ("[2024-01-15 10:12:01] INFO [liquibase.lockservice] ...") that end up in
+// updateSQL output when stderr is merged with stdout.
+func isLiquibaseLogLine(line string) bool {
+	for _, prefix := range liquibaseLogPrefixes {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	if !strings.HasPrefix(line, "[") {
+		return false
+	}
+	for _, level := range []string{"] SEVERE [", "] WARNING [", "] INFO [", "] FINE [", "] FINER [", "] FINEST [", "] CONFIG ["} {
+		if strings.Contains(line, level) {
+			return true
+		}
+	}
+	return false
+}
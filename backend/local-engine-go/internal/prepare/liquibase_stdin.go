@@ -0,0 +1,237 @@
+package prepare
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/sqlrs/engine-local/internal/runtime"
+)
+
+// liquibaseStdinDirName holds changelogs that came in Request.LiquibaseStdin,
+// under the namespace's work root.
+const liquibaseStdinDirName = "liquibase-stdin"
+
+// liquibaseStdinFormats maps Request.LiquibaseStdinFormat to the extension
+// Liquibase picks its parser by.
+var liquibaseStdinFormats = map[string]string{
+	"xml":  "xml",
+	"yaml": "yaml",
+	"yml":  "yaml",
+	"json": "json",
+	"sql":  "sql",
+}
+
+// liquibaseStdinChangelog is a changelog from Request.LiquibaseStdin and the
+// path it is written to so Liquibase can read it like any other changelog
+// file.
+type liquibaseStdinChangelog struct {
+	path string
+	hash string
+}
+
+// prepareLiquibaseStdin validates the stdin changelog of req and picks its
+// path, <work root>/liquibase-stdin/<hash>/changelog.<ext>. The path depends
+// only on the content, so identical changelogs get identical arguments and
+// reuse cached states. Nothing is written here: explain, lookups and
+// rejected submits leave no files behind, and writeLiquibaseStdin writes the
+// changelog once Liquibase is about to run.
+func (m *PrepareService) prepareLiquibaseStdin(req Request) (liquibaseStdinChangelog, error) {
+	if liquibaseArgsHaveChangelog(req.LiquibaseArgs) {
+		return liquibaseStdinChangelog{}, ValidationError{Code: ErrorCodeInvalidArgument, Message: "liquibase_stdin cannot be combined with --changelog-file"}
+	}
+	format := strings.ToLower(strings.TrimSpace(req.LiquibaseStdinFormat))
+	if format == "" {
+		format = "xml"
+	}
+	ext, ok := liquibaseStdinFormats[format]
+	if !ok {
+		return liquibaseStdinChangelog{}, ValidationError{Code: ErrorCodeInvalidArgument, Message: "liquibase_stdin_format must be xml, yaml, json or sql", Details: format}
+	}
+	content := liquibaseStdinContent(req)
+	if err := validateLiquibaseChangelog(ext, content); err != nil {
+		return liquibaseStdinChangelog{}, ValidationError{Code: ErrorCodeInvalidArgument, Message: "liquibase_stdin is not a valid " + ext + " changelog", Details: err.Error()}
+	}
+	if strings.TrimSpace(m.stateStoreRoot) == "" {
+		return liquibaseStdinChangelog{}, fmt.Errorf("state store root is required for liquibase_stdin")
+	}
+	hash := sha256Hex(ext + "\x00" + content)
+	path := filepath.Join(m.workRoot(req.Namespace), liquibaseStdinDirName, hash[:32], "changelog."+ext)
+	return liquibaseStdinChangelog{path: path, hash: hash}, nil
+}
+
+// liquibaseStdinContent is the stdin changelog of req as it is written.
+// Some editors put a UTF-8 byte order mark in front of piped changelogs; the
+// XML decoder rejects it.
+func liquibaseStdinContent(req Request) string {
+	if req.LiquibaseStdin == nil {
+		return ""
+	}
+	return strings.TrimPrefix(*req.LiquibaseStdin, "\ufeff")
+}
+
+// withLiquibaseStdinChangelog puts --changelog-file for the stdin changelog
+// at path in front of the prepared arguments, the way prepareLiquibaseArgs
+// handles that flag. It is added afterwards because prepareLiquibaseArgs
+// requires paths to exist, and the changelog is not written yet.
+func withLiquibaseStdinChangelog(prepared liquibasePrepared, path string, rewritePaths bool) liquibasePrepared {
+	value := path
+	if rewritePaths {
+		value = fmt.Sprintf("/sqlrs/mnt/path%d", len(prepared.mounts)+1)
+		prepared.mounts = append(prepared.mounts, runtime.Mount{
+			HostPath:      path,
+			ContainerPath: value,
+			ReadOnly:      true,
+		})
+	}
+	prepared.normalizedArgs = append([]string{"--changelog-file", value}, prepared.normalizedArgs...)
+	prepared.argsNormalized = strings.Join(prepared.normalizedArgs, " ")
+	prepared.lockPaths = append([]string{path}, prepared.lockPaths...)
+	return prepared
+}
+
+// writeLiquibaseStdin writes the stdin changelog of prepared, if any, to its
+// path. Rewriting an existing file is a no-op; the changelog dir's mtime is
+// refreshed either way, so sweepLiquibaseStdin keeps changelogs still in use.
+func writeLiquibaseStdin(prepared preparedRequest) error {
+	path := prepared.liquibaseStdinPath
+	if path == "" {
+		return nil
+	}
+	content := liquibaseStdinContent(prepared.request)
+	if existing, err := os.ReadFile(path); err != nil || string(existing) != content {
+		if err := writeStateSchemaCache(path, content); err != nil {
+			return fmt.Errorf("cannot write liquibase stdin changelog: %w", err)
+		}
+	}
+	now := time.Now()
+	_ = os.Chtimes(filepath.Dir(path), now, now)
+	return nil
+}
+
+// sweepLiquibaseStdin removes stdin changelogs no job has used since cutoff.
+// Changelogs of queued and running jobs are kept whatever their age.
+func (m *PrepareService) sweepLiquibaseStdin(ctx context.Context, cutoff time.Time) {
+	if strings.TrimSpace(m.stateStoreRoot) == "" {
+		return
+	}
+	jobs, err := m.queue.ListJobsByStatus(ctx, []string{StatusQueued, StatusRunning})
+	if err != nil {
+		m.logErrorJob("", "liquibase stdin sweep skipped: %v", err)
+		return
+	}
+	inUse := map[string]struct{}{}
+	for _, job := range jobs {
+		req := decodeJobRequest(job)
+		if req == nil || req.LiquibaseStdin == nil {
+			continue
+		}
+		if changelog, err := m.prepareLiquibaseStdin(*req); err == nil {
+			inUse[filepath.Dir(changelog.path)] = struct{}{}
+		}
+	}
+	for _, stdinDir := range m.namespaceDirs(liquibaseStdinDirName) {
+		entries, err := os.ReadDir(stdinDir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if ctx.Err() != nil {
+				return
+			}
+			dir := filepath.Join(stdinDir, entry.Name())
+			if _, ok := inUse[dir]; ok || !entry.IsDir() {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil || !info.ModTime().Before(cutoff) {
+				continue
+			}
+			if err := os.RemoveAll(dir); err != nil {
+				m.logWarnJob("", "liquibase stdin cleanup failed path=%s err=%v", dir, err)
+			}
+		}
+	}
+}
+
+func liquibaseArgsHaveChangelog(args []string) bool {
+	for _, arg := range args {
+		arg = strings.TrimSpace(arg)
+		if arg == "--changelog-file" || strings.HasPrefix(arg, "--changelog-file=") {
+			return true
+		}
+	}
+	return false
+}
+
+// validateLiquibaseChangelog checks that content parses as the changelog
+// format ext and, for structured formats, has a databaseChangeLog root.
+func validateLiquibaseChangelog(ext string, content string) error {
+	if strings.TrimSpace(content) == "" {
+		return errors.New("changelog is empty")
+	}
+	switch ext {
+	case "xml":
+		decoder := xml.NewDecoder(strings.NewReader(content))
+		root := ""
+		for {
+			token, err := decoder.Token()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			if start, ok := token.(xml.StartElement); ok && root == "" {
+				root = start.Name.Local
+			}
+		}
+		if root != "databaseChangeLog" {
+			return fmt.Errorf("root element is %q, want databaseChangeLog", root)
+		}
+	case "yaml":
+		var doc map[string]any
+		if err := yaml.Unmarshal([]byte(content), &doc); err != nil {
+			return err
+		}
+		if _, ok := doc["databaseChangeLog"]; !ok {
+			return errors.New("missing databaseChangeLog key")
+		}
+	case "json":
+		var doc map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(content), &doc); err != nil {
+			return err
+		}
+		if _, ok := doc["databaseChangeLog"]; !ok {
+			return errors.New("missing databaseChangeLog key")
+		}
+	case "sql":
+		first, _, _ := strings.Cut(strings.TrimSpace(content), "\n")
+		if !strings.EqualFold(strings.Join(strings.Fields(strings.TrimPrefix(strings.TrimSpace(first), "--")), " "), "liquibase formatted sql") {
+			return errors.New("first line must be --liquibase formatted sql")
+		}
+	}
+	return nil
+}
+
+// liquibaseTaskHash is liquibaseFingerprint plus, for stdin changelogs, the
+// changelog content, so the hash follows the content even when Liquibase
+// reports no checksums.
+func (p preparedRequest) liquibaseTaskHash(prevStateID string, changesets []LiquibaseChangeset) string {
+	if p.liquibaseStdinHash == "" {
+		return liquibaseFingerprint(prevStateID, changesets)
+	}
+	hasher := newStateHasher()
+	hasher.write("liquibase_fingerprint", liquibaseFingerprint(prevStateID, changesets))
+	hasher.write("liquibase_stdin", p.liquibaseStdinHash)
+	return hasher.sum()
+}
@@ -0,0 +1,213 @@
+package prepare
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const stdinChangelogXML = `<?xml version="1.0" encoding="UTF-8"?>
+<databaseChangeLog xmlns="http://www.liquibase.org/xml/ns/dbchangelog">
+  <changeSet id="1" author="dev"><sql>create table t(id int)</sql></changeSet>
+</databaseChangeLog>`
+
+func TestPrepareRequestPreparesLiquibaseStdin(t *testing.T) {
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{})
+	stdin := "\ufeff" + stdinChangelogXML
+
+	prepared, err := mgr.prepareRequest(Request{
+		PrepareKind:    "lb",
+		ImageID:        "image-1",
+		LiquibaseArgs:  []string{"update"},
+		LiquibaseStdin: &stdin,
+	})
+	if err != nil {
+		t.Fatalf("prepareRequest: %v", err)
+	}
+	if len(prepared.normalizedArgs) < 2 || prepared.normalizedArgs[0] != "--changelog-file" {
+		t.Fatalf("expected injected --changelog-file, got %+v", prepared.normalizedArgs)
+	}
+	path := prepared.normalizedArgs[1]
+	if filepath.Base(path) != "changelog.xml" || !strings.HasPrefix(path, mgr.workRoot("")) {
+		t.Fatalf("unexpected changelog path %q", path)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected prepareRequest not to write the changelog, stat err=%v", err)
+	}
+	if err := writeLiquibaseStdin(prepared); err != nil {
+		t.Fatalf("writeLiquibaseStdin: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != stdinChangelogXML {
+		t.Fatalf("expected written changelog, got %q err=%v", data, err)
+	}
+	if prepared.liquibaseStdinHash == "" {
+		t.Fatalf("expected stdin hash")
+	}
+
+	yamlStdin := "databaseChangeLog:\n  - changeSet:\n      id: 1\n      author: dev\n"
+	prepared, err = mgr.prepareRequest(Request{
+		PrepareKind:          "lb",
+		ImageID:              "image-1",
+		LiquibaseArgs:        []string{"update"},
+		LiquibaseStdin:       &yamlStdin,
+		LiquibaseStdinFormat: "YML",
+	})
+	if err != nil {
+		t.Fatalf("prepareRequest yaml: %v", err)
+	}
+	if filepath.Base(prepared.normalizedArgs[1]) != "changelog.yaml" {
+		t.Fatalf("expected yaml changelog, got %+v", prepared.normalizedArgs)
+	}
+}
+
+func TestPrepareRequestValidatesLiquibaseStdin(t *testing.T) {
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{})
+	cases := []struct {
+		name    string
+		stdin   *string
+		format  string
+		args    []string
+		message string
+	}{
+		{name: "empty", stdin: strPtr("  "), message: "not a valid xml changelog"},
+		{name: "broken xml", stdin: strPtr("<databaseChangeLog>"), message: "not a valid xml changelog"},
+		{name: "xml root", stdin: strPtr("<project/>"), message: "not a valid xml changelog"},
+		{name: "yaml", stdin: strPtr("changeSets: []"), format: "yaml", message: "not a valid yaml changelog"},
+		{name: "json", stdin: strPtr(`{"databaseChangeLog": [`), format: "json", message: "not a valid json changelog"},
+		{name: "sql", stdin: strPtr("create table t(id int);"), format: "sql", message: "not a valid sql changelog"},
+		{name: "format", stdin: strPtr(stdinChangelogXML), format: "toml", message: "liquibase_stdin_format must be"},
+		{name: "format without stdin", format: "xml", message: "requires liquibase_stdin"},
+		{name: "changelog file", stdin: strPtr(stdinChangelogXML), args: []string{"--changelog-file=other.xml"}, message: "cannot be combined"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := mgr.prepareRequest(Request{
+				PrepareKind:          "lb",
+				ImageID:              "image-1",
+				LiquibaseArgs:        append([]string{"update"}, tc.args...),
+				LiquibaseStdin:       tc.stdin,
+				LiquibaseStdinFormat: tc.format,
+			})
+			var validation ValidationError
+			if !errors.As(err, &validation) || !strings.Contains(validation.Message, tc.message) {
+				t.Fatalf("expected %q validation error, got %v", tc.message, err)
+			}
+		})
+	}
+
+	sql := "\n--liquibase formatted sql\n--changeset dev:1\ncreate table t(id int);\n"
+	if _, err := mgr.prepareRequest(Request{
+		PrepareKind:          "lb",
+		ImageID:              "image-1",
+		LiquibaseArgs:        []string{"update"},
+		LiquibaseStdin:       &sql,
+		LiquibaseStdinFormat: "sql",
+	}); err != nil {
+		t.Fatalf("expected formatted sql to be accepted, got %v", err)
+	}
+}
+
+func TestBuildPlanLiquibaseStdinDrivesPlanAndCache(t *testing.T) {
+	liquibase := &fakeLiquibaseRunner{output: strings.Join([]string{
+		"-- Changeset changelog.xml::1::dev",
+		"create table t(id int);",
+	}, "\n")}
+	queueStore := newQueueStore(t)
+	mgr := newManagerWithDeps(t, &fakeStore{}, queueStore, &testDeps{
+		runtime:   &fakeRuntime{},
+		liquibase: liquibase,
+	})
+	plan := func(jobID string, stdin string) (string, string) {
+		t.Helper()
+		req := Request{
+			PrepareKind:    "lb",
+			ImageID:        "image-1@sha256:resolved",
+			LiquibaseArgs:  []string{"update"},
+			LiquibaseStdin: &stdin,
+			PlanOnly:       true,
+		}
+		createJobRecord(t, queueStore, jobID, req, StatusRunning)
+		prepared, err := mgr.prepareRequest(req)
+		if err != nil {
+			t.Fatalf("prepareRequest: %v", err)
+		}
+		tasks, stateID, errResp := mgr.buildPlan(context.Background(), jobID, prepared)
+		if errResp != nil {
+			t.Fatalf("buildPlan: %+v", errResp)
+		}
+		run := liquibase.runs[len(liquibase.runs)-1]
+		if !containsArg(run.Args, prepared.normalizedArgs[1]) {
+			t.Fatalf("expected planner to read the stdin changelog, got %+v", run.Args)
+		}
+		taskHash := ""
+		for _, task := range tasks {
+			if task.Type == "state_execute" {
+				taskHash = task.TaskHash
+			}
+		}
+		return taskHash, stateID
+	}
+
+	firstHash, firstState := plan("job-1", stdinChangelogXML)
+	sameHash, sameState := plan("job-2", stdinChangelogXML)
+	if sameHash != firstHash || sameState != firstState {
+		t.Fatalf("expected identical stdin to reuse states, got %s/%s vs %s/%s", firstHash, firstState, sameHash, sameState)
+	}
+	edited := strings.Replace(stdinChangelogXML, "id int", "id bigint", 1)
+	editedHash, editedState := plan("job-3", edited)
+	if editedHash == firstHash || editedState == firstState {
+		t.Fatalf("expected changed stdin to invalidate the cache, got %s/%s", editedHash, editedState)
+	}
+}
+
+func TestSweepLiquibaseStdinRemovesUnusedChangelogs(t *testing.T) {
+	queueStore := newQueueStore(t)
+	mgr := newManagerWithDeps(t, &fakeStore{}, queueStore, &testDeps{})
+	write := func(jobID string, stdin string, status string) string {
+		t.Helper()
+		req := Request{PrepareKind: "lb", ImageID: "image-1", LiquibaseArgs: []string{"update"}, LiquibaseStdin: &stdin}
+		createJobRecord(t, queueStore, jobID, req, status)
+		prepared, err := mgr.prepareRequest(req)
+		if err != nil {
+			t.Fatalf("prepareRequest: %v", err)
+		}
+		if err := writeLiquibaseStdin(prepared); err != nil {
+			t.Fatalf("writeLiquibaseStdin: %v", err)
+		}
+		return filepath.Dir(prepared.liquibaseStdinPath)
+	}
+	done := write("job-1", stdinChangelogXML, StatusSucceeded)
+	running := write("job-2", strings.Replace(stdinChangelogXML, "id int", "id bigint", 1), StatusRunning)
+	old := time.Now().Add(-2 * time.Hour)
+	for _, dir := range []string{done, running} {
+		if err := os.Chtimes(dir, old, old); err != nil {
+			t.Fatalf("chtimes: %v", err)
+		}
+	}
+
+	mgr.sweepLiquibaseStdin(context.Background(), time.Now().Add(-time.Hour))
+
+	if _, err := os.Stat(done); !os.IsNotExist(err) {
+		t.Fatalf("expected unused changelog to be swept, stat err=%v", err)
+	}
+	if _, err := os.Stat(running); err != nil {
+		t.Fatalf("expected changelog of a running job to be kept: %v", err)
+	}
+}
+
+func TestLookupStateCheapDoesNotWriteLiquibaseStdin(t *testing.T) {
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{runtime: &fakeRuntime{}})
+	stdin := stdinChangelogXML
+	req := Request{PrepareKind: "lb", ImageID: "image-1@sha256:resolved", LiquibaseArgs: []string{"update"}, LiquibaseStdin: &stdin}
+	if _, err := mgr.LookupState(context.Background(), req, true); err != nil {
+		t.Fatalf("LookupState: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(mgr.workRoot(""), liquibaseStdinDirName)); !os.IsNotExist(err) {
+		t.Fatalf("expected no stdin changelog on disk, stat err=%v", err)
+	}
+}
@@ -0,0 +1,55 @@
+package prepare
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestLogLevelMatrix(t *testing.T) {
+	cases := map[string][]string{
+		"debug": {"debug line", "task line", "info line", "warn line", "failed task", "error line"},
+		"info":  {"task line", "info line", "warn line", "failed task", "error line"},
+		"warn":  {"warn line", "failed task", "error line"},
+		"error": {"error line"},
+	}
+	all := []string{"debug line", "task line", "info line", "warn line", "failed task", "error line"}
+	for level, want := range cases {
+		mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{
+			config: &fakeConfigStore{values: map[string]any{"log.level": level}},
+		})
+		var buf bytes.Buffer
+		prev := log.Writer()
+		log.SetOutput(&buf)
+		mgr.logDebugJob("job-1", "debug line")
+		mgr.logTask("job-1", "execute-0", "task line")
+		mgr.logInfoJob("job-1", "info line")
+		mgr.logWarnJob("job-1", "warn line")
+		mgr.logTaskAt("warn", "job-1", "execute-0", "failed task")
+		mgr.logErrorJob("job-1", "error line")
+		log.SetOutput(prev)
+
+		shown := map[string]bool{}
+		for _, message := range want {
+			shown[message] = true
+		}
+		for _, message := range all {
+			if got := strings.Contains(buf.String(), message); got != shown[message] {
+				t.Fatalf("level %s: expected %q shown=%t, got log:\n%s", level, message, shown[message], buf.String())
+			}
+		}
+	}
+}
+
+func TestLogLevelDefaultsToDebug(t *testing.T) {
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{})
+	var buf bytes.Buffer
+	prev := log.Writer()
+	log.SetOutput(&buf)
+	mgr.logDebugJob("job-1", "debug line")
+	log.SetOutput(prev)
+	if !strings.Contains(buf.String(), "prepare job=job-1 debug line") {
+		t.Fatalf("expected debug output by default, got %q", buf.String())
+	}
+}
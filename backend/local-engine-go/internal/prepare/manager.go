@@ -16,10 +16,12 @@ import (
 	"github.com/sqlrs/engine-local/internal/config"
 	"github.com/sqlrs/engine-local/internal/dbms"
 	"github.com/sqlrs/engine-local/internal/deletion"
+	"github.com/sqlrs/engine-local/internal/loglevel"
 	"github.com/sqlrs/engine-local/internal/prepare/queue"
 	"github.com/sqlrs/engine-local/internal/runtime"
 	"github.com/sqlrs/engine-local/internal/statefs"
 	"github.com/sqlrs/engine-local/internal/store"
+	"github.com/sqlrs/engine-local/internal/tracing"
 )
 
 const (
@@ -37,14 +39,26 @@ type Options struct {
 	ValidateStore  func(root string) error
 	DBMS           dbms.Connector
 	StateStoreRoot string
+	// WorkDirRoot holds ephemeral job scratch such as runtime dirs; empty
+	// keeps it under StateStoreRoot.
+	WorkDirRoot    string
 	Config         config.Store
 	Psql           psqlRunner
 	Liquibase      liquibaseRunner
+	Flyway         flywayRunner
 	Version        string
 	Now            func() time.Time
 	IDGen          func() (string, error)
 	Async          bool
 	HeartbeatEvery time.Duration
+	// Tracer receives job and task spans; nil disables tracing.
+	Tracer *tracing.Tracer
+	// PostgresSuperuser is the role base states are bootstrapped with and
+	// every connection uses; empty means runtime.DefaultPostgresSuperuser.
+	PostgresSuperuser string
+	// Secrets resolves ${secret:name} references in liquibase_env and
+	// flyway_env; nil means EnvSecretProvider.
+	Secrets SecretProvider
 }
 
 type PrepareService struct {
@@ -54,21 +68,43 @@ type PrepareService struct {
 	statefs        statefs.StateFS
 	dbms           dbms.Connector
 	stateStoreRoot string
+	workDirRoot    string
 	config         config.Store
 	psql           psqlRunner
 	liquibase      liquibaseRunner
+	flyway         flywayRunner
 	version        string
 	validateStore  func(root string) error
 	now            func() time.Time
 	idGen          func() (string, error)
 	async          bool
 	heartbeatEvery time.Duration
+	tracer         *tracing.Tracer
+	superuser      string
+	secrets        SecretProvider
 	lastEviction   *CacheEvictionSummary
 
-	mu      sync.Mutex
-	running map[string]*jobRunner
-	events  *eventBus
-	beats   map[string]*heartbeatState
+	// coalesceMu serializes the lookup and insert of coalescing submits so two
+	// identical requests cannot both start a job.
+	coalesceMu sync.Mutex
+	// retryMu serializes retries so a failed job is requeued only once.
+	retryMu sync.Mutex
+	// baseInit serializes InitBase per resolved image so parallel jobs never
+	// initialize the same base dir at once.
+	baseInit imageLocks
+	// warm holds pre-started base containers (container.warmPool.size).
+	warm warmPool
+	// usage holds the last GET /v1/usage summary.
+	usage usageCache
+
+	mu       sync.Mutex
+	running  map[string]*jobRunner
+	draining bool
+	events   *eventBus
+	beats    map[string]*heartbeatState
+	// logAppends counts log events appended per job since the last
+	// orchestrator.events.maxPerJob check.
+	logAppends map[string]int
 
 	coordinator jobCoordinatorAPI
 	executor    taskExecutorAPI
@@ -80,6 +116,17 @@ type jobRunner struct {
 	done   chan struct{}
 	mu     sync.Mutex
 	rt     *jobRuntime
+	// verifiedStates holds the states whose checksums were checked or
+	// written during this run, so each state is read at most once per job.
+	// corruptState is the input state a clone exposed as corrupt. Both are
+	// guarded by mu.
+	verifiedStates map[string]bool
+	corruptState   string
+	// heartbeatEvery overrides the service heartbeat for this job; guarded by
+	// PrepareService.mu.
+	heartbeatEvery time.Duration
+	// span is the job's root span; failJob marks it failed.
+	span *tracing.Span
 }
 
 type jobRuntime struct {
@@ -88,6 +135,13 @@ type jobRuntime struct {
 	runtimeDir  string
 	cleanup     func() error
 	scriptMount *scriptMount
+	// pgpassFile is set when Request.Pgpass is mounted into the container;
+	// secrets are its resolved passwords, redacted from psql output.
+	pgpassFile string
+	secrets    secretValues
+	// liquibaseLockChecked is set once the instance's liquibase changelog
+	// lock has been checked, so later steps skip the check.
+	liquibaseLockChecked bool
 }
 
 type preparedRequest struct {
@@ -100,9 +154,23 @@ type preparedRequest struct {
 	psqlInputs           []psqlInput
 	psqlSteps            []psqlStep
 	psqlWorkDir          string
+	psqlLimits           psqlScriptLimits
+	psqlMounts           []runtime.Mount
+	psqlMountsHash       string
+	psqlSingleTx         bool
+	psqlNormalizeHash    bool
 	liquibaseLockPaths   []string
 	liquibaseSearchPaths []string
 	liquibaseWorkDir     string
+	flywayWorkDir        string
+	// liquibaseStdinHash identifies a Request.LiquibaseStdin changelog; it is
+	// folded into the liquibase task hashes. liquibaseStdinPath is where
+	// writeLiquibaseStdin puts it.
+	liquibaseStdinHash string
+	liquibaseStdinPath string
+	// traceParent is the caller's span context from Submit; recovered and
+	// retried jobs start a new trace.
+	traceParent tracing.SpanContext
 }
 
 func NewPrepareService(opts Options) (*PrepareService, error) {
@@ -142,6 +210,10 @@ func NewPrepareService(opts Options) (*PrepareService, error) {
 	if liquibase == nil {
 		liquibase = hostLiquibaseRunner{}
 	}
+	flyway := opts.Flyway
+	if flyway == nil {
+		flyway = hostFlywayRunner{}
+	}
 	validateStore := opts.ValidateStore
 	if validateStore == nil {
 		validateStore = func(root string) error {
@@ -155,18 +227,24 @@ func NewPrepareService(opts Options) (*PrepareService, error) {
 		statefs:        opts.StateFS,
 		dbms:           opts.DBMS,
 		stateStoreRoot: opts.StateStoreRoot,
+		workDirRoot:    strings.TrimSpace(opts.WorkDirRoot),
 		config:         opts.Config,
 		psql:           psql,
 		liquibase:      liquibase,
+		flyway:         flyway,
 		version:        opts.Version,
 		validateStore:  validateStore,
 		now:            now,
 		idGen:          idGen,
 		async:          opts.Async,
-		heartbeatEvery: normalizeHeartbeat(opts.HeartbeatEvery),
+		heartbeatEvery: normalizeHeartbeat(opts.HeartbeatEvery, time.Second),
+		tracer:         opts.Tracer,
+		superuser:      opts.PostgresSuperuser,
+		secrets:        opts.Secrets,
 		running:        map[string]*jobRunner{},
 		events:         newEventBus(),
 		beats:          map[string]*heartbeatState{},
+		logAppends:     map[string]int{},
 	}
 	m.snapshot = &snapshotOrchestrator{m: m}
 	m.executor = &taskExecutor{m: m, snapshot: m.snapshot}
@@ -176,17 +254,22 @@ func NewPrepareService(opts Options) (*PrepareService, error) {
 
 func (m *PrepareService) Recover(ctx context.Context) error {
 	if errResp := m.ensureCacheCapacity(ctx, "", "startup_recovery"); errResp != nil {
-		m.logJob("", "startup cache check failed code=%s message=%s details=%s", errResp.Code, errResp.Message, summarizeLogDetails(errResp.Details))
+		m.logErrorJob("", "startup cache check failed code=%s message=%s details=%s", errResp.Code, errResp.Message, summarizeLogDetails(errResp.Details))
 	}
 	jobs, err := m.queue.ListJobsByStatus(ctx, []string{StatusQueued, StatusRunning})
 	if err != nil {
 		return err
 	}
+	m.sweepOrphanJobDirs(ctx)
+	m.sweepRetiredStateDirs(ctx)
 	for _, job := range jobs {
-		m.logJob(job.JobID, "recover status=%s", job.Status)
+		m.logInfoJob(job.JobID, "recover status=%s", job.Status)
+		if job.Status == StatusRunning {
+			m.resetJobRuntime(ctx, jobNamespace(job), job.JobID)
+		}
 		prepared, err := m.prepareFromJob(job)
 		if err != nil {
-			errResp := errorResponse("internal_error", "cannot restore job request", err.Error())
+			errResp := errorResponse(ErrorCodeInternal, "cannot restore job request", err.Error())
 			_ = m.failJob(job.JobID, errResp)
 			continue
 		}
@@ -200,19 +283,42 @@ func (m *PrepareService) Recover(ctx context.Context) error {
 }
 
 func (m *PrepareService) Submit(ctx context.Context, req Request) (Accepted, error) {
+	if m.isDraining() {
+		return Accepted{}, UnavailableError{Code: ErrorCodeUnavailable, Message: "engine is shutting down"}
+	}
+	req.IdempotencyKey = strings.TrimSpace(req.IdempotencyKey)
 	prepared, err := m.prepareRequest(req)
 	if err != nil {
 		return Accepted{}, err
 	}
-	jobID, err := m.idGen()
+	prepared.traceParent = tracing.SpanContextFromContext(ctx)
+	reqJSON, err := json.Marshal(prepared.request)
 	if err != nil {
 		return Accepted{}, err
 	}
-	now := m.now().UTC().Format(time.RFC3339Nano)
-	reqJSON, err := json.Marshal(prepared.request)
+	idempotencyKey := prepared.request.IdempotencyKey
+	if idempotencyKey != "" {
+		if accepted, ok, err := m.acceptedForIdempotencyKey(ctx, idempotencyKey, reqJSON); ok || err != nil {
+			return accepted, err
+		}
+	}
+	var signature string
+	if prepared.request.Coalesce {
+		signature = m.coalesceSignature(ctx, &prepared)
+		m.coalesceMu.Lock()
+		defer m.coalesceMu.Unlock()
+		if accepted, ok, err := m.acceptedForInFlightSignature(ctx, prepared, signature); ok || err != nil {
+			return accepted, err
+		}
+	}
+	if err := m.checkQueueCapacity(ctx); err != nil {
+		return Accepted{}, err
+	}
+	jobID, err := m.idGen()
 	if err != nil {
 		return Accepted{}, err
 	}
+	now := m.now().UTC().Format(time.RFC3339Nano)
 
 	argsNormalized := prepared.argsNormalized
 	job := queue.JobRecord{
@@ -226,10 +332,29 @@ func (m *PrepareService) Submit(ctx context.Context, req Request) (Accepted, err
 		RequestJSON:           strPtr(string(reqJSON)),
 		CreatedAt:             now,
 	}
+	if idempotencyKey != "" {
+		job.IdempotencyKey = &idempotencyKey
+	}
+	if signature != "" {
+		job.Signature = &signature
+	}
+	if len(prepared.request.Labels) > 0 {
+		labelsJSON, err := json.Marshal(prepared.request.Labels)
+		if err != nil {
+			return Accepted{}, err
+		}
+		job.LabelsJSON = strPtr(string(labelsJSON))
+	}
 	if err := m.queue.CreateJob(ctx, job); err != nil {
+		if idempotencyKey != "" {
+			// A concurrent submit may have claimed the key between lookup and insert.
+			if accepted, ok, lookupErr := m.acceptedForIdempotencyKey(ctx, idempotencyKey, reqJSON); ok || lookupErr != nil {
+				return accepted, lookupErr
+			}
+		}
 		return Accepted{}, err
 	}
-	m.logJob(jobID, "created kind=%s image=%s plan_only=%t", prepared.request.PrepareKind, prepared.request.ImageID, prepared.request.PlanOnly)
+	m.logInfoJob(jobID, "created kind=%s image=%s plan_only=%t", prepared.request.PrepareKind, prepared.request.ImageID, prepared.request.PlanOnly)
 	_ = m.appendEvent(jobID, Event{
 		Type:   "status",
 		Ts:     now,
@@ -242,28 +367,51 @@ func (m *PrepareService) Submit(ctx context.Context, req Request) (Accepted, err
 		m.runJob(prepared, jobID)
 	}
 
+	return acceptedFor(jobID, StatusQueued), nil
+}
+
+// acceptedForIdempotencyKey returns the Accepted payload of a retained job that
+// was submitted with the same idempotency key. Reusing a key with a different
+// request body is a conflict so retries never attach to an unrelated job.
+func (m *PrepareService) acceptedForIdempotencyKey(ctx context.Context, key string, reqJSON []byte) (Accepted, bool, error) {
+	job, ok, err := m.queue.GetJobByIdempotencyKey(ctx, key)
+	if err != nil || !ok {
+		return Accepted{}, false, err
+	}
+	if job.RequestJSON == nil || *job.RequestJSON != string(reqJSON) {
+		return Accepted{}, false, ConflictError{
+			Code:    ErrorCodeInvalidArgument,
+			Message: "idempotency_key was already used with a different request",
+			Details: job.JobID,
+		}
+	}
+	m.logDebugJob(job.JobID, "reused idempotency_key=%s status=%s", key, job.Status)
+	return acceptedFor(job.JobID, job.Status), true, nil
+}
+
+func acceptedFor(jobID string, status string) Accepted {
 	base := "/v1/prepare-jobs/" + jobID
 	return Accepted{
 		JobID:     jobID,
 		StatusURL: base,
 		EventsURL: base + "/events",
-		Status:    StatusQueued,
-	}, nil
+		Status:    status,
+	}
 }
 
 func (m *PrepareService) Get(jobID string) (Status, bool) {
 	job, ok, err := m.queue.GetJob(context.Background(), jobID)
 	if err != nil {
-		m.logJob(jobID, "lookup failed error=%v", err)
+		m.logErrorJob(jobID, "lookup failed error=%v", err)
 		return Status{}, false
 	}
 	if !ok {
-		m.logJob(jobID, "lookup missing")
+		m.logDebugJob(jobID, "lookup missing")
 		return Status{}, false
 	}
 	tasks, err := m.queue.ListTasks(context.Background(), jobID)
 	if err != nil {
-		m.logJob(jobID, "task list failed error=%v", err)
+		m.logErrorJob(jobID, "task list failed error=%v", err)
 		tasks = nil
 	}
 	status := Status{
@@ -276,6 +424,7 @@ func (m *PrepareService) Get(jobID string) (Status, bool) {
 		CreatedAt:             strPtr(job.CreatedAt),
 		StartedAt:             job.StartedAt,
 		FinishedAt:            job.FinishedAt,
+		Labels:                jobLabels(job),
 		Tasks:                 planTasksFromRecords(tasks),
 	}
 	if job.ResultJSON != nil {
@@ -298,6 +447,28 @@ func (m *PrepareService) ListJobs(jobID string) []JobEntry {
 	if err != nil {
 		return []JobEntry{}
 	}
+	return m.jobEntries(jobs, jobID)
+}
+
+// JobsGeneration returns the queue write counter, if the queue keeps one.
+func (m *PrepareService) JobsGeneration() (uint64, bool) {
+	counter, ok := m.queue.(interface{ Generation() uint64 })
+	if !ok {
+		return 0, false
+	}
+	return counter.Generation(), true
+}
+
+// ListJobsByLabels lists jobs carrying every given label.
+func (m *PrepareService) ListJobsByLabels(labels map[string]string) []JobEntry {
+	jobs, err := m.queue.ListJobsByLabels(context.Background(), labels)
+	if err != nil {
+		return []JobEntry{}
+	}
+	return m.jobEntries(jobs, "")
+}
+
+func (m *PrepareService) jobEntries(jobs []queue.JobRecord, jobID string) []JobEntry {
 	resolvedByJobID := map[string]string{}
 	if tasks, err := m.queue.ListTasks(context.Background(), jobID); err == nil {
 		for _, task := range tasks {
@@ -318,12 +489,14 @@ func (m *PrepareService) ListJobs(jobID string) []JobEntry {
 			PrepareKind:           job.PrepareKind,
 			ImageID:               job.ImageID,
 			ResolvedImageID:       resolvedByJobID[job.JobID],
+			Namespace:             jobNamespace(job),
 			PrepareArgsNormalized: valueOrEmpty(job.PrepareArgsNormalized),
 			Signature:             valueOrEmpty(job.Signature),
 			PlanOnly:              job.PlanOnly,
 			CreatedAt:             strPtr(job.CreatedAt),
 			StartedAt:             job.StartedAt,
 			FinishedAt:            job.FinishedAt,
+			Labels:                jobLabels(job),
 		}
 		entries = append(entries, entry)
 	}
@@ -351,7 +524,7 @@ func (m *PrepareService) ListTasks(jobID string) []TaskEntry {
 }
 
 func (m *PrepareService) Delete(jobID string, opts deletion.DeleteOptions) (deletion.DeleteResult, bool) {
-	_, ok, err := m.queue.GetJob(context.Background(), jobID)
+	job, ok, err := m.queue.GetJob(context.Background(), jobID)
 	if err != nil || !ok {
 		return deletion.DeleteResult{}, false
 	}
@@ -366,7 +539,7 @@ func (m *PrepareService) Delete(jobID string, opts deletion.DeleteOptions) (dele
 		ID:   jobID,
 	}
 	if blocked && !opts.Force {
-		m.logJob(jobID, "delete blocked active_tasks=true")
+		m.logInfoJob(jobID, "delete blocked active_tasks=true")
 		node.Blocked = deletion.BlockActiveTasks
 		return deletion.DeleteResult{
 			DryRun:  opts.DryRun,
@@ -386,7 +559,7 @@ func (m *PrepareService) Delete(jobID string, opts deletion.DeleteOptions) (dele
 	}
 
 	if blocked && opts.Force {
-		m.logJob(jobID, "delete force cancel")
+		m.logInfoJob(jobID, "delete force cancel")
 		runner := m.getRunner(jobID)
 		if runner != nil {
 			runner.cancel()
@@ -397,11 +570,11 @@ func (m *PrepareService) Delete(jobID string, opts deletion.DeleteOptions) (dele
 	if err := m.queue.DeleteJob(context.Background(), jobID); err != nil {
 		return deletion.DeleteResult{}, false
 	}
-	if err := m.removeJobDir(jobID); err != nil {
-		m.logJob(jobID, "delete cleanup failed: %v", err)
+	if err := m.removeJobDir(jobNamespace(job), jobID); err != nil {
+		m.logErrorJob(jobID, "delete cleanup failed: %v", err)
 		return deletion.DeleteResult{}, false
 	}
-	m.logJob(jobID, "deleted")
+	m.logInfoJob(jobID, "deleted")
 	return result, true
 }
 
@@ -424,7 +597,7 @@ func (m *PrepareService) Cancel(jobID string) (Status, bool, bool, error) {
 	}
 
 	if runner := m.getRunner(jobID); runner != nil {
-		m.logJob(jobID, "cancel requested")
+		m.logInfoJob(jobID, "cancel requested")
 		runner.cancel()
 		status, ok := m.Get(jobID)
 		if !ok {
@@ -433,8 +606,8 @@ func (m *PrepareService) Cancel(jobID string) (Status, bool, bool, error) {
 		return status, true, true, nil
 	}
 
-	m.logJob(jobID, "cancel requested without active runner")
-	if err := m.failJob(jobID, errorResponse("cancelled", "job cancelled", "")); err != nil {
+	m.logInfoJob(jobID, "cancel requested without active runner")
+	if err := m.failJob(jobID, errorResponse(ErrorCodeCancelled, "job cancelled", "")); err != nil {
 		return Status{}, true, false, err
 	}
 	status, ok := m.Get(jobID)
@@ -445,6 +618,21 @@ func (m *PrepareService) Cancel(jobID string) (Status, bool, bool, error) {
 }
 
 func (m *PrepareService) EventsSince(jobID string, index int) ([]Event, bool, bool, error) {
+	return m.listEvents(jobID, func(ctx context.Context) ([]queue.EventRecord, error) {
+		return m.queue.ListEventsSince(ctx, jobID, index)
+	})
+}
+
+// EventsAfterSeq is EventsSince for a stream that knows the seq of the last
+// event it sent, or 0 before the first one. Streams follow seqs so each
+// wakeup reads only the new events, and pruning cannot shift their position.
+func (m *PrepareService) EventsAfterSeq(jobID string, seq int64) ([]Event, bool, bool, error) {
+	return m.listEvents(jobID, func(ctx context.Context) ([]queue.EventRecord, error) {
+		return m.queue.ListEventsAfterSeq(ctx, jobID, seq)
+	})
+}
+
+func (m *PrepareService) listEvents(jobID string, list func(ctx context.Context) ([]queue.EventRecord, error)) ([]Event, bool, bool, error) {
 	job, ok, err := m.queue.GetJob(context.Background(), jobID)
 	if err != nil {
 		return nil, false, false, err
@@ -452,7 +640,7 @@ func (m *PrepareService) EventsSince(jobID string, index int) ([]Event, bool, bo
 	if !ok {
 		return nil, false, false, nil
 	}
-	events, err := m.queue.ListEventsSince(context.Background(), jobID, index)
+	events, err := list(context.Background())
 	if err != nil {
 		return nil, true, false, err
 	}
@@ -464,15 +652,51 @@ func (m *PrepareService) EventsSince(jobID string, index int) ([]Event, bool, bo
 	return out, true, done, nil
 }
 
-func (m *PrepareService) WaitForEvent(ctx context.Context, jobID string, index int) error {
+// EventSeqBeforeOffset resolves a stream offset to the seq a stream starting
+// there continues after: the seq just before the event at offset, or the
+// latest seq when offset is past the end. Offsets shift once older log events
+// are pruned, so streams convert them once and follow seqs from there.
+func (m *PrepareService) EventSeqBeforeOffset(jobID string, offset int) (int64, bool, error) {
+	_, ok, err := m.queue.GetJob(context.Background(), jobID)
+	if err != nil || !ok {
+		return 0, ok, err
+	}
+	if offset <= 0 {
+		return 0, true, nil
+	}
+	events, err := m.queue.ListEventsSince(context.Background(), jobID, offset-1)
+	if err != nil {
+		return 0, true, err
+	}
+	if len(events) == 0 {
+		seq, err := m.queue.MaxEventSeq(context.Background(), jobID)
+		return seq, true, err
+	}
+	return events[0].Seq, true, nil
+}
+
+// EventSubscribers returns the number of clients currently waiting on job
+// events. The engine counts them as activity so a watched job is not cut off
+// by the idle shutdown.
+func (m *PrepareService) EventSubscribers() int {
+	if m == nil || m.events == nil {
+		return 0
+	}
+	return m.events.count()
+}
+
+// WaitForEvent blocks until the job has an event with seq > afterSeq, the job
+// is done, or ctx ends. It waits on seq rather than on the event count, so
+// pruning older events while a stream waits cannot stall it.
+func (m *PrepareService) WaitForEvent(ctx context.Context, jobID string, afterSeq int64) error {
 	ch := m.events.subscribe(jobID)
 	defer m.events.unsubscribe(jobID, ch)
 	for {
-		count, err := m.queue.CountEvents(ctx, jobID)
+		seq, err := m.queue.MaxEventSeq(ctx, jobID)
 		if err != nil {
 			return err
 		}
-		if count > index {
+		if seq > afterSeq {
 			return nil
 		}
 		job, ok, err := m.queue.GetJob(ctx, jobID)
@@ -506,31 +730,39 @@ func (m *PrepareService) prepareFromJob(job queue.JobRecord) (preparedRequest, e
 
 func (c *jobCoordinator) runJob(prepared preparedRequest, jobID string) {
 	m := c.m
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(tracing.ContextWithSpanContext(context.Background(), prepared.traceParent))
 	runner := m.registerRunner(jobID, cancel)
+	heartbeatEvery := m.jobHeartbeat(prepared.request)
+	ctx, span := m.startJobSpan(ctx, jobID, prepared)
+	m.mu.Lock()
+	runner.heartbeatEvery = heartbeatEvery
+	runner.span = span
+	m.mu.Unlock()
 	jobSucceeded := false
+	var stateID string
 	defer func() {
 		if !jobSucceeded {
 			m.cleanupRuntime(ctx, runner)
 		}
+		endJobSpan(span, prepared, stateID)
 		close(runner.done)
 		m.unregisterRunner(jobID)
 	}()
 
 	job, ok, err := m.queue.GetJob(context.Background(), jobID)
 	if err != nil {
-		_ = m.failJob(jobID, errorResponse("internal_error", "cannot load job", err.Error()))
+		_ = m.failJob(jobID, errorResponse(ErrorCodeInternal, "cannot load job", err.Error()))
 		return
 	}
 	if !ok {
 		return
 	}
 	if job.Status == StatusSucceeded || job.Status == StatusFailed {
-		m.logJob(jobID, "skip execution for terminal job status=%s", job.Status)
+		m.logDebugJob(jobID, "skip execution for terminal job status=%s", job.Status)
 		return
 	}
 	if ctx.Err() != nil {
-		_ = m.failJob(jobID, errorResponse("cancelled", "job cancelled", ""))
+		_ = m.failJob(jobID, errorResponse(ErrorCodeCancelled, "job cancelled", ""))
 		return
 	}
 
@@ -548,14 +780,19 @@ func (c *jobCoordinator) runJob(prepared preparedRequest, jobID string) {
 	_ = m.queue.UpdateJob(ctx, jobID, queue.JobUpdate{
 		PrepareArgsNormalized: &prepared.argsNormalized,
 	})
-	m.logJob(jobID, "running")
+	m.logInfoJob(jobID, "running")
 
 	if err := m.validateStore(m.stateStoreRoot); err != nil {
-		_ = m.failJob(jobID, errorResponse("internal_error", "state store not ready", err.Error()))
+		_ = m.failJob(jobID, errorResponse(ErrorCodeInternal, "state store not ready", err.Error()))
+		return
+	}
+	if err := writeLiquibaseStdin(prepared); err != nil {
+		_ = m.failJob(jobID, errorResponse(ErrorCodeInternal, "cannot write liquibase stdin changelog", err.Error()))
 		return
 	}
 
-	tasks, stateID, errResp := c.loadOrPlanTasks(ctx, jobID, prepared)
+	tasks, plannedStateID, errResp := c.loadOrPlanTasks(ctx, jobID, prepared)
+	stateID = plannedStateID
 	if errResp != nil {
 		_ = m.failJob(jobID, errResp)
 		return
@@ -563,7 +800,7 @@ func (c *jobCoordinator) runJob(prepared preparedRequest, jobID string) {
 
 	if prepared.request.PlanOnly {
 		if err := m.markTasksSucceeded(ctx, jobID, tasks); err != nil {
-			_ = m.failJob(jobID, errorResponse("internal_error", "cannot update task status", err.Error()))
+			_ = m.failJob(jobID, errorResponse(ErrorCodeInternal, "cannot update task status", err.Error()))
 			return
 		}
 		if err := m.succeedPlan(jobID); err == nil {
@@ -572,50 +809,80 @@ func (c *jobCoordinator) runJob(prepared preparedRequest, jobID string) {
 		return
 	}
 
-	for _, task := range tasks {
+	rebuiltCorruptState := false
+	for i := 0; i < len(tasks); i++ {
+		task := tasks[i]
 		if ctx.Err() != nil {
-			_ = m.failJob(jobID, errorResponse("cancelled", "job cancelled", ""))
+			_ = m.failJob(jobID, errorResponse(ErrorCodeCancelled, "job cancelled", ""))
 			return
 		}
 		if task.Status == StatusSucceeded {
 			continue
 		}
 		if task.Status == StatusFailed {
-			_ = m.failJob(jobID, errorResponse("internal_error", "task failed", task.TaskID))
+			_ = m.failJob(jobID, errorResponse(ErrorCodeInternal, "task failed", task.TaskID))
 			return
 		}
 		if task.Type == "state_execute" && task.Cached != nil && *task.Cached && strings.TrimSpace(task.OutputStateID) != "" {
 			cached, err := m.isStateCached(task.OutputStateID)
 			if err != nil {
-				_ = m.failJob(jobID, errorResponse("internal_error", "cannot check state cache", err.Error()))
+				_ = m.failJob(jobID, errorResponse(ErrorCodeInternal, "cannot check state cache", err.Error()))
 				return
 			}
-			if cached {
+			// With checksums on, an unverified cached state goes through the
+			// cache decision in executeStateTask, which verifies it once and
+			// rebuilds it on a mismatch.
+			if cached && !m.stateVerificationPending(jobID, task.OutputStateID) {
 				stateID = task.OutputStateID
+				m.traceCachedTask(ctx, prepared, task)
 				if err := m.updateTaskStatus(ctx, jobID, task.TaskID, StatusSucceeded, nil, strPtr(m.now().UTC().Format(time.RFC3339Nano)), nil); err != nil {
-					_ = m.failJob(jobID, errorResponse("internal_error", "cannot update task status", err.Error()))
+					_ = m.failJob(jobID, errorResponse(ErrorCodeInternal, "cannot update task status", err.Error()))
 					return
 				}
 				continue
 			}
 		}
 		if err := m.updateTaskStatus(ctx, jobID, task.TaskID, StatusRunning, strPtr(m.now().UTC().Format(time.RFC3339Nano)), nil, nil); err != nil {
-			_ = m.failJob(jobID, errorResponse("internal_error", "cannot update task status", err.Error()))
+			_ = m.failJob(jobID, errorResponse(ErrorCodeInternal, "cannot update task status", err.Error()))
 			return
 		}
 		switch task.Type {
 		case "plan":
-		case "resolve_image":
+		case "resolve_image", "build_image":
 			if strings.TrimSpace(task.ResolvedImageID) != "" && strings.TrimSpace(prepared.resolvedImageID) == "" {
 				prepared.resolvedImageID = task.ResolvedImageID
 			}
-			if errResp := m.ensureResolvedImageID(ctx, jobID, &prepared, nil); errResp != nil {
+			errResp := m.runTracedTask(ctx, jobID, prepared, task, func(taskCtx context.Context) *ErrorResponse {
+				if task.Type == "build_image" {
+					return m.buildImage(taskCtx, jobID, prepared)
+				}
+				return m.ensureResolvedImageID(taskCtx, jobID, &prepared, nil)
+			})
+			if errResp != nil {
 				_ = m.updateTaskStatus(ctx, jobID, task.TaskID, StatusFailed, nil, strPtr(m.now().UTC().Format(time.RFC3339Nano)), errResp)
 				_ = m.failJob(jobID, errResp)
 				return
 			}
 		case "state_execute":
-			outputID, errResp := c.executor.executeStateTask(ctx, jobID, prepared, task)
+			var outputID string
+			errResp := m.runTracedTask(ctx, jobID, prepared, task, func(taskCtx context.Context) *ErrorResponse {
+				var execErr *ErrorResponse
+				outputID, execErr = c.executor.executeStateTask(taskCtx, jobID, prepared, task)
+				return execErr
+			})
+			if corrupt := m.takeCorruptState(jobID); errResp != nil && corrupt != "" && !rebuiltCorruptState {
+				if producer := producingTaskIndex(tasks[:i], corrupt); producer >= 0 {
+					rebuiltCorruptState = true
+					m.logInfoJob(jobID, "rebuilding corrupt state=%s from task=%s", corrupt, tasks[producer].TaskID)
+					for j := producer; j <= i; j++ {
+						notCached := false
+						tasks[j].Status = StatusQueued
+						tasks[j].Cached = &notCached
+					}
+					i = producer - 1
+					continue
+				}
+			}
 			if errResp != nil {
 				_ = m.updateTaskStatus(ctx, jobID, task.TaskID, StatusFailed, nil, strPtr(m.now().UTC().Format(time.RFC3339Nano)), errResp)
 				_ = m.failJob(jobID, errResp)
@@ -623,14 +890,19 @@ func (c *jobCoordinator) runJob(prepared preparedRequest, jobID string) {
 			}
 			stateID = outputID
 		case "prepare_instance":
-			result, errResp := c.executor.createInstance(ctx, jobID, prepared, stateID)
+			var result *Result
+			errResp := m.runTracedTask(ctx, jobID, prepared, task, func(taskCtx context.Context) *ErrorResponse {
+				var createErr *ErrorResponse
+				result, createErr = c.executor.createInstance(taskCtx, jobID, prepared, stateID)
+				return createErr
+			})
 			if errResp != nil {
 				_ = m.updateTaskStatus(ctx, jobID, task.TaskID, StatusFailed, nil, strPtr(m.now().UTC().Format(time.RFC3339Nano)), errResp)
 				_ = m.failJob(jobID, errResp)
 				return
 			}
 			if err := m.updateTaskStatus(ctx, jobID, task.TaskID, StatusSucceeded, nil, strPtr(m.now().UTC().Format(time.RFC3339Nano)), nil); err != nil {
-				_ = m.failJob(jobID, errorResponse("internal_error", "cannot update task status", err.Error()))
+				_ = m.failJob(jobID, errorResponse(ErrorCodeInternal, "cannot update task status", err.Error()))
 				return
 			}
 			if err := m.succeed(jobID, *result); err == nil {
@@ -639,16 +911,21 @@ func (c *jobCoordinator) runJob(prepared preparedRequest, jobID string) {
 			return
 		}
 		if err := m.updateTaskStatus(ctx, jobID, task.TaskID, StatusSucceeded, nil, strPtr(m.now().UTC().Format(time.RFC3339Nano)), nil); err != nil {
-			_ = m.failJob(jobID, errorResponse("internal_error", "cannot update task status", err.Error()))
+			_ = m.failJob(jobID, errorResponse(ErrorCodeInternal, "cannot update task status", err.Error()))
 			return
 		}
 	}
 
 	if stateID == "" {
-		_ = m.failJob(jobID, errorResponse("internal_error", "missing output state", ""))
+		_ = m.failJob(jobID, errorResponse(ErrorCodeInternal, "missing output state", ""))
 		return
 	}
-	result, errResp := c.executor.createInstance(ctx, jobID, prepared, stateID)
+	var result *Result
+	errResp = m.runTracedTask(ctx, jobID, prepared, taskState{PlanTask: PlanTask{TaskID: "prepare-instance", Type: "prepare_instance"}}, func(taskCtx context.Context) *ErrorResponse {
+		var createErr *ErrorResponse
+		result, createErr = c.executor.createInstance(taskCtx, jobID, prepared, stateID)
+		return createErr
+	})
 	if errResp != nil {
 		_ = m.failJob(jobID, errResp)
 		return
@@ -662,13 +939,20 @@ func (c *jobCoordinator) loadOrPlanTasks(ctx context.Context, jobID string, prep
 	m := c.m
 	taskRecords, err := m.queue.ListTasks(ctx, jobID)
 	if err != nil {
-		return nil, "", errorResponse("internal_error", "cannot load tasks", err.Error())
+		return nil, "", errorResponse(ErrorCodeInternal, "cannot load tasks", err.Error())
 	}
 	if errResp := m.ensureResolvedImageID(ctx, jobID, &prepared, taskRecords); errResp != nil {
 		return nil, "", errResp
 	}
+	if len(taskRecords) == 0 && prepared.request.Build != nil && plansFromRuntime(prepared.request.PrepareKind) {
+		// Liquibase and Flyway plan in a container of the image, so it is
+		// built up front; the build_image task then finds it built.
+		if errResp := m.buildImage(ctx, jobID, prepared); errResp != nil {
+			return nil, "", errResp
+		}
+	}
 	if len(taskRecords) == 0 {
-		if prepared.request.PrepareKind != "lb" {
+		if !plansFromRuntime(prepared.request.PrepareKind) {
 			if errResp := m.updateJobSignature(ctx, jobID, prepared); errResp != nil {
 				return nil, "", errResp
 			}
@@ -677,17 +961,18 @@ func (c *jobCoordinator) loadOrPlanTasks(ctx context.Context, jobID string, prep
 		if errResp != nil {
 			return nil, "", errResp
 		}
-		if prepared.request.PrepareKind == "lb" {
+		if plansFromRuntime(prepared.request.PrepareKind) {
 			if errResp := m.updateJobSignatureFromPlan(ctx, jobID, prepared, tasks); errResp != nil {
 				return nil, "", errResp
 			}
 		}
-		m.logJob(jobID, "planned tasks count=%d state_id=%s", len(tasks), stateID)
+		m.logInfoJob(jobID, "planned tasks count=%d state_id=%s", len(tasks), stateID)
 		records := taskRecordsFromPlan(jobID, tasks)
 		if err := m.queue.ReplaceTasks(ctx, jobID, records); err != nil {
-			return nil, "", errorResponse("internal_error", "cannot store tasks", err.Error())
+			return nil, "", errorResponse(ErrorCodeInternal, "cannot store tasks", err.Error())
 		}
-		m.logJob(jobID, "stored tasks count=%d", len(tasks))
+		m.logDebugJob(jobID, "stored tasks count=%d", len(tasks))
+		m.appendPlanEvent(jobID, tasks)
 		m.trimCompletedJobs(ctx, prepared)
 		return taskStatesFromPlan(tasks), stateID, nil
 	}
@@ -706,7 +991,7 @@ func (c *jobCoordinator) loadOrPlanTasks(ctx context.Context, jobID string, prep
 			continue
 		}
 		if task.Type == "state_execute" {
-			if exists, err := m.isStateCached(task.OutputStateID); err == nil && exists {
+			if exists, err := m.isStateCachedForPlan(prepared, task.OutputStateID); err == nil && exists {
 				finishedAt := m.now().UTC().Format(time.RFC3339Nano)
 				_ = m.updateTaskStatus(ctx, jobID, task.TaskID, StatusSucceeded, nil, &finishedAt, nil)
 				task.Status = StatusSucceeded
@@ -726,10 +1011,10 @@ func (c *jobCoordinator) replanTasksOnDrift(ctx context.Context, jobID string, p
 	}
 	job, ok, err := m.queue.GetJob(ctx, jobID)
 	if err != nil {
-		return false, nil, "", errorResponse("internal_error", "cannot load job", err.Error())
+		return false, nil, "", errorResponse(ErrorCodeInternal, "cannot load job", err.Error())
 	}
 	if !ok {
-		return false, nil, "", errorResponse("internal_error", "cannot load job", jobID)
+		return false, nil, "", errorResponse(ErrorCodeInternal, "cannot load job", jobID)
 	}
 
 	signatureDrift, errResp := m.hasPlanSignatureDrift(prepared, job, taskRecords)
@@ -745,7 +1030,7 @@ func (c *jobCoordinator) replanTasksOnDrift(ctx context.Context, jobID string, p
 	if buildErr != nil {
 		return false, nil, "", buildErr
 	}
-	if prepared.request.PrepareKind == "lb" {
+	if plansFromRuntime(prepared.request.PrepareKind) {
 		if errResp := m.updateJobSignatureFromPlan(ctx, jobID, prepared, tasks); errResp != nil {
 			return false, nil, "", errResp
 		}
@@ -756,9 +1041,10 @@ func (c *jobCoordinator) replanTasksOnDrift(ctx context.Context, jobID string, p
 	}
 	records := taskRecordsFromPlan(jobID, tasks)
 	if err := m.queue.ReplaceTasks(ctx, jobID, records); err != nil {
-		return false, nil, "", errorResponse("internal_error", "cannot store tasks", err.Error())
+		return false, nil, "", errorResponse(ErrorCodeInternal, "cannot store tasks", err.Error())
 	}
-	m.logJob(jobID, "replanned tasks due to plan drift signature=%t shape=%t count=%d state_id=%s", signatureDrift, shapeDrift, len(tasks), stateID)
+	m.logInfoJob(jobID, "replanned tasks due to plan drift signature=%t shape=%t count=%d state_id=%s", signatureDrift, shapeDrift, len(tasks), stateID)
+	m.appendPlanEvent(jobID, tasks)
 	return true, tasks, stateID, nil
 }
 
@@ -770,7 +1056,7 @@ func (m *PrepareService) hasPlanSignatureDrift(prepared preparedRequest, job que
 
 	var expectedSignature string
 	var errResp *ErrorResponse
-	if prepared.request.PrepareKind == "lb" {
+	if plansFromRuntime(prepared.request.PrepareKind) {
 		expectedSignature, errResp = m.computeJobSignatureFromPlan(prepared, planTasksFromRecords(taskRecords))
 	} else {
 		expectedSignature, errResp = m.computeJobSignature(prepared)
@@ -821,7 +1107,7 @@ func (m *PrepareService) updateJobSignature(ctx context.Context, jobID string, p
 		return errResp
 	}
 	if err := m.queue.UpdateJob(ctx, jobID, queue.JobUpdate{Signature: &signature}); err != nil {
-		return errorResponse("internal_error", "cannot update job signature", err.Error())
+		return errorResponse(ErrorCodeInternal, "cannot update job signature", err.Error())
 	}
 	return nil
 }
@@ -832,7 +1118,7 @@ func (m *PrepareService) updateJobSignatureFromPlan(ctx context.Context, jobID s
 		return errResp
 	}
 	if err := m.queue.UpdateJob(ctx, jobID, queue.JobUpdate{Signature: &signature}); err != nil {
-		return errorResponse("internal_error", "cannot update job signature", err.Error())
+		return errorResponse(ErrorCodeInternal, "cannot update job signature", err.Error())
 	}
 	return nil
 }
@@ -844,15 +1130,28 @@ func (m *PrepareService) computeJobSignature(prepared preparedRequest) (string,
 	}
 	imageID := prepared.effectiveImageID()
 	if strings.TrimSpace(imageID) == "" {
-		return "", errorResponse("internal_error", "resolved image id is required", "")
+		return "", errorResponse(ErrorCodeInternal, "resolved image id is required", "")
 	}
 	hasher := newStateHasher()
 	hasher.write("task_hash", taskHash)
 	hasher.write("image_id", imageID)
+	if prepared.request.BaseStateID != "" {
+		hasher.write("base_state_id", prepared.request.BaseStateID)
+	}
+	if prepared.request.PsqlSplit {
+		hasher.write("psql_split", "true")
+	}
+	if prepared.request.NoCache {
+		hasher.write("no_cache", "true")
+	}
+	if prepared.request.Namespace != "" {
+		hasher.write("namespace", prepared.request.Namespace)
+	}
 	hasher.write("plan_only", fmt.Sprintf("%t", prepared.request.PlanOnly))
+	m.writeSuperuserKey(hasher)
 	signature := hasher.sum()
 	if signature == "" {
-		return "", errorResponse("internal_error", "cannot compute job signature", "")
+		return "", errorResponse(ErrorCodeInternal, "cannot compute job signature", "")
 	}
 	return signature, nil
 }
@@ -860,12 +1159,18 @@ func (m *PrepareService) computeJobSignature(prepared preparedRequest) (string,
 func (m *PrepareService) computeJobSignatureFromPlan(prepared preparedRequest, tasks []PlanTask) (string, *ErrorResponse) {
 	imageID := prepared.effectiveImageID()
 	if strings.TrimSpace(imageID) == "" {
-		return "", errorResponse("internal_error", "resolved image id is required", "")
+		return "", errorResponse(ErrorCodeInternal, "resolved image id is required", "")
 	}
 	hasher := newStateHasher()
 	hasher.write("image_id", imageID)
+	if prepared.request.Namespace != "" {
+		hasher.write("namespace", prepared.request.Namespace)
+	}
 	hasher.write("plan_only", fmt.Sprintf("%t", prepared.request.PlanOnly))
-	hasher.write("engine_version", m.version)
+	if prepared.request.NoCache {
+		hasher.write("no_cache", "true")
+	}
+	hasher.write("engine_version", m.cacheVersion())
 	for _, task := range tasks {
 		hasher.write("task_id", task.TaskID)
 		hasher.write("task_type", task.Type)
@@ -888,7 +1193,7 @@ func (m *PrepareService) computeJobSignatureFromPlan(prepared preparedRequest, t
 	}
 	signature := hasher.sum()
 	if signature == "" {
-		return "", errorResponse("internal_error", "cannot compute job signature", "")
+		return "", errorResponse(ErrorCodeInternal, "cannot compute job signature", "")
 	}
 	return signature, nil
 }
@@ -900,7 +1205,7 @@ func (m *PrepareService) trimCompletedJobs(ctx context.Context, prepared prepare
 	}
 	signature, errResp := m.computeJobSignature(prepared)
 	if errResp != nil {
-		m.logJob("", "job retention skipped: %s", errResp.Message)
+		m.logWarnJob("", "job retention skipped: %s", errResp.Message)
 		return
 	}
 	m.trimCompletedJobsBySignature(ctx, signature)
@@ -912,7 +1217,7 @@ func (m *PrepareService) trimCompletedJobsForJob(ctx context.Context, jobID stri
 	}
 	job, ok, err := m.queue.GetJob(ctx, jobID)
 	if err != nil {
-		m.logJob(jobID, "job retention lookup failed: %v", err)
+		m.logErrorJob(jobID, "job retention lookup failed: %v", err)
 		return
 	}
 	if !ok {
@@ -935,7 +1240,7 @@ func (m *PrepareService) trimCompletedJobsBySignature(ctx context.Context, signa
 	}
 	jobs, err := m.queue.ListJobsBySignature(ctx, signature, []string{StatusSucceeded, StatusFailed})
 	if err != nil {
-		m.logJob("", "job retention failed: %v", err)
+		m.logErrorJob("", "job retention failed: %v", err)
 		return
 	}
 	if len(jobs) <= limit {
@@ -944,36 +1249,150 @@ func (m *PrepareService) trimCompletedJobsBySignature(ctx context.Context, signa
 	for i := limit; i < len(jobs); i++ {
 		jobID := jobs[i].JobID
 		if err := m.queue.DeleteJob(ctx, jobID); err != nil {
-			m.logJob(jobID, "job retention delete failed: %v", err)
+			m.logErrorJob(jobID, "job retention delete failed: %v", err)
 			continue
 		}
-		if err := m.removeJobDir(jobID); err != nil {
-			m.logJob(jobID, "job retention cleanup failed: %v", err)
+		if err := m.removeJobDir(jobNamespace(jobs[i]), jobID); err != nil {
+			m.logErrorJob(jobID, "job retention cleanup failed: %v", err)
 		}
-		m.logJob(jobID, "retention deleted")
+		m.logInfoJob(jobID, "retention deleted")
 	}
 }
 
 func (m *PrepareService) prepareRequest(req Request) (preparedRequest, error) {
 	kind := strings.TrimSpace(req.PrepareKind)
 	if kind == "" {
-		return preparedRequest{}, ValidationError{Code: "invalid_argument", Message: "prepare_kind is required"}
+		return preparedRequest{}, ValidationError{Code: ErrorCodeInvalidArgument, Message: "prepare_kind is required"}
 	}
 	switch kind {
-	case "psql", "lb":
+	case "psql", "lb", "flyway":
 	default:
-		return preparedRequest{}, ValidationError{Code: "invalid_argument", Message: "unsupported prepare_kind", Details: kind}
+		return preparedRequest{}, ValidationError{Code: ErrorCodeInvalidArgument, Message: "unsupported prepare_kind", Details: kind}
 	}
 	imageID := strings.TrimSpace(req.ImageID)
-	if imageID == "" {
-		return preparedRequest{}, ValidationError{Code: "invalid_argument", Message: "image_id is required"}
+	req.BaseStateID = strings.TrimSpace(req.BaseStateID)
+	if req.Build != nil {
+		if (imageID != "" && !isBuildImageRef(imageID)) || req.BaseStateID != "" {
+			return preparedRequest{}, ValidationError{Code: ErrorCodeInvalidArgument, Message: "build cannot be combined with image_id or base_state_id"}
+		}
+		build, err := m.prepareBuild(*req.Build)
+		if err != nil {
+			return preparedRequest{}, err
+		}
+		req.Build = &build
+		imageID = buildImageRepo
+	}
+	if imageID == "" && req.BaseStateID == "" {
+		return preparedRequest{}, ValidationError{Code: ErrorCodeInvalidArgument, Message: "image_id is required"}
+	}
+	instanceMode, err := normalizeInstanceMode(req.InstanceMode)
+	if err != nil {
+		return preparedRequest{}, err
+	}
+	namespace, err := normalizeNamespace(req.Namespace)
+	if err != nil {
+		return preparedRequest{}, err
+	}
+	if req.BaseStateID != "" {
+		base, err := m.resolveBaseState(req.BaseStateID, imageID, namespace)
+		if err != nil {
+			return preparedRequest{}, err
+		}
+		req.BaseStateID = base.StateID
+		imageID = base.ImageID
+	}
+	// Built images are gated by container.build.enabled; the image policy
+	// applies to pulled images only.
+	if req.Build == nil {
+		if err := m.checkImagePolicy(imageID); err != nil {
+			return preparedRequest{}, err
+		}
 	}
 	req.PrepareKind = kind
 	req.ImageID = imageID
+	req.InstanceMode = instanceMode
+	req.Namespace = namespace
+	labels, err := normalizeLabels(req.Labels)
+	if err != nil {
+		return preparedRequest{}, err
+	}
+	req.Labels = labels
+	if _, err := parseTaskTimeout(req.TaskTimeout); err != nil {
+		return preparedRequest{}, err
+	}
+	if _, err := parseHeartbeatEvery(req.HeartbeatEvery); err != nil {
+		return preparedRequest{}, err
+	}
+	if timeout, err := parseStatementTimeout(req.StatementTimeout); err != nil {
+		return preparedRequest{}, err
+	} else if timeout > 0 && kind != "psql" {
+		return preparedRequest{}, ValidationError{Code: ErrorCodeInvalidArgument, Message: "statement_timeout is only supported for psql prepare"}
+	}
+	if req.CPULimit, err = parseCPULimit(req.CPULimit); err != nil {
+		return preparedRequest{}, err
+	}
+	if req.MemoryLimit, err = parseMemoryLimit(req.MemoryLimit); err != nil {
+		return preparedRequest{}, err
+	}
+	if req.Network, err = m.parseContainerNetwork(req.Network); err != nil {
+		return preparedRequest{}, err
+	}
+	if req.DNS, err = parseContainerDNS(req.DNS); err != nil {
+		return preparedRequest{}, err
+	}
+	if req.Platform, err = parsePlatform(req.Platform); err != nil {
+		return preparedRequest{}, err
+	}
+	req.Platform = m.containerPlatform(req.Platform)
+	if err := validateSecretRefs("liquibase_env", req.LiquibaseEnv); err != nil {
+		return preparedRequest{}, err
+	}
+	if err := validateSecretRefs("flyway_env", req.FlywayEnv); err != nil {
+		return preparedRequest{}, err
+	}
+	if strings.TrimSpace(req.Pgpass) != "" {
+		if kind != "psql" {
+			return preparedRequest{}, ValidationError{Code: ErrorCodeInvalidArgument, Message: "pgpass is only supported for psql", Details: kind}
+		}
+		if err := validatePgpass(req.Pgpass); err != nil {
+			return preparedRequest{}, err
+		}
+	}
+	preamble, err := psqlPreamble(req.SearchPath, req.PsqlPreamble)
+	if err != nil {
+		return preparedRequest{}, err
+	}
+	if len(preamble) > 0 && kind != "psql" {
+		return preparedRequest{}, ValidationError{Code: ErrorCodeInvalidArgument, Message: "search_path and psql_preamble are only supported for psql prepare"}
+	}
 	var prepared preparedRequest
 	switch kind {
 	case "psql":
-		psqlPrepared, err := preparePsqlArgs(req.PsqlArgs, req.Stdin)
+		psqlArgs := req.PsqlArgs
+		if req.PsqlSingleTransaction && !hasPsqlSingleTransactionFlag(psqlArgs) {
+			// Leading, so it is a shared flag of every -c/-f step.
+			psqlArgs = append([]string{"--single-transaction"}, psqlArgs...)
+		}
+		psqlPrepared, err := preparePsqlArgs(psqlArgs, req.Stdin, m.psqlScriptLimits())
+		if err != nil {
+			return preparedRequest{}, err
+		}
+		psqlPrepared.inputs = psqlPreambleInputs(preamble, psqlPrepared.inputs)
+		singleTx := hasPsqlSingleTransactionFlag(psqlPrepared.normalizedArgs)
+		if singleTx {
+			if err := checkPsqlSingleTransactionInputs(psqlPrepared.inputs, psqlPrepared.workDir, psqlPrepared.limits); err != nil {
+				return preparedRequest{}, err
+			}
+		}
+		if req.PsqlSplit {
+			steps, err := splitPsqlSteps(psqlPrepared.steps, psqlPrepared.workDir, psqlPrepared.limits)
+			if err != nil {
+				return preparedRequest{}, ValidationError{Code: ErrorCodeInvalidArgument, Message: "cannot split psql script", Details: err.Error()}
+			}
+			psqlPrepared.steps = steps
+		}
+		psqlPrepared.steps = withPsqlPreamble(psqlPrepared.steps, preamble)
+		mountsPrepared, err := preparePsqlMounts(req.Mounts)
 		if err != nil {
 			return preparedRequest{}, err
 		}
@@ -985,16 +1404,39 @@ func (m *PrepareService) prepareRequest(req Request) (preparedRequest, error) {
 			psqlInputs:     psqlPrepared.inputs,
 			psqlSteps:      psqlPrepared.steps,
 			psqlWorkDir:    psqlPrepared.workDir,
+			psqlLimits:     psqlPrepared.limits,
+			psqlMounts:     mountsPrepared.mounts,
+			psqlMountsHash: mountsPrepared.hash,
+			psqlSingleTx:   singleTx,
+
+			psqlNormalizeHash: m.psqlNormalizeHash(),
 		}
 	case "lb":
+		if len(req.Mounts) > 0 {
+			return preparedRequest{}, ValidationError{Code: ErrorCodeInvalidArgument, Message: "mounts are only supported for psql", Details: kind}
+		}
 		cwd, _ := os.Getwd()
 		execMode := normalizeExecMode(req.LiquibaseExecMode)
 		execPath := strings.TrimSpace(req.LiquibaseExec)
 		windowsMode := shouldUseWindowsBat(execPath, execMode)
-		lbPrepared, err := prepareLiquibaseArgs(req.LiquibaseArgs, cwd, windowsMode, usesContainerLiquibaseRunner(m.liquibase))
+		var stdinChangelog liquibaseStdinChangelog
+		if req.LiquibaseStdin != nil {
+			changelog, err := m.prepareLiquibaseStdin(req)
+			if err != nil {
+				return preparedRequest{}, err
+			}
+			stdinChangelog = changelog
+		} else if strings.TrimSpace(req.LiquibaseStdinFormat) != "" {
+			return preparedRequest{}, ValidationError{Code: ErrorCodeInvalidArgument, Message: "liquibase_stdin_format requires liquibase_stdin"}
+		}
+		rewritePaths := usesContainerLiquibaseRunner(m.liquibase)
+		lbPrepared, err := prepareLiquibaseArgs(req.LiquibaseArgs, cwd, windowsMode, rewritePaths)
 		if err != nil {
 			return preparedRequest{}, err
 		}
+		if stdinChangelog.path != "" {
+			lbPrepared = withLiquibaseStdinChangelog(lbPrepared, stdinChangelog.path, rewritePaths && !windowsMode)
+		}
 		prepared = preparedRequest{
 			request:              req,
 			normalizedArgs:       lbPrepared.normalizedArgs,
@@ -1003,6 +1445,23 @@ func (m *PrepareService) prepareRequest(req Request) (preparedRequest, error) {
 			liquibaseLockPaths:   lbPrepared.lockPaths,
 			liquibaseSearchPaths: lbPrepared.searchPaths,
 			liquibaseWorkDir:     lbPrepared.workDir,
+			liquibaseStdinHash:   stdinChangelog.hash,
+			liquibaseStdinPath:   stdinChangelog.path,
+		}
+	case "flyway":
+		if len(req.Mounts) > 0 {
+			return preparedRequest{}, ValidationError{Code: ErrorCodeInvalidArgument, Message: "mounts are only supported for psql", Details: kind}
+		}
+		cwd, _ := os.Getwd()
+		flywayPrepared, err := prepareFlywayArgs(req.FlywayArgs, cwd)
+		if err != nil {
+			return preparedRequest{}, err
+		}
+		prepared = preparedRequest{
+			request:        req,
+			normalizedArgs: flywayPrepared.normalizedArgs,
+			argsNormalized: flywayPrepared.argsNormalized,
+			flywayWorkDir:  flywayPrepared.workDir,
 		}
 	}
 	resolvedImageID := ""
@@ -1013,6 +1472,68 @@ func (m *PrepareService) prepareRequest(req Request) (preparedRequest, error) {
 	return prepared, nil
 }
 
+// normalizeInstanceMode defaults an empty mode to ephemeral and rejects
+// anything other than ephemeral or persistent.
+func normalizeInstanceMode(mode string) (string, error) {
+	mode = strings.ToLower(strings.TrimSpace(mode))
+	switch mode {
+	case "":
+		return instanceModeEphemeral, nil
+	case instanceModeEphemeral, instanceModePersistent:
+		return mode, nil
+	default:
+		return "", ValidationError{Code: ErrorCodeInvalidArgument, Message: "instance_mode must be ephemeral or persistent", Details: mode}
+	}
+}
+
+// normalizeNamespace trims the namespace and rejects values that are not safe
+// to use as a single path segment: lowercase letters, digits, '-' and '_',
+// starting with a letter or digit, at most 63 characters.
+func normalizeNamespace(namespace string) (string, error) {
+	namespace = strings.TrimSpace(namespace)
+	if namespace == "" {
+		return "", nil
+	}
+	if len(namespace) > 63 {
+		return "", ValidationError{Code: ErrorCodeInvalidArgument, Message: "namespace is too long", Details: namespace}
+	}
+	for i, r := range namespace {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+		case (r == '-' || r == '_') && i > 0:
+		default:
+			return "", ValidationError{Code: ErrorCodeInvalidArgument, Message: "namespace must contain only lowercase letters, digits, '-' or '_'", Details: namespace}
+		}
+	}
+	return namespace, nil
+}
+
+// normalizeLabels trims label keys and values. Keys must be non-empty and may
+// not contain '=' or ',', which separate labels in filters.
+func normalizeLabels(labels map[string]string) (map[string]string, error) {
+	if len(labels) == 0 {
+		return nil, nil
+	}
+	normalized := make(map[string]string, len(labels))
+	for key, value := range labels {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			return nil, ValidationError{Code: ErrorCodeInvalidArgument, Message: "label key is required"}
+		}
+		if len(key) > 63 || strings.ContainsAny(key, "=,") {
+			return nil, ValidationError{Code: ErrorCodeInvalidArgument, Message: "label key must be at most 63 characters without '=' or ','", Details: key}
+		}
+		normalized[key] = strings.TrimSpace(value)
+	}
+	return normalized, nil
+}
+
+// plansFromRuntime reports whether the plan, and therefore the job signature,
+// depends on querying a running instance for pending migrations.
+func plansFromRuntime(kind string) bool {
+	return kind == "lb" || kind == "flyway"
+}
+
 func usesContainerLiquibaseRunner(r liquibaseRunner) bool {
 	switch r.(type) {
 	case containerLiquibaseRunner, *containerLiquibaseRunner:
@@ -1028,8 +1549,10 @@ func (c *jobCoordinator) buildPlan(ctx context.Context, jobID string, prepared p
 		return c.buildPlanPsql(prepared)
 	case "lb":
 		return c.buildPlanLiquibase(ctx, jobID, prepared)
+	case "flyway":
+		return c.buildPlanFlyway(ctx, jobID, prepared)
 	default:
-		return nil, "", errorResponse("internal_error", "unsupported prepare kind", prepared.request.PrepareKind)
+		return nil, "", errorResponse(ErrorCodeInternal, "unsupported prepare kind", prepared.request.PrepareKind)
 	}
 }
 
@@ -1037,7 +1560,7 @@ func (c *jobCoordinator) buildPlanPsql(prepared preparedRequest) ([]PlanTask, st
 	m := c.m
 	imageID := prepared.effectiveImageID()
 	if strings.TrimSpace(imageID) == "" {
-		return nil, "", errorResponse("internal_error", "resolved image id is required", "")
+		return nil, "", errorResponse(ErrorCodeInternal, "resolved image id is required", "")
 	}
 
 	steps := prepared.psqlSteps
@@ -1055,31 +1578,23 @@ func (c *jobCoordinator) buildPlanPsql(prepared preparedRequest) ([]PlanTask, st
 		Type:        "plan",
 		PlannerKind: prepared.request.PrepareKind,
 	})
-	if needsImageResolve(prepared.request.ImageID) {
-		tasks = append(tasks, PlanTask{
-			TaskID:          "resolve-image",
-			Type:            "resolve_image",
-			ImageID:         prepared.request.ImageID,
-			ResolvedImageID: imageID,
-		})
-	}
+	tasks = append(tasks, imagePlanTasks(prepared, imageID)...)
 
-	inputKind := "image"
-	inputID := imageID
+	inputKind, inputID := prepared.baseInput()
 	stateID := ""
 	for i, step := range steps {
-		digest, err := computePsqlContentDigest(step.inputs, prepared.psqlWorkDir)
+		digest, err := computePsqlContentDigest(step.inputs, prepared.psqlWorkDir, prepared.psqlLimits, prepared.psqlNormalizeHash)
 		if err != nil {
-			return nil, "", errorResponse("invalid_argument", "cannot compute psql content hash", err.Error())
+			return nil, "", errorResponse(ErrorCodeInvalidArgument, "cannot compute psql content hash", err.Error())
 		}
-		taskHash := psqlTaskHash(prepared.request.PrepareKind, digest.hash, m.version)
-		outputStateID, errResp := m.computeOutputStateID(inputKind, inputID, taskHash)
+		taskHash := psqlPreparedTaskHash(prepared, digest.hash, m.cacheVersion())
+		outputStateID, errResp := m.computeOutputStateID(prepared.request.Namespace, inputKind, inputID, taskHash)
 		if errResp != nil {
 			return nil, "", errResp
 		}
-		cached, err := m.isStateCached(outputStateID)
+		cached, err := m.isStateCachedForPlan(prepared, outputStateID)
 		if err != nil {
-			return nil, "", errorResponse("internal_error", "cannot check state cache", err.Error())
+			return nil, "", errorResponse(ErrorCodeInternal, "cannot check state cache", err.Error())
 		}
 		cachedFlag := cached
 		tasks = append(tasks, PlanTask{
@@ -1098,7 +1613,7 @@ func (c *jobCoordinator) buildPlanPsql(prepared preparedRequest) ([]PlanTask, st
 		stateID = outputStateID
 	}
 	if strings.TrimSpace(stateID) == "" {
-		return nil, "", errorResponse("internal_error", "missing output state", "")
+		return nil, "", errorResponse(ErrorCodeInternal, "missing output state", "")
 	}
 	tasks = append(tasks, PlanTask{
 		TaskID: "prepare-instance",
@@ -1107,16 +1622,19 @@ func (c *jobCoordinator) buildPlanPsql(prepared preparedRequest) ([]PlanTask, st
 			Kind: "state",
 			ID:   stateID,
 		},
-		InstanceMode: "ephemeral",
+		InstanceMode: prepared.instanceMode(),
 	})
 	return tasks, stateID, nil
 }
 
+// buildPlanLiquibase plans one state_execute task per pending changeset, each
+// on top of the previous one. The chain stays linear even across contexts:
+// sibling states cannot be merged (see liquibase-integration.md, 7.6).
 func (c *jobCoordinator) buildPlanLiquibase(ctx context.Context, jobID string, prepared preparedRequest) ([]PlanTask, string, *ErrorResponse) {
 	m := c.m
 	imageID := prepared.effectiveImageID()
 	if strings.TrimSpace(imageID) == "" {
-		return nil, "", errorResponse("internal_error", "resolved image id is required", "")
+		return nil, "", errorResponse(ErrorCodeInternal, "resolved image id is required", "")
 	}
 	changesets, errResp := c.planLiquibaseChangesets(ctx, jobID, prepared)
 	if errResp != nil {
@@ -1129,29 +1647,21 @@ func (c *jobCoordinator) buildPlanLiquibase(ctx context.Context, jobID string, p
 		Type:        "plan",
 		PlannerKind: prepared.request.PrepareKind,
 	})
-	if needsImageResolve(prepared.request.ImageID) {
-		tasks = append(tasks, PlanTask{
-			TaskID:          "resolve-image",
-			Type:            "resolve_image",
-			ImageID:         prepared.request.ImageID,
-			ResolvedImageID: imageID,
-		})
-	}
+	tasks = append(tasks, imagePlanTasks(prepared, imageID)...)
 
-	inputKind := "image"
-	inputID := imageID
+	inputKind, inputID := prepared.baseInput()
 	prevFingerprintID := inputID
 	stateID := ""
 
 	if len(changesets) == 0 {
-		taskHash := liquibaseFingerprint(prevFingerprintID, nil)
-		outputStateID, errResp := m.computeOutputStateID(inputKind, inputID, taskHash)
+		taskHash := prepared.liquibaseTaskHash(prevFingerprintID, nil)
+		outputStateID, errResp := m.computeOutputStateID(prepared.request.Namespace, inputKind, inputID, taskHash)
 		if errResp != nil {
 			return nil, "", errResp
 		}
-		cached, err := m.isStateCached(outputStateID)
+		cached, err := m.isStateCachedForPlan(prepared, outputStateID)
 		if err != nil {
-			return nil, "", errorResponse("internal_error", "cannot check state cache", err.Error())
+			return nil, "", errorResponse(ErrorCodeInternal, "cannot check state cache", err.Error())
 		}
 		cachedFlag := cached
 		tasks = append(tasks, PlanTask{
@@ -1168,14 +1678,14 @@ func (c *jobCoordinator) buildPlanLiquibase(ctx context.Context, jobID string, p
 		stateID = outputStateID
 	} else {
 		for i, changeset := range changesets {
-			taskHash := liquibaseFingerprint(prevFingerprintID, []LiquibaseChangeset{changeset})
-			outputStateID, errResp := m.computeOutputStateID(inputKind, inputID, taskHash)
+			taskHash := prepared.liquibaseTaskHash(prevFingerprintID, []LiquibaseChangeset{changeset})
+			outputStateID, errResp := m.computeOutputStateID(prepared.request.Namespace, inputKind, inputID, taskHash)
 			if errResp != nil {
 				return nil, "", errResp
 			}
-			cached, err := m.isStateCached(outputStateID)
+			cached, err := m.isStateCachedForPlan(prepared, outputStateID)
 			if err != nil {
-				return nil, "", errorResponse("internal_error", "cannot check state cache", err.Error())
+				return nil, "", errorResponse(ErrorCodeInternal, "cannot check state cache", err.Error())
 			}
 			cachedFlag := cached
 			tasks = append(tasks, PlanTask{
@@ -1200,7 +1710,7 @@ func (c *jobCoordinator) buildPlanLiquibase(ctx context.Context, jobID string, p
 	}
 
 	if strings.TrimSpace(stateID) == "" {
-		return nil, "", errorResponse("internal_error", "missing output state", "")
+		return nil, "", errorResponse(ErrorCodeInternal, "missing output state", "")
 	}
 	tasks = append(tasks, PlanTask{
 		TaskID: "prepare-instance",
@@ -1209,7 +1719,7 @@ func (c *jobCoordinator) buildPlanLiquibase(ctx context.Context, jobID string, p
 			Kind: "state",
 			ID:   stateID,
 		},
-		InstanceMode: "ephemeral",
+		InstanceMode: prepared.instanceMode(),
 	})
 	return tasks, stateID, nil
 }
@@ -1217,120 +1727,234 @@ func (c *jobCoordinator) buildPlanLiquibase(ctx context.Context, jobID string, p
 func (c *jobCoordinator) planLiquibaseChangesets(ctx context.Context, jobID string, prepared preparedRequest) ([]LiquibaseChangeset, *ErrorResponse) {
 	m := c.m
 	if m.liquibase == nil {
-		return nil, errorResponse("internal_error", "liquibase runner is required", "")
+		return nil, errorResponse(ErrorCodeInternal, "liquibase runner is required", "")
 	}
 	imageID := prepared.effectiveImageID()
 	if strings.TrimSpace(imageID) == "" {
-		return nil, errorResponse("internal_error", "resolved image id is required", "")
+		return nil, errorResponse(ErrorCodeInternal, "resolved image id is required", "")
 	}
 
-	var rt *jobRuntime
+	changelogHash, err := liquibaseChangelogHash(prepared)
+	if err != nil {
+		m.logWarnJob(jobID, "cannot hash liquibase changelog: %v", err)
+		changelogHash = ""
+	}
+	if changesets, ok := m.loadLiquibasePlanCache(ctx, jobID, changelogHash); ok {
+		m.logDebugJob(jobID, "liquibase plan restored from job record changesets=%d", len(changesets))
+		return changesets, nil
+	}
+
+	rt, release, errResp := c.planningRuntime(ctx, jobID, prepared)
+	if errResp != nil {
+		return nil, errResp
+	}
+	defer release()
+	lock, errResp := ensureLiquibaseContentLock(prepared, "")
+	if errResp != nil {
+		return nil, errResp
+	}
+	defer lock.Close()
+	changesets, errResp := c.executor.runLiquibaseUpdateSQL(ctx, jobID, prepared, rt)
+	if errResp != nil {
+		return nil, errResp
+	}
+	m.storeLiquibasePlanCache(ctx, jobID, changelogHash, changesets)
+	return changesets, nil
+}
+
+// planningRuntime returns an instance to plan against: the job's own runtime,
+// which execution then reuses, or a temporary one for plan-only requests. It
+// starts from the job's first input, so a base state is planned against.
+func (c *jobCoordinator) planningRuntime(ctx context.Context, jobID string, prepared preparedRequest) (*jobRuntime, func(), *ErrorResponse) {
+	m := c.m
+	inputKind, inputID := prepared.baseInput()
+	input := &TaskInput{Kind: inputKind, ID: inputID}
 	runner := m.getRunner(jobID)
 	if !prepared.request.PlanOnly && runner != nil {
-		planned, errResp := c.executor.ensureRuntime(ctx, jobID, prepared, &TaskInput{Kind: "image", ID: imageID}, runner)
+		planned, errResp := c.executor.ensureRuntime(ctx, jobID, prepared, input, runner)
 		if errResp != nil {
-			return nil, errResp
+			return nil, nil, errResp
 		}
-		rt = planned
-	} else {
-		temp := &jobRunner{}
-		planned, errResp := c.executor.startRuntime(ctx, jobID, prepared, &TaskInput{Kind: "image", ID: imageID})
-		if errResp != nil {
-			return nil, errResp
-		}
-		temp.setRuntime(planned)
-		defer m.cleanupRuntime(context.Background(), temp)
-		rt = planned
+		return planned, func() {}, nil
 	}
-	lock, errResp := ensureLiquibaseContentLock(prepared, "")
+	temp := &jobRunner{}
+	planned, errResp := c.executor.startRuntime(ctx, jobID, prepared, input)
 	if errResp != nil {
-		return nil, errResp
+		return nil, nil, errResp
 	}
-	defer lock.Close()
-	return c.executor.runLiquibaseUpdateSQL(ctx, jobID, prepared, rt)
+	temp.setRuntime(planned)
+	return planned, func() {
+		m.cleanupRuntime(context.Background(), temp)
+	}, nil
 }
 
 func (e *taskExecutor) runLiquibaseUpdateSQL(ctx context.Context, jobID string, prepared preparedRequest, rt *jobRuntime) ([]LiquibaseChangeset, *ErrorResponse) {
 	m := e.m
 	if m.liquibase == nil {
-		return nil, errorResponse("internal_error", "liquibase runner is required", "")
+		return nil, errorResponse(ErrorCodeInternal, "liquibase runner is required", "")
 	}
 	if rt == nil {
-		return nil, errorResponse("internal_error", "runtime instance is required", "")
+		return nil, errorResponse(ErrorCodeInternal, "runtime instance is required", "")
 	}
 	if strings.TrimSpace(rt.instance.Host) == "" || rt.instance.Port == 0 {
-		return nil, errorResponse("internal_error", "runtime instance is missing connection info", "")
+		return nil, errorResponse(ErrorCodeInternal, "runtime instance is missing connection info", "")
 	}
 
-	execMode := normalizeExecMode(prepared.request.LiquibaseExecMode)
-	rawExecPath := strings.TrimSpace(prepared.request.LiquibaseExec)
-	windowsMode := shouldUseWindowsBat(rawExecPath, execMode)
-	execPath, err := normalizeLiquibaseExecPath(rawExecPath, windowsMode)
-	if err != nil {
-		return nil, errorResponse("internal_error", "cannot resolve liquibase executable", err.Error())
-	}
-	var mapper PathMapper
-	if windowsMode && isWSL() {
-		mapper = wslPathMapper{}
-	}
-	args, err := mapLiquibaseArgs(prepared.normalizedArgs, mapper)
-	if err != nil {
-		return nil, errorResponse("internal_error", "cannot map liquibase arguments", err.Error())
-	}
-	workDir := strings.TrimSpace(prepared.request.WorkDir)
-	if windowsMode && workDir == "" {
-		workDir = deriveLiquibaseWorkDir(args)
-	}
-	if workDir != "" && mapper != nil {
-		mappedDir, mapErr := mapper.MapPath(workDir)
-		if mapErr != nil {
-			return nil, errorResponse("internal_error", "cannot map liquibase workdir", mapErr.Error())
-		}
-		workDir = mappedDir
-	}
-	if !windowsMode {
-		args = relativizeLiquibaseHostFileArgs(args, workDir)
+	inv, errResp := e.liquibaseInvocation(ctx, prepared)
+	if errResp != nil {
+		return nil, errResp
 	}
-	args = replaceLiquibaseCommand(args, "updateSQL")
-	args = prependLiquibaseConnectionArgs(args, rt.instance, windowsMode)
-	env, err := mapLiquibaseEnv(prepared.request.LiquibaseEnv, windowsMode)
-	if err != nil {
-		return nil, errorResponse("internal_error", "cannot map liquibase env", err.Error())
+	if errResp := e.ensureLiquibaseLockReleased(ctx, jobID, prepared, rt, inv); errResp != nil {
+		return nil, errResp
 	}
+	args := replaceLiquibaseCommand(inv.args, "updateSQL")
+	args = prependLiquibaseConnectionArgs(args, rt.instance, inv.windowsMode, m.postgresSuperuser())
 
-	execLine := formatExecLine(execPath, args)
+	execLine := formatExecLine(inv.execPath, args)
 	m.appendLog(jobID, fmt.Sprintf("liquibase: exec %s", execLine))
-	m.logJob(jobID, "liquibase exec %s", execLine)
+	m.logDebugJob(jobID, "liquibase exec %s", execLine)
 	m.appendLog(jobID, "liquibase: start")
 	lbCtx := runtime.WithLogSink(ctx, func(line string) {
-		m.appendLog(jobID, "liquibase: "+line)
+		m.appendLog(jobID, "liquibase: "+inv.secrets.redact(line))
 	})
 	output, err := m.liquibase.Run(lbCtx, LiquibaseRunRequest{
-		ExecPath: execPath,
-		ExecMode: execMode,
+		ExecPath: inv.execPath,
+		ExecMode: inv.execMode,
 		Args:     args,
-		Env:      env,
-		WorkDir:  workDir,
+		Env:      inv.env,
+		WorkDir:  inv.workDir,
 		Mounts:   prepared.liquibaseMounts,
-		Network:  "",
+		Network:  prepared.request.Network,
+		DNS:      prepared.request.DNS,
 	})
 	if err != nil {
 		if ctx.Err() != nil {
-			return nil, errorResponse("cancelled", "task cancelled", "")
+			return nil, errorResponse(ErrorCodeCancelled, "task cancelled", "")
 		}
-		details := strings.TrimSpace(output)
+		details := strings.TrimSpace(inv.secrets.redact(output))
 		if details == "" {
-			details = err.Error()
+			details = inv.secrets.redact(err.Error())
 		}
-		return nil, errorResponse("internal_error", "liquibase execution failed", details)
+		return nil, errorResponse(ErrorCodeMigrationFailed, "liquibase execution failed", details)
 	}
 	changesets, err := parseLiquibaseUpdateSQL(output)
 	if err != nil {
-		return nil, errorResponse("invalid_argument", "cannot parse liquibase changesets", err.Error())
+		return nil, errorResponse(ErrorCodeInvalidArgument, "cannot parse liquibase changesets", err.Error())
 	}
 	return changesets, nil
 }
 
+func (c *jobCoordinator) buildPlanFlyway(ctx context.Context, jobID string, prepared preparedRequest) ([]PlanTask, string, *ErrorResponse) {
+	m := c.m
+	imageID := prepared.effectiveImageID()
+	if strings.TrimSpace(imageID) == "" {
+		return nil, "", errorResponse(ErrorCodeInternal, "resolved image id is required", "")
+	}
+	migrations, errResp := c.planFlywayMigrations(ctx, jobID, prepared)
+	if errResp != nil {
+		return nil, "", errResp
+	}
+
+	tasks := make([]PlanTask, 0, 3+len(migrations))
+	tasks = append(tasks, PlanTask{
+		TaskID:      "plan",
+		Type:        "plan",
+		PlannerKind: prepared.request.PrepareKind,
+	})
+	tasks = append(tasks, imagePlanTasks(prepared, imageID)...)
+
+	// Each pending migration is its own cache boundary; with nothing pending a
+	// single migrate task still snapshots the base image state.
+	steps := make([][]FlywayMigration, 0, len(migrations))
+	for _, migration := range migrations {
+		steps = append(steps, []FlywayMigration{migration})
+	}
+	if len(steps) == 0 {
+		steps = append(steps, nil)
+	}
+
+	inputKind, inputID := prepared.baseInput()
+	stateID := ""
+	for i, step := range steps {
+		taskHash := flywayFingerprint(inputID, prepared.argsNormalized, step)
+		outputStateID, errResp := m.computeOutputStateID(prepared.request.Namespace, inputKind, inputID, taskHash)
+		if errResp != nil {
+			return nil, "", errResp
+		}
+		cached, err := m.isStateCachedForPlan(prepared, outputStateID)
+		if err != nil {
+			return nil, "", errorResponse(ErrorCodeInternal, "cannot check state cache", err.Error())
+		}
+		cachedFlag := cached
+		task := PlanTask{
+			TaskID: fmt.Sprintf("execute-%d", i),
+			Type:   "state_execute",
+			Input: &TaskInput{
+				Kind: inputKind,
+				ID:   inputID,
+			},
+			TaskHash:      taskHash,
+			OutputStateID: outputStateID,
+			Cached:        &cachedFlag,
+		}
+		if len(step) > 0 {
+			task.ChangesetID = step[0].Version
+			task.ChangesetPath = step[0].Script
+		}
+		tasks = append(tasks, task)
+		inputKind = "state"
+		inputID = outputStateID
+		stateID = outputStateID
+	}
+
+	tasks = append(tasks, PlanTask{
+		TaskID: "prepare-instance",
+		Type:   "prepare_instance",
+		Input: &TaskInput{
+			Kind: "state",
+			ID:   stateID,
+		},
+		InstanceMode: prepared.instanceMode(),
+	})
+	return tasks, stateID, nil
+}
+
+func (c *jobCoordinator) planFlywayMigrations(ctx context.Context, jobID string, prepared preparedRequest) ([]FlywayMigration, *ErrorResponse) {
+	m := c.m
+	if m.flyway == nil {
+		return nil, errorResponse(ErrorCodeInternal, "flyway runner is required", "")
+	}
+	imageID := prepared.effectiveImageID()
+	if strings.TrimSpace(imageID) == "" {
+		return nil, errorResponse(ErrorCodeInternal, "resolved image id is required", "")
+	}
+	rt, release, errResp := c.planningRuntime(ctx, jobID, prepared)
+	if errResp != nil {
+		return nil, errResp
+	}
+	defer release()
+	return c.executor.runFlywayInfo(ctx, jobID, prepared, rt)
+}
+
+func (e *taskExecutor) runFlywayInfo(ctx context.Context, jobID string, prepared preparedRequest, rt *jobRuntime) ([]FlywayMigration, *ErrorResponse) {
+	output, errResp := e.runFlyway(ctx, jobID, prepared, rt, flywayInfoArgs(prepared.normalizedArgs))
+	if errResp != nil {
+		return nil, errResp
+	}
+	migrations, err := parseFlywayInfo(output)
+	if err != nil {
+		return nil, errorResponse(ErrorCodeInvalidArgument, "cannot parse flyway info", err.Error())
+	}
+	workDir := strings.TrimSpace(prepared.request.WorkDir)
+	if workDir == "" {
+		workDir = prepared.flywayWorkDir
+	}
+	if err := hashFlywayScripts(migrations, workDir); err != nil {
+		return nil, errorResponse(ErrorCodeInvalidArgument, "cannot read flyway migration script", err.Error())
+	}
+	return migrations, nil
+}
+
 func relativizeLiquibaseHostFileArgs(args []string, workDir string) []string {
 	base := strings.TrimSpace(workDir)
 	if base == "" {
@@ -1396,13 +2020,13 @@ func relativizeLiquibaseHostPath(value string, base string) string {
 
 func (m *PrepareService) computeTaskHash(prepared preparedRequest) (string, *ErrorResponse) {
 	if prepared.request.PrepareKind == "psql" {
-		digest, err := computePsqlContentDigest(prepared.psqlInputs, prepared.psqlWorkDir)
+		digest, err := computePsqlContentDigest(prepared.psqlInputs, prepared.psqlWorkDir, prepared.psqlLimits, prepared.psqlNormalizeHash)
 		if err != nil {
-			return "", errorResponse("invalid_argument", "cannot compute psql content hash", err.Error())
+			return "", errorResponse(ErrorCodeInvalidArgument, "cannot compute psql content hash", err.Error())
 		}
-		taskHash := psqlTaskHash(prepared.request.PrepareKind, digest.hash, m.version)
+		taskHash := psqlPreparedTaskHash(prepared, digest.hash, m.cacheVersion())
 		if taskHash == "" {
-			return "", errorResponse("internal_error", "cannot compute task hash", "")
+			return "", errorResponse(ErrorCodeInternal, "cannot compute task hash", "")
 		}
 		return taskHash, nil
 	}
@@ -1411,10 +2035,10 @@ func (m *PrepareService) computeTaskHash(prepared preparedRequest) (string, *Err
 	for i, arg := range prepared.normalizedArgs {
 		hasher.write(fmt.Sprintf("arg:%d", i), arg)
 	}
-	hasher.write("engine_version", m.version)
+	hasher.write("engine_version", m.cacheVersion())
 	taskHash := hasher.sum()
 	if taskHash == "" {
-		return "", errorResponse("internal_error", "cannot compute task hash", "")
+		return "", errorResponse(ErrorCodeInternal, "cannot compute task hash", "")
 	}
 	return taskHash, nil
 }
@@ -1434,18 +2058,36 @@ func psqlTaskHashWithSchema(kind string, contentHash string, engineVersion strin
 	return hasher.sum()
 }
 
-func (m *PrepareService) computeOutputStateID(inputKind, inputID, taskHash string) (string, *ErrorResponse) {
+func (m *PrepareService) computeOutputStateID(namespace, inputKind, inputID, taskHash string) (string, *ErrorResponse) {
 	hasher := newStateHasher()
+	if namespace != "" {
+		hasher.write("namespace", namespace)
+	}
 	hasher.write("input_kind", inputKind)
 	hasher.write("input_id", inputID)
 	hasher.write("task_hash", taskHash)
+	m.writeSuperuserKey(hasher)
 	stateID := hasher.sum()
 	if stateID == "" {
-		return "", errorResponse("internal_error", "cannot compute state id", "")
+		return "", errorResponse(ErrorCodeInternal, "cannot compute state id", "")
 	}
 	return stateID, nil
 }
 
+// writeSuperuserKey folds a non-default superuser into a state id or job
+// signature. The superuser is the role initdb bootstraps, so states built
+// under different names are not interchangeable. The default writes nothing,
+// which keeps ids from before the setting existed stable.
+func (m *PrepareService) writeSuperuserKey(hasher *stateHasher) {
+	if superuser := m.postgresSuperuser(); superuser != runtime.DefaultPostgresSuperuser {
+		hasher.write("postgres_superuser", superuser)
+	}
+}
+
+func (m *PrepareService) postgresSuperuser() string {
+	return runtime.PostgresSuperuser(m.superuser)
+}
+
 func (m *PrepareService) isStateCached(stateID string) (bool, error) {
 	if strings.TrimSpace(stateID) == "" {
 		return false, nil
@@ -1484,7 +2126,11 @@ func (m *PrepareService) updateTaskStatus(ctx context.Context, jobID string, tas
 	if err := m.queue.UpdateTask(ctx, jobID, taskID, update); err != nil {
 		return err
 	}
-	m.logTask(jobID, taskID, "status=%s", status)
+	if status == StatusFailed {
+		m.logTaskAt(loglevel.Warn, jobID, taskID, "status=%s", status)
+	} else {
+		m.logTask(jobID, taskID, "status=%s", status)
+	}
 	event := Event{
 		Type:   "task",
 		Ts:     m.now().UTC().Format(time.RFC3339Nano),
@@ -1495,9 +2141,35 @@ func (m *PrepareService) updateTaskStatus(ctx context.Context, jobID string, tas
 		event.Message = errResp.Message
 		event.Error = errResp
 	}
+	event.Progress = m.taskProgress(ctx, jobID, taskID, status)
 	return m.appendEvent(jobID, event)
 }
 
+func (m *PrepareService) taskProgress(ctx context.Context, jobID string, taskID string, status string) *TaskProgress {
+	tasks, err := m.queue.ListTasks(ctx, jobID)
+	if err != nil {
+		return nil
+	}
+	var progress *TaskProgress
+	total := 0
+	for _, task := range tasks {
+		if task.Type != "state_execute" {
+			continue
+		}
+		total++
+		if task.TaskID == taskID {
+			progress = &TaskProgress{Completed: total - 1}
+			if status == StatusSucceeded {
+				progress.Completed = total
+			}
+		}
+	}
+	if progress != nil {
+		progress.Total = total
+	}
+	return progress
+}
+
 func (m *PrepareService) succeed(jobID string, result Result) error {
 	now := m.now().UTC().Format(time.RFC3339Nano)
 	payload, err := json.Marshal(result)
@@ -1518,7 +2190,7 @@ func (m *PrepareService) succeed(jobID string, result Result) error {
 	}); err != nil {
 		return err
 	}
-	m.logJob(jobID, "succeeded instance=%s state=%s", result.InstanceID, result.StateID)
+	m.logInfoJob(jobID, "succeeded instance=%s state=%s", result.InstanceID, result.StateID)
 	if err := m.appendEvent(jobID, Event{
 		Type:   "result",
 		Ts:     now,
@@ -1538,7 +2210,7 @@ func (m *PrepareService) succeedPlan(jobID string) error {
 	}); err != nil {
 		return err
 	}
-	m.logJob(jobID, "succeeded plan_only=true")
+	m.logInfoJob(jobID, "succeeded plan_only=true")
 	if err := m.appendEvent(jobID, Event{
 		Type:   "status",
 		Ts:     now,
@@ -1551,6 +2223,7 @@ func (m *PrepareService) succeedPlan(jobID string) error {
 }
 
 func (m *PrepareService) failJob(jobID string, errResp *ErrorResponse) error {
+	m.failJobSpan(jobID, errResp)
 	now := m.now().UTC().Format(time.RFC3339Nano)
 	payload, err := json.Marshal(errResp)
 	if err != nil {
@@ -1572,12 +2245,12 @@ func (m *PrepareService) failJob(jobID string, errResp *ErrorResponse) error {
 	}
 	if errResp != nil {
 		if details := summarizeLogDetails(errResp.Details); details != "" {
-			m.logJob(jobID, "failed code=%s message=%s details=%s", errResp.Code, errResp.Message, details)
+			m.logWarnJob(jobID, "failed code=%s message=%s details=%s", errResp.Code, errResp.Message, details)
 		} else {
-			m.logJob(jobID, "failed code=%s message=%s", errResp.Code, errResp.Message)
+			m.logWarnJob(jobID, "failed code=%s message=%s", errResp.Code, errResp.Message)
 		}
 	} else {
-		m.logJob(jobID, "failed")
+		m.logWarnJob(jobID, "failed")
 	}
 	if err := m.appendEvent(jobID, Event{
 		Type:  "error",
@@ -1597,6 +2270,7 @@ func (m *PrepareService) appendEvent(jobID string, event Event) error {
 	}
 	m.updateHeartbeat(jobID, event)
 	m.events.notify(jobID)
+	m.trackEventRetention(jobID, event)
 	return nil
 }
 
@@ -1635,6 +2309,15 @@ func (r *jobRunner) getRuntime() *jobRuntime {
 	return r.rt
 }
 
+// takeRuntime detaches the runtime so only one caller ever cleans it up.
+func (r *jobRunner) takeRuntime() *jobRuntime {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rt := r.rt
+	r.rt = nil
+	return rt
+}
+
 func (m *PrepareService) logJob(jobID string, format string, args ...any) {
 	if strings.TrimSpace(jobID) == "" {
 		log.Printf("prepare "+format, args...)
@@ -1644,7 +2327,21 @@ func (m *PrepareService) logJob(jobID string, format string, args ...any) {
 	log.Printf("prepare job=%s "+format, args...)
 }
 
-func (m *PrepareService) logTask(jobID string, taskID string, format string, args ...any) {
+// logJobAt logs a job message of the given severity when log.level allows
+// it. Callers use the per-level helpers below.
+func (m *PrepareService) logJobAt(level string, jobID string, format string, args ...any) {
+	if !loglevel.Allows(logLevelFromConfig(m.config), level) {
+		return
+	}
+	m.logJob(jobID, format, args...)
+}
+
+// logTaskAt logs a task message of the given severity when log.level allows
+// it.
+func (m *PrepareService) logTaskAt(level string, jobID string, taskID string, format string, args ...any) {
+	if !loglevel.Allows(logLevelFromConfig(m.config), level) {
+		return
+	}
 	if strings.TrimSpace(jobID) == "" || strings.TrimSpace(taskID) == "" {
 		log.Printf("prepare task "+format, args...)
 		return
@@ -1653,11 +2350,30 @@ func (m *PrepareService) logTask(jobID string, taskID string, format string, arg
 	log.Printf("prepare job=%s task=%s "+format, args...)
 }
 
+// logTask logs task-level progress, shown at info and above.
+func (m *PrepareService) logTask(jobID string, taskID string, format string, args ...any) {
+	m.logTaskAt(loglevel.Info, jobID, taskID, format, args...)
+}
+
+// logDebugJob logs detail such as container exec lines.
+func (m *PrepareService) logDebugJob(jobID string, format string, args ...any) {
+	m.logJobAt(loglevel.Debug, jobID, format, args...)
+}
+
+// logInfoJob logs job lifecycle and runtime progress.
 func (m *PrepareService) logInfoJob(jobID string, format string, args ...any) {
-	if !logLevelAllowsInfo(logLevelFromConfig(m.config)) {
-		return
-	}
-	m.logJob(jobID, format, args...)
+	m.logJobAt(loglevel.Info, jobID, format, args...)
+}
+
+// logWarnJob logs job and task failures.
+func (m *PrepareService) logWarnJob(jobID string, format string, args ...any) {
+	m.logJobAt(loglevel.Warn, jobID, format, args...)
+}
+
+// logErrorJob logs engine-side errors that are not a job's own failure, such
+// as store lookups and cleanup.
+func (m *PrepareService) logErrorJob(jobID string, format string, args ...any) {
+	m.logJobAt(loglevel.Error, jobID, format, args...)
 }
 
 func (m *PrepareService) appendLog(jobID string, message string) {
@@ -1671,6 +2387,27 @@ func (m *PrepareService) appendLog(jobID string, message string) {
 	})
 }
 
+// appendPlanEvent publishes a freshly built plan before any task runs, so
+// streaming clients can see which states are cached and which will be built.
+func (m *PrepareService) appendPlanEvent(jobID string, tasks []PlanTask) {
+	total, cached := 0, 0
+	for _, task := range tasks {
+		if task.Type != "state_execute" {
+			continue
+		}
+		total++
+		if task.Cached != nil && *task.Cached {
+			cached++
+		}
+	}
+	_ = m.appendEvent(jobID, Event{
+		Type:    "plan",
+		Ts:      m.now().UTC().Format(time.RFC3339Nano),
+		Message: fmt.Sprintf("%d of %d states cached", cached, total),
+		Plan:    tasks,
+	})
+}
+
 func (m *PrepareService) appendLogLines(jobID string, prefix string, content string) {
 	content = strings.TrimSpace(content)
 	if content == "" {
@@ -1689,15 +2426,20 @@ func (m *PrepareService) appendLogLines(jobID string, prefix string, content str
 	}
 }
 
-func normalizeHeartbeat(value time.Duration) time.Duration {
+// normalizeHeartbeat clamps a heartbeat interval to [200ms, ceiling]; a zero
+// value selects the 500ms default.
+func normalizeHeartbeat(value time.Duration, ceiling time.Duration) time.Duration {
+	if ceiling < minHeartbeat {
+		ceiling = minHeartbeat
+	}
 	if value <= 0 {
-		return 500 * time.Millisecond
+		return defaultHeartbeat
 	}
-	if value < 200*time.Millisecond {
-		return 200 * time.Millisecond
+	if value < minHeartbeat {
+		return minHeartbeat
 	}
-	if value > time.Second {
-		return time.Second
+	if value > ceiling {
+		return ceiling
 	}
 	return value
 }
@@ -1710,7 +2452,7 @@ type heartbeatState struct {
 }
 
 func (m *PrepareService) updateHeartbeat(jobID string, event Event) {
-	if strings.TrimSpace(jobID) == "" {
+	if strings.TrimSpace(jobID) == "" || event.Type == "ping" {
 		return
 	}
 	m.mu.Lock()
@@ -1769,10 +2511,14 @@ func (m *PrepareService) startHeartbeat(jobID string) {
 	}
 	ctx, cancel := context.WithCancel(context.Background())
 	state.cancel = cancel
+	every := m.heartbeatEvery
+	if runner := m.running[jobID]; runner != nil && runner.heartbeatEvery > 0 {
+		every = runner.heartbeatEvery
+	}
 	m.mu.Unlock()
 
 	go func() {
-		ticker := time.NewTicker(m.heartbeatEvery)
+		ticker := time.NewTicker(every)
 		defer ticker.Stop()
 		for {
 			select {
@@ -1780,26 +2526,28 @@ func (m *PrepareService) startHeartbeat(jobID string) {
 				return
 			case <-ticker.C:
 			}
-			var emit *Event
+			taskID := ""
 			var lastEventAt time.Time
 			m.mu.Lock()
 			state := m.beats[jobID]
 			if state != nil {
 				lastEventAt = state.lastEventAt
-				if state.lastEvent != nil && state.runningTask != "" {
-					copy := *state.lastEvent
-					emit = &copy
+				if state.lastEvent != nil {
+					taskID = state.runningTask
 				}
 			}
 			m.mu.Unlock()
-			if emit == nil {
+			if taskID == "" {
 				return
 			}
-			if m.now().UTC().Sub(lastEventAt) < m.heartbeatEvery {
+			if m.now().UTC().Sub(lastEventAt) < every {
 				continue
 			}
-			emit.Ts = m.now().UTC().Format(time.RFC3339Nano)
-			_ = m.appendEvent(jobID, *emit)
+			_ = m.appendEvent(jobID, Event{
+				Type:   "ping",
+				Ts:     m.now().UTC().Format(time.RFC3339Nano),
+				TaskID: taskID,
+			})
 		}
 	}()
 }
@@ -1887,7 +2635,28 @@ func planTaskFromRecord(task queue.TaskRecord) PlanTask {
 		ChangesetID:     valueOrEmpty(task.ChangesetID),
 		ChangesetAuthor: valueOrEmpty(task.ChangesetAuthor),
 		ChangesetPath:   valueOrEmpty(task.ChangesetPath),
+		StartedAt:       task.StartedAt,
+		FinishedAt:      task.FinishedAt,
+		DurationMs:      taskDurationMs(task.StartedAt, task.FinishedAt),
+	}
+}
+
+// taskDurationMs derives a task's run time from its recorded timestamps. Tasks
+// that never started (for example cached states) have no duration.
+func taskDurationMs(startedAt, finishedAt *string) *int64 {
+	if startedAt == nil || finishedAt == nil {
+		return nil
+	}
+	started, err := time.Parse(time.RFC3339Nano, *startedAt)
+	if err != nil {
+		return nil
+	}
+	finished, err := time.Parse(time.RFC3339Nano, *finishedAt)
+	if err != nil || finished.Before(started) {
+		return nil
 	}
+	duration := finished.Sub(started).Milliseconds()
+	return &duration
 }
 
 // decodeJobRequest reconstructs list-surface summaries from the persisted job request.
@@ -1903,6 +2672,27 @@ func decodeJobRequest(job queue.JobRecord) *Request {
 	return &req
 }
 
+// jobLabels decodes the labels persisted with the job.
+func jobLabels(job queue.JobRecord) map[string]string {
+	if job.LabelsJSON == nil || strings.TrimSpace(*job.LabelsJSON) == "" {
+		return nil
+	}
+	var labels map[string]string
+	if err := json.Unmarshal([]byte(*job.LabelsJSON), &labels); err != nil || len(labels) == 0 {
+		return nil
+	}
+	return labels
+}
+
+// jobNamespace returns the namespace recorded in the persisted job request.
+func jobNamespace(job queue.JobRecord) string {
+	req := decodeJobRequest(job)
+	if req == nil {
+		return ""
+	}
+	return strings.TrimSpace(req.Namespace)
+}
+
 func taskEntryFromRecord(task queue.TaskRecord, req *Request) TaskEntry {
 	var input *TaskInput
 	if task.InputKind != nil && task.InputID != nil {
@@ -1928,6 +2718,9 @@ func taskEntryFromRecord(task queue.TaskRecord, req *Request) TaskEntry {
 		ChangesetID:     valueOrEmpty(task.ChangesetID),
 		ChangesetAuthor: valueOrEmpty(task.ChangesetAuthor),
 		ChangesetPath:   valueOrEmpty(task.ChangesetPath),
+		StartedAt:       task.StartedAt,
+		FinishedAt:      task.FinishedAt,
+		DurationMs:      taskDurationMs(task.StartedAt, task.FinishedAt),
 	}
 }
 
@@ -1939,6 +2732,9 @@ func taskArgsSummary(task queue.TaskRecord, req *Request) string {
 	if summary := liquibaseTaskArgsSummary(task); summary != "" {
 		return summary
 	}
+	if summary := flywayTaskArgsSummary(task, req); summary != "" {
+		return summary
+	}
 	return psqlTaskArgsSummary(task.TaskID, req)
 }
 
@@ -1952,8 +2748,19 @@ func liquibaseTaskArgsSummary(task queue.TaskRecord) string {
 	return id + "::" + author + "::" + path
 }
 
+func flywayTaskArgsSummary(task queue.TaskRecord, req *Request) string {
+	if req == nil || strings.TrimSpace(strings.ToLower(req.PrepareKind)) != "flyway" {
+		return ""
+	}
+	version := strings.TrimSpace(valueOrEmpty(task.ChangesetID))
+	if version == "" {
+		return ""
+	}
+	return "migrate -target=" + version
+}
+
 func psqlTaskArgsSummary(taskID string, req *Request) string {
-	if req == nil || strings.TrimSpace(strings.ToLower(req.PrepareKind)) != "psql" {
+	if req == nil || strings.TrimSpace(strings.ToLower(req.PrepareKind)) != "psql" || req.PsqlSplit {
 		return ""
 	}
 	steps, err := buildPsqlSteps(req.PsqlArgs, req.Stdin)
@@ -1979,12 +2786,25 @@ func psqlTaskArgsSummary(taskID string, req *Request) string {
 
 func eventFromRecord(record queue.EventRecord) Event {
 	event := Event{
+		Seq:     record.Seq,
 		Type:    record.Type,
 		Ts:      record.Ts,
 		Status:  valueOrEmpty(record.Status),
 		TaskID:  valueOrEmpty(record.TaskID),
 		Message: valueOrEmpty(record.Message),
 	}
+	if record.ProgressJSON != nil {
+		var progress TaskProgress
+		if err := json.Unmarshal([]byte(*record.ProgressJSON), &progress); err == nil {
+			event.Progress = &progress
+		}
+	}
+	if record.PlanJSON != nil {
+		var plan []PlanTask
+		if err := json.Unmarshal([]byte(*record.PlanJSON), &plan); err == nil {
+			event.Plan = plan
+		}
+	}
 	if record.ResultJSON != nil {
 		var result Result
 		if err := json.Unmarshal([]byte(*record.ResultJSON), &result); err == nil {
@@ -2019,6 +2839,16 @@ func eventRecordFromEvent(jobID string, event Event) queue.EventRecord {
 			record.ErrorJSON = strPtr(string(payload))
 		}
 	}
+	if event.Progress != nil {
+		if payload, err := json.Marshal(event.Progress); err == nil {
+			record.ProgressJSON = strPtr(string(payload))
+		}
+	}
+	if len(event.Plan) > 0 {
+		if payload, err := json.Marshal(event.Plan); err == nil {
+			record.PlanJSON = strPtr(string(payload))
+		}
+	}
 	return record
 }
 
@@ -2165,33 +2995,66 @@ func logLevelFromConfig(cfg config.Store) string {
 }
 
 func logLevelAllowsInfo(level string) bool {
-	switch strings.TrimSpace(strings.ToLower(level)) {
-	case "", "debug", "info":
-		return true
-	default:
-		return false
-	}
+	return loglevel.Allows(level, loglevel.Info)
 }
 
-func (m *PrepareService) removeJobDir(jobID string) error {
+func (m *PrepareService) removeJobDir(namespace string, jobID string) error {
 	if strings.TrimSpace(m.stateStoreRoot) == "" {
 		return nil
 	}
 	if strings.TrimSpace(jobID) == "" {
 		return nil
 	}
-	path := filepath.Join(m.stateStoreRoot, "jobs", jobID)
-	if m.statefs != nil {
-		runtimeDir := filepath.Join(path, "runtime")
-		_ = m.statefs.RemovePath(context.Background(), runtimeDir)
+	var firstErr error
+	for _, root := range m.jobRoots(namespace) {
+		path := filepath.Join(root, "jobs", jobID)
+		if m.statefs != nil {
+			runtimeDir := filepath.Join(path, "runtime")
+			_ = m.statefs.RemovePath(context.Background(), runtimeDir)
+		}
+		if err := os.RemoveAll(path); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// namespaceRoot returns the state store root for namespace; the default
+// namespace uses the state store root itself.
+func (m *PrepareService) namespaceRoot(namespace string) string {
+	return statefs.NamespaceRoot(m.stateStoreRoot, namespace)
+}
+
+// workRoot returns the scratch root for namespace: workdir.root when set,
+// otherwise the namespace's state store root.
+func (m *PrepareService) workRoot(namespace string) string {
+	if m.workDirRoot == "" {
+		return m.namespaceRoot(namespace)
+	}
+	return statefs.NamespaceRoot(m.workDirRoot, namespace)
+}
+
+// runtimeRoot returns where job runtime dirs are cloned. btrfs clones are
+// subvolume snapshots, which cannot cross filesystems, so that backend keeps
+// runtime dirs in the state store regardless of workdir.root.
+func (m *PrepareService) runtimeRoot(namespace string) string {
+	if m.statefs != nil && m.statefs.Kind() == "btrfs" {
+		return m.namespaceRoot(namespace)
 	}
-	return os.RemoveAll(path)
+	return m.workRoot(namespace)
 }
 
 func strPtr(value string) *string {
 	return &value
 }
 
+func (p preparedRequest) instanceMode() string {
+	if strings.TrimSpace(p.request.InstanceMode) == "" {
+		return instanceModeEphemeral
+	}
+	return p.request.InstanceMode
+}
+
 func (p preparedRequest) effectiveImageID() string {
 	if strings.TrimSpace(p.resolvedImageID) != "" {
 		return p.resolvedImageID
@@ -2208,13 +3071,16 @@ func hasImageDigest(imageID string) bool {
 	return at != -1 && at+1 < len(imageID)
 }
 
-func needsImageResolve(imageID string) bool {
-	return !hasImageDigest(imageID)
+// needsImageResolve reports whether imageID must be resolved to a digest. A
+// pinned digest may name a multi-platform index, so it is resolved again
+// when a platform is requested.
+func needsImageResolve(imageID string, platform string) bool {
+	return platform != "" || !hasImageDigest(imageID)
 }
 
 func (m *PrepareService) ensureResolvedImageID(ctx context.Context, jobID string, prepared *preparedRequest, tasks []queue.TaskRecord) *ErrorResponse {
 	if prepared == nil {
-		return errorResponse("internal_error", "prepared request is required", "")
+		return errorResponse(ErrorCodeInternal, "prepared request is required", "")
 	}
 	if strings.TrimSpace(prepared.resolvedImageID) != "" {
 		return nil
@@ -2227,21 +3093,32 @@ func (m *PrepareService) ensureResolvedImageID(ctx context.Context, jobID string
 		prepared.resolvedImageID = prepared.request.ImageID
 		return nil
 	}
-	if !needsImageResolve(prepared.request.ImageID) {
+	if prepared.request.Build != nil {
+		return m.resolveBuildImage(prepared)
+	}
+	if !needsImageResolve(prepared.request.ImageID, prepared.request.Platform) {
 		prepared.resolvedImageID = prepared.request.ImageID
 		return nil
 	}
+	if m.containerOffline() {
+		return m.resolveLocalImage(ctx, jobID, prepared)
+	}
 	m.appendLog(jobID, fmt.Sprintf("resolve image %s", prepared.request.ImageID))
 	ctx = runtime.WithLogSink(ctx, func(line string) {
 		m.appendLog(jobID, "docker: "+line)
 	})
-	resolved, err := m.runtime.ResolveImage(ctx, prepared.request.ImageID)
+	var resolved string
+	err := m.withRuntimeRetry(ctx, jobID, "image resolve", func() error {
+		var resolveErr error
+		resolved, resolveErr = m.runtime.ResolveImage(ctx, prepared.request.ImageID, prepared.request.Platform)
+		return resolveErr
+	})
 	if err != nil {
-		return errorResponse("internal_error", "cannot resolve image", err.Error())
+		return errorResponse(runtimeErrorCode(err, ErrorCodeImageResolveFailed), "cannot resolve image", err.Error())
 	}
 	resolved = strings.TrimSpace(resolved)
 	if resolved == "" {
-		return errorResponse("internal_error", "resolved image id is required", "")
+		return errorResponse(ErrorCodeInternal, "resolved image id is required", "")
 	}
 	m.appendLog(jobID, fmt.Sprintf("resolved image %s", resolved))
 	prepared.resolvedImageID = resolved
@@ -2250,7 +3127,7 @@ func (m *PrepareService) ensureResolvedImageID(ctx context.Context, jobID string
 
 func resolvedImageFromTasks(tasks []queue.TaskRecord) string {
 	for _, task := range tasks {
-		if task.Type != "resolve_image" {
+		if task.Type != "resolve_image" && task.Type != "build_image" {
 			continue
 		}
 		resolved := valueOrEmpty(task.ResolvedImageID)
@@ -2266,11 +3143,17 @@ var errJobNotFound = fmt.Errorf("job not found")
 type eventBus struct {
 	mu   sync.Mutex
 	subs map[string]map[chan struct{}]struct{}
+	// watchers counts the open event streams per job; idle holds the grace
+	// timers of jobs whose last stream went away (see WatchEvents).
+	watchers map[string]int
+	idle     map[string]*time.Timer
 }
 
 func newEventBus() *eventBus {
 	return &eventBus{
-		subs: map[string]map[chan struct{}]struct{}{},
+		subs:     map[string]map[chan struct{}]struct{}{},
+		watchers: map[string]int{},
+		idle:     map[string]*time.Timer{},
 	}
 }
 
@@ -2297,6 +3180,66 @@ func (b *eventBus) unsubscribe(jobID string, ch chan struct{}) {
 	close(ch)
 }
 
+// count returns the number of open event subscriptions across all jobs.
+func (b *eventBus) count() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	total := 0
+	for _, subs := range b.subs {
+		total += len(subs)
+	}
+	return total
+}
+
+// watch counts a new stream for jobID and stops its pending grace timer.
+func (b *eventBus) watch(jobID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.watchers[jobID]++
+	if timer := b.idle[jobID]; timer != nil {
+		timer.Stop()
+		delete(b.idle, jobID)
+	}
+}
+
+// unwatch ends a stream for jobID and reports whether it was the last one.
+func (b *eventBus) unwatch(jobID string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.watchers[jobID]--
+	if b.watchers[jobID] > 0 {
+		return false
+	}
+	delete(b.watchers, jobID)
+	return true
+}
+
+// afterIdle runs fn once jobID has had no streams for grace. A stream opened
+// in the meantime stops the timer.
+func (b *eventBus) afterIdle(jobID string, grace time.Duration, fn func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.watchers[jobID] > 0 {
+		return
+	}
+	if timer := b.idle[jobID]; timer != nil {
+		timer.Stop()
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(grace, func() {
+		b.mu.Lock()
+		current := b.idle[jobID] == timer && b.watchers[jobID] == 0
+		if b.idle[jobID] == timer {
+			delete(b.idle, jobID)
+		}
+		b.mu.Unlock()
+		if current {
+			fn()
+		}
+	})
+	b.idle[jobID] = timer
+}
+
 func (b *eventBus) notify(jobID string) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
@@ -2308,8 +3251,8 @@ func (b *eventBus) notify(jobID string) {
 	}
 }
 
-func buildDSN(host string, port int) string {
-	return fmt.Sprintf("postgres://sqlrs@%s:%d/postgres", host, port)
+func buildDSN(host string, port int, user string) string {
+	return fmt.Sprintf("postgres://%s@%s:%d/postgres", runtime.PostgresSuperuser(user), host, port)
 }
 
 func formatTime(value time.Time) *string {
@@ -12,6 +12,7 @@ type coordinatorSpy struct {
 	buildPlanPsqlCalled           bool
 	buildPlanLiquibaseCalled      bool
 	planLiquibaseChangesetsCalled bool
+	buildPlanFlywayCalled         bool
 }
 
 func (s *coordinatorSpy) runJob(prepared preparedRequest, jobID string) {
@@ -43,12 +44,19 @@ func (s *coordinatorSpy) planLiquibaseChangesets(ctx context.Context, jobID stri
 	return nil, nil
 }
 
+func (s *coordinatorSpy) buildPlanFlyway(ctx context.Context, jobID string, prepared preparedRequest) ([]PlanTask, string, *ErrorResponse) {
+	s.buildPlanFlywayCalled = true
+	return nil, "state-5", nil
+}
+
 type executorSpy struct {
 	executeStateTaskCalled     bool
 	executePrepareStepCalled   bool
 	executePsqlStepCalled      bool
 	executeLiquibaseStepCalled bool
 	runUpdateSQLCalled         bool
+	executeFlywayStepCalled    bool
+	runFlywayInfoCalled        bool
 	createInstanceCalled       bool
 	ensureRuntimeCalled        bool
 	startRuntimeCalled         bool
@@ -79,6 +87,16 @@ func (s *executorSpy) runLiquibaseUpdateSQL(ctx context.Context, jobID string, p
 	return nil, nil
 }
 
+func (s *executorSpy) executeFlywayStep(ctx context.Context, jobID string, prepared preparedRequest, rt *jobRuntime, task taskState) *ErrorResponse {
+	s.executeFlywayStepCalled = true
+	return nil
+}
+
+func (s *executorSpy) runFlywayInfo(ctx context.Context, jobID string, prepared preparedRequest, rt *jobRuntime) ([]FlywayMigration, *ErrorResponse) {
+	s.runFlywayInfoCalled = true
+	return nil, nil
+}
+
 func (s *executorSpy) createInstance(ctx context.Context, jobID string, prepared preparedRequest, stateID string) (*Result, *ErrorResponse) {
 	s.createInstanceCalled = true
 	return &Result{}, nil
@@ -148,6 +166,12 @@ func TestManagerDelegatesToCoordinator(t *testing.T) {
 	if !coordinator.planLiquibaseChangesetsCalled {
 		t.Fatalf("expected planLiquibaseChangesets delegation")
 	}
+	if _, _, errResp := mgr.buildPlanFlyway(context.Background(), "job-1", preparedRequest{}); errResp != nil {
+		t.Fatalf("unexpected buildPlanFlyway error: %+v", errResp)
+	}
+	if !coordinator.buildPlanFlywayCalled {
+		t.Fatalf("expected buildPlanFlyway delegation")
+	}
 }
 
 func TestManagerDelegatesToExecutorAndSnapshot(t *testing.T) {
@@ -187,6 +211,18 @@ func TestManagerDelegatesToExecutorAndSnapshot(t *testing.T) {
 	if !executor.runUpdateSQLCalled {
 		t.Fatalf("expected runLiquibaseUpdateSQL delegation")
 	}
+	if errResp := mgr.executeFlywayStep(context.Background(), "job-1", preparedRequest{}, &jobRuntime{}, taskState{}); errResp != nil {
+		t.Fatalf("unexpected executeFlywayStep error: %+v", errResp)
+	}
+	if !executor.executeFlywayStepCalled {
+		t.Fatalf("expected executeFlywayStep delegation")
+	}
+	if _, errResp := mgr.runFlywayInfo(context.Background(), "job-1", preparedRequest{}, &jobRuntime{}); errResp != nil {
+		t.Fatalf("unexpected runFlywayInfo error: %+v", errResp)
+	}
+	if !executor.runFlywayInfoCalled {
+		t.Fatalf("expected runFlywayInfo delegation")
+	}
 	if _, errResp := mgr.createInstance(context.Background(), "job-1", preparedRequest{}, "state-1"); errResp != nil {
 		t.Fatalf("unexpected createInstance error: %+v", errResp)
 	}
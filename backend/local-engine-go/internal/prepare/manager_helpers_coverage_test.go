@@ -69,13 +69,16 @@ func TestHasRunningTasks(t *testing.T) {
 }
 
 func TestNeedsImageResolve(t *testing.T) {
-	if !needsImageResolve("postgres:17") {
+	if !needsImageResolve("postgres:17", "") {
 		t.Fatalf("expected tag-only image to require resolve")
 	}
-	if needsImageResolve("postgres@sha256:abc") {
+	if needsImageResolve("postgres@sha256:abc", "") {
 		t.Fatalf("expected digest image to skip resolve")
 	}
-	if !needsImageResolve("   ") {
+	if !needsImageResolve("   ", "") {
 		t.Fatalf("expected blank image id to require resolve")
 	}
+	if !needsImageResolve("postgres@sha256:abc", "linux/amd64") {
+		t.Fatalf("expected digest image with a platform to require resolve")
+	}
 }
@@ -0,0 +1,141 @@
+package prepare
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/sqlrs/engine-local/internal/prepare/queue"
+)
+
+type idempotencyQueueStore struct {
+	queue.Store
+
+	getByKey  func(context.Context, string) (queue.JobRecord, bool, error)
+	createJob func(context.Context, queue.JobRecord) error
+}
+
+func (s *idempotencyQueueStore) GetJobByIdempotencyKey(ctx context.Context, key string) (queue.JobRecord, bool, error) {
+	if s.getByKey != nil {
+		return s.getByKey(ctx, key)
+	}
+	return s.Store.GetJobByIdempotencyKey(ctx, key)
+}
+
+func (s *idempotencyQueueStore) CreateJob(ctx context.Context, job queue.JobRecord) error {
+	if s.createJob != nil {
+		return s.createJob(ctx, job)
+	}
+	return s.Store.CreateJob(ctx, job)
+}
+
+func TestSubmitReusesJobForIdempotencyKey(t *testing.T) {
+	queueStore := newQueueStore(t)
+	mgr := newManagerWithQueue(t, &fakeStore{}, queueStore)
+	req := Request{
+		PrepareKind:    "psql",
+		ImageID:        "image-1",
+		PsqlArgs:       []string{"-c", "select 1"},
+		PlanOnly:       true,
+		IdempotencyKey: "ci-42",
+	}
+
+	first, err := mgr.Submit(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	req.IdempotencyKey = "  ci-42 "
+	second, err := mgr.Submit(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Submit retry: %v", err)
+	}
+	if second.JobID != first.JobID || second.StatusURL != first.StatusURL {
+		t.Fatalf("expected retry to reuse job, first=%+v second=%+v", first, second)
+	}
+	if second.Status != StatusSucceeded {
+		t.Fatalf("expected current job status, got %+v", second)
+	}
+	jobs, err := queueStore.ListJobs(context.Background(), "")
+	if err != nil {
+		t.Fatalf("ListJobs: %v", err)
+	}
+	if len(jobs) != 1 {
+		t.Fatalf("expected a single job, got %d", len(jobs))
+	}
+}
+
+func TestSubmitRejectsIdempotencyKeyWithDifferentRequest(t *testing.T) {
+	mgr := newManagerWithQueue(t, &fakeStore{}, newQueueStore(t))
+	if _, err := mgr.Submit(context.Background(), Request{
+		PrepareKind:    "psql",
+		ImageID:        "image-1",
+		PsqlArgs:       []string{"-c", "select 1"},
+		PlanOnly:       true,
+		IdempotencyKey: "ci-42",
+	}); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+
+	_, err := mgr.Submit(context.Background(), Request{
+		PrepareKind:    "psql",
+		ImageID:        "image-1",
+		PsqlArgs:       []string{"-c", "select 2"},
+		PlanOnly:       true,
+		IdempotencyKey: "ci-42",
+	})
+	var conflict ConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("expected ConflictError, got %v", err)
+	}
+	resp := ToErrorResponse(err)
+	if resp == nil || resp.Code != "invalid_argument" {
+		t.Fatalf("unexpected error response: %+v", resp)
+	}
+}
+
+func TestSubmitIdempotencyLookupError(t *testing.T) {
+	faulty := &idempotencyQueueStore{
+		Store: newQueueStore(t),
+		getByKey: func(context.Context, string) (queue.JobRecord, bool, error) {
+			return queue.JobRecord{}, false, errors.New("boom")
+		},
+	}
+	mgr := newManagerWithQueue(t, &fakeStore{}, faulty)
+	if _, err := mgr.Submit(context.Background(), Request{
+		PrepareKind:    "psql",
+		ImageID:        "image-1",
+		PsqlArgs:       []string{"-c", "select 1"},
+		IdempotencyKey: "ci-42",
+	}); err == nil || err.Error() != "boom" {
+		t.Fatalf("expected lookup error, got %v", err)
+	}
+}
+
+func TestSubmitIdempotencyKeyRaceReusesWinner(t *testing.T) {
+	queueStore := newQueueStore(t)
+	var winner queue.JobRecord
+	faulty := &idempotencyQueueStore{Store: queueStore}
+	faulty.createJob = func(ctx context.Context, job queue.JobRecord) error {
+		winner = job
+		winner.JobID = "job-winner"
+		if err := queueStore.CreateJob(ctx, winner); err != nil {
+			return err
+		}
+		return queueStore.CreateJob(ctx, job)
+	}
+	mgr := newManagerWithQueue(t, &fakeStore{}, faulty)
+
+	accepted, err := mgr.Submit(context.Background(), Request{
+		PrepareKind:    "psql",
+		ImageID:        "image-1",
+		PsqlArgs:       []string{"-c", "select 1"},
+		PlanOnly:       true,
+		IdempotencyKey: "ci-42",
+	})
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	if accepted.JobID != "job-winner" {
+		t.Fatalf("expected winning job to be reused, got %+v", accepted)
+	}
+}
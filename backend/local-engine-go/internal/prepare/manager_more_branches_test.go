@@ -74,7 +74,7 @@ func TestLoadOrPlanTasksReplansLegacySingleStepPsqlShape(t *testing.T) {
 	if errResp != nil {
 		t.Fatalf("computeTaskHash: %+v", errResp)
 	}
-	legacyStateID, errResp := mgr.computeOutputStateID("image", prepared.effectiveImageID(), legacyTaskHash)
+	legacyStateID, errResp := mgr.computeOutputStateID("", "image", prepared.effectiveImageID(), legacyTaskHash)
 	if errResp != nil {
 		t.Fatalf("computeOutputStateID: %+v", errResp)
 	}
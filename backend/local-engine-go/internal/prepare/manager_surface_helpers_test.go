@@ -94,6 +94,49 @@ func TestTaskEntryFromRecordBranches(t *testing.T) {
 			t.Fatalf("unexpected psql summary: %q", entry.ArgsSummary)
 		}
 	})
+
+	t.Run("timing", func(t *testing.T) {
+		task := queue.TaskRecord{
+			TaskID:     "resolve-image",
+			JobID:      "job-1",
+			Type:       "resolve_image",
+			Status:     StatusSucceeded,
+			StartedAt:  strPtr("2026-03-10T10:00:00Z"),
+			FinishedAt: strPtr("2026-03-10T10:00:01.5Z"),
+		}
+		entry := taskEntryFromRecord(task, nil)
+		if entry.StartedAt == nil || entry.FinishedAt == nil || entry.DurationMs == nil || *entry.DurationMs != 1500 {
+			t.Fatalf("unexpected timing: %+v", entry)
+		}
+		planTask := planTaskFromRecord(task)
+		if planTask.DurationMs == nil || *planTask.DurationMs != 1500 {
+			t.Fatalf("unexpected plan task timing: %+v", planTask)
+		}
+	})
+}
+
+func TestTaskDurationMs(t *testing.T) {
+	start := "2026-03-10T10:00:00Z"
+	bad := "soon"
+	earlier := "2026-03-10T09:59:59Z"
+	if got := taskDurationMs(nil, &start); got != nil {
+		t.Fatalf("expected nil without start, got %d", *got)
+	}
+	if got := taskDurationMs(&start, nil); got != nil {
+		t.Fatalf("expected nil without finish, got %d", *got)
+	}
+	if got := taskDurationMs(&bad, &start); got != nil {
+		t.Fatalf("expected nil for invalid start, got %d", *got)
+	}
+	if got := taskDurationMs(&start, &bad); got != nil {
+		t.Fatalf("expected nil for invalid finish, got %d", *got)
+	}
+	if got := taskDurationMs(&start, &earlier); got != nil {
+		t.Fatalf("expected nil for finish before start, got %d", *got)
+	}
+	if got := taskDurationMs(&start, &start); got == nil || *got != 0 {
+		t.Fatalf("expected zero duration, got %v", got)
+	}
 }
 
 func TestEventFromRecordBranches(t *testing.T) {
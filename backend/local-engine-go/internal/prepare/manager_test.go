@@ -30,7 +30,9 @@ type fakeStore struct {
 	listStates        []store.StateEntry
 	states            []store.StateCreate
 	instances         []store.InstanceCreate
+	listInstances     []store.InstanceEntry
 	deletedStates     []string
+	names             map[string]store.NameEntry
 }
 
 func (f *fakeStore) ListNames(ctx context.Context, filters store.NameFilters) ([]store.NameEntry, error) {
@@ -38,11 +40,12 @@ func (f *fakeStore) ListNames(ctx context.Context, filters store.NameFilters) ([
 }
 
 func (f *fakeStore) GetName(ctx context.Context, name string) (store.NameEntry, bool, error) {
-	return store.NameEntry{}, false, nil
+	entry, ok := f.names[name]
+	return entry, ok, nil
 }
 
 func (f *fakeStore) ListInstances(ctx context.Context, filters store.InstanceFilters) ([]store.InstanceEntry, error) {
-	return nil, nil
+	return f.listInstances, nil
 }
 
 func (f *fakeStore) GetInstance(ctx context.Context, instanceID string) (store.InstanceEntry, bool, error) {
@@ -112,6 +115,7 @@ func (f *fakeStore) CreateState(ctx context.Context, entry store.StateCreate) er
 		CreatedAt:     entry.CreatedAt,
 		SizeBytes:     entry.SizeBytes,
 		RefCount:      0,
+		TaskHash:      entry.TaskHash,
 	}
 	return nil
 }
@@ -193,6 +197,7 @@ type fakeRuntime struct {
 	execCalls     []engineRuntime.ExecRequest
 	waitCalls     []time.Duration
 	resolveCalls  []string
+	platforms     []string
 	noDefaults    bool
 	initErr       error
 	resolveErr    error
@@ -203,6 +208,13 @@ type fakeRuntime struct {
 	execOutput    string
 	initCreated   bool
 	resolvedImage string
+	buildCalls    []engineRuntime.BuildRequest
+	buildErr      error
+	builtImage    string
+	localImages   map[string]string
+	localCalls    []string
+	managed       []engineRuntime.ManagedContainer
+	listErr       error
 }
 
 func (f *fakeRuntime) InitBase(ctx context.Context, imageID string, dataDir string) error {
@@ -221,11 +233,11 @@ type loggingRuntime struct {
 	fakeRuntime
 }
 
-func (l *loggingRuntime) ResolveImage(ctx context.Context, imageID string) (string, error) {
+func (l *loggingRuntime) ResolveImage(ctx context.Context, imageID string, platform string) (string, error) {
 	if sink := engineRuntime.LogSinkFromContext(ctx); sink != nil {
 		sink("pulling layers")
 	}
-	return l.fakeRuntime.ResolveImage(ctx, imageID)
+	return l.fakeRuntime.ResolveImage(ctx, imageID, platform)
 }
 
 func (f *fakeRuntime) Start(ctx context.Context, req engineRuntime.StartRequest) (engineRuntime.Instance, error) {
@@ -248,17 +260,38 @@ func (f *fakeRuntime) Start(ctx context.Context, req engineRuntime.StartRequest)
 	return instance, nil
 }
 
-func (f *fakeRuntime) ResolveImage(ctx context.Context, imageID string) (string, error) {
+func (f *fakeRuntime) ResolveImage(ctx context.Context, imageID string, platform string) (string, error) {
 	f.resolveCalls = append(f.resolveCalls, imageID)
+	f.platforms = append(f.platforms, platform)
 	if f.resolveErr != nil {
 		return "", f.resolveErr
 	}
 	if f.resolvedImage != "" {
 		return f.resolvedImage, nil
 	}
+	if platform != "" {
+		return imageID + "@sha256:resolved-" + strings.ReplaceAll(platform, "/", "-"), nil
+	}
 	return imageID + "@sha256:resolved", nil
 }
 
+func (f *fakeRuntime) Build(ctx context.Context, req engineRuntime.BuildRequest) (string, error) {
+	f.buildCalls = append(f.buildCalls, req)
+	if f.buildErr != nil {
+		return "", f.buildErr
+	}
+	if f.builtImage != "" {
+		return f.builtImage, nil
+	}
+	return "sha256:built", nil
+}
+
+func (f *fakeRuntime) HasImageLocally(ctx context.Context, ref string) (string, bool, error) {
+	f.localCalls = append(f.localCalls, ref)
+	resolved, ok := f.localImages[ref]
+	return resolved, ok, nil
+}
+
 func (f *fakeRuntime) Stop(ctx context.Context, id string) error {
 	f.stopCalls = append(f.stopCalls, id)
 	if f.stopErr != nil {
@@ -283,6 +316,14 @@ func (f *fakeRuntime) WaitForReady(ctx context.Context, id string, timeout time.
 	return nil
 }
 
+func (f *fakeRuntime) Ping(ctx context.Context) (string, error) {
+	return "test", nil
+}
+
+func (f *fakeRuntime) ListManaged(ctx context.Context) ([]engineRuntime.ManagedContainer, error) {
+	return f.managed, f.listErr
+}
+
 type cancelRuntime struct {
 	started chan struct{}
 }
@@ -291,10 +332,18 @@ func (b *cancelRuntime) InitBase(ctx context.Context, imageID string, dataDir st
 	return nil
 }
 
-func (b *cancelRuntime) ResolveImage(ctx context.Context, imageID string) (string, error) {
+func (b *cancelRuntime) ResolveImage(ctx context.Context, imageID string, platform string) (string, error) {
 	return imageID + "@sha256:resolved", nil
 }
 
+func (*cancelRuntime) Build(ctx context.Context, req engineRuntime.BuildRequest) (string, error) {
+	return "sha256:built", nil
+}
+
+func (*cancelRuntime) HasImageLocally(ctx context.Context, ref string) (string, bool, error) {
+	return ref, true, nil
+}
+
 func (b *cancelRuntime) Start(ctx context.Context, req engineRuntime.StartRequest) (engineRuntime.Instance, error) {
 	select {
 	case <-b.started:
@@ -317,6 +366,14 @@ func (b *cancelRuntime) WaitForReady(ctx context.Context, id string, timeout tim
 	return nil
 }
 
+func (b *cancelRuntime) Ping(ctx context.Context) (string, error) {
+	return "test", nil
+}
+
+func (b *cancelRuntime) ListManaged(ctx context.Context) ([]engineRuntime.ManagedContainer, error) {
+	return nil, nil
+}
+
 type fakeDBMS struct {
 	prepareCalls int
 	resumeCalls  int
@@ -410,7 +467,7 @@ type faultQueueStore struct {
 
 	appendEvent     func(context.Context, queue.EventRecord) (int64, error)
 	listEventsSince func(context.Context, string, int) ([]queue.EventRecord, error)
-	countEvents     func(context.Context, string) (int, error)
+	maxEventSeq     func(context.Context, string) (int64, error)
 }
 
 func (f *faultQueueStore) CreateJob(ctx context.Context, job queue.JobRecord) error {
@@ -490,11 +547,11 @@ func (f *faultQueueStore) ListEventsSince(ctx context.Context, jobID string, off
 	return f.Store.ListEventsSince(ctx, jobID, offset)
 }
 
-func (f *faultQueueStore) CountEvents(ctx context.Context, jobID string) (int, error) {
-	if f.countEvents != nil {
-		return f.countEvents(ctx, jobID)
+func (f *faultQueueStore) MaxEventSeq(ctx context.Context, jobID string) (int64, error) {
+	if f.maxEventSeq != nil {
+		return f.maxEventSeq(ctx, jobID)
 	}
-	return f.Store.CountEvents(ctx, jobID)
+	return f.Store.MaxEventSeq(ctx, jobID)
 }
 
 func (f *faultQueueStore) Close() error {
@@ -885,7 +942,7 @@ func TestSubmitPlanOnlyCachedFlag(t *testing.T) {
 	if errResp != nil {
 		t.Fatalf("computeTaskHash: %+v", errResp)
 	}
-	stateID, errResp := mgr.computeOutputStateID("image", prepared.effectiveImageID(), taskHash)
+	stateID, errResp := mgr.computeOutputStateID("", "image", prepared.effectiveImageID(), taskHash)
 	if errResp != nil {
 		t.Fatalf("computeOutputStateID: %+v", errResp)
 	}
@@ -1028,7 +1085,7 @@ func TestStateIDUsesResolvedImageID(t *testing.T) {
 	if errResp != nil {
 		t.Fatalf("computeTaskHash: %+v", errResp)
 	}
-	expectedStateID, errResp := mgr.computeOutputStateID("image", prepared.effectiveImageID(), taskHash)
+	expectedStateID, errResp := mgr.computeOutputStateID("", "image", prepared.effectiveImageID(), taskHash)
 	if errResp != nil {
 		t.Fatalf("computeOutputStateID: %+v", errResp)
 	}
@@ -1959,8 +2016,8 @@ func TestExecuteStateTaskPsqlError(t *testing.T) {
 		},
 	}
 	_, errResp := mgr.executeStateTask(context.Background(), "job-1", prepared, task)
-	if errResp == nil || errResp.Code != "internal_error" {
-		t.Fatalf("expected internal error, got %+v", errResp)
+	if errResp == nil || errResp.Code != "migration_failed" {
+		t.Fatalf("expected migration_failed, got %+v", errResp)
 	}
 }
 
@@ -1987,8 +2044,8 @@ func TestExecuteStateTaskSnapshotError(t *testing.T) {
 		},
 	}
 	_, errResp := mgr.executeStateTask(context.Background(), "job-1", prepared, task)
-	if errResp == nil || errResp.Code != "internal_error" {
-		t.Fatalf("expected internal error, got %+v", errResp)
+	if errResp == nil || errResp.Code != "snapshot_failed" {
+		t.Fatalf("expected snapshot_failed, got %+v", errResp)
 	}
 }
 
@@ -2069,8 +2126,8 @@ func TestExecuteStateTaskPrepareSnapshotError(t *testing.T) {
 		},
 	}
 	_, errResp := mgr.executeStateTask(context.Background(), "job-1", prepared, task)
-	if errResp == nil || errResp.Code != "internal_error" {
-		t.Fatalf("expected internal error, got %+v", errResp)
+	if errResp == nil || errResp.Code != "snapshot_failed" {
+		t.Fatalf("expected snapshot_failed, got %+v", errResp)
 	}
 }
 
@@ -2097,8 +2154,8 @@ func TestExecuteStateTaskResumeSnapshotError(t *testing.T) {
 		},
 	}
 	_, errResp := mgr.executeStateTask(context.Background(), "job-1", prepared, task)
-	if errResp == nil || errResp.Code != "internal_error" {
-		t.Fatalf("expected internal error, got %+v", errResp)
+	if errResp == nil || errResp.Code != "snapshot_failed" {
+		t.Fatalf("expected snapshot_failed, got %+v", errResp)
 	}
 }
 
@@ -2158,8 +2215,8 @@ func TestStartRuntimeFailsWhenStateDirty(t *testing.T) {
 	if errResp == nil {
 		t.Fatalf("expected error")
 	}
-	if errResp.Code != "internal_error" {
-		t.Fatalf("expected internal_error, got %+v", errResp)
+	if errResp.Code != "state_corrupt" {
+		t.Fatalf("expected state_corrupt, got %+v", errResp)
 	}
 }
 
@@ -2184,8 +2241,8 @@ func TestStartRuntimeFailsWhenStateMissingPGVersion(t *testing.T) {
 	if errResp == nil {
 		t.Fatalf("expected error")
 	}
-	if errResp.Code != "internal_error" {
-		t.Fatalf("expected internal_error, got %+v", errResp)
+	if errResp.Code != "state_corrupt" {
+		t.Fatalf("expected state_corrupt, got %+v", errResp)
 	}
 }
 
@@ -2217,8 +2274,8 @@ func TestStartRuntimeFailsWhenRuntimeDirDirty(t *testing.T) {
 	if errResp == nil {
 		t.Fatalf("expected error")
 	}
-	if errResp.Code != "internal_error" {
-		t.Fatalf("expected internal_error, got %+v", errResp)
+	if errResp.Code != "state_corrupt" {
+		t.Fatalf("expected state_corrupt, got %+v", errResp)
 	}
 }
 
@@ -2250,8 +2307,8 @@ func TestStartRuntimeFailsWhenRuntimeDirMissingPGVersion(t *testing.T) {
 	if errResp == nil {
 		t.Fatalf("expected error")
 	}
-	if errResp.Code != "internal_error" {
-		t.Fatalf("expected internal_error, got %+v", errResp)
+	if errResp.Code != "state_corrupt" {
+		t.Fatalf("expected state_corrupt, got %+v", errResp)
 	}
 }
 
@@ -2348,6 +2405,16 @@ func TestCreateInstanceMissingConnectionInfo(t *testing.T) {
 	store := &fakeStore{statesByID: map[string]store.StateEntry{"state-1": {StateID: "state-1", ImageID: "image-1"}}}
 	runtime := &fakeRuntime{instance: engineRuntime.Instance{ID: "container-1"}, noDefaults: true}
 	mgr := newManagerWithDeps(t, store, newQueueStore(t), &testDeps{runtime: runtime})
+	paths, err := resolveStatePaths(mgr.stateStoreRoot, "image-1", "state-1", mgr.statefs)
+	if err != nil {
+		t.Fatalf("resolveStatePaths: %v", err)
+	}
+	if err := os.MkdirAll(paths.stateDir, 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(paths.stateDir, "PG_VERSION"), []byte("17"), 0o600); err != nil {
+		t.Fatalf("write PG_VERSION: %v", err)
+	}
 	prepared, err := mgr.prepareRequest(Request{
 		PrepareKind: "psql",
 		ImageID:     "image-1",
@@ -2356,7 +2423,7 @@ func TestCreateInstanceMissingConnectionInfo(t *testing.T) {
 	if err != nil {
 		t.Fatalf("prepareRequest: %v", err)
 	}
-	if _, errResp := mgr.createInstance(context.Background(), "job-1", prepared, "state-1"); errResp == nil || errResp.Code != "internal_error" {
+	if _, errResp := mgr.createInstance(context.Background(), "job-1", prepared, "state-1"); errResp == nil || errResp.Code != "internal_error" || errResp.Message != "runtime instance is missing connection info" {
 		t.Fatalf("expected internal error, got %+v", errResp)
 	}
 }
@@ -2436,8 +2503,8 @@ func TestStartRuntimeGetStateError(t *testing.T) {
 	if err != nil {
 		t.Fatalf("prepareRequest: %v", err)
 	}
-	if _, errResp := mgr.startRuntime(context.Background(), "job-1", prepared, &TaskInput{Kind: "state", ID: "state-1"}); errResp == nil || errResp.Code != "internal_error" {
-		t.Fatalf("expected internal error, got %+v", errResp)
+	if _, errResp := mgr.startRuntime(context.Background(), "job-1", prepared, &TaskInput{Kind: "state", ID: "state-1"}); errResp == nil || errResp.Code != "store_not_ready" {
+		t.Fatalf("expected store_not_ready, got %+v", errResp)
 	}
 }
 
@@ -2554,7 +2621,7 @@ func TestExecuteStateTaskLiquibaseUsesPlannedHashForCachedState(t *testing.T) {
 	}
 
 	taskHash := "planned-task-hash"
-	outputID, errResp := mgr.computeOutputStateID("image", "image-1", taskHash)
+	outputID, errResp := mgr.computeOutputStateID("", "image", "image-1", taskHash)
 	if errResp != nil {
 		t.Fatalf("computeOutputStateID: %+v", errResp)
 	}
@@ -2661,6 +2728,16 @@ func TestCreateInstanceErrors(t *testing.T) {
 		},
 	}
 	mgr := newManager(t, store)
+	paths, err := resolveStatePaths(mgr.stateStoreRoot, "image-1", "state-1", mgr.statefs)
+	if err != nil {
+		t.Fatalf("resolveStatePaths: %v", err)
+	}
+	if err := os.MkdirAll(paths.stateDir, 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(paths.stateDir, "PG_VERSION"), []byte("17"), 0o600); err != nil {
+		t.Fatalf("write PG_VERSION: %v", err)
+	}
 	prepared, err := mgr.prepareRequest(Request{
 		PrepareKind: "psql",
 		ImageID:     "image-1",
@@ -2669,7 +2746,7 @@ func TestCreateInstanceErrors(t *testing.T) {
 	if err != nil {
 		t.Fatalf("prepareRequest: %v", err)
 	}
-	if _, errResp := mgr.createInstance(context.Background(), "job-1", prepared, "state-1"); errResp == nil || errResp.Code != "internal_error" {
+	if _, errResp := mgr.createInstance(context.Background(), "job-1", prepared, "state-1"); errResp == nil || errResp.Code != "internal_error" || errResp.Message != "cannot store instance" {
 		t.Fatalf("expected internal error, got %+v", errResp)
 	}
 }
@@ -2755,6 +2832,98 @@ func TestEventFromRecordParsesPayloads(t *testing.T) {
 	}
 }
 
+func TestEventProgressRoundTrip(t *testing.T) {
+	record := eventRecordFromEvent("job-1", Event{Type: "task", Progress: &TaskProgress{Completed: 3, Total: 10}})
+	if record.ProgressJSON == nil {
+		t.Fatalf("expected progress json")
+	}
+	event := eventFromRecord(record)
+	if event.Progress == nil || event.Progress.Completed != 3 || event.Progress.Total != 10 {
+		t.Fatalf("unexpected progress: %+v", event.Progress)
+	}
+}
+
+func TestSubmitEmitsPlanEventBeforeTasks(t *testing.T) {
+	queueStore := newQueueStore(t)
+	mgr := newManagerWithQueue(t, &fakeStore{}, queueStore)
+
+	if _, err := mgr.Submit(context.Background(), Request{
+		PrepareKind: "psql",
+		ImageID:     "image-1",
+		PsqlArgs:    []string{"-c", "select 1"},
+		PlanOnly:    true,
+	}); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	records, err := queueStore.ListEventsSince(context.Background(), "job-1", 0)
+	if err != nil {
+		t.Fatalf("ListEventsSince: %v", err)
+	}
+	planIndex, firstTask := -1, -1
+	var plan Event
+	for i, record := range records {
+		event := eventFromRecord(record)
+		if event.Type == "plan" && planIndex < 0 {
+			planIndex, plan = i, event
+		}
+		if event.Type == "task" && firstTask < 0 {
+			firstTask = i
+		}
+	}
+	if planIndex < 0 || (firstTask >= 0 && firstTask < planIndex) {
+		t.Fatalf("expected a plan event before task events, got %+v", records)
+	}
+	if len(plan.Plan) < 2 || plan.Plan[0].TaskID != "plan" || plan.Message != "0 of 1 states cached" {
+		t.Fatalf("unexpected plan event: %+v", plan)
+	}
+	var execute *PlanTask
+	for i := range plan.Plan {
+		if plan.Plan[i].Type == "state_execute" {
+			execute = &plan.Plan[i]
+		}
+	}
+	if execute == nil || execute.OutputStateID == "" || execute.Cached == nil || *execute.Cached {
+		t.Fatalf("expected uncached execute task with a state id, got %+v", plan.Plan)
+	}
+}
+
+// fixedTasksQueueStore serves a fixed task list per job.
+type fixedTasksQueueStore struct {
+	queue.Store
+	tasks map[string][]queue.TaskRecord
+}
+
+func (s *fixedTasksQueueStore) ListTasks(ctx context.Context, jobID string) ([]queue.TaskRecord, error) {
+	return s.tasks[jobID], nil
+}
+
+func TestTaskProgress(t *testing.T) {
+	q := &fixedTasksQueueStore{tasks: map[string][]queue.TaskRecord{
+		"job-1": {
+			{TaskID: "plan", Type: "plan"},
+			{TaskID: "execute-0", Type: "state_execute"},
+			{TaskID: "execute-1", Type: "state_execute"},
+			{TaskID: "prepare-instance", Type: "prepare_instance"},
+		},
+	}}
+	mgr := &PrepareService{queue: q}
+	cases := []struct {
+		taskID string
+		status string
+		want   *TaskProgress
+	}{
+		{taskID: "execute-0", status: StatusRunning, want: &TaskProgress{Completed: 0, Total: 2}},
+		{taskID: "execute-1", status: StatusSucceeded, want: &TaskProgress{Completed: 2, Total: 2}},
+		{taskID: "plan", status: StatusSucceeded},
+	}
+	for _, tc := range cases {
+		got := mgr.taskProgress(context.Background(), "job-1", tc.taskID, tc.status)
+		if (got == nil) != (tc.want == nil) || (got != nil && *got != *tc.want) {
+			t.Fatalf("%s/%s: expected %+v, got %+v", tc.taskID, tc.status, tc.want, got)
+		}
+	}
+}
+
 func TestFindOutputStateID(t *testing.T) {
 	stateID := findOutputStateID([]taskState{
 		{PlanTask: PlanTask{Type: "plan"}},
@@ -2782,11 +2951,11 @@ func TestWaitForEvent(t *testing.T) {
 		t.Fatalf("WaitForEvent: %v", err)
 	}
 
-	count, err := queueStore.CountEvents(context.Background(), "job-1")
+	seq, err := queueStore.MaxEventSeq(context.Background(), "job-1")
 	if err != nil {
-		t.Fatalf("CountEvents: %v", err)
+		t.Fatalf("MaxEventSeq: %v", err)
 	}
-	if err := mgr.WaitForEvent(context.Background(), "job-1", count); err != nil {
+	if err := mgr.WaitForEvent(context.Background(), "job-1", seq); err != nil {
 		t.Fatalf("WaitForEvent after done: %v", err)
 	}
 }
@@ -2818,11 +2987,11 @@ func TestWaitForEventDoneNoEvents(t *testing.T) {
 	}
 }
 
-func TestWaitForEventCountError(t *testing.T) {
+func TestWaitForEventMaxSeqError(t *testing.T) {
 	queueStore := newQueueStore(t)
 	faulty := &faultQueueStore{
 		Store: queueStore,
-		countEvents: func(context.Context, string) (int, error) {
+		maxEventSeq: func(context.Context, string) (int64, error) {
 			return 0, errors.New("boom")
 		},
 	}
@@ -2880,7 +3049,7 @@ func TestWaitForEventGetJobError(t *testing.T) {
 	queueStore := newQueueStore(t)
 	faulty := &faultQueueStore{
 		Store: queueStore,
-		countEvents: func(context.Context, string) (int, error) {
+		maxEventSeq: func(context.Context, string) (int64, error) {
 			return 0, nil
 		},
 		getJob: func(context.Context, string) (queue.JobRecord, bool, error) {
@@ -2923,7 +3092,7 @@ func TestWaitForEventCancel(t *testing.T) {
 	}
 }
 
-func TestHeartbeatRepeatsRunningTask(t *testing.T) {
+func TestHeartbeatPingsRunningTask(t *testing.T) {
 	const heartbeatEvery = 200 * time.Millisecond
 	const waitTimeout = 10 * time.Second
 	queueStore := newQueueStore(t)
@@ -2958,26 +3127,33 @@ func TestHeartbeatRepeatsRunningTask(t *testing.T) {
 	}
 	ctx, cancel := context.WithTimeout(context.Background(), waitTimeout)
 	defer cancel()
-	offset := 0
+	lastSeq := int64(0)
 	runningCount := 0
-	for runningCount < 1 {
-		events, ok, _, err := mgr.EventsSince("job-1", offset)
+	pingCount := 0
+	for pingCount < 1 {
+		events, ok, _, err := mgr.EventsAfterSeq("job-1", lastSeq)
 		if err != nil || !ok {
-			t.Fatalf("EventsSince: ok=%v err=%v", ok, err)
+			t.Fatalf("EventsAfterSeq: ok=%v err=%v", ok, err)
 		}
-		offset += len(events)
 		for _, event := range events {
+			lastSeq = event.Seq
 			if event.Type == "task" && event.TaskID == "prepare-instance" && event.Status == StatusRunning {
 				runningCount++
 			}
+			if event.Type == "ping" && event.TaskID == "prepare-instance" {
+				pingCount++
+			}
 		}
-		if runningCount >= 1 {
+		if pingCount >= 1 {
 			break
 		}
-		if err := mgr.WaitForEvent(ctx, "job-1", offset); err != nil {
+		if err := mgr.WaitForEvent(ctx, "job-1", lastSeq); err != nil {
 			t.Fatalf("WaitForEvent: %v", err)
 		}
 	}
+	if runningCount != 1 {
+		t.Fatalf("expected heartbeat to ping instead of repeating the task event, got %d running events", runningCount)
+	}
 
 	finishedAt := time.Now().UTC().Format(time.RFC3339Nano)
 	if err := mgr.updateTaskStatus(context.Background(), "job-1", "prepare-instance", StatusSucceeded, nil, &finishedAt, nil); err != nil {
@@ -2985,7 +3161,7 @@ func TestHeartbeatRepeatsRunningTask(t *testing.T) {
 	}
 }
 
-func TestHeartbeatRepeatsLogEvent(t *testing.T) {
+func TestHeartbeatPingsAfterLogEvent(t *testing.T) {
 	const heartbeatEvery = 200 * time.Millisecond
 	const waitTimeout = 10 * time.Second
 	queueStore := newQueueStore(t)
@@ -3021,26 +3197,33 @@ func TestHeartbeatRepeatsLogEvent(t *testing.T) {
 	mgr.appendLog("job-1", "docker: pulling layers")
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 	defer cancel()
-	offset := 0
+	lastSeq := int64(0)
 	logCount := 0
-	for logCount < 2 {
-		events, ok, _, err := mgr.EventsSince("job-1", offset)
+	pingCount := 0
+	for pingCount < 1 {
+		events, ok, _, err := mgr.EventsAfterSeq("job-1", lastSeq)
 		if err != nil || !ok {
-			t.Fatalf("EventsSince: ok=%v err=%v", ok, err)
+			t.Fatalf("EventsAfterSeq: ok=%v err=%v", ok, err)
 		}
-		offset += len(events)
 		for _, event := range events {
+			lastSeq = event.Seq
 			if event.Type == "log" && event.Message == "docker: pulling layers" {
 				logCount++
 			}
+			if event.Type == "ping" && event.TaskID == "execute-0" {
+				pingCount++
+			}
 		}
-		if logCount >= 2 {
+		if pingCount >= 1 {
 			break
 		}
-		if err := mgr.WaitForEvent(ctx, "job-1", offset); err != nil {
+		if err := mgr.WaitForEvent(ctx, "job-1", lastSeq); err != nil {
 			t.Fatalf("WaitForEvent: %v", err)
 		}
 	}
+	if logCount != 1 {
+		t.Fatalf("expected the log event not to be repeated, got %d", logCount)
+	}
 }
 
 func TestHeartbeatStopsAfterTaskComplete(t *testing.T) {
@@ -3079,7 +3262,7 @@ func TestHeartbeatStopsAfterTaskComplete(t *testing.T) {
 	if err != nil || !ok {
 		t.Fatalf("EventsSince: ok=%v err=%v", ok, err)
 	}
-	offset := len(events)
+	lastSeq := events[len(events)-1].Seq
 	runningCount := 0
 	for _, event := range events {
 		if event.Type == "task" && event.TaskID == "execute-0" && event.Status == StatusRunning {
@@ -3095,20 +3278,20 @@ func TestHeartbeatStopsAfterTaskComplete(t *testing.T) {
 	}
 	ctxAfter, cancelAfter := context.WithTimeout(context.Background(), 200*time.Millisecond)
 	defer cancelAfter()
-	waitErr := mgr.WaitForEvent(ctxAfter, "job-1", offset)
+	waitErr := mgr.WaitForEvent(ctxAfter, "job-1", lastSeq)
 	if waitErr != nil && !errors.Is(waitErr, context.DeadlineExceeded) {
 		t.Fatalf("WaitForEvent after completion: %v", waitErr)
 	}
-	eventsAfter, ok, _, err := mgr.EventsSince("job-1", offset)
+	eventsAfter, ok, _, err := mgr.EventsAfterSeq("job-1", lastSeq)
 	if err != nil || !ok {
-		t.Fatalf("EventsSince: ok=%v err=%v", ok, err)
+		t.Fatalf("EventsAfterSeq: ok=%v err=%v", ok, err)
 	}
 	finishedTs, err := time.Parse(time.RFC3339Nano, finishedAt)
 	if err != nil {
 		t.Fatalf("parse finishedAt: %v", err)
 	}
 	for _, event := range eventsAfter {
-		if event.Type != "task" || event.TaskID != "execute-0" || event.Status != StatusRunning {
+		if event.Type != "ping" {
 			continue
 		}
 		ts, err := time.Parse(time.RFC3339Nano, event.Ts)
@@ -3208,18 +3391,24 @@ func TestAppendLogLines(t *testing.T) {
 }
 
 func TestNormalizeHeartbeat(t *testing.T) {
-	if got := normalizeHeartbeat(0); got != 500*time.Millisecond {
+	if got := normalizeHeartbeat(0, time.Second); got != 500*time.Millisecond {
 		t.Fatalf("expected default heartbeat, got %v", got)
 	}
-	if got := normalizeHeartbeat(50 * time.Millisecond); got != 200*time.Millisecond {
+	if got := normalizeHeartbeat(50*time.Millisecond, time.Second); got != 200*time.Millisecond {
 		t.Fatalf("expected min heartbeat, got %v", got)
 	}
-	if got := normalizeHeartbeat(1500 * time.Millisecond); got != time.Second {
+	if got := normalizeHeartbeat(1500*time.Millisecond, time.Second); got != time.Second {
 		t.Fatalf("expected max heartbeat, got %v", got)
 	}
-	if got := normalizeHeartbeat(300 * time.Millisecond); got != 300*time.Millisecond {
+	if got := normalizeHeartbeat(300*time.Millisecond, time.Second); got != 300*time.Millisecond {
 		t.Fatalf("expected passthrough heartbeat, got %v", got)
 	}
+	if got := normalizeHeartbeat(20*time.Second, time.Minute); got != 20*time.Second {
+		t.Fatalf("expected raised ceiling to allow long heartbeat, got %v", got)
+	}
+	if got := normalizeHeartbeat(time.Second, 0); got != 200*time.Millisecond {
+		t.Fatalf("expected ceiling below minimum to clamp to minimum, got %v", got)
+	}
 }
 
 func TestSummarizeLogDetails(t *testing.T) {
@@ -3525,21 +3714,19 @@ func TestSubmitAsyncRunsJob(t *testing.T) {
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	index := 0
 	for {
-		_, ok, done, err := mgr.EventsSince("job-1", index)
+		_, ok, done, err := mgr.EventsSince("job-1", 0)
 		if err != nil {
 			t.Fatalf("EventsSince: %v", err)
 		}
 		if ok && done {
 			return
 		}
-		count, err := mgr.queue.CountEvents(ctx, "job-1")
+		seq, err := mgr.queue.MaxEventSeq(ctx, "job-1")
 		if err != nil {
-			t.Fatalf("CountEvents: %v", err)
+			t.Fatalf("MaxEventSeq: %v", err)
 		}
-		index = count
-		if err := mgr.WaitForEvent(ctx, "job-1", index); err != nil {
+		if err := mgr.WaitForEvent(ctx, "job-1", seq); err != nil {
 			t.Fatalf("job did not finish: %v", err)
 		}
 	}
@@ -4319,7 +4506,9 @@ type testDeps struct {
 	dbms      *fakeDBMS
 	psql      psqlRunner
 	liquibase liquibaseRunner
+	flyway    flywayRunner
 	stateRoot string
+	workRoot  string
 	config    config.Store
 	validate  func(root string) error
 }
@@ -4412,6 +4601,9 @@ func newManagerWithDeps(t *testing.T, store store.Store, queueStore queue.Store,
 	if deps.liquibase == nil {
 		deps.liquibase = &fakeLiquibaseRunner{}
 	}
+	if deps.flyway == nil {
+		deps.flyway = &fakeFlywayRunner{}
+	}
 	if deps.config == nil {
 		deps.config = &fakeConfigStore{
 			values: map[string]any{
@@ -4437,9 +4629,11 @@ func newManagerWithDeps(t *testing.T, store store.Store, queueStore queue.Store,
 		ValidateStore:  deps.validate,
 		DBMS:           deps.dbms,
 		StateStoreRoot: stateRoot,
+		WorkDirRoot:    deps.workRoot,
 		Config:         deps.config,
 		Psql:           deps.psql,
 		Liquibase:      deps.liquibase,
+		Flyway:         deps.flyway,
 		Version:        "v1",
 		Now:            func() time.Time { return now },
 		IDGen:          func() (string, error) { return "job-1", nil },
@@ -4453,15 +4647,7 @@ func newManagerWithDeps(t *testing.T, store store.Store, queueStore queue.Store,
 
 func newQueueStore(t *testing.T) queue.Store {
 	t.Helper()
-	path := filepath.Join(t.TempDir(), "state.db")
-	store, err := queue.Open(path)
-	if err != nil {
-		t.Fatalf("open queue: %v", err)
-	}
-	t.Cleanup(func() {
-		_ = store.Close()
-	})
-	return store
+	return queue.NewMemory()
 }
 
 func jsonMarshal(req Request) (string, error) {
@@ -4527,18 +4713,18 @@ func TestConfigValueToInt(t *testing.T) {
 
 func TestRemoveJobDirNoopAndDelete(t *testing.T) {
 	mgr := &PrepareService{}
-	if err := mgr.removeJobDir("job-1"); err != nil {
+	if err := mgr.removeJobDir("", "job-1"); err != nil {
 		t.Fatalf("expected empty state store root to be ignored: %v", err)
 	}
 	mgr.stateStoreRoot = t.TempDir()
-	if err := mgr.removeJobDir(""); err != nil {
+	if err := mgr.removeJobDir("", ""); err != nil {
 		t.Fatalf("expected empty job id to be ignored: %v", err)
 	}
 	jobDir := filepath.Join(mgr.stateStoreRoot, "jobs", "job-1")
 	if err := os.MkdirAll(jobDir, 0o700); err != nil {
 		t.Fatalf("mkdir job dir: %v", err)
 	}
-	if err := mgr.removeJobDir("job-1"); err != nil {
+	if err := mgr.removeJobDir("", "job-1"); err != nil {
 		t.Fatalf("removeJobDir: %v", err)
 	}
 	if _, err := os.Stat(jobDir); !os.IsNotExist(err) {
@@ -4557,7 +4743,7 @@ func TestRemoveJobDirBtrfsRuntimeSubvolume(t *testing.T) {
 	if err := os.MkdirAll(runtimeDir, 0o700); err != nil {
 		t.Fatalf("mkdir runtime dir: %v", err)
 	}
-	if err := mgr.removeJobDir("job-1"); err != nil {
+	if err := mgr.removeJobDir("", "job-1"); err != nil {
 		t.Fatalf("removeJobDir: %v", err)
 	}
 	if len(snap.removeCalls) != 1 || snap.removeCalls[0] != runtimeDir {
@@ -4741,3 +4927,66 @@ func TestManagerLogHelpers(t *testing.T) {
 	mgr.logTask("job-1", "task-1", "status=%s", StatusQueued)
 	mgr.logTask("", "", "noop")
 }
+
+func TestSubmitPersistsLabelsWithoutChangingSignature(t *testing.T) {
+	queueStore := newQueueStore(t)
+	mgr := newManagerWithDeps(t, &fakeStore{}, queueStore, &testDeps{runtime: &fakeRuntime{}})
+	req := Request{
+		PrepareKind: "psql",
+		ImageID:     "image-1@sha256:resolved",
+		PsqlArgs:    []string{"-c", "select 1"},
+		PlanOnly:    true,
+	}
+	labeled := req
+	labeled.Labels = map[string]string{" pr ": " 1234 "}
+
+	prepared, err := mgr.prepareRequest(req)
+	if err != nil {
+		t.Fatalf("prepareRequest: %v", err)
+	}
+	preparedLabeled, err := mgr.prepareRequest(labeled)
+	if err != nil {
+		t.Fatalf("prepareRequest: %v", err)
+	}
+	if preparedLabeled.request.Labels["pr"] != "1234" {
+		t.Fatalf("expected normalized labels, got %+v", preparedLabeled.request.Labels)
+	}
+	plain, errResp := mgr.computeJobSignature(prepared)
+	if errResp != nil {
+		t.Fatalf("computeJobSignature: %+v", errResp)
+	}
+	withLabels, errResp := mgr.computeJobSignature(preparedLabeled)
+	if errResp != nil {
+		t.Fatalf("computeJobSignature: %+v", errResp)
+	}
+	if plain != withLabels {
+		t.Fatalf("expected labels to be excluded from the signature")
+	}
+
+	accepted, err := mgr.Submit(context.Background(), labeled)
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	status, ok := mgr.Get(accepted.JobID)
+	if !ok || status.Labels["pr"] != "1234" {
+		t.Fatalf("expected labels in status, got %+v", status.Labels)
+	}
+	entries := mgr.ListJobsByLabels(map[string]string{"pr": "1234"})
+	if len(entries) != 1 || entries[0].JobID != accepted.JobID || entries[0].Labels["pr"] != "1234" {
+		t.Fatalf("unexpected labeled jobs: %+v", entries)
+	}
+	if entries := mgr.ListJobsByLabels(map[string]string{"pr": "1"}); len(entries) != 0 {
+		t.Fatalf("expected no jobs for other label, got %+v", entries)
+	}
+}
+
+func TestPrepareRequestRejectsInvalidLabels(t *testing.T) {
+	mgr := newManager(t, &fakeStore{})
+	for _, labels := range []map[string]string{{"": "x"}, {"a=b": "x"}, {"a,b": "x"}} {
+		_, err := mgr.prepareRequest(Request{PrepareKind: "psql", ImageID: "image-1", PsqlArgs: []string{"-c", "select 1"}, Labels: labels})
+		var validation ValidationError
+		if !errors.As(err, &validation) {
+			t.Fatalf("labels %v: expected validation error, got %v", labels, err)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package prepare
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sqlrs/engine-local/internal/store"
+)
+
+func TestNormalizeNamespace(t *testing.T) {
+	valid := map[string]string{
+		"":         "",
+		"  ":       "",
+		"team-a":   "team-a",
+		" proj_1 ": "proj_1",
+		"0project": "0project",
+		"a":        "a",
+	}
+	for input, want := range valid {
+		got, err := normalizeNamespace(input)
+		if err != nil {
+			t.Fatalf("normalizeNamespace(%q): %v", input, err)
+		}
+		if got != want {
+			t.Fatalf("normalizeNamespace(%q) = %q, want %q", input, got, want)
+		}
+	}
+	for _, input := range []string{"Team", "-team", "_team", "a/b", "..", "a.b", strings.Repeat("a", 64)} {
+		_, err := normalizeNamespace(input)
+		var validation ValidationError
+		if !errors.As(err, &validation) || validation.Code != "invalid_argument" {
+			t.Fatalf("expected validation error for %q, got %v", input, err)
+		}
+	}
+}
+
+func TestPrepareRequestRejectsInvalidNamespace(t *testing.T) {
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{})
+	_, err := mgr.prepareRequest(Request{
+		PrepareKind: "psql",
+		ImageID:     "image-1",
+		PsqlArgs:    []string{"-c", "select 1"},
+		Namespace:   "../escape",
+	})
+	var validation ValidationError
+	if !errors.As(err, &validation) {
+		t.Fatalf("expected namespace validation error, got %v", err)
+	}
+}
+
+func TestComputeOutputStateIDNamespaces(t *testing.T) {
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{})
+
+	hasher := newStateHasher()
+	hasher.write("input_kind", "image")
+	hasher.write("input_id", "image-1")
+	hasher.write("task_hash", "hash")
+	legacy := hasher.sum()
+
+	defaultID, errResp := mgr.computeOutputStateID("", "image", "image-1", "hash")
+	if errResp != nil {
+		t.Fatalf("computeOutputStateID: %+v", errResp)
+	}
+	if defaultID != legacy {
+		t.Fatalf("expected default namespace to keep state id %s, got %s", legacy, defaultID)
+	}
+	teamA, _ := mgr.computeOutputStateID("team-a", "image", "image-1", "hash")
+	teamB, _ := mgr.computeOutputStateID("team-b", "image", "image-1", "hash")
+	if teamA == defaultID || teamA == teamB {
+		t.Fatalf("expected distinct state ids per namespace: default=%s a=%s b=%s", defaultID, teamA, teamB)
+	}
+}
+
+func TestComputeJobSignatureNamespaces(t *testing.T) {
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{})
+	signature := func(namespace string) string {
+		prepared, err := mgr.prepareRequest(Request{
+			PrepareKind: "psql",
+			ImageID:     "image-1@sha256:abc",
+			PsqlArgs:    []string{"-c", "select 1"},
+			Namespace:   namespace,
+		})
+		if err != nil {
+			t.Fatalf("prepareRequest: %v", err)
+		}
+		value, errResp := mgr.computeJobSignature(prepared)
+		if errResp != nil {
+			t.Fatalf("computeJobSignature: %+v", errResp)
+		}
+		return value
+	}
+	if signature("") == signature("team-a") {
+		t.Fatalf("expected namespace to change job signature")
+	}
+}
+
+func TestCreateInstanceUsesNamespaceRoot(t *testing.T) {
+	stateRoot := filepath.Join(t.TempDir(), "state-store")
+	st := &fakeStore{
+		statesByID: map[string]store.StateEntry{
+			"state-1": {StateID: "state-1", ImageID: "image-1", Namespace: "team-a"},
+		},
+	}
+	runtime := &fakeRuntime{}
+	mgr := newManagerWithDeps(t, st, newQueueStore(t), &testDeps{runtime: runtime, stateRoot: stateRoot})
+	prepared, err := mgr.prepareRequest(Request{
+		PrepareKind: "psql",
+		ImageID:     "image-1",
+		PsqlArgs:    []string{"-c", "select 1"},
+		Namespace:   "team-a",
+	})
+	if err != nil {
+		t.Fatalf("prepareRequest: %v", err)
+	}
+	nsRoot := filepath.Join(stateRoot, "ns", "team-a")
+	paths, err := resolveStatePaths(nsRoot, "image-1", "state-1", mgr.statefs)
+	if err != nil {
+		t.Fatalf("resolveStatePaths: %v", err)
+	}
+	if err := os.MkdirAll(paths.stateDir, 0o700); err != nil {
+		t.Fatalf("mkdir state dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(paths.stateDir, "PG_VERSION"), []byte("17"), 0o600); err != nil {
+		t.Fatalf("write PG_VERSION: %v", err)
+	}
+
+	if _, errResp := mgr.createInstance(context.Background(), "job-1", prepared, "state-1"); errResp != nil {
+		t.Fatalf("createInstance: %+v", errResp)
+	}
+	if len(st.instances) != 1 {
+		t.Fatalf("expected instance create, got %+v", st.instances)
+	}
+	runtimeDir := valueOrEmpty(st.instances[0].RuntimeDir)
+	if !strings.HasPrefix(runtimeDir, filepath.Join(nsRoot, "jobs", "job-1")) {
+		t.Fatalf("expected runtime dir under namespace root, got %q", runtimeDir)
+	}
+}
+
+func TestRemoveJobDirNamespace(t *testing.T) {
+	mgr := &PrepareService{stateStoreRoot: t.TempDir()}
+	defaultDir := filepath.Join(mgr.stateStoreRoot, "jobs", "job-1")
+	nsDir := filepath.Join(mgr.stateStoreRoot, "ns", "team-a", "jobs", "job-1")
+	for _, dir := range []string{defaultDir, nsDir} {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+	}
+	if err := mgr.removeJobDir("team-a", "job-1"); err != nil {
+		t.Fatalf("removeJobDir: %v", err)
+	}
+	if _, err := os.Stat(nsDir); !os.IsNotExist(err) {
+		t.Fatalf("expected namespaced job dir removed")
+	}
+	if _, err := os.Stat(defaultDir); err != nil {
+		t.Fatalf("expected default job dir kept: %v", err)
+	}
+}
+
+func TestMeasureCacheUsageIncludesNamespaces(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{
+		filepath.Join(root, "engines", "pg", "17", "states", "s1"),
+		filepath.Join(root, "ns", "team-a", "engines", "pg", "17", "states", "s2"),
+	} {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "data"), []byte("12345"), 0o600); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	size, err := measureCacheUsage(root)
+	if err != nil {
+		t.Fatalf("measureCacheUsage: %v", err)
+	}
+	if size != 10 {
+		t.Fatalf("expected usage across namespaces to be 10, got %d", size)
+	}
+}
@@ -0,0 +1,141 @@
+package prepare
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/sqlrs/engine-local/internal/store"
+)
+
+// isStateCachedForPlan is isStateCached as seen by the planners: a no_cache
+// request plans every state_execute task as a miss.
+func (m *PrepareService) isStateCachedForPlan(prepared preparedRequest, stateID string) (bool, error) {
+	if prepared.request.NoCache {
+		return false, nil
+	}
+	return m.isStateCached(stateID)
+}
+
+// rebuildStateDir is the sibling dir a no_cache job snapshots into while the
+// cached state it replaces stays usable by other jobs.
+func rebuildStateDir(stateDir string, jobID string) string {
+	return stateDir + ".rebuild-" + jobID
+}
+
+// retiredStateDir is where replaceStateDir moves the state a no_cache job
+// replaced.
+func retiredStateDir(stateDir string, jobID string) string {
+	return stateDir + ".retired-" + jobID
+}
+
+// replaceStateDir moves a rebuilt state into place. On Linux the fresh and
+// the cached dir are exchanged atomically (renameat2 RENAME_EXCHANGE), so a
+// concurrent job cloning the state always finds a complete data dir. Where
+// that is not supported the swap falls back to two renames, which leaves a
+// short window without a state dir.
+//
+// The retired dir is removed unless the backend is overlayfs and instances
+// still reference the state: their mounts use the old dir as lowerdir, so it
+// is left on disk and removed later by sweepRetiredStateDirs.
+func (m *PrepareService) replaceStateDir(ctx context.Context, jobID string, stateID string, stateDir string, freshDir string) *ErrorResponse {
+	retiredDir := retiredStateDir(stateDir, jobID)
+	retired := true
+	if err := exchangeDirs(freshDir, stateDir); err == nil {
+		// freshDir now holds the replaced state.
+		if err := os.Rename(freshDir, retiredDir); err != nil {
+			retiredDir = freshDir
+		}
+	} else {
+		if err := os.Rename(stateDir, retiredDir); err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				return errorResponse(ErrorCodeInternal, "cannot retire cached state dir", err.Error())
+			}
+			retired = false
+		}
+		if err := os.Rename(freshDir, stateDir); err != nil {
+			if retired {
+				_ = os.Rename(retiredDir, stateDir)
+			}
+			return errorResponse(ErrorCodeInternal, "cannot replace cached state dir", err.Error())
+		}
+	}
+	m.appendLog(jobID, fmt.Sprintf("no_cache: replaced cached state %s", stateID))
+	if !retired {
+		return nil
+	}
+	// Pick up dirs earlier jobs had to keep for instances that are gone now.
+	dirs, _ := filepath.Glob(retiredStateDir(stateDir, "*"))
+	if !slices.Contains(dirs, retiredDir) {
+		dirs = append(dirs, retiredDir)
+	}
+	m.removeRetiredStateDirs(ctx, jobID, stateID, dirs)
+	return nil
+}
+
+// removeRetiredStateDirs removes the retired dirs of a state unless they may
+// still back overlay instances of it.
+func (m *PrepareService) removeRetiredStateDirs(ctx context.Context, jobID string, stateID string, dirs []string) {
+	if len(dirs) == 0 {
+		return
+	}
+	if snapshotKind(m.statefs) == "overlayfs" {
+		entry, ok, err := m.store.GetState(ctx, stateID)
+		if err != nil || (ok && entry.RefCount > 0) {
+			for _, dir := range dirs {
+				m.logInfoJob(jobID, "retired state dir kept for live instances state=%s dir=%s", stateID, dir)
+			}
+			return
+		}
+	}
+	for _, dir := range dirs {
+		if err := m.statefs.RemovePath(context.Background(), dir); err != nil {
+			m.logInfoJob(jobID, "cannot remove retired state dir state=%s dir=%s err=%v", stateID, dir, err)
+		}
+	}
+}
+
+// sweepRetiredStateDirs removes the retired dirs no_cache jobs left behind,
+// e.g. those kept for overlay instances that are gone by now, or those of an
+// engine that stopped mid-swap.
+func (m *PrepareService) sweepRetiredStateDirs(ctx context.Context) {
+	if m.store == nil || m.statefs == nil || strings.TrimSpace(m.stateStoreRoot) == "" {
+		return
+	}
+	entries, err := m.store.ListStates(ctx, store.StateFilters{})
+	if err != nil {
+		m.logErrorJob("", "retired state dir sweep skipped: %v", err)
+		return
+	}
+	for _, entry := range entries {
+		if ctx.Err() != nil {
+			return
+		}
+		paths, err := resolveStatePaths(m.namespaceRoot(entry.Namespace), entry.ImageID, entry.StateID, m.statefs)
+		if err != nil || paths.stateDir == "" {
+			continue
+		}
+		dirs, _ := filepath.Glob(retiredStateDir(paths.stateDir, "*"))
+		m.removeRetiredStateDirs(ctx, "", entry.StateID, dirs)
+	}
+}
+
+// commitReplacedState swaps a no_cache rebuild into place and rewrites its
+// checksums and build marker. The store row, including the recorded size, is
+// kept: the rebuilt state has the same inputs as the one it replaces.
+func (m *PrepareService) commitReplacedState(ctx context.Context, jobID string, stateID string, stateDir string, freshDir string, kind string) *ErrorResponse {
+	if errResp := m.replaceStateDir(ctx, jobID, stateID, stateDir, freshDir); errResp != nil {
+		return errResp
+	}
+	if errResp := m.writeStateChecksums(ctx, jobID, stateID, stateDir); errResp != nil {
+		return errResp
+	}
+	if err := writeStateBuildMarker(stateDir, kind); err != nil {
+		return errorResponse(ErrorCodeInternal, "cannot write state marker", err.Error())
+	}
+	return m.ensureCacheCapacity(ctx, jobID, "metadata_commit", stateID)
+}
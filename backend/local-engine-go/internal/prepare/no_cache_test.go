@@ -0,0 +1,263 @@
+package prepare
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sqlrs/engine-local/internal/store"
+)
+
+func TestSubmitPlanOnlyNoCacheMarksTasksUncached(t *testing.T) {
+	fake := &fakeStore{statesByID: map[string]store.StateEntry{}}
+	mgr := newManager(t, fake)
+
+	req := Request{PrepareKind: "psql", ImageID: "image-1", PsqlArgs: []string{"-c", "select 1"}}
+	prepared, err := mgr.prepareRequest(req)
+	if err != nil {
+		t.Fatalf("prepareRequest: %v", err)
+	}
+	if errResp := mgr.ensureResolvedImageID(context.Background(), "job-1", &prepared, nil); errResp != nil {
+		t.Fatalf("ensureResolvedImageID: %+v", errResp)
+	}
+	taskHash, errResp := mgr.computeTaskHash(prepared)
+	if errResp != nil {
+		t.Fatalf("computeTaskHash: %+v", errResp)
+	}
+	stateID, errResp := mgr.computeOutputStateID("", "image", prepared.effectiveImageID(), taskHash)
+	if errResp != nil {
+		t.Fatalf("computeOutputStateID: %+v", errResp)
+	}
+	fake.statesByID[stateID] = store.StateEntry{StateID: stateID}
+
+	req.PlanOnly = true
+	req.NoCache = true
+	if _, err := mgr.Submit(context.Background(), req); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	status, ok := mgr.Get("job-1")
+	if !ok {
+		t.Fatalf("expected job to exist")
+	}
+	if len(status.Tasks) < 3 || status.Tasks[2].Cached == nil || *status.Tasks[2].Cached {
+		t.Fatalf("expected cached false, got %+v", status.Tasks)
+	}
+}
+
+func TestNoCacheChangesJobSignature(t *testing.T) {
+	m := &PrepareService{}
+	base := Request{PrepareKind: "psql", ImageID: "image-1@sha256:abc", PsqlArgs: []string{"-c", "select 1"}}
+	noCache := base
+	noCache.NoCache = true
+	var signatures []string
+	for _, req := range []Request{base, noCache} {
+		prepared, err := m.prepareRequest(req)
+		if err != nil {
+			t.Fatalf("prepareRequest: %v", err)
+		}
+		signature, errResp := m.computeJobSignature(prepared)
+		if errResp != nil {
+			t.Fatalf("computeJobSignature: %+v", errResp)
+		}
+		signatures = append(signatures, signature)
+	}
+	if signatures[0] == signatures[1] {
+		t.Fatalf("expected no_cache to change the signature so it never coalesces with a cached job")
+	}
+}
+
+func TestExecuteStateTaskNoCacheReplacesCachedState(t *testing.T) {
+	runtime := &fakeRuntime{}
+	stateStore := &fakeStore{}
+	mgr := newManagerWithDeps(t, stateStore, newQueueStore(t), &testDeps{runtime: runtime})
+	prepared, err := mgr.prepareRequest(Request{
+		PrepareKind: "psql",
+		ImageID:     "image-1",
+		PsqlArgs:    []string{"-c", "select 1"},
+		NoCache:     true,
+	})
+	if err != nil {
+		t.Fatalf("prepareRequest: %v", err)
+	}
+	input := TaskInput{Kind: "image", ID: "image-1"}
+	stateID := psqlOutputStateIDForStep(t, mgr, prepared, input, "execute-0")
+	stateStore.statesByID = map[string]store.StateEntry{stateID: {StateID: stateID, ImageID: "image-1"}}
+	paths, err := resolveStatePaths(mgr.stateStoreRoot, "image-1", stateID, mgr.statefs)
+	if err != nil {
+		t.Fatalf("resolveStatePaths: %v", err)
+	}
+	if err := os.MkdirAll(paths.stateDir, 0o700); err != nil {
+		t.Fatalf("mkdir state dir: %v", err)
+	}
+	stale := filepath.Join(paths.stateDir, "stale")
+	if err := os.WriteFile(stale, []byte("old"), 0o600); err != nil {
+		t.Fatalf("write stale file: %v", err)
+	}
+
+	runner := mgr.registerRunner("job-1", func() {})
+	t.Cleanup(func() {
+		mgr.cleanupRuntime(context.Background(), runner)
+		close(runner.done)
+		mgr.unregisterRunner("job-1")
+	})
+	got, errResp := mgr.executor.executeStateTask(context.Background(), "job-1", prepared, taskState{PlanTask: PlanTask{
+		TaskID:        "execute-0",
+		Type:          "state_execute",
+		OutputStateID: stateID,
+		Input:         &input,
+	}})
+	if errResp != nil {
+		t.Fatalf("executeStateTask: %+v", errResp)
+	}
+	if got != stateID {
+		t.Fatalf("expected %s, got %s", stateID, got)
+	}
+	if len(runtime.startCalls) != 1 {
+		t.Fatalf("expected the cached state to be rebuilt, got starts %+v", runtime.startCalls)
+	}
+	if len(stateStore.states) != 0 {
+		t.Fatalf("expected the state row to be kept, got creates %+v", stateStore.states)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Fatalf("expected stale state dir to be replaced, stat err=%v", err)
+	}
+	if !stateBuildMarkerExists(paths.stateDir, snapshotKind(mgr.statefs)) {
+		t.Fatalf("expected build marker in replaced state dir")
+	}
+	entries, err := os.ReadDir(paths.statesDir)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	for _, entry := range entries {
+		if strings.Contains(entry.Name(), ".rebuild-") || strings.Contains(entry.Name(), ".retired-") {
+			t.Fatalf("expected no leftover dirs, found %s", entry.Name())
+		}
+	}
+}
+
+func TestReplaceStateDirKeepsOverlayDirInUse(t *testing.T) {
+	stateStore := &fakeStore{statesByID: map[string]store.StateEntry{"state-1": {StateID: "state-1", RefCount: 1}}}
+	mgr := newManagerWithDeps(t, stateStore, newQueueStore(t), &testDeps{statefs: &fakeStateFS{kind: "overlayfs"}})
+	dir := t.TempDir()
+	stateDir := filepath.Join(dir, "state-1")
+	freshDir := rebuildStateDir(stateDir, "job-1")
+	for _, path := range []string{stateDir, freshDir} {
+		if err := os.MkdirAll(path, 0o700); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(path, "PG_VERSION"), []byte(filepath.Base(path)), 0o600); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	if errResp := mgr.replaceStateDir(context.Background(), "job-1", "state-1", stateDir, freshDir); errResp != nil {
+		t.Fatalf("replaceStateDir: %+v", errResp)
+	}
+	data, err := os.ReadFile(filepath.Join(stateDir, "PG_VERSION"))
+	if err != nil || string(data) != filepath.Base(freshDir) {
+		t.Fatalf("expected fresh dir in place, got %q err=%v", data, err)
+	}
+	if _, err := os.Stat(stateDir + ".retired-job-1"); err != nil {
+		t.Fatalf("expected retired dir to be kept for live overlay instances: %v", err)
+	}
+
+	stateStore.statesByID["state-1"] = store.StateEntry{StateID: "state-1"}
+	if err := os.MkdirAll(freshDir, 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if errResp := mgr.replaceStateDir(context.Background(), "job-2", "state-1", stateDir, freshDir); errResp != nil {
+		t.Fatalf("replaceStateDir: %+v", errResp)
+	}
+	for _, job := range []string{"job-1", "job-2"} {
+		if _, err := os.Stat(stateDir + ".retired-" + job); !os.IsNotExist(err) {
+			t.Fatalf("expected unused retired dir of %s to be removed, stat err=%v", job, err)
+		}
+	}
+}
+
+func TestReplaceStateDirExchangeKeepsStateDirPresent(t *testing.T) {
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{statefs: &fakeStateFS{}})
+	dir := t.TempDir()
+	stateDir := filepath.Join(dir, "state-1")
+	freshDir := rebuildStateDir(stateDir, "job-1")
+	for _, path := range []string{stateDir, freshDir} {
+		if err := os.MkdirAll(path, 0o700); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(path, "PG_VERSION"), []byte(filepath.Base(path)), 0o600); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	if err := exchangeDirs(freshDir, stateDir); err != nil {
+		t.Skipf("exchange not supported here: %v", err)
+	}
+	if err := exchangeDirs(freshDir, stateDir); err != nil {
+		t.Fatalf("exchange back: %v", err)
+	}
+
+	stop := make(chan struct{})
+	missing := make(chan struct{}, 1)
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if _, err := os.Stat(filepath.Join(stateDir, "PG_VERSION")); err != nil {
+				select {
+				case missing <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+	errResp := mgr.replaceStateDir(context.Background(), "job-1", "state-1", stateDir, freshDir)
+	close(stop)
+	if errResp != nil {
+		t.Fatalf("replaceStateDir: %+v", errResp)
+	}
+	select {
+	case <-missing:
+		t.Fatalf("expected state dir to stay present during the swap")
+	default:
+	}
+	data, err := os.ReadFile(filepath.Join(stateDir, "PG_VERSION"))
+	if err != nil || string(data) != filepath.Base(freshDir) {
+		t.Fatalf("expected fresh dir in place, got %q err=%v", data, err)
+	}
+	for _, path := range []string{freshDir, stateDir + ".retired-job-1"} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Fatalf("expected %s to be gone, stat err=%v", path, err)
+		}
+	}
+}
+
+func TestSweepRetiredStateDirsRemovesUnusedDirs(t *testing.T) {
+	root := t.TempDir()
+	fs := &fakeStateFS{}
+	stateStore := &fakeStore{listStates: []store.StateEntry{{StateID: "state-1", ImageID: "image-1"}}}
+	mgr := newManagerWithDeps(t, stateStore, newQueueStore(t), &testDeps{statefs: fs})
+	mgr.stateStoreRoot = root
+	paths, err := resolveStatePaths(mgr.namespaceRoot(""), "image-1", "state-1", fs)
+	if err != nil {
+		t.Fatalf("resolveStatePaths: %v", err)
+	}
+	retired := retiredStateDir(paths.stateDir, "job-1")
+	for _, path := range []string{paths.stateDir, retired} {
+		if err := os.MkdirAll(path, 0o700); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+	}
+
+	mgr.sweepRetiredStateDirs(context.Background())
+
+	if _, err := os.Stat(retired); !os.IsNotExist(err) {
+		t.Fatalf("expected retired dir to be swept, stat err=%v", err)
+	}
+	if _, err := os.Stat(paths.stateDir); err != nil {
+		t.Fatalf("expected state dir to be kept: %v", err)
+	}
+}
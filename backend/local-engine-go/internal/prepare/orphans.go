@@ -0,0 +1,145 @@
+package prepare
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	engineRuntime "github.com/sqlrs/engine-local/internal/runtime"
+	"github.com/sqlrs/engine-local/internal/store"
+)
+
+type OrphanReapOptions struct {
+	DryRun bool
+}
+
+type ReapedContainer struct {
+	ContainerID string `json:"container_id"`
+	Name        string `json:"name,omitempty"`
+	JobID       string `json:"job_id,omitempty"`
+	StateID     string `json:"state_id,omitempty"`
+	InstanceID  string `json:"instance_id,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+type OrphanReapResult struct {
+	DryRun  bool              `json:"dry_run"`
+	Checked int               `json:"checked"`
+	Reaped  []ReapedContainer `json:"reaped"`
+}
+
+// ReapOrphans stops managed containers that nothing owns any more: prepare
+// containers whose job is gone and that do not back an instance, run
+// containers whose instance is gone, and warm pool containers left behind by
+// an earlier engine. Only containers carrying this engine's identity label
+// are listed (see runtime.StoreIdentity); containers without a job, instance
+// or warm label are left alone.
+func (m *PrepareService) ReapOrphans(ctx context.Context, opts OrphanReapOptions) (OrphanReapResult, error) {
+	result := OrphanReapResult{DryRun: opts.DryRun, Reaped: []ReapedContainer{}}
+	if m.runtime == nil {
+		return result, fmt.Errorf("container runtime is not configured")
+	}
+	containers, err := m.runtime.ListManaged(ctx)
+	if err != nil {
+		return result, err
+	}
+	result.Checked = len(containers)
+	if len(containers) == 0 {
+		return result, nil
+	}
+	instances, err := m.store.ListInstances(ctx, store.InstanceFilters{})
+	if err != nil {
+		return result, err
+	}
+	for _, container := range containers {
+		orphan, err := m.isOrphanContainer(ctx, container, instances)
+		if err != nil {
+			return result, err
+		}
+		if !orphan {
+			continue
+		}
+		reaped := ReapedContainer{
+			ContainerID: container.ID,
+			Name:        container.Name,
+			JobID:       container.Labels[engineRuntime.LabelJob],
+			StateID:     container.Labels[engineRuntime.LabelState],
+			InstanceID:  container.Labels[engineRuntime.LabelInstance],
+		}
+		if !opts.DryRun {
+			if err := m.runtime.Stop(ctx, container.ID); err != nil {
+				reaped.Error = err.Error()
+			} else {
+				m.logInfoJob(reaped.JobID, "orphan container reaped container=%s", container.ID)
+			}
+		}
+		result.Reaped = append(result.Reaped, reaped)
+	}
+	return result, nil
+}
+
+func (m *PrepareService) isOrphanContainer(ctx context.Context, container engineRuntime.ManagedContainer, instances []store.InstanceEntry) (bool, error) {
+	if instanceID := strings.TrimSpace(container.Labels[engineRuntime.LabelInstance]); instanceID != "" {
+		for _, entry := range instances {
+			if entry.InstanceID == instanceID {
+				return false, nil
+			}
+		}
+		return true, nil
+	}
+	if container.Labels[engineRuntime.LabelWarm] != "" {
+		// A pooled container taken by a job keeps its warm label, so it is
+		// owned by a running job or an instance instead.
+		if m.warm.owns(container.Name) || m.runtimeInUse(container.ID) {
+			return false, nil
+		}
+		return !instancesUseContainer(instances, container.ID), nil
+	}
+	jobID := strings.TrimSpace(container.Labels[engineRuntime.LabelJob])
+	if jobID == "" {
+		return false, nil
+	}
+	_, found, err := m.queue.GetJob(ctx, jobID)
+	if err != nil {
+		return false, err
+	}
+	if found {
+		return false, nil
+	}
+	// Persistent instances keep the prepare container after the job is
+	// deleted.
+	return !instancesUseContainer(instances, container.ID), nil
+}
+
+// instancesUseContainer reports whether an instance runs in the container.
+// ps prints short ids, so they are matched as prefixes.
+func instancesUseContainer(instances []store.InstanceEntry, containerID string) bool {
+	for _, entry := range instances {
+		if sameContainerID(valueOrEmpty(entry.RuntimeID), containerID) {
+			return true
+		}
+	}
+	return false
+}
+
+// runtimeInUse reports whether a running job's runtime is the container.
+func (m *PrepareService) runtimeInUse(containerID string) bool {
+	m.mu.Lock()
+	runners := make([]*jobRunner, 0, len(m.running))
+	for _, runner := range m.running {
+		runners = append(runners, runner)
+	}
+	m.mu.Unlock()
+	for _, runner := range runners {
+		if rt := runner.getRuntime(); rt != nil && sameContainerID(rt.instance.ID, containerID) {
+			return true
+		}
+	}
+	return false
+}
+
+func sameContainerID(a, b string) bool {
+	a = strings.TrimSpace(a)
+	b = strings.TrimSpace(b)
+	return a != "" && b != "" && (strings.HasPrefix(a, b) || strings.HasPrefix(b, a))
+}
@@ -0,0 +1,97 @@
+package prepare
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	engineRuntime "github.com/sqlrs/engine-local/internal/runtime"
+	"github.com/sqlrs/engine-local/internal/store"
+)
+
+func TestStartRuntimeLabelsContainer(t *testing.T) {
+	runtime := &fakeRuntime{}
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{runtime: runtime, statefs: &fakeStateFS{}})
+	prepared, err := mgr.prepareRequest(Request{PrepareKind: "psql", ImageID: "image-1", PsqlArgs: []string{"-c", "select 1"}})
+	if err != nil {
+		t.Fatalf("prepareRequest: %v", err)
+	}
+	rt, errResp := mgr.startRuntime(context.Background(), "job-1", prepared, &TaskInput{Kind: "image", ID: "image-1"})
+	if errResp != nil {
+		t.Fatalf("startRuntime: %+v", errResp)
+	}
+	defer rt.cleanup()
+	if len(runtime.startCalls) != 1 {
+		t.Fatalf("expected one start, got %+v", runtime.startCalls)
+	}
+	labels := runtime.startCalls[0].Labels
+	if labels[engineRuntime.LabelJob] != "job-1" {
+		t.Fatalf("expected job label, got %+v", labels)
+	}
+	if _, ok := labels[engineRuntime.LabelState]; ok {
+		t.Fatalf("expected no state label for image input, got %+v", labels)
+	}
+
+	labels = runtimeLabels("job-2", &TaskInput{Kind: "state", ID: "state-1"})
+	if labels[engineRuntime.LabelJob] != "job-2" || labels[engineRuntime.LabelState] != "state-1" {
+		t.Fatalf("unexpected labels for state input: %+v", labels)
+	}
+}
+
+func TestReapOrphansStopsContainersWithoutOwner(t *testing.T) {
+	runtime := &fakeRuntime{managed: []engineRuntime.ManagedContainer{
+		{ID: "live-job", Labels: map[string]string{engineRuntime.LabelJob: "job-live"}},
+		{ID: "gone-job", Labels: map[string]string{engineRuntime.LabelJob: "job-gone"}},
+		{ID: "abc123", Labels: map[string]string{engineRuntime.LabelJob: "job-persistent"}},
+		{ID: "run-live", Labels: map[string]string{engineRuntime.LabelInstance: "inst-1"}},
+		{ID: "run-gone", Labels: map[string]string{engineRuntime.LabelInstance: "inst-gone"}},
+		{ID: "unlabeled"},
+	}}
+	st := &fakeStore{listInstances: []store.InstanceEntry{
+		{InstanceID: "inst-1"},
+		{InstanceID: "inst-2", RuntimeID: strPtr("abc123def456")},
+	}}
+	queueStore := newQueueStore(t)
+	mgr := newManagerWithDeps(t, st, queueStore, &testDeps{runtime: runtime})
+	createJobRecord(t, queueStore, "job-live", Request{PrepareKind: "psql", ImageID: "image-1"}, StatusRunning)
+
+	result, err := mgr.ReapOrphans(context.Background(), OrphanReapOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("ReapOrphans dry run: %v", err)
+	}
+	if !result.DryRun || result.Checked != 6 || len(result.Reaped) != 2 || len(runtime.stopCalls) != 0 {
+		t.Fatalf("unexpected dry run result %+v stops=%v", result, runtime.stopCalls)
+	}
+
+	result, err = mgr.ReapOrphans(context.Background(), OrphanReapOptions{})
+	if err != nil {
+		t.Fatalf("ReapOrphans: %v", err)
+	}
+	if len(result.Reaped) != 2 || result.Reaped[0].ContainerID != "gone-job" || result.Reaped[0].JobID != "job-gone" ||
+		result.Reaped[1].ContainerID != "run-gone" || result.Reaped[1].InstanceID != "inst-gone" {
+		t.Fatalf("unexpected reaped containers: %+v", result.Reaped)
+	}
+	if len(runtime.stopCalls) != 2 || runtime.stopCalls[0] != "gone-job" || runtime.stopCalls[1] != "run-gone" {
+		t.Fatalf("unexpected stop calls: %v", runtime.stopCalls)
+	}
+}
+
+func TestReapOrphansReportsStopAndListErrors(t *testing.T) {
+	runtime := &fakeRuntime{
+		managed: []engineRuntime.ManagedContainer{{ID: "gone", Labels: map[string]string{engineRuntime.LabelJob: "job-gone"}}},
+		stopErr: errors.New("boom"),
+	}
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{runtime: runtime})
+	result, err := mgr.ReapOrphans(context.Background(), OrphanReapOptions{})
+	if err != nil {
+		t.Fatalf("ReapOrphans: %v", err)
+	}
+	if len(result.Reaped) != 1 || result.Reaped[0].Error != "boom" {
+		t.Fatalf("expected stop error in result, got %+v", result.Reaped)
+	}
+
+	runtime.listErr = errors.New("daemon down")
+	if _, err := mgr.ReapOrphans(context.Background(), OrphanReapOptions{}); err == nil {
+		t.Fatalf("expected list error")
+	}
+}
@@ -0,0 +1,105 @@
+package prepare
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	engineRuntime "github.com/sqlrs/engine-local/internal/runtime"
+)
+
+// pgpassContainerPath is where Request.Pgpass is mounted in prepare
+// containers; psql finds it through PGPASSFILE.
+const pgpassContainerPath = "/run/sqlrs/pgpass"
+
+// validatePgpass checks that content is a .pgpass file whose passwords are
+// all ${secret:name} references. Stored requests then never hold a password,
+// the same as liquibase_env and flyway_env with secrets.
+func validatePgpass(content string) error {
+	for i, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := splitPgpassLine(line)
+		if len(fields) != 5 {
+			return ValidationError{Code: ErrorCodeInvalidArgument, Message: "pgpass lines must be hostname:port:database:username:password", Details: fmt.Sprintf("line %d", i+1)}
+		}
+		password := fields[4]
+		if err := validateSecretRefs("pgpass", map[string]string{fmt.Sprintf("line %d", i+1): password}); err != nil {
+			return err
+		}
+		if strings.TrimSpace(secretRefPattern.ReplaceAllString(password, "")) != "" || !secretRefPattern.MatchString(password) {
+			return ValidationError{Code: ErrorCodeInvalidArgument, Message: "pgpass passwords must be ${secret:name} references", Details: fmt.Sprintf("line %d", i+1)}
+		}
+	}
+	return nil
+}
+
+// splitPgpassLine splits a .pgpass line on the colons that libpq treats as
+// separators; "\:" and "\\" are escapes, and the colon inside a
+// ${secret:name} reference is not a separator.
+func splitPgpassLine(line string) []string {
+	var fields []string
+	var current strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line):
+			current.WriteByte(line[i])
+			current.WriteByte(line[i+1])
+			i++
+		case strings.HasPrefix(line[i:], "${"):
+			end := strings.IndexByte(line[i:], '}')
+			if end < 0 {
+				current.WriteString(line[i:])
+				return append(fields, current.String())
+			}
+			current.WriteString(line[i : i+end+1])
+			i += end
+		case line[i] == ':':
+			fields = append(fields, current.String())
+			current.Reset()
+		default:
+			current.WriteByte(line[i])
+		}
+	}
+	return append(fields, current.String())
+}
+
+// writePgpass resolves the secrets of Request.Pgpass and writes it as a 0600
+// file next to the job's runtime dir, returning the mount for the prepare
+// container and the resolved values for log redaction. The mount is owned:
+// the runtime hands the file to the container user, since psql runs as that
+// user and libpq needs a pgpass it can read but nobody else can. It returns no mount
+// when the request has no pgpass.
+func (m *PrepareService) writePgpass(ctx context.Context, jobID string, prepared preparedRequest, runtimeDir string) ([]engineRuntime.Mount, secretValues, *ErrorResponse) {
+	if strings.TrimSpace(prepared.request.Pgpass) == "" {
+		return nil, nil, nil
+	}
+	resolved, secrets, errResp := m.resolveEnvSecrets(ctx, map[string]string{"pgpass": prepared.request.Pgpass})
+	if errResp != nil {
+		return nil, nil, errResp
+	}
+	content := resolved["pgpass"]
+	if !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	path := filepath.Join(filepath.Dir(runtimeDir), "pgpass")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		return nil, nil, errorResponse(ErrorCodeInternal, "cannot write pgpass", err.Error())
+	}
+	// WriteFile keeps the mode of an existing file; libpq ignores a pgpass
+	// that group or others can read.
+	if err := os.Chmod(path, 0o600); err != nil {
+		return nil, nil, errorResponse(ErrorCodeInternal, "cannot write pgpass", err.Error())
+	}
+	m.logInfoJob(jobID, "pgpass mounted at %s", pgpassContainerPath)
+	return []engineRuntime.Mount{{
+		HostPath:      path,
+		ContainerPath: pgpassContainerPath,
+		ReadOnly:      true,
+		Owned:         true,
+	}}, secrets, nil
+}
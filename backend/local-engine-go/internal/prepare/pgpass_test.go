@@ -0,0 +1,138 @@
+package prepare
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStartRuntimeMountsPgpass(t *testing.T) {
+	runtime := &fakeRuntime{}
+	psql := &fakePsqlRunner{output: "could not connect as app/hunter2"}
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{
+		runtime: runtime,
+		statefs: &fakeStateFS{},
+		psql:    psql,
+	})
+	mgr.secrets = &fakeSecretProvider{values: map[string]string{"app_pw": "hunter2"}}
+	prepared, err := mgr.prepareRequest(Request{
+		PrepareKind: "psql",
+		ImageID:     "image-1",
+		PsqlArgs:    []string{"-c", "select 1"},
+		Pgpass:      "# external\nreports.example:5432:*:app:${secret:app_pw}",
+	})
+	if err != nil {
+		t.Fatalf("prepareRequest: %v", err)
+	}
+
+	rt, errResp := mgr.startRuntime(context.Background(), "job-1", prepared, &TaskInput{Kind: "image", ID: "image-1"})
+	if errResp != nil {
+		t.Fatalf("startRuntime: %+v", errResp)
+	}
+	defer rt.cleanup()
+	path := filepath.Join(filepath.Dir(rt.runtimeDir), "pgpass")
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat pgpass: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Fatalf("expected 0600 pgpass, got %v", info.Mode().Perm())
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "# external\nreports.example:5432:*:app:hunter2\n" {
+		t.Fatalf("unexpected pgpass content %q err=%v", data, err)
+	}
+	var mounted bool
+	for _, mount := range runtime.startCalls[0].Mounts {
+		if mount.HostPath == path && mount.ContainerPath == pgpassContainerPath && mount.ReadOnly && mount.Owned {
+			mounted = true
+		}
+	}
+	if !mounted {
+		t.Fatalf("expected an owned pgpass mount, got %+v", runtime.startCalls[0].Mounts)
+	}
+
+	if errResp := mgr.executePsqlStep(context.Background(), "job-1", prepared, rt, taskState{}); errResp != nil {
+		t.Fatalf("executePsqlStep: %+v", errResp)
+	}
+	if got := psql.runs[0].Env["PGPASSFILE"]; got != pgpassContainerPath {
+		t.Fatalf("expected PGPASSFILE=%s, got %+v", pgpassContainerPath, psql.runs[0].Env)
+	}
+	events, err := mgr.queue.ListEventsSince(context.Background(), "job-1", 0)
+	if err != nil {
+		t.Fatalf("ListEventsSince: %v", err)
+	}
+	for _, event := range events {
+		if strings.Contains(valueOrEmpty(event.Message), "hunter2") {
+			t.Fatalf("pgpass password leaked into log: %q", valueOrEmpty(event.Message))
+		}
+	}
+	if strings.Contains(prepared.request.Pgpass, "hunter2") {
+		t.Fatalf("expected the request to keep the reference")
+	}
+}
+
+func TestPrepareRequestValidatesPgpass(t *testing.T) {
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{})
+	cases := []struct {
+		name    string
+		kind    string
+		pgpass  string
+		message string
+	}{
+		{name: "literal password", kind: "psql", pgpass: "db:5432:*:app:hunter2", message: "must be ${secret:name} references"},
+		{name: "mixed password", kind: "psql", pgpass: "db:5432:*:app:x${secret:pw}", message: "must be ${secret:name} references"},
+		{name: "malformed reference", kind: "psql", pgpass: "db:5432:*:app:${secret:pw-1}", message: "malformed secret reference"},
+		{name: "field count", kind: "psql", pgpass: "db:5432:app:${secret:pw}", message: "hostname:port:database:username:password"},
+		{name: "liquibase", kind: "lb", pgpass: "db:5432:*:app:${secret:pw}", message: "only supported for psql"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := mgr.prepareRequest(Request{
+				PrepareKind:   tc.kind,
+				ImageID:       "image-1",
+				PsqlArgs:      []string{"-c", "select 1"},
+				LiquibaseArgs: []string{"update"},
+				Pgpass:        tc.pgpass,
+			})
+			var validation ValidationError
+			if !errors.As(err, &validation) || !strings.Contains(validation.Message, tc.message) {
+				t.Fatalf("expected %q validation error, got %v", tc.message, err)
+			}
+		})
+	}
+
+	if _, err := mgr.prepareRequest(Request{
+		PrepareKind: "psql",
+		ImageID:     "image-1",
+		PsqlArgs:    []string{"-c", "select 1"},
+		Pgpass:      `db\:primary:5432:*:app:${secret:pw}`,
+	}); err != nil {
+		t.Fatalf("expected escaped colon to be accepted, got %v", err)
+	}
+}
+
+func TestStartRuntimeFailsOnMissingPgpassSecret(t *testing.T) {
+	runtime := &fakeRuntime{}
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{runtime: runtime, statefs: &fakeStateFS{}})
+	mgr.secrets = &fakeSecretProvider{}
+	prepared, err := mgr.prepareRequest(Request{
+		PrepareKind: "psql",
+		ImageID:     "image-1",
+		PsqlArgs:    []string{"-c", "select 1"},
+		Pgpass:      "db:5432:*:app:${secret:missing}",
+	})
+	if err != nil {
+		t.Fatalf("prepareRequest: %v", err)
+	}
+	_, errResp := mgr.startRuntime(context.Background(), "job-1", prepared, &TaskInput{Kind: "image", ID: "image-1"})
+	if errResp == nil || errResp.Message != "cannot resolve secret" {
+		t.Fatalf("expected missing secret error, got %+v", errResp)
+	}
+	if len(runtime.startCalls) != 0 {
+		t.Fatalf("expected no container without the pgpass secrets")
+	}
+}
@@ -0,0 +1,42 @@
+package prepare
+
+import (
+	"strings"
+
+	"github.com/sqlrs/engine-local/internal/config"
+)
+
+// parsePlatform validates a --platform value such as "linux/amd64".
+func parsePlatform(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", nil
+	}
+	if !config.ValidPlatform(value) {
+		return "", ValidationError{Code: ErrorCodeInvalidArgument, Message: "platform must be os/arch[/variant], for example linux/amd64", Details: value}
+	}
+	return value, nil
+}
+
+// containerPlatform resolves the image platform for a job: the request wins,
+// then container.platform. Unlike resource limits, the platform selects which
+// image is resolved, so it reaches the state id through the resolved digest.
+// Invalid config values are ignored.
+func (m *PrepareService) containerPlatform(requested string) string {
+	if requested != "" || m.config == nil {
+		return requested
+	}
+	value, err := m.config.Get("container.platform", true)
+	if err != nil || value == nil {
+		return ""
+	}
+	raw, ok := value.(string)
+	if !ok {
+		return ""
+	}
+	platform, err := parsePlatform(raw)
+	if err != nil {
+		return ""
+	}
+	return platform
+}
@@ -0,0 +1,83 @@
+package prepare
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func submitWithPlatform(t *testing.T, platform string) (*fakeRuntime, Result) {
+	t.Helper()
+	rt := &fakeRuntime{}
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{runtime: rt})
+	accepted, err := mgr.Submit(context.Background(), Request{
+		PrepareKind: "psql",
+		ImageID:     "postgres:17",
+		PsqlArgs:    []string{"-c", "select 1"},
+		Platform:    platform,
+	})
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	status, ok := mgr.Get(accepted.JobID)
+	if !ok || status.Status != StatusSucceeded || status.Result == nil {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+	return rt, *status.Result
+}
+
+func TestPlatformReachesRuntime(t *testing.T) {
+	rt, result := submitWithPlatform(t, "linux/amd64")
+	if len(rt.platforms) != 1 || rt.platforms[0] != "linux/amd64" {
+		t.Fatalf("expected platform to reach ResolveImage, got %v", rt.platforms)
+	}
+	if len(rt.startCalls) == 0 || rt.startCalls[0].Platform != "linux/amd64" {
+		t.Fatalf("expected platform to reach Start, got %+v", rt.startCalls)
+	}
+	if result.ImageID != "postgres:17@sha256:resolved-linux-amd64" {
+		t.Fatalf("expected the platform digest to be stored, got %q", result.ImageID)
+	}
+}
+
+func TestPlatformsGetDistinctStates(t *testing.T) {
+	_, amd64 := submitWithPlatform(t, "linux/amd64")
+	_, arm64 := submitWithPlatform(t, "linux/arm64")
+	if amd64.ImageID == arm64.ImageID {
+		t.Fatalf("expected distinct resolved digests, got %q", amd64.ImageID)
+	}
+	if amd64.StateID == arm64.StateID {
+		t.Fatalf("expected distinct state ids, got %q", amd64.StateID)
+	}
+}
+
+func TestPlatformDefaultsToConfig(t *testing.T) {
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{config: &fakeConfigStore{values: map[string]any{
+		"container.platform": "linux/arm64",
+	}}})
+	prepared, err := mgr.prepareRequest(Request{PrepareKind: "psql", ImageID: "postgres@sha256:abc", PsqlArgs: []string{"-c", "select 1"}})
+	if err != nil {
+		t.Fatalf("prepareRequest: %v", err)
+	}
+	if prepared.request.Platform != "linux/arm64" {
+		t.Fatalf("expected config platform, got %q", prepared.request.Platform)
+	}
+	if !needsImageResolve(prepared.request.ImageID, prepared.request.Platform) {
+		t.Fatalf("expected a pinned digest to be resolved for the platform")
+	}
+
+	prepared, err = mgr.prepareRequest(Request{PrepareKind: "psql", ImageID: "image-1", PsqlArgs: []string{"-c", "select 1"}, Platform: "linux/amd64"})
+	if err != nil || prepared.request.Platform != "linux/amd64" {
+		t.Fatalf("expected request platform to win, got %q err=%v", prepared.request.Platform, err)
+	}
+}
+
+func TestPlatformValidation(t *testing.T) {
+	mgr := newManager(t, &fakeStore{})
+	for _, platform := range []string{"amd64", "linux/amd64/v8/x", "Linux/AMD64", "linux/amd64 --privileged"} {
+		_, err := mgr.prepareRequest(Request{PrepareKind: "psql", ImageID: "image-1", PsqlArgs: []string{"-c", "select 1"}, Platform: platform})
+		var validation ValidationError
+		if !errors.As(err, &validation) {
+			t.Fatalf("%q: expected validation error, got %v", platform, err)
+		}
+	}
+}
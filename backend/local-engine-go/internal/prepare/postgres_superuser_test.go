@@ -0,0 +1,73 @@
+package prepare
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	engineRuntime "github.com/sqlrs/engine-local/internal/runtime"
+)
+
+func TestBuildDSNUsesSuperuser(t *testing.T) {
+	if dsn := buildDSN("127.0.0.1", 5432, ""); dsn != "postgres://sqlrs@127.0.0.1:5432/postgres" {
+		t.Fatalf("unexpected default dsn: %s", dsn)
+	}
+	if dsn := buildDSN("127.0.0.1", 5432, "postgres"); dsn != "postgres://postgres@127.0.0.1:5432/postgres" {
+		t.Fatalf("unexpected dsn: %s", dsn)
+	}
+}
+
+func TestSubmitWithCustomSuperuser(t *testing.T) {
+	psql := &fakePsqlRunner{}
+	st := &fakeStore{}
+	mgr := newManagerWithDeps(t, st, newQueueStore(t), &testDeps{psql: psql})
+	mgr.superuser = "postgres"
+
+	accepted, err := mgr.Submit(context.Background(), Request{
+		PrepareKind: "psql",
+		ImageID:     "image-1",
+		PsqlArgs:    []string{"-c", "select 1"},
+	})
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	status, ok := mgr.Get(accepted.JobID)
+	if !ok || status.Status != StatusSucceeded || status.Result == nil {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+	if !strings.HasPrefix(status.Result.DSN, "postgres://postgres@") {
+		t.Fatalf("expected dsn for the postgres superuser, got %s", status.Result.DSN)
+	}
+	if len(psql.runs) != 1 || !containsArgPair(psql.runs[0].Args, "-U", "postgres") || psql.runs[0].User != "postgres" {
+		t.Fatalf("expected psql to connect as postgres, got %+v", psql.runs)
+	}
+}
+
+func TestSuperuserKeysStateIDs(t *testing.T) {
+	mgr := newManager(t, &fakeStore{})
+	defaultID, errResp := mgr.computeOutputStateID("", "image", "image-1", "task")
+	if errResp != nil {
+		t.Fatalf("computeOutputStateID: %+v", errResp)
+	}
+	mgr.superuser = engineRuntime.DefaultPostgresSuperuser
+	if id, _ := mgr.computeOutputStateID("", "image", "image-1", "task"); id != defaultID {
+		t.Fatalf("expected explicit default superuser to keep state id %s, got %s", defaultID, id)
+	}
+	mgr.superuser = "postgres"
+	if id, _ := mgr.computeOutputStateID("", "image", "image-1", "task"); id == defaultID {
+		t.Fatalf("expected postgres superuser to change the state id")
+	}
+}
+
+func TestCacheExplainPlannerKeepsSuperuser(t *testing.T) {
+	mgr := newManager(t, &fakeStore{})
+	mgr.superuser = "postgres"
+	planner, _, cleanup, err := mgr.newCacheExplainPlanner()
+	if err != nil {
+		t.Fatalf("newCacheExplainPlanner: %v", err)
+	}
+	defer cleanup()
+	if planner.postgresSuperuser() != "postgres" {
+		t.Fatalf("expected planner to hash states for postgres, got %q", planner.postgresSuperuser())
+	}
+}
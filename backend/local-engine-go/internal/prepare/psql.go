@@ -13,9 +13,10 @@ type psqlPrepared struct {
 	steps          []psqlStep
 	filePaths      []string
 	workDir        string
+	limits         psqlScriptLimits
 }
 
-func preparePsqlArgs(args []string, stdin *string) (psqlPrepared, error) {
+func preparePsqlArgs(args []string, stdin *string, limits psqlScriptLimits) (psqlPrepared, error) {
 	normalized := append([]string{}, args...)
 	var inputs []psqlInput
 	var filePaths []string
@@ -33,13 +34,13 @@ func preparePsqlArgs(args []string, stdin *string) (psqlPrepared, error) {
 
 		if arg == "--" {
 			if i+1 < len(args) {
-				return psqlPrepared{}, ValidationError{Code: "invalid_argument", Message: "positional arguments are not allowed"}
+				return psqlPrepared{}, ValidationError{Code: ErrorCodeInvalidArgument, Message: "positional arguments are not allowed"}
 			}
 			continue
 		}
 
 		if isConnectionFlag(arg) {
-			return psqlPrepared{}, ValidationError{Code: "invalid_argument", Message: "connection flags are not allowed", Details: arg}
+			return psqlPrepared{}, ValidationError{Code: ErrorCodeInvalidArgument, Message: "connection flags are not allowed", Details: arg}
 		}
 
 		if arg == "-X" || arg == "--no-psqlrc" {
@@ -68,18 +69,31 @@ func preparePsqlArgs(args []string, stdin *string) (psqlPrepared, error) {
 		if strings.HasPrefix(arg, "-") {
 			continue
 		}
-		return psqlPrepared{}, ValidationError{Code: "invalid_argument", Message: "positional database arguments are not allowed", Details: arg}
+		return psqlPrepared{}, ValidationError{Code: ErrorCodeInvalidArgument, Message: "positional database arguments are not allowed", Details: arg}
 	}
 
+	copyCommands := countPsqlCopyFromStdin(inputs)
+	if usesStdin && copyCommands > 0 {
+		return psqlPrepared{}, ValidationError{Code: ErrorCodeInvalidArgument, Message: "stdin cannot feed both -f - and COPY ... FROM STDIN"}
+	}
 	if usesStdin && stdin == nil {
-		return psqlPrepared{}, ValidationError{Code: "invalid_argument", Message: "stdin is required when using -f -"}
+		return psqlPrepared{}, ValidationError{Code: ErrorCodeInvalidArgument, Message: "stdin is required when using -f -"}
+	}
+	if !usesStdin && copyCommands == 0 && stdin != nil {
+		return psqlPrepared{}, ValidationError{Code: ErrorCodeInvalidArgument, Message: "stdin is only valid with -f - or COPY ... FROM STDIN"}
 	}
-	if !usesStdin && stdin != nil {
-		return psqlPrepared{}, ValidationError{Code: "invalid_argument", Message: "stdin is only valid with -f -"}
+	if copyCommands > 1 && stdin != nil {
+		return psqlPrepared{}, ValidationError{Code: ErrorCodeInvalidArgument, Message: "stdin can feed only one COPY ... FROM STDIN command"}
+	}
+	if copyCommands > 0 && stdin != nil {
+		inputs = withPsqlCopyData(inputs, *stdin)
 	}
 	if workDir == "" && strings.TrimSpace(cwd) != "" {
 		workDir = cwd
 	}
+	if err := checkPsqlScriptLimits(filePaths, stdin, limits); err != nil {
+		return psqlPrepared{}, err
+	}
 
 	if !hasNoPsqlrc {
 		normalized = append(normalized, "-X")
@@ -99,9 +113,72 @@ func preparePsqlArgs(args []string, stdin *string) (psqlPrepared, error) {
 		steps:          steps,
 		filePaths:      filePaths,
 		workDir:        workDir,
+		limits:         limits,
 	}, nil
 }
 
+// checkPsqlScriptLimits applies the limits to the -f files and stdin before
+// anything is read. Included files are checked as the script is expanded.
+func checkPsqlScriptLimits(filePaths []string, stdin *string, limits psqlScriptLimits) error {
+	if stdin != nil {
+		if err := limits.checkStdin(int64(len(*stdin))); err != nil {
+			return err
+		}
+		if err := limits.checkFile("stdin", int64(len(*stdin)), 0); err != nil {
+			return err
+		}
+	}
+	seen := map[string]struct{}{}
+	for _, path := range filePaths {
+		path = filepath.Clean(path)
+		if _, ok := seen[path]; ok {
+			continue
+		}
+		seen[path] = struct{}{}
+		info, err := os.Stat(path)
+		if err != nil {
+			return ValidationError{Code: ErrorCodeInvalidArgument, Message: "cannot read file", Details: path}
+		}
+		if err := limits.checkFile(path, info.Size(), len(seen)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func hasPsqlSingleTransactionFlag(args []string) bool {
+	for _, arg := range args {
+		if arg == "-1" || arg == "--single-transaction" {
+			return true
+		}
+	}
+	return false
+}
+
+// checkPsqlSingleTransactionInputs rejects scripts that manage their own
+// transactions: psql wraps the whole run in BEGIN/COMMIT, so an explicit
+// COMMIT would end that transaction early and break the rollback guarantee.
+func checkPsqlSingleTransactionInputs(inputs []psqlInput, workDir string, limits psqlScriptLimits) error {
+	script := make([]psqlInput, 0, len(inputs))
+	for _, input := range inputs {
+		if input.kind != psqlInputCopyData {
+			script = append(script, input)
+		}
+	}
+	content, err := expandPsqlInputs(script, workDir, limits)
+	if err != nil {
+		return ValidationError{Code: ErrorCodeInvalidArgument, Message: "cannot read psql script", Details: err.Error()}
+	}
+	if stmt := psqlTransactionControlStatement(content); stmt != "" {
+		return ValidationError{
+			Code:    ErrorCodeInvalidArgument,
+			Message: "single-transaction mode cannot run scripts with explicit transaction control",
+			Details: stmt,
+		}
+	}
+	return nil
+}
+
 func isConnectionFlag(arg string) bool {
 	switch arg {
 	case "-h", "-p", "-U", "-d", "--host", "--port", "--username", "--dbname", "--database":
@@ -135,7 +212,7 @@ func handleVarFlag(args []string, index *int, hasOnErrorStop *bool) (bool, error
 	switch {
 	case arg == "-v" || arg == "--set" || arg == "--variable":
 		if *index+1 >= len(args) {
-			return true, ValidationError{Code: "invalid_argument", Message: "missing value for variable flag", Details: arg}
+			return true, ValidationError{Code: ErrorCodeInvalidArgument, Message: "missing value for variable flag", Details: arg}
 		}
 		val := args[*index+1]
 		if err := checkOnErrorStop(val, hasOnErrorStop); err != nil {
@@ -171,7 +248,7 @@ func handleFileFlag(args []string, index *int, stdin *string, usesStdin *bool, i
 	switch {
 	case arg == "-f" || arg == "--file":
 		if *index+1 >= len(args) {
-			return true, ValidationError{Code: "invalid_argument", Message: "missing value for file flag", Details: arg}
+			return true, ValidationError{Code: ErrorCodeInvalidArgument, Message: "missing value for file flag", Details: arg}
 		}
 		path := args[*index+1]
 		if err := addFileInput(path, stdin, usesStdin, inputs, filePaths, workDir); err != nil {
@@ -182,7 +259,7 @@ func handleFileFlag(args []string, index *int, stdin *string, usesStdin *bool, i
 	case strings.HasPrefix(arg, "--file="):
 		path := strings.TrimPrefix(arg, "--file=")
 		if path == "" {
-			return true, ValidationError{Code: "invalid_argument", Message: "missing value for file flag", Details: arg}
+			return true, ValidationError{Code: ErrorCodeInvalidArgument, Message: "missing value for file flag", Details: arg}
 		}
 		if err := addFileInput(path, stdin, usesStdin, inputs, filePaths, workDir); err != nil {
 			return true, err
@@ -204,7 +281,7 @@ func handleCommandFlag(args []string, index *int, inputs *[]psqlInput) (bool, er
 	switch {
 	case arg == "-c" || arg == "--command":
 		if *index+1 >= len(args) {
-			return true, ValidationError{Code: "invalid_argument", Message: "missing value for command flag", Details: arg}
+			return true, ValidationError{Code: ErrorCodeInvalidArgument, Message: "missing value for command flag", Details: arg}
 		}
 		cmd := args[*index+1]
 		*inputs = append(*inputs, psqlInput{kind: "command", value: cmd})
@@ -232,13 +309,13 @@ func addFileInput(path string, stdin *string, usesStdin *bool, inputs *[]psqlInp
 		return nil
 	}
 	if path == "" {
-		return ValidationError{Code: "invalid_argument", Message: "file path is empty"}
+		return ValidationError{Code: ErrorCodeInvalidArgument, Message: "file path is empty"}
 	}
 	if !filepath.IsAbs(path) {
-		return ValidationError{Code: "invalid_argument", Message: "file path must be absolute", Details: path}
+		return ValidationError{Code: ErrorCodeInvalidArgument, Message: "file path must be absolute", Details: path}
 	}
 	if _, err := os.Stat(path); err != nil {
-		return ValidationError{Code: "invalid_argument", Message: "cannot read file", Details: path}
+		return ValidationError{Code: ErrorCodeInvalidArgument, Message: "cannot read file", Details: path}
 	}
 	*inputs = append(*inputs, psqlInput{kind: "file", value: path})
 	if filePaths != nil {
@@ -254,14 +331,14 @@ func checkOnErrorStop(value string, hasOnErrorStop *bool) error {
 	name, val, ok := splitAssignment(value)
 	if !ok {
 		if strings.EqualFold(strings.TrimSpace(value), "ON_ERROR_STOP") {
-			return ValidationError{Code: "invalid_argument", Message: "ON_ERROR_STOP must be set to 1"}
+			return ValidationError{Code: ErrorCodeInvalidArgument, Message: "ON_ERROR_STOP must be set to 1"}
 		}
 		return nil
 	}
 	if strings.EqualFold(strings.TrimSpace(name), "ON_ERROR_STOP") {
 		*hasOnErrorStop = true
 		if strings.TrimSpace(val) != "1" {
-			return ValidationError{Code: "invalid_argument", Message: "ON_ERROR_STOP must be set to 1", Details: value}
+			return ValidationError{Code: ErrorCodeInvalidArgument, Message: "ON_ERROR_STOP must be set to 1", Details: value}
 		}
 	}
 	return nil
@@ -32,7 +32,7 @@ func scriptMountForFiles(paths []string) (*scriptMount, error) {
 	}, nil
 }
 
-func buildPsqlExecArgs(args []string, mount *scriptMount) ([]string, string, error) {
+func buildPsqlExecArgs(args []string, mount *scriptMount, user string) ([]string, string, error) {
 	rewritten, workdir, err := rewritePsqlFileArgs(args, mount)
 	if err != nil {
 		return nil, "", err
@@ -41,7 +41,7 @@ func buildPsqlExecArgs(args []string, mount *scriptMount) ([]string, string, err
 		"psql",
 		"-h", "127.0.0.1",
 		"-p", "5432",
-		"-U", "sqlrs",
+		"-U", engineRuntime.PostgresSuperuser(user),
 		"-d", "postgres",
 	}
 	execArgs = append(execArgs, rewritten...)
@@ -37,7 +37,7 @@ func TestBuildPsqlExecArgsPrependsConnectionFlags(t *testing.T) {
 		HostRoot:      root,
 		ContainerRoot: containerScriptsRoot,
 	}
-	args, _, err := buildPsqlExecArgs([]string{"-f", path}, mount)
+	args, _, err := buildPsqlExecArgs([]string{"-f", path}, mount, "")
 	if err != nil {
 		t.Fatalf("buildPsqlExecArgs: %v", err)
 	}
@@ -182,7 +182,7 @@ func TestBuildPsqlExecArgsReturnsError(t *testing.T) {
 		HostRoot:      root,
 		ContainerRoot: containerScriptsRoot,
 	}
-	if _, _, err := buildPsqlExecArgs([]string{"-f"}, mount); err == nil {
+	if _, _, err := buildPsqlExecArgs([]string{"-f"}, mount, ""); err == nil {
 		t.Fatalf("expected buildPsqlExecArgs error")
 	}
 }
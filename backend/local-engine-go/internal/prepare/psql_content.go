@@ -5,6 +5,7 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -20,47 +21,94 @@ type psqlContentDigest struct {
 	filePaths []string
 }
 
-func computePsqlContentDigest(inputs []psqlInput, workDir string) (psqlContentDigest, error) {
+func computePsqlContentDigest(inputs []psqlInput, workDir string, limits psqlScriptLimits, normalize bool) (psqlContentDigest, error) {
 	locker := &contentLock{files: map[string]*os.File{}}
 	defer locker.Close()
-	return computePsqlContentDigestWithLock(inputs, workDir, locker)
+	return computePsqlContentDigestWithLock(inputs, workDir, locker, limits, normalize)
 }
 
-func computePsqlContentDigestWithLock(inputs []psqlInput, workDir string, locker *contentLock) (psqlContentDigest, error) {
+func expandPsqlInputs(inputs []psqlInput, workDir string, limits psqlScriptLimits) (string, error) {
+	locker := &contentLock{files: map[string]*os.File{}}
+	defer locker.Close()
 	builder := &strings.Builder{}
+	if _, err := writePsqlInputsWithLock(builder, inputs, workDir, locker, limits); err != nil {
+		return "", err
+	}
+	return builder.String(), nil
+}
+
+// computePsqlContentDigestWithLock hashes the expanded inputs as they are
+// read, so large scripts are never held in memory. With normalize the text is
+// canonicalized first (see psqlNormalizer).
+func computePsqlContentDigestWithLock(inputs []psqlInput, workDir string, locker *contentLock, limits psqlScriptLimits, normalize bool) (psqlContentDigest, error) {
+	hasher := sha256.New()
+	out := bufio.NewWriter(hasher)
+	var content psqlContentWriter = out
+	var normalizer *psqlNormalizer
+	if normalize {
+		normalizer = newPsqlNormalizer(out)
+		content = normalizer
+	}
+	tracker, err := writePsqlInputsWithLock(content, inputs, workDir, locker, limits)
+	if err != nil {
+		return psqlContentDigest{}, err
+	}
+	if normalizer != nil {
+		normalizer.flush()
+	}
+	if err := out.Flush(); err != nil {
+		return psqlContentDigest{}, err
+	}
+	return psqlContentDigest{
+		hash:      hex.EncodeToString(hasher.Sum(nil)),
+		filePaths: tracker.lockedFiles(),
+	}, nil
+}
+
+// psqlContentWriter receives expanded script content.
+type psqlContentWriter interface {
+	io.StringWriter
+	io.ByteWriter
+}
+
+// writePsqlInputsWithLock writes the inputs to out with \i includes inlined,
+// locking every file it reads.
+func writePsqlInputsWithLock(out psqlContentWriter, inputs []psqlInput, workDir string, locker *contentLock, limits psqlScriptLimits) (*psqlContentTracker, error) {
 	tracker := &psqlContentTracker{
 		workDir: workDir,
 		locker:  locker,
+		limits:  limits,
 		seen:    map[string]struct{}{},
 		stack:   map[string]struct{}{},
 	}
 	for idx, input := range inputs {
 		if idx > 0 {
-			builder.WriteString("\n-- sqlrs: input-boundary\n")
+			writePsqlRaw(out, "\n-- sqlrs: input-boundary\n")
 		}
 		switch input.kind {
 		case "command", "stdin":
-			if err := tracker.expandContent(input.value, "", builder); err != nil {
-				return psqlContentDigest{}, err
+			if err := tracker.expandContent(input.value, "", out); err != nil {
+				return nil, err
 			}
 		case "file":
-			if err := tracker.expandFile(input.value, builder); err != nil {
-				return psqlContentDigest{}, err
+			if err := tracker.expandFile(input.value, out); err != nil {
+				return nil, err
+			}
+		case psqlInputCopyData:
+			if _, err := writePsqlRaw(out, input.value); err != nil {
+				return nil, err
 			}
 		default:
-			return psqlContentDigest{}, fmt.Errorf("unsupported input kind: %s", input.kind)
+			return nil, fmt.Errorf("unsupported input kind: %s", input.kind)
 		}
 	}
-	sum := sha256.Sum256([]byte(builder.String()))
-	return psqlContentDigest{
-		hash:      hex.EncodeToString(sum[:]),
-		filePaths: tracker.lockedFiles(),
-	}, nil
+	return tracker, nil
 }
 
 type psqlContentTracker struct {
 	workDir string
 	locker  *contentLock
+	limits  psqlScriptLimits
 	seen    map[string]struct{}
 	stack   map[string]struct{}
 }
@@ -73,7 +121,7 @@ func (t *psqlContentTracker) lockedFiles() []string {
 	return paths
 }
 
-func (t *psqlContentTracker) expandFile(path string, builder *strings.Builder) error {
+func (t *psqlContentTracker) expandFile(path string, out psqlContentWriter) error {
 	if strings.TrimSpace(path) == "" {
 		return fmt.Errorf("empty include path")
 	}
@@ -87,15 +135,20 @@ func (t *psqlContentTracker) expandFile(path string, builder *strings.Builder) e
 	t.stack[path] = struct{}{}
 	defer delete(t.stack, path)
 
-	data, err := t.locker.readFile(path)
+	reader, err := t.locker.openFile(path)
 	if err != nil {
 		return err
 	}
-	return t.expandContent(string(data), path, builder)
+	defer reader.Close()
+	return t.expandReader(reader, path, out)
+}
+
+func (t *psqlContentTracker) expandContent(content string, currentFile string, out psqlContentWriter) error {
+	return t.expandReader(strings.NewReader(content), currentFile, out)
 }
 
-func (t *psqlContentTracker) expandContent(content string, currentFile string, builder *strings.Builder) error {
-	scanner := bufio.NewScanner(strings.NewReader(content))
+func (t *psqlContentTracker) expandReader(reader io.Reader, currentFile string, out psqlContentWriter) error {
+	scanner := bufio.NewScanner(reader)
 	for scanner.Scan() {
 		line := scanner.Text()
 		if cmd, arg, ok := parsePsqlInclude(line); ok {
@@ -103,15 +156,15 @@ func (t *psqlContentTracker) expandContent(content string, currentFile string, b
 			if err != nil {
 				return err
 			}
-			builder.WriteString("\n-- sqlrs: include-boundary\n")
-			if err := t.expandFile(includePath, builder); err != nil {
+			writePsqlRaw(out, "\n-- sqlrs: include-boundary\n")
+			if err := t.expandFile(includePath, out); err != nil {
 				return err
 			}
-			builder.WriteString("\n-- sqlrs: include-boundary\n")
+			writePsqlRaw(out, "\n-- sqlrs: include-boundary\n")
 			continue
 		}
-		builder.WriteString(line)
-		builder.WriteByte('\n')
+		out.WriteString(line)
+		out.WriteByte('\n')
 	}
 	return scanner.Err()
 }
@@ -141,7 +194,11 @@ func (t *psqlContentTracker) lockFile(path string) error {
 	if _, ok := t.seen[path]; ok {
 		return nil
 	}
-	if _, err := os.Stat(path); err != nil {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if err := t.limits.checkFile(path, info.Size(), len(t.seen)+1); err != nil {
 		return err
 	}
 	f, err := lockContentFile(path)
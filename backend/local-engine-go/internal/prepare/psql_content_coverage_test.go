@@ -14,7 +14,7 @@ func TestComputePsqlContentDigestBranches(t *testing.T) {
 		{kind: "command", value: "select 1;"},
 		{kind: "stdin", value: "select 2;"},
 		{kind: "file", value: filepath.Join(dir, "a.sql")},
-	}, dir)
+	}, dir, psqlScriptLimits{}, false)
 	if err != nil {
 		t.Fatalf("computePsqlContentDigest: %v", err)
 	}
@@ -25,13 +25,13 @@ func TestComputePsqlContentDigestBranches(t *testing.T) {
 		t.Fatalf("expected file paths")
 	}
 
-	if _, err := computePsqlContentDigest([]psqlInput{{kind: "unknown", value: "x"}}, dir); err == nil {
+	if _, err := computePsqlContentDigest([]psqlInput{{kind: "unknown", value: "x"}}, dir, psqlScriptLimits{}, false); err == nil {
 		t.Fatalf("expected error for unsupported input kind")
 	}
 }
 
 func TestComputePsqlContentDigestCommandError(t *testing.T) {
-	if _, err := computePsqlContentDigest([]psqlInput{{kind: "command", value: `\i missing.sql`}}, ""); err == nil {
+	if _, err := computePsqlContentDigest([]psqlInput{{kind: "command", value: `\i missing.sql`}}, "", psqlScriptLimits{}, false); err == nil {
 		t.Fatalf("expected error for invalid include")
 	}
 }
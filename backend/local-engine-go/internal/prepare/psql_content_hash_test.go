@@ -16,11 +16,11 @@ func TestPsqlContentDigestIgnoresIncludeForm(t *testing.T) {
 	writeFile(t, filepath.Join(dirB, "d.sql"), "select 1;")
 	writeFile(t, filepath.Join(dirB, "c.sql"), "\\include_relative d.sql\n")
 
-	digestA, err := computePsqlContentDigest([]psqlInput{{kind: "file", value: filepath.Join(dirA, "a.sql")}}, dirA)
+	digestA, err := computePsqlContentDigest([]psqlInput{{kind: "file", value: filepath.Join(dirA, "a.sql")}}, dirA, psqlScriptLimits{}, false)
 	if err != nil {
 		t.Fatalf("digest a: %v", err)
 	}
-	digestB, err := computePsqlContentDigest([]psqlInput{{kind: "file", value: filepath.Join(dirB, "c.sql")}}, dirB)
+	digestB, err := computePsqlContentDigest([]psqlInput{{kind: "file", value: filepath.Join(dirB, "c.sql")}}, dirB, psqlScriptLimits{}, false)
 	if err != nil {
 		t.Fatalf("digest b: %v", err)
 	}
@@ -35,7 +35,7 @@ func TestPsqlContentDigestNestedIncludes(t *testing.T) {
 	writeFile(t, filepath.Join(dir, "b.sql"), "\\i c.sql\nselect 2;")
 	writeFile(t, filepath.Join(dir, "a.sql"), "select 1;\n\\i b.sql")
 
-	digest, err := computePsqlContentDigest([]psqlInput{{kind: "file", value: filepath.Join(dir, "a.sql")}}, dir)
+	digest, err := computePsqlContentDigest([]psqlInput{{kind: "file", value: filepath.Join(dir, "a.sql")}}, dir, psqlScriptLimits{}, false)
 	if err != nil {
 		t.Fatalf("digest: %v", err)
 	}
@@ -47,7 +47,7 @@ func TestPsqlContentDigestNestedIncludes(t *testing.T) {
 func TestPsqlContentDigestMissingInclude(t *testing.T) {
 	dir := t.TempDir()
 	writeFile(t, filepath.Join(dir, "a.sql"), "\\i missing.sql\n")
-	_, err := computePsqlContentDigest([]psqlInput{{kind: "file", value: filepath.Join(dir, "a.sql")}}, dir)
+	_, err := computePsqlContentDigest([]psqlInput{{kind: "file", value: filepath.Join(dir, "a.sql")}}, dir, psqlScriptLimits{}, false)
 	if err == nil {
 		t.Fatalf("expected error")
 	}
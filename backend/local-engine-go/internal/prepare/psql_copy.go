@@ -0,0 +1,48 @@
+package prepare
+
+import "regexp"
+
+// psqlInputCopyData is the request stdin when it carries the rows of a
+// COPY ... FROM STDIN command given with -c. It is hashed as raw data and
+// never expanded as script text.
+const psqlInputCopyData = "copy_data"
+
+// psqlCopyFromStdinPattern matches a COPY ... FROM STDIN statement (or the
+// \copy ... FROM pstdin meta-command) anywhere in a -c command string.
+var psqlCopyFromStdinPattern = regexp.MustCompile(`(?is)(?:^|;)\s*(?:copy\b[^;]*\bfrom\s+stdin|\\copy\b[^;]*\bfrom\s+pstdin)\b`)
+
+func isPsqlCopyFromStdin(command string) bool {
+	return psqlCopyFromStdinPattern.MatchString(command)
+}
+
+func countPsqlCopyFromStdin(inputs []psqlInput) int {
+	count := 0
+	for _, input := range inputs {
+		if input.kind == "command" && isPsqlCopyFromStdin(input.value) {
+			count++
+		}
+	}
+	return count
+}
+
+// withPsqlCopyData places the stdin rows right after the command that reads
+// them, so the request hash changes whenever the payload does.
+func withPsqlCopyData(inputs []psqlInput, data string) []psqlInput {
+	out := make([]psqlInput, 0, len(inputs)+1)
+	for _, input := range inputs {
+		out = append(out, input)
+		if input.kind == "command" && isPsqlCopyFromStdin(input.value) {
+			out = append(out, psqlInput{kind: psqlInputCopyData, value: data})
+		}
+	}
+	return out
+}
+
+func hasPsqlCopyData(inputs []psqlInput) bool {
+	for _, input := range inputs {
+		if input.kind == psqlInputCopyData {
+			return true
+		}
+	}
+	return false
+}
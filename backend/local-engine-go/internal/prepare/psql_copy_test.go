@@ -0,0 +1,120 @@
+package prepare
+
+import (
+	"context"
+	"testing"
+)
+
+func TestIsPsqlCopyFromStdin(t *testing.T) {
+	cases := map[string]bool{
+		"COPY items FROM STDIN":                              true,
+		"copy items (id, name) from stdin with (format csv)": true,
+		"create table items(id int); COPY items FROM stdin":  true,
+		`\copy items from pstdin csv`:                        true,
+		"COPY items FROM '/tmp/items.csv'":                   false,
+		"COPY items TO STDOUT":                               false,
+		"select 'copy x from stdin'":                         false,
+		`\copy items from stdin`:                             false,
+	}
+	for command, want := range cases {
+		if got := isPsqlCopyFromStdin(command); got != want {
+			t.Fatalf("isPsqlCopyFromStdin(%q) = %v, want %v", command, got, want)
+		}
+	}
+}
+
+func TestPreparePsqlArgsCopyFromStdin(t *testing.T) {
+	rows := "1,alpha\n2,beta\n"
+	out, err := preparePsqlArgs([]string{"-c", "create table items(id int, name text)", "-c", "COPY items FROM STDIN (FORMAT csv)"}, &rows, psqlScriptLimits{})
+	if err != nil {
+		t.Fatalf("preparePsqlArgs: %v", err)
+	}
+	if len(out.inputs) != 3 || out.inputs[1].kind != "command" || out.inputs[2].kind != psqlInputCopyData || out.inputs[2].value != rows {
+		t.Fatalf("expected copy data after the COPY command, got %+v", out.inputs)
+	}
+	if len(out.steps) != 2 || out.steps[0].stdin != nil {
+		t.Fatalf("expected only the COPY step to read stdin, got %+v", out.steps)
+	}
+	if step := out.steps[1]; step.stdin == nil || *step.stdin != rows || !hasPsqlCopyData(step.inputs) {
+		t.Fatalf("expected COPY step to carry stdin rows, got %+v", step)
+	}
+	if script := nativePsqlScript(out.steps[1], "", psqlScriptLimits{}); script != nil {
+		t.Fatalf("expected COPY step to need psql, got native script %q", *script)
+	}
+}
+
+func TestPreparePsqlArgsCopyFromStdinRejections(t *testing.T) {
+	rows := "1\n"
+	_, err := preparePsqlArgs([]string{"-c", "COPY a FROM STDIN", "-f", "-"}, &rows, psqlScriptLimits{})
+	expectValidationError(t, err, "stdin cannot feed both -f - and COPY ... FROM STDIN")
+
+	_, err = preparePsqlArgs([]string{"-c", "COPY a FROM STDIN", "-c", "COPY b FROM STDIN"}, &rows, psqlScriptLimits{})
+	expectValidationError(t, err, "only one COPY ... FROM STDIN command")
+
+	_, err = preparePsqlArgs([]string{"-c", "select 1"}, &rows, psqlScriptLimits{})
+	expectValidationError(t, err, "stdin is only valid with -f - or COPY ... FROM STDIN")
+}
+
+func TestPreparePsqlArgsRejectsStdinOverMaxStdinBytes(t *testing.T) {
+	rows := "1,alpha\n2,beta\n"
+	_, err := preparePsqlArgs([]string{"-c", "COPY items FROM STDIN (FORMAT csv)"}, &rows, psqlScriptLimits{maxStdinBytes: 4})
+	expectPsqlLimitError(t, err, "psql stdin is too large", "prepare.psql.maxStdinBytes")
+}
+
+func TestBuildPlanCopyFromStdinStateIDFollowsPayload(t *testing.T) {
+	mgr := newManager(t, &fakeStore{})
+	stateID := func(rows string) string {
+		prepared, err := mgr.prepareRequest(Request{
+			PrepareKind: "psql",
+			ImageID:     "image-1@sha256:resolved",
+			PsqlArgs:    []string{"-c", "COPY items FROM STDIN (FORMAT csv)"},
+			Stdin:       &rows,
+		})
+		if err != nil {
+			t.Fatalf("prepareRequest: %v", err)
+		}
+		tasks, _, errResp := mgr.buildPlan(context.Background(), "job-1", prepared)
+		if errResp != nil {
+			t.Fatalf("buildPlan: %+v", errResp)
+		}
+		for _, task := range tasks {
+			if task.Type == "state_execute" {
+				return task.OutputStateID
+			}
+		}
+		t.Fatalf("expected state_execute task, got %+v", tasks)
+		return ""
+	}
+
+	first := stateID("1,alpha\n2,beta\n")
+	if first == "" || stateID("1,alpha\n2,beta\n") != first {
+		t.Fatalf("expected deterministic state id for identical rows")
+	}
+	if stateID("1,alpha\n2,gamma\n") == first {
+		t.Fatalf("expected a different state id when the rows change")
+	}
+}
+
+func TestSubmitCopyFromStdinPassesRowsToPsql(t *testing.T) {
+	psql := &fakePsqlRunner{}
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{psql: psql})
+	rows := "1,alpha\n"
+	if _, err := mgr.Submit(context.Background(), Request{
+		PrepareKind: "psql",
+		ImageID:     "image-1@sha256:resolved",
+		PsqlArgs:    []string{"-c", "COPY items FROM STDIN (FORMAT csv)"},
+		Stdin:       &rows,
+	}); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	if len(psql.runs) != 1 {
+		t.Fatalf("expected one psql run, got %+v", psql.runs)
+	}
+	run := psql.runs[0]
+	if run.Stdin == nil || *run.Stdin != rows {
+		t.Fatalf("expected stdin rows, got %v", run.Stdin)
+	}
+	if !containsArg(run.Args, "-c") || !containsArg(run.Args, "COPY items FROM STDIN (FORMAT csv)") {
+		t.Fatalf("expected COPY command args, got %+v", run.Args)
+	}
+}
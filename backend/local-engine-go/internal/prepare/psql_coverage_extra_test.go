@@ -12,13 +12,13 @@ func TestIsConnectionFlagShortWithValue(t *testing.T) {
 }
 
 func TestPreparePsqlArgsVarFlagError(t *testing.T) {
-	if _, err := preparePsqlArgs([]string{"-vON_ERROR_STOP=0"}, nil); err == nil {
+	if _, err := preparePsqlArgs([]string{"-vON_ERROR_STOP=0"}, nil, psqlScriptLimits{}); err == nil {
 		t.Fatalf("expected error for ON_ERROR_STOP=0")
 	}
 }
 
 func TestPreparePsqlArgsFileFlagLongEqError(t *testing.T) {
-	if _, err := preparePsqlArgs([]string{"--file=rel.sql"}, nil); err == nil {
+	if _, err := preparePsqlArgs([]string{"--file=rel.sql"}, nil, psqlScriptLimits{}); err == nil {
 		t.Fatalf("expected error for relative file path")
 	}
 }
@@ -0,0 +1,77 @@
+package prepare
+
+import "fmt"
+
+const (
+	defaultPsqlMaxScriptBytes int64 = 256 << 20
+	defaultPsqlMaxStdinBytes  int64 = 64 << 20
+	defaultPsqlMaxFiles             = 1000
+)
+
+// psqlScriptLimits bounds the script files a psql job may read: the size of
+// each file and the number of distinct files, includes counted. Zero fields
+// are unlimited.
+type psqlScriptLimits struct {
+	maxScriptBytes int64
+	maxFiles       int
+	// maxStdinBytes bounds the request stdin, which the engine holds in
+	// memory for the whole job.
+	maxStdinBytes int64
+}
+
+func (l psqlScriptLimits) checkStdin(size int64) error {
+	if l.maxStdinBytes > 0 && size > l.maxStdinBytes {
+		return ValidationError{
+			Code:    ErrorCodeInvalidArgument,
+			Message: "psql stdin is too large",
+			Details: fmt.Sprintf("stdin is %d bytes, prepare.psql.maxStdinBytes is %d", size, l.maxStdinBytes),
+		}
+	}
+	return nil
+}
+
+// checkFile validates the count-th distinct file of a script before it is
+// read.
+func (l psqlScriptLimits) checkFile(path string, size int64, count int) error {
+	if l.maxFiles > 0 && count > l.maxFiles {
+		return ValidationError{
+			Code:    ErrorCodeInvalidArgument,
+			Message: "psql script reads too many files",
+			Details: fmt.Sprintf("%s is file %d, prepare.psql.maxFiles is %d", path, count, l.maxFiles),
+		}
+	}
+	if l.maxScriptBytes > 0 && size > l.maxScriptBytes {
+		return ValidationError{
+			Code:    ErrorCodeInvalidArgument,
+			Message: "psql script file is too large",
+			Details: fmt.Sprintf("%s is %d bytes, prepare.psql.maxScriptBytes is %d", path, size, l.maxScriptBytes),
+		}
+	}
+	return nil
+}
+
+// psqlScriptLimits reads prepare.psql.maxScriptBytes, prepare.psql.maxFiles
+// and prepare.psql.maxStdinBytes.
+// Missing or invalid values fall back to the defaults; 0 disables a limit.
+func (m *PrepareService) psqlScriptLimits() psqlScriptLimits {
+	limits := psqlScriptLimits{maxScriptBytes: defaultPsqlMaxScriptBytes, maxFiles: defaultPsqlMaxFiles, maxStdinBytes: defaultPsqlMaxStdinBytes}
+	if m.config == nil {
+		return limits
+	}
+	if value, err := m.config.Get("prepare.psql.maxScriptBytes", true); err == nil && value != nil {
+		if parsed, ok := asInt64(value); ok && parsed >= 0 {
+			limits.maxScriptBytes = parsed
+		}
+	}
+	if value, err := m.config.Get("prepare.psql.maxFiles", true); err == nil && value != nil {
+		if parsed, ok := asInt64(value); ok && parsed >= 0 {
+			limits.maxFiles = int(parsed)
+		}
+	}
+	if value, err := m.config.Get("prepare.psql.maxStdinBytes", true); err == nil && value != nil {
+		if parsed, ok := asInt64(value); ok && parsed >= 0 {
+			limits.maxStdinBytes = parsed
+		}
+	}
+	return limits
+}
@@ -0,0 +1,151 @@
+package prepare
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPsqlScriptLimitsResolution(t *testing.T) {
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{})
+	if got := mgr.psqlScriptLimits(); got.maxScriptBytes != defaultPsqlMaxScriptBytes || got.maxFiles != defaultPsqlMaxFiles || got.maxStdinBytes != defaultPsqlMaxStdinBytes {
+		t.Fatalf("expected defaults, got %+v", got)
+	}
+
+	mgr = newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{
+		config: &fakeConfigStore{values: map[string]any{
+			"prepare.psql.maxScriptBytes": 1024,
+			"prepare.psql.maxFiles":       0,
+			"prepare.psql.maxStdinBytes":  2048,
+		}},
+	})
+	if got := mgr.psqlScriptLimits(); got.maxScriptBytes != 1024 || got.maxFiles != 0 || got.maxStdinBytes != 2048 {
+		t.Fatalf("expected configured limits, got %+v", got)
+	}
+
+	mgr = newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{
+		config: &fakeConfigStore{values: map[string]any{
+			"prepare.psql.maxScriptBytes": "big",
+			"prepare.psql.maxFiles":       -1,
+		}},
+	})
+	if got := mgr.psqlScriptLimits(); got.maxScriptBytes != defaultPsqlMaxScriptBytes || got.maxFiles != defaultPsqlMaxFiles {
+		t.Fatalf("expected invalid config to fall back to defaults, got %+v", got)
+	}
+}
+
+func TestPreparePsqlArgsRejectsLargeFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dump.sql")
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := os.Truncate(path, 4096); err != nil {
+		t.Fatalf("truncate: %v", err)
+	}
+
+	_, err := preparePsqlArgs([]string{"-f", path}, nil, psqlScriptLimits{maxScriptBytes: 1024})
+	expectPsqlLimitError(t, err, "psql script file is too large", path)
+
+	if _, err := preparePsqlArgs([]string{"-f", path}, nil, psqlScriptLimits{}); err != nil {
+		t.Fatalf("expected no limit to accept the file, got %v", err)
+	}
+}
+
+func TestPreparePsqlArgsRejectsLargeStdin(t *testing.T) {
+	stdin := strings.Repeat("select 1;\n", 20)
+	_, err := preparePsqlArgs([]string{"-f", "-"}, &stdin, psqlScriptLimits{maxScriptBytes: 100})
+	expectPsqlLimitError(t, err, "psql script file is too large", "stdin")
+}
+
+func TestPreparePsqlArgsRejectsTooManyFiles(t *testing.T) {
+	dir := t.TempDir()
+	var args []string
+	for i := 0; i < 3; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("step%d.sql", i))
+		if err := os.WriteFile(path, []byte("select 1;\n"), 0o600); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		args = append(args, "-f", path, "-f", path)
+	}
+
+	if _, err := preparePsqlArgs(args, nil, psqlScriptLimits{maxFiles: 3}); err != nil {
+		t.Fatalf("expected repeated files to count once, got %v", err)
+	}
+	_, err := preparePsqlArgs(args, nil, psqlScriptLimits{maxFiles: 2})
+	expectPsqlLimitError(t, err, "psql script reads too many files", filepath.Join(dir, "step2.sql"))
+}
+
+func TestComputePsqlContentDigestLimitsIncludes(t *testing.T) {
+	dir := t.TempDir()
+	var root strings.Builder
+	for i := 0; i < 3; i++ {
+		name := fmt.Sprintf("part%d.sql", i)
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("select 1;\n"), 0o600); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		root.WriteString("\\ir " + name + "\n")
+	}
+	rootPath := filepath.Join(dir, "root.sql")
+	if err := os.WriteFile(rootPath, []byte(root.String()), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	inputs := []psqlInput{{kind: "file", value: rootPath}}
+
+	if _, err := computePsqlContentDigest(inputs, dir, psqlScriptLimits{maxFiles: 4}, false); err != nil {
+		t.Fatalf("expected includes within the limit, got %v", err)
+	}
+	_, err := computePsqlContentDigest(inputs, dir, psqlScriptLimits{maxFiles: 3}, false)
+	expectPsqlLimitError(t, err, "psql script reads too many files", filepath.Join(dir, "part2.sql"))
+
+	large := filepath.Join(dir, "part1.sql")
+	if err := os.Truncate(large, 2048); err != nil {
+		t.Fatalf("truncate: %v", err)
+	}
+	_, err = computePsqlContentDigest(inputs, dir, psqlScriptLimits{maxScriptBytes: 1024}, false)
+	expectPsqlLimitError(t, err, "psql script file is too large", large)
+}
+
+func TestSubmitRejectsLargePsqlScriptBeforeStart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dump.sql")
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := os.Truncate(path, 4096); err != nil {
+		t.Fatalf("truncate: %v", err)
+	}
+	runtime := &fakeRuntime{}
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{
+		runtime: runtime,
+		config:  &fakeConfigStore{values: map[string]any{"prepare.psql.maxScriptBytes": 1024}},
+	})
+
+	_, err := mgr.Submit(context.Background(), Request{
+		PrepareKind: "psql",
+		ImageID:     "image-1",
+		PsqlArgs:    []string{"-f", path},
+	})
+	expectPsqlLimitError(t, err, "psql script file is too large", path)
+	time.Sleep(10 * time.Millisecond)
+	if len(runtime.startCalls) != 0 {
+		t.Fatalf("expected no runtime start, got %+v", runtime.startCalls)
+	}
+}
+
+func expectPsqlLimitError(t *testing.T, err error, message string, path string) {
+	t.Helper()
+	var validation ValidationError
+	if !errors.As(err, &validation) {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+	if validation.Code != "invalid_argument" || validation.Message != message {
+		t.Fatalf("unexpected validation error: %+v", validation)
+	}
+	if !strings.Contains(validation.Details, path) {
+		t.Fatalf("expected details to name %q, got %q", path, validation.Details)
+	}
+}
@@ -0,0 +1,151 @@
+package prepare
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sqlrs/engine-local/internal/runtime"
+)
+
+type psqlMountsPrepared struct {
+	mounts []runtime.Mount
+	hash   string
+}
+
+// preparePsqlMounts validates the extra host directories requested for psql
+// execution and hashes a manifest of their contents, so that editing a fixture
+// changes the task hash of every step that can see it.
+func preparePsqlMounts(specs []MountSpec) (psqlMountsPrepared, error) {
+	if len(specs) == 0 {
+		return psqlMountsPrepared{}, nil
+	}
+	mounts := make([]runtime.Mount, 0, len(specs))
+	for _, spec := range specs {
+		source := strings.TrimSpace(spec.Source)
+		if source == "" {
+			return psqlMountsPrepared{}, ValidationError{Code: ErrorCodeInvalidArgument, Message: "mount source is required"}
+		}
+		if !filepath.IsAbs(source) {
+			return psqlMountsPrepared{}, ValidationError{Code: ErrorCodeInvalidArgument, Message: "mount source must be absolute", Details: source}
+		}
+		source = filepath.Clean(source)
+		if _, err := os.Stat(source); err != nil {
+			return psqlMountsPrepared{}, ValidationError{Code: ErrorCodeInvalidArgument, Message: "mount source does not exist", Details: source}
+		}
+		target := strings.TrimSpace(spec.Target)
+		if target == "" {
+			return psqlMountsPrepared{}, ValidationError{Code: ErrorCodeInvalidArgument, Message: "mount target is required", Details: source}
+		}
+		if !strings.HasPrefix(target, "/") {
+			return psqlMountsPrepared{}, ValidationError{Code: ErrorCodeInvalidArgument, Message: "mount target must be an absolute container path", Details: target}
+		}
+		target = path.Clean(target)
+		for _, reserved := range []string{runtime.PostgresDataDirRoot, containerScriptsRoot} {
+			if containerPathsOverlap(target, reserved) {
+				return psqlMountsPrepared{}, ValidationError{Code: ErrorCodeInvalidArgument, Message: "mount target collides with a reserved container path", Details: target}
+			}
+		}
+		for _, existing := range mounts {
+			if containerPathsOverlap(target, existing.ContainerPath) {
+				return psqlMountsPrepared{}, ValidationError{Code: ErrorCodeInvalidArgument, Message: "mount targets overlap", Details: target}
+			}
+		}
+		mounts = append(mounts, runtime.Mount{
+			HostPath:      source,
+			ContainerPath: target,
+			ReadOnly:      spec.ReadOnly,
+		})
+	}
+	hash, err := psqlMountsHash(mounts)
+	if err != nil {
+		return psqlMountsPrepared{}, ValidationError{Code: ErrorCodeInvalidArgument, Message: "cannot hash mount contents", Details: err.Error()}
+	}
+	return psqlMountsPrepared{mounts: mounts, hash: hash}, nil
+}
+
+// containerPathsOverlap reports whether one cleaned container path equals or
+// contains the other.
+func containerPathsOverlap(a, b string) bool {
+	if a == b || a == "/" || b == "/" {
+		return true
+	}
+	return strings.HasPrefix(a, b+"/") || strings.HasPrefix(b, a+"/")
+}
+
+// psqlMountsHash hashes each mount's target, mode and every regular file under
+// its source, keyed by path relative to the source.
+func psqlMountsHash(mounts []runtime.Mount) (string, error) {
+	sorted := append([]runtime.Mount{}, mounts...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ContainerPath < sorted[j].ContainerPath })
+	hasher := newStateHasher()
+	for _, mount := range sorted {
+		hasher.write("mount_target", mount.ContainerPath)
+		if mount.ReadOnly {
+			hasher.write("mount_read_only", "true")
+		}
+		err := filepath.WalkDir(mount.HostPath, func(current string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !entry.Type().IsRegular() {
+				return nil
+			}
+			rel, err := filepath.Rel(mount.HostPath, current)
+			if err != nil {
+				return err
+			}
+			digest, err := fileDigest(current)
+			if err != nil {
+				return err
+			}
+			hasher.write("mount_file", filepath.ToSlash(rel))
+			hasher.write("mount_file_hash", digest)
+			return nil
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+	return hasher.sum(), nil
+}
+
+func fileDigest(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// psqlPreparedTaskHash is psqlTaskHash with the extra mounts manifest and the
+// single-transaction mode folded in. Requests using neither keep their
+// existing task hashes.
+func psqlPreparedTaskHash(prepared preparedRequest, contentHash string, engineVersion string) string {
+	if prepared.psqlMountsHash != "" || prepared.psqlSingleTx || prepared.psqlNormalizeHash {
+		hasher := newStateHasher()
+		hasher.write("content_hash", contentHash)
+		if prepared.psqlNormalizeHash {
+			hasher.write("content_hash_mode", "normalized")
+		}
+		if prepared.psqlMountsHash != "" {
+			hasher.write("mounts_hash", prepared.psqlMountsHash)
+		}
+		if prepared.psqlSingleTx {
+			hasher.write("single_transaction", "true")
+		}
+		contentHash = hasher.sum()
+	}
+	return psqlTaskHash(prepared.request.PrepareKind, contentHash, engineVersion)
+}
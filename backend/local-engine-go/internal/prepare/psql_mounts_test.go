@@ -0,0 +1,147 @@
+package prepare
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPreparePsqlMountsValidation(t *testing.T) {
+	dir := t.TempDir()
+	cases := []struct {
+		name  string
+		specs []MountSpec
+	}{
+		{name: "empty source", specs: []MountSpec{{Target: "/fixtures"}}},
+		{name: "relative source", specs: []MountSpec{{Source: "fixtures", Target: "/fixtures"}}},
+		{name: "missing source", specs: []MountSpec{{Source: filepath.Join(dir, "missing"), Target: "/fixtures"}}},
+		{name: "empty target", specs: []MountSpec{{Source: dir}}},
+		{name: "relative target", specs: []MountSpec{{Source: dir, Target: "fixtures"}}},
+		{name: "data dir", specs: []MountSpec{{Source: dir, Target: "/var/lib/postgresql/data"}}},
+		{name: "inside data dir", specs: []MountSpec{{Source: dir, Target: "/var/lib/postgresql/data/pgdata/x"}}},
+		{name: "parent of data dir", specs: []MountSpec{{Source: dir, Target: "/var/lib"}}},
+		{name: "scripts root", specs: []MountSpec{{Source: dir, Target: "/sqlrs/scripts/"}}},
+		{name: "overlapping targets", specs: []MountSpec{{Source: dir, Target: "/fixtures"}, {Source: dir, Target: "/fixtures/csv"}}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := preparePsqlMounts(tc.specs)
+			var validation ValidationError
+			if !errors.As(err, &validation) {
+				t.Fatalf("expected validation error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestPreparePsqlMountsBuildsRuntimeMounts(t *testing.T) {
+	dir := t.TempDir()
+	prepared, err := preparePsqlMounts([]MountSpec{{Source: dir + "/", Target: "/fixtures/", ReadOnly: true}})
+	if err != nil {
+		t.Fatalf("preparePsqlMounts: %v", err)
+	}
+	if len(prepared.mounts) != 1 || prepared.mounts[0].HostPath != filepath.Clean(dir) || prepared.mounts[0].ContainerPath != "/fixtures" || !prepared.mounts[0].ReadOnly {
+		t.Fatalf("unexpected mounts: %+v", prepared.mounts)
+	}
+	if prepared.hash == "" {
+		t.Fatalf("expected mounts hash")
+	}
+}
+
+func TestPrepareRequestRejectsMountsForNonPsql(t *testing.T) {
+	mgr := newManager(t, &fakeStore{})
+	_, err := mgr.prepareRequest(Request{
+		PrepareKind:   "lb",
+		ImageID:       "image-1",
+		LiquibaseArgs: []string{"update"},
+		Mounts:        []MountSpec{{Source: t.TempDir(), Target: "/fixtures"}},
+	})
+	var validation ValidationError
+	if !errors.As(err, &validation) {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+}
+
+func TestComputeTaskHashIncludesMountContents(t *testing.T) {
+	dir := t.TempDir()
+	fixture := filepath.Join(dir, "rows.csv")
+	writeTempFile(t, fixture, "1,a\n")
+
+	mgr := newManager(t, &fakeStore{})
+	hash := func(mounts []MountSpec) string {
+		prepared, err := mgr.prepareRequest(Request{
+			PrepareKind: "psql",
+			ImageID:     "image-1",
+			PsqlArgs:    []string{"-c", "select 1"},
+			Mounts:      mounts,
+		})
+		if err != nil {
+			t.Fatalf("prepareRequest: %v", err)
+		}
+		taskHash, errResp := mgr.computeTaskHash(prepared)
+		if errResp != nil {
+			t.Fatalf("computeTaskHash: %+v", errResp)
+		}
+		return taskHash
+	}
+	mounts := []MountSpec{{Source: dir, Target: "/fixtures", ReadOnly: true}}
+
+	without := hash(nil)
+	if without != psqlTaskHash("psql", mustPsqlContentHash(t, mgr), mgr.version) {
+		t.Fatalf("expected hash without mounts to be unchanged")
+	}
+	before := hash(mounts)
+	if before == without {
+		t.Fatalf("expected mounts to change task hash")
+	}
+	if again := hash(mounts); again != before {
+		t.Fatalf("expected stable hash, got %s vs %s", again, before)
+	}
+	if err := os.WriteFile(fixture, []byte("1,b\n"), 0o600); err != nil {
+		t.Fatalf("rewrite fixture: %v", err)
+	}
+	if after := hash(mounts); after == before {
+		t.Fatalf("expected fixture change to change task hash")
+	}
+}
+
+func mustPsqlContentHash(t *testing.T, mgr *PrepareService) string {
+	t.Helper()
+	prepared, err := mgr.prepareRequest(Request{PrepareKind: "psql", ImageID: "image-1", PsqlArgs: []string{"-c", "select 1"}})
+	if err != nil {
+		t.Fatalf("prepareRequest: %v", err)
+	}
+	digest, err := computePsqlContentDigest(prepared.psqlInputs, prepared.psqlWorkDir, prepared.psqlLimits, false)
+	if err != nil {
+		t.Fatalf("computePsqlContentDigest: %v", err)
+	}
+	return digest.hash
+}
+
+func TestSubmitPassesPsqlMountsToRuntime(t *testing.T) {
+	dir := t.TempDir()
+	runtime := &fakeRuntime{}
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{runtime: runtime})
+	if _, err := mgr.Submit(context.Background(), Request{
+		PrepareKind: "psql",
+		ImageID:     "image-1@sha256:resolved",
+		PsqlArgs:    []string{"-c", "select 1"},
+		Mounts:      []MountSpec{{Source: dir, Target: "/fixtures", ReadOnly: true}},
+	}); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	if len(runtime.startCalls) == 0 {
+		t.Fatalf("expected runtime start")
+	}
+	found := false
+	for _, mount := range runtime.startCalls[0].Mounts {
+		if mount.HostPath == dir && mount.ContainerPath == "/fixtures" && mount.ReadOnly {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected fixture mount, got %+v", runtime.startCalls[0].Mounts)
+	}
+}
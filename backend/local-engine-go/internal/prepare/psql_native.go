@@ -0,0 +1,214 @@
+package prepare
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+
+	engineRuntime "github.com/sqlrs/engine-local/internal/runtime"
+)
+
+const (
+	psqlRunnerContainer = "container"
+	psqlRunnerNative    = "native"
+)
+
+// pgxPsqlRunner runs psql steps over a direct connection to the instance
+// instead of exec-ing psql in the container. Steps that need psql itself
+// (no Script, backslash meta-commands, COPY FROM STDIN) go to fallback.
+type pgxPsqlRunner struct {
+	fallback psqlRunner
+	connect  func(ctx context.Context, config *pgconn.Config) (*pgconn.PgConn, error)
+}
+
+func (r pgxPsqlRunner) Run(ctx context.Context, instance engineRuntime.Instance, req PsqlRunRequest) (string, error) {
+	var statements []string
+	ok := req.Script != nil
+	if ok {
+		statements, ok = splitPsqlStatements(*req.Script)
+	}
+	if !ok {
+		if r.fallback == nil {
+			return "", fmt.Errorf("psql script needs the container runner")
+		}
+		return r.fallback.Run(ctx, instance, req)
+	}
+
+	host := instance.Host
+	if strings.TrimSpace(host) == "" {
+		host = "127.0.0.1"
+	}
+	port := instance.Port
+	if port == 0 {
+		port = 5432
+	}
+	config, err := pgconn.ParseConfig("host=" + host + " port=" + strconv.Itoa(port) + " user=" + engineRuntime.PostgresSuperuser(req.User) + " dbname=postgres sslmode=disable")
+	if err != nil {
+		return "", err
+	}
+	if req.StatementTimeout > 0 {
+		config.RuntimeParams["statement_timeout"] = statementTimeoutSetting(req.StatementTimeout)
+	}
+	out := &psqlNativeOutput{sink: engineRuntime.LogSinkFromContext(ctx)}
+	config.OnNotice = func(_ *pgconn.PgConn, notice *pgconn.Notice) {
+		out.line(notice.Severity + ":  " + notice.Message)
+	}
+	connect := r.connect
+	if connect == nil {
+		connect = pgconn.ConnectConfig
+	}
+	conn, err := connect(ctx, config)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close(context.Background())
+
+	singleTx := hasPsqlSingleTransactionFlag(req.Args)
+	if singleTx {
+		statements = append(append([]string{"BEGIN"}, statements...), "COMMIT")
+	}
+	for _, statement := range statements {
+		if !psqlStatementHasCode(statement) {
+			continue
+		}
+		results, err := conn.Exec(ctx, statement).ReadAll()
+		if err != nil {
+			out.error(err)
+			return out.String(), err
+		}
+		if singleTx && (statement == "BEGIN" || statement == "COMMIT") {
+			continue
+		}
+		for _, result := range results {
+			if tag := result.CommandTag.String(); tag != "" {
+				out.line(tag)
+			}
+		}
+	}
+	return out.String(), nil
+}
+
+// psqlStatementHasCode reports whether a statement has anything besides
+// whitespace and comments, so trailing comments are not sent as queries.
+func psqlStatementHasCode(statement string) bool {
+	s := &psqlSplitScanner{src: statement}
+	s.scan()
+	return s.codeEnd > 0
+}
+
+// psqlNativeOutput collects the lines psql would print and forwards each one
+// to the log sink as it is produced.
+type psqlNativeOutput struct {
+	sink  engineRuntime.LogSink
+	lines []string
+}
+
+func (o *psqlNativeOutput) line(line string) {
+	o.lines = append(o.lines, line)
+	if o.sink != nil {
+		o.sink(line)
+	}
+}
+
+func (o *psqlNativeOutput) error(err error) {
+	pgErr, ok := err.(*pgconn.PgError)
+	if !ok {
+		o.line(err.Error())
+		return
+	}
+	o.line(pgErr.Severity + ":  " + pgErr.Message)
+	if pgErr.Detail != "" {
+		o.line("DETAIL:  " + pgErr.Detail)
+	}
+	if pgErr.Hint != "" {
+		o.line("HINT:  " + pgErr.Hint)
+	}
+}
+
+func (o *psqlNativeOutput) String() string {
+	if len(o.lines) == 0 {
+		return ""
+	}
+	return strings.Join(o.lines, "\n") + "\n"
+}
+
+// psqlRunnerMode reads prepare.psql.runner; anything but "native" selects the
+// container runner.
+func (m *PrepareService) psqlRunnerMode() string {
+	if m.config == nil {
+		return psqlRunnerContainer
+	}
+	value, err := m.config.Get("prepare.psql.runner", true)
+	if err != nil || value == nil {
+		return psqlRunnerContainer
+	}
+	if raw, ok := value.(string); ok && strings.TrimSpace(raw) == psqlRunnerNative {
+		return psqlRunnerNative
+	}
+	return psqlRunnerContainer
+}
+
+// nativePsqlScript returns the SQL a step runs when it can be executed
+// without psql, or nil when the step needs psql: it sets variables other than
+// ON_ERROR_STOP, passes output flags, or its script has meta-commands.
+func nativePsqlScript(step psqlStep, workDir string, limits psqlScriptLimits) *string {
+	if len(step.inputs) == 0 || !nativePsqlArgs(step.args) || hasPsqlCopyData(step.inputs) {
+		return nil
+	}
+	var script string
+	if step.stdin != nil {
+		script = strings.Join(append(append([]string{}, step.preamble...), *step.stdin), "\n")
+	} else {
+		content, err := expandPsqlInputs(step.inputs, workDir, limits)
+		if err != nil {
+			return nil
+		}
+		script = content
+	}
+	if _, ok := splitPsqlStatements(script); !ok {
+		return nil
+	}
+	return &script
+}
+
+func nativePsqlArgs(args []string) bool {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "-c" || arg == "--command" || arg == "-f" || arg == "--file":
+			i++
+		case strings.HasPrefix(arg, "--command=") || strings.HasPrefix(arg, "--file="):
+		case strings.HasPrefix(arg, "-c") || strings.HasPrefix(arg, "-f"):
+		case arg == "-X" || arg == "--no-psqlrc" || arg == "-q" || arg == "--quiet":
+		case arg == "-1" || arg == "--single-transaction":
+		case arg == "-v" || arg == "--set" || arg == "--variable":
+			if i+1 >= len(args) || !isOnErrorStopAssignment(args[i+1]) {
+				return false
+			}
+			i++
+		case strings.HasPrefix(arg, "-v"):
+			if !isOnErrorStopAssignment(arg[2:]) {
+				return false
+			}
+		case strings.HasPrefix(arg, "--set="):
+			if !isOnErrorStopAssignment(strings.TrimPrefix(arg, "--set=")) {
+				return false
+			}
+		case strings.HasPrefix(arg, "--variable="):
+			if !isOnErrorStopAssignment(strings.TrimPrefix(arg, "--variable=")) {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func isOnErrorStopAssignment(value string) bool {
+	name, _, ok := splitAssignment(value)
+	return ok && strings.EqualFold(strings.TrimSpace(name), "ON_ERROR_STOP")
+}
@@ -0,0 +1,131 @@
+package prepare
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+
+	engineRuntime "github.com/sqlrs/engine-local/internal/runtime"
+)
+
+func TestPsqlRunnerModeResolution(t *testing.T) {
+	m := &PrepareService{}
+	if got := m.psqlRunnerMode(); got != psqlRunnerContainer {
+		t.Fatalf("expected container without config, got %q", got)
+	}
+	m.config = &fakeConfigStore{values: map[string]any{"prepare.psql.runner": "native"}}
+	if got := m.psqlRunnerMode(); got != psqlRunnerNative {
+		t.Fatalf("expected native, got %q", got)
+	}
+	m.config = &fakeConfigStore{values: map[string]any{"prepare.psql.runner": "other"}}
+	if got := m.psqlRunnerMode(); got != psqlRunnerContainer {
+		t.Fatalf("expected unknown runner to fall back to container, got %q", got)
+	}
+}
+
+func TestSplitPsqlStatements(t *testing.T) {
+	statements, ok := splitPsqlStatements("create table t(id int);\ninsert into t values (1); -- done\n")
+	if !ok {
+		t.Fatalf("expected plain SQL to run natively")
+	}
+	if len(statements) != 2 || statements[0] != "create table t(id int);\n" {
+		t.Fatalf("unexpected statements: %q", statements)
+	}
+	if _, ok := splitPsqlStatements("select 1;\n\\gset\n"); ok {
+		t.Fatalf("expected meta-command to need psql")
+	}
+	if _, ok := splitPsqlStatements("copy t from stdin;\n1\n\\.\n"); ok {
+		t.Fatalf("expected COPY FROM STDIN to need psql")
+	}
+}
+
+func TestNativePsqlScript(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "init.sql")
+	if err := os.WriteFile(path, []byte("create table t(id int);\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	step := psqlStep{
+		args:   []string{"-X", "-v", "ON_ERROR_STOP=1", "-f", path},
+		inputs: []psqlInput{{kind: "file", value: path}},
+	}
+	script := nativePsqlScript(step, filepath.Dir(path), psqlScriptLimits{})
+	if script == nil || *script != "create table t(id int);\n" {
+		t.Fatalf("expected expanded script, got %v", script)
+	}
+
+	step.args = []string{"-v", "schema=app", "-f", path}
+	if script := nativePsqlScript(step, filepath.Dir(path), psqlScriptLimits{}); script != nil {
+		t.Fatalf("expected psql variables to need psql, got %q", *script)
+	}
+
+	chunk := "\\set x 1\nselect :x;\n"
+	step = psqlStep{
+		args:   []string{"-X", "-f", "-"},
+		inputs: []psqlInput{{kind: "stdin", value: chunk}},
+		stdin:  &chunk,
+	}
+	if script := nativePsqlScript(step, "", psqlScriptLimits{}); script != nil {
+		t.Fatalf("expected meta-command to need psql, got %q", *script)
+	}
+}
+
+func TestPgxPsqlRunnerFallsBackToContainer(t *testing.T) {
+	fallback := &fakePsqlRunner{output: "ok"}
+	runner := pgxPsqlRunner{
+		fallback: fallback,
+		connect: func(ctx context.Context, config *pgconn.Config) (*pgconn.PgConn, error) {
+			t.Fatalf("unexpected connect")
+			return nil, nil
+		},
+	}
+	script := "\\i other.sql\n"
+	for _, req := range []PsqlRunRequest{{}, {Script: &script}} {
+		output, err := runner.Run(context.Background(), engineRuntime.Instance{ID: "inst"}, req)
+		if err != nil || output != "ok" {
+			t.Fatalf("expected fallback output, got %q err=%v", output, err)
+		}
+	}
+	if len(fallback.runs) != 2 {
+		t.Fatalf("expected 2 fallback runs, got %d", len(fallback.runs))
+	}
+
+	if _, err := (pgxPsqlRunner{}).Run(context.Background(), engineRuntime.Instance{}, PsqlRunRequest{}); err == nil {
+		t.Fatalf("expected error without fallback")
+	}
+}
+
+func TestPgxPsqlRunnerConnectsToInstance(t *testing.T) {
+	var got *pgconn.Config
+	runner := pgxPsqlRunner{
+		connect: func(ctx context.Context, config *pgconn.Config) (*pgconn.PgConn, error) {
+			got = config
+			return nil, errors.New("boom")
+		},
+	}
+	script := "select 1;"
+	_, err := runner.Run(context.Background(), engineRuntime.Instance{Host: "127.0.0.1", Port: 55432}, PsqlRunRequest{Script: &script})
+	if err == nil || err.Error() != "boom" {
+		t.Fatalf("expected connect error, got %v", err)
+	}
+	if got == nil || got.Host != "127.0.0.1" || got.Port != 55432 || got.User != "sqlrs" || got.Database != "postgres" {
+		t.Fatalf("unexpected connection config: %+v", got)
+	}
+}
+
+func TestPsqlNativeOutputFormatsErrors(t *testing.T) {
+	var streamed []string
+	out := &psqlNativeOutput{sink: func(line string) { streamed = append(streamed, line) }}
+	out.line("NOTICE:  hello")
+	out.error(&pgconn.PgError{Severity: "ERROR", Message: "relation \"t\" does not exist", Hint: "create it"})
+	want := "NOTICE:  hello\nERROR:  relation \"t\" does not exist\nHINT:  create it\n"
+	if out.String() != want {
+		t.Fatalf("unexpected output: %q", out.String())
+	}
+	if len(streamed) != 3 {
+		t.Fatalf("expected every line streamed, got %q", streamed)
+	}
+}
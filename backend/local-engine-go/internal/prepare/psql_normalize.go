@@ -0,0 +1,388 @@
+package prepare
+
+import (
+	"regexp"
+	"strings"
+)
+
+// psqlMetaCopyFromStdinPattern matches a \copy ... FROM STDIN meta-command,
+// whose rows follow it in the script itself.
+var psqlMetaCopyFromStdinPattern = regexp.MustCompile(`(?i)^\\copy\b.*\bfrom\s+stdin\b`)
+
+type psqlNormalizeState int
+
+const (
+	normalizeCode         psqlNormalizeState = iota
+	normalizeDash                            // '-' seen, may open a line comment
+	normalizeSlash                           // '/' seen, may open a block comment
+	normalizeLineComment                     // -- ... up to the end of the line
+	normalizeBlockComment                    // /* ... */, nested
+	normalizeQuoted                          // '...' or "..."
+	normalizeQuoteEnd                        // closing quote seen, may be doubled
+	normalizeDollarTag                       // '$' seen, may open a $tag$ quote
+	normalizeDollarBody                      // inside $tag$ ... $tag$
+	normalizeMeta                            // backslash command up to the end of the line
+	normalizeCopyLine                        // rest of the COPY ... FROM STDIN line
+	normalizeCopyData                        // rows up to a "\." line
+)
+
+// psqlNormalizer canonicalizes expanded script text for
+// prepare.psql.normalizeHash: comments are dropped and every whitespace run
+// outside literals becomes one space, so reformatting a script keeps its hash.
+// Quoted strings and identifiers, dollar-quoted bodies, meta-commands and
+// COPY ... FROM STDIN rows are kept byte for byte. It lexes as it writes, like
+// psqlSplitScanner but one byte at a time, so scripts are still never held in
+// memory.
+type psqlNormalizer struct {
+	out   psqlContentWriter
+	state psqlNormalizeState
+
+	pendingSpace bool
+	last         byte // last two bytes written, to spot E'...' strings
+	beforeLast   byte
+
+	quote   byte
+	escapes bool // backslash escapes, in E'...' strings
+	escaped bool
+
+	commentDepth int
+	commentPrev  byte
+
+	tag       []byte
+	delimiter string
+	match     int
+
+	meta    []byte
+	line    []byte // first bytes of the current COPY row
+	lineLen int
+
+	word      []byte
+	firstWord string
+	prevWord  string
+	copyStdin bool
+}
+
+func newPsqlNormalizer(out psqlContentWriter) *psqlNormalizer {
+	return &psqlNormalizer{out: out}
+}
+
+func (n *psqlNormalizer) WriteString(s string) (int, error) {
+	for i := 0; i < len(s); i++ {
+		n.feed(s[i])
+	}
+	return len(s), nil
+}
+
+func (n *psqlNormalizer) WriteByte(c byte) error {
+	n.feed(c)
+	return nil
+}
+
+// writeRaw writes text as is and starts lexing afresh after it. It is used
+// for the boundary markers and stdin rows around inputs and includes.
+func (n *psqlNormalizer) writeRaw(text string) {
+	n.flush()
+	for i := 0; i < len(text); i++ {
+		n.raw(text[i])
+	}
+	n.pendingSpace = false
+}
+
+// flush writes any lookahead still held back and resets the lexer.
+func (n *psqlNormalizer) flush() {
+	switch n.state {
+	case normalizeDash:
+		n.emit('-')
+	case normalizeSlash:
+		n.emit('/')
+	case normalizeDollarTag:
+		n.abortDollarTag()
+	case normalizeMeta:
+		n.writeMeta()
+	}
+	n.state = normalizeCode
+	n.pendingSpace = false
+	n.word = n.word[:0]
+	n.resetStatement()
+}
+
+func (n *psqlNormalizer) feed(c byte) {
+	switch n.state {
+	case normalizeCode:
+		n.code(c)
+	case normalizeDash:
+		if c == '-' {
+			n.state = normalizeLineComment
+			return
+		}
+		n.emit('-')
+		n.state = normalizeCode
+		n.code(c)
+	case normalizeSlash:
+		if c == '*' {
+			n.state = normalizeBlockComment
+			n.commentDepth = 1
+			n.commentPrev = 0
+			return
+		}
+		n.emit('/')
+		n.state = normalizeCode
+		n.code(c)
+	case normalizeLineComment:
+		if c == '\n' {
+			n.state = normalizeCode
+			n.pendingSpace = true
+		}
+	case normalizeBlockComment:
+		switch {
+		case n.commentPrev == '/' && c == '*':
+			n.commentDepth++
+			n.commentPrev = 0
+		case n.commentPrev == '*' && c == '/':
+			n.commentDepth--
+			n.commentPrev = 0
+			if n.commentDepth == 0 {
+				n.state = normalizeCode
+				n.pendingSpace = true
+			}
+		default:
+			n.commentPrev = c
+		}
+	case normalizeQuoted:
+		n.raw(c)
+		switch {
+		case n.escaped:
+			n.escaped = false
+		case n.escapes && c == '\\':
+			n.escaped = true
+		case c == n.quote:
+			n.state = normalizeQuoteEnd
+		}
+	case normalizeQuoteEnd:
+		if c == n.quote {
+			n.raw(c)
+			n.state = normalizeQuoted
+			return
+		}
+		n.state = normalizeCode
+		n.code(c)
+	case normalizeDollarTag:
+		n.dollarTag(c)
+	case normalizeDollarBody:
+		n.raw(c)
+		switch {
+		case c == n.delimiter[n.match]:
+			n.match++
+			if n.match == len(n.delimiter) {
+				n.state = normalizeCode
+			}
+		case c == '$':
+			n.match = 1
+		default:
+			n.match = 0
+		}
+	case normalizeMeta:
+		if c == '\n' {
+			n.endMeta()
+			return
+		}
+		n.meta = append(n.meta, c)
+	case normalizeCopyLine:
+		n.raw(c)
+		if c == '\n' {
+			n.startCopyData()
+		}
+	case normalizeCopyData:
+		n.copyData(c)
+	}
+}
+
+func (n *psqlNormalizer) code(c byte) {
+	switch {
+	case isPsqlSpace(c):
+		n.endWord()
+		n.pendingSpace = true
+	case c == '-':
+		n.endWord()
+		n.state = normalizeDash
+	case c == '/':
+		n.endWord()
+		n.state = normalizeSlash
+	case c == '\'' || c == '"':
+		escapes := c == '\'' && !n.pendingSpace && (n.last == 'E' || n.last == 'e') && !isPsqlIdentChar(n.beforeLast)
+		n.endWord()
+		n.emit(c)
+		n.state = normalizeQuoted
+		n.quote = c
+		n.escapes = escapes
+		n.escaped = false
+	case c == '$' && len(n.word) == 0:
+		n.state = normalizeDollarTag
+		n.tag = n.tag[:0]
+	case c == '\\':
+		n.endWord()
+		n.emit(c)
+		n.state = normalizeMeta
+		n.meta = append(n.meta[:0], c)
+	case c == ';':
+		n.endWord()
+		n.emit(c)
+		if n.copyStdin {
+			n.state = normalizeCopyLine
+		}
+		n.resetStatement()
+	case isPsqlIdentStart(c) || (len(n.word) > 0 && isPsqlIdentChar(c)):
+		n.word = append(n.word, c)
+		n.emit(c)
+	default:
+		n.endWord()
+		n.emit(c)
+	}
+}
+
+// dollarTag reads the tag of a $tag$ quote. Positional parameters such as $1
+// are not quotes and are written back as code.
+func (n *psqlNormalizer) dollarTag(c byte) {
+	switch {
+	case c == '$':
+		n.emit('$')
+		for _, b := range n.tag {
+			n.raw(b)
+		}
+		n.raw('$')
+		n.delimiter = "$" + string(n.tag) + "$"
+		n.match = 0
+		n.state = normalizeDollarBody
+	case isPsqlIdentChar(c) && !(len(n.tag) == 0 && c >= '0' && c <= '9'):
+		n.tag = append(n.tag, c)
+	default:
+		n.abortDollarTag()
+		n.state = normalizeCode
+		n.code(c)
+	}
+}
+
+func (n *psqlNormalizer) abortDollarTag() {
+	n.emit('$')
+	for _, b := range n.tag {
+		n.raw(b)
+	}
+	n.tag = n.tag[:0]
+}
+
+// writeMeta writes the buffered meta-command without trailing blanks.
+func (n *psqlNormalizer) writeMeta() {
+	line := strings.TrimRight(string(n.meta[1:]), " \t\r")
+	for i := 0; i < len(line); i++ {
+		n.raw(line[i])
+	}
+}
+
+// endMeta ends a meta-command at its newline. Only the \g family ends the
+// statement being built; \copy ... FROM STDIN and a COPY ... FROM STDIN sent
+// with \g are followed by rows.
+func (n *psqlNormalizer) endMeta() {
+	n.writeMeta()
+	n.raw('\n')
+	n.pendingSpace = false
+	n.state = normalizeCode
+	line := string(n.meta)
+	name := ""
+	if fields := strings.Fields(line); len(fields) > 0 {
+		name = fields[0]
+	}
+	switch {
+	case psqlMetaCopyFromStdinPattern.MatchString(line):
+		n.startCopyData()
+	case strings.HasPrefix(name, `\g`):
+		if n.copyStdin {
+			n.startCopyData()
+		}
+		n.resetStatement()
+	}
+}
+
+func (n *psqlNormalizer) startCopyData() {
+	n.state = normalizeCopyData
+	n.line = n.line[:0]
+	n.lineLen = 0
+}
+
+// copyData writes COPY rows as is until the "\." line that ends them.
+func (n *psqlNormalizer) copyData(c byte) {
+	n.raw(c)
+	if c != '\n' {
+		n.lineLen++
+		if len(n.line) < 3 {
+			n.line = append(n.line, c)
+		}
+		return
+	}
+	if n.lineLen <= 3 && strings.TrimRight(string(n.line), "\r") == `\.` {
+		n.state = normalizeCode
+		n.pendingSpace = false
+		return
+	}
+	n.line = n.line[:0]
+	n.lineLen = 0
+}
+
+func (n *psqlNormalizer) endWord() {
+	if len(n.word) == 0 {
+		return
+	}
+	word := strings.ToUpper(string(n.word))
+	n.word = n.word[:0]
+	if n.firstWord == "" {
+		n.firstWord = word
+	}
+	if word == "STDIN" && n.prevWord == "FROM" && n.firstWord == "COPY" {
+		n.copyStdin = true
+	}
+	n.prevWord = word
+}
+
+func (n *psqlNormalizer) resetStatement() {
+	n.firstWord = ""
+	n.prevWord = ""
+	n.copyStdin = false
+}
+
+// emit writes a code byte, preceded by one space when whitespace or a
+// comment separated it from the previous token on the same line.
+func (n *psqlNormalizer) emit(c byte) {
+	if n.pendingSpace && n.last != 0 && n.last != '\n' {
+		n.raw(' ')
+	}
+	n.pendingSpace = false
+	n.raw(c)
+}
+
+func (n *psqlNormalizer) raw(c byte) {
+	_ = n.out.WriteByte(c)
+	n.beforeLast = n.last
+	n.last = c
+}
+
+// writePsqlRaw writes text that normalization must keep as is: input and
+// include boundary markers and stdin rows.
+func writePsqlRaw(out psqlContentWriter, text string) (int, error) {
+	if normalizer, ok := out.(*psqlNormalizer); ok {
+		normalizer.writeRaw(text)
+		return len(text), nil
+	}
+	return out.WriteString(text)
+}
+
+// psqlNormalizeHash reads prepare.psql.normalizeHash.
+func (m *PrepareService) psqlNormalizeHash() bool {
+	if m.config == nil {
+		return false
+	}
+	value, err := m.config.Get("prepare.psql.normalizeHash", true)
+	if err != nil {
+		return false
+	}
+	enabled, ok := value.(bool)
+	return ok && enabled
+}
@@ -0,0 +1,214 @@
+package prepare
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func normalizePsql(text string) string {
+	out := &strings.Builder{}
+	normalizer := newPsqlNormalizer(out)
+	_, _ = normalizer.WriteString(text)
+	normalizer.flush()
+	return out.String()
+}
+
+func TestPsqlNormalizerCanonicalForm(t *testing.T) {
+	cases := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"collapses whitespace", "  select\t1 ,\n\n   2 ;\n", "select 1 , 2 ;"},
+		{"crlf", "select 1;\r\nselect 2;\r\n", "select 1; select 2;"},
+		{"line comment", "select 1; -- one\n-- two\nselect 2;", "select 1; select 2;"},
+		{"comment between tokens", "select--x\n1", "select 1"},
+		{"block comment", "/* header\n * text\n */\nselect /* inline */ 1;", "select 1;"},
+		{"nested block comment", "select /* a /* b */ still comment */ 1;", "select 1;"},
+		{"comment without spaces", "select/**/1", "select 1"},
+		{"minus and division", "select 4 - 2 / 1, -1, a-b", "select 4 - 2 / 1, -1, a-b"},
+		{"trailing lookahead", "select 1 -", "select 1 -"},
+		{"string keeps whitespace", "select 'a  \t b';", "select 'a  \t b';"},
+		{"string keeps comment markers", "select 'a -- b /* c */';  -- d", "select 'a -- b /* c */';"},
+		{"doubled quote", "select   'it''s   -- here'  ;", "select 'it''s   -- here' ;"},
+		{"escape string", "select E'it\\'s  -- here';", "select E'it\\'s  -- here';"},
+		{"escape string backslash before quote", "select e'a\\\\'  ,  'b  c'", "select e'a\\\\' , 'b  c'"},
+		{"backslash in plain string", "select 'a\\'  ,  'b  c'", "select 'a\\' , 'b  c'"},
+		{"identifier ending in e", "select type'a  b'", "select type'a  b'"},
+		{"quoted identifier", "select 1 as \"a  --  b\" ,  \"x\"\"y  z\"", "select 1 as \"a  --  b\" , \"x\"\"y  z\""},
+		{"multiline string", "insert into t values ('line 1\n   line 2');", "insert into t values ('line 1\n   line 2');"},
+		{
+			"dollar quote",
+			"create function f() returns text as $$\n  select  'x' -- keep\n$$   language sql;",
+			"create function f() returns text as $$\n  select  'x' -- keep\n$$ language sql;",
+		},
+		{
+			"tagged dollar quote",
+			"do $body$ begin  raise notice '$$  x'; end $body$;",
+			"do $body$ begin  raise notice '$$  x'; end $body$;",
+		},
+		{"dollar tag prefix inside body", "select $a$ x $ab$ $a  $a$", "select $a$ x $ab$ $a  $a$"},
+		{"positional parameters", "select  $1 ,  $2::int", "select $1 , $2::int"},
+		{"dollar in identifier", "select a$b   from  t$1", "select a$b from t$1"},
+		{"meta-command", "\\set x   1   \nselect  :x;\n", "\\set x   1\nselect :x;"},
+		{"meta-command after code", "select 1   \\gset  \nselect  :a", "select 1 \\gset\nselect :a"},
+		{"meta-command keeps comment markers", "\\echo -- not a comment\nselect 1;", "\\echo -- not a comment\nselect 1;"},
+		{
+			"copy from stdin",
+			"COPY t (a, b) FROM stdin;\n1\t  two  -- x\n\\.\nselect   1;",
+			"COPY t (a, b) FROM stdin;\n1\t  two  -- x\n\\.\nselect 1;",
+		},
+		{
+			"copy from stdin with options and comment",
+			"copy t from /* c */ STDIN with (format csv) ;  \n1,  a\n\\.\r\nselect   2;",
+			"copy t from STDIN with (format csv) ;  \n1,  a\n\\.\r\nselect 2;",
+		},
+		{
+			"copy data ends only at its own line",
+			"copy t from stdin;\n\\.x\n  a\n\\.\nselect  1;",
+			"copy t from stdin;\n\\.x\n  a\n\\.\nselect 1;",
+		},
+		{"copy to stdout is code", "copy t to stdout;\n  select  1;", "copy t to stdout; select 1;"},
+		{
+			"copy sent with \\g",
+			"copy t from stdin \\g\n1  a\n\\.\nselect  1;",
+			"copy t from stdin \\g\n1  a\n\\.\nselect 1;",
+		},
+		{
+			"meta copy from stdin",
+			"\\copy t from stdin\n1  a\n\\.\nselect  1;",
+			"\\copy t from stdin\n1  a\n\\.\nselect 1;",
+		},
+		{"meta copy from pstdin", "\\copy t from pstdin\nselect   1;", "\\copy t from pstdin\nselect 1;"},
+		{"unterminated comment", "select 1; /* open", "select 1;"},
+	}
+	for _, tc := range cases {
+		if got := normalizePsql(tc.in); got != tc.want {
+			t.Errorf("%s: normalize(%q) = %q, want %q", tc.name, tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestPsqlNormalizerStreaming(t *testing.T) {
+	script := "create function f() returns int as $fn$\nbegin return 1; end\n$fn$ language plpgsql; -- c\nselect E'a\\'b', \"q  q\";\ncopy t from stdin;\n1\t2\n\\.\n"
+	out := &strings.Builder{}
+	normalizer := newPsqlNormalizer(out)
+	for i := 0; i < len(script); i++ {
+		if err := normalizer.WriteByte(script[i]); err != nil {
+			t.Fatalf("WriteByte: %v", err)
+		}
+	}
+	normalizer.flush()
+	if want := normalizePsql(script); out.String() != want {
+		t.Fatalf("byte-wise output %q differs from %q", out.String(), want)
+	}
+}
+
+func TestPsqlContentDigestNormalized(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.sql"), "create table t (\n    id int,\n    name text\n);\ninsert into t values (1, 'a  b');\n")
+	writeFile(t, filepath.Join(dir, "b.sql"), "-- schema\ncreate table t (\n  id int,  -- key\n  name text\n);\n\n/* seed */\ninsert into t   values (1, 'a  b');\n")
+	writeFile(t, filepath.Join(dir, "c.sql"), "create table t (\n    id int,\n    name text\n);\ninsert into t values (1, 'a b');\n")
+
+	digest := func(name string, normalize bool) string {
+		t.Helper()
+		got, err := computePsqlContentDigest([]psqlInput{{kind: "file", value: filepath.Join(dir, name)}}, dir, psqlScriptLimits{}, normalize)
+		if err != nil {
+			t.Fatalf("digest %s: %v", name, err)
+		}
+		return got.hash
+	}
+	if digest("a.sql", false) == digest("b.sql", false) {
+		t.Fatalf("expected raw hashes of reformatted scripts to differ")
+	}
+	if digest("a.sql", true) != digest("b.sql", true) {
+		t.Fatalf("expected normalized hashes of reformatted scripts to match")
+	}
+	if digest("a.sql", true) == digest("c.sql", true) {
+		t.Fatalf("expected whitespace inside a string literal to change the normalized hash")
+	}
+}
+
+func TestPsqlContentDigestNormalizedKeepsBoundaries(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "inc.sql"), "select 2;\n")
+	digest := func(normalize bool, inputs ...psqlInput) string {
+		t.Helper()
+		got, err := computePsqlContentDigest(inputs, dir, psqlScriptLimits{}, normalize)
+		if err != nil {
+			t.Fatalf("digest: %v", err)
+		}
+		return got.hash
+	}
+
+	split := digest(true, psqlInput{kind: "command", value: "select 1;"}, psqlInput{kind: "command", value: "select 2;"})
+	joined := digest(true, psqlInput{kind: "command", value: "select 1; select 2;"})
+	if split == joined {
+		t.Fatalf("expected input boundaries to survive normalization")
+	}
+	included := digest(true, psqlInput{kind: "command", value: "select 1;\n\\i inc.sql"})
+	if included == joined {
+		t.Fatalf("expected include boundaries to survive normalization")
+	}
+	if again := digest(true, psqlInput{kind: "command", value: "select   1;\n\\ir   inc.sql  "}); again != included {
+		t.Fatalf("expected include form and spacing to be ignored")
+	}
+
+	rows := func(data string) string {
+		return digest(true, psqlInput{kind: "command", value: "copy t from stdin"}, psqlInput{kind: psqlInputCopyData, value: data})
+	}
+	if rows("1\t a\n") == rows("1\ta\n") {
+		t.Fatalf("expected stdin rows to be hashed as is")
+	}
+}
+
+func TestPsqlNormalizeHashConfig(t *testing.T) {
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{})
+	if mgr.psqlNormalizeHash() {
+		t.Fatalf("expected normalizeHash to default to off")
+	}
+	for value, want := range map[any]bool{true: true, false: false, "yes": false} {
+		mgr = newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{
+			config: &fakeConfigStore{values: map[string]any{"prepare.psql.normalizeHash": value}},
+		})
+		if got := mgr.psqlNormalizeHash(); got != want {
+			t.Fatalf("normalizeHash=%v: got %v, want %v", value, got, want)
+		}
+	}
+}
+
+func TestPsqlNormalizeHashUsesSeparateNamespace(t *testing.T) {
+	req := Request{PrepareKind: "psql", ImageID: "image-1", PsqlArgs: []string{"-c", "select 1;"}}
+	raw := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{})
+	normalized := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{
+		config: &fakeConfigStore{values: map[string]any{"prepare.psql.normalizeHash": true}},
+	})
+
+	taskHash := func(mgr *PrepareService, req Request) string {
+		t.Helper()
+		prepared, err := mgr.prepareRequest(req)
+		if err != nil {
+			t.Fatalf("prepareRequest: %v", err)
+		}
+		hash, errResp := mgr.computeTaskHash(prepared)
+		if errResp != nil {
+			t.Fatalf("computeTaskHash: %+v", errResp)
+		}
+		return hash
+	}
+
+	// "select 1;" is already canonical, so only the namespace tells the
+	// two hashes apart.
+	if taskHash(raw, req) == taskHash(normalized, req) {
+		t.Fatalf("expected normalized hashing to use its own namespace")
+	}
+	reformatted := req
+	reformatted.PsqlArgs = []string{"-c", "select   1; -- note"}
+	if taskHash(normalized, req) != taskHash(normalized, reformatted) {
+		t.Fatalf("expected reformatted script to hit the same normalized hash")
+	}
+	if taskHash(raw, req) == taskHash(raw, reformatted) {
+		t.Fatalf("expected raw hashing to stay byte-exact")
+	}
+}
@@ -0,0 +1,112 @@
+package prepare
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	searchPathIdentPattern  = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_$]*$`)
+	searchPathQuotedPattern = regexp.MustCompile(`^"(?:[^"]|"")+"$`)
+)
+
+// psqlPreamble returns the statements run ahead of the script in every psql
+// session of the job: SET search_path for searchPath, then the request
+// preamble. Each statement is passed with -c, so it must be plain SQL;
+// meta-commands and COPY ... FROM STDIN are rejected. Statements are
+// terminated with ';' so they can be joined with the script.
+func psqlPreamble(searchPath string, statements []string) ([]string, error) {
+	var out []string
+	if strings.TrimSpace(searchPath) != "" {
+		stmt, err := searchPathStatement(searchPath)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, stmt)
+	}
+	for _, raw := range statements {
+		stmt := strings.TrimSpace(raw)
+		if stmt == "" {
+			return nil, ValidationError{Code: ErrorCodeInvalidArgument, Message: "psql_preamble statements must not be empty"}
+		}
+		s := &psqlSplitScanner{src: stmt}
+		s.scan()
+		s.endStatement(true)
+		if s.hasMeta {
+			return nil, ValidationError{Code: ErrorCodeInvalidArgument, Message: "psql_preamble statements cannot contain psql meta-commands", Details: raw}
+		}
+		if isPsqlCopyFromStdin(stmt) {
+			return nil, ValidationError{Code: ErrorCodeInvalidArgument, Message: "psql_preamble statements cannot read stdin", Details: raw}
+		}
+		out = append(out, terminatePsqlStatement(stmt))
+	}
+	return out, nil
+}
+
+// searchPathStatement builds SET search_path from a comma separated list of
+// schemas. Each schema is a plain identifier or a double-quoted one
+// ("$user"), so the value cannot carry other SQL.
+func searchPathStatement(value string) (string, error) {
+	parts := strings.Split(value, ",")
+	schemas := make([]string, 0, len(parts))
+	for _, part := range parts {
+		schema := strings.TrimSpace(part)
+		if !searchPathIdentPattern.MatchString(schema) && !searchPathQuotedPattern.MatchString(schema) {
+			return "", ValidationError{Code: ErrorCodeInvalidArgument, Message: "search_path must be a comma separated list of schema names", Details: value}
+		}
+		schemas = append(schemas, schema)
+	}
+	return "SET search_path TO " + strings.Join(schemas, ", ") + ";", nil
+}
+
+// terminatePsqlStatement appends ';' unless the statement already ends with
+// one. A trailing line comment gets the ';' on a line of its own.
+func terminatePsqlStatement(stmt string) string {
+	if strings.HasSuffix(stmt, ";") {
+		return stmt
+	}
+	lastLine := stmt[strings.LastIndex(stmt, "\n")+1:]
+	if strings.Contains(lastLine, "--") {
+		return stmt + "\n;"
+	}
+	return stmt + ";"
+}
+
+// withPsqlPreamble adds the preamble to the front of every step as -c
+// commands, so it runs in the step's own psql session (and inside its
+// transaction with --single-transaction) right before the script, and is part
+// of the step's content hash.
+func withPsqlPreamble(steps []psqlStep, preamble []string) []psqlStep {
+	if len(preamble) == 0 {
+		return steps
+	}
+	out := make([]psqlStep, 0, len(steps))
+	for _, step := range steps {
+		shared := len(step.shared)
+		if shared > len(step.args) {
+			shared = len(step.args)
+		}
+		args := append([]string{}, step.args[:shared]...)
+		inputs := make([]psqlInput, 0, len(preamble)+len(step.inputs))
+		for _, stmt := range preamble {
+			args = append(args, "-c", stmt)
+			inputs = append(inputs, psqlInput{kind: "command", value: stmt})
+		}
+		step.args = append(args, step.args[shared:]...)
+		step.inputs = append(inputs, step.inputs...)
+		step.preamble = append([]string{}, preamble...)
+		out = append(out, step)
+	}
+	return out
+}
+
+func psqlPreambleInputs(preamble []string, inputs []psqlInput) []psqlInput {
+	if len(preamble) == 0 {
+		return inputs
+	}
+	out := make([]psqlInput, 0, len(preamble)+len(inputs))
+	for _, stmt := range preamble {
+		out = append(out, psqlInput{kind: "command", value: stmt})
+	}
+	return append(out, inputs...)
+}
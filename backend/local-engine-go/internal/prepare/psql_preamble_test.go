@@ -0,0 +1,153 @@
+package prepare
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func preambleStateID(t *testing.T, mgr *PrepareService, req Request) string {
+	t.Helper()
+	prepared, err := mgr.prepareRequest(req)
+	if err != nil {
+		t.Fatalf("prepareRequest: %v", err)
+	}
+	prepared.resolvedImageID = prepared.request.ImageID
+	_, stateID, errResp := mgr.buildPlanPsql(prepared)
+	if errResp != nil {
+		t.Fatalf("buildPlanPsql: %+v", errResp)
+	}
+	return stateID
+}
+
+func TestPsqlPreambleChangesStateID(t *testing.T) {
+	mgr := newManager(t, &fakeStore{})
+	req := Request{PrepareKind: "psql", ImageID: "image@sha256:abc", PsqlArgs: []string{"-c", "create table t(id int)"}}
+	plain := preambleStateID(t, mgr, req)
+
+	req.PsqlPreamble = []string{"SET ROLE app_owner"}
+	owner := preambleStateID(t, mgr, req)
+	req.PsqlPreamble = []string{"SET ROLE app_reader"}
+	reader := preambleStateID(t, mgr, req)
+	req.PsqlPreamble = nil
+	req.SearchPath = "app, public"
+	searchPath := preambleStateID(t, mgr, req)
+
+	if plain == owner || owner == reader || plain == searchPath {
+		t.Fatalf("expected preamble to change the state id: plain=%s owner=%s reader=%s search_path=%s", plain, owner, reader, searchPath)
+	}
+}
+
+func TestSubmitRunsPsqlPreambleBeforeScript(t *testing.T) {
+	psql := &fakePsqlRunner{}
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{psql: psql})
+
+	accepted, err := mgr.Submit(context.Background(), Request{
+		PrepareKind:           "psql",
+		ImageID:               "image-1",
+		PsqlArgs:              []string{"-c", "create table t(id int)"},
+		SearchPath:            `app, "$user"`,
+		PsqlPreamble:          []string{"SET ROLE app_owner"},
+		PsqlSingleTransaction: true,
+	})
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	if status, ok := mgr.Get(accepted.JobID); !ok || status.Status != StatusSucceeded {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+	if len(psql.runs) != 1 {
+		t.Fatalf("expected one psql run, got %+v", psql.runs)
+	}
+	var commands []string
+	args := psql.runs[0].Args
+	for i := 0; i+1 < len(args); i++ {
+		if args[i] == "-c" {
+			commands = append(commands, args[i+1])
+		}
+	}
+	want := []string{`SET search_path TO app, "$user";`, "SET ROLE app_owner;", "create table t(id int)"}
+	if strings.Join(commands, "|") != strings.Join(want, "|") {
+		t.Fatalf("expected preamble before the script in one session, got %q", commands)
+	}
+	if !hasPsqlSingleTransactionFlag(args) {
+		t.Fatalf("expected the session to run with --single-transaction, got %v", args)
+	}
+}
+
+func TestPsqlPreambleRunsInEverySplitStep(t *testing.T) {
+	mgr := newManager(t, &fakeStore{})
+	stdin := "create table a(id int);\ncreate table b(id int);\n"
+	prepared, err := mgr.prepareRequest(Request{
+		PrepareKind: "psql",
+		ImageID:     "image-1",
+		PsqlArgs:    []string{"-f", "-"},
+		Stdin:       &stdin,
+		PsqlSplit:   true,
+		SearchPath:  "app",
+	})
+	if err != nil {
+		t.Fatalf("prepareRequest: %v", err)
+	}
+	if len(prepared.psqlSteps) != 2 {
+		t.Fatalf("expected two split steps, got %d", len(prepared.psqlSteps))
+	}
+	for i, step := range prepared.psqlSteps {
+		if !containsArg(step.args, "SET search_path TO app;") || step.inputs[0].value != "SET search_path TO app;" {
+			t.Fatalf("step %d: expected search_path preamble, got args=%v inputs=%+v", i, step.args, step.inputs)
+		}
+		script := nativePsqlScript(step, "", psqlScriptLimits{})
+		if script == nil || !strings.HasPrefix(*script, "SET search_path TO app;\n") {
+			t.Fatalf("step %d: expected native script to start with the preamble, got %v", i, script)
+		}
+	}
+}
+
+func TestPsqlPreambleValidation(t *testing.T) {
+	mgr := newManager(t, &fakeStore{})
+	cases := map[string]Request{
+		"meta-command":   {PrepareKind: "psql", PsqlPreamble: []string{`\set ON_ERROR_STOP 0`}},
+		"inline meta":    {PrepareKind: "psql", PsqlPreamble: []string{"select 1 \\gset"}},
+		"copy stdin":     {PrepareKind: "psql", PsqlPreamble: []string{"copy t from stdin"}},
+		"empty":          {PrepareKind: "psql", PsqlPreamble: []string{"  "}},
+		"bad searchpath": {PrepareKind: "psql", SearchPath: "app; drop table t"},
+		"not psql":       {PrepareKind: "lb", SearchPath: "app", LiquibaseArgs: []string{"update"}},
+		"single tx":      {PrepareKind: "psql", PsqlPreamble: []string{"commit"}, PsqlSingleTransaction: true},
+	}
+	for name, req := range cases {
+		req.ImageID = "image-1"
+		if req.PsqlArgs == nil && req.PrepareKind == "psql" {
+			req.PsqlArgs = []string{"-c", "select 1"}
+		}
+		_, err := mgr.prepareRequest(req)
+		var validation ValidationError
+		if !errors.As(err, &validation) {
+			t.Fatalf("%s: expected validation error, got %v", name, err)
+		}
+	}
+
+	if _, err := mgr.prepareRequest(Request{
+		PrepareKind:  "psql",
+		ImageID:      "image-1",
+		PsqlArgs:     []string{"-c", "select 1"},
+		PsqlPreamble: []string{`select '\not a meta-command'`},
+	}); err != nil {
+		t.Fatalf("expected backslash inside a literal to be accepted: %v", err)
+	}
+}
+
+func TestTerminatePsqlStatement(t *testing.T) {
+	cases := map[string]string{
+		"SET ROLE app;":           "SET ROLE app;",
+		"SET ROLE app":            "SET ROLE app;",
+		"SET ROLE app -- owner":   "SET ROLE app -- owner\n;",
+		"SET a = 1;\nSET b = 2":   "SET a = 1;\nSET b = 2;",
+		"SET a = '--x';\nSET b=2": "SET a = '--x';\nSET b=2;",
+	}
+	for stmt, want := range cases {
+		if got := terminatePsqlStatement(stmt); got != want {
+			t.Fatalf("terminatePsqlStatement(%q) = %q, want %q", stmt, got, want)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package prepare
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPrepareRequestPsqlSingleTransactionAddsFlag(t *testing.T) {
+	mgr := newManager(t, &fakeStore{})
+	prepared, err := mgr.prepareRequest(Request{
+		PrepareKind:           "psql",
+		ImageID:               "image-1",
+		PsqlArgs:              []string{"-c", "create table t(id int)"},
+		PsqlSingleTransaction: true,
+	})
+	if err != nil {
+		t.Fatalf("prepareRequest: %v", err)
+	}
+	if !strings.Contains(prepared.argsNormalized, "--single-transaction") {
+		t.Fatalf("expected --single-transaction in normalized args, got %q", prepared.argsNormalized)
+	}
+	if !prepared.psqlSingleTx {
+		t.Fatalf("expected single-transaction mode")
+	}
+	step, err := psqlStepForPreparedTask(prepared, "execute-0")
+	if err != nil {
+		t.Fatalf("psqlStepForPreparedTask: %v", err)
+	}
+	if !hasPsqlSingleTransactionFlag(step.args) {
+		t.Fatalf("expected psql step to run with --single-transaction, got %v", step.args)
+	}
+
+	// An explicit -1 is not duplicated and enables the same mode.
+	prepared, err = mgr.prepareRequest(Request{
+		PrepareKind:           "psql",
+		ImageID:               "image-1",
+		PsqlArgs:              []string{"-1", "-c", "create table t(id int)"},
+		PsqlSingleTransaction: true,
+	})
+	if err != nil {
+		t.Fatalf("prepareRequest with -1: %v", err)
+	}
+	if strings.Contains(prepared.argsNormalized, "--single-transaction") || !prepared.psqlSingleTx {
+		t.Fatalf("unexpected normalized args %q single=%v", prepared.argsNormalized, prepared.psqlSingleTx)
+	}
+}
+
+func TestPsqlSingleTransactionChangesTaskHash(t *testing.T) {
+	mgr := newManager(t, &fakeStore{})
+	req := Request{PrepareKind: "psql", ImageID: "image-1", PsqlArgs: []string{"-c", "select 1"}}
+	plain, err := mgr.prepareRequest(req)
+	if err != nil {
+		t.Fatalf("prepareRequest: %v", err)
+	}
+	req.PsqlSingleTransaction = true
+	single, err := mgr.prepareRequest(req)
+	if err != nil {
+		t.Fatalf("prepareRequest single: %v", err)
+	}
+	if psqlPreparedTaskHash(plain, "content", "v1") == psqlPreparedTaskHash(single, "content", "v1") {
+		t.Fatalf("expected single-transaction mode to change the task hash")
+	}
+	if psqlPreparedTaskHash(plain, "content", "v1") != psqlTaskHash("psql", "content", "v1") {
+		t.Fatalf("expected plain requests to keep their task hash")
+	}
+}
+
+func TestPrepareRequestPsqlSingleTransactionRejectsTransactionControl(t *testing.T) {
+	mgr := newManager(t, &fakeStore{})
+	script := filepath.Join(t.TempDir(), "migrate.sql")
+	writeTempFile(t, script, "create table t(id int);\nBEGIN;\ninsert into t values (1);\nCOMMIT;\n")
+	cases := map[string][]string{
+		"file":    {"-f", script},
+		"command": {"-c", "start transaction; select 1; commit"},
+	}
+	for name, args := range cases {
+		_, err := mgr.prepareRequest(Request{
+			PrepareKind:           "psql",
+			ImageID:               "image-1",
+			PsqlArgs:              args,
+			PsqlSingleTransaction: true,
+		})
+		var validation ValidationError
+		if !errors.As(err, &validation) || !strings.Contains(validation.Message, "explicit transaction control") {
+			t.Fatalf("%s: expected transaction control validation error, got %v", name, err)
+		}
+	}
+	// Without single-transaction mode the same script is accepted.
+	if _, err := mgr.prepareRequest(Request{PrepareKind: "psql", ImageID: "image-1", PsqlArgs: []string{"-f", script}}); err != nil {
+		t.Fatalf("expected script to be accepted without single-transaction mode: %v", err)
+	}
+}
+
+func TestPsqlTransactionControlStatement(t *testing.T) {
+	cases := map[string]string{
+		"create table t(id int);":               "",
+		"savepoint a; rollback to savepoint a;": "",
+		"create function f() returns int as $$ begin return 1; end $$ language plpgsql;": "",
+		"do $$ begin perform 1; end $$;":                  "",
+		"select 'begin; commit';":                         "",
+		"-- begin;\nselect 1;":                            "",
+		"select 1;\nbegin;\nselect 2;\ncommit;":           "BEGIN",
+		"select 1;\nCOMMIT":                               "COMMIT",
+		"start transaction isolation level serializable;": "START TRANSACTION",
+		"prepare transaction 'x';":                        "PREPARE TRANSACTION",
+		"prepare stmt as select 1;":                       "",
+	}
+	for script, want := range cases {
+		if got := psqlTransactionControlStatement(script); got != want {
+			t.Fatalf("psqlTransactionControlStatement(%q) = %q, want %q", script, got, want)
+		}
+	}
+}
+
+func TestExecuteStateTaskPsqlFailureSkipsSnapshot(t *testing.T) {
+	dbms := &fakeDBMS{}
+	snap := &fakeStateFS{}
+	psql := &fakePsqlRunner{err: errors.New("exit status 3"), output: "ERROR:  relation \"missing\" does not exist"}
+	store := &fakeStore{}
+	mgr := newManagerWithDeps(t, store, newQueueStore(t), &testDeps{statefs: snap, dbms: dbms, psql: psql})
+
+	prepared, err := mgr.prepareRequest(Request{
+		PrepareKind:           "psql",
+		ImageID:               "image-1",
+		PsqlArgs:              []string{"-c", "insert into missing values (1)"},
+		PsqlSingleTransaction: true,
+	})
+	if err != nil {
+		t.Fatalf("prepareRequest: %v", err)
+	}
+	outputID := psqlOutputStateID(t, mgr, prepared, TaskInput{Kind: "image", ID: "image-1"})
+	task := taskState{PlanTask: PlanTask{TaskID: "execute-0", Type: "state_execute", OutputStateID: outputID, Input: &TaskInput{Kind: "image", ID: "image-1"}}}
+
+	if _, errResp := mgr.executeStateTask(context.Background(), "job-1", prepared, task); errResp == nil {
+		t.Fatalf("expected psql failure")
+	}
+	if len(psql.runs) != 1 || !hasPsqlSingleTransactionFlag(psql.runs[0].Args) {
+		t.Fatalf("expected one psql run with --single-transaction, got %+v", psql.runs)
+	}
+	if dbms.prepareCalls != 0 || len(snap.snapshotCalls) != 0 {
+		t.Fatalf("expected no snapshot after failed psql, got prepare=%d snapshots=%d", dbms.prepareCalls, len(snap.snapshotCalls))
+	}
+	if len(store.states) != 0 {
+		t.Fatalf("expected no state to be stored, got %+v", store.states)
+	}
+	paths, err := resolveStatePaths(mgr.stateStoreRoot, prepared.request.ImageID, outputID, mgr.statefs)
+	if err != nil {
+		t.Fatalf("resolveStatePaths: %v", err)
+	}
+	if stateBuildMarkerExists(paths.stateDir, snapshotKind(mgr.statefs)) {
+		t.Fatalf("expected no build marker in %s", filepath.Base(paths.stateDir))
+	}
+}
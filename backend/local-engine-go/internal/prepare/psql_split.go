@@ -0,0 +1,418 @@
+package prepare
+
+import "strings"
+
+// psqlCheckpointMarker is a line comment that lets a script choose its own
+// split points. When a script contains at least one marker, statement
+// boundaries are ignored and the script is cut only at markers.
+const psqlCheckpointMarker = "sqlrs:checkpoint"
+
+type psqlSplitCandidate struct {
+	pos     int
+	codeEnd int
+}
+
+// splitPsqlScript cuts an expanded psql script into chunks that can be run one
+// after another with the same effect as the whole script. Cuts are never
+// placed inside quoted text, comments, COPY ... FROM STDIN data, open
+// transaction blocks or \if blocks. Chunks holding only comments or
+// meta-commands are merged into the following chunk.
+func splitPsqlScript(content string) []string {
+	s := &psqlSplitScanner{src: content}
+	s.scan()
+	candidates := s.statements
+	if len(s.checkpoints) > 0 {
+		candidates = s.checkpoints
+	}
+	return s.cut(candidates)
+}
+
+// splitPsqlStatements cuts an expanded script at statement boundaries only,
+// ignoring checkpoint markers, for running it without psql. ok is false when
+// the script needs psql itself: it has backslash meta-commands or
+// COPY ... FROM STDIN data.
+func splitPsqlStatements(content string) (statements []string, ok bool) {
+	s := &psqlSplitScanner{src: content}
+	s.scan()
+	s.endStatement(true)
+	if s.hasMeta || s.hasCopyData {
+		return nil, false
+	}
+	return s.cut(s.statements), true
+}
+
+func (s *psqlSplitScanner) cut(candidates []psqlSplitCandidate) []string {
+	chunks := []string{}
+	prev := 0
+	for _, candidate := range candidates {
+		if candidate.codeEnd <= prev {
+			continue
+		}
+		chunks = append(chunks, s.src[prev:candidate.pos])
+		prev = candidate.pos
+	}
+	if s.codeEnd <= prev && len(chunks) > 0 {
+		chunks[len(chunks)-1] += s.src[prev:]
+	} else {
+		chunks = append(chunks, s.src[prev:])
+	}
+	return chunks
+}
+
+// psqlSplitScanner follows psql's own lexing closely enough to find the end of
+// each statement: quotes, nested comments, dollar quotes, parentheses and
+// BEGIN/CASE ... END bodies all suppress ';' as a terminator.
+type psqlSplitScanner struct {
+	src         string
+	pos         int
+	codeEnd     int
+	stmtHasCode bool
+	words       []string
+	prevWord    string
+	copyStdin   bool
+	parenDepth  int
+	beginDepth  int
+	ifDepth     int
+	inTx        bool
+	txControl   string
+	statements  []psqlSplitCandidate
+	checkpoints []psqlSplitCandidate
+	hasMeta     bool
+	hasCopyData bool
+}
+
+func (s *psqlSplitScanner) scan() {
+	for s.pos < len(s.src) {
+		c := s.src[s.pos]
+		switch {
+		case c == '-' && s.peek(1) == '-':
+			s.lineComment()
+		case c == '/' && s.peek(1) == '*':
+			s.blockComment()
+		case c == '\'':
+			s.quoted('\'', s.isEscapeString())
+		case c == '"':
+			s.quoted('"', false)
+		case c == '$' && s.dollarQuote():
+		case c == '\\':
+			s.metaCommand()
+		case c == ';' && s.parenDepth == 0 && s.beginDepth == 0:
+			s.pos++
+			s.markCode()
+			s.endStatement(false)
+		case isPsqlIdentStart(c):
+			s.word()
+		default:
+			switch c {
+			case '(':
+				s.parenDepth++
+			case ')':
+				if s.parenDepth > 0 {
+					s.parenDepth--
+				}
+			}
+			s.pos++
+			if !isPsqlSpace(c) {
+				s.markCode()
+			}
+		}
+	}
+}
+
+func (s *psqlSplitScanner) peek(offset int) byte {
+	if s.pos+offset >= len(s.src) {
+		return 0
+	}
+	return s.src[s.pos+offset]
+}
+
+func (s *psqlSplitScanner) markCode() {
+	s.codeEnd = s.pos
+	s.stmtHasCode = true
+}
+
+func (s *psqlSplitScanner) lineEnd(from int) int {
+	if idx := strings.IndexByte(s.src[from:], '\n'); idx >= 0 {
+		return from + idx
+	}
+	return len(s.src)
+}
+
+func (s *psqlSplitScanner) lineComment() {
+	end := s.lineEnd(s.pos)
+	if strings.TrimSpace(s.src[s.pos+2:end]) == psqlCheckpointMarker {
+		lineStart := strings.LastIndexByte(s.src[:s.pos], '\n') + 1
+		if strings.TrimSpace(s.src[lineStart:s.pos]) == "" && s.canCut() && !s.stmtHasCode {
+			s.checkpoints = append(s.checkpoints, psqlSplitCandidate{pos: lineStart, codeEnd: s.codeEnd})
+		}
+	}
+	s.pos = end
+}
+
+func (s *psqlSplitScanner) blockComment() {
+	depth := 0
+	for s.pos < len(s.src) {
+		switch {
+		case s.src[s.pos] == '/' && s.peek(1) == '*':
+			depth++
+			s.pos += 2
+		case s.src[s.pos] == '*' && s.peek(1) == '/':
+			depth--
+			s.pos += 2
+			if depth == 0 {
+				return
+			}
+		default:
+			s.pos++
+		}
+	}
+}
+
+// isEscapeString reports whether the quote at pos opens an E'...' literal, in
+// which backslashes escape the next character.
+func (s *psqlSplitScanner) isEscapeString() bool {
+	if s.pos == 0 || (s.src[s.pos-1] != 'E' && s.src[s.pos-1] != 'e') {
+		return false
+	}
+	return s.pos == 1 || !isPsqlIdentChar(s.src[s.pos-2])
+}
+
+func (s *psqlSplitScanner) quoted(quote byte, backslashEscapes bool) {
+	s.pos++
+	for s.pos < len(s.src) {
+		c := s.src[s.pos]
+		if backslashEscapes && c == '\\' {
+			s.pos += 2
+			continue
+		}
+		if c == quote {
+			if s.peek(1) == quote {
+				s.pos += 2
+				continue
+			}
+			s.pos++
+			break
+		}
+		s.pos++
+	}
+	if s.pos > len(s.src) {
+		s.pos = len(s.src)
+	}
+	s.markCode()
+}
+
+// dollarQuote consumes a $tag$ ... $tag$ string. Positional parameters such as
+// $1 are not quotes and are left to the caller.
+func (s *psqlSplitScanner) dollarQuote() bool {
+	end := s.pos + 1
+	for end < len(s.src) && isPsqlIdentChar(s.src[end]) && s.src[end] != '$' {
+		end++
+	}
+	if end >= len(s.src) || s.src[end] != '$' {
+		return false
+	}
+	if end > s.pos+1 && s.src[s.pos+1] >= '0' && s.src[s.pos+1] <= '9' {
+		return false
+	}
+	delimiter := s.src[s.pos : end+1]
+	body := end + 1
+	if idx := strings.Index(s.src[body:], delimiter); idx >= 0 {
+		s.pos = body + idx + len(delimiter)
+	} else {
+		s.pos = len(s.src)
+	}
+	s.markCode()
+	return true
+}
+
+// metaCommand consumes a backslash command up to the end of its line. Only the
+// \g family terminates the statement being built; other commands run on their
+// own and are not cut points.
+func (s *psqlSplitScanner) metaCommand() {
+	end := s.lineEnd(s.pos)
+	fields := strings.Fields(s.src[s.pos:end])
+	name := ""
+	if len(fields) > 0 {
+		name = fields[0]
+	}
+	s.hasMeta = true
+	switch {
+	case name == `\if`:
+		s.ifDepth++
+	case name == `\endif`:
+		if s.ifDepth > 0 {
+			s.ifDepth--
+		}
+	}
+	s.pos = end
+	if s.stmtHasCode && strings.HasPrefix(name, `\g`) {
+		if s.pos < len(s.src) {
+			s.pos++
+		}
+		s.codeEnd = s.pos
+		s.endStatement(true)
+	}
+}
+
+func (s *psqlSplitScanner) word() {
+	start := s.pos
+	for s.pos < len(s.src) && isPsqlIdentChar(s.src[s.pos]) {
+		s.pos++
+	}
+	word := strings.ToUpper(s.src[start:s.pos])
+	first := len(s.words) == 0
+	if len(s.words) < 2 {
+		s.words = append(s.words, word)
+	}
+	switch word {
+	case "BEGIN", "CASE":
+		if !first {
+			s.beginDepth++
+		}
+	case "END":
+		if s.beginDepth > 0 {
+			s.beginDepth--
+		}
+	case "STDIN":
+		if s.prevWord == "FROM" && s.words[0] == "COPY" {
+			s.copyStdin = true
+		}
+	}
+	s.prevWord = word
+	s.markCode()
+}
+
+func (s *psqlSplitScanner) canCut() bool {
+	return !s.inTx && s.ifDepth == 0
+}
+
+func (s *psqlSplitScanner) endStatement(atLineEnd bool) {
+	if !s.stmtHasCode {
+		return
+	}
+	s.updateTransaction()
+	cut := s.pos
+	switch {
+	case s.copyStdin:
+		s.hasCopyData = true
+		s.skipCopyData()
+		cut = s.pos
+	case !atLineEnd:
+		cut = s.lineTailEnd(s.pos)
+	}
+	if s.canCut() {
+		s.statements = append(s.statements, psqlSplitCandidate{pos: cut, codeEnd: s.codeEnd})
+	}
+	s.stmtHasCode = false
+	s.words = nil
+	s.prevWord = ""
+	s.copyStdin = false
+	s.parenDepth = 0
+	s.beginDepth = 0
+}
+
+func (s *psqlSplitScanner) updateTransaction() {
+	if len(s.words) == 0 {
+		return
+	}
+	second := ""
+	if len(s.words) > 1 {
+		second = s.words[1]
+	}
+	control := true
+	switch s.words[0] {
+	case "BEGIN":
+		s.inTx = true
+	case "START":
+		if second == "TRANSACTION" {
+			s.inTx = true
+		} else {
+			control = false
+		}
+	case "COMMIT", "END", "ABORT":
+		s.inTx = false
+	case "ROLLBACK":
+		if second != "TO" {
+			s.inTx = false
+		} else {
+			control = false
+		}
+	case "PREPARE":
+		if second == "TRANSACTION" {
+			s.inTx = false
+		} else {
+			control = false
+		}
+	default:
+		control = false
+	}
+	if control && s.txControl == "" {
+		s.txControl = strings.TrimSpace(strings.Join(s.words, " "))
+	}
+}
+
+// psqlTransactionControlStatement returns the leading words of the first
+// BEGIN/COMMIT-style statement in an expanded script, or "" if there is none.
+// Function bodies and quoted text are skipped like in splitPsqlScript.
+func psqlTransactionControlStatement(content string) string {
+	s := &psqlSplitScanner{src: content}
+	s.scan()
+	s.endStatement(true)
+	return s.txControl
+}
+
+// skipCopyData moves past the rows that follow COPY ... FROM STDIN, which end
+// with a line holding only "\.".
+func (s *psqlSplitScanner) skipCopyData() {
+	line := s.lineEnd(s.pos)
+	for line < len(s.src) {
+		start := line + 1
+		end := s.lineEnd(start)
+		if strings.TrimRight(s.src[start:end], "\r") == `\.` {
+			s.pos = end
+			if s.pos < len(s.src) {
+				s.pos++
+			}
+			s.codeEnd = s.pos
+			return
+		}
+		line = end
+	}
+	s.pos = len(s.src)
+	s.codeEnd = s.pos
+}
+
+// lineTailEnd extends a cut after ';' over trailing blanks, a trailing line
+// comment and the newline, so a statement keeps its own line.
+func (s *psqlSplitScanner) lineTailEnd(pos int) int {
+	end := pos
+	for end < len(s.src) && (s.src[end] == ' ' || s.src[end] == '\t' || s.src[end] == '\r') {
+		end++
+	}
+	if strings.HasPrefix(s.src[end:], "--") {
+		end = s.lineEnd(end)
+	}
+	if end < len(s.src) && s.src[end] == '\n' {
+		return end + 1
+	}
+	if end == len(s.src) {
+		return end
+	}
+	return pos
+}
+
+func isPsqlIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
+
+func isPsqlIdentChar(c byte) bool {
+	return isPsqlIdentStart(c) || (c >= '0' && c <= '9') || c == '$'
+}
+
+func isPsqlSpace(c byte) bool {
+	switch c {
+	case ' ', '\t', '\n', '\r', '\f', '\v':
+		return true
+	}
+	return false
+}
@@ -0,0 +1,193 @@
+package prepare
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSplitPsqlScriptStatements(t *testing.T) {
+	script := "-- schema\ncreate table a(id int); -- first\ncreate table b(id int);\n\ninsert into a values (1);"
+	chunks := splitPsqlScript(script)
+	want := []string{
+		"-- schema\ncreate table a(id int); -- first\n",
+		"create table b(id int);\n",
+		"\ninsert into a values (1);",
+	}
+	if strings.Join(chunks, "|") != strings.Join(want, "|") {
+		t.Fatalf("unexpected chunks: %q", chunks)
+	}
+	if strings.Join(chunks, "") != script {
+		t.Fatalf("expected chunks to cover the script")
+	}
+}
+
+func TestSplitPsqlScriptKeepsQuotedSemicolons(t *testing.T) {
+	script := strings.Join([]string{
+		"create function f() returns int as $body$ begin perform 1; return 2; end $body$ language plpgsql;",
+		"select 'a;b', E'c\\';d', \"x;y\" from t;",
+		"select $$;$$, $1;",
+		"/* outer /* inner; */ still; */ select 3;",
+		"create rule r as on insert to t do also (insert into u values (1); insert into u values (2));",
+		"create function g() returns int begin atomic select 1; select case when true then 2 end; end;",
+		"",
+	}, "\n")
+	chunks := splitPsqlScript(script)
+	if len(chunks) != 6 {
+		t.Fatalf("expected 6 chunks, got %d: %q", len(chunks), chunks)
+	}
+	if !strings.HasPrefix(chunks[3], "/* outer") {
+		t.Fatalf("expected nested comment to stay with its statement: %q", chunks[3])
+	}
+}
+
+func TestSplitPsqlScriptCopyFromStdin(t *testing.T) {
+	script := "create table t(v text);\ncopy t (v) from stdin;\na;b\n$$\n\\.\nselect count(*) from t;\n"
+	chunks := splitPsqlScript(script)
+	if len(chunks) != 3 {
+		t.Fatalf("expected 3 chunks, got %q", chunks)
+	}
+	if chunks[1] != "copy t (v) from stdin;\na;b\n$$\n\\.\n" {
+		t.Fatalf("expected copy data to stay with copy: %q", chunks[1])
+	}
+}
+
+func TestSplitPsqlScriptKeepsTransactionsAndIfBlocks(t *testing.T) {
+	script := "begin;\ninsert into t values (1);\ncommit;\n\\if :flag\nselect 1;\nselect 2;\n\\endif\nselect 3;\n"
+	chunks := splitPsqlScript(script)
+	want := []string{
+		"begin;\ninsert into t values (1);\ncommit;\n",
+		"\\if :flag\nselect 1;\nselect 2;\n\\endif\nselect 3;\n",
+	}
+	if strings.Join(chunks, "|") != strings.Join(want, "|") {
+		t.Fatalf("unexpected chunks: %q", chunks)
+	}
+}
+
+func TestSplitPsqlScriptMetaCommands(t *testing.T) {
+	script := "\\set x 1\nselect :x \\gset\nselect 2;\n\\echo done\n"
+	chunks := splitPsqlScript(script)
+	want := []string{
+		"\\set x 1\nselect :x \\gset\n",
+		"select 2;\n\\echo done\n",
+	}
+	if strings.Join(chunks, "|") != strings.Join(want, "|") {
+		t.Fatalf("unexpected chunks: %q", chunks)
+	}
+}
+
+func TestSplitPsqlScriptCheckpoints(t *testing.T) {
+	script := "create table a(id int);\ncreate table b(id int);\n-- sqlrs:checkpoint\ninsert into a values (1);\ninsert into b values (1);\n"
+	chunks := splitPsqlScript(script)
+	want := []string{
+		"create table a(id int);\ncreate table b(id int);\n",
+		"-- sqlrs:checkpoint\ninsert into a values (1);\ninsert into b values (1);\n",
+	}
+	if strings.Join(chunks, "|") != strings.Join(want, "|") {
+		t.Fatalf("unexpected chunks: %q", chunks)
+	}
+
+	inTx := "begin;\n-- sqlrs:checkpoint\ncommit;\n"
+	if chunks := splitPsqlScript(inTx); len(chunks) != 1 {
+		t.Fatalf("expected checkpoint inside transaction to be ignored, got %q", chunks)
+	}
+}
+
+func TestSplitPsqlScriptWithoutStatements(t *testing.T) {
+	if chunks := splitPsqlScript(""); len(chunks) != 1 || chunks[0] != "" {
+		t.Fatalf("expected single empty chunk, got %q", chunks)
+	}
+	if chunks := splitPsqlScript("-- nothing\n"); len(chunks) != 1 {
+		t.Fatalf("expected single chunk, got %q", chunks)
+	}
+}
+
+func TestBuildPlanPsqlSplitReusesPrefixStates(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "schema.sql")
+	writeTempFile(t, path, "create table a(id int);\ncreate table b(id int);\n")
+
+	mgr := newManager(t, &fakeStore{})
+	plan := func() []PlanTask {
+		prepared, err := mgr.prepareRequest(Request{
+			PrepareKind: "psql",
+			ImageID:     "image-1@sha256:resolved",
+			PsqlArgs:    []string{"-f", path},
+			PsqlSplit:   true,
+		})
+		if err != nil {
+			t.Fatalf("prepareRequest: %v", err)
+		}
+		tasks, _, errResp := mgr.buildPlan(context.Background(), "job-1", prepared)
+		if errResp != nil {
+			t.Fatalf("buildPlan: %+v", errResp)
+		}
+		return tasks
+	}
+
+	before := plan()
+	if len(before) != 4 {
+		t.Fatalf("expected plan, two execute tasks and prepare-instance, got %+v", before)
+	}
+	if err := os.WriteFile(path, []byte("create table a(id int);\ncreate table b(id int);\ncreate table c(id int);\n"), 0o600); err != nil {
+		t.Fatalf("rewrite script: %v", err)
+	}
+	after := plan()
+	if len(after) != 5 {
+		t.Fatalf("expected three execute tasks, got %+v", after)
+	}
+	for i := 1; i <= 2; i++ {
+		if before[i].TaskHash != after[i].TaskHash || before[i].OutputStateID != after[i].OutputStateID {
+			t.Fatalf("expected execute-%d to be reused, got %+v vs %+v", i-1, before[i], after[i])
+		}
+	}
+	if after[3].Input == nil || after[3].Input.ID != after[2].OutputStateID {
+		t.Fatalf("expected new statement to build on previous state, got %+v", after[3])
+	}
+}
+
+func TestPrepareRequestPsqlSplitOffKeepsSingleStep(t *testing.T) {
+	mgr := newManager(t, &fakeStore{})
+	script := "select 1;\nselect 2;\n"
+	prepared, err := mgr.prepareRequest(Request{
+		PrepareKind: "psql",
+		ImageID:     "image-1",
+		PsqlArgs:    []string{"-f", "-"},
+		Stdin:       &script,
+	})
+	if err != nil {
+		t.Fatalf("prepareRequest: %v", err)
+	}
+	if len(prepared.psqlSteps) != 1 {
+		t.Fatalf("expected single step without psql_split, got %d", len(prepared.psqlSteps))
+	}
+}
+
+func TestSubmitPsqlSplitRunsChunksThroughStdin(t *testing.T) {
+	psql := &fakePsqlRunner{}
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{psql: psql})
+	script := "select 1;\nselect 2;\n"
+	if _, err := mgr.Submit(context.Background(), Request{
+		PrepareKind: "psql",
+		ImageID:     "image-1@sha256:resolved",
+		PsqlArgs:    []string{"-f", "-"},
+		Stdin:       &script,
+		PsqlSplit:   true,
+	}); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	if len(psql.runs) != 2 {
+		t.Fatalf("expected two psql runs, got %+v", psql.runs)
+	}
+	for i, want := range []string{"select 1;\n", "select 2;\n"} {
+		run := psql.runs[i]
+		if run.Stdin == nil || *run.Stdin != want {
+			t.Fatalf("run %d: unexpected stdin %v", i, run.Stdin)
+		}
+		if !containsArg(run.Args, "-f") || !containsArg(run.Args, "-") {
+			t.Fatalf("run %d: expected -f - args, got %+v", i, run.Args)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package prepare
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseStatementTimeout validates Request.StatementTimeout; zero means "use
+// config".
+func parseStatementTimeout(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout < time.Millisecond {
+		return 0, ValidationError{Code: ErrorCodeInvalidArgument, Message: "statement_timeout must be a duration of at least 1ms", Details: value}
+	}
+	return timeout, nil
+}
+
+// psqlStatementTimeout resolves the statement_timeout for psql steps: the
+// request override wins, then prepare.psql.statementTimeout. Zero disables it.
+//
+// The timeout is environmental: a statement either finishes, producing the
+// same state as without the limit, or fails the job. It never affects
+// signatures or caching.
+func (m *PrepareService) psqlStatementTimeout(req Request) time.Duration {
+	if timeout, err := parseStatementTimeout(req.StatementTimeout); err == nil && timeout > 0 {
+		return timeout
+	}
+	if m.config == nil {
+		return 0
+	}
+	value, err := m.config.Get("prepare.psql.statementTimeout", true)
+	if err != nil || value == nil {
+		return 0
+	}
+	raw, ok := value.(string)
+	if !ok {
+		return 0
+	}
+	timeout, err := time.ParseDuration(strings.TrimSpace(raw))
+	if err != nil || timeout < 0 {
+		return 0
+	}
+	return timeout
+}
+
+// statementTimeoutSetting renders a timeout as a statement_timeout value in
+// milliseconds, rounding sub-millisecond values up so they stay enabled.
+func statementTimeoutSetting(timeout time.Duration) string {
+	ms := timeout.Milliseconds()
+	if ms < 1 {
+		ms = 1
+	}
+	return strconv.FormatInt(ms, 10)
+}
+
+// isStatementTimeoutError matches the error PostgreSQL raises when
+// statement_timeout cancels a statement (SQLSTATE 57014 shares its code with
+// user cancels, so the message is checked instead).
+func isStatementTimeoutError(details string) bool {
+	return strings.Contains(details, "canceling statement due to statement timeout")
+}
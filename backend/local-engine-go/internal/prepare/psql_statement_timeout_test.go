@@ -0,0 +1,152 @@
+package prepare
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	engineRuntime "github.com/sqlrs/engine-local/internal/runtime"
+)
+
+func TestPsqlStatementTimeoutResolution(t *testing.T) {
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{})
+	if got := mgr.psqlStatementTimeout(Request{}); got != 0 {
+		t.Fatalf("expected no statement timeout by default, got %s", got)
+	}
+
+	mgr = newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{
+		config: &fakeConfigStore{values: map[string]any{"prepare.psql.statementTimeout": "2m"}},
+	})
+	if got := mgr.psqlStatementTimeout(Request{}); got != 2*time.Minute {
+		t.Fatalf("expected configured timeout, got %s", got)
+	}
+	if got := mgr.psqlStatementTimeout(Request{StatementTimeout: "5s"}); got != 5*time.Second {
+		t.Fatalf("expected request override, got %s", got)
+	}
+
+	mgr = newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{
+		config: &fakeConfigStore{values: map[string]any{"prepare.psql.statementTimeout": "soon"}},
+	})
+	if got := mgr.psqlStatementTimeout(Request{}); got != 0 {
+		t.Fatalf("expected invalid config to leave the timeout unset, got %s", got)
+	}
+}
+
+func TestPrepareRequestValidatesStatementTimeout(t *testing.T) {
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{})
+	for _, value := range []string{"soon", "-1s", "0s", "500us"} {
+		_, err := mgr.prepareRequest(Request{
+			PrepareKind:      "psql",
+			ImageID:          "image-1",
+			PsqlArgs:         []string{"-c", "select 1"},
+			StatementTimeout: value,
+		})
+		var validation ValidationError
+		if !errors.As(err, &validation) || validation.Code != "invalid_argument" {
+			t.Fatalf("%s: expected invalid_argument, got %v", value, err)
+		}
+	}
+	_, err := mgr.prepareRequest(Request{
+		PrepareKind:      "lb",
+		ImageID:          "image-1",
+		LiquibaseArgs:    []string{"update"},
+		StatementTimeout: "30s",
+	})
+	var validation ValidationError
+	if !errors.As(err, &validation) || !strings.Contains(validation.Message, "only supported for psql") {
+		t.Fatalf("expected statement_timeout to be rejected for liquibase, got %v", err)
+	}
+}
+
+func TestStatementTimeoutDoesNotChangeSignature(t *testing.T) {
+	m := &PrepareService{}
+	base := Request{PrepareKind: "psql", ImageID: "image-1@sha256:abc", PsqlArgs: []string{"-c", "select 1"}}
+	limited := base
+	limited.StatementTimeout = "30s"
+	var signatures []string
+	for _, req := range []Request{base, limited} {
+		prepared, err := m.prepareRequest(req)
+		if err != nil {
+			t.Fatalf("prepareRequest: %v", err)
+		}
+		signature, errResp := m.computeJobSignature(prepared)
+		if errResp != nil {
+			t.Fatalf("computeJobSignature: %+v", errResp)
+		}
+		signatures = append(signatures, signature)
+	}
+	if signatures[0] != signatures[1] {
+		t.Fatalf("expected statement_timeout to be excluded from the signature")
+	}
+}
+
+func TestExecutePsqlStepSetsStatementTimeoutGUC(t *testing.T) {
+	psql := &fakePsqlRunner{}
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{
+		psql:   psql,
+		config: &fakeConfigStore{values: map[string]any{"prepare.psql.statementTimeout": "1m"}},
+	})
+	prepared, err := mgr.prepareRequest(Request{
+		PrepareKind:           "psql",
+		ImageID:               "image-1",
+		PsqlArgs:              []string{"-c", "create table t(id int)"},
+		PsqlSingleTransaction: true,
+		StatementTimeout:      "1500ms",
+	})
+	if err != nil {
+		t.Fatalf("prepareRequest: %v", err)
+	}
+	if errResp := mgr.executePsqlStep(context.Background(), "job-1", prepared, &jobRuntime{}, taskState{PlanTask: PlanTask{TaskID: "execute-0"}}); errResp != nil {
+		t.Fatalf("executePsqlStep: %+v", errResp)
+	}
+	if len(psql.runs) != 1 || psql.runs[0].StatementTimeout != 1500*time.Millisecond {
+		t.Fatalf("expected the request timeout to reach the runner, got %+v", psql.runs)
+	}
+	if !hasPsqlSingleTransactionFlag(psql.runs[0].Args) {
+		t.Fatalf("expected --single-transaction to be kept, got %v", psql.runs[0].Args)
+	}
+
+	rt := &fakeRuntime{}
+	if _, err := (containerPsqlRunner{runtime: rt}).Run(context.Background(), engineRuntime.Instance{ID: "c1"}, psql.runs[0]); err != nil {
+		t.Fatalf("containerPsqlRunner.Run: %v", err)
+	}
+	if len(rt.execCalls) != 1 {
+		t.Fatalf("expected one exec, got %+v", rt.execCalls)
+	}
+	call := rt.execCalls[0]
+	if got := call.Env["PGOPTIONS"]; got != "-c statement_timeout=1500" {
+		t.Fatalf("expected statement_timeout GUC in PGOPTIONS, got %q", got)
+	}
+	if !hasPsqlSingleTransactionFlag(call.Args) {
+		t.Fatalf("expected psql to run with --single-transaction, got %v", call.Args)
+	}
+	if _, ok := psql.runs[0].Env["PGOPTIONS"]; ok {
+		t.Fatalf("expected the runner request env to be left untouched")
+	}
+}
+
+func TestExecutePsqlStepStatementTimeoutIsDeadlineExceeded(t *testing.T) {
+	psql := &fakePsqlRunner{
+		output: "psql:/sql/slow.sql:1: ERROR:  canceling statement due to statement timeout",
+		err:    errors.New("exit status 3"),
+	}
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{psql: psql})
+	prepared, err := mgr.prepareRequest(Request{
+		PrepareKind:      "psql",
+		ImageID:          "image-1",
+		PsqlArgs:         []string{"-c", "select pg_sleep(10)"},
+		StatementTimeout: "100ms",
+	})
+	if err != nil {
+		t.Fatalf("prepareRequest: %v", err)
+	}
+	errResp := mgr.executePsqlStep(context.Background(), "job-1", prepared, &jobRuntime{}, taskState{PlanTask: PlanTask{TaskID: "execute-0"}})
+	if errResp == nil || errResp.Code != "deadline_exceeded" {
+		t.Fatalf("expected deadline_exceeded, got %+v", errResp)
+	}
+	if !strings.Contains(errResp.Details, "statement_timeout 100ms") {
+		t.Fatalf("expected timeout in details, got %q", errResp.Details)
+	}
+}
@@ -8,8 +8,11 @@ import (
 
 type psqlStep struct {
 	args   []string
+	shared []string
 	inputs []psqlInput
 	stdin  *string
+	// preamble holds the statements withPsqlPreamble put ahead of the script.
+	preamble []string
 }
 
 func buildPsqlSteps(args []string, stdin *string) ([]psqlStep, error) {
@@ -23,24 +26,12 @@ func buildPsqlSteps(args []string, stdin *string) ([]psqlStep, error) {
 			if i+1 >= len(args) {
 				return nil, fmt.Errorf("missing value for command flag: %s", arg)
 			}
-			cmd := args[i+1]
-			steps = append(steps, psqlStep{
-				args:   append(append([]string{}, shared...), "-c", cmd),
-				inputs: []psqlInput{{kind: "command", value: cmd}},
-			})
+			steps = append(steps, buildPsqlCommandStep(shared, args[i+1], stdin))
 			i++
 		case strings.HasPrefix(arg, "--command="):
-			cmd := strings.TrimPrefix(arg, "--command=")
-			steps = append(steps, psqlStep{
-				args:   append(append([]string{}, shared...), "-c", cmd),
-				inputs: []psqlInput{{kind: "command", value: cmd}},
-			})
+			steps = append(steps, buildPsqlCommandStep(shared, strings.TrimPrefix(arg, "--command="), stdin))
 		case strings.HasPrefix(arg, "-c") && len(arg) > 2:
-			cmd := arg[2:]
-			steps = append(steps, psqlStep{
-				args:   append(append([]string{}, shared...), "-c", cmd),
-				inputs: []psqlInput{{kind: "command", value: cmd}},
-			})
+			steps = append(steps, buildPsqlCommandStep(shared, arg[2:], stdin))
 		case arg == "-f" || arg == "--file":
 			if i+1 >= len(args) {
 				return nil, fmt.Errorf("missing value for file flag: %s", arg)
@@ -79,6 +70,21 @@ func buildPsqlSteps(args []string, stdin *string) ([]psqlStep, error) {
 	return steps, nil
 }
 
+// buildPsqlCommandStep builds the step for one -c command. A COPY ... FROM
+// STDIN command receives the request stdin as its rows.
+func buildPsqlCommandStep(shared []string, cmd string, stdin *string) psqlStep {
+	step := psqlStep{
+		args:   append(append([]string{}, shared...), "-c", cmd),
+		shared: append([]string{}, shared...),
+		inputs: []psqlInput{{kind: "command", value: cmd}},
+	}
+	if stdin != nil && isPsqlCopyFromStdin(cmd) {
+		step.inputs = append(step.inputs, psqlInput{kind: psqlInputCopyData, value: *stdin})
+		step.stdin = stdin
+	}
+	return step
+}
+
 func buildPsqlFileStep(shared []string, value string, stdin *string) (psqlStep, error) {
 	if value == "-" {
 		if stdin == nil {
@@ -86,6 +92,7 @@ func buildPsqlFileStep(shared []string, value string, stdin *string) (psqlStep,
 		}
 		return psqlStep{
 			args:   append(append([]string{}, shared...), "-f", "-"),
+			shared: append([]string{}, shared...),
 			inputs: []psqlInput{{kind: "stdin", value: *stdin}},
 			stdin:  stdin,
 		}, nil
@@ -95,10 +102,42 @@ func buildPsqlFileStep(shared []string, value string, stdin *string) (psqlStep,
 	}
 	return psqlStep{
 		args:   append(append([]string{}, shared...), "-f", value),
+		shared: append([]string{}, shared...),
 		inputs: []psqlInput{{kind: "file", value: value}},
 	}, nil
 }
 
+// splitPsqlSteps replaces every script step with one step per chunk of its
+// expanded content (see splitPsqlScript). Chunks are fed through stdin, and
+// each step's inputs repeat all earlier chunks of the same script so its
+// content hash covers the script up to that point. Commands passed with -c
+// run as a single query string and are kept whole.
+func splitPsqlSteps(steps []psqlStep, workDir string, limits psqlScriptLimits) ([]psqlStep, error) {
+	out := make([]psqlStep, 0, len(steps))
+	for _, step := range steps {
+		if len(step.inputs) == 0 || step.inputs[0].kind == "command" {
+			out = append(out, step)
+			continue
+		}
+		content, err := expandPsqlInputs(step.inputs, workDir, limits)
+		if err != nil {
+			return nil, err
+		}
+		var inputs []psqlInput
+		for _, chunk := range splitPsqlScript(content) {
+			chunk := chunk
+			inputs = append(inputs, psqlInput{kind: "stdin", value: chunk})
+			out = append(out, psqlStep{
+				args:   append(append([]string{}, step.shared...), "-f", "-"),
+				shared: append([]string{}, step.shared...),
+				inputs: append([]psqlInput{}, inputs...),
+				stdin:  &chunk,
+			})
+		}
+	}
+	return out, nil
+}
+
 func psqlStepForTask(steps []psqlStep, taskID string) (psqlStep, error) {
 	if len(steps) == 0 {
 		return psqlStep{}, fmt.Errorf("psql steps are required")
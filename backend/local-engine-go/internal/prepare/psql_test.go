@@ -9,7 +9,7 @@ import (
 
 func TestPreparePsqlArgsAddsDefaults(t *testing.T) {
 	path := writeTempSQL(t, "select 1;")
-	out, err := preparePsqlArgs([]string{"-f", path}, nil)
+	out, err := preparePsqlArgs([]string{"-f", path}, nil, psqlScriptLimits{})
 	if err != nil {
 		t.Fatalf("preparePsqlArgs: %v", err)
 	}
@@ -29,7 +29,7 @@ func TestPreparePsqlArgsAddsDefaults(t *testing.T) {
 
 func TestPreparePsqlArgsRespectsProvidedDefaults(t *testing.T) {
 	path := writeTempSQL(t, "select 1;")
-	out, err := preparePsqlArgs([]string{"-X", "-v", "ON_ERROR_STOP=1", "-f", path}, nil)
+	out, err := preparePsqlArgs([]string{"-X", "-v", "ON_ERROR_STOP=1", "-f", path}, nil, psqlScriptLimits{})
 	if err != nil {
 		t.Fatalf("preparePsqlArgs: %v", err)
 	}
@@ -50,22 +50,22 @@ func TestPreparePsqlArgsRejectsConnectionFlags(t *testing.T) {
 		"--database", "--database=test",
 	}
 	for _, flag := range flags {
-		_, err := preparePsqlArgs([]string{flag}, nil)
+		_, err := preparePsqlArgs([]string{flag}, nil, psqlScriptLimits{})
 		expectValidationError(t, err, "connection flags are not allowed")
 	}
 }
 
 func TestPreparePsqlArgsRejectsPositionalArgs(t *testing.T) {
-	_, err := preparePsqlArgs([]string{"db"}, nil)
+	_, err := preparePsqlArgs([]string{"db"}, nil, psqlScriptLimits{})
 	expectValidationError(t, err, "positional database arguments are not allowed")
 
-	_, err = preparePsqlArgs([]string{"--", "db"}, nil)
+	_, err = preparePsqlArgs([]string{"--", "db"}, nil, psqlScriptLimits{})
 	expectValidationError(t, err, "positional arguments are not allowed")
 }
 
 func TestPreparePsqlArgsHandlesStdin(t *testing.T) {
 	input := "select 1;"
-	out, err := preparePsqlArgs([]string{"-f", "-"}, &input)
+	out, err := preparePsqlArgs([]string{"-f", "-"}, &input, psqlScriptLimits{})
 	if err != nil {
 		t.Fatalf("preparePsqlArgs: %v", err)
 	}
@@ -73,30 +73,30 @@ func TestPreparePsqlArgsHandlesStdin(t *testing.T) {
 		t.Fatalf("expected stdin input, got %+v", out.inputs)
 	}
 
-	_, err = preparePsqlArgs([]string{"-f", "-"}, nil)
+	_, err = preparePsqlArgs([]string{"-f", "-"}, nil, psqlScriptLimits{})
 	expectValidationError(t, err, "stdin is required when using -f -")
 
 	path := writeTempSQL(t, "select 1;")
-	_, err = preparePsqlArgs([]string{"-f", path}, &input)
+	_, err = preparePsqlArgs([]string{"-f", path}, &input, psqlScriptLimits{})
 	expectValidationError(t, err, "stdin is only valid with -f -")
 }
 
 func TestPreparePsqlArgsRejectsInvalidFileFlag(t *testing.T) {
-	_, err := preparePsqlArgs([]string{"-f"}, nil)
+	_, err := preparePsqlArgs([]string{"-f"}, nil, psqlScriptLimits{})
 	expectValidationError(t, err, "missing value for file flag")
 
-	_, err = preparePsqlArgs([]string{"--file"}, nil)
+	_, err = preparePsqlArgs([]string{"--file"}, nil, psqlScriptLimits{})
 	expectValidationError(t, err, "missing value for file flag")
 
-	_, err = preparePsqlArgs([]string{"--file="}, nil)
+	_, err = preparePsqlArgs([]string{"--file="}, nil, psqlScriptLimits{})
 	expectValidationError(t, err, "missing value for file flag")
 
-	_, err = preparePsqlArgs([]string{"-f", "relative.sql"}, nil)
+	_, err = preparePsqlArgs([]string{"-f", "relative.sql"}, nil, psqlScriptLimits{})
 	expectValidationError(t, err, "file path must be absolute")
 }
 
 func TestPreparePsqlArgsHandlesCommandFlags(t *testing.T) {
-	out, err := preparePsqlArgs([]string{"-c", "select 1;"}, nil)
+	out, err := preparePsqlArgs([]string{"-c", "select 1;"}, nil, psqlScriptLimits{})
 	if err != nil {
 		t.Fatalf("preparePsqlArgs: %v", err)
 	}
@@ -104,10 +104,10 @@ func TestPreparePsqlArgsHandlesCommandFlags(t *testing.T) {
 		t.Fatalf("expected command input, got %+v", out.inputs)
 	}
 
-	_, err = preparePsqlArgs([]string{"-c"}, nil)
+	_, err = preparePsqlArgs([]string{"-c"}, nil, psqlScriptLimits{})
 	expectValidationError(t, err, "missing value for command flag")
 
-	out, err = preparePsqlArgs([]string{"-cselect 1;"}, nil)
+	out, err = preparePsqlArgs([]string{"-cselect 1;"}, nil, psqlScriptLimits{})
 	if err != nil {
 		t.Fatalf("preparePsqlArgs: %v", err)
 	}
@@ -115,7 +115,7 @@ func TestPreparePsqlArgsHandlesCommandFlags(t *testing.T) {
 		t.Fatalf("expected inline command input, got %+v", out.inputs)
 	}
 
-	out, err = preparePsqlArgs([]string{"--command=select 1;"}, nil)
+	out, err = preparePsqlArgs([]string{"--command=select 1;"}, nil, psqlScriptLimits{})
 	if err != nil {
 		t.Fatalf("preparePsqlArgs: %v", err)
 	}
@@ -125,60 +125,60 @@ func TestPreparePsqlArgsHandlesCommandFlags(t *testing.T) {
 }
 
 func TestPreparePsqlArgsHandlesVarFlags(t *testing.T) {
-	_, err := preparePsqlArgs([]string{"-v"}, nil)
+	_, err := preparePsqlArgs([]string{"-v"}, nil, psqlScriptLimits{})
 	expectValidationError(t, err, "missing value for variable flag")
 
-	_, err = preparePsqlArgs([]string{"--set"}, nil)
+	_, err = preparePsqlArgs([]string{"--set"}, nil, psqlScriptLimits{})
 	expectValidationError(t, err, "missing value for variable flag")
 
-	_, err = preparePsqlArgs([]string{"--variable"}, nil)
+	_, err = preparePsqlArgs([]string{"--variable"}, nil, psqlScriptLimits{})
 	expectValidationError(t, err, "missing value for variable flag")
 
-	_, err = preparePsqlArgs([]string{"-v", "ON_ERROR_STOP"}, nil)
+	_, err = preparePsqlArgs([]string{"-v", "ON_ERROR_STOP"}, nil, psqlScriptLimits{})
 	expectValidationError(t, err, "ON_ERROR_STOP must be set to 1")
 
-	_, err = preparePsqlArgs([]string{"-v", "FOO"}, nil)
+	_, err = preparePsqlArgs([]string{"-v", "FOO"}, nil, psqlScriptLimits{})
 	if err != nil {
 		t.Fatalf("preparePsqlArgs: %v", err)
 	}
 
-	_, err = preparePsqlArgs([]string{"-v", "ON_ERROR_STOP=0"}, nil)
+	_, err = preparePsqlArgs([]string{"-v", "ON_ERROR_STOP=0"}, nil, psqlScriptLimits{})
 	expectValidationError(t, err, "ON_ERROR_STOP must be set to 1")
 
-	_, err = preparePsqlArgs([]string{"-vON_ERROR_STOP=1"}, nil)
+	_, err = preparePsqlArgs([]string{"-vON_ERROR_STOP=1"}, nil, psqlScriptLimits{})
 	if err != nil {
 		t.Fatalf("preparePsqlArgs: %v", err)
 	}
 
-	_, err = preparePsqlArgs([]string{"--variable=ON_ERROR_STOP=1"}, nil)
+	_, err = preparePsqlArgs([]string{"--variable=ON_ERROR_STOP=1"}, nil, psqlScriptLimits{})
 	if err != nil {
 		t.Fatalf("preparePsqlArgs: %v", err)
 	}
 
-	_, err = preparePsqlArgs([]string{"--set=ON_ERROR_STOP=1"}, nil)
+	_, err = preparePsqlArgs([]string{"--set=ON_ERROR_STOP=1"}, nil, psqlScriptLimits{})
 	if err != nil {
 		t.Fatalf("preparePsqlArgs: %v", err)
 	}
 
-	_, err = preparePsqlArgs([]string{"--set=ON_ERROR_STOP=0"}, nil)
+	_, err = preparePsqlArgs([]string{"--set=ON_ERROR_STOP=0"}, nil, psqlScriptLimits{})
 	expectValidationError(t, err, "ON_ERROR_STOP must be set to 1")
 
-	_, err = preparePsqlArgs([]string{"--set", "FOO=bar"}, nil)
+	_, err = preparePsqlArgs([]string{"--set", "FOO=bar"}, nil, psqlScriptLimits{})
 	if err != nil {
 		t.Fatalf("preparePsqlArgs: %v", err)
 	}
 
-	_, err = preparePsqlArgs([]string{"--variable=ON_ERROR_STOP=0"}, nil)
+	_, err = preparePsqlArgs([]string{"--variable=ON_ERROR_STOP=0"}, nil, psqlScriptLimits{})
 	expectValidationError(t, err, "ON_ERROR_STOP must be set to 1")
 
-	_, err = preparePsqlArgs([]string{"--variable", "FOO=bar"}, nil)
+	_, err = preparePsqlArgs([]string{"--variable", "FOO=bar"}, nil, psqlScriptLimits{})
 	if err != nil {
 		t.Fatalf("preparePsqlArgs: %v", err)
 	}
 }
 
 func TestPreparePsqlArgsIgnoresMiscFlags(t *testing.T) {
-	out, err := preparePsqlArgs([]string{"", "-q"}, nil)
+	out, err := preparePsqlArgs([]string{"", "-q"}, nil, psqlScriptLimits{})
 	if err != nil {
 		t.Fatalf("preparePsqlArgs: %v", err)
 	}
@@ -191,7 +191,7 @@ func TestPreparePsqlArgsIgnoresMiscFlags(t *testing.T) {
 }
 
 func TestPreparePsqlArgsAllowsTerminator(t *testing.T) {
-	out, err := preparePsqlArgs([]string{"--"}, nil)
+	out, err := preparePsqlArgs([]string{"--"}, nil, psqlScriptLimits{})
 	if err != nil {
 		t.Fatalf("preparePsqlArgs: %v", err)
 	}
@@ -205,7 +205,7 @@ func TestPreparePsqlArgsAllowsTerminator(t *testing.T) {
 
 func TestPreparePsqlArgsHandlesInlineFileFlag(t *testing.T) {
 	path := writeTempSQL(t, "select 1;")
-	out, err := preparePsqlArgs([]string{"-f" + path}, nil)
+	out, err := preparePsqlArgs([]string{"-f" + path}, nil, psqlScriptLimits{})
 	if err != nil {
 		t.Fatalf("preparePsqlArgs: %v", err)
 	}
@@ -216,7 +216,7 @@ func TestPreparePsqlArgsHandlesInlineFileFlag(t *testing.T) {
 
 func TestPreparePsqlArgsHandlesLongFileFlag(t *testing.T) {
 	path := writeTempSQL(t, "select 1;")
-	out, err := preparePsqlArgs([]string{"--file", path}, nil)
+	out, err := preparePsqlArgs([]string{"--file", path}, nil, psqlScriptLimits{})
 	if err != nil {
 		t.Fatalf("preparePsqlArgs: %v", err)
 	}
@@ -227,7 +227,7 @@ func TestPreparePsqlArgsHandlesLongFileFlag(t *testing.T) {
 
 func TestPreparePsqlArgsHandlesFileFlagEquals(t *testing.T) {
 	path := writeTempSQL(t, "select 1;")
-	out, err := preparePsqlArgs([]string{"--file=" + path}, nil)
+	out, err := preparePsqlArgs([]string{"--file=" + path}, nil, psqlScriptLimits{})
 	if err != nil {
 		t.Fatalf("preparePsqlArgs: %v", err)
 	}
@@ -237,19 +237,19 @@ func TestPreparePsqlArgsHandlesFileFlagEquals(t *testing.T) {
 }
 
 func TestPreparePsqlArgsRejectsInlineRelativeFile(t *testing.T) {
-	_, err := preparePsqlArgs([]string{"-frelative.sql"}, nil)
+	_, err := preparePsqlArgs([]string{"-frelative.sql"}, nil, psqlScriptLimits{})
 	expectValidationError(t, err, "file path must be absolute")
 }
 
 func TestPreparePsqlArgsRejectsEmptyFilePath(t *testing.T) {
-	_, err := preparePsqlArgs([]string{"-f", ""}, nil)
+	_, err := preparePsqlArgs([]string{"-f", ""}, nil, psqlScriptLimits{})
 	expectValidationError(t, err, "file path is empty")
 }
 
 func TestPreparePsqlArgsRejectsUnreadableFile(t *testing.T) {
 	dir := t.TempDir()
 	path := filepath.Join(dir, "missing.sql")
-	_, err := preparePsqlArgs([]string{"-f", path}, nil)
+	_, err := preparePsqlArgs([]string{"-f", path}, nil, psqlScriptLimits{})
 	expectValidationError(t, err, "cannot read file")
 }
 
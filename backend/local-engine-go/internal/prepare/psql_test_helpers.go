@@ -4,12 +4,12 @@ import "testing"
 
 func psqlOutputStateID(t *testing.T, mgr *PrepareService, prepared preparedRequest, input TaskInput) string {
 	t.Helper()
-	digest, err := computePsqlContentDigest(prepared.psqlInputs, prepared.psqlWorkDir)
+	digest, err := computePsqlContentDigest(prepared.psqlInputs, prepared.psqlWorkDir, prepared.psqlLimits, prepared.psqlNormalizeHash)
 	if err != nil {
 		t.Fatalf("computePsqlContentDigest: %v", err)
 	}
 	taskHash := psqlTaskHash(prepared.request.PrepareKind, digest.hash, mgr.version)
-	outputID, _ := mgr.computeOutputStateID(input.Kind, input.ID, taskHash)
+	outputID, _ := mgr.computeOutputStateID("", input.Kind, input.ID, taskHash)
 	return outputID
 }
 
@@ -19,11 +19,11 @@ func psqlOutputStateIDForStep(t *testing.T, mgr *PrepareService, prepared prepar
 	if err != nil {
 		t.Fatalf("psqlStepForTask: %v", err)
 	}
-	digest, err := computePsqlContentDigest(step.inputs, prepared.psqlWorkDir)
+	digest, err := computePsqlContentDigest(step.inputs, prepared.psqlWorkDir, prepared.psqlLimits, prepared.psqlNormalizeHash)
 	if err != nil {
 		t.Fatalf("computePsqlContentDigest: %v", err)
 	}
 	taskHash := psqlTaskHash(prepared.request.PrepareKind, digest.hash, mgr.version)
-	outputID, _ := mgr.computeOutputStateID(input.Kind, input.ID, taskHash)
+	outputID, _ := mgr.computeOutputStateID("", input.Kind, input.ID, taskHash)
 	return outputID
 }
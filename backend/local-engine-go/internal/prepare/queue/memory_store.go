@@ -0,0 +1,551 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+var errMemoryStoreClosed = errors.New("queue store is closed")
+
+// MemoryStore is a Store kept in process memory, for embedding the prepare
+// service in tests without a database file. It mirrors SQLiteStore, including
+// its ordering, uniqueness and cascade rules; the shared conformance tests
+// hold both to the same behavior.
+type MemoryStore struct {
+	mu     sync.Mutex
+	closed bool
+	// jobs, tasks and events keep insertion order, which is how SQLite
+	// breaks ties in ORDER BY.
+	jobs    []JobRecord
+	tasks   []TaskRecord
+	events  []EventRecord
+	lastSeq int64
+	// generation advances after every write to jobs or tasks.
+	generation atomic.Uint64
+}
+
+// NewMemory returns an empty in-memory Store.
+func NewMemory() *MemoryStore {
+	return &MemoryStore{}
+}
+
+func (s *MemoryStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
+
+// Generation returns a counter that changes whenever jobs or tasks are
+// written, like SQLiteStore.Generation.
+func (s *MemoryStore) Generation() uint64 {
+	return s.generation.Load()
+}
+
+func (s *MemoryStore) CreateJob(ctx context.Context, job JobRecord) error {
+	defer s.generation.Add(1)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return errMemoryStoreClosed
+	}
+	for _, existing := range s.jobs {
+		if existing.JobID == job.JobID {
+			return fmt.Errorf("job %s already exists", job.JobID)
+		}
+		if job.IdempotencyKey != nil && existing.IdempotencyKey != nil && *existing.IdempotencyKey == *job.IdempotencyKey {
+			return fmt.Errorf("idempotency key %s is already used", *job.IdempotencyKey)
+		}
+	}
+	job = cloneJob(job)
+	job.SnapshotMode = jobSnapshotMode(job.SnapshotMode)
+	s.jobs = append(s.jobs, job)
+	return nil
+}
+
+func (s *MemoryStore) UpdateJob(ctx context.Context, jobID string, update JobUpdate) error {
+	defer s.generation.Add(1)
+	if jobID == "" {
+		return fmt.Errorf("job id is required")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return errMemoryStoreClosed
+	}
+	idx := s.jobIndex(jobID)
+	if idx < 0 {
+		return nil
+	}
+	job := &s.jobs[idx]
+	if update.Status != nil {
+		job.Status = *update.Status
+	}
+	if update.SnapshotMode != nil {
+		job.SnapshotMode = *update.SnapshotMode
+	}
+	setString(&job.PrepareArgsNormalized, update.PrepareArgsNormalized)
+	setString(&job.Signature, update.Signature)
+	setString(&job.RequestJSON, update.RequestJSON)
+	setString(&job.StartedAt, update.StartedAt)
+	setString(&job.FinishedAt, update.FinishedAt)
+	setString(&job.ResultJSON, update.ResultJSON)
+	setString(&job.ErrorJSON, update.ErrorJSON)
+	setString(&job.PlanJSON, update.PlanJSON)
+	if update.ResetOutcome {
+		job.FinishedAt = nil
+		job.ResultJSON = nil
+		job.ErrorJSON = nil
+	}
+	return nil
+}
+
+func (s *MemoryStore) GetJob(ctx context.Context, jobID string) (JobRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return JobRecord{}, false, errMemoryStoreClosed
+	}
+	idx := s.jobIndex(jobID)
+	if idx < 0 {
+		return JobRecord{}, false, nil
+	}
+	return cloneJob(s.jobs[idx]), true, nil
+}
+
+func (s *MemoryStore) GetJobByIdempotencyKey(ctx context.Context, key string) (JobRecord, bool, error) {
+	if strings.TrimSpace(key) == "" {
+		return JobRecord{}, false, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return JobRecord{}, false, errMemoryStoreClosed
+	}
+	for _, job := range s.jobs {
+		if job.IdempotencyKey != nil && *job.IdempotencyKey == key {
+			return cloneJob(job), true, nil
+		}
+	}
+	return JobRecord{}, false, nil
+}
+
+func (s *MemoryStore) ListJobs(ctx context.Context, jobID string) ([]JobRecord, error) {
+	prefix := strings.TrimSpace(jobID)
+	return s.listJobs(func(job JobRecord) (bool, error) {
+		return prefix == "" || hasPrefixFoldASCII(job.JobID, prefix), nil
+	}, byCreatedAt)
+}
+
+func (s *MemoryStore) ListJobsByStatus(ctx context.Context, statuses []string) ([]JobRecord, error) {
+	if len(statuses) == 0 {
+		return nil, nil
+	}
+	return s.listJobs(func(job JobRecord) (bool, error) {
+		return containsString(statuses, job.Status), nil
+	}, byCreatedAt)
+}
+
+func (s *MemoryStore) ListJobsBySignature(ctx context.Context, signature string, statuses []string) ([]JobRecord, error) {
+	if strings.TrimSpace(signature) == "" {
+		return nil, nil
+	}
+	return s.listJobs(func(job JobRecord) (bool, error) {
+		if job.Signature == nil || *job.Signature != signature {
+			return false, nil
+		}
+		return len(statuses) == 0 || containsString(statuses, job.Status), nil
+	}, byFinishedOrCreatedDesc)
+}
+
+// ListJobsByLabels returns jobs carrying every given label. An empty label
+// set matches all jobs.
+func (s *MemoryStore) ListJobsByLabels(ctx context.Context, labels map[string]string) ([]JobRecord, error) {
+	return s.listJobs(func(job JobRecord) (bool, error) {
+		if len(labels) == 0 {
+			return true, nil
+		}
+		if job.LabelsJSON == nil {
+			return false, nil
+		}
+		var stored map[string]any
+		if err := json.Unmarshal([]byte(*job.LabelsJSON), &stored); err != nil {
+			return false, fmt.Errorf("malformed labels_json for job %s: %w", job.JobID, err)
+		}
+		for key, want := range labels {
+			if got, ok := stored[key].(string); !ok || got != want {
+				return false, nil
+			}
+		}
+		return true, nil
+	}, byCreatedAt)
+}
+
+func (s *MemoryStore) DeleteJob(ctx context.Context, jobID string) error {
+	defer s.generation.Add(1)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return errMemoryStoreClosed
+	}
+	idx := s.jobIndex(jobID)
+	if idx < 0 {
+		return nil
+	}
+	s.jobs = append(s.jobs[:idx], s.jobs[idx+1:]...)
+	s.tasks = filterTasks(s.tasks, func(task TaskRecord) bool { return task.JobID != jobID })
+	s.events = filterEvents(s.events, func(event EventRecord) bool { return event.JobID != jobID })
+	return nil
+}
+
+// ReplaceTasks deletes the job's tasks and inserts tasks one by one under
+// their own JobID. Like SQLiteStore it is not atomic: rows inserted before a
+// failing one are kept.
+func (s *MemoryStore) ReplaceTasks(ctx context.Context, jobID string, tasks []TaskRecord) error {
+	defer s.generation.Add(1)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return errMemoryStoreClosed
+	}
+	s.tasks = filterTasks(s.tasks, func(task TaskRecord) bool { return task.JobID != jobID })
+	for _, task := range tasks {
+		if s.jobIndex(task.JobID) < 0 {
+			return fmt.Errorf("job %s does not exist", task.JobID)
+		}
+		if s.taskIndex(task.JobID, task.TaskID) >= 0 {
+			return fmt.Errorf("task %s/%s already exists", task.JobID, task.TaskID)
+		}
+		s.tasks = append(s.tasks, cloneTask(task))
+	}
+	return nil
+}
+
+func (s *MemoryStore) ListTasks(ctx context.Context, jobID string) ([]TaskRecord, error) {
+	jobID = strings.TrimSpace(jobID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, errMemoryStoreClosed
+	}
+	var out []TaskRecord
+	for _, task := range s.tasks {
+		if jobID == "" || task.JobID == jobID {
+			out = append(out, cloneTask(task))
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].JobID != out[j].JobID {
+			return out[i].JobID < out[j].JobID
+		}
+		return out[i].Position < out[j].Position
+	})
+	return out, nil
+}
+
+func (s *MemoryStore) UpdateTask(ctx context.Context, jobID string, taskID string, update TaskUpdate) error {
+	defer s.generation.Add(1)
+	if jobID == "" || taskID == "" {
+		return fmt.Errorf("job_id and task_id are required")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return errMemoryStoreClosed
+	}
+	idx := s.taskIndex(jobID, taskID)
+	if idx < 0 {
+		return nil
+	}
+	task := &s.tasks[idx]
+	if update.Status != nil {
+		task.Status = *update.Status
+	}
+	setString(&task.StartedAt, update.StartedAt)
+	setString(&task.FinishedAt, update.FinishedAt)
+	setString(&task.ErrorJSON, update.ErrorJSON)
+	setString(&task.TaskHash, update.TaskHash)
+	setString(&task.OutputStateID, update.OutputStateID)
+	if update.Cached != nil {
+		cached := *update.Cached
+		task.Cached = &cached
+	}
+	return nil
+}
+
+func (s *MemoryStore) AppendEvent(ctx context.Context, event EventRecord) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return 0, errMemoryStoreClosed
+	}
+	if s.jobIndex(event.JobID) < 0 {
+		return 0, fmt.Errorf("job %s does not exist", event.JobID)
+	}
+	// Seqs are never reused, like SQLite AUTOINCREMENT.
+	s.lastSeq++
+	event = cloneEvent(event)
+	event.Seq = s.lastSeq
+	s.events = append(s.events, event)
+	return event.Seq, nil
+}
+
+func (s *MemoryStore) ListEventsSince(ctx context.Context, jobID string, offset int) ([]EventRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, errMemoryStoreClosed
+	}
+	var out []EventRecord
+	skipped := 0
+	for _, event := range s.events {
+		if event.JobID != jobID {
+			continue
+		}
+		if skipped < offset {
+			skipped++
+			continue
+		}
+		out = append(out, cloneEvent(event))
+	}
+	return out, nil
+}
+
+func (s *MemoryStore) ListEventsAfterSeq(ctx context.Context, jobID string, seq int64) ([]EventRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, errMemoryStoreClosed
+	}
+	var out []EventRecord
+	for _, event := range s.events {
+		if event.JobID == jobID && event.Seq > seq {
+			out = append(out, cloneEvent(event))
+		}
+	}
+	return out, nil
+}
+
+func (s *MemoryStore) CountEvents(ctx context.Context, jobID string) (int, error) {
+	return s.countEvents(jobID, func(EventRecord) bool { return true })
+}
+
+func (s *MemoryStore) MaxEventSeq(ctx context.Context, jobID string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return 0, errMemoryStoreClosed
+	}
+	seq := int64(0)
+	for _, event := range s.events {
+		if event.JobID == jobID && event.Seq > seq {
+			seq = event.Seq
+		}
+	}
+	return seq, nil
+}
+
+func (s *MemoryStore) PruneEvents(ctx context.Context, jobID string, keepFrom int64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return 0, errMemoryStoreClosed
+	}
+	before := len(s.events)
+	s.events = filterEvents(s.events, func(event EventRecord) bool {
+		return event.JobID != jobID || event.Type != "log" || event.Seq >= keepFrom
+	})
+	return before - len(s.events), nil
+}
+
+func (s *MemoryStore) countEvents(jobID string, match func(EventRecord) bool) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return 0, errMemoryStoreClosed
+	}
+	count := 0
+	for _, event := range s.events {
+		if event.JobID == jobID && match(event) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *MemoryStore) listJobs(match func(JobRecord) (bool, error), less func(a, b JobRecord) bool) ([]JobRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, errMemoryStoreClosed
+	}
+	var out []JobRecord
+	for _, job := range s.jobs {
+		ok, err := match(job)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			out = append(out, cloneJob(job))
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return less(out[i], out[j]) })
+	return out, nil
+}
+
+func (s *MemoryStore) jobIndex(jobID string) int {
+	for i, job := range s.jobs {
+		if job.JobID == jobID {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *MemoryStore) taskIndex(jobID, taskID string) int {
+	for i, task := range s.tasks {
+		if task.JobID == jobID && task.TaskID == taskID {
+			return i
+		}
+	}
+	return -1
+}
+
+func byCreatedAt(a, b JobRecord) bool {
+	return a.CreatedAt < b.CreatedAt
+}
+
+func byFinishedOrCreatedDesc(a, b JobRecord) bool {
+	return finishedOrCreated(a) > finishedOrCreated(b)
+}
+
+func finishedOrCreated(job JobRecord) string {
+	if job.FinishedAt != nil {
+		return *job.FinishedAt
+	}
+	return job.CreatedAt
+}
+
+// hasPrefixFoldASCII matches SQLite LIKE 'prefix%', which ignores case for
+// ASCII letters only.
+func hasPrefixFoldASCII(value, prefix string) bool {
+	if len(value) < len(prefix) {
+		return false
+	}
+	for i := 0; i < len(prefix); i++ {
+		if lowerASCII(value[i]) != lowerASCII(prefix[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func lowerASCII(c byte) byte {
+	if c >= 'A' && c <= 'Z' {
+		return c + ('a' - 'A')
+	}
+	return c
+}
+
+func containsString(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
+
+func filterTasks(tasks []TaskRecord, keep func(TaskRecord) bool) []TaskRecord {
+	out := tasks[:0]
+	for _, task := range tasks {
+		if keep(task) {
+			out = append(out, task)
+		}
+	}
+	return out
+}
+
+func filterEvents(events []EventRecord, keep func(EventRecord) bool) []EventRecord {
+	out := events[:0]
+	for _, event := range events {
+		if keep(event) {
+			out = append(out, event)
+		}
+	}
+	return out
+}
+
+func setString(dst **string, value *string) {
+	if value != nil {
+		*dst = copyString(value)
+	}
+}
+
+func copyString(value *string) *string {
+	if value == nil {
+		return nil
+	}
+	out := *value
+	return &out
+}
+
+func copyBool(value *bool) *bool {
+	if value == nil {
+		return nil
+	}
+	out := *value
+	return &out
+}
+
+// cloneJob, cloneTask and cloneEvent copy pointer fields so callers never
+// share memory with the store, as with rows read from SQLite.
+func cloneJob(job JobRecord) JobRecord {
+	job.PrepareArgsNormalized = copyString(job.PrepareArgsNormalized)
+	job.Signature = copyString(job.Signature)
+	job.RequestJSON = copyString(job.RequestJSON)
+	job.IdempotencyKey = copyString(job.IdempotencyKey)
+	job.StartedAt = copyString(job.StartedAt)
+	job.FinishedAt = copyString(job.FinishedAt)
+	job.ResultJSON = copyString(job.ResultJSON)
+	job.ErrorJSON = copyString(job.ErrorJSON)
+	job.PlanJSON = copyString(job.PlanJSON)
+	job.LabelsJSON = copyString(job.LabelsJSON)
+	return job
+}
+
+func cloneTask(task TaskRecord) TaskRecord {
+	task.PlannerKind = copyString(task.PlannerKind)
+	task.InputKind = copyString(task.InputKind)
+	task.InputID = copyString(task.InputID)
+	task.ImageID = copyString(task.ImageID)
+	task.ResolvedImageID = copyString(task.ResolvedImageID)
+	task.TaskHash = copyString(task.TaskHash)
+	task.OutputStateID = copyString(task.OutputStateID)
+	task.Cached = copyBool(task.Cached)
+	task.InstanceMode = copyString(task.InstanceMode)
+	task.ChangesetID = copyString(task.ChangesetID)
+	task.ChangesetAuthor = copyString(task.ChangesetAuthor)
+	task.ChangesetPath = copyString(task.ChangesetPath)
+	task.StartedAt = copyString(task.StartedAt)
+	task.FinishedAt = copyString(task.FinishedAt)
+	task.ErrorJSON = copyString(task.ErrorJSON)
+	return task
+}
+
+func cloneEvent(event EventRecord) EventRecord {
+	event.Status = copyString(event.Status)
+	event.TaskID = copyString(event.TaskID)
+	event.Message = copyString(event.Message)
+	event.ResultJSON = copyString(event.ResultJSON)
+	event.ErrorJSON = copyString(event.ErrorJSON)
+	event.ProgressJSON = copyString(event.ProgressJSON)
+	event.PlanJSON = copyString(event.PlanJSON)
+	return event
+}
@@ -10,13 +10,17 @@ CREATE TABLE IF NOT EXISTS prepare_jobs (
   prepare_args_normalized TEXT,
   signature TEXT,
   request_json TEXT,
+  idempotency_key TEXT,
   created_at TEXT NOT NULL,
   started_at TEXT,
   finished_at TEXT,
   result_json TEXT,
-  error_json TEXT
+  error_json TEXT,
+  plan_json TEXT,
+  labels_json TEXT
 );
 CREATE INDEX IF NOT EXISTS idx_prepare_jobs_status ON prepare_jobs(status);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_prepare_jobs_idempotency_key ON prepare_jobs(idempotency_key);
 
 CREATE TABLE IF NOT EXISTS prepare_tasks (
   job_id TEXT NOT NULL,
@@ -56,6 +60,8 @@ CREATE TABLE IF NOT EXISTS prepare_events (
   message TEXT,
   result_json TEXT,
   error_json TEXT,
+  progress_json TEXT,
+  plan_json TEXT,
   FOREIGN KEY(job_id) REFERENCES prepare_jobs(job_id) ON DELETE CASCADE
 );
 CREATE INDEX IF NOT EXISTS idx_prepare_events_job_seq ON prepare_events(job_id, seq);
@@ -7,13 +7,17 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync/atomic"
 
 	_ "modernc.org/sqlite"
 )
 
 type SQLiteStore struct {
 	db *sql.DB
+	// generation advances after every write to jobs or tasks.
+	generation atomic.Uint64
 }
 
 var sqlOpenFn = sql.Open
@@ -53,10 +57,18 @@ func (s *SQLiteStore) Close() error {
 	return s.db.Close()
 }
 
+// Generation returns a counter that changes whenever jobs or tasks are
+// written. List handlers use it to answer conditional requests without
+// reading the tables. It is not persisted.
+func (s *SQLiteStore) Generation() uint64 {
+	return s.generation.Load()
+}
+
 func (s *SQLiteStore) CreateJob(ctx context.Context, job JobRecord) error {
+	defer s.generation.Add(1)
 	query := `
-INSERT INTO prepare_jobs (job_id, status, prepare_kind, image_id, plan_only, snapshot_mode, prepare_args_normalized, signature, request_json, created_at, started_at, finished_at, result_json, error_json)
-VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+INSERT INTO prepare_jobs (job_id, status, prepare_kind, image_id, plan_only, snapshot_mode, prepare_args_normalized, signature, request_json, idempotency_key, created_at, started_at, finished_at, result_json, error_json, plan_json, labels_json)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 	_, err := s.db.ExecContext(ctx, query,
 		job.JobID,
 		job.Status,
@@ -67,16 +79,20 @@ VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 		nullString(job.PrepareArgsNormalized),
 		nullString(job.Signature),
 		nullString(job.RequestJSON),
+		nullString(job.IdempotencyKey),
 		job.CreatedAt,
 		nullString(job.StartedAt),
 		nullString(job.FinishedAt),
 		nullString(job.ResultJSON),
 		nullString(job.ErrorJSON),
+		nullString(job.PlanJSON),
+		nullString(job.LabelsJSON),
 	)
 	return err
 }
 
 func (s *SQLiteStore) UpdateJob(ctx context.Context, jobID string, update JobUpdate) error {
+	defer s.generation.Add(1)
 	if jobID == "" {
 		return fmt.Errorf("job id is required")
 	}
@@ -118,6 +134,13 @@ func (s *SQLiteStore) UpdateJob(ctx context.Context, jobID string, update JobUpd
 		sets = append(sets, "error_json = ?")
 		args = append(args, *update.ErrorJSON)
 	}
+	if update.PlanJSON != nil {
+		sets = append(sets, "plan_json = ?")
+		args = append(args, *update.PlanJSON)
+	}
+	if update.ResetOutcome {
+		sets = append(sets, "finished_at = NULL", "result_json = NULL", "error_json = NULL")
+	}
 	if len(sets) == 0 {
 		return nil
 	}
@@ -130,7 +153,7 @@ func (s *SQLiteStore) UpdateJob(ctx context.Context, jobID string, update JobUpd
 func (s *SQLiteStore) GetJob(ctx context.Context, jobID string) (JobRecord, bool, error) {
 	query := `
 SELECT job_id, status, prepare_kind, image_id, plan_only, snapshot_mode, prepare_args_normalized, signature, request_json,
-       created_at, started_at, finished_at, result_json, error_json
+       idempotency_key, created_at, started_at, finished_at, result_json, error_json, plan_json, labels_json
 FROM prepare_jobs
 WHERE job_id = ?`
 	row := s.db.QueryRowContext(ctx, query, jobID)
@@ -144,11 +167,31 @@ WHERE job_id = ?`
 	return record, true, nil
 }
 
+func (s *SQLiteStore) GetJobByIdempotencyKey(ctx context.Context, key string) (JobRecord, bool, error) {
+	if strings.TrimSpace(key) == "" {
+		return JobRecord{}, false, nil
+	}
+	query := `
+SELECT job_id, status, prepare_kind, image_id, plan_only, snapshot_mode, prepare_args_normalized, signature, request_json,
+       idempotency_key, created_at, started_at, finished_at, result_json, error_json, plan_json, labels_json
+FROM prepare_jobs
+WHERE idempotency_key = ?`
+	row := s.db.QueryRowContext(ctx, query, key)
+	record, err := scanJob(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return JobRecord{}, false, nil
+		}
+		return JobRecord{}, false, err
+	}
+	return record, true, nil
+}
+
 func (s *SQLiteStore) ListJobs(ctx context.Context, jobID string) ([]JobRecord, error) {
 	query := strings.Builder{}
 	query.WriteString(`
 SELECT job_id, status, prepare_kind, image_id, plan_only, snapshot_mode, prepare_args_normalized, signature, request_json,
-       created_at, started_at, finished_at, result_json, error_json
+       idempotency_key, created_at, started_at, finished_at, result_json, error_json, plan_json, labels_json
 FROM prepare_jobs
 WHERE 1=1`)
 	args := []any{}
@@ -181,7 +224,7 @@ func (s *SQLiteStore) ListJobsByStatus(ctx context.Context, statuses []string) (
 	query := strings.Builder{}
 	query.WriteString(`
 SELECT job_id, status, prepare_kind, image_id, plan_only, snapshot_mode, prepare_args_normalized, signature, request_json,
-       created_at, started_at, finished_at, result_json, error_json
+       idempotency_key, created_at, started_at, finished_at, result_json, error_json, plan_json, labels_json
 FROM prepare_jobs
 WHERE status IN (`)
 	args := []any{}
@@ -220,7 +263,7 @@ func (s *SQLiteStore) ListJobsBySignature(ctx context.Context, signature string,
 	query := strings.Builder{}
 	query.WriteString(`
 SELECT job_id, status, prepare_kind, image_id, plan_only, snapshot_mode, prepare_args_normalized, signature, request_json,
-       created_at, started_at, finished_at, result_json, error_json
+       idempotency_key, created_at, started_at, finished_at, result_json, error_json, plan_json, labels_json
 FROM prepare_jobs
 WHERE signature = ?`)
 	args := []any{signature}
@@ -256,12 +299,58 @@ WHERE signature = ?`)
 	return out, nil
 }
 
+// ListJobsByLabels returns jobs carrying every given label. An empty label
+// set matches all jobs.
+func (s *SQLiteStore) ListJobsByLabels(ctx context.Context, labels map[string]string) ([]JobRecord, error) {
+	query := strings.Builder{}
+	query.WriteString(`
+SELECT job_id, status, prepare_kind, image_id, plan_only, snapshot_mode, prepare_args_normalized, signature, request_json,
+       idempotency_key, created_at, started_at, finished_at, result_json, error_json, plan_json, labels_json
+FROM prepare_jobs
+WHERE 1=1`)
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	args := []any{}
+	for _, key := range keys {
+		query.WriteString(" AND json_extract(labels_json, ?) = ?")
+		args = append(args, labelJSONPath(key), labels[key])
+	}
+	query.WriteString(" ORDER BY created_at")
+	rows, err := s.db.QueryContext(ctx, query.String(), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []JobRecord
+	for rows.Next() {
+		record, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func labelJSONPath(key string) string {
+	return `$."` + strings.ReplaceAll(strings.ReplaceAll(key, `\`, `\\`), `"`, `\"`) + `"`
+}
+
 func (s *SQLiteStore) DeleteJob(ctx context.Context, jobID string) error {
+	defer s.generation.Add(1)
 	_, err := s.db.ExecContext(ctx, `DELETE FROM prepare_jobs WHERE job_id = ?`, jobID)
 	return err
 }
 
 func (s *SQLiteStore) ReplaceTasks(ctx context.Context, jobID string, tasks []TaskRecord) error {
+	defer s.generation.Add(1)
 	if _, err := s.db.ExecContext(ctx, `DELETE FROM prepare_tasks WHERE job_id = ?`, jobID); err != nil {
 		return err
 	}
@@ -331,6 +420,7 @@ WHERE 1=1`)
 }
 
 func (s *SQLiteStore) UpdateTask(ctx context.Context, jobID string, taskID string, update TaskUpdate) error {
+	defer s.generation.Add(1)
 	if jobID == "" || taskID == "" {
 		return fmt.Errorf("job_id and task_id are required")
 	}
@@ -379,8 +469,8 @@ func (s *SQLiteStore) UpdateTask(ctx context.Context, jobID string, taskID strin
 
 func (s *SQLiteStore) AppendEvent(ctx context.Context, event EventRecord) (int64, error) {
 	query := `
-INSERT INTO prepare_events (job_id, type, ts, status, task_id, message, result_json, error_json)
-VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+INSERT INTO prepare_events (job_id, type, ts, status, task_id, message, result_json, error_json, progress_json, plan_json)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 	result, err := s.db.ExecContext(ctx, query,
 		event.JobID,
 		event.Type,
@@ -390,6 +480,8 @@ VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
 		nullString(event.Message),
 		nullString(event.ResultJSON),
 		nullString(event.ErrorJSON),
+		nullString(event.ProgressJSON),
+		nullString(event.PlanJSON),
 	)
 	if err != nil {
 		return 0, err
@@ -399,7 +491,7 @@ VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
 
 func (s *SQLiteStore) ListEventsSince(ctx context.Context, jobID string, offset int) ([]EventRecord, error) {
 	query := `
-SELECT seq, job_id, type, ts, status, task_id, message, result_json, error_json
+SELECT seq, job_id, type, ts, status, task_id, message, result_json, error_json, progress_json, plan_json
 FROM prepare_events
 WHERE job_id = ?
 ORDER BY seq
@@ -424,6 +516,32 @@ LIMIT -1 OFFSET ?`
 	return out, nil
 }
 
+func (s *SQLiteStore) ListEventsAfterSeq(ctx context.Context, jobID string, seq int64) ([]EventRecord, error) {
+	query := `
+SELECT seq, job_id, type, ts, status, task_id, message, result_json, error_json, progress_json, plan_json
+FROM prepare_events
+WHERE job_id = ? AND seq > ?
+ORDER BY seq`
+	rows, err := s.db.QueryContext(ctx, query, jobID, seq)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []EventRecord
+	for rows.Next() {
+		record, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (s *SQLiteStore) CountEvents(ctx context.Context, jobID string) (int, error) {
 	row := s.db.QueryRowContext(ctx, `SELECT COUNT(1) FROM prepare_events WHERE job_id = ?`, jobID)
 	var count int
@@ -433,6 +551,27 @@ func (s *SQLiteStore) CountEvents(ctx context.Context, jobID string) (int, error
 	return count, nil
 }
 
+func (s *SQLiteStore) MaxEventSeq(ctx context.Context, jobID string) (int64, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(seq), 0) FROM prepare_events WHERE job_id = ?`, jobID)
+	var seq int64
+	if err := row.Scan(&seq); err != nil {
+		return 0, err
+	}
+	return seq, nil
+}
+
+func (s *SQLiteStore) PruneEvents(ctx context.Context, jobID string, keepFrom int64) (int, error) {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM prepare_events WHERE job_id = ? AND type = 'log' AND seq < ?`, jobID, keepFrom)
+	if err != nil {
+		return 0, err
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(deleted), nil
+}
+
 func initDB(db *sql.DB) error {
 	db.SetMaxOpenConns(1)
 	db.SetMaxIdleConns(1)
@@ -448,6 +587,21 @@ func initDB(db *sql.DB) error {
 	if err := ensureJobSignatureColumn(db); err != nil {
 		return err
 	}
+	if err := ensureJobIdempotencyKeyColumn(db); err != nil {
+		return err
+	}
+	if err := ensureJobPlanJSONColumn(db); err != nil {
+		return err
+	}
+	if err := ensureJobLabelsJSONColumn(db); err != nil {
+		return err
+	}
+	if err := ensureEventProgressJSONColumn(db); err != nil {
+		return err
+	}
+	if err := ensureEventPlanJSONColumn(db); err != nil {
+		return err
+	}
 	_, err := db.Exec(SchemaSQL())
 	return err
 }
@@ -514,6 +668,66 @@ func ensureJobSignatureColumn(db *sql.DB) error {
 	return nil
 }
 
+func ensureJobIdempotencyKeyColumn(db *sql.DB) error {
+	if _, err := db.Exec("ALTER TABLE prepare_jobs ADD COLUMN idempotency_key TEXT"); err != nil {
+		if strings.Contains(err.Error(), "duplicate column name") {
+			return nil
+		} else if strings.Contains(err.Error(), "no such table") {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+func ensureJobPlanJSONColumn(db *sql.DB) error {
+	if _, err := db.Exec("ALTER TABLE prepare_jobs ADD COLUMN plan_json TEXT"); err != nil {
+		if strings.Contains(err.Error(), "duplicate column name") {
+			return nil
+		} else if strings.Contains(err.Error(), "no such table") {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+func ensureJobLabelsJSONColumn(db *sql.DB) error {
+	if _, err := db.Exec("ALTER TABLE prepare_jobs ADD COLUMN labels_json TEXT"); err != nil {
+		if strings.Contains(err.Error(), "duplicate column name") {
+			return nil
+		} else if strings.Contains(err.Error(), "no such table") {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+func ensureEventProgressJSONColumn(db *sql.DB) error {
+	if _, err := db.Exec("ALTER TABLE prepare_events ADD COLUMN progress_json TEXT"); err != nil {
+		if strings.Contains(err.Error(), "duplicate column name") {
+			return nil
+		} else if strings.Contains(err.Error(), "no such table") {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+func ensureEventPlanJSONColumn(db *sql.DB) error {
+	if _, err := db.Exec("ALTER TABLE prepare_events ADD COLUMN plan_json TEXT"); err != nil {
+		if strings.Contains(err.Error(), "duplicate column name") {
+			return nil
+		} else if strings.Contains(err.Error(), "no such table") {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
 func scanJob(scanner interface {
 	Scan(dest ...any) error
 }) (JobRecord, error) {
@@ -523,10 +737,13 @@ func scanJob(scanner interface {
 	var argsNormalized sql.NullString
 	var signature sql.NullString
 	var requestJSON sql.NullString
+	var idempotencyKey sql.NullString
 	var startedAt sql.NullString
 	var finishedAt sql.NullString
 	var resultJSON sql.NullString
 	var errorJSON sql.NullString
+	var planJSON sql.NullString
+	var labelsJSON sql.NullString
 	if err := scanner.Scan(
 		&record.JobID,
 		&record.Status,
@@ -537,11 +754,14 @@ func scanJob(scanner interface {
 		&argsNormalized,
 		&signature,
 		&requestJSON,
+		&idempotencyKey,
 		&record.CreatedAt,
 		&startedAt,
 		&finishedAt,
 		&resultJSON,
 		&errorJSON,
+		&planJSON,
+		&labelsJSON,
 	); err != nil {
 		return JobRecord{}, err
 	}
@@ -550,10 +770,13 @@ func scanJob(scanner interface {
 	record.PrepareArgsNormalized = strPtr(argsNormalized)
 	record.Signature = strPtr(signature)
 	record.RequestJSON = strPtr(requestJSON)
+	record.IdempotencyKey = strPtr(idempotencyKey)
 	record.StartedAt = strPtr(startedAt)
 	record.FinishedAt = strPtr(finishedAt)
 	record.ResultJSON = strPtr(resultJSON)
 	record.ErrorJSON = strPtr(errorJSON)
+	record.PlanJSON = strPtr(planJSON)
+	record.LabelsJSON = strPtr(labelsJSON)
 	return record, nil
 }
 
@@ -627,6 +850,8 @@ func scanEvent(scanner interface {
 	var message sql.NullString
 	var resultJSON sql.NullString
 	var errorJSON sql.NullString
+	var progressJSON sql.NullString
+	var planJSON sql.NullString
 	if err := scanner.Scan(
 		&record.Seq,
 		&record.JobID,
@@ -637,6 +862,8 @@ func scanEvent(scanner interface {
 		&message,
 		&resultJSON,
 		&errorJSON,
+		&progressJSON,
+		&planJSON,
 	); err != nil {
 		return EventRecord{}, err
 	}
@@ -645,6 +872,8 @@ func scanEvent(scanner interface {
 	record.Message = strPtr(message)
 	record.ResultJSON = strPtr(resultJSON)
 	record.ErrorJSON = strPtr(errorJSON)
+	record.ProgressJSON = strPtr(progressJSON)
+	record.PlanJSON = strPtr(planJSON)
 	return record, nil
 }
 
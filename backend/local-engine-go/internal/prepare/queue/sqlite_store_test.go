@@ -96,10 +96,12 @@ func TestSQLiteStoreJobTaskEventRoundTrip(t *testing.T) {
 	}
 
 	if _, err := store.AppendEvent(context.Background(), EventRecord{
-		JobID:  "job-1",
-		Type:   "status",
-		Ts:     "2026-01-19T00:02:30Z",
-		Status: stringPtr("running"),
+		JobID:        "job-1",
+		Type:         "status",
+		Ts:           "2026-01-19T00:02:30Z",
+		Status:       stringPtr("running"),
+		ProgressJSON: stringPtr(`{"completed":1,"total":2}`),
+		PlanJSON:     stringPtr(`[{"task_id":"plan"}]`),
 	}); err != nil {
 		t.Fatalf("AppendEvent: %v", err)
 	}
@@ -108,7 +110,7 @@ func TestSQLiteStoreJobTaskEventRoundTrip(t *testing.T) {
 	if err != nil {
 		t.Fatalf("ListEventsSince: %v", err)
 	}
-	if len(events) != 1 || events[0].Status == nil {
+	if len(events) != 1 || events[0].Status == nil || events[0].ProgressJSON == nil || events[0].PlanJSON == nil {
 		t.Fatalf("unexpected events: %+v", events)
 	}
 
@@ -121,6 +123,38 @@ func TestSQLiteStoreJobTaskEventRoundTrip(t *testing.T) {
 	}
 }
 
+func TestSQLiteStoreMaxEventSeq(t *testing.T) {
+	store := newQueueStore(t)
+	ctx := context.Background()
+	for _, jobID := range []string{"job-1", "job-2"} {
+		if err := store.CreateJob(ctx, JobRecord{JobID: jobID, Status: "queued", PrepareKind: "psql", ImageID: "image-1", CreatedAt: "2026-01-19T00:00:00Z"}); err != nil {
+			t.Fatalf("CreateJob: %v", err)
+		}
+	}
+	if seq, err := store.MaxEventSeq(ctx, "job-1"); err != nil || seq != 0 {
+		t.Fatalf("expected seq 0 without events, got %d err=%v", seq, err)
+	}
+	seqs := map[string][]int64{}
+	for _, jobID := range []string{"job-1", "job-1", "job-2"} {
+		seq, err := store.AppendEvent(ctx, EventRecord{JobID: jobID, Type: "log", Ts: "2026-01-19T00:00:01Z"})
+		if err != nil {
+			t.Fatalf("AppendEvent: %v", err)
+		}
+		seqs[jobID] = append(seqs[jobID], seq)
+	}
+	if seq, err := store.MaxEventSeq(ctx, "job-1"); err != nil || seq != seqs["job-1"][1] {
+		t.Fatalf("expected seq %d, got %d err=%v", seqs["job-1"][1], seq, err)
+	}
+
+	// Pruning older events leaves the latest seq where it was.
+	if _, err := store.PruneEvents(ctx, "job-1", seqs["job-1"][1]); err != nil {
+		t.Fatalf("PruneEvents: %v", err)
+	}
+	if seq, err := store.MaxEventSeq(ctx, "job-1"); err != nil || seq != seqs["job-1"][1] {
+		t.Fatalf("expected seq %d after prune, got %d err=%v", seqs["job-1"][1], seq, err)
+	}
+}
+
 func TestSQLiteStoreListJobsByStatus(t *testing.T) {
 	store := newQueueStore(t)
 
@@ -268,6 +302,7 @@ func TestSQLiteStoreUpdateJobAllFields(t *testing.T) {
 	finished := "2026-01-19T00:02:00Z"
 	resultJSON := `{"ok":true}`
 	errorJSON := `{"err":"fail"}`
+	planJSON := `{"changesets":[]}`
 	if err := store.UpdateJob(context.Background(), "job-1", JobUpdate{
 		Status:                &status,
 		SnapshotMode:          &snapshotMode,
@@ -278,6 +313,7 @@ func TestSQLiteStoreUpdateJobAllFields(t *testing.T) {
 		FinishedAt:            &finished,
 		ResultJSON:            &resultJSON,
 		ErrorJSON:             &errorJSON,
+		PlanJSON:              &planJSON,
 	}); err != nil {
 		t.Fatalf("UpdateJob: %v", err)
 	}
@@ -288,6 +324,9 @@ func TestSQLiteStoreUpdateJobAllFields(t *testing.T) {
 	if record.Signature == nil || *record.Signature != signature {
 		t.Fatalf("unexpected signature: %+v", record.Signature)
 	}
+	if record.PlanJSON == nil || *record.PlanJSON != planJSON {
+		t.Fatalf("unexpected plan json: %+v", record.PlanJSON)
+	}
 }
 
 func TestSQLiteStoreReplaceTasksEmpty(t *testing.T) {
@@ -322,6 +361,20 @@ func TestEnsureJobSignatureColumn(t *testing.T) {
 	}
 }
 
+func TestEnsureJobPlanJSONColumn(t *testing.T) {
+	db := openMemoryDB(t)
+	execSQL(t, db, `CREATE TABLE prepare_jobs (job_id TEXT)`)
+	if err := ensureJobPlanJSONColumn(db); err != nil {
+		t.Fatalf("ensureJobPlanJSONColumn: %v", err)
+	}
+	if !hasColumn(t, db, "prepare_jobs", "plan_json") {
+		t.Fatalf("expected plan_json column added")
+	}
+	if err := ensureJobPlanJSONColumn(db); err != nil {
+		t.Fatalf("expected duplicate column to be ignored: %v", err)
+	}
+}
+
 func openMemoryDB(t *testing.T) *sql.DB {
 	t.Helper()
 	db, err := sql.Open("sqlite", ":memory:")
@@ -679,6 +732,21 @@ func TestInitDBPropagatesMigrationErrors(t *testing.T) {
 		errors.New("duplicate column name: changeset_author"),
 		errors.New("duplicate column name: changeset_path"),
 		errors.New("duplicate column name: signature"),
+		errors.New("idempotency key migration failed"),
+	})
+	if err := initDB(db); err == nil {
+		t.Fatalf("expected idempotency key migration error")
+	}
+
+	db = openErrorDB(t, []error{
+		nil,
+		errors.New("duplicate column name: image_id"),
+		errors.New("duplicate column name: resolved_image_id"),
+		errors.New("duplicate column name: changeset_id"),
+		errors.New("duplicate column name: changeset_author"),
+		errors.New("duplicate column name: changeset_path"),
+		errors.New("duplicate column name: signature"),
+		errors.New("duplicate column name: idempotency_key"),
 		errors.New("schema apply failed"),
 	})
 	if err := initDB(db); err == nil {
@@ -686,6 +754,82 @@ func TestInitDBPropagatesMigrationErrors(t *testing.T) {
 	}
 }
 
+func TestEnsureJobIdempotencyKeyColumn(t *testing.T) {
+	db := openMemoryDB(t)
+	execSQL(t, db, `CREATE TABLE prepare_jobs (job_id TEXT)`)
+	if err := ensureJobIdempotencyKeyColumn(db); err != nil {
+		t.Fatalf("ensureJobIdempotencyKeyColumn: %v", err)
+	}
+	if !hasColumn(t, db, "prepare_jobs", "idempotency_key") {
+		t.Fatalf("expected idempotency_key column added")
+	}
+	if err := ensureJobIdempotencyKeyColumn(db); err != nil {
+		t.Fatalf("ensureJobIdempotencyKeyColumn duplicate: %v", err)
+	}
+}
+
+func TestEnsureJobIdempotencyKeyColumnErrors(t *testing.T) {
+	db := openErrorDB(t, []error{errors.New("no such table: prepare_jobs")})
+	if err := ensureJobIdempotencyKeyColumn(db); err != nil {
+		t.Fatalf("expected nil for missing table, got %v", err)
+	}
+	db = openErrorDB(t, []error{errors.New("boom")})
+	if err := ensureJobIdempotencyKeyColumn(db); err == nil {
+		t.Fatalf("expected exec error")
+	}
+}
+
+func TestSQLiteStoreGetJobByIdempotencyKey(t *testing.T) {
+	store := newQueueStore(t)
+	key := "ci-run-42"
+	if err := store.CreateJob(context.Background(), JobRecord{
+		JobID:          "job-1",
+		Status:         "queued",
+		PrepareKind:    "psql",
+		ImageID:        "image-1",
+		IdempotencyKey: &key,
+		CreatedAt:      "2026-01-19T00:00:00Z",
+	}); err != nil {
+		t.Fatalf("CreateJob: %v", err)
+	}
+
+	job, ok, err := store.GetJobByIdempotencyKey(context.Background(), key)
+	if err != nil || !ok {
+		t.Fatalf("GetJobByIdempotencyKey: ok=%v err=%v", ok, err)
+	}
+	if job.JobID != "job-1" || job.IdempotencyKey == nil || *job.IdempotencyKey != key {
+		t.Fatalf("unexpected job: %+v", job)
+	}
+	if _, ok, err := store.GetJobByIdempotencyKey(context.Background(), "other"); err != nil || ok {
+		t.Fatalf("expected missing key, ok=%v err=%v", ok, err)
+	}
+	if _, ok, err := store.GetJobByIdempotencyKey(context.Background(), "  "); err != nil || ok {
+		t.Fatalf("expected blank key to be ignored, ok=%v err=%v", ok, err)
+	}
+
+	if err := store.CreateJob(context.Background(), JobRecord{
+		JobID:          "job-2",
+		Status:         "queued",
+		PrepareKind:    "psql",
+		ImageID:        "image-1",
+		IdempotencyKey: &key,
+		CreatedAt:      "2026-01-19T00:00:01Z",
+	}); err == nil {
+		t.Fatalf("expected unique index violation")
+	}
+	for _, id := range []string{"job-3", "job-4"} {
+		if err := store.CreateJob(context.Background(), JobRecord{
+			JobID:       id,
+			Status:      "queued",
+			PrepareKind: "psql",
+			ImageID:     "image-1",
+			CreatedAt:   "2026-01-19T00:00:02Z",
+		}); err != nil {
+			t.Fatalf("CreateJob without key: %v", err)
+		}
+	}
+}
+
 func TestEnsureJobSignatureColumnNoTable(t *testing.T) {
 	db, err := sql.Open("sqlite", ":memory:")
 	if err != nil {
@@ -872,3 +1016,79 @@ func stringPtr(value string) *string {
 func boolPtrFromValue(value bool) *bool {
 	return &value
 }
+
+func TestSQLiteStoreListJobsByLabels(t *testing.T) {
+	store := newQueueStore(t)
+	pr1 := `{"pr":"1234","team":"db"}`
+	pr2 := `{"pr":"99"}`
+	for _, job := range []JobRecord{
+		{JobID: "job-1", Status: "queued", PrepareKind: "psql", ImageID: "image-1", CreatedAt: "2026-01-19T00:00:00Z", LabelsJSON: &pr1},
+		{JobID: "job-2", Status: "queued", PrepareKind: "psql", ImageID: "image-1", CreatedAt: "2026-01-19T00:01:00Z", LabelsJSON: &pr2},
+		{JobID: "job-3", Status: "queued", PrepareKind: "psql", ImageID: "image-1", CreatedAt: "2026-01-19T00:02:00Z"},
+	} {
+		if err := store.CreateJob(context.Background(), job); err != nil {
+			t.Fatalf("CreateJob: %v", err)
+		}
+	}
+	jobs, err := store.ListJobsByLabels(context.Background(), map[string]string{"pr": "1234"})
+	if err != nil {
+		t.Fatalf("ListJobsByLabels: %v", err)
+	}
+	if len(jobs) != 1 || jobs[0].JobID != "job-1" || jobs[0].LabelsJSON == nil || *jobs[0].LabelsJSON != pr1 {
+		t.Fatalf("unexpected jobs: %+v", jobs)
+	}
+	jobs, err = store.ListJobsByLabels(context.Background(), map[string]string{"pr": "1234", "team": "web"})
+	if err != nil || len(jobs) != 0 {
+		t.Fatalf("expected all labels to match, got %+v err=%v", jobs, err)
+	}
+	jobs, err = store.ListJobsByLabels(context.Background(), nil)
+	if err != nil || len(jobs) != 3 {
+		t.Fatalf("expected empty filter to list all jobs, got %d err=%v", len(jobs), err)
+	}
+}
+
+func TestSQLiteStoreGenerationAdvancesOnJobAndTaskWrites(t *testing.T) {
+	store := newQueueStore(t)
+	ctx := context.Background()
+
+	last := store.Generation()
+	expectAdvanced := func(step string) {
+		t.Helper()
+		if next := store.Generation(); next == last {
+			t.Fatalf("expected generation to advance after %s", step)
+		} else {
+			last = next
+		}
+	}
+	if err := store.CreateJob(ctx, JobRecord{JobID: "job-1", Status: "queued", PrepareKind: "psql", ImageID: "image-1", CreatedAt: "2026-01-19T00:00:00Z"}); err != nil {
+		t.Fatalf("CreateJob: %v", err)
+	}
+	expectAdvanced("CreateJob")
+	if err := store.UpdateJob(ctx, "job-1", JobUpdate{Status: stringPtr("running")}); err != nil {
+		t.Fatalf("UpdateJob: %v", err)
+	}
+	expectAdvanced("UpdateJob")
+	if err := store.ReplaceTasks(ctx, "job-1", []TaskRecord{{JobID: "job-1", TaskID: "plan", Type: "plan", Status: "queued"}}); err != nil {
+		t.Fatalf("ReplaceTasks: %v", err)
+	}
+	expectAdvanced("ReplaceTasks")
+	if err := store.UpdateTask(ctx, "job-1", "plan", TaskUpdate{Status: stringPtr("running")}); err != nil {
+		t.Fatalf("UpdateTask: %v", err)
+	}
+	expectAdvanced("UpdateTask")
+
+	if _, err := store.AppendEvent(ctx, EventRecord{JobID: "job-1", Type: "log", Ts: "2026-01-19T00:00:01Z"}); err != nil {
+		t.Fatalf("AppendEvent: %v", err)
+	}
+	if _, err := store.ListJobs(ctx, ""); err != nil {
+		t.Fatalf("ListJobs: %v", err)
+	}
+	if store.Generation() != last {
+		t.Fatalf("expected events and reads to keep the generation")
+	}
+
+	if err := store.DeleteJob(ctx, "job-1"); err != nil {
+		t.Fatalf("DeleteJob: %v", err)
+	}
+	expectAdvanced("DeleteJob")
+}
@@ -12,11 +12,14 @@ type JobRecord struct {
 	PrepareArgsNormalized *string
 	Signature             *string
 	RequestJSON           *string
+	IdempotencyKey        *string
 	CreatedAt             string
 	StartedAt             *string
 	FinishedAt            *string
 	ResultJSON            *string
 	ErrorJSON             *string
+	PlanJSON              *string
+	LabelsJSON            *string
 }
 
 type TaskRecord struct {
@@ -43,15 +46,17 @@ type TaskRecord struct {
 }
 
 type EventRecord struct {
-	Seq        int64
-	JobID      string
-	Type       string
-	Ts         string
-	Status     *string
-	TaskID     *string
-	Message    *string
-	ResultJSON *string
-	ErrorJSON  *string
+	Seq          int64
+	JobID        string
+	Type         string
+	Ts           string
+	Status       *string
+	TaskID       *string
+	Message      *string
+	ResultJSON   *string
+	ErrorJSON    *string
+	ProgressJSON *string
+	PlanJSON     *string
 }
 
 type JobUpdate struct {
@@ -64,6 +69,10 @@ type JobUpdate struct {
 	FinishedAt            *string
 	ResultJSON            *string
 	ErrorJSON             *string
+	PlanJSON              *string
+	// ResetOutcome clears finished_at, result_json and error_json so a
+	// requeued job no longer reports its previous outcome.
+	ResetOutcome bool
 }
 
 type TaskUpdate struct {
@@ -80,9 +89,11 @@ type Store interface {
 	CreateJob(ctx context.Context, job JobRecord) error
 	UpdateJob(ctx context.Context, jobID string, update JobUpdate) error
 	GetJob(ctx context.Context, jobID string) (JobRecord, bool, error)
+	GetJobByIdempotencyKey(ctx context.Context, key string) (JobRecord, bool, error)
 	ListJobs(ctx context.Context, jobID string) ([]JobRecord, error)
 	ListJobsByStatus(ctx context.Context, statuses []string) ([]JobRecord, error)
 	ListJobsBySignature(ctx context.Context, signature string, statuses []string) ([]JobRecord, error)
+	ListJobsByLabels(ctx context.Context, labels map[string]string) ([]JobRecord, error)
 	DeleteJob(ctx context.Context, jobID string) error
 
 	ReplaceTasks(ctx context.Context, jobID string, tasks []TaskRecord) error
@@ -91,7 +102,20 @@ type Store interface {
 
 	AppendEvent(ctx context.Context, event EventRecord) (int64, error)
 	ListEventsSince(ctx context.Context, jobID string, offset int) ([]EventRecord, error)
+	// ListEventsAfterSeq lists the job's events with seq > seq. Unlike an
+	// offset it seeks straight to the position, so tailing a long stream does
+	// not rescan its earlier events.
+	ListEventsAfterSeq(ctx context.Context, jobID string, seq int64) ([]EventRecord, error)
 	CountEvents(ctx context.Context, jobID string) (int, error)
+	// MaxEventSeq returns the seq of the job's latest event, or 0 when it has
+	// none. Streams wait for it to pass their cursor, which pruning older
+	// events cannot move.
+	MaxEventSeq(ctx context.Context, jobID string) (int64, error)
+	// PruneEvents deletes the job's log events with seq < keepFrom and
+	// returns how many were deleted. Other event types (status, task, result,
+	// error, ...) are kept, so the stream keeps its milestones with gaps in
+	// seq where the logs were.
+	PruneEvents(ctx context.Context, jobID string, keepFrom int64) (int, error)
 
 	Close() error
 }
@@ -0,0 +1,511 @@
+package queue
+
+import (
+	"context"
+	"testing"
+)
+
+// The conformance tests run against every Store implementation, so the
+// in-memory store keeps exact parity with the SQLite one.
+
+func TestSQLiteStoreConformance(t *testing.T) {
+	runStoreConformance(t, func(t *testing.T) Store { return newQueueStore(t) })
+}
+
+func TestMemoryStoreConformance(t *testing.T) {
+	runStoreConformance(t, func(t *testing.T) Store { return NewMemory() })
+}
+
+func runStoreConformance(t *testing.T, newStore func(t *testing.T) Store) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, store Store)
+	}{
+		{"JobRoundTrip", conformJobRoundTrip},
+		{"CreateJobConstraints", conformCreateJobConstraints},
+		{"UpdateJob", conformUpdateJob},
+		{"ListJobs", conformListJobs},
+		{"ListJobsBySignature", conformListJobsBySignature},
+		{"ListJobsByLabels", conformListJobsByLabels},
+		{"Tasks", conformTasks},
+		{"Events", conformEvents},
+		{"PruneEvents", conformPruneEvents},
+		{"DeleteJobCascades", conformDeleteJobCascades},
+		{"Generation", conformGeneration},
+		{"Closed", conformClosed},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.run(t, newStore(t))
+		})
+	}
+}
+
+func conformCreateJobs(t *testing.T, store Store, jobs ...JobRecord) {
+	t.Helper()
+	for _, job := range jobs {
+		if job.Status == "" {
+			job.Status = "queued"
+		}
+		if job.PrepareKind == "" {
+			job.PrepareKind = "psql"
+		}
+		if job.ImageID == "" {
+			job.ImageID = "image-1"
+		}
+		if err := store.CreateJob(context.Background(), job); err != nil {
+			t.Fatalf("CreateJob(%s): %v", job.JobID, err)
+		}
+	}
+}
+
+func conformJobIDs(jobs []JobRecord) []string {
+	ids := make([]string, 0, len(jobs))
+	for _, job := range jobs {
+		ids = append(ids, job.JobID)
+	}
+	return ids
+}
+
+func conformEqualIDs(t *testing.T, label string, got []string, want ...string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s: got %v, want %v", label, got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("%s: got %v, want %v", label, got, want)
+		}
+	}
+}
+
+func conformJobRoundTrip(t *testing.T, store Store) {
+	ctx := context.Background()
+	key := "key-1"
+	conformCreateJobs(t, store, JobRecord{
+		JobID:          "job-1",
+		PlanOnly:       true,
+		Signature:      stringPtr("sig-1"),
+		IdempotencyKey: &key,
+		CreatedAt:      "2026-01-19T00:00:00Z",
+	})
+	job, ok, err := store.GetJob(ctx, "job-1")
+	if err != nil || !ok {
+		t.Fatalf("GetJob: ok=%v err=%v", ok, err)
+	}
+	if !job.PlanOnly || job.SnapshotMode != "always" || job.Signature == nil || *job.Signature != "sig-1" {
+		t.Fatalf("unexpected job: %+v", job)
+	}
+	if job.StartedAt != nil || job.ResultJSON != nil {
+		t.Fatalf("expected unset fields to stay nil: %+v", job)
+	}
+
+	// Records are copies: mutating one must not reach the store.
+	*job.Signature = "mutated"
+	key = "mutated"
+	job, _, _ = store.GetJob(ctx, "job-1")
+	if *job.Signature != "sig-1" || *job.IdempotencyKey != "key-1" {
+		t.Fatalf("expected stored job to be isolated from callers: %+v", job)
+	}
+
+	if _, ok, err := store.GetJob(ctx, "missing"); err != nil || ok {
+		t.Fatalf("GetJob(missing): ok=%v err=%v", ok, err)
+	}
+	if job, ok, err := store.GetJobByIdempotencyKey(ctx, "key-1"); err != nil || !ok || job.JobID != "job-1" {
+		t.Fatalf("GetJobByIdempotencyKey: job=%+v ok=%v err=%v", job, ok, err)
+	}
+	for _, key := range []string{"", "  ", "key-2"} {
+		if _, ok, err := store.GetJobByIdempotencyKey(ctx, key); err != nil || ok {
+			t.Fatalf("GetJobByIdempotencyKey(%q): ok=%v err=%v", key, ok, err)
+		}
+	}
+}
+
+func conformCreateJobConstraints(t *testing.T, store Store) {
+	ctx := context.Background()
+	conformCreateJobs(t, store,
+		JobRecord{JobID: "job-1", IdempotencyKey: stringPtr("key-1"), CreatedAt: "2026-01-19T00:00:00Z"},
+		JobRecord{JobID: "job-2", CreatedAt: "2026-01-19T00:01:00Z"},
+		JobRecord{JobID: "job-3", CreatedAt: "2026-01-19T00:02:00Z"},
+	)
+	dup := JobRecord{JobID: "job-1", Status: "queued", PrepareKind: "psql", ImageID: "image-1", CreatedAt: "2026-01-19T00:03:00Z"}
+	if err := store.CreateJob(ctx, dup); err == nil {
+		t.Fatalf("expected duplicate job id to fail")
+	}
+	dup.JobID = "job-4"
+	dup.IdempotencyKey = stringPtr("key-1")
+	if err := store.CreateJob(ctx, dup); err == nil {
+		t.Fatalf("expected duplicate idempotency key to fail")
+	}
+	jobs, err := store.ListJobs(ctx, "")
+	if err != nil {
+		t.Fatalf("ListJobs: %v", err)
+	}
+	conformEqualIDs(t, "ListJobs", conformJobIDs(jobs), "job-1", "job-2", "job-3")
+}
+
+func conformUpdateJob(t *testing.T, store Store) {
+	ctx := context.Background()
+	conformCreateJobs(t, store, JobRecord{JobID: "job-1", CreatedAt: "2026-01-19T00:00:00Z"})
+	if err := store.UpdateJob(ctx, "", JobUpdate{Status: stringPtr("running")}); err == nil {
+		t.Fatalf("expected empty job id to fail")
+	}
+	if err := store.UpdateJob(ctx, "job-1", JobUpdate{}); err != nil {
+		t.Fatalf("UpdateJob(no fields): %v", err)
+	}
+	if err := store.UpdateJob(ctx, "missing", JobUpdate{Status: stringPtr("running")}); err != nil {
+		t.Fatalf("UpdateJob(missing): %v", err)
+	}
+	if err := store.UpdateJob(ctx, "job-1", JobUpdate{
+		Status:       stringPtr("succeeded"),
+		SnapshotMode: stringPtr("never"),
+		StartedAt:    stringPtr("2026-01-19T00:01:00Z"),
+		FinishedAt:   stringPtr("2026-01-19T00:02:00Z"),
+		ResultJSON:   stringPtr(`{"ok":true}`),
+		PlanJSON:     stringPtr(`[]`),
+	}); err != nil {
+		t.Fatalf("UpdateJob: %v", err)
+	}
+	job, _, _ := store.GetJob(ctx, "job-1")
+	if job.Status != "succeeded" || job.SnapshotMode != "never" || job.FinishedAt == nil || job.ResultJSON == nil || job.PlanJSON == nil {
+		t.Fatalf("unexpected updated job: %+v", job)
+	}
+
+	// ResetOutcome wins over outcome fields set in the same update.
+	if err := store.UpdateJob(ctx, "job-1", JobUpdate{
+		Status:       stringPtr("queued"),
+		FinishedAt:   stringPtr("2026-01-19T00:03:00Z"),
+		ErrorJSON:    stringPtr(`{}`),
+		ResetOutcome: true,
+	}); err != nil {
+		t.Fatalf("UpdateJob(reset): %v", err)
+	}
+	job, _, _ = store.GetJob(ctx, "job-1")
+	if job.Status != "queued" || job.FinishedAt != nil || job.ResultJSON != nil || job.ErrorJSON != nil {
+		t.Fatalf("expected outcome to be reset: %+v", job)
+	}
+	if job.StartedAt == nil || job.PlanJSON == nil {
+		t.Fatalf("expected non-outcome fields to be kept: %+v", job)
+	}
+}
+
+func conformListJobs(t *testing.T, store Store) {
+	ctx := context.Background()
+	conformCreateJobs(t, store,
+		JobRecord{JobID: "job-b", Status: "running", CreatedAt: "2026-01-19T00:02:00Z"},
+		JobRecord{JobID: "job-a", Status: "queued", CreatedAt: "2026-01-19T00:01:00Z"},
+		JobRecord{JobID: "other_1", Status: "queued", CreatedAt: "2026-01-19T00:00:00Z"},
+		JobRecord{JobID: "otherx1", Status: "failed", CreatedAt: "2026-01-19T00:02:00Z"},
+	)
+	jobs, err := store.ListJobs(ctx, "")
+	if err != nil {
+		t.Fatalf("ListJobs: %v", err)
+	}
+	conformEqualIDs(t, "ListJobs(all)", conformJobIDs(jobs), "other_1", "job-a", "job-b", "otherx1")
+	jobs, _ = store.ListJobs(ctx, " JOB-")
+	conformEqualIDs(t, "ListJobs(prefix)", conformJobIDs(jobs), "job-a", "job-b")
+	jobs, _ = store.ListJobs(ctx, "other_")
+	conformEqualIDs(t, "ListJobs(literal prefix)", conformJobIDs(jobs), "other_1")
+
+	jobs, err = store.ListJobsByStatus(ctx, []string{"queued", "running"})
+	if err != nil {
+		t.Fatalf("ListJobsByStatus: %v", err)
+	}
+	conformEqualIDs(t, "ListJobsByStatus", conformJobIDs(jobs), "other_1", "job-a", "job-b")
+	if jobs, err := store.ListJobsByStatus(ctx, nil); err != nil || jobs != nil {
+		t.Fatalf("ListJobsByStatus(nil): jobs=%v err=%v", jobs, err)
+	}
+}
+
+func conformListJobsBySignature(t *testing.T, store Store) {
+	ctx := context.Background()
+	conformCreateJobs(t, store,
+		JobRecord{JobID: "job-1", Status: "succeeded", Signature: stringPtr("sig"), CreatedAt: "2026-01-19T00:00:00Z", FinishedAt: stringPtr("2026-01-19T00:05:00Z")},
+		JobRecord{JobID: "job-2", Status: "queued", Signature: stringPtr("sig"), CreatedAt: "2026-01-19T00:03:00Z"},
+		JobRecord{JobID: "job-3", Status: "failed", Signature: stringPtr("sig"), CreatedAt: "2026-01-19T00:01:00Z", FinishedAt: stringPtr("2026-01-19T00:04:00Z")},
+		JobRecord{JobID: "job-4", Status: "succeeded", Signature: stringPtr("other"), CreatedAt: "2026-01-19T00:06:00Z"},
+	)
+	jobs, err := store.ListJobsBySignature(ctx, "sig", nil)
+	if err != nil {
+		t.Fatalf("ListJobsBySignature: %v", err)
+	}
+	conformEqualIDs(t, "ListJobsBySignature", conformJobIDs(jobs), "job-1", "job-3", "job-2")
+	jobs, _ = store.ListJobsBySignature(ctx, "sig", []string{"succeeded", "queued"})
+	conformEqualIDs(t, "ListJobsBySignature(status)", conformJobIDs(jobs), "job-1", "job-2")
+	if jobs, err := store.ListJobsBySignature(ctx, " ", nil); err != nil || jobs != nil {
+		t.Fatalf("ListJobsBySignature(blank): jobs=%v err=%v", jobs, err)
+	}
+}
+
+func conformListJobsByLabels(t *testing.T, store Store) {
+	ctx := context.Background()
+	conformCreateJobs(t, store,
+		JobRecord{JobID: "job-1", CreatedAt: "2026-01-19T00:00:00Z", LabelsJSON: stringPtr(`{"pr":"1234","team":"db"}`)},
+		JobRecord{JobID: "job-2", CreatedAt: "2026-01-19T00:01:00Z", LabelsJSON: stringPtr(`{"pr":"99"}`)},
+		JobRecord{JobID: "job-3", CreatedAt: "2026-01-19T00:02:00Z"},
+	)
+	jobs, err := store.ListJobsByLabels(ctx, map[string]string{"pr": "1234", "team": "db"})
+	if err != nil {
+		t.Fatalf("ListJobsByLabels: %v", err)
+	}
+	conformEqualIDs(t, "ListJobsByLabels", conformJobIDs(jobs), "job-1")
+	jobs, _ = store.ListJobsByLabels(ctx, map[string]string{"pr": "99", "team": "db"})
+	conformEqualIDs(t, "ListJobsByLabels(partial)", conformJobIDs(jobs))
+	jobs, _ = store.ListJobsByLabels(ctx, nil)
+	conformEqualIDs(t, "ListJobsByLabels(none)", conformJobIDs(jobs), "job-1", "job-2", "job-3")
+}
+
+func conformTasks(t *testing.T, store Store) {
+	ctx := context.Background()
+	conformCreateJobs(t, store,
+		JobRecord{JobID: "job-b", CreatedAt: "2026-01-19T00:00:00Z"},
+		JobRecord{JobID: "job-a", CreatedAt: "2026-01-19T00:01:00Z"},
+	)
+	if err := store.ReplaceTasks(ctx, "job-b", []TaskRecord{
+		{JobID: "job-b", TaskID: "execute-0", Position: 1, Type: "state_execute", Status: "queued", Cached: boolPtrFromValue(false)},
+		{JobID: "job-b", TaskID: "plan", Position: 0, Type: "plan", Status: "queued"},
+	}); err != nil {
+		t.Fatalf("ReplaceTasks: %v", err)
+	}
+	if err := store.ReplaceTasks(ctx, "job-a", []TaskRecord{{JobID: "job-a", TaskID: "plan", Type: "plan", Status: "queued"}}); err != nil {
+		t.Fatalf("ReplaceTasks: %v", err)
+	}
+	tasks, err := store.ListTasks(ctx, "")
+	if err != nil {
+		t.Fatalf("ListTasks: %v", err)
+	}
+	var got []string
+	for _, task := range tasks {
+		got = append(got, task.JobID+"/"+task.TaskID)
+	}
+	conformEqualIDs(t, "ListTasks", got, "job-a/plan", "job-b/plan", "job-b/execute-0")
+
+	if err := store.UpdateTask(ctx, "job-b", "execute-0", TaskUpdate{
+		Status:        stringPtr("succeeded"),
+		OutputStateID: stringPtr("state-1"),
+		Cached:        boolPtrFromValue(true),
+	}); err != nil {
+		t.Fatalf("UpdateTask: %v", err)
+	}
+	if err := store.UpdateTask(ctx, "job-b", "missing", TaskUpdate{Status: stringPtr("failed")}); err != nil {
+		t.Fatalf("UpdateTask(missing): %v", err)
+	}
+	if err := store.UpdateTask(ctx, "", "plan", TaskUpdate{}); err == nil {
+		t.Fatalf("expected empty job id to fail")
+	}
+	tasks, _ = store.ListTasks(ctx, " job-b ")
+	if len(tasks) != 2 || tasks[1].Status != "succeeded" || tasks[1].Cached == nil || !*tasks[1].Cached || tasks[1].OutputStateID == nil {
+		t.Fatalf("unexpected updated tasks: %+v", tasks)
+	}
+
+	// Replacing drops the old tasks; rows inserted before a failure stay.
+	if err := store.ReplaceTasks(ctx, "job-b", []TaskRecord{
+		{JobID: "job-b", TaskID: "plan", Type: "plan", Status: "queued"},
+		{JobID: "job-b", TaskID: "plan", Position: 1, Type: "plan", Status: "queued"},
+	}); err == nil {
+		t.Fatalf("expected duplicate task id to fail")
+	}
+	tasks, _ = store.ListTasks(ctx, "job-b")
+	if len(tasks) != 1 || tasks[0].TaskID != "plan" {
+		t.Fatalf("expected only the first replacement task, got %+v", tasks)
+	}
+	if err := store.ReplaceTasks(ctx, "missing", []TaskRecord{{JobID: "missing", TaskID: "plan", Type: "plan", Status: "queued"}}); err == nil {
+		t.Fatalf("expected tasks for a missing job to fail")
+	}
+}
+
+func conformEvents(t *testing.T, store Store) {
+	ctx := context.Background()
+	conformCreateJobs(t, store,
+		JobRecord{JobID: "job-1", CreatedAt: "2026-01-19T00:00:00Z"},
+		JobRecord{JobID: "job-2", CreatedAt: "2026-01-19T00:01:00Z"},
+	)
+	var seqs []int64
+	for _, jobID := range []string{"job-1", "job-2", "job-1", "job-1"} {
+		seq, err := store.AppendEvent(ctx, EventRecord{JobID: jobID, Type: "status", Ts: "2026-01-19T00:00:00Z", Status: stringPtr("queued")})
+		if err != nil {
+			t.Fatalf("AppendEvent: %v", err)
+		}
+		seqs = append(seqs, seq)
+	}
+	if seqs[0] != 1 || seqs[1] != 2 || seqs[2] != 3 || seqs[3] != 4 {
+		t.Fatalf("expected global increasing seqs, got %v", seqs)
+	}
+	if _, err := store.AppendEvent(ctx, EventRecord{JobID: "missing", Type: "status", Ts: "2026-01-19T00:00:00Z"}); err == nil {
+		t.Fatalf("expected event for a missing job to fail")
+	}
+
+	events, err := store.ListEventsSince(ctx, "job-1", 1)
+	if err != nil {
+		t.Fatalf("ListEventsSince: %v", err)
+	}
+	if len(events) != 2 || events[0].Seq != 3 || events[1].Seq != 4 || events[0].Status == nil {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+	if events, _ := store.ListEventsSince(ctx, "job-1", -1); len(events) != 3 {
+		t.Fatalf("expected a negative offset to list all events, got %+v", events)
+	}
+	if events, _ := store.ListEventsSince(ctx, "job-1", 3); len(events) != 0 {
+		t.Fatalf("expected no events past the end, got %+v", events)
+	}
+	events, err = store.ListEventsAfterSeq(ctx, "job-1", 1)
+	if err != nil {
+		t.Fatalf("ListEventsAfterSeq: %v", err)
+	}
+	if len(events) != 2 || events[0].Seq != 3 || events[1].Seq != 4 {
+		t.Fatalf("unexpected events after seq 1: %+v", events)
+	}
+	if events, _ := store.ListEventsAfterSeq(ctx, "job-1", 4); len(events) != 0 {
+		t.Fatalf("expected no events after the last seq, got %+v", events)
+	}
+	if count, err := store.CountEvents(ctx, "job-1"); err != nil || count != 3 {
+		t.Fatalf("CountEvents: count=%d err=%v", count, err)
+	}
+	if seq, err := store.MaxEventSeq(ctx, "job-1"); err != nil || seq != 4 {
+		t.Fatalf("MaxEventSeq: seq=%d err=%v", seq, err)
+	}
+	if seq, err := store.MaxEventSeq(ctx, "missing"); err != nil || seq != 0 {
+		t.Fatalf("MaxEventSeq without events: seq=%d err=%v", seq, err)
+	}
+
+	// Seqs of deleted jobs are never reused.
+	if err := store.DeleteJob(ctx, "job-1"); err != nil {
+		t.Fatalf("DeleteJob: %v", err)
+	}
+	seq, err := store.AppendEvent(ctx, EventRecord{JobID: "job-2", Type: "status", Ts: "2026-01-19T00:00:00Z"})
+	if err != nil || seq != 5 {
+		t.Fatalf("AppendEvent after delete: seq=%d err=%v", seq, err)
+	}
+}
+
+func conformPruneEvents(t *testing.T, store Store) {
+	ctx := context.Background()
+	conformCreateJobs(t, store,
+		JobRecord{JobID: "job-1", CreatedAt: "2026-01-19T00:00:00Z"},
+		JobRecord{JobID: "job-2", CreatedAt: "2026-01-19T00:01:00Z"},
+	)
+	for _, event := range []EventRecord{
+		{JobID: "job-1", Type: "status", Ts: "2026-01-19T00:00:00Z"},
+		{JobID: "job-1", Type: "log", Ts: "2026-01-19T00:00:01Z"},
+		{JobID: "job-2", Type: "log", Ts: "2026-01-19T00:00:02Z"},
+		{JobID: "job-1", Type: "task", Ts: "2026-01-19T00:00:03Z"},
+		{JobID: "job-1", Type: "log", Ts: "2026-01-19T00:00:04Z"},
+		{JobID: "job-1", Type: "log", Ts: "2026-01-19T00:00:05Z"},
+	} {
+		if _, err := store.AppendEvent(ctx, event); err != nil {
+			t.Fatalf("AppendEvent: %v", err)
+		}
+	}
+
+	deleted, err := store.PruneEvents(ctx, "job-1", 6)
+	if err != nil || deleted != 2 {
+		t.Fatalf("PruneEvents: deleted=%d err=%v", deleted, err)
+	}
+	events, err := store.ListEventsAfterSeq(ctx, "job-1", 0)
+	if err != nil {
+		t.Fatalf("ListEventsAfterSeq: %v", err)
+	}
+	var seqs []int64
+	for _, event := range events {
+		seqs = append(seqs, event.Seq)
+	}
+	if len(seqs) != 3 || seqs[0] != 1 || seqs[1] != 4 || seqs[2] != 6 {
+		t.Fatalf("expected status, task and the newest log to remain, got %v", seqs)
+	}
+	if count, _ := store.CountEvents(ctx, "job-2"); count != 1 {
+		t.Fatalf("expected other jobs' logs to remain, got %d", count)
+	}
+	if deleted, err := store.PruneEvents(ctx, "job-1", 6); err != nil || deleted != 0 {
+		t.Fatalf("expected a repeated prune to delete nothing: deleted=%d err=%v", deleted, err)
+	}
+}
+
+func conformDeleteJobCascades(t *testing.T, store Store) {
+	ctx := context.Background()
+	conformCreateJobs(t, store,
+		JobRecord{JobID: "job-1", CreatedAt: "2026-01-19T00:00:00Z"},
+		JobRecord{JobID: "job-2", CreatedAt: "2026-01-19T00:01:00Z"},
+	)
+	for _, jobID := range []string{"job-1", "job-2"} {
+		if err := store.ReplaceTasks(ctx, jobID, []TaskRecord{{JobID: jobID, TaskID: "plan", Type: "plan", Status: "queued"}}); err != nil {
+			t.Fatalf("ReplaceTasks: %v", err)
+		}
+		if _, err := store.AppendEvent(ctx, EventRecord{JobID: jobID, Type: "status", Ts: "2026-01-19T00:00:00Z"}); err != nil {
+			t.Fatalf("AppendEvent: %v", err)
+		}
+	}
+	if err := store.DeleteJob(ctx, "job-1"); err != nil {
+		t.Fatalf("DeleteJob: %v", err)
+	}
+	if err := store.DeleteJob(ctx, "missing"); err != nil {
+		t.Fatalf("DeleteJob(missing): %v", err)
+	}
+	if _, ok, _ := store.GetJob(ctx, "job-1"); ok {
+		t.Fatalf("expected job to be deleted")
+	}
+	if tasks, _ := store.ListTasks(ctx, "job-1"); len(tasks) != 0 {
+		t.Fatalf("expected tasks to be deleted, got %+v", tasks)
+	}
+	if count, _ := store.CountEvents(ctx, "job-1"); count != 0 {
+		t.Fatalf("expected events to be deleted, got %d", count)
+	}
+	if tasks, _ := store.ListTasks(ctx, ""); len(tasks) != 1 || tasks[0].JobID != "job-2" {
+		t.Fatalf("expected other tasks to be kept, got %+v", tasks)
+	}
+	if count, _ := store.CountEvents(ctx, "job-2"); count != 1 {
+		t.Fatalf("expected other events to be kept, got %d", count)
+	}
+}
+
+func conformGeneration(t *testing.T, store Store) {
+	ctx := context.Background()
+	gen, ok := store.(interface{ Generation() uint64 })
+	if !ok {
+		t.Fatalf("expected %T to report a generation", store)
+	}
+	last := gen.Generation()
+	advanced := func(label string) {
+		t.Helper()
+		if next := gen.Generation(); next == last {
+			t.Fatalf("%s: expected generation to advance", label)
+		} else {
+			last = next
+		}
+	}
+	conformCreateJobs(t, store, JobRecord{JobID: "job-1", CreatedAt: "2026-01-19T00:00:00Z"})
+	advanced("CreateJob")
+	_ = store.UpdateJob(ctx, "job-1", JobUpdate{Status: stringPtr("running")})
+	advanced("UpdateJob")
+	_ = store.ReplaceTasks(ctx, "job-1", []TaskRecord{{JobID: "job-1", TaskID: "plan", Type: "plan", Status: "queued"}})
+	advanced("ReplaceTasks")
+	_ = store.UpdateTask(ctx, "job-1", "plan", TaskUpdate{Status: stringPtr("running")})
+	advanced("UpdateTask")
+	_, _, _ = store.GetJob(ctx, "job-1")
+	if gen.Generation() != last {
+		t.Fatalf("expected reads to keep the generation")
+	}
+	_ = store.DeleteJob(ctx, "job-1")
+	advanced("DeleteJob")
+}
+
+func conformClosed(t *testing.T, store Store) {
+	ctx := context.Background()
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if _, _, err := store.GetJob(ctx, "job-1"); err == nil {
+		t.Fatalf("expected GetJob to fail after Close")
+	}
+	if err := store.CreateJob(ctx, JobRecord{JobID: "job-1", Status: "queued", PrepareKind: "psql", ImageID: "image-1", CreatedAt: "2026-01-19T00:00:00Z"}); err == nil {
+		t.Fatalf("expected CreateJob to fail after Close")
+	}
+	if _, err := store.ListEventsSince(ctx, "job-1", 0); err == nil {
+		t.Fatalf("expected ListEventsSince to fail after Close")
+	}
+	if _, err := store.ListEventsAfterSeq(ctx, "job-1", 0); err == nil {
+		t.Fatalf("expected ListEventsAfterSeq to fail after Close")
+	}
+	if _, err := store.PruneEvents(ctx, "job-1", 1); err == nil {
+		t.Fatalf("expected PruneEvents to fail after Close")
+	}
+}
@@ -0,0 +1,47 @@
+package prepare
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sqlrs/engine-local/internal/config"
+)
+
+const defaultMaxQueuedJobs = 1000
+
+// maxQueuedJobs reads orchestrator.jobs.maxQueued; 0 disables the limit.
+func maxQueuedJobs(cfg config.Store) int {
+	if cfg == nil {
+		return defaultMaxQueuedJobs
+	}
+	value, err := cfg.Get("orchestrator.jobs.maxQueued", true)
+	if err != nil || value == nil {
+		return defaultMaxQueuedJobs
+	}
+	if num, ok := configValueToInt(value); ok && num >= 0 {
+		return num
+	}
+	return defaultMaxQueuedJobs
+}
+
+// checkQueueCapacity rejects a new job once the queued and running jobs reach
+// orchestrator.jobs.maxQueued, so a runaway client cannot grow the queue
+// without bound. Concurrent submits may overshoot the limit slightly.
+func (m *PrepareService) checkQueueCapacity(ctx context.Context) error {
+	limit := maxQueuedJobs(m.config)
+	if limit <= 0 {
+		return nil
+	}
+	jobs, err := m.queue.ListJobsByStatus(ctx, []string{StatusQueued, StatusRunning})
+	if err != nil {
+		return err
+	}
+	if len(jobs) >= limit {
+		return ResourceExhaustedError{
+			Code:    ErrorCodeResourceExhausted,
+			Message: "too many queued jobs",
+			Details: fmt.Sprintf("%d jobs queued or running, limit is %d", len(jobs), limit),
+		}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package prepare
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/sqlrs/engine-local/internal/prepare/queue"
+)
+
+type queueLimitStore struct {
+	queue.Store
+	active []queue.JobRecord
+	err    error
+}
+
+func (s *queueLimitStore) ListJobsByStatus(ctx context.Context, statuses []string) ([]queue.JobRecord, error) {
+	return s.active, s.err
+}
+
+func TestMaxQueuedJobs(t *testing.T) {
+	if got := maxQueuedJobs(nil); got != defaultMaxQueuedJobs {
+		t.Fatalf("expected default, got %d", got)
+	}
+	if got := maxQueuedJobs(&fakeConfigStore{values: map[string]any{"orchestrator.jobs.maxQueued": 3}}); got != 3 {
+		t.Fatalf("expected configured limit, got %d", got)
+	}
+	if got := maxQueuedJobs(&fakeConfigStore{values: map[string]any{"orchestrator.jobs.maxQueued": -1}}); got != defaultMaxQueuedJobs {
+		t.Fatalf("expected invalid limit to fall back to default, got %d", got)
+	}
+}
+
+func TestCheckQueueCapacity(t *testing.T) {
+	store := &queueLimitStore{active: []queue.JobRecord{{JobID: "job-1"}, {JobID: "job-2"}}}
+	m := &PrepareService{queue: store, config: &fakeConfigStore{values: map[string]any{"orchestrator.jobs.maxQueued": 2}}}
+	err := m.checkQueueCapacity(context.Background())
+	var exhausted ResourceExhaustedError
+	if !errors.As(err, &exhausted) || exhausted.Code != "resource_exhausted" {
+		t.Fatalf("expected resource exhausted, got %v", err)
+	}
+	if resp := ToErrorResponse(err); resp.Code != "resource_exhausted" {
+		t.Fatalf("unexpected error response: %+v", resp)
+	}
+
+	store.active = store.active[:1]
+	if err := m.checkQueueCapacity(context.Background()); err != nil {
+		t.Fatalf("expected capacity to be available, got %v", err)
+	}
+
+	m.config = &fakeConfigStore{values: map[string]any{"orchestrator.jobs.maxQueued": 0}}
+	store.err = errors.New("boom")
+	if err := m.checkQueueCapacity(context.Background()); err != nil {
+		t.Fatalf("expected disabled limit to skip the lookup, got %v", err)
+	}
+}
+
+func TestSubmitRejectsWhenQueueIsFull(t *testing.T) {
+	queueStore := &faultQueueStore{
+		Store: newQueueStore(t),
+		listJobsByStatus: func(context.Context, []string) ([]queue.JobRecord, error) {
+			return []queue.JobRecord{{JobID: "job-0", Status: StatusQueued}}, nil
+		},
+	}
+	mgr := newManagerWithDeps(t, &fakeStore{}, queueStore, &testDeps{
+		config: &fakeConfigStore{values: map[string]any{"orchestrator.jobs.maxQueued": 1}},
+	})
+	_, err := mgr.Submit(context.Background(), Request{PrepareKind: "psql", ImageID: "image-1", PsqlArgs: []string{"-c", "select 1"}})
+	var exhausted ResourceExhaustedError
+	if !errors.As(err, &exhausted) {
+		t.Fatalf("expected resource exhausted, got %v", err)
+	}
+	if _, ok, _ := queueStore.GetJob(context.Background(), "job-1"); ok {
+		t.Fatalf("expected no job to be created")
+	}
+}
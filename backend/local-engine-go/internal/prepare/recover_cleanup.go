@@ -0,0 +1,148 @@
+package prepare
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sqlrs/engine-local/internal/store"
+)
+
+// resetJobRuntime removes what an interrupted run of jobID left in its job
+// dirs: the runtime clone and any runtime dirs a failed reset set aside. A
+// leftover clone may hold the postmaster.pid of the postgres that was killed
+// with the engine, so the rerun must not start from it.
+func (m *PrepareService) resetJobRuntime(ctx context.Context, namespace string, jobID string) {
+	if strings.TrimSpace(m.stateStoreRoot) == "" || strings.TrimSpace(jobID) == "" {
+		return
+	}
+	for _, root := range m.jobRoots(namespace) {
+		runtimeDir := filepath.Join(root, "jobs", jobID, "runtime")
+		stale, _ := filepath.Glob(runtimeDir + ".stale-*")
+		for _, path := range append([]string{runtimeDir}, stale...) {
+			if _, err := os.Lstat(path); err != nil {
+				continue
+			}
+			if err := m.removeRuntimePath(ctx, path); err != nil {
+				m.logWarnJob(jobID, "recover runtime cleanup failed path=%s err=%v", path, err)
+				continue
+			}
+			m.logInfoJob(jobID, "recover removed runtime dir path=%s", path)
+		}
+	}
+}
+
+// sweepOrphanJobDirs removes job dirs left behind by jobs that no longer
+// exist, e.g. when the engine stopped between deleting a job and its dirs.
+// Dirs holding the runtime of an instance are kept.
+func (m *PrepareService) sweepOrphanJobDirs(ctx context.Context) {
+	if strings.TrimSpace(m.stateStoreRoot) == "" {
+		return
+	}
+	var inUse []string
+	if m.store != nil {
+		instances, err := m.store.ListInstances(ctx, store.InstanceFilters{})
+		if err != nil {
+			m.logErrorJob("", "orphan job dir sweep skipped: %v", err)
+			return
+		}
+		for _, instance := range instances {
+			if dir := strings.TrimSpace(valueOrEmpty(instance.RuntimeDir)); dir != "" {
+				inUse = append(inUse, filepath.Clean(dir))
+			}
+		}
+	}
+	for _, jobsDir := range m.jobsDirs() {
+		entries, err := os.ReadDir(jobsDir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if ctx.Err() != nil {
+				return
+			}
+			jobID := entry.Name()
+			if !entry.IsDir() {
+				continue
+			}
+			_, found, err := m.queue.GetJob(ctx, jobID)
+			if err != nil {
+				m.logErrorJob("", "orphan job dir sweep failed: %v", err)
+				return
+			}
+			jobDir := filepath.Join(jobsDir, jobID)
+			if found || pathHoldsAny(jobDir, inUse) {
+				continue
+			}
+			runtimeDirs, _ := filepath.Glob(filepath.Join(jobDir, "runtime*"))
+			for _, runtimeDir := range runtimeDirs {
+				_ = m.removeRuntimePath(ctx, runtimeDir)
+			}
+			if err := os.RemoveAll(jobDir); err != nil {
+				m.logWarnJob(jobID, "orphan job dir cleanup failed path=%s err=%v", jobDir, err)
+				continue
+			}
+			m.logInfoJob(jobID, "removed orphan job dir path=%s", jobDir)
+		}
+	}
+}
+
+// removeRuntimePath removes a runtime clone through statefs, which knows how
+// to drop btrfs subvolumes, and falls back to a plain remove.
+func (m *PrepareService) removeRuntimePath(ctx context.Context, path string) error {
+	if m.statefs != nil {
+		if err := m.statefs.RemovePath(ctx, path); err == nil {
+			return nil
+		}
+	}
+	return os.RemoveAll(path)
+}
+
+// jobRoots returns the roots holding job dirs for namespace: the state store
+// root and, when workdir.root is set, the scratch root.
+func (m *PrepareService) jobRoots(namespace string) []string {
+	roots := []string{m.namespaceRoot(namespace)}
+	if workRoot := m.workRoot(namespace); workRoot != roots[0] {
+		roots = append(roots, workRoot)
+	}
+	return roots
+}
+
+// jobsDirs returns the jobs dirs of every namespace present on disk.
+func (m *PrepareService) jobsDirs() []string {
+	return m.namespaceDirs("jobs")
+}
+
+// namespaceDirs returns the dirs called name in every namespace present on
+// disk, under the state store root and the scratch root.
+func (m *PrepareService) namespaceDirs(name string) []string {
+	bases := []string{m.stateStoreRoot}
+	if m.workDirRoot != "" && filepath.Clean(m.workDirRoot) != filepath.Clean(m.stateStoreRoot) {
+		bases = append(bases, m.workDirRoot)
+	}
+	var dirs []string
+	for _, base := range bases {
+		dirs = append(dirs, filepath.Join(base, name))
+		namespaces, err := os.ReadDir(filepath.Join(base, "ns"))
+		if err != nil {
+			continue
+		}
+		for _, ns := range namespaces {
+			if ns.IsDir() {
+				dirs = append(dirs, filepath.Join(base, "ns", ns.Name(), name))
+			}
+		}
+	}
+	return dirs
+}
+
+// pathHoldsAny reports whether any of paths is dir or lies inside it.
+func pathHoldsAny(dir string, paths []string) bool {
+	for _, path := range paths {
+		if rel, err := filepath.Rel(dir, path); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,126 @@
+package prepare
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sqlrs/engine-local/internal/prepare/queue"
+	"github.com/sqlrs/engine-local/internal/store"
+)
+
+func TestRecoverResetsStaleRuntimeDirOfRunningJob(t *testing.T) {
+	stateRoot := t.TempDir()
+	queueStore := newQueueStore(t)
+	createRecoverableJob(t, queueStore, "job-1", StatusRunning)
+
+	runtimeDir := filepath.Join(stateRoot, "jobs", "job-1", "runtime")
+	writeStaleRuntime(t, runtimeDir)
+	writeStaleRuntime(t, runtimeDir+".stale-1")
+
+	// The reset in startRuntime cannot always clear a leftover clone (a btrfs
+	// subvolume, files owned by the container user); make it leave the stale
+	// dir in place so only recovery can clean it.
+	originalRemoveAll := removeAllFn
+	removeAllFn = func(path string) error {
+		if path == runtimeDir {
+			return nil
+		}
+		return os.RemoveAll(path)
+	}
+	t.Cleanup(func() { removeAllFn = originalRemoveAll })
+
+	sfs := &fakeStateFS{}
+	mgr := newManagerWithDeps(t, &fakeStore{}, queueStore, &testDeps{
+		stateRoot: stateRoot,
+		runtime:   &fakeRuntime{},
+		statefs:   sfs,
+	})
+	if err := mgr.Recover(context.Background()); err != nil {
+		t.Fatalf("Recover: %v", err)
+	}
+
+	status, ok := mgr.Get("job-1")
+	if !ok || status.Status != StatusSucceeded {
+		t.Fatalf("expected recovered job to succeed, got %+v", status)
+	}
+	if len(sfs.removeCalls) < 2 || sfs.removeCalls[0] != runtimeDir || sfs.removeCalls[1] != runtimeDir+".stale-1" {
+		t.Fatalf("expected runtime dirs removed through statefs, got %v", sfs.removeCalls)
+	}
+	if _, err := os.Stat(runtimeDir + ".stale-1"); !os.IsNotExist(err) {
+		t.Fatalf("expected set-aside runtime dir removed, got %v", err)
+	}
+}
+
+func TestRecoverSweepsOrphanJobDirs(t *testing.T) {
+	stateRoot := t.TempDir()
+	workRoot := t.TempDir()
+	queueStore := newQueueStore(t)
+	createRecoverableJob(t, queueStore, "job-kept", StatusSucceeded)
+
+	orphan := filepath.Join(stateRoot, "jobs", "job-gone")
+	writeStaleRuntime(t, filepath.Join(orphan, "runtime"))
+	orphanWork := filepath.Join(workRoot, "ns", "team", "jobs", "job-gone-too")
+	writeStaleRuntime(t, filepath.Join(orphanWork, "runtime"))
+	kept := filepath.Join(stateRoot, "jobs", "job-kept")
+	writeStaleRuntime(t, filepath.Join(kept, "runtime"))
+	instanceDir := filepath.Join(stateRoot, "jobs", "job-deleted-with-instance", "runtime")
+	writeStaleRuntime(t, instanceDir)
+
+	mgr := newManagerWithDeps(t, &fakeStore{
+		listInstances: []store.InstanceEntry{{InstanceID: "inst-1", RuntimeDir: &instanceDir}},
+	}, queueStore, &testDeps{
+		stateRoot: stateRoot,
+		workRoot:  workRoot,
+		runtime:   &fakeRuntime{},
+		statefs:   &fakeStateFS{},
+	})
+	if err := mgr.Recover(context.Background()); err != nil {
+		t.Fatalf("Recover: %v", err)
+	}
+
+	for _, path := range []string{orphan, orphanWork} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Fatalf("expected orphan job dir %s removed, got %v", path, err)
+		}
+	}
+	for _, path := range []string{kept, instanceDir} {
+		if _, err := os.Stat(path); err != nil {
+			t.Fatalf("expected job dir %s kept: %v", path, err)
+		}
+	}
+}
+
+func createRecoverableJob(t *testing.T, queueStore queue.Store, jobID string, status string) {
+	t.Helper()
+	reqJSON, err := jsonMarshal(Request{
+		PrepareKind: "psql",
+		ImageID:     "image-1",
+		PsqlArgs:    []string{"-c", "select 1"},
+	})
+	if err != nil {
+		t.Fatalf("jsonMarshal: %v", err)
+	}
+	if err := queueStore.CreateJob(context.Background(), queue.JobRecord{
+		JobID:       jobID,
+		Status:      status,
+		PrepareKind: "psql",
+		ImageID:     "image-1",
+		RequestJSON: &reqJSON,
+		CreatedAt:   time.Now().UTC().Format(time.RFC3339Nano),
+	}); err != nil {
+		t.Fatalf("CreateJob: %v", err)
+	}
+}
+
+func writeStaleRuntime(t *testing.T, dir string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		t.Fatalf("mkdir runtime dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "postmaster.pid"), []byte("4242\n"), 0o600); err != nil {
+		t.Fatalf("write postmaster.pid: %v", err)
+	}
+}
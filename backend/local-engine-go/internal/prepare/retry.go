@@ -0,0 +1,96 @@
+package prepare
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sqlrs/engine-local/internal/prepare/queue"
+)
+
+// Retry requeues a failed job under the same id. Succeeded tasks are kept as
+// long as the states they produced are still cached; the first task that
+// failed, never finished, or lost its state is reset to queued together with
+// every task after it. The bool result reports whether the job exists.
+func (m *PrepareService) Retry(ctx context.Context, jobID string) (Accepted, bool, error) {
+	if m.isDraining() {
+		return Accepted{}, true, UnavailableError{Code: ErrorCodeUnavailable, Message: "engine is shutting down"}
+	}
+	m.retryMu.Lock()
+	defer m.retryMu.Unlock()
+
+	job, ok, err := m.queue.GetJob(ctx, jobID)
+	if err != nil || !ok {
+		return Accepted{}, ok, err
+	}
+	if job.Status != StatusFailed || m.getRunner(jobID) != nil {
+		return Accepted{}, true, ConflictError{Code: ErrorCodeConflict, Message: "only failed jobs can be retried", Details: job.Status}
+	}
+	prepared, err := m.prepareFromJob(job)
+	if err != nil {
+		return Accepted{}, true, err
+	}
+	records, err := m.queue.ListTasks(ctx, jobID)
+	if err != nil {
+		return Accepted{}, true, err
+	}
+	kept, err := m.resetTasksForRetry(records)
+	if err != nil {
+		return Accepted{}, true, err
+	}
+	if err := m.queue.ReplaceTasks(ctx, jobID, records); err != nil {
+		return Accepted{}, true, err
+	}
+	if err := m.queue.UpdateJob(ctx, jobID, queue.JobUpdate{
+		Status:       strPtr(StatusQueued),
+		ResetOutcome: true,
+	}); err != nil {
+		return Accepted{}, true, err
+	}
+	m.logInfoJob(jobID, "retry requested kept_tasks=%d reset_tasks=%d", kept, len(records)-kept)
+	_ = m.appendEvent(jobID, Event{
+		Type:    "status",
+		Ts:      m.now().UTC().Format(time.RFC3339Nano),
+		Status:  StatusQueued,
+		Message: fmt.Sprintf("retry: reusing %d of %d tasks", kept, len(records)),
+	})
+
+	if m.async {
+		go m.runJob(prepared, jobID)
+	} else {
+		m.runJob(prepared, jobID)
+	}
+	return acceptedFor(jobID, StatusQueued), true, nil
+}
+
+// resetTasksForRetry keeps the leading run of succeeded tasks whose output
+// states still exist and resets the rest to queued. It returns how many
+// tasks were kept.
+func (m *PrepareService) resetTasksForRetry(records []queue.TaskRecord) (int, error) {
+	kept := 0
+	for ; kept < len(records); kept++ {
+		task := records[kept]
+		if task.Status != StatusSucceeded {
+			break
+		}
+		if task.Type != "state_execute" {
+			continue
+		}
+		outputID := strings.TrimSpace(valueOrEmpty(task.OutputStateID))
+		cached, err := m.isStateCached(outputID)
+		if err != nil {
+			return 0, err
+		}
+		if !cached {
+			break
+		}
+	}
+	for i := kept; i < len(records); i++ {
+		records[i].Status = StatusQueued
+		records[i].StartedAt = nil
+		records[i].FinishedAt = nil
+		records[i].ErrorJSON = nil
+	}
+	return kept, nil
+}
@@ -0,0 +1,93 @@
+package prepare
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/sqlrs/engine-local/internal/prepare/queue"
+	"github.com/sqlrs/engine-local/internal/store"
+)
+
+func TestRetryResumesFailedJob(t *testing.T) {
+	psql := &fakePsqlRunner{err: errors.New("boom"), output: "psql failed"}
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{psql: psql})
+	accepted, err := mgr.Submit(context.Background(), Request{
+		PrepareKind: "psql",
+		ImageID:     "image-1@sha256:abc",
+		PsqlArgs:    []string{"-c", "select 1"},
+	})
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	status, ok := mgr.Get(accepted.JobID)
+	if !ok || status.Status != StatusFailed {
+		t.Fatalf("expected failed job, got %+v", status)
+	}
+
+	psql.err = nil
+	retried, found, err := mgr.Retry(context.Background(), accepted.JobID)
+	if err != nil || !found {
+		t.Fatalf("Retry: found=%v err=%v", found, err)
+	}
+	if retried.JobID != accepted.JobID {
+		t.Fatalf("expected retry to keep job id %q, got %q", accepted.JobID, retried.JobID)
+	}
+	status, ok = mgr.Get(accepted.JobID)
+	if !ok || status.Status != StatusSucceeded || status.Error != nil {
+		t.Fatalf("expected succeeded job after retry, got %+v", status)
+	}
+	if len(psql.runs) != 2 {
+		t.Fatalf("expected psql to run again, got %d runs", len(psql.runs))
+	}
+}
+
+func TestRetryRejectsJobsThatDidNotFail(t *testing.T) {
+	mgr := newManagerWithQueue(t, &fakeStore{}, newQueueStore(t))
+	if err := mgr.queue.CreateJob(context.Background(), queue.JobRecord{
+		JobID:       "job-succeeded",
+		Status:      StatusSucceeded,
+		PrepareKind: "psql",
+		ImageID:     "image-1",
+		CreatedAt:   "2026-01-01T00:00:00Z",
+	}); err != nil {
+		t.Fatalf("create job: %v", err)
+	}
+	_, found, err := mgr.Retry(context.Background(), "job-succeeded")
+	var conflict ConflictError
+	if !found || !errors.As(err, &conflict) {
+		t.Fatalf("expected conflict, got found=%v err=%v", found, err)
+	}
+	if _, found, err := mgr.Retry(context.Background(), "missing"); found || err != nil {
+		t.Fatalf("expected not found, got found=%v err=%v", found, err)
+	}
+}
+
+func TestResetTasksForRetryKeepsCachedPrefix(t *testing.T) {
+	mgr := &PrepareService{store: &fakeStore{statesByID: map[string]store.StateEntry{
+		"state-0": {StateID: "state-0"},
+	}}}
+	errJSON := `{"code":"internal_error"}`
+	records := []queue.TaskRecord{
+		{TaskID: "plan", Type: "plan", Status: StatusSucceeded},
+		{TaskID: "execute-0", Type: "state_execute", Status: StatusSucceeded, OutputStateID: strPtr("state-0")},
+		{TaskID: "execute-1", Type: "state_execute", Status: StatusSucceeded, OutputStateID: strPtr("state-1")},
+		{TaskID: "execute-2", Type: "state_execute", Status: StatusFailed, ErrorJSON: &errJSON},
+	}
+	kept, err := mgr.resetTasksForRetry(records)
+	if err != nil {
+		t.Fatalf("resetTasksForRetry: %v", err)
+	}
+	if kept != 2 {
+		t.Fatalf("expected two kept tasks, got %d", kept)
+	}
+	for i, task := range records {
+		want := StatusQueued
+		if i < kept {
+			want = StatusSucceeded
+		}
+		if task.Status != want || (i >= kept && task.ErrorJSON != nil) {
+			t.Fatalf("unexpected task %s: %+v", task.TaskID, task)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package prepare
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	engineRuntime "github.com/sqlrs/engine-local/internal/runtime"
+)
+
+const (
+	defaultRuntimeRetryMaxAttempts = 3
+	defaultRuntimeRetryBaseDelay   = 500 * time.Millisecond
+)
+
+type runtimeRetrySettings struct {
+	maxAttempts int
+	baseDelay   time.Duration
+}
+
+var runtimeRetrySleep = func(ctx context.Context, delay time.Duration) error {
+	if delay <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// loadRuntimeRetrySettings reads container.retry.*; missing or invalid values
+// fall back to the defaults so a bad config never blocks prepare jobs.
+func (m *PrepareService) loadRuntimeRetrySettings() runtimeRetrySettings {
+	settings := runtimeRetrySettings{
+		maxAttempts: defaultRuntimeRetryMaxAttempts,
+		baseDelay:   defaultRuntimeRetryBaseDelay,
+	}
+	if m.config == nil {
+		return settings
+	}
+	if value, err := m.config.Get("container.retry.maxAttempts", true); err == nil && value != nil {
+		if parsed, ok := asInt64(value); ok && parsed >= 1 {
+			settings.maxAttempts = int(parsed)
+		}
+	}
+	if value, err := m.config.Get("container.retry.baseDelay", true); err == nil && value != nil {
+		if raw, ok := value.(string); ok {
+			if parsed, err := time.ParseDuration(strings.TrimSpace(raw)); err == nil && parsed >= 0 {
+				settings.baseDelay = parsed
+			}
+		}
+	}
+	return settings
+}
+
+// withRuntimeRetry runs fn and retries transient container runtime errors
+// with exponential backoff. Permanent errors are returned immediately.
+func (m *PrepareService) withRuntimeRetry(ctx context.Context, jobID string, op string, fn func() error) error {
+	settings := m.loadRuntimeRetrySettings()
+	delay := settings.baseDelay
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		if attempt >= settings.maxAttempts || ctx.Err() != nil || !engineRuntime.IsRetryableError(err) {
+			return err
+		}
+		m.logWarnJob(jobID, "runtime %s failed attempt=%d/%d err=%v", op, attempt, settings.maxAttempts, err)
+		if sleepErr := runtimeRetrySleep(ctx, delay); sleepErr != nil {
+			return err
+		}
+		m.appendLog(jobID, fmt.Sprintf("retrying %s (attempt %d/%d)", op, attempt+1, settings.maxAttempts))
+		delay *= 2
+	}
+}
@@ -0,0 +1,148 @@
+package prepare
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sqlrs/engine-local/internal/prepare/queue"
+)
+
+func overrideRuntimeRetrySleep(t *testing.T) *[]time.Duration {
+	t.Helper()
+	prev := runtimeRetrySleep
+	var delays []time.Duration
+	runtimeRetrySleep = func(ctx context.Context, delay time.Duration) error {
+		delays = append(delays, delay)
+		return ctx.Err()
+	}
+	t.Cleanup(func() { runtimeRetrySleep = prev })
+	return &delays
+}
+
+func newRetryTestManager(t *testing.T, cfg *fakeConfigStore) (*PrepareService, queue.Store) {
+	t.Helper()
+	queueStore := newQueueStore(t)
+	mgr := newManagerWithDeps(t, &fakeStore{}, queueStore, &testDeps{config: cfg})
+	if err := queueStore.CreateJob(context.Background(), queue.JobRecord{
+		JobID:       "job-1",
+		Status:      StatusRunning,
+		PrepareKind: "psql",
+		ImageID:     "image-1",
+		CreatedAt:   "2026-02-22T10:00:00Z",
+	}); err != nil {
+		t.Fatalf("create job: %v", err)
+	}
+	return mgr, queueStore
+}
+
+func TestWithRuntimeRetryRetriesTransientErrors(t *testing.T) {
+	delays := overrideRuntimeRetrySleep(t)
+	mgr, queueStore := newRetryTestManager(t, &fakeConfigStore{values: map[string]any{
+		"container.retry.maxAttempts": float64(3),
+		"container.retry.baseDelay":   "100ms",
+	}})
+
+	calls := 0
+	err := mgr.withRuntimeRetry(context.Background(), "job-1", "container start", func() error {
+		calls++
+		if calls < 3 {
+			return errors.New("dial unix /var/run/docker.sock: connect: connection refused")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("withRuntimeRetry: %v", err)
+	}
+	if calls != 3 {
+		t.Fatalf("expected 3 calls, got %d", calls)
+	}
+	if len(*delays) != 2 || (*delays)[0] != 100*time.Millisecond || (*delays)[1] != 200*time.Millisecond {
+		t.Fatalf("unexpected backoff delays: %v", *delays)
+	}
+
+	events, err := queueStore.ListEventsSince(context.Background(), "job-1", 0)
+	if err != nil {
+		t.Fatalf("ListEventsSince: %v", err)
+	}
+	var messages []string
+	for _, event := range events {
+		if event.Message != nil {
+			messages = append(messages, *event.Message)
+		}
+	}
+	want := []string{"retrying container start (attempt 2/3)", "retrying container start (attempt 3/3)"}
+	if len(messages) != len(want) || messages[0] != want[0] || messages[1] != want[1] {
+		t.Fatalf("unexpected retry log events: %v", messages)
+	}
+}
+
+func TestWithRuntimeRetryStopsAtMaxAttempts(t *testing.T) {
+	overrideRuntimeRetrySleep(t)
+	mgr, _ := newRetryTestManager(t, &fakeConfigStore{values: map[string]any{
+		"container.retry.maxAttempts": float64(2),
+		"container.retry.baseDelay":   "0s",
+	}})
+
+	calls := 0
+	transient := errors.New("postgres readiness timed out")
+	err := mgr.withRuntimeRetry(context.Background(), "job-1", "container start", func() error {
+		calls++
+		return transient
+	})
+	if !errors.Is(err, transient) {
+		t.Fatalf("expected last transient error, got %v", err)
+	}
+	if calls != 2 {
+		t.Fatalf("expected 2 calls, got %d", calls)
+	}
+}
+
+func TestWithRuntimeRetryFailsFastOnPermanentErrors(t *testing.T) {
+	delays := overrideRuntimeRetrySleep(t)
+	mgr, _ := newRetryTestManager(t, &fakeConfigStore{})
+
+	calls := 0
+	err := mgr.withRuntimeRetry(context.Background(), "job-1", "image resolve", func() error {
+		calls++
+		return errors.New("docker pull failed: manifest unknown: image not found")
+	})
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+	if calls != 1 || len(*delays) != 0 {
+		t.Fatalf("expected no retries, got calls=%d delays=%v", calls, *delays)
+	}
+}
+
+func TestWithRuntimeRetryStopsWhenCancelled(t *testing.T) {
+	prev := runtimeRetrySleep
+	t.Cleanup(func() { runtimeRetrySleep = prev })
+	ctx, cancel := context.WithCancel(context.Background())
+	runtimeRetrySleep = func(context.Context, time.Duration) error {
+		cancel()
+		return context.Canceled
+	}
+	mgr, _ := newRetryTestManager(t, &fakeConfigStore{})
+
+	calls := 0
+	err := mgr.withRuntimeRetry(ctx, "job-1", "container start", func() error {
+		calls++
+		return errors.New("connection reset by peer")
+	})
+	if err == nil || calls != 1 {
+		t.Fatalf("expected single attempt with error, got calls=%d err=%v", calls, err)
+	}
+}
+
+func TestLoadRuntimeRetrySettingsDefaults(t *testing.T) {
+	mgr, _ := newRetryTestManager(t, &fakeConfigStore{values: map[string]any{
+		"container.retry.maxAttempts": "bad",
+		"container.retry.baseDelay":   "nope",
+	}})
+	settings := mgr.loadRuntimeRetrySettings()
+	if settings.maxAttempts != defaultRuntimeRetryMaxAttempts || settings.baseDelay != defaultRuntimeRetryBaseDelay {
+		t.Fatalf("expected defaults, got %+v", settings)
+	}
+}
@@ -0,0 +1,108 @@
+package prepare
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// secretEnvPrefix is where EnvSecretProvider looks up secrets.
+const secretEnvPrefix = "SQLRS_SECRET_"
+
+// secretRedacted replaces resolved secret values in job logs and errors.
+const secretRedacted = "***"
+
+var secretRefPattern = regexp.MustCompile(`\$\{secret:([A-Za-z0-9_]+)\}`)
+
+// SecretProvider resolves the ${secret:name} references in liquibase_env and
+// flyway_env values. References are resolved just before the runner starts,
+// so stored requests and the queue database only ever hold the reference.
+type SecretProvider interface {
+	Secret(ctx context.Context, name string) (string, error)
+}
+
+// EnvSecretProvider resolves ${secret:name} from the engine's SQLRS_SECRET_<name>
+// environment variable. It is the default provider.
+type EnvSecretProvider struct{}
+
+func (EnvSecretProvider) Secret(ctx context.Context, name string) (string, error) {
+	value, ok := os.LookupEnv(secretEnvPrefix + name)
+	if !ok {
+		return "", fmt.Errorf("%s%s is not set", secretEnvPrefix, name)
+	}
+	return value, nil
+}
+
+// validateSecretRefs rejects env values that mention ${secret: without being
+// a well-formed reference, so a typo fails at submit instead of passing the
+// literal text to the tool.
+func validateSecretRefs(field string, env map[string]string) error {
+	for key, value := range env {
+		rest := secretRefPattern.ReplaceAllString(value, "")
+		if strings.Contains(rest, "${secret:") {
+			return ValidationError{
+				Code:    ErrorCodeInvalidArgument,
+				Message: field + " has a malformed secret reference",
+				Details: key,
+			}
+		}
+	}
+	return nil
+}
+
+// resolveEnvSecrets returns env with every ${secret:name} reference replaced
+// by its value, and the values so the caller can redact them from output.
+// env itself is left untouched.
+func (m *PrepareService) resolveEnvSecrets(ctx context.Context, env map[string]string) (map[string]string, secretValues, *ErrorResponse) {
+	var names []string
+	for _, value := range env {
+		for _, match := range secretRefPattern.FindAllStringSubmatch(value, -1) {
+			names = append(names, match[1])
+		}
+	}
+	if len(names) == 0 {
+		return env, nil, nil
+	}
+	provider := m.secrets
+	if provider == nil {
+		provider = EnvSecretProvider{}
+	}
+	resolved := map[string]string{}
+	var values secretValues
+	for _, name := range names {
+		if _, ok := resolved[name]; ok {
+			continue
+		}
+		value, err := provider.Secret(ctx, name)
+		if err != nil {
+			return nil, nil, errorResponse(ErrorCodeInvalidArgument, "cannot resolve secret", fmt.Sprintf("%s: %v", name, err))
+		}
+		resolved[name] = value
+		values = append(values, value)
+	}
+	out := make(map[string]string, len(env))
+	for key, value := range env {
+		out[key] = secretRefPattern.ReplaceAllStringFunc(value, func(ref string) string {
+			return resolved[secretRefPattern.FindStringSubmatch(ref)[1]]
+		})
+	}
+	// Longer values first, so a secret containing another is redacted whole.
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+	return out, values, nil
+}
+
+// secretValues are the resolved secrets of one runner call.
+type secretValues []string
+
+// redact replaces every secret value in text.
+func (s secretValues) redact(text string) string {
+	for _, value := range s {
+		if value != "" {
+			text = strings.ReplaceAll(text, value, secretRedacted)
+		}
+	}
+	return text
+}
@@ -0,0 +1,132 @@
+package prepare
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	engineRuntime "github.com/sqlrs/engine-local/internal/runtime"
+)
+
+type fakeSecretProvider struct {
+	values map[string]string
+	calls  []string
+}
+
+func (f *fakeSecretProvider) Secret(ctx context.Context, name string) (string, error) {
+	f.calls = append(f.calls, name)
+	value, ok := f.values[name]
+	if !ok {
+		return "", errors.New("not found")
+	}
+	return value, nil
+}
+
+func TestExecuteLiquibaseStepResolvesSecretsAndRedactsLogs(t *testing.T) {
+	queueStore := newQueueStore(t)
+	createRecoverableJob(t, queueStore, "job-1", StatusRunning)
+	liquibase := &fakeLiquibaseRunner{output: "connected as app with password hunter2"}
+	mgr := newManagerWithDeps(t, &fakeStore{}, queueStore, &testDeps{liquibase: liquibase})
+	secrets := &fakeSecretProvider{values: map[string]string{"db_pw": "hunter2"}}
+	mgr.secrets = secrets
+	env := map[string]string{
+		"LIQUIBASE_COMMAND_PASSWORD": "${secret:db_pw}",
+		"JDBC_PROPS":                 "password=${secret:db_pw};ssl=true",
+		"LIQUIBASE_LOG_LEVEL":        "info",
+	}
+	prepared := preparedRequest{
+		request:        Request{PrepareKind: "lb", LiquibaseEnv: env},
+		normalizedArgs: []string{"update"},
+	}
+	rt := &jobRuntime{instance: engineRuntime.Instance{ID: "container-1", Host: "127.0.0.1", Port: 5432}}
+
+	if errResp := mgr.executeLiquibaseStep(context.Background(), "job-1", prepared, rt, taskState{}); errResp != nil {
+		t.Fatalf("executeLiquibaseStep: %+v", errResp)
+	}
+	got := liquibase.runs[0].Env
+	if got["LIQUIBASE_COMMAND_PASSWORD"] != "hunter2" || got["JDBC_PROPS"] != "password=hunter2;ssl=true" || got["LIQUIBASE_LOG_LEVEL"] != "info" {
+		t.Fatalf("unexpected resolved env: %+v", got)
+	}
+	if len(secrets.calls) != 1 {
+		t.Fatalf("expected one lookup per secret, got %v", secrets.calls)
+	}
+	if env["LIQUIBASE_COMMAND_PASSWORD"] != "${secret:db_pw}" {
+		t.Fatalf("expected the request to keep the reference, got %+v", env)
+	}
+
+	events, err := mgr.queue.ListEventsSince(context.Background(), "job-1", 0)
+	if err != nil {
+		t.Fatalf("ListEventsSince: %v", err)
+	}
+	var redacted bool
+	for _, event := range events {
+		message := valueOrEmpty(event.Message)
+		if strings.Contains(message, "hunter2") {
+			t.Fatalf("secret leaked into log event: %q", message)
+		}
+		if strings.Contains(message, "password ***") {
+			redacted = true
+		}
+	}
+	if !redacted {
+		t.Fatalf("expected redacted liquibase output in events, got %+v", events)
+	}
+}
+
+func TestExecuteLiquibaseStepRedactsSecretsFromErrors(t *testing.T) {
+	liquibase := &fakeLiquibaseRunner{err: errors.New("login failed for hunter2")}
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{liquibase: liquibase})
+	mgr.secrets = &fakeSecretProvider{values: map[string]string{"db_pw": "hunter2"}}
+	prepared := preparedRequest{
+		request:        Request{PrepareKind: "lb", LiquibaseEnv: map[string]string{"PASSWORD": "${secret:db_pw}"}},
+		normalizedArgs: []string{"update"},
+	}
+	rt := &jobRuntime{instance: engineRuntime.Instance{ID: "container-1", Host: "127.0.0.1", Port: 5432}}
+
+	errResp := mgr.executeLiquibaseStep(context.Background(), "job-1", prepared, rt, taskState{})
+	if errResp == nil || errResp.Details != "login failed for ***" {
+		t.Fatalf("expected redacted error details, got %+v", errResp)
+	}
+}
+
+func TestExecuteFlywayStepReadsSecretsFromEnvironment(t *testing.T) {
+	t.Setenv("SQLRS_SECRET_flyway_pw", "s3cret")
+	flyway := &fakeFlywayRunner{output: "ok"}
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{flyway: flyway})
+	rt := &jobRuntime{instance: engineRuntime.Instance{ID: "container-1", Host: "127.0.0.1", Port: 5432}}
+	prepared := preparedRequest{
+		request:        Request{PrepareKind: "flyway", FlywayEnv: map[string]string{"FLYWAY_PASSWORD": "${secret:flyway_pw}"}},
+		normalizedArgs: []string{"migrate"},
+	}
+
+	if errResp := mgr.executeFlywayStep(context.Background(), "job-1", prepared, rt, taskState{}); errResp != nil {
+		t.Fatalf("executeFlywayStep: %+v", errResp)
+	}
+	if flyway.runs[0].Env["FLYWAY_PASSWORD"] != "s3cret" {
+		t.Fatalf("unexpected resolved env: %+v", flyway.runs[0].Env)
+	}
+
+	prepared.request.FlywayEnv = map[string]string{"FLYWAY_PASSWORD": "${secret:missing}"}
+	errResp := mgr.executeFlywayStep(context.Background(), "job-1", prepared, rt, taskState{})
+	if errResp == nil || errResp.Code != ErrorCodeInvalidArgument || !strings.Contains(errResp.Details, "SQLRS_SECRET_missing") {
+		t.Fatalf("expected missing secret error, got %+v", errResp)
+	}
+	if len(flyway.runs) != 1 {
+		t.Fatalf("expected flyway not to run without its secrets, got %d runs", len(flyway.runs))
+	}
+}
+
+func TestPrepareRequestRejectsMalformedSecretRefs(t *testing.T) {
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{})
+	_, err := mgr.prepareRequest(Request{
+		PrepareKind:   "lb",
+		ImageID:       "image-1",
+		LiquibaseArgs: []string{"update"},
+		LiquibaseEnv:  map[string]string{"PASSWORD": "${secret:db-pw}"},
+	})
+	var validation ValidationError
+	if !errors.As(err, &validation) || !strings.Contains(validation.Message, "liquibase_env") || validation.Details != "PASSWORD" {
+		t.Fatalf("expected malformed reference error, got %v", err)
+	}
+}
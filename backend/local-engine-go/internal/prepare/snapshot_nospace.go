@@ -0,0 +1,129 @@
+package prepare
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// snapshotStateDir runs snapshot, which writes the job's data dir into
+// buildDir. A snapshot that runs out of space is retried once after an
+// eviction pass sized for it; if it runs out of space again the job fails
+// with resource_exhausted carrying the usage and free-space figures.
+func (m *PrepareService) snapshotStateDir(ctx context.Context, jobID string, dataDir string, buildDir string, snapshot func() error) *ErrorResponse {
+	err := snapshot()
+	if err == nil {
+		return nil
+	}
+	if resp := snapshotErrorResponse(err); resp != nil {
+		return resp
+	}
+	m.appendLog(jobID, "snapshot: no space left on device, evicting cached states and retrying")
+	m.logInfoJob(jobID, "snapshot out of space, evicting and retrying: %v", err)
+	if resetErr := resetStateDir(ctx, m.statefs, buildDir); resetErr != nil {
+		return m.snapshotNoSpaceError(dataDir, err, evictionSummary{})
+	}
+	eviction := m.evictForSnapshot(ctx, jobID, dataDir)
+	err = snapshot()
+	if err == nil {
+		m.appendLog(jobID, "snapshot: retry succeeded")
+		return nil
+	}
+	if resp := snapshotErrorResponse(err); resp != nil {
+		return resp
+	}
+	return m.snapshotNoSpaceError(dataDir, err, eviction)
+}
+
+// snapshotErrorResponse maps a snapshot error that is not a full disk; it
+// returns nil for a full disk.
+func snapshotErrorResponse(err error) *ErrorResponse {
+	if quotaResp := quotaErrorResponse("snapshot", err); quotaResp != nil {
+		return quotaResp
+	}
+	if isNoSpaceError(err) {
+		return nil
+	}
+	return errorResponse(ErrorCodeSnapshotFailed, "snapshot failed", err.Error())
+}
+
+// evictForSnapshot evicts cached states until the store has room for the
+// snapshot plus cache.capacity.reserveBytes. It is best effort: a failure to
+// measure or evict leaves the retry to report the full disk.
+func (m *PrepareService) evictForSnapshot(ctx context.Context, jobID string, dataDir string) evictionSummary {
+	if m.config == nil || strings.TrimSpace(m.stateStoreRoot) == "" {
+		return evictionSummary{}
+	}
+	totalBytes, freeBytes, err := filesystemStatsFn(m.stateStoreRoot)
+	if err != nil {
+		return evictionSummary{}
+	}
+	settings, err := m.loadCapacitySettings(totalBytes)
+	if err != nil {
+		return evictionSummary{}
+	}
+	usageBytes, err := cacheUsageFn(m.stateStoreRoot)
+	if err != nil {
+		return evictionSummary{}
+	}
+	settings.ReserveBytes += m.snapshotEstimate(dataDir)
+	eviction := evictionSummary{}
+	lockErr := withEvictLock(ctx, m.stateStoreRoot, func() error {
+		summary, runErr := m.runEviction(ctx, jobID, settings, usageBytes, freeBytes)
+		eviction = summary
+		return runErr
+	})
+	if lockErr != nil {
+		m.logErrorJob(jobID, "snapshot eviction failed: %v", lockErr)
+	}
+	if eviction.EvictedCount > 0 {
+		m.appendLog(jobID, fmt.Sprintf("cache: evicted %d state(s), reclaimed %d bytes", eviction.EvictedCount, eviction.FreedBytes))
+	}
+	return eviction
+}
+
+// snapshotEstimate is the expected snapshot size: the runtime data dir,
+// except on btrfs where the snapshot shares its extents.
+func (m *PrepareService) snapshotEstimate(dataDir string) int64 {
+	if m.statefs != nil && m.statefs.Kind() == "btrfs" {
+		return 0
+	}
+	estimate, err := storeUsageFn(dataDir)
+	if err != nil || estimate < 0 {
+		return 0
+	}
+	return estimate
+}
+
+// snapshotNoSpaceError reports a snapshot that ran out of space after the
+// eviction retry. Figures that cannot be measured are left out.
+func (m *PrepareService) snapshotNoSpaceError(dataDir string, err error, eviction evictionSummary) *ErrorResponse {
+	details := map[string]any{
+		"phase":                    "snapshot",
+		"error":                    err.Error(),
+		"estimated_snapshot_bytes": m.snapshotEstimate(dataDir),
+		"evicted_count":            eviction.EvictedCount,
+		"freed_bytes":              eviction.FreedBytes,
+		"hint":                     "free space on the state store filesystem, remove unused states (sqlrs states prune) or lower cache.capacity.maxBytes",
+	}
+	message := "state store is out of space for the snapshot"
+	if strings.TrimSpace(m.stateStoreRoot) != "" {
+		if totalBytes, freeBytes, statErr := filesystemStatsFn(m.stateStoreRoot); statErr == nil {
+			details["store_total_bytes"] = totalBytes
+			details["free_bytes"] = freeBytes
+			message = fmt.Sprintf("%s: %d bytes free", message, freeBytes)
+			if m.config != nil {
+				if settings, settingsErr := m.loadCapacitySettings(totalBytes); settingsErr == nil {
+					details["max_bytes"] = settings.MaxBytes
+					details["effective_max_bytes"] = settings.EffectiveMax
+					details["reserve_bytes"] = settings.ReserveBytes
+				}
+			}
+		}
+		if usageBytes, usageErr := cacheUsageFn(m.stateStoreRoot); usageErr == nil {
+			details["usage_bytes"] = usageBytes
+			message = fmt.Sprintf("%s, cache uses %d bytes", message, usageBytes)
+		}
+	}
+	return capacityError(ErrorCodeResourceExhausted, message, details)
+}
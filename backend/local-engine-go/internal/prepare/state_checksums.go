@@ -0,0 +1,194 @@
+package prepare
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/sqlrs/engine-local/internal/config"
+	"github.com/sqlrs/engine-local/internal/statefs"
+)
+
+// stateChecksumsEnabled reports whether statefs.verifyChecksums is on. It is
+// off by default because both writing and checking a manifest read every file
+// of the state.
+func stateChecksumsEnabled(cfg config.Store) bool {
+	if cfg == nil {
+		return false
+	}
+	value, err := cfg.Get("statefs.verifyChecksums", true)
+	if err != nil {
+		return false
+	}
+	enabled, ok := value.(bool)
+	return ok && enabled
+}
+
+func (m *PrepareService) stateChecksummer() (statefs.Checksummer, bool) {
+	if !stateChecksumsEnabled(m.config) {
+		return nil, false
+	}
+	checksummer, ok := m.statefs.(statefs.Checksummer)
+	return checksummer, ok
+}
+
+// writeStateChecksums records the manifest of a freshly snapshotted state.
+func (m *PrepareService) writeStateChecksums(ctx context.Context, jobID string, stateID string, stateDir string) *ErrorResponse {
+	checksummer, ok := m.stateChecksummer()
+	if !ok {
+		return nil
+	}
+	if err := checksummer.WriteChecksums(ctx, stateDir); err != nil {
+		if noSpaceResp := noSpaceErrorResponse("insufficient storage during snapshot", "snapshot", err); noSpaceResp != nil {
+			return noSpaceResp
+		}
+		return errorResponse(ErrorCodeInternal, "cannot write state checksums", err.Error())
+	}
+	m.markStateVerified(jobID, stateID)
+	m.logInfoJob(jobID, "state checksums written dir=%s", stateDir)
+	return nil
+}
+
+// stateVerified reports whether the job already checked (or wrote) the
+// checksums of stateID during this run.
+func (m *PrepareService) stateVerified(jobID string, stateID string) bool {
+	runner := m.getRunner(jobID)
+	if runner == nil {
+		return false
+	}
+	runner.mu.Lock()
+	defer runner.mu.Unlock()
+	return runner.verifiedStates[stateID]
+}
+
+func (m *PrepareService) markStateVerified(jobID string, stateID string) {
+	runner := m.getRunner(jobID)
+	if runner == nil || strings.TrimSpace(stateID) == "" {
+		return
+	}
+	runner.mu.Lock()
+	defer runner.mu.Unlock()
+	if runner.verifiedStates == nil {
+		runner.verifiedStates = map[string]bool{}
+	}
+	runner.verifiedStates[stateID] = true
+}
+
+// stateVerificationPending reports whether a cached state still has to go
+// through the cache decision in executeStateTask to be verified.
+func (m *PrepareService) stateVerificationPending(jobID string, stateID string) bool {
+	if _, ok := m.stateChecksummer(); !ok {
+		return false
+	}
+	return !m.stateVerified(jobID, stateID)
+}
+
+// takeCorruptState returns and clears the input state a clone exposed as
+// corrupt during the job's last task.
+func (m *PrepareService) takeCorruptState(jobID string) string {
+	runner := m.getRunner(jobID)
+	if runner == nil {
+		return ""
+	}
+	runner.mu.Lock()
+	defer runner.mu.Unlock()
+	stateID := runner.corruptState
+	runner.corruptState = ""
+	return stateID
+}
+
+// invalidateCorruptCachedState checks a cached state against its manifest and
+// drops it on mismatch so the caller rebuilds it. States created without a
+// manifest are trusted. Each state is checked at most once per job.
+func (m *PrepareService) invalidateCorruptCachedState(ctx context.Context, jobID string, prepared preparedRequest, stateID string) (bool, *ErrorResponse) {
+	checksummer, ok := m.stateChecksummer()
+	if !ok || strings.TrimSpace(stateID) == "" || m.stateVerified(jobID, stateID) {
+		return false, nil
+	}
+	entry, found, err := m.store.GetState(ctx, stateID)
+	if err != nil {
+		return false, errorResponse(ErrorCodeInternal, "cannot load cached state", err.Error())
+	}
+	imageID := prepared.effectiveImageID()
+	if found && strings.TrimSpace(entry.ImageID) != "" {
+		imageID = entry.ImageID
+	}
+	paths, err := resolveStatePaths(m.namespaceRoot(prepared.request.Namespace), imageID, stateID, m.statefs)
+	if err != nil {
+		return false, errorResponse(ErrorCodeInternal, "cannot resolve cached state paths", err.Error())
+	}
+	verifyErr := checksummer.VerifyChecksums(ctx, paths.stateDir, paths.stateDir)
+	if verifyErr == nil || errors.Is(verifyErr, statefs.ErrNoChecksumManifest) {
+		m.markStateVerified(jobID, stateID)
+		return false, nil
+	}
+	if !errors.Is(verifyErr, statefs.ErrChecksumMismatch) {
+		return false, errorResponse(ErrorCodeInternal, "cannot verify cached state checksums", verifyErr.Error())
+	}
+	m.appendLog(jobID, fmt.Sprintf("statefs: cached state %s failed checksum verification, rebuilding: %v", stateID, verifyErr))
+	if errResp := m.dropCorruptState(ctx, stateID, paths.stateDir); errResp != nil {
+		return false, errResp
+	}
+	return true, nil
+}
+
+// verifyCloneChecksums checks a runtime clone of an input state that was not
+// already verified during this job. On a mismatch the source state itself is
+// checked: a corrupt source is invalidated and recorded on the job runner so
+// runJob rebuilds it through the producing task; an intact source means only
+// the clone was damaged and the state is kept.
+func (m *PrepareService) verifyCloneChecksums(ctx context.Context, jobID string, stateID string, stateDir string, cloneDir string) *ErrorResponse {
+	checksummer, ok := m.stateChecksummer()
+	if !ok || m.stateVerified(jobID, stateID) {
+		return nil
+	}
+	verifyErr := checksummer.VerifyChecksums(ctx, stateDir, cloneDir)
+	if verifyErr == nil {
+		m.markStateVerified(jobID, stateID)
+		return nil
+	}
+	if errors.Is(verifyErr, statefs.ErrNoChecksumManifest) {
+		m.logInfoJob(jobID, "state checksums skipped state=%s reason=no_manifest", stateID)
+		return nil
+	}
+	if !errors.Is(verifyErr, statefs.ErrChecksumMismatch) {
+		return errorResponse(ErrorCodeInternal, "cannot verify state clone checksums", verifyErr.Error())
+	}
+	sourceErr := checksummer.VerifyChecksums(ctx, stateDir, stateDir)
+	if sourceErr == nil {
+		m.appendLog(jobID, fmt.Sprintf("statefs: clone of state %s failed checksum verification, the state itself is intact: %v", stateID, verifyErr))
+		return errorResponse(ErrorCodeStateCorrupt, "state clone failed checksum verification", verifyErr.Error())
+	}
+	m.appendLog(jobID, fmt.Sprintf("statefs: state %s failed checksum verification, rebuilding: %v", stateID, sourceErr))
+	if errResp := m.dropCorruptState(ctx, stateID, stateDir); errResp != nil {
+		return errResp
+	}
+	if runner := m.getRunner(jobID); runner != nil {
+		runner.mu.Lock()
+		runner.corruptState = stateID
+		runner.mu.Unlock()
+	}
+	return errorResponse(ErrorCodeStateCorrupt, "input state failed checksum verification; the state was invalidated and will be rebuilt", sourceErr.Error())
+}
+
+// producingTaskIndex returns the index of the state_execute task whose output
+// is stateID, or -1.
+func producingTaskIndex(tasks []taskState, stateID string) int {
+	for i := len(tasks) - 1; i >= 0; i-- {
+		if tasks[i].Type == "state_execute" && tasks[i].OutputStateID == stateID {
+			return i
+		}
+	}
+	return -1
+}
+
+func (m *PrepareService) dropCorruptState(ctx context.Context, stateID string, stateDir string) *ErrorResponse {
+	if err := m.statefs.RemovePath(context.Background(), stateDir); err != nil {
+		return errorResponse(ErrorCodeInternal, "cannot remove corrupt cached state dir", err.Error())
+	}
+	if err := m.store.DeleteState(ctx, stateID); err != nil {
+		return errorResponse(ErrorCodeInternal, "cannot delete corrupt cached state", err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,260 @@
+package prepare
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sqlrs/engine-local/internal/statefs"
+)
+
+// checksumStateFS snapshots a small data file and delegates manifests to the
+// real copy backend.
+type checksumStateFS struct {
+	fakeStateFS
+	verifyCalls int
+}
+
+func (c *checksumStateFS) Snapshot(ctx context.Context, srcDir, destDir string) error {
+	if err := c.fakeStateFS.Snapshot(ctx, srcDir, destDir); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Join(destDir, "base", "1"), 0o700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(destDir, "PG_VERSION"), []byte("17\n"), 0o600); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(destDir, "base", "1", "1259"), []byte("relation data"), 0o600)
+}
+
+func (c *checksumStateFS) WriteChecksums(ctx context.Context, stateDir string) error {
+	return testLayoutFS.(statefs.Checksummer).WriteChecksums(ctx, stateDir)
+}
+
+func (c *checksumStateFS) VerifyChecksums(ctx context.Context, stateDir, cloneDir string) error {
+	c.verifyCalls++
+	return testLayoutFS.(statefs.Checksummer).VerifyChecksums(ctx, stateDir, cloneDir)
+}
+
+func TestExecuteStateTaskRebuildsCorruptCachedState(t *testing.T) {
+	store := &fakeStore{}
+	snap := &checksumStateFS{}
+	mgr := newManagerWithStateFS(t, store, snap)
+	mgr.config = &fakeConfigStore{values: map[string]any{"statefs.verifyChecksums": true}}
+	req := Request{PrepareKind: "psql", ImageID: "image-1", PsqlArgs: []string{"-c", "select 1"}}
+	createJobRecord(t, mgr.queue, "job-1", req, StatusRunning)
+
+	prepared, err := mgr.prepareRequest(req)
+	if err != nil {
+		t.Fatalf("prepareRequest: %v", err)
+	}
+	outputID := psqlOutputStateID(t, mgr, prepared, TaskInput{Kind: "image", ID: "image-1"})
+	task := taskState{
+		PlanTask: PlanTask{
+			TaskID:        "execute-0",
+			Type:          "state_execute",
+			OutputStateID: outputID,
+			Input:         &TaskInput{Kind: "image", ID: "image-1"},
+		},
+	}
+	if _, errResp := mgr.executeStateTask(context.Background(), "job-1", prepared, task); errResp != nil {
+		t.Fatalf("executeStateTask: %+v", errResp)
+	}
+	paths, err := resolveStatePaths(mgr.stateStoreRoot, prepared.request.ImageID, outputID, mgr.statefs)
+	if err != nil {
+		t.Fatalf("resolveStatePaths: %v", err)
+	}
+	if err := snap.VerifyChecksums(context.Background(), paths.stateDir, paths.stateDir); err != nil {
+		t.Fatalf("expected manifest for new state: %v", err)
+	}
+
+	// An intact cached state is reused without a rebuild.
+	if _, errResp := mgr.executeStateTask(context.Background(), "job-1", prepared, task); errResp != nil {
+		t.Fatalf("executeStateTask cached: %+v", errResp)
+	}
+	if len(snap.snapshotCalls) != 1 || len(store.deletedStates) != 0 {
+		t.Fatalf("expected cache hit, got snapshots=%d deleted=%v", len(snap.snapshotCalls), store.deletedStates)
+	}
+
+	if err := os.Truncate(filepath.Join(paths.stateDir, "base", "1", "1259"), 2); err != nil {
+		t.Fatalf("truncate: %v", err)
+	}
+	outputIDAfter, errResp := mgr.executeStateTask(context.Background(), "job-1", prepared, task)
+	if errResp != nil {
+		t.Fatalf("executeStateTask after corruption: %+v", errResp)
+	}
+	if outputIDAfter != outputID {
+		t.Fatalf("expected same state id, got %s want %s", outputIDAfter, outputID)
+	}
+	if len(store.deletedStates) != 1 || store.deletedStates[0] != outputID {
+		t.Fatalf("expected corrupt state to be invalidated, got %v", store.deletedStates)
+	}
+	if len(snap.snapshotCalls) != 2 {
+		t.Fatalf("expected rebuild snapshot, got %d", len(snap.snapshotCalls))
+	}
+	if _, ok := store.statesByID[outputID]; !ok {
+		t.Fatalf("expected rebuilt state to be stored")
+	}
+	if err := snap.VerifyChecksums(context.Background(), paths.stateDir, paths.stateDir); err != nil {
+		t.Fatalf("expected rebuilt state to verify: %v", err)
+	}
+
+	events, _, _, err := mgr.EventsSince("job-1", 0)
+	if err != nil {
+		t.Fatalf("EventsSince: %v", err)
+	}
+	found := false
+	for _, event := range events {
+		if strings.Contains(event.Message, "failed checksum verification") {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected checksum log event, got %+v", events)
+	}
+}
+
+func TestExecuteStateTaskSkipsChecksumsWhenDisabled(t *testing.T) {
+	store := &fakeStore{}
+	snap := &checksumStateFS{}
+	mgr := newManagerWithStateFS(t, store, snap)
+	prepared, err := mgr.prepareRequest(Request{PrepareKind: "psql", ImageID: "image-1", PsqlArgs: []string{"-c", "select 1"}})
+	if err != nil {
+		t.Fatalf("prepareRequest: %v", err)
+	}
+	outputID := psqlOutputStateID(t, mgr, prepared, TaskInput{Kind: "image", ID: "image-1"})
+	task := taskState{PlanTask: PlanTask{TaskID: "execute-0", Type: "state_execute", OutputStateID: outputID, Input: &TaskInput{Kind: "image", ID: "image-1"}}}
+	if _, errResp := mgr.executeStateTask(context.Background(), "job-1", prepared, task); errResp != nil {
+		t.Fatalf("executeStateTask: %+v", errResp)
+	}
+	paths, err := resolveStatePaths(mgr.stateStoreRoot, prepared.request.ImageID, outputID, mgr.statefs)
+	if err != nil {
+		t.Fatalf("resolveStatePaths: %v", err)
+	}
+	if err := snap.VerifyChecksums(context.Background(), paths.stateDir, paths.stateDir); err != statefs.ErrNoChecksumManifest {
+		t.Fatalf("expected no manifest when verification is off, got %v", err)
+	}
+}
+
+func TestVerifyCloneChecksumsChecksSourceOnMismatch(t *testing.T) {
+	store := &fakeStore{}
+	snap := &checksumStateFS{}
+	mgr := newManagerWithStateFS(t, store, snap)
+	mgr.config = &fakeConfigStore{values: map[string]any{"statefs.verifyChecksums": true}}
+	mgr.registerRunner("job-1", func() {})
+	t.Cleanup(func() { mgr.unregisterRunner("job-1") })
+	root := t.TempDir()
+	stateDir := filepath.Join(root, "state")
+	if err := snap.Snapshot(context.Background(), "", stateDir); err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	if err := snap.WriteChecksums(context.Background(), stateDir); err != nil {
+		t.Fatalf("WriteChecksums: %v", err)
+	}
+	cloneDir := filepath.Join(root, "clone")
+	if err := snap.Snapshot(context.Background(), "", cloneDir); err != nil {
+		t.Fatalf("Snapshot clone: %v", err)
+	}
+
+	// A damaged clone of an intact state fails the task but keeps the state.
+	if err := os.Truncate(filepath.Join(cloneDir, "base", "1", "1259"), 0); err != nil {
+		t.Fatalf("truncate: %v", err)
+	}
+	errResp := mgr.verifyCloneChecksums(context.Background(), "job-1", "state-1", stateDir, cloneDir)
+	if errResp == nil || errResp.Code != "state_corrupt" {
+		t.Fatalf("expected checksum error, got %+v", errResp)
+	}
+	if len(store.deletedStates) != 0 || mgr.takeCorruptState("job-1") != "" {
+		t.Fatalf("expected intact state to be kept, got %v", store.deletedStates)
+	}
+
+	// A corrupt source is invalidated and handed to runJob for a rebuild.
+	if err := os.Truncate(filepath.Join(stateDir, "base", "1", "1259"), 0); err != nil {
+		t.Fatalf("truncate: %v", err)
+	}
+	errResp = mgr.verifyCloneChecksums(context.Background(), "job-1", "state-1", stateDir, cloneDir)
+	if errResp == nil || errResp.Code != "state_corrupt" || !strings.Contains(errResp.Message, "rebuilt") {
+		t.Fatalf("expected invalidation error, got %+v", errResp)
+	}
+	if len(store.deletedStates) != 1 || store.deletedStates[0] != "state-1" {
+		t.Fatalf("expected state invalidated, got %v", store.deletedStates)
+	}
+	if _, err := os.Stat(stateDir); !os.IsNotExist(err) {
+		t.Fatalf("expected state dir removed, got %v", err)
+	}
+	if got := mgr.takeCorruptState("job-1"); got != "state-1" {
+		t.Fatalf("expected corrupt state recorded for rebuild, got %q", got)
+	}
+}
+
+func TestStateChecksumsVerifiedOncePerJob(t *testing.T) {
+	store := &fakeStore{}
+	snap := &checksumStateFS{}
+	mgr := newManagerWithStateFS(t, store, snap)
+	mgr.config = &fakeConfigStore{values: map[string]any{"statefs.verifyChecksums": true}}
+	mgr.registerRunner("job-1", func() {})
+	t.Cleanup(func() { mgr.unregisterRunner("job-1") })
+	root := t.TempDir()
+	stateDir := filepath.Join(root, "state")
+	if err := snap.Snapshot(context.Background(), "", stateDir); err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	if errResp := mgr.writeStateChecksums(context.Background(), "job-1", "state-1", stateDir); errResp != nil {
+		t.Fatalf("writeStateChecksums: %+v", errResp)
+	}
+	if mgr.stateVerificationPending("job-1", "state-1") {
+		t.Fatalf("expected a freshly written state to count as verified")
+	}
+	if errResp := mgr.verifyCloneChecksums(context.Background(), "job-1", "state-1", stateDir, filepath.Join(root, "missing")); errResp != nil {
+		t.Fatalf("expected the clone check to be skipped, got %+v", errResp)
+	}
+	if snap.verifyCalls != 0 {
+		t.Fatalf("expected no second read of the state, got %d", snap.verifyCalls)
+	}
+	if !mgr.stateVerificationPending("job-2", "state-1") {
+		t.Fatalf("expected other jobs to verify the state themselves")
+	}
+}
+
+func TestRunJobRebuildsCorruptCachedStateOnce(t *testing.T) {
+	store := &fakeStore{}
+	snap := &checksumStateFS{}
+	mgr := newManagerWithStateFS(t, store, snap)
+	mgr.config = &fakeConfigStore{values: map[string]any{"statefs.verifyChecksums": true}}
+	req := Request{PrepareKind: "psql", ImageID: "image-1", PsqlArgs: []string{"-c", "select 1"}}
+
+	first, err := mgr.Submit(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	status, ok := mgr.Get(first.JobID)
+	if !ok || status.Status != StatusSucceeded || status.Result == nil {
+		t.Fatalf("expected first job to succeed, got %+v", status)
+	}
+	paths, err := resolveStatePaths(mgr.stateStoreRoot, status.Result.ImageID, status.Result.StateID, mgr.statefs)
+	if err != nil {
+		t.Fatalf("resolveStatePaths: %v", err)
+	}
+	if err := os.Truncate(filepath.Join(paths.stateDir, "base", "1", "1259"), 2); err != nil {
+		t.Fatalf("truncate: %v", err)
+	}
+	snap.verifyCalls = 0
+
+	second, err := mgr.Submit(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	status, ok = mgr.Get(second.JobID)
+	if !ok || status.Status != StatusSucceeded {
+		t.Fatalf("expected rebuilt job to succeed, got %+v", status)
+	}
+	if len(store.deletedStates) != 1 || len(snap.snapshotCalls) != 2 {
+		t.Fatalf("expected one invalidation and a rebuild, got deleted=%v snapshots=%d", store.deletedStates, len(snap.snapshotCalls))
+	}
+	if snap.verifyCalls != 1 {
+		t.Fatalf("expected the cached state to be verified once, got %d", snap.verifyCalls)
+	}
+}
@@ -0,0 +1,64 @@
+package prepare
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/sqlrs/engine-local/internal/statefs"
+)
+
+func TestExecuteStateTaskStoresCompressedStateSize(t *testing.T) {
+	if _, err := exec.LookPath("zstd"); err != nil {
+		t.Skipf("zstd not available: %v", err)
+	}
+	store := &fakeStore{}
+	fs := statefs.NewManager(statefs.Options{Backend: "copy", Compression: statefs.CompressionZstd})
+	mgr := newManagerWithStateFS(t, store, fs)
+	prepared, err := mgr.prepareRequest(Request{PrepareKind: "psql", ImageID: "image-1", PsqlArgs: []string{"-c", "select 1"}})
+	if err != nil {
+		t.Fatalf("prepareRequest: %v", err)
+	}
+	outputID := psqlOutputStateID(t, mgr, prepared, TaskInput{Kind: "image", ID: "image-1"})
+	task := taskState{PlanTask: PlanTask{TaskID: "execute-0", Type: "state_execute", OutputStateID: outputID, Input: &TaskInput{Kind: "image", ID: "image-1"}}}
+	if _, errResp := mgr.executeStateTask(context.Background(), "job-1", prepared, task); errResp != nil {
+		t.Fatalf("executeStateTask: %+v", errResp)
+	}
+	paths, err := resolveStatePaths(mgr.stateStoreRoot, prepared.request.ImageID, outputID, mgr.statefs)
+	if err != nil {
+		t.Fatalf("resolveStatePaths: %v", err)
+	}
+	archive, err := os.Stat(filepath.Join(paths.stateDir, ".sqlrs-state.tar.zst"))
+	if err != nil {
+		t.Fatalf("expected compressed state archive: %v", err)
+	}
+	if len(store.states) != 1 || store.states[0].SizeBytes == nil {
+		t.Fatalf("expected stored state with size, got %+v", store.states)
+	}
+	// The size is measured after compression and before the build marker is
+	// written.
+	measured, err := measureStoreUsage(paths.stateDir)
+	if err != nil {
+		t.Fatalf("measureStoreUsage: %v", err)
+	}
+	marker, err := os.Stat(stateBuildMarkerPath(paths.stateDir, "copy"))
+	if err != nil {
+		t.Fatalf("stat build marker: %v", err)
+	}
+	if want := measured - marker.Size(); *store.states[0].SizeBytes != want || want < archive.Size() {
+		t.Fatalf("expected compressed size %d, got %d", want, *store.states[0].SizeBytes)
+	}
+
+	// The compressed state still passes the PG_VERSION check and clones into
+	// a usable runtime dir.
+	rt, errResp := mgr.startRuntime(context.Background(), "job-2", prepared, &TaskInput{Kind: "state", ID: outputID})
+	if errResp != nil {
+		t.Fatalf("startRuntime from compressed state: %+v", errResp)
+	}
+	defer rt.cleanup()
+	if ok, err := hasPGVersion(rt.dataDir); err != nil || !ok {
+		t.Fatalf("expected PG_VERSION in runtime dir, ok=%v err=%v", ok, err)
+	}
+}
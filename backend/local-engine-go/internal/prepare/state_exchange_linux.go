@@ -0,0 +1,11 @@
+//go:build linux
+
+package prepare
+
+import "golang.org/x/sys/unix"
+
+// exchangeDirs atomically swaps two paths with renameat2(RENAME_EXCHANGE).
+// It fails on filesystems without exchange support.
+func exchangeDirs(a string, b string) error {
+	return unix.Renameat2(unix.AT_FDCWD, a, unix.AT_FDCWD, b, unix.RENAME_EXCHANGE)
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package prepare
+
+import "errors"
+
+// exchangeDirs is only available on Linux; callers fall back to two renames.
+func exchangeDirs(a string, b string) error {
+	return errors.ErrUnsupported
+}
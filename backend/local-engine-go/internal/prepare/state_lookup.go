@@ -0,0 +1,62 @@
+package prepare
+
+import "context"
+
+// StateLookupResult is the POST /v1/states/lookup payload.
+type StateLookupResult struct {
+	// Cached is null when the answer needs a plan that was not run.
+	Cached *bool `json:"cached"`
+	// StateID is the final state a submit would produce.
+	StateID         string `json:"state_id,omitempty"`
+	ResolvedImageID string `json:"resolved_image_id,omitempty"`
+	// ReasonCode is "exact_state_match", "no_matching_state", "no_cache",
+	// "planned_in_container" or "image_not_built".
+	ReasonCode string `json:"reason_code"`
+	Note       string `json:"note,omitempty"`
+}
+
+// LookupState computes the final state ID a submit of req would produce and
+// whether it is already cached, without creating a job or storing anything.
+// The image is resolved to its digest first, as a submit would; a build is
+// keyed on its context hash and never run. Liquibase and flyway plans are
+// read from the tool in a container; cheap skips that and reports cached as
+// unknown for those kinds, as does a build image no job has built yet.
+func (m *PrepareService) LookupState(ctx context.Context, req Request, cheap bool) (StateLookupResult, error) {
+	prepared, err := m.prepareRequest(req)
+	if err != nil {
+		return StateLookupResult{}, err
+	}
+	if errResp := m.ensureResolvedImageID(ctx, "", &prepared, nil); errResp != nil {
+		return StateLookupResult{}, errorFromExplainResponse(errResp)
+	}
+	result := StateLookupResult{ResolvedImageID: prepared.effectiveImageID()}
+	if cheap && plansFromRuntime(prepared.request.PrepareKind) {
+		result.ReasonCode = "planned_in_container"
+		result.Note = prepared.request.PrepareKind + " changes are planned in a container; look up without cheap to plan them"
+		return result, nil
+	}
+	if m.buildImagePending(ctx, prepared) {
+		result.ReasonCode = "image_not_built"
+		result.Note = prepared.request.PrepareKind + " changes are planned in a container of the built image; submit a job to build it first"
+		return result, nil
+	}
+	_, stateID, err := m.planWithoutJob(ctx, prepared)
+	if err != nil {
+		return StateLookupResult{}, err
+	}
+	cached, err := m.isStateCachedForPlan(prepared, stateID)
+	if err != nil {
+		return StateLookupResult{}, err
+	}
+	result.Cached = &cached
+	result.StateID = stateID
+	switch {
+	case prepared.request.NoCache:
+		result.ReasonCode = "no_cache"
+	case cached:
+		result.ReasonCode = "exact_state_match"
+	default:
+		result.ReasonCode = "no_matching_state"
+	}
+	return result, nil
+}
@@ -0,0 +1,151 @@
+package prepare
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sqlrs/engine-local/internal/store"
+)
+
+func TestLookupStatePsqlResolvesImageAndReportsCache(t *testing.T) {
+	rt := &fakeRuntime{}
+	stateStore := &fakeStore{statesByID: map[string]store.StateEntry{}}
+	queueStore := newQueueStore(t)
+	mgr := newManagerWithDeps(t, stateStore, queueStore, &testDeps{runtime: rt})
+	scriptPath := filepath.Join(t.TempDir(), "prepare.sql")
+	if err := os.WriteFile(scriptPath, []byte("select 1;\n"), 0o600); err != nil {
+		t.Fatalf("write script: %v", err)
+	}
+	req := Request{
+		PrepareKind: "psql",
+		ImageID:     "postgres:17",
+		PsqlArgs:    []string{"-f", scriptPath},
+	}
+
+	result, err := mgr.LookupState(context.Background(), req, false)
+	if err != nil {
+		t.Fatalf("LookupState: %v", err)
+	}
+	if result.Cached == nil || *result.Cached || result.ReasonCode != "no_matching_state" || result.StateID == "" {
+		t.Fatalf("expected a miss with a state id, got %+v", result)
+	}
+	if result.ResolvedImageID != "postgres:17@sha256:resolved" || len(rt.resolveCalls) != 1 {
+		t.Fatalf("expected the image to be resolved, got %+v resolve=%v", result, rt.resolveCalls)
+	}
+	if len(rt.startCalls) != 0 {
+		t.Fatalf("expected no containers, got %+v", rt.startCalls)
+	}
+	jobs, err := queueStore.ListJobs(context.Background(), "")
+	if err != nil || len(jobs) != 0 {
+		t.Fatalf("expected no jobs, got %+v err=%v", jobs, err)
+	}
+
+	stateStore.statesByID[result.StateID] = store.StateEntry{StateID: result.StateID, ImageID: result.ResolvedImageID, PrepareKind: "psql"}
+	hit, err := mgr.LookupState(context.Background(), req, true)
+	if err != nil {
+		t.Fatalf("LookupState: %v", err)
+	}
+	if hit.Cached == nil || !*hit.Cached || hit.ReasonCode != "exact_state_match" || hit.StateID != result.StateID {
+		t.Fatalf("expected a hit on %s, got %+v", result.StateID, hit)
+	}
+
+	req.NoCache = true
+	noCache, err := mgr.LookupState(context.Background(), req, false)
+	if err != nil {
+		t.Fatalf("LookupState: %v", err)
+	}
+	if noCache.Cached == nil || *noCache.Cached || noCache.ReasonCode != "no_cache" {
+		t.Fatalf("expected no_cache to report a miss, got %+v", noCache)
+	}
+}
+
+func TestLookupStateCheapSkipsLiquibasePlanning(t *testing.T) {
+	rt := &fakeRuntime{}
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{runtime: rt})
+	changelog := filepath.Join(t.TempDir(), "changelog.xml")
+	if err := os.WriteFile(changelog, []byte("<databaseChangeLog/>"), 0o600); err != nil {
+		t.Fatalf("write changelog: %v", err)
+	}
+
+	result, err := mgr.LookupState(context.Background(), Request{
+		PrepareKind:   "lb",
+		ImageID:       "image-1",
+		LiquibaseArgs: []string{"update", "--changelog-file", changelog},
+	}, true)
+	if err != nil {
+		t.Fatalf("LookupState: %v", err)
+	}
+	if result.Cached != nil || result.StateID != "" || result.ReasonCode != "planned_in_container" || result.Note == "" {
+		t.Fatalf("expected an unknown answer, got %+v", result)
+	}
+	if len(rt.startCalls) != 0 {
+		t.Fatalf("expected no planning container, got %+v", rt.startCalls)
+	}
+}
+
+func TestLookupStateNeverBuilds(t *testing.T) {
+	rt := &fakeRuntime{}
+	stateStore := &fakeStore{statesByID: map[string]store.StateEntry{}}
+	mgr := newManagerWithDeps(t, stateStore, newQueueStore(t), &testDeps{
+		runtime: rt,
+		config:  &fakeConfigStore{values: map[string]any{"container.build.enabled": true}},
+	})
+	contextDir := writeBuildContext(t, "FROM postgres:17\n")
+
+	result, err := mgr.LookupState(context.Background(), Request{
+		PrepareKind: "psql",
+		PsqlArgs:    []string{"-c", "select 1"},
+		Build:       &BuildSpec{ContextDir: contextDir},
+	}, false)
+	if err != nil {
+		t.Fatalf("LookupState: %v", err)
+	}
+	if result.Cached == nil || result.StateID == "" || !strings.HasPrefix(result.ResolvedImageID, buildImageRepo+":") {
+		t.Fatalf("expected a psql answer keyed on the context hash, got %+v", result)
+	}
+
+	changelog := filepath.Join(t.TempDir(), "changelog.xml")
+	if err := os.WriteFile(changelog, []byte("<databaseChangeLog/>"), 0o600); err != nil {
+		t.Fatalf("write changelog: %v", err)
+	}
+	result, err = mgr.LookupState(context.Background(), Request{
+		PrepareKind:   "lb",
+		LiquibaseArgs: []string{"update", "--changelog-file", changelog},
+		Build:         &BuildSpec{ContextDir: contextDir},
+	}, false)
+	if err != nil {
+		t.Fatalf("LookupState: %v", err)
+	}
+	if result.Cached != nil || result.ReasonCode != "image_not_built" {
+		t.Fatalf("expected an unknown answer for an unbuilt image, got %+v", result)
+	}
+	explain, err := mgr.CacheExplain(context.Background(), Request{
+		PrepareKind:   "lb",
+		LiquibaseArgs: []string{"update", "--changelog-file", changelog},
+		Build:         &BuildSpec{ContextDir: contextDir},
+	})
+	if err != nil {
+		t.Fatalf("CacheExplain: %v", err)
+	}
+	if explain.Decision != "miss" || explain.ReasonCode != "cache_lookup_unavailable" {
+		t.Fatalf("expected cache explain to skip planning, got %+v", explain)
+	}
+	if len(rt.buildCalls) != 0 || len(rt.startCalls) != 0 {
+		t.Fatalf("expected no build and no container, got build=%+v start=%+v", rt.buildCalls, rt.startCalls)
+	}
+}
+
+func TestLookupStateRejectsInvalidRequest(t *testing.T) {
+	mgr := newManager(t, &fakeStore{})
+	_, err := mgr.LookupState(context.Background(), Request{
+		PrepareKind: "psql",
+		ImageID:     "image-1",
+		PsqlArgs:    []string{"-f", filepath.Join(t.TempDir(), "missing.sql")},
+	}, false)
+	if _, ok := err.(ValidationError); !ok {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+package prepare
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+
+	engineRuntime "github.com/sqlrs/engine-local/internal/runtime"
+)
+
+const stateSchemaJobPrefix = "state-schema-"
+
+// StateSchema is the GET /v1/states/{id}/schema payload.
+type StateSchema struct {
+	StateID string `json:"state_id"`
+	Schema  string `json:"schema"`
+	// Cached is true when the schema was served from an earlier dump.
+	Cached bool `json:"cached"`
+}
+
+// StateSchema returns the schema-only dump of a state. The state is started
+// from a throwaway clone and the container is torn down right after pg_dump,
+// so the state dir itself is never modified. The dump is cached next to the
+// state; state ids are content addressed, so a cached dump stays valid for as
+// long as the id exists. A container left behind by a crashed inspect has no
+// job and is removed by ReapOrphans.
+func (m *PrepareService) StateSchema(ctx context.Context, stateID string) (StateSchema, bool, error) {
+	entry, ok, err := m.store.GetState(ctx, stateID)
+	if err != nil || !ok {
+		return StateSchema{}, ok, err
+	}
+	paths, err := resolveStatePaths(m.namespaceRoot(entry.Namespace), entry.ImageID, entry.StateID, m.statefs)
+	if err != nil {
+		return StateSchema{}, true, err
+	}
+	cachePath := stateSchemaCachePath(paths.statesDir, entry.StateID)
+	if data, err := os.ReadFile(cachePath); err == nil {
+		return StateSchema{StateID: entry.StateID, Schema: string(data), Cached: true}, true, nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return StateSchema{}, true, err
+	}
+	if m.runtime == nil {
+		return StateSchema{}, true, UnavailableError{Code: ErrorCodeUnavailable, Message: "container runtime is not configured"}
+	}
+
+	suffix, err := randomHex(8)
+	if err != nil {
+		return StateSchema{}, true, err
+	}
+	jobID := stateSchemaJobPrefix + suffix
+	defer func() {
+		_ = m.removeJobDir(entry.Namespace, jobID)
+	}()
+	prepared := preparedRequest{request: Request{ImageID: entry.ImageID, Namespace: entry.Namespace}}
+	rt, errResp := m.startRuntime(ctx, jobID, prepared, &TaskInput{Kind: "state", ID: entry.StateID})
+	if errResp != nil {
+		return StateSchema{}, true, errorFromExplainResponse(errResp)
+	}
+	defer m.cleanupRuntime(context.Background(), &jobRunner{rt: rt})
+
+	schema, err := m.runtime.Exec(ctx, rt.instance.ID, engineRuntime.ExecRequest{
+		User: "postgres",
+		Args: []string{"pg_dump", "-h", "127.0.0.1", "-p", "5432", "-U", m.postgresSuperuser(), "-d", "postgres", "--schema-only"},
+	})
+	if err != nil {
+		return StateSchema{}, true, err
+	}
+	if err := writeStateSchemaCache(cachePath, schema); err != nil {
+		m.logWarnJob(jobID, "state schema cache write failed state=%s err=%v", entry.StateID, err)
+	}
+	return StateSchema{StateID: entry.StateID, Schema: schema}, true, nil
+}
+
+// stateSchemaCachePath keeps the dump in the states dir's .build sidecar dir,
+// outside the state itself.
+func stateSchemaCachePath(statesDir string, stateID string) string {
+	return filepath.Join(statesDir, stateBuildLockDirName, stateID+".schema.sql")
+}
+
+func writeStateSchemaCache(path string, schema string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.WriteString(schema); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package prepare
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sqlrs/engine-local/internal/store"
+)
+
+func newStateSchemaManager(t *testing.T, rt *fakeRuntime) (*PrepareService, string, statePaths) {
+	t.Helper()
+	stateID := "state-schema-1"
+	st := &fakeStore{statesByID: map[string]store.StateEntry{
+		stateID: {StateID: stateID, ImageID: "postgres:17", CreatedAt: "2026-01-01T00:00:00Z"},
+	}}
+	mgr := newManagerWithDeps(t, st, newQueueStore(t), &testDeps{runtime: rt})
+	paths, err := resolveStatePaths(mgr.stateStoreRoot, "postgres:17", stateID, mgr.statefs)
+	if err != nil {
+		t.Fatalf("paths: %v", err)
+	}
+	if err := os.MkdirAll(paths.stateDir, 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(paths.stateDir, "PG_VERSION"), []byte("17\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	return mgr, stateID, paths
+}
+
+func TestStateSchemaDumpsFromThrowawayClone(t *testing.T) {
+	rt := &fakeRuntime{execOutput: "CREATE TABLE public.t (id integer);\n"}
+	mgr, stateID, paths := newStateSchemaManager(t, rt)
+
+	schema, ok, err := mgr.StateSchema(context.Background(), stateID)
+	if err != nil || !ok {
+		t.Fatalf("StateSchema: ok=%v err=%v", ok, err)
+	}
+	if schema.StateID != stateID || schema.Cached || schema.Schema != rt.execOutput {
+		t.Fatalf("unexpected schema: %+v", schema)
+	}
+	if len(rt.startCalls) != 1 || rt.startCalls[0].DataDir == paths.stateDir {
+		t.Fatalf("expected one start from a clone, got %+v", rt.startCalls)
+	}
+	if len(rt.execCalls) != 1 || !strings.Contains(strings.Join(rt.execCalls[0].Args, " "), "pg_dump") || !containsArg(rt.execCalls[0].Args, "--schema-only") {
+		t.Fatalf("expected pg_dump --schema-only, got %+v", rt.execCalls)
+	}
+	if len(rt.stopCalls) != 1 {
+		t.Fatalf("expected container teardown, got %v", rt.stopCalls)
+	}
+	entries, err := os.ReadDir(paths.stateDir)
+	if err != nil || len(entries) != 1 || entries[0].Name() != "PG_VERSION" {
+		t.Fatalf("expected state dir untouched, got %v err=%v", entries, err)
+	}
+	jobs, _ := os.ReadDir(filepath.Join(mgr.stateStoreRoot, "jobs"))
+	if len(jobs) != 0 {
+		t.Fatalf("expected runtime dir removed, got %v", jobs)
+	}
+
+	cached, ok, err := mgr.StateSchema(context.Background(), stateID)
+	if err != nil || !ok {
+		t.Fatalf("StateSchema cached: ok=%v err=%v", ok, err)
+	}
+	if !cached.Cached || cached.Schema != rt.execOutput {
+		t.Fatalf("expected cached schema, got %+v", cached)
+	}
+	if len(rt.startCalls) != 1 {
+		t.Fatalf("expected no second start, got %d", len(rt.startCalls))
+	}
+}
+
+func TestStateSchemaNotFound(t *testing.T) {
+	mgr, _, _ := newStateSchemaManager(t, &fakeRuntime{})
+	if _, ok, err := mgr.StateSchema(context.Background(), "missing"); ok || err != nil {
+		t.Fatalf("expected not found, got ok=%v err=%v", ok, err)
+	}
+}
+
+func TestStateSchemaDumpFailureIsNotCached(t *testing.T) {
+	rt := &fakeRuntime{execErr: errors.New("pg_dump: connection refused")}
+	mgr, stateID, paths := newStateSchemaManager(t, rt)
+
+	if _, ok, err := mgr.StateSchema(context.Background(), stateID); !ok || err == nil {
+		t.Fatalf("expected dump error, got ok=%v err=%v", ok, err)
+	}
+	if len(rt.stopCalls) != 1 {
+		t.Fatalf("expected container teardown, got %v", rt.stopCalls)
+	}
+	if _, err := os.Stat(stateSchemaCachePath(paths.statesDir, stateID)); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected no cached schema, got %v", err)
+	}
+}
@@ -0,0 +1,440 @@
+package prepare
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sqlrs/engine-local/internal/statefs"
+	"github.com/sqlrs/engine-local/internal/store"
+)
+
+const (
+	stateArchiveFormat   = "sqlrs-state-v1"
+	stateArchiveMetadata = "sqlrs-state.json"
+	stateArchiveDataDir  = "data"
+)
+
+// StateArchiveMetadata is the first entry of a state archive. Together with
+// the task hash it carries every input of the state ID, so an importer can
+// recompute the ID instead of trusting the declared one.
+type StateArchiveMetadata struct {
+	Format        string  `json:"format"`
+	StateID       string  `json:"state_id"`
+	ParentStateID *string `json:"parent_state_id,omitempty"`
+	ImageID       string  `json:"image_id"`
+	PrepareKind   string  `json:"prepare_kind"`
+	PrepareArgs   string  `json:"prepare_args_normalized"`
+	TaskHash      string  `json:"task_hash"`
+	Namespace     string  `json:"namespace,omitempty"`
+	CreatedAt     string  `json:"created_at"`
+	// ContentSHA256 digests the files under data/ (see stateContentDigest);
+	// the importer recomputes it from the unpacked tree.
+	ContentSHA256 string `json:"content_sha256,omitempty"`
+}
+
+type StateImportResult struct {
+	StateID string `json:"state_id"`
+	// Created is false when the state was already present.
+	Created bool `json:"created"`
+}
+
+// StateExport resolves the archive metadata of a state. The task hash is
+// read from the state row; states created before it was recorded cannot be
+// exported.
+func (m *PrepareService) StateExport(ctx context.Context, stateID string) (StateArchiveMetadata, bool, error) {
+	entry, ok, err := m.store.GetState(ctx, stateID)
+	if err != nil || !ok {
+		return StateArchiveMetadata{}, ok, err
+	}
+	taskHash := strings.TrimSpace(entry.TaskHash)
+	if taskHash == "" {
+		return StateArchiveMetadata{}, true, ConflictError{
+			Code:    ErrorCodeConflict,
+			Message: "state cannot be exported: it has no recorded task hash",
+			Details: entry.StateID,
+		}
+	}
+	return StateArchiveMetadata{
+		Format:        stateArchiveFormat,
+		StateID:       entry.StateID,
+		ParentStateID: entry.ParentStateID,
+		ImageID:       entry.ImageID,
+		PrepareKind:   entry.PrepareKind,
+		PrepareArgs:   entry.PrepareArgs,
+		TaskHash:      taskHash,
+		Namespace:     entry.Namespace,
+		CreatedAt:     entry.CreatedAt,
+	}, true, nil
+}
+
+// WriteStateArchive streams a tar archive of the state: the metadata entry
+// followed by the state files under data/. The state is cloned first, so
+// overlay and btrfs states are written as a plain copy of their contents.
+func (m *PrepareService) WriteStateArchive(ctx context.Context, meta StateArchiveMetadata, w io.Writer) error {
+	paths, err := resolveStatePaths(m.namespaceRoot(meta.Namespace), meta.ImageID, meta.StateID, m.statefs)
+	if err != nil {
+		return err
+	}
+	cloneDir, err := os.MkdirTemp(paths.statesDir, "export-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(cloneDir)
+	clone, err := m.statefs.Clone(ctx, paths.stateDir, filepath.Join(cloneDir, "clone"))
+	if err != nil {
+		return err
+	}
+	if clone.Cleanup != nil {
+		defer clone.Cleanup()
+	}
+
+	meta.ContentSHA256, err = stateArchiveTreeDigest(ctx, clone.MountDir)
+	if err != nil {
+		return err
+	}
+	tw := tar.NewWriter(w)
+	payload, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    stateArchiveMetadata,
+		Mode:    0o600,
+		Size:    int64(len(payload)),
+		ModTime: m.now().UTC(),
+	}); err != nil {
+		return err
+	}
+	if _, err := tw.Write(payload); err != nil {
+		return err
+	}
+	if err := writeStateArchiveTree(ctx, tw, clone.MountDir); err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+func writeStateArchiveTree(ctx context.Context, tw *tar.Writer, root string) error {
+	return filepath.WalkDir(root, func(current string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, current)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		link := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(current); err != nil {
+				return err
+			}
+		}
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		header.Name = path.Join(stateArchiveDataDir, filepath.ToSlash(rel))
+		if d.IsDir() {
+			header.Name += "/"
+		}
+		header.Uname, header.Gname = "", ""
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		file, err := os.Open(current)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(tw, file)
+		return err
+	})
+}
+
+// ImportState unpacks a state archive and registers the state. The state ID
+// is recomputed from the metadata and must match the declared one; the parent
+// state, if any, must already be present.
+func (m *PrepareService) ImportState(ctx context.Context, r io.Reader) (StateImportResult, error) {
+	tr := tar.NewReader(r)
+	header, err := tr.Next()
+	if err != nil || header.Name != stateArchiveMetadata {
+		return StateImportResult{}, ValidationError{Code: ErrorCodeInvalidArgument, Message: "state archive must start with " + stateArchiveMetadata}
+	}
+	var meta StateArchiveMetadata
+	if err := json.NewDecoder(io.LimitReader(tr, 1<<20)).Decode(&meta); err != nil {
+		return StateImportResult{}, ValidationError{Code: ErrorCodeInvalidArgument, Message: "invalid state archive metadata", Details: err.Error()}
+	}
+	if err := m.validateStateArchiveMetadata(ctx, meta); err != nil {
+		return StateImportResult{}, err
+	}
+	result := StateImportResult{StateID: meta.StateID}
+	if _, ok, err := m.store.GetState(ctx, meta.StateID); err != nil || ok {
+		return result, err
+	}
+
+	paths, err := resolveStatePaths(m.namespaceRoot(meta.Namespace), meta.ImageID, meta.StateID, m.statefs)
+	if err != nil {
+		return result, err
+	}
+	if err := os.MkdirAll(paths.statesDir, 0o700); err != nil {
+		return result, err
+	}
+	stagingDir, err := os.MkdirTemp(paths.statesDir, "import-*")
+	if err != nil {
+		return result, err
+	}
+	dataDir := filepath.Join(stagingDir, stateArchiveDataDir)
+	defer func() {
+		_ = m.statefs.RemovePath(context.Background(), dataDir)
+		_ = os.RemoveAll(stagingDir)
+	}()
+	if err := m.statefs.EnsureBaseDir(ctx, dataDir); err != nil {
+		return result, err
+	}
+	digest, err := extractStateArchiveTree(tr, dataDir)
+	if err != nil {
+		return result, err
+	}
+	if digest != meta.ContentSHA256 {
+		return result, ValidationError{
+			Code:    ErrorCodeInvalidArgument,
+			Message: "state archive content does not match its metadata",
+			Details: fmt.Sprintf("declared %s, computed %s", meta.ContentSHA256, digest),
+		}
+	}
+	if err := m.statefs.EnsureStateDir(ctx, paths.stateDir); err != nil {
+		return result, err
+	}
+	if err := m.statefs.Snapshot(ctx, dataDir, paths.stateDir); err != nil {
+		_ = m.statefs.RemovePath(context.Background(), paths.stateDir)
+		return result, err
+	}
+	stateSize, err := storeUsageFn(paths.stateDir)
+	if err != nil {
+		_ = m.statefs.RemovePath(context.Background(), paths.stateDir)
+		return result, err
+	}
+	createdAt := strings.TrimSpace(meta.CreatedAt)
+	if createdAt == "" {
+		createdAt = m.now().UTC().Format(time.RFC3339Nano)
+	}
+	if err := m.store.CreateState(ctx, store.StateCreate{
+		StateID:               meta.StateID,
+		ParentStateID:         meta.ParentStateID,
+		StateFingerprint:      meta.StateID,
+		ImageID:               meta.ImageID,
+		PrepareKind:           meta.PrepareKind,
+		PrepareArgsNormalized: meta.PrepareArgs,
+		CreatedAt:             createdAt,
+		SizeBytes:             &stateSize,
+		Namespace:             meta.Namespace,
+		TaskHash:              meta.TaskHash,
+	}); err != nil {
+		_ = m.statefs.RemovePath(context.Background(), paths.stateDir)
+		return result, err
+	}
+	m.logInfoJob("", "state imported state=%s image=%s size=%d", meta.StateID, meta.ImageID, stateSize)
+	result.Created = true
+	return result, nil
+}
+
+func (m *PrepareService) validateStateArchiveMetadata(ctx context.Context, meta StateArchiveMetadata) error {
+	if meta.Format != stateArchiveFormat {
+		return ValidationError{Code: ErrorCodeInvalidArgument, Message: "unsupported state archive format", Details: meta.Format}
+	}
+	for field, value := range map[string]string{
+		"state_id":       meta.StateID,
+		"image_id":       meta.ImageID,
+		"prepare_kind":   meta.PrepareKind,
+		"task_hash":      meta.TaskHash,
+		"content_sha256": meta.ContentSHA256,
+	} {
+		if strings.TrimSpace(value) == "" {
+			return ValidationError{Code: ErrorCodeInvalidArgument, Message: "state archive metadata is incomplete", Details: field + " is required"}
+		}
+	}
+	inputKind, inputID := "image", meta.ImageID
+	if parent := valueOrEmpty(meta.ParentStateID); parent != "" {
+		inputKind, inputID = "state", parent
+	}
+	stateID, errResp := m.computeOutputStateID(meta.Namespace, inputKind, inputID, meta.TaskHash)
+	if errResp != nil {
+		return fmt.Errorf("%s", errResp.Message)
+	}
+	if stateID != meta.StateID {
+		return ValidationError{
+			Code:    ErrorCodeInvalidArgument,
+			Message: "state archive id does not match its metadata",
+			Details: fmt.Sprintf("declared %s, computed %s", meta.StateID, stateID),
+		}
+	}
+	if inputKind == "state" {
+		parent, ok, err := m.store.GetState(ctx, inputID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ValidationError{Code: ErrorCodeInvalidArgument, Message: "parent state is not present, import it first", Details: inputID}
+		}
+		if parent.ImageID != meta.ImageID {
+			return ValidationError{Code: ErrorCodeInvalidArgument, Message: "parent state has a different image", Details: parent.ImageID}
+		}
+	}
+	return nil
+}
+
+// extractStateArchiveTree unpacks the data/ entries into destDir and returns
+// the content digest of what it wrote. Entries may not pass through symlinks
+// and links may not resolve outside destDir; hard links are not supported.
+func extractStateArchiveTree(tr *tar.Reader, destDir string) (string, error) {
+	digest := &stateContentDigest{}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", ValidationError{Code: ErrorCodeInvalidArgument, Message: "invalid state archive", Details: err.Error()}
+		}
+		name := path.Clean(header.Name)
+		if name == stateArchiveDataDir {
+			continue
+		}
+		rel, ok := strings.CutPrefix(name, stateArchiveDataDir+"/")
+		if !ok {
+			return "", ValidationError{Code: ErrorCodeInvalidArgument, Message: "invalid state archive entry", Details: header.Name}
+		}
+		target, err := statefs.ArchiveEntryPath(destDir, rel)
+		if err != nil {
+			return "", ValidationError{Code: ErrorCodeInvalidArgument, Message: "invalid state archive entry", Details: err.Error()}
+		}
+		mode := os.FileMode(header.Mode).Perm()
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0o700); err != nil {
+				return "", err
+			}
+			if err := os.Chmod(target, mode); err != nil {
+				return "", err
+			}
+			digest.add("dir", rel, mode, "")
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0o700); err != nil {
+				return "", err
+			}
+			file, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+			if err != nil {
+				return "", err
+			}
+			hash := sha256.New()
+			if _, err := io.Copy(io.MultiWriter(file, hash), tr); err != nil {
+				file.Close()
+				return "", err
+			}
+			if err := file.Close(); err != nil {
+				return "", err
+			}
+			digest.add("file", rel, mode, hex.EncodeToString(hash.Sum(nil)))
+		case tar.TypeSymlink:
+			if err := statefs.CheckArchiveLink(rel, header.Linkname); err != nil {
+				return "", ValidationError{Code: ErrorCodeInvalidArgument, Message: "invalid state archive link", Details: err.Error()}
+			}
+			if err := os.Symlink(header.Linkname, target); err != nil {
+				return "", err
+			}
+			digest.add("link", rel, 0, header.Linkname)
+		default:
+			return "", ValidationError{Code: ErrorCodeInvalidArgument, Message: "unsupported state archive entry", Details: header.Name}
+		}
+	}
+	if err := statefs.CheckArchiveLinks(destDir); err != nil {
+		return "", ValidationError{Code: ErrorCodeInvalidArgument, Message: "invalid state archive link", Details: err.Error()}
+	}
+	return digest.sum(), nil
+}
+
+// stateArchiveTreeDigest computes the content digest of the tree that
+// writeStateArchiveTree packs from root.
+func stateArchiveTreeDigest(ctx context.Context, root string) (string, error) {
+	digest := &stateContentDigest{}
+	err := filepath.WalkDir(root, func(current string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, current)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(current)
+			if err != nil {
+				return err
+			}
+			digest.add("link", rel, 0, link)
+		case info.IsDir():
+			digest.add("dir", rel, info.Mode().Perm(), "")
+		case info.Mode().IsRegular():
+			sum, err := fileDigest(current)
+			if err != nil {
+				return err
+			}
+			digest.add("file", rel, info.Mode().Perm(), sum)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return digest.sum(), nil
+}
+
+// stateContentDigest hashes a state tree as one line per entry (kind, mode,
+// path and file digest or link target), sorted by path so the result does not
+// depend on archive order.
+type stateContentDigest struct {
+	lines []string
+}
+
+func (d *stateContentDigest) add(kind string, rel string, mode os.FileMode, value string) {
+	d.lines = append(d.lines, fmt.Sprintf("%s\x00%s\x00%o\x00%s", path.Clean(rel), kind, mode, value))
+}
+
+func (d *stateContentDigest) sum() string {
+	sort.Strings(d.lines)
+	hash := sha256.New()
+	for _, line := range d.lines {
+		_, _ = io.WriteString(hash, line+"\n")
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
@@ -0,0 +1,332 @@
+package prepare
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sqlrs/engine-local/internal/store"
+)
+
+func newStateTransferSource(t *testing.T) (*PrepareService, string) {
+	t.Helper()
+	m := &PrepareService{}
+	stateID, errResp := m.computeOutputStateID("", "image", "postgres:17", "task-hash")
+	if errResp != nil {
+		t.Fatalf("state id: %+v", errResp)
+	}
+	st := &fakeStore{statesByID: map[string]store.StateEntry{
+		stateID: {
+			StateID:     stateID,
+			ImageID:     "postgres:17",
+			PrepareKind: "psql",
+			PrepareArgs: "-f init.sql",
+			CreatedAt:   "2026-01-01T00:00:00Z",
+			TaskHash:    "task-hash",
+		},
+	}}
+	mgr := newManagerWithDeps(t, st, newQueueStore(t), nil)
+	paths, err := resolveStatePaths(mgr.stateStoreRoot, "postgres:17", stateID, mgr.statefs)
+	if err != nil {
+		t.Fatalf("paths: %v", err)
+	}
+	if err := os.MkdirAll(paths.stateDir, 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(paths.stateDir, "PG_VERSION"), []byte("17\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	return mgr, stateID
+}
+
+func exportStateArchive(t *testing.T, mgr *PrepareService, stateID string) []byte {
+	t.Helper()
+	meta, ok, err := mgr.StateExport(context.Background(), stateID)
+	if err != nil || !ok {
+		t.Fatalf("StateExport: ok=%v err=%v", ok, err)
+	}
+	var buf bytes.Buffer
+	if err := mgr.WriteStateArchive(context.Background(), meta, &buf); err != nil {
+		t.Fatalf("WriteStateArchive: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestStateExportImportRoundTrip(t *testing.T) {
+	source, stateID := newStateTransferSource(t)
+	archive := exportStateArchive(t, source, stateID)
+
+	tr := tar.NewReader(bytes.NewReader(archive))
+	var names []string
+	for {
+		header, err := tr.Next()
+		if err != nil {
+			break
+		}
+		names = append(names, header.Name)
+	}
+	if len(names) < 2 || names[0] != stateArchiveMetadata || !strings.Contains(strings.Join(names, ","), "data/PG_VERSION") {
+		t.Fatalf("unexpected archive entries: %v", names)
+	}
+
+	targetStore := &fakeStore{}
+	statefs := &fakeStateFS{}
+	target := newManagerWithDeps(t, targetStore, newQueueStore(t), &testDeps{statefs: statefs})
+	result, err := target.ImportState(context.Background(), bytes.NewReader(archive))
+	if err != nil {
+		t.Fatalf("ImportState: %v", err)
+	}
+	if !result.Created || result.StateID != stateID {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if len(targetStore.states) != 1 || targetStore.states[0].PrepareArgsNormalized != "-f init.sql" || targetStore.states[0].ImageID != "postgres:17" || targetStore.states[0].TaskHash != "task-hash" {
+		t.Fatalf("unexpected created states: %+v", targetStore.states)
+	}
+	if len(statefs.snapshotCalls) != 1 {
+		t.Fatalf("expected one snapshot, got %v", statefs.snapshotCalls)
+	}
+
+	result, err = target.ImportState(context.Background(), bytes.NewReader(archive))
+	if err != nil || result.Created {
+		t.Fatalf("expected existing state to be kept, got %+v err=%v", result, err)
+	}
+	if len(targetStore.states) != 1 {
+		t.Fatalf("expected no second state, got %d", len(targetStore.states))
+	}
+}
+
+func TestStateImportRejectsMismatchedID(t *testing.T) {
+	source, stateID := newStateTransferSource(t)
+	archive := exportStateArchive(t, source, stateID)
+	meta, _, _ := source.StateExport(context.Background(), stateID)
+	meta.TaskHash = "other-hash"
+	tampered := replaceStateArchiveMetadata(t, archive, meta)
+
+	target := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), nil)
+	_, err := target.ImportState(context.Background(), bytes.NewReader(tampered))
+	var validation ValidationError
+	if !errors.As(err, &validation) || !strings.Contains(validation.Message, "does not match") {
+		t.Fatalf("expected id mismatch, got %v", err)
+	}
+}
+
+func TestStateImportRequiresParent(t *testing.T) {
+	m := &PrepareService{}
+	parent := "parent-state"
+	stateID, _ := m.computeOutputStateID("", "state", parent, "task-hash")
+	meta := StateArchiveMetadata{
+		Format:        stateArchiveFormat,
+		StateID:       stateID,
+		ParentStateID: &parent,
+		ImageID:       "postgres:17",
+		PrepareKind:   "psql",
+		TaskHash:      "task-hash",
+	}
+	target := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), nil)
+	_, err := target.ImportState(context.Background(), bytes.NewReader(buildStateArchive(t, meta, nil)))
+	if err == nil || !strings.Contains(err.Error(), "parent state is not present") {
+		t.Fatalf("expected missing parent error, got %v", err)
+	}
+}
+
+func TestStateImportRejectsEscapingEntries(t *testing.T) {
+	m := &PrepareService{}
+	stateID, _ := m.computeOutputStateID("", "image", "postgres:17", "task-hash")
+	meta := StateArchiveMetadata{
+		Format:      stateArchiveFormat,
+		StateID:     stateID,
+		ImageID:     "postgres:17",
+		PrepareKind: "psql",
+		TaskHash:    "task-hash",
+	}
+	target := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), nil)
+	_, err := target.ImportState(context.Background(), bytes.NewReader(buildStateArchive(t, meta, map[string]string{"data/../escape": "x"})))
+	if err == nil || !strings.Contains(err.Error(), "invalid state archive entry") {
+		t.Fatalf("expected invalid entry error, got %v", err)
+	}
+}
+
+func TestStateImportRejectsContentMismatch(t *testing.T) {
+	source, stateID := newStateTransferSource(t)
+	archive := exportStateArchive(t, source, stateID)
+	tr := tar.NewReader(bytes.NewReader(archive))
+	if _, err := tr.Next(); err != nil {
+		t.Fatalf("read metadata: %v", err)
+	}
+	var meta StateArchiveMetadata
+	if err := json.NewDecoder(tr).Decode(&meta); err != nil {
+		t.Fatalf("decode metadata: %v", err)
+	}
+	if meta.ContentSHA256 == "" {
+		t.Fatalf("expected exported metadata to carry a content digest")
+	}
+	tampered := buildStateArchive(t, meta, map[string]string{"data/PG_VERSION": "16\n"})
+
+	target := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), nil)
+	_, err := target.ImportState(context.Background(), bytes.NewReader(tampered))
+	var validation ValidationError
+	if !errors.As(err, &validation) || !strings.Contains(validation.Message, "content does not match") {
+		t.Fatalf("expected content mismatch, got %v", err)
+	}
+}
+
+func TestStateImportRejectsEscapingLinks(t *testing.T) {
+	m := &PrepareService{}
+	stateID, _ := m.computeOutputStateID("", "image", "postgres:17", "task-hash")
+	meta := StateArchiveMetadata{
+		Format:        stateArchiveFormat,
+		StateID:       stateID,
+		ImageID:       "postgres:17",
+		PrepareKind:   "psql",
+		TaskHash:      "task-hash",
+		ContentSHA256: "unchecked",
+	}
+	cases := map[string][]tar.Header{
+		"absolute link": {
+			{Name: "data/evil", Typeflag: tar.TypeSymlink, Linkname: "/etc"},
+		},
+		"link through link": {
+			{Name: "data/d/", Typeflag: tar.TypeDir, Mode: 0o700},
+			{Name: "data/d/up", Typeflag: tar.TypeSymlink, Linkname: ".."},
+			{Name: "data/evil", Typeflag: tar.TypeSymlink, Linkname: "d/up/.."},
+		},
+		"write through link": {
+			{Name: "data/d/", Typeflag: tar.TypeDir, Mode: 0o700},
+			{Name: "data/d/up", Typeflag: tar.TypeSymlink, Linkname: ".."},
+			{Name: "data/x", Typeflag: tar.TypeSymlink, Linkname: "d/up/.."},
+			{Name: "data/x/pwned", Typeflag: tar.TypeReg, Mode: 0o600},
+		},
+		"hard link": {
+			{Name: "data/evil", Typeflag: tar.TypeLink, Linkname: "../outside"},
+		},
+	}
+	for name, entries := range cases {
+		st := &fakeStore{}
+		target := newManagerWithDeps(t, st, newQueueStore(t), nil)
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		payload, _ := json.Marshal(meta)
+		writeTarFile(t, tw, stateArchiveMetadata, payload)
+		for _, header := range entries {
+			header := header
+			if header.Typeflag == tar.TypeReg {
+				header.Size = int64(len("pwned"))
+			}
+			if err := tw.WriteHeader(&header); err != nil {
+				t.Fatalf("header: %v", err)
+			}
+			if header.Typeflag == tar.TypeReg {
+				_, _ = tw.Write([]byte("pwned"))
+			}
+		}
+		if err := tw.Close(); err != nil {
+			t.Fatalf("close: %v", err)
+		}
+		_, err := target.ImportState(context.Background(), bytes.NewReader(buf.Bytes()))
+		var validation ValidationError
+		if !errors.As(err, &validation) {
+			t.Fatalf("%s: expected validation error, got %v", name, err)
+		}
+		if len(st.states) != 0 {
+			t.Fatalf("%s: expected no state to be registered", name)
+		}
+		var escaped []string
+		_ = filepath.WalkDir(target.stateStoreRoot, func(p string, d fs.DirEntry, err error) error {
+			if err == nil && d.Name() == "pwned" {
+				escaped = append(escaped, p)
+			}
+			return nil
+		})
+		if len(escaped) != 0 {
+			t.Fatalf("%s: expected nothing written, found %v", name, escaped)
+		}
+	}
+}
+
+func TestStateExportReadsTaskHashFromState(t *testing.T) {
+	source, stateID := newStateTransferSource(t)
+	// The job that built the state is long gone; the state row alone is enough.
+	source.queue = newQueueStore(t)
+	meta, ok, err := source.StateExport(context.Background(), stateID)
+	if err != nil || !ok || meta.TaskHash != "task-hash" {
+		t.Fatalf("expected the recorded task hash, got %+v ok=%v err=%v", meta, ok, err)
+	}
+
+	st := source.store.(*fakeStore)
+	entry := st.statesByID[stateID]
+	entry.TaskHash = ""
+	st.statesByID[stateID] = entry
+	_, ok, err = source.StateExport(context.Background(), stateID)
+	var conflict ConflictError
+	if !ok || !errors.As(err, &conflict) {
+		t.Fatalf("expected conflict for a state without a task hash, got ok=%v err=%v", ok, err)
+	}
+
+	if _, ok, err := source.StateExport(context.Background(), "missing"); ok || err != nil {
+		t.Fatalf("expected not found, got ok=%v err=%v", ok, err)
+	}
+}
+
+func buildStateArchive(t *testing.T, meta StateArchiveMetadata, files map[string]string) []byte {
+	t.Helper()
+	if meta.ContentSHA256 == "" {
+		digest := &stateContentDigest{}
+		for name, content := range files {
+			sum := sha256.Sum256([]byte(content))
+			digest.add("file", strings.TrimPrefix(name, stateArchiveDataDir+"/"), 0o600, hex.EncodeToString(sum[:]))
+		}
+		meta.ContentSHA256 = digest.sum()
+	}
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	payload, err := json.Marshal(meta)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	writeTarFile(t, tw, stateArchiveMetadata, payload)
+	for name, content := range files {
+		writeTarFile(t, tw, name, []byte(content))
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func replaceStateArchiveMetadata(t *testing.T, archive []byte, meta StateArchiveMetadata) []byte {
+	t.Helper()
+	files := map[string]string{}
+	tr := tar.NewReader(bytes.NewReader(archive))
+	for {
+		header, err := tr.Next()
+		if err != nil {
+			break
+		}
+		if header.Typeflag != tar.TypeReg || header.Name == stateArchiveMetadata {
+			continue
+		}
+		var content bytes.Buffer
+		_, _ = content.ReadFrom(tr)
+		files[header.Name] = content.String()
+	}
+	return buildStateArchive(t, meta, files)
+}
+
+func writeTarFile(t *testing.T, tw *tar.Writer, name string, content []byte) {
+	t.Helper()
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o600, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+		t.Fatalf("header: %v", err)
+	}
+	if _, err := tw.Write(content); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+}
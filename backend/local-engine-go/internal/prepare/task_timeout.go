@@ -0,0 +1,67 @@
+package prepare
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const defaultTaskTimeout = 10 * time.Minute
+
+// parseTaskTimeout validates Request.TaskTimeout; zero means "use config".
+func parseTaskTimeout(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		return 0, ValidationError{Code: ErrorCodeInvalidArgument, Message: "task_timeout must be a positive duration", Details: value}
+	}
+	return timeout, nil
+}
+
+// taskTimeout resolves the per-task deadline: the request override wins, then
+// orchestrator.tasks.timeout, then the default. A configured "0" disables it.
+func (m *PrepareService) taskTimeout(req Request) time.Duration {
+	if timeout, err := parseTaskTimeout(req.TaskTimeout); err == nil && timeout > 0 {
+		return timeout
+	}
+	if m.config == nil {
+		return defaultTaskTimeout
+	}
+	value, err := m.config.Get("orchestrator.tasks.timeout", true)
+	if err != nil || value == nil {
+		return defaultTaskTimeout
+	}
+	raw, ok := value.(string)
+	if !ok {
+		return defaultTaskTimeout
+	}
+	timeout, err := time.ParseDuration(strings.TrimSpace(raw))
+	if err != nil || timeout < 0 {
+		return defaultTaskTimeout
+	}
+	return timeout
+}
+
+// runTask runs fn under the per-task deadline. When the deadline fires and the
+// task fails, its own error (usually "cancelled") is replaced with
+// deadline_exceeded so it is not confused with a job cancellation. A task that
+// succeeds right at the deadline keeps its result.
+func (m *PrepareService) runTask(ctx context.Context, jobID string, prepared preparedRequest, taskID string, fn func(ctx context.Context) *ErrorResponse) *ErrorResponse {
+	timeout := m.taskTimeout(prepared.request)
+	if timeout <= 0 {
+		return fn(ctx)
+	}
+	taskCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	errResp := fn(taskCtx)
+	if errResp != nil && ctx.Err() == nil && errors.Is(taskCtx.Err(), context.DeadlineExceeded) {
+		m.logWarnJob(jobID, "task=%s timed out after %s", taskID, timeout)
+		return errorResponse(ErrorCodeDeadlineExceeded, "task timed out", fmt.Sprintf("task %s exceeded %s", taskID, timeout))
+	}
+	return errResp
+}
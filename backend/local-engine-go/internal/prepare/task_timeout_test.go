@@ -0,0 +1,127 @@
+package prepare
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	engineRuntime "github.com/sqlrs/engine-local/internal/runtime"
+)
+
+type blockingPsqlRunner struct{}
+
+func (blockingPsqlRunner) Run(ctx context.Context, instance engineRuntime.Instance, req PsqlRunRequest) (string, error) {
+	<-ctx.Done()
+	return "", ctx.Err()
+}
+
+func TestTaskTimeoutResolution(t *testing.T) {
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{})
+	if got := mgr.taskTimeout(Request{}); got != defaultTaskTimeout {
+		t.Fatalf("expected default timeout, got %s", got)
+	}
+	if got := mgr.taskTimeout(Request{TaskTimeout: "30s"}); got != 30*time.Second {
+		t.Fatalf("expected request override, got %s", got)
+	}
+
+	mgr = newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{
+		config: &fakeConfigStore{values: map[string]any{"orchestrator.tasks.timeout": "2m"}},
+	})
+	if got := mgr.taskTimeout(Request{}); got != 2*time.Minute {
+		t.Fatalf("expected configured timeout, got %s", got)
+	}
+
+	mgr = newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{
+		config: &fakeConfigStore{values: map[string]any{"orchestrator.tasks.timeout": "0"}},
+	})
+	if got := mgr.taskTimeout(Request{}); got != 0 {
+		t.Fatalf("expected zero to disable the timeout, got %s", got)
+	}
+
+	mgr = newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{
+		config: &fakeConfigStore{values: map[string]any{"orchestrator.tasks.timeout": "soon"}},
+	})
+	if got := mgr.taskTimeout(Request{}); got != defaultTaskTimeout {
+		t.Fatalf("expected invalid config to fall back to default, got %s", got)
+	}
+}
+
+func TestRunTaskKeepsSuccessAtDeadline(t *testing.T) {
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{})
+	prepared := preparedRequest{request: Request{TaskTimeout: "10ms"}}
+	errResp := mgr.runTask(context.Background(), "job-1", prepared, "execute-0", func(ctx context.Context) *ErrorResponse {
+		<-ctx.Done()
+		return nil
+	})
+	if errResp != nil {
+		t.Fatalf("expected success to be kept, got %+v", errResp)
+	}
+	errResp = mgr.runTask(context.Background(), "job-1", prepared, "execute-0", func(ctx context.Context) *ErrorResponse {
+		<-ctx.Done()
+		return errorResponse(ErrorCodeCancelled, "cancelled", "")
+	})
+	if errResp == nil || errResp.Code != ErrorCodeDeadlineExceeded {
+		t.Fatalf("expected deadline_exceeded, got %+v", errResp)
+	}
+}
+
+func TestPrepareRequestRejectsInvalidTaskTimeout(t *testing.T) {
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{})
+	for _, value := range []string{"soon", "-1m", "0s"} {
+		_, err := mgr.prepareRequest(Request{
+			PrepareKind: "psql",
+			ImageID:     "image-1",
+			PsqlArgs:    []string{"-c", "select 1"},
+			TaskTimeout: value,
+		})
+		var validation ValidationError
+		if !errors.As(err, &validation) {
+			t.Fatalf("expected validation error for %q, got %v", value, err)
+		}
+	}
+}
+
+func TestRunJobFailsTaskOnTimeout(t *testing.T) {
+	runtime := &fakeRuntime{}
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{
+		runtime: runtime,
+		psql:    blockingPsqlRunner{},
+	})
+
+	accepted, err := mgr.Submit(context.Background(), Request{
+		PrepareKind: "psql",
+		ImageID:     "image-1",
+		PsqlArgs:    []string{"-c", "select pg_sleep(3600)"},
+		TaskTimeout: "50ms",
+	})
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+
+	status, ok := mgr.Get(accepted.JobID)
+	if !ok || status.Status != StatusFailed {
+		t.Fatalf("expected failed job, got %+v", status)
+	}
+	if status.Error == nil || status.Error.Code != "deadline_exceeded" {
+		t.Fatalf("expected deadline_exceeded error, got %+v", status.Error)
+	}
+	var timedOut bool
+	for _, task := range mgr.ListTasks(accepted.JobID) {
+		if task.TaskID == "execute-0" {
+			timedOut = task.Status == StatusFailed
+		}
+	}
+	if !timedOut {
+		t.Fatalf("expected execute task to be failed, got %+v", mgr.ListTasks(accepted.JobID))
+	}
+	if len(runtime.stopCalls) == 0 {
+		t.Fatalf("expected runtime cleanup after timeout")
+	}
+	mgr.mu.Lock()
+	_, beating := mgr.beats[accepted.JobID]
+	mgr.mu.Unlock()
+	if beating {
+		t.Fatalf("expected heartbeat to stop after timeout")
+	}
+}
@@ -12,18 +12,21 @@ import (
 var testLayoutFS = statefs.NewManager(statefs.Options{Backend: "copy"})
 
 type fakeStateFS struct {
-	kind           string
-	caps           statefs.Capabilities
-	cloneCalls     []string
-	snapshotCalls  []string
-	removeCalls    []string
-	cloneErr       error
-	snapshotErr    error
-	removeErr      error
-	ensureBaseErr  error
-	ensureStateErr error
-	validateErr    error
-	mountDir       string
+	kind          string
+	caps          statefs.Capabilities
+	cloneCalls    []string
+	snapshotCalls []string
+	removeCalls   []string
+	cloneErr      error
+	snapshotErr   error
+	// snapshotFailures limits snapshotErr to the first calls; zero fails
+	// every call.
+	snapshotFailures int
+	removeErr        error
+	ensureBaseErr    error
+	ensureStateErr   error
+	validateErr      error
+	mountDir         string
 }
 
 func (f *fakeStateFS) Kind() string {
@@ -110,7 +113,7 @@ func (f *fakeStateFS) Clone(ctx context.Context, srcDir, destDir string) (statef
 func (f *fakeStateFS) Snapshot(ctx context.Context, srcDir, destDir string) error {
 	if f != nil {
 		f.snapshotCalls = append(f.snapshotCalls, srcDir)
-		if f.snapshotErr != nil {
+		if f.snapshotErr != nil && (f.snapshotFailures == 0 || len(f.snapshotCalls) <= f.snapshotFailures) {
 			return f.snapshotErr
 		}
 	}
@@ -0,0 +1,28 @@
+-- *********************************************************************
+-- Update Database Script
+-- *********************************************************************
+-- Change Log: changelog.sql
+-- Ran at: 1/15/24, 10:12 AM
+-- Against: sqlrs@jdbc:postgresql://127.0.0.1:5432/postgres
+-- Liquibase version: 4.17.2
+-- *********************************************************************
+
+-- Lock Database
+UPDATE public.databasechangeloglock SET LOCKED = TRUE, LOCKEDBY = '5f0c2a91b7d3 (172.17.0.3)', LOCKGRANTED = NOW() WHERE ID = 1 AND LOCKED = FALSE;
+
+-- Changeset changelog.sql::create-accounts::carol
+CREATE TABLE accounts (
+    id bigint PRIMARY KEY,
+    email text NOT NULL
+);
+
+INSERT INTO public.databasechangelog (ID, AUTHOR, FILENAME, DATEEXECUTED, ORDEREXECUTED, MD5SUM, DESCRIPTION, COMMENTS, EXECTYPE, CONTEXTS, LABELS, LIQUIBASE, DEPLOYMENT_ID) VALUES ('create-accounts', 'carol', 'changelog.sql', NOW(), 1, '8:a1b2c3d4e5f60718293a4b5c6d7e8f90', 'sql', '', 'EXECUTED', NULL, NULL, '4.17.2', '5311520123');
+
+-- Changeset changelog.sql::seed-accounts::carol
+INSERT INTO accounts (id, email) VALUES (1, 'admin@example.com');
+
+INSERT INTO public.databasechangelog (ID, AUTHOR, FILENAME, DATEEXECUTED, ORDEREXECUTED, MD5SUM, DESCRIPTION, COMMENTS, EXECTYPE, CONTEXTS, LABELS, LIQUIBASE, DEPLOYMENT_ID) VALUES ('seed-accounts', 'carol', 'changelog.sql', NOW(), 2, '8:0f9e8d7c6b5a49382716a5b4c3d2e1f0', 'sql', '', 'EXECUTED', NULL, NULL, '4.17.2', '5311520123');
+
+-- Release Database Lock
+UPDATE public.databasechangeloglock SET LOCKED = FALSE, LOCKEDBY = NULL, LOCKGRANTED = NULL WHERE ID = 1;
+
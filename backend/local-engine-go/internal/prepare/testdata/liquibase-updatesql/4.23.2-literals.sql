@@ -0,0 +1,39 @@
+-- *********************************************************************
+-- Update Database Script
+-- *********************************************************************
+-- Change Log: db/changelog.yaml
+-- Ran at: 1/15/24, 10:12 AM
+-- Against: sqlrs@jdbc:postgresql://127.0.0.1:5432/postgres
+-- Liquibase version: 4.23.2
+-- *********************************************************************
+
+-- Lock Database
+UPDATE public.databasechangeloglock SET LOCKED = TRUE, LOCKEDBY = '5f0c2a91b7d3 (172.17.0.3)', LOCKGRANTED = NOW() WHERE ID = 1 AND LOCKED = FALSE;
+
+-- Changeset db/functions.sql::audit-fn::dave
+CREATE OR REPLACE FUNCTION audit_note() RETURNS text AS $body$
+BEGIN
+-- Changeset fake.sql::not-a-changeset::mallory
+  RETURN 'it''s fine';
+END;
+$body$ LANGUAGE plpgsql;
+
+INSERT INTO public.databasechangelog (ID, AUTHOR, FILENAME, DATEEXECUTED, ORDEREXECUTED, MD5SUM, DESCRIPTION, COMMENTS, EXECTYPE, CONTEXTS, LABELS, LIQUIBASE, DEPLOYMENT_ID) VALUES ('audit-fn', 'dave', 'db/functions.sql', NOW(), 1, '8:11112222333344445555666677778888', 'sql', '', 'EXECUTED', NULL, NULL, '4.23.2', '5311520123');
+
+-- Changeset db/seed.sql::notes::dave
+INSERT INTO notes (body) VALUES ('first line
+-- Changeset fake.sql::inside-string::mallory
+last line');
+
+/* a block comment
+-- Changeset fake.sql::inside-comment::mallory
+*/
+INSERT INTO notes (body) VALUES (E'escaped \' quote
+-- Changeset fake.sql::inside-escape-string::mallory
+');
+
+INSERT INTO public.databasechangelog (ID, AUTHOR, FILENAME, DATEEXECUTED, ORDEREXECUTED, MD5SUM, DESCRIPTION, COMMENTS, EXECTYPE, CONTEXTS, LABELS, LIQUIBASE, DEPLOYMENT_ID) VALUES ('notes', 'dave', 'db/seed.sql', NOW(), 2, '8:99990000aaaabbbbccccddddeeeeffff', 'sql', '', 'EXECUTED', NULL, NULL, '4.23.2', '5311520123');
+
+-- Release Database Lock
+UPDATE public.databasechangeloglock SET LOCKED = FALSE, LOCKEDBY = NULL, LOCKGRANTED = NULL WHERE ID = 1;
+
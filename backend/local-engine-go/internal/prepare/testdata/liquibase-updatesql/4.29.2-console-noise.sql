@@ -0,0 +1,49 @@
+####################################################
+##   _     _             _ _                      ##
+##  | |   (_)           (_) |                     ##
+##  | |    _  __ _ _   _ _| |__   __ _ ___  ___   ##
+##  |_____|_|\__, |\__,_|_|_.__/ \__,_|___/\___|  ##
+##              | |                               ##
+##              |_|                               ##
+##                                                ##
+##  Get documentation at docs.liquibase.com       ##
+####################################################
+Starting Liquibase at 10:12:01 (version 4.29.2 #3683 built at 2024-08-29 16:37+0000)
+Liquibase Version: 4.29.2
+Liquibase Open Source 4.29.2 by Liquibase
+-- *********************************************************************
+-- Update Database Script
+-- *********************************************************************
+-- Change Log: changelog.xml
+-- Ran at: 1/15/24, 10:12 AM
+-- Against: sqlrs@jdbc:postgresql://127.0.0.1:5432/postgres
+-- Liquibase version: 4.29.2
+-- *********************************************************************
+
+-- Lock Database
+UPDATE public.databasechangeloglock SET LOCKED = TRUE, LOCKEDBY = '5f0c2a91b7d3 (172.17.0.3)', LOCKGRANTED = NOW() WHERE ID = 1 AND LOCKED = FALSE;
+
+-- Changeset changelog.xml::1::erin
+CREATE TABLE public.items (id INTEGER NOT NULL, CONSTRAINT items_pkey PRIMARY KEY (id));
+
+INSERT INTO public.databasechangelog (ID, AUTHOR, FILENAME, DATEEXECUTED, ORDEREXECUTED, MD5SUM, DESCRIPTION, COMMENTS, EXECTYPE, CONTEXTS, LABELS, LIQUIBASE, DEPLOYMENT_ID) VALUES ('1', 'erin', 'changelog.xml', NOW(), 1, '9:4f3e2d1c0b9a88776655443322110000', 'createTable tableName=items', '', 'EXECUTED', NULL, NULL, '4.29.2', '5311520123');
+
+[2024-09-02 10:12:02] INFO [liquibase.changelog] ChangeSet changelog.xml::1::erin ran successfully in 3ms
+-- Changeset changelog.xml::2::erin
+CREATE INDEX items_id_idx ON public.items(id);
+
+INSERT INTO public.databasechangelog (ID, AUTHOR, FILENAME, DATEEXECUTED, ORDEREXECUTED, MD5SUM, DESCRIPTION, COMMENTS, EXECTYPE, CONTEXTS, LABELS, LIQUIBASE, DEPLOYMENT_ID) VALUES ('2', 'erin', 'changelog.xml', NOW(), 2, '9:00112233445566778899aabbccddeeff', 'createIndex indexName=items_id_idx, tableName=items', '', 'EXECUTED', NULL, NULL, '4.29.2', '5311520123');
+
+-- Release Database Lock
+UPDATE public.databasechangeloglock SET LOCKED = FALSE, LOCKEDBY = NULL, LOCKGRANTED = NULL WHERE ID = 1;
+
+
+UPDATE SUMMARY
+Run:                          2
+Previously run:               0
+Filtered out:                 0
+-------------------------------
+Total change sets:            2
+
+Liquibase: Update has been successful. Rows affected: 2
+Liquibase command 'updateSql' was executed successfully.
@@ -0,0 +1,30 @@
+-- *********************************************************************
+-- Update Database Script
+-- *********************************************************************
+-- Change Log: db/changelog/master.xml
+-- Ran at: 1/15/24, 10:12 AM
+-- Against: sqlrs@jdbc:postgresql://127.0.0.1:5432/postgres
+-- Liquibase version: 4.9.1
+-- *********************************************************************
+
+-- Lock Database
+UPDATE public.databasechangeloglock SET LOCKED = TRUE, LOCKEDBY = '5f0c2a91b7d3 (172.17.0.3)', LOCKGRANTED = NOW() WHERE ID = 1 AND LOCKED = FALSE;
+
+-- Create Database Change Log Table
+CREATE TABLE public.databasechangelog (ID VARCHAR(255) NOT NULL, AUTHOR VARCHAR(255) NOT NULL, FILENAME VARCHAR(255) NOT NULL, DATEEXECUTED TIMESTAMP WITHOUT TIME ZONE NOT NULL, ORDEREXECUTED INTEGER NOT NULL, EXECTYPE VARCHAR(10) NOT NULL, MD5SUM VARCHAR(35), DESCRIPTION VARCHAR(255), COMMENTS VARCHAR(255), TAG VARCHAR(255), LIQUIBASE VARCHAR(20), CONTEXTS VARCHAR(255), LABELS VARCHAR(255), DEPLOYMENT_ID VARCHAR(10));
+
+-- Changeset db/changelog/001-users.xml::1::alice
+CREATE TABLE public.users (id INTEGER GENERATED BY DEFAULT AS IDENTITY NOT NULL, name VARCHAR(255), CONSTRAINT users_pkey PRIMARY KEY (id));
+
+INSERT INTO public.databasechangelog (ID, AUTHOR, FILENAME, DATEEXECUTED, ORDEREXECUTED, MD5SUM, DESCRIPTION, COMMENTS, EXECTYPE, CONTEXTS, LABELS, LIQUIBASE, DEPLOYMENT_ID) VALUES ('1', 'alice', 'db/changelog/001-users.xml', NOW(), 1, '8:7d1b8c3e5d4f0a9c2b6e1f3a4d5c6b7a', 'createTable tableName=users', '', 'EXECUTED', NULL, NULL, '4.9.1', '5311520123');
+
+-- Changeset db/changelog/002-orders.xml::2::bob
+CREATE TABLE public.orders (id INTEGER GENERATED BY DEFAULT AS IDENTITY NOT NULL, user_id INTEGER NOT NULL, CONSTRAINT orders_pkey PRIMARY KEY (id));
+
+ALTER TABLE public.orders ADD CONSTRAINT orders_user_fk FOREIGN KEY (user_id) REFERENCES public.users (id);
+
+INSERT INTO public.databasechangelog (ID, AUTHOR, FILENAME, DATEEXECUTED, ORDEREXECUTED, MD5SUM, DESCRIPTION, COMMENTS, EXECTYPE, CONTEXTS, LABELS, LIQUIBASE, DEPLOYMENT_ID) VALUES ('2', 'bob', 'db/changelog/002-orders.xml', NOW(), 2, '8:2c4e6a8b0d1f3e5a7c9b1d3f5e7a9c0b', 'createTable tableName=orders; addForeignKeyConstraint baseTableName=orders, constraintName=orders_user_fk, referencedTableName=users', '', 'EXECUTED', NULL, NULL, '4.9.1', '5311520123');
+
+-- Release Database Lock
+UPDATE public.databasechangeloglock SET LOCKED = FALSE, LOCKEDBY = NULL, LOCKGRANTED = NULL WHERE ID = 1;
+
@@ -0,0 +1,118 @@
+package prepare
+
+import (
+	"context"
+	"strings"
+
+	"github.com/sqlrs/engine-local/internal/tracing"
+)
+
+// startJobSpan opens the root span of a job. It joins the trace passed to
+// Submit through a traceparent header, if any.
+func (m *PrepareService) startJobSpan(ctx context.Context, jobID string, prepared preparedRequest) (context.Context, *tracing.Span) {
+	if m.tracer == nil {
+		return ctx, nil
+	}
+	return m.tracer.Start(ctx, "prepare.job",
+		tracing.String("job.id", jobID),
+		tracing.String("prepare.kind", prepared.request.PrepareKind),
+		tracing.String("image.id", prepared.request.ImageID),
+		tracing.Bool("plan_only", prepared.request.PlanOnly),
+	)
+}
+
+func endJobSpan(span *tracing.Span, prepared preparedRequest, stateID string) {
+	if span == nil {
+		return
+	}
+	if digest := strings.TrimSpace(prepared.resolvedImageID); digest != "" {
+		span.SetAttributes(tracing.String("image.digest", digest))
+	}
+	if stateID != "" {
+		span.SetAttributes(tracing.String("state.id", stateID))
+	}
+	span.End()
+}
+
+// failJobSpan marks the running job's root span failed with the job error.
+func (m *PrepareService) failJobSpan(jobID string, errResp *ErrorResponse) {
+	m.mu.Lock()
+	runner := m.running[jobID]
+	var span *tracing.Span
+	if runner != nil {
+		span = runner.span
+	}
+	m.mu.Unlock()
+	if span == nil {
+		return
+	}
+	if errResp == nil {
+		span.SetError("job failed")
+		return
+	}
+	span.SetError(errResp.Code + ": " + errResp.Message)
+}
+
+// runTracedTask runs a task through runTask inside a span named after the task
+// type, so resolve_image, state_execute and prepare_instance show up as
+// children of the job span.
+func (m *PrepareService) runTracedTask(ctx context.Context, jobID string, prepared preparedRequest, task taskState, fn func(ctx context.Context) *ErrorResponse) *ErrorResponse {
+	if m.tracer == nil {
+		return m.runTask(ctx, jobID, prepared, task.TaskID, fn)
+	}
+	ctx, span := m.tracer.Start(ctx, "prepare."+task.Type, taskSpanAttrs(prepared, task)...)
+	errResp := m.runTask(ctx, jobID, prepared, task.TaskID, fn)
+	endSpan(span, errResp)
+	return errResp
+}
+
+// traceCachedTask records a state_execute task that was satisfied from the
+// cache as an empty span with cached=true.
+func (m *PrepareService) traceCachedTask(ctx context.Context, prepared preparedRequest, task taskState) {
+	if m.tracer == nil {
+		return
+	}
+	_, span := m.tracer.Start(ctx, "prepare."+task.Type, taskSpanAttrs(prepared, task)...)
+	span.End()
+}
+
+func taskSpanAttrs(prepared preparedRequest, task taskState) []tracing.Attr {
+	attrs := []tracing.Attr{
+		tracing.String("task.id", task.TaskID),
+		tracing.String("prepare.kind", prepared.request.PrepareKind),
+	}
+	digest := strings.TrimSpace(task.ResolvedImageID)
+	if digest == "" {
+		digest = strings.TrimSpace(prepared.resolvedImageID)
+	}
+	if digest != "" {
+		attrs = append(attrs, tracing.String("image.digest", digest))
+	}
+	if task.OutputStateID != "" {
+		attrs = append(attrs, tracing.String("state.id", task.OutputStateID))
+	}
+	if task.Type == "state_execute" {
+		attrs = append(attrs, tracing.Bool("cached", task.Cached != nil && *task.Cached))
+	}
+	return attrs
+}
+
+// startPhaseSpan opens a span for one sub-phase of a state_execute task
+// (execute, prepare_snapshot, snapshot, resume_snapshot).
+func (m *PrepareService) startPhaseSpan(ctx context.Context, phase string) *tracing.Span {
+	if m.tracer == nil {
+		return nil
+	}
+	_, span := m.tracer.Start(ctx, "prepare."+phase)
+	return span
+}
+
+func endSpan(span *tracing.Span, errResp *ErrorResponse) {
+	if span == nil {
+		return
+	}
+	if errResp != nil {
+		span.SetError(errResp.Code + ": " + errResp.Message)
+	}
+	span.End()
+}
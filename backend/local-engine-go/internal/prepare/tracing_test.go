@@ -0,0 +1,148 @@
+package prepare
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/sqlrs/engine-local/internal/tracing"
+)
+
+type exportedSpan struct {
+	TraceID      string `json:"traceId"`
+	SpanID       string `json:"spanId"`
+	ParentSpanID string `json:"parentSpanId"`
+	Name         string `json:"name"`
+	Attributes   []struct {
+		Key   string `json:"key"`
+		Value struct {
+			StringValue *string `json:"stringValue"`
+			BoolValue   *bool   `json:"boolValue"`
+		} `json:"value"`
+	} `json:"attributes"`
+}
+
+func newSpanCollector(t *testing.T) (*httptest.Server, func() []exportedSpan) {
+	t.Helper()
+	var mu sync.Mutex
+	var spans []exportedSpan
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			ResourceSpans []struct {
+				ScopeSpans []struct {
+					Spans []exportedSpan `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		for _, rs := range body.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				spans = append(spans, ss.Spans...)
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []exportedSpan {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]exportedSpan(nil), spans...)
+	}
+}
+
+func (s exportedSpan) attr(key string) (string, bool) {
+	for _, attr := range s.Attributes {
+		if attr.Key != key {
+			continue
+		}
+		if attr.Value.StringValue != nil {
+			return *attr.Value.StringValue, true
+		}
+		if attr.Value.BoolValue != nil {
+			if *attr.Value.BoolValue {
+				return "true", true
+			}
+			return "false", true
+		}
+	}
+	return "", false
+}
+
+func TestSubmitEmitsJobTaskAndPhaseSpans(t *testing.T) {
+	server, collected := newSpanCollector(t)
+	mgr := newManager(t, &fakeStore{})
+	mgr.tracer = tracing.New(tracing.Options{Endpoint: server.URL})
+
+	ctx := tracing.Extract(context.Background(), "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if _, err := mgr.Submit(ctx, Request{
+		PrepareKind: "psql",
+		ImageID:     "image-1@sha256:abc",
+		PsqlArgs:    []string{"-c", "select 1"},
+	}); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	if err := mgr.tracer.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	byName := map[string]exportedSpan{}
+	for _, span := range collected() {
+		if span.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+			t.Fatalf("expected span in caller trace, got %+v", span)
+		}
+		byName[span.Name] = span
+	}
+	root, ok := byName["prepare.job"]
+	if !ok || root.ParentSpanID != "00f067aa0ba902b7" {
+		t.Fatalf("expected job span under remote parent, got %+v", byName)
+	}
+	if kind, _ := root.attr("prepare.kind"); kind != "psql" {
+		t.Fatalf("expected prepare.kind on job span, got %+v", root.Attributes)
+	}
+	if _, ok := root.attr("state.id"); !ok {
+		t.Fatalf("expected state.id on job span, got %+v", root.Attributes)
+	}
+	execute, ok := byName["prepare.state_execute"]
+	if !ok || execute.ParentSpanID != root.SpanID {
+		t.Fatalf("expected state_execute span under job span, got %+v", byName)
+	}
+	if cached, _ := execute.attr("cached"); cached != "false" {
+		t.Fatalf("expected cached=false on state_execute, got %+v", execute.Attributes)
+	}
+	for _, phase := range []string{"prepare.execute", "prepare.snapshot"} {
+		if span, ok := byName[phase]; !ok || span.ParentSpanID != execute.SpanID {
+			t.Fatalf("expected %s span under state_execute, got %+v", phase, byName)
+		}
+	}
+	if span, ok := byName["prepare.prepare_instance"]; !ok || span.ParentSpanID != root.SpanID {
+		t.Fatalf("expected prepare_instance span under job span, got %+v", byName)
+	}
+}
+
+func TestSubmitWithoutTracerSkipsSpans(t *testing.T) {
+	mgr := newManager(t, &fakeStore{})
+	prepared, err := mgr.prepareRequest(Request{PrepareKind: "psql", ImageID: "image-1", PsqlArgs: []string{"-c", "select 1"}})
+	if err != nil {
+		t.Fatalf("prepareRequest: %v", err)
+	}
+	ctx := context.Background()
+	gotCtx, span := mgr.startJobSpan(ctx, "job-1", prepared)
+	if gotCtx != ctx || span != nil {
+		t.Fatalf("expected no job span without tracer")
+	}
+	called := false
+	errResp := mgr.runTracedTask(ctx, "job-1", prepared, taskState{PlanTask: PlanTask{TaskID: "execute-0", Type: "state_execute"}}, func(ctx context.Context) *ErrorResponse {
+		called = true
+		return nil
+	})
+	if errResp != nil || !called {
+		t.Fatalf("expected task to run untraced, got %+v called=%v", errResp, called)
+	}
+}
@@ -8,11 +8,114 @@ type Request struct {
 	LiquibaseExec     string            `json:"liquibase_exec,omitempty"`
 	LiquibaseExecMode string            `json:"liquibase_exec_mode,omitempty"`
 	LiquibaseEnv      map[string]string `json:"liquibase_env,omitempty"`
+	FlywayArgs        []string          `json:"flyway_args,omitempty"`
+	FlywayExec        string            `json:"flyway_exec,omitempty"`
+	FlywayExecMode    string            `json:"flyway_exec_mode,omitempty"`
+	FlywayEnv         map[string]string `json:"flyway_env,omitempty"`
 	WorkDir           string            `json:"work_dir,omitempty"`
 	Stdin             *string           `json:"stdin,omitempty"`
 	PlanOnly          bool              `json:"plan_only,omitempty"`
+	IdempotencyKey    string            `json:"idempotency_key,omitempty"`
+	// LiquibaseStdin is a changelog passed inline instead of --changelog-file.
+	// The engine writes it under the work root and points Liquibase at it;
+	// LiquibaseStdinFormat (xml, yaml, json or sql; default xml) picks the
+	// parser.
+	LiquibaseStdin       *string `json:"liquibase_stdin,omitempty"`
+	LiquibaseStdinFormat string  `json:"liquibase_stdin_format,omitempty"`
+	// Pgpass is .pgpass content for psql jobs, mounted read-only into the
+	// prepare container with PGPASSFILE pointing at it. Passwords must be
+	// ${secret:name} references, resolved when the container starts. It is
+	// connection configuration and stays out of the task hash.
+	Pgpass string `json:"pgpass,omitempty"`
+	// InstanceMode is "ephemeral" (default) or "persistent".
+	InstanceMode string `json:"instance_mode,omitempty"`
+	// Namespace partitions states, jobs and instances under state-store/ns/{namespace}.
+	Namespace string `json:"namespace,omitempty"`
+	// TaskTimeout overrides orchestrator.tasks.timeout for this job (Go duration, e.g. "30m").
+	TaskTimeout string `json:"task_timeout,omitempty"`
+	// HeartbeatEvery sets how often a quiet running job emits "ping" events
+	// (Go duration), up to orchestrator.jobs.heartbeatMax.
+	HeartbeatEvery string `json:"heartbeat_every,omitempty"`
+	// PsqlSplit splits psql scripts into one cached state per statement, or per
+	// "-- sqlrs:checkpoint" section when the script has markers.
+	PsqlSplit bool `json:"psql_split,omitempty"`
+	// PsqlSingleTransaction runs each psql step with --single-transaction so a
+	// failing script leaves no partial changes behind. Scripts with their own
+	// BEGIN/COMMIT are rejected in this mode.
+	PsqlSingleTransaction bool `json:"psql_single_transaction,omitempty"`
+	// SearchPath and PsqlPreamble run ahead of the script in every psql
+	// session of the job (SET search_path first, then the preamble), inside
+	// its transaction with PsqlSingleTransaction. They change the resulting
+	// state, so they are part of the content hash. Preamble statements are
+	// plain SQL; meta-commands are rejected.
+	SearchPath   string   `json:"search_path,omitempty"`
+	PsqlPreamble []string `json:"psql_preamble,omitempty"`
+	// StatementTimeout overrides prepare.psql.statementTimeout for this job
+	// (Go duration, e.g. "30s"). psql steps run with statement_timeout set, so
+	// a stuck statement fails with deadline_exceeded. It never affects
+	// signatures or caching.
+	StatementTimeout string `json:"statement_timeout,omitempty"`
+	// Mounts exposes extra host paths to the psql execute container.
+	Mounts []MountSpec `json:"mounts,omitempty"`
+	// Labels are free-form job metadata (e.g. pr=1234) used to list and delete
+	// jobs; they never affect signatures or caching.
+	Labels map[string]string `json:"labels,omitempty"`
+	// BaseStateID builds the job on top of an existing state instead of the
+	// image. ImageID may be omitted; the job runs on the base state's image.
+	// A state tag is accepted too and replaced by the state ID it names.
+	BaseStateID string `json:"base_state_id,omitempty"`
+	// Coalesce returns the queued or running job with the same signature
+	// instead of starting an identical one. Coalesced callers share the job:
+	// cancelling or deleting it affects every caller waiting on it.
+	Coalesce bool `json:"coalesce,omitempty"`
+	// NoCache rebuilds every state of the job even when it is cached. A rebuilt
+	// state keeps its ID and replaces the cached one once its snapshot is
+	// complete.
+	NoCache bool `json:"no_cache,omitempty"`
+	// CancelOnDisconnect cancels the job once every client streaming its
+	// events has gone away for orchestrator.jobs.disconnectGrace. It has no
+	// effect on jobs nobody streams and never affects signatures or caching.
+	CancelOnDisconnect bool `json:"cancel_on_disconnect,omitempty"`
+	// CPULimit and MemoryLimit override container.limits.cpus and
+	// container.limits.memory for this job (e.g. "2" and "4g"). They never
+	// affect signatures or caching.
+	CPULimit    string `json:"cpu_limit,omitempty"`
+	MemoryLimit string `json:"memory_limit,omitempty"`
+	// Network attaches the prepare containers to a runtime network listed in
+	// container.network.allowed; DNS sets their resolvers. Both are
+	// environmental and never affect signatures or caching.
+	Network string   `json:"network,omitempty"`
+	DNS     []string `json:"dns,omitempty"`
+	// Platform selects the image variant (e.g. "linux/amd64") and defaults
+	// to container.platform. The image is resolved to that platform's digest,
+	// so each platform gets its own states.
+	Platform string `json:"platform,omitempty"`
+	// Build produces the base image with docker build instead of pulling
+	// ImageID; it requires container.build.enabled. The engine tags the image
+	// with a hash of the build context, so an unchanged context builds from
+	// the layer cache to the same image and reuses cached states.
+	Build *BuildSpec `json:"build,omitempty"`
 }
 
+// BuildSpec names a docker build context on the engine host.
+type BuildSpec struct {
+	ContextDir string `json:"context_dir"`
+	// Dockerfile is relative to ContextDir; empty means "Dockerfile".
+	Dockerfile string `json:"dockerfile,omitempty"`
+}
+
+// MountSpec binds a host path (Source) into the container at Target.
+type MountSpec struct {
+	Source   string `json:"source"`
+	Target   string `json:"target"`
+	ReadOnly bool   `json:"read_only,omitempty"`
+}
+
+const (
+	instanceModeEphemeral  = "ephemeral"
+	instanceModePersistent = "persistent"
+)
+
 type Accepted struct {
 	JobID     string `json:"job_id"`
 	StatusURL string `json:"status_url"`
@@ -29,42 +132,60 @@ type CacheExplainPrepareResult struct {
 }
 
 type Status struct {
-	JobID                 string         `json:"job_id"`
-	Status                string         `json:"status"`
-	PrepareKind           string         `json:"prepare_kind"`
-	ImageID               string         `json:"image_id"`
-	PlanOnly              bool           `json:"plan_only,omitempty"`
-	PrepareArgsNormalized string         `json:"prepare_args_normalized,omitempty"`
-	CreatedAt             *string        `json:"created_at,omitempty"`
-	StartedAt             *string        `json:"started_at,omitempty"`
-	FinishedAt            *string        `json:"finished_at,omitempty"`
-	Tasks                 []PlanTask     `json:"tasks,omitempty"`
-	Result                *Result        `json:"result,omitempty"`
-	Error                 *ErrorResponse `json:"error,omitempty"`
+	JobID                 string            `json:"job_id"`
+	Status                string            `json:"status"`
+	PrepareKind           string            `json:"prepare_kind"`
+	ImageID               string            `json:"image_id"`
+	PlanOnly              bool              `json:"plan_only,omitempty"`
+	PrepareArgsNormalized string            `json:"prepare_args_normalized,omitempty"`
+	CreatedAt             *string           `json:"created_at,omitempty"`
+	StartedAt             *string           `json:"started_at,omitempty"`
+	FinishedAt            *string           `json:"finished_at,omitempty"`
+	Labels                map[string]string `json:"labels,omitempty"`
+	Tasks                 []PlanTask        `json:"tasks,omitempty"`
+	Result                *Result           `json:"result,omitempty"`
+	Error                 *ErrorResponse    `json:"error,omitempty"`
 }
 
 type JobEntry struct {
-	JobID                 string  `json:"job_id"`
-	Status                string  `json:"status"`
-	PrepareKind           string  `json:"prepare_kind"`
-	ImageID               string  `json:"image_id"`
-	ResolvedImageID       string  `json:"resolved_image_id,omitempty"`
-	PrepareArgsNormalized string  `json:"prepare_args_normalized,omitempty"`
-	Signature             string  `json:"signature,omitempty"`
-	PlanOnly              bool    `json:"plan_only,omitempty"`
-	CreatedAt             *string `json:"created_at,omitempty"`
-	StartedAt             *string `json:"started_at,omitempty"`
-	FinishedAt            *string `json:"finished_at,omitempty"`
+	JobID                 string            `json:"job_id"`
+	Status                string            `json:"status"`
+	PrepareKind           string            `json:"prepare_kind"`
+	ImageID               string            `json:"image_id"`
+	ResolvedImageID       string            `json:"resolved_image_id,omitempty"`
+	Namespace             string            `json:"namespace,omitempty"`
+	PrepareArgsNormalized string            `json:"prepare_args_normalized,omitempty"`
+	Signature             string            `json:"signature,omitempty"`
+	PlanOnly              bool              `json:"plan_only,omitempty"`
+	CreatedAt             *string           `json:"created_at,omitempty"`
+	StartedAt             *string           `json:"started_at,omitempty"`
+	FinishedAt            *string           `json:"finished_at,omitempty"`
+	Labels                map[string]string `json:"labels,omitempty"`
 }
 
 type Event struct {
-	Type    string         `json:"type"`
-	Ts      string         `json:"ts"`
-	Status  string         `json:"status,omitempty"`
-	TaskID  string         `json:"task_id,omitempty"`
-	Message string         `json:"message,omitempty"`
-	Result  *Result        `json:"result,omitempty"`
-	Error   *ErrorResponse `json:"error,omitempty"`
+	// Seq is the event's position in the engine-wide event log. It only grows,
+	// so unlike the stream offset it stays valid if older events are removed.
+	Seq      int64          `json:"seq,omitempty"`
+	Type     string         `json:"type"`
+	Ts       string         `json:"ts"`
+	Status   string         `json:"status,omitempty"`
+	TaskID   string         `json:"task_id,omitempty"`
+	Message  string         `json:"message,omitempty"`
+	Result   *Result        `json:"result,omitempty"`
+	Error    *ErrorResponse `json:"error,omitempty"`
+	Progress *TaskProgress  `json:"progress,omitempty"`
+	// Plan is the task list of a "plan" event, emitted once planning is done
+	// and before execution starts.
+	Plan []PlanTask `json:"plan,omitempty"`
+}
+
+// TaskProgress places a state_execute task among the job's execute tasks.
+// Completed counts execute tasks finished so far, including this one once it
+// has succeeded.
+type TaskProgress struct {
+	Completed int `json:"completed"`
+	Total     int `json:"total"`
 }
 
 type Result struct {
@@ -95,6 +216,9 @@ type PlanTask struct {
 	ChangesetID     string     `json:"changeset_id,omitempty"`
 	ChangesetAuthor string     `json:"changeset_author,omitempty"`
 	ChangesetPath   string     `json:"changeset_path,omitempty"`
+	StartedAt       *string    `json:"started_at,omitempty"`
+	FinishedAt      *string    `json:"finished_at,omitempty"`
+	DurationMs      *int64     `json:"duration_ms,omitempty"`
 }
 
 type TaskEntry struct {
@@ -114,6 +238,9 @@ type TaskEntry struct {
 	ChangesetID     string     `json:"changeset_id,omitempty"`
 	ChangesetAuthor string     `json:"changeset_author,omitempty"`
 	ChangesetPath   string     `json:"changeset_path,omitempty"`
+	StartedAt       *string    `json:"started_at,omitempty"`
+	FinishedAt      *string    `json:"finished_at,omitempty"`
+	DurationMs      *int64     `json:"duration_ms,omitempty"`
 }
 
 type ErrorResponse struct {
@@ -0,0 +1,178 @@
+package prepare
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sqlrs/engine-local/internal/store"
+)
+
+// usageTTL is how long a computed usage summary is served before it is
+// aggregated again.
+const usageTTL = 10 * time.Second
+
+// UsageSummary is the GET /v1/usage payload: a one-shot summary of the
+// state store and the prepare job history.
+type UsageSummary struct {
+	GeneratedAt string `json:"generated_at"`
+	// StateCount counts all states; StateSizeBytes sums the sizes of the
+	// states that have one and UnsizedStateCount counts the others.
+	StateCount        int            `json:"state_count"`
+	StateSizeBytes    int64          `json:"state_size_bytes"`
+	UnsizedStateCount int            `json:"unsized_state_count"`
+	Jobs              map[string]int `json:"jobs"`
+	Cache             UsageCache     `json:"cache"`
+	PrepareDuration   UsageDuration  `json:"prepare_duration"`
+}
+
+// UsageCache counts the state tasks of succeeded jobs that reused a cached
+// state. HitRatio is omitted when there are no such tasks.
+type UsageCache struct {
+	Hits     int      `json:"hits"`
+	Misses   int      `json:"misses"`
+	HitRatio *float64 `json:"hit_ratio,omitempty"`
+}
+
+// UsageDuration summarizes the run time of succeeded prepare jobs, from
+// started_at to finished_at, in milliseconds.
+type UsageDuration struct {
+	Count  int   `json:"count"`
+	MeanMs int64 `json:"mean_ms"`
+	P50Ms  int64 `json:"p50_ms"`
+	P95Ms  int64 `json:"p95_ms"`
+}
+
+type usageCache struct {
+	mu       sync.Mutex
+	summary  *UsageSummary
+	computed time.Time
+}
+
+// Usage aggregates the usage summary from the state store and the job
+// queue. The result is reused for usageTTL since the aggregation reads every
+// state, job and task.
+func (m *PrepareService) Usage(ctx context.Context) (UsageSummary, error) {
+	if m == nil || m.store == nil || m.queue == nil {
+		return UsageSummary{}, fmt.Errorf("prepare service is not configured")
+	}
+	m.usage.mu.Lock()
+	defer m.usage.mu.Unlock()
+	now := m.now()
+	if m.usage.summary != nil && now.Sub(m.usage.computed) < usageTTL {
+		return *m.usage.summary, nil
+	}
+	summary, err := m.computeUsage(ctx, now)
+	if err != nil {
+		return UsageSummary{}, err
+	}
+	m.usage.summary = &summary
+	m.usage.computed = now
+	return summary, nil
+}
+
+func (m *PrepareService) computeUsage(ctx context.Context, now time.Time) (UsageSummary, error) {
+	states, err := m.store.ListStates(ctx, store.StateFilters{})
+	if err != nil {
+		return UsageSummary{}, err
+	}
+	summary := UsageSummary{
+		GeneratedAt: now.UTC().Format(time.RFC3339Nano),
+		StateCount:  len(states),
+		Jobs:        map[string]int{StatusQueued: 0, StatusRunning: 0, StatusSucceeded: 0, StatusFailed: 0},
+	}
+	for _, state := range states {
+		if state.SizeBytes == nil {
+			summary.UnsizedStateCount++
+			continue
+		}
+		summary.StateSizeBytes += *state.SizeBytes
+	}
+
+	jobs, err := m.queue.ListJobsByStatus(ctx, []string{StatusQueued, StatusRunning, StatusSucceeded, StatusFailed})
+	if err != nil {
+		return UsageSummary{}, err
+	}
+	succeeded := map[string]struct{}{}
+	var durations []time.Duration
+	for _, job := range jobs {
+		summary.Jobs[job.Status]++
+		if job.Status != StatusSucceeded {
+			continue
+		}
+		succeeded[job.JobID] = struct{}{}
+		if job.PlanOnly {
+			continue
+		}
+		if duration, ok := jobDuration(job.StartedAt, job.FinishedAt); ok {
+			durations = append(durations, duration)
+		}
+	}
+	summary.PrepareDuration = summarizeDurations(durations)
+
+	tasks, err := m.queue.ListTasks(ctx, "")
+	if err != nil {
+		return UsageSummary{}, err
+	}
+	for _, task := range tasks {
+		if task.Cached == nil {
+			continue
+		}
+		if _, ok := succeeded[task.JobID]; !ok {
+			continue
+		}
+		if *task.Cached {
+			summary.Cache.Hits++
+		} else {
+			summary.Cache.Misses++
+		}
+	}
+	if total := summary.Cache.Hits + summary.Cache.Misses; total > 0 {
+		ratio := float64(summary.Cache.Hits) / float64(total)
+		summary.Cache.HitRatio = &ratio
+	}
+	return summary, nil
+}
+
+func jobDuration(startedAt *string, finishedAt *string) (time.Duration, bool) {
+	if startedAt == nil || finishedAt == nil {
+		return 0, false
+	}
+	started, err := time.Parse(time.RFC3339Nano, *startedAt)
+	if err != nil {
+		return 0, false
+	}
+	finished, err := time.Parse(time.RFC3339Nano, *finishedAt)
+	if err != nil || finished.Before(started) {
+		return 0, false
+	}
+	return finished.Sub(started), true
+}
+
+// summarizeDurations computes the mean and the nearest-rank p50 and p95.
+func summarizeDurations(durations []time.Duration) UsageDuration {
+	if len(durations) == 0 {
+		return UsageDuration{}
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	var total time.Duration
+	for _, duration := range durations {
+		total += duration
+	}
+	percentile := func(p float64) int64 {
+		rank := int(math.Ceil(p * float64(len(durations))))
+		if rank < 1 {
+			rank = 1
+		}
+		return durations[rank-1].Milliseconds()
+	}
+	return UsageDuration{
+		Count:  len(durations),
+		MeanMs: (total / time.Duration(len(durations))).Milliseconds(),
+		P50Ms:  percentile(0.50),
+		P95Ms:  percentile(0.95),
+	}
+}
@@ -0,0 +1,120 @@
+package prepare
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sqlrs/engine-local/internal/prepare/queue"
+	"github.com/sqlrs/engine-local/internal/store"
+)
+
+func TestUsageAggregatesStatesJobsAndTasks(t *testing.T) {
+	size := int64(100)
+	otherSize := int64(50)
+	stateStore := &fakeStore{listStates: []store.StateEntry{
+		{StateID: "state-1", SizeBytes: &size},
+		{StateID: "state-2", SizeBytes: &otherSize},
+		{StateID: "state-3"},
+	}}
+	queueStore := newQueueStore(t)
+	mgr := newManagerWithDeps(t, stateStore, queueStore, &testDeps{})
+	clock := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	mgr.now = func() time.Time { return clock }
+
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+	for i, seconds := range []int{1, 2, 3, 10} {
+		started := base.Format(time.RFC3339Nano)
+		finished := base.Add(time.Duration(seconds) * time.Second).Format(time.RFC3339Nano)
+		jobID := "job-ok-" + string(rune('a'+i))
+		if err := queueStore.CreateJob(ctx, queue.JobRecord{JobID: jobID, Status: StatusSucceeded, CreatedAt: started, StartedAt: &started, FinishedAt: &finished}); err != nil {
+			t.Fatalf("CreateJob: %v", err)
+		}
+	}
+	planStarted := base.Format(time.RFC3339Nano)
+	planFinished := base.Add(time.Hour).Format(time.RFC3339Nano)
+	for _, job := range []queue.JobRecord{
+		{JobID: "job-plan", Status: StatusSucceeded, PlanOnly: true, StartedAt: &planStarted, FinishedAt: &planFinished},
+		{JobID: "job-failed", Status: StatusFailed},
+		{JobID: "job-queued", Status: StatusQueued},
+	} {
+		job.CreatedAt = planStarted
+		if err := queueStore.CreateJob(ctx, job); err != nil {
+			t.Fatalf("CreateJob: %v", err)
+		}
+	}
+	hit, miss := true, false
+	if err := queueStore.ReplaceTasks(ctx, "job-ok-a", []queue.TaskRecord{
+		{JobID: "job-ok-a", TaskID: "plan", Type: "plan"},
+		{JobID: "job-ok-a", TaskID: "execute-0", Position: 1, Type: "state_execute", Cached: &hit},
+		{JobID: "job-ok-a", TaskID: "execute-1", Position: 2, Type: "state_execute", Cached: &miss},
+	}); err != nil {
+		t.Fatalf("ReplaceTasks: %v", err)
+	}
+	if err := queueStore.ReplaceTasks(ctx, "job-ok-b", []queue.TaskRecord{
+		{JobID: "job-ok-b", TaskID: "execute-0", Type: "state_execute", Cached: &hit},
+	}); err != nil {
+		t.Fatalf("ReplaceTasks: %v", err)
+	}
+	if err := queueStore.ReplaceTasks(ctx, "job-failed", []queue.TaskRecord{
+		{JobID: "job-failed", TaskID: "execute-0", Type: "state_execute", Cached: &miss},
+	}); err != nil {
+		t.Fatalf("ReplaceTasks: %v", err)
+	}
+
+	usage, err := mgr.Usage(ctx)
+	if err != nil {
+		t.Fatalf("Usage: %v", err)
+	}
+	if usage.StateCount != 3 || usage.StateSizeBytes != 150 || usage.UnsizedStateCount != 1 {
+		t.Fatalf("unexpected state totals: %+v", usage)
+	}
+	if usage.Jobs[StatusSucceeded] != 5 || usage.Jobs[StatusFailed] != 1 || usage.Jobs[StatusQueued] != 1 || usage.Jobs[StatusRunning] != 0 {
+		t.Fatalf("unexpected job counts: %+v", usage.Jobs)
+	}
+	if usage.Cache.Hits != 2 || usage.Cache.Misses != 1 || usage.Cache.HitRatio == nil || *usage.Cache.HitRatio < 0.66 || *usage.Cache.HitRatio > 0.67 {
+		t.Fatalf("unexpected cache usage: %+v", usage.Cache)
+	}
+	want := UsageDuration{Count: 4, MeanMs: 4000, P50Ms: 2000, P95Ms: 10000}
+	if usage.PrepareDuration != want {
+		t.Fatalf("unexpected durations: %+v, want %+v", usage.PrepareDuration, want)
+	}
+	if usage.GeneratedAt != clock.Format(time.RFC3339Nano) {
+		t.Fatalf("unexpected generated_at: %s", usage.GeneratedAt)
+	}
+}
+
+func TestUsageIsCachedBriefly(t *testing.T) {
+	stateStore := &fakeStore{listStates: []store.StateEntry{{StateID: "state-1"}}}
+	mgr := newManagerWithDeps(t, stateStore, newQueueStore(t), &testDeps{})
+	clock := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	mgr.now = func() time.Time { return clock }
+
+	first, err := mgr.Usage(context.Background())
+	if err != nil || first.StateCount != 1 {
+		t.Fatalf("Usage: %+v err=%v", first, err)
+	}
+	stateStore.listStates = append(stateStore.listStates, store.StateEntry{StateID: "state-2"})
+
+	clock = clock.Add(usageTTL / 2)
+	cached, err := mgr.Usage(context.Background())
+	if err != nil || cached.StateCount != 1 || cached.GeneratedAt != first.GeneratedAt {
+		t.Fatalf("expected the cached summary, got %+v err=%v", cached, err)
+	}
+
+	clock = clock.Add(usageTTL)
+	fresh, err := mgr.Usage(context.Background())
+	if err != nil || fresh.StateCount != 2 {
+		t.Fatalf("expected a fresh summary, got %+v err=%v", fresh, err)
+	}
+}
+
+func TestSummarizeDurationsEmpty(t *testing.T) {
+	if got := summarizeDurations(nil); got != (UsageDuration{}) {
+		t.Fatalf("expected zero summary, got %+v", got)
+	}
+	if _, ok := jobDuration(strPtr("bad"), strPtr("2026-01-01T00:00:00Z")); ok {
+		t.Fatalf("expected unparsable timestamps to be skipped")
+	}
+}
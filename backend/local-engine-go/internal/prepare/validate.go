@@ -0,0 +1,87 @@
+package prepare
+
+import (
+	"context"
+
+	engineRuntime "github.com/sqlrs/engine-local/internal/runtime"
+)
+
+// ValidationReport is the POST /v1/prepare-jobs/validate payload.
+type ValidationReport struct {
+	Valid bool `json:"valid"`
+	// Error is the error submit would return for the request.
+	Error       *ErrorResponse `json:"error,omitempty"`
+	PrepareKind string         `json:"prepare_kind,omitempty"`
+	ImageID     string         `json:"image_id,omitempty"`
+	// NormalizedImageRef is the image reference with its registry spelled
+	// out (docker.io/library/postgres:17); the digest is not resolved.
+	NormalizedImageRef    string   `json:"normalized_image_ref,omitempty"`
+	NormalizedArgs        []string `json:"normalized_args,omitempty"`
+	PrepareArgsNormalized string   `json:"prepare_args_normalized,omitempty"`
+	// PlanningRequiresContainer is true for kinds whose plan is read from the
+	// tool in a container (liquibase, flyway); their pending steps and cache
+	// hits are only known to a plan-only submit.
+	PlanningRequiresContainer bool                     `json:"planning_requires_container"`
+	Cache                     *ValidationCacheEstimate `json:"cache,omitempty"`
+}
+
+// ValidationCacheEstimate tells whether submitting the request is likely to
+// be a cache hit.
+type ValidationCacheEstimate struct {
+	// Decision is "hit", "miss" or "unknown".
+	Decision   string `json:"decision"`
+	ReasonCode string `json:"reason_code"`
+	StateID    string `json:"state_id,omitempty"`
+}
+
+// Validate checks a prepare request the way submit does (arguments, files,
+// image policy, limits) without side effects: no job is created, the image
+// digest is not resolved and no container is started. The cache estimate is
+// exact for psql requests whose input is already pinned (an image digest or a
+// base state); a tag needs a digest lookup, so the estimate is "unknown".
+// Request errors are reported in the report; other errors are returned.
+func (m *PrepareService) Validate(ctx context.Context, req Request) (ValidationReport, error) {
+	prepared, err := m.prepareRequest(req)
+	if err != nil {
+		resp := ToErrorResponse(err)
+		if resp.Code == "internal_error" {
+			return ValidationReport{}, err
+		}
+		return ValidationReport{Error: resp}, nil
+	}
+	report := ValidationReport{
+		Valid:                     true,
+		PrepareKind:               prepared.request.PrepareKind,
+		ImageID:                   prepared.request.ImageID,
+		NormalizedImageRef:        engineRuntime.NormalizeImageRef(prepared.request.ImageID),
+		NormalizedArgs:            prepared.normalizedArgs,
+		PrepareArgsNormalized:     prepared.argsNormalized,
+		PlanningRequiresContainer: plansFromRuntime(prepared.request.PrepareKind),
+	}
+	if report.PlanningRequiresContainer {
+		report.Cache = &ValidationCacheEstimate{Decision: "unknown", ReasonCode: "planned_in_container"}
+		return report, nil
+	}
+	if needsImageResolve(prepared.request.ImageID, prepared.request.Platform) {
+		report.Cache = &ValidationCacheEstimate{Decision: "unknown", ReasonCode: "image_not_resolved"}
+		return report, nil
+	}
+	prepared.resolvedImageID = prepared.request.ImageID
+	_, stateID, errResp := m.buildPlanPsql(prepared)
+	if errResp != nil {
+		return ValidationReport{}, errorFromExplainResponse(errResp)
+	}
+	cached, err := m.isStateCachedForPlan(prepared, stateID)
+	if err != nil {
+		return ValidationReport{}, err
+	}
+	report.Cache = &ValidationCacheEstimate{Decision: "miss", ReasonCode: "no_matching_state", StateID: stateID}
+	switch {
+	case prepared.request.NoCache:
+		report.Cache.ReasonCode = "no_cache"
+	case cached:
+		report.Cache.Decision = "hit"
+		report.Cache.ReasonCode = "exact_state_match"
+	}
+	return report, nil
+}
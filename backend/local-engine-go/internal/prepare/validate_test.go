@@ -0,0 +1,135 @@
+package prepare
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sqlrs/engine-local/internal/store"
+)
+
+func TestValidatePsqlWithTagDoesNotResolveImage(t *testing.T) {
+	rt := &fakeRuntime{}
+	queueStore := newQueueStore(t)
+	mgr := newManagerWithDeps(t, &fakeStore{}, queueStore, &testDeps{runtime: rt})
+
+	report, err := mgr.Validate(context.Background(), Request{
+		PrepareKind: "psql",
+		ImageID:     "postgres:17",
+		PsqlArgs:    []string{"-c", "select 1"},
+	})
+	if err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if !report.Valid || report.Error != nil || report.PlanningRequiresContainer {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if report.NormalizedImageRef != "docker.io/library/postgres:17" || len(report.NormalizedArgs) == 0 || report.PrepareArgsNormalized == "" {
+		t.Fatalf("unexpected normalization: %+v", report)
+	}
+	if report.Cache == nil || report.Cache.Decision != "unknown" || report.Cache.ReasonCode != "image_not_resolved" {
+		t.Fatalf("unexpected cache estimate: %+v", report.Cache)
+	}
+	if len(rt.resolveCalls) != 0 || len(rt.startCalls) != 0 {
+		t.Fatalf("expected no runtime calls, got resolve=%v start=%v", rt.resolveCalls, rt.startCalls)
+	}
+	jobs, err := queueStore.ListJobs(context.Background(), "")
+	if err != nil || len(jobs) != 0 {
+		t.Fatalf("expected no jobs, got %+v err=%v", jobs, err)
+	}
+}
+
+func TestValidatePsqlWithDigestEstimatesCacheHit(t *testing.T) {
+	stateStore := &fakeStore{statesByID: map[string]store.StateEntry{}}
+	mgr := newManager(t, stateStore)
+	scriptPath := filepath.Join(t.TempDir(), "prepare.sql")
+	if err := os.WriteFile(scriptPath, []byte("select 1;\n"), 0o600); err != nil {
+		t.Fatalf("write script: %v", err)
+	}
+	req := Request{
+		PrepareKind: "psql",
+		ImageID:     "image-1@sha256:abc",
+		PsqlArgs:    []string{"-f", scriptPath},
+	}
+
+	report, err := mgr.Validate(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if report.Cache == nil || report.Cache.Decision != "miss" || report.Cache.ReasonCode != "no_matching_state" || report.Cache.StateID == "" {
+		t.Fatalf("expected miss estimate, got %+v", report.Cache)
+	}
+
+	stateStore.statesByID[report.Cache.StateID] = store.StateEntry{StateID: report.Cache.StateID, ImageID: req.ImageID, PrepareKind: "psql"}
+	report, err = mgr.Validate(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if report.Cache.Decision != "hit" || report.Cache.ReasonCode != "exact_state_match" {
+		t.Fatalf("expected hit estimate, got %+v", report.Cache)
+	}
+
+	req.NoCache = true
+	report, err = mgr.Validate(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if report.Cache.Decision != "miss" || report.Cache.ReasonCode != "no_cache" {
+		t.Fatalf("expected no_cache estimate, got %+v", report.Cache)
+	}
+}
+
+func TestValidateReportsRequestErrors(t *testing.T) {
+	mgr := newManager(t, &fakeStore{})
+	report, err := mgr.Validate(context.Background(), Request{
+		PrepareKind: "psql",
+		ImageID:     "image-1",
+		PsqlArgs:    []string{"-f", filepath.Join(t.TempDir(), "missing.sql")},
+	})
+	if err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if report.Valid || report.Error == nil || report.Error.Code != "invalid_argument" {
+		t.Fatalf("expected invalid report, got %+v", report)
+	}
+}
+
+func TestValidateLiquibaseReportsContainerPlanning(t *testing.T) {
+	rt := &fakeRuntime{}
+	mgr := newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{runtime: rt})
+	changelog := filepath.Join(t.TempDir(), "changelog.xml")
+	if err := os.WriteFile(changelog, []byte("<databaseChangeLog/>"), 0o600); err != nil {
+		t.Fatalf("write changelog: %v", err)
+	}
+
+	report, err := mgr.Validate(context.Background(), Request{
+		PrepareKind:   "lb",
+		ImageID:       "image-1",
+		LiquibaseArgs: []string{"update", "--changelog-file", changelog},
+	})
+	if err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if !report.Valid || !report.PlanningRequiresContainer {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if report.Cache == nil || report.Cache.Decision != "unknown" || report.Cache.ReasonCode != "planned_in_container" {
+		t.Fatalf("unexpected cache estimate: %+v", report.Cache)
+	}
+	if len(rt.resolveCalls) != 0 || len(rt.startCalls) != 0 {
+		t.Fatalf("expected no runtime calls, got resolve=%v start=%v", rt.resolveCalls, rt.startCalls)
+	}
+
+	report, err = mgr.Validate(context.Background(), Request{
+		PrepareKind:   "lb",
+		ImageID:       "image-1",
+		LiquibaseArgs: []string{"update", "--changelog-file", filepath.Join(t.TempDir(), "missing.xml")},
+	})
+	if err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if report.Valid || report.Error == nil {
+		t.Fatalf("expected missing changelog to be reported, got %+v", report)
+	}
+}
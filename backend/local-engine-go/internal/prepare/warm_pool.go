@@ -0,0 +1,400 @@
+package prepare
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	engineRuntime "github.com/sqlrs/engine-local/internal/runtime"
+)
+
+const defaultWarmPoolIdleTimeout = 10 * time.Minute
+
+// warmPoolHealthTimeout bounds the readiness check a pooled container must
+// pass before a job takes it.
+var warmPoolHealthTimeout = 5 * time.Second
+
+// warmPoolKey identifies interchangeable containers: the same base state,
+// started with the same options.
+type warmPoolKey struct {
+	namespace string
+	imageID   string
+	superuser string
+	user      string
+	cpus      string
+	memory    string
+	network   string
+	dns       string
+	platform  string
+}
+
+// warmPoolSpec is what a refill needs to start containers for key.
+type warmPoolSpec struct {
+	key     warmPoolKey
+	baseDir string
+	dns     []string
+}
+
+type warmContainer struct {
+	name       string
+	instance   engineRuntime.Instance
+	dataDir    string
+	runtimeDir string
+	cleanup    func() error
+	idleSince  time.Time
+}
+
+// warmPool holds base containers started ahead of demand for
+// container.warmPool.size. The zero value is empty and ready to use.
+type warmPool struct {
+	mu      sync.Mutex
+	idle    map[warmPoolKey][]*warmContainer
+	filling map[warmPoolKey]int
+	// names holds every container the pool owns, idle or still starting,
+	// so ReapOrphans leaves them alone.
+	names map[string]struct{}
+	timer *time.Timer
+}
+
+// warmPoolSpecFor returns the pool a cold start of prepared on baseDir can
+// be served from. Jobs that bind-mount scripts or --mount sources need a
+// container of their own and never use the pool.
+func (m *PrepareService) warmPoolSpecFor(prepared preparedRequest, imageID string, baseDir string) (warmPoolSpec, bool) {
+	if m.warmPoolSize() <= 0 || len(prepared.filePaths) > 0 || len(prepared.psqlMounts) > 0 || strings.TrimSpace(prepared.request.Pgpass) != "" {
+		return warmPoolSpec{}, false
+	}
+	cpus, memory := m.containerLimits(prepared.request)
+	return warmPoolSpec{
+		key: warmPoolKey{
+			namespace: prepared.request.Namespace,
+			imageID:   imageID,
+			superuser: m.postgresSuperuser(),
+			user:      m.containerUser(),
+			cpus:      cpus,
+			memory:    memory,
+			network:   prepared.request.Network,
+			dns:       strings.Join(prepared.request.DNS, ","),
+			platform:  prepared.request.Platform,
+		},
+		baseDir: baseDir,
+		dns:     prepared.request.DNS,
+	}, true
+}
+
+// takeWarmRuntime hands a pooled container to jobID, or returns nil when the
+// pool has no healthy one. Containers failing the health check are stopped.
+func (m *PrepareService) takeWarmRuntime(ctx context.Context, jobID string, key warmPoolKey) *jobRuntime {
+	m.evictIdleWarm()
+	for {
+		container := m.warm.pop(key)
+		if container == nil {
+			return nil
+		}
+		if err := m.runtime.WaitForReady(ctx, container.instance.ID, warmPoolHealthTimeout); err != nil {
+			m.logWarnJob(jobID, "warm pool container unhealthy container=%s err=%v", container.instance.ID, err)
+			m.discardWarm(container)
+			if ctx.Err() != nil {
+				return nil
+			}
+			continue
+		}
+		m.appendLog(jobID, fmt.Sprintf("docker: using warm container %s", container.instance.ID))
+		m.logInfoJob(jobID, "warm pool hit container=%s image=%s", container.instance.ID, key.imageID)
+		return &jobRuntime{
+			instance:   container.instance,
+			dataDir:    container.dataDir,
+			runtimeDir: container.runtimeDir,
+			cleanup:    container.cleanup,
+		}
+	}
+}
+
+// refillWarmPool starts containers until the pool for spec holds
+// container.warmPool.size of them.
+func (m *PrepareService) refillWarmPool(spec warmPoolSpec) {
+	if m.isDraining() {
+		return
+	}
+	missing := m.warm.reserve(spec.key, m.warmPoolSize())
+	if missing == 0 {
+		return
+	}
+	fill := func() {
+		for i := 0; i < missing; i++ {
+			container, err := m.startWarmContainer(context.Background(), spec)
+			if err != nil {
+				m.logWarnJob("", "warm pool refill failed image=%s err=%v", spec.key.imageID, err)
+				m.warm.release(spec.key, missing-i)
+				return
+			}
+			m.warm.release(spec.key, 1)
+			if m.isDraining() {
+				m.warm.dropName(container.name)
+				m.discardWarm(container)
+				continue
+			}
+			if !m.warm.put(spec.key, container, m.warmPoolSize(), m.now()) {
+				m.discardWarm(container)
+				continue
+			}
+			m.scheduleWarmEviction()
+		}
+	}
+	if m.async {
+		go fill()
+	} else {
+		fill()
+	}
+}
+
+// startWarmContainer clones the base state and starts a container on it the
+// way a cold start from an image does.
+func (m *PrepareService) startWarmContainer(ctx context.Context, spec warmPoolSpec) (*warmContainer, error) {
+	suffix, err := randomHex(6)
+	if err != nil {
+		return nil, err
+	}
+	name := "sqlrs-warm-" + suffix
+	m.warm.claimName(name)
+	runtimeDir := filepath.Join(m.runtimeRoot(spec.key.namespace), "warm", name, "runtime")
+	if err := os.MkdirAll(filepath.Dir(runtimeDir), 0o700); err != nil {
+		m.warm.dropName(name)
+		return nil, err
+	}
+	clone, err := m.statefs.Clone(ctx, spec.baseDir, runtimeDir)
+	if err != nil {
+		m.warm.dropName(name)
+		_ = removeAllFn(filepath.Dir(runtimeDir))
+		return nil, err
+	}
+	cleanup := func() error {
+		err := clone.Cleanup()
+		_ = removeAllFn(filepath.Dir(runtimeDir))
+		return err
+	}
+	instance, err := m.runtime.Start(ctx, engineRuntime.StartRequest{
+		ImageID:     spec.key.imageID,
+		DataDir:     clone.MountDir,
+		Name:        name,
+		AllowInitdb: true,
+		Labels:      map[string]string{engineRuntime.LabelWarm: "true"},
+		CPUs:        spec.key.cpus,
+		Memory:      spec.key.memory,
+		Network:     spec.key.network,
+		DNS:         spec.dns,
+		Platform:    spec.key.platform,
+		User:        spec.key.user,
+	})
+	if err != nil {
+		m.warm.dropName(name)
+		_ = cleanup()
+		return nil, err
+	}
+	m.logInfoJob("", "warm pool container started container=%s image=%s", instance.ID, spec.key.imageID)
+	return &warmContainer{
+		name:       name,
+		instance:   instance,
+		dataDir:    clone.MountDir,
+		runtimeDir: runtimeDir,
+		cleanup:    cleanup,
+	}, nil
+}
+
+// evictIdleWarm stops containers idle for longer than
+// container.warmPool.idleTimeout and any beyond the configured size.
+func (m *PrepareService) evictIdleWarm() {
+	cutoff := m.now().Add(-m.warmPoolIdleTimeout())
+	for _, container := range m.warm.evict(cutoff, m.warmPoolSize()) {
+		m.logInfoJob("", "warm pool container evicted container=%s", container.instance.ID)
+		m.discardWarm(container)
+	}
+}
+
+// scheduleWarmEviction arms the idle eviction timer while the pool holds
+// containers.
+func (m *PrepareService) scheduleWarmEviction() {
+	m.warm.mu.Lock()
+	defer m.warm.mu.Unlock()
+	if m.warm.timer != nil || len(m.warm.idle) == 0 {
+		return
+	}
+	m.warm.timer = time.AfterFunc(m.warmPoolIdleTimeout(), func() {
+		m.warm.mu.Lock()
+		m.warm.timer = nil
+		m.warm.mu.Unlock()
+		m.evictIdleWarm()
+		m.scheduleWarmEviction()
+	})
+}
+
+// stopWarmPool stops every idle pooled container; Drain calls it so the pool
+// never outlives the engine.
+func (m *PrepareService) stopWarmPool() {
+	m.warm.mu.Lock()
+	if m.warm.timer != nil {
+		m.warm.timer.Stop()
+		m.warm.timer = nil
+	}
+	m.warm.mu.Unlock()
+	for _, container := range m.warm.evict(time.Time{}, 0) {
+		m.discardWarm(container)
+	}
+}
+
+func (m *PrepareService) discardWarm(container *warmContainer) {
+	stopCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	if err := m.runtime.Stop(stopCtx, container.instance.ID); err != nil {
+		m.logWarnJob("", "warm pool container stop failed container=%s err=%v", container.instance.ID, err)
+	}
+	if container.cleanup != nil {
+		_ = container.cleanup()
+	}
+}
+
+// warmPoolSize reads container.warmPool.size; zero disables the pool.
+func (m *PrepareService) warmPoolSize() int {
+	if m.config == nil {
+		return 0
+	}
+	value, err := m.config.Get("container.warmPool.size", true)
+	if err != nil || value == nil {
+		return 0
+	}
+	if num, ok := configValueToInt(value); ok && num > 0 {
+		return num
+	}
+	return 0
+}
+
+// warmPoolIdleTimeout reads container.warmPool.idleTimeout.
+func (m *PrepareService) warmPoolIdleTimeout() time.Duration {
+	if m.config == nil {
+		return defaultWarmPoolIdleTimeout
+	}
+	value, err := m.config.Get("container.warmPool.idleTimeout", true)
+	if err != nil || value == nil {
+		return defaultWarmPoolIdleTimeout
+	}
+	raw, ok := value.(string)
+	if !ok {
+		return defaultWarmPoolIdleTimeout
+	}
+	timeout, err := time.ParseDuration(strings.TrimSpace(raw))
+	if err != nil || timeout <= 0 {
+		return defaultWarmPoolIdleTimeout
+	}
+	return timeout
+}
+
+// pop takes the most recently pooled container for key.
+func (p *warmPool) pop(key warmPoolKey) *warmContainer {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	containers := p.idle[key]
+	if len(containers) == 0 {
+		return nil
+	}
+	container := containers[len(containers)-1]
+	if len(containers) == 1 {
+		delete(p.idle, key)
+	} else {
+		p.idle[key] = containers[:len(containers)-1]
+	}
+	delete(p.names, container.name)
+	return container
+}
+
+// reserve returns how many containers key is short of size and counts them
+// as starting.
+func (p *warmPool) reserve(key warmPoolKey, size int) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	missing := size - len(p.idle[key]) - p.filling[key]
+	if missing <= 0 {
+		return 0
+	}
+	if p.filling == nil {
+		p.filling = map[warmPoolKey]int{}
+	}
+	p.filling[key] += missing
+	return missing
+}
+
+func (p *warmPool) release(key warmPoolKey, count int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.filling[key] -= count
+	if p.filling[key] <= 0 {
+		delete(p.filling, key)
+	}
+}
+
+// put adds a started container to the pool unless key already holds size
+// of them.
+func (p *warmPool) put(key warmPoolKey, container *warmContainer, size int, now time.Time) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.idle[key]) >= size {
+		delete(p.names, container.name)
+		return false
+	}
+	if p.idle == nil {
+		p.idle = map[warmPoolKey][]*warmContainer{}
+	}
+	container.idleSince = now
+	p.idle[key] = append(p.idle[key], container)
+	return true
+}
+
+// evict removes containers idle since before cutoff and those beyond size
+// per key, oldest first.
+func (p *warmPool) evict(cutoff time.Time, size int) []*warmContainer {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var evicted []*warmContainer
+	for key, containers := range p.idle {
+		kept := containers[:0]
+		for i, container := range containers {
+			if container.idleSince.Before(cutoff) || len(containers)-i > size {
+				evicted = append(evicted, container)
+				delete(p.names, container.name)
+				continue
+			}
+			kept = append(kept, container)
+		}
+		if len(kept) == 0 {
+			delete(p.idle, key)
+		} else {
+			p.idle[key] = kept
+		}
+	}
+	return evicted
+}
+
+func (p *warmPool) claimName(name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.names == nil {
+		p.names = map[string]struct{}{}
+	}
+	p.names[name] = struct{}{}
+}
+
+func (p *warmPool) dropName(name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.names, name)
+}
+
+// owns reports whether the pool holds a container by name.
+func (p *warmPool) owns(name string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, ok := p.names[strings.TrimPrefix(name, "/")]
+	return ok
+}
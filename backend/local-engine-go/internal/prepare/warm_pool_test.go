@@ -0,0 +1,200 @@
+package prepare
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	engineRuntime "github.com/sqlrs/engine-local/internal/runtime"
+)
+
+func newWarmPoolManager(t *testing.T, runtime *fakeRuntime, size int) *PrepareService {
+	t.Helper()
+	return newManagerWithDeps(t, &fakeStore{}, newQueueStore(t), &testDeps{
+		runtime: runtime,
+		statefs: &fakeStateFS{},
+		config:  &fakeConfigStore{values: map[string]any{"container.warmPool.size": size}},
+	})
+}
+
+func startImageRuntime(t *testing.T, mgr *PrepareService, jobID string, req Request) *jobRuntime {
+	t.Helper()
+	prepared, err := mgr.prepareRequest(req)
+	if err != nil {
+		t.Fatalf("prepareRequest: %v", err)
+	}
+	rt, errResp := mgr.startRuntime(context.Background(), jobID, prepared, &TaskInput{Kind: "image", ID: req.ImageID})
+	if errResp != nil {
+		t.Fatalf("startRuntime: %+v", errResp)
+	}
+	return rt
+}
+
+func warmStarts(calls []engineRuntime.StartRequest) []engineRuntime.StartRequest {
+	var warm []engineRuntime.StartRequest
+	for _, call := range calls {
+		if call.Labels[engineRuntime.LabelWarm] != "" {
+			warm = append(warm, call)
+		}
+	}
+	return warm
+}
+
+func TestWarmPoolHitSkipsColdStart(t *testing.T) {
+	runtime := &fakeRuntime{}
+	mgr := newWarmPoolManager(t, runtime, 1)
+	req := Request{PrepareKind: "psql", ImageID: "image-1", PsqlArgs: []string{"-c", "select 1"}}
+
+	first := startImageRuntime(t, mgr, "job-1", req)
+	defer first.cleanup()
+	if len(runtime.initCalls) != 1 || len(runtime.startCalls) != 2 {
+		t.Fatalf("expected a cold start followed by one pool fill, got init=%d start=%+v", len(runtime.initCalls), runtime.startCalls)
+	}
+	fill := runtime.startCalls[1]
+	if !strings.HasPrefix(fill.Name, "sqlrs-warm-") || fill.Labels[engineRuntime.LabelWarm] != "true" || fill.Labels[engineRuntime.LabelJob] != "" {
+		t.Fatalf("unexpected warm start: %+v", fill)
+	}
+
+	second := startImageRuntime(t, mgr, "job-2", req)
+	if second.dataDir != fill.DataDir {
+		t.Fatalf("expected job-2 to run on the pooled clone %q, got %q", fill.DataDir, second.dataDir)
+	}
+	if len(runtime.initCalls) != 1 || len(runtime.waitCalls) != 1 {
+		t.Fatalf("expected a health check and no base init on a pool hit, got init=%d wait=%d", len(runtime.initCalls), len(runtime.waitCalls))
+	}
+	if starts := warmStarts(runtime.startCalls); len(runtime.startCalls) != 3 || len(starts) != 2 {
+		t.Fatalf("expected the hit to refill the pool without a job start, got %+v", runtime.startCalls)
+	}
+	if err := second.cleanup(); err != nil {
+		t.Fatalf("cleanup: %v", err)
+	}
+	if _, err := os.Stat(filepath.Dir(second.runtimeDir)); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected warm runtime dir to be removed, got %v", err)
+	}
+}
+
+func TestWarmPoolKeysByStartOptions(t *testing.T) {
+	runtime := &fakeRuntime{}
+	mgr := newWarmPoolManager(t, runtime, 1)
+	req := Request{PrepareKind: "psql", ImageID: "image-1", PsqlArgs: []string{"-c", "select 1"}}
+	startImageRuntime(t, mgr, "job-1", req).cleanup()
+
+	other := req
+	other.Platform = "linux/arm64"
+	startImageRuntime(t, mgr, "job-2", other).cleanup()
+	if len(runtime.waitCalls) != 0 {
+		t.Fatalf("expected a different platform to miss the pool, got %d health checks", len(runtime.waitCalls))
+	}
+	if starts := warmStarts(runtime.startCalls); len(starts) != 2 || starts[1].Platform != "linux/arm64" {
+		t.Fatalf("expected a pool per platform, got %+v", starts)
+	}
+}
+
+func TestWarmPoolSkipsMountedScripts(t *testing.T) {
+	runtime := &fakeRuntime{}
+	mgr := newWarmPoolManager(t, runtime, 1)
+	script := filepath.Join(t.TempDir(), "init.sql")
+	writeFile(t, script, "select 1;")
+	req := Request{PrepareKind: "psql", ImageID: "image-1", PsqlArgs: []string{"-f", script}}
+
+	startImageRuntime(t, mgr, "job-1", req).cleanup()
+	if len(runtime.startCalls) != 1 || len(warmStarts(runtime.startCalls)) != 0 {
+		t.Fatalf("expected scripts mounted into the container to bypass the pool, got %+v", runtime.startCalls)
+	}
+}
+
+func TestWarmPoolDiscardsUnhealthyContainer(t *testing.T) {
+	runtime := &fakeRuntime{}
+	mgr := newWarmPoolManager(t, runtime, 1)
+	req := Request{PrepareKind: "psql", ImageID: "image-1", PsqlArgs: []string{"-c", "select 1"}}
+	startImageRuntime(t, mgr, "job-1", req).cleanup()
+	stops := len(runtime.stopCalls)
+
+	runtime.waitErr = errors.New("not ready")
+	rt := startImageRuntime(t, mgr, "job-2", req)
+	defer rt.cleanup()
+	if len(runtime.waitCalls) != 1 || len(runtime.stopCalls) != stops+1 {
+		t.Fatalf("expected the unhealthy container to be stopped, got wait=%d stops=%v", len(runtime.waitCalls), runtime.stopCalls)
+	}
+	if !strings.Contains(rt.runtimeDir, filepath.Join("jobs", "job-2")) {
+		t.Fatalf("expected a cold start after the unhealthy container, got runtime dir %s", rt.runtimeDir)
+	}
+}
+
+func TestWarmPoolEvictsIdleContainers(t *testing.T) {
+	runtime := &fakeRuntime{}
+	mgr := newWarmPoolManager(t, runtime, 1)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	mgr.now = func() time.Time { return now }
+	req := Request{PrepareKind: "psql", ImageID: "image-1", PsqlArgs: []string{"-c", "select 1"}}
+	startImageRuntime(t, mgr, "job-1", req).cleanup()
+	stops := len(runtime.stopCalls)
+
+	now = now.Add(9 * time.Minute)
+	mgr.evictIdleWarm()
+	if len(runtime.stopCalls) != stops {
+		t.Fatalf("expected a container idle for less than idleTimeout to stay, got stops=%v", runtime.stopCalls)
+	}
+	now = now.Add(2 * time.Minute)
+	mgr.evictIdleWarm()
+	if len(runtime.stopCalls) != stops+1 || mgr.warm.pop(mustWarmKey(t, mgr, req)) != nil {
+		t.Fatalf("expected the idle container to be evicted, got stops=%v", runtime.stopCalls)
+	}
+}
+
+func TestDrainStopsWarmPool(t *testing.T) {
+	runtime := &fakeRuntime{}
+	mgr := newWarmPoolManager(t, runtime, 2)
+	req := Request{PrepareKind: "psql", ImageID: "image-1", PsqlArgs: []string{"-c", "select 1"}}
+	startImageRuntime(t, mgr, "job-1", req).cleanup()
+	stops := len(runtime.stopCalls)
+
+	if err := mgr.Drain(context.Background()); err != nil {
+		t.Fatalf("Drain: %v", err)
+	}
+	if len(runtime.stopCalls) != stops+2 {
+		t.Fatalf("expected both pooled containers to be stopped, got %v", runtime.stopCalls)
+	}
+	mgr.refillWarmPool(warmPoolSpec{key: mustWarmKey(t, mgr, req)})
+	if len(warmStarts(runtime.startCalls)) != 2 {
+		t.Fatalf("expected no refill while draining")
+	}
+}
+
+func TestReapOrphansKeepsOwnedWarmContainers(t *testing.T) {
+	runtime := &fakeRuntime{}
+	mgr := newWarmPoolManager(t, runtime, 1)
+	req := Request{PrepareKind: "psql", ImageID: "image-1", PsqlArgs: []string{"-c", "select 1"}}
+	startImageRuntime(t, mgr, "job-1", req).cleanup()
+	pooled := warmStarts(runtime.startCalls)[0].Name
+
+	warmLabels := map[string]string{engineRuntime.LabelWarm: "true"}
+	runtime.managed = []engineRuntime.ManagedContainer{
+		{ID: "pooled", Name: "/" + pooled, Labels: warmLabels},
+		{ID: "leftover", Name: "sqlrs-warm-000000000000", Labels: warmLabels},
+	}
+	result, err := mgr.ReapOrphans(context.Background(), OrphanReapOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("ReapOrphans: %v", err)
+	}
+	if len(result.Reaped) != 1 || result.Reaped[0].ContainerID != "leftover" {
+		t.Fatalf("expected only the leftover warm container to be reaped, got %+v", result.Reaped)
+	}
+}
+
+func mustWarmKey(t *testing.T, mgr *PrepareService, req Request) warmPoolKey {
+	t.Helper()
+	prepared, err := mgr.prepareRequest(req)
+	if err != nil {
+		t.Fatalf("prepareRequest: %v", err)
+	}
+	spec, ok := mgr.warmPoolSpecFor(prepared, prepared.effectiveImageID(), "")
+	if !ok {
+		t.Fatalf("expected request to be pool eligible")
+	}
+	return spec.key
+}
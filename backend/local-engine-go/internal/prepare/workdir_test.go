@@ -0,0 +1,84 @@
+package prepare
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sqlrs/engine-local/internal/store"
+)
+
+func TestCreateInstanceUsesWorkDirRoot(t *testing.T) {
+	stateRoot := filepath.Join(t.TempDir(), "state-store")
+	workRoot := filepath.Join(t.TempDir(), "work")
+	st := &fakeStore{
+		statesByID: map[string]store.StateEntry{
+			"state-1": {StateID: "state-1", ImageID: "image-1"},
+		},
+	}
+	mgr := newManagerWithDeps(t, st, newQueueStore(t), &testDeps{stateRoot: stateRoot, workRoot: workRoot})
+	prepared, err := mgr.prepareRequest(Request{
+		PrepareKind: "psql",
+		ImageID:     "image-1",
+		PsqlArgs:    []string{"-c", "select 1"},
+	})
+	if err != nil {
+		t.Fatalf("prepareRequest: %v", err)
+	}
+	paths, err := resolveStatePaths(stateRoot, "image-1", "state-1", mgr.statefs)
+	if err != nil {
+		t.Fatalf("resolveStatePaths: %v", err)
+	}
+	if err := os.MkdirAll(paths.stateDir, 0o700); err != nil {
+		t.Fatalf("mkdir state dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(paths.stateDir, "PG_VERSION"), []byte("17"), 0o600); err != nil {
+		t.Fatalf("write PG_VERSION: %v", err)
+	}
+	if _, errResp := mgr.createInstance(context.Background(), "job-1", prepared, "state-1"); errResp != nil {
+		t.Fatalf("createInstance: %+v", errResp)
+	}
+	expected := filepath.Join(workRoot, "jobs", "job-1", "runtime")
+	if len(st.instances) != 1 || st.instances[0].RuntimeDir == nil || *st.instances[0].RuntimeDir != expected {
+		t.Fatalf("expected runtime dir under workdir.root, got %+v", st.instances)
+	}
+	if _, err := os.Stat(filepath.Join(stateRoot, "jobs")); !os.IsNotExist(err) {
+		t.Fatalf("expected no job dirs in the state store, got %v", err)
+	}
+}
+
+func TestRuntimeRootKeepsBtrfsInStateStore(t *testing.T) {
+	mgr := &PrepareService{stateStoreRoot: "/state", workDirRoot: "/work", statefs: &fakeStateFS{kind: "btrfs"}}
+	if got := mgr.runtimeRoot("team"); got != filepath.Join("/state", "ns", "team") {
+		t.Fatalf("expected btrfs runtime root in the state store, got %s", got)
+	}
+	mgr.statefs = &fakeStateFS{kind: "overlay"}
+	if got := mgr.runtimeRoot("team"); got != filepath.Join("/work", "ns", "team") {
+		t.Fatalf("expected overlay runtime root under workdir.root, got %s", got)
+	}
+	mgr.workDirRoot = ""
+	if got := mgr.runtimeRoot(""); got != "/state" {
+		t.Fatalf("expected state store root without workdir.root, got %s", got)
+	}
+}
+
+func TestRemoveJobDirCoversWorkDirRoot(t *testing.T) {
+	mgr := &PrepareService{stateStoreRoot: t.TempDir(), workDirRoot: t.TempDir()}
+	var jobDirs []string
+	for _, root := range []string{mgr.stateStoreRoot, mgr.workDirRoot} {
+		jobDir := filepath.Join(root, "jobs", "job-1")
+		if err := os.MkdirAll(filepath.Join(jobDir, "runtime"), 0o700); err != nil {
+			t.Fatalf("mkdir job dir: %v", err)
+		}
+		jobDirs = append(jobDirs, jobDir)
+	}
+	if err := mgr.removeJobDir("", "job-1"); err != nil {
+		t.Fatalf("removeJobDir: %v", err)
+	}
+	for _, jobDir := range jobDirs {
+		if _, err := os.Stat(jobDir); !os.IsNotExist(err) {
+			t.Fatalf("expected %s removed", jobDir)
+		}
+	}
+}
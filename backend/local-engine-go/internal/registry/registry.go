@@ -2,6 +2,7 @@ package registry
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 
@@ -54,6 +55,32 @@ func (r *Registry) GetState(ctx context.Context, stateID string) (store.StateEnt
 	return r.store.GetState(ctx, strings.TrimSpace(stateID))
 }
 
+// StateAncestors walks ParentStateID from entry up to its root and returns the
+// ancestors nearest first. The walk stops at a missing parent or a cycle, so a
+// partially deleted lineage still yields what the store has.
+func (r *Registry) StateAncestors(ctx context.Context, entry store.StateEntry) ([]store.StateEntry, error) {
+	var ancestors []store.StateEntry
+	seen := map[string]bool{entry.StateID: true}
+	current := entry
+	for current.ParentStateID != nil {
+		parentID := strings.TrimSpace(*current.ParentStateID)
+		if parentID == "" || seen[parentID] {
+			break
+		}
+		seen[parentID] = true
+		parent, ok, err := r.store.GetState(ctx, parentID)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		ancestors = append(ancestors, parent)
+		current = parent
+	}
+	return ancestors, nil
+}
+
 func (r *Registry) UpdateInstanceRuntime(ctx context.Context, instanceID string, runtimeID *string) error {
 	type runtimeUpdater interface {
 		UpdateInstanceRuntime(ctx context.Context, instanceID string, runtimeID *string) error
@@ -65,6 +92,64 @@ func (r *Registry) UpdateInstanceRuntime(ctx context.Context, instanceID string,
 	return updater.UpdateInstanceRuntime(ctx, instanceID, runtimeID)
 }
 
+// ErrInvalidName reports a state tag name that validateStateName rejects.
+var ErrInvalidName = errors.New("invalid name")
+
+// TagState points name at a state so it can be used in place of the state ID,
+// e.g. as base_state_id. Names are unique: tagging again re-points a state tag,
+// while a name held by an instance yields store.ErrNameInUse. found is false
+// when the state does not exist.
+func (r *Registry) TagState(ctx context.Context, stateID string, name string) (store.NameEntry, bool, error) {
+	type stateTagger interface {
+		TagState(ctx context.Context, name string, stateID string) (store.NameEntry, bool, error)
+	}
+	tagger, ok := r.store.(stateTagger)
+	if !ok {
+		return store.NameEntry{}, false, fmt.Errorf("store does not support state tags")
+	}
+	name = strings.TrimSpace(name)
+	if err := validateStateName(name); err != nil {
+		return store.NameEntry{}, false, err
+	}
+	return tagger.TagState(ctx, name, strings.TrimSpace(stateID))
+}
+
+// validateStateName accepts 1-63 lowercase letters, digits, '-', '_' and '.',
+// starting with a letter. Names that parse as instance IDs are rejected so
+// that instance lookups by ID or name stay unambiguous.
+func validateStateName(name string) error {
+	if name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidName)
+	}
+	if len(name) > 63 {
+		return fmt.Errorf("%w: name is longer than 63 characters", ErrInvalidName)
+	}
+	for i, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z':
+		case (r >= '0' && r <= '9') || r == '-' || r == '_' || r == '.':
+			if i == 0 {
+				return fmt.Errorf("%w: name must start with a lowercase letter", ErrInvalidName)
+			}
+		default:
+			return fmt.Errorf("%w: name must contain only lowercase letters, digits, '-', '_' or '.'", ErrInvalidName)
+		}
+	}
+	if id.IsInstanceID(name) {
+		return fmt.Errorf("%w: name looks like an instance id", ErrInvalidName)
+	}
+	return nil
+}
+
+// Generation returns the store write counter, if the store keeps one.
+func (r *Registry) Generation() (uint64, bool) {
+	counter, ok := r.store.(interface{ Generation() uint64 })
+	if !ok {
+		return 0, false
+	}
+	return counter.Generation(), true
+}
+
 func (r *Registry) Close() error {
 	return r.store.Close()
 }
@@ -280,6 +280,43 @@ func TestRegistryListStatesAndGetState(t *testing.T) {
 	}
 }
 
+func TestRegistryStateAncestors(t *testing.T) {
+	ctx := context.Background()
+	parent := func(id string) *string { return &id }
+	states := map[string]store.StateEntry{
+		"root":  {StateID: "root"},
+		"mid":   {StateID: "mid", ParentStateID: parent("root")},
+		"leaf":  {StateID: "leaf", ParentStateID: parent("mid")},
+		"loopA": {StateID: "loopA", ParentStateID: parent("loopB")},
+		"loopB": {StateID: "loopB", ParentStateID: parent("loopA")},
+		"stray": {StateID: "stray", ParentStateID: parent("gone")},
+	}
+	fake := &fakeStore{}
+	fake.getState = func(ctx context.Context, stateID string) (store.StateEntry, bool, error) {
+		entry, ok := states[stateID]
+		return entry, ok, nil
+	}
+	reg := New(fake)
+
+	ancestors, err := reg.StateAncestors(ctx, states["leaf"])
+	if err != nil || len(ancestors) != 2 || ancestors[0].StateID != "mid" || ancestors[1].StateID != "root" {
+		t.Fatalf("unexpected ancestors: %+v err=%v", ancestors, err)
+	}
+	if ancestors, err := reg.StateAncestors(ctx, states["loopA"]); err != nil || len(ancestors) != 1 {
+		t.Fatalf("expected cycle to stop the walk, got %+v err=%v", ancestors, err)
+	}
+	if ancestors, err := reg.StateAncestors(ctx, states["stray"]); err != nil || len(ancestors) != 0 {
+		t.Fatalf("expected missing parent to stop the walk, got %+v err=%v", ancestors, err)
+	}
+
+	fake.getState = func(ctx context.Context, stateID string) (store.StateEntry, bool, error) {
+		return store.StateEntry{}, false, errors.New("boom")
+	}
+	if _, err := reg.StateAncestors(ctx, states["leaf"]); err == nil {
+		t.Fatalf("expected store error")
+	}
+}
+
 func TestRegistryCloseCallsStore(t *testing.T) {
 	fake := &fakeStore{}
 	closed := false
@@ -330,3 +367,23 @@ func TestRegistryUpdateInstanceRuntimeCallsStore(t *testing.T) {
 		t.Fatalf("expected runtime id to be forwarded")
 	}
 }
+
+func TestValidateStateName(t *testing.T) {
+	for _, name := range []string{"my-seed", "seed.v2", "a", "release_1"} {
+		if err := validateStateName(name); err != nil {
+			t.Fatalf("expected %q to be valid, got %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "1seed", "-seed", "My-Seed", "seed/1", strings.Repeat("a", 64), "abcdefabcdefabcdefabcdefabcdefab"} {
+		if err := validateStateName(name); !errors.Is(err, ErrInvalidName) {
+			t.Fatalf("expected %q to be rejected, got %v", name, err)
+		}
+	}
+}
+
+func TestTagStateRequiresTaggingStore(t *testing.T) {
+	reg := New(&fakeStore{})
+	if _, _, err := reg.TagState(context.Background(), "state-1", "my-seed"); err == nil || !strings.Contains(err.Error(), "does not support state tags") {
+		t.Fatalf("expected unsupported store error, got %v", err)
+	}
+}
@@ -0,0 +1,121 @@
+package run
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	engineRuntime "github.com/sqlrs/engine-local/internal/runtime"
+)
+
+const instanceModePersistent = "persistent"
+
+// ExecRequest carries ad-hoc psql input for an already running instance:
+// either a single SQL string or raw psql arguments.
+type ExecRequest struct {
+	SQL  *string  `json:"sql,omitempty"`
+	Args []string `json:"args,omitempty"`
+}
+
+type containerLiveness interface {
+	ContainerRunning(ctx context.Context, id string) (bool, error)
+}
+
+// Exec runs psql inside the recorded container of a persistent instance.
+// Unlike Run it never recreates a missing container: the instance must still
+// be up, otherwise the request is rejected as a conflict.
+func (m *Manager) Exec(ctx context.Context, instanceID string, req ExecRequest) (Result, error) {
+	return m.ExecStreaming(ctx, instanceID, req, nil)
+}
+
+// ExecStreaming is Exec with the output handed to emit while psql runs: a
+// "start" event once the instance is checked, then a "log" event per output
+// line, passed through the runtime log sink like the prepare psql runner
+// does. An error returned before "start" means nothing was run.
+func (m *Manager) ExecStreaming(ctx context.Context, instanceID string, req ExecRequest, emit func(Event)) (Result, error) {
+	if emit == nil {
+		emit = func(Event) {}
+	}
+	instanceID = strings.TrimSpace(instanceID)
+	if instanceID == "" {
+		return Result{}, ValidationError{Message: "instance id is required"}
+	}
+	hasSQL := req.SQL != nil && strings.TrimSpace(*req.SQL) != ""
+	if hasSQL == (len(req.Args) > 0) {
+		return Result{}, ValidationError{Message: "exactly one of sql or args is required"}
+	}
+	args := append([]string{}, req.Args...)
+	if hasSQL {
+		args = []string{"-X", "-v", "ON_ERROR_STOP=1", "-c", *req.SQL}
+	}
+	if hasPsqlConnectionArgs(args) {
+		return Result{}, ConflictError{Message: "conflicting psql connection arguments"}
+	}
+
+	entry, ok, _, err := m.registry.GetInstance(ctx, instanceID)
+	if err != nil {
+		return Result{}, err
+	}
+	if !ok {
+		return Result{}, NotFoundError{Message: "instance not found"}
+	}
+	if entry.Mode != instanceModePersistent {
+		mode := entry.Mode
+		if mode == "" {
+			mode = "ephemeral"
+		}
+		return Result{}, ConflictError{Message: "exec requires a persistent instance", Details: "instance mode is " + mode}
+	}
+	runtimeID := ""
+	if entry.RuntimeID != nil {
+		runtimeID = strings.TrimSpace(*entry.RuntimeID)
+	}
+	if runtimeID == "" {
+		return Result{}, ConflictError{Message: "instance runtime id is missing"}
+	}
+	if liveness, ok := m.runtime.(containerLiveness); ok {
+		running, err := liveness.ContainerRunning(ctx, runtimeID)
+		if err != nil {
+			return Result{}, fmt.Errorf("container liveness check failed: %w", err)
+		}
+		if !running {
+			return Result{}, ConflictError{Message: "instance container is not running"}
+		}
+	}
+
+	emit(Event{Type: "start", Ts: execEventTime(), InstanceID: entry.InstanceID})
+	var sinkCalled atomic.Bool
+	execCtx := engineRuntime.WithLogSink(ctx, func(line string) {
+		sinkCalled.Store(true)
+		emit(Event{Type: "log", Ts: execEventTime(), Data: line})
+	})
+	output, err := m.runtime.Exec(execCtx, runtimeID, engineRuntime.ExecRequest{
+		User: "postgres",
+		Args: buildExecArgs(kindPsql, defaultCommand(kindPsql), args, m.superuser),
+	})
+	if !sinkCalled.Load() {
+		// Runtimes without a log sink only return the output at the end.
+		for _, line := range strings.Split(output, "\n") {
+			if line = strings.TrimRight(line, "\r"); strings.TrimSpace(line) != "" {
+				emit(Event{Type: "log", Ts: execEventTime(), Data: line})
+			}
+		}
+	}
+	if err != nil {
+		if isContainerMissing(err) {
+			return Result{}, ConflictError{Message: "instance container is not running"}
+		}
+		return Result{}, fmt.Errorf("exec failed: %w", err)
+	}
+	return Result{
+		InstanceID: entry.InstanceID,
+		Stdout:     output,
+		ExitCode:   0,
+	}, nil
+}
+
+func execEventTime() string {
+	return time.Now().UTC().Format(time.RFC3339Nano)
+}
@@ -0,0 +1,218 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sqlrs/engine-local/internal/registry"
+	engineRuntime "github.com/sqlrs/engine-local/internal/runtime"
+	"github.com/sqlrs/engine-local/internal/store"
+)
+
+const execInstanceID = "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+
+type execStore struct {
+	store.Store
+	instances map[string]store.InstanceEntry
+}
+
+func (s execStore) GetInstance(ctx context.Context, instanceID string) (store.InstanceEntry, bool, error) {
+	entry, ok := s.instances[instanceID]
+	return entry, ok, nil
+}
+
+func (s execStore) GetName(ctx context.Context, name string) (store.NameEntry, bool, error) {
+	return store.NameEntry{}, false, nil
+}
+
+type livenessRuntime struct {
+	*fakeRuntime
+	running bool
+	checked []string
+}
+
+func (r *livenessRuntime) ContainerRunning(ctx context.Context, id string) (bool, error) {
+	r.checked = append(r.checked, id)
+	return r.running, nil
+}
+
+func newExecManager(t *testing.T, entry store.InstanceEntry, rt *livenessRuntime) *Manager {
+	t.Helper()
+	mgr, err := NewManager(Options{
+		Registry: registry.New(execStore{instances: map[string]store.InstanceEntry{entry.InstanceID: entry}}),
+		Runtime:  rt,
+	})
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	return mgr
+}
+
+func persistentInstance() store.InstanceEntry {
+	return store.InstanceEntry{
+		InstanceID: execInstanceID,
+		ImageID:    "image-1",
+		StateID:    "state-1",
+		RuntimeID:  strPtr("container-1"),
+		Mode:       instanceModePersistent,
+	}
+}
+
+func TestManagerExecRunsSQLInInstanceContainer(t *testing.T) {
+	rt := &livenessRuntime{fakeRuntime: &fakeRuntime{output: []string{" ?column? \n----------\n        1\n"}}, running: true}
+	mgr := newExecManager(t, persistentInstance(), rt)
+
+	res, err := mgr.Exec(context.Background(), execInstanceID, ExecRequest{SQL: strPtr("select 1")})
+	if err != nil {
+		t.Fatalf("Exec: %v", err)
+	}
+	if res.InstanceID != execInstanceID || !strings.Contains(res.Stdout, "1") {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if len(rt.checked) != 1 || rt.checked[0] != "container-1" {
+		t.Fatalf("expected liveness check on container-1, got %v", rt.checked)
+	}
+	if len(rt.calls) != 1 || rt.execIDs[0] != "container-1" || rt.calls[0].User != "postgres" {
+		t.Fatalf("unexpected exec calls: %+v ids=%v", rt.calls, rt.execIDs)
+	}
+	args := rt.calls[0].Args
+	if args[0] != "psql" || !contains(args, "select 1") || !strings.HasPrefix(args[len(args)-1], "postgres://") {
+		t.Fatalf("unexpected exec args: %v", args)
+	}
+}
+
+// sinkRuntime streams its output through the context log sink line by line,
+// like the docker runtime does.
+type sinkRuntime struct {
+	*livenessRuntime
+	lines []string
+	seen  []int
+}
+
+func (r *sinkRuntime) Exec(ctx context.Context, id string, req engineRuntime.ExecRequest) (string, error) {
+	sink := engineRuntime.LogSinkFromContext(ctx)
+	for _, line := range r.lines {
+		sink(line)
+		r.seen = append(r.seen, len(r.seen))
+	}
+	return strings.Join(r.lines, "\n"), nil
+}
+
+func TestManagerExecStreamingEmitsLinesWhileRunning(t *testing.T) {
+	rt := &sinkRuntime{livenessRuntime: &livenessRuntime{fakeRuntime: &fakeRuntime{}, running: true}, lines: []string{"line-1", "line-2"}}
+	mgr := newExecManager(t, persistentInstance(), rt.livenessRuntime)
+	mgr.runtime = rt
+
+	var events []Event
+	_, err := mgr.ExecStreaming(context.Background(), execInstanceID, ExecRequest{SQL: strPtr("select 1")}, func(evt Event) {
+		if evt.Type == "log" && len(rt.seen) >= len(rt.lines) {
+			t.Errorf("expected %q before the exec finished", evt.Data)
+		}
+		events = append(events, evt)
+	})
+	if err != nil {
+		t.Fatalf("ExecStreaming: %v", err)
+	}
+	if len(events) != 3 || events[0].Type != "start" || events[0].InstanceID != execInstanceID || events[1].Data != "line-1" || events[2].Data != "line-2" {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+}
+
+func TestManagerExecStreamingFallsBackToOutput(t *testing.T) {
+	rt := &livenessRuntime{fakeRuntime: &fakeRuntime{output: []string{"a\n\nb\n"}}, running: true}
+	mgr := newExecManager(t, persistentInstance(), rt)
+
+	var data []string
+	_, err := mgr.ExecStreaming(context.Background(), execInstanceID, ExecRequest{SQL: strPtr("select 1")}, func(evt Event) {
+		if evt.Type == "log" {
+			data = append(data, evt.Data)
+		}
+	})
+	if err != nil || len(data) != 2 || data[0] != "a" || data[1] != "b" {
+		t.Fatalf("expected output lines as log events, got %v err=%v", data, err)
+	}
+}
+
+func TestManagerExecPassesArgs(t *testing.T) {
+	rt := &livenessRuntime{fakeRuntime: &fakeRuntime{}, running: true}
+	mgr := newExecManager(t, persistentInstance(), rt)
+
+	if _, err := mgr.Exec(context.Background(), execInstanceID, ExecRequest{Args: []string{"-c", "\\dt"}}); err != nil {
+		t.Fatalf("Exec: %v", err)
+	}
+	if args := rt.calls[0].Args; len(args) != 4 || args[1] != "-c" || args[2] != "\\dt" {
+		t.Fatalf("unexpected exec args: %v", args)
+	}
+}
+
+func TestManagerExecRejections(t *testing.T) {
+	ephemeral := persistentInstance()
+	ephemeral.Mode = ""
+	cases := []struct {
+		name    string
+		entry   store.InstanceEntry
+		running bool
+		id      string
+		req     ExecRequest
+		check   func(error) bool
+	}{
+		{name: "no input", entry: persistentInstance(), running: true, id: execInstanceID, req: ExecRequest{}, check: isValidation},
+		{name: "sql and args", entry: persistentInstance(), running: true, id: execInstanceID, req: ExecRequest{SQL: strPtr("select 1"), Args: []string{"-l"}}, check: isValidation},
+		{name: "connection args", entry: persistentInstance(), running: true, id: execInstanceID, req: ExecRequest{Args: []string{"-h", "db"}}, check: isConflict},
+		{name: "missing instance", entry: persistentInstance(), running: true, id: "cccccccccccccccccccccccccccccccc", req: ExecRequest{SQL: strPtr("select 1")}, check: isNotFound},
+		{name: "ephemeral instance", entry: ephemeral, running: true, id: execInstanceID, req: ExecRequest{SQL: strPtr("select 1")}, check: isConflict},
+		{name: "stopped container", entry: persistentInstance(), running: false, id: execInstanceID, req: ExecRequest{SQL: strPtr("select 1")}, check: isConflict},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rt := &livenessRuntime{fakeRuntime: &fakeRuntime{}, running: tc.running}
+			mgr := newExecManager(t, tc.entry, rt)
+			_, err := mgr.Exec(context.Background(), tc.id, tc.req)
+			if !tc.check(err) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(rt.calls) != 0 {
+				t.Fatalf("expected no exec calls, got %+v", rt.calls)
+			}
+		})
+	}
+}
+
+func TestManagerExecDoesNotRecreateMissingContainer(t *testing.T) {
+	rt := &livenessRuntime{fakeRuntime: &fakeRuntime{err: errors.New("Error: No such container: container-1")}, running: true}
+	mgr := newExecManager(t, persistentInstance(), rt)
+
+	_, err := mgr.Exec(context.Background(), execInstanceID, ExecRequest{SQL: strPtr("select 1")})
+	if !isConflict(err) {
+		t.Fatalf("expected conflict, got %v", err)
+	}
+	if len(rt.startCalls) != 0 {
+		t.Fatalf("expected no container recreation, got %+v", rt.startCalls)
+	}
+}
+
+func isValidation(err error) bool {
+	var target ValidationError
+	return errors.As(err, &target)
+}
+
+func isConflict(err error) bool {
+	var target ConflictError
+	return errors.As(err, &target)
+}
+
+func isNotFound(err error) bool {
+	var target NotFoundError
+	return errors.As(err, &target)
+}
+
+func contains(values []string, value string) bool {
+	for _, item := range values {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
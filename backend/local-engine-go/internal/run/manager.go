@@ -21,11 +21,19 @@ const (
 type Options struct {
 	Registry *registry.Registry
 	Runtime  engineRuntime.Runtime
+	// Superuser is the role psql and pgbench connect as; empty means
+	// runtime.DefaultPostgresSuperuser.
+	Superuser string
+	// ContainerUser is container.runAsUser, passed as --user when an
+	// instance's container is restarted; empty keeps the image's postgres user.
+	ContainerUser string
 }
 
 type Manager struct {
-	registry *registry.Registry
-	runtime  engineRuntime.Runtime
+	registry      *registry.Registry
+	runtime       engineRuntime.Runtime
+	superuser     string
+	containerUser string
 }
 
 type Request struct {
@@ -58,8 +66,10 @@ func NewManager(opts Options) (*Manager, error) {
 		return nil, fmt.Errorf("runtime is required")
 	}
 	return &Manager{
-		registry: opts.Registry,
-		runtime:  opts.Runtime,
+		registry:      opts.Registry,
+		runtime:       opts.Runtime,
+		superuser:     engineRuntime.PostgresSuperuser(opts.Superuser),
+		containerUser: strings.TrimSpace(opts.ContainerUser),
 	}, nil
 }
 
@@ -120,7 +130,7 @@ func (m *Manager) Run(ctx context.Context, req Request) (Result, error) {
 			if hasPsqlConnectionArgs(stepArgs) {
 				return Result{}, ConflictError{Message: "conflicting psql connection arguments"}
 			}
-			execArgs := buildExecArgs(kind, command, stepArgs)
+			execArgs := buildExecArgs(kind, command, stepArgs, m.superuser)
 			stepOutput, err := m.execWithRecovery(ctx, entry, &runtimeID, execArgs, step.Stdin, &events)
 			if err != nil {
 				return Result{}, fmt.Errorf("exec failed: %w", err)
@@ -135,7 +145,7 @@ func (m *Manager) Run(ctx context.Context, req Request) (Result, error) {
 		}, nil
 	}
 
-	execArgs := buildExecArgs(kind, command, args)
+	execArgs := buildExecArgs(kind, command, args, m.superuser)
 	output, err := m.execWithRecovery(ctx, entry, &runtimeID, execArgs, req.Stdin, &events)
 	if err != nil {
 		return Result{}, fmt.Errorf("exec failed: %w", err)
@@ -197,6 +207,11 @@ func (m *Manager) recreateContainer(ctx context.Context, entry store.InstanceEnt
 		DataDir: dataDir,
 		Name:    "sqlrs-run-" + entry.InstanceID,
 		AllowInitdb: false,
+		User:        m.containerUser,
+		Labels: map[string]string{
+			engineRuntime.LabelInstance: entry.InstanceID,
+			engineRuntime.LabelState:    entry.StateID,
+		},
 	})
 	if err != nil {
 		return "", fmt.Errorf("runtime start failed: %w", err)
@@ -254,18 +269,18 @@ func defaultCommand(kind string) string {
 	return "pgbench"
 }
 
-func buildExecArgs(kind string, command string, args []string) []string {
+func buildExecArgs(kind string, command string, args []string, user string) []string {
 	execArgs := []string{command}
 	if kind == kindPsql {
 		execArgs = append(execArgs, args...)
-		execArgs = append(execArgs, defaultDSN())
+		execArgs = append(execArgs, defaultDSN(user))
 		return execArgs
 	}
-	execArgs = append(execArgs, "-h", "127.0.0.1", "-p", "5432", "-U", "sqlrs", "-d", "postgres")
+	execArgs = append(execArgs, "-h", "127.0.0.1", "-p", "5432", "-U", engineRuntime.PostgresSuperuser(user), "-d", "postgres")
 	execArgs = append(execArgs, args...)
 	return execArgs
 }
 
-func defaultDSN() string {
-	return "postgres://sqlrs@127.0.0.1:5432/postgres"
+func defaultDSN(user string) string {
+	return "postgres://" + engineRuntime.PostgresSuperuser(user) + "@127.0.0.1:5432/postgres"
 }
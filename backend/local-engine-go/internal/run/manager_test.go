@@ -28,9 +28,17 @@ type fakeRuntime struct {
 }
 
 func (f *fakeRuntime) InitBase(ctx context.Context, imageID string, dataDir string) error { return nil }
-func (f *fakeRuntime) ResolveImage(ctx context.Context, imageID string) (string, error) {
+func (f *fakeRuntime) ResolveImage(ctx context.Context, imageID string, platform string) (string, error) {
 	return imageID, nil
 }
+
+func (*fakeRuntime) Build(ctx context.Context, req engineRuntime.BuildRequest) (string, error) {
+	return "sha256:built", nil
+}
+
+func (*fakeRuntime) HasImageLocally(ctx context.Context, ref string) (string, bool, error) {
+	return ref, true, nil
+}
 func (f *fakeRuntime) Start(ctx context.Context, req engineRuntime.StartRequest) (engineRuntime.Instance, error) {
 	f.startCalls = append(f.startCalls, req)
 	if f.startErr != nil {
@@ -75,6 +83,14 @@ func (f *fakeRuntime) WaitForReady(ctx context.Context, id string, timeout time.
 	return nil
 }
 
+func (f *fakeRuntime) Ping(ctx context.Context) (string, error) {
+	return "test", nil
+}
+
+func (f *fakeRuntime) ListManaged(ctx context.Context) ([]engineRuntime.ManagedContainer, error) {
+	return nil, nil
+}
+
 func createInstance(t *testing.T, st store.Store, instanceID string) {
 	t.Helper()
 	now := timeNow()
@@ -374,6 +390,34 @@ func TestManagerRunPgbenchExecArgs(t *testing.T) {
 	}
 }
 
+func TestManagerRunUsesConfiguredSuperuser(t *testing.T) {
+	db := openStore(t)
+	defer db.Close()
+	createInstance(t, db, "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbd")
+
+	rt := &fakeRuntime{output: []string{"ok", "ok"}}
+	mgr, _ := NewManager(Options{Registry: registry.New(db), Runtime: rt, Superuser: "postgres"})
+	for _, kind := range []string{"psql", "pgbench"} {
+		if _, err := mgr.Run(context.Background(), Request{
+			InstanceRef: "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbd",
+			Kind:        kind,
+			Args:        []string{"-c", "10"},
+		}); err != nil {
+			t.Fatalf("Run %s: %v", kind, err)
+		}
+	}
+	if len(rt.calls) != 2 {
+		t.Fatalf("expected two exec calls, got %d", len(rt.calls))
+	}
+	psqlArgs := rt.calls[0].Args
+	if psqlArgs[len(psqlArgs)-1] != "postgres://postgres@127.0.0.1:5432/postgres" {
+		t.Fatalf("expected psql dsn for postgres, got %v", psqlArgs)
+	}
+	if !strings.Contains(strings.Join(rt.calls[1].Args, " "), "-U postgres") {
+		t.Fatalf("expected pgbench to connect as postgres, got %v", rt.calls[1].Args)
+	}
+}
+
 func TestManagerRunPsqlConflictArgs(t *testing.T) {
 	db := openStore(t)
 	defer db.Close()
@@ -4,6 +4,9 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -11,6 +14,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -24,6 +28,7 @@ var (
 	cmdWait       = func(cmd *exec.Cmd) error { return cmd.Wait() }
 	execLookPath  = exec.LookPath
 	osStat        = os.Stat
+	geteuid       = os.Geteuid
 	ensureMountFn = ensureStateStoreMount
 )
 
@@ -36,6 +41,22 @@ const (
 type Options struct {
 	Binary string
 	Runner commandRunner
+	// RegistryMirror is a registry host (optionally with a path prefix) that
+	// replaces the registry of every image reference the runtime pulls or runs.
+	RegistryMirror string
+	// RegistryAuthFile points at registry credentials: passed as --authfile to
+	// podman, or used as the docker config directory (its config.json) for docker.
+	RegistryAuthFile string
+	// Namespace is the containerd namespace passed to nerdctl as --namespace.
+	// Other runtimes have no namespaces and ignore it.
+	Namespace string
+	// Superuser is the role initdb bootstraps and readiness checks connect
+	// as; empty means DefaultPostgresSuperuser.
+	Superuser string
+	// EngineID is set as the sqlrs.engine label of every started container
+	// and restricts ListManaged to containers carrying it (see
+	// StoreIdentity). Empty disables the label and the filter.
+	EngineID string
 }
 
 type DockerUnavailableError struct {
@@ -123,8 +144,14 @@ func (execRunner) RunStreaming(ctx context.Context, name string, args []string,
 }
 
 type DockerRuntime struct {
-	binary string
-	runner commandRunner
+	binary    string
+	runner    commandRunner
+	registry  registryConfig
+	namespace string
+	superuser string
+	engineID  string
+	// users maps container IDs started with StartRequest.User to that user.
+	users sync.Map
 }
 
 func NewDocker(opts Options) *DockerRuntime {
@@ -145,7 +172,32 @@ func NewDocker(opts Options) *DockerRuntime {
 	if runner == nil {
 		runner = execRunner{}
 	}
-	return &DockerRuntime{binary: binary, runner: runner}
+	namespace := ""
+	if IsNerdctlBinary(binary) {
+		namespace = strings.TrimSpace(opts.Namespace)
+	}
+	return &DockerRuntime{
+		binary:    binary,
+		runner:    runner,
+		registry:  newRegistryConfig(opts.RegistryMirror, opts.RegistryAuthFile),
+		namespace: namespace,
+		superuser: PostgresSuperuser(opts.Superuser),
+		engineID:  strings.TrimSpace(opts.EngineID),
+	}
+}
+
+// StoreIdentity derives an engine identity from its state store root: a
+// short hash of the absolute path, stable across restarts of the engine.
+func StoreIdentity(stateStoreRoot string) string {
+	root := strings.TrimSpace(stateStoreRoot)
+	if root == "" {
+		return ""
+	}
+	if abs, err := filepath.Abs(root); err == nil {
+		root = abs
+	}
+	sum := sha256.Sum256([]byte(filepath.Clean(root)))
+	return hex.EncodeToString(sum[:6])
 }
 
 func (r *DockerRuntime) InitBase(ctx context.Context, imageID string, dataDir string) error {
@@ -155,7 +207,7 @@ func (r *DockerRuntime) InitBase(ctx context.Context, imageID string, dataDir st
 	if strings.TrimSpace(dataDir) == "" {
 		return fmt.Errorf("data dir is required")
 	}
-	if err := r.ensureDataDirOwner(ctx, imageID, dataDir); err != nil {
+	if err := r.ensureDataDirOwner(ctx, imageID, dataDir, ""); err != nil {
 		return err
 	}
 	if ok, err := r.pgVersionReady(ctx, imageID, dataDir); err != nil {
@@ -170,9 +222,9 @@ func (r *DockerRuntime) InitBase(ctx context.Context, imageID string, dataDir st
 		"run", "--rm",
 		"-u", "postgres",
 		"-v", dockerBindSpec(dataDir, PostgresDataDirRoot, false),
-		imageID,
+		r.registry.imageRef(imageID),
 		"initdb",
-		"--username=sqlrs",
+		"--username=" + r.superuser,
 		"--auth=trust",
 		"--auth-host=trust",
 		"--auth-local=trust",
@@ -213,7 +265,7 @@ func (r *DockerRuntime) hasPGVersionInContainer(ctx context.Context, imageID str
 	args := []string{
 		"run", "--rm",
 		"-v", dockerBindSpec(dataDir, PostgresDataDirRoot, false),
-		imageID,
+		r.registry.imageRef(imageID),
 		"test", "-f", filepath.ToSlash(filepath.Join(PostgresDataDirRoot, "pgdata", "PG_VERSION")),
 	}
 	_, err := r.run(ctx, args, nil)
@@ -335,28 +387,32 @@ func pgDataHostDir(dataDir string) string {
 	return filepath.Join(dataDir, filepath.FromSlash(rel))
 }
 
-func (r *DockerRuntime) ResolveImage(ctx context.Context, imageID string) (string, error) {
+func (r *DockerRuntime) ResolveImage(ctx context.Context, imageID string, platform string) (string, error) {
 	imageID = strings.TrimSpace(imageID)
 	if imageID == "" {
 		return "", fmt.Errorf("image id is required")
 	}
+	if platform = strings.TrimSpace(platform); platform != "" {
+		return r.resolvePlatformImage(ctx, imageID, platform)
+	}
 	if strings.Contains(imageID, "@") {
 		return imageID, nil
 	}
-	resolved, err := r.inspectImageDigest(ctx, imageID)
+	pullRef := r.registry.imageRef(imageID)
+	resolved, err := r.inspectImageDigest(ctx, pullRef)
 	if err == nil && strings.TrimSpace(resolved) != "" {
-		return strings.TrimSpace(resolved), nil
+		return r.registry.canonicalDigestRef(imageID, resolved), nil
 	}
 	if err != nil && isDockerUnavailable(err) {
 		return "", fmt.Errorf("docker is not running: %w", err)
 	}
-	if _, pullErr := r.run(ctx, []string{"pull", imageID}, nil); pullErr != nil {
+	if _, pullErr := r.run(ctx, []string{"pull", pullRef}, nil); pullErr != nil {
 		if isDockerUnavailable(pullErr) {
 			return "", fmt.Errorf("docker is not running: %w", pullErr)
 		}
 		return "", fmt.Errorf("docker pull failed: %w", pullErr)
 	}
-	resolved, err = r.inspectImageDigest(ctx, imageID)
+	resolved, err = r.inspectImageDigest(ctx, pullRef)
 	if err != nil {
 		if isDockerUnavailable(err) {
 			return "", fmt.Errorf("docker is not running: %w", err)
@@ -367,7 +423,151 @@ func (r *DockerRuntime) ResolveImage(ctx context.Context, imageID string) (strin
 	if resolved == "" {
 		return "", fmt.Errorf("image digest is empty")
 	}
-	return resolved, nil
+	return r.registry.canonicalDigestRef(imageID, resolved), nil
+}
+
+// resolvePlatformImage resolves imageID to the manifest for platform. The
+// repo digest docker reports for a multi-arch image is the digest of its
+// index, which is the same for every platform, so the platform manifest is
+// looked up in the index and pulled by its own digest instead.
+func (r *DockerRuntime) resolvePlatformImage(ctx context.Context, imageID string, platform string) (string, error) {
+	pullRef := r.registry.imageRef(imageID)
+	out, err := r.run(ctx, []string{"manifest", "inspect", pullRef}, nil)
+	if err != nil {
+		if isDockerUnavailable(err) {
+			return "", fmt.Errorf("docker is not running: %w", err)
+		}
+		return "", fmt.Errorf("docker manifest inspect failed: %w", err)
+	}
+	digest, isIndex, err := platformManifestDigest(out, platform)
+	if err != nil {
+		return "", fmt.Errorf("image %s: %w", imageID, err)
+	}
+	if !isIndex {
+		// A single-platform image has no per-platform digests; pull it for
+		// the platform (which fails on a mismatch) and resolve as usual.
+		if _, err := r.run(ctx, []string{"pull", "--platform", platform, pullRef}, nil); err != nil {
+			if isDockerUnavailable(err) {
+				return "", fmt.Errorf("docker is not running: %w", err)
+			}
+			return "", fmt.Errorf("docker pull failed: %w", err)
+		}
+		if strings.Contains(imageID, "@") {
+			return imageID, nil
+		}
+		resolved, err := r.inspectImageDigest(ctx, pullRef)
+		if err != nil {
+			return "", fmt.Errorf("docker inspect failed: %w", err)
+		}
+		if strings.TrimSpace(resolved) == "" {
+			return "", fmt.Errorf("image digest is empty")
+		}
+		return r.registry.canonicalDigestRef(imageID, resolved), nil
+	}
+	ref := imageRepository(pullRef) + "@" + digest
+	if _, err := r.run(ctx, []string{"pull", "--platform", platform, ref}, nil); err != nil {
+		if isDockerUnavailable(err) {
+			return "", fmt.Errorf("docker is not running: %w", err)
+		}
+		return "", fmt.Errorf("docker pull failed: %w", err)
+	}
+	return r.registry.canonicalDigestRef(imageID, ref), nil
+}
+
+// platformManifestDigest picks the manifest for platform from the output of
+// `manifest inspect`. isIndex is false when the output is a single image
+// manifest rather than an index.
+func platformManifestDigest(out string, platform string) (string, bool, error) {
+	var index struct {
+		Manifests []struct {
+			Digest   string `json:"digest"`
+			Platform struct {
+				OS           string `json:"os"`
+				Architecture string `json:"architecture"`
+				Variant      string `json:"variant"`
+			} `json:"platform"`
+		} `json:"manifests"`
+	}
+	if err := json.Unmarshal([]byte(out), &index); err != nil {
+		return "", false, fmt.Errorf("cannot parse image manifest: %w", err)
+	}
+	if len(index.Manifests) == 0 {
+		return "", false, nil
+	}
+	parts := strings.Split(platform, "/")
+	if len(parts) < 2 || len(parts) > 3 {
+		return "", true, fmt.Errorf("invalid platform %q", platform)
+	}
+	for _, manifest := range index.Manifests {
+		if manifest.Platform.OS != parts[0] || manifest.Platform.Architecture != parts[1] {
+			continue
+		}
+		if len(parts) == 3 && manifest.Platform.Variant != parts[2] {
+			continue
+		}
+		if strings.TrimSpace(manifest.Digest) != "" {
+			return manifest.Digest, true, nil
+		}
+	}
+	return "", true, fmt.Errorf("no manifest for platform %s", platform)
+}
+
+func (r *DockerRuntime) HasImageLocally(ctx context.Context, ref string) (string, bool, error) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return "", false, fmt.Errorf("image id is required")
+	}
+	out, err := r.run(ctx, []string{"image", "inspect", "--format", "{{.Id}} {{range .RepoDigests}}{{.}} {{end}}", r.registry.imageRef(ref)}, nil)
+	if err != nil {
+		if isDockerUnavailable(err) {
+			return "", false, fmt.Errorf("docker is not running: %w", err)
+		}
+		if IsImageNotFoundError(err) {
+			return "", false, nil
+		}
+		return "", false, fmt.Errorf("docker image inspect failed: %w", err)
+	}
+	fields := strings.Fields(out)
+	if len(fields) == 0 {
+		return "", false, fmt.Errorf("docker image inspect returned no image id")
+	}
+	if len(fields) > 1 {
+		return r.registry.canonicalDigestRef(ref, fields[1]), true, nil
+	}
+	return fields[0], true, nil
+}
+
+// Build runs docker build with --quiet, which prints only the image ID. The
+// layer cache makes an unchanged context build instantly to the same ID.
+func (r *DockerRuntime) Build(ctx context.Context, req BuildRequest) (string, error) {
+	contextDir := strings.TrimSpace(req.ContextDir)
+	if contextDir == "" {
+		return "", fmt.Errorf("build context is required")
+	}
+	args := []string{"build", "--quiet"}
+	if dockerfile := strings.TrimSpace(req.Dockerfile); dockerfile != "" {
+		args = append(args, "--file", filepath.Join(contextDir, dockerfile))
+	}
+	if tag := strings.TrimSpace(req.Tag); tag != "" {
+		args = append(args, "--tag", tag)
+	}
+	if platform := strings.TrimSpace(req.Platform); platform != "" {
+		args = append(args, "--platform", platform)
+	}
+	args = append(args, contextDir)
+	out, err := r.run(ctx, args, nil)
+	if err != nil {
+		if isDockerUnavailable(err) {
+			return "", fmt.Errorf("docker is not running: %w", err)
+		}
+		return "", fmt.Errorf("docker build failed: %w", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	imageID := strings.TrimSpace(lines[len(lines)-1])
+	if !strings.HasPrefix(imageID, "sha256:") {
+		return "", fmt.Errorf("docker build returned no image id: %s", strings.TrimSpace(out))
+	}
+	return imageID, nil
 }
 
 func (r *DockerRuntime) inspectImageDigest(ctx context.Context, imageID string) (string, error) {
@@ -378,36 +578,69 @@ func (r *DockerRuntime) inspectImageDigest(ctx context.Context, imageID string)
 	return strings.TrimSpace(out), nil
 }
 
-func (r *DockerRuntime) ensureDataDirOwner(ctx context.Context, imageID string, dataDir string) error {
+// ensureDataDirOwner creates the pgdata directory and hands it to owner, or
+// to the image's postgres user when owner is empty.
+func (r *DockerRuntime) ensureDataDirOwner(ctx context.Context, imageID string, dataDir string, owner string) error {
 	ensureHostDataDirAccess(dataDir)
-	args := []string{
-		"run", "--rm",
-		"-v", dockerBindSpec(dataDir, PostgresDataDirRoot, false),
-		imageID,
-		"mkdir", "-p", PostgresDataDir,
+	if owner == "" {
+		owner = "postgres:postgres"
+	}
+	steps := [][]string{
+		{"mkdir", "-p", PostgresDataDir},
+		{"chown", "-R", owner, PostgresDataDir},
+		{"chmod", "-R", "0700", PostgresDataDir},
+	}
+	for _, step := range steps {
+		args := []string{
+			"run", "--rm",
+			"-v", dockerBindSpec(dataDir, PostgresDataDirRoot, false),
+		}
+		args = append(args, r.userNamespaceArgs(owner)...)
+		args = append(args, r.registry.imageRef(imageID))
+		args = append(args, step...)
+		if err := r.runPermissionCommand(ctx, args); err != nil {
+			return err
+		}
 	}
-	if err := r.runPermissionCommand(ctx, args); err != nil {
-		return err
+	return nil
+}
+
+// ensureMountOwner hands an owned mount to owner, or to the image's postgres
+// user when owner is empty, the same way ensureDataDirOwner does for pgdata.
+func (r *DockerRuntime) ensureMountOwner(ctx context.Context, imageID string, mount Mount, owner string) error {
+	if owner == "" {
+		owner = "postgres:postgres"
 	}
-	args = []string{
+	args := []string{
 		"run", "--rm",
-		"-v", dockerBindSpec(dataDir, PostgresDataDirRoot, false),
-		imageID,
-		"chown", "-R", "postgres:postgres", PostgresDataDir,
+		"-v", dockerBindSpec(mount.HostPath, mount.ContainerPath, false),
 	}
-	if err := r.runPermissionCommand(ctx, args); err != nil {
-		return err
+	args = append(args, r.userNamespaceArgs(owner)...)
+	args = append(args, r.registry.imageRef(imageID), "chown", owner, mount.ContainerPath)
+	return r.runPermissionCommand(ctx, args)
+}
+
+// userNamespaceArgs keeps the engine user's uid on rootless podman. There the
+// container's uids are mapped to subordinate host uids, so a --user container
+// would write files the engine cannot read; --userns=keep-id maps the
+// engine's own uid into the container unchanged instead.
+func (r *DockerRuntime) userNamespaceArgs(user string) []string {
+	if user == "" || user == "postgres:postgres" || !isPodmanBinary(r.binary) || geteuid() <= 0 {
+		return nil
 	}
-	args = []string{
-		"run", "--rm",
-		"-v", dockerBindSpec(dataDir, PostgresDataDirRoot, false),
-		imageID,
-		"chmod", "-R", "0700", PostgresDataDir,
+	return []string{"--userns=keep-id"}
+}
+
+// execUser runs execs that ask for the image's postgres user as the user the
+// container was started with, so they keep the data directory's owner.
+func (r *DockerRuntime) execUser(id string, user string) string {
+	if user != "postgres" {
+		return user
 	}
-	if err := r.runPermissionCommand(ctx, args); err != nil {
-		return err
+	if started, ok := r.users.Load(id); ok {
+		return started.(string)
 	}
-	return nil
+	return user
 }
 
 func (r *DockerRuntime) runPermissionCommand(ctx context.Context, args []string) error {
@@ -431,9 +664,18 @@ func (r *DockerRuntime) Start(ctx context.Context, req StartRequest) (Instance,
 	if strings.TrimSpace(req.DataDir) == "" {
 		return Instance{}, fmt.Errorf("data dir is required")
 	}
-	if err := r.ensureDataDirOwner(ctx, req.ImageID, req.DataDir); err != nil {
+	user := strings.TrimSpace(req.User)
+	if err := r.ensureDataDirOwner(ctx, req.ImageID, req.DataDir, user); err != nil {
 		return Instance{}, err
 	}
+	for _, mount := range req.Mounts {
+		if !mount.Owned || strings.TrimSpace(mount.HostPath) == "" || strings.TrimSpace(mount.ContainerPath) == "" {
+			continue
+		}
+		if err := r.ensureMountOwner(ctx, req.ImageID, mount, user); err != nil {
+			return Instance{}, err
+		}
+	}
 	args := []string{
 		"run", "-d", "--rm",
 		"-p", "5432",
@@ -450,7 +692,22 @@ func (r *DockerRuntime) Start(ctx context.Context, req StartRequest) (Instance,
 	if strings.TrimSpace(req.Name) != "" {
 		args = append(args, "--name", req.Name)
 	}
-	args = append(args, req.ImageID, "sleep", "infinity")
+	if cpus := strings.TrimSpace(req.CPUs); cpus != "" {
+		args = append(args, "--cpus", cpus)
+	}
+	if memory := strings.TrimSpace(req.Memory); memory != "" {
+		args = append(args, "--memory", memory)
+	}
+	if platform := strings.TrimSpace(req.Platform); platform != "" {
+		args = append(args, "--platform", platform)
+	}
+	if user != "" {
+		args = append(args, "--user", user)
+	}
+	args = append(args, r.userNamespaceArgs(user)...)
+	args = append(args, dockerNetworkArgs(req.Network, req.DNS)...)
+	args = append(args, dockerLabelArgs(req.Labels, r.engineID)...)
+	args = append(args, r.registry.imageRef(req.ImageID), "sleep", "infinity")
 	out, err := r.run(ctx, args, nil)
 	if err != nil {
 		if isDockerUnavailable(err) {
@@ -462,6 +719,9 @@ func (r *DockerRuntime) Start(ctx context.Context, req StartRequest) (Instance,
 	if containerID == "" {
 		return Instance{}, fmt.Errorf("docker run returned empty container id")
 	}
+	if user != "" {
+		r.users.Store(containerID, user)
+	}
 
 	ok, err := r.pgVersionReadyInContainer(ctx, containerID)
 	if err != nil {
@@ -539,7 +799,7 @@ func (r *DockerRuntime) initdbInContainer(ctx context.Context, id string) error
 		User: "postgres",
 		Args: []string{
 			"initdb",
-			"--username=sqlrs",
+			"--username=" + r.superuser,
 			"--auth=trust",
 			"--auth-host=trust",
 			"--auth-local=trust",
@@ -574,19 +834,135 @@ func (r *DockerRuntime) Stop(ctx context.Context, id string) error {
 	// avoids waiting on PostgreSQL shutdown during cleanup paths.
 	output, err := r.run(ctx, []string{"rm", "-f", id}, nil)
 	if err != nil && isDockerNotFoundOutput(output, err) {
-		return nil
+		err = nil
+	}
+	if err == nil {
+		r.users.Delete(id)
 	}
 	return err
 }
 
+func (r *DockerRuntime) Ping(ctx context.Context) (string, error) {
+	format := "{{.Server.Version}}"
+	if IsNerdctlBinary(r.binary) {
+		// nerdctl reports the server as a list of components.
+		format = `{{range .Server.Components}}{{if eq .Name "containerd"}}{{.Version}}{{end}}{{end}}`
+	}
+	output, err := r.run(ctx, []string{"version", "--format", format}, nil)
+	if err != nil {
+		return "", err
+	}
+	version := strings.TrimSpace(output)
+	if version == "" {
+		return "", fmt.Errorf("container runtime returned empty server version")
+	}
+	return version, nil
+}
+
+// ListManaged lists every container labeled sqlrs.managed=true, including
+// stopped ones. With an engine ID only containers labeled with it are listed.
+func (r *DockerRuntime) ListManaged(ctx context.Context) ([]ManagedContainer, error) {
+	args := []string{"ps", "-a", "--filter", "label=" + LabelManaged + "=true"}
+	if r.engineID != "" {
+		args = append(args, "--filter", "label="+LabelEngine+"="+r.engineID)
+	}
+	args = append(args, "--format", "{{.ID}}\t{{.Names}}\t{{.Labels}}")
+	output, err := r.run(ctx, args, nil)
+	if err != nil {
+		return nil, err
+	}
+	var containers []ManagedContainer
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		parts := strings.SplitN(line, "\t", 3)
+		container := ManagedContainer{ID: parts[0]}
+		if len(parts) > 1 {
+			container.Name = strings.TrimSpace(parts[1])
+		}
+		if len(parts) > 2 {
+			container.Labels = parseContainerLabels(parts[2])
+		}
+		containers = append(containers, container)
+	}
+	return containers, nil
+}
+
+// dockerLabelArgs returns --label flags for the managed label, the engine
+// identity and the request labels, sorted by key so the command line is
+// deterministic.
+func dockerLabelArgs(labels map[string]string, engineID string) []string {
+	args := []string{"--label", LabelManaged + "=true"}
+	if engineID != "" {
+		args = append(args, "--label", LabelEngine+"="+engineID)
+	}
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		if strings.TrimSpace(key) == "" || key == LabelManaged || key == LabelEngine {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		args = append(args, "--label", key+"="+labels[key])
+	}
+	return args
+}
+
+// parseContainerLabels parses the {{.Labels}} column of ps output: docker
+// prints "k=v,k2=v2", podman prints a Go map such as "map[k:v k2:v2]".
+func parseContainerLabels(value string) map[string]string {
+	value = strings.TrimSpace(value)
+	labels := map[string]string{}
+	if strings.HasPrefix(value, "map[") && strings.HasSuffix(value, "]") {
+		for _, field := range strings.Fields(value[len("map[") : len(value)-1]) {
+			if key, val, ok := strings.Cut(field, ":"); ok {
+				labels[key] = val
+			}
+		}
+		return labels
+	}
+	for _, field := range strings.Split(value, ",") {
+		if key, val, ok := strings.Cut(field, "="); ok {
+			labels[strings.TrimSpace(key)] = val
+		}
+	}
+	return labels
+}
+
+// Binary returns the container runtime executable used for commands.
+func (r *DockerRuntime) Binary() string {
+	return r.binary
+}
+
+// ContainerRunning reports whether the container exists and is running.
+// A missing container is not an error.
+func (r *DockerRuntime) ContainerRunning(ctx context.Context, id string) (bool, error) {
+	id = strings.TrimSpace(id)
+	if id == "" {
+		return false, nil
+	}
+	output, err := r.run(ctx, []string{"inspect", "--format", "{{.State.Running}}", id}, nil)
+	if err != nil {
+		if isDockerNotFoundOutput(output, err) || strings.Contains(strings.ToLower(output), "no such object") {
+			return false, nil
+		}
+		return false, err
+	}
+	return strings.TrimSpace(output) == "true", nil
+}
+
 func (r *DockerRuntime) Exec(ctx context.Context, id string, req ExecRequest) (string, error) {
 	id = strings.TrimSpace(id)
 	if id == "" {
 		return "", fmt.Errorf("container id is required")
 	}
 	args := []string{"exec"}
-	if strings.TrimSpace(req.User) != "" {
-		args = append(args, "-u", req.User)
+	if user := r.execUser(id, strings.TrimSpace(req.User)); user != "" {
+		args = append(args, "-u", user)
 	}
 	if strings.TrimSpace(req.Dir) != "" {
 		args = append(args, "-w", req.Dir)
@@ -616,7 +992,7 @@ func (r *DockerRuntime) WaitForReady(ctx context.Context, id string, timeout tim
 		}
 		out, err := r.Exec(ctx, id, ExecRequest{
 			User: "postgres",
-			Args: []string{"pg_isready", "-U", "sqlrs", "-d", "postgres", "-h", "127.0.0.1", "-p", "5432"},
+			Args: []string{"pg_isready", "-U", r.superuser, "-d", "postgres", "-h", "127.0.0.1", "-p", "5432"},
 		})
 		if err == nil && strings.Contains(out, "accepting connections") {
 			return nil
@@ -637,6 +1013,10 @@ func (r *DockerRuntime) run(ctx context.Context, args []string, stdin *string) (
 			return "", err
 		}
 	}
+	args = r.registry.commandArgs(r.binary, args)
+	if r.namespace != "" {
+		args = append([]string{"--namespace", r.namespace}, args...)
+	}
 	sink := logSinkFromContext(ctx)
 	if sink != nil {
 		if runner, ok := r.runner.(streamingRunner); ok {
@@ -678,9 +1058,7 @@ func (r *DockerRuntime) RunContainer(ctx context.Context, req RunRequest) (strin
 	if strings.TrimSpace(req.Dir) != "" {
 		args = append(args, "-w", strings.TrimSpace(req.Dir))
 	}
-	if strings.TrimSpace(req.Network) != "" {
-		args = append(args, "--network", strings.TrimSpace(req.Network))
-	}
+	args = append(args, dockerNetworkArgs(req.Network, req.DNS)...)
 	for key, value := range req.Env {
 		key = strings.TrimSpace(key)
 		if key == "" {
@@ -694,7 +1072,7 @@ func (r *DockerRuntime) RunContainer(ctx context.Context, req RunRequest) (strin
 		}
 		args = append(args, "-v", dockerBindSpec(mount.HostPath, mount.ContainerPath, mount.ReadOnly))
 	}
-	args = append(args, imageID)
+	args = append(args, r.registry.imageRef(imageID))
 	if len(req.Args) > 0 {
 		args = append(args, req.Args...)
 	}
@@ -708,6 +1086,21 @@ func (r *DockerRuntime) RunContainer(ctx context.Context, req RunRequest) (strin
 	return output, nil
 }
 
+// dockerNetworkArgs builds --network and --dns flags; blank values are
+// skipped.
+func dockerNetworkArgs(network string, dns []string) []string {
+	var args []string
+	if network = strings.TrimSpace(network); network != "" {
+		args = append(args, "--network", network)
+	}
+	for _, server := range dns {
+		if server = strings.TrimSpace(server); server != "" {
+			args = append(args, "--dns", server)
+		}
+	}
+	return args
+}
+
 func parseHostPort(value string) (int, error) {
 	value = strings.TrimSpace(value)
 	if value == "" {
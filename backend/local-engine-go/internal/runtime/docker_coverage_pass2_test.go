@@ -16,7 +16,7 @@ func TestDockerRuntimeEnsureDataDirOwnerReturnsLaterCommandErrors(t *testing.T)
 			},
 		}
 		rt := NewDocker(Options{Binary: "docker", Runner: runner})
-		if err := rt.ensureDataDirOwner(context.Background(), "image", t.TempDir()); err == nil || !strings.Contains(err.Error(), "data directory setup failed") {
+		if err := rt.ensureDataDirOwner(context.Background(), "image", t.TempDir(), ""); err == nil || !strings.Contains(err.Error(), "data directory setup failed") {
 			t.Fatalf("expected chown failure, got %v", err)
 		}
 	})
@@ -30,7 +30,7 @@ func TestDockerRuntimeEnsureDataDirOwnerReturnsLaterCommandErrors(t *testing.T)
 			},
 		}
 		rt := NewDocker(Options{Binary: "docker", Runner: runner})
-		if err := rt.ensureDataDirOwner(context.Background(), "image", t.TempDir()); err == nil || !strings.Contains(err.Error(), "data directory setup failed") {
+		if err := rt.ensureDataDirOwner(context.Background(), "image", t.TempDir(), ""); err == nil || !strings.Contains(err.Error(), "data directory setup failed") {
 			t.Fatalf("expected chmod failure, got %v", err)
 		}
 	})
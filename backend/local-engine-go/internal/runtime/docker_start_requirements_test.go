@@ -52,6 +52,59 @@ func TestDockerRuntimeStartSkipsInvalidMountsAndMarksReadOnly(t *testing.T) {
 	}
 }
 
+func TestDockerRuntimeStartHandsOwnedMountsToContainerUser(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		user  string
+		owner string
+	}{
+		{name: "image user", owner: "postgres:postgres"},
+		{name: "run-as user", user: "1000:1000", owner: "1000:1000"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			runner := &fakeRunner{
+				responses: []runResponse{
+					{output: ""},              // mkdir
+					{output: ""},              // chown
+					{output: ""},              // chmod
+					{output: ""},              // chown pgpass
+					{output: "container-1\n"}, // docker run
+					{output: ""},              // test -f PG_VERSION
+					{output: ""},              // ensureContainerHostAuth
+					{output: ""},              // pg_ctl start
+					{output: "accepting connections\n"},
+					{output: "0.0.0.0:5432\n"},
+				},
+			}
+			rt := NewDocker(Options{Binary: "docker", Runner: runner})
+			_, err := rt.Start(context.Background(), StartRequest{
+				ImageID: "postgres:17",
+				DataDir: t.TempDir(),
+				User:    tc.user,
+				Mounts: []Mount{
+					{HostPath: "/host/scripts", ContainerPath: "/scripts", ReadOnly: true},
+					{HostPath: "/host/pgpass", ContainerPath: "/run/sqlrs/pgpass", ReadOnly: true, Owned: true},
+				},
+			})
+			if err != nil {
+				t.Fatalf("Start: %v", err)
+			}
+			chown := runner.calls[3].args
+			if !containsArg(chown, "chown", tc.owner) || !containsFlag(chown, "/run/sqlrs/pgpass") || !containsFlag(chown, "/host/pgpass:/run/sqlrs/pgpass") {
+				t.Fatalf("expected pgpass chown to %s, got %+v", tc.owner, chown)
+			}
+			if !containsFlag(runner.calls[4].args, "/host/pgpass:/run/sqlrs/pgpass:ro") {
+				t.Fatalf("expected read-only pgpass mount, got %+v", runner.calls[4].args)
+			}
+			for _, call := range runner.calls {
+				if containsFlag(call.args, "/host/scripts") && containsFlag(call.args, "chown") {
+					t.Fatalf("expected only owned mounts to be chowned, got %+v", call.args)
+				}
+			}
+		})
+	}
+}
+
 func TestDockerRuntimeStartPgVersionCheckErrorStopsContainer(t *testing.T) {
 	runner := &fakeRunner{
 		responses: []runResponse{
@@ -85,6 +85,7 @@ func TestDockerRuntimeStart(t *testing.T) {
 		DataDir:     "/data",
 		Name:        "sqlrs-test",
 		AllowInitdb: false,
+		Labels:      map[string]string{LabelJob: "job-1", LabelState: "state-1"},
 	})
 	if err != nil {
 		t.Fatalf("Start: %v", err)
@@ -101,6 +102,11 @@ func TestDockerRuntimeStart(t *testing.T) {
 	if !containsArg(runner.calls[3].args, "--name", "sqlrs-test") {
 		t.Fatalf("expected container name in args: %v", runner.calls[3].args)
 	}
+	for _, label := range []string{"sqlrs.managed=true", "sqlrs.job=job-1", "sqlrs.state=state-1"} {
+		if !containsArg(runner.calls[3].args, "--label", label) {
+			t.Fatalf("expected label %s in args: %v", label, runner.calls[3].args)
+		}
+	}
 	if !containsArg(runner.calls[3].args, "-p", "5432") {
 		t.Fatalf("expected auto host port publish syntax, got %v", runner.calls[3].args)
 	}
@@ -139,6 +145,294 @@ func TestDockerRuntimeStart(t *testing.T) {
 	}
 }
 
+func TestDockerRuntimeStartResourceLimits(t *testing.T) {
+	runner := &fakeRunner{
+		responses: []runResponse{
+			{output: ""},
+			{output: ""},
+			{output: ""},
+			{output: "container-1\n"},
+			{output: ""},
+			{output: ""},
+			{output: ""},
+			{output: "accepting connections\n"},
+			{output: "0.0.0.0:54321\n"},
+		},
+	}
+	rt := NewDocker(Options{Binary: "docker", Runner: runner})
+	if _, err := rt.Start(context.Background(), StartRequest{
+		ImageID: "postgres:17",
+		DataDir: "/data",
+		CPUs:    "1.5",
+		Memory:  "2g",
+	}); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if !containsArg(runner.calls[3].args, "--cpus", "1.5") || !containsArg(runner.calls[3].args, "--memory", "2g") {
+		t.Fatalf("expected resource limits in args: %v", runner.calls[3].args)
+	}
+}
+
+func TestDockerRuntimeStartRunAsUser(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		binary     string
+		euid       int
+		wantUserns bool
+	}{
+		{name: "docker", binary: "docker", euid: 1000},
+		{name: "rootful podman", binary: "podman", euid: 0},
+		{name: "rootless podman", binary: "podman", euid: 1000, wantUserns: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			prevEuid := geteuid
+			geteuid = func() int { return tc.euid }
+			t.Cleanup(func() { geteuid = prevEuid })
+			runner := &fakeRunner{
+				responses: []runResponse{
+					{output: ""},
+					{output: ""},
+					{output: ""},
+					{output: "container-1\n"},
+					{output: ""},
+					{output: ""},
+					{output: ""},
+					{output: "accepting connections\n"},
+					{output: "0.0.0.0:54321\n"},
+					{output: ""},
+					{output: ""},
+				},
+			}
+			rt := NewDocker(Options{Binary: tc.binary, Runner: runner})
+			if _, err := rt.Start(context.Background(), StartRequest{
+				ImageID: "postgres:17",
+				DataDir: "/data",
+				User:    "1000:1000",
+			}); err != nil {
+				t.Fatalf("Start: %v", err)
+			}
+			if !containsArg(runner.calls[1].args, "chown", "-R") || !containsFlag(runner.calls[1].args, "1000:1000") {
+				t.Fatalf("expected data dir chown to the run-as user: %v", runner.calls[1].args)
+			}
+			if !containsArg(runner.calls[3].args, "--user", "1000:1000") {
+				t.Fatalf("expected --user in args: %v", runner.calls[3].args)
+			}
+			for _, call := range runner.calls[:4] {
+				if containsFlag(call.args, "--userns=keep-id") != tc.wantUserns {
+					t.Fatalf("unexpected user namespace args (want keep-id=%v): %v", tc.wantUserns, call.args)
+				}
+			}
+			if !containsArg(runner.calls[6].args, "-u", "1000:1000") {
+				t.Fatalf("expected pg_ctl to run as the run-as user: %v", runner.calls[6].args)
+			}
+
+			if err := rt.Stop(context.Background(), "container-1"); err != nil {
+				t.Fatalf("Stop: %v", err)
+			}
+			if _, err := rt.Exec(context.Background(), "container-1", ExecRequest{User: "postgres", Args: []string{"true"}}); err != nil {
+				t.Fatalf("Exec: %v", err)
+			}
+			if last := runner.calls[len(runner.calls)-1].args; !containsArg(last, "-u", "postgres") {
+				t.Fatalf("expected a stopped container to forget its user: %v", last)
+			}
+		})
+	}
+}
+
+func TestDockerRuntimeStartNetworkAndDNS(t *testing.T) {
+	runner := &fakeRunner{
+		responses: []runResponse{
+			{output: ""},
+			{output: ""},
+			{output: ""},
+			{output: "container-1\n"},
+			{output: ""},
+			{output: ""},
+			{output: ""},
+			{output: "accepting connections\n"},
+			{output: "0.0.0.0:54321\n"},
+		},
+	}
+	rt := NewDocker(Options{Binary: "docker", Runner: runner})
+	if _, err := rt.Start(context.Background(), StartRequest{
+		ImageID: "postgres:17",
+		DataDir: "/data",
+		Network: "corp-net",
+		DNS:     []string{"10.0.0.53", " ", "10.0.0.54"},
+	}); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	args := runner.calls[3].args
+	if !containsArg(args, "--network", "corp-net") || !containsArg(args, "--dns", "10.0.0.53") || !containsArg(args, "--dns", "10.0.0.54") {
+		t.Fatalf("expected network and dns flags in args: %v", args)
+	}
+	dnsFlags := 0
+	for _, arg := range args {
+		if arg == "--dns" {
+			dnsFlags++
+		}
+	}
+	if dnsFlags != 2 {
+		t.Fatalf("expected blank dns entries to be skipped: %v", args)
+	}
+}
+
+func TestDockerRuntimeStartPlatform(t *testing.T) {
+	runner := &fakeRunner{
+		responses: []runResponse{
+			{output: ""},
+			{output: ""},
+			{output: ""},
+			{output: "container-1\n"},
+			{output: ""},
+			{output: ""},
+			{output: ""},
+			{output: "accepting connections\n"},
+			{output: "0.0.0.0:54321\n"},
+		},
+	}
+	rt := NewDocker(Options{Binary: "docker", Runner: runner})
+	if _, err := rt.Start(context.Background(), StartRequest{
+		ImageID:  "postgres:17",
+		DataDir:  "/data",
+		Platform: "linux/arm64",
+	}); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if !containsArg(runner.calls[3].args, "--platform", "linux/arm64") {
+		t.Fatalf("expected platform flag in args: %v", runner.calls[3].args)
+	}
+}
+
+const multiArchManifest = `{
+  "schemaVersion": 2,
+  "mediaType": "application/vnd.oci.image.index.v1+json",
+  "manifests": [
+    {"digest": "sha256:amd", "platform": {"architecture": "amd64", "os": "linux"}},
+    {"digest": "sha256:armv7", "platform": {"architecture": "arm", "os": "linux", "variant": "v7"}},
+    {"digest": "sha256:arm", "platform": {"architecture": "arm64", "os": "linux", "variant": "v8"}}
+  ]
+}`
+
+func TestDockerResolveImagePlatform(t *testing.T) {
+	resolved := map[string]string{}
+	for _, platform := range []string{"linux/amd64", "linux/arm64"} {
+		runner := &fakeRunner{responses: []runResponse{{output: multiArchManifest}, {output: ""}}}
+		rt := NewDocker(Options{Binary: "docker", Runner: runner})
+		imageID, err := rt.ResolveImage(context.Background(), "postgres:17", platform)
+		if err != nil {
+			t.Fatalf("ResolveImage %s: %v", platform, err)
+		}
+		if len(runner.calls) != 2 || strings.Join(runner.calls[0].args, " ") != "manifest inspect postgres:17" {
+			t.Fatalf("unexpected calls: %+v", runner.calls)
+		}
+		if !containsArg(runner.calls[1].args, "--platform", platform) {
+			t.Fatalf("expected pull with platform flag, got %v", runner.calls[1].args)
+		}
+		resolved[platform] = imageID
+	}
+	if !strings.HasSuffix(resolved["linux/amd64"], "@sha256:amd") || !strings.HasSuffix(resolved["linux/arm64"], "@sha256:arm") {
+		t.Fatalf("expected platform manifest digests, got %v", resolved)
+	}
+}
+
+func TestDockerResolveImagePlatformSingleManifest(t *testing.T) {
+	runner := &fakeRunner{responses: []runResponse{
+		{output: `{"schemaVersion": 2, "config": {"digest": "sha256:cfg"}, "layers": []}`},
+		{output: ""},
+		{output: "postgres@sha256:single\n"},
+	}}
+	rt := NewDocker(Options{Binary: "docker", Runner: runner})
+	imageID, err := rt.ResolveImage(context.Background(), "postgres:17", "linux/amd64")
+	if err != nil {
+		t.Fatalf("ResolveImage: %v", err)
+	}
+	if !strings.HasSuffix(imageID, "@sha256:single") {
+		t.Fatalf("unexpected resolved image %q", imageID)
+	}
+	if !containsArg(runner.calls[1].args, "--platform", "linux/amd64") {
+		t.Fatalf("expected pull with platform flag, got %v", runner.calls[1].args)
+	}
+}
+
+func TestDockerHasImageLocally(t *testing.T) {
+	runner := &fakeRunner{responses: []runResponse{{output: "sha256:id postgres@sha256:repo \n"}}}
+	rt := NewDocker(Options{Binary: "docker", Runner: runner})
+	resolved, ok, err := rt.HasImageLocally(context.Background(), "postgres:17")
+	if err != nil || !ok || resolved != "postgres@sha256:repo" {
+		t.Fatalf("expected the repo digest, got %q ok=%v err=%v", resolved, ok, err)
+	}
+	if runner.calls[0].args[0] != "image" || runner.calls[0].args[1] != "inspect" || runner.calls[0].args[len(runner.calls[0].args)-1] != "postgres:17" {
+		t.Fatalf("unexpected inspect args %v", runner.calls[0].args)
+	}
+
+	runner = &fakeRunner{responses: []runResponse{{output: "sha256:loaded \n"}}}
+	rt = NewDocker(Options{Binary: "docker", Runner: runner})
+	if resolved, ok, err := rt.HasImageLocally(context.Background(), "seeded:1"); err != nil || !ok || resolved != "sha256:loaded" {
+		t.Fatalf("expected the image id of a loaded image, got %q ok=%v err=%v", resolved, ok, err)
+	}
+
+	runner = &fakeRunner{responses: []runResponse{{output: "Error: No such image: missing:1", err: errors.New("exit status 1")}}}
+	rt = NewDocker(Options{Binary: "docker", Runner: runner})
+	if _, ok, err := rt.HasImageLocally(context.Background(), "missing:1"); err != nil || ok {
+		t.Fatalf("expected a missing image to be reported as absent, got ok=%v err=%v", ok, err)
+	}
+	if len(runner.calls) != 1 {
+		t.Fatalf("expected no pull, got %+v", runner.calls)
+	}
+}
+
+func TestDockerBuild(t *testing.T) {
+	runner := &fakeRunner{responses: []runResponse{{output: "sha256:built\n"}}}
+	rt := NewDocker(Options{Binary: "docker", Runner: runner})
+	contextDir := filepath.Join(t.TempDir(), "ctx")
+	imageID, err := rt.Build(context.Background(), BuildRequest{
+		ContextDir: contextDir,
+		Dockerfile: "db/Dockerfile",
+		Tag:        "sqlrs/build:abc",
+		Platform:   "linux/amd64",
+	})
+	if err != nil || imageID != "sha256:built" {
+		t.Fatalf("Build: id=%q err=%v", imageID, err)
+	}
+	args := runner.calls[0].args
+	if args[0] != "build" || args[len(args)-1] != contextDir {
+		t.Fatalf("unexpected build args %v", args)
+	}
+	if !containsArg(args, "--file", filepath.Join(contextDir, "db/Dockerfile")) || !containsArg(args, "--tag", "sqlrs/build:abc") || !containsArg(args, "--platform", "linux/amd64") {
+		t.Fatalf("unexpected build args %v", args)
+	}
+
+	runner = &fakeRunner{responses: []runResponse{{output: "step 3/3 failed", err: errors.New("exit status 1")}}}
+	rt = NewDocker(Options{Binary: "docker", Runner: runner})
+	if _, err := rt.Build(context.Background(), BuildRequest{ContextDir: contextDir}); err == nil || !strings.Contains(err.Error(), "docker build failed") {
+		t.Fatalf("expected build failure, got %v", err)
+	}
+	if _, err := rt.Build(context.Background(), BuildRequest{}); err == nil {
+		t.Fatalf("expected missing context to fail")
+	}
+}
+
+func TestPlatformManifestDigest(t *testing.T) {
+	cases := map[string]string{
+		"linux/amd64":    "sha256:amd",
+		"linux/arm/v7":   "sha256:armv7",
+		"linux/arm64/v8": "sha256:arm",
+	}
+	for platform, want := range cases {
+		digest, isIndex, err := platformManifestDigest(multiArchManifest, platform)
+		if err != nil || !isIndex || digest != want {
+			t.Fatalf("%s: got %q index=%v err=%v", platform, digest, isIndex, err)
+		}
+	}
+	if _, _, err := platformManifestDigest(multiArchManifest, "linux/s390x"); err == nil {
+		t.Fatalf("expected error for a platform missing from the index")
+	}
+	if _, _, err := platformManifestDigest(multiArchManifest, "amd64"); err == nil {
+		t.Fatalf("expected error for an invalid platform")
+	}
+}
+
 func TestDockerRuntimeInitBaseRejectsEmpty(t *testing.T) {
 	rt := NewDocker(Options{Runner: &fakeRunner{}})
 	if err := rt.InitBase(context.Background(), "", "/data"); err == nil {
@@ -591,6 +885,7 @@ func TestDockerRuntimeRunContainerBuildsArgs(t *testing.T) {
 		User:    "liquibase",
 		Name:    "sqlrs-liquibase",
 		Network: "container:pg-1",
+		DNS:     []string{"10.0.0.53"},
 		Mounts: []Mount{
 			{HostPath: "/host", ContainerPath: "/mnt", ReadOnly: true},
 		},
@@ -617,6 +912,9 @@ func TestDockerRuntimeRunContainerBuildsArgs(t *testing.T) {
 	if !containsArg(args, "--network", "container:pg-1") {
 		t.Fatalf("expected network flag, got %+v", args)
 	}
+	if !containsArg(args, "--dns", "10.0.0.53") {
+		t.Fatalf("expected dns flag, got %+v", args)
+	}
 	if !containsArg(args, "-e", "FOO=bar") {
 		t.Fatalf("expected env flag, got %+v", args)
 	}
@@ -781,6 +1079,101 @@ func TestDockerRuntimeStopIgnoresMissingContainer(t *testing.T) {
 	}
 }
 
+func TestDockerRuntimePing(t *testing.T) {
+	runner := &fakeRunner{
+		responses: []runResponse{
+			{output: "27.0.1\n"},
+			{output: "\n"},
+			{output: "Cannot connect to the Docker daemon at unix:///var/run/docker.sock", err: errors.New("exit 1")},
+		},
+	}
+	rt := NewDocker(Options{Binary: "docker", Runner: runner})
+	if rt.Binary() != "docker" {
+		t.Fatalf("unexpected binary: %q", rt.Binary())
+	}
+	version, err := rt.Ping(context.Background())
+	if err != nil || version != "27.0.1" {
+		t.Fatalf("expected server version, got %q %v", version, err)
+	}
+	if len(runner.calls[0].args) != 3 || runner.calls[0].args[0] != "version" || runner.calls[0].args[2] != "{{.Server.Version}}" {
+		t.Fatalf("unexpected ping args: %+v", runner.calls[0].args)
+	}
+	if _, err := rt.Ping(context.Background()); err == nil {
+		t.Fatalf("expected empty version error")
+	}
+	if _, err := rt.Ping(context.Background()); !isDockerUnavailable(err) {
+		t.Fatalf("expected docker unavailable error, got %v", err)
+	}
+}
+
+func TestDockerRuntimeNerdctlNamespaceAndPing(t *testing.T) {
+	runner := &fakeRunner{
+		responses: []runResponse{
+			{output: "1.7.2\n"},
+			{output: ""},
+		},
+	}
+	rt := NewDocker(Options{
+		Binary:           "/usr/local/bin/nerdctl",
+		Runner:           runner,
+		Namespace:        "ci",
+		RegistryAuthFile: "/etc/sqlrs/docker/config.json",
+	})
+	version, err := rt.Ping(context.Background())
+	if err != nil || version != "1.7.2" {
+		t.Fatalf("expected containerd version, got %q %v", version, err)
+	}
+	ping := runner.calls[0].args
+	if len(ping) != 5 || ping[0] != "--namespace" || ping[1] != "ci" || ping[2] != "version" || !strings.Contains(ping[4], ".Server.Components") {
+		t.Fatalf("unexpected nerdctl ping args: %v", ping)
+	}
+	if err := rt.Stop(context.Background(), "container-1"); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if stop := runner.calls[1].args; !containsArg(stop, "--namespace", "ci") || containsFlag(stop, "--config") {
+		t.Fatalf("unexpected nerdctl stop args: %v", stop)
+	}
+
+	runner = &fakeRunner{responses: []runResponse{{output: "27.0.1\n"}}}
+	rt = NewDocker(Options{Binary: "docker", Runner: runner, Namespace: "ci"})
+	if _, err := rt.Ping(context.Background()); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+	if containsFlag(runner.calls[0].args, "--namespace") {
+		t.Fatalf("expected docker to ignore the containerd namespace, got %v", runner.calls[0].args)
+	}
+}
+
+func TestDockerRuntimeContainerRunning(t *testing.T) {
+	runner := &fakeRunner{
+		responses: []runResponse{
+			{output: "true\n"},
+			{output: "false\n"},
+			{output: "Error: No such object: container-3\n", err: errors.New("exit 1")},
+			{output: "", err: errors.New("boom")},
+		},
+	}
+	rt := NewDocker(Options{Binary: "docker", Runner: runner})
+	if running, err := rt.ContainerRunning(context.Background(), "container-1"); err != nil || !running {
+		t.Fatalf("expected running container, got %v %v", running, err)
+	}
+	if running, err := rt.ContainerRunning(context.Background(), "container-2"); err != nil || running {
+		t.Fatalf("expected stopped container, got %v %v", running, err)
+	}
+	if running, err := rt.ContainerRunning(context.Background(), "container-3"); err != nil || running {
+		t.Fatalf("expected missing container to be reported as not running, got %v %v", running, err)
+	}
+	if _, err := rt.ContainerRunning(context.Background(), "container-4"); err == nil {
+		t.Fatalf("expected inspect error")
+	}
+	if running, err := rt.ContainerRunning(context.Background(), " "); err != nil || running {
+		t.Fatalf("expected empty id to be not running, got %v %v", running, err)
+	}
+	if len(runner.calls) != 4 || runner.calls[0].args[0] != "inspect" {
+		t.Fatalf("unexpected docker calls: %+v", runner.calls)
+	}
+}
+
 func TestDockerRuntimeWaitForReadyTimeout(t *testing.T) {
 	runner := &fakeRunner{
 		responses: []runResponse{
@@ -807,6 +1200,31 @@ func TestDockerRuntimeWaitForReadySuccess(t *testing.T) {
 	}
 }
 
+func TestDockerRuntimeUsesConfiguredSuperuser(t *testing.T) {
+	runner := &fakeRunner{responses: []runResponse{
+		{output: ""}, {output: ""}, {output: ""},
+		{output: "missing\n", err: errors.New("exit 1")},
+		{output: ""},
+		{output: ""},
+	}}
+	rt := NewDocker(Options{Binary: "docker", Runner: runner, Superuser: "postgres"})
+	if err := rt.InitBase(context.Background(), "image", "/data"); err != nil {
+		t.Fatalf("InitBase: %v", err)
+	}
+	if !containsFlag(runner.calls[4].args, "--username=postgres") {
+		t.Fatalf("expected initdb as postgres, got %+v", runner.calls[4].args)
+	}
+
+	ready := &fakeRunner{responses: []runResponse{{output: "accepting connections\n"}}}
+	rt = NewDocker(Options{Binary: "docker", Runner: ready, Superuser: "postgres"})
+	if err := rt.WaitForReady(context.Background(), "container-1", time.Second); err != nil {
+		t.Fatalf("WaitForReady: %v", err)
+	}
+	if args := ready.calls[0].args; !containsFlag(args, "pg_isready") || !containsArg(args, "-U", "postgres") {
+		t.Fatalf("expected pg_isready as postgres, got %+v", args)
+	}
+}
+
 func TestDockerRuntimeRunPermissionCommandDockerUnavailable(t *testing.T) {
 	runner := &fakeRunner{
 		responses: []runResponse{
@@ -835,7 +1253,7 @@ func TestDockerRuntimeResolveImageWithDigest(t *testing.T) {
 	runner := &fakeRunner{}
 	rt := NewDocker(Options{Binary: "docker", Runner: runner})
 	imageID := "image-1@sha256:abc"
-	resolved, err := rt.ResolveImage(context.Background(), imageID)
+	resolved, err := rt.ResolveImage(context.Background(), imageID, "")
 	if err != nil {
 		t.Fatalf("ResolveImage: %v", err)
 	}
@@ -852,7 +1270,7 @@ func TestDockerRuntimeResolveImageInspectSuccess(t *testing.T) {
 		responses: []runResponse{{output: "repo@sha256:abc\n"}},
 	}
 	rt := NewDocker(Options{Binary: "docker", Runner: runner})
-	resolved, err := rt.ResolveImage(context.Background(), "image-1")
+	resolved, err := rt.ResolveImage(context.Background(), "image-1", "")
 	if err != nil {
 		t.Fatalf("ResolveImage: %v", err)
 	}
@@ -873,7 +1291,7 @@ func TestDockerRuntimeResolveImagePullOnInspectError(t *testing.T) {
 		},
 	}
 	rt := NewDocker(Options{Binary: "docker", Runner: runner})
-	resolved, err := rt.ResolveImage(context.Background(), "image-1")
+	resolved, err := rt.ResolveImage(context.Background(), "image-1", "")
 	if err != nil {
 		t.Fatalf("ResolveImage: %v", err)
 	}
@@ -892,7 +1310,7 @@ func TestDockerRuntimeResolveImageDockerUnavailable(t *testing.T) {
 		},
 	}
 	rt := NewDocker(Options{Binary: "docker", Runner: runner})
-	if _, err := rt.ResolveImage(context.Background(), "image-1"); err == nil || !strings.Contains(err.Error(), "docker is not running") {
+	if _, err := rt.ResolveImage(context.Background(), "image-1", ""); err == nil || !strings.Contains(err.Error(), "docker is not running") {
 		t.Fatalf("expected docker unavailable error, got %v", err)
 	}
 }
@@ -1077,7 +1495,7 @@ func TestNewDockerDefaults(t *testing.T) {
 
 func TestDockerRuntimeResolveImageRejectsEmpty(t *testing.T) {
 	rt := NewDocker(Options{Runner: &fakeRunner{}})
-	if _, err := rt.ResolveImage(context.Background(), " "); err == nil {
+	if _, err := rt.ResolveImage(context.Background(), " ", ""); err == nil {
 		t.Fatalf("expected error for empty image id")
 	}
 }
@@ -1116,7 +1534,7 @@ func TestDockerRuntimeResolveImagePullError(t *testing.T) {
 		},
 	}
 	rt := NewDocker(Options{Binary: "docker", Runner: runner})
-	if _, err := rt.ResolveImage(context.Background(), "image-1"); err == nil || !strings.Contains(err.Error(), "docker pull failed") {
+	if _, err := rt.ResolveImage(context.Background(), "image-1", ""); err == nil || !strings.Contains(err.Error(), "docker pull failed") {
 		t.Fatalf("expected pull failure, got %v", err)
 	}
 }
@@ -1130,7 +1548,7 @@ func TestDockerRuntimeResolveImageInspectAfterPullDockerUnavailable(t *testing.T
 		},
 	}
 	rt := NewDocker(Options{Binary: "docker", Runner: runner})
-	if _, err := rt.ResolveImage(context.Background(), "image-1"); err == nil || !strings.Contains(err.Error(), "docker is not running") {
+	if _, err := rt.ResolveImage(context.Background(), "image-1", ""); err == nil || !strings.Contains(err.Error(), "docker is not running") {
 		t.Fatalf("expected docker unavailable error, got %v", err)
 	}
 }
@@ -1144,7 +1562,7 @@ func TestDockerRuntimeResolveImageEmptyDigest(t *testing.T) {
 		},
 	}
 	rt := NewDocker(Options{Binary: "docker", Runner: runner})
-	if _, err := rt.ResolveImage(context.Background(), "image-1"); err == nil || !strings.Contains(err.Error(), "image digest is empty") {
+	if _, err := rt.ResolveImage(context.Background(), "image-1", ""); err == nil || !strings.Contains(err.Error(), "image digest is empty") {
 		t.Fatalf("expected empty digest error, got %v", err)
 	}
 }
@@ -1158,7 +1576,7 @@ func TestDockerRuntimeResolveImageInspectAfterPullError(t *testing.T) {
 		},
 	}
 	rt := NewDocker(Options{Binary: "docker", Runner: runner})
-	if _, err := rt.ResolveImage(context.Background(), "image-1"); err == nil || !strings.Contains(err.Error(), "docker inspect failed") {
+	if _, err := rt.ResolveImage(context.Background(), "image-1", ""); err == nil || !strings.Contains(err.Error(), "docker inspect failed") {
 		t.Fatalf("expected inspect error, got %v", err)
 	}
 }
@@ -1535,3 +1953,69 @@ func (f fakeFileInfo) Mode() os.FileMode  { return 0 }
 func (f fakeFileInfo) ModTime() time.Time { return time.Now() }
 func (f fakeFileInfo) IsDir() bool        { return false }
 func (f fakeFileInfo) Sys() any           { return nil }
+
+func TestDockerRuntimeListManaged(t *testing.T) {
+	runner := &fakeRunner{responses: []runResponse{{
+		output: "abc123\tsqlrs-prepare-job-1\tsqlrs.job=job-1,sqlrs.managed=true,sqlrs.state=state-1\n" +
+			"def456\tsqlrs-run-inst-1\tmap[sqlrs.instance:inst-1 sqlrs.managed:true]\n\n",
+	}}}
+	rt := NewDocker(Options{Binary: "docker", Runner: runner})
+	containers, err := rt.ListManaged(context.Background())
+	if err != nil {
+		t.Fatalf("ListManaged: %v", err)
+	}
+	if len(runner.calls) != 1 || !containsArg(runner.calls[0].args, "--filter", "label=sqlrs.managed=true") || !containsFlag(runner.calls[0].args, "-a") {
+		t.Fatalf("unexpected ps call: %+v", runner.calls)
+	}
+	if len(containers) != 2 {
+		t.Fatalf("expected 2 containers, got %+v", containers)
+	}
+	if containers[0].ID != "abc123" || containers[0].Name != "sqlrs-prepare-job-1" ||
+		containers[0].Labels[LabelJob] != "job-1" || containers[0].Labels[LabelState] != "state-1" {
+		t.Fatalf("unexpected docker container: %+v", containers[0])
+	}
+	if containers[1].ID != "def456" || containers[1].Labels[LabelInstance] != "inst-1" || containers[1].Labels[LabelManaged] != "true" {
+		t.Fatalf("unexpected podman container: %+v", containers[1])
+	}
+}
+
+func TestDockerRuntimeEngineIDLabelsAndFilters(t *testing.T) {
+	runner := &fakeRunner{responses: []runResponse{{output: "abc123\tsqlrs-prepare-job-1\tsqlrs.engine=e1,sqlrs.managed=true\n"}}}
+	rt := NewDocker(Options{Binary: "docker", Runner: runner, EngineID: "e1"})
+	if _, err := rt.ListManaged(context.Background()); err != nil {
+		t.Fatalf("ListManaged: %v", err)
+	}
+	args := runner.calls[0].args
+	if !containsArg(args, "--filter", "label=sqlrs.managed=true") || !containsArg(args, "--filter", "label=sqlrs.engine=e1") {
+		t.Fatalf("expected engine filter, got %v", args)
+	}
+	labels := dockerLabelArgs(map[string]string{LabelJob: "job-1", LabelEngine: "other"}, "e1")
+	if !containsArg(labels, "--label", "sqlrs.engine=e1") || containsArg(labels, "--label", "sqlrs.engine=other") {
+		t.Fatalf("expected the runtime engine label only, got %v", labels)
+	}
+	if got := dockerLabelArgs(nil, ""); len(got) != 2 {
+		t.Fatalf("expected only the managed label without engine id, got %v", got)
+	}
+}
+
+func TestStoreIdentity(t *testing.T) {
+	root := t.TempDir()
+	id := StoreIdentity(root)
+	if len(id) != 12 || StoreIdentity(root+string(filepath.Separator)) != id {
+		t.Fatalf("expected a stable 12 character identity, got %q", id)
+	}
+	if StoreIdentity(filepath.Join(root, "other")) == id {
+		t.Fatalf("expected different stores to get different identities")
+	}
+	if StoreIdentity(" ") != "" {
+		t.Fatalf("expected empty identity for an empty root")
+	}
+}
+
+func TestDockerRuntimeListManagedError(t *testing.T) {
+	runner := &fakeRunner{responses: []runResponse{{output: "boom", err: errors.New("exit status 1")}}}
+	rt := NewDocker(Options{Binary: "docker", Runner: runner})
+	if _, err := rt.ListManaged(context.Background()); err == nil {
+		t.Fatalf("expected error")
+	}
+}
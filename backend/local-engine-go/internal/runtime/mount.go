@@ -66,6 +66,44 @@ func ensureStateStoreMount() error {
 	return nil
 }
 
+// MountStatus describes the WSL state store mount configured with
+// SQLRS_WSL_MOUNT_UNIT and SQLRS_WSL_MOUNT_FSTYPE.
+type MountStatus struct {
+	Unit           string
+	ExpectedFSType string
+	UnitActive     bool
+	Mounted        bool
+	FSType         string
+}
+
+// StateStoreMountStatus reads the state of the WSL state store mount without
+// starting the unit, for diagnostics. ok is false when no mount is configured.
+func StateStoreMountStatus(storeRoot string) (MountStatus, bool, error) {
+	unit := strings.TrimSpace(os.Getenv("SQLRS_WSL_MOUNT_UNIT"))
+	fstype := strings.TrimSpace(os.Getenv("SQLRS_WSL_MOUNT_FSTYPE"))
+	if unit == "" && fstype == "" {
+		return MountStatus{}, false, nil
+	}
+	if fstype == "" {
+		fstype = "btrfs"
+	}
+	status := MountStatus{Unit: unit, ExpectedFSType: fstype}
+	if unit != "" {
+		active, err := isSystemdUnitActive(unit)
+		if err != nil {
+			return status, true, fmt.Errorf("mount unit check failed: %w", err)
+		}
+		status.UnitActive = active
+	}
+	fsType, mounted, err := findmntFSType(storeRoot)
+	if err != nil {
+		return status, true, err
+	}
+	status.Mounted = mounted
+	status.FSType = fsType
+	return status, true, nil
+}
+
 func findmntFSType(target string) (string, bool, error) {
 	out, err := runMountCommandInInitNamespace("findmnt", "-n", "-o", "FSTYPE", "-T", target)
 	if err == nil {
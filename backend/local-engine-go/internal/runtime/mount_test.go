@@ -232,3 +232,38 @@ func TestRunMountCommandOutput(t *testing.T) {
 		t.Fatalf("expected output ok, got %q", out)
 	}
 }
+
+func TestStateStoreMountStatus(t *testing.T) {
+	t.Setenv("SQLRS_WSL_MOUNT_UNIT", "")
+	t.Setenv("SQLRS_WSL_MOUNT_FSTYPE", "")
+	if _, ok, err := StateStoreMountStatus("/tmp/sqlrs"); ok || err != nil {
+		t.Fatalf("expected no mount without env, got ok=%v err=%v", ok, err)
+	}
+
+	prev := runMountCommandFn
+	var calls []string
+	runMountCommandFn = func(name string, args ...string) (string, error) {
+		calls = append(calls, name+" "+strings.Join(args, " "))
+		switch name {
+		case "systemctl":
+			return "inactive\n", exitError(3)
+		default:
+			return "ext4\n", nil
+		}
+	}
+	t.Cleanup(func() { runMountCommandFn = prev })
+
+	t.Setenv("SQLRS_WSL_MOUNT_UNIT", "sqlrs-state-store.mount")
+	status, ok, err := StateStoreMountStatus("/tmp/sqlrs")
+	if err != nil || !ok {
+		t.Fatalf("StateStoreMountStatus: ok=%v err=%v", ok, err)
+	}
+	if status.UnitActive || !status.Mounted || status.FSType != "ext4" || status.ExpectedFSType != "btrfs" {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+	for _, call := range calls {
+		if strings.Contains(call, " start ") {
+			t.Fatalf("expected the unit not to be started, got %v", calls)
+		}
+	}
+}
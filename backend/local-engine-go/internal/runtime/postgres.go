@@ -1,6 +1,19 @@
 package runtime
 
+import "strings"
+
 const (
 	PostgresDataDirRoot = "/var/lib/postgresql/data"
 	PostgresDataDir     = "/var/lib/postgresql/data/pgdata"
+	// DefaultPostgresSuperuser is the role initdb bootstraps when no other
+	// superuser is configured.
+	DefaultPostgresSuperuser = "sqlrs"
 )
+
+// PostgresSuperuser returns name, or DefaultPostgresSuperuser when it is blank.
+func PostgresSuperuser(name string) string {
+	if name = strings.TrimSpace(name); name != "" {
+		return name
+	}
+	return DefaultPostgresSuperuser
+}
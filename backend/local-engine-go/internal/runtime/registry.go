@@ -0,0 +1,183 @@
+package runtime
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const defaultRegistryDomain = "docker.io"
+
+// registryConfig carries the optional registry mirror and credentials used
+// when the container runtime has to pull an image.
+type registryConfig struct {
+	mirror   string
+	authFile string
+}
+
+func newRegistryConfig(mirror, authFile string) registryConfig {
+	mirror = strings.TrimSpace(mirror)
+	mirror = strings.TrimPrefix(mirror, "https://")
+	mirror = strings.TrimPrefix(mirror, "http://")
+	return registryConfig{
+		mirror:   strings.TrimRight(mirror, "/"),
+		authFile: strings.TrimSpace(authFile),
+	}
+}
+
+// imageRef rewrites an image reference so that it is pulled from the mirror.
+// The registry domain of the reference is replaced by the mirror host, and
+// Docker Hub official images get their implicit "library/" namespace.
+func (c registryConfig) imageRef(ref string) string {
+	ref = strings.TrimSpace(ref)
+	if c.mirror == "" || ref == "" {
+		return ref
+	}
+	if strings.HasPrefix(ref, c.mirror+"/") {
+		return ref
+	}
+	_, remainder := splitImageDomain(ref)
+	return c.mirror + "/" + remainder
+}
+
+// canonicalDigestRef maps a digest reference reported for the mirrored image
+// back onto the repository the caller asked for, so that resolved image ids
+// (and the state ids derived from them) do not depend on the mirror.
+func (c registryConfig) canonicalDigestRef(requested string, resolved string) string {
+	resolved = strings.TrimSpace(resolved)
+	if c.mirror == "" {
+		return resolved
+	}
+	at := strings.LastIndex(resolved, "@")
+	if at < 0 {
+		return resolved
+	}
+	return imageRepository(requested) + resolved[at:]
+}
+
+// commandArgs adds registry credentials to commands that may pull images.
+// Podman accepts --authfile on pull and run; docker reads credentials from the
+// config.json in the directory given by its global --config flag, which is the
+// flag form of DOCKER_CONFIG. nerdctl has no such flag and only reads
+// DOCKER_CONFIG, which the engine sets at startup (see DockerConfigDir).
+func (c registryConfig) commandArgs(binary string, args []string) []string {
+	if c.authFile == "" || len(args) == 0 || IsNerdctlBinary(binary) {
+		return args
+	}
+	switch args[0] {
+	case "pull", "run":
+	default:
+		return args
+	}
+	if isPodmanBinary(binary) {
+		out := make([]string, 0, len(args)+2)
+		out = append(out, args[0], "--authfile", c.authFile)
+		return append(out, args[1:]...)
+	}
+	return append([]string{"--config", DockerConfigDir(c.authFile)}, args...)
+}
+
+// DockerConfigDir returns the docker config directory for a registry auth
+// file, or the path itself when it already is a directory. Docker and nerdctl only read <dir>/config.json, so a file with another
+// name is linked (or copied, where links are unavailable) as config.json into
+// a directory under the temp dir derived from the file path. When that fails
+// the file's own directory is returned.
+func DockerConfigDir(authFile string) string {
+	if strings.EqualFold(filepath.Base(authFile), "config.json") {
+		return filepath.Dir(authFile)
+	}
+	if info, err := os.Stat(authFile); err == nil && info.IsDir() {
+		return authFile
+	}
+	dir, err := linkDockerConfig(authFile)
+	if err != nil {
+		return filepath.Dir(authFile)
+	}
+	return dir
+}
+
+func linkDockerConfig(authFile string) (string, error) {
+	abs, err := filepath.Abs(authFile)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(abs))
+	dir := filepath.Join(os.TempDir(), "sqlrs-docker-config-"+hex.EncodeToString(sum[:6]))
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	target := filepath.Join(dir, "config.json")
+	if current, err := os.Readlink(target); err == nil && current == abs {
+		return dir, nil
+	}
+	_ = os.Remove(target)
+	if err := os.Symlink(abs, target); err == nil {
+		return dir, nil
+	}
+	data, err := os.ReadFile(abs)
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(target, data, 0o600); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+func isPodmanBinary(binary string) bool {
+	return strings.Contains(strings.ToLower(filepath.Base(binary)), "podman")
+}
+
+// IsNerdctlBinary reports whether binary is nerdctl, the docker-compatible
+// CLI for containerd.
+func IsNerdctlBinary(binary string) bool {
+	return strings.Contains(strings.ToLower(filepath.Base(binary)), "nerdctl")
+}
+
+// splitImageDomain splits a reference into its registry domain and the rest,
+// following the docker rule that the first path component is a domain only if
+// it contains "." or ":" or is "localhost".
+func splitImageDomain(ref string) (string, string) {
+	domain := defaultRegistryDomain
+	remainder := ref
+	if idx := strings.IndexByte(ref, '/'); idx >= 0 {
+		first := ref[:idx]
+		if strings.ContainsAny(first, ".:") || first == "localhost" {
+			domain = first
+			remainder = ref[idx+1:]
+		}
+	}
+	if (domain == defaultRegistryDomain || domain == "index.docker.io") && !strings.Contains(remainder, "/") {
+		remainder = "library/" + remainder
+	}
+	return domain, remainder
+}
+
+// NormalizeImageRef spells out the registry domain and the implicit Docker Hub
+// "library/" namespace of a reference, so "postgres:17" becomes
+// "docker.io/library/postgres:17". Tags and digests are kept.
+func NormalizeImageRef(ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return ""
+	}
+	domain, remainder := splitImageDomain(ref)
+	if domain == "index.docker.io" {
+		domain = defaultRegistryDomain
+	}
+	return domain + "/" + remainder
+}
+
+// imageRepository strips the tag and digest from an image reference.
+func imageRepository(ref string) string {
+	ref = strings.TrimSpace(ref)
+	if at := strings.Index(ref, "@"); at >= 0 {
+		ref = ref[:at]
+	}
+	if colon := strings.LastIndex(ref, ":"); colon > strings.LastIndex(ref, "/") {
+		ref = ref[:colon]
+	}
+	return ref
+}
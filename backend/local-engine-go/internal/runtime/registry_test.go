@@ -0,0 +1,165 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRegistryConfigImageRef(t *testing.T) {
+	cfg := newRegistryConfig("https://mirror.local:5000/", "")
+	cases := map[string]string{
+		"postgres:17":                     "mirror.local:5000/library/postgres:17",
+		"postgres@sha256:abc":             "mirror.local:5000/library/postgres@sha256:abc",
+		"bitnami/postgresql:16":           "mirror.local:5000/bitnami/postgresql:16",
+		"docker.io/postgres:17":           "mirror.local:5000/library/postgres:17",
+		"ghcr.io/org/pg:1":                "mirror.local:5000/org/pg:1",
+		"localhost/pg:1":                  "mirror.local:5000/pg:1",
+		"mirror.local:5000/library/pg:17": "mirror.local:5000/library/pg:17",
+	}
+	for ref, want := range cases {
+		if got := cfg.imageRef(ref); got != want {
+			t.Fatalf("imageRef(%q) = %q, want %q", ref, got, want)
+		}
+	}
+	if got := newRegistryConfig("", "").imageRef("postgres:17"); got != "postgres:17" {
+		t.Fatalf("expected reference unchanged without mirror, got %q", got)
+	}
+}
+
+func TestNormalizeImageRef(t *testing.T) {
+	cases := map[string]string{
+		"postgres:17":                  "docker.io/library/postgres:17",
+		" postgres@sha256:abc ":        "docker.io/library/postgres@sha256:abc",
+		"bitnami/postgresql:16":        "docker.io/bitnami/postgresql:16",
+		"index.docker.io/postgres:17":  "docker.io/library/postgres:17",
+		"registry.internal/team/pg:17": "registry.internal/team/pg:17",
+		"localhost:5000/pg":            "localhost:5000/pg",
+		"":                             "",
+	}
+	for ref, want := range cases {
+		if got := NormalizeImageRef(ref); got != want {
+			t.Fatalf("NormalizeImageRef(%q) = %q, want %q", ref, got, want)
+		}
+	}
+}
+
+func TestRegistryConfigCanonicalDigestRef(t *testing.T) {
+	cfg := newRegistryConfig("mirror.local", "")
+	got := cfg.canonicalDigestRef("postgres:17", "mirror.local/library/postgres@sha256:abc")
+	if got != "postgres@sha256:abc" {
+		t.Fatalf("unexpected canonical ref: %q", got)
+	}
+	got = cfg.canonicalDigestRef("localhost:5000/pg:1", "mirror.local/pg@sha256:def")
+	if got != "localhost:5000/pg@sha256:def" {
+		t.Fatalf("unexpected canonical ref for registry with port: %q", got)
+	}
+}
+
+func TestRegistryConfigCommandArgs(t *testing.T) {
+	docker := newRegistryConfig("", "/home/user/.docker/config.json")
+	args := docker.commandArgs("/usr/bin/docker", []string{"pull", "postgres:17"})
+	if len(args) != 4 || args[0] != "--config" || args[1] != "/home/user/.docker" || args[2] != "pull" {
+		t.Fatalf("unexpected docker args: %v", args)
+	}
+	podman := newRegistryConfig("", "/run/auth.json")
+	args = podman.commandArgs("podman", []string{"run", "--rm", "img"})
+	if len(args) != 5 || args[0] != "run" || args[1] != "--authfile" || args[2] != "/run/auth.json" {
+		t.Fatalf("unexpected podman args: %v", args)
+	}
+	args = podman.commandArgs("podman", []string{"exec", "c1", "true"})
+	if containsFlag(args, "--authfile") {
+		t.Fatalf("expected exec args unchanged, got %v", args)
+	}
+}
+
+func TestDockerConfigDirLinksOtherAuthFiles(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	authFile := filepath.Join(t.TempDir(), "registry-auth.json")
+	if err := os.WriteFile(authFile, []byte(`{"auths":{}}`), 0o600); err != nil {
+		t.Fatalf("write auth file: %v", err)
+	}
+	dir := DockerConfigDir(authFile)
+	if dir == authFile || dir == filepath.Dir(authFile) {
+		t.Fatalf("expected a separate config dir, got %q", dir)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "config.json"))
+	if err != nil || string(data) != `{"auths":{}}` {
+		t.Fatalf("expected config.json to expose the auth file, got %q err=%v", data, err)
+	}
+	if again := DockerConfigDir(authFile); again != dir {
+		t.Fatalf("expected a stable config dir, got %q and %q", dir, again)
+	}
+	if got := DockerConfigDir(filepath.Dir(authFile)); got != filepath.Dir(authFile) {
+		t.Fatalf("expected a directory to be used as is, got %q", got)
+	}
+	args := newRegistryConfig("", authFile).commandArgs("docker", []string{"pull", "postgres:17"})
+	if len(args) < 2 || args[0] != "--config" || args[1] != dir {
+		t.Fatalf("expected --config %s, got %v", dir, args)
+	}
+}
+
+func TestDockerRuntimeResolveImageThroughMirror(t *testing.T) {
+	runner := &fakeRunner{
+		responses: []runResponse{
+			{output: "no such image\n", err: errors.New("fail")},
+			{output: ""},
+			{output: "mirror.local/library/postgres@sha256:abc\n"},
+		},
+	}
+	rt := NewDocker(Options{
+		Binary:           "podman",
+		Runner:           runner,
+		RegistryMirror:   "mirror.local",
+		RegistryAuthFile: "/run/auth.json",
+	})
+	resolved, err := rt.ResolveImage(context.Background(), "postgres:17", "")
+	if err != nil {
+		t.Fatalf("ResolveImage: %v", err)
+	}
+	if resolved != "postgres@sha256:abc" {
+		t.Fatalf("expected canonical digest, got %q", resolved)
+	}
+	if len(runner.calls) != 3 {
+		t.Fatalf("expected inspect, pull and inspect, got %+v", runner.calls)
+	}
+	pull := runner.calls[1].args
+	if !containsArg(pull, "--authfile", "/run/auth.json") || pull[len(pull)-1] != "mirror.local/library/postgres:17" {
+		t.Fatalf("unexpected pull args: %v", pull)
+	}
+	if inspect := runner.calls[2].args; inspect[len(inspect)-1] != "mirror.local/library/postgres:17" {
+		t.Fatalf("expected inspect of mirrored ref, got %v", inspect)
+	}
+}
+
+func TestDockerRuntimeStartUsesMirror(t *testing.T) {
+	runner := &fakeRunner{
+		responses: []runResponse{
+			{output: ""},
+			{output: ""},
+			{output: ""},
+			{output: "container-1\n"},
+			{output: ""},
+			{output: ""},
+			{output: ""},
+			{output: "accepting connections\n"},
+			{output: "0.0.0.0:54321\n"},
+		},
+	}
+	rt := NewDocker(Options{
+		Binary:           "docker",
+		Runner:           runner,
+		RegistryMirror:   "mirror.local",
+		RegistryAuthFile: "/etc/sqlrs/docker/config.json",
+	})
+	if _, err := rt.Start(context.Background(), StartRequest{ImageID: "postgres@sha256:abc", DataDir: "/data"}); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	for _, call := range runner.calls[:4] {
+		if !containsArg(call.args, "--config", "/etc/sqlrs/docker") || !containsFlag(call.args, "mirror.local/library/postgres@sha256:abc") {
+			t.Fatalf("expected mirrored image and docker config, got %v", call.args)
+		}
+	}
+}
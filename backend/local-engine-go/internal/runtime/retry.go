@@ -0,0 +1,94 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// nonRetryableMarkers identify permanent failures that must not be retried
+// even when the output also contains a transient-looking message.
+var nonRetryableMarkers = []string{
+	"not found",
+	"no such image",
+	"manifest unknown",
+	"pull access denied",
+	"unauthorized",
+	"access denied",
+	"invalid reference format",
+	"permission denied",
+}
+
+// retryableMarkers identify transient container runtime failures.
+var retryableMarkers = []string{
+	"connection refused",
+	"connection reset by peer",
+	"i/o timeout",
+	"tls handshake timeout",
+	"temporary failure in name resolution",
+	"service unavailable",
+	"toomanyrequests",
+	"too many requests",
+	"context deadline exceeded (client.timeout exceeded",
+	"the database system is starting up",
+	"postgres readiness timed out",
+}
+
+// imageNotFoundMarkers identify an image reference, or a platform variant of
+// it, that the registry does not have.
+var imageNotFoundMarkers = []string{
+	"manifest unknown",
+	"no such image",
+	"repository does not exist",
+	"pull access denied",
+	"no matching manifest",
+	"no manifest for platform",
+}
+
+// IsImageNotFoundError reports whether err says the requested image does not
+// exist (or is not visible with the current registry credentials).
+func IsImageNotFoundError(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	for _, marker := range imageNotFoundMarkers {
+		if strings.Contains(msg, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsUnavailableError reports whether err says the container runtime itself
+// could not be reached.
+func IsUnavailableError(err error) bool {
+	return isDockerUnavailable(err)
+}
+
+// IsRetryableError reports whether err is a transient container runtime
+// failure worth retrying. Cancellation and permanent errors such as missing
+// images are never retryable.
+func IsRetryableError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	for _, marker := range nonRetryableMarkers {
+		if strings.Contains(msg, marker) {
+			return false
+		}
+	}
+	if isDockerUnavailable(err) {
+		return true
+	}
+	for _, marker := range retryableMarkers {
+		if strings.Contains(msg, marker) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestIsRetryableError(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "nil", err: nil, want: false},
+		{name: "canceled", err: context.Canceled, want: false},
+		{name: "deadline", err: fmt.Errorf("run: %w", context.DeadlineExceeded), want: false},
+		{name: "docker unavailable", err: fmt.Errorf("docker is not running: %w", DockerUnavailableError{}), want: true},
+		{name: "connection refused", err: errors.New("dial tcp 127.0.0.1:2375: connect: connection refused"), want: true},
+		{name: "registry rate limit", err: errors.New("docker pull failed: toomanyrequests: rate limit"), want: true},
+		{name: "readiness timeout", err: errors.New("postgres readiness timed out"), want: true},
+		{name: "image not found", err: errors.New("docker pull failed: manifest for postgres:99 not found: manifest unknown"), want: false},
+		{name: "pull denied with timeout", err: errors.New("pull access denied after i/o timeout"), want: false},
+		{name: "unknown", err: errors.New("boom"), want: false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := IsRetryableError(tc.err); got != tc.want {
+				t.Fatalf("IsRetryableError(%v) = %v, want %v", tc.err, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestIsImageNotFoundError(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "nil", err: nil, want: false},
+		{name: "manifest unknown", err: errors.New("docker pull failed: manifest for postgres:99 not found: manifest unknown"), want: true},
+		{name: "private repo", err: errors.New("docker pull failed: pull access denied for acme/pg, repository does not exist or may require 'docker login'"), want: true},
+		{name: "platform", err: errors.New("image postgres:17: no manifest for platform linux/s390x"), want: true},
+		{name: "docker unavailable", err: fmt.Errorf("docker is not running: %w", DockerUnavailableError{}), want: false},
+		{name: "rate limit", err: errors.New("docker pull failed: toomanyrequests: rate limit"), want: false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := IsImageNotFoundError(tc.err); got != tc.want {
+				t.Fatalf("IsImageNotFoundError(%v) = %v, want %v", tc.err, got, tc.want)
+			}
+		})
+	}
+	if !IsUnavailableError(fmt.Errorf("docker is not running: %w", DockerUnavailableError{})) || IsUnavailableError(errors.New("boom")) {
+		t.Fatalf("unexpected IsUnavailableError result")
+	}
+}
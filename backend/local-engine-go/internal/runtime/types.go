@@ -13,6 +13,21 @@ type Instance struct {
 	Port int
 }
 
+// Container labels attached to every container started by the engine.
+const (
+	LabelManaged  = "sqlrs.managed"
+	LabelJob      = "sqlrs.job"
+	LabelState    = "sqlrs.state"
+	LabelInstance = "sqlrs.instance"
+	// LabelEngine identifies the state store of the engine that started the
+	// container, so engines sharing a container runtime leave each other's
+	// containers alone.
+	LabelEngine = "sqlrs.engine"
+	// LabelWarm marks idle containers started ahead of demand for the
+	// prepare warm pool (container.warmPool.size).
+	LabelWarm = "sqlrs.warm"
+)
+
 type StartRequest struct {
 	ImageID string
 	DataDir string
@@ -20,6 +35,30 @@ type StartRequest struct {
 	Mounts  []Mount
 	// AllowInitdb controls whether Start may initialize an empty data directory.
 	AllowInitdb bool
+	// Labels are attached to the container in addition to sqlrs.managed=true.
+	// They are for observability only and never affect state content.
+	Labels map[string]string
+	// CPUs and Memory are passed as --cpus and --memory (for example "2" and
+	// "4g"); empty means no limit.
+	CPUs   string
+	Memory string
+	// Network and DNS are passed as --network and --dns; empty means the
+	// runtime default network and resolvers.
+	Network string
+	DNS     []string
+	// Platform is passed as --platform (for example "linux/amd64"); empty
+	// means the runtime default.
+	Platform string
+	// User is passed as --user (a name or uid[:gid]) and owns the data
+	// directory; empty keeps the image's postgres user.
+	User string
+}
+
+// ManagedContainer is a container carrying the sqlrs.managed=true label.
+type ManagedContainer struct {
+	ID     string
+	Name   string
+	Labels map[string]string
 }
 
 type ExecRequest struct {
@@ -38,20 +77,51 @@ type RunRequest struct {
 	User    string
 	Name    string
 	Network string
+	DNS     []string
 	Mounts  []Mount
 }
 
+// BuildRequest describes a docker build of a base image.
+type BuildRequest struct {
+	// ContextDir is the build context on the engine host; Dockerfile is a
+	// path inside it.
+	ContextDir string
+	Dockerfile string
+	// Tag names the built image; Platform is passed as --platform.
+	Tag      string
+	Platform string
+}
+
 type Mount struct {
 	HostPath      string
 	ContainerPath string
 	ReadOnly      bool
+	// Owned marks a file only the container user may read, such as a 0600
+	// pgpass; Start hands it to that user like the data directory.
+	Owned bool
 }
 
 type Runtime interface {
 	InitBase(ctx context.Context, imageID string, dataDir string) error
-	ResolveImage(ctx context.Context, imageID string) (string, error)
+	// ResolveImage returns a digest reference for imageID. With a platform
+	// (os/arch[/variant]) the digest is that platform's manifest, so the same
+	// tag resolves to a different reference per platform.
+	ResolveImage(ctx context.Context, imageID string, platform string) (string, error)
+	// HasImageLocally looks ref up in the local image store without
+	// contacting a registry. It returns the image's digest reference, or its
+	// image ID when it has no repo digest (for example after docker load);
+	// ok is false when the image is not present.
+	HasImageLocally(ctx context.Context, ref string) (resolved string, ok bool, err error)
+	// Build builds an image from a Dockerfile and returns its image ID
+	// ("sha256:..."), which Start accepts as an image reference.
+	Build(ctx context.Context, req BuildRequest) (string, error)
 	Start(ctx context.Context, req StartRequest) (Instance, error)
 	Stop(ctx context.Context, id string) error
 	Exec(ctx context.Context, id string, req ExecRequest) (string, error)
 	WaitForReady(ctx context.Context, id string, timeout time.Duration) error
+	// Ping checks that the container runtime daemon is reachable and returns
+	// its server version.
+	Ping(ctx context.Context) (string, error)
+	// ListManaged lists containers, running or not, started by the engine.
+	ListManaged(ctx context.Context) ([]ManagedContainer, error)
 }
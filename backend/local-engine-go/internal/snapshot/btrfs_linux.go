@@ -33,6 +33,7 @@ var btrfsListSubvolumesFn = func(ctx context.Context, dir string) (string, error
 
 type btrfsManager struct {
 	runner commandRunner
+	quota  *btrfsQuota
 }
 
 func newBtrfsManager() Manager {
@@ -121,11 +122,15 @@ func (m btrfsManager) Clone(ctx context.Context, srcDir string, destDir string)
 		log.Printf("btrfs: clone mkdir failed dest=%s err=%v", destDir, err)
 		return CloneResult{}, err
 	}
-	args := []string{"subvolume", "snapshot", srcDir, destDir}
+	inherit, err := m.inheritArgs(ctx, destDir)
+	if err != nil {
+		return CloneResult{}, err
+	}
+	args := append(append([]string{"subvolume", "snapshot"}, inherit...), srcDir, destDir)
 	log.Printf("btrfs: clone exec %s", formatCommand("btrfs", args))
 	if err := m.runner.Run(ctx, "btrfs", args); err != nil {
 		log.Printf("btrfs: clone snapshot failed src=%s dest=%s err=%v", srcDir, destDir, err)
-		return CloneResult{}, err
+		return CloneResult{}, quotaError(err)
 	}
 	if output, err := btrfsListSubvolumesFn(ctx, destDir); err == nil {
 		trimmed := strings.TrimSpace(output)
@@ -186,11 +191,20 @@ func (m btrfsManager) Snapshot(ctx context.Context, srcDir string, destDir strin
 	} else if !errors.Is(err, os.ErrNotExist) {
 		return err
 	}
-	log.Printf("btrfs: snapshot exec %s", formatCommand("btrfs", []string{"subvolume", "snapshot", "-r", srcDir, destDir}))
-	if err := m.runner.Run(ctx, "btrfs", []string{"subvolume", "snapshot", "-r", srcDir, destDir}); err != nil {
-		log.Printf("btrfs: snapshot failed src=%s dest=%s err=%v", srcDir, destDir, err)
+	if err := m.checkQuota(ctx, destDir); err != nil {
+		log.Printf("btrfs: snapshot refused dest=%s err=%v", destDir, err)
+		return err
+	}
+	inherit, err := m.inheritArgs(ctx, destDir)
+	if err != nil {
 		return err
 	}
+	args := append(append([]string{"subvolume", "snapshot", "-r"}, inherit...), srcDir, destDir)
+	log.Printf("btrfs: snapshot exec %s", formatCommand("btrfs", args))
+	if err := m.runner.Run(ctx, "btrfs", args); err != nil {
+		log.Printf("btrfs: snapshot failed src=%s dest=%s err=%v", srcDir, destDir, err)
+		return quotaError(err)
+	}
 	if output, err := btrfsListSubvolumesFn(ctx, destDir); err == nil {
 		trimmed := strings.TrimSpace(output)
 		if trimmed != "" {
@@ -237,13 +251,21 @@ func (m btrfsManager) EnsureSubvolume(ctx context.Context, path string) error {
 		if err := osRemoveAllBtrfs(path); err != nil {
 			return fmt.Errorf("path exists but is not a btrfs subvolume: %s", path)
 		}
-		log.Printf("btrfs: ensure subvolume exec %s", formatCommand("btrfs", []string{"subvolume", "create", path}))
-		return m.runner.Run(ctx, "btrfs", []string{"subvolume", "create", path})
+		return m.createSubvolume(ctx, path)
 	} else if !errors.Is(err, os.ErrNotExist) {
 		return err
 	}
-	log.Printf("btrfs: ensure subvolume exec %s", formatCommand("btrfs", []string{"subvolume", "create", path}))
-	return m.runner.Run(ctx, "btrfs", []string{"subvolume", "create", path})
+	return m.createSubvolume(ctx, path)
+}
+
+func (m btrfsManager) createSubvolume(ctx context.Context, path string) error {
+	inherit, err := m.inheritArgs(ctx, path)
+	if err != nil {
+		return err
+	}
+	args := append(append([]string{"subvolume", "create"}, inherit...), path)
+	log.Printf("btrfs: ensure subvolume exec %s", formatCommand("btrfs", args))
+	return quotaError(m.runner.Run(ctx, "btrfs", args))
 }
 
 func (m btrfsManager) IsSubvolume(ctx context.Context, path string) (bool, error) {
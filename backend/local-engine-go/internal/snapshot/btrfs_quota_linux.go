@@ -0,0 +1,149 @@
+//go:build linux
+
+package snapshot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+var btrfsQgroupShowFn = func(ctx context.Context, path string) (string, error) {
+	cmd := exec.CommandContext(ctx, "btrfs", "qgroup", "show", "--raw", "-r", path)
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
+
+// btrfsQuota tracks the level-1 qgroups that cap the state store. Every
+// namespace root (root/ns/<name>) gets its own qgroup; everything else is
+// accounted to the state store root. Subvolumes join their qgroup when they
+// are created, so the limit covers all states of a namespace together.
+type btrfsQuota struct {
+	root  string
+	limit int64
+
+	mu    sync.Mutex
+	ready map[string]bool
+}
+
+func (m btrfsManager) withQuota(root string, limitBytes int64) Manager {
+	root = strings.TrimSpace(root)
+	if root == "" || limitBytes <= 0 {
+		return m
+	}
+	m.quota = &btrfsQuota{
+		root:  filepath.Clean(root),
+		limit: limitBytes,
+		ready: map[string]bool{},
+	}
+	return m
+}
+
+// quotaRoot returns the directory whose qgroup accounts for path.
+func (q *btrfsQuota) quotaRoot(path string) string {
+	nsDir := filepath.Join(q.root, "ns")
+	rel, err := filepath.Rel(nsDir, filepath.Clean(path))
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return q.root
+	}
+	name := strings.SplitN(rel, string(filepath.Separator), 2)[0]
+	return filepath.Join(nsDir, name)
+}
+
+func qgroupID(quotaRoot string) string {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(quotaRoot))
+	return fmt.Sprintf("1/%d", uint64(h.Sum32())+1)
+}
+
+// inheritArgs makes sure the qgroup for path exists and returns the extra
+// arguments that add a new subvolume at path to it.
+func (m btrfsManager) inheritArgs(ctx context.Context, path string) ([]string, error) {
+	if m.quota == nil {
+		return nil, nil
+	}
+	id, err := m.quota.ensure(ctx, m.runner, path)
+	if err != nil {
+		return nil, err
+	}
+	return []string{"-i", id}, nil
+}
+
+func (q *btrfsQuota) ensure(ctx context.Context, runner commandRunner, path string) (string, error) {
+	quotaRoot := q.quotaRoot(path)
+	id := qgroupID(quotaRoot)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.ready[id] {
+		return id, nil
+	}
+	log.Printf("btrfs: quota setup root=%s qgroup=%s limit=%d", quotaRoot, id, q.limit)
+	if err := runner.Run(ctx, "btrfs", []string{"quota", "enable", q.root}); err != nil {
+		return "", fmt.Errorf("enable btrfs quota: %w", err)
+	}
+	if err := runner.Run(ctx, "btrfs", []string{"qgroup", "create", id, q.root}); err != nil && !strings.Contains(strings.ToLower(err.Error()), "exist") {
+		return "", fmt.Errorf("create btrfs qgroup %s: %w", id, err)
+	}
+	if err := runner.Run(ctx, "btrfs", []string{"qgroup", "limit", strconv.FormatInt(q.limit, 10), id, q.root}); err != nil {
+		return "", fmt.Errorf("limit btrfs qgroup %s: %w", id, err)
+	}
+	q.ready[id] = true
+	return id, nil
+}
+
+// checkQuota refuses a snapshot when the qgroup for path has already used up
+// its limit. Failing to read usage is logged and does not block the snapshot;
+// the kernel still enforces the limit on writes.
+func (m btrfsManager) checkQuota(ctx context.Context, path string) error {
+	if m.quota == nil {
+		return nil
+	}
+	id := qgroupID(m.quota.quotaRoot(path))
+	output, err := btrfsQgroupShowFn(ctx, m.quota.root)
+	if err != nil {
+		log.Printf("btrfs: quota usage check failed qgroup=%s err=%v", id, err)
+		return nil
+	}
+	used, ok := parseQgroupReferenced(output, id)
+	if !ok {
+		return nil
+	}
+	if used >= m.quota.limit {
+		return fmt.Errorf("%w: qgroup %s uses %d of %d bytes", ErrQuotaExceeded, id, used, m.quota.limit)
+	}
+	return nil
+}
+
+func parseQgroupReferenced(output string, id string) (int64, bool) {
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] != id {
+			continue
+		}
+		value, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return 0, false
+		}
+		return value, true
+	}
+	return 0, false
+}
+
+// quotaError marks btrfs failures caused by an exhausted qgroup limit.
+func quotaError(err error) error {
+	if err == nil || errors.Is(err, ErrQuotaExceeded) {
+		return err
+	}
+	if errors.Is(err, syscall.EDQUOT) || strings.Contains(strings.ToLower(err.Error()), "disk quota exceeded") {
+		return fmt.Errorf("%w: %v", ErrQuotaExceeded, err)
+	}
+	return err
+}
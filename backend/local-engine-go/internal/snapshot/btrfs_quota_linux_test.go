@@ -0,0 +1,167 @@
+//go:build linux
+
+package snapshot
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func stubQgroupShow(t *testing.T, output string, err error) {
+	t.Helper()
+	prev := btrfsQgroupShowFn
+	btrfsQgroupShowFn = func(context.Context, string) (string, error) {
+		return output, err
+	}
+	t.Cleanup(func() { btrfsQgroupShowFn = prev })
+}
+
+func TestBtrfsQuotaSnapshotIssuesQgroupCommands(t *testing.T) {
+	stubQgroupShow(t, "", nil)
+	root := t.TempDir()
+	runner := &btrfsFakeRunner{}
+	mgr := btrfsManager{runner: runner}.withQuota(root, 1<<30)
+	src := filepath.Join(root, "ns", "team-a", "engines", "postgres", "17", "base")
+	if err := os.MkdirAll(src, 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	dest := filepath.Join(root, "ns", "team-a", "engines", "postgres", "17", "states", "state-1")
+
+	if err := mgr.Snapshot(context.Background(), src, dest); err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	id := qgroupID(filepath.Join(root, "ns", "team-a"))
+	want := [][]string{
+		{"quota", "enable", root},
+		{"qgroup", "create", id, root},
+		{"qgroup", "limit", "1073741824", id, root},
+		{"subvolume", "snapshot", "-r", "-i", id, src, dest},
+	}
+	if len(runner.calls) != len(want) {
+		t.Fatalf("unexpected calls: %+v", runner.calls)
+	}
+	for i, args := range want {
+		if runner.calls[i].name != "btrfs" || !equalArgs(runner.calls[i].args, args) {
+			t.Fatalf("call %d = %+v, want %v", i, runner.calls[i], args)
+		}
+	}
+
+	runner.calls = nil
+	if err := mgr.Snapshot(context.Background(), src, dest+"-2"); err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	if len(runner.calls) != 1 || runner.calls[0].args[0] != "subvolume" {
+		t.Fatalf("expected qgroup setup to run once, got %+v", runner.calls)
+	}
+}
+
+func TestBtrfsQuotaGroupsByNamespace(t *testing.T) {
+	q := &btrfsQuota{root: "/store"}
+	cases := map[string]string{
+		"/store/engines/postgres/17/base":           "/store",
+		"/store/ns/team-a/engines/postgres/17/base": "/store/ns/team-a",
+		"/store/ns/team-b/jobs/job-1/runtime":       "/store/ns/team-b",
+		"/store/ns":                                 "/store",
+		"/elsewhere/state":                          "/store",
+	}
+	for path, want := range cases {
+		if got := q.quotaRoot(path); got != want {
+			t.Fatalf("quotaRoot(%q) = %q, want %q", path, got, want)
+		}
+	}
+	if qgroupID("/store/ns/team-a") == qgroupID("/store/ns/team-b") {
+		t.Fatalf("expected distinct qgroups per namespace")
+	}
+}
+
+func TestBtrfsQuotaEnsureSubvolumeJoinsQgroup(t *testing.T) {
+	prevStat := osStatBtrfs
+	osStatBtrfs = func(string) (os.FileInfo, error) {
+		return nil, os.ErrNotExist
+	}
+	t.Cleanup(func() { osStatBtrfs = prevStat })
+
+	root := t.TempDir()
+	runner := &btrfsFakeRunner{}
+	mgr := btrfsManager{runner: runner}.withQuota(root, 4096).(btrfsManager)
+	path := filepath.Join(root, "engines", "postgres", "17", "base")
+	if err := mgr.EnsureSubvolume(context.Background(), path); err != nil {
+		t.Fatalf("EnsureSubvolume: %v", err)
+	}
+	last := runner.calls[len(runner.calls)-1]
+	if !equalArgs(last.args, []string{"subvolume", "create", "-i", qgroupID(root), path}) {
+		t.Fatalf("unexpected create args: %+v", last.args)
+	}
+}
+
+func TestBtrfsQuotaToleratesExistingQgroup(t *testing.T) {
+	stubQgroupShow(t, "", nil)
+	root := t.TempDir()
+	runner := &btrfsSequencedRunner{failOnCall: 2, err: errors.New("exit status 1: ERROR: unable to create quota group: File exists")}
+	mgr := btrfsManager{runner: runner}.withQuota(root, 4096)
+	src := filepath.Join(root, "base")
+	if err := os.MkdirAll(src, 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := mgr.Snapshot(context.Background(), src, filepath.Join(root, "states", "state-1")); err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	if len(runner.calls) != 4 {
+		t.Fatalf("unexpected calls: %+v", runner.calls)
+	}
+}
+
+func TestBtrfsQuotaSnapshotRefusedWhenLimitReached(t *testing.T) {
+	root := t.TempDir()
+	id := qgroupID(root)
+	stubQgroupShow(t, "qgroupid rfer excl max_rfer\n-------- ---- ---- --------\n0/5 16384 16384 none\n"+id+" 4096 4096 4096\n", nil)
+	runner := &btrfsFakeRunner{}
+	mgr := btrfsManager{runner: runner}.withQuota(root, 4096)
+	src := filepath.Join(root, "base")
+	if err := os.MkdirAll(src, 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+
+	err := mgr.Snapshot(context.Background(), src, filepath.Join(root, "states", "state-1"))
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("expected quota error, got %v", err)
+	}
+	if len(runner.calls) != 0 {
+		t.Fatalf("expected no btrfs calls, got %+v", runner.calls)
+	}
+}
+
+func TestBtrfsQuotaMapsDiskQuotaErrors(t *testing.T) {
+	stubQgroupShow(t, "", errors.New("quota not enabled"))
+	root := t.TempDir()
+	runner := &btrfsSequencedRunner{failOnCall: 4, err: errors.New("exit status 1: ERROR: cannot snapshot: Disk quota exceeded")}
+	mgr := btrfsManager{runner: runner}.withQuota(root, 4096)
+	src := filepath.Join(root, "base")
+	if err := os.MkdirAll(src, 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+
+	err := mgr.Snapshot(context.Background(), src, filepath.Join(root, "states", "state-1"))
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("expected quota error, got %v", err)
+	}
+}
+
+func TestBtrfsWithoutQuotaKeepsPlainCommands(t *testing.T) {
+	runner := &btrfsFakeRunner{}
+	mgr := btrfsManager{runner: runner}.withQuota("", 4096)
+	src := filepath.Join(t.TempDir(), "src")
+	if err := os.MkdirAll(src, 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	dest := filepath.Join(t.TempDir(), "state-1")
+	if _, err := mgr.Clone(context.Background(), src, dest); err != nil {
+		t.Fatalf("Clone: %v", err)
+	}
+	if len(runner.calls) != 1 || !equalArgs(runner.calls[0].args, []string{"subvolume", "snapshot", src, dest}) {
+		t.Fatalf("unexpected calls: %+v", runner.calls)
+	}
+}
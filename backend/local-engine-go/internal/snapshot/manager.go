@@ -28,6 +28,9 @@ type Options struct {
 	PreferOverlay  bool
 	Backend        string
 	StateStoreRoot string
+	// QuotaBytes caps each namespace of a btrfs state store with a qgroup
+	// limit. Zero disables quotas; non-btrfs backends ignore it.
+	QuotaBytes int64
 }
 
 func NewManager(opts Options) Manager {
@@ -47,14 +50,14 @@ func NewManager(opts Options) Manager {
 		return CopyManager{}
 	case "btrfs":
 		if btrfsSupportedFn(opts.StateStoreRoot) {
-			return newBtrfsManagerFn()
+			return applyQuota(newBtrfsManagerFn(), opts)
 		}
 		return CopyManager{}
 	case "copy":
 		return CopyManager{}
 	case "auto":
 		if btrfsSupportedFn(opts.StateStoreRoot) {
-			return newBtrfsManagerFn()
+			return applyQuota(newBtrfsManagerFn(), opts)
 		}
 		if overlaySupportedFn() {
 			return newOverlayManagerFn()
@@ -130,3 +130,14 @@ func (f fakeManager) Snapshot(ctx context.Context, srcDir string, destDir string
 func (f fakeManager) Destroy(ctx context.Context, dir string) error {
 	return nil
 }
+
+func TestNewManagerQuotaIgnoredByNonBtrfsBackends(t *testing.T) {
+	prevBtrfsSupported := btrfsSupportedFn
+	defer func() { btrfsSupportedFn = prevBtrfsSupported }()
+	btrfsSupportedFn = func(string) bool { return false }
+
+	mgr := NewManager(Options{Backend: "copy", StateStoreRoot: "root", QuotaBytes: 1024})
+	if _, ok := mgr.(CopyManager); !ok {
+		t.Fatalf("expected plain copy manager, got %T", mgr)
+	}
+}
@@ -0,0 +1,23 @@
+package snapshot
+
+import "errors"
+
+// ErrQuotaExceeded reports that a snapshot was refused because the btrfs
+// quota of its namespace (or of the whole state store) is used up.
+var ErrQuotaExceeded = errors.New("state store quota exceeded")
+
+type quotaLimiter interface {
+	withQuota(root string, limitBytes int64) Manager
+}
+
+// applyQuota enables quota enforcement on backends that support it. Other
+// backends ignore the setting.
+func applyQuota(mgr Manager, opts Options) Manager {
+	if opts.QuotaBytes <= 0 {
+		return mgr
+	}
+	if limiter, ok := mgr.(quotaLimiter); ok {
+		return limiter.withQuota(opts.StateStoreRoot, opts.QuotaBytes)
+	}
+	return mgr
+}
@@ -0,0 +1,119 @@
+package statefs
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ArchiveEntryPath joins the slash-separated archive entry name onto root for
+// extraction. Names that leave root are rejected, and so are entries whose
+// parent directories inside root are symlinks: a link unpacked from an earlier
+// entry must not redirect a later one. An existing symlink at the target
+// itself is rejected too, since writing through it would follow it.
+func ArchiveEntryPath(root, name string) (string, error) {
+	rel := path.Clean(name)
+	if rel == ".." || strings.HasPrefix(rel, "../") || path.IsAbs(rel) || filepath.IsAbs(filepath.FromSlash(rel)) {
+		return "", fmt.Errorf("invalid archive entry: %s", name)
+	}
+	target := root
+	if rel == "." {
+		return target, nil
+	}
+	for _, part := range strings.Split(rel, "/") {
+		target = filepath.Join(target, part)
+		info, err := os.Lstat(target)
+		if os.IsNotExist(err) {
+			// Nothing below a missing component can be a link yet.
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return "", fmt.Errorf("archive entry passes through a symlink: %s", name)
+		}
+	}
+	return filepath.Join(root, filepath.FromSlash(rel)), nil
+}
+
+// CheckArchiveLink rejects a symlink entry whose target is absolute or
+// leaves root when read relative to the link's own directory. Links that
+// reach outside through other links are caught by CheckArchiveLinks once the
+// whole archive is unpacked.
+func CheckArchiveLink(name, linkname string) error {
+	if linkname == "" || path.IsAbs(linkname) || filepath.IsAbs(linkname) || filepath.VolumeName(linkname) != "" {
+		return fmt.Errorf("invalid archive link target: %q", linkname)
+	}
+	resolved := path.Join(path.Dir(path.Clean(name)), filepath.ToSlash(linkname))
+	if resolved == ".." || strings.HasPrefix(resolved, "../") {
+		return fmt.Errorf("archive link leaves the extraction root: %s -> %s", name, linkname)
+	}
+	return nil
+}
+
+// maxArchiveLinkHops bounds link resolution like the kernel's ELOOP limit.
+const maxArchiveLinkHops = 40
+
+// CheckArchiveLinks resolves every symlink under root, following links within
+// the tree, and fails if one resolves outside root. It runs after extraction,
+// when no later entry can change what a link points at.
+func CheckArchiveLinks(root string) error {
+	return filepath.WalkDir(root, func(current string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type()&os.ModeSymlink == 0 {
+			return nil
+		}
+		rel, err := filepath.Rel(root, current)
+		if err != nil {
+			return err
+		}
+		if !archiveLinkConfined(root, filepath.ToSlash(rel)) {
+			return fmt.Errorf("archive link leaves the extraction root: %s", filepath.ToSlash(rel))
+		}
+		return nil
+	})
+}
+
+// archiveLinkConfined walks rel from root component by component, expanding
+// symlinks found on disk, and reports whether the walk stays inside root.
+// Missing components are taken literally.
+func archiveLinkConfined(root, rel string) bool {
+	pending := strings.Split(rel, "/")
+	var resolved []string
+	hops := 0
+	for len(pending) > 0 {
+		part := pending[0]
+		pending = pending[1:]
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			if len(resolved) == 0 {
+				return false
+			}
+			resolved = resolved[:len(resolved)-1]
+			continue
+		}
+		current := filepath.Join(root, filepath.Join(resolved...), part)
+		info, err := os.Lstat(current)
+		if err != nil || info.Mode()&os.ModeSymlink == 0 {
+			resolved = append(resolved, part)
+			continue
+		}
+		hops++
+		if hops > maxArchiveLinkHops {
+			return false
+		}
+		link, err := os.Readlink(current)
+		if err != nil || path.IsAbs(filepath.ToSlash(link)) || filepath.IsAbs(link) || filepath.VolumeName(link) != "" {
+			return false
+		}
+		pending = append(strings.Split(filepath.ToSlash(link), "/"), pending...)
+	}
+	return true
+}
@@ -0,0 +1,225 @@
+package statefs
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	checksumManifestName    = ".sqlrs-checksums.json"
+	checksumManifestVersion = 1
+	checksumBuildDirName    = ".build"
+)
+
+var (
+	// ErrChecksumMismatch reports a clone or state dir whose content differs
+	// from the manifest recorded when the state was created.
+	ErrChecksumMismatch = errors.New("state checksum mismatch")
+	// ErrNoChecksumManifest reports a state created without a manifest, for
+	// example while verification was disabled.
+	ErrNoChecksumManifest = errors.New("state checksum manifest not found")
+)
+
+// Checksummer is implemented by StateFS backends that can record a content
+// manifest for a state and check clones against it.
+type Checksummer interface {
+	WriteChecksums(ctx context.Context, stateDir string) error
+	VerifyChecksums(ctx context.Context, stateDir, cloneDir string) error
+}
+
+type checksumManifest struct {
+	Version int             `json:"version"`
+	Files   []checksumEntry `json:"files"`
+}
+
+type checksumEntry struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// WriteChecksums records the size and SHA-256 of every regular file in
+// stateDir. btrfs snapshots are read-only, so their manifest is kept next to
+// the build markers instead of inside the state.
+func (m *Manager) WriteChecksums(ctx context.Context, stateDir string) error {
+	return writeChecksumManifest(ctx, stateDir, m.checksumManifestPath(stateDir))
+}
+
+// VerifyChecksums checks cloneDir against the manifest of stateDir. Pass the
+// state dir itself as cloneDir to check the stored state.
+func (m *Manager) VerifyChecksums(ctx context.Context, stateDir, cloneDir string) error {
+	return verifyChecksumManifest(ctx, cloneDir, m.checksumManifestPath(stateDir))
+}
+
+func (m *Manager) checksumManifestPath(stateDir string) string {
+	if m.backend.Kind() == "btrfs" {
+		return filepath.Join(filepath.Dir(stateDir), checksumBuildDirName, filepath.Base(stateDir)+".checksums.json")
+	}
+	return filepath.Join(stateDir, checksumManifestName)
+}
+
+func writeChecksumManifest(ctx context.Context, dir, manifestPath string) error {
+	entries, err := checksumStateEntries(ctx, dir)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(checksumManifest{Version: checksumManifestVersion, Files: entries})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(manifestPath), 0o700); err != nil {
+		return err
+	}
+	tmp := manifestPath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, manifestPath)
+}
+
+// verifyChecksumManifest checks that every file listed in the manifest exists
+// in dir with the recorded size and digest. Extra files are ignored: Postgres
+// may add files on start, and a truncated copy shows up as missing or short.
+func verifyChecksumManifest(ctx context.Context, dir, manifestPath string) error {
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return ErrNoChecksumManifest
+		}
+		return err
+	}
+	var manifest checksumManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return fmt.Errorf("%w: unreadable manifest: %v", ErrChecksumMismatch, err)
+	}
+	if manifest.Version != checksumManifestVersion {
+		return fmt.Errorf("%w: unsupported manifest version %d", ErrChecksumMismatch, manifest.Version)
+	}
+	if isCompressedState(dir) {
+		return verifyChecksumArchive(ctx, dir, manifest)
+	}
+	for _, entry := range manifest.Files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		path := filepath.Join(dir, filepath.FromSlash(entry.Path))
+		info, err := os.Stat(path)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("%w: %s is missing", ErrChecksumMismatch, entry.Path)
+			}
+			return err
+		}
+		if info.Size() != entry.Size {
+			return fmt.Errorf("%w: %s has %d bytes, expected %d", ErrChecksumMismatch, entry.Path, info.Size(), entry.Size)
+		}
+		sum, err := fileSHA256(path)
+		if err != nil {
+			return err
+		}
+		if sum != entry.SHA256 {
+			return fmt.Errorf("%w: %s content differs", ErrChecksumMismatch, entry.Path)
+		}
+	}
+	return nil
+}
+
+// verifyChecksumArchive checks a compressed state against the manifest.
+func verifyChecksumArchive(ctx context.Context, dir string, manifest checksumManifest) error {
+	entries, err := checksumArchive(ctx, dir)
+	if err != nil {
+		return fmt.Errorf("%w: unreadable state archive: %v", ErrChecksumMismatch, err)
+	}
+	byPath := make(map[string]checksumEntry, len(entries))
+	for _, entry := range entries {
+		byPath[entry.Path] = entry
+	}
+	for _, want := range manifest.Files {
+		got, ok := byPath[want.Path]
+		if !ok {
+			return fmt.Errorf("%w: %s is missing", ErrChecksumMismatch, want.Path)
+		}
+		if got.Size != want.Size {
+			return fmt.Errorf("%w: %s has %d bytes, expected %d", ErrChecksumMismatch, want.Path, got.Size, want.Size)
+		}
+		if got.SHA256 != want.SHA256 {
+			return fmt.Errorf("%w: %s content differs", ErrChecksumMismatch, want.Path)
+		}
+	}
+	return nil
+}
+
+func checksumStateEntries(ctx context.Context, dir string) ([]checksumEntry, error) {
+	if isCompressedState(dir) {
+		return checksumArchive(ctx, dir)
+	}
+	return checksumDir(ctx, dir)
+}
+
+// checksumDir lists regular files under dir. Top-level dot files are engine
+// bookkeeping (build markers, locks, the manifest itself) and are skipped.
+func checksumDir(ctx context.Context, dir string) ([]checksumEntry, error) {
+	entries := []checksumEntry{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		if !strings.ContainsRune(rel, filepath.Separator) && strings.HasPrefix(rel, ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		sum, err := fileSHA256(path)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, checksumEntry{Path: filepath.ToSlash(rel), Size: info.Size(), SHA256: sum})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	return entries, nil
+}
+
+func fileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,112 @@
+package statefs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeChecksumFixture(t *testing.T, dir string) {
+	t.Helper()
+	files := map[string]string{
+		"PG_VERSION":      "17\n",
+		"base/1/1259":     "relation data",
+		"global/pg_class": "catalog",
+		".build.ok":       "ok",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+}
+
+func TestChecksumsDetectCorruptClone(t *testing.T) {
+	mgr := NewManager(Options{Backend: "copy"})
+	checksummer, ok := mgr.(Checksummer)
+	if !ok {
+		t.Fatalf("expected manager to implement Checksummer")
+	}
+	root := t.TempDir()
+	stateDir := filepath.Join(root, "state")
+	writeChecksumFixture(t, stateDir)
+	if err := checksummer.WriteChecksums(context.Background(), stateDir); err != nil {
+		t.Fatalf("WriteChecksums: %v", err)
+	}
+	if err := checksummer.VerifyChecksums(context.Background(), stateDir, stateDir); err != nil {
+		t.Fatalf("VerifyChecksums state: %v", err)
+	}
+
+	cloneDir := filepath.Join(root, "clone")
+	if _, err := mgr.Clone(context.Background(), stateDir, cloneDir); err != nil {
+		t.Fatalf("Clone: %v", err)
+	}
+	// Bookkeeping dot files and files added after the snapshot are not checked.
+	if err := os.WriteFile(filepath.Join(cloneDir, ".build.ok"), []byte("changed"), 0o600); err != nil {
+		t.Fatalf("write marker: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(cloneDir, "postmaster.opts"), []byte("extra"), 0o600); err != nil {
+		t.Fatalf("write extra: %v", err)
+	}
+	if err := checksummer.VerifyChecksums(context.Background(), stateDir, cloneDir); err != nil {
+		t.Fatalf("VerifyChecksums clone: %v", err)
+	}
+
+	relation := filepath.Join(cloneDir, "base", "1", "1259")
+	if err := os.Truncate(relation, 3); err != nil {
+		t.Fatalf("truncate: %v", err)
+	}
+	if err := checksummer.VerifyChecksums(context.Background(), stateDir, cloneDir); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected mismatch for truncated file, got %v", err)
+	}
+	if err := os.WriteFile(relation, []byte("relation DATA"), 0o600); err != nil {
+		t.Fatalf("rewrite: %v", err)
+	}
+	if err := checksummer.VerifyChecksums(context.Background(), stateDir, cloneDir); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected mismatch for changed content, got %v", err)
+	}
+	if err := os.Remove(relation); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if err := checksummer.VerifyChecksums(context.Background(), stateDir, cloneDir); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected mismatch for missing file, got %v", err)
+	}
+}
+
+func TestVerifyChecksumsWithoutManifest(t *testing.T) {
+	mgr := &Manager{backend: &fakeBackend{kind: "copy"}}
+	stateDir := filepath.Join(t.TempDir(), "state")
+	writeChecksumFixture(t, stateDir)
+	if err := mgr.VerifyChecksums(context.Background(), stateDir, stateDir); !errors.Is(err, ErrNoChecksumManifest) {
+		t.Fatalf("expected ErrNoChecksumManifest, got %v", err)
+	}
+}
+
+func TestChecksumManifestKeptOutsideBtrfsSnapshot(t *testing.T) {
+	root := t.TempDir()
+	stateDir := filepath.Join(root, "states", "state-1")
+	writeChecksumFixture(t, stateDir)
+	mgr := &Manager{backend: &fakeBackend{kind: "btrfs"}}
+	if err := mgr.WriteChecksums(context.Background(), stateDir); err != nil {
+		t.Fatalf("WriteChecksums: %v", err)
+	}
+	manifest := filepath.Join(root, "states", ".build", "state-1.checksums.json")
+	if _, err := os.Stat(manifest); err != nil {
+		t.Fatalf("expected manifest next to build markers: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(stateDir, checksumManifestName)); !os.IsNotExist(err) {
+		t.Fatalf("expected no manifest inside the snapshot, got %v", err)
+	}
+	if err := mgr.RemovePath(context.Background(), stateDir); err != nil {
+		t.Fatalf("RemovePath: %v", err)
+	}
+	if _, err := os.Stat(manifest); !os.IsNotExist(err) {
+		t.Fatalf("expected manifest removed with the state, got %v", err)
+	}
+}
@@ -0,0 +1,315 @@
+package statefs
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	CompressionNone = "none"
+	CompressionZstd = "zstd"
+
+	compressedStateName = ".sqlrs-state.tar.zst"
+	// pgVersionName stays uncompressed next to the archive so state checks
+	// that only look for PG_VERSION work without extracting the state.
+	pgVersionName = "PG_VERSION"
+)
+
+var (
+	zstdBinary   = "zstd"
+	execLookPath = exec.LookPath
+)
+
+// compressionEnabled reports whether new states are stored as archives. Only
+// the copy backend compresses; overlay and btrfs already share blocks.
+func (m *Manager) compressionEnabled() bool {
+	return m.compression == CompressionZstd && m.backend.Kind() == "copy"
+}
+
+func compressedStatePath(stateDir string) string {
+	return filepath.Join(stateDir, compressedStateName)
+}
+
+func isCompressedState(stateDir string) bool {
+	info, err := os.Stat(compressedStatePath(stateDir))
+	return err == nil && info.Mode().IsRegular()
+}
+
+// compressStateDir packs stateDir into a zstd tar archive inside the same dir
+// and removes the packed files. Top-level dot files are engine bookkeeping and
+// stay as they are.
+func compressStateDir(ctx context.Context, stateDir string) error {
+	if _, err := execLookPath(zstdBinary); err != nil {
+		log.Printf("statefs: %s not found, storing state uncompressed dir=%s", zstdBinary, stateDir)
+		return nil
+	}
+	archive := compressedStatePath(stateDir)
+	tmp := archive + ".tmp"
+	if err := writeStateArchive(ctx, stateDir, tmp); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, archive); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	entries, err := os.ReadDir(stateDir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") || name == pgVersionName {
+			continue
+		}
+		if err := removeAll(filepath.Join(stateDir, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeStateArchive(ctx context.Context, stateDir string, archivePath string) error {
+	out, err := os.OpenFile(archivePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	cmd := exec.CommandContext(ctx, zstdBinary, "-q", "-c", "-")
+	cmd.Stdout = out
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	writeErr := writeStateTar(ctx, stateDir, stdin)
+	closeErr := stdin.Close()
+	waitErr := cmd.Wait()
+	if writeErr != nil {
+		return writeErr
+	}
+	if closeErr != nil {
+		return closeErr
+	}
+	if waitErr != nil {
+		return fmt.Errorf("%s compress failed: %w: %s", zstdBinary, waitErr, strings.TrimSpace(stderr.String()))
+	}
+	return out.Close()
+}
+
+func writeStateTar(ctx context.Context, stateDir string, w io.Writer) error {
+	tw := tar.NewWriter(w)
+	err := filepath.WalkDir(stateDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(stateDir, path)
+		if err != nil {
+			return err
+		}
+		if rel != "." && !strings.ContainsRune(rel, filepath.Separator) && strings.HasPrefix(rel, ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		link := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		if d.IsDir() {
+			header.Name += "/"
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(tw, file)
+		file.Close()
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// readStateArchive streams the archive of stateDir through fn, one entry at a
+// time.
+func readStateArchive(ctx context.Context, stateDir string, fn func(header *tar.Header, r io.Reader) error) error {
+	in, err := os.Open(compressedStatePath(stateDir))
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	cmd := exec.CommandContext(ctx, zstdBinary, "-q", "-d", "-c", "-")
+	cmd.Stdin = in
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	readErr := func() error {
+		tr := tar.NewReader(stdout)
+		for {
+			header, err := tr.Next()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := fn(header, tr); err != nil {
+				return err
+			}
+		}
+	}()
+	if readErr != nil {
+		_ = cmd.Process.Kill()
+	} else {
+		// Drain trailing padding so zstd does not fail on a closed pipe.
+		_, _ = io.Copy(io.Discard, stdout)
+	}
+	waitErr := cmd.Wait()
+	if readErr != nil {
+		return readErr
+	}
+	if waitErr != nil {
+		return fmt.Errorf("%s decompress failed: %w: %s", zstdBinary, waitErr, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// extractState unpacks a compressed state into destDir with the recorded
+// permissions, so Postgres accepts the data dir.
+func extractState(ctx context.Context, stateDir string, destDir string) error {
+	info, err := os.Stat(stateDir)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(destDir, info.Mode().Perm()); err != nil {
+		return err
+	}
+	type dirMode struct {
+		path string
+		mode os.FileMode
+	}
+	var dirs []dirMode
+	err = readStateArchive(ctx, stateDir, func(header *tar.Header, r io.Reader) error {
+		target, err := ArchiveEntryPath(destDir, header.Name)
+		if err != nil {
+			return fmt.Errorf("invalid state archive entry: %w", err)
+		}
+		mode := os.FileMode(header.Mode).Perm()
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0o700); err != nil {
+				return err
+			}
+			dirs = append(dirs, dirMode{path: target, mode: mode})
+		case tar.TypeSymlink:
+			if err := CheckArchiveLink(header.Name, header.Linkname); err != nil {
+				return fmt.Errorf("invalid state archive entry %s: %w", header.Name, err)
+			}
+			return os.Symlink(header.Linkname, target)
+		case tar.TypeLink:
+			// State archives are written without hard links.
+			return fmt.Errorf("unsupported state archive entry: %s", header.Name)
+		case tar.TypeReg:
+			out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(out, r); err != nil {
+				out.Close()
+				return err
+			}
+			if err := out.Close(); err != nil {
+				return err
+			}
+			return os.Chmod(target, mode)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := CheckArchiveLinks(destDir); err != nil {
+		return fmt.Errorf("invalid state archive: %w", err)
+	}
+	// Directory modes are applied last so read-only dirs can still be filled.
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := os.Chmod(dirs[i].path, dirs[i].mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checksumArchive lists the regular files of a compressed state like
+// checksumDir does for a plain one.
+func checksumArchive(ctx context.Context, stateDir string) ([]checksumEntry, error) {
+	entries := []checksumEntry{}
+	err := readStateArchive(ctx, stateDir, func(header *tar.Header, r io.Reader) error {
+		if header.Typeflag != tar.TypeReg {
+			return nil
+		}
+		hash := sha256.New()
+		size, err := io.Copy(hash, r)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, checksumEntry{
+			Path:   path.Clean(header.Name),
+			Size:   size,
+			SHA256: hex.EncodeToString(hash.Sum(nil)),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	return entries, nil
+}
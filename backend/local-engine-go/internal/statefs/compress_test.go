@@ -0,0 +1,302 @@
+package statefs
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func requireZstd(tb testing.TB) {
+	tb.Helper()
+	if _, err := exec.LookPath(zstdBinary); err != nil {
+		tb.Skipf("%s not available: %v", zstdBinary, err)
+	}
+}
+
+func writeStateFixture(tb testing.TB, dir string) {
+	tb.Helper()
+	if err := os.MkdirAll(filepath.Join(dir, "base", "1"), 0o700); err != nil {
+		tb.Fatalf("mkdir: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "pg_wal"), 0o700); err != nil {
+		tb.Fatalf("mkdir: %v", err)
+	}
+	files := map[string]string{
+		"PG_VERSION":  "17\n",
+		"base/1/1259": "relation data",
+		".build.ok":   "ok",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, filepath.FromSlash(name)), []byte(content), 0o600); err != nil {
+			tb.Fatalf("write %s: %v", name, err)
+		}
+	}
+	if err := os.Symlink("../base", filepath.Join(dir, "pg_wal", "base")); err != nil {
+		tb.Fatalf("symlink: %v", err)
+	}
+	if err := os.Chmod(dir, 0o700); err != nil {
+		tb.Fatalf("chmod: %v", err)
+	}
+}
+
+func TestSnapshotCompressesCopyState(t *testing.T) {
+	requireZstd(t)
+	mgr := NewManager(Options{Backend: "copy", Compression: CompressionZstd})
+	root := t.TempDir()
+	srcDir := filepath.Join(root, "runtime")
+	writeStateFixture(t, srcDir)
+	stateDir := filepath.Join(root, "state")
+	if err := mgr.Snapshot(context.Background(), srcDir, stateDir); err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	if !isCompressedState(stateDir) {
+		t.Fatalf("expected compressed state archive")
+	}
+	entries, err := os.ReadDir(stateDir)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if fmt.Sprint(names) != fmt.Sprint([]string{".build.ok", compressedStateName, "PG_VERSION"}) {
+		t.Fatalf("unexpected state dir contents: %v", names)
+	}
+
+	cloneDir := filepath.Join(root, "clone")
+	clone, err := mgr.Clone(context.Background(), stateDir, cloneDir)
+	if err != nil {
+		t.Fatalf("Clone: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(clone.MountDir, "base", "1", "1259"))
+	if err != nil || string(data) != "relation data" {
+		t.Fatalf("unexpected relation file: %q err=%v", data, err)
+	}
+	for path, want := range map[string]os.FileMode{
+		clone.MountDir: 0o700,
+		filepath.Join(clone.MountDir, "base", "1"):         0o700,
+		filepath.Join(clone.MountDir, "base", "1", "1259"): 0o600,
+	} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("stat %s: %v", path, err)
+		}
+		if info.Mode().Perm() != want {
+			t.Fatalf("expected %s mode %v, got %v", path, want, info.Mode().Perm())
+		}
+	}
+	if link, err := os.Readlink(filepath.Join(clone.MountDir, "pg_wal", "base")); err != nil || link != "../base" {
+		t.Fatalf("unexpected symlink %q err=%v", link, err)
+	}
+	if _, err := os.Stat(filepath.Join(clone.MountDir, ".build.ok")); !os.IsNotExist(err) {
+		t.Fatalf("expected bookkeeping files to stay out of the clone, got %v", err)
+	}
+	if err := clone.Cleanup(); err != nil {
+		t.Fatalf("Cleanup: %v", err)
+	}
+	if _, err := os.Stat(cloneDir); !os.IsNotExist(err) {
+		t.Fatalf("expected clone removed, got %v", err)
+	}
+}
+
+func TestCompressionOnlyAppliesToCopyBackend(t *testing.T) {
+	for _, kind := range []string{"overlay", "btrfs"} {
+		mgr := &Manager{backend: &fakeBackend{kind: kind}, compression: CompressionZstd}
+		if mgr.compressionEnabled() {
+			t.Fatalf("expected compression disabled for %s", kind)
+		}
+	}
+	if (&Manager{backend: &fakeBackend{kind: "copy"}}).compressionEnabled() {
+		t.Fatalf("expected compression disabled by default")
+	}
+}
+
+func TestSnapshotWithoutZstdStoresPlainState(t *testing.T) {
+	prev := execLookPath
+	execLookPath = func(string) (string, error) { return "", exec.ErrNotFound }
+	t.Cleanup(func() { execLookPath = prev })
+
+	mgr := NewManager(Options{Backend: "copy", Compression: CompressionZstd})
+	root := t.TempDir()
+	srcDir := filepath.Join(root, "runtime")
+	writeStateFixture(t, srcDir)
+	stateDir := filepath.Join(root, "state")
+	if err := mgr.Snapshot(context.Background(), srcDir, stateDir); err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	if isCompressedState(stateDir) {
+		t.Fatalf("expected plain state without zstd")
+	}
+	if _, err := os.Stat(filepath.Join(stateDir, "base", "1", "1259")); err != nil {
+		t.Fatalf("expected state files in place: %v", err)
+	}
+}
+
+func TestChecksumsOnCompressedState(t *testing.T) {
+	requireZstd(t)
+	mgr := NewManager(Options{Backend: "copy", Compression: CompressionZstd})
+	checksummer := mgr.(Checksummer)
+	root := t.TempDir()
+	srcDir := filepath.Join(root, "runtime")
+	writeStateFixture(t, srcDir)
+	stateDir := filepath.Join(root, "state")
+	if err := mgr.Snapshot(context.Background(), srcDir, stateDir); err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	if err := checksummer.WriteChecksums(context.Background(), stateDir); err != nil {
+		t.Fatalf("WriteChecksums: %v", err)
+	}
+	if err := checksummer.VerifyChecksums(context.Background(), stateDir, stateDir); err != nil {
+		t.Fatalf("VerifyChecksums state: %v", err)
+	}
+	clone, err := mgr.Clone(context.Background(), stateDir, filepath.Join(root, "clone"))
+	if err != nil {
+		t.Fatalf("Clone: %v", err)
+	}
+	if err := checksummer.VerifyChecksums(context.Background(), stateDir, clone.MountDir); err != nil {
+		t.Fatalf("VerifyChecksums clone: %v", err)
+	}
+
+	archive := compressedStatePath(stateDir)
+	info, err := os.Stat(archive)
+	if err != nil {
+		t.Fatalf("stat archive: %v", err)
+	}
+	if err := os.Truncate(archive, info.Size()/2); err != nil {
+		t.Fatalf("truncate: %v", err)
+	}
+	if err := checksummer.VerifyChecksums(context.Background(), stateDir, stateDir); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected mismatch for truncated archive, got %v", err)
+	}
+	if _, err := mgr.Clone(context.Background(), stateDir, filepath.Join(root, "clone-2")); err == nil {
+		t.Fatalf("expected clone of truncated archive to fail")
+	}
+}
+
+// writeCompressedArchive stores a hand-built tar as the compressed archive of
+// stateDir, so extraction can be fed entries the engine never writes.
+func writeCompressedArchive(t *testing.T, stateDir string, entries []tar.Header) {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, header := range entries {
+		header := header
+		if header.Typeflag == tar.TypeReg {
+			header.Size = int64(len("pwned"))
+		}
+		if err := tw.WriteHeader(&header); err != nil {
+			t.Fatalf("header: %v", err)
+		}
+		if header.Typeflag == tar.TypeReg {
+			_, _ = tw.Write([]byte("pwned"))
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if err := os.MkdirAll(stateDir, 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	cmd := exec.Command(zstdBinary, "-q", "-c", "-")
+	cmd.Stdin = &buf
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("zstd: %v", err)
+	}
+	if err := os.WriteFile(compressedStatePath(stateDir), out, 0o600); err != nil {
+		t.Fatalf("write archive: %v", err)
+	}
+}
+
+func TestExtractStateRejectsEscapingLinks(t *testing.T) {
+	requireZstd(t)
+	cases := map[string][]tar.Header{
+		"absolute link": {
+			{Name: "evil", Typeflag: tar.TypeSymlink, Linkname: "/etc"},
+		},
+		"relative link": {
+			{Name: "base/evil", Typeflag: tar.TypeSymlink, Linkname: "../../outside"},
+		},
+		"link through link": {
+			{Name: "d/", Typeflag: tar.TypeDir, Mode: 0o700},
+			{Name: "d/up", Typeflag: tar.TypeSymlink, Linkname: ".."},
+			{Name: "evil", Typeflag: tar.TypeSymlink, Linkname: "d/up/.."},
+		},
+		"write through link": {
+			{Name: "d/", Typeflag: tar.TypeDir, Mode: 0o700},
+			{Name: "d/up", Typeflag: tar.TypeSymlink, Linkname: ".."},
+			{Name: "x", Typeflag: tar.TypeSymlink, Linkname: "d/up/.."},
+			{Name: "x/pwned", Typeflag: tar.TypeReg, Mode: 0o600},
+		},
+		"hard link": {
+			{Name: "evil", Typeflag: tar.TypeLink, Linkname: "../../outside"},
+		},
+	}
+	for name, entries := range cases {
+		root := t.TempDir()
+		stateDir := filepath.Join(root, "state")
+		writeCompressedArchive(t, stateDir, entries)
+		destDir := filepath.Join(root, "sandbox", "clone")
+		if err := extractState(context.Background(), stateDir, destDir); err == nil {
+			t.Fatalf("%s: expected extraction to fail", name)
+		}
+		if _, err := os.Stat(filepath.Join(root, "sandbox", "pwned")); !os.IsNotExist(err) {
+			t.Fatalf("%s: expected nothing written outside the clone, got %v", name, err)
+		}
+	}
+
+	// Links that stay inside the tree, even through other links, are kept.
+	root := t.TempDir()
+	stateDir := filepath.Join(root, "state")
+	writeCompressedArchive(t, stateDir, []tar.Header{
+		{Name: "base/", Typeflag: tar.TypeDir, Mode: 0o700},
+		{Name: "pg_wal/", Typeflag: tar.TypeDir, Mode: 0o700},
+		{Name: "pg_wal/base", Typeflag: tar.TypeSymlink, Linkname: "../base"},
+		{Name: "wal", Typeflag: tar.TypeSymlink, Linkname: "pg_wal/base/.."},
+	})
+	if err := extractState(context.Background(), stateDir, filepath.Join(root, "clone")); err != nil {
+		t.Fatalf("expected confined links to extract: %v", err)
+	}
+}
+
+// BenchmarkClone compares cloning a plain copy state with extracting a
+// compressed one. Run with: go test ./internal/statefs -run '^$' -bench Clone
+func BenchmarkClone(b *testing.B) {
+	requireZstd(b)
+	for _, compression := range []string{CompressionNone, CompressionZstd} {
+		b.Run(compression, func(b *testing.B) {
+			mgr := NewManager(Options{Backend: "copy", Compression: compression})
+			root := b.TempDir()
+			srcDir := filepath.Join(root, "runtime")
+			writeStateFixture(b, srcDir)
+			// 64 MiB of relation pages with a repetitive, compressible layout.
+			page := bytes.Repeat([]byte("sqlrs-relation-page"), 8192/19+1)[:8192]
+			for i := 0; i < 64; i++ {
+				if err := os.WriteFile(filepath.Join(srcDir, "base", "1", fmt.Sprintf("%d", 16384+i)), bytes.Repeat(page, 128), 0o600); err != nil {
+					b.Fatalf("write: %v", err)
+				}
+			}
+			stateDir := filepath.Join(root, "state")
+			if err := mgr.Snapshot(context.Background(), srcDir, stateDir); err != nil {
+				b.Fatalf("Snapshot: %v", err)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				clone, err := mgr.Clone(context.Background(), stateDir, filepath.Join(root, fmt.Sprintf("clone-%d", i)))
+				if err != nil {
+					b.Fatalf("Clone: %v", err)
+				}
+				b.StopTimer()
+				_ = clone.Cleanup()
+				b.StartTimer()
+			}
+		})
+	}
+}
@@ -6,6 +6,16 @@ import (
 	"strings"
 )
 
+// NamespaceRoot returns the state store root for a namespace. The default
+// (empty) namespace maps to root itself so existing layouts stay unchanged.
+func NamespaceRoot(root, namespace string) string {
+	namespace = strings.TrimSpace(namespace)
+	if namespace == "" {
+		return root
+	}
+	return filepath.Join(root, "ns", namespace)
+}
+
 func baseDir(root, imageID string) (string, error) {
 	if strings.TrimSpace(root) == "" {
 		return "", fmt.Errorf("state store root is required")
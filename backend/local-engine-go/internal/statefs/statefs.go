@@ -41,10 +41,19 @@ type Options struct {
 	PreferOverlay  bool
 	Backend        string
 	StateStoreRoot string
+	// Compression stores copy-backend states as archives ("none" or "zstd").
+	Compression string
+	// QuotaBytes limits each namespace of a btrfs state store (0 = off).
+	QuotaBytes int64
 }
 
+// ErrQuotaExceeded is returned by Snapshot when the btrfs quota of the
+// target namespace is exhausted.
+var ErrQuotaExceeded = snapshot.ErrQuotaExceeded
+
 type Manager struct {
-	backend snapshot.Manager
+	backend     snapshot.Manager
+	compression string
 }
 
 var removeAll = os.RemoveAll
@@ -55,7 +64,9 @@ func NewManager(opts Options) StateFS {
 			PreferOverlay:  opts.PreferOverlay,
 			Backend:        opts.Backend,
 			StateStoreRoot: opts.StateStoreRoot,
+			QuotaBytes:     opts.QuotaBytes,
 		}),
+		compression: opts.Compression,
 	}
 }
 
@@ -110,6 +121,18 @@ func (m *Manager) EnsureStateDir(ctx context.Context, stateDir string) error {
 }
 
 func (m *Manager) Clone(ctx context.Context, srcDir, destDir string) (CloneResult, error) {
+	if isCompressedState(srcDir) {
+		if err := extractState(ctx, srcDir, destDir); err != nil {
+			_ = removeAll(destDir)
+			return CloneResult{}, err
+		}
+		return CloneResult{
+			MountDir: destDir,
+			Cleanup: func() error {
+				return removeAll(destDir)
+			},
+		}, nil
+	}
 	res, err := m.backend.Clone(ctx, srcDir, destDir)
 	if err != nil {
 		return CloneResult{}, err
@@ -118,7 +141,13 @@ func (m *Manager) Clone(ctx context.Context, srcDir, destDir string) (CloneResul
 }
 
 func (m *Manager) Snapshot(ctx context.Context, srcDir, destDir string) error {
-	return m.backend.Snapshot(ctx, srcDir, destDir)
+	if err := m.backend.Snapshot(ctx, srcDir, destDir); err != nil {
+		return err
+	}
+	if m.compressionEnabled() {
+		return compressStateDir(ctx, destDir)
+	}
+	return nil
 }
 
 func (m *Manager) RemovePath(ctx context.Context, path string) error {
@@ -127,6 +156,7 @@ func (m *Manager) RemovePath(ctx context.Context, path string) error {
 		return nil
 	}
 	if m.backend.Kind() == "btrfs" {
+		defer os.Remove(m.checksumManifestPath(path))
 		if checker, ok := m.backend.(subvolumeChecker); ok {
 			if isSub, err := checker.IsSubvolume(ctx, path); err == nil && isSub {
 				if err := m.backend.Destroy(ctx, path); err != nil {
@@ -124,6 +124,20 @@ func TestDirBuildersErrors(t *testing.T) {
 	}
 }
 
+func TestNamespaceRoot(t *testing.T) {
+	root := filepath.Join("var", "state-store")
+	if got := NamespaceRoot(root, ""); got != root {
+		t.Fatalf("expected default namespace to keep root, got %s", got)
+	}
+	if got := NamespaceRoot(root, "  "); got != root {
+		t.Fatalf("expected blank namespace to keep root, got %s", got)
+	}
+	want := filepath.Join(root, "ns", "team-a")
+	if got := NamespaceRoot(root, "team-a"); got != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+}
+
 func TestEnsureDirsUsesSubvolumeEnsurer(t *testing.T) {
 	root := t.TempDir()
 	base := filepath.Join(root, "base")
@@ -44,6 +44,9 @@ func TestOpenMigratesParentStateColumn(t *testing.T) {
 	if !hasColumn(t, verifyDB, "instances", "runtime_dir") {
 		t.Fatalf("expected runtime_dir column after migration")
 	}
+	if !hasColumn(t, verifyDB, "states", "task_hash") {
+		t.Fatalf("expected task_hash column after migration")
+	}
 }
 
 func seedLegacySchema(db *sql.DB) error {
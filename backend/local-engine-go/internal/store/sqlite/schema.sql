@@ -14,12 +14,15 @@ CREATE TABLE IF NOT EXISTS states (
   min_retention_until TEXT,
   evicted_at TEXT,
   eviction_reason TEXT,
-  status TEXT
+  status TEXT,
+  namespace TEXT,
+  task_hash TEXT
 );
 CREATE UNIQUE INDEX IF NOT EXISTS idx_states_fingerprint ON states(state_fingerprint);
 CREATE INDEX IF NOT EXISTS idx_states_parent ON states(parent_state_id);
 CREATE INDEX IF NOT EXISTS idx_states_image ON states(image_id);
 CREATE INDEX IF NOT EXISTS idx_states_kind ON states(prepare_kind);
+CREATE INDEX IF NOT EXISTS idx_states_namespace ON states(namespace);
 
 CREATE TABLE IF NOT EXISTS instances (
   instance_id TEXT PRIMARY KEY,
@@ -29,6 +32,7 @@ CREATE TABLE IF NOT EXISTS instances (
   expires_at TEXT,
   runtime_id TEXT,
   runtime_dir TEXT,
+  instance_mode TEXT,
   status TEXT,
   FOREIGN KEY(state_id) REFERENCES states(state_id)
 );
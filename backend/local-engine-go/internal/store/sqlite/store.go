@@ -8,6 +8,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	_ "modernc.org/sqlite"
@@ -17,6 +18,8 @@ import (
 
 type Store struct {
 	db *sql.DB
+	// generation advances after every write to states or instances.
+	generation atomic.Uint64
 }
 
 func Open(path string) (*Store, error) {
@@ -54,12 +57,16 @@ func (s *Store) Close() error {
 	return s.db.Close()
 }
 
+// Generation returns a counter that changes whenever the store is written.
+// List handlers use it to answer conditional requests without reading the
+// tables. It is not persisted.
+func (s *Store) Generation() uint64 {
+	return s.generation.Load()
+}
+
 func (s *Store) ListNames(ctx context.Context, filters store.NameFilters) ([]store.NameEntry, error) {
 	query := strings.Builder{}
-	query.WriteString(`
-SELECT n.name, n.instance_id, n.image_id, n.state_id, n.state_fingerprint, n.last_used_at, i.expires_at, i.instance_id
-FROM names n
-LEFT JOIN instances i ON i.instance_id = n.instance_id
+	query.WriteString(nameSelect + `
 WHERE 1=1`)
 	args := []any{}
 	addFilter(&query, &args, "n.instance_id", filters.InstanceID)
@@ -74,40 +81,10 @@ WHERE 1=1`)
 	now := time.Now().UTC()
 	var out []store.NameEntry
 	for rows.Next() {
-		var name string
-		var instanceID sql.NullString
-		var imageID string
-		var stateID sql.NullString
-		var stateFingerprint string
-		var lastUsedAt sql.NullString
-		var expiresAt sql.NullString
-		var joinedInstanceID sql.NullString
-		if err := rows.Scan(&name, &instanceID, &imageID, &stateID, &stateFingerprint, &lastUsedAt, &expiresAt, &joinedInstanceID); err != nil {
+		entry, err := scanName(rows, now)
+		if err != nil {
 			return nil, err
 		}
-		entry := store.NameEntry{
-			Name:             name,
-			ImageID:          imageID,
-			StateFingerprint: stateFingerprint,
-			Status:           store.NameStatusActive,
-		}
-		if instanceID.Valid {
-			entry.InstanceID = strPtr(instanceID.String)
-		}
-		if lastUsedAt.Valid {
-			entry.LastUsedAt = strPtr(lastUsedAt.String)
-		}
-		entry.StateID = stateFingerprint
-		if stateID.Valid && stateID.String != "" {
-			entry.StateID = stateID.String
-		}
-		if instanceID.Valid && joinedInstanceID.Valid {
-			if isExpired(expiresAt, now) {
-				entry.Status = store.NameStatusExpired
-			}
-		} else {
-			entry.Status = store.NameStatusMissing
-		}
 		out = append(out, entry)
 	}
 	if err := rows.Err(); err != nil {
@@ -117,12 +94,8 @@ WHERE 1=1`)
 }
 
 func (s *Store) GetName(ctx context.Context, name string) (store.NameEntry, bool, error) {
-	query := `
-SELECT n.name, n.instance_id, n.image_id, n.state_id, n.state_fingerprint, n.last_used_at, i.expires_at, i.instance_id
-FROM names n
-LEFT JOIN instances i ON i.instance_id = n.instance_id
-WHERE n.name = ?`
-	row := s.db.QueryRowContext(ctx, query, name)
+	row := s.db.QueryRowContext(ctx, nameSelect+`
+WHERE n.name = ?`, name)
 	now := time.Now().UTC()
 	entry, err := scanName(row, now)
 	if err != nil {
@@ -134,18 +107,79 @@ WHERE n.name = ?`
 	return entry, true, nil
 }
 
+// nameSelect joins a name with its instance and, for state tags, its state,
+// so scanName can tell live names from dangling ones.
+const nameSelect = `
+SELECT n.name, n.instance_id, n.image_id, n.state_id, n.state_fingerprint, n.last_used_at, i.expires_at, i.instance_id, st.state_id
+FROM names n
+LEFT JOIN instances i ON i.instance_id = n.instance_id
+LEFT JOIN states st ON st.state_id = n.state_id`
+
+// TagState points name at stateID, creating the name or re-pointing an
+// existing state tag. ok is false when the state does not exist; a name that
+// belongs to an instance is left alone and store.ErrNameInUse is returned.
+func (s *Store) TagState(ctx context.Context, name string, stateID string) (store.NameEntry, bool, error) {
+	defer s.generation.Add(1)
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return store.NameEntry{}, false, err
+	}
+	var fingerprint string
+	var imageID string
+	err = tx.QueryRowContext(ctx, `SELECT COALESCE(state_fingerprint, state_id), image_id FROM states WHERE state_id = ?`, stateID).Scan(&fingerprint, &imageID)
+	if err != nil {
+		_ = tx.Rollback()
+		if errors.Is(err, sql.ErrNoRows) {
+			return store.NameEntry{}, false, nil
+		}
+		return store.NameEntry{}, false, err
+	}
+	var instanceID sql.NullString
+	err = tx.QueryRowContext(ctx, `SELECT instance_id FROM names WHERE name = ?`, name).Scan(&instanceID)
+	switch {
+	case err == nil && instanceID.Valid:
+		_ = tx.Rollback()
+		return store.NameEntry{}, false, store.ErrNameInUse
+	case err != nil && !errors.Is(err, sql.ErrNoRows):
+		_ = tx.Rollback()
+		return store.NameEntry{}, false, err
+	}
+	if _, err := tx.ExecContext(ctx, `
+INSERT INTO names (name, instance_id, state_id, state_fingerprint, image_id, last_used_at, is_primary)
+VALUES (?, NULL, ?, ?, ?, NULL, 0)
+ON CONFLICT(name) DO UPDATE SET
+  state_id = excluded.state_id,
+  state_fingerprint = excluded.state_fingerprint,
+  image_id = excluded.image_id`,
+		name, stateID, fingerprint, imageID,
+	); err != nil {
+		_ = tx.Rollback()
+		return store.NameEntry{}, false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return store.NameEntry{}, false, err
+	}
+	entry, ok, err := s.GetName(ctx, name)
+	if err != nil {
+		return store.NameEntry{}, false, err
+	}
+	return entry, ok, nil
+}
+
 func (s *Store) ListInstances(ctx context.Context, filters store.InstanceFilters) ([]store.InstanceEntry, error) {
 	query := strings.Builder{}
 	query.WriteString(`
-SELECT i.instance_id, i.image_id, i.state_id, i.created_at, i.expires_at, i.runtime_id, i.runtime_dir,
+SELECT i.instance_id, i.image_id, i.state_id, i.created_at, i.expires_at, i.runtime_id, i.runtime_dir, i.instance_mode,
        pn.name,
        (SELECT COUNT(1) FROM names n WHERE n.instance_id = i.instance_id) as name_count
 FROM instances i
 LEFT JOIN names pn ON pn.instance_id = i.instance_id AND pn.is_primary = 1
+LEFT JOIN states ns ON ns.state_id = i.state_id
 WHERE 1=1`)
 	args := []any{}
 	addFilter(&query, &args, "i.state_id", filters.StateID)
 	addFilter(&query, &args, "i.image_id", filters.ImageID)
+	addFilter(&query, &args, "COALESCE(ns.namespace, '')", filters.Namespace)
 	addPrefixFilter(&query, &args, "i.instance_id", filters.IDPrefix)
 	rows, err := s.db.QueryContext(ctx, query.String(), args...)
 	if err != nil {
@@ -170,7 +204,7 @@ WHERE 1=1`)
 
 func (s *Store) GetInstance(ctx context.Context, instanceID string) (store.InstanceEntry, bool, error) {
 	query := `
-SELECT i.instance_id, i.image_id, i.state_id, i.created_at, i.expires_at, i.runtime_id, i.runtime_dir,
+SELECT i.instance_id, i.image_id, i.state_id, i.created_at, i.expires_at, i.runtime_id, i.runtime_dir, i.instance_mode,
        pn.name,
        (SELECT COUNT(1) FROM names n WHERE n.instance_id = i.instance_id) as name_count
 FROM instances i
@@ -191,14 +225,16 @@ func (s *Store) ListStates(ctx context.Context, filters store.StateFilters) ([]s
 	query := strings.Builder{}
 	query.WriteString(`
 SELECT s.state_id, s.parent_state_id, s.image_id, s.prepare_kind, s.prepare_args_normalized, s.created_at, s.size_bytes,
-       s.last_used_at, s.use_count, s.min_retention_until,
-       (SELECT COUNT(1) FROM instances i WHERE i.state_id = s.state_id) as refcount
+       s.last_used_at, s.use_count, s.min_retention_until, COALESCE(s.namespace, ''),
+       (SELECT COUNT(1) FROM instances i WHERE i.state_id = s.state_id) as refcount,
+       COALESCE(s.task_hash, '')
 FROM states s
 WHERE 1=1`)
 	args := []any{}
 	addFilter(&query, &args, "s.prepare_kind", filters.Kind)
 	addFilter(&query, &args, "s.image_id", filters.ImageID)
 	addFilter(&query, &args, "s.parent_state_id", filters.ParentID)
+	addFilter(&query, &args, "COALESCE(s.namespace, '')", filters.Namespace)
 	addPrefixFilter(&query, &args, "s.state_id", filters.IDPrefix)
 	rows, err := s.db.QueryContext(ctx, query.String(), args...)
 	if err != nil {
@@ -224,7 +260,9 @@ WHERE 1=1`)
 			&lastUsedAt,
 			&useCount,
 			&minRetentionUntil,
+			&entry.Namespace,
 			&entry.RefCount,
+			&entry.TaskHash,
 		); err != nil {
 			return nil, err
 		}
@@ -251,8 +289,9 @@ WHERE 1=1`)
 func (s *Store) GetState(ctx context.Context, stateID string) (store.StateEntry, bool, error) {
 	query := `
 SELECT s.state_id, s.parent_state_id, s.image_id, s.prepare_kind, s.prepare_args_normalized, s.created_at, s.size_bytes,
-       s.last_used_at, s.use_count, s.min_retention_until,
-       (SELECT COUNT(1) FROM instances i WHERE i.state_id = s.state_id) as refcount
+       s.last_used_at, s.use_count, s.min_retention_until, COALESCE(s.namespace, ''),
+       (SELECT COUNT(1) FROM instances i WHERE i.state_id = s.state_id) as refcount,
+       COALESCE(s.task_hash, '')
 FROM states s
 WHERE s.state_id = ?`
 	row := s.db.QueryRowContext(ctx, query, stateID)
@@ -272,7 +311,9 @@ WHERE s.state_id = ?`
 		&lastUsedAt,
 		&useCount,
 		&minRetentionUntil,
+		&entry.Namespace,
 		&entry.RefCount,
+		&entry.TaskHash,
 	); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return store.StateEntry{}, false, nil
@@ -295,12 +336,13 @@ WHERE s.state_id = ?`
 }
 
 func (s *Store) CreateState(ctx context.Context, entry store.StateCreate) error {
+	defer s.generation.Add(1)
 	query := `
 INSERT OR IGNORE INTO states (
 	state_id, parent_state_id, state_fingerprint, image_id, prepare_kind, prepare_args_normalized, created_at,
-	size_bytes, last_used_at, use_count, status
+	size_bytes, last_used_at, use_count, status, namespace, task_hash
 )
-VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 	_, err := s.db.ExecContext(ctx, query,
 		entry.StateID,
 		entry.ParentStateID,
@@ -313,11 +355,14 @@ VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 		entry.CreatedAt,
 		0,
 		entry.Status,
+		nullableNamespace(entry.Namespace),
+		nullableText(entry.TaskHash),
 	)
 	return err
 }
 
 func (s *Store) UpdateStateSize(ctx context.Context, stateID string, sizeBytes int64) error {
+	defer s.generation.Add(1)
 	_, err := s.db.ExecContext(ctx,
 		`UPDATE states
 		 SET size_bytes = ?
@@ -329,9 +374,10 @@ func (s *Store) UpdateStateSize(ctx context.Context, stateID string, sizeBytes i
 }
 
 func (s *Store) CreateInstance(ctx context.Context, entry store.InstanceCreate) error {
+	defer s.generation.Add(1)
 	insertQuery := `
-INSERT INTO instances (instance_id, state_id, image_id, created_at, expires_at, runtime_id, runtime_dir, status)
-VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+INSERT INTO instances (instance_id, state_id, image_id, created_at, expires_at, runtime_id, runtime_dir, instance_mode, status)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
@@ -344,6 +390,7 @@ VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
 		entry.ExpiresAt,
 		entry.RuntimeID,
 		entry.RuntimeDir,
+		entry.Mode,
 		entry.Status,
 	); err != nil {
 		_ = tx.Rollback()
@@ -363,16 +410,31 @@ VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
 }
 
 func (s *Store) DeleteInstance(ctx context.Context, instanceID string) error {
+	defer s.generation.Add(1)
 	_, err := s.db.ExecContext(ctx, `DELETE FROM instances WHERE instance_id = ?`, instanceID)
 	return err
 }
 
+// DeleteState removes the state together with the tags that name it.
 func (s *Store) DeleteState(ctx context.Context, stateID string) error {
-	_, err := s.db.ExecContext(ctx, `DELETE FROM states WHERE state_id = ?`, stateID)
-	return err
+	defer s.generation.Add(1)
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM names WHERE state_id = ? AND instance_id IS NULL`, stateID); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM states WHERE state_id = ?`, stateID); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
 }
 
 func (s *Store) UpdateInstanceRuntime(ctx context.Context, instanceID string, runtimeID *string) error {
+	defer s.generation.Add(1)
 	var value any
 	if runtimeID != nil {
 		trimmed := strings.TrimSpace(*runtimeID)
@@ -399,6 +461,9 @@ func initDB(db *sql.DB) error {
 	if err := ensureRuntimeDirColumn(db); err != nil {
 		return err
 	}
+	if err := ensureInstanceModeColumn(db); err != nil {
+		return err
+	}
 	if err := ensureStateLastUsedAtColumn(db); err != nil {
 		return err
 	}
@@ -414,6 +479,12 @@ func initDB(db *sql.DB) error {
 	if err := ensureStateEvictionReasonColumn(db); err != nil {
 		return err
 	}
+	if err := ensureStateNamespaceColumn(db); err != nil {
+		return err
+	}
+	if err := ensureStateTaskHashColumn(db); err != nil {
+		return err
+	}
 	_, err := db.Exec(SchemaSQL())
 	return err
 }
@@ -459,6 +530,19 @@ func ensureRuntimeDirColumn(db *sql.DB) error {
 	return nil
 }
 
+func ensureInstanceModeColumn(db *sql.DB) error {
+	if _, err := db.Exec("ALTER TABLE instances ADD COLUMN instance_mode TEXT"); err != nil {
+		if strings.Contains(err.Error(), "duplicate column name") {
+			return nil
+		} else if strings.Contains(err.Error(), "no such table") {
+			return nil
+		} else {
+			return err
+		}
+	}
+	return nil
+}
+
 func ensureStateLastUsedAtColumn(db *sql.DB) error {
 	if _, err := db.Exec("ALTER TABLE states ADD COLUMN last_used_at TEXT"); err != nil {
 		if strings.Contains(err.Error(), "duplicate column name") {
@@ -511,6 +595,32 @@ func ensureStateEvictedAtColumn(db *sql.DB) error {
 	return nil
 }
 
+func ensureStateNamespaceColumn(db *sql.DB) error {
+	if _, err := db.Exec("ALTER TABLE states ADD COLUMN namespace TEXT"); err != nil {
+		if strings.Contains(err.Error(), "duplicate column name") {
+			return nil
+		} else if strings.Contains(err.Error(), "no such table") {
+			return nil
+		} else {
+			return err
+		}
+	}
+	return nil
+}
+
+func ensureStateTaskHashColumn(db *sql.DB) error {
+	if _, err := db.Exec("ALTER TABLE states ADD COLUMN task_hash TEXT"); err != nil {
+		if strings.Contains(err.Error(), "duplicate column name") {
+			return nil
+		} else if strings.Contains(err.Error(), "no such table") {
+			return nil
+		} else {
+			return err
+		}
+	}
+	return nil
+}
+
 func ensureStateEvictionReasonColumn(db *sql.DB) error {
 	if _, err := db.Exec("ALTER TABLE states ADD COLUMN eviction_reason TEXT"); err != nil {
 		if strings.Contains(err.Error(), "duplicate column name") {
@@ -524,6 +634,25 @@ func ensureStateEvictionReasonColumn(db *sql.DB) error {
 	return nil
 }
 
+// nullableNamespace stores the default namespace as NULL so existing rows and
+// new default-namespace rows look the same.
+func nullableNamespace(namespace string) any {
+	namespace = strings.TrimSpace(namespace)
+	if namespace == "" {
+		return nil
+	}
+	return namespace
+}
+
+// nullableText stores an empty string as NULL.
+func nullableText(value string) any {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil
+	}
+	return value
+}
+
 func addFilter(query *strings.Builder, args *[]any, column, value string) {
 	value = strings.TrimSpace(value)
 	if value == "" {
@@ -557,7 +686,8 @@ func scanName(scanner interface {
 	var lastUsedAt sql.NullString
 	var expiresAt sql.NullString
 	var joinedInstanceID sql.NullString
-	if err := scanner.Scan(&name, &instanceID, &imageID, &stateID, &stateFingerprint, &lastUsedAt, &expiresAt, &joinedInstanceID); err != nil {
+	var joinedStateID sql.NullString
+	if err := scanner.Scan(&name, &instanceID, &imageID, &stateID, &stateFingerprint, &lastUsedAt, &expiresAt, &joinedInstanceID, &joinedStateID); err != nil {
 		return store.NameEntry{}, err
 	}
 	entry := store.NameEntry{
@@ -576,11 +706,14 @@ func scanName(scanner interface {
 	if stateID.Valid && stateID.String != "" {
 		entry.StateID = stateID.String
 	}
-	if instanceID.Valid && joinedInstanceID.Valid {
+	switch {
+	case instanceID.Valid && joinedInstanceID.Valid:
 		if isExpired(expiresAt, now) {
 			entry.Status = store.NameStatusExpired
 		}
-	} else {
+	case !instanceID.Valid && joinedStateID.Valid:
+		// A state tag is live while its state exists.
+	default:
 		entry.Status = store.NameStatusMissing
 	}
 	return entry, nil
@@ -593,9 +726,10 @@ func scanInstance(scanner interface {
 	var expiresAt sql.NullString
 	var runtimeID sql.NullString
 	var runtimeDir sql.NullString
+	var mode sql.NullString
 	var name sql.NullString
 	var nameCount int
-	if err := scanner.Scan(&entry.InstanceID, &entry.ImageID, &entry.StateID, &entry.CreatedAt, &expiresAt, &runtimeID, &runtimeDir, &name, &nameCount); err != nil {
+	if err := scanner.Scan(&entry.InstanceID, &entry.ImageID, &entry.StateID, &entry.CreatedAt, &expiresAt, &runtimeID, &runtimeDir, &mode, &name, &nameCount); err != nil {
 		return store.InstanceEntry{}, err
 	}
 	entry.Status = store.InstanceStatusActive
@@ -611,6 +745,9 @@ func scanInstance(scanner interface {
 	if runtimeDir.Valid {
 		entry.RuntimeDir = strPtr(runtimeDir.String)
 	}
+	if mode.Valid {
+		entry.Mode = mode.String
+	}
 	if name.Valid {
 		entry.Name = strPtr(name.String)
 	}
@@ -119,6 +119,7 @@ func TestInitDBAddsStateCapacityColumns(t *testing.T) {
 		"min_retention_until",
 		"evicted_at",
 		"eviction_reason",
+		"namespace",
 	}
 	for _, name := range expected {
 		var found string
@@ -229,6 +230,7 @@ func TestEnsureColumnsReturnErrorsOnClosedDB(t *testing.T) {
 		{name: "ensureStateMinRetentionUntilColumn", fn: ensureStateMinRetentionUntilColumn},
 		{name: "ensureStateEvictedAtColumn", fn: ensureStateEvictedAtColumn},
 		{name: "ensureStateEvictionReasonColumn", fn: ensureStateEvictionReasonColumn},
+		{name: "ensureStateNamespaceColumn", fn: ensureStateNamespaceColumn},
 	}
 	for _, tc := range checks {
 		if err := tc.fn(db); err == nil {
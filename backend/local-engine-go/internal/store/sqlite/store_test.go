@@ -3,6 +3,7 @@ package sqlite
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"path/filepath"
 	"strings"
 	"testing"
@@ -256,6 +257,46 @@ func TestStoreListNamesStatuses(t *testing.T) {
 	}
 }
 
+func TestStoreTagState(t *testing.T) {
+	now := time.Now().UTC()
+	st := openTestStore(t)
+	seedStore(t, st, now)
+	ctx := context.Background()
+
+	entry, ok, err := st.TagState(ctx, "my-seed", "state-1")
+	if err != nil || !ok {
+		t.Fatalf("TagState: ok=%v err=%v", ok, err)
+	}
+	if entry.StateID != "state-1" || entry.ImageID != "image-1" || entry.InstanceID != nil || entry.Status != store.NameStatusActive {
+		t.Fatalf("unexpected tag: %+v", entry)
+	}
+
+	// Tagging again re-points the name.
+	if entry, ok, err = st.TagState(ctx, "my-seed", "state-2"); err != nil || !ok || entry.StateID != "state-2" || entry.ImageID != "image-2" {
+		t.Fatalf("expected the tag to move to state-2, got %+v ok=%v err=%v", entry, ok, err)
+	}
+	if _, ok, err := st.TagState(ctx, "other", "missing"); err != nil || ok {
+		t.Fatalf("expected a missing state to be reported, ok=%v err=%v", ok, err)
+	}
+	if _, _, err := st.TagState(ctx, "dev", "state-2"); !errors.Is(err, store.ErrNameInUse) {
+		t.Fatalf("expected ErrNameInUse for an instance name, got %v", err)
+	}
+	if name, _, _ := st.GetName(ctx, "dev"); name.InstanceID == nil || *name.InstanceID != "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa" {
+		t.Fatalf("expected the instance name to be kept, got %+v", name)
+	}
+
+	exec(t, st, `DELETE FROM instances WHERE state_id = ?`, "state-2")
+	if err := st.DeleteState(ctx, "state-2"); err != nil {
+		t.Fatalf("DeleteState: %v", err)
+	}
+	if _, ok, err := st.GetName(ctx, "my-seed"); err != nil || ok {
+		t.Fatalf("expected the tag to go with its state, ok=%v err=%v", ok, err)
+	}
+	if _, ok, err := st.GetName(ctx, "ghost"); err != nil || !ok {
+		t.Fatalf("expected names without a state tag to be kept, ok=%v err=%v", ok, err)
+	}
+}
+
 func TestStoreListInstancesStatuses(t *testing.T) {
 	now := time.Now().UTC()
 	st := openTestStore(t)
@@ -402,3 +443,132 @@ func exec(t *testing.T, st *Store, query string, args ...any) {
 		t.Fatalf("exec %q: %v", query, err)
 	}
 }
+
+func TestStoreNamespaceFilters(t *testing.T) {
+	ctx := context.Background()
+	st := openTestStore(t)
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	for _, tc := range []struct{ stateID, namespace string }{
+		{"state-default", ""},
+		{"state-team-a", "team-a"},
+	} {
+		if err := st.CreateState(ctx, store.StateCreate{
+			StateID:               tc.stateID,
+			StateFingerprint:      tc.stateID,
+			ImageID:               "image-1",
+			PrepareKind:           "psql",
+			PrepareArgsNormalized: "-c select 1",
+			CreatedAt:             now,
+			Namespace:             tc.namespace,
+		}); err != nil {
+			t.Fatalf("CreateState: %v", err)
+		}
+	}
+	if err := st.CreateInstance(ctx, store.InstanceCreate{
+		InstanceID: "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
+		StateID:    "state-team-a",
+		ImageID:    "image-1",
+		CreatedAt:  now,
+	}); err != nil {
+		t.Fatalf("CreateInstance: %v", err)
+	}
+
+	state, ok, err := st.GetState(ctx, "state-team-a")
+	if err != nil || !ok || state.Namespace != "team-a" {
+		t.Fatalf("unexpected namespaced state: %+v ok=%v err=%v", state, ok, err)
+	}
+	all, err := st.ListStates(ctx, store.StateFilters{})
+	if err != nil || len(all) != 2 {
+		t.Fatalf("expected all states without namespace filter, got %+v err=%v", all, err)
+	}
+	scoped, err := st.ListStates(ctx, store.StateFilters{Namespace: "team-a"})
+	if err != nil || len(scoped) != 1 || scoped[0].StateID != "state-team-a" {
+		t.Fatalf("unexpected scoped states: %+v err=%v", scoped, err)
+	}
+	instances, err := st.ListInstances(ctx, store.InstanceFilters{Namespace: "team-a"})
+	if err != nil || len(instances) != 1 {
+		t.Fatalf("unexpected scoped instances: %+v err=%v", instances, err)
+	}
+	instances, err = st.ListInstances(ctx, store.InstanceFilters{Namespace: "team-b"})
+	if err != nil || len(instances) != 0 {
+		t.Fatalf("expected no instances in other namespace, got %+v err=%v", instances, err)
+	}
+}
+
+func TestStoreKeepsStateTaskHash(t *testing.T) {
+	ctx := context.Background()
+	st := openTestStore(t)
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	for _, tc := range []struct{ stateID, taskHash string }{
+		{"state-hashed", "task-hash"},
+		{"state-legacy", ""},
+	} {
+		if err := st.CreateState(ctx, store.StateCreate{
+			StateID:               tc.stateID,
+			StateFingerprint:      tc.stateID,
+			ImageID:               "image-1",
+			PrepareKind:           "psql",
+			PrepareArgsNormalized: "-c select 1",
+			CreatedAt:             now,
+			TaskHash:              tc.taskHash,
+		}); err != nil {
+			t.Fatalf("CreateState: %v", err)
+		}
+	}
+	state, ok, err := st.GetState(ctx, "state-hashed")
+	if err != nil || !ok || state.TaskHash != "task-hash" {
+		t.Fatalf("expected the task hash, got %+v ok=%v err=%v", state, ok, err)
+	}
+	state, ok, err = st.GetState(ctx, "state-legacy")
+	if err != nil || !ok || state.TaskHash != "" {
+		t.Fatalf("expected no task hash, got %+v ok=%v err=%v", state, ok, err)
+	}
+}
+
+func TestStoreGenerationAdvancesOnWrites(t *testing.T) {
+	st := openTestStore(t)
+	ctx := context.Background()
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+
+	last := st.Generation()
+	expectAdvanced := func(step string) {
+		t.Helper()
+		if next := st.Generation(); next == last {
+			t.Fatalf("expected generation to advance after %s", step)
+		} else {
+			last = next
+		}
+	}
+	if err := st.CreateState(ctx, store.StateCreate{StateID: "state-1", StateFingerprint: "state-1", ImageID: "image-1", PrepareKind: "psql", PrepareArgsNormalized: "-c select 1", CreatedAt: now}); err != nil {
+		t.Fatalf("CreateState: %v", err)
+	}
+	expectAdvanced("CreateState")
+	if err := st.UpdateStateSize(ctx, "state-1", 42); err != nil {
+		t.Fatalf("UpdateStateSize: %v", err)
+	}
+	expectAdvanced("UpdateStateSize")
+	if err := st.CreateInstance(ctx, store.InstanceCreate{InstanceID: "instance-1", StateID: "state-1", ImageID: "image-1", CreatedAt: now}); err != nil {
+		t.Fatalf("CreateInstance: %v", err)
+	}
+	expectAdvanced("CreateInstance")
+	if err := st.UpdateInstanceRuntime(ctx, "instance-1", strPtr("container-1")); err != nil {
+		t.Fatalf("UpdateInstanceRuntime: %v", err)
+	}
+	expectAdvanced("UpdateInstanceRuntime")
+
+	if _, err := st.ListStates(ctx, store.StateFilters{}); err != nil {
+		t.Fatalf("ListStates: %v", err)
+	}
+	if st.Generation() != last {
+		t.Fatalf("expected reads to keep the generation")
+	}
+
+	if err := st.DeleteInstance(ctx, "instance-1"); err != nil {
+		t.Fatalf("DeleteInstance: %v", err)
+	}
+	expectAdvanced("DeleteInstance")
+	if err := st.DeleteState(ctx, "state-1"); err != nil {
+		t.Fatalf("DeleteState: %v", err)
+	}
+	expectAdvanced("DeleteState")
+}
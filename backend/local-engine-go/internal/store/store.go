@@ -1,6 +1,9 @@
 package store
 
-import "context"
+import (
+	"context"
+	"errors"
+)
 
 const (
 	NameStatusActive  = "active"
@@ -10,8 +13,13 @@ const (
 	InstanceStatusActive   = "active"
 	InstanceStatusExpired  = "expired"
 	InstanceStatusOrphaned = "orphaned"
+	// InstanceStatusStale marks a recorded instance whose container is gone.
+	InstanceStatusStale = "stale"
 )
 
+// ErrNameInUse reports a state tag whose name already names an instance.
+var ErrNameInUse = errors.New("name is in use by an instance")
+
 type NameEntry struct {
 	Name             string  `json:"name"`
 	InstanceID       *string `json:"instance_id,omitempty"`
@@ -30,7 +38,8 @@ type InstanceEntry struct {
 	CreatedAt  string  `json:"created_at"`
 	ExpiresAt  *string `json:"expires_at,omitempty"`
 	RuntimeID  *string `json:"runtime_id,omitempty"`
-	RuntimeDir *string `json:"-"`
+	RuntimeDir *string `json:"runtime_dir,omitempty"`
+	Mode       string  `json:"instance_mode,omitempty"`
 	Status     string  `json:"status"`
 }
 
@@ -45,7 +54,12 @@ type StateEntry struct {
 	LastUsedAt        *string `json:"last_used_at,omitempty"`
 	UseCount          *int64  `json:"use_count,omitempty"`
 	MinRetentionUntil *string `json:"min_retention_until,omitempty"`
+	Namespace         string  `json:"namespace,omitempty"`
 	RefCount          int     `json:"refcount"`
+	// TaskHash is the hash of the task that produced the state, recorded so
+	// the state can be exported after its job is gone. Empty for states
+	// created before it was recorded.
+	TaskHash string `json:"-"`
 }
 
 type StateCreate struct {
@@ -58,6 +72,8 @@ type StateCreate struct {
 	CreatedAt             string
 	SizeBytes             *int64
 	Status                *string
+	Namespace             string
+	TaskHash              string
 }
 
 type InstanceCreate struct {
@@ -69,6 +85,7 @@ type InstanceCreate struct {
 	Status     *string
 	RuntimeID  *string
 	RuntimeDir *string
+	Mode       *string
 }
 
 type NameFilters struct {
@@ -78,16 +95,18 @@ type NameFilters struct {
 }
 
 type InstanceFilters struct {
-	StateID  string
-	ImageID  string
-	IDPrefix string
+	StateID   string
+	ImageID   string
+	IDPrefix  string
+	Namespace string
 }
 
 type StateFilters struct {
-	Kind     string
-	ImageID  string
-	IDPrefix string
-	ParentID string
+	Kind      string
+	ImageID   string
+	IDPrefix  string
+	ParentID  string
+	Namespace string
 }
 
 type Store interface {
@@ -0,0 +1,258 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultFlushEvery = 5 * time.Second
+	maxBatchSpans     = 256
+	// maxPendingSpans caps memory while the collector is unreachable; older
+	// spans are dropped first.
+	maxPendingSpans  = 4096
+	instrumentation  = "github.com/sqlrs/engine-local"
+	spanKindInternal = 1
+	statusCodeError  = 2
+)
+
+// exporter batches ended spans and posts them as OTLP/HTTP JSON. Export
+// failures are logged and the batch is dropped; tracing never fails a job.
+type exporter struct {
+	url     string
+	service string
+	client  *http.Client
+
+	mu      sync.Mutex
+	pending []spanData
+	stopped bool
+
+	kick chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newExporter(endpoint string, service string, flushEvery time.Duration) *exporter {
+	if flushEvery <= 0 {
+		flushEvery = defaultFlushEvery
+	}
+	e := &exporter{
+		url:     tracesURL(endpoint),
+		service: service,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		kick:    make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go e.loop(flushEvery)
+	return e
+}
+
+// tracesURL appends the OTLP traces path unless the endpoint already names it.
+func tracesURL(endpoint string) string {
+	endpoint = strings.TrimRight(strings.TrimSpace(endpoint), "/")
+	if strings.HasSuffix(endpoint, "/v1/traces") {
+		return endpoint
+	}
+	return endpoint + "/v1/traces"
+}
+
+func (e *exporter) enqueue(span spanData) {
+	e.mu.Lock()
+	if e.stopped {
+		e.mu.Unlock()
+		return
+	}
+	if len(e.pending) >= maxPendingSpans {
+		e.pending = e.pending[1:]
+	}
+	e.pending = append(e.pending, span)
+	full := len(e.pending) >= maxBatchSpans
+	e.mu.Unlock()
+	if full {
+		select {
+		case e.kick <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (e *exporter) loop(flushEvery time.Duration) {
+	defer close(e.done)
+	ticker := time.NewTicker(flushEvery)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-e.stop:
+			return
+		case <-ticker.C:
+		case <-e.kick:
+		}
+		e.flush(context.Background())
+	}
+}
+
+func (e *exporter) shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	if e.stopped {
+		e.mu.Unlock()
+		return nil
+	}
+	e.stopped = true
+	e.mu.Unlock()
+	close(e.stop)
+	select {
+	case <-e.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return e.flush(ctx)
+}
+
+func (e *exporter) flush(ctx context.Context) error {
+	for {
+		e.mu.Lock()
+		if len(e.pending) == 0 {
+			e.mu.Unlock()
+			return nil
+		}
+		n := len(e.pending)
+		if n > maxBatchSpans {
+			n = maxBatchSpans
+		}
+		batch := append([]spanData(nil), e.pending[:n]...)
+		e.pending = e.pending[n:]
+		e.mu.Unlock()
+		if err := e.post(ctx, batch); err != nil {
+			log.Printf("otel export failed spans=%d err=%v", len(batch), err)
+			return err
+		}
+	}
+}
+
+func (e *exporter) post(ctx context.Context, batch []spanData) error {
+	body, err := json.Marshal(encodeTraces(e.service, batch))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("collector returned %s", resp.Status)
+	}
+	return nil
+}
+
+type otlpTraces struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            *otlpStatus    `json:"status,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	BoolValue   *bool   `json:"boolValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+}
+
+func encodeTraces(service string, batch []spanData) otlpTraces {
+	spans := make([]otlpSpan, 0, len(batch))
+	for _, data := range batch {
+		span := otlpSpan{
+			TraceID:           hex.EncodeToString(data.sc.TraceID[:]),
+			SpanID:            hex.EncodeToString(data.sc.SpanID[:]),
+			Name:              data.name,
+			Kind:              spanKindInternal,
+			StartTimeUnixNano: strconv.FormatInt(data.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(data.end.UnixNano(), 10),
+			Attributes:        encodeAttrs(data.attrs),
+		}
+		if data.parent != ([8]byte{}) {
+			span.ParentSpanID = hex.EncodeToString(data.parent[:])
+		}
+		if data.failed {
+			span.Status = &otlpStatus{Code: statusCodeError, Message: data.errorMsg}
+		}
+		spans = append(spans, span)
+	}
+	return otlpTraces{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: encodeAttrs([]Attr{String("service.name", service)})},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: instrumentation}, Spans: spans}},
+	}}}
+}
+
+func encodeAttrs(attrs []Attr) []otlpKeyValue {
+	out := make([]otlpKeyValue, 0, len(attrs))
+	for _, attr := range attrs {
+		var value otlpValue
+		switch v := attr.Value.(type) {
+		case string:
+			value.StringValue = &v
+		case bool:
+			value.BoolValue = &v
+		case int64:
+			str := strconv.FormatInt(v, 10)
+			value.IntValue = &str
+		default:
+			str := fmt.Sprint(v)
+			value.StringValue = &str
+		}
+		out = append(out, otlpKeyValue{Key: attr.Key, Value: value})
+	}
+	return out
+}
@@ -0,0 +1,50 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	const header = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	sc, ok := ParseTraceparent(header)
+	if !ok || !sc.Sampled {
+		t.Fatalf("expected sampled span context, got %+v ok=%v", sc, ok)
+	}
+	if got := sc.Traceparent(); got != header {
+		t.Fatalf("round trip = %q, want %q", got, header)
+	}
+	if _, ok := ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-extra"); !ok {
+		t.Fatalf("expected future version with extra fields to parse")
+	}
+}
+
+func TestParseTraceparentRejectsMalformed(t *testing.T) {
+	cases := []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"00_4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473z-00f067aa0ba902b7-01",
+	}
+	for _, value := range cases {
+		if sc, ok := ParseTraceparent(value); ok {
+			t.Fatalf("expected %q to be rejected, got %+v", value, sc)
+		}
+	}
+}
+
+func TestExtractAttachesRemoteParent(t *testing.T) {
+	ctx := Extract(context.Background(), "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if got := SpanContextFromContext(ctx).Traceparent(); got != "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01" {
+		t.Fatalf("unexpected span context: %q", got)
+	}
+	base := context.Background()
+	if Extract(base, "garbage") != base {
+		t.Fatalf("expected malformed header to leave ctx unchanged")
+	}
+}
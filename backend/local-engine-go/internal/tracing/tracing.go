@@ -0,0 +1,189 @@
+// Package tracing emits OpenTelemetry-compatible spans for the prepare
+// pipeline and ships them to an OTLP/HTTP collector. A nil *Tracer is valid
+// and does nothing, so tracing costs nothing unless otel.endpoint is set.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultServiceName = "sqlrs-engine"
+
+type Options struct {
+	// Endpoint is the collector base URL (for example http://127.0.0.1:4318);
+	// spans are posted to <Endpoint>/v1/traces. Empty disables tracing.
+	Endpoint    string
+	ServiceName string
+	// FlushEvery bounds how long an ended span waits before export.
+	FlushEvery time.Duration
+}
+
+type Tracer struct {
+	exporter *exporter
+}
+
+// New returns a tracer exporting to opts.Endpoint, or nil when no endpoint is
+// configured.
+func New(opts Options) *Tracer {
+	endpoint := strings.TrimSpace(opts.Endpoint)
+	if endpoint == "" {
+		return nil
+	}
+	service := strings.TrimSpace(opts.ServiceName)
+	if service == "" {
+		service = defaultServiceName
+	}
+	return &Tracer{exporter: newExporter(endpoint, service, opts.FlushEvery)}
+}
+
+// Start opens a span named name. The span joins the trace carried by ctx (a
+// local parent span or a remote traceparent) or starts a new trace. The
+// returned context carries the new span for children.
+func (t *Tracer) Start(ctx context.Context, name string, attrs ...Attr) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+	parent := SpanContextFromContext(ctx)
+	sc := SpanContext{TraceID: parent.TraceID, Sampled: true}
+	if !parent.IsValid() {
+		sc.TraceID = newTraceID()
+	}
+	sc.SpanID = newSpanID()
+	span := &Span{
+		tracer: t,
+		name:   name,
+		sc:     sc,
+		start:  time.Now(),
+		attrs:  append([]Attr(nil), attrs...),
+	}
+	if parent.IsValid() {
+		span.parent = parent.SpanID
+	}
+	return ContextWithSpanContext(ctx, sc), span
+}
+
+// Shutdown exports spans that are still buffered and stops the background
+// flusher.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+	return t.exporter.shutdown(ctx)
+}
+
+// Span is one timed operation. All methods are safe on a nil *Span.
+type Span struct {
+	tracer *Tracer
+	name   string
+	sc     SpanContext
+	parent [8]byte
+	start  time.Time
+
+	mu       sync.Mutex
+	attrs    []Attr
+	errorMsg string
+	failed   bool
+	ended    bool
+}
+
+func (s *Span) SetAttributes(attrs ...Attr) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.attrs = append(s.attrs, attrs...)
+	s.mu.Unlock()
+}
+
+// SetError marks the span as failed with msg as the status description.
+func (s *Span) SetError(msg string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.failed = true
+	s.errorMsg = msg
+	s.mu.Unlock()
+}
+
+// End records the span's end time and queues it for export. Only the first
+// call has an effect.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	data := spanData{
+		name:     s.name,
+		sc:       s.sc,
+		parent:   s.parent,
+		start:    s.start,
+		end:      time.Now(),
+		attrs:    append([]Attr(nil), s.attrs...),
+		failed:   s.failed,
+		errorMsg: s.errorMsg,
+	}
+	s.mu.Unlock()
+	s.tracer.exporter.enqueue(data)
+}
+
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.sc
+}
+
+// Attr is a span attribute. Value is a string, bool or int64.
+type Attr struct {
+	Key   string
+	Value any
+}
+
+func String(key string, value string) Attr {
+	return Attr{Key: key, Value: value}
+}
+
+func Bool(key string, value bool) Attr {
+	return Attr{Key: key, Value: value}
+}
+
+func Int64(key string, value int64) Attr {
+	return Attr{Key: key, Value: value}
+}
+
+type spanData struct {
+	name     string
+	sc       SpanContext
+	parent   [8]byte
+	start    time.Time
+	end      time.Time
+	attrs    []Attr
+	failed   bool
+	errorMsg string
+}
+
+func newTraceID() [16]byte {
+	var id [16]byte
+	for id == ([16]byte{}) {
+		_, _ = rand.Read(id[:])
+	}
+	return id
+}
+
+func newSpanID() [8]byte {
+	var id [8]byte
+	for id == ([8]byte{}) {
+		_, _ = rand.Read(id[:])
+	}
+	return id
+}
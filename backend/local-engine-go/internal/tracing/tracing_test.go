@@ -0,0 +1,139 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type collector struct {
+	mu     sync.Mutex
+	paths  []string
+	traces []otlpTraces
+}
+
+func (c *collector) handler(w http.ResponseWriter, r *http.Request) {
+	var body otlpTraces
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	c.paths = append(c.paths, r.URL.Path)
+	c.traces = append(c.traces, body)
+	c.mu.Unlock()
+}
+
+func (c *collector) spans() []otlpSpan {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var out []otlpSpan
+	for _, trace := range c.traces {
+		for _, rs := range trace.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				out = append(out, ss.Spans...)
+			}
+		}
+	}
+	return out
+}
+
+func TestNewWithoutEndpointIsNoop(t *testing.T) {
+	tracer := New(Options{Endpoint: "  "})
+	if tracer != nil {
+		t.Fatalf("expected nil tracer")
+	}
+	ctx := context.Background()
+	gotCtx, span := tracer.Start(ctx, "job")
+	if gotCtx != ctx || span != nil {
+		t.Fatalf("expected nil tracer to return ctx unchanged and nil span")
+	}
+	span.SetAttributes(String("k", "v"))
+	span.SetError("boom")
+	span.End()
+	if span.SpanContext().IsValid() {
+		t.Fatalf("expected invalid span context for nil span")
+	}
+	if err := tracer.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+}
+
+func TestTracerExportsSpanTree(t *testing.T) {
+	col := &collector{}
+	server := httptest.NewServer(http.HandlerFunc(col.handler))
+	defer server.Close()
+
+	tracer := New(Options{Endpoint: server.URL, ServiceName: "test-engine", FlushEvery: time.Hour})
+	remote, ok := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if !ok {
+		t.Fatalf("parse traceparent")
+	}
+	ctx, root := tracer.Start(ContextWithSpanContext(context.Background(), remote), "prepare.job", String("prepare.kind", "psql"))
+	_, child := tracer.Start(ctx, "prepare.state_execute", Bool("cached", false), Int64("attempt", 2))
+	child.SetError("boom")
+	child.End()
+	root.End()
+	root.End()
+
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	spans := col.spans()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %+v", spans)
+	}
+	if col.paths[0] != "/v1/traces" {
+		t.Fatalf("unexpected export path: %v", col.paths)
+	}
+	if got := col.traces[0].ResourceSpans[0].Resource.Attributes[0]; got.Key != "service.name" || *got.Value.StringValue != "test-engine" {
+		t.Fatalf("unexpected resource attributes: %+v", got)
+	}
+	childSpan, rootSpan := spans[0], spans[1]
+	if rootSpan.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || rootSpan.ParentSpanID != "00f067aa0ba902b7" {
+		t.Fatalf("expected root span to join remote trace, got %+v", rootSpan)
+	}
+	if childSpan.TraceID != rootSpan.TraceID || childSpan.ParentSpanID != rootSpan.SpanID {
+		t.Fatalf("expected child of root span, got %+v", childSpan)
+	}
+	if childSpan.Status == nil || childSpan.Status.Code != statusCodeError || childSpan.Status.Message != "boom" {
+		t.Fatalf("expected error status, got %+v", childSpan.Status)
+	}
+	if len(childSpan.Attributes) != 2 || *childSpan.Attributes[0].Value.BoolValue || *childSpan.Attributes[1].Value.IntValue != "2" {
+		t.Fatalf("unexpected child attributes: %+v", childSpan.Attributes)
+	}
+	if rootSpan.Status != nil {
+		t.Fatalf("expected ok root span, got %+v", rootSpan.Status)
+	}
+}
+
+func TestTracerStartsNewTraceWithoutParent(t *testing.T) {
+	tracer := New(Options{Endpoint: "http://127.0.0.1:1", FlushEvery: time.Hour})
+	defer tracer.Shutdown(context.Background())
+
+	_, first := tracer.Start(context.Background(), "a")
+	_, second := tracer.Start(context.Background(), "b")
+	if !first.SpanContext().IsValid() || first.SpanContext().TraceID == second.SpanContext().TraceID {
+		t.Fatalf("expected distinct new traces, got %+v %+v", first.SpanContext(), second.SpanContext())
+	}
+	if first.parent != ([8]byte{}) {
+		t.Fatalf("expected root span without parent")
+	}
+}
+
+func TestTracesURL(t *testing.T) {
+	cases := map[string]string{
+		"http://collector:4318":            "http://collector:4318/v1/traces",
+		"http://collector:4318/":           "http://collector:4318/v1/traces",
+		"https://collector/otlp/v1/traces": "https://collector/otlp/v1/traces",
+	}
+	for input, want := range cases {
+		if got := tracesURL(input); got != want {
+			t.Fatalf("tracesURL(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
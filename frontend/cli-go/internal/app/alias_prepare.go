@@ -38,6 +38,13 @@ func parsePrepareAliasArgs(args []string) (prepareAliasInvocation, bool, error)
 		case arg == "--no-watch":
 			opts.Watch = false
 			opts.WatchSpecified = true
+		case arg == "--wait" || strings.HasPrefix(arg, "--wait="):
+			watch, err := parseWaitFlag(arg)
+			if err != nil {
+				return opts, false, err
+			}
+			opts.Watch = watch
+			opts.WatchSpecified = true
 		case arg == "--provenance-path":
 			if i+1 >= len(args) {
 				return opts, false, ExitErrorf(2, "Missing value for --provenance-path")
@@ -37,6 +37,16 @@ func TestParsePrepareAliasArgsAdditionalBranches(t *testing.T) {
 		}
 	})
 
+	t.Run("wait=false specified", func(t *testing.T) {
+		invocation, showHelp, err := parsePrepareAliasArgs([]string{"--wait=false", "chinook"})
+		if err != nil || showHelp {
+			t.Fatalf("parsePrepareAliasArgs: err=%v showHelp=%v", err, showHelp)
+		}
+		if invocation.Ref != "chinook" || invocation.Watch || !invocation.WatchSpecified {
+			t.Fatalf("unexpected invocation: %+v", invocation)
+		}
+	})
+
 	t.Run("ref options", func(t *testing.T) {
 		invocation, showHelp, err := parsePrepareAliasArgs([]string{"--ref", "HEAD~1", "--ref-mode", "blob", "chinook"})
 		if err != nil || showHelp {
@@ -36,6 +36,7 @@ type commandContext struct {
 	idleTimeout          time.Duration
 	startupTimeout       time.Duration
 	verbose              bool
+	engineOverrides      engineOverrides
 }
 
 // resolveCommandContext resolves config, profile, runtime paths, and output mode
@@ -210,7 +211,48 @@ func (ctx commandContext) rmOptions() cli.RmOptions {
 	}
 }
 
+func (ctx commandContext) statesOptions() cli.StatesOptions {
+	return cli.StatesOptions{
+		ProfileName:     ctx.profileName,
+		Mode:            ctx.mode,
+		AuthToken:       ctx.authToken,
+		Endpoint:        ctx.profile.Endpoint,
+		Autostart:       ctx.profile.Autostart,
+		DaemonPath:      ctx.daemonPath,
+		RunDir:          ctx.runDir,
+		StateDir:        ctx.cfgResult.Paths.StateDir,
+		EngineRunDir:    ctx.engineRunDir,
+		EngineStatePath: ctx.engineStatePath,
+		EngineStoreDir:  ctx.engineStoreDir,
+		WSLVHDXPath:     ctx.engineHostStorePath,
+		WSLMountUnit:    ctx.engineWSLMountUnit,
+		WSLMountFSType:  ctx.engineWSLMountFSType,
+		WSLDistro:       ctx.wslDistro,
+		Timeout:         ctx.timeout,
+		IdleTimeout:     ctx.idleTimeout,
+		StartupTimeout:  ctx.startupTimeout,
+		Verbose:         ctx.verbose,
+	}
+}
+
+func (ctx commandContext) engineOptions() cli.EngineOptions {
+	return cli.EngineOptions{
+		ProfileName:   ctx.profileName,
+		Mode:          ctx.mode,
+		AuthToken:     ctx.authToken,
+		Endpoint:      ctx.profile.Endpoint,
+		StateDir:      ctx.cfgResult.Paths.StateDir,
+		Timeout:       ctx.timeout,
+		Verbose:       ctx.verbose,
+		ClientVersion: Version,
+	}
+}
+
 func (ctx commandContext) prepareOptions(composite bool) cli.PrepareOptions {
+	idleTimeout := ctx.idleTimeout
+	if ctx.engineOverrides.IdleTimeout > 0 {
+		idleTimeout = ctx.engineOverrides.IdleTimeout
+	}
 	return cli.PrepareOptions{
 		ProfileName:         ctx.profileName,
 		Mode:                ctx.mode,
@@ -228,12 +270,18 @@ func (ctx commandContext) prepareOptions(composite bool) cli.PrepareOptions {
 		WSLMountFSType:      ctx.engineWSLMountFSType,
 		WSLDistro:           ctx.wslDistro,
 		Timeout:             ctx.timeout,
-		IdleTimeout:         ctx.idleTimeout,
+		IdleTimeout:         idleTimeout,
+		ContainerRuntime:    ctx.engineOverrides.ContainerRuntime,
+		SnapshotBackend:     ctx.engineOverrides.SnapshotBackend,
+		StateStore:          ctx.engineOverrides.StateStore,
+		EngineOverrideFlags: ctx.engineOverrides.flags(),
 		StartupTimeout:      ctx.startupTimeout,
 		Verbose:             ctx.verbose,
+		OutputFormat:        ctx.output,
 		CompositeRun:        composite,
 		SourceSyncMode:      ctx.profile.SourceSync.Mode,
 		SourceSyncMaxRounds: ctx.profile.SourceSync.MaxRounds,
+		NoStream:            ctx.profile.NoStream,
 	}
 }
 
@@ -282,6 +330,7 @@ func (ctx commandContext) statusOptions() cli.StatusOptions {
 		IdleTimeout:     ctx.idleTimeout,
 		StartupTimeout:  ctx.startupTimeout,
 		Verbose:         ctx.verbose,
+		ClientVersion:   Version,
 	}
 }
 
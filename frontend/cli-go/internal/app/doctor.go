@@ -0,0 +1,63 @@
+package app
+
+import (
+	"context"
+	"flag"
+	"io"
+
+	"github.com/sqlrs/cli/internal/cli"
+)
+
+type doctorOptions struct {
+	JSON bool
+}
+
+func parseDoctorFlags(args []string) (doctorOptions, bool, error) {
+	var opts doctorOptions
+	if err := validateNoUnicodeDashFlags(args, 2); err != nil {
+		return opts, false, err
+	}
+
+	fs := flag.NewFlagSet("sqlrs doctor", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	jsonOutput := fs.Bool("json", false, "print JSON")
+	help := fs.Bool("help", false, "show help")
+	helpShort := fs.Bool("h", false, "show help")
+
+	if err := fs.Parse(args); err != nil {
+		return opts, false, ExitErrorf(2, "Invalid arguments: %v", err)
+	}
+	if *help || *helpShort {
+		return opts, true, nil
+	}
+	if fs.NArg() > 0 {
+		return opts, false, ExitErrorf(2, "doctor does not accept arguments")
+	}
+	opts.JSON = *jsonOutput
+	return opts, false, nil
+}
+
+func runDoctor(w io.Writer, runOpts cli.StatusOptions, args []string, output string) error {
+	opts, showHelp, err := parseDoctorFlags(args)
+	if err != nil {
+		return err
+	}
+	if showHelp {
+		cli.PrintDoctorUsage(w)
+		return nil
+	}
+
+	result := cli.RunDoctor(context.Background(), runOpts)
+	if opts.JSON || output == "json" {
+		if err := writeJSON(w, result); err != nil {
+			return err
+		}
+	} else {
+		cli.PrintDoctor(w, result)
+	}
+	if !result.OK {
+		return ExitErrorf(1, "doctor found problems")
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sqlrs/cli/internal/cli"
+)
+
+func TestParseDoctorFlags(t *testing.T) {
+	for _, args := range [][]string{{"extra"}, {"--unknown"}, {"—json"}} {
+		_, _, err := parseDoctorFlags(args)
+		var exitErr *ExitError
+		if !errors.As(err, &exitErr) || exitErr.Code != 2 {
+			t.Fatalf("args %v: expected ExitError code 2, got %v", args, err)
+		}
+	}
+	if _, showHelp, err := parseDoctorFlags([]string{"--help"}); err != nil || !showHelp {
+		t.Fatalf("expected help, err=%v help=%v", err, showHelp)
+	}
+	if opts, _, err := parseDoctorFlags([]string{"--json"}); err != nil || !opts.JSON {
+		t.Fatalf("expected --json, got %+v err=%v", opts, err)
+	}
+}
+
+func TestRunDoctorExitCode(t *testing.T) {
+	status := "pass"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/health":
+			io.WriteString(w, `{"ok":true,"version":"v1","instanceId":"inst","pid":42}`)
+		case "/v1/diagnostics":
+			io.WriteString(w, `{"ok":true,"checks":[{"name":"stateStore","status":"`+status+`","message":"checked"}]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	opts := cli.StatusOptions{Mode: "remote", Endpoint: server.URL, Timeout: time.Second}
+
+	var human bytes.Buffer
+	if err := runDoctor(&human, opts, nil, "human"); err != nil {
+		t.Fatalf("runDoctor: %v", err)
+	}
+	if !strings.Contains(human.String(), "PASS  stateStore: checked") {
+		t.Fatalf("unexpected output: %q", human.String())
+	}
+
+	status = "fail"
+	var jsonOut bytes.Buffer
+	err := runDoctor(&jsonOut, opts, []string{"--json"}, "human")
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 1 {
+		t.Fatalf("expected exit code 1, got %v", err)
+	}
+	var result cli.DoctorResult
+	if err := json.Unmarshal(jsonOut.Bytes(), &result); err != nil || result.OK || len(result.Checks) != 2 {
+		t.Fatalf("unexpected JSON output %q: %v", jsonOut.String(), err)
+	}
+}
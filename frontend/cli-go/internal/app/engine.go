@@ -0,0 +1,82 @@
+package app
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/sqlrs/cli/internal/cli"
+)
+
+type engineCommand struct {
+	action string
+	json   bool
+}
+
+func parseEngineArgs(args []string) (engineCommand, bool, error) {
+	var cmd engineCommand
+	if err := validateNoUnicodeDashFlags(args, 2); err != nil {
+		return cmd, false, err
+	}
+	if len(args) == 0 {
+		return cmd, false, ExitErrorf(2, "Missing engine command")
+	}
+	action := strings.TrimSpace(args[0])
+	switch action {
+	case "--help", "-h":
+		return cmd, true, nil
+	case "status":
+		fs := flag.NewFlagSet("sqlrs engine status", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+
+		jsonOutput := fs.Bool("json", false, "print JSON")
+		help := fs.Bool("help", false, "show help")
+		helpShort := fs.Bool("h", false, "show help")
+
+		if err := fs.Parse(args[1:]); err != nil {
+			return cmd, false, ExitErrorf(2, "Invalid arguments: %v", err)
+		}
+		if *help || *helpShort {
+			return cmd, true, nil
+		}
+		if fs.NArg() > 0 {
+			return cmd, false, ExitErrorf(2, "Too many arguments")
+		}
+		cmd = engineCommand{action: "status", json: *jsonOutput}
+	default:
+		return cmd, false, ExitErrorf(2, "Unknown engine command: %s", action)
+	}
+	return cmd, false, nil
+}
+
+func runEngine(w io.Writer, runOpts cli.EngineOptions, args []string, output string) error {
+	cmd, showHelp, err := parseEngineArgs(args)
+	if err != nil {
+		return err
+	}
+	if showHelp {
+		cli.PrintEngineUsage(w)
+		return nil
+	}
+
+	switch cmd.action {
+	case "status":
+		result, err := cli.RunEngineStatus(context.Background(), runOpts)
+		if err != nil {
+			return err
+		}
+		if cmd.json || output == "json" {
+			if err := writeJSON(w, result); err != nil {
+				return err
+			}
+		} else {
+			cli.PrintEngineStatus(w, result)
+		}
+		if !result.Running {
+			return fmt.Errorf("engine not running")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package app
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sqlrs/cli/internal/cli"
+)
+
+// engineOverrides carries the prepare flags that configure an engine the CLI
+// autostarts. They have no effect when a running engine is reused, and
+// prepare warns about them then.
+type engineOverrides struct {
+	ContainerRuntime string
+	SnapshotBackend  string
+	StateStore       string
+	IdleTimeout      time.Duration
+}
+
+var engineOverrideFlags = []string{"--container-runtime", "--snapshot-backend", "--state-store", "--idle-timeout"}
+
+// extractEngineOverrides removes the engine override flags from prepare
+// arguments before the prepare parsers see them. Arguments after "--" belong
+// to psql or Liquibase and are left alone.
+func extractEngineOverrides(args []string) (engineOverrides, []string, error) {
+	var overrides engineOverrides
+	rest := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			rest = append(rest, args[i:]...)
+			break
+		}
+		name, value, hasValue, ok := matchEngineOverrideFlag(arg)
+		if !ok {
+			rest = append(rest, arg)
+			continue
+		}
+		if !hasValue {
+			if i+1 >= len(args) {
+				return overrides, nil, ExitErrorf(2, "Missing value for %s", name)
+			}
+			value = args[i+1]
+			i++
+		}
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return overrides, nil, ExitErrorf(2, "Missing value for %s", name)
+		}
+		switch name {
+		case "--container-runtime":
+			switch strings.ToLower(value) {
+			case "auto", "docker", "podman", "nerdctl", "containerd":
+				overrides.ContainerRuntime = strings.ToLower(value)
+			default:
+				return overrides, nil, ExitErrorf(2, "Invalid value for --container-runtime: %q (use auto, docker, podman or nerdctl)", value)
+			}
+		case "--snapshot-backend":
+			switch value {
+			case "auto", "overlay", "btrfs", "copy":
+				overrides.SnapshotBackend = value
+			default:
+				return overrides, nil, ExitErrorf(2, "Invalid value for --snapshot-backend: %q (use auto, overlay, btrfs or copy)", value)
+			}
+		case "--state-store":
+			overrides.StateStore = value
+		case "--idle-timeout":
+			parsed, err := time.ParseDuration(value)
+			if err != nil || parsed <= 0 {
+				return overrides, nil, ExitErrorf(2, "Invalid value for --idle-timeout: %q", value)
+			}
+			overrides.IdleTimeout = parsed
+		}
+	}
+	return overrides, rest, nil
+}
+
+func matchEngineOverrideFlag(arg string) (string, string, bool, bool) {
+	for _, name := range engineOverrideFlags {
+		if arg == name {
+			return name, "", false, true
+		}
+		if strings.HasPrefix(arg, name+"=") {
+			return name, strings.TrimPrefix(arg, name+"="), true, true
+		}
+	}
+	return "", "", false, false
+}
+
+// flags returns the names of the override flags that were given, in
+// engineOverrideFlags order.
+func (o engineOverrides) flags() []string {
+	var names []string
+	if o.ContainerRuntime != "" {
+		names = append(names, "--container-runtime")
+	}
+	if o.SnapshotBackend != "" {
+		names = append(names, "--snapshot-backend")
+	}
+	if o.StateStore != "" {
+		names = append(names, "--state-store")
+	}
+	if o.IdleTimeout > 0 {
+		names = append(names, "--idle-timeout")
+	}
+	return names
+}
+
+// applyEngineOverrides strips the engine override flags from the prepare
+// commands and records them on the command context. Relative state store
+// paths resolve from the invocation directory, except under WSL where the
+// path is read inside the distro.
+func applyEngineOverrides(cmdCtx commandContext, commands []cli.Command) (commandContext, error) {
+	for i, cmd := range commands {
+		if cmd.Name != "prepare" && !strings.HasPrefix(cmd.Name, "prepare:") {
+			continue
+		}
+		overrides, rest, err := extractEngineOverrides(cmd.Args)
+		if err != nil {
+			return cmdCtx, err
+		}
+		commands[i].Args = rest
+		if overrides.StateStore != "" && cmdCtx.wslDistro == "" && !filepath.IsAbs(overrides.StateStore) {
+			overrides.StateStore = filepath.Join(cmdCtx.cwd, overrides.StateStore)
+		}
+		cmdCtx.engineOverrides = overrides
+	}
+	return cmdCtx, nil
+}
@@ -0,0 +1,120 @@
+package app
+
+import (
+	"io"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sqlrs/cli/internal/cli"
+	"github.com/sqlrs/cli/internal/config"
+)
+
+func TestExtractEngineOverrides(t *testing.T) {
+	overrides, rest, err := extractEngineOverrides([]string{
+		"--container-runtime", "Podman",
+		"--snapshot-backend=copy",
+		"--image", "img",
+		"--state-store", "/srv/store",
+		"--idle-timeout=5m",
+		"--",
+		"--container-runtime", "docker",
+	})
+	if err != nil {
+		t.Fatalf("extractEngineOverrides: %v", err)
+	}
+	want := engineOverrides{ContainerRuntime: "podman", SnapshotBackend: "copy", StateStore: "/srv/store", IdleTimeout: 5 * time.Minute}
+	if overrides != want {
+		t.Fatalf("unexpected overrides: %+v", overrides)
+	}
+	if !reflect.DeepEqual(rest, []string{"--image", "img", "--", "--container-runtime", "docker"}) {
+		t.Fatalf("unexpected remaining args: %q", rest)
+	}
+}
+
+func TestExtractEngineOverridesRejectsInvalidValues(t *testing.T) {
+	cases := map[string][]string{
+		"Invalid value for --container-runtime": {"--container-runtime", "rkt"},
+		"Invalid value for --snapshot-backend":  {"--snapshot-backend=zfs"},
+		"Invalid value for --idle-timeout":      {"--idle-timeout", "soon"},
+		"Missing value for --state-store":       {"--state-store"},
+	}
+	for want, args := range cases {
+		_, _, err := extractEngineOverrides(args)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected %q for %q, got %v", want, args, err)
+		}
+	}
+}
+
+func TestApplyEngineOverridesOnlyTouchesPrepareCommands(t *testing.T) {
+	cwd := t.TempDir()
+	commands := []cli.Command{
+		{Name: "prepare", Args: []string{"--state-store", "store", "chinook"}},
+		{Name: "run", Args: []string{"--idle-timeout", "1m"}},
+	}
+	ctx, err := applyEngineOverrides(commandContext{cwd: cwd}, commands)
+	if err != nil {
+		t.Fatalf("applyEngineOverrides: %v", err)
+	}
+	if ctx.engineOverrides.StateStore != filepath.Join(cwd, "store") {
+		t.Fatalf("expected state store relative to cwd, got %q", ctx.engineOverrides.StateStore)
+	}
+	if !reflect.DeepEqual(commands[0].Args, []string{"chinook"}) || len(commands[1].Args) != 2 {
+		t.Fatalf("unexpected commands: %+v", commands)
+	}
+
+	wslCtx, err := applyEngineOverrides(commandContext{cwd: cwd, wslDistro: "Ubuntu"}, []cli.Command{{Name: "prepare:psql", Args: []string{"--state-store=store"}}})
+	if err != nil || wslCtx.engineOverrides.StateStore != "store" {
+		t.Fatalf("expected WSL state store to be kept as given, got %q err=%v", wslCtx.engineOverrides.StateStore, err)
+	}
+}
+
+func TestPrepareOptionsCarryEngineOverrides(t *testing.T) {
+	ctx := commandContext{idleTimeout: time.Minute, engineStoreDir: "/store"}
+	opts := ctx.prepareOptions(false)
+	if opts.IdleTimeout != time.Minute || opts.ContainerRuntime != "" || opts.StateStore != "" || len(opts.EngineOverrideFlags) != 0 {
+		t.Fatalf("unexpected defaults: %+v", opts)
+	}
+	ctx.engineOverrides = engineOverrides{ContainerRuntime: "podman", SnapshotBackend: "btrfs", StateStore: "/other", IdleTimeout: time.Hour}
+	opts = ctx.prepareOptions(false)
+	if opts.IdleTimeout != time.Hour || opts.ContainerRuntime != "podman" || opts.SnapshotBackend != "btrfs" || opts.StateStore != "/other" || opts.EngineStoreDir != "/store" {
+		t.Fatalf("unexpected overrides: %+v", opts)
+	}
+	want := []string{"--container-runtime", "--snapshot-backend", "--state-store", "--idle-timeout"}
+	if !reflect.DeepEqual(opts.EngineOverrideFlags, want) {
+		t.Fatalf("expected override flags %v, got %v", want, opts.EngineOverrideFlags)
+	}
+}
+
+func TestRunnerPassesEngineOverridesToPrepare(t *testing.T) {
+	cwd := t.TempDir()
+	var got cli.PrepareOptions
+	var gotArgs []string
+	err := runWithParsedCommands(t, cli.GlobalOptions{}, []cli.Command{
+		{Name: "prepare:psql", Args: []string{"--container-runtime", "podman", "--image", "img", "--", "-c", "select 1"}},
+	}, func(deps *runnerDeps) {
+		deps.getwd = func() (string, error) {
+			return cwd, nil
+		}
+		deps.resolveCommandContext = func(gotCwd string, opts cli.GlobalOptions) (commandContext, error) {
+			return testCommandContext(gotCwd, "human", false), nil
+		}
+		deps.runPrepare = func(_ io.Writer, _ io.Writer, opts cli.PrepareOptions, _ config.LoadedConfig, _ string, _ string, args []string) error {
+			got = opts
+			gotArgs = args
+			return nil
+		}
+	})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if got.ContainerRuntime != "podman" {
+		t.Fatalf("expected podman override, got %+v", got)
+	}
+	if !reflect.DeepEqual(gotArgs, []string{"--image", "img", "--", "-c", "select 1"}) {
+		t.Fatalf("unexpected prepare args: %q", gotArgs)
+	}
+}
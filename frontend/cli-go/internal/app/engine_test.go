@@ -0,0 +1,97 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sqlrs/cli/internal/cli"
+)
+
+func TestParseEngineArgsErrors(t *testing.T) {
+	cases := [][]string{
+		{},
+		{"unknown"},
+		{"status", "extra"},
+		{"status", "--unknown"},
+		{"status", "—json"},
+	}
+	for _, args := range cases {
+		_, _, err := parseEngineArgs(args)
+		var exitErr *ExitError
+		if !errors.As(err, &exitErr) || exitErr.Code != 2 {
+			t.Fatalf("args %v: expected ExitError code 2, got %v", args, err)
+		}
+	}
+}
+
+func TestParseEngineArgsHelp(t *testing.T) {
+	for _, args := range [][]string{{"--help"}, {"-h"}, {"status", "--help"}} {
+		_, showHelp, err := parseEngineArgs(args)
+		if err != nil || !showHelp {
+			t.Fatalf("args %v: expected help, err=%v help=%v", args, err, showHelp)
+		}
+	}
+}
+
+func TestRunEngineStatusOutputs(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/health":
+			io.WriteString(w, `{"ok":true,"version":"v1","instanceId":"inst","pid":42}`)
+		case "/v1/engine/stats":
+			io.WriteString(w, `{"version":"v1","instanceId":"inst","pid":42,"uptimeSeconds":60,"idleSeconds":3,"idleTimeoutSeconds":30,"runningJobs":2,"snapshotBackend":"copy"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	opts := cli.EngineOptions{Mode: "remote", Endpoint: server.URL, Timeout: time.Second}
+
+	var human bytes.Buffer
+	if err := runEngine(&human, opts, []string{"status"}, "human"); err != nil {
+		t.Fatalf("runEngine: %v", err)
+	}
+	if !strings.Contains(human.String(), "engine: running\n") || !strings.Contains(human.String(), "jobs: 2 running\n") {
+		t.Fatalf("unexpected human output: %q", human.String())
+	}
+
+	for _, tc := range []struct {
+		args   []string
+		output string
+	}{
+		{args: []string{"status", "--json"}, output: "human"},
+		{args: []string{"status"}, output: "json"},
+	} {
+		var jsonOut bytes.Buffer
+		if err := runEngine(&jsonOut, opts, tc.args, tc.output); err != nil {
+			t.Fatalf("runEngine %v: %v", tc.args, err)
+		}
+		var payload map[string]any
+		if err := json.Unmarshal(jsonOut.Bytes(), &payload); err != nil {
+			t.Fatalf("decode json output %q: %v", jsonOut.String(), err)
+		}
+		if payload["running"] != true || payload["runningJobs"] != float64(2) || payload["snapshotBackend"] != "copy" {
+			t.Fatalf("unexpected json output: %v", payload)
+		}
+	}
+}
+
+func TestRunEngineStatusNotRunningFails(t *testing.T) {
+	var out bytes.Buffer
+	err := runEngine(&out, cli.EngineOptions{Mode: "local", StateDir: t.TempDir(), Timeout: time.Second}, []string{"status"}, "human")
+	if err == nil || err.Error() != "engine not running" {
+		t.Fatalf("expected engine not running error, got %v", err)
+	}
+	if !strings.HasPrefix(out.String(), "engine: not running\n") {
+		t.Fatalf("unexpected output: %q", out.String())
+	}
+}
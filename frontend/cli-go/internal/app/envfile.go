@@ -0,0 +1,244 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var readEnvFileFn = os.ReadFile
+
+// resolvePrepareEnv merges base (the environment the CLI derives itself, e.g.
+// JAVA_HOME), the --env-file files in order and the --env overrides; later
+// sources win. Relative env file paths resolve from cwd. Values are sent
+// as-is, so ${secret:name} references reach the engine unresolved.
+func resolvePrepareEnv(base map[string]string, parsed prepareArgs, cwd string) (map[string]string, error) {
+	if len(parsed.EnvFiles) == 0 && len(parsed.Env) == 0 {
+		return base, nil
+	}
+	env := make(map[string]string, len(base))
+	for key, value := range base {
+		env[key] = value
+	}
+	for _, path := range parsed.EnvFiles {
+		if !filepath.IsAbs(path) && cwd != "" {
+			path = filepath.Join(cwd, path)
+		}
+		data, err := readEnvFileFn(path)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil, ExitErrorf(2, "env file not found: %s", path)
+			}
+			return nil, ExitErrorf(2, "cannot read env file %s: %v", path, err)
+		}
+		entries, err := parseDotenv(string(data))
+		if err != nil {
+			return nil, ExitErrorf(2, "env file %s: %v", path, err)
+		}
+		for key, value := range entries {
+			env[key] = value
+		}
+	}
+	for _, entry := range parsed.Env {
+		key, value, _ := strings.Cut(entry, "=")
+		env[key] = value
+	}
+	return env, nil
+}
+
+// parseEnvAssignment checks a --env KEY=VALUE argument.
+func parseEnvAssignment(value string) (string, error) {
+	key, _, ok := strings.Cut(value, "=")
+	if !ok || !isEnvKey(key) {
+		return "", ExitErrorf(2, "Invalid value for --env: %q (use KEY=VALUE)", value)
+	}
+	return value, nil
+}
+
+// parseDotenv reads dotenv content: KEY=VALUE lines with an optional
+// "export " prefix, blank lines and # comments. Unquoted values end at a
+// " #" comment and are trimmed. Single-quoted values are literal; double-
+// quoted values understand \n, \r, \t, \", \\ and \$. Quoted values may span
+// lines. Nothing is interpolated.
+func parseDotenv(data string) (map[string]string, error) {
+	data = strings.TrimPrefix(data, "\ufeff")
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	env := map[string]string{}
+	p := dotenvParser{data: data, line: 1}
+	for !p.done() {
+		p.skipBlank()
+		if p.done() {
+			break
+		}
+		if p.peek() == '#' {
+			p.skipLine()
+			continue
+		}
+		line := p.line
+		key, err := p.key()
+		if err != nil {
+			return nil, err
+		}
+		value, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		if !isEnvKey(key) {
+			return nil, dotenvError(line, "invalid key %q", key)
+		}
+		env[key] = value
+	}
+	return env, nil
+}
+
+type dotenvParser struct {
+	data string
+	pos  int
+	line int
+}
+
+func (p *dotenvParser) done() bool {
+	return p.pos >= len(p.data)
+}
+
+func (p *dotenvParser) peek() byte {
+	return p.data[p.pos]
+}
+
+func (p *dotenvParser) next() byte {
+	ch := p.data[p.pos]
+	p.pos++
+	if ch == '\n' {
+		p.line++
+	}
+	return ch
+}
+
+// skipBlank skips whitespace, including newlines.
+func (p *dotenvParser) skipBlank() {
+	for !p.done() && strings.IndexByte(" \t\r\n", p.peek()) >= 0 {
+		p.next()
+	}
+}
+
+// skipSpace skips spaces and tabs on the current line.
+func (p *dotenvParser) skipSpace() {
+	for !p.done() && (p.peek() == ' ' || p.peek() == '\t') {
+		p.next()
+	}
+}
+
+// skipLine moves past the next newline.
+func (p *dotenvParser) skipLine() {
+	for !p.done() && p.next() != '\n' {
+	}
+}
+
+// key reads "[export ]KEY=" and leaves the parser at the value.
+func (p *dotenvParser) key() (string, error) {
+	line := p.line
+	end := strings.IndexAny(p.data[p.pos:], "=\n")
+	if end < 0 || p.data[p.pos+end] != '=' {
+		rest := p.data[p.pos:]
+		if end >= 0 {
+			rest = rest[:end]
+		}
+		return "", dotenvError(line, "expected KEY=VALUE, got %q", strings.TrimSpace(rest))
+	}
+	key := strings.TrimSpace(p.data[p.pos : p.pos+end])
+	if rest, ok := strings.CutPrefix(key, "export"); ok && rest != "" && (rest[0] == ' ' || rest[0] == '\t') {
+		key = strings.TrimSpace(rest)
+	}
+	p.pos += end + 1
+	return key, nil
+}
+
+func (p *dotenvParser) value() (string, error) {
+	start := p.pos
+	p.skipSpace()
+	if p.done() {
+		return "", nil
+	}
+	if p.peek() == '#' && p.pos > start {
+		// KEY= # comment
+		p.skipLine()
+		return "", nil
+	}
+	line := p.line
+	switch quote := p.peek(); quote {
+	case '\'', '"':
+		p.next()
+		var b strings.Builder
+		for {
+			if p.done() {
+				return "", dotenvError(line, "unterminated %c quote", quote)
+			}
+			ch := p.next()
+			if ch == quote {
+				break
+			}
+			if ch == '\\' && quote == '"' && !p.done() {
+				escaped := p.next()
+				switch escaped {
+				case 'n':
+					b.WriteByte('\n')
+				case 'r':
+					b.WriteByte('\r')
+				case 't':
+					b.WriteByte('\t')
+				case '"', '\\', '$':
+					b.WriteByte(escaped)
+				default:
+					b.WriteByte('\\')
+					b.WriteByte(escaped)
+				}
+				continue
+			}
+			b.WriteByte(ch)
+		}
+		p.skipSpace()
+		if !p.done() && p.peek() != '\n' && p.peek() != '#' {
+			return "", dotenvError(p.line, "unexpected text after closing %c quote", quote)
+		}
+		p.skipLine()
+		return b.String(), nil
+	default:
+		end := strings.IndexByte(p.data[p.pos:], '\n')
+		if end < 0 {
+			end = len(p.data) - p.pos
+		}
+		value := p.data[p.pos : p.pos+end]
+		for i := 1; i < len(value); i++ {
+			if value[i] == '#' && (value[i-1] == ' ' || value[i-1] == '\t') {
+				value = value[:i]
+				break
+			}
+		}
+		p.pos += end
+		p.skipLine()
+		return strings.TrimRight(value, " \t"), nil
+	}
+}
+
+func dotenvError(line int, format string, args ...any) error {
+	return fmt.Errorf("line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+func isEnvKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		ch := key[i]
+		switch {
+		case ch == '_', ch >= 'A' && ch <= 'Z', ch >= 'a' && ch <= 'z':
+		case ch >= '0' && ch <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,301 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sqlrs/cli/internal/cli"
+	"github.com/sqlrs/cli/internal/config"
+	"github.com/sqlrs/cli/internal/paths"
+	"github.com/sqlrs/cli/internal/refctx"
+)
+
+func TestParseDotenvQuotedValues(t *testing.T) {
+	env, err := parseDotenv(strings.Join([]string{
+		`SINGLE='a # b \n $HOME'`,
+		`DOUBLE="line1\nline2 \"quoted\" \$HOME \\ \x"`,
+		`SPACED = "  padded  "`,
+		`MULTI="first`,
+		`second"`,
+		`EMPTY_QUOTED=""`,
+		`SECRET="${secret:db_password}"`,
+	}, "\n"))
+	if err != nil {
+		t.Fatalf("parseDotenv: %v", err)
+	}
+	want := map[string]string{
+		"SINGLE":       `a # b \n $HOME`,
+		"DOUBLE":       "line1\nline2 \"quoted\" $HOME \\ \\x",
+		"SPACED":       "  padded  ",
+		"MULTI":        "first\nsecond",
+		"EMPTY_QUOTED": "",
+		"SECRET":       "${secret:db_password}",
+	}
+	if !reflect.DeepEqual(env, want) {
+		t.Fatalf("unexpected env:\n got %#v\nwant %#v", env, want)
+	}
+}
+
+func TestParseDotenvCommentsAndExport(t *testing.T) {
+	env, err := parseDotenv("\ufeff# leading comment\r\n" +
+		"\r\n" +
+		"   # indented comment\n" +
+		"export LIQUIBASE_COMMAND_USERNAME=app\n" +
+		"PLAIN=value # trailing comment\n" +
+		"HASH=a#b\n" +
+		"QUOTED='x' # trailing comment\n" +
+		"BLANK= # only a comment\n" +
+		"EMPTY=\n" +
+		"export=kept\n" +
+		"LAST=no newline")
+	if err != nil {
+		t.Fatalf("parseDotenv: %v", err)
+	}
+	want := map[string]string{
+		"LIQUIBASE_COMMAND_USERNAME": "app",
+		"PLAIN":                      "value",
+		"HASH":                       "a#b",
+		"QUOTED":                     "x",
+		"BLANK":                      "",
+		"EMPTY":                      "",
+		"export":                     "kept",
+		"LAST":                       "no newline",
+	}
+	if !reflect.DeepEqual(env, want) {
+		t.Fatalf("unexpected env:\n got %#v\nwant %#v", env, want)
+	}
+}
+
+func TestParseDotenvExportPrefix(t *testing.T) {
+	env, err := parseDotenv(strings.Join([]string{
+		"export PLAIN=a",
+		"export\tTABBED=b",
+		"  export   SPACED = c",
+		"export QUOTED='d e'",
+		"exported=f",
+		"EXPORT_ME=g",
+	}, "\n"))
+	if err != nil {
+		t.Fatalf("parseDotenv: %v", err)
+	}
+	want := map[string]string{
+		"PLAIN":     "a",
+		"TABBED":    "b",
+		"SPACED":    "c",
+		"QUOTED":    "d e",
+		"exported":  "f",
+		"EXPORT_ME": "g",
+	}
+	if !reflect.DeepEqual(env, want) {
+		t.Fatalf("unexpected env:\n got %#v\nwant %#v", env, want)
+	}
+	if _, err := parseDotenv("export ONLY_NAME\n"); err == nil || !strings.Contains(err.Error(), "line 1: expected KEY=VALUE") {
+		t.Fatalf("expected an error for export without a value, got %v", err)
+	}
+}
+
+func TestParseDotenvSingleVersusDoubleQuotes(t *testing.T) {
+	env, err := parseDotenv(strings.Join([]string{
+		`SINGLE='tab\there \$HOME \"x\"'`,
+		`DOUBLE="tab\there \$HOME \"x\""`,
+		`SINGLE_DQ='say "hi"'`,
+		`DOUBLE_SQ="it's"`,
+	}, "\n"))
+	if err != nil {
+		t.Fatalf("parseDotenv: %v", err)
+	}
+	want := map[string]string{
+		"SINGLE":    `tab\there \$HOME \"x\"`,
+		"DOUBLE":    "tab\there $HOME \"x\"",
+		"SINGLE_DQ": `say "hi"`,
+		"DOUBLE_SQ": "it's",
+	}
+	if !reflect.DeepEqual(env, want) {
+		t.Fatalf("unexpected env:\n got %#v\nwant %#v", env, want)
+	}
+}
+
+func TestParseDotenvHashInsideQuotes(t *testing.T) {
+	env, err := parseDotenv(strings.Join([]string{
+		`DOUBLE="pass # word"`,
+		`SINGLE='pass # word'`,
+		`DOUBLE_COMMENT="a#b" # trailing comment`,
+		`SINGLE_COMMENT='a # b'# trailing comment`,
+		`UNQUOTED=pass # word`,
+	}, "\n"))
+	if err != nil {
+		t.Fatalf("parseDotenv: %v", err)
+	}
+	want := map[string]string{
+		"DOUBLE":         "pass # word",
+		"SINGLE":         "pass # word",
+		"DOUBLE_COMMENT": "a#b",
+		"SINGLE_COMMENT": "a # b",
+		"UNQUOTED":       "pass",
+	}
+	if !reflect.DeepEqual(env, want) {
+		t.Fatalf("unexpected env:\n got %#v\nwant %#v", env, want)
+	}
+}
+
+func TestParseDotenvErrors(t *testing.T) {
+	cases := []struct {
+		name string
+		data string
+		want string
+	}{
+		{name: "missing equals", data: "A=1\nNOVALUE\n", want: "line 2: expected KEY=VALUE"},
+		{name: "invalid key", data: "1ABC=x\n", want: `line 1: invalid key "1ABC"`},
+		{name: "key with space", data: "MY KEY=x\n", want: "invalid key"},
+		{name: "unterminated", data: "A=1\nB=\"open\nstill open\n", want: "line 2: unterminated \" quote"},
+		{name: "text after quote", data: "A='x' y\n", want: "unexpected text after closing ' quote"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := parseDotenv(tc.data)
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("expected %q, got %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func TestResolvePrepareEnvMergesFilesAndOverrides(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "base.env"), []byte("A=file1\nB=file1\nJAVA_HOME=/opt/file-java\n"), 0o600); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "local.env"), []byte("B=file2\n"), 0o600); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+	base := map[string]string{"JAVA_HOME": "/opt/java"}
+	env, err := resolvePrepareEnv(base, prepareArgs{
+		EnvFiles: []string{"base.env", filepath.Join(dir, "local.env")},
+		Env:      []string{"A=flag", "C=a=b"},
+	}, dir)
+	if err != nil {
+		t.Fatalf("resolvePrepareEnv: %v", err)
+	}
+	want := map[string]string{"JAVA_HOME": "/opt/file-java", "A": "flag", "B": "file2", "C": "a=b"}
+	if !reflect.DeepEqual(env, want) {
+		t.Fatalf("unexpected env:\n got %#v\nwant %#v", env, want)
+	}
+	if base["JAVA_HOME"] != "/opt/java" {
+		t.Fatalf("expected base env to stay untouched, got %#v", base)
+	}
+
+	if env, err := resolvePrepareEnv(base, prepareArgs{}, dir); err != nil || !reflect.DeepEqual(env, base) {
+		t.Fatalf("expected base env without env options, got %#v err=%v", env, err)
+	}
+}
+
+func TestResolvePrepareEnvMissingFile(t *testing.T) {
+	dir := t.TempDir()
+	_, err := resolvePrepareEnv(nil, prepareArgs{EnvFiles: []string{"missing.env"}}, dir)
+	if err == nil || !strings.Contains(err.Error(), "env file not found: "+filepath.Join(dir, "missing.env")) {
+		t.Fatalf("expected missing env file error, got %v", err)
+	}
+	if exitErr, ok := err.(*ExitError); !ok || exitErr.Code != 2 {
+		t.Fatalf("expected exit code 2, got %#v", err)
+	}
+	absent := filepath.Join(dir, "nested", "absent.env")
+	if _, err := resolvePrepareEnv(nil, prepareArgs{EnvFiles: []string{absent}}, "/elsewhere"); err == nil || !strings.Contains(err.Error(), "env file not found: "+absent) {
+		t.Fatalf("expected missing absolute env file error, got %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "bad.env"), []byte("NOPE\n"), 0o600); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+	_, err = resolvePrepareEnv(nil, prepareArgs{EnvFiles: []string{"bad.env"}}, dir)
+	if err == nil || !strings.Contains(err.Error(), "bad.env: line 1: expected KEY=VALUE") {
+		t.Fatalf("expected parse error with file and line, got %v", err)
+	}
+}
+
+func TestParsePrepareArgsEnvFlags(t *testing.T) {
+	parsed, _, err := parsePrepareArgs([]string{"--env-file", "a.env", "--env-file=b.env", "--env", "A=1", "--env=B=", "--", "update"})
+	if err != nil {
+		t.Fatalf("parsePrepareArgs: %v", err)
+	}
+	if !reflect.DeepEqual(parsed.EnvFiles, []string{"a.env", "b.env"}) || !reflect.DeepEqual(parsed.Env, []string{"A=1", "B="}) {
+		t.Fatalf("unexpected env options: files=%v env=%v", parsed.EnvFiles, parsed.Env)
+	}
+	if !reflect.DeepEqual(parsed.PsqlArgs, []string{"update"}) {
+		t.Fatalf("unexpected args: %v", parsed.PsqlArgs)
+	}
+
+	// --env has no -e short form: -e reaches the tool, where psql reads it as
+	// --echo-queries.
+	parsed, _, err = parsePrepareArgs([]string{"-e", "-f", "init.sql"})
+	if err != nil || len(parsed.Env) != 0 || !reflect.DeepEqual(parsed.PsqlArgs, []string{"-e", "-f", "init.sql"}) {
+		t.Fatalf("expected -e to reach the tool args, got env=%v args=%v err=%v", parsed.Env, parsed.PsqlArgs, err)
+	}
+
+	for _, args := range [][]string{{"--env-file"}, {"--env-file="}, {"--env"}, {"--env", "NOEQUALS"}, {"--env==x"}} {
+		if _, _, err := parsePrepareArgs(args); err == nil {
+			t.Fatalf("expected error for %v", args)
+		}
+	}
+}
+
+func TestStagePipelineLiquibaseSendsEnvFile(t *testing.T) {
+	t.Setenv("JAVA_HOME", "")
+	cwd := t.TempDir()
+	if err := os.WriteFile(filepath.Join(cwd, ".env"), []byte("LIQUIBASE_COMMAND_USERNAME=app\nLIQUIBASE_COMMAND_PASSWORD=${secret:db}\n"), 0o600); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+
+	prevBind := bindPrepareLiquibaseInputsFn
+	bindPrepareLiquibaseInputsFn = func(_ cli.PrepareOptions, _ string, cwd string, _ prepareArgs, _ *refctx.Context, _ string, _ string, _ bool) (prepareStageBinding, error) {
+		return prepareStageBinding{LiquibaseArgs: []string{"update"}, WorkDir: cwd}, nil
+	}
+	t.Cleanup(func() { bindPrepareLiquibaseInputsFn = prevBind })
+
+	var got map[string]string
+	prevRunPlan := runPlanFn
+	runPlanFn = func(_ context.Context, opts cli.PrepareOptions) (cli.PlanResult, error) {
+		got = opts.LiquibaseEnv
+		return cli.PlanResult{PrepareKind: "lb", ImageID: "img"}, nil
+	}
+	t.Cleanup(func() { runPlanFn = prevRunPlan })
+
+	err := runPlanKindParsedWithPathMode(&bytes.Buffer{}, io.Discard, cli.PrepareOptions{}, config.LoadedConfig{
+		Paths: paths.Dirs{ConfigDir: t.TempDir()},
+	}, cwd, cwd, prepareArgs{
+		Image:    "img",
+		PsqlArgs: []string{"update"},
+		EnvFiles: []string{".env"},
+		Env:      []string{"LIQUIBASE_COMMAND_USERNAME=override"},
+	}, nil, "json", "lb", true)
+	if err != nil {
+		t.Fatalf("runPlanKindParsedWithPathMode: %v", err)
+	}
+	want := map[string]string{"LIQUIBASE_COMMAND_USERNAME": "override", "LIQUIBASE_COMMAND_PASSWORD": "${secret:db}"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected liquibase env:\n got %#v\nwant %#v", got, want)
+	}
+}
+
+func TestStagePipelineRejectsEnvFileForPsql(t *testing.T) {
+	prevBind := bindPreparePsqlInputsFn
+	bindPreparePsqlInputsFn = func(cli.PrepareOptions, string, string, prepareArgs, *refctx.Context, io.Reader) (prepareStageBinding, error) {
+		t.Fatal("bindPreparePsqlInputsFn should not be called")
+		return prepareStageBinding{}, nil
+	}
+	t.Cleanup(func() { bindPreparePsqlInputsFn = prevBind })
+
+	err := runPlanKindParsedWithPathMode(&bytes.Buffer{}, io.Discard, cli.PrepareOptions{}, config.LoadedConfig{}, "", "", prepareArgs{
+		Image:    "img",
+		PsqlArgs: []string{"-c", "select 1"},
+		EnvFiles: []string{".env"},
+	}, nil, "json", "psql", true)
+	if err == nil || !strings.Contains(err.Error(), "--env-file and --env are only supported for liquibase") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
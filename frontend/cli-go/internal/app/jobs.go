@@ -0,0 +1,189 @@
+package app
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/sqlrs/cli/internal/cli"
+)
+
+type jobsCommand struct {
+	action string
+	jobID  string
+	logs   cli.JobsLogsOptions
+	labels map[string]string
+}
+
+// labelFlags collects repeated --label key=value flags.
+type labelFlags map[string]string
+
+func (l labelFlags) String() string {
+	keys := make([]string, 0, len(l))
+	for key := range l {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, key+"="+l[key])
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (l labelFlags) Set(value string) error {
+	key, val, ok := strings.Cut(value, "=")
+	key = strings.TrimSpace(key)
+	if !ok || key == "" {
+		return fmt.Errorf("label must be key=value: %q", value)
+	}
+	l[key] = strings.TrimSpace(val)
+	return nil
+}
+
+func parseJobsArgs(args []string) (jobsCommand, bool, error) {
+	var cmd jobsCommand
+	if err := validateNoUnicodeDashFlags(args, 2); err != nil {
+		return cmd, false, err
+	}
+	if len(args) == 0 {
+		return cmd, false, ExitErrorf(2, "Missing jobs command")
+	}
+	action := strings.TrimSpace(args[0])
+	switch action {
+	case "--help", "-h":
+		return cmd, true, nil
+	case "logs":
+		fs := flag.NewFlagSet("sqlrs jobs logs", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+
+		follow := fs.Bool("follow", false, "stream events until the job finishes")
+		sinceOffset := fs.Int("since-offset", 0, "skip events before this offset")
+		help := fs.Bool("help", false, "show help")
+		helpShort := fs.Bool("h", false, "show help")
+
+		flags, positionals := splitJobsLogsArgs(args[1:])
+		if err := fs.Parse(flags); err != nil {
+			return cmd, false, ExitErrorf(2, "Invalid arguments: %v", err)
+		}
+		if *help || *helpShort {
+			return cmd, true, nil
+		}
+		if len(positionals) == 0 || strings.TrimSpace(positionals[0]) == "" {
+			return cmd, false, ExitErrorf(2, "Missing prepare job id")
+		}
+		if len(positionals) > 1 {
+			return cmd, false, ExitErrorf(2, "jobs logs accepts exactly one job id")
+		}
+		if *sinceOffset < 0 {
+			return cmd, false, ExitErrorf(2, "Invalid --since-offset: %d", *sinceOffset)
+		}
+		cmd = jobsCommand{
+			action: "logs",
+			jobID:  strings.TrimSpace(positionals[0]),
+			logs:   cli.JobsLogsOptions{Follow: *follow, SinceOffset: *sinceOffset},
+		}
+	case "show", "wait":
+		fs := flag.NewFlagSet("sqlrs jobs "+action, flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+
+		help := fs.Bool("help", false, "show help")
+		helpShort := fs.Bool("h", false, "show help")
+
+		flags, positionals := splitJobsLogsArgs(args[1:])
+		if err := fs.Parse(flags); err != nil {
+			return cmd, false, ExitErrorf(2, "Invalid arguments: %v", err)
+		}
+		if *help || *helpShort {
+			return cmd, true, nil
+		}
+		if len(positionals) == 0 || strings.TrimSpace(positionals[0]) == "" {
+			return cmd, false, ExitErrorf(2, "Missing prepare job id")
+		}
+		if len(positionals) > 1 {
+			return cmd, false, ExitErrorf(2, "jobs %s accepts exactly one job id", action)
+		}
+		cmd = jobsCommand{action: action, jobID: strings.TrimSpace(positionals[0])}
+	case "list", "delete":
+		fs := flag.NewFlagSet("sqlrs jobs "+action, flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+
+		labels := labelFlags{}
+		fs.Var(labels, "label", "filter by label key=value (repeatable)")
+		help := fs.Bool("help", false, "show help")
+		helpShort := fs.Bool("h", false, "show help")
+
+		if err := fs.Parse(args[1:]); err != nil {
+			return cmd, false, ExitErrorf(2, "Invalid arguments: %v", err)
+		}
+		if *help || *helpShort {
+			return cmd, true, nil
+		}
+		if fs.NArg() > 0 {
+			return cmd, false, ExitErrorf(2, "jobs %s does not accept arguments", action)
+		}
+		if action == "delete" && len(labels) == 0 {
+			return cmd, false, ExitErrorf(2, "jobs delete requires at least one --label")
+		}
+		cmd = jobsCommand{action: action, labels: labels}
+	default:
+		return cmd, false, ExitErrorf(2, "Unknown jobs command: %s", action)
+	}
+	return cmd, false, nil
+}
+
+// splitJobsLogsArgs separates flags from the job id so the id may appear
+// before or after the flags.
+func splitJobsLogsArgs(args []string) ([]string, []string) {
+	flags := make([]string, 0, len(args))
+	positionals := make([]string, 0, 1)
+	inPositionals := false
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if inPositionals {
+			positionals = append(positionals, arg)
+			continue
+		}
+		if arg == "--" {
+			inPositionals = true
+			continue
+		}
+		if strings.HasPrefix(arg, "-") {
+			flags = append(flags, arg)
+			if (arg == "--since-offset" || arg == "-since-offset") && i+1 < len(args) {
+				flags = append(flags, args[i+1])
+				i++
+			}
+			continue
+		}
+		positionals = append(positionals, arg)
+	}
+	return flags, positionals
+}
+
+func runJobs(w io.Writer, runOpts cli.PrepareOptions, args []string) error {
+	cmd, showHelp, err := parseJobsArgs(args)
+	if err != nil {
+		return err
+	}
+	if showHelp {
+		cli.PrintJobsUsage(w)
+		return nil
+	}
+	switch cmd.action {
+	case "logs":
+		return cli.RunJobsLogs(context.Background(), w, runOpts, cmd.jobID, cmd.logs)
+	case "show":
+		return cli.RunJobsShow(context.Background(), w, runOpts, cmd.jobID)
+	case "wait":
+		return waitPrepareJob(w, runOpts, cmd.jobID)
+	case "list":
+		return cli.RunJobsList(context.Background(), w, runOpts, cmd.labels)
+	case "delete":
+		return cli.RunJobsDelete(context.Background(), w, runOpts, cmd.labels)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package app
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sqlrs/cli/internal/cli"
+)
+
+func TestParseJobsArgsLogs(t *testing.T) {
+	for _, args := range [][]string{
+		{"logs", "job-1", "--follow", "--since-offset", "4"},
+		{"logs", "--follow", "--since-offset=4", "job-1"},
+	} {
+		cmd, showHelp, err := parseJobsArgs(args)
+		if err != nil {
+			t.Fatalf("parseJobsArgs(%v): %v", args, err)
+		}
+		if showHelp {
+			t.Fatalf("expected showHelp=false")
+		}
+		want := jobsCommand{action: "logs", jobID: "job-1", logs: cli.JobsLogsOptions{Follow: true, SinceOffset: 4}}
+		if !reflect.DeepEqual(cmd, want) {
+			t.Fatalf("parseJobsArgs(%v) = %+v, want %+v", args, cmd, want)
+		}
+	}
+}
+
+func TestParseJobsArgsShow(t *testing.T) {
+	cmd, showHelp, err := parseJobsArgs([]string{"show", "job-1"})
+	if err != nil || showHelp {
+		t.Fatalf("parseJobsArgs: cmd=%+v help=%v err=%v", cmd, showHelp, err)
+	}
+	if want := (jobsCommand{action: "show", jobID: "job-1"}); !reflect.DeepEqual(cmd, want) {
+		t.Fatalf("parseJobsArgs = %+v, want %+v", cmd, want)
+	}
+	if _, showHelp, err := parseJobsArgs([]string{"show", "--help"}); err != nil || !showHelp {
+		t.Fatalf("expected help for jobs show, got help=%v err=%v", showHelp, err)
+	}
+}
+
+func TestParseJobsArgsWait(t *testing.T) {
+	cmd, showHelp, err := parseJobsArgs([]string{"wait", "job-1"})
+	if err != nil || showHelp {
+		t.Fatalf("parseJobsArgs: cmd=%+v help=%v err=%v", cmd, showHelp, err)
+	}
+	if want := (jobsCommand{action: "wait", jobID: "job-1"}); !reflect.DeepEqual(cmd, want) {
+		t.Fatalf("parseJobsArgs = %+v, want %+v", cmd, want)
+	}
+	if _, _, err := parseJobsArgs([]string{"wait"}); err == nil || !strings.Contains(err.Error(), "Missing prepare job id") {
+		t.Fatalf("expected missing job id error, got %v", err)
+	}
+}
+
+func TestParseJobsArgsLabels(t *testing.T) {
+	cmd, showHelp, err := parseJobsArgs([]string{"delete", "--label", "pr=1234", "--label=branch=feat"})
+	if err != nil || showHelp {
+		t.Fatalf("parseJobsArgs: cmd=%+v help=%v err=%v", cmd, showHelp, err)
+	}
+	want := jobsCommand{action: "delete", labels: map[string]string{"pr": "1234", "branch": "feat"}}
+	if !reflect.DeepEqual(cmd, want) {
+		t.Fatalf("parseJobsArgs = %+v, want %+v", cmd, want)
+	}
+	cmd, _, err = parseJobsArgs([]string{"list"})
+	if err != nil || cmd.action != "list" || len(cmd.labels) != 0 {
+		t.Fatalf("expected unfiltered list, got %+v err=%v", cmd, err)
+	}
+}
+
+func TestParseJobsArgsErrors(t *testing.T) {
+	cases := map[string][]string{
+		"Missing jobs command":       nil,
+		"Unknown jobs command":       {"tail"},
+		"Missing prepare job id":     {"logs", "--follow"},
+		"accepts exactly one job id": {"logs", "job-1", "job-2"},
+		"Invalid --since-offset":     {"logs", "job-1", "--since-offset", "-1"},
+		"Invalid arguments":          {"logs", "job-1", "--bogus"},
+		"jobs show accepts exactly":  {"show", "job-1", "job-2"},
+		"jobs wait accepts exactly":  {"wait", "job-1", "job-2"},
+		"requires at least one":      {"delete"},
+		"label must be key=value":    {"list", "--label", "pr"},
+		"does not accept arguments":  {"list", "job-1"},
+	}
+	for want, args := range cases {
+		_, _, err := parseJobsArgs(args)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("parseJobsArgs(%v): expected %q, got %v", args, want, err)
+		}
+	}
+}
+
+func TestRunJobsHelp(t *testing.T) {
+	var out bytes.Buffer
+	if err := runJobs(&out, cli.PrepareOptions{}, []string{"logs", "--help"}); err != nil {
+		t.Fatalf("runJobs: %v", err)
+	}
+	if !strings.Contains(out.String(), "sqlrs jobs logs <job-id>") {
+		t.Fatalf("expected jobs usage, got %q", out.String())
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 
 	"github.com/sqlrs/cli/internal/cli"
@@ -24,6 +25,7 @@ var runPlanFn = cli.RunPlan
 var submitPrepareFn = cli.SubmitPrepare
 var bindPreparePsqlInputsFn = bindPreparePsqlInputs
 var bindPrepareLiquibaseInputsFn = bindPrepareLiquibaseInputs
+var readLiquibaseStdinFn = readLiquibaseStdin
 
 type prepareArgs struct {
 	Image           string
@@ -34,6 +36,17 @@ type prepareArgs struct {
 	Ref             string
 	RefMode         string
 	RefKeepWorktree bool
+	// ChangelogFromStdin sends stdin as the Liquibase changelog, in
+	// ChangelogFormat (default xml); prepare:lb and plan:lb only.
+	ChangelogFromStdin bool
+	ChangelogFormat    string
+	// EnvFiles are dotenv files and Env KEY=VALUE entries merged into the
+	// Liquibase environment, Env last; prepare:lb and plan:lb only.
+	EnvFiles []string
+	Env      []string
+	// CancelOnDisconnect asks the engine to cancel the job once the CLI stops
+	// watching it; watch mode only.
+	CancelOnDisconnect bool
 }
 
 type stdoutAndErr struct {
@@ -57,7 +70,7 @@ func parsePrepareArgs(args []string) (prepareArgs, bool, error) {
 				return opts, false, err
 			}
 			opts.RefMode = mode
-			if err := validatePrepareRefWatch(opts.Ref, opts.WatchSpecified, opts.Watch); err != nil {
+			if err := validatePrepareWatch(opts); err != nil {
 				return opts, false, err
 			}
 			return opts, false, nil
@@ -71,6 +84,13 @@ func parsePrepareArgs(args []string) (prepareArgs, bool, error) {
 		case arg == "--no-watch":
 			opts.Watch = false
 			opts.WatchSpecified = true
+		case arg == "--wait" || strings.HasPrefix(arg, "--wait="):
+			watch, err := parseWaitFlag(arg)
+			if err != nil {
+				return opts, false, err
+			}
+			opts.Watch = watch
+			opts.WatchSpecified = true
 		case arg == "--provenance-path":
 			if i+1 >= len(args) {
 				return opts, false, ExitErrorf(2, "Missing value for --provenance-path")
@@ -99,6 +119,51 @@ func parsePrepareArgs(args []string) (prepareArgs, bool, error) {
 			i++
 		case arg == "--ref-keep-worktree":
 			opts.RefKeepWorktree = true
+		case arg == "--cancel-on-disconnect":
+			opts.CancelOnDisconnect = true
+		case arg == "--changelog-from-stdin":
+			opts.ChangelogFromStdin = true
+		case strings.HasPrefix(arg, "--changelog-from-stdin="):
+			value := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(arg, "--changelog-from-stdin=")))
+			switch value {
+			case "xml", "yaml", "yml", "json", "sql":
+			default:
+				return opts, false, ExitErrorf(2, "Invalid value for --changelog-from-stdin: %q (use xml, yaml, json or sql)", value)
+			}
+			opts.ChangelogFromStdin = true
+			opts.ChangelogFormat = value
+		case arg == "--env-file":
+			if i+1 >= len(args) {
+				return opts, false, ExitErrorf(2, "Missing value for --env-file")
+			}
+			value := strings.TrimSpace(args[i+1])
+			if value == "" {
+				return opts, false, ExitErrorf(2, "Missing value for --env-file")
+			}
+			opts.EnvFiles = append(opts.EnvFiles, value)
+			i++
+		case strings.HasPrefix(arg, "--env-file="):
+			value := strings.TrimSpace(strings.TrimPrefix(arg, "--env-file="))
+			if value == "" {
+				return opts, false, ExitErrorf(2, "Missing value for --env-file")
+			}
+			opts.EnvFiles = append(opts.EnvFiles, value)
+		case arg == "--env":
+			if i+1 >= len(args) {
+				return opts, false, ExitErrorf(2, "Missing value for --env")
+			}
+			value, err := parseEnvAssignment(args[i+1])
+			if err != nil {
+				return opts, false, err
+			}
+			opts.Env = append(opts.Env, value)
+			i++
+		case strings.HasPrefix(arg, "--env="):
+			value, err := parseEnvAssignment(strings.TrimPrefix(arg, "--env="))
+			if err != nil {
+				return opts, false, err
+			}
+			opts.Env = append(opts.Env, value)
 		case arg == "--image":
 			if i+1 >= len(args) {
 				return opts, false, ExitErrorf(2, "Missing value for --image")
@@ -122,7 +187,7 @@ func parsePrepareArgs(args []string) (prepareArgs, bool, error) {
 				return opts, false, err
 			}
 			opts.RefMode = mode
-			if err := validatePrepareRefWatch(opts.Ref, opts.WatchSpecified, opts.Watch); err != nil {
+			if err := validatePrepareWatch(opts); err != nil {
 				return opts, false, err
 			}
 			return opts, false, nil
@@ -133,12 +198,23 @@ func parsePrepareArgs(args []string) (prepareArgs, bool, error) {
 		return opts, false, err
 	}
 	opts.RefMode = mode
-	if err := validatePrepareRefWatch(opts.Ref, opts.WatchSpecified, opts.Watch); err != nil {
+	if err := validatePrepareWatch(opts); err != nil {
 		return opts, false, err
 	}
 	return opts, false, nil
 }
 
+// validatePrepareWatch rejects options that need the CLI to watch the job.
+func validatePrepareWatch(opts prepareArgs) error {
+	if err := validatePrepareRefWatch(opts.Ref, opts.WatchSpecified, opts.Watch); err != nil {
+		return err
+	}
+	if opts.CancelOnDisconnect && !opts.Watch {
+		return ExitErrorf(2, "--cancel-on-disconnect requires watch mode")
+	}
+	return nil
+}
+
 func normalizeRefMode(ref string, refMode string, refKeepWorktree bool) (string, error) {
 	ref = strings.TrimSpace(ref)
 	refMode = strings.TrimSpace(refMode)
@@ -163,6 +239,20 @@ func normalizeRefMode(ref string, refMode string, refKeepWorktree bool) (string,
 	return refMode, nil
 }
 
+// parseWaitFlag reads --wait[=<bool>], a spelling of --watch/--no-watch for
+// scripts that pass the mode as a value.
+func parseWaitFlag(arg string) (bool, error) {
+	value, ok := strings.CutPrefix(arg, "--wait=")
+	if !ok {
+		return true, nil
+	}
+	wait, err := strconv.ParseBool(strings.TrimSpace(value))
+	if err != nil {
+		return false, ExitErrorf(2, "Invalid value for --wait: %q", value)
+	}
+	return wait, nil
+}
+
 func validatePrepareRefWatch(ref string, watchSpecified bool, watch bool) error {
 	if strings.TrimSpace(ref) == "" || !watchSpecified || watch {
 		return nil
@@ -178,8 +268,7 @@ func runPrepare(stdout, stderr io.Writer, runOpts cli.PrepareOptions, cfg config
 	if handled {
 		return nil
 	}
-	fmt.Fprintf(stdout, "DSN=%s\n", result.DSN)
-	return nil
+	return printPrepareResult(stdout, runOpts, result)
 }
 
 func runPrepareParsed(stdout, stderr io.Writer, runOpts cli.PrepareOptions, cfg config.LoadedConfig, workspaceRoot string, cwd string, parsed prepareArgs, ref *refctx.Context) error {
@@ -190,8 +279,7 @@ func runPrepareParsed(stdout, stderr io.Writer, runOpts cli.PrepareOptions, cfg
 	if handled {
 		return nil
 	}
-	fmt.Fprintf(stdout, "DSN=%s\n", result.DSN)
-	return nil
+	return printPrepareResult(stdout, runOpts, result)
 }
 
 func runPrepareLiquibase(stdout, stderr io.Writer, runOpts cli.PrepareOptions, cfg config.LoadedConfig, workspaceRoot string, cwd string, args []string) error {
@@ -206,8 +294,7 @@ func runPrepareLiquibaseWithPathMode(stdout, stderr io.Writer, runOpts cli.Prepa
 	if handled {
 		return nil
 	}
-	fmt.Fprintf(stdout, "DSN=%s\n", result.DSN)
-	return nil
+	return printPrepareResult(stdout, runOpts, result)
 }
 
 func runPrepareLiquibaseParsedWithPathMode(stdout, stderr io.Writer, runOpts cli.PrepareOptions, cfg config.LoadedConfig, workspaceRoot string, cwd string, parsed prepareArgs, ref *refctx.Context, relativizePaths bool) error {
@@ -218,8 +305,7 @@ func runPrepareLiquibaseParsedWithPathMode(stdout, stderr io.Writer, runOpts cli
 	if handled {
 		return nil
 	}
-	fmt.Fprintf(stdout, "DSN=%s\n", result.DSN)
-	return nil
+	return printPrepareResult(stdout, runOpts, result)
 }
 
 func prepareResult(w stdoutAndErr, runOpts cli.PrepareOptions, cfg config.LoadedConfig, workspaceRoot string, cwd string, args []string) (client.PrepareJobResult, bool, error) {
@@ -26,6 +26,52 @@ func TestParsePrepareArgsImageFlag(t *testing.T) {
 	}
 }
 
+func TestParsePrepareArgsWaitFlag(t *testing.T) {
+	cases := map[string]bool{"--wait": true, "--wait=true": true, "--wait=false": false, "--wait=0": false}
+	for arg, want := range cases {
+		opts, _, err := parsePrepareArgs([]string{arg, "-c", "select 1"})
+		if err != nil {
+			t.Fatalf("parsePrepareArgs(%s): %v", arg, err)
+		}
+		if !opts.WatchSpecified || opts.Watch != want {
+			t.Fatalf("parsePrepareArgs(%s): watch=%v specified=%v", arg, opts.Watch, opts.WatchSpecified)
+		}
+	}
+	if _, _, err := parsePrepareArgs([]string{"--wait=later"}); err == nil || !strings.Contains(err.Error(), "Invalid value for --wait") {
+		t.Fatalf("expected invalid --wait error, got %v", err)
+	}
+	if _, _, err := parsePrepareArgs([]string{"--ref", "main", "--wait=false", "-c", "select 1"}); err == nil {
+		t.Fatalf("expected --wait=false to be rejected with --ref")
+	}
+}
+
+func TestParsePrepareArgsChangelogFromStdin(t *testing.T) {
+	opts, _, err := parsePrepareArgs([]string{"--changelog-from-stdin", "--", "update"})
+	if err != nil || !opts.ChangelogFromStdin || opts.ChangelogFormat != "" {
+		t.Fatalf("unexpected parse: %+v err=%v", opts, err)
+	}
+	if len(opts.PsqlArgs) != 1 || opts.PsqlArgs[0] != "update" {
+		t.Fatalf("unexpected liquibase args: %+v", opts.PsqlArgs)
+	}
+	opts, _, err = parsePrepareArgs([]string{"--changelog-from-stdin=YAML", "update"})
+	if err != nil || !opts.ChangelogFromStdin || opts.ChangelogFormat != "yaml" {
+		t.Fatalf("unexpected parse: %+v err=%v", opts, err)
+	}
+	if _, _, err := parsePrepareArgs([]string{"--changelog-from-stdin=toml", "update"}); err == nil || !strings.Contains(err.Error(), "Invalid value for --changelog-from-stdin") {
+		t.Fatalf("expected invalid format error, got %v", err)
+	}
+}
+
+func TestParsePrepareArgsCancelOnDisconnect(t *testing.T) {
+	opts, _, err := parsePrepareArgs([]string{"--cancel-on-disconnect", "-c", "select 1"})
+	if err != nil || !opts.CancelOnDisconnect {
+		t.Fatalf("unexpected parse: %+v err=%v", opts, err)
+	}
+	if _, _, err := parsePrepareArgs([]string{"--no-watch", "--cancel-on-disconnect", "-c", "select 1"}); err == nil || !strings.Contains(err.Error(), "requires watch mode") {
+		t.Fatalf("expected --no-watch to be rejected, got %v", err)
+	}
+}
+
 func TestParsePrepareArgsHelp(t *testing.T) {
 	_, showHelp, err := parsePrepareArgs([]string{"--help"})
 	if err != nil || !showHelp {
@@ -0,0 +1,87 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/sqlrs/cli/internal/cli"
+	"github.com/sqlrs/cli/internal/client"
+)
+
+type jsonErrorOutput struct {
+	Error client.ErrorResponse `json:"error"`
+}
+
+// printPrepareResult writes the outcome of a watched prepare: a DSN line for
+// humans, or the full result object in json mode.
+func printPrepareResult(w io.Writer, opts cli.PrepareOptions, result client.PrepareJobResult) error {
+	if opts.OutputFormat == cli.OutputFormatJSON {
+		return writeJSON(w, result)
+	}
+	fmt.Fprintf(w, "DSN=%s\n", result.DSN)
+	if result.StateID != "" {
+		fmt.Fprintf(w, "STATE_ID=%s\n", result.StateID)
+	}
+	return nil
+}
+
+// commandsWriteJSONErrors reports whether a failure of the invocation should
+// also be written to stdout as a structured error object: only standalone
+// prepare and plan commands in json mode do this, so `$(sqlrs prepare ...)`
+// always captures JSON.
+func commandsWriteJSONErrors(output string, commands []cli.Command) bool {
+	if output != cli.OutputFormatJSON || len(commands) != 1 {
+		return false
+	}
+	name := strings.TrimSpace(commands[0].Name)
+	return name == "prepare" || name == "plan" || strings.HasPrefix(name, "prepare:") || strings.HasPrefix(name, "plan:")
+}
+
+func writeJSONError(w io.Writer, err error) error {
+	return writeJSON(w, jsonErrorOutput{Error: jsonErrorFromError(err)})
+}
+
+// engineExitError gives an engine failure the exit code of its error code
+// (see cli.ErrorCodeExit), so scripts can tell a failed migration from a
+// missing image. Errors that already carry an exit code are kept.
+func engineExitError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return err
+	}
+	code := ""
+	var failed *cli.PrepareJobFailedError
+	var apiErr *client.ErrorResponseError
+	switch {
+	case errors.As(err, &failed) && failed.Err != nil:
+		code = failed.Err.Code
+	case errors.As(err, &apiErr):
+		code = apiErr.Code
+	}
+	if exit := cli.ErrorCodeExit(code); exit != 1 {
+		return &ExitError{Code: exit, Err: err}
+	}
+	return err
+}
+
+func jsonErrorFromError(err error) client.ErrorResponse {
+	var failed *cli.PrepareJobFailedError
+	if errors.As(err, &failed) && failed.Err != nil {
+		return *failed.Err
+	}
+	var apiErr *client.ErrorResponseError
+	if errors.As(err, &apiErr) && strings.TrimSpace(apiErr.Code) != "" {
+		return client.ErrorResponse{Code: apiErr.Code, Message: apiErr.Message, Details: apiErr.Details}
+	}
+	code := "cli_error"
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) && exitErr.Code == 2 {
+		code = cli.ErrorCodeInvalidArgument
+	}
+	return client.ErrorResponse{Code: code, Message: err.Error()}
+}
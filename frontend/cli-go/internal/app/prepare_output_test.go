@@ -0,0 +1,165 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/sqlrs/cli/internal/cli"
+	"github.com/sqlrs/cli/internal/client"
+	"github.com/sqlrs/cli/internal/config"
+)
+
+func TestPrintPrepareResultJSON(t *testing.T) {
+	var out bytes.Buffer
+	result := client.PrepareJobResult{
+		DSN:                   "postgres://sqlrs@127.0.0.1:5432/postgres",
+		InstanceID:            "inst-1",
+		StateID:               "state-1",
+		ImageID:               "postgres@sha256:abc",
+		PrepareKind:           "psql",
+		PrepareArgsNormalized: "-c select 1",
+	}
+	if err := printPrepareResult(&out, cli.PrepareOptions{OutputFormat: "json"}, result); err != nil {
+		t.Fatalf("printPrepareResult: %v", err)
+	}
+	var decoded client.PrepareJobResult
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatalf("expected JSON output, got %q: %v", out.String(), err)
+	}
+	if decoded != result {
+		t.Fatalf("unexpected result: %+v", decoded)
+	}
+
+	out.Reset()
+	if err := printPrepareResult(&out, cli.PrepareOptions{OutputFormat: "human"}, result); err != nil {
+		t.Fatalf("printPrepareResult: %v", err)
+	}
+	if out.String() != "DSN=postgres://sqlrs@127.0.0.1:5432/postgres\nSTATE_ID=state-1\n" {
+		t.Fatalf("unexpected human output: %q", out.String())
+	}
+}
+
+func TestJSONErrorFromError(t *testing.T) {
+	failed := &cli.PrepareJobFailedError{JobID: "job-1", Err: &client.ErrorResponse{Code: "execution_failed", Message: "psql failed", Details: "exit 3"}}
+	if got := jsonErrorFromError(failed); got.Code != "execution_failed" || got.Details != "exit 3" {
+		t.Fatalf("unexpected job failure error: %+v", got)
+	}
+	apiErr := &client.ErrorResponseError{Code: "invalid_argument", Message: "bad args"}
+	if got := jsonErrorFromError(apiErr); got.Code != "invalid_argument" || got.Message != "bad args" {
+		t.Fatalf("unexpected api error: %+v", got)
+	}
+	if got := jsonErrorFromError(ExitErrorf(2, "missing image")); got.Code != "invalid_argument" || got.Message != "missing image" {
+		t.Fatalf("unexpected usage error: %+v", got)
+	}
+	if got := jsonErrorFromError(errors.New("boom")); got.Code != "cli_error" {
+		t.Fatalf("unexpected generic error: %+v", got)
+	}
+}
+
+func TestCommandsWriteJSONErrors(t *testing.T) {
+	prepare := []cli.Command{{Name: "prepare:psql"}}
+	if !commandsWriteJSONErrors("json", prepare) {
+		t.Fatalf("expected json errors for prepare")
+	}
+	if !commandsWriteJSONErrors("json", []cli.Command{{Name: "plan"}}) {
+		t.Fatalf("expected json errors for plan alias")
+	}
+	if commandsWriteJSONErrors("human", prepare) {
+		t.Fatalf("expected no json errors in human mode")
+	}
+	if commandsWriteJSONErrors("json", []cli.Command{{Name: "prepare:psql"}, {Name: "run:psql"}}) {
+		t.Fatalf("expected no json errors for composite prepare ... run")
+	}
+	if commandsWriteJSONErrors("json", []cli.Command{{Name: "ls"}}) {
+		t.Fatalf("expected no json errors for ls")
+	}
+}
+
+func TestRunnerWritesJSONErrorForFailedPrepare(t *testing.T) {
+	cwd := t.TempDir()
+	var stdout bytes.Buffer
+	err := runWithParsedCommands(t, cli.GlobalOptions{}, []cli.Command{
+		{Name: "prepare:psql", Args: []string{"--image", "img", "--", "-c", "select 1"}},
+	}, func(deps *runnerDeps) {
+		deps.stdout = &stdout
+		deps.getwd = func() (string, error) {
+			return cwd, nil
+		}
+		deps.resolveCommandContext = func(gotCwd string, opts cli.GlobalOptions) (commandContext, error) {
+			return testCommandContext(gotCwd, "json", false), nil
+		}
+		deps.runPrepare = func(_ io.Writer, _ io.Writer, opts cli.PrepareOptions, _ config.LoadedConfig, _ string, _ string, _ []string) error {
+			if opts.OutputFormat != "json" {
+				t.Fatalf("expected json output format, got %q", opts.OutputFormat)
+			}
+			return &cli.PrepareJobFailedError{JobID: "job-1", Err: &client.ErrorResponse{Code: "execution_failed", Message: "psql failed"}}
+		}
+	})
+	if err == nil {
+		t.Fatalf("expected prepare error")
+	}
+	var payload jsonErrorOutput
+	if decodeErr := json.Unmarshal(stdout.Bytes(), &payload); decodeErr != nil {
+		t.Fatalf("expected JSON error on stdout, got %q: %v", stdout.String(), decodeErr)
+	}
+	if payload.Error.Code != "execution_failed" || !strings.Contains(payload.Error.Message, "psql failed") {
+		t.Fatalf("unexpected error payload: %+v", payload)
+	}
+}
+
+func TestEngineExitError(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "migration", err: &cli.PrepareJobFailedError{JobID: "job-1", Err: &client.ErrorResponse{Code: "migration_failed", Message: "psql execution failed"}}, want: 3},
+		{name: "image", err: &cli.PrepareJobFailedError{JobID: "job-1", Err: &client.ErrorResponse{Code: "image_not_found", Message: "cannot resolve image"}}, want: 4},
+		{name: "rejected", err: &client.ErrorResponseError{StatusCode: 403, Code: "permission_denied", Message: "image is not allowed"}, want: 2},
+		{name: "capacity", err: &client.ErrorResponseError{StatusCode: 429, Code: "resource_exhausted", Message: "too many jobs"}, want: 5},
+		{name: "internal", err: &cli.PrepareJobFailedError{JobID: "job-1", Err: &client.ErrorResponse{Code: "internal_error", Message: "boom"}}, want: 0},
+		{name: "unknown", err: errors.New("boom"), want: 0},
+	}
+	for _, tc := range cases {
+		err := engineExitError(tc.err)
+		var exitErr *ExitError
+		if tc.want == 0 {
+			if errors.As(err, &exitErr) {
+				t.Fatalf("%s: expected no exit code, got %d", tc.name, exitErr.Code)
+			}
+			continue
+		}
+		if !errors.As(err, &exitErr) || exitErr.Code != tc.want || !errors.Is(err, tc.err) {
+			t.Fatalf("%s: expected exit %d wrapping the error, got %#v", tc.name, tc.want, err)
+		}
+	}
+
+	usage := ExitErrorf(64, "Invalid arguments")
+	if err := engineExitError(usage); err != usage {
+		t.Fatalf("expected existing exit code to be kept, got %#v", err)
+	}
+}
+
+func TestRunMapsPrepareFailureToExitCode(t *testing.T) {
+	err := runWithParsedCommands(t, cli.GlobalOptions{}, []cli.Command{
+		{Name: "prepare:psql", Args: []string{"--image", "img", "--", "-c", "select 1"}},
+	}, func(deps *runnerDeps) {
+		deps.getwd = func() (string, error) {
+			return t.TempDir(), nil
+		}
+		deps.resolveCommandContext = func(gotCwd string, opts cli.GlobalOptions) (commandContext, error) {
+			return testCommandContext(gotCwd, "human", false), nil
+		}
+		deps.runPrepare = func(_ io.Writer, _ io.Writer, _ cli.PrepareOptions, _ config.LoadedConfig, _ string, _ string, _ []string) error {
+			return &cli.PrepareJobFailedError{JobID: "job-1", Err: &client.ErrorResponse{Code: "migration_failed", Message: "psql execution failed"}}
+		}
+	})
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 3 {
+		t.Fatalf("expected exit code 3, got %#v", err)
+	}
+}
@@ -0,0 +1,366 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sqlrs/cli/internal/cli"
+	"github.com/sqlrs/cli/internal/client"
+	"github.com/sqlrs/cli/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+// prepareSpecInvocation is `sqlrs prepare -f <spec>` plus the flags that
+// override the values read from the spec file.
+type prepareSpecInvocation struct {
+	Path           string
+	Image          string
+	EnvFiles       []string
+	Env            []string
+	Watch          bool
+	WatchSpecified bool
+	ProvenancePath string
+}
+
+// prepareSpecRequest is one prepare request of a spec file. It carries the
+// same inputs as a prepare alias plus the liquibase environment.
+type prepareSpecRequest struct {
+	Kind     string            `yaml:"kind"`
+	Image    string            `yaml:"image"`
+	Args     []string          `yaml:"args"`
+	Env      map[string]string `yaml:"env"`
+	EnvFiles []string          `yaml:"envFiles"`
+	Watch    *bool             `yaml:"watch"`
+}
+
+// prepareSpecFile is either a single request at the top level or a
+// requests list submitted as a batch.
+type prepareSpecFile struct {
+	prepareSpecRequest `yaml:",inline"`
+	Requests           []prepareSpecRequest `yaml:"requests"`
+}
+
+var readPrepareSpecFn = os.ReadFile
+
+// usesPrepareSpecFile reports whether prepare args select a spec file.
+func usesPrepareSpecFile(args []string) bool {
+	for _, arg := range args {
+		switch {
+		case arg == "--":
+			return false
+		case arg == "-f" || arg == "--file" || strings.HasPrefix(arg, "--file="):
+			return true
+		}
+	}
+	return false
+}
+
+func parsePrepareSpecArgs(args []string, cwd string) (prepareSpecInvocation, bool, error) {
+	opts := prepareSpecInvocation{Watch: true}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--help" || arg == "-h":
+			return opts, true, nil
+		case arg == "-f" || arg == "--file":
+			if i+1 >= len(args) || strings.TrimSpace(args[i+1]) == "" {
+				return opts, false, ExitErrorf(2, "Missing value for --file")
+			}
+			if opts.Path != "" {
+				return opts, false, ExitErrorf(2, "prepare accepts exactly one spec file")
+			}
+			opts.Path = strings.TrimSpace(args[i+1])
+			i++
+		case strings.HasPrefix(arg, "--file="):
+			value := strings.TrimSpace(strings.TrimPrefix(arg, "--file="))
+			if value == "" {
+				return opts, false, ExitErrorf(2, "Missing value for --file")
+			}
+			if opts.Path != "" {
+				return opts, false, ExitErrorf(2, "prepare accepts exactly one spec file")
+			}
+			opts.Path = value
+		case arg == "--watch":
+			opts.Watch = true
+			opts.WatchSpecified = true
+		case arg == "--no-watch":
+			opts.Watch = false
+			opts.WatchSpecified = true
+		case arg == "--wait" || strings.HasPrefix(arg, "--wait="):
+			watch, err := parseWaitFlag(arg)
+			if err != nil {
+				return opts, false, err
+			}
+			opts.Watch = watch
+			opts.WatchSpecified = true
+		case arg == "--provenance-path":
+			if i+1 >= len(args) || strings.TrimSpace(args[i+1]) == "" {
+				return opts, false, ExitErrorf(2, "Missing value for --provenance-path")
+			}
+			opts.ProvenancePath = strings.TrimSpace(args[i+1])
+			i++
+		case arg == "--image":
+			if i+1 >= len(args) || strings.TrimSpace(args[i+1]) == "" {
+				return opts, false, ExitErrorf(2, "Missing value for --image")
+			}
+			opts.Image = strings.TrimSpace(args[i+1])
+			i++
+		case strings.HasPrefix(arg, "--image="):
+			value := strings.TrimSpace(strings.TrimPrefix(arg, "--image="))
+			if value == "" {
+				return opts, false, ExitErrorf(2, "Missing value for --image")
+			}
+			opts.Image = value
+		case arg == "--env-file":
+			if i+1 >= len(args) || strings.TrimSpace(args[i+1]) == "" {
+				return opts, false, ExitErrorf(2, "Missing value for --env-file")
+			}
+			opts.EnvFiles = append(opts.EnvFiles, strings.TrimSpace(args[i+1]))
+			i++
+		case strings.HasPrefix(arg, "--env-file="):
+			value := strings.TrimSpace(strings.TrimPrefix(arg, "--env-file="))
+			if value == "" {
+				return opts, false, ExitErrorf(2, "Missing value for --env-file")
+			}
+			opts.EnvFiles = append(opts.EnvFiles, value)
+		case arg == "--env":
+			if i+1 >= len(args) {
+				return opts, false, ExitErrorf(2, "Missing value for --env")
+			}
+			value, err := parseEnvAssignment(args[i+1])
+			if err != nil {
+				return opts, false, err
+			}
+			opts.Env = append(opts.Env, value)
+			i++
+		case strings.HasPrefix(arg, "--env="):
+			value, err := parseEnvAssignment(strings.TrimPrefix(arg, "--env="))
+			if err != nil {
+				return opts, false, err
+			}
+			opts.Env = append(opts.Env, value)
+		case arg == "--ref" || arg == "--ref-mode" || arg == "--ref-keep-worktree" ||
+			strings.HasPrefix(arg, "--ref=") || strings.HasPrefix(arg, "--ref-mode="):
+			return opts, false, ExitErrorf(2, "%s is not supported with --file", strings.SplitN(arg, "=", 2)[0])
+		case arg == "--":
+			return opts, false, ExitErrorf(2, "prepare spec files do not accept inline tool args")
+		case strings.HasPrefix(arg, "-"):
+			return opts, false, ExitErrorf(2, "unknown prepare spec option: %s", arg)
+		default:
+			return opts, false, ExitErrorf(2, "prepare --file does not accept an alias ref: %s", arg)
+		}
+	}
+	if !filepath.IsAbs(opts.Path) && cwd != "" {
+		opts.Path = filepath.Join(cwd, opts.Path)
+	}
+	for i, path := range opts.EnvFiles {
+		if !filepath.IsAbs(path) && cwd != "" {
+			opts.EnvFiles[i] = filepath.Join(cwd, path)
+		}
+	}
+	return opts, false, nil
+}
+
+// loadPrepareSpec reads a YAML or JSON spec file. Unknown fields are
+// rejected so a typo does not silently drop an input.
+func loadPrepareSpec(path string) ([]prepareSpecRequest, error) {
+	data, err := readPrepareSpecFn(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ExitErrorf(2, "prepare spec not found: %s", path)
+		}
+		return nil, ExitErrorf(2, "cannot read prepare spec %s: %v", path, err)
+	}
+	var spec prepareSpecFile
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&spec); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, ExitErrorf(2, "prepare spec %s is empty", path)
+		}
+		return nil, ExitErrorf(2, "read prepare spec %s: %v", path, err)
+	}
+	top := spec.prepareSpecRequest
+	hasTop := top.Kind != "" || top.Image != "" || len(top.Args) > 0 || len(top.Env) > 0 || len(top.EnvFiles) > 0 || top.Watch != nil
+	switch {
+	case hasTop && len(spec.Requests) > 0:
+		return nil, ExitErrorf(2, "prepare spec %s: use either a single request or requests, not both", path)
+	case hasTop:
+		return []prepareSpecRequest{top}, nil
+	case len(spec.Requests) > 0:
+		return spec.Requests, nil
+	default:
+		return nil, ExitErrorf(2, "prepare spec %s declares no requests", path)
+	}
+}
+
+// buildPrepareSpecArgs validates each request of the spec and applies the
+// command-line overrides: --image replaces the image, --env-file files are
+// read after the spec's envFiles and --env wins over both. Nothing is
+// submitted unless every request is valid.
+func buildPrepareSpecArgs(invocation prepareSpecInvocation, requests []prepareSpecRequest, cfg config.LoadedConfig) ([]prepareArgs, []string, error) {
+	batch := len(requests) > 1
+	baseDir := filepath.Dir(invocation.Path)
+	if batch && invocation.Watch && invocation.WatchSpecified {
+		return nil, nil, ExitErrorf(2, "prepare specs with several requests are submitted without watching; --watch is not supported")
+	}
+	if batch && invocation.ProvenancePath != "" {
+		return nil, nil, ExitErrorf(2, "--provenance-path is not supported for prepare specs with several requests")
+	}
+	parsed := make([]prepareArgs, 0, len(requests))
+	kinds := make([]string, 0, len(requests))
+	for i, req := range requests {
+		label := "prepare spec"
+		if batch {
+			label = fmt.Sprintf("prepare spec requests[%d]", i)
+		}
+		kind := strings.ToLower(strings.TrimSpace(req.Kind))
+		switch kind {
+		case "":
+			return nil, nil, ExitErrorf(2, "%s: kind is required", label)
+		case "psql", "lb":
+		default:
+			return nil, nil, ExitErrorf(2, "%s: unknown kind: %s", label, req.Kind)
+		}
+		if len(req.Args) == 0 {
+			return nil, nil, ExitErrorf(2, "%s: args are required", label)
+		}
+		image := strings.TrimSpace(req.Image)
+		if invocation.Image != "" {
+			image = invocation.Image
+		}
+		resolved, _, err := resolvePrepareImage(image, cfg)
+		if err != nil {
+			return nil, nil, err
+		}
+		if resolved == "" {
+			return nil, nil, ExitErrorf(2, "%s: image is required (set image, --image or dbms.image)", label)
+		}
+		if kind != "lb" && (len(req.Env) > 0 || len(req.EnvFiles) > 0) {
+			return nil, nil, ExitErrorf(2, "%s: env and envFiles are only supported for kind lb", label)
+		}
+		watch := invocation.Watch
+		if !invocation.WatchSpecified && req.Watch != nil {
+			watch = *req.Watch
+		}
+		if batch {
+			if req.Watch != nil && *req.Watch {
+				return nil, nil, ExitErrorf(2, "%s: watch is not supported for prepare specs with several requests", label)
+			}
+			watch = false
+		}
+		args := prepareArgs{
+			Image:          image,
+			PsqlArgs:       rebasePrepareAliasArgs(kind, req.Args, invocation.Path),
+			Watch:          watch,
+			WatchSpecified: invocation.WatchSpecified || req.Watch != nil,
+			ProvenancePath: invocation.ProvenancePath,
+		}
+		if kind == "lb" {
+			for _, path := range req.EnvFiles {
+				if !filepath.IsAbs(path) {
+					path = filepath.Join(baseDir, path)
+				}
+				args.EnvFiles = append(args.EnvFiles, path)
+			}
+			args.EnvFiles = append(args.EnvFiles, invocation.EnvFiles...)
+			keys := make([]string, 0, len(req.Env))
+			for key := range req.Env {
+				if !isEnvKey(key) {
+					return nil, nil, ExitErrorf(2, "%s: invalid env key %q", label, key)
+				}
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				args.Env = append(args.Env, key+"="+req.Env[key])
+			}
+			args.Env = append(args.Env, invocation.Env...)
+		} else if len(invocation.EnvFiles) > 0 || len(invocation.Env) > 0 {
+			return nil, nil, ExitErrorf(2, "%s: --env-file and --env are only supported for liquibase", label)
+		}
+		parsed = append(parsed, args)
+		kinds = append(kinds, kind)
+	}
+	return parsed, kinds, nil
+}
+
+// runPrepareSpec runs `sqlrs prepare -f <spec>`. A single request behaves
+// like a prepare alias; several requests are submitted one after another
+// without watching and their job references are printed together.
+func runPrepareSpec(w stdoutAndErr, runOpts cli.PrepareOptions, cfg config.LoadedConfig, workspaceRoot string, cwd string, invocation prepareSpecInvocation) error {
+	if invocation.Path == "" {
+		return ExitErrorf(2, "Missing value for --file")
+	}
+	requests, err := loadPrepareSpec(invocation.Path)
+	if err != nil {
+		return err
+	}
+	parsed, kinds, err := buildPrepareSpecArgs(invocation, requests, cfg)
+	if err != nil {
+		return err
+	}
+	stageReq := func(i int) stageRunRequest {
+		req := stageRunRequest{
+			mode:          stageModePrepare,
+			class:         "alias",
+			kind:          kinds[i],
+			parsed:        parsed[i],
+			workspaceRoot: workspaceRoot,
+			cwd:           cwd,
+			invocationCwd: cwd,
+			aliasPath:     invocation.Path,
+		}
+		if kinds[i] == "lb" {
+			req.cwd = filepath.Dir(invocation.Path)
+		}
+		return req
+	}
+	if len(parsed) == 1 {
+		result, handled, err := prepareResultStageRequest(w, runOpts, cfg, stageReq(0))
+		if err != nil || handled {
+			return err
+		}
+		return printPrepareResult(w.stdout, runOpts, result)
+	}
+
+	accepted := make([]client.PrepareJobAccepted, 0, len(parsed))
+	for i := range parsed {
+		runtime, err := buildStageRuntime(w.stderr, runOpts, cfg, stageReq(i))
+		if err != nil {
+			return prepareSpecBatchError(i, accepted, err)
+		}
+		job, err := submitPrepareFn(context.Background(), runtime.opts)
+		if err = finishPrepareCleanup(err, runtime.cleanup); err != nil {
+			return prepareSpecBatchError(i, accepted, err)
+		}
+		accepted = append(accepted, job)
+		if runOpts.OutputFormat != cli.OutputFormatJSON {
+			printPrepareJobRefs(w.stdout, job)
+		}
+	}
+	if runOpts.OutputFormat == cli.OutputFormatJSON {
+		return writeJSON(w.stdout, accepted)
+	}
+	return nil
+}
+
+// prepareSpecBatchError names the failed request and the jobs that were
+// already submitted, which keep running.
+func prepareSpecBatchError(index int, accepted []client.PrepareJobAccepted, err error) error {
+	if len(accepted) == 0 {
+		return fmt.Errorf("prepare spec requests[%d]: %w", index, err)
+	}
+	ids := make([]string, 0, len(accepted))
+	for _, job := range accepted {
+		ids = append(ids, job.JobID)
+	}
+	return fmt.Errorf("prepare spec requests[%d]: %w (already submitted: %s)", index, err, strings.Join(ids, ", "))
+}
@@ -0,0 +1,302 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sqlrs/cli/internal/cli"
+	"github.com/sqlrs/cli/internal/client"
+	"github.com/sqlrs/cli/internal/config"
+	"github.com/sqlrs/cli/internal/paths"
+	"github.com/sqlrs/cli/internal/refctx"
+)
+
+func writePrepareSpec(t *testing.T, dir string, name string, data string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatalf("write spec: %v", err)
+	}
+	return path
+}
+
+func TestUsesPrepareSpecFile(t *testing.T) {
+	for _, args := range [][]string{{"-f", "spec.yaml"}, {"--no-watch", "--file", "spec.yaml"}, {"--file=spec.yaml"}} {
+		if !usesPrepareSpecFile(args) {
+			t.Fatalf("expected spec mode for %v", args)
+		}
+	}
+	for _, args := range [][]string{{"chinook"}, {"--watch", "chinook"}, {"--", "-f", "x.sql"}} {
+		if usesPrepareSpecFile(args) {
+			t.Fatalf("expected alias mode for %v", args)
+		}
+	}
+}
+
+func TestParsePrepareSpecArgs(t *testing.T) {
+	cwd := t.TempDir()
+	invocation, _, err := parsePrepareSpecArgs([]string{"-f", "prepare.yaml", "--no-watch", "--image=img-2", "--env-file", "local.env", "--env", "A=1"}, cwd)
+	if err != nil {
+		t.Fatalf("parsePrepareSpecArgs: %v", err)
+	}
+	want := prepareSpecInvocation{
+		Path:           filepath.Join(cwd, "prepare.yaml"),
+		Image:          "img-2",
+		EnvFiles:       []string{filepath.Join(cwd, "local.env")},
+		Env:            []string{"A=1"},
+		WatchSpecified: true,
+	}
+	if !reflect.DeepEqual(invocation, want) {
+		t.Fatalf("unexpected invocation:\n got %#v\nwant %#v", invocation, want)
+	}
+
+	cases := []struct {
+		args []string
+		want string
+	}{
+		{args: []string{"-f"}, want: "Missing value for --file"},
+		{args: []string{"-f", "a.yaml", "-f", "b.yaml"}, want: "exactly one spec file"},
+		{args: []string{"-f", "a.yaml", "chinook"}, want: "does not accept an alias ref"},
+		{args: []string{"-f", "a.yaml", "--ref", "HEAD"}, want: "--ref is not supported with --file"},
+		{args: []string{"-f", "a.yaml", "--bogus"}, want: "unknown prepare spec option: --bogus"},
+		{args: []string{"-f", "a.yaml", "--env", "NOEQUALS"}, want: "Invalid value for --env"},
+	}
+	for _, tc := range cases {
+		if _, _, err := parsePrepareSpecArgs(tc.args, cwd); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("args %v: expected %q, got %v", tc.args, tc.want, err)
+		}
+	}
+}
+
+func TestLoadPrepareSpecValidation(t *testing.T) {
+	dir := t.TempDir()
+	cases := []struct {
+		name string
+		data string
+		want string
+	}{
+		{name: "unknown.yaml", data: "kind: psql\nimage: img\nargs: [-c, select 1]\nlabels: {team: a}\n", want: "field labels not found"},
+		{name: "unknown-nested.yaml", data: "requests:\n  - kind: psql\n    args: [-c, select 1]\n    namespace: dev\n", want: "field namespace not found"},
+		{name: "both.yaml", data: "kind: psql\nargs: [-c, select 1]\nrequests:\n  - kind: psql\n    args: [-c, select 1]\n", want: "not both"},
+		{name: "empty.yaml", data: "", want: "is empty"},
+		{name: "none.yaml", data: "requests: []\n", want: "declares no requests"},
+	}
+	for _, tc := range cases {
+		path := writePrepareSpec(t, dir, tc.name, tc.data)
+		if _, err := loadPrepareSpec(path); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("%s: expected %q, got %v", tc.name, tc.want, err)
+		}
+	}
+	if _, err := loadPrepareSpec(filepath.Join(dir, "missing.yaml")); err == nil || !strings.Contains(err.Error(), "prepare spec not found") {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+
+	path := writePrepareSpec(t, dir, "prepare.json", `{"kind": "lb", "image": "img", "args": ["update"], "env": {"A": "1"}}`)
+	requests, err := loadPrepareSpec(path)
+	if err != nil {
+		t.Fatalf("loadPrepareSpec json: %v", err)
+	}
+	if len(requests) != 1 || requests[0].Kind != "lb" || requests[0].Env["A"] != "1" {
+		t.Fatalf("unexpected requests: %+v", requests)
+	}
+}
+
+func TestBuildPrepareSpecArgsValidation(t *testing.T) {
+	spec := filepath.Join(t.TempDir(), "prepare.yaml")
+	cfg := config.LoadedConfig{Paths: paths.Dirs{ConfigDir: t.TempDir()}}
+	yes := true
+	cases := []struct {
+		name       string
+		invocation prepareSpecInvocation
+		requests   []prepareSpecRequest
+		want       string
+	}{
+		{name: "missing image", requests: []prepareSpecRequest{{Kind: "psql", Args: []string{"-c", "select 1"}}}, want: "prepare spec: image is required"},
+		{name: "missing kind", requests: []prepareSpecRequest{{Image: "img", Args: []string{"-c", "select 1"}}}, want: "kind is required"},
+		{name: "unknown kind", requests: []prepareSpecRequest{{Kind: "flyway", Image: "img", Args: []string{"migrate"}}}, want: "unknown kind: flyway"},
+		{name: "missing args", requests: []prepareSpecRequest{{Kind: "psql", Image: "img"}}, want: "args are required"},
+		{name: "psql env", requests: []prepareSpecRequest{{Kind: "psql", Image: "img", Args: []string{"-c", "select 1"}, Env: map[string]string{"A": "1"}}}, want: "only supported for kind lb"},
+		{name: "bad env key", requests: []prepareSpecRequest{{Kind: "lb", Image: "img", Args: []string{"update"}, Env: map[string]string{"1A": "1"}}}, want: `invalid env key "1A"`},
+		{
+			name:     "batch missing image",
+			requests: []prepareSpecRequest{{Kind: "psql", Image: "img", Args: []string{"-c", "select 1"}}, {Kind: "lb", Args: []string{"update"}}},
+			want:     "prepare spec requests[1]: image is required",
+		},
+		{
+			name:       "batch watch flag",
+			invocation: prepareSpecInvocation{Watch: true, WatchSpecified: true},
+			requests:   []prepareSpecRequest{{Kind: "psql", Image: "img", Args: []string{"-c", "select 1"}}, {Kind: "psql", Image: "img", Args: []string{"-c", "select 2"}}},
+			want:       "--watch is not supported",
+		},
+		{
+			name:     "batch watch field",
+			requests: []prepareSpecRequest{{Kind: "psql", Image: "img", Args: []string{"-c", "select 1"}, Watch: &yes}, {Kind: "psql", Image: "img", Args: []string{"-c", "select 2"}}},
+			want:     "requests[0]: watch is not supported",
+		},
+	}
+	for _, tc := range cases {
+		tc.invocation.Path = spec
+		if !tc.invocation.WatchSpecified {
+			tc.invocation.Watch = true
+		}
+		if _, _, err := buildPrepareSpecArgs(tc.invocation, tc.requests, cfg); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("%s: expected %q, got %v", tc.name, tc.want, err)
+		}
+	}
+}
+
+func TestBuildPrepareSpecArgsFlagsOverrideSpec(t *testing.T) {
+	dir := t.TempDir()
+	spec := filepath.Join(dir, "prepare.yaml")
+	no := false
+	parsed, kinds, err := buildPrepareSpecArgs(prepareSpecInvocation{
+		Path:           spec,
+		Image:          "img-flag",
+		EnvFiles:       []string{"/abs/flag.env"},
+		Env:            []string{"B=flag"},
+		Watch:          true,
+		WatchSpecified: true,
+	}, []prepareSpecRequest{{
+		Kind:     "lb",
+		Image:    "img-spec",
+		Args:     []string{"update", "--changelog-file", "db/changelog.xml"},
+		Env:      map[string]string{"B": "spec", "A": "spec"},
+		EnvFiles: []string{"spec.env"},
+		Watch:    &no,
+	}}, config.LoadedConfig{})
+	if err != nil {
+		t.Fatalf("buildPrepareSpecArgs: %v", err)
+	}
+	want := prepareArgs{
+		Image:          "img-flag",
+		PsqlArgs:       []string{"update", "--changelog-file", filepath.Join(dir, "db", "changelog.xml")},
+		Watch:          true,
+		WatchSpecified: true,
+		EnvFiles:       []string{filepath.Join(dir, "spec.env"), "/abs/flag.env"},
+		Env:            []string{"A=spec", "B=spec", "B=flag"},
+	}
+	if !reflect.DeepEqual(kinds, []string{"lb"}) || len(parsed) != 1 || !reflect.DeepEqual(parsed[0], want) {
+		t.Fatalf("unexpected args:\n got %#v kinds=%v\nwant %#v", parsed, kinds, want)
+	}
+
+	parsed, _, err = buildPrepareSpecArgs(prepareSpecInvocation{Path: spec, Watch: true}, []prepareSpecRequest{{
+		Kind:  "psql",
+		Image: "img-spec",
+		Args:  []string{"-f", "init.sql"},
+		Watch: &no,
+	}}, config.LoadedConfig{})
+	if err != nil {
+		t.Fatalf("buildPrepareSpecArgs: %v", err)
+	}
+	if parsed[0].Watch || parsed[0].Image != "img-spec" || parsed[0].PsqlArgs[1] != filepath.Join(dir, "init.sql") {
+		t.Fatalf("expected spec values without flags, got %#v", parsed[0])
+	}
+}
+
+func TestRunPrepareSpecBatchSubmitsEveryRequest(t *testing.T) {
+	t.Setenv("JAVA_HOME", "")
+	dir := t.TempDir()
+	spec := writePrepareSpec(t, dir, "prepare.yaml", strings.Join([]string{
+		"requests:",
+		"  - kind: psql",
+		"    image: postgres:17",
+		"    args: [-f, init.sql]",
+		"  - kind: lb",
+		"    image: postgres:16",
+		"    args: [update, --changelog-file, changelog.xml]",
+		"    env:",
+		"      LIQUIBASE_COMMAND_USERNAME: app",
+		"",
+	}, "\n"))
+
+	prevPsql := bindPreparePsqlInputsFn
+	bindPreparePsqlInputsFn = func(_ cli.PrepareOptions, _ string, _ string, parsed prepareArgs, _ *refctx.Context, _ io.Reader) (prepareStageBinding, error) {
+		return prepareStageBinding{PsqlArgs: parsed.PsqlArgs}, nil
+	}
+	t.Cleanup(func() { bindPreparePsqlInputsFn = prevPsql })
+	prevLb := bindPrepareLiquibaseInputsFn
+	bindPrepareLiquibaseInputsFn = func(_ cli.PrepareOptions, _ string, cwd string, parsed prepareArgs, _ *refctx.Context, _ string, _ string, _ bool) (prepareStageBinding, error) {
+		return prepareStageBinding{LiquibaseArgs: parsed.PsqlArgs, WorkDir: cwd}, nil
+	}
+	t.Cleanup(func() { bindPrepareLiquibaseInputsFn = prevLb })
+	prevRun := runPrepareFn
+	runPrepareFn = func(context.Context, cli.PrepareOptions) (client.PrepareJobResult, error) {
+		t.Fatal("runPrepareFn should not be called for a batch")
+		return client.PrepareJobResult{}, nil
+	}
+	t.Cleanup(func() { runPrepareFn = prevRun })
+
+	var submitted []cli.PrepareOptions
+	prevSubmit := submitPrepareFn
+	submitPrepareFn = func(_ context.Context, opts cli.PrepareOptions) (client.PrepareJobAccepted, error) {
+		submitted = append(submitted, opts)
+		id := "job-" + opts.PrepareKind
+		return client.PrepareJobAccepted{JobID: id, StatusURL: "/v1/prepare-jobs/" + id, EventsURL: "/v1/prepare-jobs/" + id + "/events"}, nil
+	}
+	t.Cleanup(func() { submitPrepareFn = prevSubmit })
+
+	invocation, _, err := parsePrepareSpecArgs([]string{"-f", spec, "--image", "img-override"}, dir)
+	if err != nil {
+		t.Fatalf("parsePrepareSpecArgs: %v", err)
+	}
+	var stdout bytes.Buffer
+	err = runPrepareSpec(stdoutAndErr{stdout: &stdout, stderr: io.Discard}, cli.PrepareOptions{OutputFormat: cli.OutputFormatJSON}, config.LoadedConfig{
+		Paths: paths.Dirs{ConfigDir: t.TempDir()},
+	}, dir, dir, invocation)
+	if err != nil {
+		t.Fatalf("runPrepareSpec: %v", err)
+	}
+	if len(submitted) != 2 {
+		t.Fatalf("expected two submits, got %d", len(submitted))
+	}
+	if submitted[0].ImageID != "img-override" || !reflect.DeepEqual(submitted[0].PsqlArgs, []string{"-f", filepath.Join(dir, "init.sql")}) {
+		t.Fatalf("unexpected psql submit: %+v", submitted[0])
+	}
+	if submitted[1].ImageID != "img-override" || submitted[1].LiquibaseEnv["LIQUIBASE_COMMAND_USERNAME"] != "app" {
+		t.Fatalf("unexpected liquibase submit: %+v", submitted[1])
+	}
+	var accepted []client.PrepareJobAccepted
+	if err := json.Unmarshal(stdout.Bytes(), &accepted); err != nil {
+		t.Fatalf("decode output %q: %v", stdout.String(), err)
+	}
+	if len(accepted) != 2 || accepted[0].JobID != "job-psql" || accepted[1].JobID != "job-lb" {
+		t.Fatalf("unexpected job refs: %+v", accepted)
+	}
+}
+
+func TestRunPrepareSpecBatchReportsSubmittedJobsOnFailure(t *testing.T) {
+	dir := t.TempDir()
+	spec := writePrepareSpec(t, dir, "prepare.yaml", "requests:\n  - {kind: psql, image: img, args: [-c, select 1]}\n  - {kind: psql, image: img, args: [-c, select 2]}\n")
+
+	prevPsql := bindPreparePsqlInputsFn
+	bindPreparePsqlInputsFn = func(_ cli.PrepareOptions, _ string, _ string, parsed prepareArgs, _ *refctx.Context, _ io.Reader) (prepareStageBinding, error) {
+		return prepareStageBinding{PsqlArgs: parsed.PsqlArgs}, nil
+	}
+	t.Cleanup(func() { bindPreparePsqlInputsFn = prevPsql })
+	calls := 0
+	prevSubmit := submitPrepareFn
+	submitPrepareFn = func(context.Context, cli.PrepareOptions) (client.PrepareJobAccepted, error) {
+		calls++
+		if calls == 2 {
+			return client.PrepareJobAccepted{}, ExitErrorf(1, "engine unavailable")
+		}
+		return client.PrepareJobAccepted{JobID: "job-1", StatusURL: "/v1/prepare-jobs/job-1", EventsURL: "/v1/prepare-jobs/job-1/events"}, nil
+	}
+	t.Cleanup(func() { submitPrepareFn = prevSubmit })
+
+	var stdout bytes.Buffer
+	err := runPrepareSpec(stdoutAndErr{stdout: &stdout, stderr: io.Discard}, cli.PrepareOptions{}, config.LoadedConfig{}, dir, dir, prepareSpecInvocation{Path: spec, Watch: true})
+	if err == nil || !strings.Contains(err.Error(), "prepare spec requests[1]: engine unavailable (already submitted: job-1)") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(stdout.String(), "JOB_ID=job-1") {
+		t.Fatalf("expected the first job reference, got %q", stdout.String())
+	}
+}
@@ -133,6 +133,55 @@ func TestRunPrepareLiquibaseParsedWithPathModePrintsDSN(t *testing.T) {
 	}
 }
 
+func TestRunPrepareLiquibaseParsedSendsChangelogFromStdin(t *testing.T) {
+	prevBind := bindPrepareLiquibaseInputsFn
+	bindPrepareLiquibaseInputsFn = func(cli.PrepareOptions, string, string, prepareArgs, *refctx.Context, string, string, bool) (prepareStageBinding, error) {
+		return prepareStageBinding{LiquibaseArgs: []string{"update"}, WorkDir: t.TempDir()}, nil
+	}
+	t.Cleanup(func() { bindPrepareLiquibaseInputsFn = prevBind })
+
+	prevRead := readLiquibaseStdinFn
+	readLiquibaseStdinFn = func(io.Reader) (string, error) {
+		return readLiquibaseStdin(strings.NewReader("databaseChangeLog: []\n"))
+	}
+	t.Cleanup(func() { readLiquibaseStdinFn = prevRead })
+
+	prevRunPrepare := runPrepareFn
+	runPrepareFn = func(_ context.Context, opts cli.PrepareOptions) (client.PrepareJobResult, error) {
+		if opts.LiquibaseStdin == nil || *opts.LiquibaseStdin != "databaseChangeLog: []\n" || opts.LiquibaseStdinFormat != "yaml" {
+			t.Fatalf("unexpected stdin changelog: %v %q", opts.LiquibaseStdin, opts.LiquibaseStdinFormat)
+		}
+		return client.PrepareJobResult{DSN: "postgres://sqlrs@local/instance/lb"}, nil
+	}
+	t.Cleanup(func() { runPrepareFn = prevRunPrepare })
+
+	root := t.TempDir()
+	cfg := config.LoadedConfig{Paths: paths.Dirs{ConfigDir: t.TempDir()}}
+	err := runPrepareLiquibaseParsedWithPathMode(&bytes.Buffer{}, io.Discard, cli.PrepareOptions{}, cfg, root, root, prepareArgs{
+		Image:              "image-1",
+		PsqlArgs:           []string{"update"},
+		Watch:              true,
+		ChangelogFromStdin: true,
+		ChangelogFormat:    "yaml",
+	}, nil, true)
+	if err != nil {
+		t.Fatalf("runPrepareLiquibaseParsedWithPathMode: %v", err)
+	}
+
+	err = runPrepareParsed(&bytes.Buffer{}, io.Discard, cli.PrepareOptions{}, cfg, root, root, prepareArgs{
+		Image:              "image-1",
+		PsqlArgs:           []string{"-c", "select 1"},
+		Watch:              true,
+		ChangelogFromStdin: true,
+	}, nil)
+	if err == nil || !strings.Contains(err.Error(), "only supported for liquibase") {
+		t.Fatalf("expected psql to reject --changelog-from-stdin, got %v", err)
+	}
+	if _, err := readLiquibaseStdin(strings.NewReader(" \n")); err == nil || !strings.Contains(err.Error(), "stdin is empty") {
+		t.Fatalf("expected empty stdin error, got %v", err)
+	}
+}
+
 func TestRunPrepareLiquibaseParsedWithPathModeHandlesSubmittedPrepare(t *testing.T) {
 	prevBind := bindPrepareLiquibaseInputsFn
 	bindPrepareLiquibaseInputsFn = func(cli.PrepareOptions, string, string, prepareArgs, *refctx.Context, string, string, bool) (prepareStageBinding, error) {
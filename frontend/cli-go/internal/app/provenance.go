@@ -85,6 +85,9 @@ func explainPrepareCache(ctx context.Context, opts cli.PrepareOptions) (client.C
 		WorkDir:           opts.WorkDir,
 		Stdin:             opts.Stdin,
 		PlanOnly:          opts.PlanOnly,
+
+		LiquibaseStdin:       opts.LiquibaseStdin,
+		LiquibaseStdinFormat: opts.LiquibaseStdinFormat,
 	}
 	if opts.SourceSync == nil || !opts.SourceSync.Enabled {
 		return cliClient.ExplainPrepareCache(ctx, request)
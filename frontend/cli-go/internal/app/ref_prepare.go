@@ -1,6 +1,7 @@
 package app
 
 import (
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
@@ -193,6 +194,19 @@ func bindPrepareLiquibaseInputs(runOpts cli.PrepareOptions, workspaceRoot string
 	}, nil
 }
 
+// readLiquibaseStdin reads the changelog for --changelog-from-stdin. The
+// engine validates its format; only an empty stdin is rejected here.
+func readLiquibaseStdin(stdin io.Reader) (string, error) {
+	data, err := io.ReadAll(stdin)
+	if err != nil {
+		return "", fmt.Errorf("read changelog from stdin: %w", err)
+	}
+	if strings.TrimSpace(string(data)) == "" {
+		return "", ExitErrorf(2, "--changelog-from-stdin: stdin is empty")
+	}
+	return string(data), nil
+}
+
 func resolvePrepareBindingContext(workspaceRoot string, cwd string, parsed prepareArgs, existing *refctx.Context) (*refctx.Context, func() error, error) {
 	if existing != nil {
 		return existing, existing.Cleanup, nil
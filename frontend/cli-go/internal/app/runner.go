@@ -46,6 +46,11 @@ type runnerDeps struct {
 	runStatus       func(io.Writer, cli.StatusOptions, string, string, []string) error
 	runWatch        func(io.Writer, cli.PrepareOptions, []string) error
 	runConfig       func(io.Writer, cli.ConfigOptions, []string, string) error
+	runStates       func(io.Writer, cli.StatesOptions, []string, string) error
+	runEngine       func(io.Writer, cli.EngineOptions, []string, string) error
+	runDoctor       func(io.Writer, cli.StatusOptions, []string, string) error
+	runJobs         func(io.Writer, cli.PrepareOptions, []string) error
+	runUsage        func(io.Writer, cli.PrepareOptions, []string, string) error
 	runUser         func(io.Writer, commandContext, []string, string) error
 	runOrg          func(io.Writer, commandContext, []string, string) error
 
@@ -135,6 +140,21 @@ func (deps *runnerDeps) withDefaults() {
 	if deps.runConfig == nil {
 		deps.runConfig = runConfig
 	}
+	if deps.runStates == nil {
+		deps.runStates = runStates
+	}
+	if deps.runEngine == nil {
+		deps.runEngine = runEngine
+	}
+	if deps.runDoctor == nil {
+		deps.runDoctor = runDoctor
+	}
+	if deps.runJobs == nil {
+		deps.runJobs = runJobs
+	}
+	if deps.runUsage == nil {
+		deps.runUsage = runUsage
+	}
 	if deps.runUser == nil {
 		deps.runUser = runUser
 	}
@@ -146,7 +166,7 @@ func (deps *runnerDeps) withDefaults() {
 	}
 }
 
-func (r runner) run(args []string) error {
+func (r runner) run(args []string) (err error) {
 	opts, commands, err := r.deps.parseArgs(args)
 	if err != nil {
 		if errors.Is(err, cli.ErrHelp) {
@@ -204,6 +224,20 @@ func (r runner) run(args []string) error {
 	if err != nil {
 		return err
 	}
+	defer func() {
+		err = engineExitError(err)
+	}()
+	if commandsWriteJSONErrors(cmdCtx.output, commands) {
+		defer func() {
+			if err != nil {
+				_ = writeJSONError(r.deps.stdout, err)
+			}
+		}()
+	}
+	cmdCtx, err = applyEngineOverrides(cmdCtx, commands)
+	if err != nil {
+		return err
+	}
 	if commandsNeedEffectiveAuthToken(commands) {
 		cmdCtx, err = r.deps.resolveEffectiveAuthToken(context.Background(), cmdCtx)
 		if err != nil {
@@ -303,11 +337,36 @@ func (r runner) run(args []string) error {
 				return fmt.Errorf("watch cannot be combined with other commands")
 			}
 			return r.deps.runWatch(r.deps.stdout, cmdCtx.prepareOptions(false), cmd.Args)
+		case "jobs":
+			if len(commands) > 1 {
+				return fmt.Errorf("jobs cannot be combined with other commands")
+			}
+			return r.deps.runJobs(r.deps.stdout, cmdCtx.prepareOptions(false), cmd.Args)
+		case "usage":
+			if len(commands) > 1 {
+				return fmt.Errorf("usage cannot be combined with other commands")
+			}
+			return r.deps.runUsage(r.deps.stdout, cmdCtx.prepareOptions(false), cmd.Args, cmdCtx.output)
 		case "config":
 			if len(commands) > 1 {
 				return fmt.Errorf("config cannot be combined with other commands")
 			}
 			return r.deps.runConfig(r.deps.stdout, cmdCtx.configOptions(), cmd.Args, cmdCtx.output)
+		case "states":
+			if len(commands) > 1 {
+				return fmt.Errorf("states cannot be combined with other commands")
+			}
+			return r.deps.runStates(r.deps.stdout, cmdCtx.statesOptions(), cmd.Args, cmdCtx.output)
+		case "engine":
+			if len(commands) > 1 {
+				return fmt.Errorf("engine cannot be combined with other commands")
+			}
+			return r.deps.runEngine(r.deps.stdout, cmdCtx.engineOptions(), cmd.Args, cmdCtx.output)
+		case "doctor":
+			if len(commands) > 1 {
+				return fmt.Errorf("doctor cannot be combined with other commands")
+			}
+			return r.deps.runDoctor(r.deps.stdout, cmdCtx.statusOptions(), cmd.Args, cmdCtx.output)
 		case "user":
 			if len(commands) > 1 {
 				return fmt.Errorf("user cannot be combined with other commands")
@@ -319,6 +378,20 @@ func (r runner) run(args []string) error {
 			}
 			return r.deps.runOrg(r.deps.stdout, cmdCtx, cmd.Args, cmdCtx.output)
 		case "prepare":
+			if usesPrepareSpecFile(cmd.Args) {
+				if len(commands) > 1 {
+					return fmt.Errorf("prepare --file cannot be combined with other commands")
+				}
+				invocation, showHelp, err := parsePrepareSpecArgs(cmd.Args, cmdCtx.cwd)
+				if err != nil {
+					return err
+				}
+				if showHelp {
+					cli.PrintPrepareUsage(r.deps.stdout)
+					return nil
+				}
+				return runPrepareSpec(stdoutAndErr{stdout: r.deps.stdout, stderr: r.deps.stderr}, cmdCtx.prepareOptions(false), cmdCtx.cfgResult, cmdCtx.workspaceRoot, cmdCtx.cwd, invocation)
+			}
 			invocation, showHelp, err := parsePrepareAliasArgs(cmd.Args)
 			if err != nil {
 				return err
@@ -360,8 +433,7 @@ func (r runner) run(args []string) error {
 					if handled {
 						return nil
 					}
-					fmt.Fprintf(r.deps.stdout, "DSN=%s\n", result.DSN)
-					return nil
+					return printPrepareResult(r.deps.stdout, prepareOpts, result)
 				}
 				result, handled, err := prepareResultStageRequest(stdoutAndErr{stdout: r.deps.stdout, stderr: r.deps.stderr}, prepareOpts, cmdCtx.cfgResult, stageRunRequest{
 					mode:          stageModePrepare,
@@ -401,8 +473,7 @@ func (r runner) run(args []string) error {
 					if handled {
 						return nil
 					}
-					fmt.Fprintf(r.deps.stdout, "DSN=%s\n", result.DSN)
-					return nil
+					return printPrepareResult(r.deps.stdout, prepareOpts, result)
 				}
 				result, handled, err := prepareResultStageRequest(stdoutAndErr{stdout: r.deps.stdout, stderr: r.deps.stderr}, prepareOpts, cmdCtx.cfgResult, stageRunRequest{
 					mode:                    stageModePrepare,
@@ -525,7 +596,7 @@ func commandsNeedEffectiveAuthToken(commands []cli.Command) bool {
 	for _, cmd := range commands {
 		name := strings.TrimSpace(cmd.Name)
 		switch name {
-		case "cache", "ls", "rm", "run", "run:psql", "run:pgbench", "status", "user", "org", "watch":
+		case "cache", "ls", "rm", "run", "run:psql", "run:pgbench", "states", "status", "engine", "doctor", "user", "org", "watch", "jobs", "usage":
 			return true
 		case "plan", "plan:psql", "plan:lb", "prepare", "prepare:psql", "prepare:lb":
 			return true
@@ -79,6 +79,7 @@ func buildStageRuntime(stderr io.Writer, runOpts cli.PrepareOptions, cfg config.
 	}
 	runtime.opts.ImageID = imageID
 	runtime.opts.DisableControlPrompt = usesPrepareRef(req.parsed, req.ref)
+	runtime.opts.CancelOnDisconnect = req.parsed.CancelOnDisconnect
 
 	actualRef, refCleanup, err := resolvePrepareBindingContext(req.workspaceRoot, req.cwd, req.parsed, req.ref)
 	if err != nil {
@@ -87,6 +88,13 @@ func buildStageRuntime(stderr io.Writer, runOpts cli.PrepareOptions, cfg config.
 	runtime.actualRef = actualRef
 	runtime.cleanup = refCleanup
 
+	if req.parsed.ChangelogFromStdin && req.kind != "lb" {
+		return stageRuntime{}, combineBindingCleanupError(ExitErrorf(2, "--changelog-from-stdin is only supported for liquibase"), runStageCleanup(refCleanup))
+	}
+	if (len(req.parsed.EnvFiles) > 0 || len(req.parsed.Env) > 0) && req.kind != "lb" {
+		return stageRuntime{}, combineBindingCleanupError(ExitErrorf(2, "--env-file and --env are only supported for liquibase"), runStageCleanup(refCleanup))
+	}
+
 	switch req.kind {
 	case "psql":
 		bound, err := bindPreparePsqlInputsFn(runOpts, req.workspaceRoot, req.cwd, req.parsed, actualRef, os.Stdin)
@@ -114,9 +122,25 @@ func buildStageRuntime(stderr io.Writer, runOpts cli.PrepareOptions, cfg config.
 		runtime.opts.LiquibaseArgs = bound.LiquibaseArgs
 		runtime.opts.LiquibaseExec = liquibaseExec
 		runtime.opts.LiquibaseExecMode = liquibaseExecMode
-		runtime.opts.LiquibaseEnv = resolveLiquibaseEnv()
+		envCwd := req.invocationCwd
+		if envCwd == "" {
+			envCwd = req.cwd
+		}
+		env, err := resolvePrepareEnv(resolveLiquibaseEnv(), req.parsed, envCwd)
+		if err != nil {
+			return stageRuntime{}, combineBindingCleanupError(err, runStageCleanup(runtime.cleanup))
+		}
+		runtime.opts.LiquibaseEnv = env
 		runtime.opts.WorkDir = bound.WorkDir
 		runtime.opts.PrepareKind = "lb"
+		if req.parsed.ChangelogFromStdin {
+			changelog, err := readLiquibaseStdinFn(os.Stdin)
+			if err != nil {
+				return stageRuntime{}, combineBindingCleanupError(err, runStageCleanup(runtime.cleanup))
+			}
+			runtime.opts.LiquibaseStdin = &changelog
+			runtime.opts.LiquibaseStdinFormat = req.parsed.ChangelogFormat
+		}
 	default:
 		switch req.mode {
 		case stageModePlan:
@@ -229,6 +253,9 @@ func runPrepareStage(w stdoutAndErr, runtime stageRuntime) (prepareStageResult,
 		if err != nil {
 			return prepareStageResult{}, err
 		}
+		if runtime.opts.OutputFormat == cli.OutputFormatJSON && !runtime.opts.CompositeRun {
+			return prepareStageResult{handled: true, accepted: &accepted}, writeJSON(w.stdout, accepted)
+		}
 		printPrepareJobRefs(w.stdout, accepted)
 		if runtime.opts.CompositeRun {
 			printRunSkipped(w.stdout, "prepare_not_watched")
@@ -245,6 +272,9 @@ func runPrepareStage(w stdoutAndErr, runtime stageRuntime) (prepareStageResult,
 				StatusURL: "/v1/prepare-jobs/" + detached.JobID,
 				EventsURL: "/v1/prepare-jobs/" + detached.JobID + "/events",
 			}
+			if runtime.opts.OutputFormat == cli.OutputFormatJSON && !runtime.opts.CompositeRun {
+				return prepareStageResult{handled: true, accepted: &accepted}, writeJSON(w.stdout, accepted)
+			}
 			printPrepareJobRefs(w.stdout, accepted)
 			if runtime.opts.CompositeRun {
 				printRunSkipped(w.stdout, "prepare_detached")
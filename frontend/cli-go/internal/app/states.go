@@ -0,0 +1,206 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sqlrs/cli/internal/cli"
+	"github.com/sqlrs/cli/internal/client"
+)
+
+type statesCommand struct {
+	action    string
+	dryRun    bool
+	olderThan string
+	stateID   string
+	filePath  string
+	name      string
+}
+
+func parseStatesArgs(args []string) (statesCommand, bool, error) {
+	var cmd statesCommand
+	if err := validateNoUnicodeDashFlags(args, 2); err != nil {
+		return cmd, false, err
+	}
+	if len(args) == 0 {
+		return cmd, false, ExitErrorf(2, "Missing states command")
+	}
+	action := strings.TrimSpace(args[0])
+	switch action {
+	case "--help", "-h":
+		return cmd, true, nil
+	case "prune":
+		fs := flag.NewFlagSet("sqlrs states prune", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+
+		dryRun := fs.Bool("dry-run", false, "show states that would be removed")
+		olderThan := fs.String("older-than", "", "minimum state age")
+		help := fs.Bool("help", false, "show help")
+		helpShort := fs.Bool("h", false, "show help")
+
+		if err := fs.Parse(args[1:]); err != nil {
+			return cmd, false, ExitErrorf(2, "Invalid arguments: %v", err)
+		}
+		if *help || *helpShort {
+			return cmd, true, nil
+		}
+		if fs.NArg() > 0 {
+			return cmd, false, ExitErrorf(2, "Too many arguments")
+		}
+		value := strings.TrimSpace(*olderThan)
+		if value != "" {
+			parsed, err := time.ParseDuration(value)
+			if err != nil || parsed < 0 {
+				return cmd, false, ExitErrorf(2, "Invalid --older-than: %s", value)
+			}
+		}
+		cmd = statesCommand{action: "prune", dryRun: *dryRun, olderThan: value}
+	case "show":
+		rest := args[1:]
+		if len(rest) == 1 && (rest[0] == "--help" || rest[0] == "-h") {
+			return cmd, true, nil
+		}
+		if len(rest) == 0 || strings.TrimSpace(rest[0]) == "" {
+			return cmd, false, ExitErrorf(2, "Missing state id")
+		}
+		if len(rest) > 1 {
+			return cmd, false, ExitErrorf(2, "Too many arguments")
+		}
+		cmd = statesCommand{action: "show", stateID: strings.TrimSpace(rest[0])}
+	case "tag":
+		rest := args[1:]
+		if len(rest) == 1 && (rest[0] == "--help" || rest[0] == "-h") {
+			return cmd, true, nil
+		}
+		if len(rest) == 0 || strings.TrimSpace(rest[0]) == "" {
+			return cmd, false, ExitErrorf(2, "Missing state id")
+		}
+		if len(rest) == 1 || strings.TrimSpace(rest[1]) == "" {
+			return cmd, false, ExitErrorf(2, "Missing name")
+		}
+		if len(rest) > 2 {
+			return cmd, false, ExitErrorf(2, "Too many arguments")
+		}
+		cmd = statesCommand{action: "tag", stateID: strings.TrimSpace(rest[0]), name: strings.TrimSpace(rest[1])}
+	case "export":
+		fs := flag.NewFlagSet("sqlrs states export", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+
+		file := fs.String("file", "", "archive path")
+		help := fs.Bool("help", false, "show help")
+		helpShort := fs.Bool("h", false, "show help")
+
+		if err := fs.Parse(args[1:]); err != nil {
+			return cmd, false, ExitErrorf(2, "Invalid arguments: %v", err)
+		}
+		if *help || *helpShort {
+			return cmd, true, nil
+		}
+		if fs.NArg() == 0 {
+			return cmd, false, ExitErrorf(2, "Missing state id")
+		}
+		if fs.NArg() > 1 {
+			return cmd, false, ExitErrorf(2, "Too many arguments")
+		}
+		stateID := strings.TrimSpace(fs.Arg(0))
+		if stateID == "" {
+			return cmd, false, ExitErrorf(2, "Missing state id")
+		}
+		cmd = statesCommand{action: "export", stateID: stateID, filePath: strings.TrimSpace(*file)}
+	case "import":
+		rest := args[1:]
+		if len(rest) == 1 && (rest[0] == "--help" || rest[0] == "-h") {
+			return cmd, true, nil
+		}
+		if len(rest) == 0 || strings.TrimSpace(rest[0]) == "" {
+			return cmd, false, ExitErrorf(2, "Missing archive path")
+		}
+		if len(rest) > 1 {
+			return cmd, false, ExitErrorf(2, "Too many arguments")
+		}
+		cmd = statesCommand{action: "import", filePath: strings.TrimSpace(rest[0])}
+	default:
+		return cmd, false, ExitErrorf(2, "Unknown states command: %s", action)
+	}
+	return cmd, false, nil
+}
+
+func runStates(w io.Writer, runOpts cli.StatesOptions, args []string, output string) error {
+	cmd, showHelp, err := parseStatesArgs(args)
+	if err != nil {
+		return err
+	}
+	if showHelp {
+		cli.PrintStatesUsage(w)
+		return nil
+	}
+
+	switch cmd.action {
+	case "show":
+		runOpts.StateID = cmd.stateID
+		detail, found, err := cli.RunStatesShow(context.Background(), runOpts)
+		if err != nil {
+			return ExitErrorf(3, "Internal error: %v", err)
+		}
+		if !found {
+			return ExitErrorf(2, "State not found: %s", cmd.stateID)
+		}
+		if output == "json" {
+			return writeJSON(w, detail)
+		}
+		cli.PrintStatesShow(w, detail)
+	case "tag":
+		runOpts.StateID = cmd.stateID
+		runOpts.Name = cmd.name
+		entry, err := cli.RunStatesTag(context.Background(), runOpts)
+		if err != nil {
+			var apiErr *client.ErrorResponseError
+			if errors.As(err, &apiErr) && apiErr.StatusCode < http.StatusInternalServerError {
+				return ExitErrorf(2, "Cannot tag state: %v", err)
+			}
+			return ExitErrorf(3, "Internal error: %v", err)
+		}
+		if output == "json" {
+			return writeJSON(w, entry)
+		}
+		cli.PrintStatesTag(w, entry)
+	case "prune":
+		runOpts.DryRun = cmd.dryRun
+		runOpts.OlderThan = cmd.olderThan
+		result, err := cli.RunStatesPrune(context.Background(), runOpts)
+		if err != nil {
+			return ExitErrorf(3, "Internal error: %v", err)
+		}
+		if output == "json" {
+			return writeJSON(w, result)
+		}
+		cli.PrintStatesPrune(w, result)
+	case "export":
+		runOpts.StateID = cmd.stateID
+		runOpts.FilePath = cmd.filePath
+		result, err := cli.RunStatesExport(context.Background(), runOpts)
+		if err != nil {
+			return ExitErrorf(3, "Internal error: %v", err)
+		}
+		if output == "json" {
+			return writeJSON(w, result)
+		}
+		cli.PrintStatesExport(w, result)
+	case "import":
+		runOpts.FilePath = cmd.filePath
+		result, err := cli.RunStatesImport(context.Background(), runOpts)
+		if err != nil {
+			return ExitErrorf(3, "Internal error: %v", err)
+		}
+		if output == "json" {
+			return writeJSON(w, result)
+		}
+		cli.PrintStatesImport(w, result)
+	}
+	return nil
+}
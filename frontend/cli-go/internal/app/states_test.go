@@ -0,0 +1,218 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sqlrs/cli/internal/cli"
+)
+
+func TestParseStatesArgsErrors(t *testing.T) {
+	cases := [][]string{
+		{},
+		{"unknown"},
+		{"prune", "extra"},
+		{"prune", "--older-than", "soon"},
+		{"prune", "--older-than", "-1h"},
+		{"prune", "--unknown"},
+		{"prune", "—dry-run"},
+		{"export"},
+		{"export", "a", "b"},
+		{"export", "--unknown", "a"},
+		{"import"},
+		{"import", "a", "b"},
+		{"show"},
+		{"show", "a", "b"},
+		{"tag"},
+		{"tag", "a"},
+		{"tag", "a", "b", "c"},
+	}
+	for _, args := range cases {
+		_, _, err := parseStatesArgs(args)
+		var exitErr *ExitError
+		if !errors.As(err, &exitErr) || exitErr.Code != 2 {
+			t.Fatalf("args %v: expected ExitError code 2, got %v", args, err)
+		}
+	}
+}
+
+func TestParseStatesArgsHelp(t *testing.T) {
+	for _, args := range [][]string{{"--help"}, {"-h"}, {"prune", "--help"}, {"export", "--help"}, {"import", "-h"}, {"show", "--help"}, {"tag", "-h"}} {
+		_, showHelp, err := parseStatesArgs(args)
+		if err != nil || !showHelp {
+			t.Fatalf("args %v: expected help, err=%v help=%v", args, err, showHelp)
+		}
+	}
+}
+
+func TestParseStatesPrune(t *testing.T) {
+	cmd, _, err := parseStatesArgs([]string{"prune", "--dry-run", "--older-than", "2h"})
+	if err != nil {
+		t.Fatalf("parseStatesArgs: %v", err)
+	}
+	if cmd.action != "prune" || !cmd.dryRun || cmd.olderThan != "2h" {
+		t.Fatalf("unexpected states command: %+v", cmd)
+	}
+}
+
+func TestParseStatesExportImport(t *testing.T) {
+	cmd, _, err := parseStatesArgs([]string{"export", "--file", "out.tar", "state-1"})
+	if err != nil {
+		t.Fatalf("parseStatesArgs: %v", err)
+	}
+	if cmd.action != "export" || cmd.stateID != "state-1" || cmd.filePath != "out.tar" {
+		t.Fatalf("unexpected export command: %+v", cmd)
+	}
+	cmd, _, err = parseStatesArgs([]string{"import", "out.tar"})
+	if err != nil {
+		t.Fatalf("parseStatesArgs: %v", err)
+	}
+	if cmd.action != "import" || cmd.filePath != "out.tar" {
+		t.Fatalf("unexpected import command: %+v", cmd)
+	}
+}
+
+func TestRunStatesPruneOutputs(t *testing.T) {
+	var gotQuery string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/states/gc" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		gotQuery = r.URL.RawQuery
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"dry_run":true,"outcome":"would_delete","older_than":"10m0s","state_ids":["STATE-1"],"reclaimed_bytes":7}`)
+	}))
+	defer server.Close()
+
+	opts := cli.StatesOptions{Mode: "remote", Endpoint: server.URL, Timeout: time.Second}
+
+	var human bytes.Buffer
+	if err := runStates(&human, opts, []string{"prune", "--dry-run"}, "human"); err != nil {
+		t.Fatalf("runStates: %v", err)
+	}
+	if gotQuery != "dry_run=true" {
+		t.Fatalf("unexpected query: %q", gotQuery)
+	}
+	if !strings.Contains(human.String(), "state state-1 would delete") || !strings.Contains(human.String(), "reclaimedBytes: 7") {
+		t.Fatalf("unexpected human output: %q", human.String())
+	}
+
+	var jsonOut bytes.Buffer
+	if err := runStates(&jsonOut, opts, []string{"prune", "--dry-run"}, "json"); err != nil {
+		t.Fatalf("runStates json: %v", err)
+	}
+	if !strings.Contains(jsonOut.String(), `"state_ids"`) {
+		t.Fatalf("unexpected json output: %q", jsonOut.String())
+	}
+}
+
+func TestRunStatesShowOutputs(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/v1/states/state-2" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"state_id":"state-2","parent_state_id":"state-1","image_id":"img","prepare_kind":"psql","prepare_args_normalized":"-f seed.sql","created_at":"2026-01-01T00:00:00Z","size_bytes":5,"refcount":1,"ancestors":[{"state_id":"state-1","image_id":"img","prepare_kind":"psql","prepare_args_normalized":"-f schema.sql","created_at":"2026-01-01T00:00:00Z","refcount":0}]}`)
+	}))
+	defer server.Close()
+
+	opts := cli.StatesOptions{Mode: "remote", Endpoint: server.URL, Timeout: time.Second}
+
+	var human bytes.Buffer
+	if err := runStates(&human, opts, []string{"show", "state-2"}, "human"); err != nil {
+		t.Fatalf("runStates: %v", err)
+	}
+	if !strings.HasPrefix(human.String(), "state-1  kind=psql  size=-  args=-f schema.sql\n` state-2  kind=psql  size=5") {
+		t.Fatalf("unexpected human output: %q", human.String())
+	}
+
+	var jsonOut bytes.Buffer
+	if err := runStates(&jsonOut, opts, []string{"show", "state-2"}, "json"); err != nil {
+		t.Fatalf("runStates json: %v", err)
+	}
+	if !strings.Contains(jsonOut.String(), `"ancestors"`) {
+		t.Fatalf("unexpected json output: %q", jsonOut.String())
+	}
+
+	err := runStates(io.Discard, opts, []string{"show", "missing"}, "human")
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 2 || !strings.Contains(exitErr.Error(), "missing") {
+		t.Fatalf("expected not found ExitError, got %v", err)
+	}
+}
+
+func TestRunStatesTagOutputs(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/states/STATE-1/tags":
+			io.WriteString(w, `{"name":"my-seed","image_id":"img","state_id":"STATE-1","status":"active"}`)
+		case r.URL.Path == "/v1/states/state-2/tags":
+			w.WriteHeader(http.StatusConflict)
+			io.WriteString(w, `{"code":"conflict","message":"name is in use by an instance","details":"dev"}`)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	opts := cli.StatesOptions{Mode: "remote", Endpoint: server.URL, Timeout: time.Second}
+
+	var human bytes.Buffer
+	if err := runStates(&human, opts, []string{"tag", "STATE-1", "my-seed"}, "human"); err != nil {
+		t.Fatalf("runStates: %v", err)
+	}
+	if human.String() != "state state-1 tagged my-seed\n" {
+		t.Fatalf("unexpected human output: %q", human.String())
+	}
+
+	var jsonOut bytes.Buffer
+	if err := runStates(&jsonOut, opts, []string{"tag", "STATE-1", "my-seed"}, "json"); err != nil {
+		t.Fatalf("runStates json: %v", err)
+	}
+	if !strings.Contains(jsonOut.String(), `"name":"my-seed"`) {
+		t.Fatalf("unexpected json output: %q", jsonOut.String())
+	}
+
+	err := runStates(io.Discard, opts, []string{"tag", "state-2", "dev"}, "human")
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 2 || !strings.Contains(exitErr.Error(), "in use") {
+		t.Fatalf("expected a usage ExitError for a conflict, got %v", err)
+	}
+	err = runStates(io.Discard, opts, []string{"tag", "state-3", "x"}, "human")
+	if !errors.As(err, &exitErr) || exitErr.Code != 3 {
+		t.Fatalf("expected ExitError code 3, got %v", err)
+	}
+}
+
+func TestRunStatesPruneError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	opts := cli.StatesOptions{Mode: "remote", Endpoint: server.URL, Timeout: time.Second}
+	err := runStates(io.Discard, opts, []string{"prune"}, "human")
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 3 {
+		t.Fatalf("expected ExitError code 3, got %v", err)
+	}
+}
+
+func TestRunStatesHelp(t *testing.T) {
+	var out bytes.Buffer
+	if err := runStates(&out, cli.StatesOptions{}, []string{"--help"}, "human"); err != nil {
+		t.Fatalf("runStates: %v", err)
+	}
+	if !strings.Contains(out.String(), "sqlrs states prune") {
+		t.Fatalf("unexpected usage: %q", out.String())
+	}
+}
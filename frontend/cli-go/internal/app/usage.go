@@ -0,0 +1,52 @@
+package app
+
+import (
+	"context"
+	"flag"
+	"io"
+
+	"github.com/sqlrs/cli/internal/cli"
+)
+
+func parseUsageArgs(args []string) (bool, bool, error) {
+	if err := validateNoUnicodeDashFlags(args, 2); err != nil {
+		return false, false, err
+	}
+	fs := flag.NewFlagSet("sqlrs usage", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	jsonOutput := fs.Bool("json", false, "print JSON")
+	help := fs.Bool("help", false, "show help")
+	helpShort := fs.Bool("h", false, "show help")
+
+	if err := fs.Parse(args); err != nil {
+		return false, false, ExitErrorf(2, "Invalid arguments: %v", err)
+	}
+	if *help || *helpShort {
+		return false, true, nil
+	}
+	if fs.NArg() > 0 {
+		return false, false, ExitErrorf(2, "Too many arguments")
+	}
+	return *jsonOutput, false, nil
+}
+
+func runUsage(w io.Writer, runOpts cli.PrepareOptions, args []string, output string) error {
+	jsonOutput, showHelp, err := parseUsageArgs(args)
+	if err != nil {
+		return err
+	}
+	if showHelp {
+		cli.PrintUsageUsage(w)
+		return nil
+	}
+	summary, err := cli.RunUsage(context.Background(), runOpts)
+	if err != nil {
+		return err
+	}
+	if jsonOutput || output == "json" {
+		return writeJSON(w, summary)
+	}
+	cli.PrintUsageSummary(w, summary)
+	return nil
+}
@@ -0,0 +1,34 @@
+package app
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/sqlrs/cli/internal/cli"
+)
+
+func TestParseUsageArgs(t *testing.T) {
+	jsonOutput, showHelp, err := parseUsageArgs([]string{"--json"})
+	if err != nil || !jsonOutput || showHelp {
+		t.Fatalf("unexpected parse: json=%v help=%v err=%v", jsonOutput, showHelp, err)
+	}
+	if _, showHelp, err := parseUsageArgs([]string{"-h"}); err != nil || !showHelp {
+		t.Fatalf("expected help, got help=%v err=%v", showHelp, err)
+	}
+	for _, args := range [][]string{{"extra"}, {"--bogus"}} {
+		if _, _, err := parseUsageArgs(args); err == nil {
+			t.Fatalf("expected error for %v", args)
+		}
+	}
+}
+
+func TestRunUsageHelp(t *testing.T) {
+	var out bytes.Buffer
+	if err := runUsage(&out, cli.PrepareOptions{}, []string{"--help"}, "human"); err != nil {
+		t.Fatalf("runUsage: %v", err)
+	}
+	if !strings.Contains(out.String(), "sqlrs usage [--json]") {
+		t.Fatalf("unexpected help: %q", out.String())
+	}
+}
@@ -44,6 +44,12 @@ func runWatch(stdout io.Writer, runOpts cli.PrepareOptions, args []string) error
 		cli.PrintWatchUsage(stdout)
 		return nil
 	}
+	return waitPrepareJob(stdout, runOpts, jobID)
+}
+
+// waitPrepareJob blocks until an already submitted prepare job finishes.
+// Detaching prints the job references instead of failing.
+func waitPrepareJob(stdout io.Writer, runOpts cli.PrepareOptions, jobID string) error {
 	status, err := cli.RunWatch(context.Background(), runOpts, jobID)
 	if err != nil {
 		var detached *cli.PrepareDetachedError
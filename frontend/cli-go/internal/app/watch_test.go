@@ -88,6 +88,27 @@ func TestRunWatchSuccess(t *testing.T) {
 	}
 }
 
+func TestRunJobsWaitFailedJob(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/v1/prepare-jobs/job-1" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"job_id":"job-1","status":"failed","error":{"code":"internal_error","message":"boom"}}`))
+	}))
+	defer server.Close()
+
+	err := runJobs(&bytes.Buffer{}, cli.PrepareOptions{
+		Mode:     "remote",
+		Endpoint: server.URL,
+		Timeout:  time.Second,
+	}, []string{"wait", "job-1"})
+	if err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("expected job failure, got %v", err)
+	}
+}
+
 func TestRunWatchError(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusNotFound)
@@ -6,7 +6,7 @@ func PrintCacheUsage(w io.Writer) {
 	io.WriteString(w, "Usage:\n")
 	io.WriteString(w, "  sqlrs cache explain prepare [--ref <git-ref>] [--ref-mode worktree|blob] [--ref-keep-worktree] <ref>\n")
 	io.WriteString(w, "  sqlrs cache explain prepare:psql [--ref <git-ref>] [--ref-mode worktree|blob] [--ref-keep-worktree] [--image <image-id>] [--] [psql-args...]\n")
-	io.WriteString(w, "  sqlrs cache explain prepare:lb [--ref <git-ref>] [--ref-mode worktree|blob] [--ref-keep-worktree] [--image <image-id>] [--] [liquibase-args...]\n\n")
+	io.WriteString(w, "  sqlrs cache explain prepare:lb [--ref <git-ref>] [--ref-mode worktree|blob] [--ref-keep-worktree] [--image <image-id>] [--changelog-from-stdin[=<format>]] [--env-file <path>] [--env KEY=VALUE] [--] [liquibase-args...]\n\n")
 	io.WriteString(w, "Notes:\n")
 	io.WriteString(w, "  cache explain is read-only and only supports wrapped prepare stages.\n")
 	io.WriteString(w, "  --watch and --no-watch are not accepted because cache explain does not execute the stage.\n")
@@ -0,0 +1,127 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/sqlrs/cli/internal/client"
+)
+
+const (
+	DoctorPass = "pass"
+	DoctorWarn = "warn"
+	DoctorFail = "fail"
+)
+
+type DoctorCheck struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	Hint    string `json:"hint,omitempty"`
+}
+
+type DoctorResult struct {
+	OK       bool          `json:"ok"`
+	Endpoint string        `json:"endpoint,omitempty"`
+	Profile  string        `json:"profile"`
+	Mode     string        `json:"mode"`
+	Checks   []DoctorCheck `json:"checks"`
+}
+
+// RunDoctor checks that the engine is reachable and then collects the
+// engine-side checks from /v1/diagnostics. Problems are reported as failed
+// checks rather than errors, so the caller can always print the result.
+func RunDoctor(ctx context.Context, opts StatusOptions) DoctorResult {
+	mode := strings.ToLower(strings.TrimSpace(opts.Mode))
+	result := DoctorResult{Profile: opts.ProfileName, Mode: mode}
+
+	endpoint, authToken, err := resolveStatusEndpoint(ctx, opts, mode)
+	result.Endpoint = endpoint
+	if err != nil {
+		result.Checks = append(result.Checks, doctorEngineFailure(mode, err))
+		return finishDoctor(result)
+	}
+
+	cliClient := client.New(endpoint, client.Options{Timeout: opts.Timeout, AuthToken: authToken})
+	if opts.Verbose {
+		fmt.Fprintln(os.Stderr, "requesting health")
+	}
+	health, err := cliClient.Health(ctx)
+	if err != nil {
+		result.Checks = append(result.Checks, doctorEngineFailure(mode, err))
+		return finishDoctor(result)
+	}
+	engine := DoctorCheck{Name: "engine", Status: DoctorPass, Message: fmt.Sprintf("engine %s reachable at %s", health.Version, endpoint)}
+	if warning := versionSkewWarning(opts.ClientVersion, health.Version); warning != "" {
+		engine.Status = DoctorWarn
+		engine.Message += "; " + warning
+		engine.Hint = "upgrade sqlrs and the engine to the same version"
+	}
+	result.Checks = append(result.Checks, engine)
+
+	if opts.Verbose {
+		fmt.Fprintln(os.Stderr, "requesting diagnostics")
+	}
+	diagnostics, found, err := cliClient.GetDiagnostics(ctx)
+	switch {
+	case err != nil:
+		result.Checks = append(result.Checks, DoctorCheck{
+			Name:    "diagnostics",
+			Status:  DoctorFail,
+			Message: fmt.Sprintf("cannot read engine diagnostics: %v", err),
+			Hint:    "check the auth token for this profile",
+		})
+	case !found:
+		result.Checks = append(result.Checks, DoctorCheck{
+			Name:    "diagnostics",
+			Status:  DoctorWarn,
+			Message: "engine does not report diagnostics",
+			Hint:    "upgrade the engine to run the engine-side checks",
+		})
+	default:
+		for _, check := range diagnostics.Checks {
+			result.Checks = append(result.Checks, DoctorCheck{
+				Name:    check.Name,
+				Status:  check.Status,
+				Message: check.Message,
+				Hint:    check.Hint,
+			})
+		}
+	}
+	return finishDoctor(result)
+}
+
+func doctorEngineFailure(mode string, err error) DoctorCheck {
+	hint := "check the endpoint and auth token for this profile"
+	if mode == "local" {
+		hint = "run `sqlrs engine status` and check the engine log in the state directory"
+	}
+	return DoctorCheck{
+		Name:    "engine",
+		Status:  DoctorFail,
+		Message: fmt.Sprintf("engine is not reachable: %v", err),
+		Hint:    hint,
+	}
+}
+
+func finishDoctor(result DoctorResult) DoctorResult {
+	result.OK = true
+	for _, check := range result.Checks {
+		if check.Status == DoctorFail {
+			result.OK = false
+		}
+	}
+	return result
+}
+
+func PrintDoctor(w io.Writer, result DoctorResult) {
+	for _, check := range result.Checks {
+		fmt.Fprintf(w, "%-4s  %s: %s\n", strings.ToUpper(check.Status), check.Name, check.Message)
+		if check.Hint != "" && check.Status != DoctorPass {
+			fmt.Fprintf(w, "      hint: %s\n", check.Hint)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newDoctorServer(t *testing.T, diagnostics string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/v1/health":
+			io.WriteString(w, `{"ok":true,"version":"v1","instanceId":"inst","pid":1}`)
+		case r.URL.Path == "/v1/diagnostics" && diagnostics != "":
+			io.WriteString(w, diagnostics)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestRunDoctorCollectsEngineChecks(t *testing.T) {
+	server := newDoctorServer(t, `{"ok":false,"checks":[{"name":"containerRuntime","status":"pass","message":"docker 27.0.1"},{"name":"stateStore","status":"fail","message":"not writable","hint":"fix permissions"}]}`)
+
+	result := RunDoctor(context.Background(), StatusOptions{Mode: "remote", Endpoint: server.URL, Timeout: time.Second})
+	if result.OK || len(result.Checks) != 3 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if result.Checks[0].Name != "engine" || result.Checks[0].Status != DoctorPass {
+		t.Fatalf("expected engine check first, got %+v", result.Checks[0])
+	}
+
+	var out bytes.Buffer
+	PrintDoctor(&out, result)
+	text := out.String()
+	if !strings.Contains(text, "PASS  containerRuntime: docker 27.0.1") || !strings.Contains(text, "FAIL  stateStore: not writable\n      hint: fix permissions") {
+		t.Fatalf("unexpected output:\n%s", text)
+	}
+}
+
+func TestRunDoctorOldEngineWarns(t *testing.T) {
+	server := newDoctorServer(t, "")
+
+	result := RunDoctor(context.Background(), StatusOptions{Mode: "remote", Endpoint: server.URL, Timeout: time.Second})
+	if !result.OK || len(result.Checks) != 2 || result.Checks[1].Status != DoctorWarn {
+		t.Fatalf("expected a warning for an engine without diagnostics, got %+v", result)
+	}
+}
+
+func TestRunDoctorUnreachableEngine(t *testing.T) {
+	server := newDoctorServer(t, "")
+	endpoint := server.URL
+	server.Close()
+
+	result := RunDoctor(context.Background(), StatusOptions{Mode: "remote", Endpoint: endpoint, Timeout: time.Second})
+	if result.OK || len(result.Checks) != 1 || result.Checks[0].Name != "engine" || result.Checks[0].Status != DoctorFail || result.Checks[0].Hint == "" {
+		t.Fatalf("expected engine failure, got %+v", result)
+	}
+
+	result = RunDoctor(context.Background(), StatusOptions{Mode: "remote", Timeout: time.Second})
+	if result.OK || result.Checks[0].Status != DoctorFail {
+		t.Fatalf("expected missing remote endpoint to fail, got %+v", result)
+	}
+}
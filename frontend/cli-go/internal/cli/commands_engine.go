@@ -0,0 +1,201 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sqlrs/cli/internal/client"
+	"github.com/sqlrs/cli/internal/daemon"
+)
+
+type EngineOptions struct {
+	ProfileName string
+	Mode        string
+	AuthToken   string
+	Endpoint    string
+	StateDir    string
+	Timeout     time.Duration
+	Verbose     bool
+	// ClientVersion is compared with the engine version to warn about skew.
+	ClientVersion string
+}
+
+type EngineStatusResult struct {
+	Running  bool   `json:"running"`
+	OK       bool   `json:"ok"`
+	Reason   string `json:"reason,omitempty"`
+	Endpoint string `json:"endpoint,omitempty"`
+	Profile  string `json:"profile"`
+	Mode     string `json:"mode"`
+
+	Version            string  `json:"version,omitempty"`
+	InstanceID         string  `json:"instanceId,omitempty"`
+	PID                int     `json:"pid,omitempty"`
+	StartedAt          string  `json:"startedAt,omitempty"`
+	UptimeSeconds      float64 `json:"uptimeSeconds,omitempty"`
+	InflightRequests   int64   `json:"inflightRequests"`
+	IdleSeconds        float64 `json:"idleSeconds"`
+	IdleTimeoutSeconds float64 `json:"idleTimeoutSeconds"`
+	RunningJobs        int     `json:"runningJobs"`
+	ContainerRuntime   string  `json:"containerRuntime,omitempty"`
+	SnapshotBackend    string  `json:"snapshotBackend,omitempty"`
+
+	APIVersion string   `json:"apiVersion,omitempty"`
+	Revision   string   `json:"revision,omitempty"`
+	BuildTime  string   `json:"buildTime,omitempty"`
+	GoVersion  string   `json:"goVersion,omitempty"`
+	Warnings   []string `json:"warnings,omitempty"`
+}
+
+// RunEngineStatus reports on the engine without starting it. In local mode
+// the endpoint and token come from engine.json; a missing file or an engine
+// that does not answer health is reported as not running.
+func RunEngineStatus(ctx context.Context, opts EngineOptions) (EngineStatusResult, error) {
+	mode := strings.ToLower(strings.TrimSpace(opts.Mode))
+	endpoint := strings.TrimSpace(opts.Endpoint)
+	authToken := strings.TrimSpace(opts.AuthToken)
+	result := EngineStatusResult{Profile: opts.ProfileName, Mode: mode}
+
+	if mode == "local" {
+		authToken = ""
+		if endpoint == "" || endpoint == "auto" {
+			enginePath := filepath.Join(opts.StateDir, "engine.json")
+			if opts.Verbose {
+				fmt.Fprintf(os.Stderr, "checking engine.json at %s\n", enginePath)
+			}
+			state, err := daemon.ReadEngineState(enginePath)
+			if errors.Is(err, fs.ErrNotExist) {
+				result.Reason = "engine.json not found"
+				return result, nil
+			}
+			if err != nil {
+				return result, fmt.Errorf("read engine.json: %w", err)
+			}
+			endpoint = state.Endpoint
+			authToken = state.AuthToken
+			result.Version = state.Version
+			result.InstanceID = state.InstanceID
+			result.PID = state.PID
+			result.StartedAt = state.StartedAt
+		}
+	} else if mode == "remote" {
+		if endpoint == "" || endpoint == "auto" {
+			return result, fmt.Errorf("remote mode requires explicit endpoint")
+		}
+		if opts.Verbose {
+			fmt.Fprintf(os.Stderr, "using remote endpoint %s\n", endpoint)
+		}
+	}
+	result.Endpoint = endpoint
+
+	cliClient := client.New(endpoint, client.Options{Timeout: opts.Timeout, AuthToken: authToken})
+	if opts.Verbose {
+		fmt.Fprintln(os.Stderr, "requesting health")
+	}
+	health, err := cliClient.Health(ctx)
+	if err != nil {
+		result.Reason = fmt.Sprintf("health check failed: %v", err)
+		return result, nil
+	}
+	result.Running = true
+	result.OK = health.Ok
+	result.Version = health.Version
+	result.InstanceID = health.InstanceID
+	result.PID = health.PID
+
+	if opts.Verbose {
+		fmt.Fprintln(os.Stderr, "requesting engine stats")
+	}
+	stats, err := cliClient.GetEngineStats(ctx)
+	if err != nil {
+		return result, err
+	}
+	if stats.StartedAt != "" {
+		result.StartedAt = stats.StartedAt
+	}
+	result.UptimeSeconds = stats.UptimeSeconds
+	result.InflightRequests = stats.InflightRequests
+	result.IdleSeconds = stats.IdleSeconds
+	result.IdleTimeoutSeconds = stats.IdleTimeoutSeconds
+	result.RunningJobs = stats.RunningJobs
+	result.ContainerRuntime = stats.ContainerRuntime
+	result.SnapshotBackend = stats.SnapshotBackend
+
+	// Engines that predate /v1/version answer 404; the build fields stay empty.
+	if version, err := cliClient.GetVersion(ctx); err == nil {
+		result.APIVersion = version.APIVersion
+		result.Revision = version.Revision
+		result.BuildTime = version.BuildTime
+		result.GoVersion = version.GoVersion
+	} else if opts.Verbose {
+		fmt.Fprintf(os.Stderr, "version request failed: %v\n", err)
+	}
+	if warning := versionSkewWarning(opts.ClientVersion, result.Version); warning != "" {
+		result.Warnings = append(result.Warnings, warning)
+	}
+	return result, nil
+}
+
+func PrintEngineStatus(w io.Writer, result EngineStatusResult) {
+	if !result.Running {
+		fmt.Fprintln(w, "engine: not running")
+		if result.Endpoint != "" {
+			fmt.Fprintf(w, "endpoint: %s\n", result.Endpoint)
+		}
+		if result.Reason != "" {
+			fmt.Fprintf(w, "reason: %s\n", result.Reason)
+		}
+		return
+	}
+
+	status := "running"
+	if !result.OK {
+		status = "running (unhealthy)"
+	}
+	fmt.Fprintf(w, "engine: %s\n", status)
+	fmt.Fprintf(w, "endpoint: %s\n", result.Endpoint)
+	if result.Version != "" {
+		fmt.Fprintf(w, "version: %s\n", result.Version)
+	}
+	if result.PID != 0 {
+		fmt.Fprintf(w, "pid: %d\n", result.PID)
+	}
+	if result.StartedAt != "" {
+		fmt.Fprintf(w, "uptime: %s (since %s)\n", formatSeconds(result.UptimeSeconds), result.StartedAt)
+	}
+	fmt.Fprintf(w, "jobs: %d running\n", result.RunningJobs)
+	fmt.Fprintf(w, "requests: %d in flight\n", result.InflightRequests)
+	if result.IdleTimeoutSeconds > 0 {
+		remaining := result.IdleTimeoutSeconds - result.IdleSeconds
+		if remaining < 0 || result.InflightRequests > 0 {
+			fmt.Fprintf(w, "idle: %s of %s\n", formatSeconds(result.IdleSeconds), formatSeconds(result.IdleTimeoutSeconds))
+		} else {
+			fmt.Fprintf(w, "idle: %s of %s (shutdown in %s)\n", formatSeconds(result.IdleSeconds), formatSeconds(result.IdleTimeoutSeconds), formatSeconds(remaining))
+		}
+	} else {
+		fmt.Fprintf(w, "idle: %s (no idle timeout)\n", formatSeconds(result.IdleSeconds))
+	}
+	if result.ContainerRuntime != "" {
+		fmt.Fprintf(w, "containerRuntime: %s\n", result.ContainerRuntime)
+	}
+	if result.SnapshotBackend != "" {
+		fmt.Fprintf(w, "snapshotBackend: %s\n", result.SnapshotBackend)
+	}
+	if result.Revision != "" {
+		fmt.Fprintf(w, "build: %s %s %s\n", result.Revision, result.BuildTime, result.GoVersion)
+	}
+	for _, warning := range result.Warnings {
+		fmt.Fprintf(w, "warning: %s\n", warning)
+	}
+}
+
+func formatSeconds(seconds float64) string {
+	return time.Duration(seconds * float64(time.Second)).Round(time.Second).String()
+}
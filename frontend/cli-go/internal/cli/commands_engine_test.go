@@ -0,0 +1,193 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sqlrs/cli/internal/daemon"
+)
+
+func newEngineStatsServer(t *testing.T, token string) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/health":
+			io.WriteString(w, `{"ok":true,"version":"v1","instanceId":"inst","pid":42}`)
+		case "/v1/engine/stats":
+			if r.Header.Get("Authorization") != "Bearer "+token {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			io.WriteString(w, `{"version":"v1","instanceId":"inst","pid":42,"startedAt":"2026-03-09T12:00:00Z","uptimeSeconds":3723,"inflightRequests":0,"idleSeconds":12,"idleTimeoutSeconds":30,"runningJobs":1,"containerRuntime":"docker","snapshotBackend":"copy"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestRunEngineStatusLocalReadsEngineState(t *testing.T) {
+	server := newEngineStatsServer(t, "local-token")
+	defer server.Close()
+	stateDir := t.TempDir()
+	if err := daemon.WriteEngineState(filepath.Join(stateDir, "engine.json"), daemon.EngineState{
+		Endpoint:  server.URL,
+		PID:       42,
+		AuthToken: "local-token",
+	}); err != nil {
+		t.Fatalf("write engine.json: %v", err)
+	}
+
+	result, err := RunEngineStatus(context.Background(), EngineOptions{Mode: "local", StateDir: stateDir, Timeout: time.Second})
+	if err != nil {
+		t.Fatalf("RunEngineStatus: %v", err)
+	}
+	if !result.Running || !result.OK || result.Endpoint != server.URL || result.PID != 42 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if result.RunningJobs != 1 || result.IdleSeconds != 12 || result.IdleTimeoutSeconds != 30 || result.SnapshotBackend != "copy" || result.ContainerRuntime != "docker" {
+		t.Fatalf("unexpected stats: %+v", result)
+	}
+}
+
+func TestRunEngineStatusLocalNotRunning(t *testing.T) {
+	result, err := RunEngineStatus(context.Background(), EngineOptions{Mode: "local", StateDir: t.TempDir(), Timeout: time.Second})
+	if err != nil {
+		t.Fatalf("RunEngineStatus: %v", err)
+	}
+	if result.Running || result.Reason != "engine.json not found" {
+		t.Fatalf("expected not running, got %+v", result)
+	}
+
+	stateDir := t.TempDir()
+	server := httptest.NewServer(http.NotFoundHandler())
+	endpoint := server.URL
+	server.Close()
+	if err := daemon.WriteEngineState(filepath.Join(stateDir, "engine.json"), daemon.EngineState{Endpoint: endpoint}); err != nil {
+		t.Fatalf("write engine.json: %v", err)
+	}
+	result, err = RunEngineStatus(context.Background(), EngineOptions{Mode: "local", StateDir: stateDir, Timeout: time.Second})
+	if err != nil {
+		t.Fatalf("RunEngineStatus: %v", err)
+	}
+	if result.Running || !strings.HasPrefix(result.Reason, "health check failed") || result.Endpoint != endpoint {
+		t.Fatalf("expected stale engine.json to report not running, got %+v", result)
+	}
+}
+
+func TestRunEngineStatusRemoteRequiresEndpoint(t *testing.T) {
+	if _, err := RunEngineStatus(context.Background(), EngineOptions{Mode: "remote"}); err == nil {
+		t.Fatalf("expected endpoint error")
+	}
+}
+
+func TestPrintEngineStatus(t *testing.T) {
+	var out bytes.Buffer
+	PrintEngineStatus(&out, EngineStatusResult{
+		Running:            true,
+		OK:                 true,
+		Endpoint:           "127.0.0.1:1234",
+		Version:            "v1",
+		PID:                42,
+		StartedAt:          "2026-03-09T12:00:00Z",
+		UptimeSeconds:      3723.4,
+		IdleSeconds:        12,
+		IdleTimeoutSeconds: 30,
+		RunningJobs:        1,
+		ContainerRuntime:   "docker",
+		SnapshotBackend:    "copy",
+	})
+	want := "engine: running\n" +
+		"endpoint: 127.0.0.1:1234\n" +
+		"version: v1\n" +
+		"pid: 42\n" +
+		"uptime: 1h2m3s (since 2026-03-09T12:00:00Z)\n" +
+		"jobs: 1 running\n" +
+		"requests: 0 in flight\n" +
+		"idle: 12s of 30s (shutdown in 18s)\n" +
+		"containerRuntime: docker\n" +
+		"snapshotBackend: copy\n"
+	if out.String() != want {
+		t.Fatalf("unexpected output:\n%s", out.String())
+	}
+
+	out.Reset()
+	PrintEngineStatus(&out, EngineStatusResult{Running: true, Endpoint: "e", IdleSeconds: 5})
+	if !strings.Contains(out.String(), "engine: running (unhealthy)\n") || !strings.Contains(out.String(), "idle: 5s (no idle timeout)\n") {
+		t.Fatalf("unexpected output:\n%s", out.String())
+	}
+
+	out.Reset()
+	PrintEngineStatus(&out, EngineStatusResult{Reason: "engine.json not found"})
+	if out.String() != "engine: not running\nreason: engine.json not found\n" {
+		t.Fatalf("unexpected output:\n%s", out.String())
+	}
+}
+
+func TestRunEngineStatusReportsBuildAndVersionSkew(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/health":
+			io.WriteString(w, `{"ok":true,"version":"0.3.2","instanceId":"inst","pid":42}`)
+		case "/v1/engine/stats":
+			io.WriteString(w, `{"version":"0.3.2","instanceId":"inst","pid":42,"runningJobs":0}`)
+		case "/v1/version":
+			io.WriteString(w, `{"version":"0.3.2","apiVersion":"v1","revision":"abc123","buildTime":"2026-01-01T00:00:00Z","goVersion":"go1.22.0"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	result, err := RunEngineStatus(context.Background(), EngineOptions{Mode: "remote", Endpoint: server.URL, Timeout: time.Second, ClientVersion: "v0.4.0"})
+	if err != nil {
+		t.Fatalf("RunEngineStatus: %v", err)
+	}
+	if result.APIVersion != "v1" || result.Revision != "abc123" || result.GoVersion != "go1.22.0" {
+		t.Fatalf("unexpected build info: %+v", result)
+	}
+	if len(result.Warnings) != 1 || !strings.Contains(result.Warnings[0], "engine version 0.3.2 does not match CLI version v0.4.0") {
+		t.Fatalf("expected skew warning, got %+v", result.Warnings)
+	}
+	var out bytes.Buffer
+	PrintEngineStatus(&out, result)
+	if !strings.Contains(out.String(), "build: abc123 2026-01-01T00:00:00Z go1.22.0\n") || !strings.Contains(out.String(), "warning: engine version 0.3.2") {
+		t.Fatalf("unexpected output: %q", out.String())
+	}
+
+	result, err = RunEngineStatus(context.Background(), EngineOptions{Mode: "remote", Endpoint: server.URL, Timeout: time.Second, ClientVersion: "0.3.0"})
+	if err != nil {
+		t.Fatalf("RunEngineStatus: %v", err)
+	}
+	if len(result.Warnings) != 0 {
+		t.Fatalf("expected patch releases to be compatible, got %+v", result.Warnings)
+	}
+}
+
+func TestVersionSkewWarning(t *testing.T) {
+	cases := []struct {
+		client string
+		engine string
+		warn   bool
+	}{
+		{"dev", "0.4.0", false},
+		{"0.4.0", "dev", false},
+		{"v0.4.1", "0.4.0-rc.1", false},
+		{"0.4.0", "0.3.9", true},
+		{"1.0.0", "0.9.0+build.7", true},
+		{"", "", false},
+	}
+	for _, tc := range cases {
+		if got := versionSkewWarning(tc.client, tc.engine) != ""; got != tc.warn {
+			t.Fatalf("versionSkewWarning(%q, %q) warned=%v, want %v", tc.client, tc.engine, got, tc.warn)
+		}
+	}
+}
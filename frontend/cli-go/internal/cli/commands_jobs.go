@@ -0,0 +1,189 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/sqlrs/cli/internal/client"
+)
+
+type JobsLogsOptions struct {
+	Follow      bool
+	SinceOffset int
+}
+
+// RunJobsLogs prints a prepare job's events, one per line prefixed with its
+// offset. With Follow it attaches to a running job and returns once a terminal
+// status is seen; without it the job must already be finished.
+func RunJobsLogs(ctx context.Context, w io.Writer, opts PrepareOptions, jobID string, logsOpts JobsLogsOptions) error {
+	cliClient, err := prepareClient(ctx, opts)
+	if err != nil {
+		return err
+	}
+	jobID, status, err := lookupPrepareJob(ctx, cliClient, jobID)
+	if err != nil {
+		return err
+	}
+	if !logsOpts.Follow && !isTerminalPrepareStatus(status.Status) {
+		return fmt.Errorf("prepare job %s is %s; use --follow to stream its events", jobID, status.Status)
+	}
+	handle := func(index int, event client.PrepareJobEvent) (bool, error) {
+		if event.Type == "ping" {
+			return false, nil
+		}
+		fmt.Fprintf(w, "%d %s\n", index, formatPrepareEvent(event))
+		return event.Type == "status" && isTerminalPrepareStatus(event.Status), nil
+	}
+	ended := func(ctx context.Context) (bool, error) {
+		current, found, err := cliClient.GetPrepareJob(ctx, jobID)
+		if err != nil {
+			return false, err
+		}
+		if !found {
+			return false, fmt.Errorf("prepare job not found: %s", jobID)
+		}
+		return isTerminalPrepareStatus(current.Status), nil
+	}
+	eventsURL := "/v1/prepare-jobs/" + jobID + "/events"
+	return cliClient.FollowPrepareEvents(ctx, eventsURL, handle, client.FollowOptions{
+		SinceOffset: logsOpts.SinceOffset,
+		Ended:       ended,
+	})
+}
+
+// RunJobsShow prints a prepare job's summary followed by a table of its tasks
+// with their durations, so a slow job can be traced to the step that took the
+// time (for example an image pull in resolve_image versus the migration).
+func RunJobsShow(ctx context.Context, w io.Writer, opts PrepareOptions, jobID string) error {
+	cliClient, err := prepareClient(ctx, opts)
+	if err != nil {
+		return err
+	}
+	jobID, status, err := lookupPrepareJob(ctx, cliClient, jobID)
+	if err != nil {
+		return err
+	}
+	tasks, err := cliClient.ListTasks(ctx, jobID)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "job: %s\n", jobID)
+	fmt.Fprintf(w, "status: %s\n", status.Status)
+	fmt.Fprintf(w, "kind: %s\n", status.PrepareKind)
+	fmt.Fprintf(w, "image: %s\n", status.ImageID)
+	if duration := formatJobDuration(status.StartedAt, status.FinishedAt); duration != "" {
+		fmt.Fprintf(w, "duration: %s\n", duration)
+	}
+	if status.Error != nil {
+		fmt.Fprintf(w, "error: %s\n", status.Error.Message)
+	}
+	fmt.Fprintln(w)
+	printJobTaskTimings(w, tasks)
+	return nil
+}
+
+func printJobTaskTimings(w io.Writer, tasks []client.TaskEntry) {
+	headers := []string{"TASK_ID", "TYPE", "STATUS", "ARGS", "STARTED", "DURATION"}
+	rows := make([][]string, 0, len(tasks))
+	for _, task := range tasks {
+		rows = append(rows, []string{
+			task.TaskID,
+			task.Type,
+			task.Status,
+			strings.TrimSpace(task.ArgsSummary),
+			formatOptionalTimestamp(task.StartedAt, true),
+			formatTaskDuration(task),
+		})
+	}
+	printCompactTable(w, headers, rows, false, compactTableColumnGap)
+}
+
+func formatTaskDuration(task client.TaskEntry) string {
+	if task.DurationMs != nil {
+		return formatDurationMs(*task.DurationMs)
+	}
+	if task.Cached != nil && *task.Cached && task.StartedAt == nil {
+		return "cached"
+	}
+	return "-"
+}
+
+func formatJobDuration(startedAt, finishedAt *string) string {
+	if startedAt == nil || finishedAt == nil {
+		return ""
+	}
+	started, err := time.Parse(time.RFC3339Nano, *startedAt)
+	if err != nil {
+		return ""
+	}
+	finished, err := time.Parse(time.RFC3339Nano, *finishedAt)
+	if err != nil || finished.Before(started) {
+		return ""
+	}
+	return formatDurationMs(finished.Sub(started).Milliseconds())
+}
+
+func formatDurationMs(ms int64) string {
+	if ms < 1000 {
+		return fmt.Sprintf("%dms", ms)
+	}
+	return (time.Duration(ms) * time.Millisecond).Round(100 * time.Millisecond).String()
+}
+
+func isTerminalPrepareStatus(status string) bool {
+	return status == "succeeded" || status == "failed"
+}
+
+// RunJobsList prints the prepare jobs carrying every given label. An empty
+// label set lists all jobs, like `sqlrs ls --jobs`.
+func RunJobsList(ctx context.Context, w io.Writer, opts PrepareOptions, labels map[string]string) error {
+	cliClient, err := prepareClient(ctx, opts)
+	if err != nil {
+		return err
+	}
+	jobs, err := listJobsByLabels(ctx, cliClient, labels)
+	if err != nil {
+		return err
+	}
+	printJobsTable(w, jobs, false, false, false, false)
+	return nil
+}
+
+// RunJobsDelete deletes every prepare job carrying all given labels, one
+// delete call per job, and prints each result. Labels are required so a typo
+// cannot wipe the whole job list.
+func RunJobsDelete(ctx context.Context, w io.Writer, opts PrepareOptions, labels map[string]string) error {
+	if len(labels) == 0 {
+		return fmt.Errorf("at least one label is required")
+	}
+	cliClient, err := prepareClient(ctx, opts)
+	if err != nil {
+		return err
+	}
+	jobs, err := listJobsByLabels(ctx, cliClient, labels)
+	if err != nil {
+		return err
+	}
+	if len(jobs) == 0 {
+		fmt.Fprintln(w, "no matching jobs")
+		return nil
+	}
+	for _, job := range jobs {
+		result, _, err := cliClient.DeletePrepareJob(ctx, job.JobID, client.DeleteOptions{})
+		if err != nil {
+			return fmt.Errorf("delete prepare job %s: %w", job.JobID, err)
+		}
+		PrintRm(w, result)
+	}
+	return nil
+}
+
+func listJobsByLabels(ctx context.Context, cliClient *client.Client, labels map[string]string) ([]client.PrepareJobEntry, error) {
+	if len(labels) == 0 {
+		return cliClient.ListPrepareJobs(ctx, "")
+	}
+	return cliClient.ListPrepareJobsByLabels(ctx, labels)
+}
@@ -0,0 +1,260 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const jobsLogsEvents = `{"type":"status","ts":"2026-01-24T00:00:00Z","status":"running"}
+{"type":"log","ts":"2026-01-24T00:00:01Z","message":"psql started"}
+{"type":"status","ts":"2026-01-24T00:00:02Z","status":"succeeded"}
+`
+
+func newJobsLogsServer(t *testing.T, jobStatus func() string, events func(call int32) string) (*httptest.Server, *int32) {
+	t.Helper()
+	var eventCalls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/prepare-jobs/job-1":
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"job_id":"job-1","status":"`+jobStatus()+`","prepare_kind":"psql","image_id":"image"}`)
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/prepare-jobs/job-1/events":
+			call := atomic.AddInt32(&eventCalls, 1)
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			io.WriteString(w, events(call))
+			// Flush to send a chunked body like the engine's live stream.
+			w.(http.Flusher).Flush()
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &eventCalls
+}
+
+func jobsLogsPrepareOptions(endpoint string) PrepareOptions {
+	return PrepareOptions{Mode: "remote", Endpoint: endpoint, Timeout: time.Second}
+}
+
+func TestRunJobsLogsFollowStopsAtTerminalStatus(t *testing.T) {
+	server, _ := newJobsLogsServer(t, func() string { return "running" }, func(int32) string { return jobsLogsEvents })
+
+	var out bytes.Buffer
+	err := RunJobsLogs(context.Background(), &out, jobsLogsPrepareOptions(server.URL), "job-1", JobsLogsOptions{Follow: true})
+	if err != nil {
+		t.Fatalf("RunJobsLogs: %v", err)
+	}
+	want := "0 prepare status: running\n1 prepare log: psql started\n2 prepare status: succeeded\n"
+	if out.String() != want {
+		t.Fatalf("unexpected output:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestRunJobsLogsSkipsPingEvents(t *testing.T) {
+	lines := strings.SplitAfter(jobsLogsEvents, "\n")
+	events := lines[0] + `{"type":"ping","ts":"2026-01-24T00:00:00.5Z","task_id":"execute-0"}` + "\n" + lines[1] + lines[2]
+	server, _ := newJobsLogsServer(t, func() string { return "succeeded" }, func(int32) string { return events })
+
+	var out bytes.Buffer
+	err := RunJobsLogs(context.Background(), &out, jobsLogsPrepareOptions(server.URL), "job-1", JobsLogsOptions{})
+	if err != nil {
+		t.Fatalf("RunJobsLogs: %v", err)
+	}
+	want := "0 prepare status: running\n2 prepare log: psql started\n3 prepare status: succeeded\n"
+	if out.String() != want {
+		t.Fatalf("unexpected output:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestRunJobsLogsFollowReconnectsAndResumes(t *testing.T) {
+	lines := strings.SplitAfter(jobsLogsEvents, "\n")
+	server, calls := newJobsLogsServer(t, func() string { return "running" }, func(call int32) string {
+		if call == 1 {
+			return lines[0] + lines[1]
+		}
+		return jobsLogsEvents
+	})
+
+	var out bytes.Buffer
+	err := RunJobsLogs(context.Background(), &out, jobsLogsPrepareOptions(server.URL), "job-1", JobsLogsOptions{Follow: true})
+	if err != nil {
+		t.Fatalf("RunJobsLogs: %v", err)
+	}
+	if atomic.LoadInt32(calls) != 2 {
+		t.Fatalf("expected reconnect, got %d event calls", atomic.LoadInt32(calls))
+	}
+	if strings.Count(out.String(), "psql started") != 1 || !strings.HasSuffix(out.String(), "2 prepare status: succeeded\n") {
+		t.Fatalf("expected events printed once, got:\n%s", out.String())
+	}
+}
+
+func TestRunJobsLogsSinceOffsetOnTerminalJob(t *testing.T) {
+	// The terminal status event is before the offset, so the stream ends
+	// without one and the job status decides when to stop.
+	server, calls := newJobsLogsServer(t, func() string { return "succeeded" }, func(int32) string { return jobsLogsEvents })
+
+	var out bytes.Buffer
+	err := RunJobsLogs(context.Background(), &out, jobsLogsPrepareOptions(server.URL), "job-1", JobsLogsOptions{SinceOffset: 3})
+	if err != nil {
+		t.Fatalf("RunJobsLogs: %v", err)
+	}
+	if out.Len() != 0 {
+		t.Fatalf("expected no events past offset, got:\n%s", out.String())
+	}
+	if atomic.LoadInt32(calls) != 1 {
+		t.Fatalf("expected a single events request, got %d", atomic.LoadInt32(calls))
+	}
+}
+
+func TestRunJobsLogsRequiresFollowForRunningJob(t *testing.T) {
+	server, _ := newJobsLogsServer(t, func() string { return "running" }, func(int32) string { return jobsLogsEvents })
+
+	err := RunJobsLogs(context.Background(), io.Discard, jobsLogsPrepareOptions(server.URL), "job-1", JobsLogsOptions{})
+	if err == nil || !strings.Contains(err.Error(), "--follow") {
+		t.Fatalf("expected --follow hint, got %v", err)
+	}
+}
+
+func TestRunJobsLogsNotFound(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/prepare-jobs" {
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `[]`)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	err := RunJobsLogs(context.Background(), io.Discard, jobsLogsPrepareOptions(server.URL), "missing", JobsLogsOptions{Follow: true})
+	if err == nil || !strings.Contains(err.Error(), "prepare job not found") {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+}
+
+func TestRunJobsShowPrintsTaskDurations(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/v1/prepare-jobs/job-1":
+			io.WriteString(w, `{"job_id":"job-1","status":"succeeded","prepare_kind":"psql","image_id":"postgres:17","started_at":"2026-01-24T00:00:00Z","finished_at":"2026-01-24T00:01:05Z"}`)
+		case r.URL.Path == "/v1/tasks" && r.URL.Query().Get("job") == "job-1":
+			io.WriteString(w, `[
+{"task_id":"plan","job_id":"job-1","type":"plan","status":"succeeded","started_at":"2026-01-24T00:00:00Z","finished_at":"2026-01-24T00:00:00.250Z","duration_ms":250},
+{"task_id":"resolve-image","job_id":"job-1","type":"resolve_image","status":"succeeded","started_at":"2026-01-24T00:00:00Z","finished_at":"2026-01-24T00:01:00Z","duration_ms":60000},
+{"task_id":"execute-0","job_id":"job-1","type":"state_execute","status":"succeeded","args_summary":"-c select 1","cached":true,"finished_at":"2026-01-24T00:01:00Z"},
+{"task_id":"prepare-instance","job_id":"job-1","type":"prepare_instance","status":"queued"}
+]`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	var out bytes.Buffer
+	if err := RunJobsShow(context.Background(), &out, jobsLogsPrepareOptions(server.URL), "job-1"); err != nil {
+		t.Fatalf("RunJobsShow: %v", err)
+	}
+	text := out.String()
+	for _, want := range []string{
+		"job: job-1\n",
+		"status: succeeded\n",
+		"duration: 1m5s\n",
+		"TASK_ID",
+		"DURATION",
+		"250ms",
+		"1m0s",
+		"cached",
+		"-c select 1",
+		"2026-01-24T00:00:00Z",
+	} {
+		if !strings.Contains(text, want) {
+			t.Fatalf("expected %q in output:\n%s", want, text)
+		}
+	}
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	if last := lines[len(lines)-1]; !strings.HasPrefix(last, "prepare-instance") || !strings.HasSuffix(last, "-") {
+		t.Fatalf("expected unfinished task without duration, got %q", last)
+	}
+}
+
+func TestFormatDurationMs(t *testing.T) {
+	cases := map[int64]string{
+		0:       "0ms",
+		999:     "999ms",
+		1500:    "1.5s",
+		61234:   "1m1.2s",
+		3600000: "1h0m0s",
+	}
+	for ms, want := range cases {
+		if got := formatDurationMs(ms); got != want {
+			t.Fatalf("formatDurationMs(%d) = %q, want %q", ms, got, want)
+		}
+	}
+}
+
+func TestRunJobsDeleteByLabelDeletesEachMatch(t *testing.T) {
+	var deleted []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/prepare-jobs":
+			if got := r.URL.Query()["label"]; len(got) != 1 || got[0] != "pr=1234" {
+				t.Errorf("unexpected label query: %v", got)
+			}
+			io.WriteString(w, `[{"job_id":"job-1","status":"succeeded","prepare_kind":"psql","image_id":"image","labels":{"pr":"1234"}},{"job_id":"job-2","status":"failed","prepare_kind":"psql","image_id":"image","labels":{"pr":"1234"}}]`)
+		case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/v1/prepare-jobs/"):
+			jobID := strings.TrimPrefix(r.URL.Path, "/v1/prepare-jobs/")
+			deleted = append(deleted, jobID)
+			io.WriteString(w, `{"dry_run":false,"outcome":"deleted","root":{"kind":"job","id":"`+jobID+`"}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	var out bytes.Buffer
+	if err := RunJobsDelete(context.Background(), &out, jobsLogsPrepareOptions(server.URL), map[string]string{"pr": "1234"}); err != nil {
+		t.Fatalf("RunJobsDelete: %v", err)
+	}
+	if strings.Join(deleted, ",") != "job-1,job-2" {
+		t.Fatalf("unexpected deletes: %v", deleted)
+	}
+	if out.String() != "job job-1 deleted\njob job-2 deleted\n" {
+		t.Fatalf("unexpected output:\n%s", out.String())
+	}
+}
+
+func TestRunJobsDeleteRequiresLabels(t *testing.T) {
+	err := RunJobsDelete(context.Background(), io.Discard, jobsLogsPrepareOptions("http://127.0.0.1:1"), nil)
+	if err == nil || !strings.Contains(err.Error(), "label") {
+		t.Fatalf("expected label error, got %v", err)
+	}
+}
+
+func TestRunJobsListWithoutLabelsListsAllJobs(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/prepare-jobs" || r.URL.RawQuery != "" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `[{"job_id":"job-1","status":"succeeded","prepare_kind":"psql","image_id":"image"}]`)
+	}))
+	t.Cleanup(server.Close)
+
+	var out bytes.Buffer
+	if err := RunJobsList(context.Background(), &out, jobsLogsPrepareOptions(server.URL), nil); err != nil {
+		t.Fatalf("RunJobsList: %v", err)
+	}
+	if !strings.Contains(out.String(), "JOB_ID") || !strings.Contains(out.String(), "job-1") {
+		t.Fatalf("unexpected output:\n%s", out.String())
+	}
+}
@@ -3,7 +3,6 @@ package cli
 import (
 	"bufio"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -11,7 +10,6 @@ import (
 	"os"
 	"os/signal"
 	"runtime"
-	"strconv"
 	"strings"
 	"sync/atomic"
 	"time"
@@ -19,7 +17,6 @@ import (
 	"github.com/sqlrs/cli/internal/client"
 	"github.com/sqlrs/cli/internal/daemon"
 	"github.com/sqlrs/cli/internal/remotesource"
-	"github.com/sqlrs/cli/internal/util"
 
 	"golang.org/x/term"
 )
@@ -54,8 +51,16 @@ const (
 
 type waitPrepareOptions struct {
 	allowControls bool
+	// plainProgress writes each progress step once per line, without the
+	// spinner or in-place rewrites.
+	plainProgress bool
+	// noStream skips the events stream and polls the job status instead.
+	noStream bool
 }
 
+// OutputFormatJSON selects machine-readable prepare and plan output.
+const OutputFormatJSON = "json"
+
 type PrepareOptions struct {
 	ProfileName     string
 	Mode            string
@@ -74,8 +79,23 @@ type PrepareOptions struct {
 	WSLDistro       string
 	Timeout         time.Duration
 	IdleTimeout     time.Duration
-	StartupTimeout  time.Duration
-	Verbose         bool
+	// ContainerRuntime, SnapshotBackend and StateStore configure an engine
+	// that this invocation autostarts; a running engine is reused as is.
+	// StateStore replaces EngineStoreDir for that launch.
+	ContainerRuntime string
+	SnapshotBackend  string
+	StateStore       string
+	// EngineOverrideFlags names the engine flags given on the command line,
+	// reported as ignored when a running engine is reused.
+	EngineOverrideFlags []string
+	StartupTimeout      time.Duration
+	Verbose             bool
+	// OutputFormat is "human" (default) or "json". In json mode progress is
+	// written to stderr as plain lines so that stdout carries only the result.
+	OutputFormat string
+	// NoStream polls the job status instead of following the events stream,
+	// for proxies that buffer streamed responses.
+	NoStream bool
 
 	ImageID           string
 	PsqlArgs          []string
@@ -94,6 +114,12 @@ type PrepareOptions struct {
 	SourceSyncMode       string
 	SourceSyncMaxRounds  int
 	SourceSync           *remotesource.Options
+	// LiquibaseStdin is a changelog sent inline instead of --changelog-file.
+	LiquibaseStdin       *string
+	LiquibaseStdinFormat string
+	// CancelOnDisconnect asks the engine to cancel the job once every client
+	// watching it has gone away.
+	CancelOnDisconnect bool
 }
 
 func RunPrepare(ctx context.Context, opts PrepareOptions) (client.PrepareJobResult, error) {
@@ -121,6 +147,8 @@ func RunPrepare(ctx context.Context, opts PrepareOptions) (client.PrepareJobResu
 
 	status, err := waitForPrepareWithOptions(ctx, cliClient, jobID, eventsURL, os.Stderr, opts.Verbose, waitPrepareOptions{
 		allowControls: !opts.DisableControlPrompt,
+		plainProgress: opts.OutputFormat == OutputFormatJSON,
+		noStream:      opts.NoStream,
 	})
 	if err != nil {
 		return client.PrepareJobResult{}, err
@@ -159,7 +187,10 @@ func RunPlan(ctx context.Context, opts PrepareOptions) (PlanResult, error) {
 		return PlanResult{}, fmt.Errorf("prepare events url missing")
 	}
 
-	status, err := waitForPrepareWithOptions(ctx, cliClient, jobID, eventsURL, os.Stderr, opts.Verbose, waitPrepareOptions{})
+	status, err := waitForPrepareWithOptions(ctx, cliClient, jobID, eventsURL, os.Stderr, opts.Verbose, waitPrepareOptions{
+		plainProgress: opts.OutputFormat == OutputFormatJSON,
+		noStream:      opts.NoStream,
+	})
 	if err != nil {
 		return PlanResult{}, err
 	}
@@ -186,45 +217,59 @@ func SubmitPrepare(ctx context.Context, opts PrepareOptions) (client.PrepareJobA
 }
 
 func RunWatch(ctx context.Context, opts PrepareOptions, jobID string) (client.PrepareJobStatus, error) {
-	jobID = strings.TrimSpace(jobID)
-	if jobID == "" {
+	if strings.TrimSpace(jobID) == "" {
 		return client.PrepareJobStatus{}, fmt.Errorf("prepare job id is required")
 	}
-	requestedJobID := jobID
 	cliClient, err := prepareClient(ctx, opts)
 	if err != nil {
 		return client.PrepareJobStatus{}, err
 	}
-	status, found, err := cliClient.GetPrepareJob(ctx, jobID)
+	jobID, status, err := lookupPrepareJob(ctx, cliClient, jobID)
 	if err != nil {
 		return client.PrepareJobStatus{}, err
 	}
+	switch status.Status {
+	case "succeeded":
+		return status, nil
+	case "failed":
+		return client.PrepareJobStatus{}, prepareFailureError(status, nil)
+	}
+	eventsURL := "/v1/prepare-jobs/" + jobID + "/events"
+	return waitForPrepareWithOptions(ctx, cliClient, jobID, eventsURL, os.Stderr, opts.Verbose, waitPrepareOptions{
+		allowControls: !opts.DisableControlPrompt,
+		noStream:      opts.NoStream,
+	})
+}
+
+// lookupPrepareJob fetches a prepare job by id, falling back to a unique
+// id prefix match. It returns the resolved job id with its status.
+func lookupPrepareJob(ctx context.Context, cliClient *client.Client, jobID string) (string, client.PrepareJobStatus, error) {
+	jobID = strings.TrimSpace(jobID)
+	if jobID == "" {
+		return "", client.PrepareJobStatus{}, fmt.Errorf("prepare job id is required")
+	}
+	requestedJobID := jobID
+	status, found, err := cliClient.GetPrepareJob(ctx, jobID)
+	if err != nil {
+		return "", client.PrepareJobStatus{}, err
+	}
 	if !found {
 		resolvedJobID, err := resolvePrepareJobByPrefix(ctx, cliClient, requestedJobID)
 		if err != nil {
-			return client.PrepareJobStatus{}, err
+			return "", client.PrepareJobStatus{}, err
 		}
 		if resolvedJobID != "" {
 			jobID = resolvedJobID
 			status, found, err = cliClient.GetPrepareJob(ctx, jobID)
 			if err != nil {
-				return client.PrepareJobStatus{}, err
+				return "", client.PrepareJobStatus{}, err
 			}
 		}
 		if !found {
-			return client.PrepareJobStatus{}, fmt.Errorf("prepare job not found: %s", requestedJobID)
+			return "", client.PrepareJobStatus{}, fmt.Errorf("prepare job not found: %s", requestedJobID)
 		}
 	}
-	switch status.Status {
-	case "succeeded":
-		return status, nil
-	case "failed":
-		return client.PrepareJobStatus{}, prepareFailureError(status, nil)
-	}
-	eventsURL := "/v1/prepare-jobs/" + jobID + "/events"
-	return waitForPrepareWithOptions(ctx, cliClient, jobID, eventsURL, os.Stderr, opts.Verbose, waitPrepareOptions{
-		allowControls: !opts.DisableControlPrompt,
-	})
+	return jobID, status, nil
 }
 
 func resolvePrepareJobByPrefix(ctx context.Context, cliClient *client.Client, prefix string) (string, error) {
@@ -263,27 +308,36 @@ func prepareClient(ctx context.Context, opts PrepareOptions) (*client.Client, er
 			if opts.Verbose {
 				fmt.Fprintln(os.Stderr, "checking local engine state")
 			}
+			engineStoreDir := opts.EngineStoreDir
+			if strings.TrimSpace(opts.StateStore) != "" {
+				engineStoreDir = strings.TrimSpace(opts.StateStore)
+			}
 			resolved, err := daemon.ConnectOrStart(ctx, daemon.ConnectOptions{
-				Endpoint:        endpoint,
-				Autostart:       opts.Autostart,
-				DaemonPath:      opts.DaemonPath,
-				RunDir:          opts.RunDir,
-				StateDir:        opts.StateDir,
-				EngineRunDir:    opts.EngineRunDir,
-				EngineStatePath: opts.EngineStatePath,
-				EngineStoreDir:  opts.EngineStoreDir,
-				WSLVHDXPath:     opts.WSLVHDXPath,
-				WSLMountUnit:    opts.WSLMountUnit,
-				WSLMountFSType:  opts.WSLMountFSType,
-				WSLDistro:       opts.WSLDistro,
-				IdleTimeout:     opts.IdleTimeout,
-				StartupTimeout:  opts.StartupTimeout,
-				ClientTimeout:   opts.Timeout,
-				Verbose:         opts.Verbose,
+				Endpoint:         endpoint,
+				Autostart:        opts.Autostart,
+				DaemonPath:       opts.DaemonPath,
+				RunDir:           opts.RunDir,
+				StateDir:         opts.StateDir,
+				EngineRunDir:     opts.EngineRunDir,
+				EngineStatePath:  opts.EngineStatePath,
+				EngineStoreDir:   engineStoreDir,
+				WSLVHDXPath:      opts.WSLVHDXPath,
+				WSLMountUnit:     opts.WSLMountUnit,
+				WSLMountFSType:   opts.WSLMountFSType,
+				WSLDistro:        opts.WSLDistro,
+				IdleTimeout:      opts.IdleTimeout,
+				ContainerRuntime: opts.ContainerRuntime,
+				SnapshotBackend:  opts.SnapshotBackend,
+				StartupTimeout:   opts.StartupTimeout,
+				ClientTimeout:    opts.Timeout,
+				Verbose:          opts.Verbose,
 			})
 			if err != nil {
 				return nil, err
 			}
+			if !resolved.Started && len(opts.EngineOverrideFlags) > 0 {
+				fmt.Fprintf(os.Stderr, "warning: engine already running; ignoring %s\n", strings.Join(opts.EngineOverrideFlags, ", "))
+			}
 			endpoint = resolved.Endpoint
 			authToken = resolved.AuthToken
 			if opts.Verbose {
@@ -340,6 +394,10 @@ func createPrepareJob(ctx context.Context, opts PrepareOptions, planOnly bool) (
 		WorkDir:           opts.WorkDir,
 		Stdin:             opts.Stdin,
 		PlanOnly:          planOnly,
+
+		LiquibaseStdin:       opts.LiquibaseStdin,
+		LiquibaseStdinFormat: opts.LiquibaseStdinFormat,
+		CancelOnDisconnect:   opts.CancelOnDisconnect,
 	}
 	accepted, err := createPrepareJobWithSourceSync(ctx, cliClient, opts, request)
 	if err != nil {
@@ -350,13 +408,25 @@ func createPrepareJob(ctx context.Context, opts PrepareOptions, planOnly bool) (
 
 func createPrepareJobWithSourceSync(ctx context.Context, cliClient *client.Client, opts PrepareOptions, request client.PrepareJobRequest) (client.PrepareJobAccepted, error) {
 	if opts.SourceSync == nil || !opts.SourceSync.Enabled {
-		return cliClient.CreatePrepareJob(ctx, request)
+		accepted, err := cliClient.CreatePrepareJob(ctx, request)
+		return accepted, submitError(err)
 	}
 	syncOpts := *opts.SourceSync
 	syncOpts.Uploader = cliClient
-	return remotesource.Execute(ctx, syncOpts, request, func(ctx context.Context, req client.PrepareJobRequest) (client.PrepareJobAccepted, error) {
+	accepted, err := remotesource.Execute(ctx, syncOpts, request, func(ctx context.Context, req client.PrepareJobRequest) (client.PrepareJobAccepted, error) {
 		return cliClient.CreatePrepareJob(ctx, req)
 	})
+	return accepted, submitError(err)
+}
+
+// submitError explains a submit the engine rejected because it is at its job
+// or submit-rate limit; other errors are returned unchanged.
+func submitError(err error) error {
+	var apiErr *client.ErrorResponseError
+	if errors.As(err, &apiErr) && (apiErr.Code == ErrorCodeResourceExhausted || apiErr.StatusCode == http.StatusTooManyRequests) {
+		return fmt.Errorf("engine busy, retry shortly: %w", err)
+	}
+	return err
 }
 
 func waitForPrepareWithOptions(ctx context.Context, cliClient *client.Client, jobID string, eventsURL string, progress io.Writer, verbose bool, options waitPrepareOptions) (client.PrepareJobStatus, error) {
@@ -364,6 +434,7 @@ func waitForPrepareWithOptions(ctx context.Context, cliClient *client.Client, jo
 		return client.PrepareJobStatus{}, fmt.Errorf("prepare events url missing")
 	}
 	tracker := newPrepareProgress(progress, verbose)
+	tracker.plain = options.plainProgress
 	defer tracker.Close()
 
 	controlsEnabled := options.allowControls && canUsePrepareControlPrompt(progress)
@@ -373,12 +444,39 @@ func waitForPrepareWithOptions(ctx context.Context, cliClient *client.Client, jo
 		defer signal.Stop(interrupts)
 	}
 
-	resumeIndex := 0
-	for {
-		rangeHeader := ""
-		if resumeIndex > 0 {
-			rangeHeader = fmt.Sprintf("events=%d-", resumeIndex)
+	var pollInterrupts <-chan os.Signal
+	if controlsEnabled {
+		pollInterrupts = interrupts
+	}
+	if options.noStream {
+		return pollPrepareJob(ctx, cliClient, jobID, tracker, pollInterrupts)
+	}
+
+	var final client.PrepareJobStatus
+	handle := func(_ int, event client.PrepareJobEvent) (bool, error) {
+		tracker.Update(event)
+		if event.Type != "status" {
+			return false, nil
+		}
+		status, found, err := cliClient.GetPrepareJob(ctx, jobID)
+		if err != nil {
+			return false, err
+		}
+		if !found {
+			return false, fmt.Errorf("prepare job not found: %s", jobID)
 		}
+		switch status.Status {
+		case "succeeded":
+			final = status
+			return true, nil
+		case "failed":
+			return false, prepareFailureError(status, tracker)
+		}
+		return false, nil
+	}
+
+	stream := cliClient.NewEventsStream(eventsURL, 0, prepareStreamGrace)
+	for {
 		streamCtx := ctx
 		streamCancel := func() {}
 		var interruptFired int32
@@ -405,105 +503,30 @@ func waitForPrepareWithOptions(ctx context.Context, cliClient *client.Client, jo
 			}()
 		}
 
-		resp, err := cliClient.StreamPrepareEvents(streamCtx, eventsURL, rangeHeader)
+		pass, err := stream.Pass(streamCtx, handle)
+		cleanupInterrupt()
+		interrupted := controlsEnabled && atomic.LoadInt32(&interruptFired) == 1 && ctx.Err() == nil
 		if err != nil {
-			cleanupInterrupt()
-			if controlsEnabled && atomic.LoadInt32(&interruptFired) == 1 && ctx.Err() == nil {
-				status, actionErr := handlePrepareControlAction(ctx, cliClient, jobID, tracker, interrupts)
-				if actionErr != nil {
-					return client.PrepareJobStatus{}, actionErr
-				}
-				if status != nil {
-					return *status, nil
-				}
-				continue
-			}
-			if ctx.Err() != nil {
-				return client.PrepareJobStatus{}, ctx.Err()
-			}
-			return client.PrepareJobStatus{}, err
-		}
-		if resp.StatusCode >= 400 && resp.StatusCode < 500 {
-			resp.Body.Close()
-			cleanupInterrupt()
-			return client.PrepareJobStatus{}, fmt.Errorf("events stream returned status %d", resp.StatusCode)
-		}
-		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
-			resp.Body.Close()
-			cleanupInterrupt()
-			return client.PrepareJobStatus{}, fmt.Errorf("events stream returned status %d", resp.StatusCode)
-		}
-
-		startIndex := 0
-		if resp.StatusCode == http.StatusPartialContent {
-			rangeStart, err := parseEventsContentRange(resp.Header.Get("Content-Range"))
-			if err != nil {
-				resp.Body.Close()
-				cleanupInterrupt()
+			if !pass.ConnectFailed {
 				return client.PrepareJobStatus{}, err
 			}
-			startIndex = rangeStart
-			if rangeStart > resumeIndex {
-				resumeIndex = rangeStart
-			}
-		}
-
-		counter := &countingReader{reader: resp.Body}
-		reader := util.NewNDJSONReader(counter)
-		currentIndex := startIndex
-		readErr := error(nil)
-		for {
-			line, err := reader.Next()
-			if err == io.EOF {
-				break
-			}
-			if err != nil {
-				readErr = err
-				break
-			}
-			if len(line) == 0 {
-				continue
-			}
-			if currentIndex < resumeIndex {
-				currentIndex++
-				continue
-			}
-			var event client.PrepareJobEvent
-			if err := json.Unmarshal(line, &event); err != nil {
-				resp.Body.Close()
-				cleanupInterrupt()
+			if !interrupted {
+				if ctx.Err() != nil {
+					return client.PrepareJobStatus{}, ctx.Err()
+				}
 				return client.PrepareJobStatus{}, err
 			}
-			tracker.Update(event)
-			if event.Type == "status" {
-				status, found, err := cliClient.GetPrepareJob(ctx, jobID)
-				if err != nil {
-					resp.Body.Close()
-					cleanupInterrupt()
-					return client.PrepareJobStatus{}, err
-				}
-				if !found {
-					resp.Body.Close()
-					cleanupInterrupt()
-					return client.PrepareJobStatus{}, fmt.Errorf("prepare job not found: %s", jobID)
-				}
-				switch status.Status {
-				case "succeeded":
-					resp.Body.Close()
-					cleanupInterrupt()
-					return status, nil
-				case "failed":
-					resp.Body.Close()
-					cleanupInterrupt()
-					return client.PrepareJobStatus{}, prepareFailureError(status, tracker)
-				}
+		}
+		if pass.Done {
+			return final, nil
+		}
+		if pass.Stalled {
+			if verbose {
+				fmt.Fprintf(progress, "no events received within %s, polling prepare job status\n", prepareStreamGrace)
 			}
-			currentIndex++
-			resumeIndex = currentIndex
+			return pollPrepareJob(ctx, cliClient, jobID, tracker, pollInterrupts)
 		}
-		resp.Body.Close()
-		cleanupInterrupt()
-		if controlsEnabled && atomic.LoadInt32(&interruptFired) == 1 && ctx.Err() == nil {
+		if interrupted {
 			status, actionErr := handlePrepareControlAction(ctx, cliClient, jobID, tracker, interrupts)
 			if actionErr != nil {
 				return client.PrepareJobStatus{}, actionErr
@@ -513,17 +536,14 @@ func waitForPrepareWithOptions(ctx context.Context, cliClient *client.Client, jo
 			}
 			continue
 		}
-		if readErr != nil {
+		if pass.ReadErr != nil {
 			if ctx.Err() != nil {
 				return client.PrepareJobStatus{}, ctx.Err()
 			}
 			continue
 		}
-		if resp.StatusCode == http.StatusOK && resp.ContentLength >= 0 {
-			if counter.count >= resp.ContentLength {
-				return client.PrepareJobStatus{}, fmt.Errorf("prepare job events stream ended without terminal status")
-			}
-			continue
+		if pass.Exhausted {
+			return client.PrepareJobStatus{}, fmt.Errorf("prepare job events stream ended without terminal status")
 		}
 		if ctx.Err() != nil {
 			return client.PrepareJobStatus{}, ctx.Err()
@@ -583,20 +603,39 @@ func drainInterruptSignals(interrupts <-chan os.Signal) {
 	}
 }
 
-func prepareFailureError(status client.PrepareJobStatus, tracker *prepareProgress) error {
-	if status.Error != nil {
-		if tracker != nil {
-			tracker.Update(client.PrepareJobEvent{
-				Type:  "error",
-				Error: status.Error,
-			})
-		}
-		if status.Error.Details != "" {
-			return fmt.Errorf("%s: %s", status.Error.Message, status.Error.Details)
+// PrepareJobFailedError reports a prepare job that finished with status
+// failed, keeping the engine error for structured output. The message ends
+// with a hint chosen by error code when there is one.
+type PrepareJobFailedError struct {
+	JobID string
+	Err   *client.ErrorResponse
+}
+
+func (e *PrepareJobFailedError) Error() string {
+	if e.Err == nil {
+		return "prepare job failed"
+	}
+	message := e.Err.Message
+	if e.Err.Details != "" {
+		message = fmt.Sprintf("%s: %s", e.Err.Message, e.Err.Details)
+	}
+	if hint := ErrorCodeHint(e.Err.Code); hint != "" {
+		if e.JobID != "" {
+			hint = strings.ReplaceAll(hint, "<job-id>", e.JobID)
 		}
-		return fmt.Errorf("%s", status.Error.Message)
+		message += "\nhint: " + hint
+	}
+	return message
+}
+
+func prepareFailureError(status client.PrepareJobStatus, tracker *prepareProgress) error {
+	if status.Error != nil && tracker != nil {
+		tracker.Update(client.PrepareJobEvent{
+			Type:  "error",
+			Error: status.Error,
+		})
 	}
-	return fmt.Errorf("prepare job failed")
+	return &PrepareJobFailedError{JobID: status.JobID, Err: status.Error}
 }
 
 func defaultCanUsePrepareControlPrompt(progress io.Writer) bool {
@@ -717,48 +756,17 @@ func confirmPrepareStop(reader *bufio.Reader, writer io.Writer, interrupts <-cha
 	}
 }
 
-type countingReader struct {
-	reader io.Reader
-	count  int64
-}
-
-func (c *countingReader) Read(p []byte) (int, error) {
-	n, err := c.reader.Read(p)
-	c.count += int64(n)
-	return n, err
-}
-
-func parseEventsContentRange(value string) (int, error) {
-	value = strings.TrimSpace(value)
-	if value == "" {
-		return 0, fmt.Errorf("missing content range")
-	}
-	if !strings.HasPrefix(value, "events ") {
-		return 0, fmt.Errorf("unexpected content range: %s", value)
-	}
-	value = strings.TrimPrefix(value, "events ")
-	parts := strings.SplitN(value, "/", 2)
-	rangePart := parts[0]
-	dash := strings.Index(rangePart, "-")
-	if dash == -1 {
-		return 0, fmt.Errorf("invalid content range: %s", value)
-	}
-	start, err := strconv.Atoi(strings.TrimSpace(rangePart[:dash]))
-	if err != nil {
-		return 0, fmt.Errorf("invalid content range: %s", value)
-	}
-	return start, nil
-}
-
 type prepareProgress struct {
 	writer       io.Writer
 	spinner      []string
 	spinnerIndex int
 	lastKey      string
+	lastLine     string
 	lastLen      int
 	lastVisible  int
 	wroteLine    bool
 	verbose      bool
+	plain        bool
 	clearLine    bool
 	minSpinner   bool
 	spinnerShown bool
@@ -778,6 +786,10 @@ func newPrepareProgress(writer io.Writer, verbose bool) *prepareProgress {
 }
 
 func (p *prepareProgress) Update(event client.PrepareJobEvent) {
+	if event.Type == "ping" {
+		p.tick()
+		return
+	}
 	base := formatPrepareEvent(event)
 	if p.verbose {
 		if p.writer != io.Discard {
@@ -787,21 +799,40 @@ func (p *prepareProgress) Update(event client.PrepareJobEvent) {
 		return
 	}
 	key := prepareEventKey(event)
-	if key == p.lastKey && p.wroteLine {
-		p.spinnerIndex = (p.spinnerIndex + 1) % len(p.spinner)
-		if p.minSpinner {
-			p.writeSpinner(p.spinner[p.spinnerIndex])
+	if p.plain {
+		if key == p.lastKey || p.writer == io.Discard {
 			return
 		}
-		p.writeLine(base + " " + p.spinner[p.spinnerIndex])
+		p.lastKey = key
+		fmt.Fprintln(p.writer, base)
+		return
+	}
+	if key == p.lastKey && p.wroteLine {
+		p.lastLine = base
+		p.tick()
 		return
 	}
 	p.lastKey = key
+	p.lastLine = base
 	p.spinnerIndex = 0
 	p.spinnerShown = false
 	p.writeLine(base)
 }
 
+// tick advances the spinner on the current line; engine ping events and
+// repeated events use it. Verbose and plain output have no spinner.
+func (p *prepareProgress) tick() {
+	if p.verbose || p.plain || !p.wroteLine {
+		return
+	}
+	p.spinnerIndex = (p.spinnerIndex + 1) % len(p.spinner)
+	if p.minSpinner {
+		p.writeSpinner(p.spinner[p.spinnerIndex])
+		return
+	}
+	p.writeLine(p.lastLine + " " + p.spinner[p.spinnerIndex])
+}
+
 func (p *prepareProgress) Close() {
 	if p.writer == io.Discard || !p.wroteLine || p.verbose {
 		return
@@ -910,6 +941,34 @@ func prepareEventKey(event client.PrepareJobEvent) string {
 	return fmt.Sprintf("%s|%s|%s|%s", event.Type, event.Status, event.TaskID, event.Message)
 }
 
+// summarizePlanEvent describes a plan event's state_execute tasks as
+// "2/3 states cached, building execute-2", naming at most three builds.
+func summarizePlanEvent(tasks []client.PlanTask) string {
+	total := 0
+	var building []string
+	for _, task := range tasks {
+		if task.Type != "state_execute" {
+			continue
+		}
+		total++
+		if task.Cached == nil || !*task.Cached {
+			building = append(building, task.TaskID)
+		}
+	}
+	if total == 0 {
+		return ""
+	}
+	summary := fmt.Sprintf("%d/%d states cached", total-len(building), total)
+	if len(building) == 0 {
+		return summary
+	}
+	names := building
+	if len(names) > 3 {
+		names = append(names[:3:3], fmt.Sprintf("%d more", len(building)-3))
+	}
+	return summary + ", building " + strings.Join(names, ", ")
+}
+
 func formatPrepareEvent(event client.PrepareJobEvent) string {
 	message := strings.TrimSpace(event.Message)
 	if message == "" && event.Error != nil {
@@ -935,16 +994,25 @@ func formatPrepareEvent(event client.PrepareJobEvent) string {
 		}
 		return "prepare status" + suffix
 	case "task":
-		if event.TaskID != "" && event.Status != "" {
-			return fmt.Sprintf("prepare task %s: %s%s", event.TaskID, event.Status, suffix)
+		taskID := event.TaskID
+		if taskID != "" && event.Progress != nil && event.Progress.Total > 0 {
+			taskID = fmt.Sprintf("%s [%d/%d]", taskID, event.Progress.Completed, event.Progress.Total)
 		}
-		if event.TaskID != "" {
-			return fmt.Sprintf("prepare task %s%s", event.TaskID, suffix)
+		if taskID != "" && event.Status != "" {
+			return fmt.Sprintf("prepare task %s: %s%s", taskID, event.Status, suffix)
+		}
+		if taskID != "" {
+			return fmt.Sprintf("prepare task %s%s", taskID, suffix)
 		}
 		if event.Status != "" {
 			return fmt.Sprintf("prepare task: %s%s", event.Status, suffix)
 		}
 		return "prepare task" + suffix
+	case "plan":
+		if summary := summarizePlanEvent(event.Plan); summary != "" {
+			return "prepare plan: " + summary
+		}
+		return "prepare plan" + suffix
 	case "result":
 		return "prepare result: ready"
 	case "error":
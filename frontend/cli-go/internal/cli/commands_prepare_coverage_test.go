@@ -27,6 +27,11 @@ func TestFormatPrepareEventVariants(t *testing.T) {
 			event: client.PrepareJobEvent{Type: "task", TaskID: "task-1", Status: "running"},
 			want:  "prepare task task-1: running",
 		},
+		{
+			name:  "task with progress",
+			event: client.PrepareJobEvent{Type: "task", TaskID: "execute-2", Status: "succeeded", Progress: &client.TaskProgress{Completed: 3, Total: 10}},
+			want:  "prepare task execute-2 [3/10]: succeeded",
+		},
 		{
 			name:  "task with id only",
 			event: client.PrepareJobEvent{Type: "task", TaskID: "task-1", Message: "init"},
@@ -42,6 +47,38 @@ func TestFormatPrepareEventVariants(t *testing.T) {
 			event: client.PrepareJobEvent{Type: "task", TaskID: "execute-0", Status: "failed", Error: &client.ErrorResponse{Message: "psql execution failed", Details: "exit status 3"}},
 			want:  "prepare task execute-0: failed - psql execution failed: exit status 3",
 		},
+		{
+			name: "plan with builds",
+			event: client.PrepareJobEvent{Type: "plan", Message: "2 of 3 states cached", Plan: []client.PlanTask{
+				{TaskID: "plan", Type: "plan"},
+				{TaskID: "execute-0", Type: "state_execute", Cached: boolPtr(true)},
+				{TaskID: "execute-1", Type: "state_execute", Cached: boolPtr(true)},
+				{TaskID: "execute-2", Type: "state_execute", Cached: boolPtr(false)},
+				{TaskID: "prepare-instance", Type: "prepare_instance"},
+			}},
+			want: "prepare plan: 2/3 states cached, building execute-2",
+		},
+		{
+			name: "plan with many builds",
+			event: client.PrepareJobEvent{Type: "plan", Plan: []client.PlanTask{
+				{TaskID: "execute-0", Type: "state_execute"},
+				{TaskID: "execute-1", Type: "state_execute"},
+				{TaskID: "execute-2", Type: "state_execute"},
+				{TaskID: "execute-3", Type: "state_execute"},
+				{TaskID: "execute-4", Type: "state_execute"},
+			}},
+			want: "prepare plan: 0/5 states cached, building execute-0, execute-1, execute-2, 2 more",
+		},
+		{
+			name:  "plan fully cached",
+			event: client.PrepareJobEvent{Type: "plan", Plan: []client.PlanTask{{TaskID: "execute-0", Type: "state_execute", Cached: boolPtr(true)}}},
+			want:  "prepare plan: 1/1 states cached",
+		},
+		{
+			name:  "plan without tasks",
+			event: client.PrepareJobEvent{Type: "plan", Message: "0 of 0 states cached"},
+			want:  "prepare plan - 0 of 0 states cached",
+		},
 		{
 			name:  "result",
 			event: client.PrepareJobEvent{Type: "result"},
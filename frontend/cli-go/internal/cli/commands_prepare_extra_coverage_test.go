@@ -125,20 +125,6 @@ func TestWaitForPrepareInvalidJSONEvent(t *testing.T) {
 	}
 }
 
-func TestParseEventsContentRangeErrors(t *testing.T) {
-	cases := []string{
-		"",
-		"bytes 1-2/3",
-		"events 1/3",
-		"events a-b/3",
-	}
-	for _, in := range cases {
-		if _, err := parseEventsContentRange(in); err == nil {
-			t.Fatalf("expected parse error for %q", in)
-		}
-	}
-}
-
 func TestFormatPrepareEventAdditionalBranches(t *testing.T) {
 	if got := formatPrepareEvent(client.PrepareJobEvent{Type: "task"}); got != "prepare task" {
 		t.Fatalf("unexpected bare task event: %q", got)
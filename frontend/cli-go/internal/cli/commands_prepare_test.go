@@ -55,6 +55,44 @@ func TestRunPrepareRemoteSuccess(t *testing.T) {
 	}
 }
 
+func TestRunPrepareSendsCancelOnDisconnect(t *testing.T) {
+	var submitted client.PrepareJobRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/prepare-jobs":
+			if err := json.NewDecoder(r.Body).Decode(&submitted); err != nil {
+				t.Errorf("decode request: %v", err)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusAccepted)
+			io.WriteString(w, `{"job_id":"job-1","status_url":"/v1/prepare-jobs/job-1","events_url":"/v1/prepare-jobs/job-1/events"}`)
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/prepare-jobs/job-1/events":
+			writeEventStream(w, []client.PrepareJobEvent{statusEvent("succeeded")})
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/prepare-jobs/job-1":
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"job_id":"job-1","status":"succeeded","result":{"dsn":"dsn","instance_id":"inst","state_id":"state","image_id":"image","prepare_kind":"psql","prepare_args_normalized":"-c select 1"}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	_, err := RunPrepare(context.Background(), PrepareOptions{
+		Mode:               "remote",
+		Endpoint:           server.URL,
+		ImageID:            "image",
+		PsqlArgs:           []string{"-c", "select 1"},
+		Timeout:            time.Second,
+		CancelOnDisconnect: true,
+	})
+	if err != nil {
+		t.Fatalf("RunPrepare: %v", err)
+	}
+	if !submitted.CancelOnDisconnect {
+		t.Fatalf("expected cancel_on_disconnect in the submitted request: %+v", submitted)
+	}
+}
+
 func TestRunPrepareRemoteRequiresEndpoint(t *testing.T) {
 	_, err := RunPrepare(context.Background(), PrepareOptions{Mode: "remote"})
 	if err == nil || !strings.Contains(err.Error(), "explicit endpoint") {
@@ -109,6 +147,72 @@ func TestRunPrepareLocalAutoUsesEngineState(t *testing.T) {
 	}
 }
 
+func TestRunPrepareWarnsAboutIgnoredEngineOverrides(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v1/health":
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"ok":true,"instanceId":"inst"}`)
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/prepare-jobs":
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusAccepted)
+			io.WriteString(w, `{"job_id":"job-1","status_url":"/v1/prepare-jobs/job-1","events_url":"/v1/prepare-jobs/job-1/events"}`)
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/prepare-jobs/job-1/events":
+			writeEventStream(w, []client.PrepareJobEvent{statusEvent("succeeded")})
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/prepare-jobs/job-1":
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"job_id":"job-1","status":"succeeded","result":{"dsn":"dsn","instance_id":"inst","state_id":"state","image_id":"image","prepare_kind":"psql","prepare_args_normalized":"-c select 1"}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	stateDir := t.TempDir()
+	if err := daemon.WriteEngineState(filepath.Join(stateDir, "engine.json"), daemon.EngineState{
+		Endpoint:   server.URL,
+		AuthToken:  "token",
+		InstanceID: "inst",
+	}); err != nil {
+		t.Fatalf("WriteEngineState: %v", err)
+	}
+
+	oldStderr := os.Stderr
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("pipe: %v", err)
+	}
+	os.Stderr = w
+	t.Cleanup(func() {
+		_ = r.Close()
+		_ = w.Close()
+		os.Stderr = oldStderr
+	})
+
+	_, err = RunPrepare(context.Background(), PrepareOptions{
+		Mode:                "local",
+		StateDir:            stateDir,
+		ImageID:             "image",
+		PsqlArgs:            []string{"-c", "select 1"},
+		Timeout:             time.Second,
+		ContainerRuntime:    "podman",
+		StateStore:          "/other",
+		EngineOverrideFlags: []string{"--container-runtime", "--state-store"},
+	})
+	if err != nil {
+		t.Fatalf("RunPrepare: %v", err)
+	}
+
+	_ = w.Close()
+	data, readErr := io.ReadAll(r)
+	if readErr != nil {
+		t.Fatalf("read stderr: %v", readErr)
+	}
+	if !strings.Contains(string(data), "warning: engine already running; ignoring --container-runtime, --state-store") {
+		t.Fatalf("expected a warning naming the ignored flags, got %q", string(data))
+	}
+}
+
 func TestRunPrepareFailedWithErrorDetails(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		switch {
@@ -277,6 +381,26 @@ func TestRunPrepareCreateJobError(t *testing.T) {
 	}
 }
 
+func TestRunPrepareEngineBusy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTooManyRequests)
+		io.WriteString(w, `{"code":"resource_exhausted","message":"too many queued jobs","details":"1000 jobs queued or running, limit is 1000"}`)
+	}))
+	defer server.Close()
+
+	_, err := RunPrepare(context.Background(), PrepareOptions{
+		Mode:     "remote",
+		Endpoint: server.URL,
+		ImageID:  "image",
+		PsqlArgs: []string{"-c", "select 1"},
+		Timeout:  time.Second,
+	})
+	if err == nil || !strings.HasPrefix(err.Error(), "engine busy, retry shortly: too many queued jobs") {
+		t.Fatalf("expected engine busy error, got %v", err)
+	}
+}
+
 func TestRunPrepareLocalAutostartDisabled(t *testing.T) {
 	_, err := RunPrepare(context.Background(), PrepareOptions{
 		Mode:      "local",
@@ -617,6 +741,54 @@ func TestWaitForPrepareReconnectRangePartial(t *testing.T) {
 	}
 }
 
+func TestWaitForPrepareReconnectAfterSeq(t *testing.T) {
+	var eventCalls int32
+	var statusCalls int32
+	running := statusEvent("running")
+	running.Seq = 7
+	succeeded := statusEvent("succeeded")
+	succeeded.Seq = 9
+	firstChunk := encodeEvents([]client.PrepareJobEvent{running})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/prepare-jobs/job-1/events":
+			call := atomic.AddInt32(&eventCalls, 1)
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			if call == 1 {
+				w.Header().Set("Content-Length", strconv.Itoa(len(firstChunk)+100))
+				io.WriteString(w, firstChunk)
+				return
+			}
+			if got := r.URL.Query().Get("after_seq"); got != "7" {
+				t.Errorf("expected after_seq=7 on reconnect, got %q", got)
+			}
+			// Earlier events were removed, so the engine starts after seq 7
+			// at what is now offset 0.
+			writeEventStream(w, []client.PrepareJobEvent{succeeded})
+		case "/v1/prepare-jobs/job-1":
+			call := atomic.AddInt32(&statusCalls, 1)
+			w.Header().Set("Content-Type", "application/json")
+			if call == 1 {
+				io.WriteString(w, `{"job_id":"job-1","status":"running"}`)
+				return
+			}
+			io.WriteString(w, `{"job_id":"job-1","status":"succeeded","result":{"dsn":"dsn","instance_id":"inst","state_id":"state","image_id":"image","prepare_kind":"psql","prepare_args_normalized":"-c select 1"}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	cli := client.New(server.URL, client.Options{Timeout: time.Second})
+	status, err := waitForPrepare(context.Background(), cli, "job-1", server.URL+"/v1/prepare-jobs/job-1/events", io.Discard, false)
+	if err != nil {
+		t.Fatalf("waitForPrepare: %v", err)
+	}
+	if status.Status != "succeeded" {
+		t.Fatalf("expected succeeded status, got %q", status.Status)
+	}
+}
+
 func TestWaitForPrepareSpinnerNoNewlines(t *testing.T) {
 	var statusCalls int32
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -684,6 +856,73 @@ func TestPrepareProgressSpinnerOnDuplicateOnly(t *testing.T) {
 	}
 }
 
+func TestPrepareProgressPingTicksSpinner(t *testing.T) {
+	var buf bytes.Buffer
+	progress := newPrepareProgress(&buf, false)
+	progress.Update(client.PrepareJobEvent{Type: "ping", TaskID: "execute-0"})
+	if buf.Len() != 0 {
+		t.Fatalf("expected ping before any line to print nothing, got %q", buf.String())
+	}
+
+	progress.Update(client.PrepareJobEvent{Type: "task", Status: "running", TaskID: "execute-0"})
+	progress.Update(client.PrepareJobEvent{Type: "ping", TaskID: "execute-0"})
+	line := lastProgressLine(buf.String())
+	if !hasSpinnerSuffix(line) || !strings.Contains(line, "prepare task execute-0: running") {
+		t.Fatalf("expected ping to tick the spinner on the task line, got %q", line)
+	}
+	if strings.Contains(buf.String(), "ping") {
+		t.Fatalf("expected ping not to be printed, got %q", buf.String())
+	}
+
+	var verbose bytes.Buffer
+	progress = newPrepareProgress(&verbose, true)
+	progress.Update(client.PrepareJobEvent{Type: "ping", TaskID: "execute-0"})
+	if verbose.Len() != 0 {
+		t.Fatalf("expected verbose output to skip ping, got %q", verbose.String())
+	}
+}
+
+func TestPrepareProgressPlainWritesLinesWithoutSpinner(t *testing.T) {
+	var buf bytes.Buffer
+	progress := newPrepareProgress(&buf, false)
+	progress.plain = true
+	event := client.PrepareJobEvent{
+		Type:   "task",
+		Status: "running",
+		TaskID: "execute-0",
+	}
+
+	progress.Update(event)
+	progress.Update(event)
+	progress.Update(client.PrepareJobEvent{Type: "log", Message: "docker: pull"})
+	progress.Close()
+
+	out := buf.String()
+	if strings.ContainsAny(out, "\r\b") {
+		t.Fatalf("expected no in-place rewrites, got %q", out)
+	}
+	if strings.Count(out, "\n") != 2 || hasSpinnerSuffix(lastProgressLine(out)) {
+		t.Fatalf("expected one line per distinct event, got %q", out)
+	}
+}
+
+func TestPrepareFailureErrorKeepsEngineError(t *testing.T) {
+	err := prepareFailureError(client.PrepareJobStatus{
+		JobID: "job-1",
+		Error: &client.ErrorResponse{Code: "execution_failed", Message: "psql failed", Details: "exit 3"},
+	}, nil)
+	var failed *PrepareJobFailedError
+	if !errors.As(err, &failed) || failed.JobID != "job-1" || failed.Err.Code != "execution_failed" {
+		t.Fatalf("expected PrepareJobFailedError, got %#v", err)
+	}
+	if err.Error() != "psql failed: exit 3" {
+		t.Fatalf("unexpected message: %q", err.Error())
+	}
+	if err := prepareFailureError(client.PrepareJobStatus{}, nil); err.Error() != "prepare job failed" {
+		t.Fatalf("unexpected message without engine error: %q", err.Error())
+	}
+}
+
 func TestPrepareProgressWriteSpinnerMinimal(t *testing.T) {
 	var buf bytes.Buffer
 	progress := newPrepareProgress(&buf, false)
@@ -0,0 +1,230 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sqlrs/cli/internal/client"
+	"github.com/sqlrs/cli/internal/daemon"
+)
+
+type StatesOptions struct {
+	ProfileName     string
+	Mode            string
+	AuthToken       string
+	Endpoint        string
+	Autostart       bool
+	DaemonPath      string
+	RunDir          string
+	StateDir        string
+	EngineRunDir    string
+	EngineStatePath string
+	EngineStoreDir  string
+	WSLVHDXPath     string
+	WSLMountUnit    string
+	WSLMountFSType  string
+	WSLDistro       string
+	Timeout         time.Duration
+	IdleTimeout     time.Duration
+	StartupTimeout  time.Duration
+	Verbose         bool
+
+	DryRun    bool
+	OlderThan string
+	StateID   string
+	FilePath  string
+	Name      string
+}
+
+type StatesExportResult struct {
+	StateID string `json:"state_id"`
+	Path    string `json:"path"`
+}
+
+func RunStatesPrune(ctx context.Context, opts StatesOptions) (client.PruneStatesResult, error) {
+	cliClient, err := statesClient(ctx, opts)
+	if err != nil {
+		return client.PruneStatesResult{}, err
+	}
+	return cliClient.PruneStates(ctx, client.PruneStatesOptions{
+		DryRun:    opts.DryRun,
+		OlderThan: opts.OlderThan,
+	})
+}
+
+func PrintStatesPrune(w io.Writer, result client.PruneStatesResult) {
+	action := "deleted"
+	if result.DryRun {
+		action = "would delete"
+	}
+	for _, stateID := range result.StateIDs {
+		fmt.Fprintf(w, "state %s %s\n", strings.ToLower(stateID), action)
+	}
+	fmt.Fprintf(w, "states: %d\n", len(result.StateIDs))
+	fmt.Fprintf(w, "olderThan: %s\n", result.OlderThan)
+	fmt.Fprintf(w, "reclaimedBytes: %d\n", result.ReclaimedBytes)
+}
+
+func RunStatesExport(ctx context.Context, opts StatesOptions) (StatesExportResult, error) {
+	result := StatesExportResult{StateID: strings.TrimSpace(opts.StateID), Path: opts.FilePath}
+	if result.Path == "" {
+		result.Path = result.StateID + ".tar"
+	}
+	cliClient, err := statesClient(ctx, opts)
+	if err != nil {
+		return result, err
+	}
+	file, err := os.Create(result.Path)
+	if err != nil {
+		return result, err
+	}
+	err = cliClient.ExportState(ctx, result.StateID, file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(result.Path)
+		return result, err
+	}
+	return result, nil
+}
+
+func RunStatesImport(ctx context.Context, opts StatesOptions) (client.StateImportResult, error) {
+	cliClient, err := statesClient(ctx, opts)
+	if err != nil {
+		return client.StateImportResult{}, err
+	}
+	file, err := os.Open(opts.FilePath)
+	if err != nil {
+		return client.StateImportResult{}, err
+	}
+	defer file.Close()
+	return cliClient.ImportState(ctx, file)
+}
+
+// RunStatesShow fetches a state with its ancestor chain. found is false when
+// the engine does not know the state.
+func RunStatesShow(ctx context.Context, opts StatesOptions) (client.StateDetail, bool, error) {
+	cliClient, err := statesClient(ctx, opts)
+	if err != nil {
+		return client.StateDetail{}, false, err
+	}
+	return cliClient.GetState(ctx, opts.StateID)
+}
+
+// PrintStatesShow renders the lineage root first, ending with the requested
+// state, so two states that missed the cache can be compared ancestor by
+// ancestor.
+func PrintStatesShow(w io.Writer, detail client.StateDetail) {
+	chain := make([]client.StateEntry, 0, len(detail.Ancestors)+1)
+	for i := len(detail.Ancestors) - 1; i >= 0; i-- {
+		chain = append(chain, detail.Ancestors[i])
+	}
+	chain = append(chain, detail.StateEntry)
+
+	var ancestorsHasNext []bool
+	for depth, entry := range chain {
+		prefix := ""
+		if depth > 0 {
+			prefix = compactTreePrefix(ancestorsHasNext, true) + " "
+			ancestorsHasNext = compactTreeNextAncestors(ancestorsHasNext, true)
+		}
+		size := optionalInt64(entry.SizeBytes)
+		if size == "" {
+			size = "-"
+		}
+		fmt.Fprintf(w, "%s%s  kind=%s  size=%s  args=%s\n",
+			prefix,
+			strings.ToLower(entry.StateID),
+			entry.PrepareKind,
+			size,
+			strings.TrimSpace(entry.PrepareArgs),
+		)
+	}
+	fmt.Fprintf(w, "image: %s\n", detail.ImageID)
+	fmt.Fprintf(w, "created: %s\n", detail.CreatedAt)
+	fmt.Fprintf(w, "refcount: %d\n", detail.RefCount)
+}
+
+// RunStatesTag attaches opts.Name to opts.StateID, re-pointing the name when
+// it already tags another state.
+func RunStatesTag(ctx context.Context, opts StatesOptions) (client.NameEntry, error) {
+	cliClient, err := statesClient(ctx, opts)
+	if err != nil {
+		return client.NameEntry{}, err
+	}
+	return cliClient.TagState(ctx, opts.StateID, opts.Name)
+}
+
+func PrintStatesTag(w io.Writer, entry client.NameEntry) {
+	fmt.Fprintf(w, "state %s tagged %s\n", strings.ToLower(entry.StateID), entry.Name)
+}
+
+func PrintStatesExport(w io.Writer, result StatesExportResult) {
+	fmt.Fprintf(w, "state %s exported to %s\n", strings.ToLower(result.StateID), result.Path)
+}
+
+func PrintStatesImport(w io.Writer, result client.StateImportResult) {
+	action := "imported"
+	if !result.Created {
+		action = "already present"
+	}
+	fmt.Fprintf(w, "state %s %s\n", strings.ToLower(result.StateID), action)
+}
+
+func statesClient(ctx context.Context, opts StatesOptions) (*client.Client, error) {
+	mode := strings.ToLower(strings.TrimSpace(opts.Mode))
+	endpoint := strings.TrimSpace(opts.Endpoint)
+	authToken := strings.TrimSpace(opts.AuthToken)
+
+	if mode == "local" {
+		authToken = ""
+		if endpoint == "" {
+			endpoint = "auto"
+		}
+		if endpoint == "auto" {
+			if opts.Verbose {
+				fmt.Fprintln(os.Stderr, "checking local engine state")
+			}
+			resolved, err := daemon.ConnectOrStart(ctx, daemon.ConnectOptions{
+				Endpoint:        endpoint,
+				Autostart:       opts.Autostart,
+				DaemonPath:      opts.DaemonPath,
+				RunDir:          opts.RunDir,
+				StateDir:        opts.StateDir,
+				EngineRunDir:    opts.EngineRunDir,
+				EngineStatePath: opts.EngineStatePath,
+				EngineStoreDir:  opts.EngineStoreDir,
+				WSLVHDXPath:     opts.WSLVHDXPath,
+				WSLMountUnit:    opts.WSLMountUnit,
+				WSLMountFSType:  opts.WSLMountFSType,
+				WSLDistro:       opts.WSLDistro,
+				IdleTimeout:     opts.IdleTimeout,
+				StartupTimeout:  opts.StartupTimeout,
+				ClientTimeout:   opts.Timeout,
+				Verbose:         opts.Verbose,
+			})
+			if err != nil {
+				return nil, err
+			}
+			endpoint = resolved.Endpoint
+			authToken = resolved.AuthToken
+			if opts.Verbose {
+				fmt.Fprintf(os.Stderr, "engine ready at %s\n", endpoint)
+			}
+		}
+	} else if mode == "remote" {
+		if endpoint == "" || endpoint == "auto" {
+			return nil, fmt.Errorf("remote mode requires explicit endpoint")
+		}
+		if opts.Verbose {
+			fmt.Fprintf(os.Stderr, "using remote endpoint %s\n", endpoint)
+		}
+	}
+
+	return client.New(endpoint, client.Options{Timeout: opts.Timeout, AuthToken: authToken}), nil
+}
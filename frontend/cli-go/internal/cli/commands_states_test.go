@@ -0,0 +1,150 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sqlrs/cli/internal/client"
+)
+
+func TestRunStatesPrune(t *testing.T) {
+	var gotQuery string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/states/gc" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		gotQuery = r.URL.RawQuery
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"dry_run":false,"outcome":"deleted","older_than":"1h0m0s","state_ids":["s1","s2"],"reclaimed_bytes":3}`)
+	}))
+	defer server.Close()
+
+	result, err := RunStatesPrune(context.Background(), StatesOptions{
+		Mode:      "remote",
+		Endpoint:  server.URL,
+		Timeout:   time.Second,
+		OlderThan: "1h",
+	})
+	if err != nil {
+		t.Fatalf("RunStatesPrune: %v", err)
+	}
+	if gotQuery != "older_than=1h" {
+		t.Fatalf("unexpected query: %q", gotQuery)
+	}
+	if result.Outcome != "deleted" || len(result.StateIDs) != 2 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+}
+
+func TestRunStatesPruneRemoteRequiresEndpoint(t *testing.T) {
+	if _, err := RunStatesPrune(context.Background(), StatesOptions{Mode: "remote"}); err == nil {
+		t.Fatalf("expected endpoint error")
+	}
+}
+
+func TestRunStatesExportImport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/states/state-1/export":
+			io.WriteString(w, "archive")
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/states/import":
+			body, _ := io.ReadAll(r.Body)
+			if string(body) != "archive" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"state_id":"state-1","created":false}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "state.tar")
+	opts := StatesOptions{Mode: "remote", Endpoint: server.URL, Timeout: time.Second, StateID: "state-1", FilePath: path}
+	exported, err := RunStatesExport(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("RunStatesExport: %v", err)
+	}
+	if exported.Path != path {
+		t.Fatalf("unexpected export result: %+v", exported)
+	}
+	imported, err := RunStatesImport(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("RunStatesImport: %v", err)
+	}
+	if imported.StateID != "state-1" || imported.Created {
+		t.Fatalf("unexpected import result: %+v", imported)
+	}
+
+	opts.StateID = "missing"
+	if _, err := RunStatesExport(context.Background(), opts); err == nil {
+		t.Fatalf("expected export error")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected failed export to remove %s, got %v", path, err)
+	}
+}
+
+func TestPrintStatesExportImport(t *testing.T) {
+	var out bytes.Buffer
+	PrintStatesExport(&out, StatesExportResult{StateID: "S1", Path: "s1.tar"})
+	PrintStatesImport(&out, client.StateImportResult{StateID: "S1", Created: true})
+	PrintStatesImport(&out, client.StateImportResult{StateID: "S1"})
+	want := "state s1 exported to s1.tar\nstate s1 imported\nstate s1 already present\n"
+	if out.String() != want {
+		t.Fatalf("unexpected output:\n%s", out.String())
+	}
+}
+
+func TestPrintStatesShow(t *testing.T) {
+	root := "ROOT"
+	size := int64(42)
+	var out bytes.Buffer
+	PrintStatesShow(&out, client.StateDetail{
+		StateEntry: client.StateEntry{StateID: "LEAF", ParentStateID: &root, ImageID: "img", PrepareKind: "lb", PrepareArgs: "update", CreatedAt: "2026-01-01T00:00:00Z", SizeBytes: &size, RefCount: 2},
+		Ancestors: []client.StateEntry{
+			{StateID: "MID", ParentStateID: &root, PrepareKind: "psql", PrepareArgs: "-f seed.sql"},
+			{StateID: "ROOT", PrepareKind: "psql", PrepareArgs: "-f schema.sql", SizeBytes: &size},
+		},
+	})
+	want := "root  kind=psql  size=42  args=-f schema.sql\n" +
+		"` mid  kind=psql  size=-  args=-f seed.sql\n" +
+		" ` leaf  kind=lb  size=42  args=update\n" +
+		"image: img\ncreated: 2026-01-01T00:00:00Z\nrefcount: 2\n"
+	if out.String() != want {
+		t.Fatalf("unexpected output:\n%s", out.String())
+	}
+}
+
+func TestPrintStatesPrune(t *testing.T) {
+	var out bytes.Buffer
+	PrintStatesPrune(&out, client.PruneStatesResult{
+		Outcome:        "deleted",
+		OlderThan:      "10m0s",
+		StateIDs:       []string{"S1"},
+		ReclaimedBytes: 42,
+	})
+	want := "state s1 deleted\nstates: 1\nolderThan: 10m0s\nreclaimedBytes: 42\n"
+	if out.String() != want {
+		t.Fatalf("unexpected output:\n%s", out.String())
+	}
+}
+
+func TestPrintStatesUsage(t *testing.T) {
+	var out bytes.Buffer
+	PrintStatesUsage(&out)
+	if !strings.Contains(out.String(), "--older-than") {
+		t.Fatalf("unexpected usage: %q", out.String())
+	}
+}
@@ -38,6 +38,8 @@ type StatusOptions struct {
 	StartupTimeout  time.Duration
 	Verbose         bool
 	CacheDetails    bool
+	// ClientVersion is compared with the engine version to warn about skew.
+	ClientVersion string
 }
 
 type StatusResult struct {
@@ -115,52 +117,9 @@ var execCommandContextFn = exec.CommandContext
 
 func RunStatus(ctx context.Context, opts StatusOptions) (StatusResult, error) {
 	mode := strings.ToLower(strings.TrimSpace(opts.Mode))
-	endpoint := strings.TrimSpace(opts.Endpoint)
-	authToken := strings.TrimSpace(opts.AuthToken)
-
-	if mode == "local" {
-		authToken = ""
-		if endpoint == "" {
-			endpoint = "auto"
-		}
-		if endpoint == "auto" {
-			if opts.Verbose {
-				fmt.Fprintln(os.Stderr, "checking local engine state")
-			}
-			resolved, err := daemon.ConnectOrStart(ctx, daemon.ConnectOptions{
-				Endpoint:        endpoint,
-				Autostart:       opts.Autostart,
-				DaemonPath:      opts.DaemonPath,
-				RunDir:          opts.RunDir,
-				StateDir:        opts.StateDir,
-				EngineRunDir:    opts.EngineRunDir,
-				EngineStatePath: opts.EngineStatePath,
-				EngineStoreDir:  opts.EngineStoreDir,
-				WSLVHDXPath:     opts.WSLVHDXPath,
-				WSLMountUnit:    opts.WSLMountUnit,
-				WSLMountFSType:  opts.WSLMountFSType,
-				WSLDistro:       opts.WSLDistro,
-				IdleTimeout:     opts.IdleTimeout,
-				StartupTimeout:  opts.StartupTimeout,
-				ClientTimeout:   opts.Timeout,
-				Verbose:         opts.Verbose,
-			})
-			if err != nil {
-				return StatusResult{Endpoint: endpoint, Profile: opts.ProfileName, Mode: mode}, err
-			}
-			endpoint = resolved.Endpoint
-			authToken = resolved.AuthToken
-			if opts.Verbose {
-				fmt.Fprintf(os.Stderr, "engine ready at %s\n", endpoint)
-			}
-		}
-	} else if mode == "remote" {
-		if endpoint == "" || endpoint == "auto" {
-			return StatusResult{Endpoint: endpoint, Profile: opts.ProfileName, Mode: mode}, fmt.Errorf("remote mode requires explicit endpoint")
-		}
-		if opts.Verbose {
-			fmt.Fprintf(os.Stderr, "using remote endpoint %s\n", endpoint)
-		}
+	endpoint, authToken, err := resolveStatusEndpoint(ctx, opts, mode)
+	if err != nil {
+		return StatusResult{Endpoint: endpoint, Profile: opts.ProfileName, Mode: mode}, err
 	}
 
 	cliClient := client.New(endpoint, client.Options{Timeout: opts.Timeout, AuthToken: authToken})
@@ -194,6 +153,9 @@ func RunStatus(ctx context.Context, opts StatusOptions) (StatusResult, error) {
 	}
 
 	warnings := append([]string(nil), deps.Warnings...)
+	if warning := versionSkewWarning(opts.ClientVersion, health.Version); warning != "" {
+		warnings = append(warnings, warning)
+	}
 	var cacheSummary *StatusCacheSummary
 	var cacheDetails *StatusCacheDetails
 	cacheStatus, cacheErr := cliClient.GetCacheStatus(ctx)
@@ -238,6 +200,59 @@ func RunStatus(ctx context.Context, opts StatusOptions) (StatusResult, error) {
 	}, nil
 }
 
+// resolveStatusEndpoint returns the endpoint and token to talk to. In local
+// auto mode it connects to (or starts) the local engine.
+func resolveStatusEndpoint(ctx context.Context, opts StatusOptions, mode string) (string, string, error) {
+	endpoint := strings.TrimSpace(opts.Endpoint)
+	authToken := strings.TrimSpace(opts.AuthToken)
+
+	if mode == "local" {
+		authToken = ""
+		if endpoint == "" {
+			endpoint = "auto"
+		}
+		if endpoint == "auto" {
+			if opts.Verbose {
+				fmt.Fprintln(os.Stderr, "checking local engine state")
+			}
+			resolved, err := daemon.ConnectOrStart(ctx, daemon.ConnectOptions{
+				Endpoint:        endpoint,
+				Autostart:       opts.Autostart,
+				DaemonPath:      opts.DaemonPath,
+				RunDir:          opts.RunDir,
+				StateDir:        opts.StateDir,
+				EngineRunDir:    opts.EngineRunDir,
+				EngineStatePath: opts.EngineStatePath,
+				EngineStoreDir:  opts.EngineStoreDir,
+				WSLVHDXPath:     opts.WSLVHDXPath,
+				WSLMountUnit:    opts.WSLMountUnit,
+				WSLMountFSType:  opts.WSLMountFSType,
+				WSLDistro:       opts.WSLDistro,
+				IdleTimeout:     opts.IdleTimeout,
+				StartupTimeout:  opts.StartupTimeout,
+				ClientTimeout:   opts.Timeout,
+				Verbose:         opts.Verbose,
+			})
+			if err != nil {
+				return endpoint, "", err
+			}
+			endpoint = resolved.Endpoint
+			authToken = resolved.AuthToken
+			if opts.Verbose {
+				fmt.Fprintf(os.Stderr, "engine ready at %s\n", endpoint)
+			}
+		}
+	} else if mode == "remote" {
+		if endpoint == "" || endpoint == "auto" {
+			return endpoint, "", fmt.Errorf("remote mode requires explicit endpoint")
+		}
+		if opts.Verbose {
+			fmt.Fprintf(os.Stderr, "using remote endpoint %s\n", endpoint)
+		}
+	}
+	return endpoint, authToken, nil
+}
+
 func PrintStatus(w io.Writer, result StatusResult) {
 	status := "unavailable"
 	if result.OK {
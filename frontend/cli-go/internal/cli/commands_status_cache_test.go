@@ -267,3 +267,32 @@ func TestFormatCacheSummaryWarningNil(t *testing.T) {
 		t.Fatalf("unexpected warning: %q", got)
 	}
 }
+
+func TestRunStatusRemoteWarnsOnVersionSkew(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/health":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"ok":true,"version":"0.2.5","instanceId":"inst","pid":1}`))
+		case "/v1/cache/status":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"usage_bytes":0,"state_count":0}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	result, err := RunStatus(context.Background(), StatusOptions{
+		Mode:          "remote",
+		Endpoint:      server.URL,
+		Timeout:       time.Second,
+		ClientVersion: "0.3.0",
+	})
+	if err != nil {
+		t.Fatalf("RunStatus: %v", err)
+	}
+	if len(result.Warnings) != 1 || !strings.Contains(result.Warnings[0], "engine version 0.2.5 does not match CLI version 0.3.0") {
+		t.Fatalf("expected skew warning, got %+v", result.Warnings)
+	}
+}
@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/sqlrs/cli/internal/client"
+)
+
+// usageJobStatuses fixes the order of the job counts in human output;
+// statuses the CLI does not know follow in name order.
+var usageJobStatuses = []string{"queued", "running", "succeeded", "failed"}
+
+// RunUsage fetches the engine's usage summary.
+func RunUsage(ctx context.Context, opts PrepareOptions) (client.UsageSummary, error) {
+	cliClient, err := prepareClient(ctx, opts)
+	if err != nil {
+		return client.UsageSummary{}, err
+	}
+	return cliClient.GetUsage(ctx)
+}
+
+func PrintUsageSummary(w io.Writer, summary client.UsageSummary) {
+	fmt.Fprintf(w, "states: %d\n", summary.StateCount)
+	if summary.UnsizedStateCount > 0 {
+		fmt.Fprintf(w, "states.sizeBytes: %d (%d without a size)\n", summary.StateSizeBytes, summary.UnsizedStateCount)
+	} else {
+		fmt.Fprintf(w, "states.sizeBytes: %d\n", summary.StateSizeBytes)
+	}
+	known := map[string]bool{}
+	for _, status := range usageJobStatuses {
+		known[status] = true
+		fmt.Fprintf(w, "jobs.%s: %d\n", status, summary.Jobs[status])
+	}
+	extra := make([]string, 0)
+	for status := range summary.Jobs {
+		if !known[status] {
+			extra = append(extra, status)
+		}
+	}
+	sort.Strings(extra)
+	for _, status := range extra {
+		fmt.Fprintf(w, "jobs.%s: %d\n", status, summary.Jobs[status])
+	}
+	if summary.Cache.HitRatio != nil {
+		fmt.Fprintf(w, "cache.hitRatio: %.1f%% (%d hits, %d misses)\n", *summary.Cache.HitRatio*100, summary.Cache.Hits, summary.Cache.Misses)
+	} else {
+		fmt.Fprintln(w, "cache.hitRatio: n/a")
+	}
+	duration := summary.PrepareDuration
+	if duration.Count == 0 {
+		fmt.Fprintln(w, "prepare.duration: n/a")
+	} else {
+		fmt.Fprintf(w, "prepare.duration: mean %s, p50 %s, p95 %s (%d jobs)\n",
+			formatUsageMillis(duration.MeanMs), formatUsageMillis(duration.P50Ms), formatUsageMillis(duration.P95Ms), duration.Count)
+	}
+	if summary.GeneratedAt != "" {
+		fmt.Fprintf(w, "generatedAt: %s\n", summary.GeneratedAt)
+	}
+}
+
+func formatUsageMillis(ms int64) string {
+	duration := time.Duration(ms) * time.Millisecond
+	if duration >= time.Second {
+		return duration.Round(100 * time.Millisecond).String()
+	}
+	return duration.String()
+}
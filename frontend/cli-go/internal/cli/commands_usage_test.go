@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sqlrs/cli/internal/client"
+)
+
+func TestRunUsageFetchesSummary(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/v1/usage" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"generated_at":"2026-03-09T12:00:00Z","state_count":3,"state_size_bytes":150,"unsized_state_count":1,"jobs":{"queued":1,"running":0,"succeeded":4,"failed":1},"cache":{"hits":3,"misses":1,"hit_ratio":0.75},"prepare_duration":{"count":4,"mean_ms":4000,"p50_ms":2000,"p95_ms":10000}}`)
+	}))
+	defer server.Close()
+
+	summary, err := RunUsage(context.Background(), PrepareOptions{Mode: "remote", Endpoint: server.URL, Timeout: time.Second})
+	if err != nil {
+		t.Fatalf("RunUsage: %v", err)
+	}
+	if summary.StateSizeBytes != 150 || summary.Jobs["succeeded"] != 4 || summary.Cache.HitRatio == nil || *summary.Cache.HitRatio != 0.75 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+
+	var out bytes.Buffer
+	PrintUsageSummary(&out, summary)
+	want := "states: 3\n" +
+		"states.sizeBytes: 150 (1 without a size)\n" +
+		"jobs.queued: 1\n" +
+		"jobs.running: 0\n" +
+		"jobs.succeeded: 4\n" +
+		"jobs.failed: 1\n" +
+		"cache.hitRatio: 75.0% (3 hits, 1 misses)\n" +
+		"prepare.duration: mean 4s, p50 2s, p95 10s (4 jobs)\n" +
+		"generatedAt: 2026-03-09T12:00:00Z\n"
+	if out.String() != want {
+		t.Fatalf("unexpected output:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestPrintUsageSummaryWithoutHistory(t *testing.T) {
+	var out bytes.Buffer
+	PrintUsageSummary(&out, client.UsageSummary{Jobs: map[string]int{"cancelling": 2}})
+	want := "states: 0\n" +
+		"states.sizeBytes: 0\n" +
+		"jobs.queued: 0\n" +
+		"jobs.running: 0\n" +
+		"jobs.succeeded: 0\n" +
+		"jobs.failed: 0\n" +
+		"jobs.cancelling: 2\n" +
+		"cache.hitRatio: n/a\n" +
+		"prepare.duration: n/a\n"
+	if out.String() != want {
+		t.Fatalf("unexpected output:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
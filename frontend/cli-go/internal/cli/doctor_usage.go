@@ -0,0 +1,17 @@
+package cli
+
+import "io"
+
+func PrintDoctorUsage(w io.Writer) {
+	io.WriteString(w, "Usage:\n")
+	io.WriteString(w, "  sqlrs doctor [--json]\n\n")
+	io.WriteString(w, "Flags:\n")
+	io.WriteString(w, "  --json      Print the checks as JSON (same as --output json)\n")
+	io.WriteString(w, "  -h, --help  Show help\n\n")
+	io.WriteString(w, "Notes:\n")
+	io.WriteString(w, "  Checks engine reachability, the container runtime, the snapshot backend,\n")
+	io.WriteString(w, "  state store writability, free disk space against cache.capacity.reserveBytes,\n")
+	io.WriteString(w, "  and on WSL the state store mount unit. Each check prints pass, warn or fail\n")
+	io.WriteString(w, "  with a hint; the command exits nonzero when any check fails.\n")
+	io.WriteString(w, "  In local mode doctor starts the engine if needed, like status.\n")
+}
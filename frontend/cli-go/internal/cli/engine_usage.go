@@ -0,0 +1,15 @@
+package cli
+
+import "io"
+
+func PrintEngineUsage(w io.Writer) {
+	io.WriteString(w, "Usage:\n")
+	io.WriteString(w, "  sqlrs engine status [--json]\n\n")
+	io.WriteString(w, "Flags:\n")
+	io.WriteString(w, "  --json      Print the status as JSON (same as --output json)\n")
+	io.WriteString(w, "  -h, --help  Show help\n\n")
+	io.WriteString(w, "Notes:\n")
+	io.WriteString(w, "  status never starts the local engine; it reads engine.json and reports\n")
+	io.WriteString(w, "  \"not running\" when the engine is down.\n")
+	io.WriteString(w, "  The status request itself counts as activity and restarts the idle timer.\n")
+}
@@ -0,0 +1,84 @@
+package cli
+
+// Engine error codes (ErrorResponse.Code). They mirror the engine's
+// prepare.ErrorCode* constants; the CLI branches on these, never on messages.
+const (
+	ErrorCodeInvalidArgument   = "invalid_argument"
+	ErrorCodeConflict          = "conflict"
+	ErrorCodePermissionDenied  = "permission_denied"
+	ErrorCodeUnavailable       = "unavailable"
+	ErrorCodeResourceExhausted = "resource_exhausted"
+	ErrorCodeCancelled         = "cancelled"
+	ErrorCodeDeadlineExceeded  = "deadline_exceeded"
+	ErrorCodeInternal          = "internal_error"
+
+	ErrorCodeRuntimeUnavailable   = "runtime_unavailable"
+	ErrorCodeImageNotFound        = "image_not_found"
+	ErrorCodeImageResolveFailed   = "image_resolve_failed"
+	ErrorCodeImageBuildFailed     = "image_build_failed"
+	ErrorCodeBaseInitFailed       = "base_init_failed"
+	ErrorCodeContainerStartFailed = "container_start_failed"
+
+	ErrorCodeMigrationFailed = "migration_failed"
+
+	ErrorCodeStoreNotReady  = "store_not_ready"
+	ErrorCodeSnapshotFailed = "snapshot_failed"
+	ErrorCodeStateCorrupt   = "state_corrupt"
+
+	ErrorCodeCacheFull                   = "cache_full_unreclaimable"
+	ErrorCodeCacheLimitTooSmall          = "cache_limit_too_small"
+	ErrorCodeCacheEnforcementUnavailable = "cache_enforcement_unavailable"
+)
+
+// Exit codes for failures reported by the engine, grouped by who can fix
+// them. Codes without an entry in errorCodeInfos exit with 1.
+const (
+	ExitCodeRejected    = 2 // the request itself was refused
+	ExitCodeMigration   = 3 // the script or migration failed
+	ExitCodeEnvironment = 4 // the container runtime or state store failed
+	ExitCodeCapacity    = 5 // the engine is out of space or busy
+)
+
+type errorCodeInfo struct {
+	exit int
+	hint string
+}
+
+var errorCodeInfos = map[string]errorCodeInfo{
+	ErrorCodeInvalidArgument:  {exit: ExitCodeRejected},
+	ErrorCodeConflict:         {exit: ExitCodeRejected},
+	ErrorCodePermissionDenied: {exit: ExitCodeRejected, hint: "the engine's images.allowed/images.denied policy forbids this request"},
+
+	ErrorCodeMigrationFailed:  {exit: ExitCodeMigration, hint: "fix the script or migration and prepare again; `sqlrs jobs logs <job-id>` shows the full output"},
+	ErrorCodeDeadlineExceeded: {exit: ExitCodeMigration, hint: "raise orchestrator.tasks.timeout or the statement timeout if the step is expected to take longer"},
+
+	ErrorCodeRuntimeUnavailable:   {exit: ExitCodeEnvironment, hint: "start Docker (or Podman) and retry; `sqlrs doctor` checks the engine environment"},
+	ErrorCodeImageNotFound:        {exit: ExitCodeEnvironment, hint: "check the image name, tag and platform; private registries need `docker login` on the engine host"},
+	ErrorCodeImageResolveFailed:   {exit: ExitCodeEnvironment, hint: "check that the engine host can reach the image registry"},
+	ErrorCodeImageBuildFailed:     {exit: ExitCodeEnvironment, hint: "`sqlrs jobs logs <job-id>` shows the docker build output"},
+	ErrorCodeBaseInitFailed:       {exit: ExitCodeEnvironment, hint: "check that the image is a PostgreSQL image; `sqlrs doctor` checks the state store"},
+	ErrorCodeContainerStartFailed: {exit: ExitCodeEnvironment, hint: "`sqlrs jobs logs <job-id>` shows the container output"},
+	ErrorCodeStoreNotReady:        {exit: ExitCodeEnvironment, hint: "`sqlrs doctor` checks the state store"},
+	ErrorCodeSnapshotFailed:       {exit: ExitCodeEnvironment, hint: "`sqlrs doctor` checks the snapshot backend"},
+	ErrorCodeStateCorrupt:         {exit: ExitCodeEnvironment, hint: "prepare again to rebuild the state; remove it with `sqlrs rm` if the error persists"},
+
+	ErrorCodeResourceExhausted:           {exit: ExitCodeCapacity, hint: "free disk space or remove unused states with `sqlrs states prune`"},
+	ErrorCodeUnavailable:                 {exit: ExitCodeCapacity, hint: "the engine is shutting down; retry shortly"},
+	ErrorCodeCacheFull:                   {exit: ExitCodeCapacity, hint: "remove unused states with `sqlrs states prune` or raise cache.capacity.maxBytes"},
+	ErrorCodeCacheLimitTooSmall:          {exit: ExitCodeCapacity, hint: "raise cache.capacity.maxBytes or free disk space on the state store"},
+	ErrorCodeCacheEnforcementUnavailable: {exit: ExitCodeCapacity, hint: "`sqlrs doctor` checks the state store"},
+}
+
+// ErrorCodeExit returns the process exit code for an engine error code.
+func ErrorCodeExit(code string) int {
+	if info, ok := errorCodeInfos[code]; ok {
+		return info.exit
+	}
+	return 1
+}
+
+// ErrorCodeHint returns a next step for an engine error code, or "" when
+// there is nothing more useful to say than the engine message.
+func ErrorCodeHint(code string) string {
+	return errorCodeInfos[code].hint
+}
@@ -0,0 +1,42 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sqlrs/cli/internal/client"
+)
+
+func TestErrorCodeExit(t *testing.T) {
+	cases := map[string]int{
+		ErrorCodeInvalidArgument:    ExitCodeRejected,
+		ErrorCodeMigrationFailed:    ExitCodeMigration,
+		ErrorCodeImageNotFound:      ExitCodeEnvironment,
+		ErrorCodeRuntimeUnavailable: ExitCodeEnvironment,
+		ErrorCodeCacheFull:          ExitCodeCapacity,
+		ErrorCodeInternal:           1,
+		"":                          1,
+		"something_new":             1,
+	}
+	for code, want := range cases {
+		if got := ErrorCodeExit(code); got != want {
+			t.Fatalf("ErrorCodeExit(%q) = %d, want %d", code, got, want)
+		}
+	}
+}
+
+func TestPrepareJobFailedErrorHint(t *testing.T) {
+	err := &PrepareJobFailedError{JobID: "job-1", Err: &client.ErrorResponse{Code: ErrorCodeMigrationFailed, Message: "psql execution failed", Details: "ERROR: syntax error"}}
+	lines := strings.Split(err.Error(), "\n")
+	if len(lines) != 2 || lines[0] != "psql execution failed: ERROR: syntax error" {
+		t.Fatalf("unexpected message: %q", err.Error())
+	}
+	if !strings.HasPrefix(lines[1], "hint: ") || !strings.Contains(lines[1], "sqlrs jobs logs job-1") {
+		t.Fatalf("expected hint with the job id, got %q", lines[1])
+	}
+
+	err = &PrepareJobFailedError{JobID: "job-1", Err: &client.ErrorResponse{Code: ErrorCodeInternal, Message: "boom"}}
+	if err.Error() != "boom" {
+		t.Fatalf("expected no hint for internal errors, got %q", err.Error())
+	}
+}
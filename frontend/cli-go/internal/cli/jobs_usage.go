@@ -0,0 +1,22 @@
+package cli
+
+import "io"
+
+func PrintJobsUsage(w io.Writer) {
+	io.WriteString(w, "Usage:\n")
+	io.WriteString(w, "  sqlrs jobs logs <job-id> [--follow] [--since-offset <n>]\n")
+	io.WriteString(w, "  sqlrs jobs show <job-id>\n")
+	io.WriteString(w, "  sqlrs jobs wait <job-id>\n")
+	io.WriteString(w, "  sqlrs jobs list [--label <key=value>]...\n")
+	io.WriteString(w, "  sqlrs jobs delete --label <key=value> [--label <key=value>]...\n\n")
+	io.WriteString(w, "Flags:\n")
+	io.WriteString(w, "  --follow            Attach to a running job and stream events until it finishes\n")
+	io.WriteString(w, "  --since-offset <n>  Skip events before offset n (offsets are printed with each event)\n")
+	io.WriteString(w, "  --label <key=value> Match jobs carrying this label (repeatable; all must match)\n")
+	io.WriteString(w, "  -h, --help          Show help\n\n")
+	io.WriteString(w, "Notes:\n")
+	io.WriteString(w, "  logs without --follow requires a finished job.\n")
+	io.WriteString(w, "  show prints the job summary and a table of tasks with their durations.\n")
+	io.WriteString(w, "  wait blocks until a job submitted with --no-watch finishes; it exits non-zero if the job failed.\n")
+	io.WriteString(w, "  delete removes every job matching the labels; at least one label is required.\n")
+}
@@ -6,13 +6,17 @@ func PrintPlanUsage(w io.Writer) {
 	io.WriteString(w, "Usage:\n")
 	io.WriteString(w, "  sqlrs plan [--provenance-path <path>] [--ref <git-ref>] [--ref-mode worktree|blob] [--ref-keep-worktree] <ref>\n")
 	io.WriteString(w, "  sqlrs plan:psql [--provenance-path <path>] [--ref <git-ref>] [--ref-mode worktree|blob] [--ref-keep-worktree] [--image <image-id>] [--] [psql-args...]\n")
-	io.WriteString(w, "  sqlrs plan:lb [--provenance-path <path>] [--ref <git-ref>] [--ref-mode worktree|blob] [--ref-keep-worktree] -- [liquibase-args...]\n\n")
+	io.WriteString(w, "  sqlrs plan:lb [--provenance-path <path>] [--ref <git-ref>] [--ref-mode worktree|blob] [--ref-keep-worktree] [--changelog-from-stdin[=<format>]] [--env-file <path>] [--env KEY=VALUE] -- [liquibase-args...]\n\n")
 	io.WriteString(w, "Options:\n")
 	io.WriteString(w, "  --provenance-path <path>  Write a JSON provenance artifact for the bound plan stage\n")
 	io.WriteString(w, "  --ref <git-ref>      Read plan inputs from a selected Git revision\n")
 	io.WriteString(w, "  --ref-mode <mode>    Ref mode: worktree (default) or blob\n")
 	io.WriteString(w, "  --ref-keep-worktree  Keep detached worktree after exit (worktree mode only)\n")
 	io.WriteString(w, "  --image <image-id>  Override base image id\n")
+	io.WriteString(w, "  --changelog-from-stdin[=<format>]  Read the Liquibase changelog from stdin (xml, yaml, json or sql; default xml)\n")
+	io.WriteString(w, "  --env-file <path>   Add Liquibase environment from a dotenv file (repeatable)\n")
+	io.WriteString(w, "  --env KEY=VALUE     Set a Liquibase environment variable; overrides --env-file (repeatable)\n")
+	io.WriteString(w, "                      There is no -e short form: -e stays psql's --echo-queries\n")
 	io.WriteString(w, "  -h, --help          Show help\n\n")
 	io.WriteString(w, "Notes:\n")
 	io.WriteString(w, "  Alias mode resolves <ref> from the current working directory.\n")
@@ -0,0 +1,107 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/sqlrs/cli/internal/client"
+)
+
+var (
+	// prepareStreamGrace is how long the events stream may stay silent before
+	// the first byte; after that the wait switches to polling.
+	prepareStreamGrace = 10 * time.Second
+	preparePollMin     = 200 * time.Millisecond
+	preparePollMax     = 5 * time.Second
+)
+
+// pollPrepareJob waits for a prepare job by polling its status with
+// exponential backoff. It is used when the events stream is disabled or
+// delivers nothing, and reports the status and task transitions it observes
+// as synthesized events.
+func pollPrepareJob(ctx context.Context, cliClient *client.Client, jobID string, tracker *prepareProgress, interrupts <-chan os.Signal) (client.PrepareJobStatus, error) {
+	observer := &prepareStatusObserver{tasks: map[string]string{}}
+	delay := preparePollMin
+	for {
+		status, found, err := cliClient.GetPrepareJob(ctx, jobID)
+		if err != nil {
+			return client.PrepareJobStatus{}, err
+		}
+		if !found {
+			return client.PrepareJobStatus{}, fmt.Errorf("prepare job not found: %s", jobID)
+		}
+		events := observer.observe(status)
+		for _, event := range events {
+			tracker.Update(event)
+		}
+		switch status.Status {
+		case "succeeded":
+			return status, nil
+		case "failed":
+			return client.PrepareJobStatus{}, prepareFailureError(status, tracker)
+		}
+		if len(events) > 0 {
+			delay = preparePollMin
+		} else {
+			delay = min(delay*2, preparePollMax)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return client.PrepareJobStatus{}, ctx.Err()
+		case <-interrupts:
+			timer.Stop()
+			final, err := handlePrepareControlAction(ctx, cliClient, jobID, tracker, interrupts)
+			if err != nil {
+				return client.PrepareJobStatus{}, err
+			}
+			if final != nil {
+				return *final, nil
+			}
+			delay = preparePollMin
+		case <-timer.C:
+		}
+	}
+}
+
+// prepareStatusObserver turns successive job status snapshots into the
+// status and task events the stream would have delivered.
+type prepareStatusObserver struct {
+	status string
+	tasks  map[string]string
+}
+
+func (o *prepareStatusObserver) observe(status client.PrepareJobStatus) []client.PrepareJobEvent {
+	var events []client.PrepareJobEvent
+	for _, task := range status.Tasks {
+		taskStatus := polledTaskStatus(task, status.Status)
+		if taskStatus == "" || o.tasks[task.TaskID] == taskStatus {
+			continue
+		}
+		o.tasks[task.TaskID] = taskStatus
+		events = append(events, client.PrepareJobEvent{Type: "task", TaskID: task.TaskID, Status: taskStatus})
+	}
+	if status.Status != "" && status.Status != o.status {
+		o.status = status.Status
+		events = append(events, client.PrepareJobEvent{Type: "status", Status: status.Status})
+	}
+	return events
+}
+
+// polledTaskStatus derives a task status from its timestamps. A finished task
+// of a failed job is left to the job error.
+func polledTaskStatus(task client.PlanTask, jobStatus string) string {
+	switch {
+	case task.FinishedAt != nil && jobStatus == "failed":
+		return ""
+	case task.FinishedAt != nil:
+		return "succeeded"
+	case task.StartedAt != nil:
+		return "running"
+	}
+	return ""
+}
@@ -0,0 +1,186 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sqlrs/cli/internal/client"
+)
+
+func withFastPolling(t *testing.T, grace time.Duration) {
+	t.Helper()
+	prevGrace, prevMin, prevMax := prepareStreamGrace, preparePollMin, preparePollMax
+	prepareStreamGrace = grace
+	preparePollMin = time.Millisecond
+	preparePollMax = 5 * time.Millisecond
+	t.Cleanup(func() {
+		prepareStreamGrace, preparePollMin, preparePollMax = prevGrace, prevMin, prevMax
+	})
+}
+
+// pollingJobServer serves a job that starts one task on the second poll and
+// succeeds on the third. Event requests are counted and never answered.
+func pollingJobServer(t *testing.T, eventCalls *int32) *httptest.Server {
+	t.Helper()
+	var polls int32
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/prepare-jobs/job-1/events":
+			atomic.AddInt32(eventCalls, 1)
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		case "/v1/prepare-jobs/job-1":
+			w.Header().Set("Content-Type", "application/json")
+			switch atomic.AddInt32(&polls, 1) {
+			case 1:
+				io.WriteString(w, `{"job_id":"job-1","status":"queued","tasks":[{"task_id":"execute-0","type":"state_execute"}]}`)
+			case 2:
+				io.WriteString(w, `{"job_id":"job-1","status":"running","tasks":[{"task_id":"execute-0","type":"state_execute","started_at":"2026-01-01T00:00:00Z"}]}`)
+			default:
+				io.WriteString(w, `{"job_id":"job-1","status":"succeeded","tasks":[{"task_id":"execute-0","type":"state_execute","started_at":"2026-01-01T00:00:00Z","finished_at":"2026-01-01T00:00:01Z"}],"result":{"dsn":"dsn","instance_id":"inst","state_id":"state","image_id":"image","prepare_kind":"psql","prepare_args_normalized":"-c select 1"}}`)
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestWaitForPrepareNoStreamPolls(t *testing.T) {
+	withFastPolling(t, time.Hour)
+	var eventCalls int32
+	server := pollingJobServer(t, &eventCalls)
+	defer server.Close()
+
+	var out bytes.Buffer
+	cli := client.New(server.URL, client.Options{Timeout: time.Second})
+	status, err := waitForPrepareWithOptions(context.Background(), cli, "job-1", "/v1/prepare-jobs/job-1/events", &out, true, waitPrepareOptions{noStream: true})
+	if err != nil {
+		t.Fatalf("waitForPrepareWithOptions: %v", err)
+	}
+	if status.Status != "succeeded" || status.Result == nil || status.Result.DSN != "dsn" {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+	if atomic.LoadInt32(&eventCalls) != 0 {
+		t.Fatalf("expected no events requests, got %d", eventCalls)
+	}
+	want := []string{
+		"prepare status: queued",
+		"prepare task execute-0: running",
+		"prepare status: running",
+		"prepare task execute-0: succeeded",
+		"prepare status: succeeded",
+	}
+	if got := strings.Split(strings.TrimSpace(out.String()), "\n"); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("unexpected progress:\n%s", out.String())
+	}
+}
+
+func TestWaitForPrepareFallsBackToPollingWhenStreamIsSilent(t *testing.T) {
+	withFastPolling(t, 20*time.Millisecond)
+	var eventCalls int32
+	server := pollingJobServer(t, &eventCalls)
+	defer server.Close()
+
+	var out bytes.Buffer
+	cli := client.New(server.URL, client.Options{Timeout: time.Second})
+	status, err := waitForPrepareWithOptions(context.Background(), cli, "job-1", "/v1/prepare-jobs/job-1/events", &out, true, waitPrepareOptions{})
+	if err != nil {
+		t.Fatalf("waitForPrepareWithOptions: %v", err)
+	}
+	if status.Status != "succeeded" {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+	if atomic.LoadInt32(&eventCalls) != 1 {
+		t.Fatalf("expected one events request, got %d", eventCalls)
+	}
+	if !strings.Contains(out.String(), "polling prepare job status") {
+		t.Fatalf("expected fallback notice, got:\n%s", out.String())
+	}
+}
+
+func TestWaitForPrepareKeepsStreamingAfterFirstEvent(t *testing.T) {
+	withFastPolling(t, 20*time.Millisecond)
+	var statusCalls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/prepare-jobs/job-1/events":
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			io.WriteString(w, encodeEvents([]client.PrepareJobEvent{statusEvent("running")}))
+			w.(http.Flusher).Flush()
+			time.Sleep(60 * time.Millisecond)
+			io.WriteString(w, encodeEvents([]client.PrepareJobEvent{statusEvent("succeeded")}))
+		case "/v1/prepare-jobs/job-1":
+			w.Header().Set("Content-Type", "application/json")
+			if atomic.AddInt32(&statusCalls, 1) == 1 {
+				io.WriteString(w, `{"job_id":"job-1","status":"running"}`)
+				return
+			}
+			io.WriteString(w, `{"job_id":"job-1","status":"succeeded","result":{"dsn":"dsn","instance_id":"inst","state_id":"state","image_id":"image","prepare_kind":"psql","prepare_args_normalized":"-c select 1"}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	var out bytes.Buffer
+	cli := client.New(server.URL, client.Options{Timeout: time.Second})
+	status, err := waitForPrepareWithOptions(context.Background(), cli, "job-1", "/v1/prepare-jobs/job-1/events", &out, true, waitPrepareOptions{})
+	if err != nil {
+		t.Fatalf("waitForPrepareWithOptions: %v", err)
+	}
+	if status.Status != "succeeded" {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+	if strings.Contains(out.String(), "polling") {
+		t.Fatalf("expected no fallback once events arrived, got:\n%s", out.String())
+	}
+	if got := atomic.LoadInt32(&statusCalls); got != 2 {
+		t.Fatalf("expected status fetched per status event, got %d", got)
+	}
+}
+
+func TestPollPrepareJobFailed(t *testing.T) {
+	withFastPolling(t, time.Hour)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"job_id":"job-1","status":"failed","tasks":[{"task_id":"execute-0","type":"state_execute","started_at":"2026-01-01T00:00:00Z","finished_at":"2026-01-01T00:00:01Z"}],"error":{"code":"internal_error","message":"psql failed"}}`)
+	}))
+	defer server.Close()
+
+	var out bytes.Buffer
+	cli := client.New(server.URL, client.Options{Timeout: time.Second})
+	tracker := newPrepareProgress(&out, true)
+	_, err := pollPrepareJob(context.Background(), cli, "job-1", tracker, nil)
+	if err == nil || !strings.Contains(err.Error(), "psql failed") {
+		t.Fatalf("expected job failure, got %v", err)
+	}
+	if strings.Contains(out.String(), "execute-0: succeeded") {
+		t.Fatalf("expected finished task of failed job not reported as succeeded, got:\n%s", out.String())
+	}
+}
+
+func TestPollPrepareJobCanceled(t *testing.T) {
+	withFastPolling(t, time.Hour)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"job_id":"job-1","status":"running"}`)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	cli := client.New(server.URL, client.Options{Timeout: time.Second})
+	_, err := pollPrepareJob(ctx, cli, "job-1", newPrepareProgress(io.Discard, false), nil)
+	if err == nil || ctx.Err() == nil {
+		t.Fatalf("expected context error, got %v", err)
+	}
+}
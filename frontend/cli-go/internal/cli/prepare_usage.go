@@ -5,8 +5,9 @@ import "io"
 func PrintPrepareUsage(w io.Writer) {
 	io.WriteString(w, "Usage:\n")
 	io.WriteString(w, "  sqlrs prepare [--provenance-path <path>] [--ref <git-ref>] [--ref-mode worktree|blob] [--ref-keep-worktree] [--watch|--no-watch] <ref>\n")
+	io.WriteString(w, "  sqlrs prepare -f <spec> [--provenance-path <path>] [--watch|--no-watch] [--image <image-id>] [--env-file <path>] [--env KEY=VALUE]\n")
 	io.WriteString(w, "  sqlrs prepare:psql [--provenance-path <path>] [--ref <git-ref>] [--ref-mode worktree|blob] [--ref-keep-worktree] [--watch|--no-watch] [--image <image-id>] [--] [psql-args...]\n")
-	io.WriteString(w, "  sqlrs prepare:lb [--provenance-path <path>] [--ref <git-ref>] [--ref-mode worktree|blob] [--ref-keep-worktree] [--watch|--no-watch] [--image <image-id>] [--] [liquibase-args...]\n\n")
+	io.WriteString(w, "  sqlrs prepare:lb [--provenance-path <path>] [--ref <git-ref>] [--ref-mode worktree|blob] [--ref-keep-worktree] [--watch|--no-watch] [--image <image-id>] [--changelog-from-stdin[=<format>]] [--env-file <path>] [--env KEY=VALUE] [--] [liquibase-args...]\n\n")
 	io.WriteString(w, "Options:\n")
 	io.WriteString(w, "  --provenance-path <path>  Write a JSON provenance artifact for the bound prepare stage\n")
 	io.WriteString(w, "  --ref <git-ref>      Read prepare inputs from a selected Git revision\n")
@@ -14,11 +15,25 @@ func PrintPrepareUsage(w io.Writer) {
 	io.WriteString(w, "  --ref-keep-worktree  Keep detached worktree after exit (worktree mode only)\n")
 	io.WriteString(w, "  --watch             Watch progress until terminal status (default)\n")
 	io.WriteString(w, "  --no-watch          Submit job and exit immediately with job references\n")
+	io.WriteString(w, "  --wait[=<bool>]     Same as --watch; --wait=false is the same as --no-watch\n")
+	io.WriteString(w, "  --cancel-on-disconnect  Cancel the job if this CLI stops watching it (watch mode only)\n")
+	io.WriteString(w, "  -f, --file <spec>   Read prepare requests from a YAML or JSON spec file\n")
 	io.WriteString(w, "  --image <image-id>  Override base image id\n")
+	io.WriteString(w, "  --changelog-from-stdin[=<format>]  Read the Liquibase changelog from stdin (xml, yaml, json or sql; default xml)\n")
+	io.WriteString(w, "  --env-file <path>   Add Liquibase environment from a dotenv file (repeatable)\n")
+	io.WriteString(w, "  --env KEY=VALUE     Set a Liquibase environment variable; overrides --env-file (repeatable)\n")
+	io.WriteString(w, "                      There is no -e short form: -e stays psql's --echo-queries\n")
 	io.WriteString(w, "  -h, --help          Show help\n\n")
+	io.WriteString(w, "Engine autostart options (apply only when this command starts the local engine):\n")
+	io.WriteString(w, "  --container-runtime <auto|docker|podman|nerdctl>  Override container.runtime\n")
+	io.WriteString(w, "  --snapshot-backend <auto|overlay|btrfs|copy>      Override snapshot.backend\n")
+	io.WriteString(w, "  --state-store <path>                              Use this state store directory\n")
+	io.WriteString(w, "  --idle-timeout <duration>                         Override orchestrator.idleTimeout\n\n")
 	io.WriteString(w, "Notes:\n")
 	io.WriteString(w, "  Alias mode resolves <ref> from the current working directory.\n")
 	io.WriteString(w, "  Paths inside the alias file resolve relative to that alias file.\n")
+	io.WriteString(w, "  Paths inside a spec file resolve relative to that file; --image, --env-file and --env override its values.\n")
+	io.WriteString(w, "  A spec with several requests submits them all without watching and prints every job reference.\n")
 	io.WriteString(w, "  Use -- to pass flags that would otherwise conflict with sqlrs options.\n")
 	io.WriteString(w, "  In composite form, prepare ... run may mix raw and alias stages.\n")
 	io.WriteString(w, "  Relative provenance paths resolve from the command invocation directory.\n")
@@ -93,6 +93,7 @@ func PrintUsage(w io.Writer) {
 	fmt.Fprintln(w, "  init     Initialize a workspace")
 	fmt.Fprintln(w, "  ls       List names, instances, or states")
 	fmt.Fprintln(w, "  rm       Remove an instance or state")
+	fmt.Fprintln(w, "  states   Prune unreferenced states")
 	fmt.Fprintln(w, "  diff     Compare file sets between two paths (plan/prepare)")
 	fmt.Fprintln(w, "  prepare  Prepare a database state from a repo alias")
 	fmt.Fprintln(w, "  run      Run a repo alias against an instance")
@@ -104,7 +105,11 @@ func PrintUsage(w io.Writer) {
 	fmt.Fprintln(w, "  prepare:psql  Prepare a database state with psql")
 	fmt.Fprintln(w, "  prepare:lb    Prepare a database state with Liquibase")
 	fmt.Fprintln(w, "  watch    Attach to a running prepare job")
+	fmt.Fprintln(w, "  jobs     Show prepare job event logs")
 	fmt.Fprintln(w, "  status   Check service health")
+	fmt.Fprintln(w, "  engine   Show local engine uptime, jobs, and idle timer")
+	fmt.Fprintln(w, "  usage    Summarize state disk use, jobs, and cache hit rate")
+	fmt.Fprintln(w, "  doctor   Diagnose the engine and local environment")
 	fmt.Fprintln(w, "  config   Manage server config")
 	fmt.Fprintln(w, "  user     Manage remote user profiles")
 	fmt.Fprintln(w, "  org      Manage remote organizations")
@@ -292,7 +297,7 @@ func looksLikeRunAliasStage(args []string) bool {
 
 func isCommandToken(value string) bool {
 	switch value {
-	case "alias", "auth", "cache", "discover", "init", "ls", "diff", "rm", "plan", "prepare", "run", "watch", "status", "config", "user", "org":
+	case "alias", "auth", "cache", "discover", "init", "ls", "diff", "rm", "plan", "prepare", "run", "watch", "jobs", "states", "status", "engine", "usage", "doctor", "config", "user", "org":
 		return true
 	}
 	if strings.HasPrefix(value, "prepare:") {
@@ -0,0 +1,22 @@
+package cli
+
+import "io"
+
+func PrintStatesUsage(w io.Writer) {
+	io.WriteString(w, "Usage:\n")
+	io.WriteString(w, "  sqlrs states show <state_id>\n")
+	io.WriteString(w, "  sqlrs states tag <state_id> <name>\n")
+	io.WriteString(w, "  sqlrs states prune [--dry-run] [--older-than <duration>]\n")
+	io.WriteString(w, "  sqlrs states export <state_id> [--file <path>]\n")
+	io.WriteString(w, "  sqlrs states import <path>\n\n")
+	io.WriteString(w, "Flags:\n")
+	io.WriteString(w, "  --dry-run                Show states that would be removed\n")
+	io.WriteString(w, "  --older-than <duration>  Minimum state age (default: cache.capacity.minStateAge)\n")
+	io.WriteString(w, "  --file <path>            Archive to write (default: <state_id>.tar)\n")
+	io.WriteString(w, "  -h, --help               Show help\n\n")
+	io.WriteString(w, "Notes:\n")
+	io.WriteString(w, "  show prints the state's ancestor chain, root first, with sizes and prepare args.\n")
+	io.WriteString(w, "  tag names a state; tagging an existing name again moves it to the new state.\n")
+	io.WriteString(w, "  prune only removes states without instances or surviving descendants.\n")
+	io.WriteString(w, "  export/import move a state between engines; import needs the parent state.\n")
+}
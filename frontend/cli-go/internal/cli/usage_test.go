@@ -30,6 +30,7 @@ func TestPrintCommandUsage(t *testing.T) {
 		{name: "prepare", fn: func(b *bytes.Buffer) { PrintPrepareUsage(b) }},
 		{name: "run", fn: func(b *bytes.Buffer) { PrintRunUsage(b) }},
 		{name: "watch", fn: func(b *bytes.Buffer) { PrintWatchUsage(b) }},
+		{name: "usage", fn: func(b *bytes.Buffer) { PrintUsageUsage(b) }},
 		{name: "config", fn: func(b *bytes.Buffer) { PrintConfigUsage(b) }},
 		{name: "rm", fn: func(b *bytes.Buffer) { PrintRmUsage(b) }},
 		{name: "status", fn: func(b *bytes.Buffer) { PrintStatusUsage(b) }},
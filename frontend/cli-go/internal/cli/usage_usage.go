@@ -0,0 +1,15 @@
+package cli
+
+import "io"
+
+func PrintUsageUsage(w io.Writer) {
+	io.WriteString(w, "Usage:\n")
+	io.WriteString(w, "  sqlrs usage [--json]\n\n")
+	io.WriteString(w, "Flags:\n")
+	io.WriteString(w, "  --json      Print the summary as JSON (same as --output json)\n")
+	io.WriteString(w, "  -h, --help  Show help\n\n")
+	io.WriteString(w, "Notes:\n")
+	io.WriteString(w, "  usage summarizes state disk use, prepare jobs by status, the cache hit\n")
+	io.WriteString(w, "  ratio and prepare durations (mean, p50, p95) of succeeded jobs.\n")
+	io.WriteString(w, "  The engine reuses a computed summary for 10 seconds.\n")
+}
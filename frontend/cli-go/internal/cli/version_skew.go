@@ -0,0 +1,45 @@
+package cli
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// versionSkewWarning reports a CLI/engine pair whose major or minor versions
+// differ. Patch releases are compatible, and development builds ("dev", or
+// anything that is not a version number) are never flagged.
+func versionSkewWarning(clientVersion, engineVersion string) string {
+	clientMajor, clientMinor, ok := parseMajorMinor(clientVersion)
+	if !ok {
+		return ""
+	}
+	engineMajor, engineMinor, ok := parseMajorMinor(engineVersion)
+	if !ok {
+		return ""
+	}
+	if clientMajor == engineMajor && clientMinor == engineMinor {
+		return ""
+	}
+	return fmt.Sprintf("engine version %s does not match CLI version %s; restart the engine to pick up the matching build", strings.TrimSpace(engineVersion), strings.TrimSpace(clientVersion))
+}
+
+func parseMajorMinor(version string) (int, int, bool) {
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	if cut := strings.IndexAny(version, "-+"); cut >= 0 {
+		version = version[:cut]
+	}
+	parts := strings.Split(version, ".")
+	if len(parts) < 2 {
+		return 0, 0, false
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, false
+	}
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, 0, false
+	}
+	return major, minor, true
+}
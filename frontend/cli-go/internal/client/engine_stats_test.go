@@ -0,0 +1,102 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGetEngineStats(t *testing.T) {
+	var gotAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/v1/engine/stats" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		gotAuth = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"version":"v1","instanceId":"inst","pid":42,"startedAt":"2026-03-09T12:00:00Z","uptimeSeconds":90.5,"inflightRequests":1,"idleSeconds":12,"idleTimeoutSeconds":30,"runningJobs":2,"containerRuntime":"docker","snapshotBackend":"copy"}`)
+	}))
+	defer server.Close()
+
+	cli := New(server.URL, Options{Timeout: time.Second, AuthToken: "secret"})
+	stats, err := cli.GetEngineStats(context.Background())
+	if err != nil {
+		t.Fatalf("GetEngineStats: %v", err)
+	}
+	if gotAuth != "Bearer secret" {
+		t.Fatalf("expected auth header, got %q", gotAuth)
+	}
+	if stats.PID != 42 || stats.UptimeSeconds != 90.5 || stats.InflightRequests != 1 || stats.IdleSeconds != 12 ||
+		stats.IdleTimeoutSeconds != 30 || stats.RunningJobs != 2 || stats.ContainerRuntime != "docker" || stats.SnapshotBackend != "copy" {
+		t.Fatalf("unexpected engine stats: %+v", stats)
+	}
+}
+
+func TestGetEngineStatsError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	cli := New(server.URL, Options{Timeout: time.Second})
+	if _, err := cli.GetEngineStats(context.Background()); err == nil {
+		t.Fatalf("expected engine stats error")
+	}
+}
+
+func TestGetVersion(t *testing.T) {
+	var gotAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/v1/version" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		gotAuth = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"version":"0.4.0","apiVersion":"v1","revision":"abc123","modified":true,"goVersion":"go1.22.0","containerRuntime":"podman","snapshotBackend":"btrfs"}`)
+	}))
+	defer server.Close()
+
+	cli := New(server.URL, Options{Timeout: time.Second, AuthToken: "secret"})
+	version, err := cli.GetVersion(context.Background())
+	if err != nil {
+		t.Fatalf("GetVersion: %v", err)
+	}
+	if gotAuth != "" {
+		t.Fatalf("expected no auth header, got %q", gotAuth)
+	}
+	if version.Version != "0.4.0" || version.APIVersion != "v1" || version.Revision != "abc123" || !version.Modified ||
+		version.ContainerRuntime != "podman" || version.SnapshotBackend != "btrfs" {
+		t.Fatalf("unexpected version: %+v", version)
+	}
+}
+
+func TestGetDiagnostics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/v1/diagnostics" || r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"ok":false,"checks":[{"name":"stateStore","status":"fail","message":"not writable","hint":"fix permissions"}]}`)
+	}))
+	defer server.Close()
+
+	cli := New(server.URL, Options{Timeout: time.Second, AuthToken: "secret"})
+	diag, found, err := cli.GetDiagnostics(context.Background())
+	if err != nil || !found {
+		t.Fatalf("GetDiagnostics: found=%v err=%v", found, err)
+	}
+	if diag.Ok || len(diag.Checks) != 1 || diag.Checks[0].Name != "stateStore" || diag.Checks[0].Status != "fail" || diag.Checks[0].Hint != "fix permissions" {
+		t.Fatalf("unexpected diagnostics: %+v", diag)
+	}
+
+	cli = New(server.URL, Options{Timeout: time.Second})
+	if _, found, err := cli.GetDiagnostics(context.Background()); err != nil || found {
+		t.Fatalf("expected not found for an engine without diagnostics, got found=%v err=%v", found, err)
+	}
+}
@@ -0,0 +1,265 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/sqlrs/cli/internal/util"
+)
+
+// PrepareEventHandler receives each prepare job event with its zero-based
+// offset in the job's event log. Returning true stops the stream.
+type PrepareEventHandler func(index int, event PrepareJobEvent) (bool, error)
+
+// EventsStream reads a prepare job events stream and remembers the next
+// offset so a dropped connection can resume with a Range request. Engines that
+// number events also get ?after_seq, which stays correct if older events were
+// removed in between.
+type EventsStream struct {
+	client      *Client
+	eventsURL   string
+	resumeIndex int
+	// lastSeq is the seq of the last delivered event, 0 if none carried one.
+	lastSeq int64
+	// firstByteTimeout, when set, ends a pass with Stalled if the stream has
+	// not delivered a single byte within that time.
+	firstByteTimeout time.Duration
+	// received is set once any connection delivered data.
+	received bool
+}
+
+// NewEventsStream returns a stream over eventsURL (absolute, or relative to
+// the engine base URL) that starts at sinceOffset. A positive firstByteTimeout
+// ends a pass with Stalled when no data arrives within that time.
+func (c *Client) NewEventsStream(eventsURL string, sinceOffset int, firstByteTimeout time.Duration) *EventsStream {
+	return &EventsStream{client: c, eventsURL: eventsURL, resumeIndex: sinceOffset, firstByteTimeout: firstByteTimeout}
+}
+
+// EventsPass describes how a single connection to the events stream ended.
+type EventsPass struct {
+	// ConnectFailed is set when the request itself failed.
+	ConnectFailed bool
+	// Done is set when the handler asked to stop.
+	Done bool
+	// ReadErr is set when the body broke mid-stream; reconnecting is safe.
+	ReadErr error
+	// Exhausted is set when a length-delimited body was read to the end.
+	Exhausted bool
+	// Stalled is set when no data arrived within the first byte timeout,
+	// which usually means a proxy is buffering the response.
+	Stalled bool
+}
+
+const (
+	firstByteWaiting int32 = iota
+	firstByteReceived
+	firstByteStalled
+)
+
+// Pass opens one connection, skips events already delivered (servers may
+// ignore Range) and feeds the rest to handle.
+func (s *EventsStream) Pass(ctx context.Context, handle PrepareEventHandler) (EventsPass, error) {
+	if s.firstByteTimeout <= 0 || s.received {
+		return s.read(ctx, handle, nil)
+	}
+	passCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var state int32
+	timer := time.AfterFunc(s.firstByteTimeout, func() {
+		if atomic.CompareAndSwapInt32(&state, firstByteWaiting, firstByteStalled) {
+			cancel()
+		}
+	})
+	defer timer.Stop()
+	pass, err := s.read(passCtx, handle, func() bool {
+		return atomic.CompareAndSwapInt32(&state, firstByteWaiting, firstByteReceived)
+	})
+	if atomic.LoadInt32(&state) == firstByteStalled && ctx.Err() == nil {
+		return EventsPass{Stalled: true}, nil
+	}
+	return pass, err
+}
+
+// read runs one connection. gotData, when set, is called on the first data
+// and returns false if the pass was already given up as stalled.
+func (s *EventsStream) read(ctx context.Context, handle PrepareEventHandler, gotData func() bool) (EventsPass, error) {
+	rangeHeader := ""
+	if s.resumeIndex > 0 {
+		rangeHeader = fmt.Sprintf("events=%d-", s.resumeIndex)
+	}
+	eventsURL := s.eventsURL
+	if s.lastSeq > 0 {
+		eventsURL = eventsURLAfterSeq(eventsURL, s.lastSeq)
+	}
+	resp, err := s.client.StreamPrepareEvents(ctx, eventsURL, rangeHeader)
+	if err != nil {
+		return EventsPass{ConnectFailed: true}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		return EventsPass{}, fmt.Errorf("events stream returned status %d", resp.StatusCode)
+	}
+
+	startIndex := 0
+	if resp.StatusCode == http.StatusPartialContent {
+		rangeStart, err := parseEventsContentRange(resp.Header.Get("Content-Range"))
+		if err != nil {
+			return EventsPass{}, err
+		}
+		startIndex = rangeStart
+		if rangeStart > s.resumeIndex {
+			s.resumeIndex = rangeStart
+		}
+	}
+
+	counter := &countingReader{reader: resp.Body}
+	reader := util.NewNDJSONReader(counter)
+	currentIndex := startIndex
+	for {
+		line, err := reader.Next()
+		if counter.count > 0 && !s.received {
+			if gotData != nil && !gotData() {
+				return EventsPass{}, ctx.Err()
+			}
+			s.received = true
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return EventsPass{ReadErr: err}, nil
+		}
+		if len(line) == 0 {
+			continue
+		}
+		var event PrepareJobEvent
+		if err := json.Unmarshal(line, &event); err != nil {
+			return EventsPass{}, err
+		}
+		if s.lastSeq > 0 && event.Seq > 0 {
+			if event.Seq <= s.lastSeq {
+				continue
+			}
+			// The engine already started after lastSeq.
+			if currentIndex < s.resumeIndex {
+				currentIndex = s.resumeIndex
+			}
+		} else if currentIndex < s.resumeIndex {
+			currentIndex++
+			continue
+		}
+		done, err := handle(currentIndex, event)
+		if err != nil {
+			return EventsPass{}, err
+		}
+		if done {
+			return EventsPass{Done: true}, nil
+		}
+		currentIndex++
+		s.resumeIndex = currentIndex
+		if event.Seq > 0 {
+			s.lastSeq = event.Seq
+		}
+	}
+	exhausted := resp.StatusCode == http.StatusOK && resp.ContentLength >= 0 && counter.count >= resp.ContentLength
+	return EventsPass{Exhausted: exhausted}, nil
+}
+
+// eventsURLAfterSeq adds after_seq to an events URL, keeping its other query
+// parameters.
+func eventsURLAfterSeq(eventsURL string, seq int64) string {
+	parsed, err := url.Parse(eventsURL)
+	if err != nil {
+		return eventsURL
+	}
+	query := parsed.Query()
+	query.Set("after_seq", strconv.FormatInt(seq, 10))
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
+}
+
+// FollowOptions configures FollowPrepareEvents.
+type FollowOptions struct {
+	// SinceOffset skips events before this offset.
+	SinceOffset int
+	// Ended is consulted when the server closes the stream before handle
+	// reports done; returning true stops streaming instead of reconnecting.
+	Ended func(ctx context.Context) (bool, error)
+}
+
+// FollowPrepareEvents follows a prepare job events stream, reconnecting from
+// the last delivered offset until handle or options.Ended stops it.
+func (c *Client) FollowPrepareEvents(ctx context.Context, eventsURL string, handle PrepareEventHandler, options FollowOptions) error {
+	stream := c.NewEventsStream(eventsURL, options.SinceOffset, 0)
+	for {
+		pass, err := stream.Pass(ctx, handle)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		if pass.Done {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if pass.ReadErr != nil {
+			continue
+		}
+		if options.Ended != nil {
+			stop, err := options.Ended(ctx)
+			if err != nil {
+				return err
+			}
+			if stop {
+				return nil
+			}
+		}
+		if pass.Exhausted {
+			return fmt.Errorf("prepare job events stream ended without terminal status")
+		}
+	}
+}
+
+type countingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	c.count += int64(n)
+	return n, err
+}
+
+func parseEventsContentRange(value string) (int, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, fmt.Errorf("missing content range")
+	}
+	if !strings.HasPrefix(value, "events ") {
+		return 0, fmt.Errorf("unexpected content range: %s", value)
+	}
+	value = strings.TrimPrefix(value, "events ")
+	parts := strings.SplitN(value, "/", 2)
+	rangePart := parts[0]
+	dash := strings.Index(rangePart, "-")
+	if dash == -1 {
+		return 0, fmt.Errorf("invalid content range: %s", value)
+	}
+	start, err := strconv.Atoi(strings.TrimSpace(rangePart[:dash]))
+	if err != nil {
+		return 0, fmt.Errorf("invalid content range: %s", value)
+	}
+	return start, nil
+}
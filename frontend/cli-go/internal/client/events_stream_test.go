@@ -0,0 +1,92 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFollowPrepareEventsResumesWithRange(t *testing.T) {
+	var calls int32
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		if atomic.AddInt32(&calls, 1) == 1 {
+			// Promise more than is sent so the client sees a broken body.
+			w.Header().Set("Content-Length", "1000")
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprintln(w, `{"type":"log","ts":"t0","message":"one"}`)
+			return
+		}
+		w.Header().Set("Content-Range", "events 1-1/2")
+		w.WriteHeader(http.StatusPartialContent)
+		fmt.Fprintln(w, `{"type":"status","ts":"t1","status":"succeeded"}`)
+	}))
+	defer server.Close()
+
+	cli := New(server.URL, Options{Timeout: time.Second})
+	var got []string
+	err := cli.FollowPrepareEvents(context.Background(), "/v1/prepare-jobs/job-1/events", func(index int, event PrepareJobEvent) (bool, error) {
+		got = append(got, fmt.Sprintf("%d:%s", index, event.Type))
+		return event.Type == "status", nil
+	}, FollowOptions{})
+	if err != nil {
+		t.Fatalf("FollowPrepareEvents: %v", err)
+	}
+	if strings.Join(got, ",") != "0:log,1:status" {
+		t.Fatalf("unexpected events: %v", got)
+	}
+	if len(ranges) != 2 || ranges[0] != "" || ranges[1] != "events=1-" {
+		t.Fatalf("unexpected Range headers: %q", ranges)
+	}
+}
+
+func TestFollowPrepareEventsStopsWhenEnded(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"type":"log","ts":"t0"}`)
+	}))
+	defer server.Close()
+
+	cli := New(server.URL, Options{Timeout: time.Second})
+	ended := 0
+	err := cli.FollowPrepareEvents(context.Background(), "/v1/prepare-jobs/job-1/events", func(int, PrepareJobEvent) (bool, error) {
+		return false, nil
+	}, FollowOptions{
+		SinceOffset: 1,
+		Ended: func(context.Context) (bool, error) {
+			ended++
+			return true, nil
+		},
+	})
+	if err != nil || ended != 1 {
+		t.Fatalf("expected ended to stop the stream, got err=%v ended=%d", err, ended)
+	}
+}
+
+func TestParseEventsContentRangeErrors(t *testing.T) {
+	cases := []string{
+		"",
+		"bytes 1-2/3",
+		"events 1/3",
+		"events a-b/3",
+	}
+	for _, in := range cases {
+		if _, err := parseEventsContentRange(in); err == nil {
+			t.Fatalf("expected parse error for %q", in)
+		}
+	}
+}
+
+func TestEventsURLAfterSeq(t *testing.T) {
+	if got := eventsURLAfterSeq("/v1/prepare-jobs/job-1/events", 5); got != "/v1/prepare-jobs/job-1/events?after_seq=5" {
+		t.Fatalf("unexpected url: %q", got)
+	}
+	if got := eventsURLAfterSeq("http://engine/v1/prepare-jobs/job-1/events?after_seq=1&x=y", 12); got != "http://engine/v1/prepare-jobs/job-1/events?after_seq=12&x=y" {
+		t.Fatalf("unexpected url: %q", got)
+	}
+}
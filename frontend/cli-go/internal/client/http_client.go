@@ -9,6 +9,7 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"sort"
 	"strings"
 	"time"
 )
@@ -74,6 +75,30 @@ func (c *Client) Health(ctx context.Context) (HealthResponse, error) {
 	return out, nil
 }
 
+func (c *Client) GetVersion(ctx context.Context) (VersionResponse, error) {
+	var out VersionResponse
+	if err := c.doJSON(ctx, http.MethodGet, "/v1/version", false, &out); err != nil {
+		return out, err
+	}
+	return out, nil
+}
+
+func (c *Client) GetEngineStats(ctx context.Context) (EngineStats, error) {
+	var out EngineStats
+	if err := c.doJSON(ctx, http.MethodGet, "/v1/engine/stats", true, &out); err != nil {
+		return out, err
+	}
+	return out, nil
+}
+
+// GetDiagnostics returns the engine-side doctor checks; found is false when
+// the engine predates /v1/diagnostics.
+func (c *Client) GetDiagnostics(ctx context.Context) (Diagnostics, bool, error) {
+	var out Diagnostics
+	found, err := c.doJSONOptional(ctx, http.MethodGet, "/v1/diagnostics", true, &out)
+	return out, found, err
+}
+
 func (c *Client) GetCacheStatus(ctx context.Context) (CacheStatus, error) {
 	var out CacheStatus
 	if err := c.doJSON(ctx, http.MethodGet, "/v1/cache/status", true, &out); err != nil {
@@ -82,6 +107,14 @@ func (c *Client) GetCacheStatus(ctx context.Context) (CacheStatus, error) {
 	return out, nil
 }
 
+func (c *Client) GetUsage(ctx context.Context) (UsageSummary, error) {
+	var out UsageSummary
+	if err := c.doJSON(ctx, http.MethodGet, "/v1/usage", true, &out); err != nil {
+		return out, err
+	}
+	return out, nil
+}
+
 func (c *Client) ExplainPrepareCache(ctx context.Context, req PrepareJobRequest) (CacheExplainPrepareResponse, error) {
 	var out CacheExplainPrepareResponse
 	body, err := json.Marshal(req)
@@ -153,9 +186,9 @@ func (c *Client) ListStates(ctx context.Context, filters ListFilters) ([]StateEn
 	return out, nil
 }
 
-func (c *Client) GetState(ctx context.Context, stateID string) (StateEntry, bool, error) {
+func (c *Client) GetState(ctx context.Context, stateID string) (StateDetail, bool, error) {
 	path := "/v1/states/" + url.PathEscape(strings.TrimSpace(stateID))
-	var out StateEntry
+	var out StateDetail
 	found, err := c.doJSONOptional(ctx, http.MethodGet, path, true, &out)
 	return out, found, err
 }
@@ -168,6 +201,87 @@ func (c *Client) DeleteState(ctx context.Context, stateID string, opts DeleteOpt
 	return c.deleteWithOptions(ctx, "/v1/states/"+url.PathEscape(strings.TrimSpace(stateID)), opts, true)
 }
 
+func (c *Client) PruneStates(ctx context.Context, opts PruneStatesOptions) (PruneStatesResult, error) {
+	var out PruneStatesResult
+	query := url.Values{}
+	if opts.DryRun {
+		query.Set("dry_run", "true")
+	}
+	if olderThan := strings.TrimSpace(opts.OlderThan); olderThan != "" {
+		query.Set("older_than", olderThan)
+	}
+	resp, err := c.doRequest(ctx, http.MethodPost, appendQuery("/v1/states/gc", query), true)
+	if err != nil {
+		return out, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return out, parseErrorResponse(resp)
+	}
+	decoder := json.NewDecoder(resp.Body)
+	if err := decoder.Decode(&out); err != nil {
+		return out, err
+	}
+	return out, nil
+}
+
+func (c *Client) ExportState(ctx context.Context, stateID string, w io.Writer) error {
+	path := "/v1/states/" + url.PathEscape(strings.TrimSpace(stateID)) + "/export"
+	resp, err := c.doSourceRequestWithBody(ctx, http.MethodGet, path, true, nil, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return parseErrorResponse(resp)
+	}
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+func (c *Client) ImportState(ctx context.Context, body io.Reader) (StateImportResult, error) {
+	var out StateImportResult
+	resp, err := c.doSourceRequestWithBody(ctx, http.MethodPost, "/v1/states/import", true, body, "application/x-tar")
+	if err != nil {
+		return out, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return out, parseErrorResponse(resp)
+	}
+	decoder := json.NewDecoder(resp.Body)
+	if err := decoder.Decode(&out); err != nil {
+		return out, err
+	}
+	return out, nil
+}
+
+// TagState points name at a state; tagging again re-points the name.
+func (c *Client) TagState(ctx context.Context, stateID string, name string) (NameEntry, error) {
+	var out NameEntry
+	body, err := json.Marshal(map[string]string{"name": name})
+	if err != nil {
+		return out, err
+	}
+	path := "/v1/states/" + url.PathEscape(strings.TrimSpace(stateID)) + "/tags"
+	resp, err := c.doRequestWithBody(ctx, http.MethodPost, path, true, bytes.NewReader(body), "application/json")
+	if err != nil {
+		return out, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return out, parseErrorResponse(resp)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return out, err
+	}
+	return out, nil
+}
+
 func (c *Client) CreatePrepareJob(ctx context.Context, req PrepareJobRequest) (PrepareJobAccepted, error) {
 	var out PrepareJobAccepted
 	body, err := json.Marshal(req)
@@ -260,6 +374,24 @@ func (c *Client) ListPrepareJobs(ctx context.Context, jobID string) ([]PrepareJo
 	return out, nil
 }
 
+// ListPrepareJobsByLabels returns the prepare jobs carrying every given label.
+func (c *Client) ListPrepareJobsByLabels(ctx context.Context, labels map[string]string) ([]PrepareJobEntry, error) {
+	var out []PrepareJobEntry
+	query := url.Values{}
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		query.Add("label", key+"="+labels[key])
+	}
+	if err := c.doJSON(ctx, http.MethodGet, appendQuery("/v1/prepare-jobs", query), true, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *Client) DeletePrepareJob(ctx context.Context, jobID string, opts DeleteOptions) (DeleteResult, int, error) {
 	return c.deleteWithOptions(ctx, "/v1/prepare-jobs/"+url.PathEscape(strings.TrimSpace(jobID)), opts, false)
 }
@@ -1,11 +1,14 @@
 package client
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 	"time"
 )
@@ -141,7 +144,7 @@ func TestGetStateFound(t *testing.T) {
 			return
 		}
 		w.Header().Set("Content-Type", "application/json")
-		w.Write([]byte(`{"state_id":"state-1","image_id":"img","prepare_kind":"psql","prepare_args_normalized":"-c select 1","created_at":"2026-01-01T00:00:00Z","refcount":1}`))
+		w.Write([]byte(`{"state_id":"state-1","image_id":"img","prepare_kind":"psql","prepare_args_normalized":"-c select 1","created_at":"2026-01-01T00:00:00Z","refcount":1,"ancestors":[{"state_id":"state-0","image_id":"img","prepare_kind":"psql","prepare_args_normalized":"-c create","created_at":"2026-01-01T00:00:00Z","refcount":0}]}`))
 	}))
 	defer server.Close()
 
@@ -153,6 +156,9 @@ func TestGetStateFound(t *testing.T) {
 	if !found || state.StateID != "state-1" {
 		t.Fatalf("unexpected result: %+v, found=%v", state, found)
 	}
+	if len(state.Ancestors) != 1 || state.Ancestors[0].StateID != "state-0" {
+		t.Fatalf("unexpected ancestors: %+v", state.Ancestors)
+	}
 }
 
 func TestGetStateNotFound(t *testing.T) {
@@ -226,6 +232,87 @@ func TestDeleteStateOptions(t *testing.T) {
 	}
 }
 
+func TestPruneStatesOptions(t *testing.T) {
+	var gotQuery url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/states/gc" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		gotQuery = r.URL.Query()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"dry_run":true,"outcome":"would_delete","older_than":"1h0m0s","state_ids":["state-1"],"reclaimed_bytes":42}`))
+	}))
+	defer server.Close()
+
+	cli := New(server.URL, Options{Timeout: time.Second})
+	result, err := cli.PruneStates(context.Background(), PruneStatesOptions{DryRun: true, OlderThan: "1h"})
+	if err != nil {
+		t.Fatalf("PruneStates: %v", err)
+	}
+	if result.Outcome != "would_delete" || len(result.StateIDs) != 1 || result.ReclaimedBytes != 42 {
+		t.Fatalf("unexpected prune result: %+v", result)
+	}
+	if gotQuery.Get("dry_run") != "true" || gotQuery.Get("older_than") != "1h" {
+		t.Fatalf("unexpected query: %+v", gotQuery)
+	}
+}
+
+func TestExportImportState(t *testing.T) {
+	var imported string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/states/state-1/export":
+			w.Header().Set("Content-Type", "application/x-tar")
+			w.Write([]byte("archive"))
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/states/import":
+			body, _ := io.ReadAll(r.Body)
+			imported = string(body)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"state_id":"state-1","created":true}`))
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"code":"not_found","message":"state not found"}`))
+		}
+	}))
+	defer server.Close()
+
+	cli := New(server.URL, Options{Timeout: time.Second})
+	var archive bytes.Buffer
+	if err := cli.ExportState(context.Background(), "state-1", &archive); err != nil {
+		t.Fatalf("ExportState: %v", err)
+	}
+	if archive.String() != "archive" {
+		t.Fatalf("unexpected archive: %q", archive.String())
+	}
+	result, err := cli.ImportState(context.Background(), &archive)
+	if err != nil {
+		t.Fatalf("ImportState: %v", err)
+	}
+	if result.StateID != "state-1" || !result.Created || imported != "archive" {
+		t.Fatalf("unexpected import: %+v body=%q", result, imported)
+	}
+	if err := cli.ExportState(context.Background(), "missing", io.Discard); err == nil || !strings.Contains(err.Error(), "state not found") {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+}
+
+func TestPruneStatesError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"code":"invalid_argument","message":"invalid older_than"}`))
+	}))
+	defer server.Close()
+
+	cli := New(server.URL, Options{Timeout: time.Second})
+	if _, err := cli.PruneStates(context.Background(), PruneStatesOptions{OlderThan: "soon"}); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
 func TestCreatePrepareJob(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost || r.URL.Path != "/v1/prepare-jobs" {
@@ -360,3 +447,32 @@ func TestCancelPrepareJobError(t *testing.T) {
 		t.Fatalf("expected status 404, got %d", code)
 	}
 }
+
+func TestTagState(t *testing.T) {
+	var gotBody string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/states/state-1/tags" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"code":"not_found","message":"state not found"}`))
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"name":"my-seed","image_id":"img","state_id":"state-1","status":"active"}`))
+	}))
+	defer server.Close()
+
+	cli := New(server.URL, Options{Timeout: time.Second})
+	entry, err := cli.TagState(context.Background(), "state-1", "my-seed")
+	if err != nil {
+		t.Fatalf("TagState: %v", err)
+	}
+	if entry.Name != "my-seed" || entry.StateID != "state-1" || gotBody != `{"name":"my-seed"}` {
+		t.Fatalf("unexpected tag: %+v body=%q", entry, gotBody)
+	}
+	if _, err := cli.TagState(context.Background(), "missing", "my-seed"); err == nil || !strings.Contains(err.Error(), "state not found") {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+}
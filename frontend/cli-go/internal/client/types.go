@@ -7,6 +7,43 @@ type HealthResponse struct {
 	PID        int    `json:"pid"`
 }
 
+type VersionResponse struct {
+	Version          string `json:"version"`
+	APIVersion       string `json:"apiVersion"`
+	Revision         string `json:"revision,omitempty"`
+	BuildTime        string `json:"buildTime,omitempty"`
+	Modified         bool   `json:"modified,omitempty"`
+	GoVersion        string `json:"goVersion,omitempty"`
+	ContainerRuntime string `json:"containerRuntime,omitempty"`
+	SnapshotBackend  string `json:"snapshotBackend,omitempty"`
+}
+
+type EngineStats struct {
+	Version            string  `json:"version"`
+	InstanceID         string  `json:"instanceId"`
+	PID                int     `json:"pid"`
+	StartedAt          string  `json:"startedAt,omitempty"`
+	UptimeSeconds      float64 `json:"uptimeSeconds"`
+	InflightRequests   int64   `json:"inflightRequests"`
+	IdleSeconds        float64 `json:"idleSeconds"`
+	IdleTimeoutSeconds float64 `json:"idleTimeoutSeconds"`
+	RunningJobs        int     `json:"runningJobs"`
+	ContainerRuntime   string  `json:"containerRuntime,omitempty"`
+	SnapshotBackend    string  `json:"snapshotBackend,omitempty"`
+}
+
+type Diagnostics struct {
+	Ok     bool              `json:"ok"`
+	Checks []DiagnosticCheck `json:"checks"`
+}
+
+type DiagnosticCheck struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	Hint    string `json:"hint,omitempty"`
+}
+
 type CacheEvictionSummary struct {
 	CompletedAt      string `json:"completed_at"`
 	Trigger          string `json:"trigger"`
@@ -20,6 +57,30 @@ type CacheEvictionSummary struct {
 	FreeBytesAfter   int64  `json:"free_bytes_after"`
 }
 
+// UsageSummary is the GET /v1/usage payload.
+type UsageSummary struct {
+	GeneratedAt       string         `json:"generated_at"`
+	StateCount        int            `json:"state_count"`
+	StateSizeBytes    int64          `json:"state_size_bytes"`
+	UnsizedStateCount int            `json:"unsized_state_count"`
+	Jobs              map[string]int `json:"jobs"`
+	Cache             UsageCache     `json:"cache"`
+	PrepareDuration   UsageDuration  `json:"prepare_duration"`
+}
+
+type UsageCache struct {
+	Hits     int      `json:"hits"`
+	Misses   int      `json:"misses"`
+	HitRatio *float64 `json:"hit_ratio,omitempty"`
+}
+
+type UsageDuration struct {
+	Count  int   `json:"count"`
+	MeanMs int64 `json:"mean_ms"`
+	P50Ms  int64 `json:"p50_ms"`
+	P95Ms  int64 `json:"p95_ms"`
+}
+
 type CacheStatus struct {
 	UsageBytes         int64                 `json:"usage_bytes"`
 	ConfiguredMaxBytes *int64                `json:"configured_max_bytes,omitempty"`
@@ -88,6 +149,13 @@ type StateEntry struct {
 	RefCount          int     `json:"refcount"`
 }
 
+// StateDetail is returned by GET /v1/states/{id}: the state plus its ancestor
+// chain, nearest parent first.
+type StateDetail struct {
+	StateEntry
+	Ancestors []StateEntry `json:"ancestors,omitempty"`
+}
+
 type PrepareJobRequest struct {
 	PrepareKind       string            `json:"prepare_kind"`
 	ImageID           string            `json:"image_id"`
@@ -100,6 +168,12 @@ type PrepareJobRequest struct {
 	Stdin             *string           `json:"stdin,omitempty"`
 	SourceManifest    *SourceManifest   `json:"source_manifest,omitempty"`
 	PlanOnly          bool              `json:"plan_only,omitempty"`
+	// LiquibaseStdin replaces --changelog-file with inline changelog content.
+	LiquibaseStdin       *string `json:"liquibase_stdin,omitempty"`
+	LiquibaseStdinFormat string  `json:"liquibase_stdin_format,omitempty"`
+	// CancelOnDisconnect cancels the job once every client streaming its
+	// events has disconnected for the engine's grace period.
+	CancelOnDisconnect bool `json:"cancel_on_disconnect,omitempty"`
 }
 
 // SourceManifest is the CLI-side representation of the remote source-sync
@@ -172,27 +246,36 @@ type PrepareJobStatus struct {
 }
 
 type PrepareJobEntry struct {
-	JobID                 string  `json:"job_id"`
-	Status                string  `json:"status"`
-	PrepareKind           string  `json:"prepare_kind"`
-	ImageID               string  `json:"image_id"`
-	ResolvedImageID       string  `json:"resolved_image_id,omitempty"`
-	PrepareArgsNormalized string  `json:"prepare_args_normalized,omitempty"`
-	Signature             string  `json:"signature,omitempty"`
-	PlanOnly              bool    `json:"plan_only,omitempty"`
-	CreatedAt             *string `json:"created_at,omitempty"`
-	StartedAt             *string `json:"started_at,omitempty"`
-	FinishedAt            *string `json:"finished_at,omitempty"`
+	JobID                 string            `json:"job_id"`
+	Status                string            `json:"status"`
+	PrepareKind           string            `json:"prepare_kind"`
+	ImageID               string            `json:"image_id"`
+	ResolvedImageID       string            `json:"resolved_image_id,omitempty"`
+	PrepareArgsNormalized string            `json:"prepare_args_normalized,omitempty"`
+	Signature             string            `json:"signature,omitempty"`
+	PlanOnly              bool              `json:"plan_only,omitempty"`
+	Labels                map[string]string `json:"labels,omitempty"`
+	CreatedAt             *string           `json:"created_at,omitempty"`
+	StartedAt             *string           `json:"started_at,omitempty"`
+	FinishedAt            *string           `json:"finished_at,omitempty"`
 }
 
 type PrepareJobEvent struct {
-	Type    string            `json:"type"`
-	Ts      string            `json:"ts"`
-	Status  string            `json:"status,omitempty"`
-	TaskID  string            `json:"task_id,omitempty"`
-	Message string            `json:"message,omitempty"`
-	Result  *PrepareJobResult `json:"result,omitempty"`
-	Error   *ErrorResponse    `json:"error,omitempty"`
+	Seq      int64             `json:"seq,omitempty"`
+	Type     string            `json:"type"`
+	Ts       string            `json:"ts"`
+	Status   string            `json:"status,omitempty"`
+	TaskID   string            `json:"task_id,omitempty"`
+	Message  string            `json:"message,omitempty"`
+	Result   *PrepareJobResult `json:"result,omitempty"`
+	Error    *ErrorResponse    `json:"error,omitempty"`
+	Progress *TaskProgress     `json:"progress,omitempty"`
+	Plan     []PlanTask        `json:"plan,omitempty"`
+}
+
+type TaskProgress struct {
+	Completed int `json:"completed"`
+	Total     int `json:"total"`
 }
 
 type PrepareJobResult struct {
@@ -241,6 +324,9 @@ type PlanTask struct {
 	OutputStateID string     `json:"output_state_id,omitempty"`
 	Cached        *bool      `json:"cached,omitempty"`
 	InstanceMode  string     `json:"instance_mode,omitempty"`
+	StartedAt     *string    `json:"started_at,omitempty"`
+	FinishedAt    *string    `json:"finished_at,omitempty"`
+	DurationMs    *int64     `json:"duration_ms,omitempty"`
 }
 
 type TaskEntry struct {
@@ -260,6 +346,9 @@ type TaskEntry struct {
 	ChangesetID     string     `json:"changeset_id,omitempty"`
 	ChangesetAuthor string     `json:"changeset_author,omitempty"`
 	ChangesetPath   string     `json:"changeset_path,omitempty"`
+	StartedAt       *string    `json:"started_at,omitempty"`
+	FinishedAt      *string    `json:"finished_at,omitempty"`
+	DurationMs      *int64     `json:"duration_ms,omitempty"`
 }
 
 type ErrorResponse struct {
@@ -348,6 +437,24 @@ type DeleteResult struct {
 	Root    DeleteNode `json:"root"`
 }
 
+type PruneStatesOptions struct {
+	DryRun    bool
+	OlderThan string
+}
+
+type PruneStatesResult struct {
+	DryRun         bool     `json:"dry_run"`
+	Outcome        string   `json:"outcome"`
+	OlderThan      string   `json:"older_than"`
+	StateIDs       []string `json:"state_ids"`
+	ReclaimedBytes int64    `json:"reclaimed_bytes"`
+}
+
+type StateImportResult struct {
+	StateID string `json:"state_id"`
+	Created bool   `json:"created"`
+}
+
 type DeleteNode struct {
 	Kind        string       `json:"kind"`
 	ID          string       `json:"id"`
@@ -54,6 +54,9 @@ type ProfileConfig struct {
 	Autostart  bool             `yaml:"autostart"`
 	Auth       AuthConfig       `yaml:"auth"`
 	SourceSync SourceSyncConfig `yaml:"sourceSync"`
+	// NoStream makes the CLI poll prepare job status instead of reading the
+	// events stream, for endpoints behind proxies that buffer responses.
+	NoStream bool `yaml:"noStream"`
 }
 
 type AuthConfig struct {
@@ -42,15 +42,22 @@ type ConnectOptions struct {
 	WSLMountUnit    string
 	WSLMountFSType  string
 	IdleTimeout     time.Duration
-	StartupTimeout  time.Duration
-	ClientTimeout   time.Duration
-	Verbose         bool
+	// ContainerRuntime and SnapshotBackend are only applied when this call
+	// starts the engine; an engine that is already running keeps its own.
+	ContainerRuntime string
+	SnapshotBackend  string
+	StartupTimeout   time.Duration
+	ClientTimeout    time.Duration
+	Verbose          bool
 }
 
 type ConnectResult struct {
 	Endpoint  string
 	AuthToken string
 	State     EngineState
+	// Started is set when this call launched the engine rather than reusing
+	// one that was already running.
+	Started bool
 }
 
 func ConnectOrStart(ctx context.Context, opts ConnectOptions) (ConnectResult, error) {
@@ -157,17 +164,19 @@ LONG_PATH:
 	if daemonStatePath == "" {
 		daemonStatePath = enginePath
 	}
-	cmd := buildDaemonCommand(
-		opts.DaemonPath,
-		daemonRunDir,
-		daemonStatePath,
-		opts.WSLDistro,
-		opts.EngineStoreDir,
-		opts.WSLMountUnit,
-		opts.WSLMountFSType,
-		opts.IdleTimeout,
-		logPath,
-	)
+	cmd := buildDaemonCommand(daemonCommand{
+		Path:             opts.DaemonPath,
+		RunDir:           daemonRunDir,
+		StatePath:        daemonStatePath,
+		WSLDistro:        opts.WSLDistro,
+		StoreDir:         opts.EngineStoreDir,
+		MountUnit:        opts.WSLMountUnit,
+		MountFSType:      opts.WSLMountFSType,
+		IdleTimeout:      opts.IdleTimeout,
+		ContainerRuntime: opts.ContainerRuntime,
+		SnapshotBackend:  opts.SnapshotBackend,
+		LogPath:          logPath,
+	})
 	cmd.Stdout = logFile
 	cmd.Stderr = logFile
 
@@ -204,7 +213,7 @@ LONG_PATH:
 			}
 			logVerbose(opts.Verbose, "engine healthy at %s", state.Endpoint)
 			storeCachedEngineState(opts, state)
-			return ConnectResult{Endpoint: state.Endpoint, AuthToken: state.AuthToken, State: state}, nil
+			return ConnectResult{Endpoint: state.Endpoint, AuthToken: state.AuthToken, State: state, Started: true}, nil
 		} else if opts.Verbose && reason != "" {
 			now := time.Now()
 			if reason != lastReason || now.Sub(lastReasonAt) > time.Second {
@@ -385,7 +385,7 @@ func TestConnectOrStartReturnsHealthyState(t *testing.T) {
 	if err != nil {
 		t.Fatalf("ConnectOrStart: %v", err)
 	}
-	if result.Endpoint != server.URL || result.AuthToken != "token" {
+	if result.Endpoint != server.URL || result.AuthToken != "token" || result.Started {
 		t.Fatalf("unexpected result: %+v", result)
 	}
 }
@@ -7,50 +7,73 @@ import (
 	"time"
 )
 
-func buildDaemonCommand(path, runDir, statePath, wslDistro, storeDir, mountUnit, mountFSType string, idleTimeout time.Duration, logPath string) *exec.Cmd {
+// daemonCommand describes how to launch sqlrs-engine for autostart.
+// ContainerRuntime and SnapshotBackend are passed as engine flags and
+// override the engine config for that process only.
+type daemonCommand struct {
+	Path             string
+	RunDir           string
+	StatePath        string
+	WSLDistro        string
+	StoreDir         string
+	MountUnit        string
+	MountFSType      string
+	IdleTimeout      time.Duration
+	ContainerRuntime string
+	SnapshotBackend  string
+	LogPath          string
+}
+
+func buildDaemonCommand(spec daemonCommand) *exec.Cmd {
 	args := []string{
-		"--run-dir", runDir,
+		"--run-dir", spec.RunDir,
 		"--listen", "127.0.0.1:0",
-		"--write-engine-json", statePath,
+		"--write-engine-json", spec.StatePath,
+	}
+	if spec.IdleTimeout > 0 {
+		args = append(args, "--idle-timeout", spec.IdleTimeout.String())
+	}
+	if value := strings.TrimSpace(spec.ContainerRuntime); value != "" {
+		args = append(args, "--container-runtime", value)
 	}
-	if idleTimeout > 0 {
-		args = append(args, "--idle-timeout", idleTimeout.String())
+	if value := strings.TrimSpace(spec.SnapshotBackend); value != "" {
+		args = append(args, "--snapshot-backend", value)
 	}
-	if wslDistro != "" {
-		wslCmd := []string{"-d", wslDistro, "-u", "root", "--"}
+	if spec.WSLDistro != "" {
+		wslCmd := []string{"-d", spec.WSLDistro, "-u", "root", "--"}
 		envVars := []string{}
-		if storeDir != "" {
-			envVars = append(envVars, "SQLRS_STATE_STORE="+storeDir)
+		if spec.StoreDir != "" {
+			envVars = append(envVars, "SQLRS_STATE_STORE="+spec.StoreDir)
 		}
-		if mountUnit != "" {
-			envVars = append(envVars, "SQLRS_WSL_MOUNT_UNIT="+mountUnit)
+		if spec.MountUnit != "" {
+			envVars = append(envVars, "SQLRS_WSL_MOUNT_UNIT="+spec.MountUnit)
 		}
-		if mountFSType != "" {
-			envVars = append(envVars, "SQLRS_WSL_MOUNT_FSTYPE="+mountFSType)
+		if spec.MountFSType != "" {
+			envVars = append(envVars, "SQLRS_WSL_MOUNT_FSTYPE="+spec.MountFSType)
 		}
 		if len(envVars) > 0 {
 			wslCmd = append(wslCmd, "env")
 			wslCmd = append(wslCmd, envVars...)
 		}
-		wslCmd = append(wslCmd, "nsenter", "-t", "1", "-m", "--", path)
+		wslCmd = append(wslCmd, "nsenter", "-t", "1", "-m", "--", spec.Path)
 		wslCmd = append(wslCmd, args...)
 		cmd := exec.Command("wsl.exe", wslCmd...)
 		cmd.Stdin = nil
 		configureDetachedWSL(cmd)
-		appendLogLine(logPath, "spawn wsl: "+buildCmdLine("wsl.exe", wslCmd, ""))
+		appendLogLine(spec.LogPath, "spawn wsl: "+buildCmdLine("wsl.exe", wslCmd, ""))
 		return cmd
 	}
-	cmd := exec.Command(path, args...)
+	cmd := exec.Command(spec.Path, args...)
 	cmd.Stdin = nil
 	envVars := []string{}
-	if storeDir != "" {
-		envVars = append(envVars, "SQLRS_STATE_STORE="+storeDir)
+	if spec.StoreDir != "" {
+		envVars = append(envVars, "SQLRS_STATE_STORE="+spec.StoreDir)
 	}
-	if mountUnit != "" {
-		envVars = append(envVars, "SQLRS_WSL_MOUNT_UNIT="+mountUnit)
+	if spec.MountUnit != "" {
+		envVars = append(envVars, "SQLRS_WSL_MOUNT_UNIT="+spec.MountUnit)
 	}
-	if mountFSType != "" {
-		envVars = append(envVars, "SQLRS_WSL_MOUNT_FSTYPE="+mountFSType)
+	if spec.MountFSType != "" {
+		envVars = append(envVars, "SQLRS_WSL_MOUNT_FSTYPE="+spec.MountFSType)
 	}
 	if len(envVars) > 0 {
 		cmd.Env = append(os.Environ(), envVars...)
@@ -12,7 +12,7 @@ import (
 func TestBuildDaemonCommand(t *testing.T) {
 	runDir := filepath.Join("C:\\", "sqlrs", "run")
 	statePath := filepath.Join("C:\\", "sqlrs", "engine.json")
-	cmd := buildDaemonCommand("sqlrs-engine", runDir, statePath, "", "", "", "", 0, "")
+	cmd := buildDaemonCommand(daemonCommand{Path: "sqlrs-engine", RunDir: runDir, StatePath: statePath})
 	if len(cmd.Args) < 2 || cmd.Args[0] != "sqlrs-engine" {
 		t.Fatalf("unexpected args: %+v", cmd.Args)
 	}
@@ -24,7 +24,16 @@ func TestBuildDaemonCommand(t *testing.T) {
 func TestBuildDaemonCommandWSL(t *testing.T) {
 	runDir := "/var/lib/sqlrs/run"
 	statePath := "/mnt/c/sqlrs/engine.json"
-	cmd := buildDaemonCommand("/mnt/c/sqlrs/sqlrs-engine", runDir, statePath, "Ubuntu", "/var/lib/sqlrs/store", "sqlrs-state-store.mount", "btrfs", 0, filepath.Join("C:\\", "sqlrs", "logs", "engine.log"))
+	cmd := buildDaemonCommand(daemonCommand{
+		Path:        "/mnt/c/sqlrs/sqlrs-engine",
+		RunDir:      runDir,
+		StatePath:   statePath,
+		WSLDistro:   "Ubuntu",
+		StoreDir:    "/var/lib/sqlrs/store",
+		MountUnit:   "sqlrs-state-store.mount",
+		MountFSType: "btrfs",
+		LogPath:     filepath.Join("C:\\", "sqlrs", "logs", "engine.log"),
+	})
 	if runtime.GOOS == "windows" {
 		if len(cmd.Args) < 5 || cmd.Args[0] != "wsl.exe" {
 			t.Fatalf("unexpected args: %+v", cmd.Args)
@@ -57,7 +66,7 @@ func TestBuildDaemonCommandWSL(t *testing.T) {
 func TestBuildDaemonCommandEnvVars(t *testing.T) {
 	runDir := filepath.Join("C:\\", "sqlrs", "run")
 	statePath := filepath.Join("C:\\", "sqlrs", "engine.json")
-	cmd := buildDaemonCommand("sqlrs-engine", runDir, statePath, "", "C:\\store", "unit.mount", "btrfs", 0, "")
+	cmd := buildDaemonCommand(daemonCommand{Path: "sqlrs-engine", RunDir: runDir, StatePath: statePath, StoreDir: "C:\\store", MountUnit: "unit.mount", MountFSType: "btrfs"})
 	if !containsEnv(cmd.Env, "SQLRS_STATE_STORE=C:\\store") {
 		t.Fatalf("expected SQLRS_STATE_STORE in env")
 	}
@@ -72,7 +81,7 @@ func TestBuildDaemonCommandEnvVars(t *testing.T) {
 func TestBuildDaemonCommandWSLNoEnv(t *testing.T) {
 	runDir := "/var/lib/sqlrs/run"
 	statePath := "/mnt/c/sqlrs/engine.json"
-	cmd := buildDaemonCommand("/mnt/c/sqlrs/sqlrs-engine", runDir, statePath, "Ubuntu", "", "", "", 0, "")
+	cmd := buildDaemonCommand(daemonCommand{Path: "/mnt/c/sqlrs/sqlrs-engine", RunDir: runDir, StatePath: statePath, WSLDistro: "Ubuntu"})
 	if containsArg(cmd.Args, "SQLRS_STATE_STORE=") {
 		t.Fatalf("did not expect SQLRS_STATE_STORE in args")
 	}
@@ -87,7 +96,7 @@ func TestQuotePowerShell(t *testing.T) {
 func TestBuildDaemonCommandWithIdleTimeout(t *testing.T) {
 	runDir := filepath.Join("C:\\", "sqlrs", "run")
 	statePath := filepath.Join("C:\\", "sqlrs", "engine.json")
-	cmd := buildDaemonCommand("sqlrs-engine", runDir, statePath, "", "", "", "", 2*time.Minute, "")
+	cmd := buildDaemonCommand(daemonCommand{Path: "sqlrs-engine", RunDir: runDir, StatePath: statePath, IdleTimeout: 2 * time.Minute})
 	if !containsArg(cmd.Args, "--idle-timeout") {
 		t.Fatalf("expected --idle-timeout flag in args, got %+v", cmd.Args)
 	}
@@ -96,6 +105,65 @@ func TestBuildDaemonCommandWithIdleTimeout(t *testing.T) {
 	}
 }
 
+func TestBuildDaemonCommandEngineOverrides(t *testing.T) {
+	cmd := buildDaemonCommand(daemonCommand{
+		Path:             "sqlrs-engine",
+		RunDir:           "/run/sqlrs",
+		StatePath:        "/state/engine.json",
+		StoreDir:         "/srv/store",
+		IdleTimeout:      time.Minute,
+		ContainerRuntime: "podman",
+		SnapshotBackend:  "copy",
+	})
+	want := []string{
+		"sqlrs-engine",
+		"--run-dir", "/run/sqlrs",
+		"--listen", "127.0.0.1:0",
+		"--write-engine-json", "/state/engine.json",
+		"--idle-timeout", "1m0s",
+		"--container-runtime", "podman",
+		"--snapshot-backend", "copy",
+	}
+	if strings.Join(cmd.Args, " ") != strings.Join(want, " ") {
+		t.Fatalf("unexpected args:\n got %q\nwant %q", cmd.Args, want)
+	}
+	if !containsEnv(cmd.Env, "SQLRS_STATE_STORE=/srv/store") {
+		t.Fatalf("expected SQLRS_STATE_STORE in env")
+	}
+}
+
+func TestBuildDaemonCommandEngineOverridesWSL(t *testing.T) {
+	cmd := buildDaemonCommand(daemonCommand{
+		Path:             "/mnt/c/sqlrs/sqlrs-engine",
+		RunDir:           "/var/lib/sqlrs/run",
+		StatePath:        "/mnt/c/sqlrs/engine.json",
+		WSLDistro:        "Ubuntu",
+		StoreDir:         "/var/lib/sqlrs/store",
+		ContainerRuntime: "docker",
+		SnapshotBackend:  "btrfs",
+	})
+	want := []string{
+		"wsl.exe", "-d", "Ubuntu", "-u", "root", "--",
+		"env", "SQLRS_STATE_STORE=/var/lib/sqlrs/store",
+		"nsenter", "-t", "1", "-m", "--", "/mnt/c/sqlrs/sqlrs-engine",
+		"--run-dir", "/var/lib/sqlrs/run",
+		"--listen", "127.0.0.1:0",
+		"--write-engine-json", "/mnt/c/sqlrs/engine.json",
+		"--container-runtime", "docker",
+		"--snapshot-backend", "btrfs",
+	}
+	if strings.Join(cmd.Args, " ") != strings.Join(want, " ") {
+		t.Fatalf("unexpected args:\n got %q\nwant %q", cmd.Args, want)
+	}
+}
+
+func TestBuildDaemonCommandOmitsEmptyOverrides(t *testing.T) {
+	cmd := buildDaemonCommand(daemonCommand{Path: "sqlrs-engine", RunDir: "/run", StatePath: "/engine.json", ContainerRuntime: " ", SnapshotBackend: ""})
+	if containsArg(cmd.Args, "--container-runtime") || containsArg(cmd.Args, "--snapshot-backend") {
+		t.Fatalf("did not expect override flags, got %+v", cmd.Args)
+	}
+}
+
 func TestBuildCmdLineWithLog(t *testing.T) {
 	cmdline := buildCmdLine("wsl.exe", []string{"--arg", "value with space"}, "C:\\logs\\engine.log")
 	if !strings.Contains(cmdline, ">>") || !strings.Contains(cmdline, "engine.log") {
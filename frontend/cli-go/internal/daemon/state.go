@@ -2,19 +2,24 @@ package daemon
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
+	"strings"
 
 	"github.com/sqlrs/cli/internal/client"
 	"github.com/sqlrs/cli/internal/util"
 )
 
 type EngineState struct {
-	Endpoint   string `json:"endpoint"`
-	PID        int    `json:"pid"`
-	StartedAt  string `json:"startedAt"`
-	AuthToken  string `json:"authToken"`
-	Version    string `json:"version"`
-	InstanceID string `json:"instanceId"`
+	Endpoint  string `json:"endpoint"`
+	PID       int    `json:"pid"`
+	StartedAt string `json:"startedAt"`
+	AuthToken string `json:"authToken"`
+	// AuthTokenFile is set when the engine keeps its token outside
+	// engine.json; ReadEngineState loads the token from it.
+	AuthTokenFile string `json:"authTokenFile,omitempty"`
+	Version       string `json:"version"`
+	InstanceID    string `json:"instanceId"`
 }
 
 func ReadEngineState(path string) (EngineState, error) {
@@ -26,10 +31,20 @@ func ReadEngineState(path string) (EngineState, error) {
 	if err := json.Unmarshal(data, &state); err != nil {
 		return state, err
 	}
+	if strings.TrimSpace(state.AuthToken) == "" && strings.TrimSpace(state.AuthTokenFile) != "" {
+		token, err := os.ReadFile(state.AuthTokenFile)
+		if err != nil {
+			return state, fmt.Errorf("read auth token file: %w", err)
+		}
+		state.AuthToken = strings.TrimSpace(string(token))
+	}
 	return state, nil
 }
 
 func WriteEngineState(path string, state EngineState) error {
+	if strings.TrimSpace(state.AuthTokenFile) != "" {
+		state.AuthToken = ""
+	}
 	data, _ := json.MarshalIndent(state, "", "  ")
 	return util.AtomicWriteFile(path, data, 0o600)
 }
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/sqlrs/cli/internal/client"
@@ -36,6 +37,39 @@ func TestEngineStateReadWrite(t *testing.T) {
 	}
 }
 
+func TestReadEngineStateFollowsAuthTokenFile(t *testing.T) {
+	temp := t.TempDir()
+	tokenPath := filepath.Join(temp, "auth-token")
+	if err := os.WriteFile(tokenPath, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatalf("write token: %v", err)
+	}
+	path := filepath.Join(temp, "engine.json")
+	if err := WriteEngineState(path, EngineState{Endpoint: "127.0.0.1:1", AuthToken: "ignored", AuthTokenFile: tokenPath}); err != nil {
+		t.Fatalf("write engine state: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read engine.json: %v", err)
+	}
+	if strings.Contains(string(data), "ignored") {
+		t.Fatalf("expected token to stay out of engine.json, got %s", data)
+	}
+	read, err := ReadEngineState(path)
+	if err != nil {
+		t.Fatalf("read engine state: %v", err)
+	}
+	if read.AuthToken != "from-file" {
+		t.Fatalf("expected token from file, got %q", read.AuthToken)
+	}
+
+	if err := os.Remove(tokenPath); err != nil {
+		t.Fatalf("remove token: %v", err)
+	}
+	if _, err := ReadEngineState(path); err == nil {
+		t.Fatalf("expected error for missing token file")
+	}
+}
+
 func TestEngineStateStaleRules(t *testing.T) {
 	state := EngineState{InstanceID: "abc", PID: 10}
 
@@ -7,12 +7,14 @@ import (
 	"fmt"
 	"io"
 	"path/filepath"
+	"regexp"
 	"strings"
 
 	"github.com/sqlrs/cli/internal/inputset"
 )
 
 // NormalizeArgs applies shared host-path normalization for `psql` file-bearing args.
+// Stdin is read when a -f - file or a COPY ... FROM STDIN command uses it.
 func NormalizeArgs(args []string, resolver inputset.Resolver, stdin io.Reader) ([]string, *string, error) {
 	normalized := make([]string, 0, len(args))
 	usesStdin := false
@@ -45,6 +47,20 @@ func NormalizeArgs(args []string, resolver inputset.Resolver, stdin io.Reader) (
 			}
 			usesStdin = usesStdin || useStdin
 			normalized = append(normalized, "-f"+value)
+		case arg == "-c" || arg == "--command":
+			if i+1 < len(args) {
+				usesStdin = usesStdin || copiesFromStdin(args[i+1])
+				normalized = append(normalized, arg, args[i+1])
+				i++
+				continue
+			}
+			normalized = append(normalized, arg)
+		case strings.HasPrefix(arg, "--command="):
+			usesStdin = usesStdin || copiesFromStdin(strings.TrimPrefix(arg, "--command="))
+			normalized = append(normalized, arg)
+		case strings.HasPrefix(arg, "-c") && len(arg) > 2:
+			usesStdin = usesStdin || copiesFromStdin(arg[2:])
+			normalized = append(normalized, arg)
 		default:
 			normalized = append(normalized, arg)
 		}
@@ -61,6 +77,14 @@ func NormalizeArgs(args []string, resolver inputset.Resolver, stdin io.Reader) (
 	return normalized, &text, nil
 }
 
+// copyFromStdinPattern matches COPY ... FROM STDIN (or \copy ... FROM pstdin)
+// in a -c command; such a command reads its rows from the invocation stdin.
+var copyFromStdinPattern = regexp.MustCompile(`(?is)(?:^|;)\s*(?:copy\b[^;]*\bfrom\s+stdin|\\copy\b[^;]*\bfrom\s+pstdin)\b`)
+
+func copiesFromStdin(command string) bool {
+	return copyFromStdinPattern.MatchString(command)
+}
+
 // BuildRunSteps materializes the run-facing `psql` step projection from shared semantics.
 func BuildRunSteps(args []string, resolver inputset.Resolver, stdin io.Reader, fs inputset.FileSystem) ([]inputset.RunStep, error) {
 	var shared []string
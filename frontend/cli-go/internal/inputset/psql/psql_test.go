@@ -48,6 +48,27 @@ func TestNormalizeArgsReadsStdinOnceWhenReferenced(t *testing.T) {
 	}
 }
 
+func TestNormalizeArgsReadsStdinForCopyFromStdin(t *testing.T) {
+	root := t.TempDir()
+	resolver := inputset.NewWorkspaceResolver(root, root, nil)
+
+	args, stdinValue, err := NormalizeArgs([]string{"-c", "COPY items FROM STDIN (FORMAT csv)"}, resolver, strings.NewReader("1,alpha\n"))
+	if err != nil {
+		t.Fatalf("NormalizeArgs: %v", err)
+	}
+	if got := strings.Join(args, "|"); got != "-c|COPY items FROM STDIN (FORMAT csv)" {
+		t.Fatalf("args = %q", got)
+	}
+	if stdinValue == nil || *stdinValue != "1,alpha\n" {
+		t.Fatalf("stdin = %+v", stdinValue)
+	}
+
+	_, stdinValue, err = NormalizeArgs([]string{"-c", "select 1"}, resolver, strings.NewReader("ignored"))
+	if err != nil || stdinValue != nil {
+		t.Fatalf("expected stdin to stay unread, got %+v err=%v", stdinValue, err)
+	}
+}
+
 func TestBuildRunStepsMatchesSharedPsqlSemantics(t *testing.T) {
 	root := t.TempDir()
 	writeFile(t, filepath.Join(root, "query.sql"), "select 2;")
@@ -0,0 +1,288 @@
+// Package sqlrsclient is a Go client for the sqlrs engine HTTP API.
+//
+// It is the same client the sqlrs CLI uses, exposed with a stable surface:
+// submit prepare jobs, follow their events, and query states and instances.
+// Request and response types are aliases of the CLI's own, so they always
+// match the wire format of the engine the CLI ships with.
+//
+//	c := sqlrsclient.New("http://127.0.0.1:7777", sqlrsclient.Options{AuthToken: token})
+//	if _, err := c.CheckVersion(ctx); err != nil {
+//		return err
+//	}
+//	accepted, err := c.Submit(ctx, sqlrsclient.PrepareJobRequest{
+//		PrepareKind: "psql",
+//		ImageID:     "postgres:17",
+//		PsqlArgs:    []string{"-f", "/abs/path/schema.sql"},
+//	})
+//	if err != nil {
+//		return err
+//	}
+//	status, err := c.Wait(ctx, accepted.JobID)
+package sqlrsclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sqlrs/cli/internal/client"
+)
+
+// APIVersion is the engine API generation this package speaks. CheckVersion
+// compares it with the apiVersion reported by GET /v1/version.
+const APIVersion = "v1"
+
+// Prepare job statuses.
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+type (
+	HealthResponse  = client.HealthResponse
+	VersionResponse = client.VersionResponse
+
+	PrepareJobRequest  = client.PrepareJobRequest
+	PrepareJobAccepted = client.PrepareJobAccepted
+	PrepareJobStatus   = client.PrepareJobStatus
+	PrepareJobEntry    = client.PrepareJobEntry
+	PrepareJobEvent    = client.PrepareJobEvent
+	PrepareJobResult   = client.PrepareJobResult
+	TaskProgress       = client.TaskProgress
+	TaskInput          = client.TaskInput
+	PlanTask           = client.PlanTask
+	TaskEntry          = client.TaskEntry
+
+	ListFilters   = client.ListFilters
+	StateEntry    = client.StateEntry
+	StateDetail   = client.StateDetail
+	InstanceEntry = client.InstanceEntry
+	NameEntry     = client.NameEntry
+
+	DeleteOptions = client.DeleteOptions
+	DeleteResult  = client.DeleteResult
+	DeleteNode    = client.DeleteNode
+
+	// ErrorResponse is the error body the engine returns; Code is one of
+	// the stable codes of the ErrorResponse schema in the engine OpenAPI spec.
+	ErrorResponse = client.ErrorResponse
+	// ErrorResponseError is returned for non-2xx responses that carry an
+	// ErrorResponse body.
+	ErrorResponseError = client.ErrorResponseError
+	// HTTPStatusError is returned for non-2xx responses without one.
+	HTTPStatusError = client.HTTPStatusError
+
+	// EventHandler receives each prepare job event with its offset in the
+	// stream. Returning true stops streaming.
+	EventHandler = client.PrepareEventHandler
+)
+
+// ErrNotFound is returned when the requested job, state or instance does not
+// exist.
+var ErrNotFound = errors.New("not found")
+
+// Options configures a Client.
+type Options struct {
+	// AuthToken is sent as a bearer token on every authenticated request.
+	AuthToken string
+	// Timeout bounds each request; zero means 30s. Event streams outlive it
+	// by reconnecting where they left off.
+	Timeout time.Duration
+	// UserAgent is sent on every request when set.
+	UserAgent string
+}
+
+// Client talks to one engine. It is safe for concurrent use.
+type Client struct {
+	c *client.Client
+}
+
+// New returns a Client for the engine at baseURL, e.g. http://127.0.0.1:7777.
+func New(baseURL string, opts Options) *Client {
+	return &Client{c: client.New(baseURL, client.Options{
+		Timeout:   opts.Timeout,
+		AuthToken: opts.AuthToken,
+		UserAgent: opts.UserAgent,
+	})}
+}
+
+// Health returns the engine health; it does not need the auth token.
+func (c *Client) Health(ctx context.Context) (HealthResponse, error) {
+	return c.c.Health(ctx)
+}
+
+// Version returns the engine build and API version.
+func (c *Client) Version(ctx context.Context) (VersionResponse, error) {
+	return c.c.GetVersion(ctx)
+}
+
+// CheckVersion returns the engine version, or an error when the engine
+// serves a different API generation than APIVersion.
+func (c *Client) CheckVersion(ctx context.Context) (VersionResponse, error) {
+	version, err := c.c.GetVersion(ctx)
+	if err != nil {
+		return version, err
+	}
+	if version.APIVersion != APIVersion {
+		return version, fmt.Errorf("engine %s serves API %q, client expects %q", version.Version, version.APIVersion, APIVersion)
+	}
+	return version, nil
+}
+
+// Submit queues a prepare job. Paths in the request are resolved on the
+// engine host.
+func (c *Client) Submit(ctx context.Context, req PrepareJobRequest) (PrepareJobAccepted, error) {
+	return c.c.CreatePrepareJob(ctx, req)
+}
+
+// Get returns the current status of a prepare job.
+func (c *Client) Get(ctx context.Context, jobID string) (PrepareJobStatus, error) {
+	status, found, err := c.c.GetPrepareJob(ctx, jobID)
+	if err != nil {
+		return status, err
+	}
+	if !found {
+		return status, fmt.Errorf("prepare job %s: %w", jobID, ErrNotFound)
+	}
+	return status, nil
+}
+
+// ListJobs lists prepare jobs carrying every given label; no labels lists
+// all jobs.
+func (c *Client) ListJobs(ctx context.Context, labels map[string]string) ([]PrepareJobEntry, error) {
+	return c.c.ListPrepareJobsByLabels(ctx, labels)
+}
+
+// Tasks lists the tasks of a prepare job.
+func (c *Client) Tasks(ctx context.Context, jobID string) ([]TaskEntry, error) {
+	return c.c.ListTasks(ctx, jobID)
+}
+
+// Cancel asks the engine to stop a queued or running prepare job.
+func (c *Client) Cancel(ctx context.Context, jobID string) (PrepareJobStatus, error) {
+	status, code, err := c.c.CancelPrepareJob(ctx, jobID)
+	if code == http.StatusNotFound {
+		return status, fmt.Errorf("prepare job %s: %w", jobID, ErrNotFound)
+	}
+	return status, err
+}
+
+// Delete removes a finished prepare job. A job that is still running, or
+// whose removal is blocked, comes back with Outcome "blocked" and a nil
+// error; opts.Force deletes running jobs too.
+func (c *Client) Delete(ctx context.Context, jobID string, opts DeleteOptions) (DeleteResult, error) {
+	result, code, err := c.c.DeletePrepareJob(ctx, jobID, opts)
+	if code == http.StatusNotFound {
+		return result, fmt.Errorf("prepare job %s: %w", jobID, ErrNotFound)
+	}
+	return result, err
+}
+
+// StreamEvents delivers the events of a prepare job to handle, starting at
+// offset sinceOffset, until handle returns true or the job finishes.
+// Dropped connections are resumed from the last delivered event, so handle
+// sees every event exactly once.
+func (c *Client) StreamEvents(ctx context.Context, jobID string, sinceOffset int, handle EventHandler) error {
+	ended := func(ctx context.Context) (bool, error) {
+		status, err := c.Get(ctx, jobID)
+		if err != nil {
+			return false, err
+		}
+		return IsTerminal(status.Status), nil
+	}
+	return c.c.FollowPrepareEvents(ctx, jobEventsURL(jobID), handle, client.FollowOptions{
+		SinceOffset: sinceOffset,
+		Ended:       ended,
+	})
+}
+
+// Wait blocks until a prepare job finishes and returns its final status. A
+// failed job is not an error here; check Status and Error.
+func (c *Client) Wait(ctx context.Context, jobID string) (PrepareJobStatus, error) {
+	handle := func(_ int, event PrepareJobEvent) (bool, error) {
+		return event.Type == "status" && IsTerminal(event.Status), nil
+	}
+	if err := c.StreamEvents(ctx, jobID, 0, handle); err != nil {
+		return PrepareJobStatus{}, err
+	}
+	return c.Get(ctx, jobID)
+}
+
+// Plan submits req as a plan-only job and returns its finished status, whose
+// Tasks hold the plan. A failed plan is returned as an *ErrorResponseError
+// alongside the status.
+func (c *Client) Plan(ctx context.Context, req PrepareJobRequest) (PrepareJobStatus, error) {
+	req.PlanOnly = true
+	accepted, err := c.Submit(ctx, req)
+	if err != nil {
+		return PrepareJobStatus{}, err
+	}
+	status, err := c.Wait(ctx, accepted.JobID)
+	if err != nil {
+		return status, err
+	}
+	if status.Status == StatusFailed {
+		return status, jobError(status)
+	}
+	return status, nil
+}
+
+// ListStates lists states matching filters.
+func (c *Client) ListStates(ctx context.Context, filters ListFilters) ([]StateEntry, error) {
+	return c.c.ListStates(ctx, filters)
+}
+
+// GetState returns a state together with its ancestors.
+func (c *Client) GetState(ctx context.Context, stateID string) (StateDetail, error) {
+	state, found, err := c.c.GetState(ctx, stateID)
+	if err != nil {
+		return state, err
+	}
+	if !found {
+		return state, fmt.Errorf("state %s: %w", stateID, ErrNotFound)
+	}
+	return state, nil
+}
+
+// ListInstances lists instances matching filters.
+func (c *Client) ListInstances(ctx context.Context, filters ListFilters) ([]InstanceEntry, error) {
+	return c.c.ListInstances(ctx, filters)
+}
+
+// GetInstance returns an instance by id or name.
+func (c *Client) GetInstance(ctx context.Context, idOrName string) (InstanceEntry, error) {
+	instance, found, err := c.c.GetInstance(ctx, idOrName)
+	if err != nil {
+		return instance, err
+	}
+	if !found {
+		return instance, fmt.Errorf("instance %s: %w", idOrName, ErrNotFound)
+	}
+	return instance, nil
+}
+
+// IsTerminal reports whether a prepare job status is final.
+func IsTerminal(status string) bool {
+	return status == StatusSucceeded || status == StatusFailed
+}
+
+func jobEventsURL(jobID string) string {
+	return "/v1/prepare-jobs/" + url.PathEscape(jobID) + "/events"
+}
+
+func jobError(status PrepareJobStatus) error {
+	if status.Error == nil || strings.TrimSpace(status.Error.Message) == "" {
+		return fmt.Errorf("prepare job %s failed", status.JobID)
+	}
+	return &ErrorResponseError{
+		Code:    status.Error.Code,
+		Message: status.Error.Message,
+		Details: status.Error.Details,
+	}
+}
@@ -0,0 +1,163 @@
+package sqlrsclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCheckVersion(t *testing.T) {
+	apiVersion := APIVersion
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/version" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `{"version":"1.2.3","apiVersion":%q}`, apiVersion)
+	}))
+	defer server.Close()
+
+	c := New(server.URL, Options{})
+	if version, err := c.CheckVersion(context.Background()); err != nil || version.Version != "1.2.3" {
+		t.Fatalf("CheckVersion = %+v, %v", version, err)
+	}
+	apiVersion = "v2"
+	if _, err := c.CheckVersion(context.Background()); err == nil || !strings.Contains(err.Error(), `"v2"`) {
+		t.Fatalf("expected API version mismatch, got %v", err)
+	}
+}
+
+func TestPlanFollowsEventsAndSendsToken(t *testing.T) {
+	var mu sync.Mutex
+	var submitted string
+	var auth []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		auth = append(auth, r.Header.Get("Authorization"))
+		mu.Unlock()
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/prepare-jobs":
+			submitted = r.Header.Get("Content-Type")
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, `{"job_id":"job-1","status_url":"/v1/prepare-jobs/job-1","events_url":"/v1/prepare-jobs/job-1/events"}`)
+		case r.URL.Path == "/v1/prepare-jobs/job-1/events":
+			fmt.Fprintln(w, `{"type":"status","ts":"t0","status":"running"}`)
+			fmt.Fprintln(w, `{"type":"status","ts":"t1","status":"succeeded"}`)
+		case r.URL.Path == "/v1/prepare-jobs/job-1":
+			fmt.Fprint(w, `{"job_id":"job-1","status":"succeeded","plan_only":true,"tasks":[{"task_id":"plan","type":"plan"}]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	c := New(server.URL, Options{AuthToken: "secret"})
+	status, err := c.Plan(context.Background(), PrepareJobRequest{PrepareKind: "psql", ImageID: "postgres:17"})
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	if !status.PlanOnly || len(status.Tasks) != 1 || status.Tasks[0].TaskID != "plan" {
+		t.Fatalf("unexpected plan status: %+v", status)
+	}
+	if submitted != "application/json" {
+		t.Fatalf("unexpected submit content type %q", submitted)
+	}
+	for _, got := range auth {
+		if got != "Bearer secret" {
+			t.Fatalf("expected bearer token on every request, got %q", auth)
+		}
+	}
+}
+
+func TestPlanReturnsJobError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/prepare-jobs":
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, `{"job_id":"job-1","status_url":"/v1/prepare-jobs/job-1"}`)
+		case "/v1/prepare-jobs/job-1/events":
+			fmt.Fprintln(w, `{"type":"status","ts":"t0","status":"failed"}`)
+		case "/v1/prepare-jobs/job-1":
+			fmt.Fprint(w, `{"job_id":"job-1","status":"failed","error":{"code":"image_not_found","message":"image missing"}}`)
+		}
+	}))
+	defer server.Close()
+
+	_, err := New(server.URL, Options{}).Plan(context.Background(), PrepareJobRequest{})
+	var errResp *ErrorResponseError
+	if !errors.As(err, &errResp) || errResp.Code != "image_not_found" {
+		t.Fatalf("expected job error, got %v", err)
+	}
+}
+
+func TestStreamEventsReconnectsUntilJobEnds(t *testing.T) {
+	var mu sync.Mutex
+	var ranges []string
+	statusCalls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.URL.Path {
+		case "/v1/prepare-jobs/job-1/events":
+			ranges = append(ranges, r.Header.Get("Range"))
+			if len(ranges) == 1 {
+				// A chunked body that just stops, like a dropped proxy.
+				fmt.Fprintln(w, `{"type":"log","ts":"t0","message":"one"}`)
+				w.(http.Flusher).Flush()
+				return
+			}
+			w.Header().Set("Content-Range", "events 1-1/2")
+			w.WriteHeader(http.StatusPartialContent)
+			fmt.Fprintln(w, `{"type":"log","ts":"t1","message":"two"}`)
+		case "/v1/prepare-jobs/job-1":
+			statusCalls++
+			status := "running"
+			if statusCalls > 1 {
+				status = "succeeded"
+			}
+			fmt.Fprintf(w, `{"job_id":"job-1","status":%q}`, status)
+		}
+	}))
+	defer server.Close()
+
+	var got []string
+	err := New(server.URL, Options{Timeout: time.Second}).StreamEvents(context.Background(), "job-1", 0, func(index int, event PrepareJobEvent) (bool, error) {
+		got = append(got, fmt.Sprintf("%d:%s", index, event.Message))
+		return false, nil
+	})
+	if err != nil {
+		t.Fatalf("StreamEvents: %v", err)
+	}
+	if strings.Join(got, ",") != "0:one,1:two" {
+		t.Fatalf("unexpected events: %v", got)
+	}
+	if len(ranges) != 2 || ranges[1] != "events=1-" {
+		t.Fatalf("unexpected Range headers: %q", ranges)
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	c := New(server.URL, Options{})
+	ctx := context.Background()
+	if _, err := c.Get(ctx, "job-1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get: expected ErrNotFound, got %v", err)
+	}
+	if _, err := c.Delete(ctx, "job-1", DeleteOptions{}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Delete: expected ErrNotFound, got %v", err)
+	}
+	if _, err := c.GetState(ctx, "state-1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetState: expected ErrNotFound, got %v", err)
+	}
+	if _, err := c.GetInstance(ctx, "dev"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetInstance: expected ErrNotFound, got %v", err)
+	}
+}